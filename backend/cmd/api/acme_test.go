@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"golang.org/x/crypto/acme"
+
+	"pmv2/backend/internal/config"
+)
+
+func testACMEConfig(t *testing.T) config.Config {
+	return config.Config{
+		ACMEDomains:      []string{"vault.example.com"},
+		ACMEEmail:        "ops@example.com",
+		ACMECacheDir:     t.TempDir(),
+		ACMEDirectoryURL: "https://acme-staging.example.com/directory",
+	}
+}
+
+func TestACMEManagerOnlyServesConfiguredDomains(t *testing.T) {
+	m := newACMEManager(testACMEConfig(t))
+
+	if err := m.HostPolicy(context.Background(), "vault.example.com"); err != nil {
+		t.Fatalf("configured domain refused: %v", err)
+	}
+	for _, host := range []string{"evil.example.com", "example.com", "127.0.0.1"} {
+		if err := m.HostPolicy(context.Background(), host); err == nil {
+			t.Errorf("HostPolicy(%s) allowed an unconfigured name", host)
+		}
+	}
+	if m.Client == nil || m.Client.DirectoryURL != "https://acme-staging.example.com/directory" {
+		t.Fatal("ACME_DIRECTORY_URL was not applied")
+	}
+	if m.Email != "ops@example.com" {
+		t.Fatalf("Email = %q", m.Email)
+	}
+}
+
+func TestACMEManagerDefaultsToLetsEncrypt(t *testing.T) {
+	cfg := testACMEConfig(t)
+	cfg.ACMEDirectoryURL = ""
+	if m := newACMEManager(cfg); m.Client != nil {
+		t.Fatalf("Client = %+v, want nil so autocert uses Let's Encrypt", m.Client)
+	}
+}
+
+func TestUseACMEAnswersTLSALPNChallenges(t *testing.T) {
+	tlsConfig := newTLSConfig("1.2")
+	useACME(tlsConfig, newACMEManager(testACMEConfig(t)))
+
+	if tlsConfig.GetCertificate == nil {
+		t.Fatal("GetCertificate was not set")
+	}
+	if !slices.Contains(tlsConfig.NextProtos, acme.ALPNProto) {
+		t.Fatalf("NextProtos = %v, want %s", tlsConfig.NextProtos, acme.ALPNProto)
+	}
+}
+
+func TestACMEHTTPHandlerFallsBackToRedirect(t *testing.T) {
+	handler := newACMEManager(testACMEConfig(t)).HTTPHandler(redirectToHTTPS("443"))
+
+	req := httptest.NewRequest(http.MethodGet, "/vault/items", nil)
+	req.Host = "vault.example.com"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "https://vault.example.com/vault/items" {
+		t.Fatalf("got %d %q, want a redirect to HTTPS", rec.Code, rec.Header().Get("Location"))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/.well-known/acme-challenge/unknown-token", nil)
+	req.Host = "vault.example.com"
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code == http.StatusMovedPermanently {
+		t.Fatal("an HTTP-01 challenge request was redirected instead of answered")
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"pmv2/backend/internal/breach"
+)
+
+// buildBreachFilter turns a Pwned Passwords hash list into the bloom filter
+// BREACH_CHECK_BLOOM_PATH loads, for checking passwords without the range
+// API.
+func buildBreachFilter(w io.Writer, listPath, outPath string, minCount int, fpRate float64) error {
+	list, err := os.Open(listPath)
+	if err != nil {
+		return fmt.Errorf("open hash list: %w", err)
+	}
+	defer list.Close()
+
+	filter, n, err := breach.BuildBloomFilter(list, minCount, fpRate)
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("create breach filter: %w", err)
+	}
+	size, err := filter.WriteTo(out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write breach filter: %w", err)
+	}
+	fmt.Fprintf(w, "# Wrote %d hashes to %s (%d bytes, false positive rate %g).\nBREACH_CHECK_BLOOM_PATH=%s\n", n, outPath, size, fpRate, outPath)
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"pmv2/backend/internal/util"
+)
+
+// calibrateKDF benchmarks Argon2id on this host and prints password hashing
+// parameters that take target within a memory budget, as environment
+// variables ready for .env.
+func calibrateKDF(w io.Writer, target time.Duration, memoryKiB, parallelism int) error {
+	if target <= 0 {
+		return fmt.Errorf("-kdf-target must be positive")
+	}
+	if memoryKiB < util.Argon2MinMemoryKiB {
+		return fmt.Errorf("-kdf-memory-kib=%d is below the %d KiB Argon2id minimum", memoryKiB, util.Argon2MinMemoryKiB)
+	}
+	if parallelism < 1 || parallelism > 255 {
+		return fmt.Errorf("-kdf-parallelism must be between 1 and 255")
+	}
+
+	params, took := util.CalibrateArgon2(target, uint32(memoryKiB), uint8(parallelism))
+	fmt.Fprintf(w, "# Argon2id with %d KiB, %d passes and %d lanes takes %s per hash on this host (target %s).\n",
+		params.Memory, params.Iterations, params.Parallelism, took.Round(time.Millisecond), target)
+	if took < target {
+		fmt.Fprintf(w, "# Even %d passes fall short of the target; raise -kdf-memory-kib.\n", params.Iterations)
+	}
+	fmt.Fprintf(w, "PASSWORD_HASH_MEMORY_KIB=%d\nPASSWORD_HASH_ITERATIONS=%d\nPASSWORD_HASH_PARALLELISM=%d\n",
+		params.Memory, params.Iterations, params.Parallelism)
+	return nil
+}
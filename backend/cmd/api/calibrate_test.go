@@ -0,0 +1,23 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalibrateKDFPrintsEnvironment(t *testing.T) {
+	var out bytes.Buffer
+	if err := calibrateKDF(&out, time.Millisecond, 19456, 1); err != nil {
+		t.Fatalf("calibrateKDF: %v", err)
+	}
+	for _, want := range []string{"PASSWORD_HASH_MEMORY_KIB=19456\n", "PASSWORD_HASH_ITERATIONS=", "PASSWORD_HASH_PARALLELISM=1\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output is missing %q:\n%s", want, out.String())
+		}
+	}
+	if err := calibrateKDF(&out, time.Second, 1024, 1); err == nil {
+		t.Fatal("calibrateKDF accepted a memory budget below the Argon2id minimum")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"pmv2/backend/internal/config"
+	"pmv2/backend/internal/grpcapi"
+	"pmv2/backend/internal/service"
+)
+
+// newGRPCServer builds the gRPC server. apiTLS is the REST listener's TLS
+// config, or nil when the API runs without TLS; the gRPC listener then
+// serves plaintext too.
+func newGRPCServer(cfg config.Config, log *slog.Logger, apiTLS *tls.Config, authService *service.AuthService, vaultService *service.VaultService) (*grpc.Server, error) {
+	var opts []grpc.ServerOption
+	if apiTLS != nil {
+		tlsConfig := apiTLS.Clone()
+		if tlsConfig.GetCertificate == nil {
+			cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	return grpcapi.NewServer(grpcapi.Config{WatchInterval: cfg.GRPCWatchInterval}, authService, vaultService, log, opts...), nil
+}
+
+func serveGRPC(srv *grpc.Server, port string, log *slog.Logger) error {
+	lis, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return err
+	}
+	go func() {
+		log.Info("grpc listening", slog.String("port", port))
+		if err := srv.Serve(lis); err != nil {
+			log.Error("grpc server error", slog.Any("error", err))
+		}
+	}()
+	return nil
+}
+
+// stopGRPC drains in-flight RPCs until ctx expires, then closes the rest.
+// Watch streams only end when the client goes away, so the hard stop is the
+// usual path while any client is watching.
+func stopGRPC(ctx context.Context, srv *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		srv.Stop()
+	}
+}
@@ -2,27 +2,88 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
+	"sort"
 	"syscall"
 	"time"
+	// Share schedules name IANA timezones; the runtime image has no
+	// zoneinfo of its own.
+	_ "time/tzdata"
 
+	"pmv2/backend/internal/blobstore"
+	"pmv2/backend/internal/breach"
+	"pmv2/backend/internal/buildinfo"
 	"pmv2/backend/internal/config"
 	"pmv2/backend/internal/database"
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/geoip"
+	"pmv2/backend/internal/i18n"
+	"pmv2/backend/internal/jobs"
+	"pmv2/backend/internal/kms"
 	"pmv2/backend/internal/logger"
+	"pmv2/backend/internal/mailer"
+	"pmv2/backend/internal/metrics"
+	"pmv2/backend/internal/middlewares"
+	"pmv2/backend/internal/outbox"
+	"pmv2/backend/internal/push"
 	"pmv2/backend/internal/repository"
 	"pmv2/backend/internal/router"
+	"pmv2/backend/internal/safehttp"
 	"pmv2/backend/internal/service"
+	"pmv2/backend/internal/siem"
+	"pmv2/backend/internal/telemetry"
+	"pmv2/backend/internal/util"
+	"pmv2/backend/internal/webauthn"
+	"pmv2/backend/internal/webhook"
+	"pmv2/backend/internal/worker"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
 )
 
 func main() {
-	cfg := config.Load()
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file; environment variables override its values")
+	calibrate := flag.Bool("calibrate-kdf", false, "benchmark Argon2id on this host, print password hashing parameters that take -kdf-target within -kdf-memory-kib, and exit")
+	kdfTarget := flag.Duration("kdf-target", 500*time.Millisecond, "with -calibrate-kdf, the time one password hash should take")
+	kdfMemory := flag.Int("kdf-memory-kib", 65536, "with -calibrate-kdf, the memory one password hash may use, in KiB")
+	kdfParallelism := flag.Int("kdf-parallelism", 2, "with -calibrate-kdf, the Argon2id lanes per hash")
+	breachList := flag.String("build-breach-filter", "", "build a breached password bloom filter from this Pwned Passwords hash list (SHA1:COUNT lines), write it to -breach-filter-out, and exit")
+	breachOut := flag.String("breach-filter-out", "breached-passwords.bloom", "with -build-breach-filter, the file to write the filter to")
+	breachMinCount := flag.Int("breach-filter-min-count", 1, "with -build-breach-filter, leave out hashes seen fewer times than this")
+	breachFPRate := flag.Float64("breach-filter-fp-rate", 0.001, "with -build-breach-filter, the false positive rate of the filter")
+	flag.Parse()
+
+	if *calibrate {
+		if err := calibrateKDF(os.Stdout, *kdfTarget, *kdfMemory, *kdfParallelism); err != nil {
+			fmt.Fprintln(os.Stderr, "FATAL: "+err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if *breachList != "" {
+		if err := buildBreachFilter(os.Stdout, *breachList, *breachOut, *breachMinCount, *breachFPRate); err != nil {
+			fmt.Fprintln(os.Stderr, "FATAL: "+err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	cfg, err := config.LoadFile(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "FATAL: "+err.Error())
+		os.Exit(1)
+	}
 
-	// Validate security-critical config (e.g. pepper) for production environments.
-	if err := cfg.ValidateForProduction(); err != nil {
+	// Fail fast on any config problem; production additionally rejects the
+	// development defaults (pepper, database DSN, ...).
+	if err := cfg.Validate(); err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
@@ -31,67 +92,871 @@ func main() {
 	log, logFile := logger.New(cfg)
 	defer logFile.Close()
 
+	build := buildinfo.Get()
+	log.Info("starting pmv2-api",
+		slog.String("version", build.Version),
+		slog.String("commit", build.Commit),
+		slog.String("build_time", build.BuildTime),
+		slog.String("go_version", build.GoVersion),
+	)
+
+	checkPasswordHashCost(cfg, log)
+
 	ctx := context.Background()
 
-	postgres, err := database.New(ctx, cfg.DatabaseURL)
+	store, closeStore, err := newStore(ctx, cfg, log)
 	if err != nil {
 		log.Error("database init failed", slog.Any("error", err))
 		os.Exit(1)
 	}
 	defer func() {
-		if err := postgres.Close(); err != nil {
+		if err := closeStore(); err != nil {
 			log.Error("database close failed", slog.Any("error", err))
 		}
 	}()
+	if store, err = encryptMetadata(ctx, cfg, store); err != nil {
+		log.Error("metadata encryption init failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+	store, closeAuthCache, err := cacheAuthRecords(ctx, cfg, store)
+	if err != nil {
+		log.Error("auth cache init failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+	defer closeAuthCache()
 
-	authRepository := repository.NewAuthRepository(postgres.SQL())
-	vaultRepository := repository.NewVaultRepository(postgres.SQL())
-	folderRepository := repository.NewPostgresFolderRepository(postgres.SQL())
-	userKeysRepository := repository.NewUserKeysRepository(postgres.SQL())
-	sharingRepository := repository.NewSharingRepository(postgres.SQL())
-	familyRepository := repository.NewFamilyRepository(postgres.SQL())
-	auditRepository := repository.NewAuditRepository(postgres.SQL())
-
-	auditService := service.NewAuditService(auditRepository)
-	authService := service.NewAuthService(authRepository, auditService, cfg.AuthPepper, cfg.SessionTTL, cfg.TOTPIssuer)
-	vaultService := service.NewVaultService(vaultRepository, auditService)
-	folderService := service.NewFolderService(folderRepository)
-	sharingService := service.NewSharingService(sharingRepository, userKeysRepository, vaultRepository, familyRepository, auditService)
-	familyService := service.NewFamilyService(familyRepository, authRepository, sharingService, auditService)
-
-	go func() {
-		ticker := time.NewTicker(1 * time.Hour)
-		defer ticker.Stop()
-		for range ticker.C {
-			deleted, err := authRepository.DeleteExpiredSessions(context.Background())
-			if err != nil {
-				log.Error("failed to delete expired sessions", slog.Any("error", err))
-			} else if deleted > 0 {
-				log.Info("deleted expired/revoked sessions", slog.Int64("count", deleted))
-			}
+	messages := i18n.NewBundle()
+	if cfg.I18nCatalogDir != "" {
+		if err := i18n.LoadDir(messages, cfg.I18nCatalogDir); err != nil {
+			log.Error("message catalogs load failed", slog.Any("error", err))
+			os.Exit(1)
 		}
-	}()
+	}
 
+	emailer, err := mailer.New(newMailSender(cfg, log), store.Outbox(), messages)
+	if err != nil {
+		log.Error("mailer init failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	auditService := service.NewAuditService(store.Audit(), store.Outbox())
+	authService := service.NewAuthService(store.Auth(), store.Transactor(), auditService, cfg.AuthPepper, cfg.SessionTTL, cfg.TOTPIssuer)
+	authService.UsePasswordHashParams(passwordHashParams(cfg))
+	hashConcurrency := cfg.PasswordHashConcurrency
+	if hashConcurrency == 0 {
+		hashConcurrency = runtime.NumCPU()
+	}
+	authService.UsePasswordHashLimit(hashConcurrency, cfg.PasswordHashQueueTimeout)
+	authService.UseDeviceTracking(store.Devices())
+	authService.UsePasskeys(store.Passkeys(), webauthn.RelyingParty{ID: cfg.WebAuthnRPID, Name: cfg.WebAuthnRPName, Origins: cfg.WebAuthnOrigins})
+	authService.UseQRLogin(store.QRLogins())
+	authService.UsePasswordResets(store.PasswordResets(), emailer, cfg.FrontendOrigin)
+	authService.UseVaultKeys(store.Vault())
+	authService.UseAccountMerge(store.Admin())
+	sessionEvents := service.NewSessionEvents()
+	authService.UseSessionEvents(sessionEvents, cfg.SessionEventsCheckInterval)
+	if cfg.AccessTokenTTL > 0 {
+		authService.UseAccessTokens(service.NewAccessTokens(cfg.AuthPepper, cfg.AccessTokenTTL))
+	}
+	authService.UseDPoP(cfg.DPoPClientTypes)
+	if cfg.LoginFailureAlertThreshold > 0 {
+		authService.UseLoginFailureAlerts(cfg.LoginFailureAlertThreshold, cfg.LoginFailureAlertWindow)
+	}
+	if cfg.BreachCheckEnabled {
+		checker, err := newBreachChecker(cfg)
+		if err != nil {
+			log.Error("breach check init failed", slog.Any("error", err))
+			os.Exit(1)
+		}
+		authService.UseBreachCheck(checker)
+	}
+	if cfg.EmailAliasNormalization {
+		authService.UseEmailAliasNormalization()
+	}
+	revokeLinks := service.NewSessionRevokeLinks(cfg.AuthPepper, cfg.RevokeLinkTTL)
+	authService.UseSessionRevokeLinks(revokeLinks)
+	vaultService := service.NewVaultService(store.Vault(), store.Folders(), store.Transactor(), auditService)
+	vaultService.UseAutofill(store.Autofill())
+	vaultService.UseExports(store.Attachments(), store.Backups())
+	folderService := service.NewFolderService(store.Folders(), auditService)
+	sharingService := service.NewSharingService(store.Sharing(), store.UserKeys(), store.Vault(), store.Family(), auditService)
+	familyService := service.NewFamilyService(store.Family(), store.Auth(), sharingService, auditService)
+	notificationService := service.NewNotificationService(store.Notifications(), auditService, emailer)
+	notificationService.UseSessionRevokeLinks(revokeLinks, cfg.FrontendOrigin)
+	adminService := service.NewAdminService(store.Admin(), store.Auth(), auditService, cfg.AdminStatsCacheTTL)
+	adminService.UseSessionEvents(sessionEvents)
+	if err := adminService.LoadMaintenance(ctx); err != nil {
+		log.Warn("load maintenance state failed; starting without it", slog.Any("error", err))
+	}
+	if cfg.MaintenanceMode {
+		adminService.ForceMaintenance(cfg.MaintenanceMessage)
+		log.Warn("maintenance mode forced by MAINTENANCE_MODE")
+	}
+	dataExportService := service.NewDataExportService(store.DataExports(), store.Admin(), store.Vault(), store.Folders(), store.Outbox(), store.Transactor(), auditService, emailer, cfg.DataExportTTL)
+	if cfg.AnomalyDetectionEnabled {
+		anomalyService, err := newAnomalyService(cfg, store, auditService)
+		if err != nil {
+			log.Error("anomaly detection init failed", slog.Any("error", err))
+			os.Exit(1)
+		}
+		anomalyService.UseSessionEvents(sessionEvents)
+		authService.UseAnomalyDetection(anomalyService)
+		vaultService.UseAnomalyDetection(anomalyService)
+		dataExportService.UseAnomalyDetection(anomalyService)
+	}
+	webhookSecretKey := util.DeriveWebhookSecretKey(cfg.AuthPepper)
+	webhookService := service.NewWebhookService(store.Webhooks(), auditService, notificationService, webhookSecretKey, cfg.WebhookAllowHTTP, cfg.WebhookAllowPrivateNetworks)
+	auditService.Subscribe(webhookService.HandleAuditEvent)
+	auditService.Subscribe(notificationService.HandleAuditEvent)
+	pushProvider, err := newPushProvider(cfg, log)
+	if err != nil {
+		log.Error("push provider init failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+	pushService := service.NewPushService(store.PushTokens(), store.Outbox(), notificationService, pushProvider, cfg.PushTokenTTL)
+	auditService.Subscribe(pushService.HandleAuditEvent)
+	ipBanService := service.NewIPBanService(store.IPBans(), store.Transactor(), auditService, service.IPBanPolicy{
+		Threshold:    cfg.IPBanThreshold,
+		Window:       cfg.IPBanWindow,
+		BaseDuration: cfg.IPBanBaseDuration,
+		MaxDuration:  cfg.IPBanMaxDuration,
+	})
+	if cfg.IPBanEnabled {
+		if err := ipBanService.LoadBans(ctx); err != nil {
+			log.Warn("load ip bans failed; starting without them", slog.Any("error", err))
+		}
+		auditService.Subscribe(ipBanService.HandleAuditEvent)
+	}
+	blobs, err := blobstore.New(cfg.BlobStore, cfg.BlobStoreLocalDir, blobstore.S3Config{
+		Endpoint:        cfg.BlobStoreS3Endpoint,
+		Region:          cfg.BlobStoreS3Region,
+		Bucket:          cfg.BlobStoreS3Bucket,
+		Prefix:          cfg.BlobStoreS3Prefix,
+		AccessKeyID:     cfg.BlobStoreS3AccessKeyID,
+		SecretAccessKey: cfg.BlobStoreS3SecretAccessKey,
+		PathStyle:       cfg.BlobStoreS3PathStyle,
+	})
+	if err != nil {
+		log.Error("blob store init failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+	if blobs != nil {
+		adminService.UseBlobStore(blobs)
+	}
+	attachmentService := service.NewAttachmentService(store.Attachments(), store.Vault(), blobs, auditService, int64(cfg.AttachmentMaxBytes))
+	attachmentService.UseSignedURLs(cfg.BlobStoreSignedURLTTL)
+	machineSecretService := service.NewMachineSecretService(store.MachineSecrets(), store.Vault(), auditService, cfg.AuthPepper)
+	accessRequestService := service.NewAccessRequestService(store.Sharing(), store.Family(), store.Transactor(), auditService)
+	shareInvitationService := service.NewShareInvitationService(store.Sharing(), store.Auth(), store.Transactor(), auditService, emailer, cfg.FrontendOrigin)
+	if cfg.EmailAliasNormalization {
+		shareInvitationService.UseEmailAliasNormalization()
+	}
+	auditService.Subscribe(shareInvitationService.HandleAuditEvent)
+
+	rateLimitStore, closeRateLimitStore, err := newRateLimitStore(ctx, cfg)
+	if err != nil {
+		log.Error("rate limit store init failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+	defer closeRateLimitStore()
+
+	workers := worker.NewManager(log)
+	if memoryStore, ok := rateLimitStore.(*middlewares.MemoryRateLimitStore); ok {
+		workers.Go("rate-limit-cleanup", memoryStore.RunCleanup)
+	}
+	scheduler, err := newScheduler(cfg, log, store.Jobs(), store.Outbox(), store.Webhooks(), authService, vaultService, attachmentService, sharingService, auditService, dataExportService, ipBanService, pushService, shareInvitationService)
+	if err != nil {
+		log.Error("job scheduler init failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+	if cfg.TelemetryEnabled {
+		reporter := newTelemetryReporter(cfg, adminService)
+		scheduler.Register(jobs.Job{
+			Name:     "telemetry",
+			Schedule: jobs.Jitter(jobs.Every(cfg.TelemetryInterval), cfg.TelemetryInterval/10),
+			Run:      reporter.Send,
+		})
+		log.Info("anonymous usage telemetry enabled", slog.String("endpoint", cfg.TelemetryEndpoint))
+	}
+	workers.Go("job-scheduler", scheduler.Run)
+	if cfg.IPBanEnabled {
+		workers.Go("ip-ban-sync", func(ctx context.Context) {
+			ipBanService.SyncBans(ctx, cfg.IPBanSyncInterval, func(err error) {
+				log.Warn("reload ip bans failed", slog.Any("error", err))
+			})
+		})
+	}
+	workers.Go("maintenance-sync", func(ctx context.Context) {
+		adminService.SyncMaintenance(ctx, cfg.MaintenanceSyncInterval, func(err error) {
+			log.Warn("reload maintenance state failed", slog.Any("error", err))
+		})
+	})
+
+	dispatcher := outbox.NewDispatcher(store.Outbox(), log, outbox.Config{
+		PollInterval: cfg.OutboxPollInterval,
+		MaxAttempts:  cfg.OutboxMaxAttempts,
+	})
+	dispatcher.Handle(domain.OutboxTopicAudit, auditService.HandleOutboxEvent)
+	dispatcher.Handle(domain.OutboxTopicEmail, emailer.HandleOutboxEvent)
+	dispatcher.Handle(domain.OutboxTopicDataExport, dataExportService.HandleOutboxEvent)
+	dispatcher.Handle(domain.OutboxTopicPush, pushService.HandleOutboxEvent)
+	workers.Go("outbox-dispatcher", dispatcher.Run)
+
+	deliverer := webhook.NewDeliverer(store.Webhooks(), log, webhook.Config{
+		PollInterval:         cfg.WebhookPollInterval,
+		MaxAttempts:          cfg.WebhookMaxAttempts,
+		Timeout:              cfg.WebhookTimeout,
+		SecretKey:            webhookSecretKey,
+		AllowPrivateNetworks: cfg.WebhookAllowPrivateNetworks,
+		Client: safehttp.NewClient(safehttp.Config{
+			Timeout:              cfg.WebhookTimeout,
+			MaxResponseBytes:     int64(cfg.OutboundMaxResponseBytes),
+			AllowPrivateNetworks: cfg.WebhookAllowPrivateNetworks,
+			Quota:                rateLimitStore,
+			QuotaRate:            rate.Limit(float64(cfg.OutboundUserQuota) / cfg.OutboundUserQuotaWindow.Seconds()),
+			QuotaBurst:           cfg.OutboundUserQuota,
+		}),
+	})
+	workers.Go("webhook-deliverer", deliverer.Run)
+
+	if sink, err := newSIEMSink(cfg); err != nil {
+		log.Error("siem exporter init failed", slog.Any("error", err))
+		os.Exit(1)
+	} else if sink != nil {
+		exporter := siem.NewExporter(store.Audit(), sink, log, siem.Config{
+			Name:          "siem-" + cfg.SIEMExporter,
+			BatchSize:     cfg.SIEMBatchSize,
+			FlushInterval: cfg.SIEMFlushInterval,
+		})
+		workers.Go("siem-exporter", exporter.Run)
+	}
+
+	handlers := router.NewRouter(cfg, log, rateLimitStore, auditService, authService, vaultService, attachmentService, folderService, sharingService, familyService, webhookService, notificationService, pushService, adminService, dataExportService, ipBanService, machineSecretService, accessRequestService, shareInvitationService, messages)
 	httpServer := &http.Server{
 		Addr:         ":" + cfg.Port,
-		Handler:      router.NewRouter(cfg, log, auditService, authService, vaultService, folderService, sharingService, familyService),
+		Handler:      handlers.API,
 		ReadTimeout:  cfg.ReadTimeout,
 		WriteTimeout: cfg.WriteTimeout,
 		IdleTimeout:  cfg.IdleTimeout,
 	}
+	configureHTTP2(httpServer, cfg)
+	servers := []*http.Server{httpServer}
 
-	go func() {
-		log.Info("api listening", slog.String("port", cfg.Port), slog.String("env", cfg.Env))
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Error("server error", slog.Any("error", err))
+	if cfg.TLSEnabled() {
+		httpServer.TLSConfig = newTLSConfig(cfg.TLSMinVersion)
+		redirect := redirectToHTTPS(cfg.Port)
+		if cfg.ACMEEnabled() {
+			manager := newACMEManager(cfg)
+			useACME(httpServer.TLSConfig, manager)
+			// HTTP-01 challenges arrive on the redirect port (must be 80).
+			redirect = manager.HTTPHandler(redirect)
+		}
+
+		go func() {
+			log.Info("api listening (tls)", slog.String("port", cfg.Port), slog.String("env", cfg.Env))
+			if err := httpServer.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile); err != nil && err != http.ErrServerClosed {
+				log.Error("server error", slog.Any("error", err))
+				os.Exit(1)
+			}
+		}()
+
+		if cfg.HTTPRedirectPort != "" {
+			redirectServer := newRedirectServer(cfg.HTTPRedirectPort, redirect)
+			servers = append(servers, redirectServer)
+			go func() {
+				log.Info("http redirect listening", slog.String("port", cfg.HTTPRedirectPort))
+				if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					log.Error("redirect server error", slog.Any("error", err))
+					os.Exit(1)
+				}
+			}()
+		}
+	} else {
+		go func() {
+			log.Info("api listening", slog.String("port", cfg.Port), slog.String("env", cfg.Env))
+			if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Error("server error", slog.Any("error", err))
+				os.Exit(1)
+			}
+		}()
+	}
+
+	if handlers.Ops != nil {
+		opsTLS, err := newOpsTLSConfig(cfg.TLSMinVersion, cfg.OpsClientCAFile)
+		if err != nil {
+			log.Error("ops listener init failed", slog.Any("error", err))
 			os.Exit(1)
 		}
-	}()
+		opsServer := &http.Server{
+			Addr:        ":" + cfg.OpsPort,
+			Handler:     handlers.Ops,
+			TLSConfig:   opsTLS,
+			ReadTimeout: cfg.ReadTimeout,
+			IdleTimeout: cfg.IdleTimeout,
+			// No WriteTimeout: CPU profiles and traces stream for as long as
+			// the caller asks.
+		}
+		configureHTTP2(opsServer, cfg)
+		servers = append(servers, opsServer)
+		go func() {
+			log.Info("ops listening (mtls)", slog.String("port", cfg.OpsPort))
+			if err := opsServer.ListenAndServeTLS(cfg.OpsTLSCertFile, cfg.OpsTLSKeyFile); err != nil && err != http.ErrServerClosed {
+				log.Error("ops server error", slog.Any("error", err))
+				os.Exit(1)
+			}
+		}()
+	}
 
-	shutdown(httpServer, log)
+	var grpcServer *grpc.Server
+	if cfg.GRPCEnabled() {
+		grpcServer, err = newGRPCServer(cfg, log, httpServer.TLSConfig, authService, vaultService)
+		if err == nil {
+			err = serveGRPC(grpcServer, cfg.GRPCPort, log)
+		}
+		if err != nil {
+			log.Error("grpc listener init failed", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+
+	shutdown(workers, log, grpcServer, servers...)
 }
 
-func shutdown(srv *http.Server, log *slog.Logger) {
+// newStore opens the database named by DATABASE_URL or, with APP_ENV=demo,
+// an in-memory store seeded with the sample accounts of cmd/seed. The
+// database is retried while it comes up, as DATABASE_CONNECT_* configure; a
+// DATABASE_REPLICA_URL that cannot be reached at startup is logged and
+// skipped. Calls to the primary go through the DATABASE_BREAKER_* circuit
+// breaker. The returned close func releases the database.
+func newStore(ctx context.Context, cfg config.Config, log *slog.Logger) (domain.Store, func() error, error) {
+	if cfg.IsDemo() {
+		store := repository.NewMemoryStore(repository.NewMemoryDB())
+		seeder := database.NewSeeder(nil, store.Auth(), store.Vault(), store.Folders(), store.Audit(), store.Family(), cfg.AuthPepper)
+		if err := seeder.SeedAll(ctx); err != nil {
+			return nil, nil, fmt.Errorf("seed demo store: %w", err)
+		}
+		return store, func() error { return nil }, nil
+	}
+
+	db, err := database.Connect(ctx, cfg.DatabaseURL, database.Retry{
+		MaxWait:        cfg.DatabaseConnectMaxWait,
+		InitialBackoff: cfg.DatabaseConnectBackoff,
+		MaxBackoff:     cfg.DatabaseConnectMaxBackoff,
+		OnRetry: func(attempt int, wait time.Duration, err error) {
+			log.Warn("database unavailable; retrying", slog.Int("attempt", attempt), slog.Duration("wait", wait), slog.Any("error", err))
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.DatabaseBreakerThreshold > 0 {
+		db.UseCircuitBreaker(database.NewBreaker(cfg.DatabaseBreakerThreshold, cfg.DatabaseBreakerCooldown))
+	}
+	if cfg.DatabaseReplicaURL != "" {
+		if err := db.AttachReplica(ctx, cfg.DatabaseReplicaURL); err != nil {
+			log.Warn("read replica unavailable; reading from the primary", slog.Any("error", err))
+		}
+	}
+	store, err := repository.NewStore(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, nil, err
+	}
+	return store, db.Close, nil
+}
+
+// encryptMetadata wraps store so session and audit metadata is encrypted
+// with a key from the configured KMS provider; without one, store is
+// returned unchanged.
+func encryptMetadata(ctx context.Context, cfg config.Config, store domain.Store) (domain.Store, error) {
+	provider, err := kms.New(cfg.KMSProvider, cfg.KMSLocalKey)
+	if err != nil || provider == nil {
+		return store, err
+	}
+	key, err := provider.DataKey(ctx, "metadata")
+	if err != nil {
+		return nil, fmt.Errorf("fetch metadata key: %w", err)
+	}
+	cipher, err := util.NewMetadataCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return repository.NewEncryptedStore(store, cipher), nil
+}
+
+// newMailSender picks SMTP delivery or, by default, the log-only sender.
+// checkPasswordHashCost times one password hash with the parameters new
+// passwords get and warns when it is under PASSWORD_HASH_MIN_DURATION: on
+// this hardware they would make offline guessing cheaper than intended.
+func checkPasswordHashCost(cfg config.Config, log *slog.Logger) {
+	if cfg.PasswordHashMinDuration <= 0 {
+		return
+	}
+	params := passwordHashParams(cfg)
+	start := time.Now()
+	if _, _, err := util.HashPassword("pmv2-startup-benchmark", params); err != nil {
+		log.Warn("password hash benchmark failed", slog.Any("error", err))
+		return
+	}
+	if took := time.Since(start); took < cfg.PasswordHashMinDuration {
+		log.Warn("password hashing is faster than PASSWORD_HASH_MIN_DURATION; consider stronger Argon2id parameters",
+			slog.Duration("took", took),
+			slog.Duration("min", cfg.PasswordHashMinDuration),
+			slog.Uint64("memory_kib", uint64(params.Memory)),
+			slog.Uint64("iterations", uint64(params.Iterations)),
+			slog.Uint64("parallelism", uint64(params.Parallelism)),
+		)
+	}
+}
+
+func passwordHashParams(cfg config.Config) domain.Argon2Params {
+	params := util.DefaultArgon2Params()
+	params.Memory = uint32(cfg.PasswordHashMemoryKiB)
+	params.Iterations = uint32(cfg.PasswordHashIterations)
+	params.Parallelism = uint8(cfg.PasswordHashParallelism)
+	return params
+}
+
+func newMailSender(cfg config.Config, log *slog.Logger) mailer.Sender {
+	if cfg.MailerDriver != "smtp" {
+		return mailer.NewLogSender(log)
+	}
+	return mailer.NewSMTPSender(mailer.SMTPConfig{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+		TLSMode:  cfg.SMTPTLSMode,
+	})
+}
+
+// newPushProvider builds a provider for each configured push platform. With
+// neither FCM nor APNs configured, pushes are only logged.
+func newPushProvider(cfg config.Config, log *slog.Logger) (push.Provider, error) {
+	if cfg.PushFCMCredentialsFile == "" && cfg.PushAPNsKeyFile == "" {
+		return push.NewLogProvider(log), nil
+	}
+
+	router := push.Router{}
+	if cfg.PushFCMCredentialsFile != "" {
+		credentials, err := os.ReadFile(cfg.PushFCMCredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("read PUSH_FCM_CREDENTIALS_FILE: %w", err)
+		}
+		fcm, err := push.NewFCM(credentials, nil)
+		if err != nil {
+			return nil, err
+		}
+		router[domain.PushPlatformFCM] = fcm
+	}
+	if cfg.PushAPNsKeyFile != "" {
+		key, err := os.ReadFile(cfg.PushAPNsKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read PUSH_APNS_KEY_FILE: %w", err)
+		}
+		apns, err := push.NewAPNs(push.APNsConfig{
+			KeyPEM:  key,
+			KeyID:   cfg.PushAPNsKeyID,
+			TeamID:  cfg.PushAPNsTeamID,
+			Topic:   cfg.PushAPNsTopic,
+			Sandbox: cfg.PushAPNsSandbox,
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+		router[domain.PushPlatformAPNs] = apns
+	}
+	return router, nil
+}
+
+// newSIEMSink returns the configured audit export destination, or nil when
+// SIEM export is disabled.
+func newSIEMSink(cfg config.Config) (siem.Sink, error) {
+	switch cfg.SIEMExporter {
+	case "":
+		return nil, nil
+	case "syslog":
+		return siem.NewSyslogSink(cfg.SIEMSyslogNetwork, cfg.SIEMSyslogAddr, cfg.SIEMFormat)
+	case "hec":
+		return siem.NewHECSink(cfg.SIEMHECURL, cfg.SIEMHECToken), nil
+	default:
+		return nil, fmt.Errorf("unknown SIEM_EXPORTER %q", cfg.SIEMExporter)
+	}
+}
+
+// newTelemetryReporter reports the build, the database backend, a bucketed
+// user count and the names of the optional features in use.
+// newAnomalyService builds the suspicious activity detector. Impossible
+// travel is only checked when ANOMALY_GEOIP_PATHS names a GeoIP database.
+func newAnomalyService(cfg config.Config, store domain.Store, auditService *service.AuditService) (*service.AnomalyService, error) {
+	var geo service.Geolocator
+	if len(cfg.AnomalyGeoIPPaths) > 0 {
+		db, err := geoip.Open(cfg.AnomalyGeoIPPaths...)
+		if err != nil {
+			return nil, err
+		}
+		geo = db
+	}
+	return service.NewAnomalyService(store.Audit(), store.Auth(), store.Devices(), auditService, geo, service.AnomalyPolicy{
+		MaxTravelSpeedKmh: float64(cfg.AnomalyMaxTravelSpeedKmh),
+		DownloadThreshold: cfg.AnomalyDownloadThreshold,
+		DownloadWindow:    cfg.AnomalyDownloadWindow,
+		NewDeviceWindow:   cfg.AnomalyNewDeviceWindow,
+		StepUp:            cfg.AnomalyStepUp,
+	}), nil
+}
+
+// newBreachChecker builds the breached password check from the range API,
+// the offline bloom filter, or both.
+func newBreachChecker(cfg config.Config) (*breach.Checker, error) {
+	var filter *breach.BloomFilter
+	if cfg.BreachCheckBloomPath != "" {
+		var err error
+		if filter, err = breach.OpenBloomFilter(cfg.BreachCheckBloomPath); err != nil {
+			return nil, err
+		}
+	}
+	return breach.NewChecker(cfg.BreachCheckRangeURL, filter, cfg.BreachCheckCacheTTL, &http.Client{Timeout: cfg.BreachCheckTimeout})
+}
+
+func newTelemetryReporter(cfg config.Config, adminService *service.AdminService) *telemetry.Reporter {
+	return telemetry.NewReporter(cfg.TelemetryEndpoint, func(ctx context.Context) (telemetry.Report, error) {
+		stats, err := adminService.Stats(ctx)
+		if err != nil {
+			return telemetry.Report{}, err
+		}
+		build := buildinfo.Get()
+		return telemetry.Report{
+			Version:   build.Version,
+			GoVersion: build.GoVersion,
+			OS:        runtime.GOOS,
+			Arch:      runtime.GOARCH,
+			Database:  string(database.DriverFor(cfg.DatabaseURL)),
+			Users:     telemetry.UserBucket(stats.Users),
+			Features:  telemetryFeatures(cfg, stats),
+		}, nil
+	})
+}
+
+func telemetryFeatures(cfg config.Config, stats domain.InstanceStats) []string {
+	features := []string{}
+	for name, on := range map[string]bool{
+		"tls":               cfg.TLSCertFile != "" || len(cfg.ACMEDomains) > 0,
+		"acme":              len(cfg.ACMEDomains) > 0,
+		"ops_listener":      cfg.OpsPort != "",
+		"grpc":              cfg.GRPCPort != "",
+		"read_replica":      cfg.DatabaseReplicaURL != "",
+		"redis":             cfg.RateLimitBackend == "redis",
+		"smtp":              cfg.MailerDriver == "smtp",
+		"siem":              cfg.SIEMExporter != "",
+		"push":              cfg.PushFCMCredentialsFile != "" || cfg.PushAPNsKeyFile != "",
+		"kms":               cfg.KMSProvider != "",
+		"trash_retention":   cfg.TrashRetention > 0,
+		"audit_retention":   cfg.AuditRetention > 0 || cfg.LoginHistoryRetention > 0,
+		"anomaly_detection": cfg.AnomalyDetectionEnabled,
+		"totp":              stats.TOTPUsers > 0,
+		"sharing":           stats.Shares > 0,
+	} {
+		if on {
+			features = append(features, name)
+		}
+	}
+	sort.Strings(features)
+	return features
+}
+
+// newScheduler registers the periodic maintenance jobs. Each job runs on at
+// most one replica at a time and its outcome is kept in job_runs. The trash
+// purge only runs when TRASH_RETENTION is set, the audit purge when
+// AUDIT_RETENTION or LOGIN_HISTORY_RETENTION is, and the IP ban prune when
+// IP_BAN_ENABLED is. Rows the purge jobs delete are counted in
+// metrics.RetentionRowsDeleted.
+func newScheduler(cfg config.Config, log *slog.Logger, jobRepository domain.JobRepository, outboxRepository domain.OutboxRepository, webhookRepository domain.WebhookRepository, authService *service.AuthService, vaultService *service.VaultService, attachmentService *service.AttachmentService, sharingService *service.SharingService, auditService *service.AuditService, dataExportService *service.DataExportService, ipBanService *service.IPBanService, pushService *service.PushService, shareInvitationService *service.ShareInvitationService) (*jobs.Scheduler, error) {
+	scheduler := jobs.NewScheduler(jobRepository, log)
+	scheduler.OnFailure(func(ctx context.Context, jobName string, err error, consecutiveFailures int) {
+		auditService.LogEvent(ctx, nil, domain.EventTypeSystemJobFailed, map[string]any{
+			"job":                  jobName,
+			"error":                err.Error(),
+			"consecutive_failures": consecutiveFailures,
+		})
+	})
+
+	scheduler.Register(jobs.Job{
+		Name:     "session-cleanup",
+		Schedule: jobs.Jitter(jobs.Every(cfg.SessionCleanupInterval), cfg.SessionCleanupInterval/10),
+		Run: func(ctx context.Context) error {
+			stats, err := authService.PurgeSessions(ctx, cfg.RevokedSessionRetention, cfg.SessionCleanupBatchSize, cfg.SessionCleanupBatchPause)
+			metrics.RetentionRowsDeleted.WithLabelValues("sessions").Add(float64(stats.Deleted))
+			var slowest time.Duration
+			for _, batch := range stats.Batches {
+				slowest = max(slowest, batch.Duration)
+			}
+			if err != nil {
+				return err
+			}
+			if stats.Deleted > 0 {
+				log.Info("deleted expired/revoked sessions",
+					slog.Int64("count", stats.Deleted),
+					slog.Int("batches", len(stats.Batches)),
+					slog.Duration("slowest_batch", slowest),
+				)
+			}
+			return nil
+		},
+	})
+
+	scheduler.Register(jobs.Job{
+		Name:     "passkey-challenge-prune",
+		Schedule: jobs.Every(time.Hour),
+		Run: func(ctx context.Context) error {
+			deleted, err := authService.PurgePasskeyChallenges(ctx)
+			metrics.RetentionRowsDeleted.WithLabelValues("passkey_challenges").Add(float64(deleted))
+			return err
+		},
+	})
+
+	scheduler.Register(jobs.Job{
+		Name:     "qr-login-prune",
+		Schedule: jobs.Every(time.Hour),
+		Run: func(ctx context.Context) error {
+			deleted, err := authService.PurgeQRLogins(ctx)
+			metrics.RetentionRowsDeleted.WithLabelValues("qr_login_requests").Add(float64(deleted))
+			return err
+		},
+	})
+
+	scheduler.Register(jobs.Job{
+		Name:     "password-reset-prune",
+		Schedule: jobs.Every(time.Hour),
+		Run: func(ctx context.Context) error {
+			deleted, err := authService.PurgePasswordResets(ctx)
+			metrics.RetentionRowsDeleted.WithLabelValues("password_reset_tokens").Add(float64(deleted))
+			return err
+		},
+	})
+
+	if cfg.TrashRetention > 0 {
+		trashSchedule, err := jobs.ParseSchedule("30 3 * * *")
+		if err != nil {
+			return nil, fmt.Errorf("trash-purge schedule: %w", err)
+		}
+		scheduler.Register(jobs.Job{
+			Name:     "trash-purge",
+			Schedule: trashSchedule,
+			Run: func(ctx context.Context) error {
+				attachments, err := attachmentService.PurgeTrashed(ctx, cfg.TrashRetention)
+				metrics.RetentionRowsDeleted.WithLabelValues("vault_attachments").Add(float64(attachments))
+				if err != nil {
+					return err
+				}
+				purged, err := vaultService.PurgeTrash(ctx, cfg.TrashRetention)
+				metrics.RetentionRowsDeleted.WithLabelValues("vault_trash").Add(float64(purged))
+				if err != nil {
+					return err
+				}
+				if purged > 0 {
+					log.Info("purged trashed vault items", slog.Int64("count", purged))
+				}
+				tombstones, err := vaultService.PurgeTombstones(ctx)
+				metrics.RetentionRowsDeleted.WithLabelValues("vault_item_tombstones").Add(float64(tombstones))
+				return err
+			},
+		})
+	}
+
+	scheduler.Register(jobs.Job{
+		Name:     "share-expiry",
+		Schedule: jobs.Every(15 * time.Minute),
+		Run: func(ctx context.Context) error {
+			revoked, err := sharingService.RevokeExpiredShares(ctx)
+			if err != nil {
+				return err
+			}
+			if revoked > 0 {
+				log.Info("revoked expired shares", slog.Int64("count", revoked))
+			}
+			return nil
+		},
+	})
+
+	scheduler.Register(jobs.Job{
+		Name:     "share-invitation-expiry",
+		Schedule: jobs.Every(time.Hour),
+		Run: func(ctx context.Context) error {
+			deleted, err := shareInvitationService.PurgeExpired(ctx)
+			metrics.RetentionRowsDeleted.WithLabelValues("share_invitations").Add(float64(deleted))
+			return err
+		},
+	})
+
+	historySchedule, err := jobs.ParseSchedule("@daily")
+	if err != nil {
+		return nil, fmt.Errorf("daily schedule: %w", err)
+	}
+	scheduler.Register(jobs.Job{
+		Name:     "job-history-prune",
+		Schedule: historySchedule,
+		Run: func(ctx context.Context) error {
+			deleted, err := jobRepository.DeleteRunsBefore(ctx, time.Now().UTC().Add(-cfg.JobHistoryRetention))
+			metrics.RetentionRowsDeleted.WithLabelValues("job_runs").Add(float64(deleted))
+			return err
+		},
+	})
+
+	scheduler.Register(jobs.Job{
+		Name:     "outbox-prune",
+		Schedule: historySchedule,
+		Run: func(ctx context.Context) error {
+			deleted, err := outboxRepository.DeleteDispatchedBefore(ctx, time.Now().UTC().Add(-cfg.OutboxRetention))
+			metrics.RetentionRowsDeleted.WithLabelValues("outbox_events").Add(float64(deleted))
+			return err
+		},
+	})
+
+	scheduler.Register(jobs.Job{
+		Name:     "webhook-delivery-prune",
+		Schedule: historySchedule,
+		Run: func(ctx context.Context) error {
+			deleted, err := webhookRepository.DeleteDeliveriesBefore(ctx, time.Now().UTC().Add(-cfg.WebhookDeliveryRetention))
+			metrics.RetentionRowsDeleted.WithLabelValues("webhook_deliveries").Add(float64(deleted))
+			return err
+		},
+	})
+
+	scheduler.Register(jobs.Job{
+		Name:     "audit-partitions",
+		Schedule: historySchedule,
+		Run: func(ctx context.Context) error {
+			created, dropped, err := auditService.MaintainPartitions(ctx, cfg.AuditRetention)
+			if len(created) > 0 {
+				log.Info("created audit partitions", slog.Any("partitions", created))
+			}
+			if len(dropped) > 0 {
+				log.Info("dropped expired audit partitions", slog.Any("partitions", dropped))
+			}
+			return err
+		},
+	})
+
+	if cfg.AuditRetention > 0 || cfg.LoginHistoryRetention > 0 {
+		scheduler.Register(jobs.Job{
+			Name:     "audit-purge",
+			Schedule: historySchedule,
+			Run: func(ctx context.Context) error {
+				if cfg.LoginHistoryRetention > 0 {
+					purged, err := auditService.PurgeEvents(ctx, cfg.LoginHistoryRetention, domain.LoginHistoryCategory, cfg.AuditPurgeBatchSize)
+					metrics.RetentionRowsDeleted.WithLabelValues("login_history").Add(float64(purged))
+					if err != nil {
+						return err
+					}
+				}
+				if cfg.AuditRetention > 0 {
+					purged, err := auditService.PurgeEvents(ctx, cfg.AuditRetention, "", cfg.AuditPurgeBatchSize)
+					metrics.RetentionRowsDeleted.WithLabelValues("audit_events").Add(float64(purged))
+					if err != nil {
+						return err
+					}
+					if purged > 0 {
+						log.Info("deleted old audit events", slog.Int64("count", purged))
+					}
+				}
+				return nil
+			},
+		})
+	}
+
+	if cfg.IPBanEnabled {
+		scheduler.Register(jobs.Job{
+			Name:     "ip-ban-prune",
+			Schedule: historySchedule,
+			Run: func(ctx context.Context) error {
+				deleted, err := ipBanService.PurgeExpired(ctx, cfg.IPBanForgetAfter)
+				metrics.RetentionRowsDeleted.WithLabelValues("ip_bans").Add(float64(deleted))
+				return err
+			},
+		})
+	}
+
+	scheduler.Register(jobs.Job{
+		Name:     "data-export-purge",
+		Schedule: jobs.Every(time.Hour),
+		Run: func(ctx context.Context) error {
+			purged, err := dataExportService.PurgeExpired(ctx)
+			metrics.RetentionRowsDeleted.WithLabelValues("data_exports").Add(float64(purged))
+			if err != nil {
+				return err
+			}
+			if purged > 0 {
+				log.Info("deleted expired data exports", slog.Int64("count", purged))
+			}
+			return nil
+		},
+	})
+
+	scheduler.Register(jobs.Job{
+		Name:     "push-token-prune",
+		Schedule: historySchedule,
+		Run: func(ctx context.Context) error {
+			deleted, err := pushService.PurgeStaleTokens(ctx)
+			metrics.RetentionRowsDeleted.WithLabelValues("push_tokens").Add(float64(deleted))
+			return err
+		},
+	})
+
+	return scheduler, nil
+}
+
+// newRateLimitStore builds the backend selected by RATE_LIMIT_BACKEND. The
+// returned close func releases any connection the store holds.
+func newRateLimitStore(ctx context.Context, cfg config.Config) (middlewares.RateLimitStore, func(), error) {
+	if cfg.RateLimitBackend != "redis" {
+		return middlewares.NewMemoryRateLimitStore(), func() {}, nil
+	}
+
+	client, err := newRedisClient(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return middlewares.NewRedisRateLimitStore(client, ""), func() { _ = client.Close() }, nil
+}
+
+// cacheAuthRecords wraps store with the AUTH_CACHE_* cache, if enabled. The
+// returned close func releases any connection the cache holds.
+func cacheAuthRecords(ctx context.Context, cfg config.Config, store domain.Store) (domain.Store, func(), error) {
+	if cfg.AuthCacheTTL <= 0 {
+		return store, func() {}, nil
+	}
+	if cfg.AuthCacheBackend != "redis" {
+		return repository.NewCachedAuthStore(store, repository.NewMemoryAuthCache(), cfg.AuthCacheTTL), func() {}, nil
+	}
+
+	client, err := newRedisClient(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	cache := repository.NewRedisAuthCache(client, "")
+	return repository.NewCachedAuthStore(store, cache, cfg.AuthCacheTTL), func() { _ = client.Close() }, nil
+}
+
+// newRedisClient connects to REDIS_URL and checks that it answers.
+func newRedisClient(ctx context.Context, cfg config.Config) (*redis.Client, error) {
+	opts, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse REDIS_URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Ping(pingCtx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("ping redis: %w", err)
+	}
+	return client, nil
+}
+
+func shutdown(workers *worker.Manager, log *slog.Logger, grpcServer *grpc.Server, servers ...*http.Server) {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 	<-sigCh
@@ -99,8 +964,19 @@ func shutdown(srv *http.Server, log *slog.Logger) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Error("graceful shutdown failed", slog.Any("error", err))
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Error("graceful shutdown failed", slog.String("addr", srv.Addr), slog.Any("error", err))
+		}
+	}
+	if grpcServer != nil {
+		stopGRPC(ctx, grpcServer)
+	}
+
+	// Stop background workers after the server so no request is left
+	// depending on them, and before deferred DB/Redis closes run.
+	if err := workers.Shutdown(ctx); err != nil {
+		log.Error("background workers did not stop in time", slog.Any("error", err))
 		return
 	}
 
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeCAFile(t *testing.T, ca *testCA) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.der}), 0o600); err != nil {
+		t.Fatalf("write CA file: %v", err)
+	}
+	return path
+}
+
+func TestOpsTLSRequiresClientCertificateFromCA(t *testing.T) {
+	serverCA := newTestCA(t)
+	clientCA := newTestCA(t)
+	otherCA := newTestCA(t)
+
+	opsTLS, err := newOpsTLSConfig("1.2", writeCAFile(t, clientCA))
+	if err != nil {
+		t.Fatalf("newOpsTLSConfig: %v", err)
+	}
+	srv := startTLSServer(t, serverCA, opsTLS)
+
+	cases := []struct {
+		name    string
+		certs   []tls.Certificate
+		wantErr bool
+	}{
+		{"no certificate", nil, true},
+		{"certificate from another CA", []tls.Certificate{otherCA.issue(t, "operator")}, true},
+		{"certificate from the client CA", []tls.Certificate{clientCA.issue(t, "operator")}, false},
+	}
+	for _, tc := range cases {
+		for _, version := range []uint16{tls.VersionTLS12, tls.VersionTLS13} {
+			err := handshake(t, srv, &tls.Config{RootCAs: serverCA.pool(), Certificates: tc.certs, MaxVersion: version})
+			if (err != nil) != tc.wantErr {
+				t.Errorf("%s (TLS %x): err = %v, wantErr %v", tc.name, version, err, tc.wantErr)
+			}
+		}
+	}
+}
+
+func TestOpsTLSConfigRejectsBadCAFile(t *testing.T) {
+	if _, err := newOpsTLSConfig("1.2", filepath.Join(t.TempDir(), "missing.pem")); err == nil {
+		t.Error("missing CA file: expected an error")
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(empty, []byte("not a certificate\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newOpsTLSConfig("1.2", empty); err == nil {
+		t.Error("CA file without certificates: expected an error")
+	}
+}
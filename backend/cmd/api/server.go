@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"pmv2/backend/internal/config"
+)
+
+// configureHTTP2 selects the protocols srv speaks and its HTTP/2 limits.
+// HTTP/1.1 is always available. Over TLS, HTTP/2 is offered through ALPN;
+// on a plain listener it is only accepted with H2CEnabled, as prior-knowledge
+// h2c from a reverse proxy (there is no Upgrade: h2c handshake).
+func configureHTTP2(srv *http.Server, cfg config.Config) {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	if cfg.HTTP2Enabled {
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(cfg.H2CEnabled)
+	}
+	srv.Protocols = protocols
+
+	srv.HTTP2 = &http.HTTP2Config{
+		MaxConcurrentStreams: cfg.HTTP2MaxConcurrentStreams,
+		// A client that stops reading must not pin more than this much
+		// buffered request data per stream / connection.
+		MaxReceiveBufferPerStream:     1 << 20,
+		MaxReceiveBufferPerConnection: 4 << 20,
+		// Reap dead connections (e.g. a proxy that vanished) instead of
+		// holding their streams open until IdleTimeout.
+		SendPingTimeout: cfg.IdleTimeout / 2,
+		PingTimeout:     15 * time.Second,
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/config"
+	"pmv2/backend/internal/middlewares"
+)
+
+// streamingHandler mimics the API's middleware stack around two streaming
+// routes: an SSE feed that flushes one event at a time and a large binary
+// download (an attachment).
+func streamingHandler(events chan string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /events", middlewares.Instrument("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		rc := http.NewResponseController(w)
+		// Send the headers right away so the client is not left waiting
+		// for the first event.
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case data, ok := <-events:
+				if !ok {
+					return
+				}
+				_, _ = io.WriteString(w, "data: "+data+"\n\n")
+				if err := rc.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	}))
+	mux.HandleFunc("GET /attachment", middlewares.Instrument("/attachment", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		chunk := strings.Repeat("x", 32<<10)
+		for i := 0; i < 64; i++ {
+			_, _ = io.WriteString(w, chunk)
+		}
+	}))
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return middlewares.Compress(1024, middlewares.RequestLogger(logger)(mux))
+}
+
+func testConfig(h2c bool) config.Config {
+	return config.Config{
+		HTTP2Enabled:              true,
+		H2CEnabled:                h2c,
+		HTTP2MaxConcurrentStreams: 8,
+		IdleTimeout:               time.Minute,
+	}
+}
+
+func h2cClient() *http.Client {
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	return &http.Client{Transport: &http.Transport{Protocols: protocols}}
+}
+
+func TestH2CStreamsEventsUnbuffered(t *testing.T) {
+	events := make(chan string)
+	srv := httptest.NewUnstartedServer(streamingHandler(events))
+	configureHTTP2(srv.Config, testConfig(true))
+	srv.Start()
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := h2cClient().Do(req)
+	if err != nil {
+		t.Fatalf("get events: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("proto = %s, want HTTP/2", resp.Proto)
+	}
+	if ce := resp.Header.Get("Content-Encoding"); ce != "" {
+		t.Fatalf("event stream was encoded with %q", ce)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	for _, want := range []string{"one", "two", "three"} {
+		events <- want
+		line, err := readLineWithin(reader, 2*time.Second)
+		if err != nil {
+			t.Fatalf("read event %q: %v", want, err)
+		}
+		if line != "data: "+want+"\n" {
+			t.Fatalf("event = %q, want %q", line, "data: "+want)
+		}
+		if _, err := readLineWithin(reader, 2*time.Second); err != nil {
+			t.Fatalf("read event separator: %v", err)
+		}
+	}
+	close(events)
+}
+
+func TestH2CDisabledRejectsPriorKnowledge(t *testing.T) {
+	srv := httptest.NewUnstartedServer(streamingHandler(make(chan string)))
+	configureHTTP2(srv.Config, testConfig(false))
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := h2cClient().Get(srv.URL + "/attachment")
+	if err == nil {
+		resp.Body.Close()
+		t.Fatalf("h2c request succeeded with proto %s; want failure", resp.Proto)
+	}
+}
+
+func TestHTTP2OverTLSDownloadsAttachment(t *testing.T) {
+	srv := httptest.NewUnstartedServer(streamingHandler(make(chan string)))
+	srv.EnableHTTP2 = true
+	configureHTTP2(srv.Config, testConfig(false))
+	srv.StartTLS()
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/attachment", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("get attachment: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("proto = %s, want HTTP/2", resp.Proto)
+	}
+
+	if ce := resp.Header.Get("Content-Encoding"); ce != "" {
+		t.Fatalf("binary download was encoded with %q", ce)
+	}
+	n, err := io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		t.Fatalf("read attachment: %v", err)
+	}
+	if n != 64*32<<10 {
+		t.Fatalf("read %d bytes, want %d", n, 64*32<<10)
+	}
+}
+
+func TestHTTP2LimitsConcurrentStreams(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.EnableHTTP2 = true
+	configureHTTP2(srv.Config, testConfig(false))
+	srv.StartTLS()
+	defer srv.Close()
+
+	// Read the server's SETTINGS frame directly: MaxConcurrentStreams must
+	// be advertised to the client.
+	conn, err := tls.Dial("tcp", srv.Listener.Addr().String(), &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2"}})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if conn.ConnectionState().NegotiatedProtocol != "h2" {
+		t.Fatalf("negotiated %q, want h2", conn.ConnectionState().NegotiatedProtocol)
+	}
+	if _, err := io.WriteString(conn, "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"); err != nil {
+		t.Fatalf("write preface: %v", err)
+	}
+	// Empty client SETTINGS frame.
+	if _, err := conn.Write([]byte{0, 0, 0, 0x4, 0, 0, 0, 0, 0}); err != nil {
+		t.Fatalf("write settings: %v", err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	header := make([]byte, 9)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		t.Fatalf("read frame header: %v", err)
+	}
+	if header[3] != 0x4 {
+		t.Fatalf("first frame type = %d, want SETTINGS", header[3])
+	}
+	payload := make([]byte, int(header[0])<<16|int(header[1])<<8|int(header[2]))
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		t.Fatalf("read settings: %v", err)
+	}
+	for i := 0; i+6 <= len(payload); i += 6 {
+		id := uint16(payload[i])<<8 | uint16(payload[i+1])
+		value := uint32(payload[i+2])<<24 | uint32(payload[i+3])<<16 | uint32(payload[i+4])<<8 | uint32(payload[i+5])
+		if id == 0x3 { // SETTINGS_MAX_CONCURRENT_STREAMS
+			if value != 8 {
+				t.Fatalf("max concurrent streams = %d, want 8", value)
+			}
+			return
+		}
+	}
+	t.Fatal("server did not advertise SETTINGS_MAX_CONCURRENT_STREAMS")
+}
+
+func readLineWithin(r *bufio.Reader, d time.Duration) (string, error) {
+	type result struct {
+		line string
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		line, err := r.ReadString('\n')
+		ch <- result{line, err}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	select {
+	case res := <-ch:
+		return res.line, res.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"pmv2/backend/internal/config"
+)
+
+// newTLSConfig restricts the server to TLS 1.2 or newer and, for TLS 1.2,
+// to forward-secret AEAD cipher suites. TLS 1.3 suites are not configurable
+// and are all acceptable.
+func newTLSConfig(minVersion string) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+	}
+	if minVersion == "1.3" {
+		cfg.MinVersion = tls.VersionTLS13
+	}
+	return cfg
+}
+
+// newOpsTLSConfig is newTLSConfig for the operations listener: it also
+// requires every client to present a certificate issued by the CA bundle in
+// clientCAFile.
+func newOpsTLSConfig(minVersion, clientCAFile string) (*tls.Config, error) {
+	pem, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("client CA file %s contains no certificates", clientCAFile)
+	}
+
+	cfg := newTLSConfig(minVersion)
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	return cfg, nil
+}
+
+// newACMEManager obtains and renews certificates for cfg.ACMEDomains from
+// the ACME directory (Let's Encrypt unless overridden), caching them in
+// cfg.ACMECacheDir so restarts do not hit the issuer's rate limits. Names
+// outside ACMEDomains are refused before any order is placed.
+func newACMEManager(cfg config.Config) *autocert.Manager {
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cfg.ACMECacheDir),
+		HostPolicy: autocert.HostWhitelist(cfg.ACMEDomains...),
+		Email:      cfg.ACMEEmail,
+	}
+	if cfg.ACMEDirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: cfg.ACMEDirectoryURL}
+	}
+	return m
+}
+
+// useACME makes tlsConfig serve certificates from m and answer TLS-ALPN-01
+// challenges, so issuance works even without the plain-HTTP listener.
+func useACME(tlsConfig *tls.Config, m *autocert.Manager) {
+	tlsConfig.GetCertificate = m.GetCertificate
+	tlsConfig.NextProtos = append(tlsConfig.NextProtos, acme.ALPNProto)
+}
+
+// newRedirectServer serves handler as plain HTTP on port.
+func newRedirectServer(port string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              ":" + port,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       30 * time.Second,
+	}
+}
+
+// redirectToHTTPS permanently redirects every request to the same URL over
+// HTTPS on httpsPort.
+func redirectToHTTPS(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host == "" {
+			http.Error(w, "missing host", http.StatusBadRequest)
+			return
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		target := url.URL{Scheme: "https", Host: host, Path: r.URL.Path, RawPath: r.URL.RawPath, RawQuery: r.URL.RawQuery}
+
+		// 308 keeps the method and body for non-idempotent requests.
+		status := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			status = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, target.String(), status)
+	})
+}
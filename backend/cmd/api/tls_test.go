@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testCA issues short-lived ECDSA certificates for TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate CA key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create CA certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, der: der}
+}
+
+// issue returns a leaf certificate for name, usable as a server or client
+// certificate.
+func (ca *testCA) issue(t *testing.T, name string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// startTLSServer serves an empty 204 response with serverTLS.
+func startTLSServer(t *testing.T, ca *testCA, serverTLS *tls.Config) *httptest.Server {
+	t.Helper()
+	serverTLS.Certificates = []tls.Certificate{ca.issue(t, "localhost")}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.TLS = serverTLS
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func handshake(t *testing.T, srv *httptest.Server, client *tls.Config) error {
+	t.Helper()
+	client.ServerName = "localhost"
+	conn, err := tls.Dial("tcp", srv.Listener.Addr().String(), client)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// With TLS 1.3 a rejected client certificate only surfaces on the
+	// first read.
+	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")); err != nil {
+		return err
+	}
+	_, err = conn.Read(make([]byte, 1))
+	return err
+}
+
+func TestTLSConfigRefusesLegacyProtocols(t *testing.T) {
+	ca := newTestCA(t)
+	srv := startTLSServer(t, ca, newTLSConfig("1.2"))
+
+	if err := handshake(t, srv, &tls.Config{RootCAs: ca.pool(), MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS11}); err == nil {
+		t.Fatal("TLS 1.1 handshake succeeded")
+	}
+	if err := handshake(t, srv, &tls.Config{
+		RootCAs:      ca.pool(),
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA},
+	}); err == nil {
+		t.Fatal("TLS 1.2 handshake with a CBC suite succeeded")
+	}
+	if err := handshake(t, srv, &tls.Config{RootCAs: ca.pool(), MaxVersion: tls.VersionTLS12}); err != nil {
+		t.Fatalf("TLS 1.2 handshake: %v", err)
+	}
+	if err := handshake(t, srv, &tls.Config{RootCAs: ca.pool()}); err != nil {
+		t.Fatalf("TLS 1.3 handshake: %v", err)
+	}
+}
+
+func TestTLSConfigMinVersion13(t *testing.T) {
+	ca := newTestCA(t)
+	srv := startTLSServer(t, ca, newTLSConfig("1.3"))
+
+	if err := handshake(t, srv, &tls.Config{RootCAs: ca.pool(), MaxVersion: tls.VersionTLS12}); err == nil {
+		t.Fatal("TLS 1.2 handshake succeeded with TLS_MIN_VERSION=1.3")
+	}
+	if err := handshake(t, srv, &tls.Config{RootCAs: ca.pool()}); err != nil {
+		t.Fatalf("TLS 1.3 handshake: %v", err)
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	cases := []struct {
+		name, method, target, host, port string
+		status                           int
+		location                         string
+	}{
+		{"default port", http.MethodGet, "/vault/items?page=2", "vault.example.com", "443", http.StatusMovedPermanently, "https://vault.example.com/vault/items?page=2"},
+		{"custom port", http.MethodGet, "/", "vault.example.com:80", "8443", http.StatusMovedPermanently, "https://vault.example.com:8443/"},
+		{"post keeps method", http.MethodPost, "/auth/login", "vault.example.com", "443", http.StatusPermanentRedirect, "https://vault.example.com/auth/login"},
+		{"missing host", http.MethodGet, "/", "", "443", http.StatusBadRequest, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.target, nil)
+			req.Host = tc.host
+			rec := httptest.NewRecorder()
+			redirectToHTTPS(tc.port).ServeHTTP(rec, req)
+
+			if rec.Code != tc.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.status)
+			}
+			if got := rec.Header().Get("Location"); got != tc.location {
+				t.Fatalf("Location = %q, want %q", got, tc.location)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -9,6 +10,9 @@ import (
 
 	"pmv2/backend/internal/config"
 	"pmv2/backend/internal/database"
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/util"
 )
 
 func main() {
@@ -18,6 +22,8 @@ func main() {
 		fmt.Println("  up    - Apply all migrations")
 		fmt.Println("  down  - Rollback migrations")
 		fmt.Println("  drop  - Drop all tables")
+		fmt.Println("  grant-admin <email>  - Allow an account to use the /admin API")
+		fmt.Println("  revoke-admin <email> - Remove an account's admin role")
 		os.Exit(1)
 	}
 
@@ -27,33 +33,78 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	postgres, err := database.New(ctx, cfg.DatabaseURL)
+	db, err := database.Connect(ctx, cfg.DatabaseURL, database.Retry{
+		MaxWait:        cfg.DatabaseConnectMaxWait,
+		InitialBackoff: cfg.DatabaseConnectBackoff,
+		MaxBackoff:     cfg.DatabaseConnectMaxBackoff,
+		OnRetry: func(attempt int, wait time.Duration, err error) {
+			log.Printf("database unavailable (attempt %d), retrying in %s: %v", attempt, wait, err)
+		},
+	})
 	if err != nil {
 		log.Fatalf("database connection failed: %v", err)
 	}
 	defer func() {
-		if err := postgres.Close(); err != nil {
+		if err := db.Close(); err != nil {
 			log.Printf("database close failed: %v", err)
 		}
 	}()
 
 	switch command {
 	case "up":
-		if err := database.MigrateUp(ctx, postgres.SQL()); err != nil {
+		if err := database.MigrateUp(ctx, db); err != nil {
 			log.Fatalf("migrate up failed: %v", err)
 		}
 		log.Println("migrate up completed successfully")
 	case "down":
-		if err := database.MigrateDown(ctx, postgres.SQL()); err != nil {
+		if err := database.MigrateDown(ctx, db); err != nil {
 			log.Fatalf("migrate down failed: %v", err)
 		}
 		log.Println("migrate down completed successfully")
 	case "drop":
-		if err := database.DropAll(ctx, postgres.SQL()); err != nil {
+		if err := database.DropAll(ctx, db); err != nil {
 			log.Fatalf("drop all failed: %v", err)
 		}
 		log.Println("drop all completed successfully")
+	case "grant-admin", "revoke-admin":
+		if len(os.Args) < 3 {
+			log.Fatalf("usage: migrate %s <email>", command)
+		}
+		role := domain.UserRoleAdmin
+		if command == "revoke-admin" {
+			role = domain.UserRoleUser
+		}
+		email := util.NormalizeEmail(os.Args[2])
+		setter, err := newRoleSetter(db)
+		if err != nil {
+			log.Fatalf("%s failed: %v", command, err)
+		}
+		if err := setter.SetUserRole(ctx, email, role); err != nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				log.Fatalf("no account registered as %s", email)
+			}
+			log.Fatalf("%s failed: %v", command, err)
+		}
+		log.Printf("%s is now %s", email, role)
 	default:
 		log.Fatalf("unknown command: %s", command)
 	}
 }
+
+type roleSetter interface {
+	SetUserRole(ctx context.Context, email string, role domain.UserRole) error
+}
+
+// newRoleSetter returns the store's auth repository; role changes are an
+// operator task, so SetUserRole is not part of domain.AuthRepository.
+func newRoleSetter(db *database.DB) (roleSetter, error) {
+	store, err := repository.NewStore(db)
+	if err != nil {
+		return nil, err
+	}
+	setter, ok := store.Auth().(roleSetter)
+	if !ok {
+		return nil, fmt.Errorf("%s auth repository cannot set roles", db.Driver())
+	}
+	return setter, nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"pmv2/backend/internal/dto"
+)
+
+const apiPrefix = "/api/v1"
+
+// client calls the public REST API with a session token, exactly as the web
+// app does; it has no access to anything the browser could not reach.
+type client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+func newClient(server, token string) *client {
+	return &client{
+		server: strings.TrimRight(server, "/"),
+		token:  token,
+		http:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// apiError is a non-2xx answer, decoded from the standard error body.
+type apiError struct {
+	Status int
+	dto.ErrorResponse
+}
+
+func (e *apiError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("server answered %d", e.Status)
+	}
+	msg := fmt.Sprintf("%s (%s)", e.Message, e.ErrorResponse.Error)
+	for _, f := range e.Fields {
+		msg += fmt.Sprintf("\n  %s: %s", f.Field, f.Message)
+	}
+	return msg
+}
+
+func (c *client) do(ctx context.Context, method, path string, body, out any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.server+apiPrefix+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &apiError{Status: resp.StatusCode}
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr.ErrorResponse)
+		return resp, apiErr
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp, fmt.Errorf("decode %s %s response: %w", method, path, err)
+		}
+	}
+	return resp, nil
+}
+
+// login signs in and returns the session token. The API hands it out only as
+// the session cookie, so it is taken from Set-Cookie.
+func (c *client) login(ctx context.Context, req dto.LoginRequest, cookieName string) (string, dto.LoginResponse, error) {
+	var out dto.LoginResponse
+	resp, err := c.do(ctx, http.MethodPost, "/auth/login", req, &out)
+	if err != nil {
+		return "", out, err
+	}
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == cookieName && cookie.Value != "" {
+			return cookie.Value, out, nil
+		}
+	}
+	return "", out, fmt.Errorf("login succeeded but no %q cookie was set; pass --cookie-name if the server uses another name", cookieName)
+}
+
+func (c *client) logout(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodPost, "/auth/logout", nil, nil)
+	return err
+}
+
+func (c *client) me(ctx context.Context) (dto.SessionResponse, error) {
+	var out dto.SessionResponse
+	_, err := c.do(ctx, http.MethodGet, "/auth/me", nil, &out)
+	return out, err
+}
+
+func (c *client) listItems(ctx context.Context) ([]dto.VaultItemResponse, error) {
+	var out dto.VaultItemsResponse
+	_, err := c.do(ctx, http.MethodGet, "/vault/items", nil, &out)
+	return out.Items, err
+}
+
+func (c *client) getItem(ctx context.Context, id string) (dto.VaultItemResponse, error) {
+	var out dto.VaultItemResponse
+	_, err := c.do(ctx, http.MethodGet, "/vault/items/"+url.PathEscape(id), nil, &out)
+	return out, err
+}
+
+func (c *client) createItem(ctx context.Context, req dto.CreateVaultItemRequest) (dto.VaultItemResponse, error) {
+	var out dto.VaultItemResponse
+	_, err := c.do(ctx, http.MethodPost, "/vault/items", req, &out)
+	return out, err
+}
+
+func (c *client) importItems(ctx context.Context, req dto.ImportVaultItemsRequest) (dto.ImportVaultItemsResponse, error) {
+	var out dto.ImportVaultItemsResponse
+	_, err := c.do(ctx, http.MethodPost, "/vault/import", req, &out)
+	return out, err
+}
+
+func (c *client) totpSetup(ctx context.Context) (dto.TOTPSetupResponse, error) {
+	var out dto.TOTPSetupResponse
+	_, err := c.do(ctx, http.MethodPost, "/auth/totp/setup", nil, &out)
+	return out, err
+}
+
+func (c *client) totpEnable(ctx context.Context, code string) (dto.TOTPEnableResponse, error) {
+	var out dto.TOTPEnableResponse
+	_, err := c.do(ctx, http.MethodPost, "/auth/totp/enable", dto.TOTPCodeRequest{Code: code}, &out)
+	return out, err
+}
+
+func (c *client) totpDisable(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodPost, "/auth/totp/disable", nil, nil)
+	return err
+}
+
+// isMFARequired reports whether a login failed only for want of a second
+// factor.
+func isMFARequired(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.ErrorResponse.Error == "mfa_required"
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/util"
+)
+
+func TestLoginTakesTheTokenFromTheSessionCookie(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/auth/login" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		var req dto.LoginRequest
+		if err := util.ReadJSON(r, &req); err != nil {
+			util.WriteDecodeError(w, err)
+			return
+		}
+		if req.TOTPCode == "" {
+			util.WriteError(w, http.StatusUnauthorized, "mfa_required", "totp code is required for this account")
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "pmv2_session", Value: "tok-123"})
+		util.WriteJSON(w, http.StatusOK, dto.LoginResponse{Email: req.Email})
+	}))
+	defer srv.Close()
+
+	c := newClient(srv.URL+"/", "")
+	req := dto.LoginRequest{Email: "a@example.com", Password: "pw"}
+	if _, _, err := c.login(context.Background(), req, "pmv2_session"); !isMFARequired(err) {
+		t.Fatalf("login without a code: err = %v, want mfa_required", err)
+	}
+
+	req.TOTPCode = "123456"
+	token, resp, err := c.login(context.Background(), req, "pmv2_session")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if token != "tok-123" || resp.Email != "a@example.com" {
+		t.Fatalf("token = %q, email = %q", token, resp.Email)
+	}
+	if _, _, err := c.login(context.Background(), req, "other"); err == nil {
+		t.Fatal("login with a missing cookie succeeded")
+	}
+}
+
+func TestRequestsCarryTheBearerToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok-123" {
+			util.WriteError(w, http.StatusUnauthorized, "unauthorized", "missing session")
+			return
+		}
+		util.WriteJSON(w, http.StatusOK, dto.VaultItemsResponse{Items: []dto.VaultItemResponse{{ID: "item-1"}}})
+	}))
+	defer srv.Close()
+
+	items, err := newClient(srv.URL, "tok-123").listItems(context.Background())
+	if err != nil || len(items) != 1 || items[0].ID != "item-1" {
+		t.Fatalf("listItems = %+v, %v", items, err)
+	}
+
+	_, err = newClient(srv.URL, "").listItems(context.Background())
+	apiErr, ok := err.(*apiError)
+	if !ok || apiErr.Status != http.StatusUnauthorized || apiErr.ErrorResponse.Error != "unauthorized" {
+		t.Fatalf("listItems without a token: err = %#v", err)
+	}
+}
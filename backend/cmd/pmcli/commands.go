@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"pmv2/backend/internal/dto"
+)
+
+// Metadata of items the CLI creates; the web app files them under the
+// default personal vault.
+var personalVault = itemMetadata{VaultID: "personal-main", VaultName: "Personal Vault", VaultType: "personal"}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+func (a *app) login(ctx context.Context, args []string) error {
+	fs := newFlagSet("login")
+	email := fs.String("email", a.state.Email, "account email")
+	totpCode := fs.String("totp", "", "two-factor code")
+	deviceName := fs.String("device", "pmcli", "device name shown in the session list")
+	cookieName := fs.String("cookie-name", "pmv2_session", "session cookie name configured on the server")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if *email == "" {
+		return errors.New("--email is required")
+	}
+	password, err := secretInput("PMV2_PASSWORD", "Password: ")
+	if err != nil {
+		return err
+	}
+
+	req := dto.LoginRequest{Email: *email, Password: password, TOTPCode: *totpCode, DeviceName: *deviceName}
+	token, resp, err := a.client().login(ctx, req, *cookieName)
+	if isMFARequired(err) && *totpCode == "" {
+		if req.TOTPCode, err = secretInput("PMV2_TOTP", "Two-factor code: "); err != nil {
+			return err
+		}
+		token, resp, err = a.client().login(ctx, req, *cookieName)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := saveState(state{Server: a.server, Token: token, Email: resp.Email}); err != nil {
+		return fmt.Errorf("signed in but could not save the session: %w", err)
+	}
+	fmt.Fprintf(a.out, "Signed in as %s (session expires %s)\n", resp.Email, resp.ExpiresAt)
+	return nil
+}
+
+func (a *app) logout(ctx context.Context) error {
+	c, err := a.authedClient()
+	if err != nil {
+		return err
+	}
+	if err := c.logout(ctx); err != nil {
+		return err
+	}
+	return clearState()
+}
+
+func (a *app) whoami(ctx context.Context) error {
+	c, err := a.authedClient()
+	if err != nil {
+		return err
+	}
+	me, err := c.me(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "%s <%s>\nuser id:  %s\ntwo-factor: %t\nexpires:  %s\n", me.Name, me.Email, me.UserID, me.TOTPEnabled, me.ExpiresAt)
+	return nil
+}
+
+// decryptedItem is an item as the CLI prints and exports it.
+type decryptedItem struct {
+	ID        string          `json:"id"`
+	FolderID  *string         `json:"folder_id,omitempty"`
+	CreatedAt string          `json:"created_at"`
+	UpdatedAt string          `json:"updated_at"`
+	Secret    json.RawMessage `json:"secret"`
+}
+
+// vaultItems fetches and decrypts every item except the verifier. Items
+// that do not decrypt are returned in failed rather than aborting.
+func (a *app) vaultItems(ctx context.Context) (items []decryptedItem, failed []string, err error) {
+	c, err := a.authedClient()
+	if err != nil {
+		return nil, nil, err
+	}
+	raw, err := c.listItems(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	passphrase, err := secretInput("PMV2_PASSPHRASE", "Vault passphrase: ")
+	if err != nil {
+		return nil, nil, err
+	}
+	kek, err := unlock(passphrase, raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer clear(kek)
+
+	for _, item := range raw {
+		if parseMetadata(item.Metadata).Kind == verifierKind {
+			continue
+		}
+		plaintext, err := decryptItem(item, kek)
+		if err != nil || !json.Valid(plaintext) {
+			failed = append(failed, item.ID)
+			continue
+		}
+		items = append(items, decryptedItem{ID: item.ID, FolderID: item.FolderID, CreatedAt: item.CreatedAt, UpdatedAt: item.UpdatedAt, Secret: plaintext})
+	}
+	return items, failed, nil
+}
+
+func (a *app) itemList(ctx context.Context, args []string) error {
+	fs := newFlagSet("item list")
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	items, failed, err := a.vaultItems(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return writeJSON(a.out, items)
+	}
+
+	tw := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tKIND\tTITLE\tUPDATED")
+	for _, item := range items {
+		var secret struct{ Kind, Title string }
+		_ = json.Unmarshal(item.Secret, &secret)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", item.ID, secret.Kind, secret.Title, item.UpdatedAt)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	return reportFailed(failed)
+}
+
+func (a *app) itemGet(ctx context.Context, args []string) error {
+	fs := newFlagSet("item get")
+	field := fs.String("field", "", "print only this field of the secret, e.g. password")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+	c, err := a.authedClient()
+	if err != nil {
+		return err
+	}
+	raw, err := c.listItems(ctx)
+	if err != nil {
+		return err
+	}
+	item, err := c.getItem(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	passphrase, err := secretInput("PMV2_PASSPHRASE", "Vault passphrase: ")
+	if err != nil {
+		return err
+	}
+	kek, err := unlock(passphrase, raw)
+	if err != nil {
+		return err
+	}
+	defer clear(kek)
+
+	plaintext, err := decryptItem(item, kek)
+	if err != nil {
+		return fmt.Errorf("item %s: %w", item.ID, err)
+	}
+	if *field == "" {
+		return writeJSON(a.out, decryptedItem{ID: item.ID, FolderID: item.FolderID, CreatedAt: item.CreatedAt, UpdatedAt: item.UpdatedAt, Secret: plaintext})
+	}
+	var secret map[string]any
+	if err := json.Unmarshal(plaintext, &secret); err != nil {
+		return fmt.Errorf("item %s does not hold a JSON secret", item.ID)
+	}
+	value, ok := secret[*field]
+	if !ok {
+		return fmt.Errorf("item %s has no field %q", item.ID, *field)
+	}
+	fmt.Fprintln(a.out, value)
+	return nil
+}
+
+func (a *app) itemCreate(ctx context.Context, args []string) error {
+	fs := newFlagSet("item create")
+	kind := fs.String("kind", "login", "login or note")
+	title := fs.String("title", "", "item title (required)")
+	username := fs.String("username", "", "login username")
+	password := fs.String("password", "", "login password")
+	generate := fs.Bool("generate", false, "generate the password")
+	url := fs.String("url", "", "login URL")
+	notes := fs.String("notes", "", "notes")
+	folder := fs.String("folder", "", "folder ID")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if *title == "" {
+		return errors.New("--title is required")
+	}
+
+	secret := map[string]any{"kind": *kind, "title": *title, "notes": *notes, "tags": []string{}}
+	switch *kind {
+	case "login":
+		if *generate {
+			generated, err := generatePassword(passwordOptions{Length: 20, Upper: true, Lower: true, Digits: true, Symbols: true})
+			if err != nil {
+				return err
+			}
+			*password = generated
+		}
+		secret["username"], secret["password"], secret["url"] = *username, *password, *url
+	case "note":
+	default:
+		return fmt.Errorf("--kind %q is not supported here; use the web app for cards and bank accounts", *kind)
+	}
+	plaintext, err := json.Marshal(secret)
+	if err != nil {
+		return err
+	}
+
+	c, err := a.authedClient()
+	if err != nil {
+		return err
+	}
+	raw, err := c.listItems(ctx)
+	if err != nil {
+		return err
+	}
+	passphrase, err := secretInput("PMV2_PASSPHRASE", "Vault passphrase: ")
+	if err != nil {
+		return err
+	}
+	kek, err := unlock(passphrase, raw)
+	if err != nil {
+		return err
+	}
+	defer clear(kek)
+
+	req, err := encryptItem(plaintext, kek)
+	if err != nil {
+		return err
+	}
+	meta := personalVault
+	meta.Kind = *kind
+	if req.Metadata, err = json.Marshal(meta); err != nil {
+		return err
+	}
+	if *folder != "" {
+		req.FolderID = folder
+	}
+	created, err := c.createItem(ctx, req)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(a.out, created.ID)
+	return nil
+}
+
+func (a *app) generate(args []string) error {
+	fs := newFlagSet("generate")
+	opts := passwordOptions{Upper: true, Lower: true, Digits: true, Symbols: true}
+	fs.IntVar(&opts.Length, "length", 20, "password length")
+	noUpper := fs.Bool("no-upper", false, "leave out uppercase letters")
+	noLower := fs.Bool("no-lower", false, "leave out lowercase letters")
+	noDigits := fs.Bool("no-digits", false, "leave out digits")
+	noSymbols := fs.Bool("no-symbols", false, "leave out symbols")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	opts.Upper, opts.Lower, opts.Digits, opts.Symbols = !*noUpper, !*noLower, !*noDigits, !*noSymbols
+
+	password, err := generatePassword(opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(a.out, password)
+	return nil
+}
+
+func (a *app) export(ctx context.Context, args []string) error {
+	fs := newFlagSet("export")
+	output := fs.String("output", "", "file to write (default stdout)")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	items, failed, err := a.vaultItems(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := a.out
+	if *output != "" {
+		f, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	fmt.Fprintln(os.Stderr, "warning: the export contains your secrets in plain text")
+	if err := writeJSON(w, items); err != nil {
+		return err
+	}
+	return reportFailed(failed)
+}
+
+func (a *app) totp(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	c, err := a.authedClient()
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "setup":
+		setup, err := c.totpSetup(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(a.out, "Secret: %s\nURL:    %s\nAdd it to your authenticator, then run `pmcli totp enable <code>`.\n", setup.Secret, setup.OTPAuthURL)
+		return nil
+	case "enable":
+		if len(args) != 2 {
+			return errUsage
+		}
+		enabled, err := c.totpEnable(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(a.out, "Two-factor sign-in is on. Keep these recovery codes somewhere safe:")
+		for _, code := range enabled.RecoveryCodes {
+			fmt.Fprintln(a.out, "  "+code)
+		}
+		return nil
+	case "disable":
+		if err := c.totpDisable(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintln(a.out, "Two-factor sign-in is off.")
+		return nil
+	}
+	return errUsage
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func reportFailed(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d item(s) could not be decrypted: %v", len(ids), ids)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"pmv2/backend/internal/dto"
+)
+
+// The vault format shared with the web app and the extension
+// (web/src/crypto): each item is sealed with its own random key (DEK), which
+// is itself sealed with the key derived from the vault passphrase (KEK).
+// The server only ever stores the sealed forms.
+const (
+	algoVersion = "xchacha20poly1305-v1"
+	dekWrapAAD  = "pmv2:dek-wrap:v1"
+
+	verifierKind  = "kek-verifier"
+	verifierToken = "pmv2-kek-verifier-v1"
+)
+
+// Argon2id parameters the web app uses when it checks a passphrase against
+// the verifier item.
+const (
+	kdfMemoryKiB   = 64 * 1024
+	kdfIterations  = 3
+	kdfParallelism = 2
+	kdfKeyLength   = 32
+)
+
+var errWrongPassphrase = errors.New("incorrect vault passphrase")
+
+// itemMetadata is the unencrypted metadata object the web app stores with
+// each item.
+type itemMetadata struct {
+	Kind      string `json:"kind,omitempty"`
+	VaultID   string `json:"vault_id,omitempty"`
+	VaultName string `json:"vault_name,omitempty"`
+	VaultType string `json:"vault_type,omitempty"`
+	Salt      string `json:"salt,omitempty"`
+}
+
+func parseMetadata(raw json.RawMessage) itemMetadata {
+	var meta itemMetadata
+	_ = json.Unmarshal(raw, &meta)
+	return meta
+}
+
+func deriveKEK(passphrase string, salt []byte) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, kdfIterations, kdfMemoryKiB, kdfParallelism, kdfKeyLength)
+}
+
+// unlock derives the KEK from passphrase and checks it against the
+// verifier item the web app created when the vault passphrase was set.
+func unlock(passphrase string, items []dto.VaultItemResponse) ([]byte, error) {
+	for _, item := range items {
+		meta := parseMetadata(item.Metadata)
+		if meta.Kind != verifierKind {
+			continue
+		}
+		salt, err := base64.StdEncoding.DecodeString(meta.Salt)
+		if err != nil || len(salt) < 16 {
+			return nil, errors.New("the vault verifier item is invalid")
+		}
+		kek := deriveKEK(passphrase, salt)
+		token, err := decryptItem(item, kek)
+		if err != nil || subtle.ConstantTimeCompare(token, []byte(verifierToken)) != 1 {
+			return nil, errWrongPassphrase
+		}
+		return kek, nil
+	}
+	return nil, errors.New("this vault has no passphrase yet; unlock it once in the web app to set one")
+}
+
+func encryptItem(plaintext, kek []byte) (dto.CreateVaultItemRequest, error) {
+	dek := make([]byte, chacha20poly1305.KeySize)
+	if _, err := rand.Read(dek); err != nil {
+		return dto.CreateVaultItemRequest{}, err
+	}
+	defer clear(dek)
+
+	nonce, ciphertext, err := seal(dek, plaintext, nil)
+	if err != nil {
+		return dto.CreateVaultItemRequest{}, err
+	}
+	wrapNonce, wrappedDEK, err := seal(kek, dek, []byte(dekWrapAAD))
+	if err != nil {
+		return dto.CreateVaultItemRequest{}, err
+	}
+	return dto.CreateVaultItemRequest{
+		Ciphertext:  base64.StdEncoding.EncodeToString(ciphertext),
+		Nonce:       base64.StdEncoding.EncodeToString(nonce),
+		WrappedDEK:  base64.StdEncoding.EncodeToString(wrappedDEK),
+		WrapNonce:   base64.StdEncoding.EncodeToString(wrapNonce),
+		AlgoVersion: algoVersion,
+	}, nil
+}
+
+func decryptItem(item dto.VaultItemResponse, kek []byte) ([]byte, error) {
+	if item.AlgoVersion != algoVersion {
+		return nil, fmt.Errorf("unsupported algorithm %q", item.AlgoVersion)
+	}
+	fields := make([][]byte, 4)
+	for i, encoded := range []string{item.WrapNonce, item.WrappedDEK, item.Nonce, item.Ciphertext} {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("item %s is not valid base64", item.ID)
+		}
+		fields[i] = decoded
+	}
+
+	dek, err := open(kek, fields[0], fields[1], []byte(dekWrapAAD))
+	if err != nil {
+		return nil, err
+	}
+	defer clear(dek)
+	return open(dek, fields[2], fields[3], nil)
+}
+
+func seal(key, plaintext, aad []byte) (nonce, ciphertext []byte, err error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, aead.Seal(nil, nonce, plaintext, aad), nil
+}
+
+func open(key, nonce, ciphertext, aad []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != chacha20poly1305.NonceSizeX {
+		return nil, errors.New("nonce has the wrong length")
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, errors.New("ciphertext authentication failed")
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"pmv2/backend/internal/dto"
+)
+
+// storedItem turns a create request into what the API would hand back.
+func storedItem(t *testing.T, id string, req dto.CreateVaultItemRequest, meta itemMetadata) dto.VaultItemResponse {
+	t.Helper()
+	raw, err := json.Marshal(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dto.VaultItemResponse{
+		ID:          id,
+		Ciphertext:  req.Ciphertext,
+		Nonce:       req.Nonce,
+		WrappedDEK:  req.WrappedDEK,
+		WrapNonce:   req.WrapNonce,
+		AlgoVersion: req.AlgoVersion,
+		Metadata:    raw,
+	}
+}
+
+func verifierItem(t *testing.T, passphrase string) dto.VaultItemResponse {
+	t.Helper()
+	salt := bytes.Repeat([]byte{7}, 16)
+	req, err := encryptItem([]byte(verifierToken), deriveKEK(passphrase, salt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storedItem(t, "verifier", req, itemMetadata{Kind: verifierKind, Salt: base64.StdEncoding.EncodeToString(salt)})
+}
+
+func TestUnlockChecksThePassphraseAgainstTheVerifier(t *testing.T) {
+	items := []dto.VaultItemResponse{verifierItem(t, "correct horse")}
+
+	kek, err := unlock("correct horse", items)
+	if err != nil {
+		t.Fatalf("unlock with the right passphrase: %v", err)
+	}
+	if len(kek) != kdfKeyLength {
+		t.Fatalf("kek length = %d, want %d", len(kek), kdfKeyLength)
+	}
+	if _, err := unlock("battery staple", items); !errors.Is(err, errWrongPassphrase) {
+		t.Fatalf("unlock with the wrong passphrase: err = %v, want errWrongPassphrase", err)
+	}
+	if _, err := unlock("correct horse", nil); err == nil {
+		t.Fatal("unlock without a verifier item succeeded")
+	}
+}
+
+func TestEncryptedItemsRoundTripAndRejectOtherKeys(t *testing.T) {
+	kek := bytes.Repeat([]byte{1}, kdfKeyLength)
+	plaintext := []byte(`{"kind":"login","title":"Mail","password":"hunter2"}`)
+
+	req, err := encryptItem(plaintext, kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.AlgoVersion != algoVersion {
+		t.Fatalf("algo_version = %q", req.AlgoVersion)
+	}
+	item := storedItem(t, "item-1", req, itemMetadata{Kind: "login"})
+
+	got, err := decryptItem(item, kek)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("decrypted %q, want %q", got, plaintext)
+	}
+
+	if _, err := decryptItem(item, bytes.Repeat([]byte{2}, kdfKeyLength)); err == nil {
+		t.Fatal("decrypt with another key succeeded")
+	}
+	item.AlgoVersion = "aes-gcm-v0"
+	if _, err := decryptItem(item, kek); err == nil {
+		t.Fatal("decrypt of an unknown algorithm succeeded")
+	}
+}
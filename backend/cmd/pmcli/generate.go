@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+)
+
+// Character classes of the web app's generator (web/src/utils/password.utils.ts).
+const (
+	upperChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	lowerChars  = "abcdefghijklmnopqrstuvwxyz"
+	digitChars  = "0123456789"
+	symbolChars = "!@#$%^&*()_+~`|}{[]:;?><,./-="
+)
+
+type passwordOptions struct {
+	Length  int
+	Upper   bool
+	Lower   bool
+	Digits  bool
+	Symbols bool
+}
+
+// generatePassword returns a random password with at least one character
+// from every enabled class.
+func generatePassword(opts passwordOptions) (string, error) {
+	var classes []string
+	for _, class := range []struct {
+		enabled bool
+		chars   string
+	}{
+		{opts.Upper, upperChars},
+		{opts.Lower, lowerChars},
+		{opts.Digits, digitChars},
+		{opts.Symbols, symbolChars},
+	} {
+		if class.enabled {
+			classes = append(classes, class.chars)
+		}
+	}
+	if len(classes) == 0 {
+		return "", errors.New("enable at least one character class")
+	}
+	if opts.Length < len(classes) || opts.Length > 1024 {
+		return "", errors.New("length must fit one character of every class and be at most 1024")
+	}
+
+	var charset string
+	for _, class := range classes {
+		charset += class
+	}
+	out := make([]byte, opts.Length)
+	for i := range out {
+		pool := charset
+		if i < len(classes) {
+			pool = classes[i]
+		}
+		c, err := randomIndex(len(pool))
+		if err != nil {
+			return "", err
+		}
+		out[i] = pool[c]
+	}
+
+	// Shuffle so the guaranteed characters are not always in front.
+	for i := len(out) - 1; i > 0; i-- {
+		j, err := randomIndex(i + 1)
+		if err != nil {
+			return "", err
+		}
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out), nil
+}
+
+func randomIndex(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
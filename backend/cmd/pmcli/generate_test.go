@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGeneratePasswordHonoursClasses(t *testing.T) {
+	password, err := generatePassword(passwordOptions{Length: 4, Upper: true, Lower: true, Digits: true, Symbols: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, class := range []string{upperChars, lowerChars, digitChars, symbolChars} {
+		if !strings.ContainsAny(password, class) {
+			t.Fatalf("%q has no character from %q", password, class)
+		}
+	}
+
+	digits, err := generatePassword(passwordOptions{Length: 32, Digits: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(digits) != 32 || strings.Trim(digits, digitChars) != "" {
+		t.Fatalf("digits-only password = %q", digits)
+	}
+
+	if _, err := generatePassword(passwordOptions{Length: 20}); err == nil {
+		t.Fatal("generate with no classes succeeded")
+	}
+	if _, err := generatePassword(passwordOptions{Length: 2, Upper: true, Lower: true, Digits: true}); err == nil {
+		t.Fatal("generate shorter than the number of classes succeeded")
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/importer"
+)
+
+// importBatch is how many items go in one import request, the most the
+// server takes.
+const importBatch = 500
+
+// importItems reads another password manager's export, encrypts every
+// entry locally and sends the ciphertexts to the import endpoint, so the
+// export never leaves this machine in plain text. Folders are not
+// recreated; imported items land in the personal vault.
+func (a *app) importItems(ctx context.Context, args []string) error {
+	names := make([]string, 0, len(importer.Formats()))
+	for _, f := range importer.Formats() {
+		names = append(names, string(f))
+	}
+	fs := newFlagSet("import")
+	formatName := fs.String("format", "", "export format: "+strings.Join(names, ", "))
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+	format, err := importer.ParseFormat(*formatName)
+	if err != nil {
+		return fmt.Errorf("--format must be one of %s", strings.Join(names, ", "))
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	entries, rowErrs, err := importer.Parse(format, f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	for _, rowErr := range rowErrs {
+		fmt.Fprintln(os.Stderr, "skipped", rowErr)
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("%s holds nothing to import", fs.Arg(0))
+	}
+
+	c, err := a.authedClient()
+	if err != nil {
+		return err
+	}
+	raw, err := c.listItems(ctx)
+	if err != nil {
+		return err
+	}
+	passphrase, err := secretInput("PMV2_PASSPHRASE", "Vault passphrase: ")
+	if err != nil {
+		return err
+	}
+	kek, err := unlock(passphrase, raw)
+	if err != nil {
+		return err
+	}
+	defer clear(kek)
+
+	items := make([]dto.ImportVaultItemRequest, 0, len(entries))
+	for _, entry := range entries {
+		plaintext, err := json.Marshal(entry.Secret())
+		if err != nil {
+			return err
+		}
+		req, err := encryptItem(plaintext, kek)
+		if err != nil {
+			return err
+		}
+		meta := personalVault
+		meta.Kind = entry.Kind
+		if req.Metadata, err = json.Marshal(meta); err != nil {
+			return err
+		}
+		items = append(items, dto.ImportVaultItemRequest{Row: entry.Row, CreateVaultItemRequest: req})
+	}
+
+	imported, failed := 0, len(rowErrs)
+	for start := 0; start < len(items); start += importBatch {
+		batch := items[start:min(start+importBatch, len(items))]
+		resp, err := c.importItems(ctx, dto.ImportVaultItemsRequest{Source: string(format), Items: batch})
+		if err != nil {
+			return fmt.Errorf("imported %d item(s) before failing: %w", imported, err)
+		}
+		imported += len(resp.Imported)
+		failed += len(resp.Errors)
+		for _, rowErr := range resp.Errors {
+			fmt.Fprintf(os.Stderr, "rejected row %d: %s\n", rowErr.Row, rowErr.Message)
+		}
+	}
+	fmt.Fprintf(a.out, "Imported %d item(s)\n", imported)
+	if failed > 0 {
+		return fmt.Errorf("%d row(s) were not imported", failed)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// Command pmcli is the command-line client for the password manager. It
+// talks to the same public REST API as the web app and does all encryption
+// locally, so it doubles as an end-to-end check of that API.
+//
+// Credentials come from `pmcli login` (saved under the user config
+// directory) or, for scripts, from PMV2_TOKEN. The vault passphrase is
+// prompted for on every command that needs it, or read from
+// PMV2_PASSPHRASE; it is never stored.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const usage = `Usage: pmcli [--server URL] <command> [flags]
+
+Commands:
+  login              Sign in and remember the session
+  logout             End the session and forget it
+  whoami             Show the signed-in account
+  item list          List vault items
+  item get <id>      Decrypt and print one item
+  item create        Encrypt and store a new item
+  generate           Print a random password
+  export             Write every item, decrypted, as JSON
+  import <file>      Encrypt and store another password manager's export
+  totp setup         Start two-factor setup and print the secret
+  totp enable <code> Confirm two-factor setup and print recovery codes
+  totp disable       Turn two-factor sign-in off
+
+Environment:
+  PMV2_SERVER        API base URL (default ` + defaultServer + `)
+  PMV2_TOKEN         Session token to use instead of the saved login
+  PMV2_PASSWORD      Account password for login
+  PMV2_PASSPHRASE    Vault passphrase
+  PMCLI_CONFIG       Where the login is saved
+`
+
+// errUsage makes main print the usage text and exit with status 2.
+var errUsage = errors.New("usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdout); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "pmcli:", err)
+		os.Exit(1)
+	}
+}
+
+// app is the state shared by every command.
+type app struct {
+	server string
+	state  state
+	out    io.Writer
+}
+
+func (a *app) client() *client {
+	return newClient(a.server, resolveToken(a.state))
+}
+
+// authedClient is client for commands that need a session.
+func (a *app) authedClient() (*client, error) {
+	c := a.client()
+	if c.token == "" {
+		return nil, errors.New("not signed in; run `pmcli login` or set PMV2_TOKEN")
+	}
+	return c, nil
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	global := flag.NewFlagSet("pmcli", flag.ContinueOnError)
+	global.SetOutput(io.Discard)
+	server := global.String("server", "", "API base URL")
+	if err := global.Parse(args); err != nil || global.NArg() == 0 {
+		return errUsage
+	}
+
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+	a := &app{server: resolveServer(*server, st), state: st, out: out}
+
+	command, rest := global.Arg(0), global.Args()[1:]
+	switch command {
+	case "login":
+		return a.login(ctx, rest)
+	case "logout":
+		return a.logout(ctx)
+	case "whoami":
+		return a.whoami(ctx)
+	case "item":
+		if len(rest) == 0 {
+			return errUsage
+		}
+		switch rest[0] {
+		case "list":
+			return a.itemList(ctx, rest[1:])
+		case "get":
+			return a.itemGet(ctx, rest[1:])
+		case "create":
+			return a.itemCreate(ctx, rest[1:])
+		}
+	case "generate":
+		return a.generate(rest)
+	case "export":
+		return a.export(ctx, rest)
+	case "import":
+		return a.importItems(ctx, rest)
+	case "totp":
+		return a.totp(ctx, rest)
+	case "help", "-h", "--help":
+		fmt.Fprint(out, usage)
+		return nil
+	}
+	return errUsage
+}
+
+// secretInput reads a secret from env, or prompts for it on the terminal
+// without echo.
+func secretInput(env, prompt string) (string, error) {
+	if value := os.Getenv(env); value != "" {
+		return value, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("%s is not set and stdin is not a terminal", env)
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	raw, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(raw), "\r\n"), nil
+}
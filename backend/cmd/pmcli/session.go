@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const defaultServer = "http://localhost:8080"
+
+// state is what `pmcli login` remembers between runs. The file holds a
+// bearer credential, so it is written with mode 0600.
+type state struct {
+	Server string `json:"server"`
+	Token  string `json:"token"`
+	Email  string `json:"email,omitempty"`
+}
+
+func statePath() (string, error) {
+	if path := os.Getenv("PMCLI_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pmcli", "session.json"), nil
+}
+
+func loadState() (state, error) {
+	var st state
+	path, err := statePath()
+	if err != nil {
+		return st, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return st, err
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &st); err != nil {
+			return st, fmt.Errorf("%s is corrupt: %w", path, err)
+		}
+	}
+	return st, nil
+}
+
+func saveState(st state) error {
+	path, err := statePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, raw, 0o600)
+}
+
+func clearState() error {
+	path, err := statePath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// resolveServer picks the API base URL: --server, then PMV2_SERVER, then the
+// one saved at login.
+func resolveServer(flagValue string, st state) string {
+	for _, candidate := range []string{flagValue, os.Getenv("PMV2_SERVER"), st.Server} {
+		if strings.TrimSpace(candidate) != "" {
+			return strings.TrimSpace(candidate)
+		}
+	}
+	return defaultServer
+}
+
+// resolveToken prefers PMV2_TOKEN, which lets scripts and CI use an
+// existing session token as an API key without logging in.
+func resolveToken(st state) string {
+	if token := strings.TrimSpace(os.Getenv("PMV2_TOKEN")); token != "" {
+		return token
+	}
+	return st.Token
+}
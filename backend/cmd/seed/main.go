@@ -16,22 +16,27 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
 	defer cancel()
 
-	// Connect to database
-	db, err := database.New(ctx, cfg.DatabaseURL)
+	// Connect to database and make sure the schema exists
+	db, err := database.ConnectAndMigrate(ctx, cfg.DatabaseURL, database.Retry{
+		MaxWait:        cfg.DatabaseConnectMaxWait,
+		InitialBackoff: cfg.DatabaseConnectBackoff,
+		MaxBackoff:     cfg.DatabaseConnectMaxBackoff,
+		OnRetry: func(attempt int, wait time.Duration, err error) {
+			log.Printf("database unavailable (attempt %d), retrying in %s: %v", attempt, wait, err)
+		},
+	})
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	defer db.Close()
 
-	// Initialize repositories
-	authRepo := repository.NewAuthRepository(db.SQL())
-	vaultRepo := repository.NewVaultRepository(db.SQL())
-	folderRepo := repository.NewPostgresFolderRepository(db.SQL())
-	auditRepo := repository.NewAuditRepository(db.SQL())
-	familyRepo := repository.NewFamilyRepository(db.SQL())
+	store, err := repository.NewStore(db)
+	if err != nil {
+		log.Fatalf("Failed to initialize repositories: %v", err)
+	}
 
 	// Initialize seeder
-	seeder := database.NewSeeder(db, authRepo, vaultRepo, folderRepo, auditRepo, familyRepo, cfg.AuthPepper)
+	seeder := database.NewSeeder(db, store.Auth(), store.Vault(), store.Folders(), store.Audit(), store.Family(), cfg.AuthPepper)
 
 	// Run seeding
 	if err := seeder.SeedAll(ctx); err != nil {
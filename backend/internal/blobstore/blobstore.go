@@ -0,0 +1,69 @@
+// Package blobstore keeps the opaque blobs the server stores on behalf of
+// users, such as encrypted vault attachments, outside the database. Callers
+// pick the path of each blob; a Store only reads, writes and removes it.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned by Open for a path that holds no blob.
+var ErrNotFound = errors.New("blob not found")
+
+// Store reads and writes blobs by path. Paths are slash-separated and
+// relative, like "attachments/<item_id>/<attachment_id>".
+type Store interface {
+	// Put stores everything read from body at path, replacing any blob
+	// already there, and returns the number of bytes written.
+	Put(ctx context.Context, path string, body io.Reader) (int64, error)
+	// Open returns the blob at path, or ErrNotFound.
+	Open(ctx context.Context, path string) (io.ReadCloser, error)
+	// Delete removes the blob at path. Deleting a missing blob is not an
+	// error.
+	Delete(ctx context.Context, path string) error
+}
+
+// URLSigner is implemented by stores that can hand out a time-limited URL
+// from which a client downloads a blob directly, rather than through the
+// server.
+type URLSigner interface {
+	SignedURL(ctx context.Context, path string, ttl time.Duration) (string, error)
+}
+
+// New returns the store selected by BLOB_STORE, or nil for "" (no blob
+// storage, so features that need it are off). localDir is used by "local"
+// and s3 by "s3".
+func New(provider, localDir string, s3 S3Config) (Store, error) {
+	switch provider {
+	case "":
+		return nil, nil
+	case "local":
+		s, err := NewLocalStore(localDir)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case "s3":
+		s, err := NewS3Store(s3)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown blob store %q", provider)
+}
+
+// checkPath rejects paths that are absolute, unclean or climb out of the
+// store with "..".
+func checkPath(p string) error {
+	if p == "" || path.IsAbs(p) || path.Clean(p) != p || p == ".." || strings.HasPrefix(p, "../") {
+		return fmt.Errorf("invalid blob path %q", p)
+	}
+	return nil
+}
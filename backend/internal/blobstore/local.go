@@ -0,0 +1,96 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore keeps blobs as files under a directory on the local disk. It
+// suits single-replica deployments; replicas behind a load balancer need a
+// shared directory.
+type LocalStore struct {
+	root string
+}
+
+// NewLocalStore creates root if needed, readable by the server's user only.
+func NewLocalStore(root string) (*LocalStore, error) {
+	if root == "" {
+		return nil, errors.New("local blob store directory is empty")
+	}
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return nil, fmt.Errorf("create blob store directory: %w", err)
+	}
+	return &LocalStore{root: root}, nil
+}
+
+// Put writes body to a temporary file beside the blob and renames it into
+// place, so a failed or concurrent upload never leaves a partial blob
+// behind under path.
+func (s *LocalStore) Put(_ context.Context, path string, body io.Reader) (int64, error) {
+	name, err := s.file(path)
+	if err != nil {
+		return 0, err
+	}
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return 0, fmt.Errorf("create blob directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return 0, fmt.Errorf("create blob file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, body)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("write blob: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return 0, fmt.Errorf("store blob: %w", err)
+	}
+	return n, nil
+}
+
+func (s *LocalStore) Open(_ context.Context, path string) (io.ReadCloser, error) {
+	name, err := s.file(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open blob: %w", err)
+	}
+	return f, nil
+}
+
+func (s *LocalStore) Delete(_ context.Context, path string) error {
+	name, err := s.file(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("delete blob: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalStore) file(path string) (string, error) {
+	if err := checkPath(path); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(path)), nil
+}
@@ -0,0 +1,64 @@
+package blobstore_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"pmv2/backend/internal/blobstore"
+)
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := blobstore.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+
+	n, err := store.Put(ctx, "attachments/item/blob", strings.NewReader("ciphertext"))
+	if err != nil || n != int64(len("ciphertext")) {
+		t.Fatalf("Put = %d, %v", n, err)
+	}
+	if _, err := store.Put(ctx, "attachments/item/blob", strings.NewReader("replaced")); err != nil {
+		t.Fatalf("Put again: %v", err)
+	}
+	body, err := store.Open(ctx, "attachments/item/blob")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got, err := io.ReadAll(body)
+	body.Close()
+	if err != nil || string(got) != "replaced" {
+		t.Fatalf("blob = %q, %v; want the replacement", got, err)
+	}
+
+	if err := store.Delete(ctx, "attachments/item/blob"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := store.Delete(ctx, "attachments/item/blob"); err != nil {
+		t.Fatalf("Delete of a missing blob: %v", err)
+	}
+	if _, err := store.Open(ctx, "attachments/item/blob"); !errors.Is(err, blobstore.ErrNotFound) {
+		t.Fatalf("Open after Delete = %v, want ErrNotFound", err)
+	}
+
+	for _, path := range []string{"", "/etc/passwd", "../outside", "a/../../outside", "a//b", "./a"} {
+		if _, err := store.Put(ctx, path, strings.NewReader("x")); err == nil {
+			t.Errorf("Put(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if store, err := blobstore.New("", "", blobstore.S3Config{}); store != nil || err != nil {
+		t.Fatalf(`New("") = %v, %v; want no store`, store, err)
+	}
+	if _, err := blobstore.New("local", t.TempDir(), blobstore.S3Config{}); err != nil {
+		t.Fatalf(`New("local"): %v`, err)
+	}
+	if _, err := blobstore.New("ftp", "", blobstore.S3Config{}); err == nil {
+		t.Fatal(`New("ftp") succeeded, want an error`)
+	}
+}
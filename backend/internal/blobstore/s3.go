@@ -0,0 +1,414 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config locates a bucket of Amazon S3 or of a compatible service such
+// as MinIO. Endpoint defaults to AWS's endpoint for Region. With PathStyle
+// the bucket is the first segment of the path rather than part of the host
+// name, which MinIO and most self-hosted services need. Every blob is kept
+// under Prefix.
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	PathStyle       bool
+}
+
+// s3PartSize is how much of an upload is buffered at a time. A blob that
+// fits in one part is stored with a single PUT; a larger one is streamed
+// as a multipart upload, never holding more than a part in memory. S3
+// requires every part but the last to be at least 5 MiB.
+const s3PartSize = 8 << 20
+
+// maxSignedURLTTL is the longest a SigV4 presigned URL can be valid.
+const maxSignedURLTTL = 7 * 24 * time.Hour
+
+// emptySHA256 is the payload hash of a request without a body.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Store keeps blobs as objects in an S3 bucket, signing its requests
+// with AWS Signature Version 4. It suits deployments with several replicas,
+// which all see the same bucket, and can hand out signed download URLs.
+type S3Store struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+	client    *http.Client
+	partSize  int
+	now       func() time.Time
+}
+
+// NewS3Store checks cfg; it does not contact the service.
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	if cfg.Bucket == "" || cfg.Region == "" || cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("s3 blob store needs a bucket, region and credentials")
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", endpoint)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 30 * time.Second
+	return &S3Store{
+		endpoint:  u,
+		region:    cfg.Region,
+		bucket:    cfg.Bucket,
+		prefix:    strings.Trim(cfg.Prefix, "/"),
+		accessKey: cfg.AccessKeyID,
+		secretKey: cfg.SecretAccessKey,
+		pathStyle: cfg.PathStyle,
+		client:    &http.Client{Transport: transport},
+		partSize:  s3PartSize,
+		now:       time.Now,
+	}, nil
+}
+
+// Put uploads body, with a single PUT when it fits in one part and as a
+// multipart upload otherwise. A multipart upload that fails is aborted, so
+// S3 does not keep its parts; an object becomes visible only once complete.
+func (s *S3Store) Put(ctx context.Context, path string, body io.Reader) (int64, error) {
+	u, err := s.objectURL(path)
+	if err != nil {
+		return 0, err
+	}
+	part := make([]byte, s.partSize)
+	n, err := io.ReadFull(body, part)
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		if _, err := s.send(ctx, http.MethodPut, u, part[:n]); err != nil {
+			return 0, err
+		}
+		return int64(n), nil
+	case err != nil:
+		return 0, fmt.Errorf("read blob: %w", err)
+	}
+	return s.putMultipart(ctx, u, part, body)
+}
+
+type s3Part struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// putMultipart uploads first, a full part already read, and the rest of
+// body.
+func (s *S3Store) putMultipart(ctx context.Context, u *url.URL, first []byte, body io.Reader) (int64, error) {
+	resp, err := s.send(ctx, http.MethodPost, withQuery(u, url.Values{"uploads": {""}}), nil)
+	if err != nil {
+		return 0, err
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(resp, &initiated); err != nil || initiated.UploadID == "" {
+		return 0, fmt.Errorf("s3 create multipart upload: unexpected response")
+	}
+	upload := url.Values{"uploadId": {initiated.UploadID}}
+
+	total, err := s.uploadParts(ctx, u, upload, first, body)
+	if err != nil {
+		// Abort with a fresh context: ctx may be why the upload failed.
+		abortCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+		_, _ = s.send(abortCtx, http.MethodDelete, withQuery(u, upload), nil)
+		return 0, err
+	}
+	return total, nil
+}
+
+func (s *S3Store) uploadParts(ctx context.Context, u *url.URL, upload url.Values, part []byte, body io.Reader) (int64, error) {
+	var parts []s3Part
+	var total int64
+	for n := len(part); n > 0; {
+		query := url.Values{"uploadId": upload["uploadId"], "partNumber": {strconv.Itoa(len(parts) + 1)}}
+		req, err := s.request(ctx, http.MethodPut, withQuery(u, query), part[:n])
+		if err != nil {
+			return 0, err
+		}
+		resp, err := s.do(req)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		parts = append(parts, s3Part{PartNumber: len(parts) + 1, ETag: resp.Header.Get("ETag")})
+		total += int64(n)
+
+		n, err = io.ReadFull(body, part)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, fmt.Errorf("read blob: %w", err)
+		}
+	}
+
+	complete, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return 0, err
+	}
+	resp, err := s.send(ctx, http.MethodPost, withQuery(u, upload), complete)
+	if err != nil {
+		return 0, err
+	}
+	// Completing can fail after S3 has answered 200; the error is then in
+	// the body.
+	if err := s3ErrorFrom(resp); err != nil {
+		return 0, fmt.Errorf("s3 complete multipart upload: %w", err)
+	}
+	return total, nil
+}
+
+// Open streams the object at path.
+func (s *S3Store) Open(ctx context.Context, path string) (io.ReadCloser, error) {
+	u, err := s.objectURL(path)
+	if err != nil {
+		return nil, err
+	}
+	req, err := s.request(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Delete removes the object at path. S3 answers deletes of missing objects
+// with success too.
+func (s *S3Store) Delete(ctx context.Context, path string) error {
+	u, err := s.objectURL(path)
+	if err != nil {
+		return err
+	}
+	_, err = s.send(ctx, http.MethodDelete, u, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// SignedURL returns a presigned GET URL for the object at path, valid for
+// ttl. Anyone holding it can download the object until then without
+// credentials.
+func (s *S3Store) SignedURL(_ context.Context, path string, ttl time.Duration) (string, error) {
+	if ttl <= 0 || ttl > maxSignedURLTTL {
+		return "", fmt.Errorf("signed URL lifetime %s is out of range", ttl)
+	}
+	u, err := s.objectURL(path)
+	if err != nil {
+		return "", err
+	}
+	now := s.now().UTC()
+	query := url.Values{
+		"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+		"X-Amz-Credential":    {s.accessKey + "/" + s.scope(now)},
+		"X-Amz-Date":          {now.Format("20060102T150405Z")},
+		"X-Amz-Expires":       {strconv.Itoa(int(ttl / time.Second))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	u.RawQuery = canonicalQuery(query)
+	canonical := strings.Join([]string{
+		http.MethodGet, u.EscapedPath(), u.RawQuery, "host:" + u.Host + "\n", "host", "UNSIGNED-PAYLOAD",
+	}, "\n")
+	u.RawQuery += "&X-Amz-Signature=" + s.signature(now, canonical)
+	return u.String(), nil
+}
+
+// objectURL is the URL of the object holding the blob at path.
+func (s *S3Store) objectURL(path string) (*url.URL, error) {
+	if err := checkPath(path); err != nil {
+		return nil, err
+	}
+	key := path
+	if s.prefix != "" {
+		key = s.prefix + "/" + path
+	}
+	u := *s.endpoint
+	base := strings.TrimSuffix(u.Path, "/")
+	if s.pathStyle {
+		u.Path = base + "/" + s.bucket + "/" + key
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = base + "/" + key
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	return &u, nil
+}
+
+func withQuery(u *url.URL, query url.Values) *url.URL {
+	out := *u
+	out.RawQuery = canonicalQuery(query)
+	return &out
+}
+
+// send makes a signed request with body and returns the response body.
+func (s *S3Store) send(ctx context.Context, method string, u *url.URL, body []byte) ([]byte, error) {
+	req, err := s.request(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// do sends req and turns an error answer into an error: ErrNotFound for a
+// missing object, the service's code and message for anything else.
+func (s *S3Store) do(req *http.Request) (*http.Response, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 %s: %w", req.Method, err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && req.URL.Query().Get("uploadId") == "" {
+		return nil, ErrNotFound
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err := s3ErrorFrom(raw); err != nil {
+		return nil, fmt.Errorf("s3 %s: %d: %w", req.Method, resp.StatusCode, err)
+	}
+	return nil, fmt.Errorf("s3 %s: status %d", req.Method, resp.StatusCode)
+}
+
+// s3ErrorFrom returns the error in an S3 XML error document, or nil when
+// raw is not one.
+func s3ErrorFrom(raw []byte) error {
+	var doc struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+		Message string   `xml:"Message"`
+	}
+	if xml.Unmarshal(raw, &doc) != nil || doc.Code == "" {
+		return nil
+	}
+	return fmt.Errorf("%s: %s", doc.Code, doc.Message)
+}
+
+// request builds a request signed with the Authorization header. The body
+// is hashed into the signature, as S3 requires over plain HTTP.
+func (s *S3Store) request(ctx context.Context, method string, u *url.URL, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if body == nil {
+		req.Body, req.GetBody = http.NoBody, nil
+	}
+
+	now := s.now().UTC()
+	payloadHash := emptySHA256
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		method, u.EscapedPath(), u.RawQuery,
+		"host:" + u.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders, payloadHash,
+	}, "\n")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, s.scope(now), signedHeaders, s.signature(now, canonical)))
+	return req, nil
+}
+
+func (s *S3Store) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.region + "/s3/aws4_request"
+}
+
+// signature signs a canonical request as SigV4 specifies.
+func (s *S3Store) signature(now time.Time, canonical string) string {
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + now.Format("20060102T150405Z") + "\n" + s.scope(now) + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), now.Format("20060102"))
+	for _, part := range []string{s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query sorted by name, as SigV4 signs it.
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		for _, value := range query[name] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(uriEncode(name, true))
+			b.WriteByte('=')
+			b.WriteString(uriEncode(value, true))
+		}
+	}
+	return b.String()
+}
+
+// uriEncode percent-encodes everything but unreserved characters, and "/"
+// unless encodeSlash.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,170 @@
+package breach
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// bloomMagic starts a bloom filter file. It is followed by the number of
+// bits and of hash functions, as little-endian uint64 and uint32, and the
+// bits themselves, bit i being 1<<(i%8) of byte i/8.
+const bloomMagic = "pmbloom1"
+
+// BloomFilter is an offline set of breached password hashes. It may report
+// a password that is not in it (at the rate it was built for), never the
+// other way round.
+type BloomFilter struct {
+	bits   []byte
+	m      uint64 // number of bits
+	hashes uint32
+}
+
+// NewBloomFilter returns an empty filter sized for n hashes at false
+// positive rate p.
+func NewBloomFilter(n int, p float64) (*BloomFilter, error) {
+	if n < 1 {
+		return nil, errors.New("bloom filter needs at least one entry")
+	}
+	if p <= 0 || p >= 1 {
+		return nil, errors.New("bloom filter false positive rate must be between 0 and 1")
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	m = (m + 7) &^ 7
+	k := uint32(max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &BloomFilter{bits: make([]byte, m/8), m: m, hashes: k}, nil
+}
+
+// OpenBloomFilter loads a filter written by WriteTo.
+func OpenBloomFilter(path string) (*BloomFilter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open breach filter: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	header := make([]byte, len(bloomMagic)+12)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(bloomMagic)]) != bloomMagic {
+		return nil, fmt.Errorf("breach filter %s: not a bloom filter file", path)
+	}
+	m := binary.LittleEndian.Uint64(header[len(bloomMagic):])
+	k := binary.LittleEndian.Uint32(header[len(bloomMagic)+8:])
+	if m == 0 || m%8 != 0 || k == 0 {
+		return nil, fmt.Errorf("breach filter %s: invalid header", path)
+	}
+	if info, err := f.Stat(); err == nil && uint64(info.Size()) != uint64(len(header))+m/8 {
+		return nil, fmt.Errorf("breach filter %s: truncated", path)
+	}
+	bits := make([]byte, m/8)
+	if _, err := io.ReadFull(r, bits); err != nil {
+		return nil, fmt.Errorf("breach filter %s: %w", path, err)
+	}
+	return &BloomFilter{bits: bits, m: m, hashes: k}, nil
+}
+
+// WriteTo writes the filter in the format OpenBloomFilter reads.
+func (f *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, len(bloomMagic)+12)
+	copy(header, bloomMagic)
+	binary.LittleEndian.PutUint64(header[len(bloomMagic):], f.m)
+	binary.LittleEndian.PutUint32(header[len(bloomMagic)+8:], f.hashes)
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+	written, err := w.Write(f.bits)
+	return int64(n + written), err
+}
+
+// Add puts a SHA-1 digest of a password in the filter.
+func (f *BloomFilter) Add(digest [sha1.Size]byte) {
+	f.each(digest, func(bit uint64) bool {
+		f.bits[bit/8] |= 1 << (bit % 8)
+		return true
+	})
+}
+
+// Contains reports whether a SHA-1 digest of a password may be in the
+// filter.
+func (f *BloomFilter) Contains(digest [sha1.Size]byte) bool {
+	return f.each(digest, func(bit uint64) bool {
+		return f.bits[bit/8]&(1<<(bit%8)) != 0
+	})
+}
+
+// each calls fn with the bit of each hash function until it returns false,
+// and reports whether it never did. The digest is already uniformly
+// distributed, so two halves of it make the hash functions by double
+// hashing.
+func (f *BloomFilter) each(digest [sha1.Size]byte, fn func(bit uint64) bool) bool {
+	h1 := binary.LittleEndian.Uint64(digest[0:8])
+	h2 := binary.LittleEndian.Uint64(digest[8:16]) | 1
+	for i := range uint64(f.hashes) {
+		if !fn((h1 + i*h2) % f.m) {
+			return false
+		}
+	}
+	return true
+}
+
+// BuildBloomFilter reads a Pwned Passwords hash list, one "SHA1:COUNT"
+// line per password as the HIBP downloader writes it, and returns a filter
+// of the hashes seen at least minCount times at false positive rate p. The
+// list is read twice, first to size the filter.
+func BuildBloomFilter(list io.ReadSeeker, minCount int, p float64) (*BloomFilter, int, error) {
+	n := 0
+	if err := scanHashList(list, minCount, func([sha1.Size]byte) { n++ }); err != nil {
+		return nil, 0, err
+	}
+	filter, err := NewBloomFilter(n, p)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := list.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	if err := scanHashList(list, minCount, filter.Add); err != nil {
+		return nil, 0, err
+	}
+	return filter, n, nil
+}
+
+func scanHashList(r io.Reader, minCount int, fn func([sha1.Size]byte)) error {
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		hash, countText, hasCount := strings.Cut(text, ":")
+		var digest [sha1.Size]byte
+		if len(hash) != 2*sha1.Size {
+			return fmt.Errorf("hash list line %d: not a SHA-1 hash", line)
+		}
+		if _, err := hex.Decode(digest[:], []byte(hash)); err != nil {
+			return fmt.Errorf("hash list line %d: not a SHA-1 hash", line)
+		}
+		if hasCount {
+			count, err := strconv.Atoi(countText)
+			if err != nil {
+				return fmt.Errorf("hash list line %d: invalid count", line)
+			}
+			if count < minCount {
+				continue
+			}
+		}
+		fn(digest)
+	}
+	return scanner.Err()
+}
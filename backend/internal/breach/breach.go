@@ -0,0 +1,160 @@
+// Package breach tells whether a password has appeared in a known data
+// breach. It asks the Have I Been Pwned range API with k-anonymity: only the
+// first five hex digits of the password's SHA-1 hash leave the server, and
+// the suffixes sharing them come back to be compared locally. Ranges are
+// cached for a while, and when the API is unreachable, or not configured,
+// an offline bloom filter built from the Pwned Passwords hash list answers
+// instead.
+package breach
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRangeURL is the Pwned Passwords range endpoint; the hash prefix is
+// appended to it.
+const DefaultRangeURL = "https://api.pwnedpasswords.com/range/"
+
+const (
+	prefixLength = 5
+	// maxRangeBytes bounds a range response; padded ones are about 30 KiB.
+	maxRangeBytes = 1 << 20
+	// maxCachedRanges bounds the cache; there are 16^5 prefixes in all.
+	maxCachedRanges = 4096
+)
+
+// Checker looks passwords up in the range API, the filter, or both.
+type Checker struct {
+	rangeURL string
+	client   *http.Client
+	filter   *BloomFilter
+	cacheTTL time.Duration
+	now      func() time.Time
+
+	mu     sync.Mutex
+	ranges map[string]cachedRange // by hash prefix
+}
+
+type cachedRange struct {
+	suffixes  map[string]struct{}
+	expiresAt time.Time
+}
+
+// NewChecker returns a checker asking rangeURL, when set, and falling back
+// to filter, when not nil. Ranges are kept for cacheTTL; 0 disables the
+// cache. A nil client uses one that gives up after five seconds.
+func NewChecker(rangeURL string, filter *BloomFilter, cacheTTL time.Duration, client *http.Client) (*Checker, error) {
+	if rangeURL == "" && filter == nil {
+		return nil, errors.New("breach check needs a range API URL or a bloom filter")
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &Checker{
+		rangeURL: rangeURL,
+		client:   client,
+		filter:   filter,
+		cacheTTL: cacheTTL,
+		now:      time.Now,
+		ranges:   make(map[string]cachedRange),
+	}, nil
+}
+
+// Breached reports whether password is a known breached password. An error
+// means neither the API nor a filter could tell.
+func (c *Checker) Breached(ctx context.Context, password string) (bool, error) {
+	digest := sha1.Sum([]byte(password))
+	if c.rangeURL == "" {
+		return c.filter.Contains(digest), nil
+	}
+
+	hash := strings.ToUpper(hex.EncodeToString(digest[:]))
+	prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+	suffixes, err := c.lookupRange(ctx, prefix)
+	if err != nil {
+		if c.filter != nil {
+			return c.filter.Contains(digest), nil
+		}
+		return false, err
+	}
+	_, found := suffixes[suffix]
+	return found, nil
+}
+
+func (c *Checker) lookupRange(ctx context.Context, prefix string) (map[string]struct{}, error) {
+	now := c.now()
+	c.mu.Lock()
+	cached, ok := c.ranges[prefix]
+	c.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.suffixes, nil
+	}
+
+	suffixes, err := c.fetchRange(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if c.cacheTTL > 0 {
+		c.mu.Lock()
+		if len(c.ranges) >= maxCachedRanges {
+			for p, r := range c.ranges {
+				if !now.Before(r.expiresAt) {
+					delete(c.ranges, p)
+				}
+			}
+			// Still full of live ranges: make room with an arbitrary one.
+			for p := range c.ranges {
+				if len(c.ranges) < maxCachedRanges {
+					break
+				}
+				delete(c.ranges, p)
+			}
+		}
+		c.ranges[prefix] = cachedRange{suffixes: suffixes, expiresAt: now.Add(c.cacheTTL)}
+		c.mu.Unlock()
+	}
+	return suffixes, nil
+}
+
+// fetchRange asks for the suffixes of prefix. Padding is requested so the
+// response size does not hint at the prefix; the padding entries have a
+// count of 0 and are dropped.
+func (c *Checker) fetchRange(ctx context.Context, prefix string) (map[string]struct{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.rangeURL+prefix, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Add-Padding", "true")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("breach range lookup: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("breach range lookup: unexpected status %d", resp.StatusCode)
+	}
+
+	suffixes := make(map[string]struct{})
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, maxRangeBytes))
+	for scanner.Scan() {
+		suffix, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok || count == "0" {
+			continue
+		}
+		suffixes[strings.ToUpper(suffix)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read breach range: %w", err)
+	}
+	return suffixes, nil
+}
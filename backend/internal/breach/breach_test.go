@@ -0,0 +1,118 @@
+package breach_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/breach"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func TestCheckerRangeAPI(t *testing.T) {
+	breached := sha1Hex("password1")
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		if r.Header.Get("Add-Padding") != "true" {
+			t.Errorf("range request without padding")
+		}
+		if strings.TrimPrefix(r.URL.Path, "/range/") == breached[:5] {
+			fmt.Fprintf(w, "%s:42\r\n", breached[5:])
+		}
+		// Padding entries never count as breached.
+		fmt.Fprintf(w, "%s:0\r\n", sha1Hex("Correct-Horse-9")[5:])
+	}))
+	defer srv.Close()
+
+	checker, err := breach.NewChecker(srv.URL+"/range/", nil, time.Hour, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, tc := range []struct {
+		password string
+		want     bool
+	}{{"password1", true}, {"Correct-Horse-9", false}, {"password1", true}} {
+		if got, err := checker.Breached(ctx, tc.password); err != nil || got != tc.want {
+			t.Fatalf("Breached(%q) = %v, %v; want %v", tc.password, got, err, tc.want)
+		}
+	}
+	// Only the prefix leaves the server, and the repeated one is cached.
+	if len(requests) != 2 || requests[0] != "/range/"+breached[:5] {
+		t.Fatalf("requests = %v", requests)
+	}
+
+	srv.Close()
+	if _, err := checker.Breached(ctx, "unseen-prefix"); err == nil {
+		t.Fatal("an unreachable API without a filter should fail the check")
+	}
+}
+
+func TestBloomFilterFallback(t *testing.T) {
+	dir := t.TempDir()
+	list := filepath.Join(dir, "pwned.txt")
+	if err := os.WriteFile(list, []byte(sha1Hex("password1")+":42\n"+sha1Hex("rarely-used")+":1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	filter, n, err := breach.BuildBloomFilter(f, 2, 0.001)
+	if err != nil || n != 1 {
+		t.Fatalf("BuildBloomFilter = %d, %v", n, err)
+	}
+	path := filepath.Join(dir, "pwned.bloom")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := filter.WriteTo(out); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+	loaded, err := breach.OpenBloomFilter(path)
+	if err != nil {
+		t.Fatalf("OpenBloomFilter: %v", err)
+	}
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	ctx := context.Background()
+	for _, rangeURL := range []string{down.URL + "/range/", ""} {
+		checker, err := breach.NewChecker(rangeURL, loaded, time.Hour, down.Client())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := checker.Breached(ctx, "password1"); err != nil || !got {
+			t.Fatalf("range %q: Breached(password1) = %v, %v", rangeURL, got, err)
+		}
+		// Below the minimum count, so left out of the filter.
+		if got, err := checker.Breached(ctx, "rarely-used"); err != nil || got {
+			t.Fatalf("range %q: Breached(rarely-used) = %v, %v", rangeURL, got, err)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("pmbloom1 truncated"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := breach.OpenBloomFilter(path); err == nil {
+		t.Fatal("a truncated filter loaded")
+	}
+}
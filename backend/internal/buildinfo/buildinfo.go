@@ -0,0 +1,50 @@
+// Package buildinfo identifies the running build. The release pipeline sets
+// the variables at link time:
+//
+//	go build -ldflags "-X pmv2/backend/internal/buildinfo.Version=v1.4.0 \
+//		-X pmv2/backend/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//		-X pmv2/backend/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Builds without them (go run, go test) fall back to the VCS stamp the Go
+// toolchain embeds when building inside a git checkout.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info describes a build.
+type Info struct {
+	Version   string
+	Commit    string
+	BuildTime string
+	GoVersion string
+}
+
+var get = sync.OnceValue(func() Info {
+	info := Info{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = setting.Value
+			}
+		}
+	}
+	return info
+})
+
+// Get returns the running build's info.
+func Get() Info {
+	return get()
+}
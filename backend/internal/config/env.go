@@ -0,0 +1,180 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loader reads typed values from the environment, then the config file. A
+// value that does not parse falls back to its default and is remembered, so
+// Validate can report it instead of the service silently running with the
+// fallback.
+type loader struct {
+	file     map[string]string
+	used     map[string]bool
+	problems []string
+}
+
+func (l *loader) get(key, fallback string) string {
+	if l.used == nil {
+		l.used = make(map[string]bool)
+	}
+	l.used[key] = true
+
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	if val, ok := l.file[key]; ok && val != "" {
+		return val
+	}
+	return fallback
+}
+
+// unusedFileKeys reports config file settings that match no known key,
+// which are almost always typos.
+func (l *loader) unusedFileKeys() []string {
+	var problems []string
+	for key := range l.file {
+		if !l.used[key] {
+			problems = append(problems, fmt.Sprintf("config file sets unknown key %s", key))
+		}
+	}
+	slices.Sort(problems)
+	return problems
+}
+
+func (l *loader) invalid(key, value, expected string) {
+	l.problems = append(l.problems, fmt.Sprintf("%s=%q is not a valid %s", key, value, expected))
+}
+
+func (l *loader) duration(key, fallback string) time.Duration {
+	value := l.get(key, fallback)
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		l.invalid(key, value, "duration (e.g. 30s, 15m, 24h)")
+		d, _ = time.ParseDuration(fallback)
+	}
+	return d
+}
+
+func (l *loader) int(key, fallback string) int {
+	value := l.get(key, fallback)
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		l.invalid(key, value, "integer")
+		n, _ = strconv.Atoi(fallback)
+	}
+	return n
+}
+
+func (l *loader) bool(key, fallback string) bool {
+	value := l.get(key, fallback)
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		l.invalid(key, value, "boolean")
+		b, _ = strconv.ParseBool(fallback)
+	}
+	return b
+}
+
+// time parses an RFC 3339 timestamp; an empty value is the zero time.
+func (l *loader) time(key string) time.Time {
+	value := strings.TrimSpace(l.get(key, ""))
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		l.invalid(key, value, "RFC 3339 timestamp (e.g. 2026-01-31T00:00:00Z)")
+		return time.Time{}
+	}
+	return t
+}
+
+// rateLimits overlays "name=rate:burst" pairs (comma separated) on top of
+// defaultRateLimits, e.g. "login=10:20,export=0:0".
+func (l *loader) rateLimits(key string) map[string]RateLimitRule {
+	rules := make(map[string]RateLimitRule, len(defaultRateLimits))
+	for name, rule := range defaultRateLimits {
+		rules[name] = rule
+	}
+
+	for _, entry := range splitList(l.get(key, "")) {
+		name, spec, ok := strings.Cut(entry, "=")
+		if !ok {
+			l.invalid(key, entry, "name=rate:burst entry")
+			continue
+		}
+		rateStr, burstStr, ok := strings.Cut(spec, ":")
+		if !ok {
+			l.invalid(key, entry, "name=rate:burst entry")
+			continue
+		}
+		r, err := strconv.ParseFloat(strings.TrimSpace(rateStr), 64)
+		if err != nil || r < 0 {
+			l.invalid(key, entry, "name=rate:burst entry (rate must be >= 0)")
+			continue
+		}
+		b, err := strconv.Atoi(strings.TrimSpace(burstStr))
+		if err != nil || b < 0 {
+			l.invalid(key, entry, "name=rate:burst entry (burst must be >= 0)")
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, known := defaultRateLimits[name]; !known {
+			l.problems = append(l.problems, fmt.Sprintf("%s names unknown route %q", key, name))
+			continue
+		}
+		rules[name] = RateLimitRule{Rate: r, Burst: b}
+	}
+	return rules
+}
+
+// routePolicies parses comma-separated "/prefix=value" entries into a map
+// keyed by path prefix.
+func (l *loader) routePolicies(key string) map[string]string {
+	policies := make(map[string]string)
+	for _, entry := range splitList(l.get(key, "")) {
+		prefix, value, ok := strings.Cut(entry, "=")
+		prefix = strings.TrimSpace(prefix)
+		if !ok || !strings.HasPrefix(prefix, "/") {
+			l.invalid(key, entry, "/prefix=value entry")
+			continue
+		}
+		policies[prefix] = strings.TrimSpace(value)
+	}
+	return policies
+}
+
+// prefixes parses a comma-separated list of IP addresses and CIDR ranges.
+func (l *loader) prefixes(key string) []netip.Prefix {
+	var out []netip.Prefix
+	for _, entry := range splitList(l.get(key, "")) {
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			out = append(out, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			l.invalid(key, entry, "IP address or CIDR range")
+			continue
+		}
+		out = append(out, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return out
+}
+
+func splitList(value string) []string {
+	var out []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRateLimitsOverlayDefaults(t *testing.T) {
+	t.Setenv("RATE_LIMITS", "login=10:20, recovery=0:0")
+
+	var l loader
+	rules := l.rateLimits("RATE_LIMITS")
+
+	if len(l.problems) != 0 {
+		t.Fatalf("unexpected problems: %v", l.problems)
+	}
+	if got := rules["login"]; got != (RateLimitRule{Rate: 10, Burst: 20}) {
+		t.Errorf("login = %+v, want overridden 10:20", got)
+	}
+	if got := rules["recovery"]; got != (RateLimitRule{}) {
+		t.Errorf("recovery = %+v, want disabled", got)
+	}
+	if got := rules["register"]; got != defaultRateLimits["register"] {
+		t.Errorf("register = %+v, want the default", got)
+	}
+}
+
+func TestRateLimitsReportBadEntries(t *testing.T) {
+	t.Setenv("RATE_LIMITS", "login=fast:20,register=1,nosuchroute=1:1,recovery=-1:5")
+
+	var l loader
+	rules := l.rateLimits("RATE_LIMITS")
+
+	if len(l.problems) != 4 {
+		t.Fatalf("got %d problems, want 4: %v", len(l.problems), l.problems)
+	}
+	if !strings.Contains(strings.Join(l.problems, "\n"), `unknown route "nosuchroute"`) {
+		t.Errorf("unknown route not reported: %v", l.problems)
+	}
+	for name, rule := range defaultRateLimits {
+		if rules[name] != rule {
+			t.Errorf("%s = %+v, want the default after a bad override", name, rules[name])
+		}
+	}
+}
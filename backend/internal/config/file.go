@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// readConfigFile reads a YAML (or JSON) file, or a TOML file when the name
+// ends in .toml, and flattens it into the same keys the environment uses:
+// nested sections are joined with "_" and upper cased, so
+//
+//	smtp:
+//	  host: mail.example.com
+//	rate_limit:
+//	  exempt_paths: [/healthz, /metrics]
+//
+// sets SMTP_HOST and RATE_LIMIT_EXEMPT_PATHS (lists become comma separated).
+// The top-level rate_limits section maps route names to {rate, burst} and
+// becomes RATE_LIMITS. Flat keys such as DATABASE_URL work as well.
+func readConfigFile(path string) (map[string]string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	var doc map[string]any
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		err = toml.Unmarshal(raw, &doc)
+	} else {
+		err = yaml.Unmarshal(raw, &doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	for key, value := range doc {
+		if strings.EqualFold(key, "rate_limits") {
+			limits, err := flattenRateLimits(value)
+			if err != nil {
+				return nil, fmt.Errorf("config file %s: %w", path, err)
+			}
+			values["RATE_LIMITS"] = limits
+			continue
+		}
+		if err := flattenConfig(strings.ToUpper(key), value, values); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
+	return values, nil
+}
+
+func flattenConfig(key string, value any, out map[string]string) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case map[string]any:
+		for child, childValue := range v {
+			if err := flattenConfig(key+"_"+strings.ToUpper(child), childValue, out); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case map[string]any, []any:
+				return fmt.Errorf("%s: lists may only contain plain values", key)
+			}
+			items = append(items, fmt.Sprint(item))
+		}
+		out[key] = strings.Join(items, ",")
+		return nil
+	default:
+		out[key] = fmt.Sprint(v)
+		return nil
+	}
+}
+
+// flattenRateLimits accepts either {login: {rate: 5, burst: 15}} or
+// {login: "5:15"} and renders the RATE_LIMITS syntax.
+func flattenRateLimits(value any) (string, error) {
+	routes, ok := value.(map[string]any)
+	if !ok {
+		return "", fmt.Errorf("rate_limits must map route names to {rate, burst}")
+	}
+
+	names := make([]string, 0, len(routes))
+	for name := range routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := make([]string, 0, len(routes))
+	for _, name := range names {
+		switch rule := routes[name].(type) {
+		case string:
+			entries = append(entries, name+"="+rule)
+		case map[string]any:
+			entries = append(entries, fmt.Sprintf("%s=%v:%v", name, rule["rate"], rule["burst"]))
+		default:
+			return "", fmt.Errorf("rate_limits.%s must be {rate, burst} or \"rate:burst\"", name)
+		}
+	}
+	return strings.Join(entries, ","), nil
+}
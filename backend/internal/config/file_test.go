@@ -0,0 +1,143 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	return path
+}
+
+const yamlConfig = `
+app:
+  port: 9090
+  read_timeout: 5s
+smtp:
+  host: mail.example.com
+  port: 2525
+rate_limit:
+  exempt_paths: [/healthz, /metrics]
+rate_limits:
+  login: {rate: 10, burst: 20}
+  recovery: "0:0"
+`
+
+const tomlConfig = `
+[app]
+port = 9090
+read_timeout = "5s"
+
+[smtp]
+host = "mail.example.com"
+port = 2525
+
+[rate_limit]
+exempt_paths = ["/healthz", "/metrics"]
+
+[rate_limits]
+login = { rate = 10, burst = 20 }
+recovery = "0:0"
+`
+
+func TestReadConfigFileFlattensNestedKeys(t *testing.T) {
+	for _, tc := range []struct{ name, content string }{
+		{"config.yaml", yamlConfig},
+		{"config.toml", tomlConfig},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			values, err := readConfigFile(writeConfigFile(t, tc.name, tc.content))
+			if err != nil {
+				t.Fatalf("readConfigFile: %v", err)
+			}
+			want := map[string]string{
+				"APP_PORT":                "9090",
+				"APP_READ_TIMEOUT":        "5s",
+				"SMTP_HOST":               "mail.example.com",
+				"SMTP_PORT":               "2525",
+				"RATE_LIMIT_EXEMPT_PATHS": "/healthz,/metrics",
+				"RATE_LIMITS":             "login=10:20,recovery=0:0",
+			}
+			for key, value := range want {
+				if values[key] != value {
+					t.Errorf("%s = %q, want %q", key, values[key], value)
+				}
+			}
+			if len(values) != len(want) {
+				t.Errorf("got %d keys, want %d: %v", len(values), len(want), values)
+			}
+		})
+	}
+}
+
+func TestReadConfigFileErrors(t *testing.T) {
+	for _, tc := range []struct{ name, content string }{
+		{"broken.yaml", "app: [unclosed"},
+		{"broken.toml", "[app\nport = 1"},
+		{"nested-list.yaml", "admin:\n  ips: [[1, 2]]\n"},
+		{"rate-limits.yaml", "rate_limits: 5\n"},
+	} {
+		if _, err := readConfigFile(writeConfigFile(t, tc.name, tc.content)); err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+	if _, err := readConfigFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("missing file: expected an error")
+	}
+}
+
+func TestLoadFileEnvironmentOverridesFile(t *testing.T) {
+	path := writeConfigFile(t, "config.toml", tomlConfig)
+	t.Setenv("APP_PORT", "")
+	t.Setenv("APP_READ_TIMEOUT", "")
+	t.Setenv("SMTP_PORT", "")
+	t.Setenv("SMTP_HOST", "override.example.com")
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	if cfg.Port != "9090" {
+		t.Errorf("Port = %q, want the file value 9090", cfg.Port)
+	}
+	if cfg.ReadTimeout != 5*time.Second {
+		t.Errorf("ReadTimeout = %s, want the file value 5s", cfg.ReadTimeout)
+	}
+	if cfg.SMTPHost != "override.example.com" {
+		t.Errorf("SMTPHost = %q, want the environment to win", cfg.SMTPHost)
+	}
+	if cfg.SMTPPort != 2525 {
+		t.Errorf("SMTPPort = %d, want 2525", cfg.SMTPPort)
+	}
+	if got := cfg.RateLimits["login"]; got != (RateLimitRule{Rate: 10, Burst: 20}) {
+		t.Errorf("login rate limit = %+v, want 10:20", got)
+	}
+}
+
+func TestLoadFileReportsUnknownKeys(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "smtp:\n  hots: mail.example.com\n")
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	if !hasProblem(problems(t, cfg), "unknown key SMTP_HOTS") {
+		t.Fatal("a misspelt key must be reported")
+	}
+}
+
+func TestExampleConfigFileHasNoUnknownKeys(t *testing.T) {
+	cfg, err := LoadFile(filepath.Join("..", "..", "config.example.yaml"))
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	if hasProblem(problems(t, cfg), "unknown key") {
+		t.Fatalf("config.example.yaml uses unknown keys: %v", problems(t, cfg))
+	}
+}
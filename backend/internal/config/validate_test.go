@@ -0,0 +1,327 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// problems returns the validation problems of cfg, or nil when it is valid.
+func problems(t *testing.T, cfg Config) []string {
+	t.Helper()
+	err := cfg.Validate()
+	if err == nil {
+		return nil
+	}
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Validate returned %T, want *ValidationError", err)
+	}
+	return verr.Problems
+}
+
+func hasProblem(problems []string, substr string) bool {
+	for _, p := range problems {
+		if strings.Contains(p, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTrashPurgeIsOptIn(t *testing.T) {
+	t.Setenv("TRASH_RETENTION", "")
+	cfg := Load()
+	if cfg.TrashRetention != 0 {
+		t.Fatalf("TrashRetention = %s, want 0 (disabled) by default", cfg.TrashRetention)
+	}
+	if hasProblem(problems(t, cfg), "TRASH_RETENTION") {
+		t.Fatal("a disabled trash purge must validate")
+	}
+
+	cfg.TrashRetention = -time.Hour
+	if !hasProblem(problems(t, cfg), "TRASH_RETENTION") {
+		t.Fatal("a negative TRASH_RETENTION must be rejected")
+	}
+
+	t.Setenv("TRASH_RETENTION", "720h")
+	if got := Load().TrashRetention; got != 720*time.Hour {
+		t.Fatalf("TrashRetention = %s, want 720h", got)
+	}
+}
+
+func TestAuditRetentionIsOptIn(t *testing.T) {
+	t.Setenv("AUDIT_RETENTION", "")
+	t.Setenv("LOGIN_HISTORY_RETENTION", "")
+	cfg := Load()
+	if cfg.AuditRetention != 0 || cfg.LoginHistoryRetention != 0 {
+		t.Fatalf("retention = %s/%s, want both 0 (keep) by default", cfg.AuditRetention, cfg.LoginHistoryRetention)
+	}
+
+	cfg.AuditRetention = -time.Hour
+	cfg.LoginHistoryRetention = -time.Hour
+	got := problems(t, cfg)
+	if !hasProblem(got, "AUDIT_RETENTION") || !hasProblem(got, "LOGIN_HISTORY_RETENTION") {
+		t.Fatalf("problems = %v, want negative retentions rejected", got)
+	}
+}
+
+func TestTelemetryIsOffAndNeedsAnEndpoint(t *testing.T) {
+	t.Setenv("TELEMETRY_ENABLED", "")
+	cfg := Load()
+	if cfg.TelemetryEnabled {
+		t.Fatal("telemetry must be off by default")
+	}
+	if hasProblem(problems(t, cfg), "TELEMETRY") {
+		t.Fatal("disabled telemetry must validate without an endpoint")
+	}
+
+	cfg.TelemetryEnabled = true
+	if !hasProblem(problems(t, cfg), "TELEMETRY_ENDPOINT") {
+		t.Fatal("enabled telemetry without an endpoint must be rejected")
+	}
+	cfg.TelemetryEndpoint = "https://telemetry.example.com/v1/report"
+	if hasProblem(problems(t, cfg), "TELEMETRY") {
+		t.Fatal("enabled telemetry with an https endpoint must validate")
+	}
+}
+
+func TestS3BlobStoreNeedsABucketAndCredentials(t *testing.T) {
+	cfg := Load()
+	cfg.BlobStore = "s3"
+	if !hasProblem(problems(t, cfg), "BLOB_STORE_S3_BUCKET") {
+		t.Fatal("an s3 blob store without a bucket must be rejected")
+	}
+	cfg.BlobStoreS3Bucket, cfg.BlobStoreS3AccessKeyID, cfg.BlobStoreS3SecretAccessKey = "vault", "key", "secret"
+	if hasProblem(problems(t, cfg), "BLOB_STORE") {
+		t.Fatalf("an s3 blob store on AWS must validate: %v", problems(t, cfg))
+	}
+
+	cfg.BlobStoreS3Endpoint = "minio:9000"
+	if !hasProblem(problems(t, cfg), "BLOB_STORE_S3_ENDPOINT") {
+		t.Fatal("an endpoint without a scheme must be rejected")
+	}
+	cfg.BlobStoreS3Endpoint = "http://minio:9000"
+	cfg.BlobStoreSignedURLTTL = 30 * 24 * time.Hour
+	if !hasProblem(problems(t, cfg), "BLOB_STORE_SIGNED_URL_TTL") {
+		t.Fatal("signed URLs valid for over seven days must be rejected")
+	}
+}
+
+func TestIPBanMaxDurationCoversBaseDuration(t *testing.T) {
+	cfg := Load()
+	cfg.IPBanEnabled = true
+	if hasProblem(problems(t, cfg), "IP_BAN") {
+		t.Fatal("the default IP ban settings must validate")
+	}
+	cfg.IPBanMaxDuration = cfg.IPBanBaseDuration / 2
+	if !hasProblem(problems(t, cfg), "IP_BAN_MAX_DURATION") {
+		t.Fatal("a max ban shorter than the first ban must be rejected")
+	}
+}
+
+func TestProductionRejectsLogMailer(t *testing.T) {
+	cfg := Load()
+	cfg.MailerDriver = "log"
+	if hasProblem(problems(t, cfg), "MAILER_DRIVER") {
+		t.Fatal("the log mailer must be allowed in development")
+	}
+
+	cfg.Env = "production"
+	if !hasProblem(problems(t, cfg), "MAILER_DRIVER=log") {
+		t.Fatal("the log mailer must be rejected in production")
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	t.Setenv("APP_READ_TIMEOUT", "soon")
+	t.Setenv("SMTP_PORT", "")
+	cfg := Load()
+	cfg.Env = "production"
+	cfg.Port = "http"
+	cfg.MailerDriver = "smtp"
+	cfg.SMTPHost = ""
+
+	got := problems(t, cfg)
+	for _, want := range []string{
+		`APP_READ_TIMEOUT="soon" is not a valid duration`,
+		`APP_PORT="http"`,
+		"MAILER_DRIVER=smtp requires SMTP_HOST",
+		"AUTH_TOKEN_PEPPER",
+		"DATABASE_URL",
+	} {
+		if !hasProblem(got, want) {
+			t.Errorf("missing problem %q in %v", want, got)
+		}
+	}
+
+	err := cfg.Validate()
+	if !strings.Contains(err.Error(), fmt.Sprintf("(%d problem(s))", len(got))) {
+		t.Errorf("error text does not count the problems: %s", err)
+	}
+}
+
+func TestDevelopmentDefaultsValidate(t *testing.T) {
+	cfg := Load()
+	cfg.Env = "dev"
+	if got := problems(t, cfg); got != nil {
+		t.Fatalf("default development config has problems: %v", got)
+	}
+}
+
+func TestTLSSettingsAreValidated(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{"cert without key", func(c *Config) { c.TLSCertFile = "/etc/pmv2/cert.pem" }, "must be set together"},
+		{"unreadable files", func(c *Config) {
+			c.TLSCertFile, c.TLSKeyFile = "/nonexistent/cert.pem", "/nonexistent/key.pem"
+		}, "cannot be loaded"},
+		{"unknown min version", func(c *Config) {
+			c.TLSCertFile, c.TLSKeyFile, c.TLSMinVersion = "/nonexistent/cert.pem", "/nonexistent/key.pem", "1.1"
+		}, "TLS_MIN_VERSION"},
+		{"redirect without tls", func(c *Config) { c.HTTPRedirectPort = "80" }, "HTTP_REDIRECT_PORT requires"},
+		{"redirect on api port", func(c *Config) { c.HTTPRedirectPort = c.Port }, "must differ from APP_PORT"},
+		{"h2c with tls", func(c *Config) {
+			c.TLSCertFile, c.TLSKeyFile = "/nonexistent/cert.pem", "/nonexistent/key.pem"
+			c.HTTP2Enabled, c.H2CEnabled = true, true
+		}, "H2C_ENABLED is only for plain-HTTP"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := Load()
+			tc.modify(&cfg)
+			if got := problems(t, cfg); !hasProblem(got, tc.want) {
+				t.Fatalf("want a problem containing %q, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestACMESettingsAreValidated(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{"combined with files", func(c *Config) { c.TLSCertFile = "/etc/pmv2/cert.pem" }, "cannot be combined"},
+		{"wildcard domain", func(c *Config) { c.ACMEDomains = []string{"*.example.com"} }, "not a plain host name"},
+		{"domain with port", func(c *Config) { c.ACMEDomains = []string{"vault.example.com:443"} }, "not a plain host name"},
+		{"no cache dir", func(c *Config) { c.ACMECacheDir = " " }, "ACME_CACHE_DIR is required"},
+		{"bad email", func(c *Config) { c.ACMEEmail = "ops" }, "ACME_EMAIL"},
+		{"plain http directory", func(c *Config) { c.ACMEDirectoryURL = "http://acme.example.com/dir" }, "ACME_DIRECTORY_URL"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := Load()
+			cfg.ACMEDomains = []string{"vault.example.com"}
+			cfg.ACMECacheDir = t.TempDir()
+			tc.modify(&cfg)
+			if got := problems(t, cfg); !hasProblem(got, tc.want) {
+				t.Fatalf("want a problem containing %q, got %v", tc.want, got)
+			}
+		})
+	}
+
+	cfg := Load()
+	cfg.ACMEDomains = []string{"vault.example.com"}
+	cfg.ACMECacheDir = t.TempDir()
+	cfg.HTTPRedirectPort = "80"
+	if got := problems(t, cfg); got != nil {
+		t.Fatalf("valid ACME config has problems: %v", got)
+	}
+}
+
+func TestCORSOriginsAreValidated(t *testing.T) {
+	cfg := Load()
+	cfg.CORSAllowedOrigins = []string{"https://vault.example.com", "https://*.example.com:8443", "chrome-extension://abcdef"}
+	cfg.CORSAnonymousOrigins = []string{"*"}
+	if got := problems(t, cfg); got != nil {
+		t.Fatalf("valid CORS origins have problems: %v", got)
+	}
+
+	cfg.CORSAllowedOrigins = []string{"*", "vault.example.com", "https://vault.example.com/app", "https://*"}
+	cfg.CORSAnonymousOrigins = []string{"https://a.*.example.com"}
+	got := problems(t, cfg)
+	if len(got) != 5 {
+		t.Fatalf("got %d problems, want 5: %v", len(got), got)
+	}
+}
+
+func TestSecurityHeaderSettings(t *testing.T) {
+	t.Setenv("SECURITY_CSP_ROUTES", "/api/v1/events=default-src 'none'; connect-src 'self', nonsense")
+	cfg := Load()
+	if got := cfg.SecurityRouteCSP["/api/v1/events"]; got != "default-src 'none'; connect-src 'self'" {
+		t.Fatalf("route CSP = %q", got)
+	}
+	if !hasProblem(problems(t, cfg), `SECURITY_CSP_ROUTES="nonsense"`) {
+		t.Fatal("an entry without a path prefix must be reported")
+	}
+
+	t.Setenv("SECURITY_CSP_ROUTES", "")
+	cfg = Load()
+	cfg.HSTSPreload = true
+	cfg.HSTSMaxAge = 24 * time.Hour
+	if !hasProblem(problems(t, cfg), "HSTS_PRELOAD") {
+		t.Fatal("preload with a short max-age must be rejected")
+	}
+	cfg.HSTSMaxAge = 2 * 365 * 24 * time.Hour
+	cfg.SecurityCSP = "default-src 'none'\r\nX-Injected: 1"
+	got := problems(t, cfg)
+	if hasProblem(got, "HSTS_PRELOAD") || !hasProblem(got, "SECURITY_CSP must be a single line") {
+		t.Fatalf("problems = %v", got)
+	}
+}
+
+func TestAPIV1DeprecationSettings(t *testing.T) {
+	t.Setenv("API_V1_DEPRECATED_AT", "2026-01-01T00:00:00Z")
+	t.Setenv("API_V1_SUNSET", "2027-01-01T00:00:00Z")
+	cfg := Load()
+	if !cfg.APIV1DeprecatedAt.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) || cfg.APIV1Sunset.Year() != 2027 {
+		t.Fatalf("deprecation = %v, sunset = %v", cfg.APIV1DeprecatedAt, cfg.APIV1Sunset)
+	}
+	if got := problems(t, cfg); hasProblem(got, "API_V1") {
+		t.Fatalf("problems = %v", got)
+	}
+
+	t.Setenv("API_V1_SUNSET", "2025-06-01T00:00:00Z")
+	if !hasProblem(problems(t, Load()), "API_V1_SUNSET requires") {
+		t.Fatal("a sunset before the deprecation must be rejected")
+	}
+
+	t.Setenv("API_V1_DEPRECATED_AT", "")
+	if !hasProblem(problems(t, Load()), "API_V1_SUNSET requires") {
+		t.Fatal("a sunset without a deprecation must be rejected")
+	}
+
+	t.Setenv("API_V1_DEPRECATED_AT", "next tuesday")
+	if !hasProblem(problems(t, Load()), `API_V1_DEPRECATED_AT="next tuesday"`) {
+		t.Fatal("an unparsable timestamp must be reported")
+	}
+}
+
+func TestGRPCSettingsAreValidated(t *testing.T) {
+	if cfg := Load(); cfg.GRPCEnabled() {
+		t.Fatal("gRPC must be off unless GRPC_PORT is set")
+	}
+
+	t.Setenv("GRPC_PORT", "9090")
+	if got := problems(t, Load()); hasProblem(got, "GRPC") {
+		t.Fatalf("problems = %v", got)
+	}
+
+	t.Setenv("GRPC_PORT", Load().Port)
+	if !hasProblem(problems(t, Load()), "GRPC_PORT must differ") {
+		t.Fatal("a gRPC port shared with the API must be rejected")
+	}
+
+	t.Setenv("GRPC_PORT", "9090")
+	t.Setenv("GRPC_WATCH_INTERVAL", "0s")
+	if !hasProblem(problems(t, Load()), "GRPC_WATCH_INTERVAL") {
+		t.Fatal("a zero watch interval must be rejected")
+	}
+}
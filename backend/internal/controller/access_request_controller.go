@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/service"
+	"pmv2/backend/internal/util"
+)
+
+type AccessRequestController struct {
+	requests *service.AccessRequestService
+	log      *slog.Logger
+}
+
+func NewAccessRequestController(accessRequestService *service.AccessRequestService, logger *slog.Logger) *AccessRequestController {
+	return &AccessRequestController{requests: accessRequestService, log: logger}
+}
+
+// HandleRequestAccess asks the approvers of an item for a share of it.
+func (c *AccessRequestController) HandleRequestAccess(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var req dto.AccessRequestRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+
+	created, err := c.requests.RequestAccess(r.Context(), session.UserID, r.PathValue("item_id"), req.Reason)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to request access", slog.String("user_id", session.UserID))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, accessRequestToResponse(created))
+}
+
+// HandleListPending lists the requests waiting for the user's decision.
+func (c *AccessRequestController) HandleListPending(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	requests, err := c.requests.ListPendingForApprover(r.Context(), session.UserID)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to list access requests", slog.String("user_id", session.UserID))
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, accessRequestsToResponse(requests))
+}
+
+// HandleListMine lists the requests the user made.
+func (c *AccessRequestController) HandleListMine(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	requests, err := c.requests.ListMyRequests(r.Context(), session.UserID)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to list access requests", slog.String("user_id", session.UserID))
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, accessRequestsToResponse(requests))
+}
+
+// HandleApprove grants a request with the item key the approver's client
+// wrapped for the requester.
+func (c *AccessRequestController) HandleApprove(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var req dto.ApproveAccessRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+
+	var fields util.FieldErrors
+	decode := func(name, value string) []byte {
+		raw, err := decodeBase64Required(value)
+		switch {
+		case errors.Is(err, errEmptyBase64):
+			fields.Add(name, util.FieldRequired, name+" is required")
+		case err != nil:
+			fields.Add(name, util.FieldInvalid, name+" must be non-empty standard base64")
+		}
+		return raw
+	}
+	wrappedDEK := decode("wrapped_dek", req.WrappedDEK)
+	wrapNonce := decode("wrap_nonce", req.WrapNonce)
+	permissions := strings.TrimSpace(req.Permissions)
+	switch permissions {
+	case "", domain.SharePermissionRead, domain.SharePermissionManage:
+	default:
+		fields.Add("permissions", util.FieldInvalid, "permissions must be read or manage")
+	}
+	window, windowFields := parseShareWindow(req.ShareWindow)
+	fields = append(fields, windowFields...)
+	if len(fields) > 0 {
+		util.WriteFieldErrors(w, fields)
+		return
+	}
+
+	approved, err := c.requests.Approve(r.Context(), session.UserID, r.PathValue("request_id"), service.ApproveAccessInput{
+		DEKWrapped:  wrappedDEK,
+		WrapNonce:   wrapNonce,
+		Permissions: permissions,
+		Window:      window,
+	})
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to approve access request", slog.String("user_id", session.UserID))
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, accessRequestToResponse(approved))
+}
+
+func (c *AccessRequestController) HandleDeny(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	denied, err := c.requests.Deny(r.Context(), session.UserID, r.PathValue("request_id"))
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to deny access request", slog.String("user_id", session.UserID))
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, accessRequestToResponse(denied))
+}
+
+func accessRequestsToResponse(requests []domain.AccessRequest) dto.AccessRequestsResponse {
+	resp := dto.AccessRequestsResponse{Requests: make([]dto.AccessRequestResponse, 0, len(requests))}
+	for _, req := range requests {
+		resp.Requests = append(resp.Requests, accessRequestToResponse(req))
+	}
+	return resp
+}
+
+func accessRequestToResponse(req domain.AccessRequest) dto.AccessRequestResponse {
+	resp := dto.AccessRequestResponse{
+		ID:              req.ID,
+		ItemID:          req.ItemID,
+		OwnerUserID:     req.OwnerUserID,
+		RequesterUserID: req.RequesterUserID,
+		RequesterEmail:  req.RequesterEmail,
+		RequesterName:   req.RequesterName,
+		Reason:          req.Reason,
+		Status:          string(req.Status),
+		DecidedByUserID: req.DecidedByUserID,
+		CreatedAt:       req.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if req.DecidedAt != nil {
+		resp.DecidedAt = req.DecidedAt.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
@@ -0,0 +1,306 @@
+package controller
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/service"
+	"pmv2/backend/internal/util"
+)
+
+const (
+	maxFeatureFlagDescription = 200
+	maxMaintenanceMessage     = 500
+)
+
+// AdminController serves /admin/api/v1. Routes must be wrapped in
+// AuthMiddleware.WithAdminSession.
+type AdminController struct {
+	admin *service.AdminService
+	log   *slog.Logger
+}
+
+func NewAdminController(adminService *service.AdminService, logger *slog.Logger) *AdminController {
+	return &AdminController{admin: adminService, log: logger}
+}
+
+// HandleGetStats reports instance usage for dashboards. Results are cached
+// by the service; the ETag changes only when they are recomputed, so
+// pollers can revalidate cheaply.
+func (c *AdminController) HandleGetStats(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	stats, err := c.admin.Stats(r.Context())
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to get instance stats")
+		return
+	}
+	if util.CheckETag(w, r, "stats-"+strconv.FormatInt(stats.GeneratedAt.UnixNano(), 36)) {
+		return
+	}
+
+	resp := dto.InstanceStatsResponse{
+		Users: dto.UserStatsResponse{
+			Total:       stats.Users,
+			Admins:      stats.Admins,
+			TOTPEnabled: stats.TOTPUsers,
+			New30d:      stats.NewUsers30d,
+		},
+		Sessions: dto.SessionStatsResponse{Active: stats.ActiveSessions},
+		Vault: dto.VaultStatsResponse{
+			Items:   stats.VaultItems,
+			Trashed: stats.TrashedItems,
+			Folders: stats.Folders,
+			Shares:  stats.Shares,
+		},
+		Storage: dto.StorageStatsResponse{
+			VaultBytes:      stats.Storage.VaultBytes,
+			HistoryBytes:    stats.Storage.HistoryBytes,
+			AttachmentBytes: stats.Storage.AttachmentBytes,
+			DatabaseBytes:   stats.Storage.DatabaseBytes,
+		},
+		ActiveUsers: dto.ActiveUsersResponse{
+			Day:   stats.ActiveUsers1d,
+			Week:  stats.ActiveUsers7d,
+			Month: stats.ActiveUsers30d,
+		},
+		DailyActive: make([]dto.DailyActiveResponse, 0, len(stats.DailyActive)),
+		GeneratedAt: stats.GeneratedAt.Format(time.RFC3339),
+	}
+	for _, day := range stats.DailyActive {
+		resp.DailyActive = append(resp.DailyActive, dto.DailyActiveResponse{Date: day.Date.Format(time.DateOnly), Users: day.Users})
+	}
+	util.WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleSearchUsers lists accounts whose email starts with ?email.
+func (c *AdminController) HandleSearchUsers(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	users, err := c.admin.SearchUsers(r.Context(), session.UserID, r.URL.Query().Get("email"), limit)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to search users")
+		return
+	}
+
+	resp := dto.AdminUsersResponse{Users: make([]dto.AdminUserResponse, 0, len(users))}
+	for _, u := range users {
+		resp.Users = append(resp.Users, adminUserToResponse(u))
+	}
+	util.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (c *AdminController) HandleGetUser(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	user, err := c.admin.GetUser(r.Context(), strings.TrimSpace(r.PathValue("user_id")))
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to get user")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, adminUserToResponse(user))
+}
+
+func (c *AdminController) HandleListSessions(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	sessions, err := c.admin.ListSessions(r.Context(), strings.TrimSpace(r.PathValue("user_id")))
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to list sessions")
+		return
+	}
+
+	resp := dto.AdminSessionsResponse{Sessions: make([]dto.AdminSessionResponse, 0, len(sessions))}
+	for _, s := range sessions {
+		resp.Sessions = append(resp.Sessions, dto.AdminSessionResponse{
+			ID:         s.ID,
+			DeviceName: s.DeviceName,
+			IPAddress:  s.IPAddress,
+			UserAgent:  s.UserAgent,
+			CreatedAt:  s.CreatedAt.Format(time.RFC3339),
+			ExpiresAt:  s.ExpiresAt.Format(time.RFC3339),
+		})
+	}
+	util.WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleRevokeSessions signs the user out everywhere, or of the session
+// named in the path.
+func (c *AdminController) HandleRevokeSessions(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	revoked, err := c.admin.RevokeSessions(r.Context(), strings.TrimSpace(r.PathValue("user_id")), strings.TrimSpace(r.PathValue("session_id")))
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to revoke sessions")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, dto.RevokeSessionsResponse{Revoked: revoked})
+}
+
+func (c *AdminController) HandleClearLockout(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	if err := c.admin.ClearLockout(r.Context(), strings.TrimSpace(r.PathValue("user_id"))); err != nil {
+		writeServiceError(w, r, c.log, err, "failed to clear lockout")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, dto.StatusResponse{Status: "lockout_cleared"})
+}
+
+// HandleFlagCredentialsCompromised signs the user out everywhere and
+// restricts their next sessions to changing the master password.
+func (c *AdminController) HandleFlagCredentialsCompromised(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	revoked, err := c.admin.FlagCredentialsCompromised(r.Context(), strings.TrimSpace(r.PathValue("user_id")))
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to flag credentials compromised")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, dto.RevokeSessionsResponse{Revoked: revoked})
+}
+
+func (c *AdminController) HandleListFeatureFlags(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	flags, err := c.admin.ListFeatureFlags(r.Context())
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to list feature flags")
+		return
+	}
+
+	resp := dto.FeatureFlagsResponse{Flags: make([]dto.FeatureFlagResponse, 0, len(flags))}
+	for _, f := range flags {
+		resp.Flags = append(resp.Flags, featureFlagToResponse(f))
+	}
+	util.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (c *AdminController) HandleSetFeatureFlag(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var req dto.SetFeatureFlagRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+	var fields util.FieldErrors
+	if req.Enabled == nil {
+		fields.Add("enabled", util.FieldRequired, "enabled is required")
+	}
+	if utf8.RuneCountInString(req.Description) > maxFeatureFlagDescription {
+		fields.Add("description", util.FieldInvalid, "description must be at most 200 characters")
+	}
+	if len(fields) > 0 {
+		util.WriteFieldErrors(w, fields)
+		return
+	}
+
+	flag, err := c.admin.SetFeatureFlag(r.Context(), session.UserID, r.PathValue("key"), *req.Enabled, req.Description)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to set feature flag")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, featureFlagToResponse(flag))
+}
+
+func (c *AdminController) HandleGetMaintenance(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	util.WriteJSON(w, http.StatusOK, maintenanceToResponse(c.admin.Maintenance()))
+}
+
+func (c *AdminController) HandleSetMaintenance(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var req dto.SetMaintenanceRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+	var fields util.FieldErrors
+	if req.Enabled == nil {
+		fields.Add("enabled", util.FieldRequired, "enabled is required")
+	}
+	if utf8.RuneCountInString(req.Message) > maxMaintenanceMessage {
+		fields.Add("message", util.FieldInvalid, "message must be at most 500 characters")
+	}
+	if len(fields) > 0 {
+		util.WriteFieldErrors(w, fields)
+		return
+	}
+
+	state, err := c.admin.SetMaintenance(r.Context(), session.UserID, *req.Enabled, req.Message)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to set maintenance mode")
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, maintenanceToResponse(state))
+}
+
+// HandleEraseUser deletes the user and all their data for good and returns
+// the verification report.
+func (c *AdminController) HandleEraseUser(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	report, err := c.admin.EraseUser(r.Context(), session.UserID, strings.TrimSpace(r.PathValue("user_id")))
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to erase user")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, erasureReportToResponse(report))
+}
+
+// HandleErasureReport rechecks an erased user. The optional email query
+// parameter also matches rows that mention their address.
+func (c *AdminController) HandleErasureReport(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	report, err := c.admin.ErasureReport(r.Context(), strings.TrimSpace(r.PathValue("user_id")), r.URL.Query().Get("email"))
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to check user data")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, erasureReportToResponse(report))
+}
+
+func adminUserToResponse(u domain.AdminUser) dto.AdminUserResponse {
+	resp := dto.AdminUserResponse{
+		ID:             u.ID,
+		Email:          u.Email,
+		Name:           u.Name,
+		Role:           string(u.Role),
+		EmailVerified:  u.EmailVerified,
+		TOTPEnabled:    u.TOTPEnabled,
+		ActiveSessions: u.ActiveSessions,
+		VaultItems:     u.VaultItems,
+		CreatedAt:      u.CreatedAt.Format(time.RFC3339),
+	}
+	if u.TOTPLockedUntil != nil && u.TOTPLockedUntil.After(time.Now()) {
+		resp.TOTPLockedUntil = u.TOTPLockedUntil.Format(time.RFC3339)
+	}
+	if u.LastLoginAt != nil {
+		resp.LastLoginAt = u.LastLoginAt.Format(time.RFC3339)
+	}
+	return resp
+}
+
+func featureFlagToResponse(f domain.FeatureFlag) dto.FeatureFlagResponse {
+	resp := dto.FeatureFlagResponse{
+		Key:         f.Key,
+		Enabled:     f.Enabled,
+		Description: f.Description,
+		UpdatedAt:   f.UpdatedAt.Format(time.RFC3339),
+	}
+	if f.UpdatedBy != nil {
+		resp.UpdatedBy = *f.UpdatedBy
+	}
+	return resp
+}
+
+func maintenanceToResponse(state domain.MaintenanceState) dto.MaintenanceResponse {
+	resp := dto.MaintenanceResponse{Enabled: state.Enabled, Message: state.Message, Forced: state.Forced}
+	if state.Since != nil {
+		resp.Since = state.Since.Format(time.RFC3339)
+	}
+	return resp
+}
+
+func erasureReportToResponse(r domain.ErasureReport) dto.ErasureReportResponse {
+	return dto.ErasureReportResponse{
+		UserID:       r.UserID,
+		Clean:        r.Clean(),
+		Remaining:    r.Remaining,
+		BlobsDeleted: r.BlobsDeleted,
+		BlobsFailed:  r.BlobsFailed,
+		CheckedAt:    r.CheckedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/service"
+	"pmv2/backend/internal/util"
+)
+
+type AttachmentController struct {
+	attachments *service.AttachmentService
+	log         *slog.Logger
+}
+
+func NewAttachmentController(attachmentService *service.AttachmentService, logger *slog.Logger) *AttachmentController {
+	return &AttachmentController{attachments: attachmentService, log: logger}
+}
+
+// HandleUploadAttachment stores the request body, the encrypted file, as an
+// attachment of the item. The encrypted file name and its nonce come in the
+// name and nonce query parameters, base64 encoded, so the body can be
+// streamed to the blob store as it arrives.
+func (c *AttachmentController) HandleUploadAttachment(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var fields util.FieldErrors
+	decode := func(name string) []byte {
+		raw, err := decodeBase64Required(r.URL.Query().Get(name))
+		switch {
+		case errors.Is(err, errEmptyBase64):
+			fields.Add(name, util.FieldRequired, name+" is required")
+		case err != nil:
+			fields.Add(name, util.FieldInvalid, name+" must be non-empty standard base64")
+		}
+		return raw
+	}
+	name := decode("name")
+	nonce := decode("nonce")
+	if len(fields) > 0 {
+		util.WriteFieldErrors(w, fields)
+		return
+	}
+	if r.ContentLength > c.attachments.MaxBytes() {
+		writeAttachmentTooLarge(w)
+		return
+	}
+
+	attachment, err := c.attachments.Upload(r.Context(), session.UserID, r.PathValue("item_id"), name, nonce, r.Body)
+	if err != nil {
+		if errors.Is(err, domain.ErrAttachmentTooLarge) {
+			writeAttachmentTooLarge(w)
+			return
+		}
+		writeServiceError(w, r, c.log, err, "failed to upload attachment", slog.String("user_id", session.UserID))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, attachmentToResponse(attachment))
+}
+
+func (c *AttachmentController) HandleListAttachments(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	attachments, err := c.attachments.List(r.Context(), session.UserID, r.PathValue("item_id"))
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to list attachments", slog.String("user_id", session.UserID))
+		return
+	}
+
+	resp := dto.VaultAttachmentsResponse{
+		Attachments:  make([]dto.VaultAttachmentResponse, 0, len(attachments)),
+		MaxSizeBytes: c.attachments.MaxBytes(),
+	}
+	for _, a := range attachments {
+		resp.Attachments = append(resp.Attachments, attachmentToResponse(a))
+	}
+	util.WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleDownloadAttachment streams the encrypted file. The client decrypts
+// it, and its name, with the item's data key.
+func (c *AttachmentController) HandleDownloadAttachment(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	attachment, body, err := c.attachments.Open(r.Context(), session.UserID, r.PathValue("item_id"), r.PathValue("attachment_id"))
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to download attachment", slog.String("user_id", session.UserID))
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+attachment.ID+`"`)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.SizeBytes, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, body); err != nil {
+		c.log.WarnContext(r.Context(), "attachment download interrupted", slog.String("attachment_id", attachment.ID), slog.Any("error", err))
+	}
+}
+
+// HandleAttachmentDownloadURL answers with a short-lived signed URL for
+// downloading the encrypted file straight from the blob store, sparing the
+// API the transfer. Servers whose store cannot sign answer 404
+// signed_url_unavailable, and clients fall back to HandleDownloadAttachment.
+func (c *AttachmentController) HandleAttachmentDownloadURL(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	url, expiresAt, err := c.attachments.DownloadURL(r.Context(), session.UserID, r.PathValue("item_id"), r.PathValue("attachment_id"))
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to sign attachment download url", slog.String("user_id", session.UserID))
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	util.WriteJSON(w, http.StatusOK, dto.AttachmentDownloadURLResponse{URL: url, ExpiresAt: expiresAt.Format(time.RFC3339)})
+}
+
+func (c *AttachmentController) HandleDeleteAttachment(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	if err := c.attachments.Delete(r.Context(), session.UserID, r.PathValue("item_id"), r.PathValue("attachment_id")); err != nil {
+		writeServiceError(w, r, c.log, err, "failed to delete attachment", slog.String("user_id", session.UserID))
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, dto.StatusResponse{Status: "deleted"})
+}
+
+// writeAttachmentTooLarge answers with 413 rather than the 400 the
+// catalog's KindInvalid maps to.
+func writeAttachmentTooLarge(w http.ResponseWriter) {
+	err := domain.ErrAttachmentTooLarge
+	util.WriteError(w, http.StatusRequestEntityTooLarge, err.Code, err.Message)
+}
+
+func attachmentToResponse(a domain.VaultAttachment) dto.VaultAttachmentResponse {
+	return dto.VaultAttachmentResponse{
+		ID:                 a.ID,
+		ItemID:             a.ItemID,
+		FileNameCiphertext: encodeBase64(a.FileNameCiphertext),
+		Nonce:              encodeBase64(a.Nonce),
+		SizeBytes:          a.SizeBytes,
+		CreatedAt:          a.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
@@ -1,15 +1,22 @@
 package controller
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"pmv2/backend/internal/domain"
 	"pmv2/backend/internal/dto"
 	"pmv2/backend/internal/service"
 	"pmv2/backend/internal/util"
+
+	"github.com/google/uuid"
 )
 
 type AuditController struct {
@@ -21,63 +28,228 @@ func NewAuditController(auditService *service.AuditService, logger *slog.Logger)
 	return &AuditController{audit: auditService, log: logger}
 }
 
+// HandleGetLogs lists the caller's own activity. With ?format=csv or
+// ?format=json it downloads every matching event instead of one page.
 func (c *AuditController) HandleGetLogs(w http.ResponseWriter, r *http.Request, session domain.Session) {
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
-	query := r.URL.Query().Get("query")
-	category := r.URL.Query().Get("category")
-	startDateStr := r.URL.Query().Get("start_date")
-	endDateStr := r.URL.Query().Get("end_date")
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, "invalid_filter", err.Error())
+		return
+	}
+	uid, err := uuid.Parse(session.UserID)
+	if err != nil {
+		util.WriteError(w, http.StatusUnauthorized, "unauthorized", "invalid session")
+		return
+	}
+	filter.UserID = &uid
+
+	if format := r.URL.Query().Get("format"); format != "" {
+		c.writeExport(w, r, format, filter)
+		return
+	}
 
-	limit, _ := strconv.Atoi(limitStr)
-	offset, _ := strconv.Atoi(offsetStr)
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	res, err := c.audit.GetActivityLog(r.Context(), session.UserID, limit, offset, filter)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to retrieve activity logs")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, auditPageToResponse(res))
+}
+
+// HandleAdminGetLogs queries events across all users. ?user_id narrows the
+// result to one account. Every query is itself audited.
+func (c *AuditController) HandleAdminGetLogs(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, "invalid_filter", err.Error())
+		return
+	}
+	if raw := strings.TrimSpace(r.URL.Query().Get("user_id")); raw != "" {
+		uid, err := uuid.Parse(raw)
+		if err != nil {
+			util.WriteError(w, http.StatusBadRequest, "invalid_filter", "user_id must be a UUID")
+			return
+		}
+		filter.UserID = &uid
+	}
+
+	adminID, _ := uuid.Parse(session.UserID)
+	c.audit.LogEvent(r.Context(), &adminID, domain.EventTypeAdminAuditQueried, filter)
+
+	if format := r.URL.Query().Get("format"); format != "" {
+		c.writeExport(w, r, format, filter)
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	res, err := c.audit.QueryEvents(r.Context(), limit, offset, filter)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to retrieve audit logs")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, auditPageToResponse(res))
+}
+
+// writeExport streams every matching event. Headers are sent with the first
+// event, so a query that fails up front still gets a JSON error; a failure
+// mid-stream aborts the connection rather than end a truncated file cleanly.
+func (c *AuditController) writeExport(w http.ResponseWriter, r *http.Request, format string, filter domain.AuditFilter) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format != "csv" && format != "json" {
+		util.WriteError(w, http.StatusBadRequest, "invalid_format", "format must be csv or json")
+		return
+	}
 
+	var (
+		started bool
+		count   int
+		cw      = csv.NewWriter(w)
+	)
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+		filename := "audit-" + time.Now().UTC().Format("20060102-150405") + "." + format
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		if format == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, err := io.WriteString(w, "[")
+			return err
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		return cw.Write([]string{"id", "created_at", "event_type", "user_id", "actor_user_id", "ip_address", "user_agent", "event_data"})
+	}
+
+	err := c.audit.ExportEvents(r.Context(), filter, func(e domain.AuditEvent) error {
+		if err := start(); err != nil {
+			return err
+		}
+		count++
+		if format == "json" {
+			b, err := json.Marshal(auditEventToResponse(e))
+			if err != nil {
+				return err
+			}
+			if count > 1 {
+				b = append([]byte(","), b...)
+			}
+			_, err = w.Write(b)
+			return err
+		}
+		return cw.Write([]string{
+			e.ID.String(),
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			string(e.EventType),
+			uuidOrEmpty(e.UserID),
+			uuidOrEmpty(e.ActorUserID),
+			util.CSVSafe(e.IPAddress),
+			util.CSVSafe(e.UserAgent),
+			util.CSVSafe(string(e.EventData)),
+		})
+	})
+	if err != nil {
+		c.log.ErrorContext(r.Context(), "failed to export audit logs", slog.Any("error", err), slog.Int("written", count))
+		if !started {
+			util.WriteError(w, http.StatusInternalServerError, "internal_error", "failed to export activity logs")
+			return
+		}
+		panic(http.ErrAbortHandler)
+	}
+
+	if err := start(); err != nil {
+		return
+	}
+	if format == "json" {
+		_, _ = io.WriteString(w, "]\n")
+		return
+	}
+	cw.Flush()
+}
+
+// parseAuditFilter reads the shared query filters. Unparseable dates are
+// ignored, as they always have been; an invalid IP is rejected.
+func parseAuditFilter(r *http.Request) (domain.AuditFilter, error) {
+	q := r.URL.Query()
 	filter := domain.AuditFilter{
-		Search:   query,
-		Category: category,
+		Search:   q.Get("query"),
+		Category: q.Get("category"),
 	}
 
-	if startDateStr != "" {
+	if startDateStr := q.Get("start_date"); startDateStr != "" {
 		t, err := time.Parse(time.RFC3339, startDateStr)
 		if err == nil {
 			filter.StartDate = &t
 		}
 	}
-	if endDateStr != "" {
+	if endDateStr := q.Get("end_date"); endDateStr != "" {
 		t, err := time.Parse(time.RFC3339, endDateStr)
 		if err == nil {
 			filter.EndDate = &t
 		}
 	}
 
-	res, err := c.audit.GetActivityLog(r.Context(), session.UserID, limit, offset, filter)
-	if err != nil {
-		c.log.ErrorContext(r.Context(), "failed to get audit logs", slog.Any("error", err))
-		util.WriteError(w, http.StatusInternalServerError, "internal_error", "failed to retrieve activity logs")
-		return
+	for _, raw := range strings.Split(q.Get("event_type"), ",") {
+		if t := strings.TrimSpace(raw); t != "" {
+			filter.EventTypes = append(filter.EventTypes, domain.EventType(t))
+		}
 	}
 
+	if ip := strings.TrimSpace(q.Get("ip")); ip != "" {
+		filter.IPAddress = util.NormalizeIP(ip)
+		if filter.IPAddress == "" {
+			return domain.AuditFilter{}, errors.New("ip must be a valid IP address")
+		}
+	}
+
+	return filter, nil
+}
+
+func auditPageToResponse(res *domain.AuditPaginatedResponse) dto.AuditPaginatedResponse {
 	dtoEvents := make([]dto.AuditEventResponse, 0, len(res.Events))
 	for _, e := range res.Events {
-		dtoEvents = append(dtoEvents, dto.AuditEventResponse{
-			ID:        e.ID,
-			EventType: string(e.EventType),
-			EventData: e.EventData,
-			CreatedAt: e.CreatedAt,
-		})
+		dtoEvents = append(dtoEvents, auditEventToResponse(e))
 	}
 
-	util.WriteJSON(w, http.StatusOK, dto.AuditPaginatedResponse{
+	return dto.AuditPaginatedResponse{
 		Events:  dtoEvents,
 		Total:   res.Total,
 		HasNext: res.HasNext,
-	})
+	}
+}
+
+func auditEventToResponse(e domain.AuditEvent) dto.AuditEventResponse {
+	return dto.AuditEventResponse{
+		ID:          e.ID,
+		UserID:      e.UserID,
+		ActorUserID: e.ActorUserID,
+		EventType:   string(e.EventType),
+		EventData:   e.EventData,
+		IPAddress:   e.IPAddress,
+		UserAgent:   e.UserAgent,
+		CreatedAt:   e.CreatedAt,
+	}
+}
+
+func uuidOrEmpty(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
 }
 
 func (c *AuditController) HandleClearLogs(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	if err := c.audit.ClearActivityLog(r.Context(), session.UserID); err != nil {
-		c.log.ErrorContext(r.Context(), "failed to clear audit logs", slog.Any("error", err))
-		util.WriteError(w, http.StatusInternalServerError, "internal_error", "failed to clear activity logs")
+		writeServiceError(w, r, c.log, err, "failed to clear activity logs")
 		return
 	}
 
@@ -86,8 +258,7 @@ func (c *AuditController) HandleClearLogs(w http.ResponseWriter, r *http.Request
 func (c *AuditController) HandleGetSummary(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	status, err := c.audit.GetSecuritySummary(r.Context(), session.UserID)
 	if err != nil {
-		c.log.ErrorContext(r.Context(), "failed to get security summary", slog.Any("error", err))
-		util.WriteError(w, http.StatusInternalServerError, "internal_error", "failed to retrieve security summary")
+		writeServiceError(w, r, c.log, err, "failed to retrieve security summary")
 		return
 	}
 
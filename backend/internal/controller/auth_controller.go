@@ -2,6 +2,7 @@ package controller
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strings"
@@ -9,6 +10,7 @@ import (
 
 	"pmv2/backend/internal/domain"
 	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/i18n"
 	"pmv2/backend/internal/service"
 	"pmv2/backend/internal/util"
 )
@@ -42,23 +44,22 @@ func NewAuthController(authService *service.AuthService, cookieConfig AuthCookie
 func (c *AuthController) HandleRegister(w http.ResponseWriter, r *http.Request) {
 	var req dto.RegisterRequest
 	if err := util.ReadJSON(r, &req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+		util.WriteDecodeError(w, err)
+		return
+	}
+	if fields := validateRegisterRequest(req); len(fields) > 0 {
+		util.WriteFieldErrors(w, fields)
 		return
 	}
 
 	resp, err := c.auth.Register(r.Context(), req.Email, req.Password, req.Name)
 	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrEmailTaken):
-			util.WriteError(w, http.StatusConflict, "email_taken", "email already registered")
-		case errors.Is(err, domain.ErrInvalidCredentials):
+		// Registration rejects malformed input, not a sign-in attempt.
+		if errors.Is(err, domain.ErrInvalidCredentials) {
 			util.WriteError(w, http.StatusBadRequest, "invalid_credentials", "email or password does not meet policy")
-		case errors.Is(err, domain.ErrWeakPassword):
-			util.WriteError(w, http.StatusBadRequest, "weak_password", "password does not meet complexity requirements")
-		default:
-			c.log.ErrorContext(r.Context(), "register failed", slog.Any("error", err))
-			util.WriteError(w, http.StatusInternalServerError, "internal_error", "registration failed")
+			return
 		}
+		writeServiceError(w, r, c.log, err, "registration failed")
 		return
 	}
 
@@ -73,53 +74,67 @@ func (c *AuthController) HandleRegister(w http.ResponseWriter, r *http.Request)
 func (c *AuthController) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	var req dto.LoginRequest
 	if err := util.ReadJSON(r, &req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+		util.WriteDecodeError(w, err)
+		return
+	}
+	fields := validateLoginRequest(req)
+	var assertion *domain.PasskeyAssertion
+	if req.PasskeyAssertion != nil {
+		a := passkeyAssertion(&fields, "passkey_assertion.", *req.PasskeyAssertion)
+		assertion = &a
+	}
+	if len(fields) > 0 {
+		util.WriteFieldErrors(w, fields)
 		return
 	}
 
 	output, err := c.auth.Login(r.Context(), domain.LoginInput{
-		Email:        req.Email,
-		Password:     req.Password,
-		TOTPCode:     req.TOTPCode,
-		RecoveryCode: req.RecoveryCode,
-		DeviceName:   req.DeviceName,
-		IPAddr:       util.ClientIPFromRequest(r),
-		UserAgent:    r.UserAgent(),
+		Email:             req.Email,
+		Password:          req.Password,
+		TOTPCode:          req.TOTPCode,
+		RecoveryCode:      req.RecoveryCode,
+		DeviceName:        req.DeviceName,
+		DeviceFingerprint: req.DeviceFingerprint,
+		IPAddr:            util.ClientIPFromRequest(r),
+		UserAgent:         r.UserAgent(),
+		PasskeyAssertion:  assertion,
 	})
 	if err != nil {
 		switch {
 		case errors.Is(err, domain.ErrMFARequired):
-			util.WriteJSON(w, http.StatusUnauthorized, dto.MFARequiredResponse{
-				Error:       "mfa_required",
-				Message:     "totp code is required for this account",
-				MFARequired: true,
-			})
-		case errors.Is(err, domain.ErrInvalidMFA):
-			util.WriteError(w, http.StatusUnauthorized, "invalid_mfa", "invalid totp or recovery code")
+			writeMFARequired(w, domain.ErrMFARequired.Message)
 		case errors.Is(err, domain.ErrInvalidMFAInput):
-			util.WriteError(w, http.StatusBadRequest, "invalid_mfa_input", "provide either totp_code or recovery_code, not both")
-		case errors.Is(err, domain.ErrMFARateLimited):
-			util.WriteError(w, http.StatusTooManyRequests, "mfa_rate_limited", "too many invalid mfa attempts, try again later")
-		case errors.Is(err, domain.ErrInvalidCredentials):
-			util.WriteError(w, http.StatusUnauthorized, "invalid_credentials", "invalid email or password")
-		case errors.Is(err, domain.ErrWeakPassword):
-			util.WriteError(w, http.StatusUnauthorized, "weak_password", "password does not meet complexity requirements")
+			var fields util.FieldErrors
+			fields.Add("recovery_code", util.FieldConflict, domain.ErrInvalidMFAInput.Message)
+			util.WriteValidationError(w, domain.ErrInvalidMFAInput.Code, domain.ErrInvalidMFAInput.Message, fields)
 		default:
-			c.log.ErrorContext(r.Context(), "login failed", slog.Any("error", err))
-			util.WriteError(w, http.StatusInternalServerError, "internal_error", "login failed")
+			writeServiceError(w, r, c.log, err, "login failed")
 		}
 		return
 	}
 
-	c.setSessionCookie(w, output.SessionToken, output.ExpiresAt)
+	c.setLoginCookies(w, output)
 
-	util.WriteJSON(w, http.StatusOK, dto.LoginResponse{
-		ExpiresAt:   output.ExpiresAt.UTC().Format(time.RFC3339),
-		UserID:      output.UserID,
-		Email:       output.Email,
-		Name:        output.Name,
-		TOTPEnabled: output.TOTPEnabled,
-	})
+	util.WriteJSON(w, http.StatusOK, loginResponse(output))
+}
+
+func validateRegisterRequest(req dto.RegisterRequest) util.FieldErrors {
+	var fields util.FieldErrors
+	fields.Email("email", req.Email)
+	if fields.Required("password", req.Password) && util.ValidatePasswordStrength(req.Password, req.Email, req.Name) != nil {
+		fields.Add("password", util.FieldWeakPassword, "password must be at least 8 characters, include upper and lower case letters, a number and a symbol, and be neither a common password nor contain your email or name")
+	}
+	return fields
+}
+
+// validateLoginRequest only checks presence: the address format is not
+// checked so a typo still reads as invalid_credentials.
+func validateLoginRequest(req dto.LoginRequest) util.FieldErrors {
+	var fields util.FieldErrors
+	fields.Required("email", req.Email)
+	fields.Required("password", req.Password)
+	validateDeviceFingerprint(&fields, req.DeviceFingerprint)
+	return fields
 }
 
 func (c *AuthController) HandleLogout(w http.ResponseWriter, r *http.Request, _ domain.Session) {
@@ -134,23 +149,137 @@ func (c *AuthController) HandleLogout(w http.ResponseWriter, r *http.Request, _
 	}
 
 	c.clearSessionCookie(w)
+	c.clearCookie(w, util.AccessTokenCookie(c.sessionCookieName))
 	util.WriteJSON(w, http.StatusOK, dto.LogoutResponse{Status: "logged_out"})
 }
 
+// HandleRefreshSession swaps the caller's session token for a fresh one. It
+// sits outside the session middleware: a replayed token has to reach the
+// service to be recognised as one.
+func (c *AuthController) HandleRefreshSession(w http.ResponseWriter, r *http.Request) {
+	output, err := c.auth.RefreshSession(r.Context(), c.sessionTokenFromRequest(r))
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "refresh session failed")
+		return
+	}
+
+	c.setLoginCookies(w, output)
+	util.WriteJSON(w, http.StatusOK, loginResponse(output))
+}
+
+// HandleListDevices lists the devices the user has signed in from, marking
+// the one behind the current session.
+func (c *AuthController) HandleListDevices(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	devices, err := c.auth.ListDevices(r.Context(), session.UserID)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to list devices")
+		return
+	}
+
+	resp := dto.DevicesResponse{Devices: make([]dto.DeviceResponse, 0, len(devices))}
+	for _, d := range devices {
+		resp.Devices = append(resp.Devices, dto.DeviceResponse{
+			ID:            d.ID,
+			Label:         d.Label,
+			Fingerprinted: d.Fingerprinted,
+			Current:       d.ID == session.DeviceID,
+			FirstSeenAt:   d.FirstSeenAt.UTC().Format(time.RFC3339),
+			LastSeenAt:    d.LastSeenAt.UTC().Format(time.RFC3339),
+		})
+	}
+	util.WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleRemoveDevice forgets a device; its next sign-in counts as new.
+func (c *AuthController) HandleRemoveDevice(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	if err := c.auth.RemoveDevice(r.Context(), session.UserID, r.PathValue("device_id")); err != nil {
+		writeServiceError(w, r, c.log, err, "failed to remove device")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, dto.StatusResponse{Status: "removed"})
+}
+
+// HandleListSessions lists the user's active sessions, marking the one
+// making the request.
+func (c *AuthController) HandleListSessions(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	sessions, err := c.auth.ListSessions(r.Context(), session.UserID)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to list sessions")
+		return
+	}
+
+	resp := dto.UserSessionsResponse{Sessions: make([]dto.UserSessionResponse, 0, len(sessions))}
+	for _, s := range sessions {
+		resp.Sessions = append(resp.Sessions, dto.UserSessionResponse{
+			ID:         s.ID,
+			DeviceName: s.DeviceName,
+			IPAddress:  s.IPAddress,
+			UserAgent:  s.UserAgent,
+			Current:    s.ID == session.ID,
+			CreatedAt:  s.CreatedAt.UTC().Format(time.RFC3339),
+			LastUsedAt: formatOptionalTime(s.LastUsedAt),
+			ExpiresAt:  s.ExpiresAt.UTC().Format(time.RFC3339),
+		})
+	}
+	util.WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleRevokeSession signs out the session named in the path.
+func (c *AuthController) HandleRevokeSession(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	if err := c.auth.RevokeSession(r.Context(), session.UserID, r.PathValue("session_id")); err != nil {
+		writeServiceError(w, r, c.log, err, "failed to revoke session")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, dto.StatusResponse{Status: "revoked"})
+}
+
+// HandleRevokeOtherSessions signs out every session but the caller's.
+func (c *AuthController) HandleRevokeOtherSessions(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	revoked, err := c.auth.RevokeOtherSessions(r.Context(), session)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to revoke sessions")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, dto.RevokeSessionsResponse{Revoked: revoked})
+}
+
 func (c *AuthController) HandleMe(w http.ResponseWriter, _ *http.Request, session domain.Session) {
 	util.WriteJSON(w, http.StatusOK, dto.SessionResponse{
-		ExpiresAt:   session.ExpiresAt.UTC().Format(time.RFC3339),
-		UserID:      session.UserID,
-		Email:       session.Email,
-		Name:        session.Name,
-		TOTPEnabled: session.TOTPEnabled,
+		ExpiresAt:               session.ExpiresAt.UTC().Format(time.RFC3339),
+		UserID:                  session.UserID,
+		Email:                   session.Email,
+		Name:                    session.Name,
+		TOTPEnabled:             session.TOTPEnabled,
+		CredentialResetRequired: session.CredentialResetRequired,
+	})
+}
+
+// HandleSessionStatus answers whether the caller is signed in and unlocked
+// without failing, so the browser extension can poll it cheaply.
+func (c *AuthController) HandleSessionStatus(w http.ResponseWriter, _ *http.Request, session *domain.Session) {
+	w.Header().Set("Cache-Control", "no-store")
+	if session == nil {
+		util.WriteJSON(w, http.StatusOK, dto.SessionStatusResponse{Status: "unauthenticated"})
+		return
+	}
+	status := "unlocked"
+	if session.StepUpRequired && session.TOTPEnabled {
+		status = "locked"
+	}
+	util.WriteJSON(w, http.StatusOK, dto.SessionStatusResponse{
+		Status:    status,
+		UserID:    session.UserID,
+		ExpiresAt: session.ExpiresAt.UTC().Format(time.RFC3339),
 	})
 }
 
 func (c *AuthController) HandleTOTPSetup(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	setup, err := c.auth.BeginTOTPSetup(r.Context(), session.UserID, session.Email)
 	if err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "internal_error", "failed to initialize totp")
+		writeServiceError(w, r, c.log, err, "failed to initialize totp", slog.String("user_id", session.UserID))
 		return
 	}
 	util.WriteJSON(w, http.StatusOK, dto.TOTPSetupResponse{Secret: setup.Secret, OTPAuthURL: setup.OTPAuthURL})
@@ -159,23 +288,13 @@ func (c *AuthController) HandleTOTPSetup(w http.ResponseWriter, r *http.Request,
 func (c *AuthController) HandleTOTPEnable(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	var req dto.TOTPCodeRequest
 	if err := util.ReadJSON(r, &req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+		util.WriteDecodeError(w, err)
 		return
 	}
 
 	recoveryCodes, err := c.auth.EnableTOTP(r.Context(), session.UserID, req.Code)
 	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrInvalidMFA):
-			util.WriteError(w, http.StatusUnauthorized, "invalid_mfa", "invalid totp code")
-		case errors.Is(err, domain.ErrMFARateLimited):
-			util.WriteError(w, http.StatusTooManyRequests, "mfa_rate_limited", "too many invalid mfa attempts, try again later")
-		case errors.Is(err, domain.ErrMissingTOTPSecret):
-			util.WriteError(w, http.StatusBadRequest, "totp_not_initialized", "totp setup required before enable")
-		default:
-			c.log.ErrorContext(r.Context(), "enable totp failed", slog.String("user_id", session.UserID), slog.Any("error", err))
-			util.WriteError(w, http.StatusInternalServerError, "internal_error", "failed to enable totp")
-		}
+		writeServiceError(w, r, c.log, err, "failed to enable totp", slog.String("user_id", session.UserID))
 		return
 	}
 
@@ -188,22 +307,17 @@ func (c *AuthController) HandleTOTPEnable(w http.ResponseWriter, r *http.Request
 func (c *AuthController) HandleTOTPVerify(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	var req dto.TOTPCodeRequest
 	if err := util.ReadJSON(r, &req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+		util.WriteDecodeError(w, err)
 		return
 	}
 
-	if err := c.auth.VerifyTOTPForSession(r.Context(), session.UserID, req.Code); err != nil {
-		switch {
-		case errors.Is(err, domain.ErrInvalidMFA):
-			util.WriteError(w, http.StatusUnauthorized, "invalid_mfa", "invalid totp code")
-		case errors.Is(err, domain.ErrMFARateLimited):
-			util.WriteError(w, http.StatusTooManyRequests, "mfa_rate_limited", "too many invalid mfa attempts, try again later")
-		case errors.Is(err, domain.ErrMissingTOTPSecret):
+	if err := c.auth.VerifyTOTPForSession(r.Context(), session, req.Code); err != nil {
+		// Verifying needs TOTP switched on, not merely set up.
+		if errors.Is(err, domain.ErrMissingTOTPSecret) {
 			util.WriteError(w, http.StatusBadRequest, "totp_not_enabled", "totp is not enabled")
-		default:
-			c.log.ErrorContext(r.Context(), "verify totp failed", slog.String("user_id", session.UserID), slog.Any("error", err))
-			util.WriteError(w, http.StatusInternalServerError, "internal_error", "failed to verify totp")
+			return
 		}
+		writeServiceError(w, r, c.log, err, "failed to verify totp", slog.String("user_id", session.UserID))
 		return
 	}
 
@@ -212,8 +326,7 @@ func (c *AuthController) HandleTOTPVerify(w http.ResponseWriter, r *http.Request
 
 func (c *AuthController) HandleTOTPDisable(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	if err := c.auth.DisableTOTP(r.Context(), session.UserID); err != nil {
-		c.log.ErrorContext(r.Context(), "disable totp failed", slog.String("user_id", session.UserID), slog.Any("error", err))
-		util.WriteError(w, http.StatusInternalServerError, "internal_error", "failed to disable totp")
+		writeServiceError(w, r, c.log, err, "failed to disable totp", slog.String("user_id", session.UserID))
 		return
 	}
 	util.WriteJSON(w, http.StatusOK, dto.StatusResponse{Status: "totp_disabled"})
@@ -222,7 +335,7 @@ func (c *AuthController) HandleTOTPDisable(w http.ResponseWriter, r *http.Reques
 func (c *AuthController) HandleRecoverySetup(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	var req dto.RecoverySetupRequest
 	if err := util.ReadJSON(r, &req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+		util.WriteDecodeError(w, err)
 		return
 	}
 
@@ -252,13 +365,12 @@ func (c *AuthController) HandleRecoverySetup(w http.ResponseWriter, r *http.Requ
 	}
 
 	if err := c.auth.SetupRecovery(r.Context(), session.UserID, req.RecoveryKey, wrappedKEK, wrapNonce, kekSalt); err != nil {
-		switch {
-		case errors.Is(err, domain.ErrInvalidRecoveryKey):
+		// A malformed key is bad input here, not a failed sign-in.
+		if errors.Is(err, domain.ErrInvalidRecoveryKey) {
 			util.WriteError(w, http.StatusBadRequest, "invalid_recovery_key", "invalid recovery key")
-		default:
-			c.log.ErrorContext(r.Context(), "setup recovery failed", slog.String("user_id", session.UserID), slog.Any("error", err))
-			util.WriteError(w, http.StatusInternalServerError, "internal_error", "failed to setup recovery")
+			return
 		}
+		writeServiceError(w, r, c.log, err, "failed to setup recovery", slog.String("user_id", session.UserID))
 		return
 	}
 
@@ -268,8 +380,7 @@ func (c *AuthController) HandleRecoverySetup(w http.ResponseWriter, r *http.Requ
 func (c *AuthController) HandleGetRecoveryStatus(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	status, err := c.auth.GetRecoveryStatus(r.Context(), session.UserID)
 	if err != nil {
-		c.log.ErrorContext(r.Context(), "get recovery status failed", slog.String("user_id", session.UserID), slog.Any("error", err))
-		util.WriteError(w, http.StatusInternalServerError, "internal_error", "failed to check recovery status")
+		writeServiceError(w, r, c.log, err, "failed to check recovery status", slog.String("user_id", session.UserID))
 		return
 	}
 	util.WriteJSON(w, http.StatusOK, dto.RecoveryStatusResponse{IsEnabled: status})
@@ -278,35 +389,17 @@ func (c *AuthController) HandleGetRecoveryStatus(w http.ResponseWriter, r *http.
 func (c *AuthController) HandleRecoveryVerify(w http.ResponseWriter, r *http.Request) {
 	var req dto.RecoveryVerifyRequest
 	if err := util.ReadJSON(r, &req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+		util.WriteDecodeError(w, err)
 		return
 	}
 
 	token, expiresAt, recoveryRecord, err := c.auth.VerifyRecoveryKey(r.Context(), req.Email, req.RecoveryKey, req.TOTPCode)
 	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrInvalidCredentials):
-			util.WriteError(w, http.StatusUnauthorized, "invalid_credentials", "invalid email")
-		case errors.Is(err, domain.ErrInvalidRecoveryKey):
-			util.WriteError(w, http.StatusUnauthorized, "invalid_recovery_key", "invalid recovery key")
-		case errors.Is(err, domain.ErrRecoveryNotSetup):
-			util.WriteError(w, http.StatusBadRequest, "recovery_not_setup", "account recovery is not configured")
-		case errors.Is(err, domain.ErrRecoveryCooldown):
-			util.WriteError(w, http.StatusTooManyRequests, "recovery_cooldown", "recovery attempted too recently, try again later")
-		case errors.Is(err, domain.ErrMFARequired):
-			util.WriteJSON(w, http.StatusUnauthorized, dto.MFARequiredResponse{
-				Error:       "mfa_required",
-				Message:     "totp code is required for recovery",
-				MFARequired: true,
-			})
-		case errors.Is(err, domain.ErrInvalidMFA):
-			util.WriteError(w, http.StatusUnauthorized, "invalid_mfa", "invalid totp code")
-		case errors.Is(err, domain.ErrMFARateLimited):
-			util.WriteError(w, http.StatusTooManyRequests, "mfa_rate_limited", "too many invalid mfa attempts, try again later")
-		default:
-			c.log.ErrorContext(r.Context(), "verify recovery key failed", slog.Any("error", err))
-			util.WriteError(w, http.StatusInternalServerError, "internal_error", "failed to verify recovery key")
+		if errors.Is(err, domain.ErrMFARequired) {
+			writeMFARequired(w, "totp code is required for recovery")
+			return
 		}
+		writeServiceError(w, r, c.log, err, "failed to verify recovery key")
 		return
 	}
 
@@ -322,10 +415,25 @@ func (c *AuthController) HandleRecoveryVerify(w http.ResponseWriter, r *http.Req
 	util.WriteJSON(w, http.StatusOK, resp)
 }
 
+// HandleRevokeSessions signs an account out everywhere by the token of the
+// link in a lockout alert email. It needs no session.
+func (c *AuthController) HandleRevokeSessions(w http.ResponseWriter, r *http.Request) {
+	var req dto.RevokeSessionsRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+	if err := c.auth.RevokeSessionsByLink(r.Context(), req.Token); err != nil {
+		writeServiceError(w, r, c.log, err, "failed to revoke sessions")
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, dto.StatusResponse{Status: "sessions_revoked"})
+}
+
 func (c *AuthController) HandleRecoveryReset(w http.ResponseWriter, r *http.Request) {
 	var req dto.RecoveryResetRequest
 	if err := util.ReadJSON(r, &req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+		util.WriteDecodeError(w, err)
 		return
 	}
 
@@ -338,19 +446,11 @@ func (c *AuthController) HandleRecoveryReset(w http.ResponseWriter, r *http.Requ
 		r.UserAgent(),
 	)
 	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrInvalidRecoveryToken):
-			util.WriteError(w, http.StatusUnauthorized, "invalid_recovery_token", "invalid or expired recovery token")
-		case errors.Is(err, domain.ErrWeakPassword):
-			util.WriteError(w, http.StatusBadRequest, "weak_password", "password does not meet complexity requirements")
-		default:
-			c.log.ErrorContext(r.Context(), "reset password failed", slog.Any("error", err))
-			util.WriteError(w, http.StatusInternalServerError, "internal_error", "failed to reset password")
-		}
+		writeServiceError(w, r, c.log, err, "failed to reset password")
 		return
 	}
 
-	c.setSessionCookie(w, output.SessionToken, output.ExpiresAt)
+	c.setLoginCookies(w, output)
 
 	util.WriteJSON(w, http.StatusOK, dto.RecoveryResetResponse{
 		Status:      "password_reset",
@@ -362,6 +462,199 @@ func (c *AuthController) HandleRecoveryReset(w http.ResponseWriter, r *http.Requ
 	})
 }
 
+// HandleCredentialReset changes the master password of an account an admin
+// flagged compromised, together with the wrapped keys of its vault items,
+// and replaces the restricted session with a new one.
+func (c *AuthController) HandleCredentialReset(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var req dto.CredentialResetRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+
+	var fields util.FieldErrors
+	newSalt, err := decodeBase64Required(req.NewSalt)
+	if err != nil {
+		fields.Add("new_salt", util.FieldInvalid, "new_salt must be non-empty standard base64")
+	}
+	items := decodeRewrappedKeys("items", req.Items, &fields)
+	if len(fields) > 0 {
+		util.WriteValidationError(w, "invalid_credential_reset", "credential reset payload is invalid", fields)
+		return
+	}
+
+	output, err := c.auth.ResetCompromisedCredentials(r.Context(), session, domain.CredentialResetInput{
+		UserID:          session.UserID,
+		CurrentPassword: req.CurrentPassword,
+		NewPassword:     req.NewPassword,
+		NewSalt:         newSalt,
+		Items:           items,
+	}, req.DeviceName, util.ClientIPFromRequest(r), r.UserAgent())
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to reset credentials")
+		return
+	}
+
+	c.setLoginCookies(w, output)
+
+	util.WriteJSON(w, http.StatusOK, dto.RecoveryResetResponse{
+		Status:      "credentials_reset",
+		UserID:      output.UserID,
+		Email:       output.Email,
+		Name:        output.Name,
+		ExpiresAt:   output.ExpiresAt.UTC().Format(time.RFC3339),
+		TOTPEnabled: output.TOTPEnabled,
+	})
+}
+
+// HandleChangePassword changes the caller's master password together with
+// the wrapped keys of their vault items and signs out their other
+// sessions. The caller's session stays valid.
+func (c *AuthController) HandleChangePassword(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var req dto.ChangePasswordRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+
+	var fields util.FieldErrors
+	fields.Required("current_password", req.CurrentPassword)
+	fields.Required("new_password", req.NewPassword)
+	newSalt, err := decodeBase64Required(req.NewSalt)
+	if err != nil {
+		fields.Add("new_salt", util.FieldInvalid, "new_salt must be non-empty standard base64")
+	}
+	items := decodeRewrappedKeys("items", req.Items, &fields)
+	if len(fields) > 0 {
+		util.WriteValidationError(w, "invalid_password_change", "password change payload is invalid", fields)
+		return
+	}
+
+	revoked, err := c.auth.ChangePassword(r.Context(), session, domain.CredentialResetInput{
+		UserID:          session.UserID,
+		CurrentPassword: req.CurrentPassword,
+		NewPassword:     req.NewPassword,
+		NewSalt:         newSalt,
+		Items:           items,
+	})
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to change password")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, dto.ChangePasswordResponse{Status: "password_changed", RevokedSessions: revoked})
+}
+
+// HandlePasswordResetRequest emails a password reset link to the account of
+// the email given, if there is one. The answer is the same either way.
+func (c *AuthController) HandlePasswordResetRequest(w http.ResponseWriter, r *http.Request) {
+	var req dto.PasswordResetRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+	var fields util.FieldErrors
+	fields.Required("email", req.Email)
+	if len(fields) > 0 {
+		util.WriteValidationError(w, "invalid_password_reset", "password reset payload is invalid", fields)
+		return
+	}
+
+	if err := c.auth.RequestPasswordReset(r.Context(), req.Email); err != nil {
+		writeServiceError(w, r, c.log, err, "failed to request password reset")
+		return
+	}
+	util.WriteJSON(w, http.StatusAccepted, dto.StatusResponse{Status: "password_reset_requested"})
+}
+
+// HandlePasswordResetConfirm sets a new master password with the token of
+// an emailed reset link and the vault's item keys re-wrapped under the key
+// derived from it. Every session is signed out; the account then signs in
+// with the new password.
+func (c *AuthController) HandlePasswordResetConfirm(w http.ResponseWriter, r *http.Request) {
+	var req dto.PasswordResetConfirmRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+
+	var fields util.FieldErrors
+	fields.Required("token", req.Token)
+	fields.Required("new_password", req.NewPassword)
+	newSalt, err := decodeBase64Required(req.NewSalt)
+	if err != nil {
+		fields.Add("new_salt", util.FieldInvalid, "new_salt must be non-empty standard base64")
+	}
+	items := decodeRewrappedKeys("items", req.Items, &fields)
+	if len(fields) > 0 {
+		util.WriteValidationError(w, "invalid_password_reset", "password reset payload is invalid", fields)
+		return
+	}
+
+	err = c.auth.ConfirmPasswordReset(r.Context(), domain.PasswordResetInput{
+		Token:       req.Token,
+		NewPassword: req.NewPassword,
+		NewSalt:     newSalt,
+		Items:       items,
+	})
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to reset password")
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, dto.StatusResponse{Status: "password_reset"})
+}
+
+// HandleMergeAccounts folds the account of source_session_token into the
+// caller's, with the re-wrapped keys of its items and received shares, and
+// tombstones it.
+func (c *AuthController) HandleMergeAccounts(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var req dto.AccountMergeRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+
+	var fields util.FieldErrors
+	fields.Required("source_session_token", req.SourceSessionToken)
+	items := decodeRewrappedKeys("items", req.Items, &fields)
+	shares := decodeRewrappedKeys("shares", req.Shares, &fields)
+	if len(fields) > 0 {
+		util.WriteValidationError(w, "invalid_account_merge", "account merge payload is invalid", fields)
+		return
+	}
+
+	merge, err := c.auth.MergeAccounts(r.Context(), session, strings.TrimSpace(req.SourceSessionToken), items, shares)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to merge accounts")
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, dto.AccountMergeResponse{
+		Status:       "merged",
+		MergedUserID: merge.SourceUserID,
+		Moved:        merge.Moved,
+		MergedAt:     merge.MergedAt.Format(time.RFC3339),
+	})
+}
+
+// decodeRewrappedKeys decodes the item keys of a request, reporting bad
+// ones under field.
+func decodeRewrappedKeys(field string, keys []dto.RewrappedItemKey, fields *util.FieldErrors) []domain.VaultItemKey {
+	decoded := make([]domain.VaultItemKey, 0, len(keys))
+	for i, key := range keys {
+		prefix := fmt.Sprintf("%s[%d].", field, i)
+		wrappedDEK, err := decodeBase64Required(key.WrappedDEK)
+		if err != nil {
+			fields.Add(prefix+"wrapped_dek", util.FieldInvalid, "wrapped_dek must be non-empty standard base64")
+		}
+		wrapNonce, err := decodeBase64Required(key.WrapNonce)
+		if err != nil {
+			fields.Add(prefix+"wrap_nonce", util.FieldInvalid, "wrap_nonce must be non-empty standard base64")
+		}
+		decoded = append(decoded, domain.VaultItemKey{ItemID: strings.TrimSpace(key.ID), WrappedDEK: wrappedDEK, WrapNonce: wrapNonce})
+	}
+	return decoded
+}
+
 func decodeHex(value string) ([]byte, error) {
 	trimmed := strings.TrimSpace(value)
 	if trimmed == "" {
@@ -403,13 +696,26 @@ func (c *AuthController) sessionTokenFromRequest(r *http.Request) string {
 }
 
 func (c *AuthController) setSessionCookie(w http.ResponseWriter, token string, expiresAt time.Time) {
+	c.setCookie(w, c.sessionCookieName, token, expiresAt)
+}
+
+// setLoginCookies sets the cookies of a sign-in or refresh: the session
+// token, and the access token when one was issued.
+func (c *AuthController) setLoginCookies(w http.ResponseWriter, output domain.LoginOutput) {
+	c.setSessionCookie(w, output.SessionToken, output.ExpiresAt)
+	if output.AccessToken != "" {
+		c.setCookie(w, util.AccessTokenCookie(c.sessionCookieName), output.AccessToken, output.AccessTokenExpiresAt)
+	}
+}
+
+func (c *AuthController) setCookie(w http.ResponseWriter, name, token string, expiresAt time.Time) {
 	maxAge := int(time.Until(expiresAt).Seconds())
 	if maxAge < 1 {
 		maxAge = 1
 	}
 
 	cookie := &http.Cookie{
-		Name:     c.sessionCookieName,
+		Name:     name,
 		Value:    token,
 		Path:     "/",
 		HttpOnly: true,
@@ -429,8 +735,12 @@ func (c *AuthController) setSessionCookie(w http.ResponseWriter, token string, e
 }
 
 func (c *AuthController) clearSessionCookie(w http.ResponseWriter) {
+	c.clearCookie(w, c.sessionCookieName)
+}
+
+func (c *AuthController) clearCookie(w http.ResponseWriter, name string) {
 	cookie := &http.Cookie{
-		Name:     c.sessionCookieName,
+		Name:     name,
 		Value:    "",
 		Path:     "/",
 		HttpOnly: true,
@@ -451,19 +761,83 @@ func (c *AuthController) clearSessionCookie(w http.ResponseWriter) {
 func (c *AuthController) HandleUpdateProfile(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	var req dto.UpdateProfileRequest
 	if err := util.ReadJSON(r, &req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+		util.WriteDecodeError(w, err)
 		return
 	}
 
 	if err := c.auth.UpdateProfile(r.Context(), session.UserID, req.Name); err != nil {
-		if errors.Is(err, domain.ErrNotFound) {
-			util.WriteError(w, http.StatusNotFound, "not_found", "user not found")
-			return
-		}
-		c.log.ErrorContext(r.Context(), "update profile failed", slog.String("user_id", session.UserID), slog.Any("error", err))
-		util.WriteError(w, http.StatusInternalServerError, "internal_error", "failed to update profile")
+		writeServiceError(w, r, c.log, err, "failed to update profile", slog.String("user_id", session.UserID))
 		return
 	}
 
 	util.WriteJSON(w, http.StatusOK, dto.StatusResponse{Status: "profile_updated"})
 }
+
+func (c *AuthController) HandleGetSessionBinding(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	binding, err := c.auth.GetSessionBinding(r.Context(), session.UserID)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to load session binding", slog.String("user_id", session.UserID))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, sessionBindingResponse(binding))
+}
+
+// HandleUpdateSessionBinding replaces the session binding setting. The
+// calling session is rebound to the client making the request.
+func (c *AuthController) HandleUpdateSessionBinding(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var req dto.SessionBindingRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+	var fields util.FieldErrors
+	fields.Required("on_mismatch", req.OnMismatch)
+	if len(fields) > 0 {
+		util.WriteFieldErrors(w, fields)
+		return
+	}
+
+	binding, err := c.auth.UpdateSessionBinding(r.Context(), session, domain.SessionBinding{
+		IPPrefix:   req.BindIPPrefix,
+		UserAgent:  req.BindUserAgent,
+		OnMismatch: domain.SessionBindingAction(req.OnMismatch),
+	})
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to update session binding", slog.String("user_id", session.UserID))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, sessionBindingResponse(binding))
+}
+
+// HandleRegisterDPoPKey binds the session to the key that signed the
+// request's DPoP proof. The client names its type in X-Client-Type; only
+// configured types may opt in.
+func (c *AuthController) HandleRegisterDPoPKey(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	jkt, err := c.auth.RegisterDPoPKey(r.Context(), session, c.sessionTokenFromRequest(r), r.Header.Get("X-Client-Type"))
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to register dpop key", slog.String("user_id", session.UserID))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, dto.DPoPKeyResponse{Status: "dpop_bound", JKT: jkt})
+}
+
+func sessionBindingResponse(binding domain.SessionBinding) dto.SessionBindingResponse {
+	return dto.SessionBindingResponse{
+		BindIPPrefix:  binding.IPPrefix,
+		BindUserAgent: binding.UserAgent,
+		OnMismatch:    string(binding.OnMismatch),
+	}
+}
+
+// writeMFARequired answers a sign-in that needs a second factor. The body
+// adds mfa_required so clients can prompt without parsing the code.
+func writeMFARequired(w http.ResponseWriter, message string) {
+	util.WriteJSON(w, http.StatusUnauthorized, dto.MFARequiredResponse{
+		Error:       domain.ErrMFARequired.Code,
+		Message:     i18n.FromWriter(w).Text("error."+domain.ErrMFARequired.Code, message),
+		MFARequired: true,
+	})
+}
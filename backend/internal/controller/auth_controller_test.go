@@ -4,14 +4,18 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"pmv2/backend/internal/controller"
 	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dto"
 	"pmv2/backend/internal/service"
 )
 
@@ -29,7 +33,7 @@ type mockAuthRepo struct {
 	resetTOTPFailuresFn     func(ctx context.Context, userID string) error
 	replaceRecoveryCodesFn  func(ctx context.Context, userID string, codeHashes [][]byte) error
 	consumeRecoveryCodeFn   func(ctx context.Context, userID string, codeHash []byte) (bool, error)
-	deleteExpiredSessionsFn func(ctx context.Context) (int64, error)
+	deleteExpiredSessionsFn func(ctx context.Context, revokedBefore time.Time, afterID string, limit int) (domain.SessionPurgeBatch, error)
 }
 
 func (m *mockAuthRepo) CreateUserWithCredentials(ctx context.Context, input domain.CreateUserInput) error {
@@ -44,6 +48,9 @@ func (m *mockAuthRepo) GetUserAuthByEmail(ctx context.Context, email string) (do
 	}
 	return domain.UserAuthRecord{}, domain.ErrNotFound
 }
+func (m *mockAuthRepo) GetUserAuthByID(ctx context.Context, userID string) (domain.UserAuthRecord, error) {
+	return domain.UserAuthRecord{}, domain.ErrNotFound
+}
 func (m *mockAuthRepo) CreateSession(ctx context.Context, input domain.CreateSessionInput) error {
 	if m.createSessionFn != nil {
 		return m.createSessionFn(ctx, input)
@@ -110,17 +117,69 @@ func (m *mockAuthRepo) ConsumeRecoveryCode(ctx context.Context, userID string, c
 	}
 	return false, nil
 }
-func (m *mockAuthRepo) DeleteExpiredSessions(ctx context.Context) (int64, error) {
+func (m *mockAuthRepo) DeleteExpiredSessions(ctx context.Context, revokedBefore time.Time, afterID string, limit int) (domain.SessionPurgeBatch, error) {
 	if m.deleteExpiredSessionsFn != nil {
-		return m.deleteExpiredSessionsFn(ctx)
+		return m.deleteExpiredSessionsFn(ctx, revokedBefore, afterID, limit)
 	}
-	return 0, nil
+	return domain.SessionPurgeBatch{}, nil
 }
 
 func (m *mockAuthRepo) RevokeAllUserSessions(ctx context.Context, userID string) (int64, error) {
 	return 0, nil
 }
 
+func (m *mockAuthRepo) SetSessionStepUp(ctx context.Context, sessionID string, required bool) error {
+	return nil
+}
+
+func (m *mockAuthRepo) RebindSession(ctx context.Context, sessionID string, ipAddr string, userAgent string) error {
+	return nil
+}
+
+func (m *mockAuthRepo) GetSessionBinding(ctx context.Context, userID string) (domain.SessionBinding, error) {
+	return domain.SessionBinding{}, domain.ErrNotFound
+}
+
+func (m *mockAuthRepo) SetSessionBinding(ctx context.Context, userID string, binding domain.SessionBinding) error {
+	return nil
+}
+
+func (m *mockAuthRepo) SetSessionDPoPKey(ctx context.Context, sessionID string, thumbprint string) (bool, error) {
+	return true, nil
+}
+
+func (m *mockAuthRepo) RevokeSessionByID(ctx context.Context, sessionID string) (bool, error) {
+	return false, nil
+}
+
+func (m *mockAuthRepo) SessionActive(ctx context.Context, sessionID string) (bool, error) {
+	return true, nil
+}
+
+func (m *mockAuthRepo) ListUserSessions(ctx context.Context, userID string) ([]domain.UserSession, error) {
+	return []domain.UserSession{}, nil
+}
+
+func (m *mockAuthRepo) RevokeUserSession(ctx context.Context, userID, sessionID string) (bool, error) {
+	return false, nil
+}
+
+func (m *mockAuthRepo) RevokeOtherUserSessions(ctx context.Context, userID, keepSessionID string) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockAuthRepo) TouchSession(ctx context.Context, sessionID string, usedAt time.Time) error {
+	return nil
+}
+
+func (m *mockAuthRepo) RotateSessionToken(ctx context.Context, sessionID string, oldHash, newHash []byte, expiresAt time.Time) (bool, error) {
+	return false, nil
+}
+
+func (m *mockAuthRepo) GetRetiredSessionToken(ctx context.Context, tokenHash []byte) (domain.RetiredSessionToken, error) {
+	return domain.RetiredSessionToken{}, domain.ErrNotFound
+}
+
 func (m *mockAuthRepo) SetupRecovery(ctx context.Context, input domain.SetupRecoveryInput) error {
 	return nil
 }
@@ -137,12 +196,16 @@ func (m *mockAuthRepo) UpdatePassword(ctx context.Context, input domain.ResetPas
 	return nil
 }
 
+func (m *mockAuthRepo) SetCredentialsCompromised(ctx context.Context, userID string) error {
+	return nil
+}
+
 func (m *mockAuthRepo) UpdateDisplayName(ctx context.Context, userID string, name string) error {
 	return nil
 }
 
 func setupController(repo *mockAuthRepo) *controller.AuthController {
-	svc := service.NewAuthService(repo, nil, "pepper-test", time.Hour, "issuer")
+	svc := service.NewAuthService(repo, nil, nil, "pepper-test", time.Hour, "issuer")
 	return controller.NewAuthController(svc, controller.AuthCookieConfig{
 		Name:   "pmv2_session",
 		Secure: false,
@@ -159,7 +222,7 @@ func TestHandleRegister_Success(t *testing.T) {
 
 	body := map[string]string{
 		"email":    "test@example.com",
-		"password": "Password123!",
+		"password": "Correct-Horse-9",
 		"name":     "Test",
 	}
 	b, _ := json.Marshal(body)
@@ -192,7 +255,7 @@ func TestHandleRegister_EmailTaken(t *testing.T) {
 
 	body := map[string]string{
 		"email":    "test@example.com",
-		"password": "Password123!",
+		"password": "Correct-Horse-9",
 		"name":     "Test",
 	}
 	b, _ := json.Marshal(body)
@@ -212,6 +275,36 @@ func TestHandleRegister_EmailTaken(t *testing.T) {
 	}
 }
 
+func TestHandleRegister_ServiceErrorsUseCatalog(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"wrapped catalog error", fmt.Errorf("insert user: %w", domain.ErrEmailTaken), http.StatusConflict, "email_taken"},
+		{"unexpected error", errors.New("connection reset"), http.StatusInternalServerError, "internal_error"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := setupController(&mockAuthRepo{
+				createUserFn: func(ctx context.Context, input domain.CreateUserInput) error { return tc.err },
+			})
+			b, _ := json.Marshal(map[string]string{"email": "test@example.com", "password": "Correct-Horse-9", "name": "Test"})
+			rec := httptest.NewRecorder()
+			c.HandleRegister(rec, httptest.NewRequest(http.MethodPost, "/register", bytes.NewReader(b)))
+
+			resp := decodeErrorResponse(t, rec)
+			if rec.Code != tc.status || resp.Error != tc.code {
+				t.Fatalf("got %d %q, want %d %q", rec.Code, resp.Error, tc.status, tc.code)
+			}
+			if strings.Contains(resp.Message, tc.err.Error()) {
+				t.Fatalf("message %q leaks the underlying error", resp.Message)
+			}
+		})
+	}
+}
+
 func TestHandleLogin_InvalidCredentials(t *testing.T) {
 	repo := &mockAuthRepo{
 		getUserAuthByEmailFn: func(ctx context.Context, email string) (domain.UserAuthRecord, error) {
@@ -253,3 +346,85 @@ func TestHandleLogin_InvalidJSON(t *testing.T) {
 		t.Errorf("expected 400 Bad Request, got %d", rec.Code)
 	}
 }
+
+func decodeErrorResponse(t *testing.T, rec *httptest.ResponseRecorder) dto.ErrorResponse {
+	t.Helper()
+	var resp dto.ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode error response: %v", err)
+	}
+	return resp
+}
+
+func fieldCodes(fields []dto.FieldError) map[string]string {
+	codes := make(map[string]string, len(fields))
+	for _, f := range fields {
+		codes[f.Field] = f.Code
+	}
+	return codes
+}
+
+func TestHandleRegister_FieldErrors(t *testing.T) {
+	c := setupController(&mockAuthRepo{
+		createUserFn: func(ctx context.Context, input domain.CreateUserInput) error {
+			t.Fatal("invalid registration reached the repository")
+			return nil
+		},
+	})
+
+	b, _ := json.Marshal(map[string]string{"email": "not-an-email", "password": "short"})
+	rec := httptest.NewRecorder()
+	c.HandleRegister(rec, httptest.NewRequest(http.MethodPost, "/register", bytes.NewReader(b)))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	resp := decodeErrorResponse(t, rec)
+	if resp.Error != "validation_failed" {
+		t.Errorf("expected error=validation_failed, got %q", resp.Error)
+	}
+	codes := fieldCodes(resp.Fields)
+	if codes["email"] != "invalid" || codes["password"] != "weak_password" || len(codes) != 2 {
+		t.Errorf("unexpected fields %+v", resp.Fields)
+	}
+}
+
+func TestHandleLogin_FieldErrors(t *testing.T) {
+	c := setupController(&mockAuthRepo{})
+
+	rec := httptest.NewRecorder()
+	c.HandleLogin(rec, httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader([]byte(`{"email": " "}`))))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	codes := fieldCodes(decodeErrorResponse(t, rec).Fields)
+	if codes["email"] != "required" || codes["password"] != "required" {
+		t.Errorf("unexpected fields %v", codes)
+	}
+}
+
+func TestHandleLogin_MFAInputConflictNamesField(t *testing.T) {
+	c := setupController(&mockAuthRepo{})
+
+	b, _ := json.Marshal(map[string]string{"email": "a@example.com", "password": "Password123!", "totp_code": "123456", "recovery_code": "abcd"})
+	rec := httptest.NewRecorder()
+	c.HandleLogin(rec, httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(b)))
+
+	resp := decodeErrorResponse(t, rec)
+	if resp.Error != "invalid_mfa_input" || fieldCodes(resp.Fields)["recovery_code"] != "conflict" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestHandleLogin_DecodeErrorNamesField(t *testing.T) {
+	c := setupController(&mockAuthRepo{})
+
+	rec := httptest.NewRecorder()
+	c.HandleLogin(rec, httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader([]byte(`{"email": "a@example.com", "password": 7}`))))
+
+	resp := decodeErrorResponse(t, rec)
+	if resp.Error != "invalid_json" || fieldCodes(resp.Fields)["password"] != "invalid_type" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+// eventStreamHeartbeat is how often an idle event stream sends a comment
+// line, so proxies do not close it for inactivity.
+const eventStreamHeartbeat = 25 * time.Second
+
+// HandleEvents holds a server-sent event stream open for the session. When
+// the session is revoked, whether by the user, an admin or a password
+// reset, it sends a session_revoked event and closes, so the client can
+// lock without waiting for its next request to fail.
+func (c *AuthController) HandleEvents(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	rc := http.NewResponseController(w)
+	// The stream outlives the server's write timeout by design.
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-store")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.WriteString(w, "retry: 5000\n\n"); err != nil {
+		return
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	ctx := r.Context()
+	ended := c.auth.WatchSession(ctx, session)
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ended:
+			if ctx.Err() == nil {
+				_, _ = io.WriteString(w, "event: session_revoked\ndata: {\"session_id\":\""+session.ID+"\"}\n\n")
+				_ = rc.Flush()
+			}
+			return
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package controller
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/util"
+	"pmv2/backend/internal/webauthn"
+)
+
+// maxPasskeyNameLength bounds the label a user gives a passkey.
+const maxPasskeyNameLength = 255
+
+// HandlePasskeyRegisterBegin starts creating an account that signs in with
+// a passkey only.
+func (c *AuthController) HandlePasskeyRegisterBegin(w http.ResponseWriter, r *http.Request) {
+	var req dto.PasskeyRegisterBeginRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+	var fields util.FieldErrors
+	fields.Email("email", req.Email)
+	if len(fields) > 0 {
+		util.WriteFieldErrors(w, fields)
+		return
+	}
+
+	challenge, err := c.auth.BeginPasskeyRegistration(r.Context(), req.Email, req.Name)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to start passkey registration")
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, c.creationOptions(challenge, nil))
+}
+
+// HandlePasskeyRegisterFinish creates the account and signs it in.
+func (c *AuthController) HandlePasskeyRegisterFinish(w http.ResponseWriter, r *http.Request) {
+	var req dto.PasskeyRegistrationRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+	reg, fields := passkeyRegistration(req)
+	if len(fields) > 0 {
+		util.WriteFieldErrors(w, fields)
+		return
+	}
+
+	output, err := c.auth.FinishPasskeyRegistration(r.Context(), reg, passkeyLoginInput(r, req.DeviceName, req.DeviceFingerprint))
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "passkey registration failed")
+		return
+	}
+	c.setLoginCookies(w, output)
+	util.WriteJSON(w, http.StatusCreated, loginResponse(output))
+}
+
+// HandlePasskeyLoginBegin issues a challenge for signing in with any
+// passkey; the authenticator picks the account.
+func (c *AuthController) HandlePasskeyLoginBegin(w http.ResponseWriter, r *http.Request) {
+	challenge, err := c.auth.BeginPasskeyLogin(r.Context())
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to start passkey sign-in")
+		return
+	}
+	c.writeRequestOptions(w, challenge)
+}
+
+// HandlePasskeySecondFactorBegin issues a challenge for answering the
+// second-factor step of POST /auth/login with a passkey.
+func (c *AuthController) HandlePasskeySecondFactorBegin(w http.ResponseWriter, r *http.Request) {
+	challenge, err := c.auth.BeginPasskeySecondFactor(r.Context())
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to start passkey verification")
+		return
+	}
+	c.writeRequestOptions(w, challenge)
+}
+
+// HandlePasskeyLoginFinish signs in with a passkey and returns the wrapped
+// vault key registered with it.
+func (c *AuthController) HandlePasskeyLoginFinish(w http.ResponseWriter, r *http.Request) {
+	var req dto.PasskeyLoginRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+	var fields util.FieldErrors
+	assertion := passkeyAssertion(&fields, "", req.PasskeyAssertionRequest)
+	validateDeviceFingerprint(&fields, req.DeviceFingerprint)
+	if len(fields) > 0 {
+		util.WriteFieldErrors(w, fields)
+		return
+	}
+
+	output, err := c.auth.FinishPasskeyLogin(r.Context(), assertion, passkeyLoginInput(r, req.DeviceName, req.DeviceFingerprint))
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "passkey sign-in failed")
+		return
+	}
+	c.setLoginCookies(w, output.LoginOutput)
+	util.WriteJSON(w, http.StatusOK, dto.PasskeyLoginResponse{
+		LoginResponse: loginResponse(output.LoginOutput),
+		PasskeyID:     output.Passkey.ID,
+		PRFSalt:       base64.RawURLEncoding.EncodeToString(output.Passkey.PRFSalt),
+		WrappedKey:    base64.RawURLEncoding.EncodeToString(output.Passkey.WrappedKey),
+		WrapNonce:     base64.RawURLEncoding.EncodeToString(output.Passkey.WrapNonce),
+	})
+}
+
+func (c *AuthController) HandleListPasskeys(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	passkeys, err := c.auth.ListPasskeys(r.Context(), session.UserID)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to list passkeys")
+		return
+	}
+
+	resp := dto.PasskeysResponse{Passkeys: make([]dto.PasskeyResponse, 0, len(passkeys))}
+	for _, p := range passkeys {
+		item := dto.PasskeyResponse{
+			ID:        p.ID,
+			Name:      p.Name,
+			PRF:       len(p.WrappedKey) > 0,
+			CreatedAt: p.CreatedAt.UTC().Format(time.RFC3339),
+		}
+		if p.LastUsedAt != nil {
+			lastUsed := p.LastUsedAt.UTC().Format(time.RFC3339)
+			item.LastUsedAt = &lastUsed
+		}
+		resp.Passkeys = append(resp.Passkeys, item)
+	}
+	util.WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleAddPasskeyBegin starts adding a passkey to the signed-in account.
+func (c *AuthController) HandleAddPasskeyBegin(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	challenge, err := c.auth.BeginAddPasskey(r.Context(), session.UserID, session.Email)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to start adding a passkey")
+		return
+	}
+	existing, err := c.auth.ListPasskeys(r.Context(), session.UserID)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to list passkeys")
+		return
+	}
+	challenge.Name = session.Name
+	util.WriteJSON(w, http.StatusOK, c.creationOptions(challenge, existing))
+}
+
+func (c *AuthController) HandleAddPasskeyFinish(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var req dto.PasskeyRegistrationRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+	reg, fields := passkeyRegistration(req)
+	if len(fields) > 0 {
+		util.WriteFieldErrors(w, fields)
+		return
+	}
+
+	passkey, err := c.auth.FinishAddPasskey(r.Context(), session.UserID, reg)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to add passkey")
+		return
+	}
+	util.WriteJSON(w, http.StatusCreated, dto.PasskeyResponse{
+		ID:        passkey.ID,
+		Name:      passkey.Name,
+		PRF:       len(passkey.WrappedKey) > 0,
+		CreatedAt: passkey.CreatedAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// HandleRemovePasskey deletes a passkey; an account without a password
+// keeps its last one.
+func (c *AuthController) HandleRemovePasskey(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	if err := c.auth.RemovePasskey(r.Context(), session.UserID, r.PathValue("passkey_id")); err != nil {
+		writeServiceError(w, r, c.log, err, "failed to remove passkey")
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, dto.StatusResponse{Status: "removed"})
+}
+
+// HandlePasskeyRecoveryBegin starts enrolling a replacement passkey with a
+// token from /auth/recovery/verify.
+func (c *AuthController) HandlePasskeyRecoveryBegin(w http.ResponseWriter, r *http.Request) {
+	var req dto.PasskeyRecoveryBeginRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+
+	challenge, err := c.auth.BeginPasskeyRecovery(r.Context(), req.RecoveryToken)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to start passkey recovery")
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, c.creationOptions(challenge, nil))
+}
+
+// HandlePasskeyRecoveryFinish replaces the account's passkeys with the new
+// one and signs it in, revoking every other session.
+func (c *AuthController) HandlePasskeyRecoveryFinish(w http.ResponseWriter, r *http.Request) {
+	var req dto.PasskeyRecoveryFinishRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+	reg, fields := passkeyRegistration(req.PasskeyRegistrationRequest)
+	if len(fields) > 0 {
+		util.WriteFieldErrors(w, fields)
+		return
+	}
+
+	output, err := c.auth.FinishPasskeyRecovery(r.Context(), req.RecoveryToken, reg, passkeyLoginInput(r, req.DeviceName, req.DeviceFingerprint))
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "passkey recovery failed")
+		return
+	}
+	c.setLoginCookies(w, output)
+	util.WriteJSON(w, http.StatusOK, loginResponse(output))
+}
+
+func (c *AuthController) creationOptions(challenge domain.PasskeyChallenge, existing []domain.Passkey) dto.PasskeyCreationOptionsResponse {
+	rp := c.auth.RelyingParty()
+	exclude := make([]string, 0, len(existing))
+	for _, p := range existing {
+		exclude = append(exclude, base64.RawURLEncoding.EncodeToString(p.CredentialID))
+	}
+	displayName := challenge.Name
+	if displayName == "" {
+		displayName = challenge.Email
+	}
+	return dto.PasskeyCreationOptionsResponse{
+		ChallengeID:        challenge.ID,
+		Challenge:          base64.RawURLEncoding.EncodeToString(challenge.Challenge),
+		ExpiresAt:          challenge.ExpiresAt.UTC().Format(time.RFC3339),
+		RPID:               rp.ID,
+		RPName:             rp.Name,
+		UserID:             base64.RawURLEncoding.EncodeToString([]byte(challenge.UserID)),
+		UserName:           challenge.Email,
+		UserDisplayName:    displayName,
+		Algorithms:         webauthn.Algorithms,
+		ExcludeCredentials: exclude,
+	}
+}
+
+func (c *AuthController) writeRequestOptions(w http.ResponseWriter, challenge domain.PasskeyChallenge) {
+	util.WriteJSON(w, http.StatusOK, dto.PasskeyRequestOptionsResponse{
+		ChallengeID: challenge.ID,
+		Challenge:   base64.RawURLEncoding.EncodeToString(challenge.Challenge),
+		ExpiresAt:   challenge.ExpiresAt.UTC().Format(time.RFC3339),
+		RPID:        c.auth.RelyingParty().ID,
+	})
+}
+
+// passkeyAssertion decodes an assertion, reporting its fields under prefix.
+func passkeyAssertion(fields *util.FieldErrors, prefix string, req dto.PasskeyAssertionRequest) domain.PasskeyAssertion {
+	fields.Required(prefix+"challenge_id", req.ChallengeID)
+	return domain.PasskeyAssertion{
+		ChallengeID:       req.ChallengeID,
+		CredentialID:      passkeyBytes(fields, prefix+"credential_id", req.CredentialID, true),
+		ClientDataJSON:    passkeyBytes(fields, prefix+"client_data_json", req.ClientDataJSON, true),
+		AuthenticatorData: passkeyBytes(fields, prefix+"authenticator_data", req.AuthenticatorData, true),
+		Signature:         passkeyBytes(fields, prefix+"signature", req.Signature, true),
+	}
+}
+
+func passkeyRegistration(req dto.PasskeyRegistrationRequest) (domain.PasskeyRegistration, util.FieldErrors) {
+	var fields util.FieldErrors
+	fields.Required("challenge_id", req.ChallengeID)
+	reg := domain.PasskeyRegistration{
+		ChallengeID:       req.ChallengeID,
+		CredentialID:      passkeyBytes(&fields, "credential_id", req.CredentialID, true),
+		ClientDataJSON:    passkeyBytes(&fields, "client_data_json", req.ClientDataJSON, true),
+		AuthenticatorData: passkeyBytes(&fields, "authenticator_data", req.AuthenticatorData, true),
+		PublicKey:         passkeyBytes(&fields, "public_key", req.PublicKey, true),
+		Name:              req.Name,
+		PRFSalt:           passkeyBytes(&fields, "prf_salt", req.PRFSalt, false),
+		WrappedKey:        passkeyBytes(&fields, "wrapped_key", req.WrappedKey, false),
+		WrapNonce:         passkeyBytes(&fields, "wrap_nonce", req.WrapNonce, false),
+	}
+	if len(req.Name) > maxPasskeyNameLength {
+		fields.Add("name", util.FieldInvalid, fmt.Sprintf("name must be at most %d characters", maxPasskeyNameLength))
+	}
+	prf := []bool{req.PRFSalt != "", req.WrappedKey != "", req.WrapNonce != ""}
+	if prf[0] != prf[1] || prf[1] != prf[2] {
+		fields.Add("wrapped_key", util.FieldInvalid, "prf_salt, wrapped_key and wrap_nonce must be sent together")
+	}
+	validateDeviceFingerprint(&fields, req.DeviceFingerprint)
+	return reg, fields
+}
+
+// passkeyBytes decodes a base64url field; padding is tolerated.
+func passkeyBytes(fields *util.FieldErrors, field, value string, required bool) []byte {
+	if value == "" {
+		if required {
+			fields.Required(field, value)
+		}
+		return nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		fields.Add(field, util.FieldInvalid, field+" must be base64url")
+		return nil
+	}
+	return raw
+}
+
+func validateDeviceFingerprint(fields *util.FieldErrors, fingerprint string) {
+	if len(fingerprint) > domain.MaxDeviceFingerprintLength {
+		fields.Add("device_fingerprint", util.FieldInvalid, fmt.Sprintf("device_fingerprint must be at most %d characters", domain.MaxDeviceFingerprintLength))
+	}
+}
+
+func passkeyLoginInput(r *http.Request, deviceName, fingerprint string) domain.LoginInput {
+	return domain.LoginInput{
+		DeviceName:        deviceName,
+		DeviceFingerprint: fingerprint,
+		IPAddr:            util.ClientIPFromRequest(r),
+		UserAgent:         r.UserAgent(),
+	}
+}
+
+func loginResponse(output domain.LoginOutput) dto.LoginResponse {
+	resp := dto.LoginResponse{
+		ExpiresAt:               output.ExpiresAt.UTC().Format(time.RFC3339),
+		UserID:                  output.UserID,
+		Email:                   output.Email,
+		Name:                    output.Name,
+		TOTPEnabled:             output.TOTPEnabled,
+		CredentialResetRequired: output.CredentialResetRequired,
+	}
+	if output.AccessToken != "" {
+		resp.AccessTokenExpiresAt = output.AccessTokenExpiresAt.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
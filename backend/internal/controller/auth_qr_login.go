@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/util"
+)
+
+// qrLoginPollInterval is how often a device waiting on a QR code sign-in is
+// asked to poll.
+const qrLoginPollInterval = 2 * time.Second
+
+// HandleQRLoginStart opens a sign-in request for a device that shows it as
+// a QR code to a device that is already signed in.
+func (c *AuthController) HandleQRLoginStart(w http.ResponseWriter, r *http.Request) {
+	var req dto.QRLoginStartRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+
+	start, err := c.auth.StartQRLogin(r.Context(), domain.LoginInput{
+		DeviceName: req.DeviceName,
+		IPAddr:     util.ClientIPFromRequest(r),
+		UserAgent:  r.UserAgent(),
+	})
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to start qr sign-in")
+		return
+	}
+	util.WriteJSON(w, http.StatusCreated, dto.QRLoginStartResponse{
+		RequestID:           start.ID,
+		Code:                start.Code,
+		PollToken:           start.PollToken,
+		ExpiresAt:           start.ExpiresAt.UTC().Format(time.RFC3339),
+		PollIntervalSeconds: int(qrLoginPollInterval / time.Second),
+	})
+}
+
+// HandleQRLoginPoll reports a request's status to the device that opened
+// it, and signs it in once the request is approved.
+func (c *AuthController) HandleQRLoginPoll(w http.ResponseWriter, r *http.Request) {
+	var req dto.QRLoginPollRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+	var fields util.FieldErrors
+	fields.Required("request_id", req.RequestID)
+	fields.Required("poll_token", req.PollToken)
+	validateDeviceFingerprint(&fields, req.DeviceFingerprint)
+	if len(fields) > 0 {
+		util.WriteFieldErrors(w, fields)
+		return
+	}
+
+	poll, err := c.auth.PollQRLogin(r.Context(), req.RequestID, req.PollToken, passkeyLoginInput(r, "", req.DeviceFingerprint))
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to poll qr sign-in")
+		return
+	}
+	resp := dto.QRLoginPollResponse{Status: string(poll.Status)}
+	if poll.Status == domain.QRLoginApproved {
+		c.setLoginCookies(w, poll.Login)
+		login := loginResponse(poll.Login)
+		resp.Login = &login
+	}
+	util.WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleQRLoginScan shows a signed-in user which device a scanned QR code
+// would let in.
+func (c *AuthController) HandleQRLoginScan(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	code, ok := readQRLoginCode(w, r)
+	if !ok {
+		return
+	}
+	req, err := c.auth.GetQRLogin(r.Context(), r.PathValue("request_id"), code)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to read qr sign-in")
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, dto.QRLoginResponse{
+		RequestID:  req.ID,
+		Status:     string(req.Status),
+		DeviceName: req.DeviceName,
+		IPAddress:  req.IPAddr,
+		UserAgent:  req.UserAgent,
+		CreatedAt:  req.CreatedAt.UTC().Format(time.RFC3339),
+		ExpiresAt:  req.ExpiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// HandleQRLoginApprove signs the requesting device in to the caller's
+// account on its next poll.
+func (c *AuthController) HandleQRLoginApprove(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	code, ok := readQRLoginCode(w, r)
+	if !ok {
+		return
+	}
+	if err := c.auth.ApproveQRLogin(r.Context(), session, r.PathValue("request_id"), code); err != nil {
+		writeServiceError(w, r, c.log, err, "failed to approve qr sign-in")
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, dto.StatusResponse{Status: string(domain.QRLoginApproved)})
+}
+
+func (c *AuthController) HandleQRLoginDeny(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	code, ok := readQRLoginCode(w, r)
+	if !ok {
+		return
+	}
+	if err := c.auth.DenyQRLogin(r.Context(), session, r.PathValue("request_id"), code); err != nil {
+		writeServiceError(w, r, c.log, err, "failed to deny qr sign-in")
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, dto.StatusResponse{Status: string(domain.QRLoginDenied)})
+}
+
+func readQRLoginCode(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req dto.QRLoginCodeRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return "", false
+	}
+	var fields util.FieldErrors
+	fields.Required("code", req.Code)
+	if len(fields) > 0 {
+		util.WriteFieldErrors(w, fields)
+		return "", false
+	}
+	return req.Code, true
+}
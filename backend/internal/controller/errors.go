@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/util"
+)
+
+// writeServiceError answers a failed service call. An error from the
+// domain catalog (domain.Error) is reported with its own code and message,
+// under the status its kind maps to. Anything else is unexpected: it is
+// logged with logArgs and answered with a 500 carrying message, which is
+// also the log message. Handlers only special-case errors whose response
+// differs from the catalog entry.
+func writeServiceError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, message string, logArgs ...any) {
+	if e, ok := domain.AsError(err); ok && e.Kind != domain.KindInternal {
+		if e.Kind == domain.KindUnavailable {
+			w.Header().Set("Retry-After", "1")
+		}
+		if !e.LockedUntil.IsZero() {
+			util.WriteLockedError(w, errorStatus(e.Kind), e.Code, e.Message, e.LockedUntil)
+			return
+		}
+		util.WriteError(w, errorStatus(e.Kind), e.Code, e.Message)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		util.WriteError(w, http.StatusGatewayTimeout, "timeout", "the request took too long to complete")
+		return
+	}
+	log.ErrorContext(r.Context(), message, append(logArgs, slog.Any("error", err))...)
+	util.WriteError(w, http.StatusInternalServerError, "internal_error", message)
+}
+
+func errorStatus(kind domain.ErrorKind) int {
+	switch kind {
+	case domain.KindInvalid:
+		return http.StatusBadRequest
+	case domain.KindUnauthorized:
+		return http.StatusUnauthorized
+	case domain.KindForbidden:
+		return http.StatusForbidden
+	case domain.KindNotFound:
+		return http.StatusNotFound
+	case domain.KindConflict:
+		return http.StatusConflict
+	case domain.KindRateLimited:
+		return http.StatusTooManyRequests
+	case domain.KindUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
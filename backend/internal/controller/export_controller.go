@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/service"
+	"pmv2/backend/internal/util"
+)
+
+type DataExportController struct {
+	exports *service.DataExportService
+	log     *slog.Logger
+}
+
+func NewDataExportController(exportService *service.DataExportService, logger *slog.Logger) *DataExportController {
+	return &DataExportController{exports: exportService, log: logger}
+}
+
+// HandleRequestExport queues an archive of the caller's data. Asking again
+// while one is being built returns that one.
+func (c *DataExportController) HandleRequestExport(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	export, err := c.exports.RequestExport(r.Context(), session.UserID)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to request data export")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusAccepted, dataExportToResponse(export))
+}
+
+func (c *DataExportController) HandleGetExport(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	exportID := strings.TrimSpace(r.PathValue("export_id"))
+	export, err := c.exports.GetExport(r.Context(), session.UserID, exportID)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to get data export")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, dataExportToResponse(export))
+}
+
+// HandleDownloadExport serves a ready archive as a zip attachment.
+func (c *DataExportController) HandleDownloadExport(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	exportID := strings.TrimSpace(r.PathValue("export_id"))
+	export, archive, err := c.exports.DownloadExport(r.Context(), session.UserID, exportID)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to download data export")
+		return
+	}
+
+	filename := "pmv2-export-" + export.CreatedAt.UTC().Format("20060102-150405") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(archive)
+}
+
+// HandleDiffExport compares the vault items in a ready export with the live
+// vault, to preview what restoring it would change.
+func (c *DataExportController) HandleDiffExport(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	exportID := strings.TrimSpace(r.PathValue("export_id"))
+	diff, err := c.exports.DiffExport(r.Context(), session.UserID, exportID)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to diff data export")
+		return
+	}
+
+	resp := dto.VaultBackupDiffResponse{
+		ExportID:  diff.ExportID,
+		TakenAt:   diff.TakenAt.UTC().Format(time.RFC3339),
+		Added:     vaultItemDiffsToResponse(diff.Added),
+		Removed:   vaultItemDiffsToResponse(diff.Removed),
+		Changed:   vaultItemDiffsToResponse(diff.Changed),
+		Unchanged: diff.Unchanged,
+	}
+	util.WriteJSON(w, http.StatusOK, resp)
+}
+
+func vaultItemDiffsToResponse(items []domain.VaultItemDiff) []dto.VaultItemDiffResponse {
+	resp := make([]dto.VaultItemDiffResponse, 0, len(items))
+	for _, item := range items {
+		resp = append(resp, dto.VaultItemDiffResponse(item))
+	}
+	return resp
+}
+
+func dataExportToResponse(e domain.DataExport) dto.DataExportResponse {
+	resp := dto.DataExportResponse{
+		ID:        e.ID,
+		Status:    e.Status,
+		SizeBytes: e.SizeBytes,
+		CreatedAt: e.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if e.CompletedAt != nil {
+		resp.CompletedAt = e.CompletedAt.UTC().Format(time.RFC3339)
+	}
+	if e.ExpiresAt != nil {
+		resp.ExpiresAt = e.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
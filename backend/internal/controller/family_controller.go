@@ -25,7 +25,7 @@ func NewFamilyController(familyService *service.FamilyService, logger *slog.Logg
 func (c *FamilyController) HandleSendRequest(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	var req dto.SendFamilyRequestInput
 	if err := util.ReadJSON(r, &req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+		util.WriteDecodeError(w, err)
 		return
 	}
 	if req.Email == "" {
@@ -46,7 +46,7 @@ func (c *FamilyController) HandleSendRequest(w http.ResponseWriter, r *http.Requ
 func (c *FamilyController) HandleAcceptRequest(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	var req dto.RespondFamilyRequestInput
 	if err := util.ReadJSON(r, &req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+		util.WriteDecodeError(w, err)
 		return
 	}
 	if req.UserID == "" {
@@ -67,7 +67,7 @@ func (c *FamilyController) HandleAcceptRequest(w http.ResponseWriter, r *http.Re
 func (c *FamilyController) HandleRejectRequest(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	var req dto.RespondFamilyRequestInput
 	if err := util.ReadJSON(r, &req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+		util.WriteDecodeError(w, err)
 		return
 	}
 	if req.UserID == "" {
@@ -157,24 +157,12 @@ func (c *FamilyController) HandleListRequests(w http.ResponseWriter, r *http.Req
 	util.WriteJSON(w, http.StatusOK, resp)
 }
 
+// writeFamilyError reports the generic not-found error as user_not_found:
+// family requests address users, and v1 clients match on that code.
 func (c *FamilyController) writeFamilyError(w http.ResponseWriter, r *http.Request, err error, defaultMessage string) {
-	switch {
-	case errors.Is(err, domain.ErrUnauthorizedSession):
-		util.WriteError(w, http.StatusUnauthorized, "unauthorized", "invalid or expired session")
-	case errors.Is(err, domain.ErrCannotAddSelf):
-		util.WriteError(w, http.StatusBadRequest, "cannot_add_self", "you cannot add yourself as a family member")
-	case errors.Is(err, domain.ErrAlreadyFamilyMember):
-		util.WriteError(w, http.StatusConflict, "already_family", "this user is already a family member")
-	case errors.Is(err, domain.ErrFamilyRequestAlreadySent):
-		util.WriteError(w, http.StatusConflict, "request_exists", "a family request already exists with this user")
-	case errors.Is(err, domain.ErrFamilyRequestNotFound):
-		util.WriteError(w, http.StatusNotFound, "request_not_found", "family request not found")
-	case errors.Is(err, domain.ErrNotFound):
+	if errors.Is(err, domain.ErrNotFound) {
 		util.WriteError(w, http.StatusNotFound, "user_not_found", "user not found")
-	case errors.Is(err, domain.ErrNotFamilyMember):
-		util.WriteError(w, http.StatusForbidden, "not_family_member", "you can only share with family members")
-	default:
-		c.log.ErrorContext(r.Context(), defaultMessage, slog.Any("error", err))
-		util.WriteError(w, http.StatusInternalServerError, "internal_error", defaultMessage)
+		return
 	}
+	writeServiceError(w, r, c.log, err, defaultMessage)
 }
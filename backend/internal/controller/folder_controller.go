@@ -25,7 +25,7 @@ func NewFolderController(folderService *service.FolderService, logger *slog.Logg
 func (c *FolderController) HandleCreateFolder(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	var req dto.CreateFolderRequest
 	if err := util.ReadJSON(r, &req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+		util.WriteDecodeError(w, err)
 		return
 	}
 
@@ -47,8 +47,7 @@ func (c *FolderController) HandleCreateFolder(w http.ResponseWriter, r *http.Req
 		Nonce:          nonce,
 	})
 	if err != nil {
-		c.log.ErrorContext(r.Context(), "create folder failed", slog.String("user_id", session.UserID), slog.Any("error", err))
-		util.WriteError(w, http.StatusInternalServerError, "internal_error", "failed to create folder")
+		writeServiceError(w, r, c.log, err, "failed to create folder", slog.String("user_id", session.UserID))
 		return
 	}
 
@@ -58,8 +57,7 @@ func (c *FolderController) HandleCreateFolder(w http.ResponseWriter, r *http.Req
 func (c *FolderController) HandleListFolders(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	folders, err := c.folders.ListFolders(r.Context(), session.UserID)
 	if err != nil {
-		c.log.ErrorContext(r.Context(), "list folders failed", slog.String("user_id", session.UserID), slog.Any("error", err))
-		util.WriteError(w, http.StatusInternalServerError, "internal_error", "failed to list folders")
+		writeServiceError(w, r, c.log, err, "failed to list folders", slog.String("user_id", session.UserID))
 		return
 	}
 
@@ -75,7 +73,7 @@ func (c *FolderController) HandleUpdateFolder(w http.ResponseWriter, r *http.Req
 
 	var req dto.CreateFolderRequest
 	if err := util.ReadJSON(r, &req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+		util.WriteDecodeError(w, err)
 		return
 	}
 
@@ -93,8 +91,7 @@ func (c *FolderController) HandleUpdateFolder(w http.ResponseWriter, r *http.Req
 
 	folder, err := c.folders.UpdateFolder(r.Context(), session.UserID, folderID, nameCiphertext, nonce)
 	if err != nil {
-		c.log.ErrorContext(r.Context(), "update folder failed", slog.String("user_id", session.UserID), slog.String("folder_id", folderID), slog.Any("error", err))
-		util.WriteError(w, http.StatusInternalServerError, "internal_error", "failed to update folder")
+		writeServiceError(w, r, c.log, err, "failed to update folder", slog.String("user_id", session.UserID), slog.String("folder_id", folderID))
 		return
 	}
 
@@ -104,8 +101,7 @@ func (c *FolderController) HandleUpdateFolder(w http.ResponseWriter, r *http.Req
 func (c *FolderController) HandleDeleteFolder(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	folderID := strings.TrimSpace(r.PathValue("folder_id"))
 	if err := c.folders.DeleteFolder(r.Context(), session.UserID, folderID); err != nil {
-		c.log.ErrorContext(r.Context(), "delete folder failed", slog.String("user_id", session.UserID), slog.String("folder_id", folderID), slog.Any("error", err))
-		util.WriteError(w, http.StatusInternalServerError, "internal_error", "failed to delete folder")
+		writeServiceError(w, r, c.log, err, "failed to delete folder", slog.String("user_id", session.UserID), slog.String("folder_id", folderID))
 		return
 	}
 
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/service"
+	"pmv2/backend/internal/util"
+)
+
+type IPBanController struct {
+	bans *service.IPBanService
+	log  *slog.Logger
+}
+
+func NewIPBanController(ipBanService *service.IPBanService, logger *slog.Logger) *IPBanController {
+	return &IPBanController{bans: ipBanService, log: logger}
+}
+
+// HandleListBans lists the addresses banned right now, longest ban first.
+func (c *IPBanController) HandleListBans(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	bans, err := c.bans.ListBans(r.Context())
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to list ip bans")
+		return
+	}
+
+	resp := dto.IPBansResponse{Bans: make([]dto.IPBanResponse, 0, len(bans))}
+	for _, b := range bans {
+		resp.Bans = append(resp.Bans, dto.IPBanResponse{
+			IP:          b.IP,
+			Offenses:    b.Offenses,
+			Reason:      b.Reason,
+			BannedUntil: b.BannedUntil.UTC().Format(time.RFC3339),
+			CreatedAt:   b.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	util.WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleLiftBan unbans an address and clears its offense history.
+func (c *IPBanController) HandleLiftBan(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	if err := c.bans.LiftBan(r.Context(), session.UserID, strings.TrimSpace(r.PathValue("ip"))); err != nil {
+		writeServiceError(w, r, c.log, err, "failed to lift ip ban")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, dto.StatusResponse{Status: "lifted"})
+}
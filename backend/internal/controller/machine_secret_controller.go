@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/service"
+	"pmv2/backend/internal/util"
+)
+
+type MachineSecretController struct {
+	secrets *service.MachineSecretService
+	log     *slog.Logger
+}
+
+func NewMachineSecretController(secretService *service.MachineSecretService, logger *slog.Logger) *MachineSecretController {
+	return &MachineSecretController{secrets: secretService, log: logger}
+}
+
+// HandlePublishSecret publishes a vault item for CI pipelines, or replaces
+// the copy published before.
+func (c *MachineSecretController) HandlePublishSecret(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var req dto.PublishMachineSecretRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+
+	var fields util.FieldErrors
+	fields.Required("name", req.Name)
+	decode := func(name, value string) []byte {
+		raw, err := decodeBase64Required(value)
+		switch {
+		case errors.Is(err, errEmptyBase64):
+			fields.Add(name, util.FieldRequired, name+" is required")
+		case err != nil:
+			fields.Add(name, util.FieldInvalid, name+" must be non-empty standard base64")
+		}
+		return raw
+	}
+	ciphertext := decode("ciphertext", req.Ciphertext)
+	nonce := decode("nonce", req.Nonce)
+	fields.Required("algo_version", req.AlgoVersion)
+	if len(fields) > 0 {
+		util.WriteFieldErrors(w, fields)
+		return
+	}
+
+	secret, err := c.secrets.PublishSecret(r.Context(), session.UserID, r.PathValue("item_id"), req.Name, ciphertext, nonce, req.AlgoVersion)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to publish machine secret", slog.String("user_id", session.UserID))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, machineSecretToResponse(secret, false))
+}
+
+func (c *MachineSecretController) HandleUnpublishSecret(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	if err := c.secrets.UnpublishSecret(r.Context(), session.UserID, r.PathValue("item_id")); err != nil {
+		writeServiceError(w, r, c.log, err, "failed to unpublish machine secret", slog.String("user_id", session.UserID))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, dto.StatusResponse{Status: "unpublished"})
+}
+
+func (c *MachineSecretController) HandleListSecrets(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	secrets, err := c.secrets.ListSecrets(r.Context(), session.UserID)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to list machine secrets", slog.String("user_id", session.UserID))
+		return
+	}
+
+	resp := dto.MachineSecretsResponse{Secrets: make([]dto.MachineSecretResponse, 0, len(secrets))}
+	for _, s := range secrets {
+		resp.Secrets = append(resp.Secrets, machineSecretToResponse(s, false))
+	}
+	util.WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleCreateKey hands out an API key for a service account. The key is
+// returned once, in this response.
+func (c *MachineSecretController) HandleCreateKey(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var req dto.CreateMachineKeyRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+	if req.ExpiresInDays < 0 {
+		var fields util.FieldErrors
+		fields.Add("expires_in_days", util.FieldInvalid, "expires_in_days must not be negative")
+		util.WriteFieldErrors(w, fields)
+		return
+	}
+
+	key, err := c.secrets.CreateKey(r.Context(), session.UserID, req.Name, req.Scopes, time.Duration(req.ExpiresInDays)*24*time.Hour)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to create api key", slog.String("user_id", session.UserID))
+		return
+	}
+
+	resp := machineKeyToResponse(key)
+	resp.Key = key.Key
+	w.Header().Set("Cache-Control", "no-store")
+	util.WriteJSON(w, http.StatusCreated, resp)
+}
+
+func (c *MachineSecretController) HandleListKeys(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	keys, err := c.secrets.ListKeys(r.Context(), session.UserID)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to list api keys", slog.String("user_id", session.UserID))
+		return
+	}
+
+	resp := dto.MachineKeysResponse{Keys: make([]dto.MachineKeyResponse, 0, len(keys))}
+	for _, k := range keys {
+		resp.Keys = append(resp.Keys, machineKeyToResponse(k))
+	}
+	util.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (c *MachineSecretController) HandleDeleteKey(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	if err := c.secrets.DeleteKey(r.Context(), session.UserID, r.PathValue("key_id")); err != nil {
+		writeServiceError(w, r, c.log, err, "failed to delete api key", slog.String("user_id", session.UserID))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, dto.StatusResponse{Status: "deleted"})
+}
+
+// HandleReadSecret serves a published secret to a service account. It
+// takes no session: the caller authenticates with its API key as a bearer
+// token.
+func (c *MachineSecretController) HandleReadSecret(w http.ResponseWriter, r *http.Request) {
+	key := util.BearerToken(r.Header.Get("Authorization"))
+	if key == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="secrets"`)
+		util.WriteError(w, http.StatusUnauthorized, domain.ErrInvalidMachineKey.Code, domain.ErrInvalidMachineKey.Message)
+		return
+	}
+
+	secret, err := c.secrets.ReadSecret(r.Context(), key, r.PathValue("name"))
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to read machine secret")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	util.WriteJSON(w, http.StatusOK, machineSecretToResponse(secret, true))
+}
+
+func machineSecretToResponse(s domain.MachineSecret, withValue bool) dto.MachineSecretResponse {
+	resp := dto.MachineSecretResponse{
+		Name:        s.Name,
+		AlgoVersion: s.AlgoVersion,
+		UpdatedAt:   s.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+	if withValue {
+		resp.Ciphertext = encodeBase64(s.Ciphertext)
+		resp.Nonce = encodeBase64(s.Nonce)
+	} else {
+		resp.ItemID = s.ItemID
+	}
+	return resp
+}
+
+func machineKeyToResponse(k domain.MachineKey) dto.MachineKeyResponse {
+	resp := dto.MachineKeyResponse{
+		ID:        k.ID,
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Scopes:    k.Scopes,
+		CreatedAt: k.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if k.ExpiresAt != nil {
+		resp.ExpiresAt = k.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if k.LastUsedAt != nil {
+		resp.LastUsedAt = k.LastUsedAt.UTC().Format(time.RFC3339)
+	}
+	if resp.Scopes == nil {
+		resp.Scopes = []string{}
+	}
+	return resp
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/service"
+	"pmv2/backend/internal/util"
+)
+
+type NotificationController struct {
+	notifications *service.NotificationService
+	log           *slog.Logger
+}
+
+func NewNotificationController(notificationService *service.NotificationService, logger *slog.Logger) *NotificationController {
+	return &NotificationController{notifications: notificationService, log: logger}
+}
+
+func (c *NotificationController) HandleGetPreferences(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	prefs, err := c.notifications.GetPreferences(r.Context(), session.UserID)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to load notification preferences", slog.String("user_id", session.UserID))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, notificationPreferencesToResponse(prefs))
+}
+
+// HandleUpdatePreferences applies a partial update; categories and fields
+// left out of the body keep their current values.
+func (c *NotificationController) HandleUpdatePreferences(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var req dto.UpdateNotificationPreferencesRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+
+	patch := make(map[domain.NotificationCategory]domain.NotificationPreferencePatch, len(req.Categories))
+	for category, change := range req.Categories {
+		p := domain.NotificationPreferencePatch{Enabled: change.Enabled}
+		if change.Channels != nil {
+			p.Channels = make([]domain.NotificationChannel, 0, len(*change.Channels))
+			for _, channel := range *change.Channels {
+				p.Channels = append(p.Channels, domain.NotificationChannel(channel))
+			}
+		}
+		patch[domain.NotificationCategory(category)] = p
+	}
+
+	prefs, err := c.notifications.UpdatePreferences(r.Context(), session.UserID, patch)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to update notification preferences", slog.String("user_id", session.UserID))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, notificationPreferencesToResponse(prefs))
+}
+
+func notificationPreferencesToResponse(prefs domain.NotificationPreferences) dto.NotificationPreferencesResponse {
+	resp := dto.NotificationPreferencesResponse{
+		Categories: make(map[string]dto.CategoryPreferenceResponse, len(prefs.Categories)),
+	}
+	for category, pref := range prefs.Categories {
+		channels := make([]string, 0, len(pref.Channels))
+		for _, channel := range pref.Channels {
+			channels = append(channels, string(channel))
+		}
+		resp.Categories[string(category)] = dto.CategoryPreferenceResponse{Enabled: pref.Enabled, Channels: channels}
+	}
+	if !prefs.UpdatedAt.IsZero() {
+		resp.UpdatedAt = prefs.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/service"
+	"pmv2/backend/internal/util"
+)
+
+type PushController struct {
+	push *service.PushService
+	log  *slog.Logger
+}
+
+func NewPushController(pushService *service.PushService, logger *slog.Logger) *PushController {
+	return &PushController{push: pushService, log: logger}
+}
+
+// HandleRegisterToken ties the app's push token to the current session's
+// user and device. Registering a known token again refreshes it.
+func (c *PushController) HandleRegisterToken(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var req dto.RegisterPushTokenRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+
+	token, err := c.push.RegisterToken(r.Context(), session, domain.PushPlatform(req.Platform), req.Token)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to register push token", slog.String("user_id", session.UserID))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, pushTokenToResponse(token, session))
+}
+
+func (c *PushController) HandleListTokens(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	tokens, err := c.push.ListTokens(r.Context(), session.UserID)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to list push tokens", slog.String("user_id", session.UserID))
+		return
+	}
+
+	resp := dto.PushTokensResponse{Tokens: make([]dto.PushTokenResponse, 0, len(tokens))}
+	for _, t := range tokens {
+		resp.Tokens = append(resp.Tokens, pushTokenToResponse(t, session))
+	}
+	util.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (c *PushController) HandleRemoveToken(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	if err := c.push.RemoveToken(r.Context(), session.UserID, r.PathValue("token_id")); err != nil {
+		writeServiceError(w, r, c.log, err, "failed to remove push token", slog.String("user_id", session.UserID))
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, dto.StatusResponse{Status: "removed"})
+}
+
+func pushTokenToResponse(t domain.PushToken, session domain.Session) dto.PushTokenResponse {
+	return dto.PushTokenResponse{
+		ID:        t.ID,
+		Platform:  string(t.Platform),
+		DeviceID:  t.DeviceID,
+		Current:   t.DeviceID != "" && t.DeviceID == session.DeviceID,
+		CreatedAt: t.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt: t.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/service"
+	"pmv2/backend/internal/util"
+)
+
+type ShareInvitationController struct {
+	invitations *service.ShareInvitationService
+	log         *slog.Logger
+}
+
+func NewShareInvitationController(shareInvitationService *service.ShareInvitationService, logger *slog.Logger) *ShareInvitationController {
+	return &ShareInvitationController{invitations: shareInvitationService, log: logger}
+}
+
+// HandleInvite invites an email without an account to a share of an item.
+func (c *ShareInvitationController) HandleInvite(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var req dto.ShareInvitationRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+
+	var fields util.FieldErrors
+	fields.Email("email", req.Email)
+	permissions := strings.TrimSpace(req.Permissions)
+	switch permissions {
+	case "", domain.SharePermissionRead, domain.SharePermissionManage:
+	default:
+		fields.Add("permissions", util.FieldInvalid, "permissions must be read or manage")
+	}
+	if len(fields) > 0 {
+		util.WriteFieldErrors(w, fields)
+		return
+	}
+
+	inv, err := c.invitations.Invite(r.Context(), session.UserID, r.PathValue("item_id"), req.Email, permissions)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to invite to share", slog.String("user_id", session.UserID))
+		return
+	}
+	util.WriteJSON(w, http.StatusCreated, shareInvitationToResponse(inv))
+}
+
+// HandleList lists the invitations the user sent that are still open.
+func (c *ShareInvitationController) HandleList(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	invitations, err := c.invitations.List(r.Context(), session.UserID)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to list share invitations", slog.String("user_id", session.UserID))
+		return
+	}
+	resp := dto.ShareInvitationsResponse{Invitations: make([]dto.ShareInvitationResponse, 0, len(invitations))}
+	for _, inv := range invitations {
+		resp.Invitations = append(resp.Invitations, shareInvitationToResponse(inv))
+	}
+	util.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (c *ShareInvitationController) HandleCancel(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	if err := c.invitations.Cancel(r.Context(), session.UserID, r.PathValue("invitation_id")); err != nil {
+		writeServiceError(w, r, c.log, err, "failed to cancel share invitation", slog.String("user_id", session.UserID))
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, dto.StatusResponse{Status: "cancelled"})
+}
+
+// HandleComplete shares the item with the registered invitee using the item
+// key the sharer's client wrapped for them.
+func (c *ShareInvitationController) HandleComplete(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var req dto.CompleteShareInvitationRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+
+	var fields util.FieldErrors
+	decode := func(name, value string) []byte {
+		raw, err := decodeBase64Required(value)
+		switch {
+		case errors.Is(err, errEmptyBase64):
+			fields.Add(name, util.FieldRequired, name+" is required")
+		case err != nil:
+			fields.Add(name, util.FieldInvalid, name+" must be non-empty standard base64")
+		}
+		return raw
+	}
+	wrappedDEK := decode("wrapped_dek", req.WrappedDEK)
+	wrapNonce := decode("wrap_nonce", req.WrapNonce)
+	window, windowFields := parseShareWindow(req.ShareWindow)
+	fields = append(fields, windowFields...)
+	if len(fields) > 0 {
+		util.WriteFieldErrors(w, fields)
+		return
+	}
+
+	inv, err := c.invitations.Complete(r.Context(), session.UserID, r.PathValue("invitation_id"), wrappedDEK, wrapNonce, window)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to complete share invitation", slog.String("user_id", session.UserID))
+		return
+	}
+	util.WriteJSON(w, http.StatusCreated, dto.ShareItemResponse{
+		ItemID:         inv.ItemID,
+		RecipientID:    inv.RecipientUserID,
+		SharedByUserID: session.UserID,
+		Permissions:    inv.Permissions,
+		Status:         "shared",
+	})
+}
+
+func shareInvitationToResponse(inv domain.ShareInvitation) dto.ShareInvitationResponse {
+	resp := dto.ShareInvitationResponse{
+		ID:              inv.ID,
+		ItemID:          inv.ItemID,
+		Email:           inv.Email,
+		Permissions:     inv.Permissions,
+		Status:          string(inv.Status),
+		RecipientUserID: inv.RecipientUserID,
+		ExpiresAt:       inv.ExpiresAt.UTC().Format(time.RFC3339),
+		CreatedAt:       inv.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if inv.ReadyAt != nil {
+		resp.ReadyAt = inv.ReadyAt.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strings"
@@ -23,11 +24,12 @@ func NewSharingController(sharingService *service.SharingService, logger *slog.L
 	return &SharingController{sharing: sharingService, log: logger}
 }
 
-// HandleUpsertKeys stores or updates the user's X25519 key pair.
+// HandleUpsertKeys stores or replaces the user's X25519 key pair and,
+// optionally, their Ed25519 signing key.
 func (c *SharingController) HandleUpsertKeys(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	var req dto.UpsertUserKeysRequest
 	if err := util.ReadJSON(r, &req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+		util.WriteDecodeError(w, err)
 		return
 	}
 
@@ -46,9 +48,15 @@ func (c *SharingController) HandleUpsertKeys(w http.ResponseWriter, r *http.Requ
 		util.WriteError(w, http.StatusBadRequest, "invalid_payload", "invalid nonce")
 		return
 	}
+	signingKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(req.PublicKeyEd25519))
+	if err != nil {
+		util.WriteError(w, http.StatusBadRequest, "invalid_payload", "invalid public_key_ed25519")
+		return
+	}
 
 	err = c.sharing.UpsertUserKeys(r.Context(), session.UserID, domain.UpsertUserKeysInput{
 		PublicKeyX25519:      publicKey,
+		PublicKeyEd25519:     signingKey,
 		EncryptedPrivateKeys: encPriv,
 		Nonce:                nonce,
 	})
@@ -74,13 +82,15 @@ func (c *SharingController) HandleGetMyKeys(w http.ResponseWriter, r *http.Reque
 
 	util.WriteJSON(w, http.StatusOK, dto.UserKeysResponse{
 		PublicKeyX25519:      base64.StdEncoding.EncodeToString(keys.PublicKeyX25519),
+		PublicKeyEd25519:     base64.StdEncoding.EncodeToString(keys.PublicKeyEd25519),
 		EncryptedPrivateKeys: base64.StdEncoding.EncodeToString(keys.EncryptedPrivateKeys),
 		Nonce:                base64.StdEncoding.EncodeToString(keys.Nonce),
 		HasKeys:              true,
 	})
 }
 
-// HandleGetPublicKey returns the public key for a user identified by email.
+// HandleGetPublicKey returns the public keys of a user identified by email,
+// for encrypting shares to them.
 func (c *SharingController) HandleGetPublicKey(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	email := strings.TrimSpace(r.URL.Query().Get("email"))
 	if email == "" {
@@ -95,9 +105,27 @@ func (c *SharingController) HandleGetPublicKey(w http.ResponseWriter, r *http.Re
 	}
 
 	util.WriteJSON(w, http.StatusOK, dto.UserPublicKeyResponse{
-		UserID:          userID,
-		Email:           email,
-		PublicKeyX25519: base64.StdEncoding.EncodeToString(keys.PublicKeyX25519),
+		UserID:           userID,
+		Email:            email,
+		PublicKeyX25519:  base64.StdEncoding.EncodeToString(keys.PublicKeyX25519),
+		PublicKeyEd25519: base64.StdEncoding.EncodeToString(keys.PublicKeyEd25519),
+	})
+}
+
+// HandleGetKeyFingerprint returns a user's key fingerprint and, for
+// another user, the safety number to compare with them.
+func (c *SharingController) HandleGetKeyFingerprint(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	fp, err := c.sharing.GetKeyFingerprint(r.Context(), session.UserID, r.PathValue("user_id"))
+	if err != nil {
+		c.writeSharingError(w, r, err, "failed to get key fingerprint")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, dto.KeyFingerprintResponse{
+		UserID:       fp.UserID,
+		Fingerprint:  fp.Fingerprint,
+		SafetyNumber: fp.SafetyNumber,
+		UpdatedAt:    fp.UpdatedAt.UTC().Format(time.RFC3339),
 	})
 }
 
@@ -111,7 +139,7 @@ func (c *SharingController) HandleShareItem(w http.ResponseWriter, r *http.Reque
 
 	var req dto.ShareItemRequest
 	if err := util.ReadJSON(r, &req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+		util.WriteDecodeError(w, err)
 		return
 	}
 
@@ -143,12 +171,18 @@ func (c *SharingController) HandleShareItem(w http.ResponseWriter, r *http.Reque
 	if permissions == "" {
 		permissions = "read"
 	}
+	window, fields := parseShareWindow(req.ShareWindow)
+	if len(fields) > 0 {
+		util.WriteFieldErrors(w, fields)
+		return
+	}
 
 	err = c.sharing.ShareItem(r.Context(), session.UserID, itemID, domain.ShareItemInput{
 		RecipientID: recipientID,
 		DEKWrapped:  wrappedDEK,
 		WrapNonce:   wrapNonce,
 		Permissions: permissions,
+		Window:      window,
 	})
 	if err != nil {
 		c.writeSharingError(w, r, err, "failed to share item")
@@ -182,6 +216,37 @@ func (c *SharingController) HandleRevokeShare(w http.ResponseWriter, r *http.Req
 	util.WriteJSON(w, http.StatusOK, dto.StatusResponse{Status: "revoked"})
 }
 
+// HandleUpdateSharePermissions changes what a recipient may do with their
+// share of an item.
+func (c *SharingController) HandleUpdateSharePermissions(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	itemID := strings.TrimSpace(r.PathValue("item_id"))
+	recipientUserID := strings.TrimSpace(r.PathValue("user_id"))
+	if itemID == "" || recipientUserID == "" {
+		util.WriteError(w, http.StatusBadRequest, "missing_params", "item_id and user_id are required")
+		return
+	}
+	var req dto.UpdateSharePermissionsRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+	permissions := strings.TrimSpace(req.Permissions)
+
+	err := c.sharing.UpdateSharePermissions(r.Context(), session.UserID, itemID, recipientUserID, permissions)
+	if err != nil {
+		c.writeSharingError(w, r, err, "failed to update share permissions")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, dto.ShareItemResponse{
+		ItemID:         itemID,
+		RecipientID:    recipientUserID,
+		SharedByUserID: session.UserID,
+		Permissions:    permissions,
+		Status:         "updated",
+	})
+}
+
 // HandleListSharedWithMe returns all items shared with the current user.
 func (c *SharingController) HandleListSharedWithMe(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	items, err := c.sharing.ListSharedWithMe(r.Context(), session.UserID)
@@ -208,6 +273,7 @@ func (c *SharingController) HandleListSharedWithMe(w http.ResponseWriter, r *htt
 			SharedByEmail:   si.SharedByEmail,
 			SharedByName:    si.SharedByName,
 			Permissions:     si.Permissions,
+			ShareWindow:     shareWindowToDTO(si.ShareWindow),
 		})
 	}
 
@@ -230,16 +296,70 @@ func (c *SharingController) HandleListSharesForItem(w http.ResponseWriter, r *ht
 
 	resp := dto.ShareRecipientsResponse{Shares: make([]dto.ShareRecipientResponse, 0, len(shares))}
 	for _, s := range shares {
-		resp.Shares = append(resp.Shares, dto.ShareRecipientResponse{
+		share := dto.ShareRecipientResponse{
 			UserID:      s.UserID,
 			Permissions: s.Permissions,
 			CreatedAt:   s.CreatedAt.UTC().Format(time.RFC3339),
-		})
+			ShareWindow: shareWindowToDTO(s.Window),
+		}
+		if s.FirstAccessedAt != nil {
+			share.FirstAccessedAt = s.FirstAccessedAt.UTC().Format(time.RFC3339)
+		}
+		resp.Shares = append(resp.Shares, share)
 	}
 
 	util.WriteJSON(w, http.StatusOK, resp)
 }
 
+// HandleGetAccessAlert reports whether the owner is alerted when a recipient
+// first accesses the item.
+func (c *SharingController) HandleGetAccessAlert(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	itemID := strings.TrimSpace(r.PathValue("item_id"))
+	alert, err := c.sharing.GetAccessAlert(r.Context(), session.UserID, itemID)
+	if errors.Is(err, domain.ErrNotFound) {
+		util.WriteJSON(w, http.StatusOK, dto.AccessAlertResponse{ItemID: itemID})
+		return
+	}
+	if err != nil {
+		c.writeSharingError(w, r, err, "failed to get access alert")
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, accessAlertToResponse(alert))
+}
+
+// HandleSetAccessAlert turns on access alerts for the item.
+func (c *SharingController) HandleSetAccessAlert(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var req dto.AccessAlertRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+
+	alert, err := c.sharing.SetAccessAlert(r.Context(), session.UserID, strings.TrimSpace(r.PathValue("item_id")), req.Email)
+	if err != nil {
+		c.writeSharingError(w, r, err, "failed to set access alert")
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, accessAlertToResponse(alert))
+}
+
+func (c *SharingController) HandleDeleteAccessAlert(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	if err := c.sharing.DeleteAccessAlert(r.Context(), session.UserID, strings.TrimSpace(r.PathValue("item_id"))); err != nil {
+		c.writeSharingError(w, r, err, "failed to delete access alert")
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, dto.StatusResponse{Status: "deleted"})
+}
+
+func accessAlertToResponse(alert domain.ItemAccessAlert) dto.AccessAlertResponse {
+	return dto.AccessAlertResponse{
+		ItemID:    alert.ItemID,
+		Enabled:   true,
+		Email:     alert.Email,
+		CreatedAt: alert.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
 // HandleListSentShares returns all shares created by the current user.
 func (c *SharingController) HandleListSentShares(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	shares, err := c.sharing.ListSentShares(r.Context(), session.UserID)
@@ -264,28 +384,102 @@ func (c *SharingController) HandleListSentShares(w http.ResponseWriter, r *http.
 	util.WriteJSON(w, http.StatusOK, resp)
 }
 
+// writeSharingError keeps the code sharing used for a bad payload before
+// the error catalog, which v1 clients may match on.
 func (c *SharingController) writeSharingError(w http.ResponseWriter, r *http.Request, err error, defaultMessage string) {
-	switch {
-	case errors.Is(err, domain.ErrUnauthorizedSession):
-		util.WriteError(w, http.StatusUnauthorized, "unauthorized", "invalid or expired session")
-	case errors.Is(err, domain.ErrInvalidVaultPayload):
+	if errors.Is(err, domain.ErrInvalidVaultPayload) {
 		util.WriteError(w, http.StatusBadRequest, "invalid_payload", "invalid vault payload")
-	case errors.Is(err, domain.ErrNotFound):
-		util.WriteError(w, http.StatusNotFound, "not_found", "resource not found")
-	case errors.Is(err, domain.ErrNotItemOwner):
-		util.WriteError(w, http.StatusForbidden, "not_owner", "only the item owner can perform this action")
-	case errors.Is(err, domain.ErrCannotShareWithSelf):
-		util.WriteError(w, http.StatusBadRequest, "cannot_share_self", "you cannot share an item with yourself")
-	case errors.Is(err, domain.ErrAlreadyShared):
-		util.WriteError(w, http.StatusConflict, "already_shared", "item is already shared with this user")
-	case errors.Is(err, domain.ErrRecipientKeysNotFound):
-		util.WriteError(w, http.StatusNotFound, "recipient_keys_not_found", "recipient has not set up encryption keys")
-	case errors.Is(err, domain.ErrShareNotFound):
-		util.WriteError(w, http.StatusNotFound, "share_not_found", "share not found")
-	case errors.Is(err, domain.ErrNotFamilyMember):
-		util.WriteError(w, http.StatusForbidden, "not_family_member", "you can only share with family members")
-	default:
-		c.log.ErrorContext(r.Context(), defaultMessage, slog.Any("error", err))
-		util.WriteError(w, http.StatusInternalServerError, "internal_error", defaultMessage)
+		return
+	}
+	writeServiceError(w, r, c.log, err, defaultMessage)
+}
+
+var shareWeekdays = [...]string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// parseShareWindow decodes the optional window of a share request. The
+// service checks that it makes sense; this only checks the formats.
+func parseShareWindow(req dto.ShareWindow) (domain.ShareWindow, util.FieldErrors) {
+	var window domain.ShareWindow
+	var fields util.FieldErrors
+	parseTime := func(field, value string) *time.Time {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			fields.Add(field, util.FieldInvalid, field+" must be an RFC 3339 timestamp")
+			return nil
+		}
+		return &t
+	}
+	window.NotBefore = parseTime("not_before", req.NotBefore)
+	window.ExpiresAt = parseTime("expires_at", req.ExpiresAt)
+
+	if req.Schedule == nil {
+		return window, fields
+	}
+	schedule := &domain.ShareSchedule{Timezone: req.Schedule.Timezone}
+	if len(req.Schedule.Days) == 0 {
+		fields.Add("schedule.days", util.FieldRequired, "schedule.days is required")
+	}
+	for _, name := range req.Schedule.Days {
+		day := -1
+		for i, weekday := range shareWeekdays {
+			if strings.EqualFold(strings.TrimSpace(name), weekday) {
+				day = i
+			}
+		}
+		if day < 0 {
+			fields.Add("schedule.days", util.FieldInvalid, "schedule.days must be names like mon, tue or sun")
+			break
+		}
+		schedule.Days = append(schedule.Days, time.Weekday(day))
+	}
+	var ok bool
+	if schedule.StartMinute, ok = parseClockMinute(req.Schedule.Start); !ok || schedule.StartMinute == 24*60 {
+		fields.Add("schedule.start", util.FieldInvalid, "schedule.start must be a time like 09:00")
+	}
+	if schedule.EndMinute, ok = parseClockMinute(req.Schedule.End); !ok {
+		fields.Add("schedule.end", util.FieldInvalid, "schedule.end must be a time like 18:00")
+	}
+	window.Schedule = schedule
+	return window, fields
+}
+
+// parseClockMinute parses "HH:MM" into minutes after midnight, allowing
+// "24:00" for the end of the day.
+func parseClockMinute(value string) (int, bool) {
+	value = strings.TrimSpace(value)
+	if value == "24:00" {
+		return 24 * 60, true
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+func shareWindowToDTO(window domain.ShareWindow) dto.ShareWindow {
+	var out dto.ShareWindow
+	if window.NotBefore != nil {
+		out.NotBefore = window.NotBefore.UTC().Format(time.RFC3339)
+	}
+	if window.ExpiresAt != nil {
+		out.ExpiresAt = window.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if s := window.Schedule; s != nil {
+		days := make([]string, 0, len(s.Days))
+		for _, day := range s.Days {
+			days = append(days, shareWeekdays[day%7])
+		}
+		out.Schedule = &dto.ShareSchedule{
+			Days:     days,
+			Start:    fmt.Sprintf("%02d:%02d", s.StartMinute/60, s.StartMinute%60),
+			End:      fmt.Sprintf("%02d:%02d", s.EndMinute/60, s.EndMinute%60),
+			Timezone: s.Timezone,
+		}
 	}
+	return out
 }
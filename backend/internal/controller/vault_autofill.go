@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/util"
+)
+
+// handleListCompactItems lists the active items without their ciphertext,
+// with the URI HMACs they match on and their usage. Usage changes without
+// changing the vault revision, so there is no ETag.
+func (c *VaultController) handleListCompactItems(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	items, err := c.vault.ListAutofillItems(r.Context(), session.UserID)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to list vault items")
+		return
+	}
+
+	resp := dto.CompactVaultItemsResponse{Items: make([]dto.CompactVaultItemResponse, 0, len(items))}
+	for _, item := range items {
+		hmacs := make([]string, 0, len(item.URIHMACs))
+		for _, hmac := range item.URIHMACs {
+			hmacs = append(hmacs, encodeBase64(hmac))
+		}
+		resp.Items = append(resp.Items, dto.CompactVaultItemResponse{
+			ID:          item.ID,
+			FolderID:    item.FolderID,
+			AlgoVersion: item.AlgoVersion,
+			Metadata:    item.Metadata,
+			IsShared:    item.IsShared,
+			Version:     item.Version,
+			URIHMACs:    hmacs,
+			UseCount:    item.Usage.UseCount,
+			LastUsedAt:  formatOptionalTime(item.Usage.LastUsedAt),
+			UpdatedAt:   item.UpdatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	util.WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleMatchItems returns the items carrying any of the URI HMACs the
+// extension computed for the page it is on, most recently used first.
+func (c *VaultController) HandleMatchItems(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var req dto.MatchVaultItemsRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+
+	hmacs, fields := parseURIHMACs("uri_hmacs", req.URIHMACs)
+	if len(hmacs) == 0 && len(fields) == 0 {
+		fields.Add("uri_hmacs", util.FieldRequired, "uri_hmacs is required")
+	}
+	if len(fields) > 0 {
+		util.WriteValidationError(w, domain.ErrInvalidURIHMAC.Code, domain.ErrInvalidURIHMAC.Message, fields)
+		return
+	}
+
+	items, err := c.vault.MatchItems(r.Context(), session.UserID, hmacs)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to match vault items")
+		return
+	}
+
+	resp := dto.MatchedVaultItemsResponse{Items: make([]dto.MatchedVaultItemResponse, 0, len(items))}
+	for _, item := range items {
+		resp.Items = append(resp.Items, dto.MatchedVaultItemResponse{
+			VaultItemResponse: vaultItemToResponse(item.VaultItem),
+			UseCount:          item.Usage.UseCount,
+			LastUsedAt:        formatOptionalTime(item.Usage.LastUsedAt),
+		})
+	}
+	util.WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleRecordItemUse counts one autofill of an item.
+func (c *VaultController) HandleRecordItemUse(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	itemID := strings.TrimSpace(r.PathValue("item_id"))
+	usage, err := c.vault.RecordItemUse(r.Context(), session.UserID, itemID)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to record vault item use")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, dto.ItemUsageResponse{
+		ItemID:     usage.ItemID,
+		UseCount:   usage.UseCount,
+		LastUsedAt: formatOptionalTime(usage.LastUsedAt),
+	})
+}
+
+// parseURIHMACs decodes base64 URI HMACs. nil, for a field left out, stays
+// nil; sizes are checked here so each bad value is reported by index.
+func parseURIHMACs(field string, values []string) ([][]byte, util.FieldErrors) {
+	var fields util.FieldErrors
+	if values == nil {
+		return nil, fields
+	}
+	if len(values) > domain.MaxURIHMACsPerItem {
+		fields.Add(field, util.FieldInvalid, fmt.Sprintf("at most %d URI HMACs are allowed", domain.MaxURIHMACsPerItem))
+		return nil, fields
+	}
+
+	hmacs := make([][]byte, 0, len(values))
+	for i, value := range values {
+		raw, err := decodeBase64Required(value)
+		if err != nil || len(raw) != domain.URIHMACSize {
+			fields.Add(fmt.Sprintf("%s[%d]", field, i), util.FieldInvalid, fmt.Sprintf("must be %d bytes of standard base64", domain.URIHMACSize))
+			continue
+		}
+		hmacs = append(hmacs, raw)
+	}
+	return hmacs, fields
+}
+
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	value := t.UTC().Format(time.RFC3339)
+	return &value
+}
@@ -3,8 +3,10 @@ package controller
 import (
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -34,13 +36,15 @@ func NewVaultController(vaultService *service.VaultService, logger *slog.Logger,
 func (c *VaultController) HandleCreateItem(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	var req dto.CreateVaultItemRequest
 	if err := util.ReadJSON(r, &req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+		util.WriteDecodeError(w, err)
 		return
 	}
 
-	input, err := parseUpsertVaultItemInput(req.Ciphertext, req.Nonce, req.WrappedDEK, req.WrapNonce, req.AlgoVersion, req.Metadata)
-	if err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid_vault_payload", "vault item payload is invalid")
+	input, fields := parseUpsertVaultItemInput("", req.Ciphertext, req.Nonce, req.WrappedDEK, req.WrapNonce, req.AlgoVersion, req.Metadata)
+	uriHMACs, hmacFields := parseURIHMACs("uri_hmacs", req.URIHMACs)
+	fields = append(fields, hmacFields...)
+	if len(fields) > 0 {
+		util.WriteValidationError(w, "invalid_vault_payload", "vault item payload is invalid", fields)
 		return
 	}
 
@@ -52,9 +56,10 @@ func (c *VaultController) HandleCreateItem(w http.ResponseWriter, r *http.Reques
 		WrapNonce:   input.WrapNonce,
 		AlgoVersion: input.AlgoVersion,
 		Metadata:    input.Metadata,
+		URIHMACs:    uriHMACs,
 	})
 	if err != nil {
-		c.writeVaultError(w, r, err, "failed to create vault item")
+		writeServiceError(w, r, c.log, err, "failed to create vault item")
 		return
 	}
 
@@ -64,7 +69,7 @@ func (c *VaultController) HandleCreateItem(w http.ResponseWriter, r *http.Reques
 func (c *VaultController) HandleBulkCreateItems(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	var req dto.BulkCreateVaultItemsRequest
 	if err := util.ReadJSON(r, &req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+		util.WriteDecodeError(w, err)
 		return
 	}
 
@@ -79,12 +84,12 @@ func (c *VaultController) HandleBulkCreateItems(w http.ResponseWriter, r *http.R
 	}
 
 	inputs := make([]domain.CreateVaultItemInput, 0, len(req.Items))
-	for _, item := range req.Items {
-		parsed, err := parseUpsertVaultItemInput(item.Ciphertext, item.Nonce, item.WrappedDEK, item.WrapNonce, item.AlgoVersion, item.Metadata)
-		if err != nil {
-			util.WriteError(w, http.StatusBadRequest, "invalid_vault_payload", "one or more vault item payloads are invalid")
-			return
-		}
+	var fields util.FieldErrors
+	for i, item := range req.Items {
+		parsed, itemFields := parseUpsertVaultItemInput(fmt.Sprintf("items[%d].", i), item.Ciphertext, item.Nonce, item.WrappedDEK, item.WrapNonce, item.AlgoVersion, item.Metadata)
+		uriHMACs, hmacFields := parseURIHMACs(fmt.Sprintf("items[%d].uri_hmacs", i), item.URIHMACs)
+		fields = append(fields, itemFields...)
+		fields = append(fields, hmacFields...)
 		inputs = append(inputs, domain.CreateVaultItemInput{
 			FolderID:    item.FolderID,
 			Ciphertext:  parsed.Ciphertext,
@@ -93,12 +98,17 @@ func (c *VaultController) HandleBulkCreateItems(w http.ResponseWriter, r *http.R
 			WrapNonce:   parsed.WrapNonce,
 			AlgoVersion: parsed.AlgoVersion,
 			Metadata:    parsed.Metadata,
+			URIHMACs:    uriHMACs,
 		})
 	}
+	if len(fields) > 0 {
+		util.WriteValidationError(w, "invalid_vault_payload", "one or more vault item payloads are invalid", fields)
+		return
+	}
 
 	items, err := c.vault.CreateItemsBulk(r.Context(), session.UserID, inputs)
 	if err != nil {
-		c.writeVaultError(w, r, err, "failed to bulk create vault items")
+		writeServiceError(w, r, c.log, err, "failed to bulk create vault items")
 		return
 	}
 
@@ -109,10 +119,37 @@ func (c *VaultController) HandleBulkCreateItems(w http.ResponseWriter, r *http.R
 	util.WriteJSON(w, http.StatusCreated, resp)
 }
 
+// HandleListItems lists the active items. With view=compact it answers for
+// the browser extension instead; see handleListCompactItems.
 func (c *VaultController) HandleListItems(w http.ResponseWriter, r *http.Request, session domain.Session) {
-	items, err := c.vault.ListItems(r.Context(), session.UserID)
+	if r.URL.Query().Get("view") == "compact" {
+		c.handleListCompactItems(w, r, session)
+		return
+	}
+
+	revision, err := c.vault.VaultRevision(r.Context(), session.UserID)
 	if err != nil {
-		c.writeVaultError(w, r, err, "failed to list vault items")
+		writeServiceError(w, r, c.log, err, "failed to list vault items")
+		return
+	}
+	if util.CheckETag(w, r, revision) {
+		return
+	}
+
+	query, paged, err := parseVaultItemQuery(r)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to list vault items")
+		return
+	}
+	var items []domain.VaultItem
+	var total int
+	if paged {
+		items, total, err = c.vault.ListItemPage(r.Context(), session.UserID, query)
+	} else {
+		items, err = c.vault.ListItems(r.Context(), session.UserID)
+	}
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to list vault items")
 		return
 	}
 
@@ -120,13 +157,100 @@ func (c *VaultController) HandleListItems(w http.ResponseWriter, r *http.Request
 	for _, item := range items {
 		resp.Items = append(resp.Items, vaultItemToResponse(item))
 	}
+	if paged {
+		resp.Total = &total
+		if next := query.Offset + len(items); len(items) > 0 && next < total {
+			resp.NextCursor = strconv.Itoa(next)
+		}
+	}
+	util.WriteJSON(w, http.StatusOK, resp)
+}
+
+// parseVaultItemQuery reads the paging parameters of HandleListItems:
+// limit, cursor (a previous page's next_cursor), sort (updated_at or
+// created_at), order (asc or desc) and the folder_id and type filters.
+// Without any of them the whole vault is listed, as it always was, and
+// paged is false.
+func parseVaultItemQuery(r *http.Request) (query domain.VaultItemQuery, paged bool, err error) {
+	values := r.URL.Query()
+	for _, name := range []string{"limit", "cursor", "sort", "order", "folder_id", "type"} {
+		paged = paged || values.Has(name)
+	}
+	if !paged {
+		return query, false, nil
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		if query.Limit, err = strconv.Atoi(raw); err != nil {
+			return query, true, domain.ErrInvalidItemQuery
+		}
+	}
+	if raw := values.Get("cursor"); raw != "" {
+		if query.Offset, err = strconv.Atoi(raw); err != nil || query.Offset < 0 {
+			return query, true, domain.ErrInvalidItemQuery
+		}
+	}
+	switch values.Get("order") {
+	case "", "desc":
+	case "asc":
+		query.Ascending = true
+	default:
+		return query, true, domain.ErrInvalidItemQuery
+	}
+	query.Sort = domain.VaultItemSort(values.Get("sort"))
+	query.FolderID = strings.TrimSpace(values.Get("folder_id"))
+	query.Kind = strings.TrimSpace(values.Get("type"))
+	return query, true, nil
+}
+
+// HandleSync returns every active item and folder in one response. Like
+// HandleListItems it answers 304 while the vault revision is unchanged.
+func (c *VaultController) HandleSync(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	revision, err := c.vault.VaultRevision(r.Context(), session.UserID)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to sync vault")
+		return
+	}
+	if util.CheckETag(w, r, revision) {
+		return
+	}
+
+	var snapshot domain.VaultSnapshot
+	if since := r.URL.Query().Get("since"); since != "" {
+		cursor, parseErr := time.Parse(time.RFC3339Nano, since)
+		if parseErr != nil {
+			writeServiceError(w, r, c.log, domain.ErrInvalidSyncCursor, "failed to sync vault")
+			return
+		}
+		snapshot, err = c.vault.DeltaSync(r.Context(), session.UserID, revision, cursor)
+	} else {
+		snapshot, err = c.vault.Sync(r.Context(), session.UserID, revision)
+	}
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to sync vault")
+		return
+	}
+
+	resp := dto.VaultSyncResponse{
+		Revision:       snapshot.Revision,
+		Cursor:         snapshot.Cursor.Format(time.RFC3339Nano),
+		Items:          make([]dto.VaultItemResponse, 0, len(snapshot.Items)),
+		Folders:        make([]dto.FolderResponse, 0, len(snapshot.Folders)),
+		DeletedItemIDs: snapshot.DeletedItemIDs,
+	}
+	for _, item := range snapshot.Items {
+		resp.Items = append(resp.Items, vaultItemToResponse(item))
+	}
+	for _, folder := range snapshot.Folders {
+		resp.Folders = append(resp.Folders, folderToResponse(folder))
+	}
 	util.WriteJSON(w, http.StatusOK, resp)
 }
 
 func (c *VaultController) HandleListDeletedItems(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	items, err := c.vault.ListDeletedItems(r.Context(), session.UserID)
 	if err != nil {
-		c.writeVaultError(w, r, err, "failed to list deleted vault items")
+		writeServiceError(w, r, c.log, err, "failed to list deleted vault items")
 		return
 	}
 
@@ -141,7 +265,7 @@ func (c *VaultController) HandleGetItem(w http.ResponseWriter, r *http.Request,
 	itemID := strings.TrimSpace(r.PathValue("item_id"))
 	item, err := c.vault.GetItem(r.Context(), session.UserID, itemID)
 	if err != nil {
-		c.writeVaultError(w, r, err, "failed to load vault item")
+		writeServiceError(w, r, c.log, err, "failed to load vault item")
 		return
 	}
 
@@ -153,13 +277,15 @@ func (c *VaultController) HandleUpdateItem(w http.ResponseWriter, r *http.Reques
 
 	var req dto.UpdateVaultItemRequest
 	if err := util.ReadJSON(r, &req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+		util.WriteDecodeError(w, err)
 		return
 	}
 
-	input, err := parseUpsertVaultItemInput(req.Ciphertext, req.Nonce, req.WrappedDEK, req.WrapNonce, req.AlgoVersion, req.Metadata)
-	if err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid_vault_payload", "vault item payload is invalid")
+	input, fields := parseUpsertVaultItemInput("", req.Ciphertext, req.Nonce, req.WrappedDEK, req.WrapNonce, req.AlgoVersion, req.Metadata)
+	uriHMACs, hmacFields := parseURIHMACs("uri_hmacs", req.URIHMACs)
+	fields = append(fields, hmacFields...)
+	if len(fields) > 0 {
+		util.WriteValidationError(w, "invalid_vault_payload", "vault item payload is invalid", fields)
 		return
 	}
 
@@ -171,9 +297,10 @@ func (c *VaultController) HandleUpdateItem(w http.ResponseWriter, r *http.Reques
 		WrapNonce:   input.WrapNonce,
 		AlgoVersion: input.AlgoVersion,
 		Metadata:    input.Metadata,
+		URIHMACs:    uriHMACs,
 	})
 	if err != nil {
-		c.writeVaultError(w, r, err, "failed to update vault item")
+		writeServiceError(w, r, c.log, err, "failed to update vault item")
 		return
 	}
 
@@ -183,7 +310,7 @@ func (c *VaultController) HandleUpdateItem(w http.ResponseWriter, r *http.Reques
 func (c *VaultController) HandleDeleteItem(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	itemID := strings.TrimSpace(r.PathValue("item_id"))
 	if err := c.vault.DeleteItem(r.Context(), session.UserID, itemID); err != nil {
-		c.writeVaultError(w, r, err, "failed to delete vault item")
+		writeServiceError(w, r, c.log, err, "failed to delete vault item")
 		return
 	}
 
@@ -194,7 +321,7 @@ func (c *VaultController) HandleRestoreItem(w http.ResponseWriter, r *http.Reque
 	itemID := strings.TrimSpace(r.PathValue("item_id"))
 	item, err := c.vault.RestoreItem(r.Context(), session.UserID, itemID)
 	if err != nil {
-		c.writeVaultError(w, r, err, "failed to restore vault item")
+		writeServiceError(w, r, c.log, err, "failed to restore vault item")
 		return
 	}
 
@@ -205,7 +332,7 @@ func (c *VaultController) HandleListItemVersions(w http.ResponseWriter, r *http.
 	itemID := strings.TrimSpace(r.PathValue("item_id"))
 	versions, err := c.vault.ListItemVersions(r.Context(), session.UserID, itemID)
 	if err != nil {
-		c.writeVaultError(w, r, err, "failed to list vault item versions")
+		writeServiceError(w, r, c.log, err, "failed to list vault item versions")
 		return
 	}
 
@@ -219,7 +346,7 @@ func (c *VaultController) HandleListItemVersions(w http.ResponseWriter, r *http.
 func (c *VaultController) HandleGetVaultSalt(w http.ResponseWriter, r *http.Request, session domain.Session) {
 	salt, err := c.vault.GetVaultSalt(r.Context(), session.UserID)
 	if err != nil {
-		c.writeVaultError(w, r, err, "failed to get vault salt")
+		writeServiceError(w, r, c.log, err, "failed to get vault salt")
 		return
 	}
 
@@ -237,38 +364,39 @@ type upsertVaultItemInput struct {
 	Metadata    []byte
 }
 
-func parseUpsertVaultItemInput(ciphertextB64 string, nonceB64 string, wrappedDEKB64 string, wrapNonceB64 string, algoVersion string, metadata []byte) (upsertVaultItemInput, error) {
-	ciphertext, err := decodeBase64Required(ciphertextB64)
-	if err != nil {
-		return upsertVaultItemInput{}, err
-	}
-	nonce, err := decodeBase64Required(nonceB64)
-	if err != nil {
-		return upsertVaultItemInput{}, err
-	}
-	wrappedDEK, err := decodeBase64Required(wrappedDEKB64)
-	if err != nil {
-		return upsertVaultItemInput{}, err
-	}
-	wrapNonce, err := decodeBase64Required(wrapNonceB64)
-	if err != nil {
-		return upsertVaultItemInput{}, err
+// parseUpsertVaultItemInput decodes the base64 fields of a vault item
+// payload. Problems are reported per field, each name prefixed with prefix
+// (e.g. "items[3].") for bulk requests.
+func parseUpsertVaultItemInput(prefix string, ciphertextB64 string, nonceB64 string, wrappedDEKB64 string, wrapNonceB64 string, algoVersion string, metadata []byte) (upsertVaultItemInput, util.FieldErrors) {
+	var fields util.FieldErrors
+	decode := func(name, value string) []byte {
+		raw, err := decodeBase64Required(value)
+		switch {
+		case errors.Is(err, errEmptyBase64):
+			fields.Add(prefix+name, util.FieldRequired, name+" is required")
+		case err != nil:
+			fields.Add(prefix+name, util.FieldInvalid, name+" must be non-empty standard base64")
+		}
+		return raw
 	}
 
-	return upsertVaultItemInput{
-		Ciphertext:  ciphertext,
-		Nonce:       nonce,
-		WrappedDEK:  wrappedDEK,
-		WrapNonce:   wrapNonce,
+	input := upsertVaultItemInput{
+		Ciphertext:  decode("ciphertext", ciphertextB64),
+		Nonce:       decode("nonce", nonceB64),
+		WrappedDEK:  decode("wrapped_dek", wrappedDEKB64),
+		WrapNonce:   decode("wrap_nonce", wrapNonceB64),
 		AlgoVersion: strings.TrimSpace(algoVersion),
 		Metadata:    metadata,
-	}, nil
+	}
+	return input, fields
 }
 
+var errEmptyBase64 = errors.New("empty base64 field")
+
 func decodeBase64Required(value string) ([]byte, error) {
 	trimmed := strings.TrimSpace(value)
 	if trimmed == "" {
-		return nil, errors.New("empty base64 field")
+		return nil, errEmptyBase64
 	}
 	raw, err := base64.StdEncoding.DecodeString(trimmed)
 	if err != nil {
@@ -326,20 +454,6 @@ func vaultItemVersionToResponse(version domain.VaultItemVersion) dto.VaultItemVe
 	}
 }
 
-func (c *VaultController) writeVaultError(w http.ResponseWriter, r *http.Request, err error, defaultMessage string) {
-	switch {
-	case errors.Is(err, domain.ErrUnauthorizedSession):
-		util.WriteError(w, http.StatusUnauthorized, "unauthorized", "invalid or expired session")
-	case errors.Is(err, domain.ErrInvalidVaultPayload):
-		util.WriteError(w, http.StatusBadRequest, "invalid_vault_payload", "vault item payload is invalid")
-	case errors.Is(err, domain.ErrNotFound):
-		util.WriteError(w, http.StatusNotFound, "not_found", "vault item not found")
-	default:
-		c.log.ErrorContext(r.Context(), defaultMessage, slog.Any("error", err))
-		util.WriteError(w, http.StatusInternalServerError, "internal_error", defaultMessage)
-	}
-}
-
 // HandleGetKDFParams returns the server-configured Argon2id parameters.
 // This is a public endpoint — no auth required.
 func (c *VaultController) HandleGetKDFParams(w http.ResponseWriter, r *http.Request) {
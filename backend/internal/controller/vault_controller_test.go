@@ -0,0 +1,427 @@
+package controller_test
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"pmv2/backend/internal/controller"
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/service"
+)
+
+// mockVaultRepo implements only the reads the list and sync handlers use,
+// and the bulk insert of imports; any other call panics on the nil embedded
+// interface.
+type mockVaultRepo struct {
+	domain.VaultRepository
+	revision string
+	items    []domain.VaultItem
+	deleted  []string
+	query    domain.VaultItemQuery
+}
+
+func (m *mockVaultRepo) GetVaultRevision(ctx context.Context, ownerUserID string) (string, error) {
+	return m.revision, nil
+}
+func (m *mockVaultRepo) ListVaultItemsByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultItem, error) {
+	return m.items, nil
+}
+func (m *mockVaultRepo) ListVaultItemPage(ctx context.Context, ownerUserID string, query domain.VaultItemQuery) ([]domain.VaultItem, int, error) {
+	m.query = query
+	end := min(query.Offset+query.Limit, len(m.items))
+	return m.items[min(query.Offset, end):end], len(m.items), nil
+}
+func (m *mockVaultRepo) CreateVaultItemsBulk(ctx context.Context, inputs []domain.CreateVaultItemInput) ([]domain.VaultItem, error) {
+	created := make([]domain.VaultItem, 0, len(inputs))
+	for i, input := range inputs {
+		created = append(created, domain.VaultItem{ID: fmt.Sprintf("item-%d", len(m.items)+i), Ciphertext: input.Ciphertext, Metadata: input.Metadata, Version: 1})
+	}
+	m.items = append(m.items, created...)
+	return created, nil
+}
+func (m *mockVaultRepo) ListVaultItemChangesByOwner(ctx context.Context, ownerUserID string, since time.Time) ([]domain.VaultItem, []string, error) {
+	return m.items, m.deleted, nil
+}
+
+type mockFolderRepo struct {
+	domain.FolderRepository
+	folders []domain.VaultFolder
+}
+
+func (m *mockFolderRepo) ListFoldersByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultFolder, error) {
+	return m.folders, nil
+}
+
+func newVaultController(repo *mockVaultRepo, folders *mockFolderRepo) *controller.VaultController {
+	svc := service.NewVaultService(repo, folders, nil, nil)
+	return controller.NewVaultController(svc, slog.Default(), controller.KDFConfig{})
+}
+
+func TestHandleSync(t *testing.T) {
+	now := time.Now()
+	repo := &mockVaultRepo{
+		revision: "rev-1",
+		items:    []domain.VaultItem{{ID: "item-1", OwnerUserID: "user-1", Ciphertext: []byte("c"), Nonce: []byte("n"), AlgoVersion: "v1", Version: 1, CreatedAt: now, UpdatedAt: now}},
+	}
+	folders := &mockFolderRepo{folders: []domain.VaultFolder{{ID: "folder-1", OwnerUserID: "user-1", NameCiphertext: []byte("f"), Nonce: []byte("n"), CreatedAt: now, UpdatedAt: now}}}
+	c := newVaultController(repo, folders)
+	session := domain.Session{UserID: "user-1"}
+
+	rec := httptest.NewRecorder()
+	c.HandleSync(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vault/sync", nil), session)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	etag := rec.Header().Get("ETag")
+	if len(etag) < 2 || etag[:2] != "W/" {
+		t.Fatalf("ETag = %q, want a weak tag", etag)
+	}
+
+	var resp dto.VaultSyncResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Items) != 1 || resp.Items[0].ID != "item-1" {
+		t.Fatalf("items = %+v", resp.Items)
+	}
+	if len(resp.Folders) != 1 || resp.Folders[0].ID != "folder-1" {
+		t.Fatalf("folders = %+v", resp.Folders)
+	}
+	if resp.Revision == "" || `W/"`+resp.Revision+`"` != etag {
+		t.Fatalf("revision %q does not match ETag %q", resp.Revision, etag)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, resp.Cursor); err != nil || resp.DeletedItemIDs != nil {
+		t.Fatalf("cursor = %q, deleted = %v", resp.Cursor, resp.DeletedItemIDs)
+	}
+
+	// With since only the changes are sent, deleted items by ID.
+	repo.deleted = []string{"item-2"}
+	rec = httptest.NewRecorder()
+	c.HandleSync(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vault/sync?since="+resp.Cursor, nil), session)
+	var delta dto.VaultSyncResponse
+	if err := json.NewDecoder(rec.Body).Decode(&delta); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("delta sync: status = %d, %v", rec.Code, err)
+	}
+	if len(delta.Items) != 1 || len(delta.DeletedItemIDs) != 1 || delta.DeletedItemIDs[0] != "item-2" || delta.Cursor == "" {
+		t.Fatalf("delta = %+v", delta)
+	}
+	rec = httptest.NewRecorder()
+	c.HandleSync(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vault/sync?since=yesterday", nil), session)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid_sync_cursor") {
+		t.Fatalf("bad cursor: status = %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	// A client that already holds this revision gets 304 and no body.
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/vault/sync", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	c.HandleSync(rec, req, session)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("conditional sync: status = %d, body %q", rec.Code, rec.Body.String())
+	}
+
+	// A change in the repository revision invalidates the tag.
+	repo.revision = "rev-2"
+	rec = httptest.NewRecorder()
+	c.HandleSync(rec, req, session)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("after change: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestHandleListItemsNotModified(t *testing.T) {
+	c := newVaultController(&mockVaultRepo{revision: "rev-1"}, &mockFolderRepo{})
+	session := domain.Session{UserID: "user-1"}
+
+	rec := httptest.NewRecorder()
+	c.HandleListItems(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vault/items", nil), session)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/vault/items", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	c.HandleListItems(rec, req, session)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("status = %d, want 304", rec.Code)
+	}
+}
+
+func TestHandleFullSync(t *testing.T) {
+	now := time.Now()
+	repo := &mockVaultRepo{revision: "rev-1"}
+	for _, id := range []string{"item-b", "item-a"} {
+		repo.items = append(repo.items, domain.VaultItem{ID: id, OwnerUserID: "user-1", Ciphertext: []byte("c"), Nonce: []byte("n"), AlgoVersion: "v1", Version: 1, CreatedAt: now, UpdatedAt: now})
+	}
+	folders := &mockFolderRepo{folders: []domain.VaultFolder{{ID: "folder-1", OwnerUserID: "user-1", NameCiphertext: []byte("f"), Nonce: []byte("n"), CreatedAt: now, UpdatedAt: now}}}
+	c := newVaultController(repo, folders)
+	session := domain.Session{UserID: "user-1"}
+
+	sync := func(target, acceptEncoding string) (*httptest.ResponseRecorder, []dto.VaultSyncRecord) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		rec := httptest.NewRecorder()
+		c.HandleFullSync(rec, req, session)
+		if rec.Code != http.StatusOK {
+			return rec, nil
+		}
+
+		var body io.Reader = rec.Body
+		switch rec.Header().Get("Content-Encoding") {
+		case "zstd":
+			dec, err := zstd.NewReader(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dec.Close()
+			body = dec
+		case "gzip":
+			gz, err := gzip.NewReader(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = gz
+		}
+		var records []dto.VaultSyncRecord
+		decoder := json.NewDecoder(body)
+		for decoder.More() {
+			var record dto.VaultSyncRecord
+			if err := decoder.Decode(&record); err != nil {
+				t.Fatal(err)
+			}
+			records = append(records, record)
+		}
+		return rec, records
+	}
+
+	rec, records := sync("/api/v1/vault/sync/full", "gzip, zstd")
+	if got := rec.Header().Get("Content-Encoding"); got != "zstd" {
+		t.Fatalf("Content-Encoding = %q, want zstd", got)
+	}
+	if len(records) != 5 || records[0].Type != "start" || records[4].Type != "end" {
+		t.Fatalf("records = %+v", records)
+	}
+	if *records[0].Remaining != 3 || records[1].Folder.ID != "folder-1" || records[2].Item.ID != "item-a" || records[3].Item.ID != "item-b" {
+		t.Fatalf("entries out of order: %+v", records)
+	}
+	revision := records[0].Revision
+
+	rec, records = sync("/api/v1/vault/sync/full?offset=2&revision="+revision, "gzip, zstd;q=0")
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	if len(records) != 3 || *records[0].Offset != 2 || records[1].Item.ID != "item-b" {
+		t.Fatalf("resumed records = %+v", records)
+	}
+
+	if rec, _ = sync("/api/v1/vault/sync/full?offset=2&revision=stale", ""); rec.Code != http.StatusConflict {
+		t.Fatalf("resume at a stale revision: status = %d, want 409", rec.Code)
+	}
+	if rec, _ = sync("/api/v1/vault/sync/full?offset=-1", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("negative offset: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestHandleBulkCreateItemsFieldErrors(t *testing.T) {
+	c := newVaultController(&mockVaultRepo{}, &mockFolderRepo{})
+	session := domain.Session{UserID: "user-1"}
+
+	body := `{"items": [
+		{"ciphertext": "Yw==", "nonce": "bg==", "wrapped_dek": "ZA==", "wrap_nonce": "bg==", "algo_version": "v1"},
+		{"ciphertext": "Yw==", "nonce": "", "wrapped_dek": "not base64!", "wrap_nonce": "bg==", "algo_version": "v1"}
+	]}`
+	rec := httptest.NewRecorder()
+	c.HandleBulkCreateItems(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vault/items/bulk", strings.NewReader(body)), session)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	var resp dto.ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error != "invalid_vault_payload" {
+		t.Errorf("error = %q", resp.Error)
+	}
+	want := []dto.FieldError{
+		{Field: "items[1].nonce", Code: "required", Message: "nonce is required"},
+		{Field: "items[1].wrapped_dek", Code: "invalid", Message: "wrapped_dek must be non-empty standard base64"},
+	}
+	if len(resp.Fields) != len(want) {
+		t.Fatalf("fields = %+v", resp.Fields)
+	}
+	for i := range want {
+		if resp.Fields[i] != want[i] {
+			t.Errorf("fields[%d] = %+v, want %+v", i, resp.Fields[i], want[i])
+		}
+	}
+}
+
+func TestHandleListItemsPaged(t *testing.T) {
+	now := time.Now()
+	repo := &mockVaultRepo{revision: "rev-1"}
+	for _, id := range []string{"item-a", "item-b", "item-c"} {
+		repo.items = append(repo.items, domain.VaultItem{ID: id, OwnerUserID: "user-1", Ciphertext: []byte("c"), Nonce: []byte("n"), AlgoVersion: "v1", Version: 1, CreatedAt: now, UpdatedAt: now})
+	}
+	c := newVaultController(repo, &mockFolderRepo{})
+	session := domain.Session{UserID: "user-1"}
+
+	list := func(target string) (*httptest.ResponseRecorder, dto.VaultItemsResponse) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		c.HandleListItems(rec, httptest.NewRequest(http.MethodGet, target, nil), session)
+		var resp dto.VaultItemsResponse
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec, resp
+	}
+
+	// Without paging parameters the whole vault is listed, as before.
+	if rec, resp := list("/api/v1/vault/items"); rec.Code != http.StatusOK || len(resp.Items) != 3 || resp.Total != nil || resp.NextCursor != "" {
+		t.Fatalf("unpaged: status = %d, resp = %+v", rec.Code, resp)
+	}
+
+	rec, resp := list("/api/v1/vault/items?limit=2&sort=created_at&order=asc&type=login")
+	if rec.Code != http.StatusOK || len(resp.Items) != 2 || resp.Total == nil || *resp.Total != 3 || resp.NextCursor == "" {
+		t.Fatalf("first page: status = %d, resp = %+v", rec.Code, resp)
+	}
+	if repo.query.Sort != domain.VaultItemSortCreated || !repo.query.Ascending || repo.query.Kind != "login" {
+		t.Fatalf("query = %+v", repo.query)
+	}
+	rec, resp = list("/api/v1/vault/items?limit=2&cursor=" + resp.NextCursor)
+	if rec.Code != http.StatusOK || len(resp.Items) != 1 || resp.Items[0].ID != "item-c" || resp.NextCursor != "" {
+		t.Fatalf("last page: status = %d, resp = %+v", rec.Code, resp)
+	}
+
+	for _, target := range []string{
+		"/api/v1/vault/items?cursor=-1",
+		"/api/v1/vault/items?limit=ten",
+		"/api/v1/vault/items?sort=name",
+		"/api/v1/vault/items?order=sideways",
+		"/api/v1/vault/items?folder_id=not-a-uuid",
+	} {
+		if rec, _ := list(target); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid_item_query") {
+			t.Fatalf("%s: status = %d, body %s", target, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+type mockAttachmentRepo struct {
+	domain.AttachmentRepository
+	attachments []domain.VaultAttachment
+}
+
+func (m *mockAttachmentRepo) ListAttachmentsByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultAttachment, error) {
+	return m.attachments, nil
+}
+
+type mockBackupRepo struct {
+	records []domain.BackupRecord
+}
+
+func (m *mockBackupRepo) RegisterBackup(ctx context.Context, record domain.BackupRecord) error {
+	m.records = append(m.records, record)
+	return nil
+}
+
+func TestHandleExport(t *testing.T) {
+	now := time.Now()
+	folderID := "folder-1"
+	repo := &mockVaultRepo{}
+	for _, id := range []string{"item-a", "item-b"} {
+		repo.items = append(repo.items, domain.VaultItem{ID: id, FolderID: &folderID, Ciphertext: []byte("c"), Nonce: []byte("n"), AlgoVersion: "v1", Metadata: []byte(`{"kind":"login"}`), Version: 1, CreatedAt: now, UpdatedAt: now})
+	}
+	folders := &mockFolderRepo{folders: []domain.VaultFolder{{ID: folderID, NameCiphertext: []byte("f"), Nonce: []byte("n"), CreatedAt: now, UpdatedAt: now}}}
+	backups := &mockBackupRepo{}
+	svc := service.NewVaultService(repo, folders, nil, nil)
+	svc.UseExports(&mockAttachmentRepo{attachments: []domain.VaultAttachment{{ID: "att-1", ItemID: "item-a", CreatedAt: now}}}, backups)
+	c := controller.NewVaultController(svc, slog.Default(), controller.KDFConfig{})
+	session := domain.Session{UserID: "6f1c9f7e-3b9a-4a47-9d0e-1c2b3a4d5e6f"}
+
+	export := func(target string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		c.HandleExport(rec, httptest.NewRequest(http.MethodGet, target, nil), session)
+		return rec
+	}
+
+	rec := export("/api/v1/vault/export")
+	var doc dto.VaultExportDocument
+	if err := json.NewDecoder(rec.Body).Decode(&doc); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("json export: status = %d, %v", rec.Code, err)
+	}
+	if doc.Format != dto.VaultExportFormatName || doc.Version != 1 || doc.ExportID == "" || len(doc.Items) != 2 || doc.Items[1].ID != "item-b" || len(doc.Folders) != 1 || len(doc.Attachments) != 1 {
+		t.Fatalf("document = %+v", doc)
+	}
+	if !strings.Contains(rec.Header().Get("Content-Disposition"), "attachment") {
+		t.Fatalf("Content-Disposition = %q", rec.Header().Get("Content-Disposition"))
+	}
+
+	rec = export("/api/v1/vault/export?format=csv")
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if rec.Code != http.StatusOK || len(lines) != 3 || !strings.HasPrefix(lines[0], "id,folder_id,") || !strings.HasPrefix(lines[1], "item-a,folder-1,") {
+		t.Fatalf("csv export: status = %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := export("/api/v1/vault/export?format=xml"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("xml export: status = %d", rec.Code)
+	}
+	if len(backups.records) != 2 || backups.records[0].ID != doc.ExportID || backups.records[0].CreatedByUserID != session.UserID || !backups.records[0].Encrypted {
+		t.Fatalf("registered backups = %+v", backups.records)
+	}
+}
+
+func TestHandleImportItems(t *testing.T) {
+	repo := &mockVaultRepo{}
+	c := newVaultController(repo, &mockFolderRepo{})
+	session := domain.Session{UserID: "6f1c9f7e-3b9a-4a47-9d0e-1c2b3a4d5e6f"}
+
+	importItems := func(body string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		c.HandleImportItems(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vault/import", strings.NewReader(body)), session)
+		return rec
+	}
+
+	rec := importItems(`{"source": "lastpass_csv", "items": [
+		{"row": 4, "ciphertext": "Yw==", "nonce": "bg==", "wrapped_dek": "ZA==", "wrap_nonce": "bg==", "algo_version": "v1", "metadata": {"kind": "bank"}},
+		{"row": 2, "ciphertext": "Yw==", "nonce": "bg==", "wrapped_dek": "ZA==", "wrap_nonce": "bg==", "algo_version": "v1", "metadata": {"kind": "login"}},
+		{"row": 3, "ciphertext": "Yw==", "nonce": "", "wrapped_dek": "ZA==", "wrap_nonce": "bg==", "algo_version": "v1", "metadata": {"kind": "note"}}
+	]}`)
+	var resp dto.ImportVaultItemsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("status = %d, %v", rec.Code, err)
+	}
+	if len(resp.Imported) != 1 || resp.Imported[0].Row != 2 || resp.Imported[0].Item.ID != "item-0" || len(repo.items) != 1 {
+		t.Fatalf("imported = %+v", resp.Imported)
+	}
+	if len(resp.Errors) != 2 ||
+		resp.Errors[0].Row != 3 || resp.Errors[0].Code != "invalid_vault_payload" || len(resp.Errors[0].Fields) != 1 || resp.Errors[0].Fields[0].Field != "nonce" ||
+		resp.Errors[1].Row != 4 || resp.Errors[1].Code != "unsupported_import_kind" {
+		t.Fatalf("errors = %+v", resp.Errors)
+	}
+
+	if rec := importItems(`{"source": "dashlane_csv", "items": [{"row": 1}]}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown source: status = %d", rec.Code)
+	}
+	if rec := importItems(`{"source": "lastpass_csv", "items": []}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("no items: status = %d", rec.Code)
+	}
+}
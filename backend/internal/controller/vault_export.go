@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dto"
+)
+
+// HandleExport downloads the whole active vault as one versioned document,
+// JSON by default or CSV with format=csv. Everything stays encrypted under
+// the user's keys; a client that wants a portable file re-encrypts the
+// decrypted items itself.
+func (c *VaultController) HandleExport(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	export, err := c.vault.Export(r.Context(), session.UserID, r.URL.Query().Get("format"))
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to export vault")
+		return
+	}
+
+	contentType := "application/json"
+	write := writeJSONExport
+	if export.Format == domain.VaultExportCSV {
+		contentType = "text/csv; charset=utf-8"
+		write = writeCSVExport
+	}
+	filename := "pmv2-vault-export-" + export.CreatedAt.Format("20060102-150405") + "." + export.Format
+	h := w.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	h.Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	if err := write(w, export); err != nil {
+		// The status is already sent; the client sees a truncated document.
+		c.log.WarnContext(r.Context(), "vault export stream aborted", slog.String("user_id", session.UserID), slog.Any("error", err))
+	}
+}
+
+// writeJSONExport writes a dto.VaultExportDocument one item at a time, so a
+// large vault is not encoded in memory twice.
+func writeJSONExport(w io.Writer, export domain.VaultExport) error {
+	doc := dto.VaultExportDocument{
+		Format:      dto.VaultExportFormatName,
+		Version:     domain.VaultExportVersion,
+		ExportID:    export.ID,
+		ExportedAt:  export.CreatedAt.Format(time.RFC3339),
+		Folders:     make([]dto.FolderResponse, 0, len(export.Folders)),
+		Attachments: make([]dto.VaultAttachmentResponse, 0, len(export.Attachments)),
+		Items:       []dto.VaultItemResponse{},
+	}
+	for _, folder := range export.Folders {
+		doc.Folders = append(doc.Folders, folderToResponse(folder))
+	}
+	for _, a := range export.Attachments {
+		doc.Attachments = append(doc.Attachments, attachmentToResponse(a))
+	}
+	head, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	// Items is the last field: drop its empty array and the closing brace,
+	// and stream the items in their place.
+	head = bytes.TrimSuffix(head, []byte(`[]}`))
+	if _, err := w.Write(append(head, '[')); err != nil {
+		return err
+	}
+	for i, item := range export.Items {
+		line, err := json.Marshal(vaultItemToResponse(item))
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			line = append([]byte{','}, line...)
+		}
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+	}
+	_, err = io.WriteString(w, "]}\n")
+	return err
+}
+
+func writeCSVExport(w io.Writer, export domain.VaultExport) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(dto.VaultExportCSVHeader); err != nil {
+		return err
+	}
+	for _, item := range export.Items {
+		resp := vaultItemToResponse(item)
+		folderID := ""
+		if resp.FolderID != nil {
+			folderID = *resp.FolderID
+		}
+		if err := cw.Write([]string{
+			resp.ID, folderID, resp.Ciphertext, resp.Nonce, resp.WrappedDEK, resp.WrapNonce,
+			resp.AlgoVersion, string(resp.Metadata), strconv.Itoa(resp.Version), resp.CreatedAt, resp.UpdatedAt,
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
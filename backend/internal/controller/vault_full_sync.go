@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dto"
+)
+
+// fullSyncFlushEvery is how many entries the full sync writes between
+// flushes, so a client sees progress on a large vault and a stream cut
+// short loses little.
+const fullSyncFlushEvery = 256
+
+// streamEncoder is a compressor the full sync can flush mid-stream.
+type streamEncoder interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// HandleFullSync streams the whole active vault as NDJSON for a desktop
+// client's initial sync, compressed with zstd or gzip when the client
+// accepts either. A client whose stream broke off resumes with
+// offset=<entries received>&revision=<revision of the start record>.
+func (c *VaultController) HandleFullSync(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	query := r.URL.Query()
+	offset := 0
+	if raw := query.Get("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			writeServiceError(w, r, c.log, domain.ErrInvalidSyncOffset, "failed to sync vault")
+			return
+		}
+		offset = n
+	}
+
+	snapshot, err := c.vault.FullSync(r.Context(), session.UserID, query.Get("revision"), offset)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to sync vault")
+		return
+	}
+
+	coding := negotiateSyncEncoding(r.Header.Get("Accept-Encoding"))
+	var enc streamEncoder
+	switch coding {
+	case "zstd":
+		enc, err = zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	case "gzip":
+		enc = gzip.NewWriter(w)
+	}
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to sync vault")
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "application/x-ndjson")
+	h.Set("Cache-Control", "no-store")
+	h.Add("Vary", "Accept-Encoding")
+	var out io.Writer = w
+	if enc != nil {
+		h.Set("Content-Encoding", coding)
+		out = enc
+	}
+	w.WriteHeader(http.StatusOK)
+
+	if err := writeFullSync(out, w, enc, snapshot, offset); err != nil {
+		// The status is already sent; the missing end record tells the
+		// client to resume.
+		c.log.WarnContext(r.Context(), "full sync stream aborted", slog.String("user_id", session.UserID), slog.Any("error", err))
+		return
+	}
+	if enc != nil {
+		if err := enc.Close(); err != nil {
+			c.log.WarnContext(r.Context(), "full sync stream aborted", slog.String("user_id", session.UserID), slog.Any("error", err))
+		}
+	}
+}
+
+func writeFullSync(out io.Writer, w http.ResponseWriter, enc streamEncoder, snapshot domain.VaultSnapshot, offset int) error {
+	encoder := json.NewEncoder(out)
+	flush := func() error {
+		if enc != nil {
+			if err := enc.Flush(); err != nil {
+				return err
+			}
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		return nil
+	}
+
+	remaining := len(snapshot.Folders) + len(snapshot.Items)
+	if err := encoder.Encode(dto.VaultSyncRecord{Type: "start", Revision: snapshot.Revision, Offset: &offset, Remaining: &remaining}); err != nil {
+		return err
+	}
+	written := 0
+	next := func(record dto.VaultSyncRecord) error {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+		written++
+		if written%fullSyncFlushEvery == 0 {
+			return flush()
+		}
+		return nil
+	}
+	for _, folder := range snapshot.Folders {
+		resp := folderToResponse(folder)
+		if err := next(dto.VaultSyncRecord{Type: "folder", Folder: &resp}); err != nil {
+			return err
+		}
+	}
+	for _, item := range snapshot.Items {
+		resp := vaultItemToResponse(item)
+		if err := next(dto.VaultSyncRecord{Type: "item", Item: &resp}); err != nil {
+			return err
+		}
+	}
+	return encoder.Encode(dto.VaultSyncRecord{Type: "end", Revision: snapshot.Revision})
+}
+
+// negotiateSyncEncoding picks zstd over gzip from an Accept-Encoding header,
+// or "" for an uncompressed stream. Codings refused with q=0 are skipped.
+func negotiateSyncEncoding(header string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.ReplaceAll(strings.TrimSpace(params), " ", "") == "q=0" {
+			continue
+		}
+		accepted[strings.ToLower(strings.TrimSpace(coding))] = true
+	}
+	for _, coding := range []string{"zstd", "gzip"} {
+		if accepted[coding] {
+			return coding
+		}
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"net/http"
+	"sort"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/i18n"
+	"pmv2/backend/internal/util"
+)
+
+// maxImportItems caps an import request like a bulk create; clients split
+// larger exports.
+const maxImportItems = 500
+
+// HandleImportItems imports items a client read from another password
+// manager's export and encrypted itself. Rows that fail validation do not
+// stop the others, so the response is 200 whatever the outcome: it lists
+// what was imported and why each other row was not.
+func (c *VaultController) HandleImportItems(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var req dto.ImportVaultItemsRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+	if len(req.Items) == 0 {
+		util.WriteError(w, http.StatusBadRequest, "empty_items", "no items provided")
+		return
+	}
+	if len(req.Items) > maxImportItems {
+		util.WriteError(w, http.StatusBadRequest, "too_many_items", "maximum 500 items per import request")
+		return
+	}
+
+	loc := i18n.FromWriter(w)
+	resp := dto.ImportVaultItemsResponse{Imported: []dto.ImportedVaultItemResponse{}, Errors: []dto.ImportRowErrorResponse{}}
+	rows := make([]domain.VaultImportRow, 0, len(req.Items))
+	for _, item := range req.Items {
+		parsed, fields := parseUpsertVaultItemInput("", item.Ciphertext, item.Nonce, item.WrappedDEK, item.WrapNonce, item.AlgoVersion, item.Metadata)
+		uriHMACs, hmacFields := parseURIHMACs("uri_hmacs", item.URIHMACs)
+		fields = append(fields, hmacFields...)
+		if len(fields) > 0 {
+			for i, f := range fields {
+				fields[i].Message = loc.Text("field."+f.Code, f.Message, f.Field)
+			}
+			code := domain.ErrInvalidVaultPayload.Code
+			resp.Errors = append(resp.Errors, dto.ImportRowErrorResponse{
+				Row: item.Row, Code: code, Message: loc.Text("error."+code, domain.ErrInvalidVaultPayload.Message), Fields: fields,
+			})
+			continue
+		}
+		rows = append(rows, domain.VaultImportRow{Row: item.Row, Item: domain.CreateVaultItemInput{
+			FolderID:    item.FolderID,
+			Ciphertext:  parsed.Ciphertext,
+			Nonce:       parsed.Nonce,
+			WrappedDEK:  parsed.WrappedDEK,
+			WrapNonce:   parsed.WrapNonce,
+			AlgoVersion: parsed.AlgoVersion,
+			Metadata:    parsed.Metadata,
+			URIHMACs:    uriHMACs,
+		}})
+	}
+
+	result, err := c.vault.ImportItems(r.Context(), session.UserID, req.Source, rows)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to import vault items")
+		return
+	}
+	for _, imported := range result.Imported {
+		resp.Imported = append(resp.Imported, dto.ImportedVaultItemResponse{Row: imported.Row, Item: vaultItemToResponse(imported.Item)})
+	}
+	for _, rowErr := range result.Errors {
+		resp.Errors = append(resp.Errors, dto.ImportRowErrorResponse{
+			Row: rowErr.Row, Code: rowErr.Err.Code, Message: loc.Text("error."+rowErr.Err.Code, rowErr.Err.Message),
+		})
+	}
+	sort.SliceStable(resp.Errors, func(i, j int) bool { return resp.Errors[i].Row < resp.Errors[j].Row })
+	util.WriteJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/service"
+	"pmv2/backend/internal/util"
+)
+
+type WebhookController struct {
+	webhooks *service.WebhookService
+	log      *slog.Logger
+}
+
+func NewWebhookController(webhookService *service.WebhookService, logger *slog.Logger) *WebhookController {
+	return &WebhookController{webhooks: webhookService, log: logger}
+}
+
+// HandleCreateWebhook registers an endpoint. The signing secret is returned
+// once, in this response.
+func (c *WebhookController) HandleCreateWebhook(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var req dto.CreateWebhookRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+
+	endpoint, err := c.webhooks.CreateEndpoint(r.Context(), session.UserID, req.URL, req.EventTypes)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to create webhook")
+		return
+	}
+
+	resp := webhookToResponse(endpoint)
+	resp.Secret = endpoint.Secret
+	util.WriteJSON(w, http.StatusCreated, resp)
+}
+
+func (c *WebhookController) HandleListWebhooks(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	endpoints, err := c.webhooks.ListEndpoints(r.Context(), session.UserID)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to list webhooks")
+		return
+	}
+
+	resp := dto.WebhooksResponse{Webhooks: make([]dto.WebhookResponse, 0, len(endpoints))}
+	for _, e := range endpoints {
+		resp.Webhooks = append(resp.Webhooks, webhookToResponse(e))
+	}
+	util.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (c *WebhookController) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	webhookID := strings.TrimSpace(r.PathValue("webhook_id"))
+	if err := c.webhooks.DeleteEndpoint(r.Context(), session.UserID, webhookID); err != nil {
+		writeServiceError(w, r, c.log, err, "failed to delete webhook")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, dto.StatusResponse{Status: "deleted"})
+}
+
+// HandleListDeliveries lists delivery attempts for one endpoint, newest first.
+func (c *WebhookController) HandleListDeliveries(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	webhookID := strings.TrimSpace(r.PathValue("webhook_id"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	deliveries, total, err := c.webhooks.ListDeliveries(r.Context(), session.UserID, webhookID, limit, offset)
+	if err != nil {
+		writeServiceError(w, r, c.log, err, "failed to list webhook deliveries")
+		return
+	}
+
+	resp := dto.WebhookDeliveriesResponse{
+		Deliveries: make([]dto.WebhookDeliveryResponse, 0, len(deliveries)),
+		Total:      total,
+		HasNext:    max(offset, 0)+len(deliveries) < total,
+	}
+	for _, d := range deliveries {
+		resp.Deliveries = append(resp.Deliveries, webhookDeliveryToResponse(d))
+	}
+	util.WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleRedeliver moves a dead-lettered delivery back onto the queue.
+func (c *WebhookController) HandleRedeliver(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	webhookID := strings.TrimSpace(r.PathValue("webhook_id"))
+	deliveryID := strings.TrimSpace(r.PathValue("delivery_id"))
+
+	if err := c.webhooks.Redeliver(r.Context(), session.UserID, webhookID, deliveryID); err != nil {
+		writeServiceError(w, r, c.log, err, "failed to redeliver webhook")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusAccepted, dto.StatusResponse{Status: "queued"})
+}
+
+func webhookToResponse(e domain.WebhookEndpoint) dto.WebhookResponse {
+	types := make([]string, 0, len(e.EventTypes))
+	for _, t := range e.EventTypes {
+		types = append(types, string(t))
+	}
+	return dto.WebhookResponse{
+		ID:         e.ID,
+		URL:        e.URL,
+		EventTypes: types,
+		Enabled:    e.Enabled,
+		CreatedAt:  e.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func webhookDeliveryToResponse(d domain.WebhookDelivery) dto.WebhookDeliveryResponse {
+	resp := dto.WebhookDeliveryResponse{
+		ID:         d.ID,
+		EventID:    d.EventID,
+		EventType:  string(d.EventType),
+		Status:     d.Status,
+		Attempts:   d.Attempts,
+		LastError:  d.LastError,
+		CreatedAt:  d.CreatedAt.UTC().Format(time.RFC3339),
+		AttemptLog: make([]dto.WebhookDeliveryAttemptResponse, 0, len(d.AttemptLog)),
+	}
+	if d.Status == domain.WebhookDeliveryPending {
+		resp.NextAttemptAt = d.NextAttemptAt.UTC().Format(time.RFC3339)
+	}
+	if d.DeliveredAt != nil {
+		resp.DeliveredAt = d.DeliveredAt.UTC().Format(time.RFC3339)
+	}
+	for _, a := range d.AttemptLog {
+		resp.AttemptLog = append(resp.AttemptLog, dto.WebhookDeliveryAttemptResponse{
+			StatusCode:  a.StatusCode,
+			Error:       a.Error,
+			DurationMS:  a.DurationMS,
+			AttemptedAt: a.AttemptedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return resp
+}
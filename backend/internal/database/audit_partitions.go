@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// On Postgres audit_events is partitioned by the month of created_at, in
+// UTC: audit_events_YYYY_MM holds the events of one month,
+// audit_events_before_YYYY_MM everything the table held when it was
+// partitioned (all events before that month), and audit_events_default
+// whatever no other partition takes, such as events dated before the first
+// monthly partition.
+const (
+	auditPartitionPrefix       = "audit_events_"
+	auditLegacyPartitionPrefix = "audit_events_before_"
+	auditPartitionMonth        = "2006_01"
+)
+
+type auditPartition struct {
+	name string
+	// start is zero for the partition from before partitioning.
+	start, end time.Time
+}
+
+func auditPartitionName(month time.Time) string {
+	return auditPartitionPrefix + month.Format(auditPartitionMonth)
+}
+
+func parseAuditPartition(name string) (auditPartition, bool) {
+	if rest, ok := strings.CutPrefix(name, auditLegacyPartitionPrefix); ok {
+		end, err := time.Parse(auditPartitionMonth, rest)
+		return auditPartition{name: name, end: end}, err == nil
+	}
+	rest, ok := strings.CutPrefix(name, auditPartitionPrefix)
+	if !ok {
+		return auditPartition{}, false
+	}
+	start, err := time.Parse(auditPartitionMonth, rest)
+	return auditPartition{name: name, start: start, end: start.AddDate(0, 1, 0)}, err == nil
+}
+
+func listAuditPartitions(ctx context.Context, db *sql.DB) ([]auditPartition, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'audit_events'::regclass
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list audit partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var partitions []auditPartition
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan audit partition: %w", err)
+		}
+		if p, ok := parseAuditPartition(name); ok {
+			partitions = append(partitions, p)
+		}
+	}
+	return partitions, rows.Err()
+}
+
+// EnsureAuditPartitions creates the monthly audit_events partitions for
+// the month of from and the months-1 after it, except those the partition
+// from before partitioning already covers, and returns the names of those
+// it created.
+func EnsureAuditPartitions(ctx context.Context, db *sql.DB, from time.Time, months int) ([]string, error) {
+	existing, err := listAuditPartitions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	have := make(map[string]bool, len(existing))
+	var covered time.Time
+	for _, p := range existing {
+		have[p.name] = true
+		if p.start.IsZero() && p.end.After(covered) {
+			covered = p.end
+		}
+	}
+
+	from = from.UTC()
+	first := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	var created []string
+	for i := range months {
+		month := first.AddDate(0, i, 0)
+		name := auditPartitionName(month)
+		if have[name] || month.Before(covered) {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf(
+			`CREATE TABLE IF NOT EXISTS %s PARTITION OF audit_events FOR VALUES FROM ('%s') TO ('%s')`,
+			pq.QuoteIdentifier(name), month.Format(time.RFC3339), month.AddDate(0, 1, 0).Format(time.RFC3339),
+		)); err != nil {
+			return created, fmt.Errorf("create audit partition %s: %w", name, err)
+		}
+		created = append(created, name)
+	}
+	return created, nil
+}
+
+// DropAuditPartitionsBefore detaches and drops the audit_events partitions
+// holding only events created before the cutoff, and returns their names.
+func DropAuditPartitionsBefore(ctx context.Context, db *sql.DB, before time.Time) ([]string, error) {
+	existing, err := listAuditPartitions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var dropped []string
+	for _, p := range existing {
+		if p.end.After(before) {
+			continue
+		}
+		name := pq.QuoteIdentifier(p.name)
+		if _, err := db.ExecContext(ctx, fmt.Sprintf(
+			`ALTER TABLE audit_events DETACH PARTITION %s; DROP TABLE %s;`, name, name,
+		)); err != nil {
+			return dropped, fmt.Errorf("drop audit partition %s: %w", p.name, err)
+		}
+		dropped = append(dropped, p.name)
+	}
+	return dropped, nil
+}
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAuditPartition(t *testing.T) {
+	oct := time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+	nov := time.Date(2026, time.November, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name string
+		want auditPartition
+		ok   bool
+	}{
+		{"audit_events_2026_10", auditPartition{name: "audit_events_2026_10", start: oct, end: nov}, true},
+		{"audit_events_before_2026_11", auditPartition{name: "audit_events_before_2026_11", end: nov}, true},
+		{"audit_events_default", auditPartition{}, false},
+		{"audit_events_2026_13", auditPartition{}, false},
+	} {
+		got, ok := parseAuditPartition(tc.name)
+		if ok != tc.ok || (ok && got != tc.want) {
+			t.Errorf("parseAuditPartition(%q) = %+v, %v; want %+v, %v", tc.name, got, ok, tc.want, tc.ok)
+		}
+	}
+	if got := auditPartitionName(oct.Add(15 * 24 * time.Hour)); got != "audit_events_2026_10" {
+		t.Errorf("auditPartitionName = %q", got)
+	}
+}
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/metrics"
+)
+
+// BreakerState is where a Breaker stands.
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// Breaker is a circuit breaker for the database. After threshold calls in a
+// row fail because the database could not be reached, it opens: every call
+// then fails at once with domain.ErrDatabaseUnavailable instead of waiting
+// on a dead server. Once cooldown has passed it lets one probe through;
+// success closes it again and failure reopens it for another cooldown.
+// Errors the database answered with, such as constraint violations, are
+// not failures.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewBreaker returns a closed breaker; see Breaker.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	b := &Breaker{threshold: max(threshold, 1), cooldown: cooldown, now: time.Now}
+	b.setState(BreakerClosed)
+	return b
+}
+
+// State reports the breaker's current state.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// allow returns domain.ErrDatabaseUnavailable unless a call may go to the
+// database now. probe is true for the single call let through half-open.
+func (b *Breaker) allow() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			break
+		}
+		b.setState(BreakerHalfOpen)
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probing {
+			break
+		}
+		b.probing = true
+		return true, nil
+	default:
+		return false, nil
+	}
+	metrics.DBBreakerRejected.Inc()
+	return false, domain.ErrDatabaseUnavailable
+}
+
+// done records the outcome of a call allow admitted. driver.ErrSkip only
+// asks database/sql to take another route, so it says nothing either way.
+func (b *Breaker) done(probe bool, err error) {
+	failed := isConnectionError(err)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.probing = false
+	}
+	switch {
+	case errors.Is(err, driver.ErrSkip):
+	case !failed:
+		b.failures = 0
+		if probe {
+			b.setState(BreakerClosed)
+		}
+	case probe:
+		b.openedAt = b.now()
+		b.setState(BreakerOpen)
+	case b.state == BreakerClosed:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.failures = 0
+			b.openedAt = b.now()
+			b.setState(BreakerOpen)
+		}
+	}
+}
+
+// setState moves to state; b.mu is held.
+func (b *Breaker) setState(state BreakerState) {
+	if b.state == state {
+		return
+	}
+	if b.state != "" {
+		metrics.DBBreakerTransitions.WithLabelValues(string(state)).Inc()
+	}
+	b.state = state
+	for _, s := range []BreakerState{BreakerClosed, BreakerOpen, BreakerHalfOpen} {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+		metrics.DBBreakerState.WithLabelValues(string(s)).Set(value)
+	}
+}
+
+// isConnectionError reports whether err says the database could not be
+// reached or is shutting down, as opposed to an error in the query. A
+// caller that gave up is not the database's fault; a deadline that ran out
+// waiting on it is.
+func isConnectionError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// connection_exception, insufficient_resources and the operator
+		// intervention codes other than query_canceled.
+		switch pqErr.Code.Class() {
+		case "08", "53":
+			return true
+		case "57":
+			return pqErr.Code != "57014"
+		}
+	}
+	return false
+}
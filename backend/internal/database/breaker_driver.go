@@ -0,0 +1,196 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync/atomic"
+)
+
+// breakerConnector hands out connections whose calls go through the
+// Breaker set with DB.UseCircuitBreaker, if any. Sitting below
+// database/sql it covers every repository, transactions included, and a
+// pool short of idle connections asks Connect, so an open breaker refuses
+// new work before it waits on a dead server.
+type breakerConnector struct {
+	driver.Connector
+	breaker atomic.Pointer[Breaker]
+}
+
+func (c *breakerConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	var conn driver.Conn
+	err := c.guard(func() error {
+		var err error
+		conn, err = c.Connector.Connect(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &breakerConn{Conn: conn, connector: c}, nil
+}
+
+// guard runs fn past the breaker, if one is set, and reports the outcome.
+func (c *breakerConnector) guard(fn func() error) error {
+	b := c.breaker.Load()
+	if b == nil {
+		return fn()
+	}
+	probe, err := b.allow()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	b.done(probe, err)
+	return err
+}
+
+// breakerConn passes every optional driver interface through to the
+// wrapped connection, answering as database/sql would for one it lacks.
+type breakerConn struct {
+	driver.Conn
+	connector *breakerConnector
+}
+
+func (c *breakerConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *breakerConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	err := c.connector.guard(func() error {
+		var err error
+		if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+			stmt, err = p.PrepareContext(ctx, query)
+		} else {
+			stmt, err = c.Conn.Prepare(query)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &breakerStmt{Stmt: stmt, connector: c.connector}, nil
+}
+
+func (c *breakerConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *breakerConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var tx driver.Tx
+	err := c.connector.guard(func() error {
+		var err error
+		if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+			tx, err = b.BeginTx(ctx, opts)
+		} else {
+			tx, err = c.Conn.Begin()
+		}
+		return err
+	})
+	return tx, err
+}
+
+func (c *breakerConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	var result driver.Result
+	err := c.connector.guard(func() error {
+		var err error
+		result, err = e.ExecContext(ctx, query, args)
+		return err
+	})
+	return result, err
+}
+
+func (c *breakerConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	var rows driver.Rows
+	err := c.connector.guard(func() error {
+		var err error
+		rows, err = q.QueryContext(ctx, query, args)
+		return err
+	})
+	return rows, err
+}
+
+func (c *breakerConn) Ping(ctx context.Context) error {
+	p, ok := c.Conn.(driver.Pinger)
+	if !ok {
+		return nil
+	}
+	return c.connector.guard(func() error { return p.Ping(ctx) })
+}
+
+func (c *breakerConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *breakerConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *breakerConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type breakerStmt struct {
+	driver.Stmt
+	connector *breakerConnector
+}
+
+func (s *breakerStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var result driver.Result
+	err := s.connector.guard(func() error {
+		var err error
+		if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+			result, err = e.ExecContext(ctx, args)
+		} else {
+			result, err = s.Stmt.Exec(namedValues(args))
+		}
+		return err
+	})
+	return result, err
+}
+
+func (s *breakerStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	err := s.connector.guard(func() error {
+		var err error
+		if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+			rows, err = q.QueryContext(ctx, args)
+		} else {
+			rows, err = s.Stmt.Query(namedValues(args))
+		}
+		return err
+	})
+	return rows, err
+}
+
+func (s *breakerStmt) ColumnConverter(idx int) driver.ValueConverter {
+	if c, ok := s.Stmt.(driver.ColumnConverter); ok {
+		return c.ColumnConverter(idx)
+	}
+	return driver.DefaultParameterConverter
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
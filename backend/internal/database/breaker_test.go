@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+// refusingConnector fails every Connect as an unreachable server would,
+// until up is set.
+type refusingConnector struct {
+	up    bool
+	calls int
+}
+
+func (c *refusingConnector) Connect(context.Context) (driver.Conn, error) {
+	c.calls++
+	if !c.up {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	}
+	return nil, errors.New("connected")
+}
+
+func (c *refusingConnector) Driver() driver.Driver { return nil }
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	breaker := NewBreaker(3, 10*time.Second)
+	breaker.now = func() time.Time { return now }
+
+	target := &refusingConnector{}
+	connector := &breakerConnector{Connector: target}
+	connector.breaker.Store(breaker)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx := context.Background()
+
+	for range 3 {
+		if err := db.PingContext(ctx); errors.Is(err, domain.ErrDatabaseUnavailable) {
+			t.Fatalf("ping before the threshold: %v", err)
+		}
+	}
+	if got := breaker.State(); got != BreakerOpen {
+		t.Fatalf("state after 3 failures = %s, want open", got)
+	}
+
+	calls := target.calls
+	if err := db.PingContext(ctx); !errors.Is(err, domain.ErrDatabaseUnavailable) {
+		t.Fatalf("ping while open: err = %v, want database_unavailable", err)
+	}
+	if target.calls != calls {
+		t.Fatal("ping while open reached the database")
+	}
+
+	// A failed probe after the cooldown reopens the breaker.
+	now = now.Add(10 * time.Second)
+	if err := db.PingContext(ctx); errors.Is(err, domain.ErrDatabaseUnavailable) {
+		t.Fatalf("probe: %v", err)
+	}
+	if got := breaker.State(); got != BreakerOpen {
+		t.Fatalf("state after a failed probe = %s, want open", got)
+	}
+
+	// A probe answered by the server closes it; query errors are not
+	// connection failures.
+	now = now.Add(10 * time.Second)
+	target.up = true
+	_ = db.PingContext(ctx)
+	if got := breaker.State(); got != BreakerClosed {
+		t.Fatalf("state after a successful probe = %s, want closed", got)
+	}
+}
+
+func TestBreakerHalfOpenAdmitsOneProbe(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	breaker := NewBreaker(1, time.Second)
+	breaker.now = func() time.Time { return now }
+
+	breaker.done(false, driver.ErrBadConn)
+	now = now.Add(time.Second)
+
+	probe, err := breaker.allow()
+	if err != nil || !probe {
+		t.Fatalf("first call after cooldown: probe=%v err=%v", probe, err)
+	}
+	if _, err := breaker.allow(); !errors.Is(err, domain.ErrDatabaseUnavailable) {
+		t.Fatalf("second call while probing: err = %v", err)
+	}
+	breaker.done(probe, nil)
+	if _, err := breaker.allow(); err != nil {
+		t.Fatalf("after the probe succeeded: %v", err)
+	}
+}
+
+func TestIsConnectionError(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{context.Canceled, false},
+		{sql.ErrNoRows, false},
+		{errors.New("duplicate key value violates unique constraint"), false},
+		{context.DeadlineExceeded, true},
+		{driver.ErrBadConn, true},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+	} {
+		if got := isConnectionError(tc.err); got != tc.want {
+			t.Errorf("isConnectionError(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Driver names the SQL backend behind a DB.
+type Driver string
+
+const (
+	DriverPostgres Driver = "postgres"
+	DriverSQLite   Driver = "sqlite"
+	DriverMySQL    Driver = "mysql"
+)
+
+// DriverFor picks the backend from the DSN scheme: sqlite:<path> selects
+// SQLite, mysql:// or mariadb:// URLs MySQL, and anything else (postgres://
+// URLs or key=value strings) Postgres.
+func DriverFor(dsn string) Driver {
+	lower := strings.ToLower(dsn)
+	switch {
+	case strings.HasPrefix(lower, "sqlite:"):
+		return DriverSQLite
+	case strings.HasPrefix(lower, "mysql://"), strings.HasPrefix(lower, "mariadb://"):
+		return DriverMySQL
+	}
+	return DriverPostgres
+}
+
+type DB struct {
+	sql       *sql.DB
+	replica   *sql.DB
+	driver    Driver
+	connector *breakerConnector
+}
+
+// New opens and pings the database named by dsn; see DriverFor.
+func New(ctx context.Context, dsn string) (*DB, error) {
+	driver := DriverFor(dsn)
+
+	var (
+		conn      *sql.DB
+		connector *breakerConnector
+		err       error
+	)
+	switch driver {
+	case DriverSQLite:
+		conn, err = openSQLite(ctx, dsn)
+	case DriverMySQL:
+		conn, connector, err = openMySQL(ctx, dsn)
+	default:
+		conn, connector, err = openPostgres(ctx, dsn)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &DB{sql: conn, driver: driver, connector: connector}, nil
+}
+
+// OpenAndMigrate opens the database named by dsn and brings its schema up
+// to date. It makes a single attempt; see ConnectAndMigrate for retries.
+func OpenAndMigrate(ctx context.Context, dsn string) (*DB, error) {
+	return ConnectAndMigrate(ctx, dsn, Retry{})
+}
+
+func (d *DB) SQL() *sql.DB {
+	return d.sql
+}
+
+func (d *DB) Driver() Driver {
+	return d.driver
+}
+
+// AttachReplica opens and pings the Postgres read replica named by dsn. On
+// success Replica returns it; on error the DB is left without one.
+func (d *DB) AttachReplica(ctx context.Context, dsn string) error {
+	if d.driver != DriverPostgres || DriverFor(dsn) != DriverPostgres {
+		return fmt.Errorf("read replicas are only supported for postgres, not %s", DriverFor(dsn))
+	}
+	conn, _, err := openPostgres(ctx, dsn)
+	if err != nil {
+		return fmt.Errorf("read replica: %w", err)
+	}
+	if d.replica != nil {
+		_ = d.replica.Close()
+	}
+	d.replica = conn
+	return nil
+}
+
+// UseCircuitBreaker puts b in front of every call to the primary, so an
+// unreachable Postgres or MySQL fails requests fast with
+// domain.ErrDatabaseUnavailable; see Breaker. SQLite, being in process, has
+// nothing to break, and the replica keeps its own fallback to the primary.
+func (d *DB) UseCircuitBreaker(b *Breaker) {
+	if d.connector != nil {
+		d.connector.breaker.Store(b)
+	}
+}
+
+// Replica returns the read replica attached with AttachReplica, or nil.
+func (d *DB) Replica() *sql.DB {
+	return d.replica
+}
+
+func (d *DB) Close() error {
+	if d == nil || d.sql == nil {
+		return nil
+	}
+	var replicaErr error
+	if d.replica != nil {
+		replicaErr = d.replica.Close()
+	}
+	return errors.Join(d.sql.Close(), replicaErr)
+}
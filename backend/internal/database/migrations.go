@@ -4,33 +4,93 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
 )
 
 const UpSQL = `
 CREATE TABLE IF NOT EXISTS users (
   id UUID PRIMARY KEY,
   email TEXT UNIQUE NOT NULL,
+  email_canonical TEXT,
   name TEXT,
   email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+  merged_into_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  merged_at TIMESTAMPTZ,
   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
   updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
 CREATE TABLE IF NOT EXISTS auth_credentials (
   user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
-  algo TEXT NOT NULL,
-  params JSONB NOT NULL,
+  algo TEXT,
+  params JSONB,
   salt BYTEA NOT NULL,
-  password_hash BYTEA NOT NULL,
+  password_hash BYTEA,
   mfa_totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
   mfa_totp_secret_enc BYTEA,
   totp_failed_attempts INTEGER NOT NULL DEFAULT 0,
   totp_window_started_at TIMESTAMPTZ,
   totp_locked_until TIMESTAMPTZ,
+  credentials_compromised_at TIMESTAMPTZ,
   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
   updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+CREATE TABLE IF NOT EXISTS passkeys (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  credential_id BYTEA NOT NULL UNIQUE,
+  public_key BYTEA NOT NULL,
+  sign_count BIGINT NOT NULL DEFAULT 0,
+  name TEXT NOT NULL DEFAULT '',
+  prf_salt BYTEA,
+  wrapped_key BYTEA,
+  wrap_nonce BYTEA,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  last_used_at TIMESTAMPTZ
+);
+
+-- user_id has no foreign key: a registration challenge names an account
+-- that does not exist yet.
+CREATE TABLE IF NOT EXISTS passkey_challenges (
+  id UUID PRIMARY KEY,
+  purpose TEXT NOT NULL,
+  user_id UUID,
+  email TEXT NOT NULL DEFAULT '',
+  name TEXT NOT NULL DEFAULT '',
+  challenge BYTEA NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- user_id is the account that approved the request; it is NULL while the
+-- request is pending.
+CREATE TABLE IF NOT EXISTS qr_login_requests (
+  id UUID PRIMARY KEY,
+  code_hash BYTEA NOT NULL,
+  poll_token_hash BYTEA NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending',
+  user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+  device_name TEXT NOT NULL DEFAULT '',
+  ip_address TEXT NOT NULL DEFAULT '',
+  user_agent TEXT NOT NULL DEFAULT '',
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Tokens emailed by the forgot-password flow, stored as peppered hashes.
+-- used_at is set when one sets a new password; rows go once they expire.
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  token_hash BYTEA NOT NULL UNIQUE,
+  expires_at TIMESTAMPTZ NOT NULL,
+  used_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
 CREATE TABLE IF NOT EXISTS user_recovery (
   user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
   recovery_key_hash BYTEA NOT NULL,
@@ -92,6 +152,30 @@ CREATE TABLE IF NOT EXISTS vault_item_versions (
   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+-- Browser extension support: the HMACs of an item's URIs, which clients
+-- compute so the server can match a page to items without seeing URLs, and
+-- how often each item was used to autofill.
+CREATE TABLE IF NOT EXISTS vault_item_uri_hmacs (
+  item_id UUID NOT NULL REFERENCES vault_items(id) ON DELETE CASCADE,
+  owner_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  uri_hmac BYTEA NOT NULL,
+  PRIMARY KEY (item_id, uri_hmac)
+);
+
+CREATE TABLE IF NOT EXISTS vault_item_usage (
+  item_id UUID PRIMARY KEY REFERENCES vault_items(id) ON DELETE CASCADE,
+  owner_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  use_count BIGINT NOT NULL DEFAULT 0,
+  last_used_at TIMESTAMPTZ NOT NULL
+);
+
+-- Purged items leave a tombstone so delta syncs can report them deleted.
+CREATE TABLE IF NOT EXISTS vault_item_tombstones (
+  item_id UUID PRIMARY KEY,
+  owner_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  deleted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
 CREATE TABLE IF NOT EXISTS vault_shares (
   item_id UUID NOT NULL REFERENCES vault_items(id) ON DELETE CASCADE,
   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
@@ -99,11 +183,55 @@ CREATE TABLE IF NOT EXISTS vault_shares (
   dek_wrapped BYTEA NOT NULL,
   wrap_nonce BYTEA,
   permissions TEXT NOT NULL DEFAULT 'read',
+  not_before TIMESTAMPTZ,
+  expires_at TIMESTAMPTZ,
+  schedule JSONB,
+  first_accessed_at TIMESTAMPTZ,
   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
   updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
   PRIMARY KEY (item_id, user_id)
 );
 
+-- An owner's request to be told when a recipient first opens a share of
+-- the item.
+CREATE TABLE IF NOT EXISTS vault_item_access_alerts (
+  item_id UUID PRIMARY KEY REFERENCES vault_items(id) ON DELETE CASCADE,
+  owner_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  email BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Family members asking for a share of an item. decided_by_user_id is the
+-- owner or the manage-share recipient who approved or denied the request.
+CREATE TABLE IF NOT EXISTS access_requests (
+  id UUID PRIMARY KEY,
+  item_id UUID NOT NULL REFERENCES vault_items(id) ON DELETE CASCADE,
+  owner_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  requester_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  reason TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL DEFAULT 'pending',
+  decided_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  decided_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Shares parked for emails without an account. recipient_user_id is set
+-- once the invitee has registered and uploaded keys; the sharer then
+-- completes the share and the row is deleted.
+CREATE TABLE IF NOT EXISTS share_invitations (
+  id UUID PRIMARY KEY,
+  item_id UUID NOT NULL REFERENCES vault_items(id) ON DELETE CASCADE,
+  shared_by_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  email TEXT NOT NULL,
+  permissions TEXT NOT NULL DEFAULT 'read',
+  status TEXT NOT NULL DEFAULT 'pending',
+  recipient_user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+  ready_at TIMESTAMPTZ,
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  UNIQUE (item_id, email)
+);
+
 CREATE TABLE IF NOT EXISTS vault_attachments (
   id UUID PRIMARY KEY,
   item_id UUID NOT NULL REFERENCES vault_items(id) ON DELETE CASCADE,
@@ -115,24 +243,102 @@ CREATE TABLE IF NOT EXISTS vault_attachments (
   updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+CREATE TABLE IF NOT EXISTS user_devices (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  key_hash BYTEA NOT NULL,
+  fingerprinted BOOLEAN NOT NULL DEFAULT FALSE,
+  label TEXT NOT NULL DEFAULT '',
+  first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  UNIQUE (user_id, key_hash)
+);
+
 CREATE TABLE IF NOT EXISTS sessions (
   id UUID PRIMARY KEY,
   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
   refresh_token_hash BYTEA NOT NULL,
   device_name TEXT,
-  ip_address INET,
+  ip_address TEXT,
   user_agent TEXT,
   expires_at TIMESTAMPTZ NOT NULL,
   revoked_at TIMESTAMPTZ,
+  step_up_required BOOLEAN NOT NULL DEFAULT FALSE,
+  device_id UUID REFERENCES user_devices(id) ON DELETE SET NULL,
+  dpop_jkt TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  last_used_at TIMESTAMPTZ
+);
+
+-- Session tokens replaced by a refresh. Presenting one again means it was
+-- copied before it was rotated, so the session it belonged to is revoked.
+CREATE TABLE IF NOT EXISTS retired_session_tokens (
+  token_hash BYTEA PRIMARY KEY,
+  session_id UUID NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+  retired_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Tokens are unique per platform: when a phone changes hands, or accounts,
+-- registering its token again moves it to the new account.
+CREATE TABLE IF NOT EXISTS push_tokens (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  device_id UUID REFERENCES user_devices(id) ON DELETE SET NULL,
+  platform TEXT NOT NULL,
+  token TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  UNIQUE (platform, token)
+);
+
+-- Vault items published for CI pipelines, and the API keys service accounts
+-- read them with. A secret's ciphertext is a copy of the item's value the
+-- client encrypted for the pipelines; its name is unique per owner.
+CREATE TABLE IF NOT EXISTS machine_secrets (
+  id UUID PRIMARY KEY,
+  owner_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  item_id UUID NOT NULL UNIQUE REFERENCES vault_items(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  ciphertext BYTEA NOT NULL,
+  nonce BYTEA NOT NULL,
+  algo_version TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  UNIQUE (owner_user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS machine_keys (
+  id UUID PRIMARY KEY,
+  owner_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  key_hash BYTEA NOT NULL UNIQUE,
+  prefix TEXT NOT NULL,
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  expires_at TIMESTAMPTZ,
+  last_used_at TIMESTAMPTZ,
   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+-- Partitioned by month of created_at; see EnsureAuditPartitions. The key
+-- includes created_at, as a partitioned table's must.
 CREATE TABLE IF NOT EXISTS audit_events (
-  id UUID PRIMARY KEY,
+  id UUID NOT NULL,
   user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  actor_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
   event_type TEXT NOT NULL,
   event_data JSONB,
-  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+  ip_address TEXT,
+  user_agent TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+CREATE TABLE IF NOT EXISTS audit_export_cursors (
+  exporter TEXT PRIMARY KEY,
+  last_recorded_at TIMESTAMPTZ NOT NULL,
+  last_event_id UUID NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
 CREATE TABLE IF NOT EXISTS backups_registry (
@@ -163,29 +369,192 @@ CREATE TABLE IF NOT EXISTS family_memberships (
   CHECK (user_id != friend_id)
 );
 
+CREATE TABLE IF NOT EXISTS job_runs (
+  id UUID PRIMARY KEY,
+  job_name TEXT NOT NULL,
+  status TEXT NOT NULL CHECK (status IN ('running', 'succeeded', 'failed')),
+  error TEXT,
+  started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  finished_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS outbox_events (
+  id UUID PRIMARY KEY,
+  topic TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT,
+  next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  dispatched_at TIMESTAMPTZ,
+  failed_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+  user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  preferences JSONB NOT NULL DEFAULT '{}',
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Opt-in binding of sessions to the client that signed them in; no row
+-- means sessions are not bound.
+CREATE TABLE IF NOT EXISTS session_bindings (
+  user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  bind_ip_prefix BOOLEAN NOT NULL DEFAULT FALSE,
+  bind_user_agent BOOLEAN NOT NULL DEFAULT FALSE,
+  on_mismatch TEXT NOT NULL DEFAULT 'step_up' CHECK (on_mismatch IN ('revoke', 'step_up')),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  url TEXT NOT NULL,
+  secret_enc BYTEA NOT NULL,
+  event_types TEXT[] NOT NULL DEFAULT '{}',
+  enabled BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id UUID PRIMARY KEY,
+  endpoint_id UUID NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+  event_id UUID NOT NULL,
+  event_type TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'dead')),
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT,
+  next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  delivered_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  UNIQUE (endpoint_id, event_id)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+  id UUID PRIMARY KEY,
+  delivery_id UUID NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+  status_code INTEGER,
+  error TEXT,
+  duration_ms INTEGER NOT NULL DEFAULT 0,
+  attempted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS feature_flags (
+  key TEXT PRIMARY KEY,
+  enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  description TEXT NOT NULL DEFAULT '',
+  updated_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS data_exports (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready')),
+  archive BYTEA,
+  size_bytes BIGINT NOT NULL DEFAULT 0,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  completed_at TIMESTAMPTZ,
+  expires_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS ip_bans (
+  ip TEXT PRIMARY KEY,
+  offenses INT NOT NULL DEFAULT 1,
+  reason TEXT NOT NULL DEFAULT '',
+  banned_until TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS maintenance_state (
+  id SMALLINT PRIMARY KEY CHECK (id = 1),
+  enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  message TEXT NOT NULL DEFAULT '',
+  since TIMESTAMPTZ,
+  updated_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports(user_id);
+CREATE INDEX IF NOT EXISTS idx_passkeys_user_id ON passkeys(user_id);
+CREATE INDEX IF NOT EXISTS idx_passkey_challenges_expires_at ON passkey_challenges(expires_at);
+CREATE INDEX IF NOT EXISTS idx_qr_login_requests_expires_at ON qr_login_requests(expires_at);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_expires_at ON password_reset_tokens(expires_at);
+CREATE INDEX IF NOT EXISTS idx_ip_bans_banned_until ON ip_bans(banned_until);
 CREATE INDEX IF NOT EXISTS idx_vault_items_owner_user_id ON vault_items(owner_user_id);
 CREATE INDEX IF NOT EXISTS idx_vault_items_owner_deleted_at ON vault_items(owner_user_id, deleted_at);
+CREATE INDEX IF NOT EXISTS idx_vault_items_owner_updated_at ON vault_items(owner_user_id, updated_at DESC);
 CREATE INDEX IF NOT EXISTS idx_vault_item_versions_item_id ON vault_item_versions(item_id);
+CREATE INDEX IF NOT EXISTS idx_vault_item_uri_hmacs_owner_hmac ON vault_item_uri_hmacs(owner_user_id, uri_hmac);
+CREATE INDEX IF NOT EXISTS idx_vault_item_usage_owner_user_id ON vault_item_usage(owner_user_id);
+CREATE INDEX IF NOT EXISTS idx_vault_item_tombstones_owner_deleted_at ON vault_item_tombstones(owner_user_id, deleted_at);
 CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
-CREATE INDEX IF NOT EXISTS idx_sessions_refresh_token_hash ON sessions(refresh_token_hash);
+CREATE INDEX IF NOT EXISTS idx_retired_session_tokens_session_id ON retired_session_tokens(session_id);
+CREATE INDEX IF NOT EXISTS idx_push_tokens_user_id ON push_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_push_tokens_updated_at ON push_tokens(updated_at);
+CREATE INDEX IF NOT EXISTS idx_machine_keys_owner_user_id ON machine_keys(owner_user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_active_refresh_token_hash ON sessions(refresh_token_hash) WHERE revoked_at IS NULL;
 CREATE INDEX IF NOT EXISTS idx_audit_events_user_id ON audit_events(user_id);
 CREATE INDEX IF NOT EXISTS idx_vault_shares_user_id ON vault_shares(user_id);
+CREATE INDEX IF NOT EXISTS idx_access_requests_item_id ON access_requests(item_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_access_requests_requester_user_id ON access_requests(requester_user_id);
+CREATE INDEX IF NOT EXISTS idx_share_invitations_email ON share_invitations(email) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_share_invitations_shared_by_user_id ON share_invitations(shared_by_user_id);
+CREATE INDEX IF NOT EXISTS idx_share_invitations_expires_at ON share_invitations(expires_at);
+CREATE INDEX IF NOT EXISTS idx_vault_item_access_alerts_owner_user_id ON vault_item_access_alerts(owner_user_id);
 CREATE INDEX IF NOT EXISTS idx_vault_folders_owner_user_id ON vault_folders(owner_user_id);
 CREATE INDEX IF NOT EXISTS idx_vault_attachments_item_id ON vault_attachments(item_id);
 CREATE INDEX IF NOT EXISTS idx_backups_registry_created_by_user_id ON backups_registry(created_by_user_id);
 CREATE INDEX IF NOT EXISTS idx_totp_recovery_codes_user_id ON totp_recovery_codes(user_id);
 CREATE INDEX IF NOT EXISTS idx_family_memberships_user_id ON family_memberships(user_id);
 CREATE INDEX IF NOT EXISTS idx_family_memberships_friend_id ON family_memberships(friend_id);
+CREATE INDEX IF NOT EXISTS idx_job_runs_job_name_started_at ON job_runs(job_name, started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events(next_attempt_at) WHERE dispatched_at IS NULL AND failed_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_webhook_endpoints_user_id ON webhook_endpoints(user_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_endpoint_created ON webhook_deliveries(endpoint_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery_id ON webhook_delivery_attempts(delivery_id);
 `
 
 const DropSQL = `
+DROP TABLE IF EXISTS machine_keys CASCADE;
+DROP TABLE IF EXISTS machine_secrets CASCADE;
+DROP TABLE IF EXISTS push_tokens CASCADE;
+DROP TABLE IF EXISTS retired_session_tokens CASCADE;
+DROP TABLE IF EXISTS qr_login_requests CASCADE;
+DROP TABLE IF EXISTS password_reset_tokens CASCADE;
+DROP TABLE IF EXISTS passkey_challenges CASCADE;
+DROP TABLE IF EXISTS passkeys CASCADE;
+DROP TABLE IF EXISTS ip_bans CASCADE;
+DROP TABLE IF EXISTS data_exports CASCADE;
+DROP TABLE IF EXISTS maintenance_state CASCADE;
+DROP TABLE IF EXISTS feature_flags CASCADE;
+DROP TABLE IF EXISTS webhook_delivery_attempts CASCADE;
+DROP TABLE IF EXISTS webhook_deliveries CASCADE;
+DROP TABLE IF EXISTS webhook_endpoints CASCADE;
+DROP TABLE IF EXISTS session_bindings CASCADE;
+DROP TABLE IF EXISTS notification_preferences CASCADE;
+DROP TABLE IF EXISTS outbox_events CASCADE;
+DROP TABLE IF EXISTS job_runs CASCADE;
 DROP TABLE IF EXISTS family_memberships CASCADE;
 DROP TABLE IF EXISTS totp_recovery_codes CASCADE;
 DROP TABLE IF EXISTS backups_registry CASCADE;
+DROP TABLE IF EXISTS audit_export_cursors CASCADE;
 DROP TABLE IF EXISTS audit_events CASCADE;
 DROP TABLE IF EXISTS sessions CASCADE;
+DROP TABLE IF EXISTS user_devices CASCADE;
 DROP TABLE IF EXISTS vault_attachments CASCADE;
+DROP TABLE IF EXISTS share_invitations CASCADE;
+DROP TABLE IF EXISTS vault_item_access_alerts CASCADE;
+DROP TABLE IF EXISTS access_requests CASCADE;
 DROP TABLE IF EXISTS vault_shares CASCADE;
+DROP TABLE IF EXISTS vault_item_tombstones CASCADE;
+DROP TABLE IF EXISTS vault_item_usage CASCADE;
+DROP TABLE IF EXISTS vault_item_uri_hmacs CASCADE;
 DROP TABLE IF EXISTS vault_item_versions CASCADE;
 DROP TABLE IF EXISTS vault_items CASCADE;
 DROP TABLE IF EXISTS vault_folders CASCADE;
@@ -195,7 +564,19 @@ DROP TABLE IF EXISTS auth_credentials CASCADE;
 DROP TABLE IF EXISTS users CASCADE;
 `
 
-func MigrateUp(ctx context.Context, db *sql.DB) error {
+// MigrateUp brings the schema of db up to date, using the migrations of its
+// driver.
+func MigrateUp(ctx context.Context, db *DB) error {
+	switch db.Driver() {
+	case DriverSQLite:
+		return migrateSQLiteUp(ctx, db.SQL())
+	case DriverMySQL:
+		return migrateMySQLUp(ctx, db.SQL())
+	}
+	return migratePostgresUp(ctx, db.SQL())
+}
+
+func migratePostgresUp(ctx context.Context, db *sql.DB) error {
 	if _, err := db.ExecContext(ctx, UpSQL); err != nil {
 		return fmt.Errorf("run schema migration up: %w", err)
 	}
@@ -211,20 +592,263 @@ func MigrateUp(ctx context.Context, db *sql.DB) error {
 	`); err != nil {
 		return fmt.Errorf("ensure vault_items.deleted_at exists: %w", err)
 	}
+	if _, err := db.ExecContext(ctx, `
+		ALTER TABLE audit_events
+		ADD COLUMN IF NOT EXISTS actor_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+		ADD COLUMN IF NOT EXISTS ip_address TEXT,
+		ADD COLUMN IF NOT EXISTS user_agent TEXT;
+	`); err != nil {
+		return fmt.Errorf("ensure audit_events request columns exist: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `
+		ALTER TABLE audit_events
+		ADD COLUMN IF NOT EXISTS recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+		CREATE INDEX IF NOT EXISTS idx_audit_events_recorded_at_id ON audit_events(recorded_at, id);
+	`); err != nil {
+		return fmt.Errorf("ensure audit_events.recorded_at exists: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `
+		ALTER TABLE users
+		ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin'));
+	`); err != nil {
+		return fmt.Errorf("ensure users.role exists: %w", err)
+	}
+	// The address with provider aliases folded (util.CanonicalEmail), set
+	// only while EMAIL_ALIAS_NORMALIZATION is on.
+	if _, err := db.ExecContext(ctx, `
+		ALTER TABLE users
+		ADD COLUMN IF NOT EXISTS email_canonical TEXT;
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_canonical ON users(email_canonical) WHERE email_canonical IS NOT NULL;
+	`); err != nil {
+		return fmt.Errorf("ensure users.email_canonical exists: %w", err)
+	}
+	// Set on the tombstone an account merge leaves behind.
+	if _, err := db.ExecContext(ctx, `
+		ALTER TABLE users
+		ADD COLUMN IF NOT EXISTS merged_into_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+		ADD COLUMN IF NOT EXISTS merged_at TIMESTAMPTZ;
+	`); err != nil {
+		return fmt.Errorf("ensure users merge columns exist: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `
+		ALTER TABLE sessions
+		ADD COLUMN IF NOT EXISTS step_up_required BOOLEAN NOT NULL DEFAULT FALSE;
+	`); err != nil {
+		return fmt.Errorf("ensure sessions.step_up_required exists: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `
+		ALTER TABLE sessions
+		ADD COLUMN IF NOT EXISTS device_id UUID REFERENCES user_devices(id) ON DELETE SET NULL;
+	`); err != nil {
+		return fmt.Errorf("ensure sessions.device_id exists: %w", err)
+	}
+	// Thumbprint of the key a DPoP-bound session's requests are signed with.
+	if _, err := db.ExecContext(ctx, `
+		ALTER TABLE sessions
+		ADD COLUMN IF NOT EXISTS dpop_jkt TEXT;
+	`); err != nil {
+		return fmt.Errorf("ensure sessions.dpop_jkt exists: %w", err)
+	}
+	// When the session last authenticated a request, to within a minute.
+	if _, err := db.ExecContext(ctx, `
+		ALTER TABLE sessions
+		ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMPTZ;
+	`); err != nil {
+		return fmt.Errorf("ensure sessions.last_used_at exists: %w", err)
+	}
+	// Validity window and schedule of a time-limited share.
+	if _, err := db.ExecContext(ctx, `
+		ALTER TABLE vault_shares
+		ADD COLUMN IF NOT EXISTS not_before TIMESTAMPTZ,
+		ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ,
+		ADD COLUMN IF NOT EXISTS schedule JSONB;
+		CREATE INDEX IF NOT EXISTS idx_vault_shares_expires_at ON vault_shares(expires_at) WHERE expires_at IS NOT NULL;
+	`); err != nil {
+		return fmt.Errorf("ensure vault_shares window columns exist: %w", err)
+	}
+	// When the recipient was first sent the shared item.
+	if _, err := db.ExecContext(ctx, `
+		ALTER TABLE vault_shares
+		ADD COLUMN IF NOT EXISTS first_accessed_at TIMESTAMPTZ;
+	`); err != nil {
+		return fmt.Errorf("ensure vault_shares.first_accessed_at exists: %w", err)
+	}
+	// Passkey-only accounts have no password.
+	if _, err := db.ExecContext(ctx, `
+		ALTER TABLE auth_credentials
+		ALTER COLUMN algo DROP NOT NULL,
+		ALTER COLUMN params DROP NOT NULL,
+		ALTER COLUMN password_hash DROP NOT NULL;
+	`); err != nil {
+		return fmt.Errorf("allow auth_credentials without a password: %w", err)
+	}
+	// Set while an admin has flagged the credentials as compromised.
+	if _, err := db.ExecContext(ctx, `
+		ALTER TABLE auth_credentials
+		ADD COLUMN IF NOT EXISTS credentials_compromised_at TIMESTAMPTZ;
+	`); err != nil {
+		return fmt.Errorf("ensure auth_credentials.credentials_compromised_at exists: %w", err)
+	}
+	// IP addresses were INET; TEXT also holds them encrypted (see
+	// util.MetadataCipher).
+	if _, err := db.ExecContext(ctx, `
+		DO $$
+		BEGIN
+			IF EXISTS (SELECT 1 FROM information_schema.columns
+				WHERE table_name = 'sessions' AND column_name = 'ip_address' AND data_type = 'inet') THEN
+				ALTER TABLE sessions ALTER COLUMN ip_address TYPE TEXT USING host(ip_address);
+			END IF;
+			IF EXISTS (SELECT 1 FROM information_schema.columns
+				WHERE table_name = 'audit_events' AND column_name = 'ip_address' AND data_type = 'inet') THEN
+				ALTER TABLE audit_events ALTER COLUMN ip_address TYPE TEXT USING host(ip_address);
+			END IF;
+		END $$;
+	`); err != nil {
+		return fmt.Errorf("convert ip_address columns to text: %w", err)
+	}
+	// audit_events used to be a plain table. It becomes the partition
+	// audit_events_before_YYYY_MM of a partitioned table of the same shape,
+	// covering everything before the month after its newest event, indexes
+	// and keys renamed out of the way first so the parent can take them.
+	if _, err := db.ExecContext(ctx, `
+		DO $$
+		DECLARE
+			bound TIMESTAMP;
+			legacy TEXT;
+		BEGIN
+			IF (SELECT relkind FROM pg_class WHERE oid = 'audit_events'::regclass) <> 'r' THEN
+				RETURN;
+			END IF;
+			SELECT date_trunc('month', GREATEST(COALESCE(MAX(created_at), NOW()), NOW()) AT TIME ZONE 'UTC') + INTERVAL '1 month'
+			INTO bound FROM audit_events;
+			legacy := 'audit_events_before_' || to_char(bound, 'YYYY_MM');
+
+			ALTER TABLE audit_events RENAME CONSTRAINT audit_events_pkey TO audit_events_legacy_pkey;
+			ALTER INDEX IF EXISTS idx_audit_events_user_id RENAME TO idx_audit_events_legacy_user_id;
+			ALTER INDEX IF EXISTS idx_audit_events_recorded_at_id RENAME TO idx_audit_events_legacy_recorded_at_id;
+			EXECUTE format('ALTER TABLE audit_events RENAME TO %I', legacy);
+
+			EXECUTE format('CREATE TABLE audit_events (LIKE %I INCLUDING DEFAULTS) PARTITION BY RANGE (created_at)', legacy);
+			ALTER TABLE audit_events
+				ADD PRIMARY KEY (id, created_at),
+				ADD FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL,
+				ADD FOREIGN KEY (actor_user_id) REFERENCES users(id) ON DELETE SET NULL;
+			CREATE INDEX idx_audit_events_user_id ON audit_events(user_id);
+			CREATE INDEX idx_audit_events_recorded_at_id ON audit_events(recorded_at, id);
+			EXECUTE format('ALTER TABLE audit_events ATTACH PARTITION %I FOR VALUES FROM (MINVALUE) TO (%L)', legacy, bound AT TIME ZONE 'UTC');
+		END $$;
+		CREATE TABLE IF NOT EXISTS audit_events_default PARTITION OF audit_events DEFAULT;
+	`); err != nil {
+		return fmt.Errorf("partition audit_events: %w", err)
+	}
+	if _, err := EnsureAuditPartitions(ctx, db, time.Now(), domain.AuditPartitionsAhead); err != nil {
+		return err
+	}
+	// Session lookups only ever want live sessions; the partial index that
+	// replaced the full one is smaller and skips revoked rows.
+	if _, err := db.ExecContext(ctx, `
+		DROP INDEX IF EXISTS idx_sessions_refresh_token_hash;
+	`); err != nil {
+		return fmt.Errorf("drop superseded session token index: %w", err)
+	}
+	// Row-level security on the vault tables, as defense in depth behind the
+	// owner_user_id predicates. The repositories set app.current_user_id for
+	// every owner-scoped call (see repository.asOwner); while it is unset
+	// they hide every row, so a query that lost its scope fails closed.
+	// Background jobs, admin tooling and share lookups set app.rls_bypass
+	// instead (repository.asSystem). FORCE makes the policies bind the table
+	// owner, which the API usually connects as; superusers and BYPASSRLS
+	// roles still skip them.
+	if _, err := db.ExecContext(ctx, `
+		ALTER TABLE vault_items ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE vault_items FORCE ROW LEVEL SECURITY;
+		DROP POLICY IF EXISTS vault_items_owner ON vault_items;
+		CREATE POLICY vault_items_owner ON vault_items
+			USING (
+				current_setting('app.rls_bypass', true) = 'on'
+				OR owner_user_id = NULLIF(current_setting('app.current_user_id', true), '')::uuid
+				OR EXISTS (
+					SELECT 1 FROM vault_shares vs
+					WHERE vs.item_id = vault_items.id
+					  AND vs.user_id = NULLIF(current_setting('app.current_user_id', true), '')::uuid
+				)
+			)
+			WITH CHECK (
+				current_setting('app.rls_bypass', true) = 'on'
+				OR owner_user_id = NULLIF(current_setting('app.current_user_id', true), '')::uuid
+			);
+
+		ALTER TABLE vault_item_versions ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE vault_item_versions FORCE ROW LEVEL SECURITY;
+		DROP POLICY IF EXISTS vault_item_versions_owner ON vault_item_versions;
+		CREATE POLICY vault_item_versions_owner ON vault_item_versions
+			USING (
+				current_setting('app.rls_bypass', true) = 'on'
+				OR owner_user_id = NULLIF(current_setting('app.current_user_id', true), '')::uuid
+			);
+
+		ALTER TABLE vault_item_uri_hmacs ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE vault_item_uri_hmacs FORCE ROW LEVEL SECURITY;
+		DROP POLICY IF EXISTS vault_item_uri_hmacs_owner ON vault_item_uri_hmacs;
+		CREATE POLICY vault_item_uri_hmacs_owner ON vault_item_uri_hmacs
+			USING (
+				current_setting('app.rls_bypass', true) = 'on'
+				OR owner_user_id = NULLIF(current_setting('app.current_user_id', true), '')::uuid
+			);
+
+		ALTER TABLE vault_item_usage ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE vault_item_usage FORCE ROW LEVEL SECURITY;
+		DROP POLICY IF EXISTS vault_item_usage_owner ON vault_item_usage;
+		CREATE POLICY vault_item_usage_owner ON vault_item_usage
+			USING (
+				current_setting('app.rls_bypass', true) = 'on'
+				OR owner_user_id = NULLIF(current_setting('app.current_user_id', true), '')::uuid
+			);
+
+		ALTER TABLE vault_item_tombstones ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE vault_item_tombstones FORCE ROW LEVEL SECURITY;
+		DROP POLICY IF EXISTS vault_item_tombstones_owner ON vault_item_tombstones;
+		CREATE POLICY vault_item_tombstones_owner ON vault_item_tombstones
+			USING (
+				current_setting('app.rls_bypass', true) = 'on'
+				OR owner_user_id = NULLIF(current_setting('app.current_user_id', true), '')::uuid
+			);
+
+		ALTER TABLE vault_folders ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE vault_folders FORCE ROW LEVEL SECURITY;
+		DROP POLICY IF EXISTS vault_folders_owner ON vault_folders;
+		CREATE POLICY vault_folders_owner ON vault_folders
+			USING (
+				current_setting('app.rls_bypass', true) = 'on'
+				OR owner_user_id = NULLIF(current_setting('app.current_user_id', true), '')::uuid
+			);
+	`); err != nil {
+		return fmt.Errorf("enable row level security on vault tables: %w", err)
+	}
 	return nil
 }
 
-func MigrateDown(ctx context.Context, db *sql.DB) error {
+func MigrateDown(ctx context.Context, db *DB) error {
 	// For this initial phase, Down and Drop are equivalent
-	if _, err := db.ExecContext(ctx, DropSQL); err != nil {
+	if _, err := db.SQL().ExecContext(ctx, dropSQL(db)); err != nil {
 		return fmt.Errorf("run schema migration down: %w", err)
 	}
 	return nil
 }
 
-func DropAll(ctx context.Context, db *sql.DB) error {
-	if _, err := db.ExecContext(ctx, DropSQL); err != nil {
+func DropAll(ctx context.Context, db *DB) error {
+	if _, err := db.SQL().ExecContext(ctx, dropSQL(db)); err != nil {
 		return fmt.Errorf("run schema drop all: %w", err)
 	}
 	return nil
 }
+
+func dropSQL(db *DB) string {
+	switch db.Driver() {
+	case DriverSQLite:
+		return SQLiteDropSQL
+	case DriverMySQL:
+		return MySQLDropSQL
+	}
+	return DropSQL
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysqlConfig turns a mysql:// or mariadb:// URL into the driver's config.
+// Sessions run in UTC so DATETIME columns round-trip time.Time values,
+// multi-statement execs are allowed for the migration scripts, and updates
+// report the rows they matched, as Postgres does, rather than only those
+// they changed. Query parameters of the URL are passed through as session
+// variables or driver options.
+func mysqlConfig(dsn string) (*mysql.Config, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("parse mysql DSN: %w", err)
+	}
+
+	cfg := mysql.NewConfig()
+	cfg.Net = "tcp"
+	cfg.Addr = u.Host
+	if u.Port() == "" {
+		cfg.Addr = net.JoinHostPort(u.Hostname(), "3306")
+	}
+	cfg.User = u.User.Username()
+	cfg.Passwd, _ = u.User.Password()
+	cfg.DBName = u.Path
+	if len(cfg.DBName) > 0 && cfg.DBName[0] == '/' {
+		cfg.DBName = cfg.DBName[1:]
+	}
+	if cfg.DBName == "" {
+		return nil, fmt.Errorf("mysql DSN has no database name")
+	}
+
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	cfg.MultiStatements = true
+	cfg.ClientFoundRows = true
+	cfg.Params = map[string]string{"time_zone": "'+00:00'"}
+
+	if u.RawQuery == "" {
+		return cfg, nil
+	}
+	// Options given later win, so reparsing the formatted config with the
+	// URL's query appended lets the DSN override any of the defaults above.
+	formatted := cfg.FormatDSN()
+	sep := "?"
+	if strings.Contains(formatted, "?") {
+		sep = "&"
+	}
+	cfg, err = mysql.ParseDSN(formatted + sep + u.RawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("parse mysql DSN options: %w", err)
+	}
+	return cfg, nil
+}
+
+func openMySQL(ctx context.Context, dsn string) (*sql.DB, *breakerConnector, error) {
+	cfg, err := mysqlConfig(dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open mysql: %w", err)
+	}
+	breaker := &breakerConnector{Connector: connector}
+	conn := sql.OpenDB(breaker)
+
+	if err := conn.PingContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("ping mysql: %w", err)
+	}
+
+	return conn, breaker, nil
+}
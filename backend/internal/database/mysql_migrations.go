@@ -0,0 +1,703 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// MySQLUpSQL is the MySQL/MariaDB counterpart of UpSQL. UUIDs are
+// BINARY(16), timestamps DATETIME(6) in UTC (connections set time_zone to
+// +00:00), JSON documents JSON and byte columns LONGBLOB, or VARBINARY where
+// they are part of a key. Text that is indexed or has a default is VARCHAR,
+// since TEXT columns can have neither. Indexes are declared inline: MySQL
+// has no CREATE INDEX IF NOT EXISTS and no partial indexes.
+const MySQLUpSQL = `
+CREATE TABLE IF NOT EXISTS users (
+  id BINARY(16) PRIMARY KEY,
+  email VARCHAR(320) NOT NULL UNIQUE,
+  email_canonical VARCHAR(320),
+  name TEXT,
+  email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+  role VARCHAR(16) NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin')),
+  merged_into_user_id BINARY(16),
+  merged_at DATETIME(6),
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  UNIQUE KEY uq_users_email_canonical (email_canonical),
+  FOREIGN KEY (merged_into_user_id) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS auth_credentials (
+  user_id BINARY(16) PRIMARY KEY,
+  algo VARCHAR(32),
+  params JSON,
+  salt VARBINARY(255) NOT NULL,
+  password_hash VARBINARY(255),
+  mfa_totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  mfa_totp_secret_enc BLOB,
+  totp_failed_attempts INTEGER NOT NULL DEFAULT 0,
+  totp_window_started_at DATETIME(6),
+  totp_locked_until DATETIME(6),
+  credentials_compromised_at DATETIME(6),
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS passkeys (
+  id BINARY(16) PRIMARY KEY,
+  user_id BINARY(16) NOT NULL,
+  credential_id VARBINARY(1023) NOT NULL,
+  public_key BLOB NOT NULL,
+  sign_count BIGINT NOT NULL DEFAULT 0,
+  name VARCHAR(255) NOT NULL DEFAULT '',
+  prf_salt VARBINARY(255),
+  wrapped_key BLOB,
+  wrap_nonce VARBINARY(255),
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  last_used_at DATETIME(6),
+  UNIQUE KEY uq_passkeys_credential_id (credential_id),
+  INDEX idx_passkeys_user_id (user_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- user_id has no foreign key: a registration challenge names an account
+-- that does not exist yet.
+CREATE TABLE IF NOT EXISTS passkey_challenges (
+  id BINARY(16) PRIMARY KEY,
+  purpose VARCHAR(32) NOT NULL,
+  user_id BINARY(16),
+  email VARCHAR(255) NOT NULL DEFAULT '',
+  name VARCHAR(255) NOT NULL DEFAULT '',
+  challenge VARBINARY(64) NOT NULL,
+  expires_at DATETIME(6) NOT NULL,
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  INDEX idx_passkey_challenges_expires_at (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- user_id is the account that approved the request; it is NULL while the
+-- request is pending.
+CREATE TABLE IF NOT EXISTS qr_login_requests (
+  id BINARY(16) PRIMARY KEY,
+  code_hash VARBINARY(64) NOT NULL,
+  poll_token_hash VARBINARY(64) NOT NULL,
+  status VARCHAR(16) NOT NULL DEFAULT 'pending',
+  user_id BINARY(16),
+  device_name TEXT NOT NULL,
+  ip_address VARCHAR(255) NOT NULL DEFAULT '',
+  user_agent TEXT NOT NULL,
+  expires_at DATETIME(6) NOT NULL,
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  INDEX idx_qr_login_requests_expires_at (expires_at),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Tokens emailed by the forgot-password flow, stored as peppered hashes.
+-- used_at is set when one sets a new password; rows go once they expire.
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+  id BINARY(16) PRIMARY KEY,
+  user_id BINARY(16) NOT NULL,
+  token_hash VARBINARY(64) NOT NULL,
+  expires_at DATETIME(6) NOT NULL,
+  used_at DATETIME(6),
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  UNIQUE KEY uq_password_reset_tokens_token_hash (token_hash),
+  INDEX idx_password_reset_tokens_user_id (user_id, created_at),
+  INDEX idx_password_reset_tokens_expires_at (expires_at),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS user_recovery (
+  user_id BINARY(16) PRIMARY KEY,
+  recovery_key_hash VARBINARY(255) NOT NULL,
+  recovery_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+  wrapped_kek BLOB,
+  wrap_nonce BLOB,
+  kek_salt BLOB,
+  last_recovery_at DATETIME(6),
+  updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS user_keys (
+  user_id BINARY(16) PRIMARY KEY,
+  public_key_x25519 BLOB NOT NULL,
+  public_key_ed25519 BLOB,
+  encrypted_private_keys LONGBLOB NOT NULL,
+  nonce BLOB NOT NULL,
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS vault_folders (
+  id BINARY(16) PRIMARY KEY,
+  owner_user_id BINARY(16) NOT NULL,
+  name_ciphertext LONGBLOB NOT NULL,
+  nonce BLOB NOT NULL,
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  INDEX idx_vault_folders_owner_user_id (owner_user_id),
+  FOREIGN KEY (owner_user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS vault_items (
+  id BINARY(16) PRIMARY KEY,
+  owner_user_id BINARY(16) NOT NULL,
+  folder_id BINARY(16),
+  ciphertext LONGBLOB NOT NULL,
+  nonce BLOB NOT NULL,
+  dek_wrapped BLOB NOT NULL,
+  wrap_nonce BLOB NOT NULL,
+  algo_version VARCHAR(64) NOT NULL,
+  metadata JSON,
+  version INTEGER NOT NULL DEFAULT 1,
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  deleted_at DATETIME(6),
+  INDEX idx_vault_items_owner_user_id (owner_user_id),
+  INDEX idx_vault_items_owner_deleted_at (owner_user_id, deleted_at),
+  INDEX idx_vault_items_owner_updated_at (owner_user_id, updated_at),
+  FOREIGN KEY (owner_user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (folder_id) REFERENCES vault_folders(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS vault_item_versions (
+  id BINARY(16) PRIMARY KEY,
+  item_id BINARY(16) NOT NULL,
+  owner_user_id BINARY(16) NOT NULL,
+  folder_id BINARY(16),
+  ciphertext LONGBLOB NOT NULL,
+  nonce BLOB NOT NULL,
+  dek_wrapped BLOB NOT NULL,
+  wrap_nonce BLOB NOT NULL,
+  algo_version VARCHAR(64) NOT NULL,
+  metadata JSON,
+  version INTEGER NOT NULL,
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  INDEX idx_vault_item_versions_item_id (item_id),
+  FOREIGN KEY (item_id) REFERENCES vault_items(id) ON DELETE CASCADE,
+  FOREIGN KEY (owner_user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (folder_id) REFERENCES vault_folders(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Browser extension support: the HMACs of an item's URIs, which clients
+-- compute so the server can match a page to items without seeing URLs, and
+-- how often each item was used to autofill.
+CREATE TABLE IF NOT EXISTS vault_item_uri_hmacs (
+  item_id BINARY(16) NOT NULL,
+  owner_user_id BINARY(16) NOT NULL,
+  uri_hmac VARBINARY(32) NOT NULL,
+  PRIMARY KEY (item_id, uri_hmac),
+  INDEX idx_vault_item_uri_hmacs_owner_hmac (owner_user_id, uri_hmac),
+  FOREIGN KEY (item_id) REFERENCES vault_items(id) ON DELETE CASCADE,
+  FOREIGN KEY (owner_user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS vault_item_usage (
+  item_id BINARY(16) PRIMARY KEY,
+  owner_user_id BINARY(16) NOT NULL,
+  use_count BIGINT NOT NULL DEFAULT 0,
+  last_used_at DATETIME(6) NOT NULL,
+  INDEX idx_vault_item_usage_owner_user_id (owner_user_id),
+  FOREIGN KEY (item_id) REFERENCES vault_items(id) ON DELETE CASCADE,
+  FOREIGN KEY (owner_user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Purged items leave a tombstone so delta syncs can report them deleted.
+CREATE TABLE IF NOT EXISTS vault_item_tombstones (
+  item_id BINARY(16) PRIMARY KEY,
+  owner_user_id BINARY(16) NOT NULL,
+  deleted_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  INDEX idx_vault_item_tombstones_owner_deleted_at (owner_user_id, deleted_at),
+  FOREIGN KEY (owner_user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS vault_shares (
+  item_id BINARY(16) NOT NULL,
+  user_id BINARY(16) NOT NULL,
+  shared_by_user_id BINARY(16),
+  dek_wrapped BLOB NOT NULL,
+  wrap_nonce BLOB,
+  permissions VARCHAR(16) NOT NULL DEFAULT 'read',
+  not_before DATETIME(6),
+  expires_at DATETIME(6),
+  schedule JSON,
+  first_accessed_at DATETIME(6),
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  PRIMARY KEY (item_id, user_id),
+  INDEX idx_vault_shares_user_id (user_id),
+  INDEX idx_vault_shares_expires_at (expires_at),
+  FOREIGN KEY (item_id) REFERENCES vault_items(id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (shared_by_user_id) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- An owner's request to be told when a recipient first opens a share of
+-- the item.
+CREATE TABLE IF NOT EXISTS vault_item_access_alerts (
+  item_id BINARY(16) PRIMARY KEY,
+  owner_user_id BINARY(16) NOT NULL,
+  email BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  INDEX idx_vault_item_access_alerts_owner_user_id (owner_user_id),
+  FOREIGN KEY (item_id) REFERENCES vault_items(id) ON DELETE CASCADE,
+  FOREIGN KEY (owner_user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Family members asking for a share of an item. decided_by_user_id is the
+-- owner or the manage-share recipient who approved or denied the request.
+CREATE TABLE IF NOT EXISTS access_requests (
+  id BINARY(16) PRIMARY KEY,
+  item_id BINARY(16) NOT NULL,
+  owner_user_id BINARY(16) NOT NULL,
+  requester_user_id BINARY(16) NOT NULL,
+  reason VARCHAR(500) NOT NULL DEFAULT '',
+  status VARCHAR(16) NOT NULL DEFAULT 'pending',
+  decided_by_user_id BINARY(16),
+  decided_at DATETIME(6),
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  INDEX idx_access_requests_item_id (item_id, status),
+  INDEX idx_access_requests_requester_user_id (requester_user_id),
+  FOREIGN KEY (item_id) REFERENCES vault_items(id) ON DELETE CASCADE,
+  FOREIGN KEY (owner_user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (requester_user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (decided_by_user_id) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Shares parked for emails without an account. recipient_user_id is set
+-- once the invitee has registered and uploaded keys; the sharer then
+-- completes the share and the row is deleted.
+CREATE TABLE IF NOT EXISTS share_invitations (
+  id BINARY(16) PRIMARY KEY,
+  item_id BINARY(16) NOT NULL,
+  shared_by_user_id BINARY(16) NOT NULL,
+  email VARCHAR(320) NOT NULL,
+  permissions VARCHAR(16) NOT NULL DEFAULT 'read',
+  status VARCHAR(16) NOT NULL DEFAULT 'pending',
+  recipient_user_id BINARY(16),
+  ready_at DATETIME(6),
+  expires_at DATETIME(6) NOT NULL,
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  UNIQUE KEY uq_share_invitations_item_email (item_id, email),
+  INDEX idx_share_invitations_email (email, status),
+  INDEX idx_share_invitations_shared_by_user_id (shared_by_user_id),
+  INDEX idx_share_invitations_expires_at (expires_at),
+  FOREIGN KEY (item_id) REFERENCES vault_items(id) ON DELETE CASCADE,
+  FOREIGN KEY (shared_by_user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (recipient_user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS vault_attachments (
+  id BINARY(16) PRIMARY KEY,
+  item_id BINARY(16) NOT NULL,
+  file_name_ciphertext BLOB NOT NULL,
+  nonce BLOB NOT NULL,
+  size_bytes BIGINT NOT NULL,
+  storage_path TEXT NOT NULL,
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  INDEX idx_vault_attachments_item_id (item_id),
+  FOREIGN KEY (item_id) REFERENCES vault_items(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS user_devices (
+  id BINARY(16) PRIMARY KEY,
+  user_id BINARY(16) NOT NULL,
+  key_hash VARBINARY(64) NOT NULL,
+  fingerprinted BOOLEAN NOT NULL DEFAULT FALSE,
+  label TEXT NOT NULL,
+  first_seen_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  last_seen_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  UNIQUE KEY uq_user_devices_user_key (user_id, key_hash),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS sessions (
+  id BINARY(16) PRIMARY KEY,
+  user_id BINARY(16) NOT NULL,
+  refresh_token_hash VARBINARY(64) NOT NULL,
+  device_name TEXT,
+  ip_address VARCHAR(255),
+  user_agent TEXT,
+  expires_at DATETIME(6) NOT NULL,
+  revoked_at DATETIME(6),
+  step_up_required BOOLEAN NOT NULL DEFAULT FALSE,
+  device_id BINARY(16),
+  dpop_jkt VARCHAR(64),
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  last_used_at DATETIME(6),
+  INDEX idx_sessions_user_id (user_id),
+  INDEX idx_sessions_refresh_token_hash (refresh_token_hash),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (device_id) REFERENCES user_devices(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Session tokens replaced by a refresh. Presenting one again means it was
+-- copied before it was rotated, so the session it belonged to is revoked.
+CREATE TABLE IF NOT EXISTS retired_session_tokens (
+  token_hash VARBINARY(64) PRIMARY KEY,
+  session_id BINARY(16) NOT NULL,
+  retired_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  INDEX idx_retired_session_tokens_session_id (session_id),
+  FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Tokens are unique per platform: when a phone changes hands, or accounts,
+-- registering its token again moves it to the new account.
+CREATE TABLE IF NOT EXISTS push_tokens (
+  id BINARY(16) PRIMARY KEY,
+  user_id BINARY(16) NOT NULL,
+  device_id BINARY(16),
+  platform VARCHAR(16) NOT NULL,
+  token VARCHAR(512) NOT NULL,
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  UNIQUE KEY uq_push_tokens_platform_token (platform, token),
+  INDEX idx_push_tokens_user_id (user_id),
+  INDEX idx_push_tokens_updated_at (updated_at),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (device_id) REFERENCES user_devices(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Vault items published for CI pipelines, and the API keys service accounts
+-- read them with. A secret's ciphertext is a copy of the item's value the
+-- client encrypted for the pipelines; its name is unique per owner. scopes
+-- is a JSON array of strings.
+CREATE TABLE IF NOT EXISTS machine_secrets (
+  id BINARY(16) PRIMARY KEY,
+  owner_user_id BINARY(16) NOT NULL,
+  item_id BINARY(16) NOT NULL,
+  name VARCHAR(128) NOT NULL,
+  ciphertext LONGBLOB NOT NULL,
+  nonce BLOB NOT NULL,
+  algo_version VARCHAR(64) NOT NULL,
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  UNIQUE KEY uq_machine_secrets_item_id (item_id),
+  UNIQUE KEY uq_machine_secrets_owner_name (owner_user_id, name),
+  FOREIGN KEY (owner_user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (item_id) REFERENCES vault_items(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS machine_keys (
+  id BINARY(16) PRIMARY KEY,
+  owner_user_id BINARY(16) NOT NULL,
+  name VARCHAR(128) NOT NULL,
+  key_hash VARBINARY(32) NOT NULL,
+  prefix VARCHAR(16) NOT NULL,
+  scopes JSON NOT NULL,
+  expires_at DATETIME(6),
+  last_used_at DATETIME(6),
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  UNIQUE KEY uq_machine_keys_key_hash (key_hash),
+  INDEX idx_machine_keys_owner_user_id (owner_user_id),
+  FOREIGN KEY (owner_user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS audit_events (
+  id BINARY(16) PRIMARY KEY,
+  user_id BINARY(16),
+  actor_user_id BINARY(16),
+  event_type VARCHAR(128) NOT NULL,
+  event_data JSON,
+  ip_address VARCHAR(255),
+  user_agent TEXT,
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  recorded_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  INDEX idx_audit_events_user_id (user_id),
+  INDEX idx_audit_events_recorded_at_id (recorded_at, id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL,
+  FOREIGN KEY (actor_user_id) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS audit_export_cursors (
+  exporter VARCHAR(191) PRIMARY KEY,
+  last_recorded_at DATETIME(6) NOT NULL,
+  last_event_id BINARY(16) NOT NULL,
+  updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS backups_registry (
+  id BINARY(16) PRIMARY KEY,
+  created_by_user_id BINARY(16),
+  storage_uri TEXT NOT NULL,
+  checksum_sha256 VARBINARY(32),
+  encrypted BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  INDEX idx_backups_registry_created_by_user_id (created_by_user_id),
+  FOREIGN KEY (created_by_user_id) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS totp_recovery_codes (
+  user_id BINARY(16) NOT NULL,
+  code_hash VARBINARY(64) NOT NULL,
+  used_at DATETIME(6),
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  PRIMARY KEY (user_id, code_hash),
+  INDEX idx_totp_recovery_codes_user_id (user_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS family_memberships (
+  user_id BINARY(16) NOT NULL,
+  friend_id BINARY(16) NOT NULL,
+  status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted')),
+  initiated_by BINARY(16) NOT NULL,
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  PRIMARY KEY (user_id, friend_id),
+  INDEX idx_family_memberships_user_id (user_id),
+  INDEX idx_family_memberships_friend_id (friend_id),
+  CHECK (user_id != friend_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (friend_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (initiated_by) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS job_runs (
+  id BINARY(16) PRIMARY KEY,
+  job_name VARCHAR(128) NOT NULL,
+  status VARCHAR(16) NOT NULL CHECK (status IN ('running', 'succeeded', 'failed')),
+  error TEXT,
+  started_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  finished_at DATETIME(6),
+  INDEX idx_job_runs_job_name_started_at (job_name, started_at DESC)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS outbox_events (
+  id BINARY(16) PRIMARY KEY,
+  topic VARCHAR(128) NOT NULL,
+  payload JSON NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT,
+  next_attempt_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  dispatched_at DATETIME(6),
+  failed_at DATETIME(6),
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  INDEX idx_outbox_events_pending (dispatched_at, failed_at, next_attempt_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- JSON columns cannot have a literal default; the repositories always
+-- write preferences.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+  user_id BINARY(16) PRIMARY KEY,
+  preferences JSON NOT NULL,
+  updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Opt-in binding of sessions to the client that signed them in; no row
+-- means sessions are not bound.
+CREATE TABLE IF NOT EXISTS session_bindings (
+  user_id BINARY(16) PRIMARY KEY,
+  bind_ip_prefix BOOLEAN NOT NULL DEFAULT FALSE,
+  bind_user_agent BOOLEAN NOT NULL DEFAULT FALSE,
+  on_mismatch VARCHAR(16) NOT NULL DEFAULT 'step_up' CHECK (on_mismatch IN ('revoke', 'step_up')),
+  updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- event_types is a JSON array of strings; an empty array subscribes to all.
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+  id BINARY(16) PRIMARY KEY,
+  user_id BINARY(16) NOT NULL,
+  url TEXT NOT NULL,
+  secret_enc BLOB NOT NULL,
+  event_types JSON NOT NULL,
+  enabled BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  INDEX idx_webhook_endpoints_user_id (user_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id BINARY(16) PRIMARY KEY,
+  endpoint_id BINARY(16) NOT NULL,
+  event_id BINARY(16) NOT NULL,
+  event_type VARCHAR(128) NOT NULL,
+  payload JSON NOT NULL,
+  status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'dead')),
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT,
+  next_attempt_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  delivered_at DATETIME(6),
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  UNIQUE (endpoint_id, event_id),
+  INDEX idx_webhook_deliveries_pending (status, next_attempt_at),
+  INDEX idx_webhook_deliveries_endpoint_created (endpoint_id, created_at DESC),
+  FOREIGN KEY (endpoint_id) REFERENCES webhook_endpoints(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+  id BINARY(16) PRIMARY KEY,
+  delivery_id BINARY(16) NOT NULL,
+  status_code INTEGER,
+  error TEXT,
+  duration_ms INTEGER NOT NULL DEFAULT 0,
+  attempted_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  INDEX idx_webhook_delivery_attempts_delivery_id (delivery_id),
+  FOREIGN KEY (delivery_id) REFERENCES webhook_deliveries(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS feature_flags (
+  ` + "`key`" + ` VARCHAR(191) PRIMARY KEY,
+  enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  description VARCHAR(1024) NOT NULL DEFAULT '',
+  updated_by_user_id BINARY(16),
+  updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  FOREIGN KEY (updated_by_user_id) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS maintenance_state (
+  id TINYINT PRIMARY KEY CHECK (id = 1),
+  enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  message VARCHAR(1024) NOT NULL DEFAULT '',
+  since DATETIME(6),
+  updated_by_user_id BINARY(16),
+  updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  FOREIGN KEY (updated_by_user_id) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS data_exports (
+  id BINARY(16) PRIMARY KEY,
+  user_id BINARY(16) NOT NULL,
+  status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready')),
+  archive LONGBLOB,
+  size_bytes BIGINT NOT NULL DEFAULT 0,
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  completed_at DATETIME(6),
+  expires_at DATETIME(6),
+  INDEX idx_data_exports_user_id (user_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS ip_bans (
+  ip VARCHAR(45) PRIMARY KEY,
+  offenses INT NOT NULL DEFAULT 1,
+  reason VARCHAR(255) NOT NULL DEFAULT '',
+  banned_until DATETIME(6) NOT NULL,
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  INDEX idx_ip_bans_banned_until (banned_until)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+// MySQLDropSQL turns foreign key checks off for its session so the tables
+// can be dropped in any order; MySQL has no DROP TABLE ... CASCADE.
+const MySQLDropSQL = `
+SET FOREIGN_KEY_CHECKS = 0;
+DROP TABLE IF EXISTS machine_keys;
+DROP TABLE IF EXISTS machine_secrets;
+DROP TABLE IF EXISTS push_tokens;
+DROP TABLE IF EXISTS retired_session_tokens;
+DROP TABLE IF EXISTS qr_login_requests;
+DROP TABLE IF EXISTS password_reset_tokens;
+DROP TABLE IF EXISTS passkey_challenges;
+DROP TABLE IF EXISTS passkeys;
+DROP TABLE IF EXISTS ip_bans;
+DROP TABLE IF EXISTS data_exports;
+DROP TABLE IF EXISTS maintenance_state;
+DROP TABLE IF EXISTS feature_flags;
+DROP TABLE IF EXISTS webhook_delivery_attempts;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_endpoints;
+DROP TABLE IF EXISTS session_bindings;
+DROP TABLE IF EXISTS notification_preferences;
+DROP TABLE IF EXISTS outbox_events;
+DROP TABLE IF EXISTS job_runs;
+DROP TABLE IF EXISTS family_memberships;
+DROP TABLE IF EXISTS totp_recovery_codes;
+DROP TABLE IF EXISTS backups_registry;
+DROP TABLE IF EXISTS audit_export_cursors;
+DROP TABLE IF EXISTS audit_events;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS user_devices;
+DROP TABLE IF EXISTS vault_attachments;
+DROP TABLE IF EXISTS share_invitations;
+DROP TABLE IF EXISTS vault_item_access_alerts;
+DROP TABLE IF EXISTS access_requests;
+DROP TABLE IF EXISTS vault_shares;
+DROP TABLE IF EXISTS vault_item_tombstones;
+DROP TABLE IF EXISTS vault_item_usage;
+DROP TABLE IF EXISTS vault_item_uri_hmacs;
+DROP TABLE IF EXISTS vault_item_versions;
+DROP TABLE IF EXISTS vault_items;
+DROP TABLE IF EXISTS vault_folders;
+DROP TABLE IF EXISTS user_keys;
+DROP TABLE IF EXISTS user_recovery;
+DROP TABLE IF EXISTS auth_credentials;
+DROP TABLE IF EXISTS users;
+SET FOREIGN_KEY_CHECKS = 1;
+`
+
+func migrateMySQLUp(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, MySQLUpSQL); err != nil {
+		return fmt.Errorf("run mysql schema migration up: %w", err)
+	}
+	// Wide enough for an address encrypted by util.MetadataCipher.
+	if _, err := db.ExecContext(ctx, `
+		ALTER TABLE sessions MODIFY ip_address VARCHAR(255);
+		ALTER TABLE audit_events MODIFY ip_address VARCHAR(255);
+	`); err != nil {
+		return fmt.Errorf("widen ip_address columns: %w", err)
+	}
+	// Passkey-only accounts have no password.
+	if _, err := db.ExecContext(ctx, `
+		ALTER TABLE auth_credentials
+			MODIFY algo VARCHAR(32),
+			MODIFY params JSON,
+			MODIFY password_hash VARBINARY(255);
+	`); err != nil {
+		return fmt.Errorf("allow auth_credentials without a password: %w", err)
+	}
+	for _, column := range []struct{ table, name, definition string }{
+		{"sessions", "step_up_required", "BOOLEAN NOT NULL DEFAULT FALSE"},
+		{"sessions", "device_id", "BINARY(16), ADD FOREIGN KEY (device_id) REFERENCES user_devices(id) ON DELETE SET NULL"},
+		{"sessions", "dpop_jkt", "VARCHAR(64)"},
+		{"sessions", "last_used_at", "DATETIME(6)"},
+		{"vault_shares", "not_before", "DATETIME(6)"},
+		{"vault_shares", "expires_at", "DATETIME(6), ADD INDEX idx_vault_shares_expires_at (expires_at)"},
+		{"vault_shares", "schedule", "JSON"},
+		{"vault_shares", "first_accessed_at", "DATETIME(6)"},
+		{"auth_credentials", "credentials_compromised_at", "DATETIME(6)"},
+		{"users", "email_canonical", "VARCHAR(320), ADD UNIQUE KEY uq_users_email_canonical (email_canonical)"},
+		{"users", "merged_into_user_id", "BINARY(16), ADD FOREIGN KEY (merged_into_user_id) REFERENCES users(id) ON DELETE SET NULL"},
+		{"users", "merged_at", "DATETIME(6)"},
+	} {
+		var exists bool
+		if err := db.QueryRowContext(ctx, `
+			SELECT COUNT(*) > 0 FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?
+		`, column.table, column.name).Scan(&exists); err != nil {
+			return fmt.Errorf("inspect %s columns: %w", column.table, err)
+		}
+		if exists {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, `ALTER TABLE `+column.table+` ADD COLUMN `+column.name+` `+column.definition); err != nil {
+			return fmt.Errorf("add %s.%s: %w", column.table, column.name, err)
+		}
+	}
+	for _, index := range []struct{ table, name, columns string }{
+		{"vault_items", "idx_vault_items_owner_updated_at", "owner_user_id, updated_at"},
+	} {
+		var exists bool
+		if err := db.QueryRowContext(ctx, `
+			SELECT COUNT(*) > 0 FROM information_schema.statistics
+			WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?
+		`, index.table, index.name).Scan(&exists); err != nil {
+			return fmt.Errorf("inspect %s indexes: %w", index.table, err)
+		}
+		if exists {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, `CREATE INDEX `+index.name+` ON `+index.table+` (`+index.columns+`)`); err != nil {
+			return fmt.Errorf("add index %s: %w", index.name, err)
+		}
+	}
+	return nil
+}
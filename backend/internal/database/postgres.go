@@ -5,34 +5,21 @@ import (
 	"database/sql"
 	"fmt"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
-type Postgres struct {
-	sql *sql.DB
-}
-
-func New(ctx context.Context, dsn string) (*Postgres, error) {
-	conn, err := sql.Open("postgres", dsn)
+func openPostgres(ctx context.Context, dsn string) (*sql.DB, *breakerConnector, error) {
+	connector, err := pq.NewConnector(dsn)
 	if err != nil {
-		return nil, fmt.Errorf("open postgres: %w", err)
+		return nil, nil, fmt.Errorf("open postgres: %w", err)
 	}
+	breaker := &breakerConnector{Connector: connector}
+	conn := sql.OpenDB(breaker)
 
 	if err := conn.PingContext(ctx); err != nil {
 		_ = conn.Close()
-		return nil, fmt.Errorf("ping postgres: %w", err)
+		return nil, nil, fmt.Errorf("ping postgres: %w", err)
 	}
 
-	return &Postgres{sql: conn}, nil
-}
-
-func (p *Postgres) SQL() *sql.DB {
-	return p.sql
-}
-
-func (p *Postgres) Close() error {
-	if p == nil || p.sql == nil {
-		return nil
-	}
-	return p.sql.Close()
+	return conn, breaker, nil
 }
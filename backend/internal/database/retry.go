@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Retry says how Connect keeps trying a database that is not accepting
+// connections yet, as happens while docker-compose or Kubernetes starts
+// Postgres next to the API. The zero Retry makes a single attempt.
+type Retry struct {
+	// MaxWait bounds the total time spent retrying.
+	MaxWait time.Duration
+	// InitialBackoff is the pause after the first failure; it doubles after
+	// every further one, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// OnRetry, when set, is told about every failed attempt that will be
+	// retried after wait.
+	OnRetry func(attempt int, wait time.Duration, err error)
+}
+
+func (r Retry) backoff(attempt int) time.Duration {
+	wait := max(r.InitialBackoff, time.Millisecond)
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if r.MaxBackoff > 0 && wait >= r.MaxBackoff {
+			return r.MaxBackoff
+		}
+	}
+	return wait
+}
+
+// Connect is New, retried with exponential backoff until it succeeds,
+// retry.MaxWait has passed or ctx is done.
+func Connect(ctx context.Context, dsn string, retry Retry) (*DB, error) {
+	deadline := time.Now().Add(retry.MaxWait)
+	for attempt := 1; ; attempt++ {
+		db, err := New(ctx, dsn)
+		if err == nil {
+			return db, nil
+		}
+
+		wait := retry.backoff(attempt)
+		if ctx.Err() != nil || time.Now().Add(wait).After(deadline) {
+			if attempt == 1 {
+				return nil, err
+			}
+			return nil, fmt.Errorf("database unavailable after %d attempts: %w", attempt, err)
+		}
+		if retry.OnRetry != nil {
+			retry.OnRetry(attempt, wait, err)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("database unavailable after %d attempts: %w", attempt, err)
+		case <-timer.C:
+		}
+	}
+}
+
+// ConnectAndMigrate is OpenAndMigrate with Connect's retries.
+func ConnectAndMigrate(ctx context.Context, dsn string, retry Retry) (*DB, error) {
+	db, err := Connect(ctx, dsn, retry)
+	if err != nil {
+		return nil, err
+	}
+	if err := MigrateUp(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,47 @@
+package database_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/database"
+)
+
+func TestConnectRetriesUntilMaxWait(t *testing.T) {
+	dsn := "sqlite:" + filepath.Join(t.TempDir(), "missing", "pmv2.db")
+
+	var waits []time.Duration
+	_, err := database.Connect(context.Background(), dsn, database.Retry{
+		MaxWait:        70 * time.Millisecond,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     20 * time.Millisecond,
+		OnRetry: func(attempt int, wait time.Duration, err error) {
+			waits = append(waits, wait)
+		},
+	})
+	if err == nil {
+		t.Fatal("Connect succeeded against a directory that does not exist")
+	}
+	if len(waits) < 2 || waits[0] != 10*time.Millisecond || waits[1] != 20*time.Millisecond {
+		t.Fatalf("waits = %v, want 10ms then 20ms (capped)", waits)
+	}
+	for _, wait := range waits[1:] {
+		if wait != 20*time.Millisecond {
+			t.Fatalf("waits = %v, want every later wait capped at 20ms", waits)
+		}
+	}
+}
+
+func TestConnectWithoutRetryTriesOnce(t *testing.T) {
+	dsn := "sqlite:" + filepath.Join(t.TempDir(), "missing", "pmv2.db")
+
+	retried := false
+	_, err := database.Connect(context.Background(), dsn, database.Retry{
+		OnRetry: func(int, time.Duration, error) { retried = true },
+	})
+	if err == nil || retried {
+		t.Fatalf("Connect = %v, retried %v; want a single failed attempt", err, retried)
+	}
+}
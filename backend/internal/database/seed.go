@@ -11,21 +11,20 @@ import (
 
 	"github.com/google/uuid"
 	"pmv2/backend/internal/domain"
-	"pmv2/backend/internal/repository"
 	"pmv2/backend/internal/util"
 )
 
 type Seeder struct {
-	db         *Postgres
+	db         *DB
 	authRepo   domain.AuthRepository
 	vaultRepo  domain.VaultRepository
 	folderRepo domain.FolderRepository
-	auditRepo  *repository.AuditRepository // Using concrete type for CreateEvent if interface is missing
+	auditRepo  domain.AuditRepository
 	familyRepo domain.FamilyRepository
 	pepper     string
 }
 
-func NewSeeder(db *Postgres, authRepo domain.AuthRepository, vaultRepo domain.VaultRepository, folderRepo domain.FolderRepository, auditRepo *repository.AuditRepository, familyRepo domain.FamilyRepository, pepper string) *Seeder {
+func NewSeeder(db *DB, authRepo domain.AuthRepository, vaultRepo domain.VaultRepository, folderRepo domain.FolderRepository, auditRepo domain.AuditRepository, familyRepo domain.FamilyRepository, pepper string) *Seeder {
 	return &Seeder{
 		db:         db,
 		authRepo:   authRepo,
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqlitePragmas are applied to every connection. WAL lets readers run
+// alongside the single writer, busy_timeout makes writers queue instead of
+// failing, and _txlock=immediate takes the write lock when a transaction
+// begins so two transactions never deadlock upgrading from read to write.
+var sqlitePragmas = []string{
+	"_pragma=foreign_keys(1)",
+	"_pragma=journal_mode(WAL)",
+	"_pragma=busy_timeout(5000)",
+	"_txlock=immediate",
+}
+
+// sqliteDSN turns sqlite:<path> or sqlite://<path> into the driver's
+// file: DSN. Query parameters of the original DSN, such as extra
+// _pragma=... options, are appended after sqlitePragmas.
+func sqliteDSN(dsn string) (string, error) {
+	rest := strings.TrimPrefix(dsn[len("sqlite:"):], "//")
+	path, query, _ := strings.Cut(rest, "?")
+	if path == "" {
+		return "", fmt.Errorf("sqlite DSN %q has no file path", dsn)
+	}
+	if _, err := url.ParseQuery(query); err != nil {
+		return "", fmt.Errorf("parse sqlite DSN options: %w", err)
+	}
+
+	params := append([]string(nil), sqlitePragmas...)
+	if query != "" {
+		params = append(params, query)
+	}
+	return "file:" + path + "?" + strings.Join(params, "&"), nil
+}
+
+func openSQLite(ctx context.Context, dsn string) (*sql.DB, error) {
+	fileDSN, err := sqliteDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := sql.Open("sqlite", fileDSN)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+
+	if err := conn.PingContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("ping sqlite: %w", err)
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,663 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// SQLiteUpSQL is the SQLite counterpart of UpSQL. UUIDs and IP addresses
+// are TEXT, byte columns BLOB, JSON documents TEXT and booleans INTEGER.
+// Timestamps are TEXT in the fixed-width UTC form the repositories write
+// (2006-01-02T15:04:05.000Z), so comparing them as strings orders them in
+// time; the TIMESTAMP type name makes the driver scan them into time.Time.
+const SQLiteUpSQL = `
+CREATE TABLE IF NOT EXISTS users (
+  id TEXT PRIMARY KEY,
+  email TEXT UNIQUE NOT NULL,
+  email_canonical TEXT,
+  name TEXT,
+  email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+  role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin')),
+  merged_into_user_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+  merged_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS auth_credentials (
+  user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  algo TEXT,
+  params TEXT,
+  salt BLOB NOT NULL,
+  password_hash BLOB,
+  mfa_totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  mfa_totp_secret_enc BLOB,
+  totp_failed_attempts INTEGER NOT NULL DEFAULT 0,
+  totp_window_started_at TIMESTAMP,
+  totp_locked_until TIMESTAMP,
+  credentials_compromised_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS passkeys (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  credential_id BLOB NOT NULL UNIQUE,
+  public_key BLOB NOT NULL,
+  sign_count INTEGER NOT NULL DEFAULT 0,
+  name TEXT NOT NULL DEFAULT '',
+  prf_salt BLOB,
+  wrapped_key BLOB,
+  wrap_nonce BLOB,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  last_used_at TIMESTAMP
+);
+
+-- user_id has no foreign key: a registration challenge names an account
+-- that does not exist yet.
+CREATE TABLE IF NOT EXISTS passkey_challenges (
+  id TEXT PRIMARY KEY,
+  purpose TEXT NOT NULL,
+  user_id TEXT,
+  email TEXT NOT NULL DEFAULT '',
+  name TEXT NOT NULL DEFAULT '',
+  challenge BLOB NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+-- user_id is the account that approved the request; it is NULL while the
+-- request is pending.
+CREATE TABLE IF NOT EXISTS qr_login_requests (
+  id TEXT PRIMARY KEY,
+  code_hash BLOB NOT NULL,
+  poll_token_hash BLOB NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending',
+  user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+  device_name TEXT NOT NULL DEFAULT '',
+  ip_address TEXT NOT NULL DEFAULT '',
+  user_agent TEXT NOT NULL DEFAULT '',
+  expires_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+-- Tokens emailed by the forgot-password flow, stored as peppered hashes.
+-- used_at is set when one sets a new password; rows go once they expire.
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  token_hash BLOB NOT NULL UNIQUE,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS user_recovery (
+  user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  recovery_key_hash BLOB NOT NULL,
+  recovery_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+  wrapped_kek BLOB,
+  wrap_nonce BLOB,
+  kek_salt BLOB,
+  last_recovery_at TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS user_keys (
+  user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  public_key_x25519 BLOB NOT NULL,
+  public_key_ed25519 BLOB,
+  encrypted_private_keys BLOB NOT NULL,
+  nonce BLOB NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS vault_folders (
+  id TEXT PRIMARY KEY,
+  owner_user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name_ciphertext BLOB NOT NULL,
+  nonce BLOB NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS vault_items (
+  id TEXT PRIMARY KEY,
+  owner_user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  folder_id TEXT REFERENCES vault_folders(id) ON DELETE SET NULL,
+  ciphertext BLOB NOT NULL,
+  nonce BLOB NOT NULL,
+  dek_wrapped BLOB NOT NULL,
+  wrap_nonce BLOB NOT NULL,
+  algo_version TEXT NOT NULL,
+  metadata TEXT,
+  version INTEGER NOT NULL DEFAULT 1,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  deleted_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS vault_item_versions (
+  id TEXT PRIMARY KEY,
+  item_id TEXT NOT NULL REFERENCES vault_items(id) ON DELETE CASCADE,
+  owner_user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  folder_id TEXT REFERENCES vault_folders(id) ON DELETE SET NULL,
+  ciphertext BLOB NOT NULL,
+  nonce BLOB NOT NULL,
+  dek_wrapped BLOB NOT NULL,
+  wrap_nonce BLOB NOT NULL,
+  algo_version TEXT NOT NULL,
+  metadata TEXT,
+  version INTEGER NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+-- Browser extension support: the HMACs of an item's URIs, which clients
+-- compute so the server can match a page to items without seeing URLs, and
+-- how often each item was used to autofill.
+CREATE TABLE IF NOT EXISTS vault_item_uri_hmacs (
+  item_id TEXT NOT NULL REFERENCES vault_items(id) ON DELETE CASCADE,
+  owner_user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  uri_hmac BLOB NOT NULL,
+  PRIMARY KEY (item_id, uri_hmac)
+);
+
+CREATE TABLE IF NOT EXISTS vault_item_usage (
+  item_id TEXT PRIMARY KEY REFERENCES vault_items(id) ON DELETE CASCADE,
+  owner_user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  use_count INTEGER NOT NULL DEFAULT 0,
+  last_used_at TIMESTAMP NOT NULL
+);
+
+-- Purged items leave a tombstone so delta syncs can report them deleted.
+CREATE TABLE IF NOT EXISTS vault_item_tombstones (
+  item_id TEXT PRIMARY KEY,
+  owner_user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  deleted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS vault_shares (
+  item_id TEXT NOT NULL REFERENCES vault_items(id) ON DELETE CASCADE,
+  user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  shared_by_user_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+  dek_wrapped BLOB NOT NULL,
+  wrap_nonce BLOB,
+  permissions TEXT NOT NULL DEFAULT 'read',
+  not_before TIMESTAMP,
+  expires_at TIMESTAMP,
+  schedule TEXT,
+  first_accessed_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  PRIMARY KEY (item_id, user_id)
+);
+
+-- An owner's request to be told when a recipient first opens a share of
+-- the item.
+CREATE TABLE IF NOT EXISTS vault_item_access_alerts (
+  item_id TEXT PRIMARY KEY REFERENCES vault_items(id) ON DELETE CASCADE,
+  owner_user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  email BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+-- Family members asking for a share of an item. decided_by_user_id is the
+-- owner or the manage-share recipient who approved or denied the request.
+CREATE TABLE IF NOT EXISTS access_requests (
+  id TEXT PRIMARY KEY,
+  item_id TEXT NOT NULL REFERENCES vault_items(id) ON DELETE CASCADE,
+  owner_user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  requester_user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  reason TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL DEFAULT 'pending',
+  decided_by_user_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+  decided_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+-- Shares parked for emails without an account. recipient_user_id is set
+-- once the invitee has registered and uploaded keys; the sharer then
+-- completes the share and the row is deleted.
+CREATE TABLE IF NOT EXISTS share_invitations (
+  id TEXT PRIMARY KEY,
+  item_id TEXT NOT NULL REFERENCES vault_items(id) ON DELETE CASCADE,
+  shared_by_user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  email TEXT NOT NULL,
+  permissions TEXT NOT NULL DEFAULT 'read',
+  status TEXT NOT NULL DEFAULT 'pending',
+  recipient_user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+  ready_at TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  UNIQUE (item_id, email)
+);
+
+CREATE TABLE IF NOT EXISTS vault_attachments (
+  id TEXT PRIMARY KEY,
+  item_id TEXT NOT NULL REFERENCES vault_items(id) ON DELETE CASCADE,
+  file_name_ciphertext BLOB NOT NULL,
+  nonce BLOB NOT NULL,
+  size_bytes INTEGER NOT NULL,
+  storage_path TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS user_devices (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  key_hash BLOB NOT NULL,
+  fingerprinted BOOLEAN NOT NULL DEFAULT FALSE,
+  label TEXT NOT NULL DEFAULT '',
+  first_seen_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  last_seen_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  UNIQUE (user_id, key_hash)
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  refresh_token_hash BLOB NOT NULL,
+  device_name TEXT,
+  ip_address TEXT,
+  user_agent TEXT,
+  expires_at TIMESTAMP NOT NULL,
+  revoked_at TIMESTAMP,
+  step_up_required BOOLEAN NOT NULL DEFAULT FALSE,
+  device_id TEXT REFERENCES user_devices(id) ON DELETE SET NULL,
+  dpop_jkt TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  last_used_at TIMESTAMP
+);
+
+-- Session tokens replaced by a refresh. Presenting one again means it was
+-- copied before it was rotated, so the session it belonged to is revoked.
+CREATE TABLE IF NOT EXISTS retired_session_tokens (
+  token_hash BLOB PRIMARY KEY,
+  session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+  retired_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+-- Tokens are unique per platform: when a phone changes hands, or accounts,
+-- registering its token again moves it to the new account.
+CREATE TABLE IF NOT EXISTS push_tokens (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  device_id TEXT REFERENCES user_devices(id) ON DELETE SET NULL,
+  platform TEXT NOT NULL,
+  token TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  UNIQUE (platform, token)
+);
+
+-- Vault items published for CI pipelines, and the API keys service accounts
+-- read them with. A secret's ciphertext is a copy of the item's value the
+-- client encrypted for the pipelines; its name is unique per owner. scopes
+-- is a JSON array of strings.
+CREATE TABLE IF NOT EXISTS machine_secrets (
+  id TEXT PRIMARY KEY,
+  owner_user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  item_id TEXT NOT NULL UNIQUE REFERENCES vault_items(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  ciphertext BLOB NOT NULL,
+  nonce BLOB NOT NULL,
+  algo_version TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  UNIQUE (owner_user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS machine_keys (
+  id TEXT PRIMARY KEY,
+  owner_user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  key_hash BLOB NOT NULL UNIQUE,
+  prefix TEXT NOT NULL,
+  scopes TEXT NOT NULL DEFAULT '[]',
+  expires_at TIMESTAMP,
+  last_used_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS audit_events (
+  id TEXT PRIMARY KEY,
+  user_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+  actor_user_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+  event_type TEXT NOT NULL,
+  event_data TEXT,
+  ip_address TEXT,
+  user_agent TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  recorded_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS audit_export_cursors (
+  exporter TEXT PRIMARY KEY,
+  last_recorded_at TIMESTAMP NOT NULL,
+  last_event_id TEXT NOT NULL,
+  updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS backups_registry (
+  id TEXT PRIMARY KEY,
+  created_by_user_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+  storage_uri TEXT NOT NULL,
+  checksum_sha256 BLOB,
+  encrypted BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS totp_recovery_codes (
+  user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  code_hash BLOB NOT NULL,
+  used_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  PRIMARY KEY (user_id, code_hash)
+);
+
+CREATE TABLE IF NOT EXISTS family_memberships (
+  user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  friend_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted')),
+  initiated_by TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  PRIMARY KEY (user_id, friend_id),
+  CHECK (user_id != friend_id)
+);
+
+CREATE TABLE IF NOT EXISTS job_runs (
+  id TEXT PRIMARY KEY,
+  job_name TEXT NOT NULL,
+  status TEXT NOT NULL CHECK (status IN ('running', 'succeeded', 'failed')),
+  error TEXT,
+  started_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  finished_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS outbox_events (
+  id TEXT PRIMARY KEY,
+  topic TEXT NOT NULL,
+  payload TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT,
+  next_attempt_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  dispatched_at TIMESTAMP,
+  failed_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+  user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  preferences TEXT NOT NULL DEFAULT '{}',
+  updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+-- Opt-in binding of sessions to the client that signed them in; no row
+-- means sessions are not bound.
+CREATE TABLE IF NOT EXISTS session_bindings (
+  user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  bind_ip_prefix BOOLEAN NOT NULL DEFAULT FALSE,
+  bind_user_agent BOOLEAN NOT NULL DEFAULT FALSE,
+  on_mismatch TEXT NOT NULL DEFAULT 'step_up' CHECK (on_mismatch IN ('revoke', 'step_up')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+-- event_types is a JSON array of strings; an empty array subscribes to all.
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  url TEXT NOT NULL,
+  secret_enc BLOB NOT NULL,
+  event_types TEXT NOT NULL DEFAULT '[]',
+  enabled BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id TEXT PRIMARY KEY,
+  endpoint_id TEXT NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+  event_id TEXT NOT NULL,
+  event_type TEXT NOT NULL,
+  payload TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'dead')),
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT,
+  next_attempt_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  delivered_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  UNIQUE (endpoint_id, event_id)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+  id TEXT PRIMARY KEY,
+  delivery_id TEXT NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+  status_code INTEGER,
+  error TEXT,
+  duration_ms INTEGER NOT NULL DEFAULT 0,
+  attempted_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS feature_flags (
+  key TEXT PRIMARY KEY,
+  enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  description TEXT NOT NULL DEFAULT '',
+  updated_by_user_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+  updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS data_exports (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready')),
+  archive BLOB,
+  size_bytes INTEGER NOT NULL DEFAULT 0,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  completed_at TIMESTAMP,
+  expires_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS ip_bans (
+  ip TEXT PRIMARY KEY,
+  offenses INTEGER NOT NULL DEFAULT 1,
+  reason TEXT NOT NULL DEFAULT '',
+  banned_until TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS maintenance_state (
+  id INTEGER PRIMARY KEY CHECK (id = 1),
+  enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  message TEXT NOT NULL DEFAULT '',
+  since TIMESTAMP,
+  updated_by_user_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+  updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports(user_id);
+CREATE INDEX IF NOT EXISTS idx_passkeys_user_id ON passkeys(user_id);
+CREATE INDEX IF NOT EXISTS idx_passkey_challenges_expires_at ON passkey_challenges(expires_at);
+CREATE INDEX IF NOT EXISTS idx_qr_login_requests_expires_at ON qr_login_requests(expires_at);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_expires_at ON password_reset_tokens(expires_at);
+CREATE INDEX IF NOT EXISTS idx_ip_bans_banned_until ON ip_bans(banned_until);
+CREATE INDEX IF NOT EXISTS idx_vault_items_owner_user_id ON vault_items(owner_user_id);
+CREATE INDEX IF NOT EXISTS idx_vault_items_owner_deleted_at ON vault_items(owner_user_id, deleted_at);
+CREATE INDEX IF NOT EXISTS idx_vault_items_owner_updated_at ON vault_items(owner_user_id, updated_at DESC);
+CREATE INDEX IF NOT EXISTS idx_vault_item_versions_item_id ON vault_item_versions(item_id);
+CREATE INDEX IF NOT EXISTS idx_vault_item_uri_hmacs_owner_hmac ON vault_item_uri_hmacs(owner_user_id, uri_hmac);
+CREATE INDEX IF NOT EXISTS idx_vault_item_usage_owner_user_id ON vault_item_usage(owner_user_id);
+CREATE INDEX IF NOT EXISTS idx_vault_item_tombstones_owner_deleted_at ON vault_item_tombstones(owner_user_id, deleted_at);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_retired_session_tokens_session_id ON retired_session_tokens(session_id);
+CREATE INDEX IF NOT EXISTS idx_push_tokens_user_id ON push_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_push_tokens_updated_at ON push_tokens(updated_at);
+CREATE INDEX IF NOT EXISTS idx_machine_keys_owner_user_id ON machine_keys(owner_user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_active_refresh_token_hash ON sessions(refresh_token_hash) WHERE revoked_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_audit_events_user_id ON audit_events(user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_recorded_at_id ON audit_events(recorded_at, id);
+CREATE INDEX IF NOT EXISTS idx_vault_shares_user_id ON vault_shares(user_id);
+CREATE INDEX IF NOT EXISTS idx_access_requests_item_id ON access_requests(item_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_access_requests_requester_user_id ON access_requests(requester_user_id);
+CREATE INDEX IF NOT EXISTS idx_share_invitations_email ON share_invitations(email) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_share_invitations_shared_by_user_id ON share_invitations(shared_by_user_id);
+CREATE INDEX IF NOT EXISTS idx_share_invitations_expires_at ON share_invitations(expires_at);
+CREATE INDEX IF NOT EXISTS idx_vault_item_access_alerts_owner_user_id ON vault_item_access_alerts(owner_user_id);
+CREATE INDEX IF NOT EXISTS idx_vault_folders_owner_user_id ON vault_folders(owner_user_id);
+CREATE INDEX IF NOT EXISTS idx_vault_attachments_item_id ON vault_attachments(item_id);
+CREATE INDEX IF NOT EXISTS idx_backups_registry_created_by_user_id ON backups_registry(created_by_user_id);
+CREATE INDEX IF NOT EXISTS idx_totp_recovery_codes_user_id ON totp_recovery_codes(user_id);
+CREATE INDEX IF NOT EXISTS idx_family_memberships_user_id ON family_memberships(user_id);
+CREATE INDEX IF NOT EXISTS idx_family_memberships_friend_id ON family_memberships(friend_id);
+CREATE INDEX IF NOT EXISTS idx_job_runs_job_name_started_at ON job_runs(job_name, started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events(next_attempt_at) WHERE dispatched_at IS NULL AND failed_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_webhook_endpoints_user_id ON webhook_endpoints(user_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_endpoint_created ON webhook_deliveries(endpoint_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery_id ON webhook_delivery_attempts(delivery_id);
+`
+
+// SQLiteDropSQL drops children before parents; SQLite has no CASCADE.
+const SQLiteDropSQL = `
+DROP TABLE IF EXISTS machine_keys;
+DROP TABLE IF EXISTS machine_secrets;
+DROP TABLE IF EXISTS push_tokens;
+DROP TABLE IF EXISTS retired_session_tokens;
+DROP TABLE IF EXISTS qr_login_requests;
+DROP TABLE IF EXISTS password_reset_tokens;
+DROP TABLE IF EXISTS passkey_challenges;
+DROP TABLE IF EXISTS passkeys;
+DROP TABLE IF EXISTS ip_bans;
+DROP TABLE IF EXISTS data_exports;
+DROP TABLE IF EXISTS maintenance_state;
+DROP TABLE IF EXISTS feature_flags;
+DROP TABLE IF EXISTS webhook_delivery_attempts;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_endpoints;
+DROP TABLE IF EXISTS session_bindings;
+DROP TABLE IF EXISTS notification_preferences;
+DROP TABLE IF EXISTS outbox_events;
+DROP TABLE IF EXISTS job_runs;
+DROP TABLE IF EXISTS family_memberships;
+DROP TABLE IF EXISTS totp_recovery_codes;
+DROP TABLE IF EXISTS backups_registry;
+DROP TABLE IF EXISTS audit_export_cursors;
+DROP TABLE IF EXISTS audit_events;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS user_devices;
+DROP TABLE IF EXISTS vault_attachments;
+DROP TABLE IF EXISTS share_invitations;
+DROP TABLE IF EXISTS vault_item_access_alerts;
+DROP TABLE IF EXISTS access_requests;
+DROP TABLE IF EXISTS vault_shares;
+DROP TABLE IF EXISTS vault_item_tombstones;
+DROP TABLE IF EXISTS vault_item_usage;
+DROP TABLE IF EXISTS vault_item_uri_hmacs;
+DROP TABLE IF EXISTS vault_item_versions;
+DROP TABLE IF EXISTS vault_items;
+DROP TABLE IF EXISTS vault_folders;
+DROP TABLE IF EXISTS user_keys;
+DROP TABLE IF EXISTS user_recovery;
+DROP TABLE IF EXISTS auth_credentials;
+DROP TABLE IF EXISTS users;
+`
+
+func migrateSQLiteUp(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, SQLiteUpSQL); err != nil {
+		return fmt.Errorf("run sqlite schema migration up: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `DROP INDEX IF EXISTS idx_sessions_refresh_token_hash`); err != nil {
+		return fmt.Errorf("drop superseded session token index: %w", err)
+	}
+	// SQLite has no ADD COLUMN IF NOT EXISTS.
+	for _, column := range []struct{ table, name, definition string }{
+		{"sessions", "step_up_required", "BOOLEAN NOT NULL DEFAULT FALSE"},
+		{"sessions", "device_id", "TEXT REFERENCES user_devices(id) ON DELETE SET NULL"},
+		{"sessions", "dpop_jkt", "TEXT"},
+		{"sessions", "last_used_at", "TIMESTAMP"},
+		{"vault_shares", "not_before", "TIMESTAMP"},
+		{"vault_shares", "expires_at", "TIMESTAMP"},
+		{"vault_shares", "schedule", "TEXT"},
+		{"vault_shares", "first_accessed_at", "TIMESTAMP"},
+		{"auth_credentials", "credentials_compromised_at", "TIMESTAMP"},
+		{"users", "email_canonical", "TEXT"},
+		{"users", "merged_into_user_id", "TEXT REFERENCES users(id) ON DELETE SET NULL"},
+		{"users", "merged_at", "TIMESTAMP"},
+	} {
+		var exists bool
+		if err := db.QueryRowContext(ctx, `
+			SELECT COUNT(*) > 0 FROM pragma_table_info($1) WHERE name = $2
+		`, column.table, column.name).Scan(&exists); err != nil {
+			return fmt.Errorf("inspect %s columns: %w", column.table, err)
+		}
+		if exists {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, `ALTER TABLE `+column.table+` ADD COLUMN `+column.name+` `+column.definition); err != nil {
+			return fmt.Errorf("add %s.%s: %w", column.table, column.name, err)
+		}
+	}
+	if _, err := db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_vault_shares_expires_at ON vault_shares(expires_at) WHERE expires_at IS NOT NULL`); err != nil {
+		return fmt.Errorf("create vault_shares expiry index: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_canonical ON users(email_canonical) WHERE email_canonical IS NOT NULL`); err != nil {
+		return fmt.Errorf("create users canonical email index: %w", err)
+	}
+	return relaxSQLiteAuthCredentials(ctx, db)
+}
+
+// relaxSQLiteAuthCredentials rebuilds an auth_credentials table created
+// before passkey-only accounts, whose password columns are NOT NULL. SQLite
+// cannot drop a constraint in place; nothing references the table, so it is
+// copied into one with the current definition, which must match the one in
+// SQLiteUpSQL.
+func relaxSQLiteAuthCredentials(ctx context.Context, db *sql.DB) error {
+	var strict bool
+	if err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*) > 0 FROM pragma_table_info('auth_credentials') WHERE name = 'password_hash' AND "notnull" = 1
+	`).Scan(&strict); err != nil {
+		return fmt.Errorf("inspect auth_credentials columns: %w", err)
+	}
+	if !strict {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin auth_credentials rebuild: %w", err)
+	}
+	defer tx.Rollback()
+	const columns = `user_id, algo, params, salt, password_hash, mfa_totp_enabled, mfa_totp_secret_enc,
+		totp_failed_attempts, totp_window_started_at, totp_locked_until, credentials_compromised_at, created_at, updated_at`
+	for _, stmt := range []string{
+		`ALTER TABLE auth_credentials RENAME TO auth_credentials_old`,
+		`CREATE TABLE auth_credentials (
+			user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			algo TEXT,
+			params TEXT,
+			salt BLOB NOT NULL,
+			password_hash BLOB,
+			mfa_totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+			mfa_totp_secret_enc BLOB,
+			totp_failed_attempts INTEGER NOT NULL DEFAULT 0,
+			totp_window_started_at TIMESTAMP,
+			totp_locked_until TIMESTAMP,
+			credentials_compromised_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+			updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+		)`,
+		`INSERT INTO auth_credentials (` + columns + `) SELECT ` + columns + ` FROM auth_credentials_old`,
+		`DROP TABLE auth_credentials_old`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("rebuild auth_credentials: %w", err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,50 @@
+package domain
+
+import "time"
+
+// MaxAccessRequestReasonLength bounds the note a requester leaves for the
+// approvers.
+const MaxAccessRequestReasonLength = 500
+
+// Share permissions. A recipient holding SharePermissionManage may approve
+// requests for access to the item on the owner's behalf.
+const (
+	SharePermissionRead   = "read"
+	SharePermissionManage = "manage"
+)
+
+var (
+	ErrAccessRequestNotFound = newError(KindNotFound, "access_request_not_found", "access request not found")
+	ErrAccessRequestPending  = newError(KindConflict, "access_request_pending", "you already have a pending request for this item")
+	ErrAccessRequestDecided  = newError(KindConflict, "access_request_decided", "access request was already approved or denied")
+	ErrAlreadyHasAccess      = newError(KindConflict, "already_has_access", "you already have access to this item")
+	ErrNotAccessApprover     = newError(KindForbidden, "not_approver", "only the item owner or a recipient who manages it can decide this request")
+	ErrInvalidAccessReason   = newError(KindInvalid, "invalid_reason", "reason must be at most 500 characters")
+)
+
+type AccessRequestStatus string
+
+const (
+	AccessRequestPending  AccessRequestStatus = "pending"
+	AccessRequestApproved AccessRequestStatus = "approved"
+	AccessRequestDenied   AccessRequestStatus = "denied"
+)
+
+// AccessRequest is a family member asking for a share of an item they do
+// not hold. The approvers are the item's owner and the recipients sharing
+// it with SharePermissionManage. The server cannot read the item's key, so
+// approving takes a copy of it the approver's client wrapped for the
+// requester; the resulting share names the approver as its sharer.
+type AccessRequest struct {
+	ID              string
+	ItemID          string
+	OwnerUserID     string
+	RequesterUserID string
+	RequesterEmail  string
+	RequesterName   string
+	Reason          string
+	Status          AccessRequestStatus
+	DecidedByUserID string
+	DecidedAt       *time.Time
+	CreatedAt       time.Time
+}
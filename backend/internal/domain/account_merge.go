@@ -0,0 +1,31 @@
+package domain
+
+import "time"
+
+var (
+	ErrMergeSameAccount      = newError(KindInvalid, "merge_same_account", "both sessions belong to the same account")
+	ErrInvalidMergeSession   = newError(KindUnauthorized, "invalid_merge_session", "the session of the account to merge is invalid or expired")
+	ErrMergeAdminAccount     = newError(KindForbidden, "merge_admin_account", "an admin account cannot be merged into another")
+	ErrShareRewrapIncomplete = newError(KindInvalid, "share_rewrap_incomplete", "a re-wrapped key is required for every share the merged account received, and only once")
+)
+
+// AccountMergeInput folds SourceUserID into TargetUserID. The vault keys
+// of the source are its own, so the client, holding both, sends the key of
+// every source item re-wrapped under the target's key-encryption key, and
+// the key of every share the source received re-wrapped for the target's
+// public key.
+type AccountMergeInput struct {
+	SourceUserID string
+	TargetUserID string
+	Items        []VaultItemKey
+	Shares       []VaultItemKey
+}
+
+// AccountMerge is the outcome of a merge: the rows moved to the target,
+// per table.
+type AccountMerge struct {
+	SourceUserID string
+	TargetUserID string
+	Moved        map[string]int64
+	MergedAt     time.Time
+}
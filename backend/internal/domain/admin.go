@@ -0,0 +1,147 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+var (
+	ErrInvalidFeatureFlag = newError(KindInvalid, "invalid_feature_flag", "flag keys are lowercase letters, digits, '.', '_' or '-', at most 64 long")
+	ErrCannotEraseSelf    = newError(KindConflict, "cannot_erase_self", "admins cannot erase their own account")
+)
+
+// InstanceStats are instance-wide totals for the admin dashboard. Active
+// user counts are distinct accounts that signed in or took an audited
+// action in the window.
+type InstanceStats struct {
+	Users          int
+	Admins         int
+	TOTPUsers      int
+	NewUsers30d    int
+	ActiveSessions int
+
+	VaultItems   int
+	TrashedItems int
+	Folders      int
+	Shares       int
+
+	ActiveUsers1d  int
+	ActiveUsers7d  int
+	ActiveUsers30d int
+	DailyActive    []DailyActiveUsers // oldest first
+
+	Storage     StorageStats
+	GeneratedAt time.Time
+}
+
+// DailyActiveUsers is the number of distinct active accounts on one UTC day.
+type DailyActiveUsers struct {
+	Date  time.Time
+	Users int
+}
+
+// StorageStats are sizes in bytes. Vault and attachment figures count
+// ciphertext only; DatabaseBytes is what Postgres reports for the whole
+// database, indexes and history included.
+type StorageStats struct {
+	VaultBytes      int64
+	HistoryBytes    int64
+	AttachmentBytes int64
+	DatabaseBytes   int64
+}
+
+// AdminUser is the account metadata the admin API may see. It never carries
+// credentials, key material or vault contents.
+type AdminUser struct {
+	ID              string
+	Email           string
+	Name            string
+	Role            UserRole
+	EmailVerified   bool
+	TOTPEnabled     bool
+	TOTPLockedUntil *time.Time
+	ActiveSessions  int
+	VaultItems      int
+	LastLoginAt     *time.Time
+	CreatedAt       time.Time
+}
+
+// AdminSession is one of a user's active sessions as listed to admins.
+type AdminSession struct {
+	ID         string
+	DeviceName string
+	IPAddress  string
+	UserAgent  string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+}
+
+// FeatureFlag is a named switch admins can flip at runtime.
+type FeatureFlag struct {
+	Key         string
+	Enabled     bool
+	Description string
+	UpdatedBy   *string
+	UpdatedAt   time.Time
+}
+
+// MaintenanceState describes whether the public API is refusing traffic.
+// Forced is set when MAINTENANCE_MODE turned it on for the process; the
+// admin API cannot turn that off.
+type MaintenanceState struct {
+	Enabled bool
+	Message string
+	Since   *time.Time
+	Forced  bool
+}
+
+// ErasureReport is the result of checking every table for data about an
+// erased user. Remaining counts rows still found per table; blob counts are
+// the attachment objects removed from, or left in, the blob store.
+type ErasureReport struct {
+	UserID       string
+	Remaining    map[string]int
+	BlobsDeleted int
+	BlobsFailed  int
+	CheckedAt    time.Time
+}
+
+// Clean reports whether nothing about the user is left anywhere.
+func (r ErasureReport) Clean() bool {
+	for _, n := range r.Remaining {
+		if n > 0 {
+			return false
+		}
+	}
+	return r.BlobsFailed == 0
+}
+
+type AdminRepository interface {
+	// GetInstanceStats computes every figure of InstanceStats. DailyActive
+	// gets one entry for each of the last `days` UTC days, today included.
+	GetInstanceStats(ctx context.Context, days int) (InstanceStats, error)
+	// SearchUsers matches query against the start of the email address.
+	SearchUsers(ctx context.Context, query string, limit int) ([]AdminUser, error)
+	GetUser(ctx context.Context, userID string) (AdminUser, error)
+	ListActiveSessions(ctx context.Context, userID string) ([]AdminSession, error)
+	// RevokeSession reports whether an active session of userID was revoked.
+	RevokeSession(ctx context.Context, userID, sessionID string) (bool, error)
+	ListFeatureFlags(ctx context.Context) ([]FeatureFlag, error)
+	SetFeatureFlag(ctx context.Context, flag FeatureFlag) (FeatureFlag, error)
+	// GetMaintenance returns the stored maintenance state, or the zero
+	// state when none was ever stored.
+	GetMaintenance(ctx context.Context) (MaintenanceState, error)
+	SaveMaintenance(ctx context.Context, state MaintenanceState, updatedBy *string) error
+	// EraseUser deletes the user and every row that refers to or mentions
+	// them, audit history included, and returns the storage paths of their
+	// attachments for the caller to remove from the blob store.
+	EraseUser(ctx context.Context, userID, email string) ([]string, error)
+	// CountUserData counts, per table, the rows still holding data about
+	// the user, matched by ID and, when not empty, by email.
+	CountUserData(ctx context.Context, userID, email string) (map[string]int, error)
+	// MergeAccounts moves everything input.SourceUserID owns or takes part
+	// in to input.TargetUserID and tombstones the source, in one
+	// transaction, and returns the rows moved per table. ErrNotFound means
+	// the source does not exist or was already merged.
+	MergeAccounts(ctx context.Context, input AccountMergeInput) (map[string]int64, error)
+}
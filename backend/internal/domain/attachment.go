@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// MaxAttachmentsPerItem bounds how many files one vault item can carry.
+const MaxAttachmentsPerItem = 20
+
+var (
+	ErrAttachmentTooLarge = newError(KindInvalid, "attachment_too_large", "attachment exceeds the maximum size")
+	ErrAttachmentLimit    = newError(KindConflict, "attachment_limit", "item has the maximum number of attachments")
+	// ErrSignedURLUnavailable means the blob store cannot hand out signed
+	// download URLs; the client downloads through the API instead.
+	ErrSignedURLUnavailable = newError(KindNotFound, "signed_url_unavailable", "attachments are downloaded through the API on this server")
+)
+
+// VaultAttachment is a file stored alongside a vault item. The client
+// encrypts both the file and its name, with the item's data key, before
+// uploading; the server keeps the ciphertext in a blob store at
+// StoragePath and only ever sees its size.
+type VaultAttachment struct {
+	ID                 string
+	ItemID             string
+	FileNameCiphertext []byte
+	Nonce              []byte
+	SizeBytes          int64
+	StoragePath        string
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+// AttachmentRepository stores attachment rows; the blobs themselves live in
+// a blob store. Reads and deletes are scoped to the owner of the item.
+type AttachmentRepository interface {
+	CreateAttachment(ctx context.Context, a VaultAttachment) error
+	// ListAttachments returns the item's attachments, oldest first.
+	ListAttachments(ctx context.Context, itemID, ownerUserID string) ([]VaultAttachment, error)
+	// ListAttachmentsByOwner returns the attachments of the owner's active
+	// items, by item and then oldest first.
+	ListAttachmentsByOwner(ctx context.Context, ownerUserID string) ([]VaultAttachment, error)
+	GetAttachment(ctx context.Context, itemID, ownerUserID, attachmentID string) (VaultAttachment, error)
+	DeleteAttachment(ctx context.Context, itemID, ownerUserID, attachmentID string) error
+	// DeleteTrashedAttachments removes the attachments of items that have
+	// been in the trash since before deletedBefore and returns their
+	// storage paths, so the blobs can be deleted before the items are
+	// purged.
+	DeleteTrashedAttachments(ctx context.Context, deletedBefore time.Time) ([]string, error)
+}
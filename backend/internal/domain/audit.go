@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 
@@ -10,35 +11,151 @@ import (
 type EventType string
 
 const (
+	EventTypeAuthRegistered     EventType = "auth_registered"
 	EventTypeAuthLoginSuccess   EventType = "auth_login_success"
 	EventTypeAuthLoginFailed    EventType = "auth_login_failed"
 	EventTypeAuthLogout         EventType = "auth_logout"
 	EventTypeAuthProfileUpdated EventType = "auth_profile_updated"
+	EventTypeAuthPasswordReset  EventType = "auth_password_reset"
 	EventTypeMFASetup           EventType = "mfa_setup"
 	EventTypeMFADisabled        EventType = "mfa_disabled"
 	EventTypeRecoverySetup      EventType = "recovery_setup"
+	// A recovery attempt with the wrong recovery key.
+	EventTypeRecoveryFailed     EventType = "recovery_failed"
+	EventTypeAuthStepUpVerified EventType = "auth_step_up_verified"
+	EventTypeAuthDeviceRemoved  EventType = "auth_device_removed"
+	// Every session signed out from the link in a lockout alert email.
+	EventTypeAuthSessionsRevokedByLink EventType = "auth_sessions_revoked_by_link"
+	// Recorded on both accounts of a merge: the one kept and the tombstone.
+	EventTypeAuthAccountMerged EventType = "auth_account_merged"
+	// A password reset link was emailed; the reset itself is
+	// EventTypeAuthPasswordReset.
+	EventTypeAuthPasswordResetRequested EventType = "auth_password_reset_requested"
+	// One session, or every other one, signed out from the sessions list.
+	EventTypeAuthSessionRevoked EventType = "auth_session_revoked"
 
-	EventTypeVaultItemCreated   EventType = "vault_item_created"
-	EventTypeVaultItemUpdated   EventType = "vault_item_updated"
-	EventTypeVaultItemDeleted   EventType = "vault_item_deleted"
-	EventTypeVaultItemRestored  EventType = "vault_item_restored"
-	EventTypeVaultFolderCreated EventType = "vault_folder_created"
-	EventTypeVaultFolderDeleted EventType = "vault_folder_deleted"
+	EventTypeAuthSessionBindingUpdated EventType = "auth_session_binding_updated"
+	EventTypeAuthDPoPKeyRegistered     EventType = "auth_dpop_key_registered"
 
-	EventTypeSharingItemShared EventType = "sharing_item_shared"
-	EventTypeSharingRevoked    EventType = "sharing_revoked"
+	EventTypeAuthPasskeyAdded     EventType = "auth_passkey_added"
+	EventTypeAuthPasskeyRemoved   EventType = "auth_passkey_removed"
+	EventTypeAuthPasskeyRecovered EventType = "auth_passkey_recovered"
+	EventTypeAuthQRLoginApproved  EventType = "auth_qr_login_approved"
+	EventTypeAuthQRLoginDenied    EventType = "auth_qr_login_denied"
+
+	EventTypeAuthDataExportRequested  EventType = "auth_data_export_requested"
+	EventTypeAuthDataExportDownloaded EventType = "auth_data_export_downloaded"
+
+	EventTypeVaultItemCreated       EventType = "vault_item_created"
+	EventTypeVaultItemUpdated       EventType = "vault_item_updated"
+	EventTypeVaultItemDeleted       EventType = "vault_item_deleted"
+	EventTypeVaultItemRestored      EventType = "vault_item_restored"
+	EventTypeVaultAttachmentAdded   EventType = "vault_attachment_added"
+	EventTypeVaultAttachmentDeleted EventType = "vault_attachment_deleted"
+	EventTypeVaultFolderCreated     EventType = "vault_folder_created"
+	EventTypeVaultFolderUpdated     EventType = "vault_folder_updated"
+	EventTypeVaultFolderDeleted     EventType = "vault_folder_deleted"
+	EventTypeVaultExported          EventType = "vault_exported"
+	EventTypeVaultImported          EventType = "vault_imported"
+
+	EventTypeSharingItemShared  EventType = "sharing_item_shared"
+	EventTypeSharingRevoked     EventType = "sharing_revoked"
+	EventTypeSharingKeysUpdated EventType = "sharing_keys_updated"
+	// Recorded when the owner changes what a recipient may do with a share.
+	EventTypeSharingPermissionsUpdated EventType = "sharing_permissions_updated"
+	// Access requests are logged for the requester when made, and for the
+	// approver, and the owner if someone else decided, when decided.
+	EventTypeSharingAccessRequested EventType = "sharing_access_requested"
+	EventTypeSharingAccessApproved  EventType = "sharing_access_approved"
+	EventTypeSharingAccessDenied    EventType = "sharing_access_denied"
+	// Invitations of emails without an account. Ready is logged for the
+	// sharer once the invitee has keys, and prompts their client to
+	// complete the share.
+	EventTypeSharingInvitationSent      EventType = "sharing_invitation_sent"
+	EventTypeSharingInvitationReady     EventType = "sharing_invitation_ready"
+	EventTypeSharingInvitationCancelled EventType = "sharing_invitation_cancelled"
+	// Logged for the owner when a recipient first accesses a share of an
+	// item with an access alert.
+	EventTypeSharingItemAccessed EventType = "sharing_item_accessed"
 
 	EventTypeFamilyInviteSent     EventType = "family_invite_sent"
 	EventTypeFamilyInviteAccepted EventType = "family_invite_accepted"
+	EventTypeFamilyInviteRejected EventType = "family_invite_rejected"
 	EventTypeFamilyMemberRemoved  EventType = "family_member_removed"
+
+	EventTypeWebhookCreated EventType = "webhook_created"
+	EventTypeWebhookDeleted EventType = "webhook_deleted"
+
+	EventTypeMachineSecretPublished   EventType = "machine_secret_published"
+	EventTypeMachineSecretUnpublished EventType = "machine_secret_unpublished"
+	EventTypeMachineKeyCreated        EventType = "machine_key_created"
+	EventTypeMachineKeyDeleted        EventType = "machine_key_deleted"
+	// Every read of a machine secret by an API key is logged, granted or not.
+	EventTypeMachineSecretRead       EventType = "machine_secret_read"
+	EventTypeMachineSecretReadDenied EventType = "machine_secret_read_denied"
+
+	EventTypeNotificationPreferencesUpdated EventType = "notification_preferences_updated"
+
+	EventTypeSystemJobFailed EventType = "system_job_failed"
+	EventTypeSystemIPBanned  EventType = "system_ip_banned"
+
+	// Security events report unusual activity on an account and are
+	// exported to SIEMs as high severity.
+	EventTypeSecurityImpossibleTravel     EventType = "security_impossible_travel"
+	EventTypeSecurityMassDownload         EventType = "security_mass_download"
+	EventTypeSecurityExportAfterNewDevice EventType = "security_export_after_new_device"
+	EventTypeSecuritySessionTokenReused   EventType = "security_session_token_reused"
+	EventTypeSecuritySessionMoved         EventType = "security_session_moved"
+	// An account reached the MFA lockout or the failed password alert
+	// threshold; see LoginFailuresExceeded.
+	EventTypeSecurityLoginFailuresExceeded EventType = "security_login_failures_exceeded"
+
+	EventTypeAdminAuditQueried    EventType = "admin_audit_queried"
+	EventTypeAdminUsersSearched   EventType = "admin_users_searched"
+	EventTypeAdminUserViewed      EventType = "admin_user_viewed"
+	EventTypeAdminSessionsRevoked EventType = "admin_sessions_revoked"
+	EventTypeAdminLockoutCleared  EventType = "admin_lockout_cleared"
+	EventTypeAdminFeatureFlagSet  EventType = "admin_feature_flag_set"
+	EventTypeAdminMaintenanceSet  EventType = "admin_maintenance_set"
+	EventTypeAdminUserErased      EventType = "admin_user_erased"
+	EventTypeAdminIPBanLifted     EventType = "admin_ip_ban_lifted"
+	// An admin flagged the account's credentials as compromised.
+	EventTypeAdminCredentialsCompromised EventType = "admin_credentials_compromised"
 )
 
+// LoginFailuresExceeded is the data of a security_login_failures_exceeded
+// event. Reason is "mfa" when the account's two-factor lockout kicked in,
+// until LockedUntil, and "password" when failed password attempts reached
+// the alert threshold. The failed attempts counted are the account's
+// auth_login_failed events since Since.
+type LoginFailuresExceeded struct {
+	Reason         string     `json:"reason"`
+	FailedAttempts int        `json:"failed_attempts"`
+	Since          time.Time  `json:"since"`
+	LockedUntil    *time.Time `json:"locked_until,omitempty"`
+}
+
+// LoginHistoryCategory is the event type prefix shared by login attempts,
+// successful or not; LOGIN_HISTORY_RETENTION applies to it.
+const LoginHistoryCategory = "auth_login"
+
 type AuditEvent struct {
-	ID        uuid.UUID       `json:"id"`
-	UserID    *uuid.UUID      `json:"user_id,omitempty"` // Can be null if it's an anonymous action (e.g. failed login with invalid user)
-	EventType EventType       `json:"event_type"`
-	EventData json.RawMessage `json:"event_data"` // Stores specific event details like ip_address, item_name, friend_id, etc.
-	CreatedAt time.Time       `json:"created_at"`
+	ID          uuid.UUID       `json:"id"`
+	UserID      *uuid.UUID      `json:"user_id,omitempty"`       // Can be null if it's an anonymous action (e.g. failed login with invalid user)
+	ActorUserID *uuid.UUID      `json:"actor_user_id,omitempty"` // Authenticated user who made the request, if any
+	EventType   EventType       `json:"event_type"`
+	EventData   json.RawMessage `json:"event_data"` // Stores specific event details like ip_address, item_name, friend_id, etc.
+	IPAddress   string          `json:"ip_address,omitempty"`
+	UserAgent   string          `json:"user_agent,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	RecordedAt  time.Time       `json:"-"` // When the row was inserted; may lag CreatedAt when delivered via the outbox
+}
+
+// AuditCursor marks the last event an exporter has shipped. Events are
+// ordered by the time they were inserted, then by ID.
+type AuditCursor struct {
+	RecordedAt time.Time
+	EventID    uuid.UUID
 }
 
 type AuditPaginatedResponse struct {
@@ -48,8 +165,40 @@ type AuditPaginatedResponse struct {
 }
 
 type AuditFilter struct {
-	Category  string     `json:"category"`
-	Search    string     `json:"search"`
-	StartDate *time.Time `json:"start_date"`
-	EndDate   *time.Time `json:"end_date"`
+	UserID     *uuid.UUID  `json:"user_id,omitempty"` // nil matches every user (admin queries only)
+	Category   string      `json:"category"`
+	EventTypes []EventType `json:"event_types,omitempty"`
+	IPAddress  string      `json:"ip_address,omitempty"`
+	Search     string      `json:"search"`
+	StartDate  *time.Time  `json:"start_date"`
+	EndDate    *time.Time  `json:"end_date"`
+}
+
+// AuditPartitionsAhead is how many monthly audit partitions, the current
+// month's included, are kept created in advance.
+const AuditPartitionsAhead = 3
+
+type AuditRepository interface {
+	// CreateEvent is idempotent on the event ID.
+	CreateEvent(ctx context.Context, event AuditEvent) error
+	ListEvents(ctx context.Context, limit int, offset int, filter AuditFilter) ([]AuditEvent, int, error)
+	// StreamEvents calls fn for every event matching filter, newest first,
+	// and stops at the first error fn returns.
+	StreamEvents(ctx context.Context, filter AuditFilter, fn func(AuditEvent) error) error
+	DeleteUserLogs(ctx context.Context, userID uuid.UUID) error
+	// DeleteEventsBefore deletes up to limit events recorded before the
+	// cutoff, only those whose type starts with category when it is not
+	// empty, and reports how many it deleted.
+	DeleteEventsBefore(ctx context.Context, before time.Time, category string, limit int) (int64, error)
+	// EnsurePartitions creates the monthly partitions for events created in
+	// the month of from and the months-1 after it, and DropPartitionsBefore
+	// drops those holding only events created before the cutoff; both
+	// return the partitions they changed. Only Postgres partitions audit
+	// events; elsewhere both do nothing.
+	EnsurePartitions(ctx context.Context, from time.Time, months int) ([]string, error)
+	DropPartitionsBefore(ctx context.Context, before time.Time) ([]string, error)
+	// ListEventsAfter and AdvanceExportCursor back the SIEM exporter; see
+	// siem.EventSource.
+	ListEventsAfter(ctx context.Context, cursor AuditCursor, settle time.Duration, limit int) ([]AuditEvent, error)
+	AdvanceExportCursor(ctx context.Context, exporter string, fn func(AuditCursor) (AuditCursor, error)) (locked bool, err error)
 }
@@ -2,26 +2,34 @@ package domain
 
 import (
 	"context"
-	"errors"
 	"time"
 )
 
 var (
-	ErrEmailTaken           = errors.New("email already registered")
-	ErrInvalidCredentials   = errors.New("invalid credentials")
-	ErrWeakPassword         = errors.New("password does not meet complexity requirements")
-	ErrMFARequired          = errors.New("mfa required")
-	ErrInvalidMFA           = errors.New("invalid totp code")
-	ErrInvalidMFAInput      = errors.New("invalid mfa input")
-	ErrMFARateLimited       = errors.New("mfa attempts rate limited")
-	ErrUnauthorizedSession  = errors.New("unauthorized")
-	ErrMissingTOTPSecret    = errors.New("totp secret not configured")
-	ErrInvalidVaultPayload  = errors.New("invalid vault payload")
-	ErrNotFound             = errors.New("not found")
-	ErrRecoveryNotSetup     = errors.New("account recovery not configured")
-	ErrInvalidRecoveryKey   = errors.New("invalid recovery key")
-	ErrRecoveryCooldown     = errors.New("recovery attempted too recently")
-	ErrInvalidRecoveryToken = errors.New("invalid or expired recovery token")
+	ErrEmailTaken            = newError(KindConflict, "email_taken", "email already registered")
+	ErrInvalidCredentials    = newError(KindUnauthorized, "invalid_credentials", "invalid email or password")
+	ErrWeakPassword          = newError(KindInvalid, "weak_password", "password does not meet complexity requirements")
+	ErrBreachedPassword      = newError(KindInvalid, "breached_password", "password has appeared in a data breach, choose another")
+	ErrMFARequired           = newError(KindUnauthorized, "mfa_required", "totp code is required for this account")
+	ErrInvalidMFA            = newError(KindUnauthorized, "invalid_mfa", "invalid totp or recovery code")
+	ErrInvalidMFAInput       = newError(KindInvalid, "invalid_mfa_input", "provide one of totp_code, recovery_code or passkey_assertion")
+	ErrMFARateLimited        = newError(KindRateLimited, "mfa_rate_limited", "too many invalid mfa attempts, try again later")
+	ErrUnauthorizedSession   = newError(KindUnauthorized, "unauthorized", "invalid or expired session")
+	ErrMissingTOTPSecret     = newError(KindInvalid, "totp_not_initialized", "totp setup required before enable")
+	ErrInvalidVaultPayload   = newError(KindInvalid, "invalid_vault_payload", "vault item payload is invalid")
+	ErrNotFound              = newError(KindNotFound, "not_found", "not found")
+	ErrRecoveryNotSetup      = newError(KindInvalid, "recovery_not_setup", "account recovery is not configured")
+	ErrInvalidRecoveryKey    = newError(KindUnauthorized, "invalid_recovery_key", "invalid recovery key")
+	ErrRecoveryCooldown      = newError(KindRateLimited, "recovery_cooldown", "recovery attempted too recently, try again later")
+	ErrInvalidRecoveryToken  = newError(KindUnauthorized, "invalid_recovery_token", "invalid or expired recovery token")
+	ErrStepUpRequired        = newError(KindForbidden, "step_up_required", "unusual activity was detected on this session; verify a totp code to continue")
+	ErrInvalidSessionBinding = newError(KindInvalid, "invalid_session_binding", "invalid session binding")
+	ErrInvalidDPoPProof      = newError(KindUnauthorized, "invalid_dpop_proof", "a valid dpop proof is required for this session")
+	ErrDPoPNotEnabled        = newError(KindForbidden, "dpop_not_enabled", "dpop is not enabled for this client type")
+	ErrDPoPKeyRegistered     = newError(KindConflict, "dpop_key_registered", "this session is already bound to a dpop key")
+	ErrServerBusy            = newError(KindUnavailable, "server_busy", "the server is busy, try again shortly")
+	ErrDatabaseUnavailable   = newError(KindUnavailable, "database_unavailable", "the database is unavailable, try again shortly")
+	ErrInvalidRevokeLink     = newError(KindUnauthorized, "invalid_revoke_link", "invalid or expired sign-out link")
 )
 
 type Argon2Params struct {
@@ -31,13 +39,94 @@ type Argon2Params struct {
 	KeyLength   uint32 `json:"key_length"`
 }
 
+// UserRole is stored on the users row. Admins are promoted explicitly with
+// `migrate grant-admin <email>`; there is no API to change roles.
+type UserRole string
+
+const (
+	UserRoleUser  UserRole = "user"
+	UserRoleAdmin UserRole = "admin"
+)
+
 type Session struct {
 	ID          string
 	UserID      string
 	Email       string
 	Name        string
+	Role        UserRole
 	TOTPEnabled bool
 	ExpiresAt   time.Time
+	// StepUpRequired is set when unusual activity was detected on the
+	// session; it is cleared by verifying a TOTP code.
+	StepUpRequired bool
+	// DeviceID is the device that signed the session in, if known.
+	DeviceID string
+	// IPAddr and UserAgent are the client the session is bound to: the one
+	// that signed it in, or the last one to pass step-up on it.
+	IPAddr    string
+	UserAgent string
+	// Binding is the account's session binding setting.
+	Binding SessionBinding
+	// DPoPThumbprint identifies the key every request on the session must
+	// carry a proof from; empty for sessions not bound to a key.
+	DPoPThumbprint string
+	// CredentialResetRequired is set while an admin has flagged the
+	// account's credentials as compromised; until the master password is
+	// changed the session only reaches the routes that change it.
+	CredentialResetRequired bool
+
+	// LastUsedAt is when the session last authenticated a request, nil
+	// until it has.
+	LastUsedAt *time.Time
+}
+
+// UserSession is one of a user's active sessions as listed to the user.
+type UserSession struct {
+	ID         string
+	DeviceName string
+	IPAddress  string
+	UserAgent  string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	ExpiresAt  time.Time
+}
+
+// SessionBindingAction is what happens to a bound session used from a
+// client it is not bound to.
+type SessionBindingAction string
+
+const (
+	// SessionBindingRevoke signs the session out.
+	SessionBindingRevoke SessionBindingAction = "revoke"
+	// SessionBindingStepUp flags the session for step-up; accounts without
+	// TOTP cannot step up, and are signed out instead.
+	SessionBindingStepUp SessionBindingAction = "step_up"
+)
+
+// SessionBinding is a per-account setting that ties each session to the
+// network and browser that signed it in, so a stolen session token fails
+// from another machine. It is off by default.
+type SessionBinding struct {
+	// IPPrefix binds the session to its client's /24 (IPv4) or /48 (IPv6).
+	IPPrefix bool
+	// UserAgent binds the session to its client's browser family.
+	UserAgent  bool
+	OnMismatch SessionBindingAction
+}
+
+// DefaultSessionBinding is the setting of an account that never changed it.
+func DefaultSessionBinding() SessionBinding {
+	return SessionBinding{OnMismatch: SessionBindingStepUp}
+}
+
+// Enabled reports whether the binding checks anything.
+func (b SessionBinding) Enabled() bool {
+	return b.IPPrefix || b.UserAgent
+}
+
+// Valid reports whether the binding can be stored.
+func (b SessionBinding) Valid() bool {
+	return b.OnMismatch == SessionBindingRevoke || b.OnMismatch == SessionBindingStepUp
 }
 
 type LoginInput struct {
@@ -48,6 +137,14 @@ type LoginInput struct {
 	DeviceName   string
 	IPAddr       string
 	UserAgent    string
+	// DeviceFingerprint is an optional client-computed identifier of the
+	// device, more stable than its user agent.
+	DeviceFingerprint string
+
+	// PasskeyAssertion answers the second-factor step with one of the
+	// account's passkeys instead of TOTPCode or RecoveryCode. Its challenge
+	// comes from BeginPasskeySecondFactor.
+	PasskeyAssertion *PasskeyAssertion
 }
 
 type LoginOutput struct {
@@ -57,6 +154,15 @@ type LoginOutput struct {
 	Email        string
 	Name         string
 	TOTPEnabled  bool
+	// CredentialResetRequired tells the client to go straight to the
+	// master password change; see Session.CredentialResetRequired.
+	CredentialResetRequired bool
+
+	// AccessToken, when set, authenticates requests until
+	// AccessTokenExpiresAt without a database read; SessionToken then
+	// serves to refresh it.
+	AccessToken          string
+	AccessTokenExpiresAt time.Time
 }
 
 type RegisterOutput struct {
@@ -78,6 +184,10 @@ type CreateUserInput struct {
 	ParamsJSON   []byte
 	Salt         []byte
 	PasswordHash []byte
+
+	// CanonicalEmail is Email with provider aliases folded, set when alias
+	// normalization is on; no two accounts share one.
+	CanonicalEmail string
 }
 
 type UserAuthRecord struct {
@@ -92,6 +202,14 @@ type UserAuthRecord struct {
 	TOTPFailedAttempts int
 	TOTPWindowStart    *time.Time
 	TOTPLockedUntil    *time.Time
+	// CredentialsCompromised is set by an admin and cleared by the next
+	// password change.
+	CredentialsCompromised bool
+}
+
+// Passwordless reports whether the account signs in with passkeys only.
+func (r UserAuthRecord) Passwordless() bool {
+	return len(r.PasswordHash) == 0
 }
 
 type CreateSessionInput struct {
@@ -101,9 +219,17 @@ type CreateSessionInput struct {
 	DeviceName string
 	IPAddr     string
 	UserAgent  string
+	DeviceID   string
 	ExpiresAt  time.Time
 }
 
+// RetiredSessionToken is a session token that a refresh replaced.
+type RetiredSessionToken struct {
+	SessionID string
+	UserID    string
+	RetiredAt time.Time
+}
+
 type TOTPState struct {
 	SecretEnc      []byte
 	Enabled        bool
@@ -112,6 +238,21 @@ type TOTPState struct {
 	LockedUntil    *time.Time
 }
 
+// SessionPurgeBatch is what one DeleteExpiredSessions call removed: how
+// many sessions, the highest ID among them (empty when none) and, as
+// measured by the purge, how long the statement took.
+type SessionPurgeBatch struct {
+	Deleted  int64
+	LastID   string
+	Duration time.Duration
+}
+
+// SessionPurgeStats sums up a purge of expired sessions, batch by batch.
+type SessionPurgeStats struct {
+	Deleted int64
+	Batches []SessionPurgeBatch
+}
+
 type RecoveryRecord struct {
 	UserID          string
 	RecoveryKeyHash []byte
@@ -141,10 +282,42 @@ type ResetPasswordInput struct {
 type AuthRepository interface {
 	CreateUserWithCredentials(ctx context.Context, input CreateUserInput) error
 	GetUserAuthByEmail(ctx context.Context, email string) (UserAuthRecord, error)
+	GetUserAuthByID(ctx context.Context, userID string) (UserAuthRecord, error)
 	CreateSession(ctx context.Context, input CreateSessionInput) error
 	GetActiveSessionByTokenHash(ctx context.Context, tokenHash []byte) (Session, error)
 	RevokeSessionByTokenHash(ctx context.Context, tokenHash []byte) (bool, error)
 	RevokeAllUserSessions(ctx context.Context, userID string) (int64, error)
+	RevokeSessionByID(ctx context.Context, sessionID string) (bool, error)
+	// ListUserSessions returns the user's active sessions, newest first.
+	ListUserSessions(ctx context.Context, userID string) ([]UserSession, error)
+	// RevokeUserSession reports whether an active session of userID was
+	// revoked.
+	RevokeUserSession(ctx context.Context, userID, sessionID string) (bool, error)
+	// RevokeOtherUserSessions revokes every active session of userID but
+	// keepSessionID.
+	RevokeOtherUserSessions(ctx context.Context, userID, keepSessionID string) (int64, error)
+	// TouchSession records usedAt as when the session was last used.
+	TouchSession(ctx context.Context, sessionID string, usedAt time.Time) error
+	// SessionActive reports whether the session exists, is not revoked
+	// and has not expired.
+	SessionActive(ctx context.Context, sessionID string) (bool, error)
+	// RotateSessionToken replaces the token of an active session, keeps
+	// the old one as retired and extends the session to expiresAt. It
+	// reports false when oldHash is no longer the session's token.
+	RotateSessionToken(ctx context.Context, sessionID string, oldHash, newHash []byte, expiresAt time.Time) (bool, error)
+	// GetRetiredSessionToken returns ErrNotFound for a hash that was never
+	// retired, or whose session has been deleted.
+	GetRetiredSessionToken(ctx context.Context, tokenHash []byte) (RetiredSessionToken, error)
+	// SetSessionStepUp sets or clears the session's StepUpRequired flag.
+	SetSessionStepUp(ctx context.Context, sessionID string, required bool) error
+	// RebindSession records ipAddr and userAgent as the client the session
+	// is bound to.
+	RebindSession(ctx context.Context, sessionID string, ipAddr string, userAgent string) error
+	GetSessionBinding(ctx context.Context, userID string) (SessionBinding, error)
+	// SetSessionDPoPKey binds the session to a DPoP key. It reports false
+	// if the session is gone or already bound.
+	SetSessionDPoPKey(ctx context.Context, sessionID string, thumbprint string) (bool, error)
+	SetSessionBinding(ctx context.Context, userID string, binding SessionBinding) error
 	SetTOTPSecret(ctx context.Context, userID string, secretEnc []byte) (bool, error)
 	EnableTOTP(ctx context.Context, userID string) error
 	DisableTOTP(ctx context.Context, userID string) error
@@ -153,10 +326,18 @@ type AuthRepository interface {
 	ResetTOTPFailures(ctx context.Context, userID string) error
 	ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes [][]byte) error
 	ConsumeRecoveryCode(ctx context.Context, userID string, codeHash []byte) (bool, error)
-	DeleteExpiredSessions(ctx context.Context) (int64, error)
+	// DeleteExpiredSessions deletes, in ID order, up to limit sessions with
+	// IDs above afterID (all of them when empty) that have expired or were
+	// revoked before revokedBefore. The batch's LastID is the afterID of
+	// the next call, so a purge never rescans the rows it already passed.
+	DeleteExpiredSessions(ctx context.Context, revokedBefore time.Time, afterID string, limit int) (SessionPurgeBatch, error)
 	SetupRecovery(ctx context.Context, input SetupRecoveryInput) error
 	GetRecoveryRecord(ctx context.Context, userID string) (RecoveryRecord, error)
 	UpdateLastRecoveryAt(ctx context.Context, userID string) error
+	// UpdatePassword also clears the compromised flag.
 	UpdatePassword(ctx context.Context, input ResetPasswordInput) error
+	// SetCredentialsCompromised flags the account's credentials as
+	// compromised until the next UpdatePassword.
+	SetCredentialsCompromised(ctx context.Context, userID string) error
 	UpdateDisplayName(ctx context.Context, userID string, name string) error
 }
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// URIHMACSize is the length of a URI HMAC: HMAC-SHA256 of a normalized
+	// URI under a key derived from the vault key on the client. The server
+	// matches HMACs but never learns the URIs.
+	URIHMACSize = 32
+	// MaxURIHMACsPerItem bounds the URIs one item can be matched on, and
+	// the HMACs one match query may ask for.
+	MaxURIHMACsPerItem = 32
+)
+
+var ErrInvalidURIHMAC = newError(KindInvalid, "invalid_uri_hmac", "URI HMACs must be 32 bytes each, at most 32 of them")
+
+// ItemUsage is how often, and when last, an item was used to autofill.
+type ItemUsage struct {
+	ItemID     string
+	UseCount   int64
+	LastUsedAt *time.Time
+}
+
+// AutofillItem is an active item as the browser extension lists it: the
+// item, whose ciphertext the API leaves out of compact responses, with the
+// URI HMACs it matches on and its usage.
+type AutofillItem struct {
+	VaultItem
+	URIHMACs [][]byte
+	Usage    ItemUsage
+}
+
+// AutofillRepository holds what the browser extension needs beside the
+// items themselves. Rows go away with their item.
+type AutofillRepository interface {
+	// SetURIHMACs replaces the URI HMACs of an item the caller has checked
+	// ownerUserID owns.
+	SetURIHMACs(ctx context.Context, ownerUserID, itemID string, hmacs [][]byte) error
+	// ListURIHMACs returns the URI HMACs of the owner's items by item ID.
+	ListURIHMACs(ctx context.Context, ownerUserID string) (map[string][][]byte, error)
+	// MatchURIHMACs returns the IDs of the owner's active items that carry
+	// any of hmacs.
+	MatchURIHMACs(ctx context.Context, ownerUserID string, hmacs [][]byte) ([]string, error)
+	// RecordItemUse counts one use of an active item of the owner, or
+	// returns ErrNotFound.
+	RecordItemUse(ctx context.Context, ownerUserID, itemID string, usedAt time.Time) (ItemUsage, error)
+	// ListItemUsage returns the usage of the owner's items by item ID;
+	// items never used are missing.
+	ListItemUsage(ctx context.Context, ownerUserID string) (map[string]ItemUsage, error)
+}
@@ -0,0 +1,22 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// BackupRecord is an entry of backups_registry: a copy of vault data that
+// was taken off the server. StorageURI says where it went; Checksum is nil
+// when the copy was streamed without being stored.
+type BackupRecord struct {
+	ID              string
+	CreatedByUserID string
+	StorageURI      string
+	ChecksumSHA256  []byte
+	Encrypted       bool
+	CreatedAt       time.Time
+}
+
+type BackupRegistryRepository interface {
+	RegisterBackup(ctx context.Context, record BackupRecord) error
+}
@@ -0,0 +1,31 @@
+package domain
+
+var (
+	ErrCredentialResetRequired = newError(KindForbidden, "credential_reset_required", "the credentials of this account were reported compromised; change the master password to continue")
+	ErrInvalidNewSalt          = newError(KindInvalid, "invalid_new_salt", "new_salt must be 32 random bytes, different from the current salt")
+	ErrRewrapIncomplete        = newError(KindInvalid, "rewrap_incomplete", "a re-wrapped key is required for every vault item, trashed ones included, and only once")
+	ErrCredentialResetNotDue   = newError(KindConflict, "credential_reset_not_required", "the credentials of this account are not flagged compromised")
+	ErrPasswordUnchanged       = newError(KindInvalid, "password_unchanged", "the new password must differ from the current one")
+)
+
+// VaultItemKey is the data key of a vault item wrapped under a new
+// key-encryption key.
+type VaultItemKey struct {
+	ItemID     string
+	WrappedDEK []byte
+	WrapNonce  []byte
+}
+
+// CredentialResetInput changes the master password of an account, at its
+// owner's request or because its credentials were flagged compromised.
+// Clients derive the vault's key-encryption key from the master password
+// and the account salt, so the client picks the new salt itself, derives
+// the new key from it and sends every item key re-wrapped under that key
+// along with the password.
+type CredentialResetInput struct {
+	UserID          string
+	CurrentPassword string
+	NewPassword     string
+	NewSalt         []byte
+	Items           []VaultItemKey
+}
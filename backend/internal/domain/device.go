@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// MaxDeviceFingerprintLength bounds the fingerprint a client may send at
+// sign-in.
+const MaxDeviceFingerprintLength = 512
+
+// Device is a browser or app a user has signed in from. Clients that send
+// a device fingerprint at sign-in are recognised by it; the others by their
+// user agent. Only a peppered hash of either is stored. A device that has
+// signed in before is trusted: signing in from it again raises no new
+// device alert.
+type Device struct {
+	ID     string
+	UserID string
+	// Fingerprinted is false for devices recognised by user agent only.
+	Fingerprinted bool
+	// Label is the user agent of the device's latest sign-in, for display.
+	Label       string
+	FirstSeenAt time.Time
+	LastSeenAt  time.Time
+}
+
+type DeviceRepository interface {
+	// TouchDevice records a sign-in from the user's device with keyHash,
+	// creating the device on first sight; created reports whether it did.
+	TouchDevice(ctx context.Context, userID string, keyHash []byte, fingerprinted bool, label string) (device Device, created bool, err error)
+	ListDevices(ctx context.Context, userID string) ([]Device, error)
+	// DeleteDevice forgets a device, so its next sign-in counts as new.
+	// Sessions it signed in keep working.
+	DeleteDevice(ctx context.Context, userID, deviceID string) error
+}
@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrorKind classifies a domain error independently of the transport. The
+// REST, gRPC and GraphQL layers each map it to their own status codes.
+type ErrorKind int
+
+const (
+	KindInternal ErrorKind = iota
+	KindInvalid
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindRateLimited
+	// KindUnavailable is a temporary overload; the caller should retry.
+	KindUnavailable
+)
+
+// Error is an error the API may report to its caller as is. Code is the
+// stable machine-readable code clients branch on (and the i18n key suffix
+// of Message); Message is safe to show and never carries internal detail.
+// The service layer returns these, wrapped or not; anything that is not an
+// *Error is an unexpected failure.
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	// LockedUntil is set on a rate-limited error whose lockout has a known
+	// end, so the caller can be told when to try again.
+	LockedUntil time.Time
+}
+
+func newError(kind ErrorKind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is matches errors of the same code, so an error returned by WithDetail
+// still satisfies errors.Is against the catalog entry it came from.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithDetail returns a copy of e whose message ends with detail. detail is
+// shown to the caller, so it must be as safe as the message itself.
+func (e *Error) WithDetail(detail string) *Error {
+	out := *e
+	out.Message = e.Message + ": " + detail
+	return &out
+}
+
+// WithLockedUntil returns a copy of e that reports the lockout ends at until.
+func (e *Error) WithLockedUntil(until time.Time) *Error {
+	out := *e
+	out.LockedUntil = until.UTC()
+	return &out
+}
+
+// AsError returns the *Error in err's chain, if any.
+func AsError(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+var ErrDataExportNotReady = newError(KindConflict, "export_not_ready", "data export is not ready yet")
+
+// Data export states. A pending export is built by the outbox handler for
+// OutboxTopicDataExport; a ready one can be downloaded until it expires.
+const (
+	DataExportPending = "pending"
+	DataExportReady   = "ready"
+)
+
+// DataExport is a user's request for a copy of every piece of personal data
+// the server holds about them. The archive itself is only loaded by
+// GetDataExportArchive.
+type DataExport struct {
+	ID          string
+	UserID      string
+	Status      string
+	SizeBytes   int64
+	CreatedAt   time.Time
+	CompletedAt *time.Time
+	ExpiresAt   *time.Time
+}
+
+// Expired reports whether a ready export may no longer be downloaded.
+func (e DataExport) Expired(now time.Time) bool {
+	return e.ExpiresAt != nil && !e.ExpiresAt.After(now)
+}
+
+// VaultBackupDiff compares the vault items in a data export with the live
+// vault by ID and version, so a user can see what restoring the export would
+// change. Seen from the export: Added items were created since it was taken,
+// Removed ones deleted and Changed ones edited. Items in the trash count as
+// deleted.
+type VaultBackupDiff struct {
+	ExportID  string
+	TakenAt   time.Time
+	Added     []VaultItemDiff
+	Removed   []VaultItemDiff
+	Changed   []VaultItemDiff
+	Unchanged int
+}
+
+// VaultItemDiff is one item of a VaultBackupDiff. BackupVersion is 0 for an
+// added item and LiveVersion 0 for a removed one.
+type VaultItemDiff struct {
+	ItemID        string
+	BackupVersion int
+	LiveVersion   int
+}
+
+// DataExportRequest is the OutboxTopicDataExport payload.
+type DataExportRequest struct {
+	ExportID string `json:"export_id"`
+	UserID   string `json:"user_id"`
+}
+
+type DataExportRepository interface {
+	CreateDataExport(ctx context.Context, export DataExport) (DataExport, error)
+	// GetDataExport returns ErrNotFound unless exportID belongs to userID.
+	GetDataExport(ctx context.Context, userID, exportID string) (DataExport, error)
+	// FindPendingDataExport returns ErrNotFound when the user has no export
+	// being built.
+	FindPendingDataExport(ctx context.Context, userID string) (DataExport, error)
+	// GetDataExportArchive returns ErrNotFound unless exportID belongs to
+	// userID and is ready.
+	GetDataExportArchive(ctx context.Context, userID, exportID string) ([]byte, error)
+	CompleteDataExport(ctx context.Context, exportID string, archive []byte, completedAt, expiresAt time.Time) error
+	// DeleteExpiredDataExports removes ready exports that expired before
+	// before, archives included.
+	DeleteExpiredDataExports(ctx context.Context, before time.Time) (int64, error)
+}
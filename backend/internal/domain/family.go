@@ -2,16 +2,15 @@ package domain
 
 import (
 	"context"
-	"errors"
 	"time"
 )
 
 var (
-	ErrFamilyRequestAlreadySent = errors.New("family request already sent")
-	ErrFamilyRequestNotFound    = errors.New("family request not found")
-	ErrAlreadyFamilyMember      = errors.New("already a family member")
-	ErrNotFamilyMember          = errors.New("not a family member")
-	ErrCannotAddSelf            = errors.New("cannot add yourself as a family member")
+	ErrFamilyRequestAlreadySent = newError(KindConflict, "request_exists", "a family request already exists with this user")
+	ErrFamilyRequestNotFound    = newError(KindNotFound, "request_not_found", "family request not found")
+	ErrAlreadyFamilyMember      = newError(KindConflict, "already_family", "this user is already a family member")
+	ErrNotFamilyMember          = newError(KindForbidden, "not_family_member", "you can only share with family members")
+	ErrCannotAddSelf            = newError(KindInvalid, "cannot_add_self", "you cannot add yourself as a family member")
 )
 
 // FamilyMembership represents a row in the family_memberships table.
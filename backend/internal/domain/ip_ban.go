@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+var ErrInvalidIP = newError(KindInvalid, "invalid_ip", "not a valid IP address")
+
+// IPBan is the ban record of one client address. Offenses counts the bans
+// it has earned, each longer than the last; the record, and with it the
+// escalation, is forgotten once its last ban has been over for long
+// enough.
+type IPBan struct {
+	IP          string
+	Offenses    int
+	Reason      string
+	BannedUntil time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Active reports whether the ban is still in force at now.
+func (b IPBan) Active(now time.Time) bool {
+	return now.Before(b.BannedUntil)
+}
+
+type IPBanRepository interface {
+	GetIPBan(ctx context.Context, ip string) (IPBan, error)
+	// SaveIPBan creates or replaces the record of ban.IP.
+	SaveIPBan(ctx context.Context, ban IPBan) (IPBan, error)
+	// ListActiveIPBans returns the bans in force at now, longest first.
+	ListActiveIPBans(ctx context.Context, now time.Time) ([]IPBan, error)
+	// DeleteIPBan lifts a ban and forgets the address's offenses.
+	DeleteIPBan(ctx context.Context, ip string) error
+	// DeleteIPBansEndedBefore forgets addresses whose last ban ended before
+	// the cutoff.
+	DeleteIPBansEndedBefore(ctx context.Context, before time.Time) (int64, error)
+}
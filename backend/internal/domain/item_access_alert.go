@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// ItemAccessAlert asks for the owner of ItemID to be told when a recipient
+// first accesses a share of it. The notice always goes to the owner's event
+// feed; Email also emails it.
+type ItemAccessAlert struct {
+	ItemID      string
+	OwnerUserID string
+	Email       bool
+	CreatedAt   time.Time
+}
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type JobRunStatus string
+
+const (
+	JobRunStatusRunning   JobRunStatus = "running"
+	JobRunStatusSucceeded JobRunStatus = "succeeded"
+	JobRunStatusFailed    JobRunStatus = "failed"
+)
+
+type JobRun struct {
+	ID         string
+	JobName    string
+	Status     JobRunStatus
+	Error      string
+	StartedAt  time.Time
+	FinishedAt *time.Time
+}
+
+type JobRepository interface {
+	// TryLock takes a cluster-wide lock for the named job. When acquired is
+	// false another replica is already running it; otherwise release must be
+	// called once the run is over.
+	TryLock(ctx context.Context, jobName string) (release func(), acquired bool, err error)
+	// LastRunStartedAt returns when the job last started on any replica, or
+	// the zero time if it never ran.
+	LastRunStartedAt(ctx context.Context, jobName string) (time.Time, error)
+	StartRun(ctx context.Context, jobName string, startedAt time.Time) (string, error)
+	FinishRun(ctx context.Context, runID string, status JobRunStatus, errMessage string, finishedAt time.Time) error
+	DeleteRunsBefore(ctx context.Context, before time.Time) (int64, error)
+}
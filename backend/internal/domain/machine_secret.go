@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// MaxMachineSecretNameLength bounds a machine secret's name, which CI
+// configuration refers to it by.
+const MaxMachineSecretNameLength = 128
+
+var (
+	ErrInvalidMachineSecretName = newError(KindInvalid, "invalid_secret_name", "name must be 1-128 letters, digits, '.', '_' or '-'")
+	ErrMachineSecretNameTaken   = newError(KindConflict, "secret_name_taken", "another item is already published under this name")
+	ErrInvalidMachineKeyScope   = newError(KindInvalid, "invalid_scope", "scopes must be secret names, optionally ending in '*'")
+	ErrInvalidMachineKeyName    = newError(KindInvalid, "invalid_api_key_name", "name must be 1-128 characters")
+	ErrMachineKeyLimitReached   = newError(KindConflict, "api_key_limit_reached", "api key limit reached")
+	ErrInvalidMachineKey        = newError(KindUnauthorized, "invalid_api_key", "api key is invalid or expired")
+	ErrMachineSecretOutOfScope  = newError(KindForbidden, "secret_out_of_scope", "api key is not scoped to this secret")
+)
+
+// MachineSecret is a vault item published for CI pipelines under Name.
+// The server never sees secrets in the clear, so the client publishes a
+// separate copy of the item's value, encrypted with a key it shares with
+// the pipelines out of band; the item's own ciphertext stays readable by
+// the user only. The copy is replaced whenever the client publishes again.
+type MachineSecret struct {
+	ID          string
+	OwnerUserID string
+	ItemID      string
+	Name        string
+	Ciphertext  []byte
+	Nonce       []byte
+	AlgoVersion string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// MachineKey is an API key a service account reads machine secrets with.
+// It may read the secrets of its owner its Scopes match: each scope is a
+// secret name, or a name prefix followed by '*'.
+type MachineKey struct {
+	ID          string
+	OwnerUserID string
+	Name        string
+	// Key is the plaintext key. It is only set on a newly created key;
+	// storage keeps KeyHash.
+	Key     string
+	KeyHash []byte
+	// Prefix is the start of the key, shown so users can tell keys apart.
+	Prefix     string
+	Scopes     []string
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+type MachineSecretRepository interface {
+	// UpsertMachineSecret publishes s for its item, replacing the copy
+	// published before. It returns ErrMachineSecretNameTaken when another
+	// item of the owner is published under the name.
+	UpsertMachineSecret(ctx context.Context, s MachineSecret) (MachineSecret, error)
+	ListMachineSecrets(ctx context.Context, ownerUserID string) ([]MachineSecret, error)
+	GetMachineSecretByName(ctx context.Context, ownerUserID, name string) (MachineSecret, error)
+	// DeleteMachineSecret returns ErrNotFound unless the item is published.
+	DeleteMachineSecret(ctx context.Context, ownerUserID, itemID string) error
+
+	CreateMachineKey(ctx context.Context, key MachineKey) (MachineKey, error)
+	ListMachineKeys(ctx context.Context, ownerUserID string) ([]MachineKey, error)
+	CountMachineKeys(ctx context.Context, ownerUserID string) (int, error)
+	GetMachineKeyByHash(ctx context.Context, keyHash []byte) (MachineKey, error)
+	// DeleteMachineKey returns ErrNotFound unless ownerUserID owns the key.
+	DeleteMachineKey(ctx context.Context, ownerUserID, id string) error
+	TouchMachineKey(ctx context.Context, id string, usedAt time.Time) error
+}
@@ -0,0 +1,122 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+var ErrInvalidNotificationPreference = newError(KindInvalid, "invalid_preference", "invalid notification preference")
+
+type NotificationCategory string
+
+const (
+	NotificationNewDeviceAlerts NotificationCategory = "new_device_alerts"
+	// NotificationShareInvitations governs telling a user about items
+	// shared with them. It is the recipient's setting, so it is not mapped
+	// to the sharer's audit event below.
+	NotificationShareInvitations NotificationCategory = "share_invitations"
+	// NotificationAccessRequests governs telling approvers that someone
+	// asked for access to an item; like share invitations it is the
+	// recipient's setting.
+	NotificationAccessRequests NotificationCategory = "access_requests"
+	// NotificationShareAccess governs telling owners that a recipient
+	// opened an item they asked to be alerted about. Email also needs the
+	// item's alert to ask for it.
+	NotificationShareAccess NotificationCategory = "share_access"
+)
+
+type NotificationChannel string
+
+const (
+	NotificationChannelEmail   NotificationChannel = "email"
+	NotificationChannelWebhook NotificationChannel = "webhook"
+	NotificationChannelPush    NotificationChannel = "push"
+)
+
+// NotificationCategories lists every category users can configure.
+var NotificationCategories = []NotificationCategory{
+	NotificationNewDeviceAlerts,
+	NotificationShareInvitations,
+	NotificationAccessRequests,
+	NotificationShareAccess,
+}
+
+// NotificationChannels lists every delivery channel.
+var NotificationChannels = []NotificationChannel{
+	NotificationChannelEmail,
+	NotificationChannelWebhook,
+	NotificationChannelPush,
+}
+
+type CategoryPreference struct {
+	Enabled  bool                  `json:"enabled"`
+	Channels []NotificationChannel `json:"channels"`
+}
+
+// NotificationPreferences holds a user's settings per category. Categories
+// missing from the stored row take their defaults.
+type NotificationPreferences struct {
+	UserID     string
+	Categories map[NotificationCategory]CategoryPreference
+	UpdatedAt  time.Time
+}
+
+// DefaultNotificationPreferences is what a user who never saved preferences gets.
+func DefaultNotificationPreferences(userID string) NotificationPreferences {
+	return NotificationPreferences{
+		UserID: userID,
+		Categories: map[NotificationCategory]CategoryPreference{
+			NotificationNewDeviceAlerts:  {Enabled: true, Channels: []NotificationChannel{NotificationChannelEmail, NotificationChannelWebhook, NotificationChannelPush}},
+			NotificationShareInvitations: {Enabled: true, Channels: []NotificationChannel{NotificationChannelPush}},
+			NotificationAccessRequests:   {Enabled: true, Channels: []NotificationChannel{NotificationChannelPush}},
+			NotificationShareAccess:      {Enabled: true, Channels: []NotificationChannel{NotificationChannelEmail, NotificationChannelWebhook}},
+		},
+	}
+}
+
+// Allows reports whether category notifications may go out on channel.
+func (p NotificationPreferences) Allows(category NotificationCategory, channel NotificationChannel) bool {
+	pref, ok := p.Categories[category]
+	if !ok || !pref.Enabled {
+		return false
+	}
+	for _, c := range pref.Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationPreferencePatch changes one category; nil fields are left as they are.
+type NotificationPreferencePatch struct {
+	Enabled  *bool
+	Channels []NotificationChannel
+}
+
+// eventNotificationCategories maps audit events that double as user
+// notifications to the category that governs them.
+var eventNotificationCategories = map[EventType]NotificationCategory{
+	EventTypeAuthLoginSuccess:    NotificationNewDeviceAlerts,
+	EventTypeSharingItemAccessed: NotificationShareAccess,
+}
+
+// NotificationCategoryForEvent reports which category, if any, controls
+// notifications about eventType.
+func NotificationCategoryForEvent(eventType EventType) (NotificationCategory, bool) {
+	category, ok := eventNotificationCategories[eventType]
+	return category, ok
+}
+
+// NotificationRecipient is where a user's email notifications go.
+type NotificationRecipient struct {
+	Email string
+	Name  string
+}
+
+type NotificationRepository interface {
+	// GetPreferences returns ErrNotFound when the user never saved preferences.
+	GetPreferences(ctx context.Context, userID string) (NotificationPreferences, error)
+	SavePreferences(ctx context.Context, prefs NotificationPreferences) (NotificationPreferences, error)
+	GetRecipient(ctx context.Context, userID string) (NotificationRecipient, error)
+}
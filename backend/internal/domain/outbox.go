@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const (
+	// OutboxTopicAudit carries AuditEvent payloads destined for audit_events.
+	OutboxTopicAudit = "audit"
+	// OutboxTopicEmail carries rendered mailer.Message payloads.
+	OutboxTopicEmail = "email"
+	// OutboxTopicDataExport carries DataExportRequest payloads.
+	OutboxTopicDataExport = "data_export"
+	// OutboxTopicPush carries PushMessage payloads.
+	OutboxTopicPush = "push"
+)
+
+// SensitiveOutboxTopics carry secrets such as one-time links in their
+// payload. The payload is cleared once the event is dispatched or given up
+// on, so it does not linger until the retention job prunes the row.
+var SensitiveOutboxTopics = []string{OutboxTopicEmail, OutboxTopicPush}
+
+type OutboxEvent struct {
+	ID        string
+	Topic     string
+	Payload   json.RawMessage
+	Attempts  int
+	CreatedAt time.Time
+}
+
+type OutboxRepository interface {
+	Enqueue(ctx context.Context, topic string, payload json.RawMessage) error
+	// ClaimDue leases up to limit pending events for lease; a claimed event
+	// that is never marked becomes due again once the lease runs out.
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]OutboxEvent, error)
+	MarkDispatched(ctx context.Context, id string) error
+	MarkRetry(ctx context.Context, id string, lastError string, nextAttemptAt time.Time) error
+	MarkFailed(ctx context.Context, id string, lastError string) error
+	DeleteDispatchedBefore(ctx context.Context, before time.Time) (int64, error)
+}
@@ -0,0 +1,120 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+var (
+	ErrInvalidPasskey      = newError(KindUnauthorized, "invalid_passkey", "passkey could not be verified")
+	ErrPasskeyTaken        = newError(KindConflict, "passkey_taken", "passkey is already registered")
+	ErrLastPasskey         = newError(KindConflict, "last_passkey", "an account without a password must keep at least one passkey")
+	ErrPasswordlessAccount = newError(KindInvalid, "passwordless_account", "this account has no password; enrol a new passkey instead")
+	ErrPasskeyLimit        = newError(KindConflict, "passkey_limit", "too many passkeys registered")
+)
+
+// MaxPasskeysPerUser bounds how many passkeys one account may register.
+const MaxPasskeysPerUser = 20
+
+// Passkey is a WebAuthn credential a user signs in with. An account may
+// have passkeys only, and no password at all.
+//
+// When the authenticator supports the PRF extension, the client derives a
+// key from it with PRFSalt and wraps the vault key with that; the server
+// keeps the wrapped key and hands it back after a sign-in with the passkey,
+// never seeing the PRF output.
+type Passkey struct {
+	ID           string
+	UserID       string
+	CredentialID []byte
+	// PublicKey is the credential's DER SubjectPublicKeyInfo.
+	PublicKey  []byte
+	SignCount  uint32
+	Name       string
+	PRFSalt    []byte
+	WrappedKey []byte
+	WrapNonce  []byte
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// PasskeyChallengePurpose says which ceremony a challenge was issued for.
+type PasskeyChallengePurpose string
+
+const (
+	// PasskeyChallengeRegisterAccount creates a new passwordless account.
+	PasskeyChallengeRegisterAccount PasskeyChallengePurpose = "register_account"
+	// PasskeyChallengeAddPasskey adds a passkey to a signed-in account.
+	PasskeyChallengeAddPasskey PasskeyChallengePurpose = "add_passkey"
+	// PasskeyChallengeRecover replaces the passkeys of an account being
+	// recovered with its recovery key.
+	PasskeyChallengeRecover PasskeyChallengePurpose = "recover"
+	// PasskeyChallengeLogin signs in with any discoverable passkey.
+	PasskeyChallengeLogin PasskeyChallengePurpose = "login"
+	// PasskeyChallengeSecondFactor answers the second-factor step of a
+	// password sign-in in place of a TOTP code.
+	PasskeyChallengeSecondFactor PasskeyChallengePurpose = "second_factor"
+)
+
+// PasskeyChallenge is a server-issued WebAuthn challenge, valid once until
+// ExpiresAt. UserID is the account it is for; for a registration it is the
+// ID the new account will get, and Email and Name are what it registers
+// with.
+type PasskeyChallenge struct {
+	ID        string
+	Purpose   PasskeyChallengePurpose
+	UserID    string
+	Email     string
+	Name      string
+	Challenge []byte
+	ExpiresAt time.Time
+}
+
+// PasskeyRegistration is a new credential as the client reports it, in
+// answer to the challenge ChallengeID. The PRF fields are empty when the
+// authenticator has no PRF support.
+type PasskeyRegistration struct {
+	ChallengeID       string
+	CredentialID      []byte
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	PublicKey         []byte
+	Name              string
+	PRFSalt           []byte
+	WrappedKey        []byte
+	WrapNonce         []byte
+}
+
+// PasskeyAssertion is a signed answer to the challenge ChallengeID.
+type PasskeyAssertion struct {
+	ChallengeID       string
+	CredentialID      []byte
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+}
+
+// PasskeyLoginOutput is a sign-in with a passkey. Passkey carries the PRF
+// salt and wrapped vault key the client unlocks the vault with.
+type PasskeyLoginOutput struct {
+	LoginOutput
+	Passkey Passkey
+}
+
+type PasskeyRepository interface {
+	CreatePasskeyChallenge(ctx context.Context, challenge PasskeyChallenge) error
+	// ConsumePasskeyChallenge deletes and returns an unexpired challenge
+	// issued for purpose, or returns ErrNotFound.
+	ConsumePasskeyChallenge(ctx context.Context, id string, purpose PasskeyChallengePurpose, now time.Time) (PasskeyChallenge, error)
+	DeleteExpiredPasskeyChallenges(ctx context.Context, before time.Time) (int64, error)
+
+	// CreatePasskey returns ErrPasskeyTaken when the credential ID is
+	// already registered.
+	CreatePasskey(ctx context.Context, passkey Passkey) (Passkey, error)
+	GetPasskeyByCredentialID(ctx context.Context, credentialID []byte) (Passkey, error)
+	ListPasskeys(ctx context.Context, userID string) ([]Passkey, error)
+	// TouchPasskey records a sign-in with the passkey.
+	TouchPasskey(ctx context.Context, id string, signCount uint32, usedAt time.Time) error
+	DeletePasskey(ctx context.Context, userID, id string) error
+	DeleteAllPasskeys(ctx context.Context, userID string) error
+}
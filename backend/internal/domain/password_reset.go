@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+var ErrPasswordResetInvalid = newError(KindUnauthorized, "password_reset_invalid", "the password reset link is invalid, used or expired")
+
+// PasswordResetToken lets the owner of an email address set a new master
+// password for its account without signing in. The token itself is emailed
+// and never stored; only its peppered hash is. A token is good once, until
+// ExpiresAt, and using one uses up every other token of the account too.
+type PasswordResetToken struct {
+	ID        string
+	UserID    string
+	TokenHash []byte
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// PasswordResetInput sets a new master password with an emailed token. As
+// with CredentialResetInput, the client picks the new salt and sends every
+// item key re-wrapped under the key derived from it, which only a client
+// that still holds the vault key can do. Without one the old password's
+// vault key is gone with the password, and the account is recovered with
+// its recovery key instead.
+type PasswordResetInput struct {
+	Token       string
+	NewPassword string
+	NewSalt     []byte
+	Items       []VaultItemKey
+}
+
+type PasswordResetRepository interface {
+	CreatePasswordReset(ctx context.Context, token PasswordResetToken) error
+	// GetPasswordReset returns the unused, unexpired token with tokenHash,
+	// or ErrNotFound.
+	GetPasswordReset(ctx context.Context, tokenHash []byte, now time.Time) (PasswordResetToken, error)
+	// CountPasswordResets counts the tokens issued to userID since since,
+	// used or not.
+	CountPasswordResets(ctx context.Context, userID string, since time.Time) (int, error)
+	// UsePasswordReset marks the token id used, and with it every other
+	// unused token of userID, or returns ErrNotFound when it already is, so
+	// that of two confirmations racing for a token only one wins.
+	UsePasswordReset(ctx context.Context, id, userID string, now time.Time) error
+	DeleteExpiredPasswordResets(ctx context.Context, before time.Time) (int64, error)
+}
@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// MaxPushTokenLength bounds the token a device may register. FCM tokens run
+// to a few hundred characters, APNs tokens to 64 hex digits.
+const MaxPushTokenLength = 512
+
+var ErrInvalidPushToken = newError(KindInvalid, "invalid_push_token", "invalid push token")
+
+// PushPlatform is the service that delivers to a device.
+type PushPlatform string
+
+const (
+	PushPlatformFCM  PushPlatform = "fcm"
+	PushPlatformAPNs PushPlatform = "apns"
+)
+
+// PushPlatforms lists every platform a token may be registered for.
+var PushPlatforms = []PushPlatform{PushPlatformFCM, PushPlatformAPNs}
+
+// PushKind says what a push notification is about; apps use it to decide
+// what to open when it is tapped.
+type PushKind string
+
+const (
+	// PushKindMFAPrompt asks the user to confirm a sign-in that was
+	// flagged for step-up with their authenticator code.
+	PushKindMFAPrompt       PushKind = "mfa_prompt"
+	PushKindNewDevice       PushKind = "new_device"
+	PushKindShareInvitation PushKind = "share_invitation"
+	PushKindAccessRequest   PushKind = "access_request"
+	// PushKindShareInvitationReady tells a sharer that an invitee has
+	// registered and the invitation can be completed.
+	PushKindShareInvitationReady PushKind = "share_invitation_ready"
+)
+
+// PushToken is a mobile app's registration with its platform's push
+// service. Apps register their token again whenever they start, which
+// refreshes UpdatedAt; tokens left unrefreshed long enough are dropped, as
+// are tokens the push service reports as no longer valid.
+type PushToken struct {
+	ID     string
+	UserID string
+	// DeviceID is the device of the session that registered the token,
+	// when device tracking knew it.
+	DeviceID  string
+	Platform  PushPlatform
+	Token     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// PushMessage is the OutboxTopicPush payload: one notification for one
+// token.
+type PushMessage struct {
+	Platform PushPlatform      `json:"platform"`
+	Token    string            `json:"token"`
+	Kind     PushKind          `json:"kind"`
+	Title    string            `json:"title"`
+	Body     string            `json:"body"`
+	Data     map[string]string `json:"data,omitempty"`
+}
+
+type PushTokenRepository interface {
+	// UpsertPushToken registers t.Token for t.UserID, taking it over from
+	// whichever account registered it before, and returns the stored token.
+	UpsertPushToken(ctx context.Context, t PushToken) (PushToken, error)
+	ListPushTokens(ctx context.Context, userID string) ([]PushToken, error)
+	// DeletePushToken returns ErrNotFound unless userID owns the token.
+	DeletePushToken(ctx context.Context, userID, id string) error
+	// DeletePushTokenByValue forgets a token the push service rejected.
+	DeletePushTokenByValue(ctx context.Context, platform PushPlatform, token string) error
+	// DeleteStalePushTokens forgets tokens not registered since before.
+	DeleteStalePushTokens(ctx context.Context, before time.Time) (int64, error)
+}
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+var (
+	ErrQRLoginNotFound = newError(KindNotFound, "qr_login_not_found", "sign-in request not found or expired")
+	ErrQRLoginDenied   = newError(KindForbidden, "qr_login_denied", "sign-in request was denied")
+)
+
+// QRLoginStatus is where a QR code sign-in request stands.
+type QRLoginStatus string
+
+const (
+	QRLoginPending  QRLoginStatus = "pending"
+	QRLoginApproved QRLoginStatus = "approved"
+	QRLoginDenied   QRLoginStatus = "denied"
+)
+
+// QRLoginRequest is a device asking to be signed in by another device that
+// already is. The new device shows its ID and code as a QR code; a
+// signed-in device scans it and approves it for its own account, and the new
+// device, polling with its poll token, then gets a session. Only peppered
+// hashes of the code and the poll token are stored. The requesting device's
+// details are kept so the approver can see what it is letting in.
+type QRLoginRequest struct {
+	ID            string
+	CodeHash      []byte
+	PollTokenHash []byte
+	Status        QRLoginStatus
+	UserID        string
+	DeviceName    string
+	IPAddr        string
+	UserAgent     string
+	ExpiresAt     time.Time
+	CreatedAt     time.Time
+}
+
+// QRLoginStart is a new request as its device sees it. Code and PollToken
+// are returned once and never stored.
+type QRLoginStart struct {
+	QRLoginRequest
+	Code      string
+	PollToken string
+}
+
+// QRLoginPoll is the answer to a poll: Status, and the session once the
+// request is approved.
+type QRLoginPoll struct {
+	Status QRLoginStatus
+	Login  LoginOutput
+}
+
+type QRLoginRepository interface {
+	CreateQRLogin(ctx context.Context, req QRLoginRequest) error
+	// GetQRLogin returns an unexpired request, or ErrNotFound.
+	GetQRLogin(ctx context.Context, id string, now time.Time) (QRLoginRequest, error)
+	// DecideQRLogin approves or denies a pending, unexpired request on
+	// behalf of userID, or returns ErrNotFound.
+	DecideQRLogin(ctx context.Context, id, userID string, status QRLoginStatus, now time.Time) error
+	// DeleteQRLogin returns ErrNotFound when the request is already gone, so
+	// that of two polls racing for an approved request only one wins.
+	DeleteQRLogin(ctx context.Context, id string) error
+	DeleteExpiredQRLogins(ctx context.Context, before time.Time) (int64, error)
+}
@@ -0,0 +1,40 @@
+package domain
+
+import "time"
+
+var (
+	ErrShareInvitationNotFound = newError(KindNotFound, "share_invitation_not_found", "share invitation not found")
+	ErrShareInvitationExists   = newError(KindConflict, "share_invitation_exists", "this item already has a pending invitation for that email")
+	ErrShareInvitationNotReady = newError(KindConflict, "share_invitation_not_ready", "the invited user has not registered and set up encryption keys yet")
+	ErrRecipientRegistered     = newError(KindConflict, "recipient_registered", "that email already has an account; share with it directly")
+	ErrInvalidInvitationEmail  = newError(KindInvalid, "invalid_email", "email address is invalid")
+)
+
+type ShareInvitationStatus string
+
+const (
+	// ShareInvitationPending waits for the invitee to register and upload
+	// a public key.
+	ShareInvitationPending ShareInvitationStatus = "pending"
+	// ShareInvitationReady waits for the sharer's client to wrap the item
+	// key for RecipientUserID and complete the share.
+	ShareInvitationReady ShareInvitationStatus = "ready"
+)
+
+// ShareInvitation parks a share of an item with an email that has no
+// account yet. The server cannot wrap the item key for a user without a
+// public key, so the share is only created when the sharer completes the
+// invitation after the invitee registers. Completed invitations are
+// deleted; unanswered ones lapse at ExpiresAt.
+type ShareInvitation struct {
+	ID              string
+	ItemID          string
+	SharedByUserID  string
+	Email           string
+	Permissions     string
+	Status          ShareInvitationStatus
+	RecipientUserID string
+	ReadyAt         *time.Time
+	ExpiresAt       time.Time
+	CreatedAt       time.Time
+}
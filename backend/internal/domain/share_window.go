@@ -0,0 +1,69 @@
+package domain
+
+import "time"
+
+var ErrInvalidShareWindow = newError(KindInvalid, "invalid_share_window", "share validity window or schedule is invalid")
+
+// ShareWindow limits when a share grants access to its recipient. The zero
+// value grants it at all times.
+type ShareWindow struct {
+	NotBefore *time.Time
+	ExpiresAt *time.Time
+	Schedule  *ShareSchedule
+}
+
+// ShareSchedule grants access on Days between StartMinute and EndMinute
+// (minutes after midnight) in Timezone, e.g. weekdays 9:00-18:00 in
+// Europe/Berlin. An EndMinute before StartMinute spans midnight into the
+// next day.
+type ShareSchedule struct {
+	Days        []time.Weekday `json:"days"`
+	StartMinute int            `json:"start_minute"`
+	EndMinute   int            `json:"end_minute"`
+	Timezone    string         `json:"timezone"`
+}
+
+// Allows reports whether the window grants access at t.
+func (w ShareWindow) Allows(t time.Time) bool {
+	if w.NotBefore != nil && t.Before(*w.NotBefore) {
+		return false
+	}
+	if w.Expired(t) {
+		return false
+	}
+	return w.Schedule == nil || w.Schedule.Allows(t)
+}
+
+// Expired reports whether the window has ended for good by t.
+func (w ShareWindow) Expired(t time.Time) bool {
+	return w.ExpiresAt != nil && !t.Before(*w.ExpiresAt)
+}
+
+// Allows reports whether t falls in one of the scheduled periods. A
+// schedule whose timezone no longer loads grants nothing.
+func (s ShareSchedule) Allows(t time.Time) bool {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return false
+	}
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if s.StartMinute < s.EndMinute {
+		return s.hasDay(local.Weekday()) && minute >= s.StartMinute && minute < s.EndMinute
+	}
+	// Overnight: from the start on a scheduled day, or until the end on
+	// the day after one.
+	if minute >= s.StartMinute {
+		return s.hasDay(local.Weekday())
+	}
+	return minute < s.EndMinute && s.hasDay((local.Weekday()+6)%7)
+}
+
+func (s ShareSchedule) hasDay(day time.Weekday) bool {
+	for _, d := range s.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
@@ -2,16 +2,15 @@ package domain
 
 import (
 	"context"
-	"errors"
 	"time"
 )
 
 var (
-	ErrAlreadyShared          = errors.New("item already shared with this user")
-	ErrCannotShareWithSelf    = errors.New("cannot share an item with yourself")
-	ErrRecipientKeysNotFound  = errors.New("recipient has not set up encryption keys")
-	ErrShareNotFound          = errors.New("share not found")
-	ErrNotItemOwner           = errors.New("only the item owner can perform this action")
+	ErrAlreadyShared          = newError(KindConflict, "already_shared", "item is already shared with this user")
+	ErrCannotShareWithSelf    = newError(KindInvalid, "cannot_share_self", "you cannot share an item with yourself")
+	ErrRecipientKeysNotFound  = newError(KindNotFound, "recipient_keys_not_found", "recipient has not set up encryption keys")
+	ErrShareNotFound          = newError(KindNotFound, "share_not_found", "share not found")
+	ErrNotItemOwner           = newError(KindForbidden, "not_owner", "only the item owner can perform this action")
 )
 
 // UserKeys holds asymmetric key material for a user.
@@ -25,9 +24,21 @@ type UserKeys struct {
 	UpdatedAt            time.Time
 }
 
+// KeyFingerprint is what a client shows so two people can check out of band
+// that the server handed each of them the other's real public key.
+// SafetyNumber covers the viewer's key and UserID's; it is empty when the
+// viewer is UserID or has no keys yet.
+type KeyFingerprint struct {
+	UserID       string
+	Fingerprint  string
+	SafetyNumber string
+	UpdatedAt    time.Time
+}
+
 type UpsertUserKeysInput struct {
 	UserID               string
 	PublicKeyX25519      []byte
+	PublicKeyEd25519     []byte // optional; replaced along with the rest
 	EncryptedPrivateKeys []byte
 	Nonce                []byte
 }
@@ -40,8 +51,12 @@ type VaultShare struct {
 	DEKWrapped     []byte
 	WrapNonce      []byte
 	Permissions    string
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
+	Window         ShareWindow
+	// FirstAccessedAt is when the recipient was first sent the item, nil
+	// until then.
+	FirstAccessedAt *time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
 }
 
 type ShareItemInput struct {
@@ -51,6 +66,7 @@ type ShareItemInput struct {
 	DEKWrapped     []byte
 	WrapNonce      []byte
 	Permissions    string
+	Window         ShareWindow
 }
 
 // SharedVaultItem is a vault item plus its share metadata.
@@ -62,6 +78,9 @@ type SharedVaultItem struct {
 	Permissions    string
 	ShareDEK       []byte
 	ShareWrapNonce []byte
+	ShareWindow    ShareWindow
+	// FirstAccessedAt is nil until the recipient is first sent the item.
+	FirstAccessedAt *time.Time
 }
 
 // SentShare represents an item shared BY the user to others.
@@ -84,9 +103,51 @@ type UserKeysRepository interface {
 type SharingRepository interface {
 	CreateShare(ctx context.Context, input ShareItemInput) error
 	DeleteShare(ctx context.Context, itemID string, recipientUserID string) error
+	// UpdateSharePermissions returns ErrShareNotFound if the recipient has
+	// no share of the item.
+	UpdateSharePermissions(ctx context.Context, itemID string, recipientUserID string, permissions string) error
 	ListSharesByRecipient(ctx context.Context, userID string) ([]SharedVaultItem, error)
 	ListSharesByItem(ctx context.Context, itemID string) ([]VaultShare, error)
 	ListSentShares(ctx context.Context, userID string) ([]SentShare, error)
 	GetShare(ctx context.Context, itemID string, userID string) (VaultShare, error)
 	DeleteAllSharesBetweenUsers(ctx context.Context, user1ID, user2ID string) error
+	// ListExpiredShares returns up to limit shares whose window ended at
+	// or before now.
+	ListExpiredShares(ctx context.Context, now time.Time, limit int) ([]VaultShare, error)
+
+	// GetItemOwner returns the owner of a live vault item, or ErrNotFound.
+	GetItemOwner(ctx context.Context, itemID string) (string, error)
+	CreateAccessRequest(ctx context.Context, req AccessRequest) (AccessRequest, error)
+	// GetAccessRequest returns ErrAccessRequestNotFound for unknown ids.
+	GetAccessRequest(ctx context.Context, id string) (AccessRequest, error)
+	ListAccessRequestsByRequester(ctx context.Context, requesterUserID string) ([]AccessRequest, error)
+	// ListPendingAccessRequestsForApprover returns the pending requests for
+	// items approverUserID owns or holds a manage share of, oldest first.
+	ListPendingAccessRequestsForApprover(ctx context.Context, approverUserID string) ([]AccessRequest, error)
+	// DecideAccessRequest moves a pending request to status. It returns
+	// ErrAccessRequestDecided if the request is no longer pending.
+	DecideAccessRequest(ctx context.Context, id string, status AccessRequestStatus, decidedByUserID string, decidedAt time.Time) error
+
+	// CreateShareInvitation returns ErrShareInvitationExists if the item
+	// already has an invitation for the email.
+	CreateShareInvitation(ctx context.Context, inv ShareInvitation) (ShareInvitation, error)
+	// GetShareInvitation returns ErrShareInvitationNotFound for unknown ids.
+	GetShareInvitation(ctx context.Context, id string) (ShareInvitation, error)
+	ListShareInvitationsBySharer(ctx context.Context, sharedByUserID string) ([]ShareInvitation, error)
+	// ListPendingShareInvitationsByEmail returns the pending invitations
+	// for email that have not expired by now.
+	ListPendingShareInvitationsByEmail(ctx context.Context, email string, now time.Time) ([]ShareInvitation, error)
+	// MarkShareInvitationReady returns ErrShareInvitationNotFound unless the
+	// invitation is pending.
+	MarkShareInvitationReady(ctx context.Context, id, recipientUserID string, readyAt time.Time) error
+	// DeleteShareInvitation returns ErrShareInvitationNotFound unless
+	// sharedByUserID made the invitation.
+	DeleteShareInvitation(ctx context.Context, id, sharedByUserID string) error
+	DeleteExpiredShareInvitations(ctx context.Context, now time.Time) (int64, error)
+	// MarkShareAccessed records the recipient's first access to a share
+	// and reports whether this was it.
+	MarkShareAccessed(ctx context.Context, itemID, userID string, at time.Time) (bool, error)
+	GetItemAccessAlert(ctx context.Context, itemID string) (ItemAccessAlert, error)
+	SetItemAccessAlert(ctx context.Context, alert ItemAccessAlert) error
+	DeleteItemAccessAlert(ctx context.Context, itemID string) error
 }
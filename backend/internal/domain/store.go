@@ -0,0 +1,30 @@
+package domain
+
+// Store hands out one implementation of every repository, all backed by the
+// same storage so that a Transactor spans them.
+type Store interface {
+	Auth() AuthRepository
+	Vault() VaultRepository
+	Folders() FolderRepository
+	UserKeys() UserKeysRepository
+	Sharing() SharingRepository
+	Family() FamilyRepository
+	Audit() AuditRepository
+	Jobs() JobRepository
+	Outbox() OutboxRepository
+	Webhooks() WebhookRepository
+	Notifications() NotificationRepository
+	Admin() AdminRepository
+	DataExports() DataExportRepository
+	IPBans() IPBanRepository
+	Devices() DeviceRepository
+	Passkeys() PasskeyRepository
+	QRLogins() QRLoginRepository
+	Autofill() AutofillRepository
+	PushTokens() PushTokenRepository
+	MachineSecrets() MachineSecretRepository
+	Attachments() AttachmentRepository
+	PasswordResets() PasswordResetRepository
+	Backups() BackupRegistryRepository
+	Transactor() Transactor
+}
@@ -0,0 +1,10 @@
+package domain
+
+import "context"
+
+// Transactor runs fn inside a database transaction. Repositories called with
+// the ctx passed to fn join that transaction, so their writes commit or roll
+// back together.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -5,6 +5,110 @@ import (
 	"time"
 )
 
+var (
+	// ErrSyncRevisionChanged means a resumed full sync can no longer pick up
+	// where it stopped; the client must start again from offset 0.
+	ErrSyncRevisionChanged = newError(KindConflict, "sync_revision_changed", "vault changed since the sync started")
+	ErrInvalidSyncOffset   = newError(KindInvalid, "invalid_sync_offset", "sync offset is invalid")
+	// ErrSyncCursorExpired means a delta sync's cursor is older than the
+	// tombstones of purged items go back; the client must sync in full.
+	ErrSyncCursorExpired   = newError(KindConflict, "sync_cursor_expired", "sync cursor is too old; sync the whole vault")
+	ErrInvalidSyncCursor   = newError(KindInvalid, "invalid_sync_cursor", "since must be a cursor or an RFC 3339 timestamp")
+	ErrInvalidItemQuery    = newError(KindInvalid, "invalid_item_query", "item listing parameters are invalid")
+	ErrInvalidExportFormat = newError(KindInvalid, "invalid_export_format", "export format must be json or csv")
+	ErrInvalidImportSource = newError(KindInvalid, "invalid_import_source", "import source is not a supported export format")
+	// Errors of single rows of an import; see VaultImportResult.
+	ErrUnsupportedImportKind = newError(KindInvalid, "unsupported_import_kind", "imported items must be logins, cards or notes")
+	ErrInvalidImportRow      = newError(KindInvalid, "invalid_import_row", "row numbers must be positive and unique")
+)
+
+// Vault export formats. A JSON export holds the items, folders and
+// attachment metadata; a CSV export holds the items only, one per row.
+const (
+	VaultExportJSON = "json"
+	VaultExportCSV  = "csv"
+)
+
+// VaultExportVersion is the version of the export document layout.
+const VaultExportVersion = 1
+
+// VaultExport is a user's whole active vault, as served by the export
+// endpoint. Everything in it stays encrypted under the user's keys;
+// attachments are listed but their blobs are not included.
+type VaultExport struct {
+	ID          string
+	Format      string
+	CreatedAt   time.Time
+	Items       []VaultItem
+	Folders     []VaultFolder
+	Attachments []VaultAttachment
+}
+
+// VaultImportRow is one item of an import, encrypted by the client, with
+// the row of the source export it was read from.
+type VaultImportRow struct {
+	Row  int
+	Item CreateVaultItemInput
+}
+
+// VaultImportRowError is why one row of an import was not imported.
+type VaultImportRowError struct {
+	Row int
+	Err *Error
+}
+
+// ImportedVaultItem is an item created by an import and the row it came from.
+type ImportedVaultItem struct {
+	Row  int
+	Item VaultItem
+}
+
+// VaultImportResult is the outcome of an import. The rows that pass
+// validation are imported together; the others are listed in Errors and
+// can be fixed and sent again.
+type VaultImportResult struct {
+	Imported []ImportedVaultItem
+	Errors   []VaultImportRowError
+}
+
+// VaultItemSort is the timestamp a page of items is ordered by.
+type VaultItemSort string
+
+const (
+	VaultItemSortUpdated VaultItemSort = "updated_at"
+	VaultItemSortCreated VaultItemSort = "created_at"
+)
+
+// VaultItemQuery selects a page of a user's active items. FolderID and
+// Kind, the "kind" field of the item's metadata, narrow the items when not
+// empty. Items that sort equal are ordered by ID, so pages do not overlap
+// while the vault is unchanged.
+type VaultItemQuery struct {
+	FolderID  string
+	Kind      string
+	Sort      VaultItemSort
+	Ascending bool
+	Limit     int
+	Offset    int
+}
+
+// VaultTombstoneRetention is how long the tombstone of an item purged from
+// the trash is kept, and so how old a delta sync's cursor may be.
+const VaultTombstoneRetention = 90 * 24 * time.Hour
+
+// VaultSnapshot is the whole active vault of one user, as served to syncing
+// clients, together with the revision it corresponds to.
+type VaultSnapshot struct {
+	Revision string
+	Items    []VaultItem
+	Folders  []VaultFolder
+	// Cursor is where the next delta sync picks up.
+	Cursor time.Time
+	// DeletedItemIDs is only set on a delta: the items that left the
+	// active vault, to the trash or for good, since its cursor.
+	DeletedItemIDs []string
+}
+
 type VaultItem struct {
 	ID          string
 	OwnerUserID string
@@ -46,6 +150,8 @@ type CreateVaultItemInput struct {
 	WrapNonce   []byte
 	AlgoVersion string
 	Metadata    []byte
+	// URIHMACs, when not nil, replace the URI HMACs the item autofills on.
+	URIHMACs [][]byte
 }
 
 type UpdateVaultItemInput struct {
@@ -56,6 +162,8 @@ type UpdateVaultItemInput struct {
 	WrapNonce   []byte
 	AlgoVersion string
 	Metadata    []byte
+	// URIHMACs, when not nil, replace the URI HMACs the item autofills on.
+	URIHMACs [][]byte
 }
 
 type VaultFolder struct {
@@ -77,6 +185,9 @@ type VaultRepository interface {
 	CreateVaultItem(ctx context.Context, input CreateVaultItemInput) (VaultItem, error)
 	CreateVaultItemsBulk(ctx context.Context, inputs []CreateVaultItemInput) ([]VaultItem, error)
 	ListVaultItemsByOwner(ctx context.Context, ownerUserID string) ([]VaultItem, error)
+	// ListVaultItemPage returns one page of the owner's active items and
+	// how many match the query in all.
+	ListVaultItemPage(ctx context.Context, ownerUserID string, query VaultItemQuery) ([]VaultItem, int, error)
 	ListDeletedVaultItemsByOwner(ctx context.Context, ownerUserID string) ([]VaultItem, error)
 	GetVaultItemByIDForOwner(ctx context.Context, itemID string, ownerUserID string) (VaultItem, error)
 	ListVaultItemVersionsByOwner(ctx context.Context, itemID string, ownerUserID string) ([]VaultItemVersion, error)
@@ -84,6 +195,18 @@ type VaultRepository interface {
 	DeleteVaultItemForOwner(ctx context.Context, itemID string, ownerUserID string) (bool, error)
 	RestoreVaultItemForOwner(ctx context.Context, itemID string, ownerUserID string) (VaultItem, error)
 	GetVaultSaltForUser(ctx context.Context, userID string) ([]byte, error)
+	GetVaultRevision(ctx context.Context, ownerUserID string) (string, error)
+	// RewrapVaultItemKeys replaces the wrapped data keys of the owner's
+	// items, trashed ones included, and leaves their content and history
+	// alone. It fails with ErrRewrapIncomplete unless keys covers every
+	// such item exactly once.
+	RewrapVaultItemKeys(ctx context.Context, ownerUserID string, keys []VaultItemKey) error
+	// PurgeDeletedVaultItems leaves a tombstone of every item it removes.
+	PurgeDeletedVaultItems(ctx context.Context, deletedBefore time.Time) (int64, error)
+	// ListVaultItemChangesByOwner returns the owner's active items updated
+	// after since, and the IDs of the items trashed or purged after it.
+	ListVaultItemChangesByOwner(ctx context.Context, ownerUserID string, since time.Time) ([]VaultItem, []string, error)
+	DeleteVaultItemTombstones(ctx context.Context, before time.Time) (int64, error)
 }
 
 type FolderRepository interface {
@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+var (
+	ErrInvalidWebhookURL     = newError(KindInvalid, "invalid_url", "url must be an absolute https URL")
+	ErrInvalidWebhookEvent   = newError(KindInvalid, "invalid_event_type", "event_types contains an invalid event type")
+	ErrWebhookLimitReached   = newError(KindConflict, "webhook_limit_reached", "webhook limit reached")
+	ErrWebhookDeliveryActive = newError(KindConflict, "delivery_pending", "delivery is already queued")
+)
+
+// Webhook delivery states. A delivery that keeps failing ends up dead (the
+// dead-letter state) and is only retried again on explicit redelivery.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryDead      = "dead"
+)
+
+// WebhookEndpoint is a URL a user registered to receive their events.
+// EventTypes empty means every event.
+type WebhookEndpoint struct {
+	ID     string
+	UserID string
+	URL    string
+	// Secret is the plaintext signing secret. It is only set on a newly
+	// created endpoint; storage keeps SecretEnc, encrypted with the key
+	// from util.DeriveWebhookSecretKey.
+	Secret     string
+	SecretEnc  []byte
+	EventTypes []EventType
+	Enabled    bool
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// WebhookDelivery is one event queued for one endpoint.
+type WebhookDelivery struct {
+	ID            string
+	EndpointID    string
+	EventID       string
+	EventType     EventType
+	Payload       json.RawMessage
+	Status        string
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	DeliveredAt   *time.Time
+	CreatedAt     time.Time
+	AttemptLog    []WebhookDeliveryAttempt
+
+	// Endpoint is only populated by ClaimDueDeliveries.
+	Endpoint WebhookEndpoint
+}
+
+// WebhookDeliveryAttempt records a single HTTP attempt.
+type WebhookDeliveryAttempt struct {
+	StatusCode  int // 0 when no response was received
+	Error       string
+	DurationMS  int
+	AttemptedAt time.Time
+}
+
+type WebhookRepository interface {
+	CreateEndpoint(ctx context.Context, endpoint WebhookEndpoint) (WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context, userID string) ([]WebhookEndpoint, error)
+	GetEndpoint(ctx context.Context, userID, endpointID string) (WebhookEndpoint, error)
+	CountEndpoints(ctx context.Context, userID string) (int, error)
+	DeleteEndpoint(ctx context.Context, userID, endpointID string) error
+	// ListEndpointsForEvent returns enabled endpoints of userID subscribed to eventType.
+	ListEndpointsForEvent(ctx context.Context, userID string, eventType EventType) ([]WebhookEndpoint, error)
+
+	// EnqueueDelivery is idempotent per (endpoint, event).
+	EnqueueDelivery(ctx context.Context, delivery WebhookDelivery) error
+	// ClaimDueDeliveries leases up to limit pending deliveries of enabled
+	// endpoints and increments their attempt counters.
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, deliveryID string, attempt WebhookDeliveryAttempt) error
+	MarkDelivered(ctx context.Context, deliveryID string) error
+	MarkRetry(ctx context.Context, deliveryID string, lastError string, nextAttemptAt time.Time) error
+	MarkDead(ctx context.Context, deliveryID string, lastError string) error
+	ListDeliveries(ctx context.Context, endpointID string, limit, offset int) ([]WebhookDelivery, int, error)
+	// Redeliver moves a dead or delivered delivery back to pending.
+	Redeliver(ctx context.Context, endpointID, deliveryID string) error
+	// DeleteDeliveriesBefore prunes finished (delivered or dead) deliveries.
+	DeleteDeliveriesBefore(ctx context.Context, before time.Time) (int64, error)
+}
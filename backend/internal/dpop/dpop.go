@@ -0,0 +1,277 @@
+// Package dpop verifies DPoP proofs (RFC 9449): short-lived JWTs a client
+// signs with a key it keeps to itself, one per request, naming the request's
+// method and URL and the session token sent with it. A session bound to the
+// key is useless to whoever copies its token, since they cannot sign the
+// proofs. ES256 and EdDSA (Ed25519) keys are supported.
+package dpop
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidProof is wrapped by every error reporting a proof that does not
+// check out.
+var ErrInvalidProof = errors.New("invalid dpop proof")
+
+// Header is the request header a proof travels in.
+const Header = "DPoP"
+
+// MaxAge is how long after it was issued a proof is accepted. Proofs
+// claiming to be from the future are accepted within the same margin, for
+// clients with a fast clock.
+const MaxAge = time.Minute
+
+// Proof is a verified proof.
+type Proof struct {
+	// ID is the proof's jti. A proof is good for one request; the caller
+	// remembers IDs for MaxAge on either side of IssuedAt to refuse replays.
+	ID       string
+	IssuedAt time.Time
+	// Thumbprint identifies the key that signed the proof (RFC 7638).
+	Thumbprint string
+}
+
+// Request is what a proof must have been made for.
+type Request struct {
+	Method string
+	// Path is the request's path. Only the path of the proof's URL is
+	// compared: behind a proxy the scheme and host the client used are not
+	// reliably known.
+	Path  string
+	Token string
+}
+
+type header struct {
+	Typ string `json:"typ"`
+	Alg string `json:"alg"`
+	JWK jwk    `json:"jwk"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y,omitempty"`
+	// A private key must never be sent; its presence fails the proof.
+	D string `json:"d,omitempty"`
+}
+
+type claims struct {
+	JTI string `json:"jti"`
+	HTM string `json:"htm"`
+	HTU string `json:"htu"`
+	IAT int64  `json:"iat"`
+	ATH string `json:"ath"`
+}
+
+// Verify checks that compact is a well-formed proof signed by the key in
+// its header and made for req no more than MaxAge from now.
+func Verify(compact string, req Request, now time.Time) (Proof, error) {
+	parts := strings.Split(compact, ".")
+	if len(parts) != 3 {
+		return Proof{}, fmt.Errorf("%w: not a compact jwt", ErrInvalidProof)
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return Proof{}, fmt.Errorf("%w: header: %v", ErrInvalidProof, err)
+	}
+	if h.Typ != "dpop+jwt" {
+		return Proof{}, fmt.Errorf("%w: typ %q", ErrInvalidProof, h.Typ)
+	}
+	if h.JWK.D != "" {
+		return Proof{}, fmt.Errorf("%w: jwk holds a private key", ErrInvalidProof)
+	}
+	key, err := h.JWK.publicKey(h.Alg)
+	if err != nil {
+		return Proof{}, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Proof{}, fmt.Errorf("%w: signature encoding", ErrInvalidProof)
+	}
+	if !verifySignature(key, []byte(parts[0]+"."+parts[1]), signature) {
+		return Proof{}, fmt.Errorf("%w: bad signature", ErrInvalidProof)
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return Proof{}, fmt.Errorf("%w: claims: %v", ErrInvalidProof, err)
+	}
+	if c.JTI == "" {
+		return Proof{}, fmt.Errorf("%w: missing jti", ErrInvalidProof)
+	}
+	if !strings.EqualFold(c.HTM, req.Method) {
+		return Proof{}, fmt.Errorf("%w: made for %s, not %s", ErrInvalidProof, c.HTM, req.Method)
+	}
+	htu, err := url.Parse(c.HTU)
+	if err != nil || htu.Path != req.Path {
+		return Proof{}, fmt.Errorf("%w: made for another url", ErrInvalidProof)
+	}
+	if c.ATH != TokenHash(req.Token) {
+		return Proof{}, fmt.Errorf("%w: made for another token", ErrInvalidProof)
+	}
+	issuedAt := time.Unix(c.IAT, 0)
+	if issuedAt.Before(now.Add(-MaxAge)) || issuedAt.After(now.Add(MaxAge)) {
+		return Proof{}, fmt.Errorf("%w: issued at %s", ErrInvalidProof, issuedAt.UTC().Format(time.RFC3339))
+	}
+
+	return Proof{ID: c.JTI, IssuedAt: issuedAt, Thumbprint: h.JWK.thumbprint()}, nil
+}
+
+// Sign makes a proof for a request to rawURL with method, carrying token,
+// signed by key (a P-256 *ecdsa.PrivateKey or an ed25519.PrivateKey). The
+// server only verifies proofs; Go clients and tests make them.
+func Sign(key crypto.Signer, method, rawURL, token string, now time.Time) (string, error) {
+	var h header
+	switch k := key.Public().(type) {
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return "", fmt.Errorf("unsupported curve %s", k.Curve.Params().Name)
+		}
+		h.Alg = "ES256"
+		h.JWK = jwk{Kty: "EC", Crv: "P-256", X: encodeCoordinate(k.X), Y: encodeCoordinate(k.Y)}
+	case ed25519.PublicKey:
+		h.Alg = "EdDSA"
+		h.JWK = jwk{Kty: "OKP", Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(k)}
+	default:
+		return "", fmt.Errorf("unsupported key %T", k)
+	}
+	h.Typ = "dpop+jwt"
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", fmt.Errorf("generate jti: %w", err)
+	}
+	headerJSON, err := json.Marshal(h)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims{
+		JTI: base64.RawURLEncoding.EncodeToString(jti),
+		HTM: method,
+		HTU: rawURL,
+		IAT: now.Unix(),
+		ATH: TokenHash(token),
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	var signature []byte
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signingInput))
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			return "", fmt.Errorf("sign proof: %w", err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, []byte(signingInput))
+	default:
+		return "", fmt.Errorf("unsupported key %T", key)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Thumbprint returns the RFC 7638 thumbprint of a key Sign accepts, which
+// is what Proof.Thumbprint reports for its proofs.
+func Thumbprint(key crypto.PublicKey) (string, error) {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		return jwk{Kty: "EC", Crv: "P-256", X: encodeCoordinate(k.X), Y: encodeCoordinate(k.Y)}.thumbprint(), nil
+	case ed25519.PublicKey:
+		return jwk{Kty: "OKP", Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(k)}.thumbprint(), nil
+	}
+	return "", fmt.Errorf("unsupported key %T", key)
+}
+
+func encodeCoordinate(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.FillBytes(make([]byte, 32)))
+}
+
+// TokenHash is the ath claim a proof carries for token.
+func TokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func decodeSegment(segment string, v any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func (k jwk) publicKey(alg string) (any, error) {
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("%w: jwk x", ErrInvalidProof)
+	}
+	switch {
+	case alg == "ES256" && k.Kty == "EC" && k.Crv == "P-256":
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil || len(x) != 32 || len(y) != 32 {
+			return nil, fmt.Errorf("%w: jwk coordinates", ErrInvalidProof)
+		}
+		// ecdh rejects points that are not on the curve.
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, fmt.Errorf("%w: jwk point: %v", ErrInvalidProof, err)
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case alg == "EdDSA" && k.Kty == "OKP" && k.Crv == "Ed25519":
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: jwk x", ErrInvalidProof)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("%w: unsupported key %s/%s with alg %q", ErrInvalidProof, k.Kty, k.Crv, alg)
+}
+
+// thumbprint hashes the key's required members in lexicographic order, as
+// RFC 7638 specifies.
+func (k jwk) thumbprint() string {
+	var canonical string
+	if k.Kty == "EC" {
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, k.Crv, k.Kty, k.X, k.Y)
+	} else {
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q}`, k.Crv, k.Kty, k.X)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func verifySignature(key any, message, signature []byte) bool {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		// JWS signatures are r||s, not ASN.1.
+		if len(signature) != 64 {
+			return false
+		}
+		digest := sha256.Sum256(message)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(k, digest[:], r, s)
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, message, signature)
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package dpop_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/dpop"
+)
+
+func TestVerify(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ec key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ed25519 key: %v", err)
+	}
+	now := time.Now()
+	req := dpop.Request{Method: "GET", Path: "/api/v1/vault/items", Token: "session-token"}
+
+	for name, key := range map[string]crypto.Signer{"ES256": ecKey, "EdDSA": edKey} {
+		t.Run(name, func(t *testing.T) {
+			proof, err := dpop.Sign(key, "GET", "https://vault.example.com/api/v1/vault/items?limit=10", "session-token", now)
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+			got, err := dpop.Verify(proof, req, now.Add(30*time.Second))
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			want, _ := dpop.Thumbprint(key.Public())
+			if got.Thumbprint != want || got.ID == "" {
+				t.Fatalf("proof = %+v, want thumbprint %s", got, want)
+			}
+
+			for name, req := range map[string]dpop.Request{
+				"method": {Method: "DELETE", Path: req.Path, Token: req.Token},
+				"path":   {Method: req.Method, Path: "/api/v1/auth/me", Token: req.Token},
+				"token":  {Method: req.Method, Path: req.Path, Token: "stolen-elsewhere"},
+			} {
+				if _, err := dpop.Verify(proof, req, now); !errors.Is(err, dpop.ErrInvalidProof) {
+					t.Errorf("Verify(other %s) err = %v, want ErrInvalidProof", name, err)
+				}
+			}
+			if _, err := dpop.Verify(proof, req, now.Add(2*dpop.MaxAge)); !errors.Is(err, dpop.ErrInvalidProof) {
+				t.Errorf("Verify(stale) err = %v, want ErrInvalidProof", err)
+			}
+
+			// A flipped bit in the claims breaks the signature.
+			parts := strings.Split(proof, ".")
+			tampered := parts[0] + "." + strings.Replace(parts[1], parts[1][:1], string(parts[1][0]^1), 1) + "." + parts[2]
+			if _, err := dpop.Verify(tampered, req, now); !errors.Is(err, dpop.ErrInvalidProof) {
+				t.Errorf("Verify(tampered) err = %v, want ErrInvalidProof", err)
+			}
+		})
+	}
+}
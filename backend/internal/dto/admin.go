@@ -0,0 +1,138 @@
+package dto
+
+// ─── Requests ────────────────────────────────────────────────────────
+
+type SetFeatureFlagRequest struct {
+	Enabled     *bool  `json:"enabled"`
+	Description string `json:"description,omitempty"` // empty keeps the current description
+}
+
+type SetMaintenanceRequest struct {
+	Enabled *bool  `json:"enabled"`
+	Message string `json:"message,omitempty"` // shown to clients while maintenance is on
+}
+
+// ─── Responses ───────────────────────────────────────────────────────
+
+type InstanceStatsResponse struct {
+	Users       UserStatsResponse     `json:"users"`
+	Sessions    SessionStatsResponse  `json:"sessions"`
+	Vault       VaultStatsResponse    `json:"vault"`
+	Storage     StorageStatsResponse  `json:"storage"`
+	ActiveUsers ActiveUsersResponse   `json:"active_users"`
+	DailyActive []DailyActiveResponse `json:"daily_active_users"`
+	GeneratedAt string                `json:"generated_at"`
+}
+
+type UserStatsResponse struct {
+	Total       int `json:"total"`
+	Admins      int `json:"admins"`
+	TOTPEnabled int `json:"totp_enabled"`
+	New30d      int `json:"new_30d"`
+}
+
+type SessionStatsResponse struct {
+	Active int `json:"active"`
+}
+
+type VaultStatsResponse struct {
+	Items   int `json:"items"`
+	Trashed int `json:"trashed"`
+	Folders int `json:"folders"`
+	Shares  int `json:"shares"`
+}
+
+type StorageStatsResponse struct {
+	VaultBytes      int64 `json:"vault_bytes"`
+	HistoryBytes    int64 `json:"history_bytes"`
+	AttachmentBytes int64 `json:"attachment_bytes"`
+	DatabaseBytes   int64 `json:"database_bytes"`
+}
+
+type ActiveUsersResponse struct {
+	Day   int `json:"1d"`
+	Week  int `json:"7d"`
+	Month int `json:"30d"`
+}
+
+type DailyActiveResponse struct {
+	Date  string `json:"date"` // YYYY-MM-DD, UTC
+	Users int    `json:"users"`
+}
+
+type AdminUserResponse struct {
+	ID              string `json:"id"`
+	Email           string `json:"email"`
+	Name            string `json:"name"`
+	Role            string `json:"role"`
+	EmailVerified   bool   `json:"email_verified"`
+	TOTPEnabled     bool   `json:"totp_enabled"`
+	TOTPLockedUntil string `json:"totp_locked_until,omitempty"`
+	ActiveSessions  int    `json:"active_sessions"`
+	VaultItems      int    `json:"vault_items"`
+	LastLoginAt     string `json:"last_login_at,omitempty"`
+	CreatedAt       string `json:"created_at"`
+}
+
+type AdminUsersResponse struct {
+	Users []AdminUserResponse `json:"users"`
+}
+
+type AdminSessionResponse struct {
+	ID         string `json:"id"`
+	DeviceName string `json:"device_name,omitempty"`
+	IPAddress  string `json:"ip_address,omitempty"`
+	UserAgent  string `json:"user_agent,omitempty"`
+	CreatedAt  string `json:"created_at"`
+	ExpiresAt  string `json:"expires_at"`
+}
+
+type AdminSessionsResponse struct {
+	Sessions []AdminSessionResponse `json:"sessions"`
+}
+
+type RevokeSessionsResponse struct {
+	Revoked int64 `json:"revoked"`
+}
+
+type FeatureFlagResponse struct {
+	Key         string `json:"key"`
+	Enabled     bool   `json:"enabled"`
+	Description string `json:"description,omitempty"`
+	UpdatedBy   string `json:"updated_by,omitempty"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+type FeatureFlagsResponse struct {
+	Flags []FeatureFlagResponse `json:"flags"`
+}
+
+type MaintenanceResponse struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+	Since   string `json:"since,omitempty"`
+	// Forced is true while MAINTENANCE_MODE holds the API in maintenance
+	// mode; the admin API cannot turn it off until the process restarts.
+	Forced bool `json:"forced,omitempty"`
+}
+
+type ErasureReportResponse struct {
+	UserID       string         `json:"user_id"`
+	Clean        bool           `json:"clean"`
+	Remaining    map[string]int `json:"remaining"`
+	BlobsDeleted int            `json:"blobs_deleted"`
+	BlobsFailed  int            `json:"blobs_failed"`
+	CheckedAt    string         `json:"checked_at"`
+}
+
+type IPBanResponse struct {
+	IP          string `json:"ip"`
+	Offenses    int    `json:"offenses"`
+	Reason      string `json:"reason"`
+	BannedUntil string `json:"banned_until"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type IPBansResponse struct {
+	Bans []IPBanResponse `json:"bans"`
+}
@@ -0,0 +1,28 @@
+package dto
+
+// ─── Responses ───────────────────────────────────────────────────────
+
+// VaultAttachmentResponse describes an attachment; the file itself is
+// downloaded separately. The name is encrypted like the file, base64
+// encoded.
+type VaultAttachmentResponse struct {
+	ID                 string `json:"id"`
+	ItemID             string `json:"item_id"`
+	FileNameCiphertext string `json:"file_name_ciphertext"`
+	Nonce              string `json:"nonce"`
+	SizeBytes          int64  `json:"size_bytes"`
+	CreatedAt          string `json:"created_at"`
+}
+
+type VaultAttachmentsResponse struct {
+	Attachments []VaultAttachmentResponse `json:"attachments"`
+	// MaxSizeBytes is the largest file an upload may carry.
+	MaxSizeBytes int64 `json:"max_size_bytes"`
+}
+
+// AttachmentDownloadURLResponse is a signed URL the encrypted file can be
+// downloaded from without credentials until ExpiresAt.
+type AttachmentDownloadURLResponse struct {
+	URL       string `json:"url"`
+	ExpiresAt string `json:"expires_at"`
+}
@@ -8,10 +8,14 @@ import (
 )
 
 type AuditEventResponse struct {
-	ID        uuid.UUID       `json:"id"`
-	EventType string          `json:"event_type"`
-	EventData json.RawMessage `json:"event_data"`
-	CreatedAt time.Time       `json:"created_at"`
+	ID          uuid.UUID       `json:"id"`
+	UserID      *uuid.UUID      `json:"user_id,omitempty"`
+	ActorUserID *uuid.UUID      `json:"actor_user_id,omitempty"`
+	EventType   string          `json:"event_type"`
+	EventData   json.RawMessage `json:"event_data"`
+	IPAddress   string          `json:"ip_address,omitempty"`
+	UserAgent   string          `json:"user_agent,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
 }
 
 type AuditPaginatedResponse struct {
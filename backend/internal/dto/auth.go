@@ -3,6 +3,24 @@ package dto
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message"`
+	// Fields lists the offending request fields when the request failed
+	// validation, so clients can highlight them.
+	Fields []FieldError `json:"fields,omitempty"`
+	// RequestID is set on unexpected server errors so a report can be
+	// matched to the server's logs.
+	RequestID string `json:"request_id,omitempty"`
+	// LockedUntil is when a locked-out caller may try again, in RFC 3339;
+	// Retry-After carries the same in seconds.
+	LockedUntil string `json:"locked_until,omitempty"`
+}
+
+// FieldError describes one invalid request field. Field is a JSON path such
+// as "email" or "items[2].nonce"; it is empty when the problem concerns the
+// body as a whole.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 type HealthResponse struct {
@@ -10,6 +28,16 @@ type HealthResponse struct {
 	Service string `json:"service"`
 	Time    string `json:"time"`
 	Env     string `json:"env"`
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+}
+
+// VersionResponse identifies the running build; see buildinfo.
+type VersionResponse struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
 }
 
 type RegisterRequest struct {
@@ -31,6 +59,13 @@ type LoginRequest struct {
 	TOTPCode     string `json:"totp_code"`
 	RecoveryCode string `json:"recovery_code"`
 	DeviceName   string `json:"device_name"`
+	// DeviceFingerprint optionally identifies the client device more
+	// reliably than its user agent; it is only stored hashed.
+	DeviceFingerprint string `json:"device_fingerprint"`
+	// PasskeyAssertion answers the second-factor step with a passkey in
+	// place of totp_code, to a challenge from POST
+	// /auth/passkeys/second-factor/begin.
+	PasskeyAssertion *PasskeyAssertionRequest `json:"passkey_assertion,omitempty"`
 }
 
 type LoginResponse struct {
@@ -39,6 +74,13 @@ type LoginResponse struct {
 	Email       string `json:"email"`
 	Name        string `json:"name"`
 	TOTPEnabled bool   `json:"is_totp_enabled"`
+	// CredentialResetRequired sends the client to POST
+	// /auth/credential-reset; nothing else works until it succeeds.
+	CredentialResetRequired bool `json:"credential_reset_required,omitempty"`
+	// AccessTokenExpiresAt is when the access token set in its cookie by
+	// POST /auth/refresh expires; refresh before then to keep requests off
+	// the database.
+	AccessTokenExpiresAt string `json:"access_token_expires_at,omitempty"`
 }
 
 type MFARequiredResponse struct {
@@ -52,11 +94,21 @@ type LogoutResponse struct {
 }
 
 type SessionResponse struct {
-	ExpiresAt   string `json:"expires_at"`
-	UserID      string `json:"user_id"`
-	Email       string `json:"email"`
-	Name        string `json:"name"`
-	TOTPEnabled bool   `json:"is_totp_enabled"`
+	ExpiresAt               string `json:"expires_at"`
+	UserID                  string `json:"user_id"`
+	Email                   string `json:"email"`
+	Name                    string `json:"name"`
+	TOTPEnabled             bool   `json:"is_totp_enabled"`
+	CredentialResetRequired bool   `json:"credential_reset_required,omitempty"`
+}
+
+// SessionStatusResponse tells the browser extension whether to show its
+// lock screen. Status is "unauthenticated", "locked" (signed in but awaiting
+// step-up) or "unlocked".
+type SessionStatusResponse struct {
+	Status    string `json:"status"`
+	UserID    string `json:"user_id,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
 }
 
 type TOTPSetupResponse struct {
@@ -106,6 +158,12 @@ type RecoveryVerifyResponse struct {
 	KEKSalt       string `json:"kek_salt,omitempty"`
 }
 
+// RevokeSessionsRequest carries the token of a lockout alert's sign-out
+// link.
+type RevokeSessionsRequest struct {
+	Token string `json:"token"`
+}
+
 type RecoveryResetRequest struct {
 	RecoveryToken string `json:"recovery_token"`
 	NewPassword   string `json:"new_password"`
@@ -121,6 +179,263 @@ type RecoveryResetResponse struct {
 	TOTPEnabled bool   `json:"is_totp_enabled"`
 }
 
+// CredentialResetRequest changes the master password of an account flagged
+// compromised. new_salt is the base64 salt the client derived its new vault
+// key with, and items holds the key of every vault item it owns, trashed
+// ones included, wrapped under that key.
+type CredentialResetRequest struct {
+	CurrentPassword string             `json:"current_password"`
+	NewPassword     string             `json:"new_password"`
+	NewSalt         string             `json:"new_salt"`
+	Items           []RewrappedItemKey `json:"items"`
+	DeviceName      string             `json:"device_name"`
+}
+
+// ChangePasswordRequest changes the caller's master password. new_salt and
+// items are as in CredentialResetRequest: every vault item key, trashed
+// items included, wrapped under the key derived from the new password and
+// salt.
+type ChangePasswordRequest struct {
+	CurrentPassword string             `json:"current_password"`
+	NewPassword     string             `json:"new_password"`
+	NewSalt         string             `json:"new_salt"`
+	Items           []RewrappedItemKey `json:"items"`
+}
+
+type ChangePasswordResponse struct {
+	Status string `json:"status"`
+	// RevokedSessions is how many other sessions were signed out.
+	RevokedSessions int64 `json:"revoked_sessions"`
+}
+
+// PasswordResetRequest asks for a password reset link to be emailed to
+// email.
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+// PasswordResetConfirmRequest sets a new master password with the token of
+// a reset link. new_salt and items are as in ChangePasswordRequest.
+type PasswordResetConfirmRequest struct {
+	Token       string             `json:"token"`
+	NewPassword string             `json:"new_password"`
+	NewSalt     string             `json:"new_salt"`
+	Items       []RewrappedItemKey `json:"items"`
+}
+
+type RewrappedItemKey struct {
+	ID         string `json:"id"`
+	WrappedDEK string `json:"wrapped_dek"`
+	WrapNonce  string `json:"wrap_nonce"`
+}
+
+// AccountMergeRequest folds the account source_session_token is signed in
+// to into the caller's. items holds the key of every vault item of that
+// account, trashed ones included, and shares the key of every share it
+// received, re-wrapped for the caller's vault key and public key.
+type AccountMergeRequest struct {
+	SourceSessionToken string             `json:"source_session_token"`
+	Items              []RewrappedItemKey `json:"items"`
+	Shares             []RewrappedItemKey `json:"shares"`
+}
+
+type AccountMergeResponse struct {
+	Status       string           `json:"status"`
+	MergedUserID string           `json:"merged_user_id"`
+	Moved        map[string]int64 `json:"moved"`
+	MergedAt     string           `json:"merged_at"`
+}
+
 type UpdateProfileRequest struct {
 	Name string `json:"name"`
 }
+
+// SessionBindingRequest replaces the session binding setting. on_mismatch
+// is "revoke" or "step_up"; step_up needs TOTP.
+type SessionBindingRequest struct {
+	BindIPPrefix  bool   `json:"bind_ip_prefix"`
+	BindUserAgent bool   `json:"bind_user_agent"`
+	OnMismatch    string `json:"on_mismatch"`
+}
+
+// DPoPKeyResponse reports the thumbprint of the key a session was bound to.
+type DPoPKeyResponse struct {
+	Status string `json:"status"`
+	JKT    string `json:"jkt"`
+}
+
+type SessionBindingResponse struct {
+	BindIPPrefix  bool   `json:"bind_ip_prefix"`
+	BindUserAgent bool   `json:"bind_user_agent"`
+	OnMismatch    string `json:"on_mismatch"`
+}
+
+type DeviceResponse struct {
+	ID            string `json:"id"`
+	Label         string `json:"label"`
+	Fingerprinted bool   `json:"fingerprinted"`
+	Current       bool   `json:"current"`
+	FirstSeenAt   string `json:"first_seen_at"`
+	LastSeenAt    string `json:"last_seen_at"`
+}
+
+type DevicesResponse struct {
+	Devices []DeviceResponse `json:"devices"`
+}
+
+type UserSessionResponse struct {
+	ID         string  `json:"id"`
+	DeviceName string  `json:"device_name,omitempty"`
+	IPAddress  string  `json:"ip_address,omitempty"`
+	UserAgent  string  `json:"user_agent,omitempty"`
+	Current    bool    `json:"current"`
+	CreatedAt  string  `json:"created_at"`
+	LastUsedAt *string `json:"last_used_at"`
+	ExpiresAt  string  `json:"expires_at"`
+}
+
+type UserSessionsResponse struct {
+	Sessions []UserSessionResponse `json:"sessions"`
+}
+
+// Passkey requests and responses carry binary WebAuthn values (challenges,
+// credential IDs, client data, authenticator data, keys and signatures) as
+// unpadded base64url, the encoding WebAuthn itself uses.
+
+type PasskeyRegisterBeginRequest struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+// PasskeyCreationOptionsResponse holds what the client passes to
+// navigator.credentials.create(). Algorithms are COSE identifiers for
+// pubKeyCredParams; ExcludeCredentials are the account's passkeys.
+type PasskeyCreationOptionsResponse struct {
+	ChallengeID        string   `json:"challenge_id"`
+	Challenge          string   `json:"challenge"`
+	ExpiresAt          string   `json:"expires_at"`
+	RPID               string   `json:"rp_id"`
+	RPName             string   `json:"rp_name"`
+	UserID             string   `json:"user_id"`
+	UserName           string   `json:"user_name"`
+	UserDisplayName    string   `json:"user_display_name"`
+	Algorithms         []int    `json:"algorithms"`
+	ExcludeCredentials []string `json:"exclude_credentials"`
+}
+
+// PasskeyRequestOptionsResponse holds what the client passes to
+// navigator.credentials.get().
+type PasskeyRequestOptionsResponse struct {
+	ChallengeID string `json:"challenge_id"`
+	Challenge   string `json:"challenge"`
+	ExpiresAt   string `json:"expires_at"`
+	RPID        string `json:"rp_id"`
+}
+
+// PasskeyRegistrationRequest answers a creation challenge. PublicKey is the
+// result of getPublicKey(). When the authenticator supports the PRF
+// extension, PRFSalt is the salt the client evaluated it with and
+// WrappedKey, sealed with WrapNonce, the vault key wrapped by the result.
+type PasskeyRegistrationRequest struct {
+	ChallengeID       string `json:"challenge_id"`
+	CredentialID      string `json:"credential_id"`
+	ClientDataJSON    string `json:"client_data_json"`
+	AuthenticatorData string `json:"authenticator_data"`
+	PublicKey         string `json:"public_key"`
+	Name              string `json:"name"`
+	PRFSalt           string `json:"prf_salt"`
+	WrappedKey        string `json:"wrapped_key"`
+	WrapNonce         string `json:"wrap_nonce"`
+	DeviceName        string `json:"device_name"`
+	DeviceFingerprint string `json:"device_fingerprint"`
+}
+
+// PasskeyAssertionRequest answers a request challenge with the result of
+// navigator.credentials.get().
+type PasskeyAssertionRequest struct {
+	ChallengeID       string `json:"challenge_id"`
+	CredentialID      string `json:"credential_id"`
+	ClientDataJSON    string `json:"client_data_json"`
+	AuthenticatorData string `json:"authenticator_data"`
+	Signature         string `json:"signature"`
+}
+
+type PasskeyLoginRequest struct {
+	PasskeyAssertionRequest
+	DeviceName        string `json:"device_name"`
+	DeviceFingerprint string `json:"device_fingerprint"`
+}
+
+// PasskeyLoginResponse is LoginResponse plus the PRF salt and wrapped vault
+// key registered with the passkey, if any.
+type PasskeyLoginResponse struct {
+	LoginResponse
+	PasskeyID  string `json:"passkey_id"`
+	PRFSalt    string `json:"prf_salt,omitempty"`
+	WrappedKey string `json:"wrapped_key,omitempty"`
+	WrapNonce  string `json:"wrap_nonce,omitempty"`
+}
+
+type PasskeyRecoveryBeginRequest struct {
+	RecoveryToken string `json:"recovery_token"`
+}
+
+type PasskeyRecoveryFinishRequest struct {
+	PasskeyRegistrationRequest
+	RecoveryToken string `json:"recovery_token"`
+}
+
+type PasskeyResponse struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	PRF        bool    `json:"prf"`
+	CreatedAt  string  `json:"created_at"`
+	LastUsedAt *string `json:"last_used_at"`
+}
+
+type PasskeysResponse struct {
+	Passkeys []PasskeyResponse `json:"passkeys"`
+}
+
+type QRLoginStartRequest struct {
+	DeviceName string `json:"device_name"`
+}
+
+// QRLoginStartResponse is a new QR code sign-in request. The client shows
+// request_id and code as a QR code and polls with poll_token, which it
+// keeps to itself, every poll_interval_seconds.
+type QRLoginStartResponse struct {
+	RequestID           string `json:"request_id"`
+	Code                string `json:"code"`
+	PollToken           string `json:"poll_token"`
+	ExpiresAt           string `json:"expires_at"`
+	PollIntervalSeconds int    `json:"poll_interval_seconds"`
+}
+
+type QRLoginPollRequest struct {
+	RequestID         string `json:"request_id"`
+	PollToken         string `json:"poll_token"`
+	DeviceFingerprint string `json:"device_fingerprint"`
+}
+
+// QRLoginPollResponse has Login once the request is approved.
+type QRLoginPollResponse struct {
+	Status string         `json:"status"`
+	Login  *LoginResponse `json:"login,omitempty"`
+}
+
+// QRLoginCodeRequest carries the code read from the QR code.
+type QRLoginCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// QRLoginResponse describes the device asking to sign in.
+type QRLoginResponse struct {
+	RequestID  string `json:"request_id"`
+	Status     string `json:"status"`
+	DeviceName string `json:"device_name"`
+	IPAddress  string `json:"ip_address"`
+	UserAgent  string `json:"user_agent"`
+	CreatedAt  string `json:"created_at"`
+	ExpiresAt  string `json:"expires_at"`
+}
@@ -0,0 +1,29 @@
+package dto
+
+// ─── Responses ───────────────────────────────────────────────────────
+
+type DataExportResponse struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	SizeBytes   int64  `json:"size_bytes,omitempty"`
+	CreatedAt   string `json:"created_at"`
+	CompletedAt string `json:"completed_at,omitempty"`
+	ExpiresAt   string `json:"expires_at,omitempty"`
+}
+
+// VaultBackupDiffResponse lists, by ID and version, the items created,
+// deleted and edited since a data export was taken.
+type VaultBackupDiffResponse struct {
+	ExportID  string                  `json:"export_id"`
+	TakenAt   string                  `json:"taken_at"`
+	Added     []VaultItemDiffResponse `json:"added"`
+	Removed   []VaultItemDiffResponse `json:"removed"`
+	Changed   []VaultItemDiffResponse `json:"changed"`
+	Unchanged int                     `json:"unchanged"`
+}
+
+type VaultItemDiffResponse struct {
+	ItemID        string `json:"item_id"`
+	BackupVersion int    `json:"backup_version,omitempty"`
+	LiveVersion   int    `json:"live_version,omitempty"`
+}
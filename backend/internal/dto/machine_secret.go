@@ -0,0 +1,51 @@
+package dto
+
+// ─── Requests ────────────────────────────────────────────────────────
+
+// PublishMachineSecretRequest carries the copy of the item's value the
+// client encrypted for the pipelines, base64 encoded.
+type PublishMachineSecretRequest struct {
+	Name        string `json:"name"`
+	Ciphertext  string `json:"ciphertext"`
+	Nonce       string `json:"nonce"`
+	AlgoVersion string `json:"algo_version"`
+}
+
+type CreateMachineKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// ExpiresInDays is how long the key is valid; 0 or absent for never.
+	ExpiresInDays int `json:"expires_in_days"`
+}
+
+// ─── Responses ───────────────────────────────────────────────────────
+
+// MachineSecretResponse is what GET /secrets/{name} returns. Listings for
+// the owner leave the ciphertext out.
+type MachineSecretResponse struct {
+	Name        string `json:"name"`
+	ItemID      string `json:"item_id,omitempty"`
+	Ciphertext  string `json:"ciphertext,omitempty"`
+	Nonce       string `json:"nonce,omitempty"`
+	AlgoVersion string `json:"algo_version"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+type MachineSecretsResponse struct {
+	Secrets []MachineSecretResponse `json:"secrets"`
+}
+
+type MachineKeyResponse struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	Key        string   `json:"key,omitempty"` // only returned on creation
+	ExpiresAt  string   `json:"expires_at,omitempty"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+type MachineKeysResponse struct {
+	Keys []MachineKeyResponse `json:"keys"`
+}
@@ -0,0 +1,25 @@
+package dto
+
+// ─── Requests ────────────────────────────────────────────────────────
+
+// CategoryPreferencePatch leaves omitted fields unchanged.
+type CategoryPreferencePatch struct {
+	Enabled  *bool     `json:"enabled"`
+	Channels *[]string `json:"channels"`
+}
+
+type UpdateNotificationPreferencesRequest struct {
+	Categories map[string]CategoryPreferencePatch `json:"categories"`
+}
+
+// ─── Responses ───────────────────────────────────────────────────────
+
+type CategoryPreferenceResponse struct {
+	Enabled  bool     `json:"enabled"`
+	Channels []string `json:"channels"`
+}
+
+type NotificationPreferencesResponse struct {
+	Categories map[string]CategoryPreferenceResponse `json:"categories"`
+	UpdatedAt  string                                `json:"updated_at,omitempty"`
+}
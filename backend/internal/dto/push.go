@@ -0,0 +1,25 @@
+package dto
+
+// ─── Requests ────────────────────────────────────────────────────────
+
+type RegisterPushTokenRequest struct {
+	Platform string `json:"platform"`
+	Token    string `json:"token"`
+}
+
+// ─── Responses ───────────────────────────────────────────────────────
+
+// PushTokenResponse leaves out the token itself; clients only need the ID
+// to remove it.
+type PushTokenResponse struct {
+	ID        string `json:"id"`
+	Platform  string `json:"platform"`
+	DeviceID  string `json:"device_id,omitempty"`
+	Current   bool   `json:"current"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type PushTokensResponse struct {
+	Tokens []PushTokenResponse `json:"tokens"`
+}
@@ -1,22 +1,35 @@
 package dto
 
+// UpsertUserKeysRequest replaces the user's key pairs. PublicKeyEd25519,
+// the signing key, is optional; EncryptedPrivateKeys holds both private
+// keys sealed under the vault key.
 type UpsertUserKeysRequest struct {
 	PublicKeyX25519      string `json:"public_key_x25519"`
+	PublicKeyEd25519     string `json:"public_key_ed25519,omitempty"`
 	EncryptedPrivateKeys string `json:"encrypted_private_keys"`
 	Nonce                string `json:"nonce"`
 }
 
 type UserKeysResponse struct {
 	PublicKeyX25519      string `json:"public_key_x25519"`
+	PublicKeyEd25519     string `json:"public_key_ed25519,omitempty"`
 	EncryptedPrivateKeys string `json:"encrypted_private_keys,omitempty"`
 	Nonce                string `json:"nonce,omitempty"`
 	HasKeys              bool   `json:"has_keys"`
 }
 
 type UserPublicKeyResponse struct {
-	UserID          string `json:"user_id"`
-	Email           string `json:"email"`
-	PublicKeyX25519 string `json:"public_key_x25519"`
+	UserID           string `json:"user_id"`
+	Email            string `json:"email"`
+	PublicKeyX25519  string `json:"public_key_x25519"`
+	PublicKeyEd25519 string `json:"public_key_ed25519,omitempty"`
+}
+
+type KeyFingerprintResponse struct {
+	UserID       string `json:"user_id"`
+	Fingerprint  string `json:"fingerprint"`
+	SafetyNumber string `json:"safety_number,omitempty"`
+	UpdatedAt    string `json:"updated_at"`
 }
 
 type ShareItemRequest struct {
@@ -24,6 +37,31 @@ type ShareItemRequest struct {
 	WrappedDEK     string `json:"wrapped_dek"`
 	WrapNonce      string `json:"wrap_nonce"`
 	Permissions    string `json:"permissions"`
+	ShareWindow
+}
+
+// ShareWindow limits when a share grants access. Times are RFC 3339; all
+// fields are optional.
+type ShareWindow struct {
+	NotBefore string         `json:"not_before,omitempty"`
+	ExpiresAt string         `json:"expires_at,omitempty"`
+	Schedule  *ShareSchedule `json:"schedule,omitempty"`
+}
+
+// ShareSchedule grants access on Days ("mon".."sun") from Start to End
+// ("09:00", "18:00"; "24:00" for midnight) in Timezone, an IANA name that
+// defaults to UTC. An End before Start spans midnight.
+type ShareSchedule struct {
+	Days     []string `json:"days"`
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	Timezone string   `json:"timezone,omitempty"`
+}
+
+// UpdateSharePermissionsRequest changes a share's permissions to "read"
+// or "manage".
+type UpdateSharePermissionsRequest struct {
+	Permissions string `json:"permissions"`
 }
 
 type ShareItemResponse struct {
@@ -35,9 +73,11 @@ type ShareItemResponse struct {
 }
 
 type ShareRecipientResponse struct {
-	UserID      string `json:"user_id"`
-	Permissions string `json:"permissions"`
-	CreatedAt   string `json:"created_at"`
+	UserID          string `json:"user_id"`
+	Permissions     string `json:"permissions"`
+	FirstAccessedAt string `json:"first_accessed_at,omitempty"`
+	CreatedAt       string `json:"created_at"`
+	ShareWindow
 }
 
 type ShareRecipientsResponse struct {
@@ -62,6 +102,7 @@ type SharedItemResponse struct {
 	SharedByEmail   string `json:"shared_by_email"`
 	SharedByName    string `json:"shared_by_name"`
 	Permissions     string `json:"permissions"`
+	ShareWindow
 }
 
 type SharedItemsResponse struct {
@@ -81,3 +122,76 @@ type SentShareResponse struct {
 type SentSharesResponse struct {
 	Shares []SentShareResponse `json:"shares"`
 }
+
+type AccessAlertRequest struct {
+	Email bool `json:"email"`
+}
+
+// AccessAlertResponse reports an item's access alert; Enabled is false when
+// the owner has none.
+type AccessAlertResponse struct {
+	ItemID    string `json:"item_id"`
+	Enabled   bool   `json:"enabled"`
+	Email     bool   `json:"email"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+type AccessRequestRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ApproveAccessRequest carries the item key the approver's client wrapped
+// for the requester, and the share to grant.
+type ApproveAccessRequest struct {
+	WrappedDEK  string `json:"wrapped_dek"`
+	WrapNonce   string `json:"wrap_nonce"`
+	Permissions string `json:"permissions"`
+	ShareWindow
+}
+
+type AccessRequestResponse struct {
+	ID              string `json:"id"`
+	ItemID          string `json:"item_id"`
+	OwnerUserID     string `json:"owner_user_id"`
+	RequesterUserID string `json:"requester_user_id"`
+	RequesterEmail  string `json:"requester_email"`
+	RequesterName   string `json:"requester_name"`
+	Reason          string `json:"reason,omitempty"`
+	Status          string `json:"status"`
+	DecidedByUserID string `json:"decided_by_user_id,omitempty"`
+	DecidedAt       string `json:"decided_at,omitempty"`
+	CreatedAt       string `json:"created_at"`
+}
+
+type AccessRequestsResponse struct {
+	Requests []AccessRequestResponse `json:"requests"`
+}
+
+type ShareInvitationRequest struct {
+	Email       string `json:"email"`
+	Permissions string `json:"permissions"`
+}
+
+// CompleteShareInvitationRequest carries the item key the sharer's client
+// wrapped for the registered invitee.
+type CompleteShareInvitationRequest struct {
+	WrappedDEK string `json:"wrapped_dek"`
+	WrapNonce  string `json:"wrap_nonce"`
+	ShareWindow
+}
+
+type ShareInvitationResponse struct {
+	ID              string `json:"id"`
+	ItemID          string `json:"item_id"`
+	Email           string `json:"email"`
+	Permissions     string `json:"permissions"`
+	Status          string `json:"status"`
+	RecipientUserID string `json:"recipient_user_id,omitempty"`
+	ReadyAt         string `json:"ready_at,omitempty"`
+	ExpiresAt       string `json:"expires_at"`
+	CreatedAt       string `json:"created_at"`
+}
+
+type ShareInvitationsResponse struct {
+	Invitations []ShareInvitationResponse `json:"invitations"`
+}
@@ -0,0 +1,8 @@
+// Package dto holds the request and response bodies of /api/v2 that differ
+// from v1. A body not defined here is unchanged, and v2 handlers use the
+// type from pmv2/backend/internal/dto. Import it as dtov2.
+//
+// v1 bodies are frozen: a breaking change to a v1 type (renamed or removed
+// fields, different error envelopes or pagination) is made by adding the new
+// shape here and serving it from a v2 route.
+package dto
@@ -10,6 +10,9 @@ type CreateVaultItemRequest struct {
 	WrapNonce   string          `json:"wrap_nonce"`
 	AlgoVersion string          `json:"algo_version"`
 	Metadata    json.RawMessage `json:"metadata"`
+	// URIHMACs replace the item's URI HMACs when present; an empty list
+	// clears them.
+	URIHMACs []string `json:"uri_hmacs"`
 }
 
 type UpdateVaultItemRequest struct {
@@ -20,6 +23,9 @@ type UpdateVaultItemRequest struct {
 	WrapNonce   string          `json:"wrap_nonce"`
 	AlgoVersion string          `json:"algo_version"`
 	Metadata    json.RawMessage `json:"metadata"`
+	// URIHMACs replace the item's URI HMACs when present; an empty list
+	// clears them.
+	URIHMACs []string `json:"uri_hmacs"`
 }
 
 type BulkCreateVaultItemsRequest struct {
@@ -42,8 +48,52 @@ type VaultItemResponse struct {
 	DeletedAt   *string         `json:"deleted_at,omitempty"`
 }
 
+// VaultItemsResponse lists items. A paged listing also carries the number
+// of matching items and, unless this is the last page, the cursor of the
+// next one.
 type VaultItemsResponse struct {
-	Items []VaultItemResponse `json:"items"`
+	Items      []VaultItemResponse `json:"items"`
+	Total      *int                `json:"total,omitempty"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// CompactVaultItemResponse is an item without its ciphertext, for the
+// browser extension's list; the item itself is fetched when it is used.
+type CompactVaultItemResponse struct {
+	ID          string          `json:"id"`
+	FolderID    *string         `json:"folder_id,omitempty"`
+	AlgoVersion string          `json:"algo_version"`
+	Metadata    json.RawMessage `json:"metadata,omitempty"`
+	IsShared    bool            `json:"is_shared"`
+	Version     int             `json:"version"`
+	URIHMACs    []string        `json:"uri_hmacs"`
+	UseCount    int64           `json:"use_count"`
+	LastUsedAt  *string         `json:"last_used_at,omitempty"`
+	UpdatedAt   string          `json:"updated_at"`
+}
+
+type CompactVaultItemsResponse struct {
+	Items []CompactVaultItemResponse `json:"items"`
+}
+
+type MatchVaultItemsRequest struct {
+	URIHMACs []string `json:"uri_hmacs"`
+}
+
+type MatchedVaultItemResponse struct {
+	VaultItemResponse
+	UseCount   int64   `json:"use_count"`
+	LastUsedAt *string `json:"last_used_at,omitempty"`
+}
+
+type MatchedVaultItemsResponse struct {
+	Items []MatchedVaultItemResponse `json:"items"`
+}
+
+type ItemUsageResponse struct {
+	ItemID     string  `json:"item_id"`
+	UseCount   int64   `json:"use_count"`
+	LastUsedAt *string `json:"last_used_at,omitempty"`
 }
 
 type VaultItemVersionResponse struct {
@@ -81,6 +131,85 @@ type FoldersResponse struct {
 	Folders []FolderResponse `json:"folders"`
 }
 
+// VaultSyncResponse is the full active vault, or with since only what
+// changed since that cursor. Revision matches the ETag; Cursor is passed as
+// since to the next sync.
+type VaultSyncResponse struct {
+	Revision       string              `json:"revision"`
+	Cursor         string              `json:"cursor"`
+	Items          []VaultItemResponse `json:"items"`
+	Folders        []FolderResponse    `json:"folders"`
+	DeletedItemIDs []string            `json:"deleted_item_ids,omitempty"`
+}
+
 type VaultSaltResponse struct {
 	Salt string `json:"salt"`
 }
+
+// VaultSyncRecord is one line of the streamed full sync. The stream opens
+// with a "start" record, carries one "folder" or "item" record per entry and
+// closes with an "end" record; a stream without it was cut short and can be
+// resumed from the number of entries received.
+type VaultSyncRecord struct {
+	Type      string             `json:"type"`
+	Revision  string             `json:"revision,omitempty"`
+	Offset    *int               `json:"offset,omitempty"`
+	Remaining *int               `json:"remaining,omitempty"`
+	Folder    *FolderResponse    `json:"folder,omitempty"`
+	Item      *VaultItemResponse `json:"item,omitempty"`
+}
+
+// VaultExportDocument is the JSON vault export. Items keep the encrypted
+// fields of VaultItemResponse; attachments are listed without their
+// contents. The CSV export has one row per item with the columns of
+// VaultExportCSVHeader.
+type VaultExportDocument struct {
+	Format      string                    `json:"format"`
+	Version     int                       `json:"version"`
+	ExportID    string                    `json:"export_id"`
+	ExportedAt  string                    `json:"exported_at"`
+	Folders     []FolderResponse          `json:"folders"`
+	Attachments []VaultAttachmentResponse `json:"attachments"`
+	Items       []VaultItemResponse       `json:"items"`
+}
+
+// VaultExportFormatName identifies a VaultExportDocument.
+const VaultExportFormatName = "pmv2-vault-export"
+
+var VaultExportCSVHeader = []string{
+	"id", "folder_id", "ciphertext", "nonce", "wrapped_dek", "wrap_nonce",
+	"algo_version", "metadata", "version", "created_at", "updated_at",
+}
+
+// ImportVaultItemsRequest imports items the client read from another
+// password manager's export. Source is the export's format, one of the
+// importer package's formats; each item is encrypted like a created item
+// and carries the row of the export it came from.
+type ImportVaultItemsRequest struct {
+	Source string                   `json:"source"`
+	Items  []ImportVaultItemRequest `json:"items"`
+}
+
+type ImportVaultItemRequest struct {
+	Row int `json:"row"`
+	CreateVaultItemRequest
+}
+
+// ImportVaultItemsResponse lists the items created by an import and the
+// rows that were not imported.
+type ImportVaultItemsResponse struct {
+	Imported []ImportedVaultItemResponse `json:"imported"`
+	Errors   []ImportRowErrorResponse    `json:"errors"`
+}
+
+type ImportedVaultItemResponse struct {
+	Row  int               `json:"row"`
+	Item VaultItemResponse `json:"item"`
+}
+
+type ImportRowErrorResponse struct {
+	Row     int          `json:"row"`
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
@@ -0,0 +1,13 @@
+package dto
+
+type APIVersionResponse struct {
+	Version      int    `json:"version"`
+	Prefix       string `json:"prefix"`
+	Status       string `json:"status"`
+	DeprecatedAt string `json:"deprecated_at,omitempty"`
+	Sunset       string `json:"sunset,omitempty"`
+}
+
+type APIVersionsResponse struct {
+	Versions []APIVersionResponse `json:"versions"`
+}
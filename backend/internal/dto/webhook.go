@@ -0,0 +1,49 @@
+package dto
+
+// ─── Requests ────────────────────────────────────────────────────────
+
+type CreateWebhookRequest struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"` // empty subscribes to every event
+}
+
+// ─── Responses ───────────────────────────────────────────────────────
+
+type WebhookResponse struct {
+	ID         string   `json:"id"`
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Enabled    bool     `json:"enabled"`
+	Secret     string   `json:"secret,omitempty"` // only returned on creation
+	CreatedAt  string   `json:"created_at"`
+}
+
+type WebhooksResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+}
+
+type WebhookDeliveryAttemptResponse struct {
+	StatusCode  int    `json:"status_code,omitempty"`
+	Error       string `json:"error,omitempty"`
+	DurationMS  int    `json:"duration_ms"`
+	AttemptedAt string `json:"attempted_at"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID            string                           `json:"id"`
+	EventID       string                           `json:"event_id"`
+	EventType     string                           `json:"event_type"`
+	Status        string                           `json:"status"`
+	Attempts      int                              `json:"attempts"`
+	LastError     string                           `json:"last_error,omitempty"`
+	NextAttemptAt string                           `json:"next_attempt_at,omitempty"`
+	DeliveredAt   string                           `json:"delivered_at,omitempty"`
+	CreatedAt     string                           `json:"created_at"`
+	AttemptLog    []WebhookDeliveryAttemptResponse `json:"attempt_log"`
+}
+
+type WebhookDeliveriesResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+	Total      int                       `json:"total"`
+	HasNext    bool                      `json:"has_next"`
+}
@@ -0,0 +1,126 @@
+// Package geoip approximates where an IP address is, for the impossible
+// travel check. It reads the block files of a city-level database in the
+// MaxMind CSV layout (e.g. GeoLite2-City-Blocks-IPv4.csv), which map
+// networks to coordinates; nothing is looked up over the network.
+package geoip
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Location is a point on the globe, in degrees.
+type Location struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// earthRadiusKm is the mean radius of the Earth.
+const earthRadiusKm = 6371.0
+
+// DistanceKm returns the great-circle distance between two locations.
+func DistanceKm(a, b Location) float64 {
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := rad(b.Latitude - a.Latitude)
+	dLon := rad(b.Longitude - a.Longitude)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(rad(a.Latitude))*math.Cos(rad(b.Latitude))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(min(h, 1)))
+}
+
+type block struct {
+	network  netip.Prefix
+	location Location
+}
+
+// DB locates addresses from the blocks it was loaded with.
+type DB struct {
+	blocks []block // sorted by network address; networks do not overlap
+}
+
+// Open loads and merges the given block files, typically one for IPv4 and
+// one for IPv6.
+func Open(paths ...string) (*DB, error) {
+	db := &DB{}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open geoip blocks: %w", err)
+		}
+		err = db.load(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("load geoip blocks %s: %w", path, err)
+		}
+	}
+	slices.SortFunc(db.blocks, func(a, b block) int { return a.network.Addr().Compare(b.network.Addr()) })
+	return db, nil
+}
+
+// load reads one CSV file. Only the network, latitude and longitude columns
+// are used, found by name in the header; rows without coordinates are
+// skipped.
+func (db *DB) load(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("read header: %w", err)
+	}
+	columns := map[string]int{"network": -1, "latitude": -1, "longitude": -1}
+	for i, name := range header {
+		if _, ok := columns[strings.TrimSpace(name)]; ok {
+			columns[strings.TrimSpace(name)] = i
+		}
+	}
+	for name, i := range columns {
+		if i < 0 {
+			return fmt.Errorf("missing %s column", name)
+		}
+	}
+
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		lat, latErr := strconv.ParseFloat(record[columns["latitude"]], 64)
+		lon, lonErr := strconv.ParseFloat(record[columns["longitude"]], 64)
+		if latErr != nil || lonErr != nil {
+			continue
+		}
+		network, err := netip.ParsePrefix(record[columns["network"]])
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		db.blocks = append(db.blocks, block{network: network.Masked(), location: Location{Latitude: lat, Longitude: lon}})
+	}
+}
+
+// Locate returns the location of ip, if a block covers it.
+func (db *DB) Locate(ip string) (Location, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Location{}, false
+	}
+	addr = addr.Unmap()
+	// The candidate is the last block starting at or before addr.
+	i, found := slices.BinarySearchFunc(db.blocks, addr, func(b block, a netip.Addr) int { return b.network.Addr().Compare(a) })
+	if !found {
+		i--
+	}
+	if i < 0 || !db.blocks[i].network.Contains(addr) {
+		return Location{}, false
+	}
+	return db.blocks[i].location, true
+}
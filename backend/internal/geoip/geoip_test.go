@@ -0,0 +1,54 @@
+package geoip_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pmv2/backend/internal/geoip"
+)
+
+func TestLocate(t *testing.T) {
+	dir := t.TempDir()
+	v4 := filepath.Join(dir, "blocks-ipv4.csv")
+	v6 := filepath.Join(dir, "blocks-ipv6.csv")
+	header := "network,geoname_id,registered_country_geoname_id,represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider,postal_code,latitude,longitude,accuracy_radius\n"
+	if err := os.WriteFile(v4, []byte(header+
+		"198.51.100.0/24,1,1,,0,0,,51.5072,-0.1276,20\n"+
+		"203.0.113.0/25,2,2,,0,0,,40.7128,-74.0060,20\n"+
+		"203.0.113.128/25,3,3,,0,0,,,,\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(v6, []byte(header+"2001:db8::/32,4,4,,0,0,,35.6762,139.6503,50\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := geoip.Open(v4, v6)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	for ip, want := range map[string]float64{"198.51.100.7": 51.5072, "203.0.113.1": 40.7128, "2001:db8::1": 35.6762, "::ffff:198.51.100.9": 51.5072} {
+		loc, ok := db.Locate(ip)
+		if !ok || loc.Latitude != want {
+			t.Errorf("Locate(%s) = %+v, %v; want latitude %v", ip, loc, ok, want)
+		}
+	}
+	for _, ip := range []string{"203.0.113.200", "192.0.2.1", "10.0.0.1", "not-an-ip", ""} {
+		if loc, ok := db.Locate(ip); ok {
+			t.Errorf("Locate(%s) = %+v, want unknown", ip, loc)
+		}
+	}
+}
+
+func TestDistanceKm(t *testing.T) {
+	london := geoip.Location{Latitude: 51.5072, Longitude: -0.1276}
+	newYork := geoip.Location{Latitude: 40.7128, Longitude: -74.0060}
+	if d := geoip.DistanceKm(london, newYork); math.Abs(d-5570) > 10 {
+		t.Fatalf("London to New York = %.0f km, want about 5570", d)
+	}
+	if d := geoip.DistanceKm(london, london); d != 0 {
+		t.Fatalf("distance to itself = %v", d)
+	}
+}
@@ -0,0 +1,195 @@
+package graphqlapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// executor runs one validated query operation. Fields are resolved
+// sequentially; errors are collected with their path and the failing field
+// becomes null, propagating to the nearest nullable parent as the spec
+// requires for non-null fields.
+type executor struct {
+	schema *ast.Schema
+	vars   map[string]any
+	req    *request
+	errs   gqlerror.List
+}
+
+// object is a result map that keeps the query's field order.
+type object []objectField
+
+type objectField struct {
+	key   string
+	value any
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.key)
+		b.Write(key)
+		b.WriteByte(':')
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+func (e *executor) execute(op *ast.OperationDefinition) any {
+	data, ok := e.selectionSet(e.schema.Query, nil, op.SelectionSet, nil)
+	if !ok {
+		return nil
+	}
+	return data
+}
+
+// selectionSet resolves set against parent, a value of type def. ok is false
+// when a non-null field failed and the whole object must become null.
+func (e *executor) selectionSet(def *ast.Definition, parent any, set ast.SelectionSet, path ast.Path) (object, bool) {
+	keys, fields := e.collectFields(def, set, nil, map[string][]*ast.Field{})
+	result := make(object, 0, len(keys))
+	for _, key := range keys {
+		value, ok := e.field(def, parent, fields[key], extend(path, ast.PathName(key)))
+		if !ok {
+			return nil, false
+		}
+		result = append(result, objectField{key: key, value: value})
+	}
+	return result, true
+}
+
+// collectFields flattens fragments and applies @skip/@include, grouping
+// fields by response key in first-seen order.
+func (e *executor) collectFields(def *ast.Definition, set ast.SelectionSet, keys []string, fields map[string][]*ast.Field) ([]string, map[string][]*ast.Field) {
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *ast.Field:
+			if !e.included(sel.Directives) {
+				continue
+			}
+			key := sel.Alias
+			if key == "" {
+				key = sel.Name
+			}
+			if _, seen := fields[key]; !seen {
+				keys = append(keys, key)
+			}
+			fields[key] = append(fields[key], sel)
+		case *ast.InlineFragment:
+			if e.included(sel.Directives) && (sel.TypeCondition == "" || sel.TypeCondition == def.Name) {
+				keys, fields = e.collectFields(def, sel.SelectionSet, keys, fields)
+			}
+		case *ast.FragmentSpread:
+			if e.included(sel.Directives) && sel.Definition.TypeCondition == def.Name {
+				keys, fields = e.collectFields(def, sel.Definition.SelectionSet, keys, fields)
+			}
+		}
+	}
+	return keys, fields
+}
+
+func (e *executor) included(directives ast.DirectiveList) bool {
+	if d := directives.ForName("skip"); d != nil && d.ArgumentMap(e.vars)["if"] == true {
+		return false
+	}
+	if d := directives.ForName("include"); d != nil && d.ArgumentMap(e.vars)["if"] == false {
+		return false
+	}
+	return true
+}
+
+func (e *executor) field(def *ast.Definition, parent any, fields []*ast.Field, path ast.Path) (any, bool) {
+	field := fields[0]
+	if field.Name == "__typename" {
+		return def.Name, true
+	}
+	if field.Definition == nil || def.Fields.ForName(field.Name) == nil {
+		e.errs = append(e.errs, gqlerror.ErrorPathf(path, "introspection is not supported"))
+		return nil, true
+	}
+
+	value, err := e.req.resolve(def.Name, field.Name, parent, field.ArgumentMap(e.vars))
+	if err != nil {
+		e.errs = append(e.errs, e.req.fieldError(path, err))
+		return nil, !field.Definition.Type.NonNull
+	}
+
+	// Merge the sub-selections of every field sharing this response key.
+	var set ast.SelectionSet
+	for _, f := range fields {
+		set = append(set, f.SelectionSet...)
+	}
+	return e.complete(field.Definition.Type, value, set, path)
+}
+
+func (e *executor) complete(typ *ast.Type, value any, set ast.SelectionSet, path ast.Path) (any, bool) {
+	if isNil(value) {
+		if typ.NonNull {
+			e.errs = append(e.errs, gqlerror.ErrorPathf(path, "non-null field resolved to null"))
+			return nil, false
+		}
+		return nil, true
+	}
+
+	if typ.Elem != nil {
+		list := reflect.ValueOf(value)
+		out := make([]any, list.Len())
+		for i := range out {
+			item, ok := e.complete(typ.Elem, list.Index(i).Interface(), set, extend(path, ast.PathIndex(i)))
+			if !ok {
+				return nil, !typ.NonNull
+			}
+			out[i] = item
+		}
+		return out, true
+	}
+
+	def := e.schema.Types[typ.NamedType]
+	if def.Kind == ast.Object {
+		obj, ok := e.selectionSet(def, value, set, path)
+		if !ok {
+			return nil, !typ.NonNull
+		}
+		return obj, true
+	}
+	if t, ok := value.(time.Time); ok {
+		return t.UTC().Format(time.RFC3339), true
+	}
+	return value, true
+}
+
+// isNil reports whether value is null. Nil slices are not: they complete as
+// empty lists.
+func isNil(value any) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map:
+		return v.IsNil()
+	}
+	return false
+}
+
+// extend returns a copy of path with elem appended; error paths must not
+// share a backing array with their siblings.
+func extend(path ast.Path, elem ast.PathElement) ast.Path {
+	out := make(ast.Path, len(path), len(path)+1)
+	copy(out, path)
+	return append(out, elem)
+}
@@ -0,0 +1,125 @@
+// Package graphqlapi serves a read-only GraphQL endpoint (schema.graphql) so
+// dashboards can fetch exactly the vault metadata they need in one request.
+// Queries are parsed and validated with gqlparser and executed by a small
+// resolver table over the same services the REST API uses; there are no
+// mutations and no introspection. Every query is checked against a depth and
+// a complexity limit before anything is resolved.
+package graphqlapi
+
+import (
+	_ "embed"
+	"log/slog"
+	"net/http"
+
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"github.com/vektah/gqlparser/v2/validator"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/service"
+	"pmv2/backend/internal/util"
+)
+
+//go:embed schema.graphql
+var schemaSource string
+
+type Config struct {
+	// MaxDepth bounds how deeply selections may nest; viewer { id } is 2.
+	MaxDepth int
+	// MaxComplexity bounds the estimated number of resolved fields, with
+	// list fields counting their selection once per expected element.
+	MaxComplexity int
+}
+
+type Handler struct {
+	cfg     Config
+	schema  *ast.Schema
+	vault   *service.VaultService
+	folders *service.FolderService
+	sharing *service.SharingService
+	log     *slog.Logger
+}
+
+func NewHandler(cfg Config, vault *service.VaultService, folders *service.FolderService, sharing *service.SharingService, log *slog.Logger) *Handler {
+	return &Handler{
+		cfg:     cfg,
+		schema:  gqlparser.MustLoadSchema(&ast.Source{Name: "schema.graphql", Input: schemaSource}),
+		vault:   vault,
+		folders: folders,
+		sharing: sharing,
+		log:     log,
+	}
+}
+
+type queryRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+	// Extensions is accepted because common clients always send it; it is
+	// not used.
+	Extensions map[string]any `json:"extensions"`
+}
+
+// queryResponse always carries "data", null when a non-null root field
+// failed; errorResponse answers queries that never started executing.
+type queryResponse struct {
+	Data   any           `json:"data"`
+	Errors gqlerror.List `json:"errors,omitempty"`
+}
+
+type errorResponse struct {
+	Errors gqlerror.List `json:"errors"`
+}
+
+// HandleQuery answers POST /api/graphql. As usual for GraphQL over HTTP,
+// problems with the query itself are reported in "errors" with status 200;
+// only an unreadable body gets the API's standard 400.
+func (h *Handler) HandleQuery(w http.ResponseWriter, r *http.Request, session domain.Session) {
+	var req queryRequest
+	if err := util.ReadJSON(r, &req); err != nil {
+		util.WriteDecodeError(w, err)
+		return
+	}
+
+	doc, errs := gqlparser.LoadQuery(h.schema, req.Query)
+	if len(errs) > 0 {
+		util.WriteJSON(w, http.StatusOK, errorResponse{Errors: errs})
+		return
+	}
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		util.WriteJSON(w, http.StatusOK, errorResponse{Errors: gqlerror.List{err}})
+		return
+	}
+	vars, varErr := validator.VariableValues(h.schema, op, req.Variables)
+	if varErr != nil {
+		util.WriteJSON(w, http.StatusOK, errorResponse{Errors: gqlerror.List{gqlerror.WrapIfUnwrapped(varErr)}})
+		return
+	}
+	if err := h.checkLimits(op, vars); err != nil {
+		util.WriteJSON(w, http.StatusOK, errorResponse{Errors: gqlerror.List{err}})
+		return
+	}
+
+	e := &executor{
+		schema: h.schema,
+		vars:   vars,
+		req:    &request{ctx: r.Context(), h: h, session: session},
+	}
+	data := e.execute(op)
+	util.WriteJSON(w, http.StatusOK, queryResponse{Data: data, Errors: e.errs})
+}
+
+func selectOperation(doc *ast.QueryDocument, name string) (*ast.OperationDefinition, *gqlerror.Error) {
+	if name != "" {
+		if op := doc.Operations.ForName(name); op != nil {
+			return op, nil
+		}
+		return nil, gqlerror.Errorf("unknown operation %q", name)
+	}
+	if len(doc.Operations) != 1 {
+		return nil, gqlerror.Errorf("operationName is required when the document has several operations")
+	}
+	return doc.Operations[0], nil
+}
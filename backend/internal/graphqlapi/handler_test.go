@@ -0,0 +1,174 @@
+package graphqlapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/graphqlapi"
+	"pmv2/backend/internal/service"
+)
+
+// The mocks implement only the reads the resolvers use; any other call
+// panics on the nil embedded interface.
+type mockVaultRepo struct {
+	domain.VaultRepository
+	items []domain.VaultItem
+}
+
+func (m *mockVaultRepo) ListVaultItemsByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultItem, error) {
+	return m.items, nil
+}
+func (m *mockVaultRepo) GetVaultItemByIDForOwner(ctx context.Context, itemID string, ownerUserID string) (domain.VaultItem, error) {
+	for _, item := range m.items {
+		if item.ID == itemID {
+			return item, nil
+		}
+	}
+	return domain.VaultItem{}, domain.ErrNotFound
+}
+
+type mockFolderRepo struct {
+	domain.FolderRepository
+	folders []domain.VaultFolder
+}
+
+func (m *mockFolderRepo) ListFoldersByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultFolder, error) {
+	return m.folders, nil
+}
+
+type mockSharingRepo struct {
+	domain.SharingRepository
+	shares map[string][]domain.VaultShare
+}
+
+func (m *mockSharingRepo) ListSharesByItem(ctx context.Context, itemID string) ([]domain.VaultShare, error) {
+	return m.shares[itemID], nil
+}
+
+type gqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Path       []any          `json:"path"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func newHandler(cfg graphqlapi.Config) *graphqlapi.Handler {
+	folderID := "folder-1"
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	vaultRepo := &mockVaultRepo{items: []domain.VaultItem{
+		{ID: "item-1", FolderID: &folderID, AlgoVersion: "v1", Metadata: []byte(`{"kind":"login"}`), IsShared: true, Version: 2, CreatedAt: now, UpdatedAt: now},
+		{ID: "item-2", AlgoVersion: "v1", Version: 1, CreatedAt: now, UpdatedAt: now},
+	}}
+	folderRepo := &mockFolderRepo{folders: []domain.VaultFolder{{ID: folderID, NameCiphertext: []byte("name"), Nonce: []byte("n"), CreatedAt: now, UpdatedAt: now}}}
+	sharingRepo := &mockSharingRepo{shares: map[string][]domain.VaultShare{
+		"item-1": {{ItemID: "item-1", UserID: "user-2", Permissions: "read", CreatedAt: now, UpdatedAt: now}},
+	}}
+
+	return graphqlapi.NewHandler(cfg,
+		service.NewVaultService(vaultRepo, folderRepo, nil, nil),
+		service.NewFolderService(folderRepo, nil),
+		service.NewSharingService(sharingRepo, nil, vaultRepo, nil, nil),
+		slog.Default())
+}
+
+func query(t *testing.T, h *graphqlapi.Handler, body string) gqlResponse {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	session := domain.Session{ID: "session-1", UserID: "user-1", Email: "a@example.com", Name: "A"}
+	h.HandleQuery(rec, httptest.NewRequest(http.MethodPost, "/api/graphql", strings.NewReader(body)), session)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	var resp gqlResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+var defaultLimits = graphqlapi.Config{MaxDepth: 6, MaxComplexity: 1000}
+
+func TestHandleQueryResolvesNestedFields(t *testing.T) {
+	resp := query(t, newHandler(defaultLimits), `{"query":"query Dashboard { viewer { email session { id } } folders { id itemCount items { id } } vaultItems(first: 1) { id metadata folder { id } shares { recipientId } } }"}`)
+	if len(resp.Errors) > 0 {
+		t.Fatalf("errors = %+v", resp.Errors)
+	}
+	want := `{"viewer":{"email":"a@example.com","session":{"id":"session-1"}},` +
+		`"folders":[{"id":"folder-1","itemCount":1,"items":[{"id":"item-1"}]}],` +
+		`"vaultItems":[{"id":"item-1","metadata":{"kind":"login"},"folder":{"id":"folder-1"},"shares":[{"recipientId":"user-2"}]}]}`
+	if string(resp.Data) != want {
+		t.Fatalf("data = %s\nwant   %s", resp.Data, want)
+	}
+}
+
+func TestHandleQueryFragmentsAliasesAndVariables(t *testing.T) {
+	body := `{"query":"query($id: ID!, $skip: Boolean!) { item: vaultItem(id: $id) { ...meta version @skip(if: $skip) } missing: vaultItem(id: \"nope\") { id } } fragment meta on VaultItem { id __typename }","variables":{"id":"item-2","skip":true}}`
+	resp := query(t, newHandler(defaultLimits), body)
+	if len(resp.Errors) > 0 {
+		t.Fatalf("errors = %+v", resp.Errors)
+	}
+	if want := `{"item":{"id":"item-2","__typename":"VaultItem"},"missing":null}`; string(resp.Data) != want {
+		t.Fatalf("data = %s, want %s", resp.Data, want)
+	}
+}
+
+func TestHandleQueryEnforcesLimits(t *testing.T) {
+	h := newHandler(graphqlapi.Config{MaxDepth: 3, MaxComplexity: 50})
+
+	resp := query(t, h, `{"query":"{ folders { items { folder { id } } } }"}`)
+	if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != "query_too_complex" || !strings.Contains(resp.Errors[0].Message, "depth 4") {
+		t.Fatalf("deep query: errors = %+v", resp.Errors)
+	}
+	if string(resp.Data) != "" && string(resp.Data) != "null" {
+		t.Fatalf("deep query must not run, data = %s", resp.Data)
+	}
+
+	// 1 + 10 * (1 + 10 * 1) = 111 for the default list size of 10.
+	resp = query(t, h, `{"query":"{ folders { items { id } } }"}`)
+	if len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0].Message, "complexity 111") {
+		t.Fatalf("expensive query: errors = %+v", resp.Errors)
+	}
+
+	resp = query(t, h, `{"query":"{ vaultItems(first: 4) { id version } }"}`)
+	if len(resp.Errors) > 0 {
+		t.Fatalf("cheap query: errors = %+v", resp.Errors)
+	}
+}
+
+func TestHandleQueryReportsErrors(t *testing.T) {
+	h := newHandler(defaultLimits)
+
+	resp := query(t, h, `{"query":"{ vaultItems { ciphertext } }"}`)
+	if len(resp.Errors) == 0 || !strings.Contains(resp.Errors[0].Message, "ciphertext") {
+		t.Fatalf("unknown field: errors = %+v", resp.Errors)
+	}
+
+	resp = query(t, h, `{"query":"mutation { deleteItem(id: \"x\") }"}`)
+	if len(resp.Errors) == 0 {
+		t.Fatal("mutations must be rejected")
+	}
+
+	resp = query(t, h, `{"query":"{ vaultItems(first: 0) { id } viewer { id } }"}`)
+	if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != "invalid_argument" {
+		t.Fatalf("bad argument: errors = %+v", resp.Errors)
+	}
+	// vaultItems is non-null, so its failure nulls the whole result.
+	if string(resp.Data) != "" && string(resp.Data) != "null" {
+		t.Fatalf("data = %s, want null", resp.Data)
+	}
+
+	rec := httptest.NewRecorder()
+	h.HandleQuery(rec, httptest.NewRequest(http.MethodPost, "/api/graphql", strings.NewReader(`{"query":`)), domain.Session{UserID: "user-1"})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("malformed body: status = %d, want 400", rec.Code)
+	}
+}
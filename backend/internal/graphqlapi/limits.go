@@ -0,0 +1,90 @@
+package graphqlapi
+
+import (
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// defaultListSize is the number of elements a list field is assumed to
+// return when it has no "first" argument.
+const defaultListSize = 10
+
+// checkLimits rejects operations nested deeper than MaxDepth or estimated
+// to cost more than MaxComplexity. Fragments are expanded, so spreading the
+// same fragment many times costs as much as writing it out.
+func (h *Handler) checkLimits(op *ast.OperationDefinition, vars map[string]any) *gqlerror.Error {
+	if depth := selectionDepth(op.SelectionSet); depth > h.cfg.MaxDepth {
+		return limitError("query depth %d exceeds the limit of %d", depth, h.cfg.MaxDepth)
+	}
+	if cost := selectionCost(op.SelectionSet, vars); cost > h.cfg.MaxComplexity {
+		return limitError("query complexity %d exceeds the limit of %d", cost, h.cfg.MaxComplexity)
+	}
+	return nil
+}
+
+func limitError(format string, args ...any) *gqlerror.Error {
+	err := gqlerror.Errorf(format, args...)
+	err.Extensions = map[string]any{"code": "query_too_complex"}
+	return err
+}
+
+func selectionDepth(set ast.SelectionSet) int {
+	deepest := 0
+	for _, sel := range set {
+		var depth int
+		switch sel := sel.(type) {
+		case *ast.Field:
+			depth = 1 + selectionDepth(sel.SelectionSet)
+		case *ast.InlineFragment:
+			depth = selectionDepth(sel.SelectionSet)
+		case *ast.FragmentSpread:
+			depth = selectionDepth(sel.Definition.SelectionSet)
+		}
+		deepest = max(deepest, depth)
+	}
+	return deepest
+}
+
+// selectionCost charges one per field; a list field charges its selection
+// once per expected element.
+func selectionCost(set ast.SelectionSet, vars map[string]any) int {
+	total := 0
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *ast.Field:
+			if sel.Name == "__typename" {
+				continue
+			}
+			children := selectionCost(sel.SelectionSet, vars)
+			if sel.Definition != nil && sel.Definition.Type.Elem != nil {
+				size := defaultListSize
+				if first, ok := intArg(sel.ArgumentMap(vars), "first"); ok {
+					// Out-of-range values fail in the resolver; clamping
+					// keeps them from lowering or overflowing the estimate.
+					size = min(max(first, 0), maxPageSize)
+				}
+				children *= size
+			}
+			total += 1 + children
+		case *ast.InlineFragment:
+			total += selectionCost(sel.SelectionSet, vars)
+		case *ast.FragmentSpread:
+			total += selectionCost(sel.Definition.SelectionSet, vars)
+		}
+	}
+	return total
+}
+
+// intArg reads an Int argument. Literals arrive as int64, variables as
+// whatever the JSON decoder produced.
+func intArg(args map[string]any, name string) (int, bool) {
+	switch v := args[name].(type) {
+	case int64:
+		return int(v), true
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
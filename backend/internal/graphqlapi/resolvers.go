@@ -0,0 +1,300 @@
+package graphqlapi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"pmv2/backend/internal/domain"
+)
+
+// maxPageSize is the largest "first" a list field accepts.
+const maxPageSize = 500
+
+// argumentError is reported to the client verbatim.
+type argumentError string
+
+func (e argumentError) Error() string { return string(e) }
+
+// request holds the state of one query. Items and folders are loaded at
+// most once, however many fields need them.
+type request struct {
+	ctx     context.Context
+	h       *Handler
+	session domain.Session
+
+	items   []domain.VaultItem
+	folders []domain.VaultFolder
+	loaded  bool
+}
+
+// resolve returns field of parent, a value of the GraphQL type typeName.
+// Objects are returned as domain values and completed by the executor.
+func (q *request) resolve(typeName, field string, parent any, args map[string]any) (any, error) {
+	switch typeName {
+	case "Query":
+		return q.query(field, args)
+	case "Viewer":
+		return viewerField(parent.(domain.Session), field)
+	case "Session":
+		return sessionField(parent.(domain.Session), field)
+	case "VaultItem":
+		return q.vaultItemField(parent.(domain.VaultItem), field)
+	case "Folder":
+		return q.folderField(parent.(domain.VaultFolder), field)
+	case "Share":
+		return shareField(parent.(domain.VaultShare), field)
+	case "SharedItem":
+		return sharedItemField(parent.(domain.SharedVaultItem), field)
+	case "SentShare":
+		return sentShareField(parent.(domain.SentShare), field)
+	}
+	return nil, fmt.Errorf("no resolver for type %s", typeName)
+}
+
+func (q *request) query(field string, args map[string]any) (any, error) {
+	userID := q.session.UserID
+	switch field {
+	case "viewer":
+		return q.session, nil
+	case "vaultItems":
+		first, _ := intArg(args, "first")
+		if first < 1 || first > maxPageSize {
+			return nil, argumentError(fmt.Sprintf("first must be between 1 and %d", maxPageSize))
+		}
+		items, _, err := q.load()
+		if err != nil {
+			return nil, err
+		}
+		if folderID, ok := args["folderId"].(string); ok {
+			items = itemsInFolder(items, folderID)
+		}
+		return items[:min(first, len(items))], nil
+	case "vaultItem":
+		item, err := q.h.vault.GetItem(q.ctx, userID, args["id"].(string))
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return item, nil
+	case "folders":
+		_, folders, err := q.load()
+		return folders, err
+	case "sharedWithMe":
+		return q.h.sharing.ListSharedWithMe(q.ctx, userID)
+	case "sentShares":
+		return q.h.sharing.ListSentShares(q.ctx, userID)
+	}
+	return nil, fmt.Errorf("no resolver for Query.%s", field)
+}
+
+func (q *request) load() ([]domain.VaultItem, []domain.VaultFolder, error) {
+	if q.loaded {
+		return q.items, q.folders, nil
+	}
+	items, err := q.h.vault.ListItems(q.ctx, q.session.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	folders, err := q.h.folders.ListFolders(q.ctx, q.session.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	q.items, q.folders, q.loaded = items, folders, true
+	return items, folders, nil
+}
+
+func itemsInFolder(items []domain.VaultItem, folderID string) []domain.VaultItem {
+	var out []domain.VaultItem
+	for _, item := range items {
+		if item.FolderID != nil && *item.FolderID == folderID {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func viewerField(session domain.Session, field string) (any, error) {
+	switch field {
+	case "id":
+		return session.UserID, nil
+	case "email":
+		return session.Email, nil
+	case "name":
+		return session.Name, nil
+	case "totpEnabled":
+		return session.TOTPEnabled, nil
+	case "session":
+		return session, nil
+	}
+	return nil, fmt.Errorf("no resolver for Viewer.%s", field)
+}
+
+func sessionField(session domain.Session, field string) (any, error) {
+	switch field {
+	case "id":
+		return session.ID, nil
+	case "expiresAt":
+		return session.ExpiresAt, nil
+	}
+	return nil, fmt.Errorf("no resolver for Session.%s", field)
+}
+
+func (q *request) vaultItemField(item domain.VaultItem, field string) (any, error) {
+	switch field {
+	case "id":
+		return item.ID, nil
+	case "folderId":
+		return item.FolderID, nil
+	case "folder":
+		if item.FolderID == nil {
+			return nil, nil
+		}
+		_, folders, err := q.load()
+		if err != nil {
+			return nil, err
+		}
+		for _, folder := range folders {
+			if folder.ID == *item.FolderID {
+				return folder, nil
+			}
+		}
+		return nil, nil
+	case "algoVersion":
+		return item.AlgoVersion, nil
+	case "metadata":
+		return metadataValue(item.Metadata), nil
+	case "isShared":
+		return item.IsShared, nil
+	case "version":
+		return item.Version, nil
+	case "createdAt":
+		return item.CreatedAt, nil
+	case "updatedAt":
+		return item.UpdatedAt, nil
+	case "shares":
+		return q.h.sharing.ListSharesForItem(q.ctx, q.session.UserID, item.ID)
+	}
+	return nil, fmt.Errorf("no resolver for VaultItem.%s", field)
+}
+
+func (q *request) folderField(folder domain.VaultFolder, field string) (any, error) {
+	switch field {
+	case "id":
+		return folder.ID, nil
+	case "nameCiphertext":
+		return base64.StdEncoding.EncodeToString(folder.NameCiphertext), nil
+	case "nonce":
+		return base64.StdEncoding.EncodeToString(folder.Nonce), nil
+	case "createdAt":
+		return folder.CreatedAt, nil
+	case "updatedAt":
+		return folder.UpdatedAt, nil
+	case "items", "itemCount":
+		items, _, err := q.load()
+		if err != nil {
+			return nil, err
+		}
+		items = itemsInFolder(items, folder.ID)
+		if field == "itemCount" {
+			return len(items), nil
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("no resolver for Folder.%s", field)
+}
+
+func shareField(share domain.VaultShare, field string) (any, error) {
+	switch field {
+	case "recipientId":
+		return share.UserID, nil
+	case "permissions":
+		return share.Permissions, nil
+	case "createdAt":
+		return share.CreatedAt, nil
+	case "updatedAt":
+		return share.UpdatedAt, nil
+	}
+	return nil, fmt.Errorf("no resolver for Share.%s", field)
+}
+
+func sharedItemField(item domain.SharedVaultItem, field string) (any, error) {
+	switch field {
+	case "id":
+		return item.ID, nil
+	case "algoVersion":
+		return item.AlgoVersion, nil
+	case "metadata":
+		return metadataValue(item.Metadata), nil
+	case "version":
+		return item.Version, nil
+	case "updatedAt":
+		return item.UpdatedAt, nil
+	case "permissions":
+		return item.Permissions, nil
+	case "sharedById":
+		return item.SharedByUserID, nil
+	case "sharedByEmail":
+		return item.SharedByEmail, nil
+	case "sharedByName":
+		return item.SharedByName, nil
+	}
+	return nil, fmt.Errorf("no resolver for SharedItem.%s", field)
+}
+
+func sentShareField(share domain.SentShare, field string) (any, error) {
+	switch field {
+	case "itemId":
+		return share.ItemID, nil
+	case "itemTitle":
+		return share.ItemTitle, nil
+	case "recipientId":
+		return share.RecipientID, nil
+	case "recipientEmail":
+		return share.RecipientEmail, nil
+	case "recipientName":
+		return share.RecipientName, nil
+	case "permissions":
+		return share.Permissions, nil
+	case "createdAt":
+		return share.CreatedAt, nil
+	}
+	return nil, fmt.Errorf("no resolver for SentShare.%s", field)
+}
+
+func metadataValue(raw []byte) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.RawMessage(raw)
+}
+
+// fieldError turns a resolver error into a GraphQL error. Unexpected errors
+// are logged and reported without detail, as the REST handlers do.
+func (q *request) fieldError(path ast.Path, err error) *gqlerror.Error {
+	var argErr argumentError
+	var domainErr *domain.Error
+	var code, message string
+	switch {
+	case errors.As(err, &argErr):
+		code, message = "invalid_argument", argErr.Error()
+	case errors.Is(err, domain.ErrNotItemOwner):
+		code, message = "forbidden", err.Error()
+	case errors.As(err, &domainErr) && domainErr.Kind != domain.KindInternal:
+		code, message = domainErr.Code, domainErr.Message
+	default:
+		q.h.log.ErrorContext(q.ctx, "graphql field failed", slog.String("path", path.String()), slog.Any("error", err))
+		code, message = "internal_error", "internal error"
+	}
+	gqlErr := gqlerror.ErrorPathf(path, "%s", message)
+	gqlErr.Extensions = map[string]any{"code": code}
+	return gqlErr
+}
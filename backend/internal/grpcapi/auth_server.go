@@ -0,0 +1,64 @@
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/grpcapi/pb"
+	"pmv2/backend/internal/service"
+	"pmv2/backend/internal/util"
+)
+
+type authServer struct {
+	pb.UnimplementedAuthServiceServer
+	auth *service.AuthService
+	log  *slog.Logger
+}
+
+func (s *authServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	meta := util.RequestMetaFromContext(ctx)
+	output, err := s.auth.Login(ctx, domain.LoginInput{
+		Email:        req.GetEmail(),
+		Password:     req.GetPassword(),
+		TOTPCode:     req.GetTotpCode(),
+		RecoveryCode: req.GetRecoveryCode(),
+		DeviceName:   req.GetDeviceName(),
+		IPAddr:       meta.IPAddress,
+		UserAgent:    meta.UserAgent,
+	})
+	if err != nil {
+		return nil, statusError(ctx, s.log, err, "login failed")
+	}
+
+	return &pb.LoginResponse{
+		SessionToken: output.SessionToken,
+		ExpiresAt:    timestamppb.New(output.ExpiresAt),
+		User: &pb.User{
+			UserId:      output.UserID,
+			Email:       output.Email,
+			Name:        output.Name,
+			TotpEnabled: output.TOTPEnabled,
+		},
+	}, nil
+}
+
+func (s *authServer) Logout(ctx context.Context, _ *pb.LogoutRequest) (*pb.LogoutResponse, error) {
+	token, _ := ctx.Value(tokenKey{}).(string)
+	if err := s.auth.Logout(ctx, token); err != nil {
+		return nil, statusError(ctx, s.log, err, "logout failed")
+	}
+	return &pb.LogoutResponse{}, nil
+}
+
+func (s *authServer) Me(ctx context.Context, _ *pb.MeRequest) (*pb.User, error) {
+	session := sessionFromContext(ctx)
+	return &pb.User{
+		UserId:      session.UserID,
+		Email:       session.Email,
+		Name:        session.Name,
+		TotpEnabled: session.TOTPEnabled,
+	}, nil
+}
@@ -0,0 +1,36 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"pmv2/backend/internal/domain"
+)
+
+// statusError maps a service error to a gRPC status by its domain.Error
+// kind, as the REST controllers do for HTTP statuses. Unexpected errors are
+// logged and reported as Internal with message, without their details.
+func statusError(ctx context.Context, log *slog.Logger, err error, message string) error {
+	if e, ok := domain.AsError(err); ok && e.Kind != domain.KindInternal {
+		return status.Error(kindCodes[e.Kind], e.Message)
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	log.ErrorContext(ctx, message, slog.Any("error", err))
+	return status.Error(codes.Internal, message)
+}
+
+var kindCodes = map[domain.ErrorKind]codes.Code{
+	domain.KindInvalid:      codes.InvalidArgument,
+	domain.KindUnauthorized: codes.Unauthenticated,
+	domain.KindForbidden:    codes.PermissionDenied,
+	domain.KindNotFound:     codes.NotFound,
+	domain.KindConflict:     codes.AlreadyExists,
+	domain.KindRateLimited:  codes.ResourceExhausted,
+	domain.KindUnavailable:  codes.Unavailable,
+}
@@ -0,0 +1,117 @@
+package grpcapi
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/grpcapi/pb"
+	"pmv2/backend/internal/service"
+	"pmv2/backend/internal/util"
+)
+
+// publicMethods can be called without a session token.
+var publicMethods = map[string]bool{
+	pb.AuthService_Login_FullMethodName: true,
+}
+
+type sessionKey struct{}
+type tokenKey struct{}
+
+// sessionFromContext returns the session the interceptor authenticated.
+func sessionFromContext(ctx context.Context) domain.Session {
+	session, _ := ctx.Value(sessionKey{}).(domain.Session)
+	return session
+}
+
+// sessionInterceptor is the gRPC counterpart of WithRequestMeta and
+// AuthMiddleware.WithSession: it records the caller's address and user
+// agent, then resolves the "authorization: Bearer <token>" metadata to a
+// session for every non-public method.
+type sessionInterceptor struct {
+	auth *service.AuthService
+}
+
+func (i *sessionInterceptor) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := i.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (i *sessionInterceptor) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := i.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+func (i *sessionInterceptor) authenticate(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	// A gRPC call is a POST to the method's path, which is what a DPoP
+	// proof in the "dpop" metadata must name.
+	meta := util.RequestMeta{
+		UserAgent: firstValue(md, "user-agent"),
+		Method:    "POST",
+		Path:      method,
+		DPoPProof: firstValue(md, "dpop"),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		meta.IPAddress = util.NormalizeIP(p.Addr.String())
+	}
+
+	if publicMethods[method] {
+		return util.WithRequestMeta(ctx, meta), nil
+	}
+
+	token := util.BearerToken(firstValue(md, "authorization"))
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing session token")
+	}
+	// Session binding and DPoP check the session against the caller
+	// recorded here.
+	session, err := i.auth.Authenticate(util.WithRequestMeta(ctx, meta), token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid or expired session")
+	}
+	// gRPC has no way to step up; the client completes it over REST.
+	if session.StepUpRequired && session.TOTPEnabled {
+		return nil, status.Error(codes.PermissionDenied, domain.ErrStepUpRequired.Message)
+	}
+	// Nor to reset compromised credentials.
+	if session.CredentialResetRequired {
+		return nil, status.Error(codes.PermissionDenied, domain.ErrCredentialResetRequired.Message)
+	}
+
+	meta.ActorUserID = session.UserID
+	meta.SessionID = session.ID
+	meta.DeviceID = session.DeviceID
+	ctx = util.WithRequestMeta(ctx, meta)
+	ctx = context.WithValue(ctx, sessionKey{}, session)
+	return context.WithValue(ctx, tokenKey{}, token), nil
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return strings.TrimSpace(values[0])
+	}
+	return ""
+}
+
+// contextStream replaces a stream's context with the authenticated one.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
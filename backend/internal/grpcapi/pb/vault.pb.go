@@ -0,0 +1,998 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.3
+// source: pmv2/v1/vault.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type VaultItem struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	FolderId      *string                `protobuf:"bytes,2,opt,name=folder_id,json=folderId,proto3,oneof" json:"folder_id,omitempty"`
+	Ciphertext    []byte                 `protobuf:"bytes,3,opt,name=ciphertext,proto3" json:"ciphertext,omitempty"`
+	Nonce         []byte                 `protobuf:"bytes,4,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	WrappedDek    []byte                 `protobuf:"bytes,5,opt,name=wrapped_dek,json=wrappedDek,proto3" json:"wrapped_dek,omitempty"`
+	WrapNonce     []byte                 `protobuf:"bytes,6,opt,name=wrap_nonce,json=wrapNonce,proto3" json:"wrap_nonce,omitempty"`
+	AlgoVersion   string                 `protobuf:"bytes,7,opt,name=algo_version,json=algoVersion,proto3" json:"algo_version,omitempty"`
+	Metadata      []byte                 `protobuf:"bytes,8,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	IsShared      bool                   `protobuf:"varint,9,opt,name=is_shared,json=isShared,proto3" json:"is_shared,omitempty"`
+	Version       int32                  `protobuf:"varint,10,opt,name=version,proto3" json:"version,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,12,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VaultItem) Reset() {
+	*x = VaultItem{}
+	mi := &file_pmv2_v1_vault_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VaultItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VaultItem) ProtoMessage() {}
+
+func (x *VaultItem) ProtoReflect() protoreflect.Message {
+	mi := &file_pmv2_v1_vault_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VaultItem.ProtoReflect.Descriptor instead.
+func (*VaultItem) Descriptor() ([]byte, []int) {
+	return file_pmv2_v1_vault_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *VaultItem) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *VaultItem) GetFolderId() string {
+	if x != nil && x.FolderId != nil {
+		return *x.FolderId
+	}
+	return ""
+}
+
+func (x *VaultItem) GetCiphertext() []byte {
+	if x != nil {
+		return x.Ciphertext
+	}
+	return nil
+}
+
+func (x *VaultItem) GetNonce() []byte {
+	if x != nil {
+		return x.Nonce
+	}
+	return nil
+}
+
+func (x *VaultItem) GetWrappedDek() []byte {
+	if x != nil {
+		return x.WrappedDek
+	}
+	return nil
+}
+
+func (x *VaultItem) GetWrapNonce() []byte {
+	if x != nil {
+		return x.WrapNonce
+	}
+	return nil
+}
+
+func (x *VaultItem) GetAlgoVersion() string {
+	if x != nil {
+		return x.AlgoVersion
+	}
+	return ""
+}
+
+func (x *VaultItem) GetMetadata() []byte {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *VaultItem) GetIsShared() bool {
+	if x != nil {
+		return x.IsShared
+	}
+	return false
+}
+
+func (x *VaultItem) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *VaultItem) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *VaultItem) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+// ItemPayload is the client-supplied part of an item. ciphertext, nonce,
+// wrapped_dek and wrap_nonce are required; metadata, when set, is JSON.
+type ItemPayload struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FolderId      *string                `protobuf:"bytes,1,opt,name=folder_id,json=folderId,proto3,oneof" json:"folder_id,omitempty"`
+	Ciphertext    []byte                 `protobuf:"bytes,2,opt,name=ciphertext,proto3" json:"ciphertext,omitempty"`
+	Nonce         []byte                 `protobuf:"bytes,3,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	WrappedDek    []byte                 `protobuf:"bytes,4,opt,name=wrapped_dek,json=wrappedDek,proto3" json:"wrapped_dek,omitempty"`
+	WrapNonce     []byte                 `protobuf:"bytes,5,opt,name=wrap_nonce,json=wrapNonce,proto3" json:"wrap_nonce,omitempty"`
+	AlgoVersion   string                 `protobuf:"bytes,6,opt,name=algo_version,json=algoVersion,proto3" json:"algo_version,omitempty"`
+	Metadata      []byte                 `protobuf:"bytes,7,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ItemPayload) Reset() {
+	*x = ItemPayload{}
+	mi := &file_pmv2_v1_vault_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ItemPayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ItemPayload) ProtoMessage() {}
+
+func (x *ItemPayload) ProtoReflect() protoreflect.Message {
+	mi := &file_pmv2_v1_vault_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ItemPayload.ProtoReflect.Descriptor instead.
+func (*ItemPayload) Descriptor() ([]byte, []int) {
+	return file_pmv2_v1_vault_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ItemPayload) GetFolderId() string {
+	if x != nil && x.FolderId != nil {
+		return *x.FolderId
+	}
+	return ""
+}
+
+func (x *ItemPayload) GetCiphertext() []byte {
+	if x != nil {
+		return x.Ciphertext
+	}
+	return nil
+}
+
+func (x *ItemPayload) GetNonce() []byte {
+	if x != nil {
+		return x.Nonce
+	}
+	return nil
+}
+
+func (x *ItemPayload) GetWrappedDek() []byte {
+	if x != nil {
+		return x.WrappedDek
+	}
+	return nil
+}
+
+func (x *ItemPayload) GetWrapNonce() []byte {
+	if x != nil {
+		return x.WrapNonce
+	}
+	return nil
+}
+
+func (x *ItemPayload) GetAlgoVersion() string {
+	if x != nil {
+		return x.AlgoVersion
+	}
+	return ""
+}
+
+func (x *ItemPayload) GetMetadata() []byte {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+type VaultFolder struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Id             string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	NameCiphertext []byte                 `protobuf:"bytes,2,opt,name=name_ciphertext,json=nameCiphertext,proto3" json:"name_ciphertext,omitempty"`
+	Nonce          []byte                 `protobuf:"bytes,3,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	CreatedAt      *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt      *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *VaultFolder) Reset() {
+	*x = VaultFolder{}
+	mi := &file_pmv2_v1_vault_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VaultFolder) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VaultFolder) ProtoMessage() {}
+
+func (x *VaultFolder) ProtoReflect() protoreflect.Message {
+	mi := &file_pmv2_v1_vault_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VaultFolder.ProtoReflect.Descriptor instead.
+func (*VaultFolder) Descriptor() ([]byte, []int) {
+	return file_pmv2_v1_vault_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *VaultFolder) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *VaultFolder) GetNameCiphertext() []byte {
+	if x != nil {
+		return x.NameCiphertext
+	}
+	return nil
+}
+
+func (x *VaultFolder) GetNonce() []byte {
+	if x != nil {
+		return x.Nonce
+	}
+	return nil
+}
+
+func (x *VaultFolder) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *VaultFolder) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type ListItemsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListItemsRequest) Reset() {
+	*x = ListItemsRequest{}
+	mi := &file_pmv2_v1_vault_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListItemsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListItemsRequest) ProtoMessage() {}
+
+func (x *ListItemsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pmv2_v1_vault_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListItemsRequest.ProtoReflect.Descriptor instead.
+func (*ListItemsRequest) Descriptor() ([]byte, []int) {
+	return file_pmv2_v1_vault_proto_rawDescGZIP(), []int{3}
+}
+
+type ListItemsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Items         []*VaultItem           `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListItemsResponse) Reset() {
+	*x = ListItemsResponse{}
+	mi := &file_pmv2_v1_vault_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListItemsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListItemsResponse) ProtoMessage() {}
+
+func (x *ListItemsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pmv2_v1_vault_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListItemsResponse.ProtoReflect.Descriptor instead.
+func (*ListItemsResponse) Descriptor() ([]byte, []int) {
+	return file_pmv2_v1_vault_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListItemsResponse) GetItems() []*VaultItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+type GetItemRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetItemRequest) Reset() {
+	*x = GetItemRequest{}
+	mi := &file_pmv2_v1_vault_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetItemRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetItemRequest) ProtoMessage() {}
+
+func (x *GetItemRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pmv2_v1_vault_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetItemRequest.ProtoReflect.Descriptor instead.
+func (*GetItemRequest) Descriptor() ([]byte, []int) {
+	return file_pmv2_v1_vault_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetItemRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type CreateItemRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Item          *ItemPayload           `protobuf:"bytes,1,opt,name=item,proto3" json:"item,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateItemRequest) Reset() {
+	*x = CreateItemRequest{}
+	mi := &file_pmv2_v1_vault_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateItemRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateItemRequest) ProtoMessage() {}
+
+func (x *CreateItemRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pmv2_v1_vault_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateItemRequest.ProtoReflect.Descriptor instead.
+func (*CreateItemRequest) Descriptor() ([]byte, []int) {
+	return file_pmv2_v1_vault_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CreateItemRequest) GetItem() *ItemPayload {
+	if x != nil {
+		return x.Item
+	}
+	return nil
+}
+
+type UpdateItemRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Item          *ItemPayload           `protobuf:"bytes,2,opt,name=item,proto3" json:"item,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateItemRequest) Reset() {
+	*x = UpdateItemRequest{}
+	mi := &file_pmv2_v1_vault_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateItemRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateItemRequest) ProtoMessage() {}
+
+func (x *UpdateItemRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pmv2_v1_vault_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateItemRequest.ProtoReflect.Descriptor instead.
+func (*UpdateItemRequest) Descriptor() ([]byte, []int) {
+	return file_pmv2_v1_vault_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *UpdateItemRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *UpdateItemRequest) GetItem() *ItemPayload {
+	if x != nil {
+		return x.Item
+	}
+	return nil
+}
+
+type DeleteItemRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteItemRequest) Reset() {
+	*x = DeleteItemRequest{}
+	mi := &file_pmv2_v1_vault_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteItemRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteItemRequest) ProtoMessage() {}
+
+func (x *DeleteItemRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pmv2_v1_vault_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteItemRequest.ProtoReflect.Descriptor instead.
+func (*DeleteItemRequest) Descriptor() ([]byte, []int) {
+	return file_pmv2_v1_vault_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DeleteItemRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type DeleteItemResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteItemResponse) Reset() {
+	*x = DeleteItemResponse{}
+	mi := &file_pmv2_v1_vault_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteItemResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteItemResponse) ProtoMessage() {}
+
+func (x *DeleteItemResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pmv2_v1_vault_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteItemResponse.ProtoReflect.Descriptor instead.
+func (*DeleteItemResponse) Descriptor() ([]byte, []int) {
+	return file_pmv2_v1_vault_proto_rawDescGZIP(), []int{9}
+}
+
+type SyncRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The revision the client already holds, if any.
+	IfNoneMatch   string `protobuf:"bytes,1,opt,name=if_none_match,json=ifNoneMatch,proto3" json:"if_none_match,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncRequest) Reset() {
+	*x = SyncRequest{}
+	mi := &file_pmv2_v1_vault_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncRequest) ProtoMessage() {}
+
+func (x *SyncRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pmv2_v1_vault_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncRequest.ProtoReflect.Descriptor instead.
+func (*SyncRequest) Descriptor() ([]byte, []int) {
+	return file_pmv2_v1_vault_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SyncRequest) GetIfNoneMatch() string {
+	if x != nil {
+		return x.IfNoneMatch
+	}
+	return ""
+}
+
+type SyncResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Revision      string                 `protobuf:"bytes,1,opt,name=revision,proto3" json:"revision,omitempty"`
+	NotModified   bool                   `protobuf:"varint,2,opt,name=not_modified,json=notModified,proto3" json:"not_modified,omitempty"`
+	Folders       []*VaultFolder         `protobuf:"bytes,3,rep,name=folders,proto3" json:"folders,omitempty"`
+	Items         []*VaultItem           `protobuf:"bytes,4,rep,name=items,proto3" json:"items,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncResponse) Reset() {
+	*x = SyncResponse{}
+	mi := &file_pmv2_v1_vault_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncResponse) ProtoMessage() {}
+
+func (x *SyncResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pmv2_v1_vault_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncResponse.ProtoReflect.Descriptor instead.
+func (*SyncResponse) Descriptor() ([]byte, []int) {
+	return file_pmv2_v1_vault_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *SyncResponse) GetRevision() string {
+	if x != nil {
+		return x.Revision
+	}
+	return ""
+}
+
+func (x *SyncResponse) GetNotModified() bool {
+	if x != nil {
+		return x.NotModified
+	}
+	return false
+}
+
+func (x *SyncResponse) GetFolders() []*VaultFolder {
+	if x != nil {
+		return x.Folders
+	}
+	return nil
+}
+
+func (x *SyncResponse) GetItems() []*VaultItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+type WatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Revision      string                 `protobuf:"bytes,1,opt,name=revision,proto3" json:"revision,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_pmv2_v1_vault_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pmv2_v1_vault_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_pmv2_v1_vault_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *WatchRequest) GetRevision() string {
+	if x != nil {
+		return x.Revision
+	}
+	return ""
+}
+
+type VaultChange struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Revision      string                 `protobuf:"bytes,1,opt,name=revision,proto3" json:"revision,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VaultChange) Reset() {
+	*x = VaultChange{}
+	mi := &file_pmv2_v1_vault_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VaultChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VaultChange) ProtoMessage() {}
+
+func (x *VaultChange) ProtoReflect() protoreflect.Message {
+	mi := &file_pmv2_v1_vault_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VaultChange.ProtoReflect.Descriptor instead.
+func (*VaultChange) Descriptor() ([]byte, []int) {
+	return file_pmv2_v1_vault_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *VaultChange) GetRevision() string {
+	if x != nil {
+		return x.Revision
+	}
+	return ""
+}
+
+var File_pmv2_v1_vault_proto protoreflect.FileDescriptor
+
+const file_pmv2_v1_vault_proto_rawDesc = "" +
+	"\n" +
+	"\x13pmv2/v1/vault.proto\x12\apmv2.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xad\x03\n" +
+	"\tVaultItem\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12 \n" +
+	"\tfolder_id\x18\x02 \x01(\tH\x00R\bfolderId\x88\x01\x01\x12\x1e\n" +
+	"\n" +
+	"ciphertext\x18\x03 \x01(\fR\n" +
+	"ciphertext\x12\x14\n" +
+	"\x05nonce\x18\x04 \x01(\fR\x05nonce\x12\x1f\n" +
+	"\vwrapped_dek\x18\x05 \x01(\fR\n" +
+	"wrappedDek\x12\x1d\n" +
+	"\n" +
+	"wrap_nonce\x18\x06 \x01(\fR\twrapNonce\x12!\n" +
+	"\falgo_version\x18\a \x01(\tR\valgoVersion\x12\x1a\n" +
+	"\bmetadata\x18\b \x01(\fR\bmetadata\x12\x1b\n" +
+	"\tis_shared\x18\t \x01(\bR\bisShared\x12\x18\n" +
+	"\aversion\x18\n" +
+	" \x01(\x05R\aversion\x129\n" +
+	"\n" +
+	"created_at\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\f \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAtB\f\n" +
+	"\n" +
+	"_folder_id\"\xf2\x01\n" +
+	"\vItemPayload\x12 \n" +
+	"\tfolder_id\x18\x01 \x01(\tH\x00R\bfolderId\x88\x01\x01\x12\x1e\n" +
+	"\n" +
+	"ciphertext\x18\x02 \x01(\fR\n" +
+	"ciphertext\x12\x14\n" +
+	"\x05nonce\x18\x03 \x01(\fR\x05nonce\x12\x1f\n" +
+	"\vwrapped_dek\x18\x04 \x01(\fR\n" +
+	"wrappedDek\x12\x1d\n" +
+	"\n" +
+	"wrap_nonce\x18\x05 \x01(\fR\twrapNonce\x12!\n" +
+	"\falgo_version\x18\x06 \x01(\tR\valgoVersion\x12\x1a\n" +
+	"\bmetadata\x18\a \x01(\fR\bmetadataB\f\n" +
+	"\n" +
+	"_folder_id\"\xd2\x01\n" +
+	"\vVaultFolder\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12'\n" +
+	"\x0fname_ciphertext\x18\x02 \x01(\fR\x0enameCiphertext\x12\x14\n" +
+	"\x05nonce\x18\x03 \x01(\fR\x05nonce\x129\n" +
+	"\n" +
+	"created_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\"\x12\n" +
+	"\x10ListItemsRequest\"=\n" +
+	"\x11ListItemsResponse\x12(\n" +
+	"\x05items\x18\x01 \x03(\v2\x12.pmv2.v1.VaultItemR\x05items\" \n" +
+	"\x0eGetItemRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"=\n" +
+	"\x11CreateItemRequest\x12(\n" +
+	"\x04item\x18\x01 \x01(\v2\x14.pmv2.v1.ItemPayloadR\x04item\"M\n" +
+	"\x11UpdateItemRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12(\n" +
+	"\x04item\x18\x02 \x01(\v2\x14.pmv2.v1.ItemPayloadR\x04item\"#\n" +
+	"\x11DeleteItemRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x14\n" +
+	"\x12DeleteItemResponse\"1\n" +
+	"\vSyncRequest\x12\"\n" +
+	"\rif_none_match\x18\x01 \x01(\tR\vifNoneMatch\"\xa7\x01\n" +
+	"\fSyncResponse\x12\x1a\n" +
+	"\brevision\x18\x01 \x01(\tR\brevision\x12!\n" +
+	"\fnot_modified\x18\x02 \x01(\bR\vnotModified\x12.\n" +
+	"\afolders\x18\x03 \x03(\v2\x14.pmv2.v1.VaultFolderR\afolders\x12(\n" +
+	"\x05items\x18\x04 \x03(\v2\x12.pmv2.v1.VaultItemR\x05items\"*\n" +
+	"\fWatchRequest\x12\x1a\n" +
+	"\brevision\x18\x01 \x01(\tR\brevision\")\n" +
+	"\vVaultChange\x12\x1a\n" +
+	"\brevision\x18\x01 \x01(\tR\brevision2\xbc\x03\n" +
+	"\fVaultService\x12B\n" +
+	"\tListItems\x12\x19.pmv2.v1.ListItemsRequest\x1a\x1a.pmv2.v1.ListItemsResponse\x126\n" +
+	"\aGetItem\x12\x17.pmv2.v1.GetItemRequest\x1a\x12.pmv2.v1.VaultItem\x12<\n" +
+	"\n" +
+	"CreateItem\x12\x1a.pmv2.v1.CreateItemRequest\x1a\x12.pmv2.v1.VaultItem\x12<\n" +
+	"\n" +
+	"UpdateItem\x12\x1a.pmv2.v1.UpdateItemRequest\x1a\x12.pmv2.v1.VaultItem\x12E\n" +
+	"\n" +
+	"DeleteItem\x12\x1a.pmv2.v1.DeleteItemRequest\x1a\x1b.pmv2.v1.DeleteItemResponse\x125\n" +
+	"\x04Sync\x12\x14.pmv2.v1.SyncRequest\x1a\x15.pmv2.v1.SyncResponse0\x01\x126\n" +
+	"\x05Watch\x12\x15.pmv2.v1.WatchRequest\x1a\x14.pmv2.v1.VaultChange0\x01B%Z#pmv2/backend/internal/grpcapi/pb;pbb\x06proto3"
+
+var (
+	file_pmv2_v1_vault_proto_rawDescOnce sync.Once
+	file_pmv2_v1_vault_proto_rawDescData []byte
+)
+
+func file_pmv2_v1_vault_proto_rawDescGZIP() []byte {
+	file_pmv2_v1_vault_proto_rawDescOnce.Do(func() {
+		file_pmv2_v1_vault_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pmv2_v1_vault_proto_rawDesc), len(file_pmv2_v1_vault_proto_rawDesc)))
+	})
+	return file_pmv2_v1_vault_proto_rawDescData
+}
+
+var file_pmv2_v1_vault_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_pmv2_v1_vault_proto_goTypes = []any{
+	(*VaultItem)(nil),             // 0: pmv2.v1.VaultItem
+	(*ItemPayload)(nil),           // 1: pmv2.v1.ItemPayload
+	(*VaultFolder)(nil),           // 2: pmv2.v1.VaultFolder
+	(*ListItemsRequest)(nil),      // 3: pmv2.v1.ListItemsRequest
+	(*ListItemsResponse)(nil),     // 4: pmv2.v1.ListItemsResponse
+	(*GetItemRequest)(nil),        // 5: pmv2.v1.GetItemRequest
+	(*CreateItemRequest)(nil),     // 6: pmv2.v1.CreateItemRequest
+	(*UpdateItemRequest)(nil),     // 7: pmv2.v1.UpdateItemRequest
+	(*DeleteItemRequest)(nil),     // 8: pmv2.v1.DeleteItemRequest
+	(*DeleteItemResponse)(nil),    // 9: pmv2.v1.DeleteItemResponse
+	(*SyncRequest)(nil),           // 10: pmv2.v1.SyncRequest
+	(*SyncResponse)(nil),          // 11: pmv2.v1.SyncResponse
+	(*WatchRequest)(nil),          // 12: pmv2.v1.WatchRequest
+	(*VaultChange)(nil),           // 13: pmv2.v1.VaultChange
+	(*timestamppb.Timestamp)(nil), // 14: google.protobuf.Timestamp
+}
+var file_pmv2_v1_vault_proto_depIdxs = []int32{
+	14, // 0: pmv2.v1.VaultItem.created_at:type_name -> google.protobuf.Timestamp
+	14, // 1: pmv2.v1.VaultItem.updated_at:type_name -> google.protobuf.Timestamp
+	14, // 2: pmv2.v1.VaultFolder.created_at:type_name -> google.protobuf.Timestamp
+	14, // 3: pmv2.v1.VaultFolder.updated_at:type_name -> google.protobuf.Timestamp
+	0,  // 4: pmv2.v1.ListItemsResponse.items:type_name -> pmv2.v1.VaultItem
+	1,  // 5: pmv2.v1.CreateItemRequest.item:type_name -> pmv2.v1.ItemPayload
+	1,  // 6: pmv2.v1.UpdateItemRequest.item:type_name -> pmv2.v1.ItemPayload
+	2,  // 7: pmv2.v1.SyncResponse.folders:type_name -> pmv2.v1.VaultFolder
+	0,  // 8: pmv2.v1.SyncResponse.items:type_name -> pmv2.v1.VaultItem
+	3,  // 9: pmv2.v1.VaultService.ListItems:input_type -> pmv2.v1.ListItemsRequest
+	5,  // 10: pmv2.v1.VaultService.GetItem:input_type -> pmv2.v1.GetItemRequest
+	6,  // 11: pmv2.v1.VaultService.CreateItem:input_type -> pmv2.v1.CreateItemRequest
+	7,  // 12: pmv2.v1.VaultService.UpdateItem:input_type -> pmv2.v1.UpdateItemRequest
+	8,  // 13: pmv2.v1.VaultService.DeleteItem:input_type -> pmv2.v1.DeleteItemRequest
+	10, // 14: pmv2.v1.VaultService.Sync:input_type -> pmv2.v1.SyncRequest
+	12, // 15: pmv2.v1.VaultService.Watch:input_type -> pmv2.v1.WatchRequest
+	4,  // 16: pmv2.v1.VaultService.ListItems:output_type -> pmv2.v1.ListItemsResponse
+	0,  // 17: pmv2.v1.VaultService.GetItem:output_type -> pmv2.v1.VaultItem
+	0,  // 18: pmv2.v1.VaultService.CreateItem:output_type -> pmv2.v1.VaultItem
+	0,  // 19: pmv2.v1.VaultService.UpdateItem:output_type -> pmv2.v1.VaultItem
+	9,  // 20: pmv2.v1.VaultService.DeleteItem:output_type -> pmv2.v1.DeleteItemResponse
+	11, // 21: pmv2.v1.VaultService.Sync:output_type -> pmv2.v1.SyncResponse
+	13, // 22: pmv2.v1.VaultService.Watch:output_type -> pmv2.v1.VaultChange
+	16, // [16:23] is the sub-list for method output_type
+	9,  // [9:16] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_pmv2_v1_vault_proto_init() }
+func file_pmv2_v1_vault_proto_init() {
+	if File_pmv2_v1_vault_proto != nil {
+		return
+	}
+	file_pmv2_v1_vault_proto_msgTypes[0].OneofWrappers = []any{}
+	file_pmv2_v1_vault_proto_msgTypes[1].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pmv2_v1_vault_proto_rawDesc), len(file_pmv2_v1_vault_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   14,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_pmv2_v1_vault_proto_goTypes,
+		DependencyIndexes: file_pmv2_v1_vault_proto_depIdxs,
+		MessageInfos:      file_pmv2_v1_vault_proto_msgTypes,
+	}.Build()
+	File_pmv2_v1_vault_proto = out.File
+	file_pmv2_v1_vault_proto_goTypes = nil
+	file_pmv2_v1_vault_proto_depIdxs = nil
+}
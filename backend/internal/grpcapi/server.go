@@ -0,0 +1,38 @@
+// Package grpcapi serves the gRPC API (proto/pmv2/v1) next to the REST API.
+// Both call the same services, so behaviour, validation and audit events
+// are shared; only the transport differs. Vault payloads travel as raw
+// protobuf bytes instead of base64 JSON.
+//
+// The generated code in pb is produced from proto/ with `make proto`.
+package grpcapi
+
+import (
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"pmv2/backend/internal/grpcapi/pb"
+	"pmv2/backend/internal/service"
+)
+
+type Config struct {
+	// WatchInterval is how often VaultService.Watch checks the vault
+	// revision of each watching client.
+	WatchInterval time.Duration
+}
+
+// NewServer returns a gRPC server with the auth and vault services
+// registered behind the session interceptors. opts are passed to
+// grpc.NewServer, e.g. transport credentials.
+func NewServer(cfg Config, auth *service.AuthService, vault *service.VaultService, log *slog.Logger, opts ...grpc.ServerOption) *grpc.Server {
+	interceptor := &sessionInterceptor{auth: auth}
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(interceptor.unary),
+		grpc.ChainStreamInterceptor(interceptor.stream),
+	)
+	srv := grpc.NewServer(opts...)
+	pb.RegisterAuthServiceServer(srv, &authServer{auth: auth, log: log})
+	pb.RegisterVaultServiceServer(srv, &vaultServer{vault: vault, log: log, watchInterval: cfg.WatchInterval})
+	return srv
+}
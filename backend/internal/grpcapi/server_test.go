@@ -0,0 +1,167 @@
+package grpcapi_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/grpcapi"
+	"pmv2/backend/internal/grpcapi/pb"
+	"pmv2/backend/internal/service"
+)
+
+// mockAuthRepo resolves every token to one session; any other call panics
+// on the nil embedded interface.
+type mockAuthRepo struct {
+	domain.AuthRepository
+	session domain.Session
+}
+
+func (m *mockAuthRepo) GetActiveSessionByTokenHash(ctx context.Context, tokenHash []byte) (domain.Session, error) {
+	if m.session.UserID == "" {
+		return domain.Session{}, domain.ErrNotFound
+	}
+	return m.session, nil
+}
+
+func (m *mockAuthRepo) GetRetiredSessionToken(ctx context.Context, tokenHash []byte) (domain.RetiredSessionToken, error) {
+	return domain.RetiredSessionToken{}, domain.ErrNotFound
+}
+
+func (m *mockAuthRepo) TouchSession(ctx context.Context, sessionID string, usedAt time.Time) error {
+	return nil
+}
+
+type mockVaultRepo struct {
+	domain.VaultRepository
+	revision string
+	items    []domain.VaultItem
+}
+
+func (m *mockVaultRepo) GetVaultRevision(ctx context.Context, ownerUserID string) (string, error) {
+	return m.revision, nil
+}
+func (m *mockVaultRepo) ListVaultItemsByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultItem, error) {
+	return m.items, nil
+}
+
+type mockFolderRepo struct {
+	domain.FolderRepository
+}
+
+func (m *mockFolderRepo) ListFoldersByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultFolder, error) {
+	return nil, nil
+}
+
+func dial(t *testing.T, auth *mockAuthRepo, vault *mockVaultRepo) *grpc.ClientConn {
+	t.Helper()
+	authService := service.NewAuthService(auth, nil, nil, "pepper-test", time.Hour, "issuer")
+	vaultService := service.NewVaultService(vault, &mockFolderRepo{}, nil, nil)
+	srv := grpcapi.NewServer(grpcapi.Config{WatchInterval: time.Second}, authService, vaultService, slog.Default())
+
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func withToken(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer token-1")
+}
+
+func TestMeRequiresSession(t *testing.T) {
+	conn := dial(t, &mockAuthRepo{}, &mockVaultRepo{})
+	client := pb.NewAuthServiceClient(conn)
+
+	_, err := client.Me(context.Background(), &pb.MeRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("without token: err = %v, want Unauthenticated", err)
+	}
+	_, err = client.Me(withToken(context.Background()), &pb.MeRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("unknown token: err = %v, want Unauthenticated", err)
+	}
+}
+
+func TestMeReturnsSessionUser(t *testing.T) {
+	auth := &mockAuthRepo{session: domain.Session{UserID: "user-1", Email: "a@example.com", Name: "A"}}
+	client := pb.NewAuthServiceClient(dial(t, auth, &mockVaultRepo{}))
+
+	user, err := client.Me(withToken(context.Background()), &pb.MeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.GetUserId() != "user-1" || user.GetEmail() != "a@example.com" {
+		t.Fatalf("user = %+v", user)
+	}
+}
+
+func TestSyncStreamsItemsInBatches(t *testing.T) {
+	items := make([]domain.VaultItem, 250)
+	for i := range items {
+		items[i] = domain.VaultItem{ID: fmt.Sprintf("item-%d", i), OwnerUserID: "user-1", Version: 1}
+	}
+	auth := &mockAuthRepo{session: domain.Session{UserID: "user-1"}}
+	client := pb.NewVaultServiceClient(dial(t, auth, &mockVaultRepo{revision: "rev-1", items: items}))
+	ctx := withToken(context.Background())
+
+	responses := collectSync(t, client, ctx, "")
+	// One message with the folders, then 100 + 100 + 50 items.
+	if len(responses) != 4 {
+		t.Fatalf("got %d messages, want 4", len(responses))
+	}
+	total := 0
+	for _, resp := range responses[1:] {
+		total += len(resp.GetItems())
+	}
+	if total != len(items) || len(responses[3].GetItems()) != 50 {
+		t.Fatalf("streamed %d items, last batch %d", total, len(responses[3].GetItems()))
+	}
+
+	revision := responses[0].GetRevision()
+	responses = collectSync(t, client, ctx, revision)
+	if len(responses) != 1 || !responses[0].GetNotModified() {
+		t.Fatalf("conditional sync = %+v, want a single not_modified message", responses)
+	}
+}
+
+func collectSync(t *testing.T, client pb.VaultServiceClient, ctx context.Context, ifNoneMatch string) []*pb.SyncResponse {
+	t.Helper()
+	stream, err := client.Sync(ctx, &pb.SyncRequest{IfNoneMatch: ifNoneMatch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []*pb.SyncResponse
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, resp)
+	}
+}
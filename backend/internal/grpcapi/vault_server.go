@@ -0,0 +1,185 @@
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/grpcapi/pb"
+	"pmv2/backend/internal/service"
+)
+
+// syncBatchSize bounds the items per Sync message, keeping each message well
+// under gRPC's default 4 MiB receive limit.
+const syncBatchSize = 100
+
+type vaultServer struct {
+	pb.UnimplementedVaultServiceServer
+	vault         *service.VaultService
+	log           *slog.Logger
+	watchInterval time.Duration
+}
+
+func (s *vaultServer) ListItems(ctx context.Context, _ *pb.ListItemsRequest) (*pb.ListItemsResponse, error) {
+	items, err := s.vault.ListItems(ctx, sessionFromContext(ctx).UserID)
+	if err != nil {
+		return nil, statusError(ctx, s.log, err, "failed to list vault items")
+	}
+	return &pb.ListItemsResponse{Items: vaultItemsToProto(items)}, nil
+}
+
+func (s *vaultServer) GetItem(ctx context.Context, req *pb.GetItemRequest) (*pb.VaultItem, error) {
+	item, err := s.vault.GetItem(ctx, sessionFromContext(ctx).UserID, req.GetId())
+	if err != nil {
+		return nil, statusError(ctx, s.log, err, "failed to load vault item")
+	}
+	return vaultItemToProto(item), nil
+}
+
+func (s *vaultServer) CreateItem(ctx context.Context, req *pb.CreateItemRequest) (*pb.VaultItem, error) {
+	payload := req.GetItem()
+	if payload == nil {
+		return nil, status.Error(codes.InvalidArgument, "item is required")
+	}
+	item, err := s.vault.CreateItem(ctx, sessionFromContext(ctx).UserID, domain.CreateVaultItemInput{
+		FolderID:    payload.FolderId,
+		Ciphertext:  payload.GetCiphertext(),
+		Nonce:       payload.GetNonce(),
+		WrappedDEK:  payload.GetWrappedDek(),
+		WrapNonce:   payload.GetWrapNonce(),
+		AlgoVersion: payload.GetAlgoVersion(),
+		Metadata:    payload.GetMetadata(),
+	})
+	if err != nil {
+		return nil, statusError(ctx, s.log, err, "failed to create vault item")
+	}
+	return vaultItemToProto(item), nil
+}
+
+func (s *vaultServer) UpdateItem(ctx context.Context, req *pb.UpdateItemRequest) (*pb.VaultItem, error) {
+	payload := req.GetItem()
+	if payload == nil {
+		return nil, status.Error(codes.InvalidArgument, "item is required")
+	}
+	item, err := s.vault.UpdateItem(ctx, sessionFromContext(ctx).UserID, req.GetId(), domain.UpdateVaultItemInput{
+		FolderID:    payload.FolderId,
+		Ciphertext:  payload.GetCiphertext(),
+		Nonce:       payload.GetNonce(),
+		WrappedDEK:  payload.GetWrappedDek(),
+		WrapNonce:   payload.GetWrapNonce(),
+		AlgoVersion: payload.GetAlgoVersion(),
+		Metadata:    payload.GetMetadata(),
+	})
+	if err != nil {
+		return nil, statusError(ctx, s.log, err, "failed to update vault item")
+	}
+	return vaultItemToProto(item), nil
+}
+
+func (s *vaultServer) DeleteItem(ctx context.Context, req *pb.DeleteItemRequest) (*pb.DeleteItemResponse, error) {
+	if err := s.vault.DeleteItem(ctx, sessionFromContext(ctx).UserID, req.GetId()); err != nil {
+		return nil, statusError(ctx, s.log, err, "failed to delete vault item")
+	}
+	return &pb.DeleteItemResponse{}, nil
+}
+
+func (s *vaultServer) Sync(req *pb.SyncRequest, stream grpc.ServerStreamingServer[pb.SyncResponse]) error {
+	ctx := stream.Context()
+	userID := sessionFromContext(ctx).UserID
+
+	revision, err := s.vault.VaultRevision(ctx, userID)
+	if err != nil {
+		return statusError(ctx, s.log, err, "failed to sync vault")
+	}
+	if req.GetIfNoneMatch() == revision {
+		return stream.Send(&pb.SyncResponse{Revision: revision, NotModified: true})
+	}
+
+	snapshot, err := s.vault.Sync(ctx, userID, revision)
+	if err != nil {
+		return statusError(ctx, s.log, err, "failed to sync vault")
+	}
+
+	folders := make([]*pb.VaultFolder, 0, len(snapshot.Folders))
+	for _, folder := range snapshot.Folders {
+		folders = append(folders, vaultFolderToProto(folder))
+	}
+	if err := stream.Send(&pb.SyncResponse{Revision: snapshot.Revision, Folders: folders}); err != nil {
+		return err
+	}
+	for start := 0; start < len(snapshot.Items); start += syncBatchSize {
+		end := min(start+syncBatchSize, len(snapshot.Items))
+		if err := stream.Send(&pb.SyncResponse{Revision: snapshot.Revision, Items: vaultItemsToProto(snapshot.Items[start:end])}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *vaultServer) Watch(req *pb.WatchRequest, stream grpc.ServerStreamingServer[pb.VaultChange]) error {
+	ctx := stream.Context()
+	userID := sessionFromContext(ctx).UserID
+	last := req.GetRevision()
+
+	ticker := time.NewTicker(s.watchInterval)
+	defer ticker.Stop()
+	for {
+		revision, err := s.vault.VaultRevision(ctx, userID)
+		if err != nil {
+			return statusError(ctx, s.log, err, "failed to watch vault")
+		}
+		if revision != last {
+			if err := stream.Send(&pb.VaultChange{Revision: revision}); err != nil {
+				return err
+			}
+			last = revision
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func vaultItemsToProto(items []domain.VaultItem) []*pb.VaultItem {
+	out := make([]*pb.VaultItem, 0, len(items))
+	for _, item := range items {
+		out = append(out, vaultItemToProto(item))
+	}
+	return out
+}
+
+func vaultItemToProto(item domain.VaultItem) *pb.VaultItem {
+	return &pb.VaultItem{
+		Id:          item.ID,
+		FolderId:    item.FolderID,
+		Ciphertext:  item.Ciphertext,
+		Nonce:       item.Nonce,
+		WrappedDek:  item.WrappedDEK,
+		WrapNonce:   item.WrapNonce,
+		AlgoVersion: item.AlgoVersion,
+		Metadata:    item.Metadata,
+		IsShared:    item.IsShared,
+		Version:     int32(item.Version),
+		CreatedAt:   timestamppb.New(item.CreatedAt),
+		UpdatedAt:   timestamppb.New(item.UpdatedAt),
+	}
+}
+
+func vaultFolderToProto(folder domain.VaultFolder) *pb.VaultFolder {
+	return &pb.VaultFolder{
+		Id:             folder.ID,
+		NameCiphertext: folder.NameCiphertext,
+		Nonce:          folder.Nonce,
+		CreatedAt:      timestamppb.New(folder.CreatedAt),
+		UpdatedAt:      timestamppb.New(folder.UpdatedAt),
+	}
+}
@@ -0,0 +1,108 @@
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// english holds the built-in English text for every message that has no
+// inline fallback, i.e. the email templates and push notifications. API
+// error and field messages keep their English text at the call site, so a
+// catalog for another language adds "error.<code>" and "field.<code>" entries on top of these.
+var english = Messages{
+	"email.greeting":           "Hi %s,",
+	"email.greeting_anonymous": "Hi there,",
+	"email.link_expires":       "The link expires in %s.",
+
+	"email.verification.subject":    "Verify your email address",
+	"email.verification.intro":      "Please confirm your email address:",
+	"email.verification.intro_text": "Please confirm your email address by opening the link below:",
+	"email.verification.action":     "Verify email",
+	"email.verification.ignore":     "If you did not create an account, you can ignore this email.",
+
+	"email.recovery.subject":   "Recover your account",
+	"email.recovery.intro":     "We received a request to recover your account.",
+	"email.recovery.open_link": "Open the link below to continue:",
+	"email.recovery.action":    "Recover account",
+	"email.recovery.ignore":    "If you did not request this, ignore this email; your account is unchanged.",
+
+	"email.security_alert.subject":    "Security alert: %s",
+	"email.security_alert.time":       "Time",
+	"email.security_alert.ip_address": "IP address",
+	"email.security_alert.device":     "Device",
+	"email.security_alert.advice":     "If this was you, no action is needed. Otherwise change your master password and review your active sessions.",
+
+	"email.security_alert.new_sign_in.title":  "New sign-in",
+	"email.security_alert.new_sign_in.detail": "Your account was just signed in to.",
+
+	"email.security_alert.token_reused.title":  "Session signed out",
+	"email.security_alert.token_reused.detail": "An old sign-in token for one of your sessions was used again, which suggests it was copied. That session has been signed out.",
+
+	"email.login_failures.subject":       "Failed sign-in attempts on your account",
+	"email.login_failures.mfa":           "Someone entered your master password but then failed the two-factor check %d times in a row.",
+	"email.login_failures.password":      "Someone tried to sign in to your account with a wrong password %d times in a short while.",
+	"email.login_failures.locked_until":  "Two-factor sign-in is locked until %s.",
+	"email.login_failures.recent":        "Recent attempts:",
+	"email.login_failures.revoke":        "If this was not you, sign out every device and then change your master password:",
+	"email.login_failures.revoke_action": "Sign out everywhere",
+	"email.login_failures.advice":        "If this was you, no action is needed. Otherwise change your master password and review your active sessions.",
+
+	"email.share_invitation.subject":    "%s shared a vault item with you",
+	"email.share_invitation.intro":      "%s wants to share a vault item with you.",
+	"email.share_invitation.intro_text": "%s wants to share a vault item with you. Create an account with this email address by opening the link below:",
+	"email.share_invitation.action":     "Create account",
+	"email.share_invitation.next":       "Once your account is set up, they will be asked to finish sharing the item.",
+	"email.share_invitation.ignore":     "If you do not know the sender, you can ignore this email.",
+
+	"email.share_accessed.subject": "%s opened an item you shared",
+	"email.share_accessed.intro":   "%s has opened a vault item you shared with them for the first time.",
+	"email.share_accessed.time":    "Time: %s",
+	"email.share_accessed.manage":  "You get this email because you turned on access alerts for the item. You can turn them off in the item's sharing settings.",
+
+	"email.data_export.subject":  "Your data export is ready",
+	"email.data_export.intro":    "The copy of your account data you requested is ready.",
+	"email.data_export.download": "Sign in and download it from your account settings before %s. After that it is deleted.",
+	"email.data_export.ignore":   "If you did not request this export, change your master password and review your active sessions.",
+
+	"push.mfa_prompt.title":             "Confirm it's you",
+	"push.mfa_prompt.body":              "A sign-in to your account needs your authenticator code before it can continue.",
+	"push.new_device.title":             "New sign-in",
+	"push.new_device.body":              "Your account was just signed in to from a new device.",
+	"push.share_invitation.title":       "Item shared with you",
+	"push.share_invitation.body":        "Someone shared a vault item with you.",
+	"push.access_request.title":         "Access requested",
+	"push.access_request.body":          "A family member asked for access to a vault item you manage.",
+	"push.share_invitation_ready.title": "Finish sharing",
+	"push.share_invitation_ready.body":  "Someone you invited has created an account. Open the app to finish sharing the item with them.",
+}
+
+// LoadDir registers every <lang>.json file in dir with b. Each file is a
+// flat JSON object of message ID to text, e.g. de.json:
+//
+//	{"error.email_taken": "Diese E-Mail-Adresse ist bereits registriert."}
+func LoadDir(b *Bundle, dir string) error {
+	if info, err := os.Stat(dir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read catalog: %w", err)
+		}
+		var messages Messages
+		if err := json.Unmarshal(raw, &messages); err != nil {
+			return fmt.Errorf("parse catalog %s: %w", filepath.Base(path), err)
+		}
+		b.Register(strings.TrimSuffix(filepath.Base(path), ".json"), messages)
+	}
+	return nil
+}
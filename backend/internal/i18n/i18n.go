@@ -0,0 +1,204 @@
+// Package i18n translates user-facing text. Every message has a stable ID
+// ("error.email_taken", "field.required", "email.recovery.subject") that is
+// looked up in a per-language Catalog. API clients keep switching on the
+// machine-readable error codes; only the human-readable text changes with
+// the request's Accept-Language.
+//
+// English is built in. Other languages are plugged in by registering a
+// Catalog, for example one loaded from a JSON file with LoadDir.
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage is used when a request names no supported language.
+const DefaultLanguage = "en"
+
+// Catalog maps message IDs to text in one language. Text may contain fmt
+// verbs that are filled from the arguments given to Localizer.Text.
+type Catalog interface {
+	Lookup(id string) (string, bool)
+}
+
+// Messages is an in-memory Catalog.
+type Messages map[string]string
+
+func (m Messages) Lookup(id string) (string, bool) {
+	text, ok := m[id]
+	return text, ok
+}
+
+// Bundle holds the catalogs for every supported language.
+type Bundle struct {
+	catalogs map[string]Catalog
+}
+
+// NewBundle returns a bundle containing the built-in English catalog.
+func NewBundle() *Bundle {
+	return &Bundle{catalogs: map[string]Catalog{DefaultLanguage: english}}
+}
+
+// Register adds or replaces the catalog for lang, a lower-case language tag
+// such as "de" or "pt-br". Registering "en" replaces the built-in messages,
+// so a partial English catalog should wrap them rather than stand alone.
+func (b *Bundle) Register(lang string, catalog Catalog) {
+	b.catalogs[strings.ToLower(strings.TrimSpace(lang))] = catalog
+}
+
+// Languages lists the supported language tags, sorted.
+func (b *Bundle) Languages() []string {
+	langs := make([]string, 0, len(b.catalogs))
+	for lang := range b.catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Match picks the supported language that best satisfies an Accept-Language
+// header. "de-AT" falls back to "de"; q=0 entries are ignored.
+func (b *Bundle) Match(acceptLanguage string) string {
+	best, bestQ := DefaultLanguage, 0.0
+	for _, entry := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(entry), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= bestQ || tag == "" {
+			continue
+		}
+		if lang, ok := b.supported(tag); ok {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+func (b *Bundle) supported(tag string) (string, bool) {
+	if tag == "*" {
+		return DefaultLanguage, true
+	}
+	for {
+		if _, ok := b.catalogs[tag]; ok {
+			return tag, true
+		}
+		i := strings.LastIndexByte(tag, '-')
+		if i < 0 {
+			return "", false
+		}
+		tag = tag[:i]
+	}
+}
+
+// Localizer returns a Localizer for lang.
+func (b *Bundle) Localizer(lang string) Localizer {
+	return Localizer{bundle: b, lang: lang}
+}
+
+// Localizer renders messages in one language. The zero value renders
+// nothing itself and always returns the fallback.
+type Localizer struct {
+	bundle *Bundle
+	lang   string
+}
+
+// Language is the localizer's language tag, or DefaultLanguage for the
+// zero value.
+func (l Localizer) Language() string {
+	if l.lang == "" {
+		return DefaultLanguage
+	}
+	return l.lang
+}
+
+// Text returns the message for id in l's language formatted with args, or
+// fallback when that language has no such message. API errors pass their
+// original English text as fallback, so English responses are unchanged.
+func (l Localizer) Text(id, fallback string, args ...any) string {
+	if l.bundle == nil {
+		return fallback
+	}
+	catalog, ok := l.bundle.catalogs[l.lang]
+	if !ok {
+		return fallback
+	}
+	text, ok := catalog.Lookup(id)
+	if !ok {
+		return fallback
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// Message is like Text but falls back to the English catalog and then to
+// id itself. Email templates use it, where every string has a message ID.
+func (l Localizer) Message(id string, args ...any) string {
+	fallback := id
+	if text, ok := english.Lookup(id); ok {
+		fallback = text
+		if len(args) > 0 {
+			fallback = fmt.Sprintf(text, args...)
+		}
+	}
+	return l.Text(id, fallback, args...)
+}
+
+type localizerKey struct{}
+
+// WithLocalizer attaches l to ctx, so work triggered by a request (such as
+// queued emails) uses the requester's language.
+func WithLocalizer(ctx context.Context, l Localizer) context.Context {
+	return context.WithValue(ctx, localizerKey{}, l)
+}
+
+// FromContext returns the localizer attached to ctx, or the zero value.
+func FromContext(ctx context.Context) Localizer {
+	l, _ := ctx.Value(localizerKey{}).(Localizer)
+	return l
+}
+
+// localizedWriter carries the request's localizer to code that only has the
+// ResponseWriter, such as util.WriteError.
+type localizedWriter struct {
+	http.ResponseWriter
+	localizer Localizer
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (lw *localizedWriter) Unwrap() http.ResponseWriter {
+	return lw.ResponseWriter
+}
+
+// WithWriter wraps w so FromWriter finds l.
+func WithWriter(w http.ResponseWriter, l Localizer) http.ResponseWriter {
+	return &localizedWriter{ResponseWriter: w, localizer: l}
+}
+
+// FromWriter returns the localizer attached by WithWriter, looking through
+// any writers that wrap it, or the zero value.
+func FromWriter(w http.ResponseWriter) Localizer {
+	for {
+		if lw, ok := w.(*localizedWriter); ok {
+			return lw.localizer
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return Localizer{}
+		}
+		w = u.Unwrap()
+	}
+}
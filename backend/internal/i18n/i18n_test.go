@@ -0,0 +1,113 @@
+package i18n
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testBundle() *Bundle {
+	b := NewBundle()
+	b.Register("de", Messages{"error.email_taken": "E-Mail bereits registriert", "field.required": "%s ist erforderlich"})
+	b.Register("pt-br", Messages{})
+	return b
+}
+
+func TestMatch(t *testing.T) {
+	b := testBundle()
+	cases := map[string]string{
+		"":                       "en",
+		"de":                     "de",
+		"DE-at":                  "de",
+		"fr, de;q=0.5":           "de",
+		"de;q=0.4, en;q=0.8":     "en",
+		"pt-BR":                  "pt-br",
+		"pt":                     "en",
+		"de;q=0":                 "en",
+		"de;q=bogus, pt-br;q=.3": "pt-br",
+		"*":                      "en",
+	}
+	for header, want := range cases {
+		if got := b.Match(header); got != want {
+			t.Errorf("Match(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestLocalizerText(t *testing.T) {
+	b := testBundle()
+
+	de := b.Localizer("de")
+	if got := de.Text("error.email_taken", "email already registered"); got != "E-Mail bereits registriert" {
+		t.Errorf("de text = %q", got)
+	}
+	if got := de.Text("field.required", "email is required", "email"); got != "email ist erforderlich" {
+		t.Errorf("de field text = %q", got)
+	}
+	if got := de.Text("error.unknown", "fallback"); got != "fallback" {
+		t.Errorf("missing id = %q, want fallback", got)
+	}
+	if got := b.Localizer("en").Text("error.email_taken", "email already registered"); got != "email already registered" {
+		t.Errorf("en text = %q, want the inline English", got)
+	}
+	if got := (Localizer{}).Text("error.email_taken", "fallback"); got != "fallback" {
+		t.Errorf("zero localizer = %q", got)
+	}
+
+	// Message falls back to the built-in English, then to the ID.
+	if got := de.Message("email.greeting", "Ana"); got != "Hi Ana," {
+		t.Errorf("de Message = %q", got)
+	}
+	if got := de.Message("no.such.id"); got != "no.such.id" {
+		t.Errorf("unknown Message = %q", got)
+	}
+}
+
+type wrappingWriter struct{ http.ResponseWriter }
+
+func (w wrappingWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func TestFromWriterLooksThroughWrappers(t *testing.T) {
+	loc := testBundle().Localizer("de")
+	w := wrappingWriter{WithWriter(httptest.NewRecorder(), loc)}
+	if got := FromWriter(w).Language(); got != "de" {
+		t.Errorf("language = %q, want de", got)
+	}
+	if got := FromWriter(httptest.NewRecorder()).Language(); got != DefaultLanguage {
+		t.Errorf("plain writer language = %q", got)
+	}
+	if got := FromContext(WithLocalizer(context.Background(), loc)).Language(); got != "de" {
+		t.Errorf("context language = %q", got)
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "fr.json"), []byte(`{"error.not_found": "introuvable"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("ignored"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewBundle()
+	if err := LoadDir(b, dir); err != nil {
+		t.Fatalf("LoadDir: %v", err)
+	}
+	if got := b.Localizer(b.Match("fr-CA")).Text("error.not_found", "not found"); got != "introuvable" {
+		t.Errorf("fr text = %q", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "de.json"), []byte(`{"broken"`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadDir(NewBundle(), dir); err == nil {
+		t.Error("LoadDir accepted a malformed catalog")
+	}
+	if err := LoadDir(NewBundle(), filepath.Join(dir, "missing")); err == nil {
+		t.Error("LoadDir accepted a missing directory")
+	}
+}
@@ -0,0 +1,106 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Bitwarden's item types.
+const (
+	bitwardenLogin    = 1
+	bitwardenNote     = 2
+	bitwardenCard     = 3
+	bitwardenIdentity = 4
+)
+
+type bitwardenExport struct {
+	Encrypted bool `json:"encrypted"`
+	Folders   []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"folders"`
+	Items []bitwardenItem `json:"items"`
+}
+
+type bitwardenItem struct {
+	Type     int     `json:"type"`
+	Name     string  `json:"name"`
+	Notes    *string `json:"notes"`
+	FolderID *string `json:"folderId"`
+	Login    *struct {
+		Username *string `json:"username"`
+		Password *string `json:"password"`
+		URIs     []struct {
+			URI *string `json:"uri"`
+		} `json:"uris"`
+	} `json:"login"`
+	Card *struct {
+		CardholderName *string `json:"cardholderName"`
+		Brand          *string `json:"brand"`
+		Number         *string `json:"number"`
+		ExpMonth       *string `json:"expMonth"`
+		ExpYear        *string `json:"expYear"`
+	} `json:"card"`
+}
+
+func str(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// parseBitwardenJSON reads Bitwarden's unencrypted JSON export. Logins,
+// notes and cards are imported; identities are reported as row errors.
+func parseBitwardenJSON(r io.Reader) ([]Entry, []RowError, error) {
+	var export bitwardenExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, nil, fmt.Errorf("decode Bitwarden export: %w", err)
+	}
+	if export.Encrypted {
+		return nil, nil, errors.New("encrypted Bitwarden exports cannot be imported; export unencrypted JSON instead")
+	}
+	folders := make(map[string]string, len(export.Folders))
+	for _, f := range export.Folders {
+		folders[f.ID] = f.Name
+	}
+
+	var entries []Entry
+	var rowErrs []RowError
+	for i, item := range export.Items {
+		row := i + 1
+		e := Entry{Row: row, Title: item.Name, Notes: str(item.Notes), Folder: folders[str(item.FolderID)]}
+		switch item.Type {
+		case bitwardenLogin:
+			e.Kind = KindLogin
+			if login := item.Login; login != nil {
+				e.Username, e.Password = str(login.Username), str(login.Password)
+				for _, u := range login.URIs {
+					if e.URL = str(u.URI); e.URL != "" {
+						break
+					}
+				}
+			}
+		case bitwardenNote:
+			e.Kind = KindNote
+		case bitwardenCard:
+			e.Kind = KindCard
+			if card := item.Card; card != nil {
+				e.CardholderName, e.CardNumber = str(card.CardholderName), str(card.Number)
+				e.ExpiryDate, e.CardType = expiry(str(card.ExpMonth), str(card.ExpYear)), str(card.Brand)
+			}
+		case bitwardenIdentity:
+			rowErrs = append(rowErrs, RowError{Row: row, Err: fmt.Errorf("%w: identity", ErrUnsupportedType)})
+			continue
+		default:
+			rowErrs = append(rowErrs, RowError{Row: row, Err: fmt.Errorf("%w %d", ErrUnsupportedType, item.Type)})
+			continue
+		}
+		if finish(&e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, rowErrs, nil
+}
@@ -0,0 +1,186 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// csvRow is a CSV record keyed by its normalized column header.
+type csvRow map[string]string
+
+// get returns the first non-blank value among the columns named.
+func (r csvRow) get(names ...string) string {
+	for _, name := range names {
+		if v := strings.TrimSpace(r[normalizeHeader(name)]); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// normalizeHeader makes "Login Name", "login_name" and "loginname" the same
+// column.
+func normalizeHeader(h string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return -1
+	}, h)
+}
+
+// parseCSV reads a CSV export with a header line, turning each record into
+// an entry with adapt. adapt returns ok false for a row with nothing in it.
+func parseCSV(r io.Reader, adapt func(csvRow) (e Entry, ok bool, err error)) ([]Entry, []RowError, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, errors.New("the export is empty")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("read header: %w", err)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	columns := make([]string, len(header))
+	for i, h := range header {
+		columns[i] = normalizeHeader(h)
+	}
+
+	var entries []Entry
+	var rowErrs []RowError
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := cr.FieldPos(0)
+
+		row := make(csvRow, len(columns))
+		for i, column := range columns {
+			if i < len(record) {
+				row[column] = record[i]
+			}
+		}
+		e, ok, err := adapt(row)
+		switch {
+		case err != nil:
+			rowErrs = append(rowErrs, RowError{Row: line, Err: err})
+		case ok:
+			e.Row = line
+			entries = append(entries, e)
+		}
+	}
+	return entries, rowErrs, nil
+}
+
+// loginOrNote is the entry of a row of a manager that only exports logins:
+// a row without any login field holds a note.
+func loginOrNote(e Entry) (Entry, bool, error) {
+	e.Kind = KindLogin
+	if e.Username == "" && e.Password == "" && e.URL == "" {
+		e.Kind = KindNote
+	}
+	ok := finish(&e)
+	return e, ok, nil
+}
+
+// firstURI is the first of the comma-separated URIs of a Bitwarden login.
+func firstURI(uris string) string {
+	first, _, _ := strings.Cut(uris, ",")
+	return strings.TrimSpace(first)
+}
+
+func splitTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' }) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// bitwardenRow reads Bitwarden's CSV export: folder, favorite, type, name,
+// notes, fields, reprompt, login_uri, login_username, login_password,
+// login_totp. It only ever holds logins and notes.
+func bitwardenRow(r csvRow) (Entry, bool, error) {
+	e := Entry{Title: r.get("name"), Notes: r.get("notes"), Folder: r.get("folder")}
+	switch strings.ToLower(r.get("type")) {
+	case "", "login":
+		e.Kind = KindLogin
+		e.Username, e.Password, e.URL = r.get("login_username"), r.get("login_password"), firstURI(r.get("login_uri"))
+	case "note":
+		e.Kind = KindNote
+	default:
+		return Entry{}, false, fmt.Errorf("%w %q", ErrUnsupportedType, r.get("type"))
+	}
+	ok := finish(&e)
+	return e, ok, nil
+}
+
+// lastPassNoteURL is the URL LastPass gives secure notes in its export.
+const lastPassNoteURL = "http://sn"
+
+// lastPassRow reads LastPass's CSV export: url, username, password, totp,
+// extra, name, grouping, fav. Secure notes, including the structured ones
+// LastPass keeps cards in, are imported as notes.
+func lastPassRow(r csvRow) (Entry, bool, error) {
+	e := Entry{Title: r.get("name"), Notes: r.get("extra"), Folder: r.get("grouping")}
+	if r.get("url") == lastPassNoteURL {
+		e.Kind = KindNote
+		ok := finish(&e)
+		return e, ok, nil
+	}
+	e.Username, e.Password, e.URL = r.get("username"), r.get("password"), r.get("url")
+	return loginOrNote(e)
+}
+
+// onePasswordRow reads 1Password's CSV export, whose columns changed across
+// versions: Title, Url or Website, Username, Password, Notes and Tags.
+func onePasswordRow(r csvRow) (Entry, bool, error) {
+	e := Entry{
+		Title:    r.get("title"),
+		Notes:    r.get("notes", "notesPlain"),
+		Tags:     splitTags(r.get("tags")),
+		Username: r.get("username"),
+		Password: r.get("password"),
+		URL:      r.get("url", "website", "urls"),
+	}
+	return loginOrNote(e)
+}
+
+// keePassRow reads the CSV export of KeePassXC (Group, Title, Username,
+// Password, URL, Notes) and of KeePass 2 (Group, Account, Login Name,
+// Password, Web Site, Comments). The group path, less its root, becomes
+// the folder.
+func keePassRow(r csvRow) (Entry, bool, error) {
+	folder := r.get("group")
+	if root, rest, found := strings.Cut(folder, "/"); found && strings.EqualFold(root, "root") {
+		folder = rest
+	} else if strings.EqualFold(folder, "root") {
+		folder = ""
+	}
+	e := Entry{
+		Title:    r.get("title", "account"),
+		Notes:    r.get("notes", "comments"),
+		Folder:   folder,
+		Username: r.get("username", "login name"),
+		Password: r.get("password"),
+		URL:      r.get("url", "web site"),
+	}
+	return loginOrNote(e)
+}
@@ -0,0 +1,210 @@
+// Package importer reads the exports of other password managers into vault
+// secrets. It only parses: the entries it returns are plaintext and must be
+// encrypted by the client before anything is sent to the server, which
+// accepts imported items through the same encrypted payloads as any other.
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is the source an export comes from and the layout it is in.
+type Format string
+
+const (
+	FormatBitwardenCSV  Format = "bitwarden_csv"
+	FormatBitwardenJSON Format = "bitwarden_json"
+	FormatLastPassCSV   Format = "lastpass_csv"
+	Format1PasswordCSV  Format = "1password_csv"
+	FormatKeePassCSV    Format = "keepass_csv"
+)
+
+var formats = []Format{FormatBitwardenCSV, FormatBitwardenJSON, FormatLastPassCSV, Format1PasswordCSV, FormatKeePassCSV}
+
+// Formats lists every supported format.
+func Formats() []Format {
+	return append([]Format(nil), formats...)
+}
+
+// ErrUnknownFormat is returned by ParseFormat and Parse for a format that is
+// not one of Formats.
+var ErrUnknownFormat = errors.New("unknown import format")
+
+// ParseFormat returns the format named s, ignoring case and surrounding
+// space.
+func ParseFormat(s string) (Format, error) {
+	f := Format(strings.ToLower(strings.TrimSpace(s)))
+	for _, known := range formats {
+		if f == known {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownFormat, s)
+}
+
+// The kinds of secret an import produces; they are the "kind" of both the
+// secret and the item metadata, as the web app uses them.
+const (
+	KindLogin = "login"
+	KindCard  = "card"
+	KindNote  = "note"
+)
+
+// IsKind reports whether kind is one an import produces.
+func IsKind(kind string) bool {
+	switch kind {
+	case KindLogin, KindCard, KindNote:
+		return true
+	}
+	return false
+}
+
+// Entry is one imported secret. Row is where it came from: the line it
+// starts on in a CSV file, or its 1-based position in a JSON export's item
+// list. Only the fields of its Kind are set.
+type Entry struct {
+	Row    int
+	Kind   string
+	Title  string
+	Notes  string
+	Folder string
+	Tags   []string
+
+	Username string
+	Password string
+	URL      string
+
+	CardholderName string
+	CardNumber     string
+	ExpiryDate     string
+	CardType       string
+}
+
+// Secret returns the entry as the plaintext the web app stores in an item.
+func (e Entry) Secret() map[string]any {
+	tags := e.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	secret := map[string]any{"kind": e.Kind, "title": e.Title, "notes": e.Notes, "tags": tags}
+	switch e.Kind {
+	case KindLogin:
+		secret["username"], secret["password"], secret["url"] = e.Username, e.Password, e.URL
+	case KindCard:
+		secret["cardholderName"], secret["cardNumber"] = e.CardholderName, e.CardNumber
+		secret["expiryDate"], secret["cardType"] = e.ExpiryDate, e.CardType
+	}
+	return secret
+}
+
+// RowError is an entry of the export that could not be imported.
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+func (e RowError) Unwrap() error { return e.Err }
+
+// ErrUnsupportedType is the error of a row holding a kind of secret the
+// vault has no place for, such as a Bitwarden identity.
+var ErrUnsupportedType = errors.New("unsupported item type")
+
+// Parse reads an export in format. Rows that cannot be imported are
+// reported in rowErrs and the rest are still returned; blank rows are
+// skipped. err is set only when the export as a whole is unreadable.
+func Parse(format Format, r io.Reader) (entries []Entry, rowErrs []RowError, err error) {
+	switch format {
+	case FormatBitwardenCSV:
+		return parseCSV(r, bitwardenRow)
+	case FormatBitwardenJSON:
+		return parseBitwardenJSON(r)
+	case FormatLastPassCSV:
+		return parseCSV(r, lastPassRow)
+	case Format1PasswordCSV:
+		return parseCSV(r, onePasswordRow)
+	case FormatKeePassCSV:
+		return parseCSV(r, keePassRow)
+	}
+	return nil, nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+// finish fills in the title of an entry that has none, the way the web
+// app's importer does, and reports whether the entry holds anything.
+func finish(e *Entry) bool {
+	switch e.Kind {
+	case KindLogin:
+		if e.Title == "" && e.Username == "" && e.Password == "" && e.URL == "" && e.Notes == "" {
+			return false
+		}
+		e.Title = firstOf(e.Title, e.Username, e.URL, "Login")
+	case KindCard:
+		if e.Title == "" && e.CardNumber == "" && e.CardholderName == "" && e.Notes == "" {
+			return false
+		}
+		e.Title = firstOf(e.Title, e.CardholderName, "Payment Card")
+		e.CardType = cardType(e.CardType, e.CardNumber)
+	case KindNote:
+		if e.Title == "" && e.Notes == "" {
+			return false
+		}
+		e.Title = firstOf(e.Title, "Secure Note")
+	}
+	return true
+}
+
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// cardType is the web app's card type for a brand, guessed from the number
+// when the brand is not one it knows.
+func cardType(brand, number string) string {
+	switch b := strings.ToLower(strings.TrimSpace(brand)); b {
+	case "visa", "mastercard", "amex":
+		return b
+	case "american express":
+		return "amex"
+	}
+	digits := strings.Map(func(r rune) rune {
+		if r < '0' || r > '9' {
+			return -1
+		}
+		return r
+	}, number)
+	switch {
+	case strings.HasPrefix(digits, "4"):
+		return "visa"
+	case len(digits) >= 2 && digits[0] == '5' && digits[1] >= '1' && digits[1] <= '5':
+		return "mastercard"
+	case strings.HasPrefix(digits, "34"), strings.HasPrefix(digits, "37"):
+		return "amex"
+	}
+	return "other"
+}
+
+// expiry formats a card expiry as the web app's MM/YY.
+func expiry(month, year string) string {
+	month, year = strings.TrimSpace(month), strings.TrimSpace(year)
+	if month == "" || year == "" {
+		return ""
+	}
+	if len(month) == 1 {
+		month = "0" + month
+	}
+	if len(year) > 2 {
+		year = year[len(year)-2:]
+	}
+	return month + "/" + year
+}
@@ -0,0 +1,126 @@
+package importer_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"pmv2/backend/internal/importer"
+)
+
+func TestParseCSVFormats(t *testing.T) {
+	tests := []struct {
+		format importer.Format
+		export string
+		want   []importer.Entry
+	}{
+		{
+			format: importer.FormatBitwardenCSV,
+			export: "\ufefffolder,favorite,type,name,notes,fields,reprompt,login_uri,login_username,login_password,login_totp\n" +
+				"Email,1,login,Gmail,,,0,\"https://mail.google.com,https://google.com\",ada,pw1,\n" +
+				",,note,Wifi,\"line one\nline two\",,0,,,,\n",
+			want: []importer.Entry{
+				{Row: 2, Kind: "login", Title: "Gmail", Folder: "Email", Username: "ada", Password: "pw1", URL: "https://mail.google.com"},
+				{Row: 3, Kind: "note", Title: "Wifi", Notes: "line one\nline two"},
+			},
+		},
+		{
+			format: importer.FormatLastPassCSV,
+			export: "url,username,password,totp,extra,name,grouping,fav\n" +
+				"https://github.com,ada,pw2,,,GitHub,Work,0\n" +
+				"http://sn,,,,the code is 1234,Door,,0\n",
+			want: []importer.Entry{
+				{Row: 2, Kind: "login", Title: "GitHub", Folder: "Work", Username: "ada", Password: "pw2", URL: "https://github.com"},
+				{Row: 3, Kind: "note", Title: "Door", Notes: "the code is 1234"},
+			},
+		},
+		{
+			format: importer.Format1PasswordCSV,
+			export: "Title,Url,Username,Password,OTPAuth,Favorite,Archived,Tags,Notes\n" +
+				"Bank,https://bank.example,ada,pw3,,false,false,finance;home,\n",
+			want: []importer.Entry{
+				{Row: 2, Kind: "login", Title: "Bank", Tags: []string{"finance", "home"}, Username: "ada", Password: "pw3", URL: "https://bank.example"},
+			},
+		},
+		{
+			format: importer.FormatKeePassCSV,
+			export: "\"Group\",\"Title\",\"Username\",\"Password\",\"URL\",\"Notes\"\n" +
+				"\"Root/Social\",\"\",\"ada\",\"pw4\",\"https://social.example\",\"\"\n" +
+				"\"Root\",\"\",\"\",\"\",\"\",\"\"\n",
+			want: []importer.Entry{
+				{Row: 2, Kind: "login", Title: "ada", Folder: "Social", Username: "ada", Password: "pw4", URL: "https://social.example"},
+			},
+		},
+		{
+			format: importer.FormatKeePassCSV,
+			export: "Account,Login Name,Password,Web Site,Comments\nForum,ada,pw5,https://forum.example,old\n",
+			want: []importer.Entry{
+				{Row: 2, Kind: "login", Title: "Forum", Notes: "old", Username: "ada", Password: "pw5", URL: "https://forum.example"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		entries, rowErrs, err := importer.Parse(tt.format, strings.NewReader(tt.export))
+		if err != nil || len(rowErrs) != 0 {
+			t.Fatalf("%s: Parse = %v, %v", tt.format, rowErrs, err)
+		}
+		if len(entries) != len(tt.want) {
+			t.Fatalf("%s: got %d entries, want %d: %+v", tt.format, len(entries), len(tt.want), entries)
+		}
+		if !reflect.DeepEqual(entries, tt.want) {
+			t.Errorf("%s: entries = %+v\nwant %+v", tt.format, entries, tt.want)
+		}
+	}
+}
+
+func TestParseBitwardenJSON(t *testing.T) {
+	export := `{
+		"encrypted": false,
+		"folders": [{"id": "f1", "name": "Money"}],
+		"items": [
+			{"type": 1, "name": "Gmail", "notes": null, "folderId": null,
+			 "login": {"username": "ada", "password": "pw", "uris": [{"uri": "https://mail.google.com"}]}},
+			{"type": 4, "name": "Passport", "identity": {}},
+			{"type": 3, "name": "", "folderId": "f1",
+			 "card": {"cardholderName": "Ada Lovelace", "brand": "Visa", "number": "4111111111111111", "expMonth": "3", "expYear": "2030"}},
+			{"type": 2, "name": "Recovery", "notes": "words"}
+		]
+	}`
+	entries, rowErrs, err := importer.Parse(importer.FormatBitwardenJSON, strings.NewReader(export))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(rowErrs) != 1 || rowErrs[0].Row != 2 || !errors.Is(rowErrs[0], importer.ErrUnsupportedType) {
+		t.Fatalf("row errors = %v, want the identity on row 2", rowErrs)
+	}
+	want := []importer.Entry{
+		{Row: 1, Kind: "login", Title: "Gmail", Username: "ada", Password: "pw", URL: "https://mail.google.com"},
+		{Row: 3, Kind: "card", Title: "Ada Lovelace", Folder: "Money", CardholderName: "Ada Lovelace", CardNumber: "4111111111111111", ExpiryDate: "03/30", CardType: "visa"},
+		{Row: 4, Kind: "note", Title: "Recovery", Notes: "words"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("entries = %+v\nwant %+v", entries, want)
+	}
+
+	secret := entries[1].Secret()
+	if secret["kind"] != "card" || secret["expiryDate"] != "03/30" || secret["username"] != nil {
+		t.Fatalf("card secret = %v", secret)
+	}
+
+	if _, _, err := importer.Parse(importer.FormatBitwardenJSON, strings.NewReader(`{"encrypted": true, "items": []}`)); err == nil {
+		t.Fatal("an encrypted Bitwarden export parsed")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := importer.ParseFormat(" LastPass_CSV "); err != nil || f != importer.FormatLastPassCSV {
+		t.Fatalf("ParseFormat = %q, %v", f, err)
+	}
+	if _, err := importer.ParseFormat("dashlane_csv"); !errors.Is(err, importer.ErrUnknownFormat) {
+		t.Fatalf("ParseFormat(dashlane_csv): err = %v", err)
+	}
+	if _, _, err := importer.Parse(importer.FormatBitwardenCSV, strings.NewReader("")); err == nil {
+		t.Fatal("an empty export parsed")
+	}
+}
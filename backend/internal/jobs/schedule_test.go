@@ -0,0 +1,91 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleNext(t *testing.T) {
+	// 2026-01-01 is a Thursday.
+	from := time.Date(2026, 1, 1, 10, 17, 42, 0, time.UTC)
+
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 1, 1, 10, 30, 0, 0, time.UTC)},
+		{"30 3 * * *", time.Date(2026, 1, 2, 3, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2026, 1, 1, 13, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 1,3", time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 15 * *", time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Date(2031, 1, 1, 10, 18, 0, 0, time.UTC)}, // never matches: gives up after five years
+		{"0 0 15 * 1", time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)},   // either day field matches
+		{"0 0 1 3 *", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90m", from.Add(90 * time.Minute)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.spec, func(t *testing.T) {
+			s, err := ParseSchedule(tc.spec)
+			if err != nil {
+				t.Fatalf("ParseSchedule: %v", err)
+			}
+			if got := s.Next(from); !got.Equal(tc.want) {
+				t.Fatalf("Next = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseScheduleNextIsStrictlyAfter(t *testing.T) {
+	s, err := ParseSchedule("30 3 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 1, 1, 3, 30, 0, 0, time.UTC)
+	if got, want := s.Next(at), at.AddDate(0, 0, 1); !got.Equal(want) {
+		t.Fatalf("Next(%s) = %s, want %s", at, got, want)
+	}
+}
+
+func TestParseScheduleRejectsInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@every",
+		"@every -1m",
+		"@every soon",
+		"@yearly",
+	} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestJitterDelaysWithinBound(t *testing.T) {
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := Jitter(Every(time.Hour), 6*time.Minute)
+	for range 100 {
+		next := s.Next(at)
+		if next.Before(at.Add(time.Hour)) || !next.Before(at.Add(time.Hour+6*time.Minute)) {
+			t.Fatalf("Next(%s) = %s, want within 6m after %s", at, next, at.Add(time.Hour))
+		}
+	}
+	if got, want := Jitter(Every(time.Hour), 0).Next(at), at.Add(time.Hour); !got.Equal(want) {
+		t.Fatalf("zero jitter: Next = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,167 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+const defaultJobTimeout = 10 * time.Minute
+
+// Job is a unit of periodic background work.
+type Job struct {
+	Name     string
+	Schedule Schedule
+	// Timeout bounds a single run; zero means defaultJobTimeout.
+	Timeout time.Duration
+	Run     func(ctx context.Context) error
+}
+
+// FailureHandler is called after every failed run with the number of
+// consecutive failures so far, so alerts can escalate or deduplicate.
+type FailureHandler func(ctx context.Context, jobName string, err error, consecutiveFailures int)
+
+// Scheduler runs registered jobs on their schedules. Before each run it takes
+// a Postgres advisory lock so that only one API replica executes a given job
+// at a time, and records the outcome in job_runs.
+//
+// The lock alone only prevents overlap: replicas whose timers fire a few
+// seconds apart would each run the job in turn. So, holding the lock, a
+// replica also skips the run when job_runs shows another replica already
+// started the job after this replica planned it. This covers both cron and
+// Every schedules.
+type Scheduler struct {
+	repo      domain.JobRepository
+	logger    *slog.Logger
+	jobs      []Job
+	onFailure FailureHandler
+}
+
+func NewScheduler(repo domain.JobRepository, logger *slog.Logger) *Scheduler {
+	return &Scheduler{repo: repo, logger: logger}
+}
+
+func (s *Scheduler) Register(job Job) {
+	if job.Timeout <= 0 {
+		job.Timeout = defaultJobTimeout
+	}
+	s.jobs = append(s.jobs, job)
+}
+
+// OnFailure sets the alert hook for failed runs. Failures are always logged.
+func (s *Scheduler) OnFailure(fn FailureHandler) {
+	s.onFailure = fn
+}
+
+// Run blocks until ctx is cancelled, then waits for in-flight runs to end.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, job := range s.jobs {
+		wg.Add(1)
+		go func(job Job) {
+			defer wg.Done()
+			s.loop(ctx, job)
+		}(job)
+	}
+	wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	failures := 0
+	planned := time.Now()
+	next := job.Schedule.Next(planned)
+
+	for {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		ran, err := s.runOnce(ctx, job, planned)
+		switch {
+		case err != nil:
+			failures++
+			s.logger.Error("background job failed",
+				slog.String("job", job.Name),
+				slog.Int("consecutive_failures", failures),
+				slog.Any("error", err),
+			)
+			if s.onFailure != nil {
+				s.onFailure(context.WithoutCancel(ctx), job.Name, err, failures)
+			}
+		case ran:
+			failures = 0
+		}
+
+		planned = time.Now()
+		next = job.Schedule.Next(planned)
+	}
+}
+
+// runOnce executes job if this replica wins the lock and no replica has
+// started it since planned. It reports whether the job actually ran.
+func (s *Scheduler) runOnce(ctx context.Context, job Job, planned time.Time) (ran bool, err error) {
+	// Let a run that has started finish even if shutdown begins meanwhile;
+	// the per-run timeout still bounds it.
+	runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), job.Timeout)
+	defer cancel()
+
+	if s.repo == nil {
+		return true, s.invoke(runCtx, job)
+	}
+
+	release, acquired, err := s.repo.TryLock(runCtx, job.Name)
+	if err != nil {
+		return false, fmt.Errorf("acquire job lock: %w", err)
+	}
+	if !acquired {
+		s.logger.Debug("background job skipped, running elsewhere", slog.String("job", job.Name))
+		return false, nil
+	}
+	defer release()
+
+	last, err := s.repo.LastRunStartedAt(runCtx, job.Name)
+	if err != nil {
+		return false, fmt.Errorf("read last job run: %w", err)
+	}
+	if !last.Before(planned) {
+		s.logger.Debug("background job skipped, already ran elsewhere", slog.String("job", job.Name), slog.Time("last_started_at", last))
+		return false, nil
+	}
+
+	runID, err := s.repo.StartRun(runCtx, job.Name, time.Now().UTC())
+	if err != nil {
+		return false, fmt.Errorf("record job start: %w", err)
+	}
+
+	runErr := s.invoke(runCtx, job)
+
+	status, message := domain.JobRunStatusSucceeded, ""
+	if runErr != nil {
+		status, message = domain.JobRunStatusFailed, runErr.Error()
+	}
+	if err := s.repo.FinishRun(context.WithoutCancel(runCtx), runID, status, message, time.Now().UTC()); err != nil {
+		s.logger.Error("failed to record job run", slog.String("job", job.Name), slog.Any("error", err))
+	}
+	return true, runErr
+}
+
+func (s *Scheduler) invoke(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("job panicked: %v", rec)
+		}
+	}()
+
+	start := time.Now()
+	err = job.Run(ctx)
+	s.logger.Debug("background job finished", slog.String("job", job.Name), slog.Duration("duration", time.Since(start)))
+	return err
+}
@@ -0,0 +1,169 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+// fakeJobRepo mimics the advisory lock and job_runs table in memory, shared
+// by every Scheduler that uses it as if they were separate replicas.
+type fakeJobRepo struct {
+	mu       sync.Mutex
+	locked   map[string]bool
+	lastRun  map[string]time.Time
+	finished []domain.JobRunStatus
+}
+
+func newFakeJobRepo() *fakeJobRepo {
+	return &fakeJobRepo{locked: make(map[string]bool), lastRun: make(map[string]time.Time)}
+}
+
+func (r *fakeJobRepo) TryLock(ctx context.Context, jobName string) (func(), bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.locked[jobName] {
+		return nil, false, nil
+	}
+	r.locked[jobName] = true
+	return func() {
+		r.mu.Lock()
+		delete(r.locked, jobName)
+		r.mu.Unlock()
+	}, true, nil
+}
+
+func (r *fakeJobRepo) LastRunStartedAt(ctx context.Context, jobName string) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastRun[jobName], nil
+}
+
+func (r *fakeJobRepo) StartRun(ctx context.Context, jobName string, startedAt time.Time) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastRun[jobName] = startedAt
+	return jobName, nil
+}
+
+func (r *fakeJobRepo) FinishRun(ctx context.Context, runID string, status domain.JobRunStatus, errMessage string, finishedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.finished = append(r.finished, status)
+	return nil
+}
+
+func (r *fakeJobRepo) DeleteRunsBefore(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func newTestScheduler(repo domain.JobRepository) *Scheduler {
+	return NewScheduler(repo, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestRunOnceSkipsWhileLocked(t *testing.T) {
+	repo := newFakeJobRepo()
+	release, _, _ := repo.TryLock(context.Background(), "cleanup")
+	defer release()
+
+	runs := 0
+	job := Job{Name: "cleanup", Timeout: time.Second, Run: func(ctx context.Context) error { runs++; return nil }}
+	ran, err := newTestScheduler(repo).runOnce(context.Background(), job, time.Now())
+	if err != nil || ran || runs != 0 {
+		t.Fatalf("ran=%v err=%v runs=%d, want skipped", ran, err, runs)
+	}
+}
+
+func TestRunOnceRunsOncePerPlannedSlotAcrossReplicas(t *testing.T) {
+	repo := newFakeJobRepo()
+	runs := 0
+	job := Job{Name: "cleanup", Schedule: Every(time.Hour), Timeout: time.Second, Run: func(ctx context.Context) error { runs++; return nil }}
+
+	// Both replicas planned this run before either started it; their
+	// timers fire one after the other, so the lock is free each time.
+	planned := time.Now().Add(-time.Second)
+	a, b := newTestScheduler(repo), newTestScheduler(repo)
+	if ran, err := a.runOnce(context.Background(), job, planned); !ran || err != nil {
+		t.Fatalf("replica a: ran=%v err=%v", ran, err)
+	}
+	if ran, err := b.runOnce(context.Background(), job, planned); ran || err != nil {
+		t.Fatalf("replica b: ran=%v err=%v, want skipped", ran, err)
+	}
+	if runs != 1 {
+		t.Fatalf("job ran %d times, want 1", runs)
+	}
+
+	// The next slot, planned after the first run, runs again.
+	time.Sleep(time.Millisecond)
+	if ran, err := b.runOnce(context.Background(), job, time.Now()); !ran || err != nil {
+		t.Fatalf("next slot: ran=%v err=%v", ran, err)
+	}
+	if runs != 2 {
+		t.Fatalf("job ran %d times, want 2", runs)
+	}
+}
+
+func TestRunOnceRecordsOutcome(t *testing.T) {
+	repo := newFakeJobRepo()
+	s := newTestScheduler(repo)
+
+	boom := errors.New("boom")
+	failing := Job{Name: "failing", Timeout: time.Second, Run: func(ctx context.Context) error { return boom }}
+	if ran, err := s.runOnce(context.Background(), failing, time.Now()); !ran || !errors.Is(err, boom) {
+		t.Fatalf("ran=%v err=%v, want the job error", ran, err)
+	}
+	panicking := Job{Name: "panicking", Timeout: time.Second, Run: func(ctx context.Context) error { panic("oops") }}
+	if ran, err := s.runOnce(context.Background(), panicking, time.Now()); !ran || err == nil {
+		t.Fatalf("ran=%v err=%v, want a panic error", ran, err)
+	}
+	ok := Job{Name: "ok", Timeout: time.Second, Run: func(ctx context.Context) error { return nil }}
+	if ran, err := s.runOnce(context.Background(), ok, time.Now()); !ran || err != nil {
+		t.Fatalf("ran=%v err=%v", ran, err)
+	}
+
+	want := []domain.JobRunStatus{domain.JobRunStatusFailed, domain.JobRunStatusFailed, domain.JobRunStatusSucceeded}
+	if len(repo.finished) != len(want) {
+		t.Fatalf("finished = %v, want %v", repo.finished, want)
+	}
+	for i := range want {
+		if repo.finished[i] != want[i] {
+			t.Fatalf("finished = %v, want %v", repo.finished, want)
+		}
+	}
+}
+
+func TestRunReportsConsecutiveFailures(t *testing.T) {
+	s := newTestScheduler(newFakeJobRepo())
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var counts []int
+	s.OnFailure(func(_ context.Context, jobName string, err error, consecutive int) {
+		counts = append(counts, consecutive)
+		if consecutive == 3 {
+			cancel()
+		}
+	})
+	s.Register(Job{Name: "failing", Schedule: Every(time.Millisecond), Run: func(ctx context.Context) error {
+		return errors.New("boom")
+	}})
+
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduler did not stop")
+	}
+	if len(counts) != 3 || counts[0] != 1 || counts[2] != 3 {
+		t.Fatalf("consecutive failures = %v, want [1 2 3]", counts)
+	}
+}
@@ -0,0 +1,66 @@
+// Package kms supplies the deployment keys the server encrypts its own data
+// with. A Provider hands out named 32-byte data keys; the master key behind
+// them never leaves the provider.
+package kms
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the length of every data key.
+const KeySize = 32
+
+// Provider returns data keys by name. The same name always yields the same
+// key, so data encrypted under it stays readable across restarts.
+type Provider interface {
+	DataKey(ctx context.Context, name string) ([]byte, error)
+}
+
+// LocalProvider derives data keys from a master key held in the process
+// configuration, for deployments without an external KMS.
+type LocalProvider struct {
+	master []byte
+}
+
+// NewLocalProvider takes the master key as base64, as KMS_LOCAL_KEY holds it.
+func NewLocalProvider(encodedKey string) (*LocalProvider, error) {
+	master, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encodedKey))
+	if err != nil {
+		return nil, fmt.Errorf("decode local kms key: %w", err)
+	}
+	if len(master) != KeySize {
+		return nil, fmt.Errorf("local kms key is %d bytes, want %d", len(master), KeySize)
+	}
+	return &LocalProvider{master: master}, nil
+}
+
+// DataKey derives the key for name with HMAC-SHA256 over the master key.
+func (p *LocalProvider) DataKey(_ context.Context, name string) ([]byte, error) {
+	if name == "" {
+		return nil, errors.New("data key name is empty")
+	}
+	mac := hmac.New(sha256.New, p.master)
+	mac.Write([]byte("pmv2:kms:" + name))
+	return mac.Sum(nil), nil
+}
+
+// New returns the provider selected by KMS_PROVIDER, or nil for "" (no KMS).
+func New(provider, localKey string) (Provider, error) {
+	switch provider {
+	case "":
+		return nil, nil
+	case "local":
+		p, err := NewLocalProvider(localKey)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+	return nil, fmt.Errorf("unknown kms provider %q", provider)
+}
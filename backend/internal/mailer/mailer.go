@@ -0,0 +1,89 @@
+// Package mailer renders transactional emails and delivers them over SMTP.
+//
+// Messages are rendered when they are queued and handed to the transactional
+// outbox, so a send survives restarts and is retried with the outbox's
+// backoff. Email is a sensitive outbox topic, so the stored message is
+// cleared once it has been delivered. In development the log sender records
+// recipient and subject instead of delivering messages.
+//
+// Emails are rendered in the language of the request that queued them (see
+// i18n.FromContext), or English for background work.
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/i18n"
+)
+
+// Message is a fully rendered email.
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html"`
+}
+
+// Sender delivers a single message.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type Mailer struct {
+	renderer *Renderer
+	sender   Sender
+	outbox   domain.OutboxRepository
+}
+
+// New builds a mailer that can render every language in bundle. When outbox
+// is nil messages are sent synchronously.
+func New(sender Sender, outbox domain.OutboxRepository, bundle *i18n.Bundle) (*Mailer, error) {
+	renderer, err := NewRenderer(bundle)
+	if err != nil {
+		return nil, err
+	}
+	return &Mailer{renderer: renderer, sender: sender, outbox: outbox}, nil
+}
+
+// Send renders tmpl with data and queues the result for delivery to to.
+// Inside a Transactor.WithinTx block the email is only sent if the
+// transaction commits.
+func (m *Mailer) Send(ctx context.Context, to string, tmpl Template, data any) error {
+	to = strings.TrimSpace(to)
+	if to == "" || strings.ContainsAny(to, "\r\n") {
+		return fmt.Errorf("invalid recipient %q", to)
+	}
+
+	msg, err := m.renderer.Render(i18n.FromContext(ctx).Language(), tmpl, data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+
+	if m.outbox == nil {
+		return m.sender.Send(ctx, msg)
+	}
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal email: %w", err)
+	}
+	if err := m.outbox.Enqueue(ctx, domain.OutboxTopicEmail, payload); err != nil {
+		return fmt.Errorf("enqueue email: %w", err)
+	}
+	return nil
+}
+
+// HandleOutboxEvent delivers a queued message. Returning an error makes the
+// dispatcher retry it later.
+func (m *Mailer) HandleOutboxEvent(ctx context.Context, event domain.OutboxEvent) error {
+	var msg Message
+	if err := json.Unmarshal(event.Payload, &msg); err != nil {
+		return fmt.Errorf("decode email: %w", err)
+	}
+	return m.sender.Send(ctx, msg)
+}
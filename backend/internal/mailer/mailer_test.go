@@ -0,0 +1,232 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/i18n"
+)
+
+func TestRenderEveryTemplate(t *testing.T) {
+	r, err := NewRenderer(i18n.NewBundle())
+	if err != nil {
+		t.Fatalf("NewRenderer: %v", err)
+	}
+
+	cases := []struct {
+		tmpl    Template
+		data    any
+		subject string
+		want    string
+	}{
+		{TemplateVerification, VerificationData{Name: "Ada", Link: "https://app.example/verify?t=abc", ExpiresIn: "24 hours"}, "Verify your email address", "https://app.example/verify?t=abc"},
+		{TemplateSecurityAlert, SecurityAlertData{Title: "New sign-in", Detail: "A new device signed in.", IPAddress: "203.0.113.7"}, "Security alert: New sign-in", "203.0.113.7"},
+		{TemplateRecovery, RecoveryData{Link: "https://app.example/recover?t=xyz"}, "", "https://app.example/recover?t=xyz"},
+		{TemplateDataExport, DataExportData{ExpiresAt: "Mon, 02 Jan 2026 15:04:05 UTC"}, "Your data export is ready", "Mon, 02 Jan 2026 15:04:05 UTC"},
+		{TemplateShareInvitation, ShareInvitationData{SharerName: "Ada", Link: "https://app.example/register?email=bob%40example.com", ExpiresIn: "14 days"}, "Ada shared a vault item with you", "https://app.example/register?email=bob%40example.com"},
+		{TemplateShareAccessed, ShareAccessedData{Name: "Ada", RecipientName: "Bob", Time: "Mon, 02 Jan 2026 15:04:05 UTC"}, "Bob opened an item you shared", "Mon, 02 Jan 2026 15:04:05 UTC"},
+		{TemplateLoginFailures, LoginFailuresData{Detail: "email.login_failures.mfa", Attempts: 5, Recent: []LoginAttempt{{Time: "Mon, 02 Jan 2026 15:04:05 UTC", IPAddress: "203.0.113.7"}}, RevokeLink: "https://app.example/revoke-sessions?token=abc"}, "Failed sign-in attempts on your account", "https://app.example/revoke-sessions?token=abc"},
+	}
+	for _, tc := range cases {
+		msg, err := r.Render("en", tc.tmpl, tc.data)
+		if err != nil {
+			t.Fatalf("Render(%s): %v", tc.tmpl, err)
+		}
+		if msg.Subject == "" || strings.ContainsAny(msg.Subject, "\r\n") {
+			t.Fatalf("%s subject = %q, want a single non-empty line", tc.tmpl, msg.Subject)
+		}
+		if tc.subject != "" && msg.Subject != tc.subject {
+			t.Fatalf("%s subject = %q, want %q", tc.tmpl, msg.Subject, tc.subject)
+		}
+		if !strings.Contains(msg.Text, tc.want) || !strings.Contains(msg.HTML, tc.want) {
+			t.Fatalf("%s: text or html is missing %q", tc.tmpl, tc.want)
+		}
+		if strings.Contains(msg.Text, "{{") || strings.Contains(msg.Text, `define "subject"`) {
+			t.Fatalf("%s text leaks template syntax: %q", tc.tmpl, msg.Text)
+		}
+	}
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	r, err := NewRenderer(i18n.NewBundle())
+	if err != nil {
+		t.Fatalf("NewRenderer: %v", err)
+	}
+	msg, err := r.Render("en", TemplateSecurityAlert, SecurityAlertData{Name: "<script>alert(1)</script>", Title: "t", Detail: "d"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if strings.Contains(msg.HTML, "<script>") {
+		t.Fatalf("html was not escaped: %s", msg.HTML)
+	}
+	if !strings.Contains(msg.Text, "<script>") {
+		t.Fatal("the text part should keep the name verbatim")
+	}
+}
+
+func TestRenderUnknownTemplate(t *testing.T) {
+	r, err := NewRenderer(i18n.NewBundle())
+	if err != nil {
+		t.Fatalf("NewRenderer: %v", err)
+	}
+	if _, err := r.Render("en", Template("nope"), nil); err == nil {
+		t.Fatal("expected an error for an unknown template")
+	}
+}
+
+type captureOutbox struct {
+	domain.OutboxRepository
+	topic   string
+	payload json.RawMessage
+}
+
+func (o *captureOutbox) Enqueue(ctx context.Context, topic string, payload json.RawMessage) error {
+	o.topic, o.payload = topic, payload
+	return nil
+}
+
+type captureSender struct{ sent []Message }
+
+func (s *captureSender) Send(ctx context.Context, msg Message) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestSendQueuesAndDeliversThroughOutbox(t *testing.T) {
+	outbox := &captureOutbox{}
+	sender := &captureSender{}
+	m, err := New(sender, outbox, i18n.NewBundle())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := m.Send(ctx, " user@example.com ", TemplateVerification, VerificationData{Link: "https://app.example/v"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if outbox.topic != domain.OutboxTopicEmail {
+		t.Fatalf("topic = %q, want %q", outbox.topic, domain.OutboxTopicEmail)
+	}
+	if len(sender.sent) != 0 {
+		t.Fatal("a queued email must not be sent before the outbox delivers it")
+	}
+
+	event := domain.OutboxEvent{ID: "1", Topic: outbox.topic, Payload: outbox.payload}
+	if err := m.HandleOutboxEvent(ctx, event); err != nil {
+		t.Fatalf("HandleOutboxEvent: %v", err)
+	}
+	if len(sender.sent) != 1 || sender.sent[0].To != "user@example.com" || sender.sent[0].Subject != "Verify your email address" {
+		t.Fatalf("sent = %+v", sender.sent)
+	}
+}
+
+func TestSendRendersRequestLanguage(t *testing.T) {
+	bundle := i18n.NewBundle()
+	bundle.Register("de", i18n.Messages{
+		"email.greeting":             "Hallo %s,",
+		"email.verification.subject": "Bestätige deine E-Mail-Adresse",
+	})
+	sender := &captureSender{}
+	m, err := New(sender, nil, bundle)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	ctx := i18n.WithLocalizer(context.Background(), bundle.Localizer("de"))
+	if err := m.Send(ctx, "user@example.com", TemplateVerification, VerificationData{Name: "Ana", Link: "https://app.example/v"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	msg := sender.sent[0]
+	if msg.Subject != "Bestätige deine E-Mail-Adresse" {
+		t.Errorf("subject = %q", msg.Subject)
+	}
+	if !strings.HasPrefix(msg.Text, "Hallo Ana,") || !strings.Contains(msg.HTML, `<html lang="de">`) {
+		t.Errorf("body not localized:\n%s\n%s", msg.Text, msg.HTML)
+	}
+	// Messages missing from the catalog fall back to English.
+	if !strings.Contains(msg.Text, "If you did not create an account") {
+		t.Errorf("missing English fallback:\n%s", msg.Text)
+	}
+
+	// Without a request language the email is English.
+	if err := m.Send(context.Background(), "user@example.com", TemplateVerification, VerificationData{Name: "Ana"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got := sender.sent[1].Subject; got != "Verify your email address" {
+		t.Errorf("default subject = %q", got)
+	}
+}
+
+func TestSendRejectsHeaderInjection(t *testing.T) {
+	m, err := New(&captureSender{}, nil, i18n.NewBundle())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for _, to := range []string{"", "a@example.com\r\nBcc: b@example.com"} {
+		if err := m.Send(context.Background(), to, TemplateVerification, VerificationData{}); err == nil {
+			t.Fatalf("Send(%q) succeeded, want an error", to)
+		}
+	}
+}
+
+func TestBuildMIME(t *testing.T) {
+	raw, err := buildMIME("PMV2 <no-reply@example.com>", Message{To: "user@example.com", Subject: "Grüße", Text: "plain body\n", HTML: "<p>html body</p>"})
+	if err != nil {
+		t.Fatalf("buildMIME: %v", err)
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != "Grüße" {
+		t.Fatalf("subject = %q (%v), want Grüße", subject, err)
+	}
+	if _, err := parsed.Header.Date(); err != nil {
+		t.Fatalf("Date header: %v", err)
+	}
+
+	_, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("Content-Type: %v", err)
+	}
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+	var types []string
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		types = append(types, part.Header.Get("Content-Type"))
+	}
+	if len(types) != 2 || !strings.HasPrefix(types[0], "text/plain") || !strings.HasPrefix(types[1], "text/html") {
+		t.Fatalf("parts = %v, want text/plain then text/html", types)
+	}
+
+	if _, err := buildMIME("a@example.com", Message{To: "b@example.com", Subject: "x\r\nBcc: c@example.com"}); err == nil {
+		t.Fatal("expected a line break in a header to be rejected")
+	}
+}
+
+func TestLogSenderOmitsBody(t *testing.T) {
+	var buf bytes.Buffer
+	sender := NewLogSender(slog.New(slog.NewTextHandler(&buf, nil)))
+	msg := Message{To: "user@example.com", Subject: "Verify", Text: "https://app.example/v?token=secret", HTML: "<a>secret</a>"}
+	if err := sender.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Fatalf("log output contains the message body: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "user@example.com") {
+		t.Fatalf("log output is missing the recipient: %s", buf.String())
+	}
+}
@@ -0,0 +1,179 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"pmv2/backend/internal/i18n"
+)
+
+// Template names a pair of templates/<name>.txt and templates/<name>.html.
+// The text template also defines the "subject" block. Templates hold no
+// prose of their own: {{t "id" args...}} looks the text up in the i18n
+// catalog of the recipient's language and {{lang}} is its language tag.
+type Template string
+
+const (
+	TemplateVerification    Template = "verification"
+	TemplateSecurityAlert   Template = "security_alert"
+	TemplateRecovery        Template = "recovery"
+	TemplateDataExport      Template = "data_export_ready"
+	TemplateShareInvitation Template = "share_invitation"
+	TemplateShareAccessed   Template = "share_accessed"
+	TemplateLoginFailures   Template = "login_failures"
+)
+
+var allTemplates = []Template{TemplateVerification, TemplateSecurityAlert, TemplateRecovery, TemplateDataExport, TemplateShareInvitation, TemplateShareAccessed, TemplateLoginFailures}
+
+//go:embed templates/*
+var templateFS embed.FS
+
+// VerificationData is the data for TemplateVerification.
+type VerificationData struct {
+	Name      string
+	Link      string
+	ExpiresIn string
+}
+
+// SecurityAlertData is the data for TemplateSecurityAlert. Title and Detail
+// are i18n message IDs.
+type SecurityAlertData struct {
+	Name      string
+	Title     string
+	Detail    string
+	IPAddress string
+	UserAgent string
+	Time      string
+}
+
+// RecoveryData is the data for TemplateRecovery.
+type RecoveryData struct {
+	Name      string
+	Link      string
+	ExpiresIn string
+}
+
+// DataExportData is the data for TemplateDataExport. The email carries no
+// link: the archive is only served to a signed-in session.
+type DataExportData struct {
+	Name      string
+	ExpiresAt string
+}
+
+// ShareInvitationData is the data for TemplateShareInvitation. Link opens
+// registration with the invited email filled in.
+type ShareInvitationData struct {
+	SharerName string
+	Link       string
+	ExpiresIn  string
+}
+
+// ShareAccessedData is the data for TemplateShareAccessed. It names the
+// recipient but not the item, whose title only the owner can decrypt.
+type ShareAccessedData struct {
+	Name          string
+	RecipientName string
+	Time          string
+}
+
+// LoginFailuresData is the data for TemplateLoginFailures. Detail is an
+// i18n message ID taking Attempts; LockedUntil is empty unless the account
+// is locked. RevokeLink, when set, signs the account out everywhere.
+type LoginFailuresData struct {
+	Name        string
+	Detail      string
+	Attempts    int
+	LockedUntil string
+	Recent      []LoginAttempt
+	RevokeLink  string
+	ExpiresIn   string
+}
+
+// LoginAttempt is one failed sign-in listed in a LoginFailuresData.
+type LoginAttempt struct {
+	Time      string
+	IPAddress string
+	UserAgent string
+}
+
+type templatePair struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+type Renderer struct {
+	// templates holds one parsed set per supported language, because the t
+	// function is bound at parse time.
+	templates map[string]map[Template]templatePair
+}
+
+// NewRenderer parses every embedded template for each language in bundle up
+// front so a broken template fails at startup rather than on first send.
+func NewRenderer(bundle *i18n.Bundle) (*Renderer, error) {
+	r := &Renderer{templates: make(map[string]map[Template]templatePair)}
+	for _, lang := range bundle.Languages() {
+		set, err := parseTemplates(bundle.Localizer(lang))
+		if err != nil {
+			return nil, err
+		}
+		r.templates[lang] = set
+	}
+	return r, nil
+}
+
+func parseTemplates(loc i18n.Localizer) (map[Template]templatePair, error) {
+	funcs := map[string]any{
+		"t":    loc.Message,
+		"lang": loc.Language,
+	}
+	set := make(map[Template]templatePair, len(allTemplates))
+	for _, name := range allTemplates {
+		text, err := texttemplate.New(string(name)+".txt").Funcs(funcs).ParseFS(templateFS, "templates/"+string(name)+".txt")
+		if err != nil {
+			return nil, fmt.Errorf("parse %s text template: %w", name, err)
+		}
+		if text.Lookup("subject") == nil {
+			return nil, fmt.Errorf("%s text template has no subject block", name)
+		}
+		html, err := htmltemplate.New(string(name)+".html").Funcs(funcs).ParseFS(templateFS, "templates/"+string(name)+".html")
+		if err != nil {
+			return nil, fmt.Errorf("parse %s html template: %w", name, err)
+		}
+		set[name] = templatePair{text: text, html: html}
+	}
+	return set, nil
+}
+
+// Render renders name in lang, falling back to English for a language
+// without templates.
+func (r *Renderer) Render(lang string, name Template, data any) (Message, error) {
+	set, ok := r.templates[lang]
+	if !ok {
+		set = r.templates[i18n.DefaultLanguage]
+	}
+	pair, ok := set[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown email template %q", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := pair.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("render %s subject: %w", name, err)
+	}
+	if err := pair.text.Execute(&text, data); err != nil {
+		return Message{}, fmt.Errorf("render %s text: %w", name, err)
+	}
+	if err := pair.html.Execute(&html, data); err != nil {
+		return Message{}, fmt.Errorf("render %s html: %w", name, err)
+	}
+
+	return Message{
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
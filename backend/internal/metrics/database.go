@@ -0,0 +1,33 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// DBBreakerState is 1 for the state the database circuit breaker is in
+	// (closed, open or half_open) and 0 for the others.
+	DBBreakerState = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pmv2_db_breaker_state",
+		Help: "Database circuit breaker state; 1 for the current one.",
+	}, []string{"state"})
+
+	// DBBreakerTransitions counts the breaker's state changes, by the state
+	// entered.
+	DBBreakerTransitions = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "pmv2_db_breaker_transitions_total",
+		Help: "Database circuit breaker state changes, by state entered.",
+	}, []string{"state"})
+
+	// DBBreakerRejected counts database calls failed fast while the breaker
+	// was open.
+	DBBreakerRejected = factory.NewCounter(prometheus.CounterOpts{
+		Name: "pmv2_db_breaker_rejected_total",
+		Help: "Database calls refused without reaching the database because the circuit breaker was open.",
+	})
+)
+
+// AuthCacheRequests counts lookups of cached sign-in records ("user") and
+// TOTP state ("totp"), by whether the cache answered them.
+var AuthCacheRequests = factory.NewCounterVec(prometheus.CounterOpts{
+	Name: "pmv2_auth_cache_requests_total",
+	Help: "Auth record cache lookups, by record kind and hit or miss.",
+}, []string{"kind", "result"})
@@ -0,0 +1,34 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// HTTPRequests counts handled requests by method, route pattern and
+	// status code.
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "pmv2_http_requests_total",
+		Help: "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration observes handler latency by method and route.
+	HTTPRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pmv2_http_request_duration_seconds",
+		Help:    "HTTP request latency in seconds, by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// HTTPPanics counts handler panics recovered into a 500, by route
+	// pattern ("" when the panic happened before routing).
+	HTTPPanics = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "pmv2_http_panics_total",
+		Help: "Handler panics recovered into a 500 response, by route.",
+	}, []string{"route"})
+
+	// ConcurrencyRejected counts expensive requests refused by the
+	// concurrency limiter, by route pattern and by which limit was full
+	// ("user" or "global").
+	ConcurrencyRejected = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "pmv2_concurrency_rejected_total",
+		Help: "Expensive requests refused because a concurrency limit was reached, by route and limit.",
+	}, []string{"route", "limit"})
+)
@@ -0,0 +1,20 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// PasswordHashDuration observes how long Argon2id takes to hash a new
+	// password ("hash") or check a login against a stored one ("verify").
+	PasswordHashDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pmv2_password_hash_duration_seconds",
+		Help:    "Time spent in Argon2id password hashing, by operation.",
+		Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"op"})
+
+	// PasswordHashRejected counts requests turned away with server_busy
+	// because every password hashing slot stayed taken.
+	PasswordHashRejected = factory.NewCounter(prometheus.CounterOpts{
+		Name: "pmv2_password_hash_rejected_total",
+		Help: "Password hashes refused because the hashing concurrency limit stayed reached.",
+	})
+)
@@ -0,0 +1,10 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// RetentionRowsDeleted counts rows removed by the scheduled purge jobs, by
+// the kind of data they held.
+var RetentionRowsDeleted = factory.NewCounterVec(prometheus.CounterOpts{
+	Name: "pmv2_retention_rows_deleted_total",
+	Help: "Rows deleted by retention purge jobs, by kind of data.",
+}, []string{"data"})
@@ -1,6 +1,7 @@
 package middlewares
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
@@ -21,22 +22,141 @@ func NewAuthMiddleware(authService *service.AuthService, sessionCookieName strin
 	}
 }
 
+// sessionRestriction is a state that keeps a session from most routes.
+type sessionRestriction uint8
+
+const (
+	// restrictedStepUp is a session awaiting step-up.
+	restrictedStepUp sessionRestriction = 1 << iota
+	// restrictedCredentialReset is a session of an account whose
+	// credentials an admin flagged compromised.
+	restrictedCredentialReset
+)
+
+// WithSession authenticates the request and passes its session to next. A
+// session flagged for step-up (see domain.Session.StepUpRequired) is refused
+// until a TOTP code is verified on it, and one of an account flagged
+// compromised (see domain.Session.CredentialResetRequired) until the master
+// password is changed.
 func (m *AuthMiddleware) WithSession(next func(http.ResponseWriter, *http.Request, domain.Session)) http.HandlerFunc {
+	return m.withSession(next, 0)
+}
+
+// WithSessionDuringStepUp behaves like WithSession but also lets a session
+// awaiting step-up or a credential reset through, for the routes it needs
+// to complete either or sign out.
+func (m *AuthMiddleware) WithSessionDuringStepUp(next func(http.ResponseWriter, *http.Request, domain.Session)) http.HandlerFunc {
+	return m.withSession(next, restrictedStepUp|restrictedCredentialReset)
+}
+
+// WithSessionDuringCredentialReset behaves like WithSession but also lets a
+// session awaiting a credential reset through, for the routes the client
+// needs to re-wrap the vault's keys and change the password.
+func (m *AuthMiddleware) WithSessionDuringCredentialReset(next func(http.ResponseWriter, *http.Request, domain.Session)) http.HandlerFunc {
+	return m.withSession(next, restrictedCredentialReset)
+}
+
+func (m *AuthMiddleware) withSession(next func(http.ResponseWriter, *http.Request, domain.Session), allowed sessionRestriction) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		token := m.sessionTokenFromRequest(r)
-		if token == "" {
+		access := m.accessTokenFromRequest(r)
+		if token == "" && access == "" {
 			util.WriteError(w, http.StatusUnauthorized, "unauthorized", "missing session token")
 			return
 		}
 
-		session, err := m.auth.Authenticate(r.Context(), token)
+		session, err := m.authenticate(r, access, token)
+		if errors.Is(err, domain.ErrInvalidDPoPProof) {
+			w.Header().Set("WWW-Authenticate", `DPoP algs="ES256 EdDSA"`)
+			util.WriteError(w, http.StatusUnauthorized, domain.ErrInvalidDPoPProof.Code, domain.ErrInvalidDPoPProof.Message)
+			return
+		}
 		if err != nil {
 			util.WriteError(w, http.StatusUnauthorized, "unauthorized", "invalid or expired session")
 			return
 		}
+		// Only accounts with TOTP can step up; for the others the anomaly
+		// is recorded but the session is never flagged.
+		if session.StepUpRequired && session.TOTPEnabled && allowed&restrictedStepUp == 0 {
+			util.WriteError(w, http.StatusForbidden, domain.ErrStepUpRequired.Code, domain.ErrStepUpRequired.Message)
+			return
+		}
+		if session.CredentialResetRequired && allowed&restrictedCredentialReset == 0 {
+			util.WriteError(w, http.StatusForbidden, domain.ErrCredentialResetRequired.Code, domain.ErrCredentialResetRequired.Message)
+			return
+		}
+
+		meta := util.RequestMetaFromContext(r.Context())
+		meta.ActorUserID = session.UserID
+		meta.SessionID = session.ID
+		meta.DeviceID = session.DeviceID
+		next(w, r.WithContext(util.WithRequestMeta(r.Context(), meta)), session)
+	}
+}
+
+// WithOptionalSession passes next the request's session, or nil when it has
+// no valid one. Sessions awaiting step-up are passed too; next decides what
+// they may see.
+func (m *AuthMiddleware) WithOptionalSession(next func(http.ResponseWriter, *http.Request, *domain.Session)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := m.sessionTokenFromRequest(r)
+		access := m.accessTokenFromRequest(r)
+		if token == "" && access == "" {
+			next(w, r, nil)
+			return
+		}
+		session, err := m.authenticate(r, access, token)
+		if err != nil {
+			next(w, r, nil)
+			return
+		}
 
+		meta := util.RequestMetaFromContext(r.Context())
+		meta.ActorUserID = session.UserID
+		meta.SessionID = session.ID
+		meta.DeviceID = session.DeviceID
+		next(w, r.WithContext(util.WithRequestMeta(r.Context(), meta)), &session)
+	}
+}
+
+// WithAdminSession behaves like WithSession but also requires the account to
+// hold the admin role.
+func (m *AuthMiddleware) WithAdminSession(next func(http.ResponseWriter, *http.Request, domain.Session)) http.HandlerFunc {
+	return m.WithSession(func(w http.ResponseWriter, r *http.Request, session domain.Session) {
+		if session.Role != domain.UserRoleAdmin {
+			util.WriteError(w, http.StatusForbidden, "forbidden", "admin access required")
+			return
+		}
 		next(w, r, session)
+	})
+}
+
+// authenticate tries the access token first and falls back to the session
+// token when there is none or it is no longer accepted, so a client whose
+// access token expired is not signed out before it refreshes.
+func (m *AuthMiddleware) authenticate(r *http.Request, access, token string) (domain.Session, error) {
+	if access != "" {
+		session, err := m.auth.Authenticate(r.Context(), access)
+		if err == nil || token == "" || token == access || errors.Is(err, domain.ErrInvalidDPoPProof) {
+			return session, err
+		}
+	}
+	return m.auth.Authenticate(r.Context(), token)
+}
+
+// accessTokenFromRequest returns the access token of the access token
+// cookie, or of the Authorization header when the session cookie is not
+// sent.
+func (m *AuthMiddleware) accessTokenFromRequest(r *http.Request) string {
+	if cookie, err := r.Cookie(util.AccessTokenCookie(m.sessionCookieName)); err == nil {
+		if token := strings.TrimSpace(cookie.Value); token != "" {
+			return token
+		}
+	}
+	if token := m.sessionTokenFromRequest(r); service.IsAccessToken(token) {
+		return token
 	}
+	return ""
 }
 
 func (m *AuthMiddleware) sessionTokenFromRequest(r *http.Request) string {
@@ -0,0 +1,160 @@
+package middlewares_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/middlewares"
+	"pmv2/backend/internal/service"
+	"pmv2/backend/internal/util"
+)
+
+const testPepper = "pepper-test"
+
+// sessionRepo resolves tokens to fixed sessions; other AuthRepository
+// methods are not used by the middleware.
+type sessionRepo struct {
+	domain.AuthRepository
+	sessions map[string]domain.Session
+}
+
+func (r *sessionRepo) GetActiveSessionByTokenHash(ctx context.Context, tokenHash []byte) (domain.Session, error) {
+	for token, session := range r.sessions {
+		if string(util.HashToken(token, testPepper)) == string(tokenHash) {
+			return session, nil
+		}
+	}
+	return domain.Session{}, domain.ErrNotFound
+}
+
+func (r *sessionRepo) GetRetiredSessionToken(ctx context.Context, tokenHash []byte) (domain.RetiredSessionToken, error) {
+	return domain.RetiredSessionToken{}, domain.ErrNotFound
+}
+
+func (r *sessionRepo) TouchSession(ctx context.Context, sessionID string, usedAt time.Time) error {
+	return nil
+}
+
+func TestWithAdminSessionRequiresAdminRole(t *testing.T) {
+	repo := &sessionRepo{sessions: map[string]domain.Session{
+		"admin-token": {UserID: "u1", Email: "admin@example.com", Role: domain.UserRoleAdmin},
+		"user-token":  {UserID: "u2", Email: "admin@example.com", Role: domain.UserRoleUser},
+	}}
+	auth := middlewares.NewAuthMiddleware(service.NewAuthService(repo, nil, nil, testPepper, 0, "issuer"), "pmv2_session")
+	handler := auth.WithAdminSession(func(w http.ResponseWriter, r *http.Request, session domain.Session) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	cases := map[string]int{
+		"admin-token": http.StatusNoContent,
+		// The email alone grants nothing; only the stored role does.
+		"user-token": http.StatusForbidden,
+		"":           http.StatusUnauthorized,
+		"bogus":      http.StatusUnauthorized,
+	}
+	for token, want := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/admin/api/v1/audit", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != want {
+			t.Errorf("token %q: status = %d, want %d", token, rec.Code, want)
+		}
+	}
+}
+
+func TestStepUpBlocksSessionUntilVerified(t *testing.T) {
+	repo := &sessionRepo{sessions: map[string]domain.Session{
+		"flagged":         {ID: "s1", UserID: "u1", TOTPEnabled: true, StepUpRequired: true},
+		"flagged-no-totp": {ID: "s2", UserID: "u2", StepUpRequired: true},
+	}}
+	auth := middlewares.NewAuthMiddleware(service.NewAuthService(repo, nil, nil, testPepper, 0, "issuer"), "pmv2_session")
+	ok := func(w http.ResponseWriter, r *http.Request, session domain.Session) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	serve := func(handler http.HandlerFunc, token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/vault/items", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	if got := serve(auth.WithSession(ok), "flagged"); got != http.StatusForbidden {
+		t.Errorf("flagged session: status = %d, want 403", got)
+	}
+	if got := serve(auth.WithSessionDuringStepUp(ok), "flagged"); got != http.StatusNoContent {
+		t.Errorf("flagged session on a step-up route: status = %d, want 204", got)
+	}
+	// Without TOTP there is nothing to step up with.
+	if got := serve(auth.WithSession(ok), "flagged-no-totp"); got != http.StatusNoContent {
+		t.Errorf("flagged session without totp: status = %d, want 204", got)
+	}
+}
+
+func TestCredentialResetRestrictsSession(t *testing.T) {
+	repo := &sessionRepo{sessions: map[string]domain.Session{
+		"compromised": {ID: "s1", UserID: "u1", CredentialResetRequired: true},
+	}}
+	auth := middlewares.NewAuthMiddleware(service.NewAuthService(repo, nil, nil, testPepper, 0, "issuer"), "pmv2_session")
+	ok := func(w http.ResponseWriter, r *http.Request, session domain.Session) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	serve := func(handler http.HandlerFunc) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/vault/items", nil)
+		req.Header.Set("Authorization", "Bearer compromised")
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	if got := serve(auth.WithSession(ok)); got != http.StatusForbidden {
+		t.Errorf("restricted session: status = %d, want 403", got)
+	}
+	if got := serve(auth.WithAdminSession(ok)); got != http.StatusForbidden {
+		t.Errorf("restricted session on an admin route: status = %d, want 403", got)
+	}
+	if got := serve(auth.WithSessionDuringCredentialReset(ok)); got != http.StatusNoContent {
+		t.Errorf("restricted session on a reset route: status = %d, want 204", got)
+	}
+	// Signing out and reading the session work too.
+	if got := serve(auth.WithSessionDuringStepUp(ok)); got != http.StatusNoContent {
+		t.Errorf("restricted session on a step-up route: status = %d, want 204", got)
+	}
+}
+
+func TestStaleAccessTokenFallsBackToSessionCookie(t *testing.T) {
+	repo := &sessionRepo{sessions: map[string]domain.Session{
+		"session-token": {ID: "s1", UserID: "u1", Role: domain.UserRoleUser},
+	}}
+	auth := middlewares.NewAuthMiddleware(service.NewAuthService(repo, nil, nil, testPepper, 0, "issuer"), "pmv2_session")
+	handler := auth.WithSession(func(w http.ResponseWriter, r *http.Request, session domain.Session) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	serve := func(cookies map[string]string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/vault/items", nil)
+		for name, value := range cookies {
+			req.AddCookie(&http.Cookie{Name: name, Value: value})
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	access := util.AccessTokenCookie("pmv2_session")
+	if got := serve(map[string]string{access: "at1.stale.token", "pmv2_session": "session-token"}); got != http.StatusNoContent {
+		t.Errorf("stale access token with a session cookie: status = %d, want 204", got)
+	}
+	if got := serve(map[string]string{access: "at1.stale.token"}); got != http.StatusUnauthorized {
+		t.Errorf("stale access token alone: status = %d, want 401", got)
+	}
+}
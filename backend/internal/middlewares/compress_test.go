@@ -0,0 +1,135 @@
+package middlewares_test
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pmv2/backend/internal/middlewares"
+)
+
+func serve(t *testing.T, h http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func jsonBody(size int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `"`+strings.Repeat("a", size)+`"`)
+	})
+}
+
+func TestCompressLargeJSON(t *testing.T) {
+	rec := serve(t, middlewares.Compress(1024, jsonBody(4096)), "gzip, deflate")
+
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+		t.Fatalf("Vary = %q, want Accept-Encoding", got)
+	}
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	body, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("read gzip body: %v", err)
+	}
+	if len(body) != 4098 {
+		t.Fatalf("decompressed %d bytes, want 4098", len(body))
+	}
+}
+
+func TestCompressSkipsSmallAndRefusedBodies(t *testing.T) {
+	cases := map[string]struct {
+		handler        http.Handler
+		acceptEncoding string
+	}{
+		"below threshold":  {jsonBody(10), "gzip"},
+		"no gzip accepted": {jsonBody(4096), ""},
+		"gzip refused":     {jsonBody(4096), "gzip;q=0"},
+		"binary content": {http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = io.WriteString(w, strings.Repeat("a", 4096))
+		}), "gzip"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := serve(t, middlewares.Compress(1024, tc.handler), tc.acceptEncoding)
+			if got := rec.Header().Get("Content-Encoding"); got != "" {
+				t.Fatalf("Content-Encoding = %q, want none", got)
+			}
+		})
+	}
+}
+
+// flushRecorder is an httptest.ResponseRecorder that remembers whether the
+// handler's flush reached it.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	bodyAtFlush string
+}
+
+func (f *flushRecorder) Flush() {
+	f.bodyAtFlush = f.Body.String()
+	f.ResponseRecorder.Flush()
+}
+
+func TestWrappersPassFlushThrough(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := middlewares.Compress(1024, middlewares.RequestLogger(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: hello\n\n")
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("flush through middleware stack: %v", err)
+		}
+		_, _ = io.WriteString(w, "data: bye\n\n")
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	handler.ServeHTTP(rec, req)
+
+	if rec.bodyAtFlush != "data: hello\n\n" {
+		t.Fatalf("body at flush = %q, want the first event", rec.bodyAtFlush)
+	}
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Fatalf("event stream was encoded with %q", got)
+	}
+}
+
+func TestResponseControllerReachesUnderlyingWriter(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	var unwrapped bool
+	handler := middlewares.Compress(0, middlewares.RequestLogger(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// EnableFullDuplex is only implemented by the real writer, so it
+		// succeeds only if every wrapper unwraps.
+		unwrapped = http.NewResponseController(w).EnableFullDuplex() == nil
+	})))
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	if !unwrapped {
+		t.Fatal("http.ResponseController could not reach the server's writer")
+	}
+}
@@ -0,0 +1,96 @@
+package middlewares
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/metrics"
+	"pmv2/backend/internal/util"
+)
+
+// ConcurrencyLimiter caps how many expensive requests (imports, export
+// downloads, backup diffs, attachments) run at once, both per user and
+// across the process, so one user's giant import cannot starve everyone
+// else. A request over either limit is refused at once with Retry-After
+// rather than queued: 429 when the user already has their share running,
+// 503 when the process is full. Limits are per process, not per replica.
+type ConcurrencyLimiter struct {
+	global     chan struct{}
+	perUser    int
+	retryAfter string
+
+	mu     sync.Mutex
+	active map[string]int
+}
+
+// NewConcurrencyLimiter builds a limiter admitting global requests in
+// total and perUser per user; zero leaves that limit off. retryAfter is
+// sent as Retry-After, in whole seconds rounded up.
+func NewConcurrencyLimiter(global, perUser int, retryAfter time.Duration) *ConcurrencyLimiter {
+	l := &ConcurrencyLimiter{
+		perUser:    perUser,
+		retryAfter: strconv.Itoa(max(1, int(math.Ceil(retryAfter.Seconds())))),
+		active:     make(map[string]int),
+	}
+	if global > 0 {
+		l.global = make(chan struct{}, global)
+	}
+	return l
+}
+
+// Limit wraps a session handler; it goes inside AuthMiddleware.WithSession
+// so requests are counted against the signed-in user.
+func (l *ConcurrencyLimiter) Limit(next func(http.ResponseWriter, *http.Request, domain.Session)) func(http.ResponseWriter, *http.Request, domain.Session) {
+	return func(w http.ResponseWriter, r *http.Request, session domain.Session) {
+		if !l.acquireUser(session.UserID) {
+			metrics.ConcurrencyRejected.WithLabelValues(r.Pattern, "user").Inc()
+			w.Header().Set("Retry-After", l.retryAfter)
+			util.WriteError(w, http.StatusTooManyRequests, "too_many_concurrent_requests", "another request like this one is still running, try again when it finishes")
+			return
+		}
+		defer l.releaseUser(session.UserID)
+
+		if l.global != nil {
+			select {
+			case l.global <- struct{}{}:
+				defer func() { <-l.global }()
+			default:
+				metrics.ConcurrencyRejected.WithLabelValues(r.Pattern, "global").Inc()
+				w.Header().Set("Retry-After", l.retryAfter)
+				util.WriteError(w, http.StatusServiceUnavailable, domain.ErrServerBusy.Code, domain.ErrServerBusy.Message)
+				return
+			}
+		}
+		next(w, r, session)
+	}
+}
+
+func (l *ConcurrencyLimiter) acquireUser(userID string) bool {
+	if l.perUser <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[userID] >= l.perUser {
+		return false
+	}
+	l.active[userID]++
+	return true
+}
+
+func (l *ConcurrencyLimiter) releaseUser(userID string) {
+	if l.perUser <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[userID] <= 1 {
+		delete(l.active, userID)
+		return
+	}
+	l.active[userID]--
+}
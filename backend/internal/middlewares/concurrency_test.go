@@ -0,0 +1,71 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/middlewares"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	limiter := middlewares.NewConcurrencyLimiter(2, 1, 1500*time.Millisecond)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := limiter.Limit(func(w http.ResponseWriter, r *http.Request, session domain.Session) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	done := make(chan int, 2)
+	run := func(userID string) {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vault/items/bulk", nil), domain.Session{UserID: userID})
+		done <- rec.Code
+	}
+	serve := func(userID string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vault/items/bulk", nil), domain.Session{UserID: userID})
+		return rec
+	}
+
+	go run("alice")
+	<-started
+
+	rec := serve("alice")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request for the same user: status = %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Fatalf("Retry-After = %q, want 2", got)
+	}
+
+	go run("bob")
+	<-started
+
+	rec = serve("carol")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("over the global limit: status = %d, want 503", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Fatal("over the global limit: Retry-After missing")
+	}
+
+	release <- struct{}{}
+	release <- struct{}{}
+	for range 2 {
+		if code := <-done; code != http.StatusNoContent {
+			t.Fatalf("admitted request: status = %d", code)
+		}
+	}
+
+	go run("alice")
+	<-started
+	release <- struct{}{}
+	if code := <-done; code != http.StatusNoContent {
+		t.Fatalf("after release: status = %d, want the slot freed", code)
+	}
+}
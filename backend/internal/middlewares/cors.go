@@ -2,43 +2,151 @@ package middlewares
 
 import (
 	"net/http"
+	"net/url"
 	"strings"
 )
 
-func CORS(allowedOrigins string, next http.Handler) http.Handler {
-	origins := strings.Split(allowedOrigins, ",")
-	for i := range origins {
-		origins[i] = strings.TrimSpace(origins[i])
-	}
+// CORSPolicy lists the browser origins allowed to call the API. Entries are
+// exact origins ("https://vault.example.com", "chrome-extension://<id>") or
+// wildcard subdomain patterns ("https://*.example.com", which does not match
+// example.com itself).
+type CORSPolicy struct {
+	// Origins may send credentialed requests (session cookies). "*" is
+	// ignored here.
+	Origins []string
+	// AnonymousOrigins may call the API without credentials only. "*" is
+	// accepted here and matches every origin.
+	AnonymousOrigins []string
+}
+
+const (
+	corsAllowMethods  = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	corsAllowHeaders  = "Content-Type, Authorization, X-Requested-With, If-None-Match, DPoP, X-Client-Type"
+	corsExposeHeaders = "ETag, Retry-After, Content-Disposition, API-Version, Deprecation, Sunset, Link, X-Request-ID"
+)
+
+// CORS answers preflight requests itself, so they never reach the rate
+// limiter or the auth middleware, and adds the CORS response headers for
+// allowed origins. Requests from other origins are passed through without
+// them (the browser then withholds the response); their preflights get 403.
+func CORS(policy CORSPolicy, next http.Handler) http.Handler {
+	credentialed := parseOriginPatterns(policy.Origins, false)
+	anonymous := parseOriginPatterns(policy.AnonymousOrigins, true)
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		requestOrigin := strings.TrimSpace(r.Header.Get("Origin"))
-
-		allowed := false
-		if requestOrigin != "" {
-			for _, o := range origins {
-				if o == "*" || o == requestOrigin {
-					allowed = true
-					break
-				}
-			}
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
 		}
 
-		if allowed {
-			w.Header().Set("Access-Control-Allow-Origin", requestOrigin)
-			w.Header().Set("Access-Control-Allow-Credentials", "true")
-			w.Header().Add("Vary", "Origin")
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		withCredentials := matchOrigin(credentialed, origin)
+		if !withCredentials && !matchOrigin(anonymous, origin) {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
 		}
 
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
-		w.Header().Set("Access-Control-Max-Age", "86400")
+		h.Set("Access-Control-Allow-Origin", origin)
+		if withCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
 
-		if r.Method == http.MethodOptions {
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", corsAllowMethods)
+			h.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			h.Set("Access-Control-Max-Age", "86400")
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
 
+		h.Set("Access-Control-Expose-Headers", corsExposeHeaders)
 		next.ServeHTTP(w, r)
 	})
 }
+
+type originPattern struct {
+	any    bool
+	scheme string
+	// host is the exact host, or the required suffix (".example.com")
+	// when wildcard is set.
+	host     string
+	wildcard bool
+	port     string
+}
+
+func parseOriginPatterns(entries []string, allowAny bool) []originPattern {
+	patterns := make([]originPattern, 0, len(entries))
+	for _, entry := range entries {
+		if p, ok := parseOriginPattern(entry); ok && (allowAny || !p.any) {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+func parseOriginPattern(raw string) (originPattern, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "*" {
+		return originPattern{any: true}, true
+	}
+
+	var p originPattern
+	host := raw
+	if strings.Contains(raw, "://*.") {
+		p.wildcard = true
+		host = strings.Replace(raw, "://*.", "://", 1)
+	}
+	scheme, hostname, port, ok := splitOrigin(host)
+	if !ok {
+		return originPattern{}, false
+	}
+	p.scheme, p.host, p.port = scheme, hostname, port
+	if p.wildcard {
+		p.host = "." + hostname
+	}
+	return p, true
+}
+
+// splitOrigin parses scheme://host[:port] and rejects anything with a path,
+// query or credentials.
+func splitOrigin(origin string) (scheme, host, port string, ok bool) {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.User != nil ||
+		(u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return "", "", "", false
+	}
+	return strings.ToLower(u.Scheme), strings.ToLower(u.Hostname()), u.Port(), true
+}
+
+func matchOrigin(patterns []originPattern, origin string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	scheme, host, port, ok := splitOrigin(origin)
+	for _, p := range patterns {
+		if p.any {
+			return true
+		}
+		if !ok || p.scheme != scheme || p.port != port {
+			continue
+		}
+		if p.wildcard {
+			if strings.HasSuffix(host, p.host) && len(host) > len(p.host) {
+				return true
+			}
+		} else if p.host == host {
+			return true
+		}
+	}
+	return false
+}
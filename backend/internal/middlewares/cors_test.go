@@ -0,0 +1,116 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pmv2/backend/internal/middlewares"
+)
+
+var testCORSPolicy = middlewares.CORSPolicy{
+	Origins:          []string{"https://vault.example.com", "https://*.admin.example.com", "chrome-extension://abcdef", "*"},
+	AnonymousOrigins: []string{"https://status.example.org"},
+}
+
+func corsRequest(method, origin string, preflight bool) *http.Request {
+	req := httptest.NewRequest(method, "/vault/items", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if preflight {
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	}
+	return req
+}
+
+func TestCORSMatchesOrigins(t *testing.T) {
+	handler := middlewares.CORS(testCORSPolicy, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	cases := []struct {
+		origin      string
+		allowed     bool
+		credentials bool
+	}{
+		{"https://vault.example.com", true, true},
+		{"https://VAULT.example.com", true, true},
+		{"http://vault.example.com", false, false},
+		{"https://vault.example.com:8443", false, false},
+		{"https://eu.admin.example.com", true, true},
+		{"https://a.b.admin.example.com", true, true},
+		{"https://admin.example.com", false, false},
+		{"https://evil-admin.example.com", false, false},
+		{"chrome-extension://abcdef", true, true},
+		{"https://status.example.org", true, false},
+		{"https://attacker.example.net", false, false},
+		{"null", false, false},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, corsRequest(http.MethodGet, tc.origin, false))
+
+		gotOrigin := rec.Header().Get("Access-Control-Allow-Origin")
+		if tc.allowed != (gotOrigin == tc.origin) {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q, allowed %v", tc.origin, gotOrigin, tc.allowed)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Credentials") == "true"; got != tc.credentials {
+			t.Errorf("%s: credentials = %v, want %v", tc.origin, got, tc.credentials)
+		}
+		if rec.Header().Get("Vary") != "Origin" {
+			t.Errorf("%s: Vary = %q, want Origin", tc.origin, rec.Header().Get("Vary"))
+		}
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, the request should still reach the handler", tc.origin, rec.Code)
+		}
+	}
+}
+
+func TestCORSPreflightShortCircuits(t *testing.T) {
+	reached := false
+	handler := middlewares.CORS(testCORSPolicy, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, corsRequest(http.MethodOptions, "https://vault.example.com", true))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("preflight status = %d, want 204", rec.Code)
+	}
+	if rec.Header().Get("Access-Control-Allow-Methods") == "" || rec.Header().Get("Access-Control-Allow-Headers") == "" {
+		t.Fatal("preflight is missing the allow headers")
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, corsRequest(http.MethodOptions, "https://attacker.example.net", true))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("disallowed preflight status = %d, want 403", rec.Code)
+	}
+	if reached {
+		t.Fatal("a preflight reached the wrapped handler")
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, corsRequest(http.MethodOptions, "https://vault.example.com", false))
+	if !reached {
+		t.Fatal("a plain OPTIONS request must reach the handler")
+	}
+}
+
+func TestCORSIgnoresWildcardForCredentials(t *testing.T) {
+	handler := middlewares.CORS(middlewares.CORSPolicy{Origins: []string{"*"}}, http.NotFoundHandler())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, corsRequest(http.MethodGet, "https://attacker.example.net", false))
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatal(`"*" must not allow credentialed requests from every origin`)
+	}
+
+	handler = middlewares.CORS(middlewares.CORSPolicy{AnonymousOrigins: []string{"*"}}, http.NotFoundHandler())
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, corsRequest(http.MethodGet, "https://anyone.example.net", false))
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://anyone.example.net" || rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatalf("anonymous wildcard headers = %v", rec.Header())
+	}
+}
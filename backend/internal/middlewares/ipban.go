@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pmv2/backend/internal/util"
+)
+
+// IPBans is what BanIPs needs from the ban service.
+type IPBans interface {
+	BannedUntil(ip string) (time.Time, bool)
+	Strike(ctx context.Context, ip, reason string) error
+}
+
+// BanIPs answers 403 to banned client addresses, with Retry-After set to
+// the end of the ban, and counts every 429 answered to anyone else as a
+// strike against their address. Place it outside the rate limiters so it
+// sees their responses. Paths starting with one of exemptPrefixes are
+// neither refused nor counted.
+func BanIPs(bans IPBans, exemptPrefixes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if hasAnyPrefix(r.URL.Path, exemptPrefixes) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ip := util.ClientIPFromRequest(r)
+			if until, banned := bans.BannedUntil(ip); banned {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(until).Seconds()))))
+				util.WriteError(w, http.StatusForbidden, "ip_banned", "too many failed or rate limited requests from this address, please try again later")
+				return
+			}
+
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			if rec.status == http.StatusTooManyRequests {
+				if err := bans.Strike(context.WithoutCancel(r.Context()), ip, "rate_limited"); err != nil {
+					slog.WarnContext(r.Context(), "record ip strike failed", slog.Any("error", err))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package middlewares_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/middlewares"
+)
+
+type fakeIPBans struct {
+	until   map[string]time.Time
+	strikes []string
+}
+
+func (f *fakeIPBans) BannedUntil(ip string) (time.Time, bool) {
+	until, ok := f.until[ip]
+	return until, ok
+}
+
+func (f *fakeIPBans) Strike(_ context.Context, ip, reason string) error {
+	f.strikes = append(f.strikes, ip+" "+reason)
+	return nil
+}
+
+func TestBanIPs(t *testing.T) {
+	bans := &fakeIPBans{until: map[string]time.Time{"192.0.2.1": time.Now().Add(time.Minute)}}
+	status := http.StatusNoContent
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(status) })
+	handler := middlewares.BanIPs(bans, "/admin/")(next)
+
+	serve := func(ip, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = ip + ":4321"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("192.0.2.1", "/api/v1/vault/items")
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("banned: status = %d, want 403", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "60" {
+		t.Fatalf("banned: Retry-After = %q, want 60", got)
+	}
+	if rec := serve("192.0.2.1", "/admin/api/v1/ip-bans"); rec.Code != http.StatusNoContent {
+		t.Fatalf("banned admin path: status = %d, want it exempt", rec.Code)
+	}
+	if rec := serve("192.0.2.2", "/api/v1/vault/items"); rec.Code != http.StatusNoContent {
+		t.Fatalf("not banned: status = %d", rec.Code)
+	}
+	if len(bans.strikes) != 0 {
+		t.Fatalf("strikes = %v, want none", bans.strikes)
+	}
+
+	status = http.StatusTooManyRequests
+	serve("192.0.2.2", "/api/v1/vault/items")
+	if len(bans.strikes) != 1 || bans.strikes[0] != "192.0.2.2 rate_limited" {
+		t.Fatalf("strikes = %v, want one rate_limited strike", bans.strikes)
+	}
+}
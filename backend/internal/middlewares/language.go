@@ -0,0 +1,22 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"pmv2/backend/internal/i18n"
+)
+
+// WithLanguage picks the response language from Accept-Language and makes it
+// available both on the request context (for queued emails) and on the
+// response writer (for util.WriteError).
+func WithLanguage(bundle *i18n.Bundle) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			loc := bundle.Localizer(bundle.Match(r.Header.Get("Accept-Language")))
+			h := w.Header()
+			h.Add("Vary", "Accept-Language")
+			h.Set("Content-Language", loc.Language())
+			next.ServeHTTP(i18n.WithWriter(w, loc), r.WithContext(i18n.WithLocalizer(r.Context(), loc)))
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package middlewares_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/i18n"
+	"pmv2/backend/internal/middlewares"
+	"pmv2/backend/internal/util"
+)
+
+func TestWithLanguageLocalizesErrors(t *testing.T) {
+	bundle := i18n.NewBundle()
+	bundle.Register("de", i18n.Messages{"error.email_taken": "E-Mail bereits registriert"})
+
+	var ctxLang string
+	handler := middlewares.WithLanguage(bundle)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctxLang = i18n.FromContext(r.Context()).Language()
+		util.WriteError(w, http.StatusConflict, "email_taken", "email already registered")
+	}))
+
+	cases := []struct {
+		acceptLanguage string
+		wantLang       string
+		wantMessage    string
+	}{
+		{"de-DE,de;q=0.9", "de", "E-Mail bereits registriert"},
+		{"fr", "en", "email already registered"},
+		{"", "en", "email already registered"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/register", nil)
+		req.Header.Set("Accept-Language", tc.acceptLanguage)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var resp dto.ErrorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Error != "email_taken" {
+			t.Errorf("%q: code = %q, codes must not be translated", tc.acceptLanguage, resp.Error)
+		}
+		if resp.Message != tc.wantMessage {
+			t.Errorf("%q: message = %q, want %q", tc.acceptLanguage, resp.Message, tc.wantMessage)
+		}
+		if got := rec.Header().Get("Content-Language"); got != tc.wantLang {
+			t.Errorf("%q: Content-Language = %q, want %q", tc.acceptLanguage, got, tc.wantLang)
+		}
+		if ctxLang != tc.wantLang {
+			t.Errorf("%q: context language = %q, want %q", tc.acceptLanguage, ctxLang, tc.wantLang)
+		}
+	}
+}
@@ -20,6 +20,19 @@ func (rr *responseRecorder) WriteHeader(code int) {
 	rr.ResponseWriter.WriteHeader(code)
 }
 
+// Flush passes through to the underlying writer so streaming responses
+// (SSE, large downloads) are not held back by the recorder.
+func (rr *responseRecorder) Flush() {
+	if f, ok := rr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
+
 // RequestLogger returns a middleware that emits one structured log line per
 // incoming HTTP request, including: method, path, client IP, status code, and
 // elapsed duration.
@@ -36,6 +49,7 @@ func RequestLogger(logger *slog.Logger) func(http.Handler) http.Handler {
 			logger.InfoContext(
 				r.Context(),
 				"request",
+				slog.String("request_id", util.RequestMetaFromContext(r.Context()).RequestID),
 				slog.String("method", r.Method),
 				slog.String("path", r.URL.Path),
 				slog.String("ip", util.ClientIPFromRequest(r)),
@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/util"
+)
+
+const defaultMaintenanceMessage = "the service is undergoing maintenance, please try again later"
+
+// Maintenance answers 503 while state reports maintenance mode, except for
+// requests whose path starts with one of exemptPrefixes (health checks, the
+// admin API and whatever admins need to sign in). state is called on every
+// request and must be cheap. retryAfter is sent as Retry-After, in whole
+// seconds rounded up; zero leaves the header out.
+func Maintenance(state func() domain.MaintenanceState, retryAfter time.Duration, exemptPrefixes ...string) func(http.Handler) http.Handler {
+	var retryAfterHeader string
+	if retryAfter > 0 {
+		retryAfterHeader = strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			current := state()
+			if !current.Enabled || hasAnyPrefix(r.URL.Path, exemptPrefixes) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			message := current.Message
+			if message == "" {
+				message = defaultMaintenanceMessage
+			}
+			if retryAfterHeader != "" {
+				w.Header().Set("Retry-After", retryAfterHeader)
+			}
+			util.WriteError(w, http.StatusServiceUnavailable, "maintenance", message)
+		})
+	}
+}
+
+func hasAnyPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package middlewares_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/middlewares"
+)
+
+func TestMaintenance(t *testing.T) {
+	state := domain.MaintenanceState{}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	handler := middlewares.Maintenance(func() domain.MaintenanceState { return state }, 90*time.Second+time.Millisecond, "/healthz", "/admin/")(next)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	if rec := serve("/api/v1/vault/items"); rec.Code != http.StatusNoContent {
+		t.Fatalf("off: status = %d", rec.Code)
+	}
+
+	state = domain.MaintenanceState{Enabled: true, Message: "back at noon"}
+	rec := serve("/api/v1/vault/items")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("on: status = %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "91" {
+		t.Fatalf("on: Retry-After = %q, want 91", got)
+	}
+	var body dto.ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Error != "maintenance" || body.Message != "back at noon" {
+		t.Fatalf("on: body = %+v, %v", body, err)
+	}
+
+	for _, path := range []string{"/healthz", "/admin/api/v1/maintenance"} {
+		if rec := serve(path); rec.Code != http.StatusNoContent {
+			t.Errorf("on: %s status = %d, want it exempt", path, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"pmv2/backend/internal/metrics"
+)
+
+// Instrument records the request count and latency of next under route, the
+// pattern it was registered with (so path parameters do not explode the
+// label set).
+func Instrument(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+
+		metrics.HTTPRequests.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
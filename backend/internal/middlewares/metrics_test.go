@@ -0,0 +1,35 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pmv2/backend/internal/metrics"
+	"pmv2/backend/internal/middlewares"
+)
+
+func TestInstrumentRecordsRouteMetrics(t *testing.T) {
+	handler := middlewares.Instrument("/vault/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/vault/items/123", nil))
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		`pmv2_http_requests_total{method="GET",route="/vault/items/{id}",status="404"} 1`,
+		`pmv2_http_request_duration_seconds_count{method="GET",route="/vault/items/{id}"} 1`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output is missing %q", want)
+		}
+	}
+	if strings.Contains(body, "/vault/items/123") {
+		t.Error("the raw path leaked into a label")
+	}
+}
@@ -1,71 +1,156 @@
 package middlewares
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
-	"net"
+	"io"
+	"log/slog"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
 
+	"pmv2/backend/internal/util"
+
 	"golang.org/x/time/rate"
 )
 
+// maxAccountPeekBytes caps how much of a request body AccountMiddleware
+// buffers while looking for the email field.
+const maxAccountPeekBytes = 64 << 10
+
+// RateLimitStore decides whether a request identified by key may proceed.
+// Implementations must be safe for concurrent use. The in-memory store only
+// limits a single process; the Redis store shares limits across replicas.
+type RateLimitStore interface {
+	Allow(ctx context.Context, key string, limit rate.Limit, burst int) (bool, error)
+}
+
 type clientContext struct {
 	limiter  *rate.Limiter
 	lastSeen time.Time
 }
 
-type RateLimiter struct {
+// MemoryRateLimitStore keeps one token bucket per key in process memory.
+type MemoryRateLimitStore struct {
 	clients map[string]*clientContext
 	mu      sync.Mutex
-	rate    rate.Limit
-	burst   int
 }
 
-func NewRateLimiter(r rate.Limit, b int) *RateLimiter {
-	rl := &RateLimiter{
+// NewMemoryRateLimitStore returns an empty store. Call RunCleanup in the
+// background to evict idle keys.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
 		clients: make(map[string]*clientContext),
-		rate:    r,
-		burst:   b,
 	}
-
-	go rl.cleanup()
-	return rl
 }
 
-func (rl *RateLimiter) cleanup() {
+// RunCleanup evicts keys idle for more than three minutes, once a minute,
+// until ctx is cancelled.
+func (s *MemoryRateLimitStore) RunCleanup(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
 	for {
-		time.Sleep(time.Minute)
-		rl.mu.Lock()
-		for ip, client := range rl.clients {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		for key, client := range s.clients {
 			if time.Since(client.lastSeen) > 3*time.Minute {
-				delete(rl.clients, ip)
+				delete(s.clients, key)
 			}
 		}
-		rl.mu.Unlock()
+		s.mu.Unlock()
+	}
+}
+
+func (s *MemoryRateLimitStore) Allow(_ context.Context, key string, limit rate.Limit, burst int) (bool, error) {
+	s.mu.Lock()
+	if _, found := s.clients[key]; !found {
+		s.clients[key] = &clientContext{limiter: rate.NewLimiter(limit, burst)}
 	}
+	s.clients[key].lastSeen = time.Now()
+	limiter := s.clients[key].limiter
+	s.mu.Unlock()
+
+	return limiter.Allow(), nil
+}
+
+type RateLimiter struct {
+	store RateLimitStore
+	scope string
+	rate  rate.Limit
+	burst int
+
+	canonicalEmails bool
+}
+
+func NewRateLimiter(r rate.Limit, b int) *RateLimiter {
+	return NewRateLimiterWithStore(nil, "", r, b)
+}
+
+// NewRateLimiterWithStore builds a limiter backed by the given store. scope
+// namespaces the keys so several limiters can share one store without
+// sharing buckets. A nil store falls back to a process-local memory store
+// whose cleanup runs for the life of the process.
+func NewRateLimiterWithStore(store RateLimitStore, scope string, r rate.Limit, b int) *RateLimiter {
+	if store == nil {
+		memory := NewMemoryRateLimitStore()
+		go memory.RunCleanup(context.Background())
+		store = memory
+	}
+	return &RateLimiter{
+		store: store,
+		scope: scope,
+		rate:  r,
+		burst: b,
+	}
+}
+
+// Enabled reports whether the limiter restricts anything. A zero rate or
+// burst is treated as "no limit" so routes can be switched off via config.
+func (rl *RateLimiter) Enabled() bool {
+	return rl.rate > 0 && rl.burst > 0
 }
 
 func (rl *RateLimiter) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	if !rl.Enabled() {
+		return next
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
-		ip := clientIPFromRequest(r)
+		if !rl.allow(r, util.ClientIPFromRequest(r)) {
+			writeRateLimited(w)
+			return
+		}
 
-		rl.mu.Lock()
-		if _, found := rl.clients[ip]; !found {
-			rl.clients[ip] = &clientContext{limiter: rate.NewLimiter(rl.rate, rl.burst)}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// AccountMiddleware limits requests per target account rather than per IP,
+// keyed on the normalized "email" field of the JSON body. This keeps
+// throttling a single account effective when an attacker rotates proxies,
+// without penalising everyone else behind the same NAT. Requests without a
+// readable email pass through; the per-IP limiter still applies to them.
+func (rl *RateLimiter) AccountMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	if !rl.Enabled() {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		email := peekEmail(r)
+		if rl.canonicalEmails {
+			email = util.CanonicalEmail(email)
 		}
-		rl.clients[ip].lastSeen = time.Now()
-		limiter := rl.clients[ip].limiter
-		rl.mu.Unlock()
-
-		if !limiter.Allow() {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusTooManyRequests)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error":   "rate_limit_exceeded",
-				"message": "too many requests, please try again later",
-			})
+		if email != "" && !rl.allow(r, "account:"+hashAccountKey(email)) {
+			writeRateLimited(w)
 			return
 		}
 
@@ -73,17 +158,86 @@ func (rl *RateLimiter) Middleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-func clientIPFromRequest(r *http.Request) string {
-	forwarded := strings.TrimSpace(r.Header.Get("X-Forwarded-For"))
-	if forwarded != "" {
-		parts := strings.Split(forwarded, ",")
-		if len(parts) > 0 {
-			return strings.TrimSpace(parts[0])
+// UseCanonicalEmails keys AccountMiddleware on the address with provider
+// aliases folded (util.CanonicalEmail), so a.da+1@gmail.com and
+// ada+2@gmail.com share the bucket of ada@gmail.com.
+func (rl *RateLimiter) UseCanonicalEmails() {
+	rl.canonicalEmails = true
+}
+
+// Handler applies the limiter to every request except those whose path is
+// listed in exemptPaths (e.g. health checks and metrics scrapes).
+func (rl *RateLimiter) Handler(next http.Handler, exemptPaths ...string) http.Handler {
+	if !rl.Enabled() {
+		return next
+	}
+
+	exempt := make(map[string]struct{}, len(exemptPaths))
+	for _, path := range exemptPaths {
+		exempt[path] = struct{}{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := exempt[r.URL.Path]; ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !rl.allow(r, util.ClientIPFromRequest(r)) {
+			writeRateLimited(w)
+			return
 		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (rl *RateLimiter) allow(r *http.Request, key string) bool {
+	if rl.scope != "" {
+		key = rl.scope + ":" + key
 	}
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	allowed, err := rl.store.Allow(r.Context(), key, rl.rate, rl.burst)
 	if err != nil {
-		return r.RemoteAddr
+		// Fail open: a broken limiter backend must not take the API down.
+		slog.WarnContext(r.Context(), "rate limit store unavailable", slog.String("scope", rl.scope), slog.Any("error", err))
+		return true
+	}
+	return allowed
+}
+
+func writeRateLimited(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":   "rate_limit_exceeded",
+		"message": "too many requests, please try again later",
+	})
+}
+
+// peekEmail reads the email field from a JSON body and restores the body so
+// the handler can decode it again.
+func peekEmail(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxAccountPeekBytes+1))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if err != nil || len(body) > maxAccountPeekBytes {
+		return ""
+	}
+
+	var payload struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
 	}
-	return ip
+	return util.NormalizeEmail(payload.Email)
+}
+
+// hashAccountKey keeps raw email addresses out of the limiter store.
+func hashAccountKey(email string) string {
+	sum := sha256.Sum256([]byte(email))
+	return hex.EncodeToString(sum[:16])
 }
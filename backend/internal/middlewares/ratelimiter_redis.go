@@ -0,0 +1,74 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+)
+
+// tokenBucketScript refills the bucket from the elapsed time (using the Redis
+// server clock so replicas never disagree) and takes one token if available.
+// It returns 1 when the request is allowed and 0 otherwise.
+var tokenBucketScript = redis.NewScript(`
+local key = KEYS[1]
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local ttl = tonumber(ARGV[3])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+
+local elapsed = math.max(0, now - ts) / 1000
+tokens = math.min(burst, tokens + elapsed * rate)
+
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+
+redis.call('HSET', key, 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', key, ttl)
+return allowed
+`)
+
+// RedisRateLimitStore implements a token bucket shared by every API replica
+// pointing at the same Redis instance.
+type RedisRateLimitStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisRateLimitStore(client *redis.Client, prefix string) *RedisRateLimitStore {
+	if prefix == "" {
+		prefix = "pmv2:ratelimit:"
+	}
+	return &RedisRateLimitStore{client: client, prefix: prefix}
+}
+
+func (s *RedisRateLimitStore) Allow(ctx context.Context, key string, limit rate.Limit, burst int) (bool, error) {
+	if limit <= 0 || burst <= 0 {
+		return false, nil
+	}
+
+	// Keep the key around long enough for an empty bucket to refill completely.
+	refill := float64(burst) / float64(limit) * 1000
+	ttl := int64(math.Ceil(refill)) + 1000
+
+	res, err := tokenBucketScript.Run(ctx, s.client, []string{s.prefix + key}, float64(limit), burst, ttl).Int()
+	if err != nil {
+		return false, fmt.Errorf("run token bucket script: %w", err)
+	}
+	return res == 1, nil
+}
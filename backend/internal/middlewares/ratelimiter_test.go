@@ -0,0 +1,227 @@
+package middlewares_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+
+	"pmv2/backend/internal/middlewares"
+)
+
+func newRedisStore(t *testing.T) (*middlewares.RedisRateLimitStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return middlewares.NewRedisRateLimitStore(client, ""), mr
+}
+
+func TestRateLimitStoresEnforceBurst(t *testing.T) {
+	redisStore, _ := newRedisStore(t)
+	stores := map[string]middlewares.RateLimitStore{
+		"memory": middlewares.NewMemoryRateLimitStore(),
+		"redis":  redisStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for i := 0; i < 3; i++ {
+				allowed, err := store.Allow(ctx, "k", rate.Limit(0.001), 3)
+				if err != nil {
+					t.Fatalf("allow %d: %v", i, err)
+				}
+				if !allowed {
+					t.Fatalf("request %d within burst was rejected", i)
+				}
+			}
+			if allowed, _ := store.Allow(ctx, "k", rate.Limit(0.001), 3); allowed {
+				t.Fatal("request beyond burst was allowed")
+			}
+			if allowed, _ := store.Allow(ctx, "other", rate.Limit(0.001), 3); !allowed {
+				t.Fatal("a different key shared the exhausted bucket")
+			}
+		})
+	}
+}
+
+func TestRedisRateLimitScriptRefillsAndExpires(t *testing.T) {
+	store, mr := newRedisStore(t)
+	ctx := context.Background()
+
+	// 2 tokens/s, burst 1: the second immediate request is rejected.
+	if allowed, err := store.Allow(ctx, "login:1.2.3.4", 2, 1); err != nil || !allowed {
+		t.Fatalf("first request: allowed=%v err=%v", allowed, err)
+	}
+	if allowed, _ := store.Allow(ctx, "login:1.2.3.4", 2, 1); allowed {
+		t.Fatal("second request was allowed with an empty bucket")
+	}
+
+	// The script refills from the Redis server clock.
+	mr.SetTime(time.Now().Add(time.Second))
+	if allowed, _ := store.Allow(ctx, "login:1.2.3.4", 2, 1); !allowed {
+		t.Fatal("bucket did not refill after a second")
+	}
+
+	// The key lives just long enough for the bucket to refill completely
+	// (burst/rate = 500ms, plus a second of slack).
+	ttl := mr.TTL("pmv2:ratelimit:login:1.2.3.4")
+	if ttl <= 0 || ttl > 1500*time.Millisecond {
+		t.Fatalf("bucket TTL = %s, want (0, 1.5s]", ttl)
+	}
+	mr.FastForward(2 * time.Second)
+	if mr.Exists("pmv2:ratelimit:login:1.2.3.4") {
+		t.Fatal("bucket key did not expire")
+	}
+}
+
+func TestRedisRateLimitStoreDisabledRule(t *testing.T) {
+	store, _ := newRedisStore(t)
+	if allowed, err := store.Allow(context.Background(), "k", 0, 0); err != nil || allowed {
+		t.Fatalf("zero rule: allowed=%v err=%v, want rejected without error", allowed, err)
+	}
+}
+
+func TestRateLimiterFailsOpenWhenStoreIsDown(t *testing.T) {
+	store, mr := newRedisStore(t)
+	mr.Close()
+
+	limiter := middlewares.NewRateLimiterWithStore(store, "login", 1, 1)
+	handler := limiter.Middleware(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/login", nil))
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("request %d: status %d, want 204 (fail open)", i, rec.Code)
+		}
+	}
+}
+
+func TestRateLimiterIgnoresSpoofedForwardedFor(t *testing.T) {
+	limiter := middlewares.NewRateLimiterWithStore(middlewares.NewMemoryRateLimitStore(), "login", rate.Limit(0.001), 2)
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	handler := middlewares.WithRequestMeta(trusted)(limiter.Middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	send := func(remoteAddr, xff string) int {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", xff)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// A direct client rotating the header still shares one bucket.
+	for i, xff := range []string{"1.1.1.1", "2.2.2.2"} {
+		if code := send("203.0.113.7:1000", xff); code != http.StatusNoContent {
+			t.Fatalf("request %d: status %d", i, code)
+		}
+	}
+	if code := send("203.0.113.7:1000", "3.3.3.3"); code != http.StatusTooManyRequests {
+		t.Fatalf("rotating X-Forwarded-For bypassed the limit (status %d)", code)
+	}
+
+	// Behind the trusted proxy, client-prepended entries do not help either.
+	for i, xff := range []string{"1.1.1.1, 198.51.100.9", "2.2.2.2, 198.51.100.9"} {
+		if code := send("10.0.0.1:1000", xff); code != http.StatusNoContent {
+			t.Fatalf("proxied request %d: status %d", i, code)
+		}
+	}
+	if code := send("10.0.0.1:1000", "3.3.3.3, 198.51.100.9"); code != http.StatusTooManyRequests {
+		t.Fatalf("client-supplied hop bypassed the limit behind the proxy (status %d)", code)
+	}
+}
+
+func TestRateLimiterHandlerExemptsPaths(t *testing.T) {
+	limiter := middlewares.NewRateLimiterWithStore(middlewares.NewMemoryRateLimitStore(), "global", rate.Limit(0.001), 1)
+	handler := limiter.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), "/healthz")
+
+	send := func(path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	if code := send("/api/v1/vault/items"); code != http.StatusNoContent {
+		t.Fatalf("first request: status %d", code)
+	}
+	if code := send("/api/v1/vault/items"); code != http.StatusTooManyRequests {
+		t.Fatalf("second request: status %d, want 429", code)
+	}
+	for i := 0; i < 3; i++ {
+		if code := send("/healthz"); code != http.StatusNoContent {
+			t.Fatalf("exempt path was limited (status %d)", code)
+		}
+	}
+}
+
+func TestRateLimiterZeroRuleDisables(t *testing.T) {
+	limiter := middlewares.NewRateLimiterWithStore(middlewares.NewMemoryRateLimitStore(), "login", 0, 0)
+	if limiter.Enabled() {
+		t.Fatal("0:0 limiter reports enabled")
+	}
+	handler := limiter.Middleware(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	for i := 0; i < 5; i++ {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/login", nil))
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("request %d: status %d", i, rec.Code)
+		}
+	}
+}
+
+func TestAccountMiddlewareLimitsPerEmailAcrossIPs(t *testing.T) {
+	limiter := middlewares.NewRateLimiterWithStore(middlewares.NewMemoryRateLimitStore(), "login_account", rate.Limit(0.001), 2)
+	var bodies []string
+	handler := limiter.AccountMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	send := func(remoteAddr, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	// The same account, normalized, from different IPs shares a bucket.
+	if code := send("198.51.100.1:1", `{"email":"Alice@Example.com","password":"x"}`); code != http.StatusNoContent {
+		t.Fatalf("first attempt: status %d", code)
+	}
+	if code := send("198.51.100.2:1", `{"email":"alice@example.com ","password":"y"}`); code != http.StatusNoContent {
+		t.Fatalf("second attempt: status %d", code)
+	}
+	if code := send("198.51.100.3:1", `{"email":"alice@example.com","password":"z"}`); code != http.StatusTooManyRequests {
+		t.Fatalf("third attempt from a new IP: status %d, want 429", code)
+	}
+
+	// Other accounts and bodies without an email are not affected.
+	if code := send("198.51.100.3:1", `{"email":"bob@example.com"}`); code != http.StatusNoContent {
+		t.Fatalf("other account: status %d", code)
+	}
+	if code := send("198.51.100.3:1", `not json`); code != http.StatusNoContent {
+		t.Fatalf("unparseable body: status %d", code)
+	}
+
+	// The handler still sees the full body after the middleware peeked.
+	if bodies[0] != `{"email":"Alice@Example.com","password":"x"}` {
+		t.Fatalf("handler body = %q", bodies[0])
+	}
+}
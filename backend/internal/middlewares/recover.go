@@ -0,0 +1,85 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/i18n"
+	"pmv2/backend/internal/metrics"
+	"pmv2/backend/internal/util"
+)
+
+// Recover turns a panic in next into a 500 carrying the request ID, logs it
+// with its stack trace and counts it in metrics.HTTPPanics, so one bad
+// handler neither drops the connection nor goes unnoticed. When the handler
+// had already started its response only the log and metric are produced.
+// http.ErrAbortHandler is re-raised: it is how a handler asks to abort.
+// Install it inside WithRequestMeta so the request ID is known.
+func Recover(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pw := &panicWriter{ResponseWriter: w}
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if err, ok := p.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(p)
+				}
+
+				requestID := util.RequestMetaFromContext(r.Context()).RequestID
+				metrics.HTTPPanics.WithLabelValues(r.Pattern).Inc()
+				logger.ErrorContext(r.Context(), "handler panic",
+					slog.String("request_id", requestID),
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("panic", fmt.Sprint(p)),
+					slog.String("stack", string(debug.Stack())),
+				)
+				if pw.wroteHeader {
+					return
+				}
+				util.WriteJSON(w, http.StatusInternalServerError, dto.ErrorResponse{
+					Error:     "internal_error",
+					Message:   i18n.FromWriter(w).Text("error.internal_error", "an unexpected error occurred"),
+					RequestID: requestID,
+				})
+			}()
+			next.ServeHTTP(pw, r)
+		})
+	}
+}
+
+// panicWriter notes whether a response was started before a panic.
+type panicWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (pw *panicWriter) WriteHeader(code int) {
+	pw.wroteHeader = true
+	pw.ResponseWriter.WriteHeader(code)
+}
+
+func (pw *panicWriter) Write(b []byte) (int, error) {
+	pw.wroteHeader = true
+	return pw.ResponseWriter.Write(b)
+}
+
+// Flush passes through so streaming responses still stream.
+func (pw *panicWriter) Flush() {
+	if f, ok := pw.ResponseWriter.(http.Flusher); ok {
+		pw.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (pw *panicWriter) Unwrap() http.ResponseWriter {
+	return pw.ResponseWriter
+}
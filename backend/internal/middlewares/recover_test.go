@@ -0,0 +1,78 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/metrics"
+	"pmv2/backend/internal/middlewares"
+)
+
+func TestRecoverAnswers500WithRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /boom/{id}", func(w http.ResponseWriter, r *http.Request) {
+		var m map[string]int
+		m["x"]++ // nil map write
+	})
+	handler := middlewares.WithRequestMeta(nil)(middlewares.Recover(logger)(mux))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/boom/1", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	requestID := rec.Header().Get(middlewares.RequestIDHeader)
+	var body dto.ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Error != "internal_error" || requestID == "" || body.RequestID != requestID {
+		t.Fatalf("body = %+v (header id %q), %v", body, requestID, err)
+	}
+	if out := logs.String(); !strings.Contains(out, "handler panic") || !strings.Contains(out, requestID) || !strings.Contains(out, "recover_test.go") {
+		t.Fatalf("log does not carry the panic, request ID and stack: %s", out)
+	}
+
+	rec = httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if want := `pmv2_http_panics_total{route="GET /boom/{id}"} 1`; !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("metrics output is missing %q", want)
+	}
+}
+
+func TestRecoverRepanicsAbortHandler(t *testing.T) {
+	handler := middlewares.Recover(slog.New(slog.DiscardHandler))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler", p)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestRequestIDIsKeptOnlyWhenWellFormed(t *testing.T) {
+	handler := middlewares.WithRequestMeta(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for incoming, keep := range map[string]bool{
+		"abc-123":          true,
+		"bad id\nforged=1": false,
+		"":                 false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(middlewares.RequestIDHeader, incoming)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		got := rec.Header().Get(middlewares.RequestIDHeader)
+		if (got == incoming) != keep || got == "" {
+			t.Errorf("incoming %q: got %q, keep = %v", incoming, got, keep)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/netip"
+	"regexp"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/dpop"
+	"pmv2/backend/internal/util"
+)
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDPattern is what an incoming request ID must look like to be kept;
+// anything else is replaced so it cannot forge log lines.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// WithRequestMeta records the request ID, client IP, user agent, method,
+// path and DPoP proof on the request context for downstream services.
+// AuthMiddleware later adds the acting user. The IP is resolved once here
+// (see util.ClientIP), so rate limiting, access logs and audit events all
+// agree on it. The request ID is taken from X-Request-ID when a proxy set a
+// well-formed one, generated otherwise, and sent back in the same header.
+func WithRequestMeta(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if !requestIDPattern.MatchString(requestID) {
+				requestID = uuid.NewString()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+
+			ctx := util.WithRequestMeta(r.Context(), util.RequestMeta{
+				RequestID: requestID,
+				IPAddress: util.ClientIP(r, trustedProxies),
+				UserAgent: r.UserAgent(),
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				DPoPProof: r.Header.Get(dpop.Header),
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
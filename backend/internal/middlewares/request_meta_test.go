@@ -0,0 +1,73 @@
+package middlewares_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/middlewares"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+// captureOutbox keeps enqueued payloads instead of writing them.
+type captureOutbox struct {
+	domain.OutboxRepository
+	payloads []json.RawMessage
+}
+
+func (o *captureOutbox) Enqueue(ctx context.Context, topic string, payload json.RawMessage) error {
+	o.payloads = append(o.payloads, payload)
+	return nil
+}
+
+func TestAuditEventsUseResolvedClientIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	cases := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		want       string
+	}{
+		{"direct client cannot spoof", "203.0.113.7:4321", "198.51.100.1", "203.0.113.7"},
+		{"behind trusted proxy", "10.0.0.5:443", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			outbox := &captureOutbox{}
+			// The repository is not touched when events go through the outbox.
+			audit := service.NewAuditService(repository.NewAuditRepository(nil), outbox)
+
+			handler := middlewares.WithRequestMeta(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := audit.Record(r.Context(), nil, domain.EventTypeAuthLoginSuccess, nil); err != nil {
+					t.Errorf("Record: %v", err)
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", nil)
+			req.RemoteAddr = tc.remoteAddr
+			req.Header.Set("X-Forwarded-For", tc.xff)
+			req.Header.Set("User-Agent", "test-agent")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if len(outbox.payloads) != 1 {
+				t.Fatalf("enqueued %d events, want 1", len(outbox.payloads))
+			}
+			var event domain.AuditEvent
+			if err := json.Unmarshal(outbox.payloads[0], &event); err != nil {
+				t.Fatal(err)
+			}
+			if event.IPAddress != tc.want {
+				t.Errorf("ip_address = %q, want %q", event.IPAddress, tc.want)
+			}
+			if event.UserAgent != "test-agent" {
+				t.Errorf("user_agent = %q", event.UserAgent)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/middlewares"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	headers := middlewares.SecurityHeaders{
+		ContentSecurityPolicy:   "default-src 'none'",
+		ReferrerPolicy:          "no-referrer",
+		StrictTransportSecurity: middlewares.HSTSValue(365*24*time.Hour, true, true),
+		RouteCSP: map[string]string{
+			"/api/v1/events":        "default-src 'none'; connect-src 'self'",
+			"/api/v1/events/legacy": "",
+		},
+	}
+	handler := middlewares.WithSecurityHeaders(headers)(http.NotFoundHandler())
+
+	serve := func(path string) http.Header {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Header()
+	}
+
+	h := serve("/api/v1/vault/items")
+	for key, want := range map[string]string{
+		"Content-Security-Policy":   "default-src 'none'",
+		"Referrer-Policy":           "no-referrer",
+		"Strict-Transport-Security": "max-age=31536000; includeSubDomains; preload",
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "DENY",
+	} {
+		if got := h.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if _, ok := h["Permissions-Policy"]; ok {
+		t.Error("an empty Permissions-Policy must not be sent")
+	}
+
+	if got := serve("/api/v1/events/vault").Get("Content-Security-Policy"); got != "default-src 'none'; connect-src 'self'" {
+		t.Errorf("route CSP = %q, want the override", got)
+	}
+	if _, ok := serve("/api/v1/events/legacy")["Content-Security-Policy"]; ok {
+		t.Error("the longest prefix should win and drop the CSP")
+	}
+}
+
+func TestHSTSValue(t *testing.T) {
+	if got := middlewares.HSTSValue(24*time.Hour, false, false); got != "max-age=86400" {
+		t.Fatalf("HSTSValue = %q", got)
+	}
+}
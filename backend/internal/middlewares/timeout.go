@@ -0,0 +1,113 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"pmv2/backend/internal/util"
+)
+
+// Timeout gives each request a context deadline budget from now. Storage
+// calls made with the request context are cancelled when it passes, and the
+// client gets a 504 with a "timeout" error even if the handler ignores the
+// context and keeps running; whatever the handler writes after that is
+// discarded. The response is buffered until the handler returns, so the
+// budget suits ordinary JSON routes, not streaming ones. A panic in the
+// handler is re-raised on the request's goroutine. Zero disables the budget.
+func Timeout(budget time.Duration) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if budget <= 0 {
+			return next
+		}
+		return func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), budget)
+			defer cancel()
+
+			tw := &timeoutWriter{w: w, header: make(http.Header)}
+			done := make(chan struct{})
+			panicked := make(chan any, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+				next(tw, r.WithContext(ctx))
+				close(done)
+			}()
+
+			select {
+			case p := <-panicked:
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				dst := w.Header()
+				for k, vv := range tw.header {
+					dst[k] = vv
+				}
+				if !tw.wroteHeader {
+					tw.status = http.StatusOK
+				}
+				w.WriteHeader(tw.status)
+				_, _ = w.Write(tw.body.Bytes())
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.timedOut = true
+				if ctx.Err() == context.DeadlineExceeded {
+					util.WriteError(w, http.StatusGatewayTimeout, "timeout", "the request took too long to complete")
+				}
+			}
+		}
+	}
+}
+
+// timeoutWriter buffers a response so that Timeout can drop it in favour of
+// a 504 when the deadline wins.
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+
+	mu          sync.Mutex
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.status = status
+	tw.wroteHeader = true
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.status = http.StatusOK
+		tw.wroteHeader = true
+	}
+	return tw.body.Write(b)
+}
+
+// Unwrap exposes the underlying writer, so the localizer attached to it is
+// still found by error helpers.
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.w
+}
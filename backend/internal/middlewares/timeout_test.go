@@ -0,0 +1,60 @@
+package middlewares_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/middlewares"
+)
+
+func TestTimeout(t *testing.T) {
+	t.Run("fast handler", func(t *testing.T) {
+		handler := middlewares.Timeout(time.Second)(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Deadline(); !ok {
+				t.Error("request context has no deadline")
+			}
+			w.Header().Set("X-Test", "1")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte("done"))
+		})
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusCreated || rec.Body.String() != "done" || rec.Header().Get("X-Test") != "1" {
+			t.Fatalf("got %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+		}
+	})
+
+	t.Run("slow handler", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		handler := middlewares.Timeout(20 * time.Millisecond)(func(w http.ResponseWriter, r *http.Request) {
+			<-release // ignores the context, like a stuck call would
+			w.WriteHeader(http.StatusOK)
+		})
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusGatewayTimeout {
+			t.Fatalf("status = %d, want 504", rec.Code)
+		}
+		var body dto.ErrorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Error != "timeout" {
+			t.Fatalf("body = %+v, %v", body, err)
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		handler := middlewares.Timeout(time.Second)(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Fatalf("recovered %v, want the handler's panic", p)
+			}
+		}()
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
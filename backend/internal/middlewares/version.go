@@ -0,0 +1,80 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIVersion describes one major version of the public API, served under
+// Prefix ("/api/v1"). Released versions are frozen: breaking changes go into
+// the next version, which serves everything it does not redefine through
+// VersionFallback.
+type APIVersion struct {
+	Version int
+	Prefix  string
+	// Successor is the prefix of the version replacing this one, advertised
+	// in a successor-version Link once the version is deprecated.
+	Successor string
+	// DeprecatedAt and Sunset are announced with the Deprecation (RFC 9745)
+	// and Sunset (RFC 8594) headers when set.
+	DeprecatedAt time.Time
+	Sunset       time.Time
+}
+
+// Deprecated reports whether the version has been deprecated.
+func (v APIVersion) Deprecated() bool {
+	return !v.DeprecatedAt.IsZero()
+}
+
+type servedVersionKey struct{}
+
+// Middleware marks responses with the API-Version that served them and, for
+// a deprecated version, the deprecation headers. Requests that reached this
+// version through another version's fallback keep that version's headers.
+func (v APIVersion) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, fallback := r.Context().Value(servedVersionKey{}).(int); fallback {
+			next(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("API-Version", strconv.Itoa(v.Version))
+		if v.Deprecated() {
+			h.Set("Deprecation", "@"+strconv.FormatInt(v.DeprecatedAt.Unix(), 10))
+			if !v.Sunset.IsZero() {
+				h.Set("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if v.Successor != "" {
+				successor := v.Successor + strings.TrimPrefix(r.URL.Path, v.Prefix)
+				h.Add("Link", "<"+successor+`>; rel="successor-version"`)
+			}
+		}
+		next(w, r)
+	}
+}
+
+// VersionFallback serves requests under to.Prefix that have no route of their
+// own by dispatching them to the same path under from.Prefix on mux. Mount it
+// on to.Prefix + "/"; ServeMux prefers the more specific routes to.Prefix
+// does define. The response is labelled with to's API-Version, without
+// from's deprecation headers.
+func VersionFallback(from, to APIVersion, mux http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, to.Prefix)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("API-Version", strconv.Itoa(to.Version))
+
+		ctx := context.WithValue(r.Context(), servedVersionKey{}, to.Version)
+		fallback := r.Clone(ctx)
+		fallback.URL.Path = from.Prefix + rest
+		fallback.URL.RawPath = ""
+		mux.ServeHTTP(w, fallback)
+	})
+}
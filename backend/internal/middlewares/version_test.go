@@ -0,0 +1,81 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/middlewares"
+)
+
+func newVersionedMux(v1, v2 middlewares.APIVersion) *http.ServeMux {
+	mux := http.NewServeMux()
+	reply := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(body + " " + r.PathValue("id")))
+		}
+	}
+	mux.HandleFunc("GET /api/v1/items/{id}", v1.Middleware(reply("v1 item")))
+	mux.HandleFunc("GET /api/v1/folders", v1.Middleware(reply("v1 folders")))
+	mux.HandleFunc("GET /api/v2/items/{id}", v2.Middleware(reply("v2 item")))
+	mux.Handle("/api/v2/", middlewares.VersionFallback(v1, v2, mux))
+	return mux
+}
+
+func TestAPIVersionRouting(t *testing.T) {
+	v1 := middlewares.APIVersion{Version: 1, Prefix: "/api/v1", Successor: "/api/v2"}
+	v2 := middlewares.APIVersion{Version: 2, Prefix: "/api/v2"}
+	mux := newVersionedMux(v1, v2)
+
+	cases := []struct {
+		path    string
+		body    string
+		version string
+	}{
+		{"/api/v1/items/7", "v1 item 7", "1"},
+		{"/api/v2/items/7", "v2 item 7", "2"},
+		{"/api/v2/folders", "v1 folders ", "2"},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rec.Body.String() != tc.body {
+			t.Errorf("%s: body = %q, want %q", tc.path, rec.Body.String(), tc.body)
+		}
+		if got := rec.Header().Get("API-Version"); got != tc.version {
+			t.Errorf("%s: API-Version = %q, want %q", tc.path, got, tc.version)
+		}
+		if rec.Header().Get("Deprecation") != "" {
+			t.Errorf("%s: undeprecated version sent Deprecation", tc.path)
+		}
+	}
+}
+
+func TestAPIVersionDeprecationHeaders(t *testing.T) {
+	deprecatedAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	v1 := middlewares.APIVersion{Version: 1, Prefix: "/api/v1", Successor: "/api/v2", DeprecatedAt: deprecatedAt, Sunset: sunset}
+	v2 := middlewares.APIVersion{Version: 2, Prefix: "/api/v2"}
+	mux := newVersionedMux(v1, v2)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/folders", nil))
+	h := rec.Header()
+	if got := h.Get("Deprecation"); got != "@1767225600" {
+		t.Errorf("Deprecation = %q", got)
+	}
+	if got := h.Get("Sunset"); got != "Fri, 01 Jan 2027 00:00:00 GMT" {
+		t.Errorf("Sunset = %q", got)
+	}
+	if got := h.Get("Link"); got != `</api/v2/folders>; rel="successor-version"` {
+		t.Errorf("Link = %q", got)
+	}
+
+	// The same v1 handler reached through v2 is not deprecated.
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v2/folders", nil))
+	if rec.Header().Get("Deprecation") != "" || rec.Header().Get("Link") != "" || rec.Header().Get("API-Version") != "2" {
+		t.Errorf("fallback headers = %v", rec.Header())
+	}
+}
@@ -0,0 +1,156 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+// Handler delivers one event. Returning an error schedules a retry.
+type Handler func(ctx context.Context, event domain.OutboxEvent) error
+
+type Config struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	// Lease is how long a claimed event stays hidden from other dispatchers
+	// while it is being handled.
+	Lease time.Duration
+}
+
+// Dispatcher polls outbox_events and hands each pending event to the handler
+// registered for its topic. Delivery is at-least-once; handlers must be
+// idempotent. Several replicas may run a dispatcher concurrently.
+type Dispatcher struct {
+	repo     domain.OutboxRepository
+	logger   *slog.Logger
+	cfg      Config
+	handlers map[string]Handler
+}
+
+func NewDispatcher(repo domain.OutboxRepository, logger *slog.Logger, cfg Config) *Dispatcher {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 10
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = time.Minute
+	}
+	return &Dispatcher{repo: repo, logger: logger, cfg: cfg, handlers: make(map[string]Handler)}
+}
+
+func (d *Dispatcher) Handle(topic string, handler Handler) {
+	d.handlers[topic] = handler
+}
+
+// Run polls until ctx is cancelled. A full batch is followed immediately by
+// another poll so a backlog drains quickly.
+func (d *Dispatcher) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		n, err := d.dispatchBatch(context.WithoutCancel(ctx))
+		if err != nil {
+			d.logger.Error("outbox dispatch failed", slog.Any("error", err))
+		}
+
+		wait := d.cfg.PollInterval
+		if n == d.cfg.BatchSize {
+			wait = 0
+		}
+		timer.Reset(wait)
+	}
+}
+
+func (d *Dispatcher) dispatchBatch(ctx context.Context) (int, error) {
+	events, err := d.repo.ClaimDue(ctx, d.cfg.BatchSize, d.cfg.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		d.dispatch(ctx, event)
+	}
+	return len(events), nil
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, event domain.OutboxEvent) {
+	handler, ok := d.handlers[event.Topic]
+	var err error
+	if !ok {
+		err = fmt.Errorf("no handler for topic %q", event.Topic)
+	} else {
+		err = d.invoke(ctx, handler, event)
+	}
+
+	if err == nil {
+		if err := d.repo.MarkDispatched(ctx, event.ID); err != nil {
+			d.logger.Error("failed to mark outbox event dispatched", slog.String("id", event.ID), slog.Any("error", err))
+		}
+		return
+	}
+
+	if event.Attempts >= d.cfg.MaxAttempts {
+		d.logger.Error("outbox event gave up",
+			slog.String("id", event.ID),
+			slog.String("topic", event.Topic),
+			slog.Int("attempts", event.Attempts),
+			slog.Any("error", err),
+		)
+		if markErr := d.repo.MarkFailed(ctx, event.ID, err.Error()); markErr != nil {
+			d.logger.Error("failed to mark outbox event failed", slog.String("id", event.ID), slog.Any("error", markErr))
+		}
+		return
+	}
+
+	next := time.Now().UTC().Add(backoff(event.Attempts))
+	d.logger.Warn("outbox event delivery failed, will retry",
+		slog.String("id", event.ID),
+		slog.String("topic", event.Topic),
+		slog.Int("attempts", event.Attempts),
+		slog.Time("next_attempt_at", next),
+		slog.Any("error", err),
+	)
+	if markErr := d.repo.MarkRetry(ctx, event.ID, err.Error(), next); markErr != nil {
+		d.logger.Error("failed to schedule outbox retry", slog.String("id", event.ID), slog.Any("error", markErr))
+	}
+}
+
+func (d *Dispatcher) invoke(ctx context.Context, handler Handler, event domain.OutboxEvent) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("outbox handler panicked: %v", rec)
+		}
+	}()
+
+	handlerCtx, cancel := context.WithTimeout(ctx, d.cfg.Lease)
+	defer cancel()
+	return handler(handlerCtx, event)
+}
+
+// backoff doubles from 2s per attempt and caps at 15 minutes.
+func backoff(attempts int) time.Duration {
+	if attempts > 10 {
+		return 15 * time.Minute
+	}
+	d := time.Duration(1<<attempts) * time.Second
+	if d > 15*time.Minute {
+		return 15 * time.Minute
+	}
+	return d
+}
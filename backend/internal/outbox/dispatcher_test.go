@@ -0,0 +1,146 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type fakeOutboxRepo struct {
+	due        []domain.OutboxEvent
+	dispatched []string
+	retried    map[string]time.Time
+	failed     map[string]string
+}
+
+func newFakeOutboxRepo(events ...domain.OutboxEvent) *fakeOutboxRepo {
+	return &fakeOutboxRepo{due: events, retried: make(map[string]time.Time), failed: make(map[string]string)}
+}
+
+func (r *fakeOutboxRepo) Enqueue(ctx context.Context, topic string, payload json.RawMessage) error {
+	return nil
+}
+func (r *fakeOutboxRepo) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxEvent, error) {
+	n := min(limit, len(r.due))
+	claimed := r.due[:n]
+	r.due = r.due[n:]
+	return claimed, nil
+}
+func (r *fakeOutboxRepo) MarkDispatched(ctx context.Context, id string) error {
+	r.dispatched = append(r.dispatched, id)
+	return nil
+}
+func (r *fakeOutboxRepo) MarkRetry(ctx context.Context, id string, lastError string, nextAttemptAt time.Time) error {
+	r.retried[id] = nextAttemptAt
+	return nil
+}
+func (r *fakeOutboxRepo) MarkFailed(ctx context.Context, id string, lastError string) error {
+	r.failed[id] = lastError
+	return nil
+}
+func (r *fakeOutboxRepo) DeleteDispatchedBefore(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func newTestDispatcher(repo domain.OutboxRepository, cfg Config) *Dispatcher {
+	return NewDispatcher(repo, slog.New(slog.NewTextHandler(io.Discard, nil)), cfg)
+}
+
+func TestDispatchBatchRoutesByTopic(t *testing.T) {
+	repo := newFakeOutboxRepo(
+		domain.OutboxEvent{ID: "ok", Topic: "audit", Payload: json.RawMessage(`{"n":1}`), Attempts: 1},
+		domain.OutboxEvent{ID: "flaky", Topic: "email", Attempts: 2},
+		domain.OutboxEvent{ID: "orphan", Topic: "nobody", Attempts: 1},
+		domain.OutboxEvent{ID: "exhausted", Topic: "email", Attempts: 3},
+		domain.OutboxEvent{ID: "panics", Topic: "panics", Attempts: 1},
+	)
+	d := newTestDispatcher(repo, Config{MaxAttempts: 3})
+
+	var delivered []string
+	d.Handle("audit", func(ctx context.Context, event domain.OutboxEvent) error {
+		delivered = append(delivered, string(event.Payload))
+		return nil
+	})
+	d.Handle("email", func(ctx context.Context, event domain.OutboxEvent) error {
+		return errors.New("smtp unavailable")
+	})
+	d.Handle("panics", func(ctx context.Context, event domain.OutboxEvent) error {
+		panic("bad payload")
+	})
+
+	before := time.Now()
+	n, err := d.dispatchBatch(context.Background())
+	if err != nil || n != 5 {
+		t.Fatalf("dispatchBatch = %d, %v", n, err)
+	}
+
+	if len(delivered) != 1 || delivered[0] != `{"n":1}` {
+		t.Errorf("audit handler got %v", delivered)
+	}
+	if len(repo.dispatched) != 1 || repo.dispatched[0] != "ok" {
+		t.Errorf("dispatched = %v, want [ok]", repo.dispatched)
+	}
+	for _, id := range []string{"flaky", "orphan", "panics"} {
+		if _, ok := repo.retried[id]; !ok {
+			t.Errorf("%s was not scheduled for retry", id)
+		}
+	}
+	if next := repo.retried["flaky"]; next.Before(before.Add(4 * time.Second)) {
+		t.Errorf("flaky retry at %s, want the 4s backoff for attempt 2", next.Sub(before))
+	}
+	if msg, ok := repo.failed["exhausted"]; !ok || !strings.Contains(msg, "smtp unavailable") {
+		t.Errorf("exhausted event not parked: %q", msg)
+	}
+	if len(repo.failed) != 1 {
+		t.Errorf("failed = %v, want only the exhausted event", repo.failed)
+	}
+}
+
+func TestRunDrainsBacklogAndStops(t *testing.T) {
+	events := make([]domain.OutboxEvent, 5)
+	for i := range events {
+		events[i] = domain.OutboxEvent{ID: string(rune('a' + i)), Topic: "audit", Attempts: 1}
+	}
+	repo := newFakeOutboxRepo(events...)
+	// A long poll interval: only the full-batch fast path can drain the
+	// backlog before the test times out.
+	d := newTestDispatcher(repo, Config{BatchSize: 2, PollInterval: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.Handle("audit", func(ctx context.Context, event domain.OutboxEvent) error {
+		if event.ID == "e" {
+			cancel()
+		}
+		return nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("dispatcher did not drain the backlog")
+	}
+	if len(repo.dispatched) != 5 {
+		t.Fatalf("dispatched %v, want all 5", repo.dispatched)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{0: time.Second, 1: 2 * time.Second, 5: 32 * time.Second, 10: 15 * time.Minute, 50: 15 * time.Minute}
+	for attempts, want := range cases {
+		if got := backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+const (
+	apnsProductionEndpoint = "https://api.push.apple.com"
+	apnsSandboxEndpoint    = "https://api.sandbox.push.apple.com"
+	// apnsTokenLifetime is how long a provider token is reused. Apple
+	// accepts one for an hour and refuses new ones more often than every
+	// 20 minutes.
+	apnsTokenLifetime = 50 * time.Minute
+)
+
+type APNsConfig struct {
+	// KeyPEM is the token signing key (.p8) from the Apple developer
+	// account, KeyID its identifier.
+	KeyPEM []byte
+	KeyID  string
+	TeamID string
+	// Topic is the app's bundle ID.
+	Topic   string
+	Sandbox bool
+}
+
+// APNs sends through Apple's HTTP/2 provider API with token-based
+// authentication.
+type APNs struct {
+	cfg      APNsConfig
+	key      crypto.Signer
+	endpoint string
+	client   *http.Client
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+// NewAPNs builds the provider. A nil client gets a default with a 10 second
+// timeout; Go's client negotiates the HTTP/2 Apple requires.
+func NewAPNs(cfg APNsConfig, client *http.Client) (*APNs, error) {
+	if cfg.KeyID == "" || cfg.TeamID == "" || cfg.Topic == "" {
+		return nil, errors.New("apns requires a key ID, team ID and topic")
+	}
+	key, err := parsePrivateKey(cfg.KeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("apns key: %w", err)
+	}
+	endpoint := apnsProductionEndpoint
+	if cfg.Sandbox {
+		endpoint = apnsSandboxEndpoint
+	}
+	if client == nil {
+		client = defaultClient
+	}
+	return &APNs{cfg: cfg, key: key, endpoint: endpoint, client: client}, nil
+}
+
+func (p *APNs) Send(ctx context.Context, msg domain.PushMessage) error {
+	token, err := p.providerToken()
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]any{
+		"aps": map[string]any{
+			"alert": map[string]string{"title": msg.Title, "body": msg.Body},
+			"sound": "default",
+		},
+		"kind": msg.Kind,
+	}
+	for k, v := range msg.Data {
+		payload[k] = v
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal apns payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+"/3/device/"+url.PathEscape(msg.Token), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+token)
+	req.Header.Set("apns-topic", p.cfg.Topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", "10")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send apns notification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var failure struct {
+		Reason string `json:"reason"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&failure)
+	switch {
+	case resp.StatusCode == http.StatusGone, failure.Reason == "BadDeviceToken", failure.Reason == "Unregistered":
+		return ErrUnregistered
+	case failure.Reason == "ExpiredProviderToken":
+		p.mu.Lock()
+		p.token = ""
+		p.mu.Unlock()
+	}
+	return statusError("apns", resp.StatusCode, failure.Reason)
+}
+
+func (p *APNs) providerToken() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if p.token != "" && now.Sub(p.issuedAt) < apnsTokenLifetime {
+		return p.token, nil
+	}
+	token, err := signJWT(p.key, map[string]any{"kid": p.cfg.KeyID}, map[string]any{
+		"iss": p.cfg.TeamID,
+		"iat": now.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	p.token, p.issuedAt = token, now
+	return token, nil
+}
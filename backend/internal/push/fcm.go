@@ -0,0 +1,185 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+const (
+	fcmEndpoint = "https://fcm.googleapis.com"
+	fcmScope    = "https://www.googleapis.com/auth/firebase.messaging"
+)
+
+// FCM sends through the Firebase Cloud Messaging HTTP v1 API, signing in
+// with a service account. Access tokens are fetched as needed and reused
+// until shortly before they expire.
+type FCM struct {
+	projectID   string
+	clientEmail string
+	tokenURI    string
+	key         crypto.Signer
+	endpoint    string
+	client      *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+type serviceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// NewFCM builds the provider from a service account key file's contents. A
+// nil client gets a default with a 10 second timeout.
+func NewFCM(credentialsJSON []byte, client *http.Client) (*FCM, error) {
+	var account serviceAccount
+	if err := json.Unmarshal(credentialsJSON, &account); err != nil {
+		return nil, fmt.Errorf("parse fcm credentials: %w", err)
+	}
+	if account.ProjectID == "" || account.ClientEmail == "" || account.TokenURI == "" {
+		return nil, errors.New("fcm credentials lack project_id, client_email or token_uri")
+	}
+	key, err := parsePrivateKey([]byte(account.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("fcm credentials: %w", err)
+	}
+	if client == nil {
+		client = defaultClient
+	}
+	return &FCM{
+		projectID:   account.ProjectID,
+		clientEmail: account.ClientEmail,
+		tokenURI:    account.TokenURI,
+		key:         key,
+		endpoint:    fcmEndpoint,
+		client:      client,
+	}, nil
+}
+
+func (p *FCM) Send(ctx context.Context, msg domain.PushMessage) error {
+	accessToken, err := p.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]string{"kind": string(msg.Kind)}
+	for k, v := range msg.Data {
+		data[k] = v
+	}
+	body, err := json.Marshal(map[string]any{
+		"message": map[string]any{
+			"token":        msg.Token,
+			"notification": map[string]string{"title": msg.Title, "body": msg.Body},
+			"data":         data,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal fcm message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+"/v1/projects/"+url.PathEscape(p.projectID)+"/messages:send", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send fcm message: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var failure struct {
+		Error struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+			Details []struct {
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&failure)
+	for _, detail := range failure.Error.Details {
+		if detail.ErrorCode == "UNREGISTERED" {
+			return ErrUnregistered
+		}
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrUnregistered
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		p.mu.Lock()
+		p.accessToken = ""
+		p.mu.Unlock()
+	}
+	return statusError("fcm", resp.StatusCode, strings.TrimSpace(failure.Error.Status+" "+failure.Error.Message))
+}
+
+// token returns a cached access token, exchanging a freshly signed
+// assertion for a new one when it is missing or about to expire.
+func (p *FCM) token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if p.accessToken != "" && now.Before(p.expiresAt) {
+		return p.accessToken, nil
+	}
+
+	assertion, err := signJWT(p.key, map[string]any{}, map[string]any{
+		"iss":   p.clientEmail,
+		"scope": fcmScope,
+		"aud":   p.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch fcm access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", statusError("fcm token", resp.StatusCode, "")
+	}
+
+	var grant struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&grant); err != nil || grant.AccessToken == "" {
+		return "", fmt.Errorf("decode fcm access token: %v", err)
+	}
+	p.accessToken = grant.AccessToken
+	// Renew a minute early so a token never expires in flight.
+	p.expiresAt = now.Add(time.Duration(grant.ExpiresIn)*time.Second - time.Minute)
+	return p.accessToken, nil
+}
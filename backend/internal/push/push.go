@@ -0,0 +1,77 @@
+// Package push delivers mobile push notifications through Firebase Cloud
+// Messaging and the Apple Push Notification service.
+//
+// Notifications are queued on the transactional outbox (see
+// service.PushService) and handed to a Provider by the dispatcher, so a send
+// survives restarts and is retried with the outbox's backoff. A provider
+// reports a token its service no longer accepts with ErrUnregistered; the
+// caller then forgets the token instead of retrying.
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+var (
+	// ErrUnregistered means the push service will never deliver to the
+	// token again: the app was uninstalled or the token rotated.
+	ErrUnregistered = errors.New("push token is no longer registered")
+	// ErrNoProvider means no provider is configured for the token's
+	// platform.
+	ErrNoProvider = errors.New("no push provider for platform")
+)
+
+// Provider delivers a single notification.
+type Provider interface {
+	Send(ctx context.Context, msg domain.PushMessage) error
+}
+
+// Router sends each message through the provider of its platform.
+type Router map[domain.PushPlatform]Provider
+
+func (r Router) Send(ctx context.Context, msg domain.PushMessage) error {
+	provider, ok := r[msg.Platform]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrNoProvider, msg.Platform)
+	}
+	return provider.Send(ctx, msg)
+}
+
+// LogProvider writes notifications to the log instead of delivering them.
+// It stands in for every platform when none is configured. Tokens are not
+// logged.
+type LogProvider struct {
+	logger *slog.Logger
+}
+
+func NewLogProvider(logger *slog.Logger) *LogProvider {
+	return &LogProvider{logger: logger}
+}
+
+func (p *LogProvider) Send(ctx context.Context, msg domain.PushMessage) error {
+	p.logger.InfoContext(ctx, "push (not sent, log provider)",
+		slog.String("platform", string(msg.Platform)),
+		slog.String("kind", string(msg.Kind)),
+		slog.String("title", msg.Title),
+	)
+	return nil
+}
+
+// defaultClient is used by providers given no client of their own.
+var defaultClient = &http.Client{Timeout: 10 * time.Second}
+
+// statusError describes a rejected request by status and the service's own
+// reason.
+func statusError(service string, status int, reason string) error {
+	if reason == "" {
+		reason = http.StatusText(status)
+	}
+	return fmt.Errorf("%s push rejected: %d %s", service, status, reason)
+}
@@ -0,0 +1,180 @@
+package push
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pmv2/backend/internal/domain"
+)
+
+func pkcs8PEM(t *testing.T, key crypto.Signer) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestFCMSend(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenRequests := 0
+	var got struct {
+		Message struct {
+			Token        string            `json:"token"`
+			Notification map[string]string `json:"notification"`
+			Data         map[string]string `json:"data"`
+		} `json:"message"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			tokenRequests++
+			if err := r.ParseForm(); err != nil || r.PostForm.Get("assertion") == "" {
+				t.Errorf("token request form = %v, %v", r.PostForm, err)
+			}
+			_, _ = io.WriteString(w, `{"access_token":"at-1","expires_in":3600}`)
+		case "/v1/projects/demo/messages:send":
+			if r.Header.Get("Authorization") != "Bearer at-1" {
+				t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+			}
+			if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+				t.Errorf("decode message: %v", err)
+			}
+			if got.Message.Token == "gone" {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = io.WriteString(w, `{"error":{"status":"NOT_FOUND","details":[{"errorCode":"UNREGISTERED"}]}}`)
+				return
+			}
+			_, _ = io.WriteString(w, `{"name":"projects/demo/messages/1"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	credentials, _ := json.Marshal(map[string]string{
+		"project_id":   "demo",
+		"client_email": "pmv2@demo.iam.gserviceaccount.com",
+		"private_key":  string(pkcs8PEM(t, key)),
+		"token_uri":    srv.URL + "/token",
+	})
+	p, err := NewFCM(credentials, srv.Client())
+	if err != nil {
+		t.Fatalf("NewFCM: %v", err)
+	}
+	p.endpoint = srv.URL
+
+	msg := domain.PushMessage{Platform: domain.PushPlatformFCM, Token: "device-1", Kind: domain.PushKindNewDevice, Title: "New sign-in", Body: "Body", Data: map[string]string{"device_id": "d1"}}
+	for range 2 {
+		if err := p.Send(context.Background(), msg); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	if tokenRequests != 1 {
+		t.Fatalf("token requests = %d, want the access token reused", tokenRequests)
+	}
+	if got.Message.Token != "device-1" || got.Message.Notification["title"] != "New sign-in" || got.Message.Data["kind"] != "new_device" || got.Message.Data["device_id"] != "d1" {
+		t.Fatalf("sent message = %+v", got.Message)
+	}
+
+	msg.Token = "gone"
+	if err := p.Send(context.Background(), msg); !errors.Is(err, ErrUnregistered) {
+		t.Fatalf("Send(unregistered) err = %v, want ErrUnregistered", err)
+	}
+}
+
+func TestAPNsSend(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("apns-topic") != "com.example.pmv2" || r.Header.Get("apns-push-type") != "alert" {
+			t.Errorf("headers = %v", r.Header)
+		}
+		if !validES256(r.Header.Get("Authorization"), &key.PublicKey) {
+			t.Errorf("Authorization %q is not a valid ES256 token", r.Header.Get("Authorization"))
+		}
+		switch r.URL.Path {
+		case "/3/device/gone":
+			w.WriteHeader(http.StatusGone)
+			_, _ = io.WriteString(w, `{"reason":"Unregistered"}`)
+		case "/3/device/bad":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"reason":"PayloadTooLarge"}`)
+		default:
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				t.Errorf("decode payload: %v", err)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	p, err := NewAPNs(APNsConfig{KeyPEM: pkcs8PEM(t, key), KeyID: "KEY123", TeamID: "TEAM123", Topic: "com.example.pmv2"}, srv.Client())
+	if err != nil {
+		t.Fatalf("NewAPNs: %v", err)
+	}
+	p.endpoint = srv.URL
+
+	msg := domain.PushMessage{Platform: domain.PushPlatformAPNs, Token: "abc123", Kind: domain.PushKindShareInvitation, Title: "Shared with you", Body: "Body"}
+	if err := p.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if aps, _ := payload["aps"].(map[string]any); aps == nil || payload["kind"] != "share_invitation" {
+		t.Fatalf("payload = %v", payload)
+	}
+
+	msg.Token = "gone"
+	if err := p.Send(context.Background(), msg); !errors.Is(err, ErrUnregistered) {
+		t.Fatalf("Send(gone) err = %v, want ErrUnregistered", err)
+	}
+	msg.Token = "bad"
+	if err := p.Send(context.Background(), msg); err == nil || errors.Is(err, ErrUnregistered) {
+		t.Fatalf("Send(bad payload) err = %v, want a retryable error", err)
+	}
+}
+
+func TestRouterWithoutProvider(t *testing.T) {
+	router := Router{domain.PushPlatformFCM: NewLogProvider(nil)}
+	if err := router.Send(context.Background(), domain.PushMessage{Platform: domain.PushPlatformAPNs}); !errors.Is(err, ErrNoProvider) {
+		t.Fatalf("Send err = %v, want ErrNoProvider", err)
+	}
+}
+
+func validES256(authorization string, pub *ecdsa.PublicKey) bool {
+	token, ok := strings.CutPrefix(authorization, "bearer ")
+	if !ok {
+		return false
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(sig) != 64 {
+		return false
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	return ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]))
+}
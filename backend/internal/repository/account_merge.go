@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"pmv2/backend/internal/domain"
+)
+
+// accountMergeStatement is one step of an account merge. Statements refer
+// to the accounts through the :source and :target tokens and to the time
+// of the merge through :now; see bindTokens. When Moved is set, the rows
+// the statement changes are reported under it.
+type accountMergeStatement struct {
+	Moved string
+	Query string
+}
+
+// accountMergeStatements move everything a user owns or takes part in from
+// :source to :target and tombstone :source, in an order that keeps the
+// unique keys of the target satisfied: rows the target already has an
+// equivalent of are dropped first. MySQL refuses a subquery on the table a
+// statement changes unless it is wrapped in a derived table, hence the
+// SELECT ... FROM (SELECT ...) AS t forms.
+var accountMergeStatements = []accountMergeStatement{
+	// Shares: the target needs no share of an item it owns or already has
+	// one of, nor of one it is about to own.
+	{"", `DELETE FROM vault_shares WHERE user_id = :source AND (
+		item_id IN (SELECT item_id FROM (SELECT item_id FROM vault_shares WHERE user_id = :target) AS target_shares)
+		OR item_id IN (SELECT id FROM vault_items WHERE owner_user_id = :target))`},
+	{"", `DELETE FROM vault_shares WHERE user_id = :target AND item_id IN (SELECT id FROM vault_items WHERE owner_user_id = :source)`},
+	{"vault_shares", `UPDATE vault_shares SET user_id = :target, updated_at = :now WHERE user_id = :source`},
+	{"", `UPDATE vault_shares SET shared_by_user_id = :target WHERE shared_by_user_id = :source`},
+
+	{"vault_folders", `UPDATE vault_folders SET owner_user_id = :target, updated_at = :now WHERE owner_user_id = :source`},
+	{"vault_items", `UPDATE vault_items SET owner_user_id = :target, updated_at = :now WHERE owner_user_id = :source`},
+	{"", `UPDATE vault_item_versions SET owner_user_id = :target WHERE owner_user_id = :source`},
+	{"", `UPDATE vault_item_uri_hmacs SET owner_user_id = :target WHERE owner_user_id = :source`},
+	{"", `UPDATE vault_item_usage SET owner_user_id = :target WHERE owner_user_id = :source`},
+	{"", `UPDATE vault_item_tombstones SET owner_user_id = :target WHERE owner_user_id = :source`},
+	{"", `UPDATE vault_item_access_alerts SET owner_user_id = :target WHERE owner_user_id = :source`},
+
+	// Secret names are unique per owner; the target's own win.
+	{"", `DELETE FROM machine_secrets WHERE owner_user_id = :source AND name IN (
+		SELECT name FROM (SELECT name FROM machine_secrets WHERE owner_user_id = :target) AS target_secrets)`},
+	{"machine_secrets", `UPDATE machine_secrets SET owner_user_id = :target, updated_at = :now WHERE owner_user_id = :source`},
+	{"machine_keys", `UPDATE machine_keys SET owner_user_id = :target WHERE owner_user_id = :source`},
+
+	// Requests between the two accounts would be the target asking itself.
+	{"", `DELETE FROM access_requests
+		WHERE (owner_user_id = :source AND requester_user_id = :target)
+		   OR (owner_user_id = :target AND requester_user_id = :source)`},
+	{"access_requests", `UPDATE access_requests SET owner_user_id = :target WHERE owner_user_id = :source`},
+	{"", `UPDATE access_requests SET requester_user_id = :target WHERE requester_user_id = :source`},
+	{"", `UPDATE access_requests SET decided_by_user_id = :target WHERE decided_by_user_id = :source`},
+
+	{"share_invitations", `UPDATE share_invitations SET shared_by_user_id = :target WHERE shared_by_user_id = :source`},
+	{"", `UPDATE share_invitations SET recipient_user_id = :target WHERE recipient_user_id = :source`},
+
+	// A relationship is one row in either direction. The source's with the
+	// target goes, and so do those with people the target already relates
+	// to.
+	{"", `DELETE FROM family_memberships
+		WHERE (user_id = :source AND friend_id = :target)
+		   OR (user_id = :target AND friend_id = :source)`},
+	{"", `DELETE FROM family_memberships
+		WHERE (user_id = :source OR friend_id = :source)
+		  AND (CASE WHEN user_id = :source THEN friend_id ELSE user_id END) IN (
+			SELECT member FROM (
+				SELECT friend_id AS member FROM family_memberships WHERE user_id = :target
+				UNION
+				SELECT user_id FROM family_memberships WHERE friend_id = :target
+			) AS target_family)`},
+	{"family_memberships", `UPDATE family_memberships SET user_id = :target, updated_at = :now WHERE user_id = :source`},
+	{"", `UPDATE family_memberships SET friend_id = :target, updated_at = :now WHERE friend_id = :source`},
+	{"", `UPDATE family_memberships SET initiated_by = :target WHERE initiated_by = :source`},
+
+	// A device known to both accounts keeps the target's row; the source's
+	// sessions and push tokens on it move over to that row.
+	{"", `UPDATE sessions SET device_id = (
+			SELECT t.id FROM user_devices t JOIN user_devices s ON s.key_hash = t.key_hash
+			WHERE s.id = sessions.device_id AND t.user_id = :target)
+		WHERE user_id = :source AND device_id IN (
+			SELECT s.id FROM user_devices s JOIN user_devices t ON t.key_hash = s.key_hash
+			WHERE s.user_id = :source AND t.user_id = :target)`},
+	{"", `UPDATE push_tokens SET device_id = (
+			SELECT t.id FROM user_devices t JOIN user_devices s ON s.key_hash = t.key_hash
+			WHERE s.id = push_tokens.device_id AND t.user_id = :target)
+		WHERE user_id = :source AND device_id IN (
+			SELECT s.id FROM user_devices s JOIN user_devices t ON t.key_hash = s.key_hash
+			WHERE s.user_id = :source AND t.user_id = :target)`},
+	{"", `DELETE FROM user_devices WHERE user_id = :source AND key_hash IN (
+		SELECT key_hash FROM (SELECT key_hash FROM user_devices WHERE user_id = :target) AS target_devices)`},
+	{"user_devices", `UPDATE user_devices SET user_id = :target WHERE user_id = :source`},
+	{"sessions", `UPDATE sessions SET user_id = :target WHERE user_id = :source`},
+	{"push_tokens", `UPDATE push_tokens SET user_id = :target, updated_at = :now WHERE user_id = :source`},
+	{"webhook_endpoints", `UPDATE webhook_endpoints SET user_id = :target, updated_at = :now WHERE user_id = :source`},
+
+	// The tombstone keeps its email and audit trail but nothing to sign in
+	// or decrypt with.
+	{"", `DELETE FROM auth_credentials WHERE user_id = :source`},
+	{"", `DELETE FROM passkeys WHERE user_id = :source`},
+	{"", `DELETE FROM totp_recovery_codes WHERE user_id = :source`},
+	{"", `DELETE FROM user_keys WHERE user_id = :source`},
+	{"", `DELETE FROM user_recovery WHERE user_id = :source`},
+	{"", `DELETE FROM session_bindings WHERE user_id = :source`},
+	{"", `DELETE FROM password_reset_tokens WHERE user_id = :source`},
+}
+
+// mergeAccounts runs the merge in tx, which must be a transaction the
+// caller commits. The re-wrapped keys of the source's received shares
+// replace the old ones first, while the rows still belong to the source.
+// id converts a user or item ID to the dialect's column value and now is
+// the time of the merge as the dialect stores it.
+func mergeAccounts(ctx context.Context, tx dbtx, input domain.AccountMergeInput, id func(string) any, now any) (map[string]int64, error) {
+	if !uniqueItemKeys(input.Shares) {
+		return nil, domain.ErrShareRewrapIncomplete
+	}
+	var count int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM vault_shares WHERE user_id = $1`, id(input.SourceUserID)).Scan(&count); err != nil {
+		return nil, fmt.Errorf("count merged shares: %w", err)
+	}
+	if count != len(input.Shares) {
+		return nil, domain.ErrShareRewrapIncomplete
+	}
+	for _, key := range input.Shares {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE vault_shares SET dek_wrapped = $3, wrap_nonce = $4
+			WHERE item_id = $1 AND user_id = $2
+		`, id(key.ItemID), id(input.SourceUserID), key.WrappedDEK, key.WrapNonce)
+		if err != nil {
+			return nil, fmt.Errorf("rewrap merged share: %w", err)
+		}
+		if err := requireAffected(result); err != nil {
+			return nil, domain.ErrShareRewrapIncomplete
+		}
+	}
+
+	values := map[string]any{":source": id(input.SourceUserID), ":target": id(input.TargetUserID), ":now": now}
+	moved := make(map[string]int64)
+	for _, stmt := range accountMergeStatements {
+		query, args := bindTokens(stmt.Query, values)
+		result, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("merge accounts: %w", err)
+		}
+		if stmt.Moved == "" {
+			continue
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("read rows affected: %w", err)
+		}
+		moved[stmt.Moved] = affected
+	}
+
+	query, args := bindTokens(`
+		UPDATE users SET merged_into_user_id = :target, merged_at = :now, updated_at = :now
+		WHERE id = :source AND merged_into_user_id IS NULL
+	`, values)
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("tombstone merged account: %w", err)
+	}
+	if err := requireAffected(result); err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
@@ -0,0 +1,391 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type AdminRepository struct {
+	db *sql.DB
+}
+
+func NewAdminRepository(db *sql.DB) *AdminRepository {
+	return &AdminRepository{db: db}
+}
+
+// activitySince lists (user_id, created_at) for every sign-in and every
+// audited action a signed-in user took from $1 on; it is the definition of
+// "active". Events are attributed to their actor, so an admin looking at an
+// account does not make that account active.
+const activitySince = `
+	WITH activity AS (
+		SELECT user_id, created_at FROM sessions WHERE created_at >= $1
+		UNION ALL
+		SELECT actor_user_id, created_at FROM audit_events WHERE actor_user_id IS NOT NULL AND created_at >= $1
+	)
+`
+
+func (r *AdminRepository) GetInstanceStats(ctx context.Context, days int) (domain.InstanceStats, error) {
+	return asSystem(ctx, r.db, func(ctx context.Context) (domain.InstanceStats, error) {
+		var stats domain.InstanceStats
+		err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+			SELECT
+				(SELECT COUNT(*) FROM users),
+				(SELECT COUNT(*) FROM users WHERE role = 'admin'),
+				(SELECT COUNT(*) FROM auth_credentials WHERE mfa_totp_enabled),
+				(SELECT COUNT(*) FROM users WHERE created_at >= NOW() - INTERVAL '30 days'),
+				(SELECT COUNT(*) FROM sessions WHERE revoked_at IS NULL AND expires_at > NOW()),
+				(SELECT COUNT(*) FROM vault_items WHERE deleted_at IS NULL),
+				(SELECT COUNT(*) FROM vault_items WHERE deleted_at IS NOT NULL),
+				(SELECT COUNT(*) FROM vault_folders),
+				(SELECT COUNT(*) FROM vault_shares),
+				(SELECT COALESCE(SUM(octet_length(ciphertext) + octet_length(dek_wrapped)), 0) FROM vault_items),
+				(SELECT COALESCE(SUM(octet_length(ciphertext) + octet_length(dek_wrapped)), 0) FROM vault_item_versions),
+				(SELECT COALESCE(SUM(size_bytes), 0) FROM vault_attachments),
+				pg_database_size(current_database()),
+				NOW()
+		`).Scan(
+			&stats.Users, &stats.Admins, &stats.TOTPUsers, &stats.NewUsers30d, &stats.ActiveSessions,
+			&stats.VaultItems, &stats.TrashedItems, &stats.Folders, &stats.Shares,
+			&stats.Storage.VaultBytes, &stats.Storage.HistoryBytes, &stats.Storage.AttachmentBytes, &stats.Storage.DatabaseBytes,
+			&stats.GeneratedAt,
+		)
+		if err != nil {
+			return domain.InstanceStats{}, fmt.Errorf("query instance stats: %w", err)
+		}
+		stats.GeneratedAt = stats.GeneratedAt.UTC()
+
+		monthAgo := stats.GeneratedAt.AddDate(0, 0, -30)
+		err = dbFor(ctx, r.db).QueryRowContext(ctx, activitySince+`
+			SELECT
+				COUNT(DISTINCT user_id) FILTER (WHERE created_at >= $2::timestamptz - INTERVAL '1 day'),
+				COUNT(DISTINCT user_id) FILTER (WHERE created_at >= $2::timestamptz - INTERVAL '7 days'),
+				COUNT(DISTINCT user_id)
+			FROM activity
+		`, monthAgo, stats.GeneratedAt).Scan(&stats.ActiveUsers1d, &stats.ActiveUsers7d, &stats.ActiveUsers30d)
+		if err != nil {
+			return domain.InstanceStats{}, fmt.Errorf("query active users: %w", err)
+		}
+
+		today := stats.GeneratedAt.Truncate(24 * time.Hour)
+		firstDay := today.AddDate(0, 0, 1-days)
+		rows, err := dbFor(ctx, r.db).QueryContext(ctx, activitySince+`
+			SELECT day, COUNT(DISTINCT a.user_id)
+			FROM generate_series($1::timestamptz, $2::timestamptz, INTERVAL '1 day') AS day
+			LEFT JOIN activity a ON a.created_at >= day AND a.created_at < day + INTERVAL '1 day'
+			GROUP BY day
+			ORDER BY day
+		`, firstDay, today)
+		if err != nil {
+			return domain.InstanceStats{}, fmt.Errorf("query daily active users: %w", err)
+		}
+		defer rows.Close()
+
+		stats.DailyActive = make([]domain.DailyActiveUsers, 0, days)
+		for rows.Next() {
+			var day domain.DailyActiveUsers
+			if err := rows.Scan(&day.Date, &day.Users); err != nil {
+				return domain.InstanceStats{}, fmt.Errorf("scan daily active users: %w", err)
+			}
+			day.Date = day.Date.UTC()
+			stats.DailyActive = append(stats.DailyActive, day)
+		}
+		return stats, rows.Err()
+	})
+}
+
+// adminUserSelect reads the columns scanAdminUser expects.
+const adminUserSelect = `
+	SELECT
+		u.id, u.email, u.name, u.role, u.email_verified, u.created_at,
+		COALESCE(ac.mfa_totp_enabled, FALSE), ac.totp_locked_until,
+		(SELECT COUNT(*) FROM sessions s WHERE s.user_id = u.id AND s.revoked_at IS NULL AND s.expires_at > NOW()),
+		(SELECT COUNT(*) FROM vault_items vi WHERE vi.owner_user_id = u.id AND vi.deleted_at IS NULL),
+		(SELECT MAX(s.created_at) FROM sessions s WHERE s.user_id = u.id)
+	FROM users u
+	LEFT JOIN auth_credentials ac ON ac.user_id = u.id
+`
+
+func (r *AdminRepository) SearchUsers(ctx context.Context, query string, limit int) ([]domain.AdminUser, error) {
+	return asSystem(ctx, r.db, func(ctx context.Context) ([]domain.AdminUser, error) {
+		rows, err := dbFor(ctx, r.db).QueryContext(ctx, adminUserSelect+`
+			WHERE u.email LIKE $1 ESCAPE '\'
+			ORDER BY u.email
+			LIMIT $2
+		`, escapeLike(query)+"%", limit)
+		if err != nil {
+			return nil, fmt.Errorf("search users: %w", err)
+		}
+		defer rows.Close()
+
+		users := make([]domain.AdminUser, 0)
+		for rows.Next() {
+			user, err := scanAdminUser(rows)
+			if err != nil {
+				return nil, fmt.Errorf("scan user: %w", err)
+			}
+			users = append(users, user)
+		}
+		return users, rows.Err()
+	})
+}
+
+func (r *AdminRepository) GetUser(ctx context.Context, userID string) (domain.AdminUser, error) {
+	return asSystem(ctx, r.db, func(ctx context.Context) (domain.AdminUser, error) {
+		user, err := scanAdminUser(dbFor(ctx, r.db).QueryRowContext(ctx, adminUserSelect+`WHERE u.id = $1`, userID))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return domain.AdminUser{}, domain.ErrNotFound
+			}
+			return domain.AdminUser{}, fmt.Errorf("get user: %w", err)
+		}
+		return user, nil
+	})
+}
+
+func scanAdminUser(row interface{ Scan(...any) error }) (domain.AdminUser, error) {
+	var (
+		user        domain.AdminUser
+		name        sql.NullString
+		lockedUntil sql.NullTime
+		lastLogin   sql.NullTime
+	)
+	err := row.Scan(
+		&user.ID, &user.Email, &name, &user.Role, &user.EmailVerified, &user.CreatedAt,
+		&user.TOTPEnabled, &lockedUntil,
+		&user.ActiveSessions, &user.VaultItems, &lastLogin,
+	)
+	if err != nil {
+		return domain.AdminUser{}, err
+	}
+	user.Name = name.String
+	user.CreatedAt = user.CreatedAt.UTC()
+	if lockedUntil.Valid {
+		t := lockedUntil.Time.UTC()
+		user.TOTPLockedUntil = &t
+	}
+	if lastLogin.Valid {
+		t := lastLogin.Time.UTC()
+		user.LastLoginAt = &t
+	}
+	return user, nil
+}
+
+func (r *AdminRepository) ListActiveSessions(ctx context.Context, userID string) ([]domain.AdminSession, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT id, COALESCE(device_name, ''), COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at, expires_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := make([]domain.AdminSession, 0)
+	for rows.Next() {
+		var s domain.AdminSession
+		if err := rows.Scan(&s.ID, &s.DeviceName, &s.IPAddress, &s.UserAgent, &s.CreatedAt, &s.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("scan session: %w", err)
+		}
+		s.CreatedAt = s.CreatedAt.UTC()
+		s.ExpiresAt = s.ExpiresAt.UTC()
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+func (r *AdminRepository) RevokeSession(ctx context.Context, userID, sessionID string) (bool, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE sessions
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, sessionID, userID)
+	if err != nil {
+		return false, fmt.Errorf("revoke session: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (r *AdminRepository) ListFeatureFlags(ctx context.Context) ([]domain.FeatureFlag, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT key, enabled, description, updated_by_user_id, updated_at
+		FROM feature_flags
+		ORDER BY key
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list feature flags: %w", err)
+	}
+	defer rows.Close()
+
+	flags := make([]domain.FeatureFlag, 0)
+	for rows.Next() {
+		var (
+			flag      domain.FeatureFlag
+			updatedBy sql.NullString
+		)
+		if err := rows.Scan(&flag.Key, &flag.Enabled, &flag.Description, &updatedBy, &flag.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan feature flag: %w", err)
+		}
+		if updatedBy.Valid {
+			flag.UpdatedBy = &updatedBy.String
+		}
+		flag.UpdatedAt = flag.UpdatedAt.UTC()
+		flags = append(flags, flag)
+	}
+	return flags, rows.Err()
+}
+
+// SetFeatureFlag creates or updates a flag. An empty description keeps the
+// stored one.
+func (r *AdminRepository) SetFeatureFlag(ctx context.Context, flag domain.FeatureFlag) (domain.FeatureFlag, error) {
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		INSERT INTO feature_flags (key, enabled, description, updated_by_user_id, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (key) DO UPDATE SET
+			enabled = EXCLUDED.enabled,
+			description = CASE WHEN EXCLUDED.description = '' THEN feature_flags.description ELSE EXCLUDED.description END,
+			updated_by_user_id = EXCLUDED.updated_by_user_id,
+			updated_at = NOW()
+		RETURNING description, updated_at
+	`, flag.Key, flag.Enabled, flag.Description, flag.UpdatedBy).Scan(&flag.Description, &flag.UpdatedAt)
+	if err != nil {
+		return domain.FeatureFlag{}, fmt.Errorf("set feature flag: %w", err)
+	}
+	flag.UpdatedAt = flag.UpdatedAt.UTC()
+	return flag, nil
+}
+
+func (r *AdminRepository) GetMaintenance(ctx context.Context) (domain.MaintenanceState, error) {
+	var (
+		state domain.MaintenanceState
+		since sql.NullTime
+	)
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT enabled, message, since FROM maintenance_state WHERE id = 1
+	`).Scan(&state.Enabled, &state.Message, &since)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.MaintenanceState{}, nil
+	}
+	if err != nil {
+		return domain.MaintenanceState{}, fmt.Errorf("get maintenance state: %w", err)
+	}
+	if since.Valid {
+		t := since.Time.UTC()
+		state.Since = &t
+	}
+	return state, nil
+}
+
+func (r *AdminRepository) SaveMaintenance(ctx context.Context, state domain.MaintenanceState, updatedBy *string) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO maintenance_state (id, enabled, message, since, updated_by_user_id, updated_at)
+		VALUES (1, $1, $2, $3, $4, NOW())
+		ON CONFLICT (id) DO UPDATE SET
+			enabled = EXCLUDED.enabled,
+			message = EXCLUDED.message,
+			since = EXCLUDED.since,
+			updated_by_user_id = EXCLUDED.updated_by_user_id,
+			updated_at = NOW()
+	`, state.Enabled, state.Message, state.Since, updatedBy)
+	if err != nil {
+		return fmt.Errorf("save maintenance state: %w", err)
+	}
+	return nil
+}
+
+// postgresUserDataChecks finds mentions in JSONB columns through their text
+// form.
+var postgresUserDataChecks = userDataChecks(func(column, token string) string {
+	return "strpos(" + column + "::text, " + token + "::text) > 0"
+})
+
+func (r *AdminRepository) EraseUser(ctx context.Context, userID, email string) ([]string, error) {
+	return asSystem(ctx, r.db, func(ctx context.Context) ([]string, error) {
+		tx, commit, rollback, err := beginTx(ctx, r.db)
+		if err != nil {
+			return nil, fmt.Errorf("begin erase user tx: %w", err)
+		}
+		defer rollback()
+
+		rows, err := tx.QueryContext(ctx, `
+			SELECT a.storage_path
+			FROM vault_attachments a
+			JOIN vault_items i ON i.id = a.item_id
+			WHERE i.owner_user_id = $1
+		`, userID)
+		if err != nil {
+			return nil, fmt.Errorf("query attachment paths: %w", err)
+		}
+		paths, err := scanStrings(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan attachment paths: %w", err)
+		}
+
+		for _, stmt := range userErasureStatements(postgresUserDataChecks) {
+			query, args := bindUserData(stmt, userID, userID, email)
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				return nil, fmt.Errorf("erase user data: %w", err)
+			}
+		}
+		result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, userID)
+		if err != nil {
+			return nil, fmt.Errorf("delete user: %w", err)
+		}
+		if err := requireAffected(result); err != nil {
+			return nil, err
+		}
+
+		if err := commit(); err != nil {
+			return nil, fmt.Errorf("commit erase user: %w", err)
+		}
+		return paths, nil
+	})
+}
+
+func (r *AdminRepository) CountUserData(ctx context.Context, userID, email string) (map[string]int, error) {
+	return asSystem(ctx, r.db, func(ctx context.Context) (map[string]int, error) {
+		query, args := bindUserData(userDataCountQuery(postgresUserDataChecks), userID, userID, email)
+		rows, err := dbFor(ctx, r.db).QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("count user data: %w", err)
+		}
+		return scanTableCounts(rows)
+	})
+}
+
+func (r *AdminRepository) MergeAccounts(ctx context.Context, input domain.AccountMergeInput) (map[string]int64, error) {
+	return asSystem(ctx, r.db, func(ctx context.Context) (map[string]int64, error) {
+		tx, commit, rollback, err := beginTx(ctx, r.db)
+		if err != nil {
+			return nil, fmt.Errorf("begin merge accounts tx: %w", err)
+		}
+		defer rollback()
+
+		moved, err := mergeAccounts(ctx, tx, input, func(id string) any { return id }, time.Now().UTC())
+		if err != nil {
+			return nil, err
+		}
+		if err := commit(); err != nil {
+			return nil, fmt.Errorf("commit merge accounts: %w", err)
+		}
+		return moved, nil
+	})
+}
+
+// escapeLike makes s match literally inside a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
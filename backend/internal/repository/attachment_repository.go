@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+const attachmentColumns = `a.id, a.item_id, a.file_name_ciphertext, a.nonce, a.size_bytes, a.storage_path, a.created_at, a.updated_at`
+
+type AttachmentRepository struct {
+	db *sql.DB
+}
+
+func NewAttachmentRepository(db *sql.DB) *AttachmentRepository {
+	return &AttachmentRepository{db: db}
+}
+
+func (r *AttachmentRepository) CreateAttachment(ctx context.Context, a domain.VaultAttachment) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO vault_attachments (id, item_id, file_name_ciphertext, nonce, size_bytes, storage_path, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+	`, a.ID, a.ItemID, a.FileNameCiphertext, a.Nonce, a.SizeBytes, a.StoragePath, a.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("create attachment: %w", err)
+	}
+	return nil
+}
+
+func (r *AttachmentRepository) ListAttachments(ctx context.Context, itemID, ownerUserID string) ([]domain.VaultAttachment, error) {
+	return asOwner(ctx, r.db, ownerUserID, func(ctx context.Context) ([]domain.VaultAttachment, error) {
+		rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+			SELECT `+attachmentColumns+`
+			FROM vault_attachments a
+			JOIN vault_items i ON i.id = a.item_id
+			WHERE a.item_id = $1 AND i.owner_user_id = $2
+			ORDER BY a.created_at, a.id
+		`, itemID, ownerUserID)
+		if err != nil {
+			return nil, fmt.Errorf("list attachments: %w", err)
+		}
+		return scanAttachments(rows)
+	})
+}
+
+func (r *AttachmentRepository) ListAttachmentsByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultAttachment, error) {
+	return asOwner(ctx, r.db, ownerUserID, func(ctx context.Context) ([]domain.VaultAttachment, error) {
+		rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+			SELECT `+attachmentColumns+`
+			FROM vault_attachments a
+			JOIN vault_items i ON i.id = a.item_id
+			WHERE i.owner_user_id = $1 AND i.deleted_at IS NULL
+			ORDER BY a.item_id, a.created_at, a.id
+		`, ownerUserID)
+		if err != nil {
+			return nil, fmt.Errorf("list attachments: %w", err)
+		}
+		return scanAttachments(rows)
+	})
+}
+
+func (r *AttachmentRepository) GetAttachment(ctx context.Context, itemID, ownerUserID, attachmentID string) (domain.VaultAttachment, error) {
+	return asOwner(ctx, r.db, ownerUserID, func(ctx context.Context) (domain.VaultAttachment, error) {
+		rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+			SELECT `+attachmentColumns+`
+			FROM vault_attachments a
+			JOIN vault_items i ON i.id = a.item_id
+			WHERE a.id = $1 AND a.item_id = $2 AND i.owner_user_id = $3
+		`, attachmentID, itemID, ownerUserID)
+		if err != nil {
+			return domain.VaultAttachment{}, fmt.Errorf("get attachment: %w", err)
+		}
+		return firstAttachment(rows)
+	})
+}
+
+func (r *AttachmentRepository) DeleteAttachment(ctx context.Context, itemID, ownerUserID, attachmentID string) error {
+	_, err := asOwner(ctx, r.db, ownerUserID, func(ctx context.Context) (struct{}, error) {
+		result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+			DELETE FROM vault_attachments a
+			USING vault_items i
+			WHERE a.id = $1 AND a.item_id = $2 AND i.id = a.item_id AND i.owner_user_id = $3
+		`, attachmentID, itemID, ownerUserID)
+		if err != nil {
+			return struct{}{}, fmt.Errorf("delete attachment: %w", err)
+		}
+		return struct{}{}, requireAffected(result)
+	})
+	return err
+}
+
+func (r *AttachmentRepository) DeleteTrashedAttachments(ctx context.Context, deletedBefore time.Time) ([]string, error) {
+	return asSystem(ctx, r.db, func(ctx context.Context) ([]string, error) {
+		rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+			DELETE FROM vault_attachments a
+			USING vault_items i
+			WHERE i.id = a.item_id AND i.deleted_at IS NOT NULL AND i.deleted_at < $1
+			RETURNING a.storage_path
+		`, deletedBefore)
+		if err != nil {
+			return nil, fmt.Errorf("delete trashed attachments: %w", err)
+		}
+		paths, err := scanStrings(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan attachment paths: %w", err)
+		}
+		return paths, nil
+	})
+}
+
+func firstAttachment(rows *sql.Rows) (domain.VaultAttachment, error) {
+	attachments, err := scanAttachments(rows)
+	if err != nil {
+		return domain.VaultAttachment{}, err
+	}
+	if len(attachments) == 0 {
+		return domain.VaultAttachment{}, domain.ErrNotFound
+	}
+	return attachments[0], nil
+}
+
+func scanAttachments(rows *sql.Rows) ([]domain.VaultAttachment, error) {
+	defer rows.Close()
+	attachments := make([]domain.VaultAttachment, 0)
+	for rows.Next() {
+		var a domain.VaultAttachment
+		if err := rows.Scan(&a.ID, &a.ItemID, &a.FileNameCiphertext, &a.Nonce, &a.SizeBytes, &a.StoragePath, &a.CreatedAt, &a.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan attachment: %w", err)
+		}
+		attachments = append(attachments, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate attachments: %w", err)
+	}
+	return attachments, nil
+}
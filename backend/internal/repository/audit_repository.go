@@ -3,15 +3,20 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
+	"pmv2/backend/internal/database"
 	"pmv2/backend/internal/domain"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type AuditRepository struct {
-	db *sql.DB
+	db    *sql.DB
+	reads reader // see readFor; nil reads from db
 }
 
 func NewAuditRepository(db *sql.DB) *AuditRepository {
@@ -19,20 +24,106 @@ func NewAuditRepository(db *sql.DB) *AuditRepository {
 }
 
 func (r *AuditRepository) CreateEvent(ctx context.Context, event domain.AuditEvent) error {
-	_, err := r.db.ExecContext(ctx, `
-		INSERT INTO audit_events (id, user_id, event_type, event_data, created_at)
-		VALUES ($1, $2, $3, $4, $5)
-	`, event.ID, event.UserID, event.EventType, event.EventData, event.CreatedAt)
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO audit_events (id, user_id, actor_user_id, event_type, event_data, ip_address, user_agent, created_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8)
+		ON CONFLICT (id, created_at) DO NOTHING
+	`, event.ID, event.UserID, event.ActorUserID, event.EventType, event.EventData, event.IPAddress, event.UserAgent, event.CreatedAt)
 	if err != nil {
 		return fmt.Errorf("insert audit event: %w", err)
 	}
 	return nil
 }
 
-func (r *AuditRepository) ListEvents(ctx context.Context, userID uuid.UUID, limit int, offset int, filter domain.AuditFilter) ([]domain.AuditEvent, int, error) {
-	where := "WHERE user_id = $1"
-	args := []interface{}{userID}
-	argIdx := 2
+func (r *AuditRepository) ListEvents(ctx context.Context, limit int, offset int, filter domain.AuditFilter) ([]domain.AuditEvent, int, error) {
+	where, args := auditFilterClause(filter)
+	argIdx := len(args) + 1
+
+	// 1. Get total count with filters
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM audit_events %s", where)
+	err := readFor(ctx, r.db, r.reads).QueryRowContext(ctx, countQuery, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("count audit events: %w", err)
+	}
+
+	// 2. Get paginated events with filters
+	limitIdx := argIdx
+	offsetIdx := argIdx + 1
+	query := fmt.Sprintf(`
+		SELECT id, user_id, actor_user_id, event_type, event_data,
+			COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at
+		FROM audit_events
+		%s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d
+	`, where, limitIdx, offsetIdx)
+
+	args = append(args, limit, offset)
+	rows, err := readFor(ctx, r.db, r.reads).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []domain.AuditEvent
+	for rows.Next() {
+		var e domain.AuditEvent
+		if err := rows.Scan(&e.ID, &e.UserID, &e.ActorUserID, &e.EventType, &e.EventData, &e.IPAddress, &e.UserAgent, &e.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("scan audit event: %w", err)
+		}
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("iterate audit events: %w", err)
+	}
+
+	return events, total, nil
+}
+
+// StreamEvents calls fn for every event matching filter, newest first,
+// reading rows as fn consumes them. It stops at the first error fn returns.
+func (r *AuditRepository) StreamEvents(ctx context.Context, filter domain.AuditFilter, fn func(domain.AuditEvent) error) error {
+	where, args := auditFilterClause(filter)
+	rows, err := readFor(ctx, r.db, r.reads).QueryContext(ctx, fmt.Sprintf(`
+		SELECT id, user_id, actor_user_id, event_type, event_data,
+			COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at
+		FROM audit_events
+		%s
+		ORDER BY created_at DESC, id
+	`, where), args...)
+	if err != nil {
+		return fmt.Errorf("query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e domain.AuditEvent
+		if err := rows.Scan(&e.ID, &e.UserID, &e.ActorUserID, &e.EventType, &e.EventData, &e.IPAddress, &e.UserAgent, &e.CreatedAt); err != nil {
+			return fmt.Errorf("scan audit event: %w", err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate audit events: %w", err)
+	}
+	return nil
+}
+
+// auditFilterClause builds the WHERE clause and its arguments for filter.
+func auditFilterClause(filter domain.AuditFilter) (string, []interface{}) {
+	where := "WHERE TRUE"
+	args := []interface{}{}
+	argIdx := 1
+
+	if filter.UserID != nil {
+		where += fmt.Sprintf(" AND user_id = $%d", argIdx)
+		args = append(args, *filter.UserID)
+		argIdx++
+	}
 
 	if filter.Category != "" {
 		where += fmt.Sprintf(" AND event_type LIKE $%d", argIdx)
@@ -40,6 +131,22 @@ func (r *AuditRepository) ListEvents(ctx context.Context, userID uuid.UUID, limi
 		argIdx++
 	}
 
+	if len(filter.EventTypes) > 0 {
+		types := make([]string, 0, len(filter.EventTypes))
+		for _, t := range filter.EventTypes {
+			types = append(types, string(t))
+		}
+		where += fmt.Sprintf(" AND event_type = ANY($%d)", argIdx)
+		args = append(args, pq.Array(types))
+		argIdx++
+	}
+
+	if filter.IPAddress != "" {
+		where += fmt.Sprintf(" AND ip_address = $%d", argIdx)
+		args = append(args, filter.IPAddress)
+		argIdx++
+	}
+
 	if filter.Search != "" {
 		where += fmt.Sprintf(" AND (event_type ILIKE $%d OR event_data::text ILIKE $%d)", argIdx, argIdx)
 		args = append(args, "%"+filter.Search+"%")
@@ -58,50 +165,95 @@ func (r *AuditRepository) ListEvents(ctx context.Context, userID uuid.UUID, limi
 		argIdx++
 	}
 
-	// 1. Get total count with filters
-	var total int
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM audit_events %s", where)
-	err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&total)
-	if err != nil {
-		return nil, 0, fmt.Errorf("count audit events: %w", err)
-	}
+	return where, args
+}
 
-	// 2. Get paginated events with filters
-	limitIdx := argIdx
-	offsetIdx := argIdx + 1
-	query := fmt.Sprintf(`
-		SELECT id, user_id, event_type, event_data, created_at
+// ListEventsAfter returns events inserted after cursor, oldest first. Rows
+// younger than settle are held back so a concurrently committing insert
+// cannot slip in behind an advanced cursor.
+func (r *AuditRepository) ListEventsAfter(ctx context.Context, cursor domain.AuditCursor, settle time.Duration, limit int) ([]domain.AuditEvent, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT id, user_id, actor_user_id, event_type, event_data,
+			COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at, recorded_at
 		FROM audit_events
-		%s
-		ORDER BY created_at DESC
-		LIMIT $%d OFFSET $%d
-	`, where, limitIdx, offsetIdx)
-
-	args = append(args, limit, offset)
-	rows, err := r.db.QueryContext(ctx, query, args...)
+		WHERE (recorded_at, id) > ($1, $2)
+		  AND recorded_at < NOW() - make_interval(secs => $3)
+		ORDER BY recorded_at, id
+		LIMIT $4
+	`, cursor.RecordedAt, cursor.EventID, settle.Seconds(), limit)
 	if err != nil {
-		return nil, 0, fmt.Errorf("query audit events: %w", err)
+		return nil, fmt.Errorf("query audit events after cursor: %w", err)
 	}
 	defer rows.Close()
 
-	var events []domain.AuditEvent
+	events := make([]domain.AuditEvent, 0)
 	for rows.Next() {
 		var e domain.AuditEvent
-		if err := rows.Scan(&e.ID, &e.UserID, &e.EventType, &e.EventData, &e.CreatedAt); err != nil {
-			return nil, 0, fmt.Errorf("scan audit event: %w", err)
+		if err := rows.Scan(&e.ID, &e.UserID, &e.ActorUserID, &e.EventType, &e.EventData, &e.IPAddress, &e.UserAgent, &e.CreatedAt, &e.RecordedAt); err != nil {
+			return nil, fmt.Errorf("scan audit event: %w", err)
 		}
 		events = append(events, e)
 	}
-
 	if err := rows.Err(); err != nil {
-		return nil, 0, fmt.Errorf("iterate audit events: %w", err)
+		return nil, fmt.Errorf("iterate audit events: %w", err)
 	}
+	return events, nil
+}
 
-	return events, total, nil
+// AdvanceExportCursor locks the exporter's cursor row with SELECT ... FOR
+// UPDATE SKIP LOCKED, so one replica at a time exports. fn receives the
+// saved position (the zero cursor on the first run) and returns the new one,
+// which is written in the same transaction that held the lock. When another
+// replica holds the lock fn is not called and locked is false.
+func (r *AuditRepository) AdvanceExportCursor(ctx context.Context, exporter string, fn func(domain.AuditCursor) (domain.AuditCursor, error)) (locked bool, err error) {
+	if _, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO audit_export_cursors (exporter, last_recorded_at, last_event_id)
+		VALUES ($1, to_timestamp(0), $2)
+		ON CONFLICT (exporter) DO NOTHING
+	`, exporter, uuid.Nil); err != nil {
+		return false, fmt.Errorf("ensure audit export cursor: %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin audit export tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var cursor domain.AuditCursor
+	err = tx.QueryRowContext(ctx, `
+		SELECT last_recorded_at, last_event_id FROM audit_export_cursors
+		WHERE exporter = $1
+		FOR UPDATE SKIP LOCKED
+	`, exporter).Scan(&cursor.RecordedAt, &cursor.EventID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("lock audit export cursor: %w", err)
+	}
+
+	next, err := fn(cursor)
+	if err != nil {
+		return true, err
+	}
+	if next != cursor {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE audit_export_cursors
+			SET last_recorded_at = $2, last_event_id = $3, updated_at = NOW()
+			WHERE exporter = $1
+		`, exporter, next.RecordedAt, next.EventID); err != nil {
+			return true, fmt.Errorf("save audit export cursor: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return true, fmt.Errorf("commit audit export cursor: %w", err)
+	}
+	return true, nil
 }
 
 func (r *AuditRepository) DeleteUserLogs(ctx context.Context, userID uuid.UUID) error {
-	_, err := r.db.ExecContext(ctx, `
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
 		DELETE FROM audit_events WHERE user_id = $1
 	`, userID)
 	if err != nil {
@@ -109,3 +261,32 @@ func (r *AuditRepository) DeleteUserLogs(ctx context.Context, userID uuid.UUID)
 	}
 	return nil
 }
+
+func (r *AuditRepository) DeleteEventsBefore(ctx context.Context, before time.Time, category string, limit int) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM audit_events
+		WHERE id IN (
+			SELECT id FROM audit_events
+			WHERE recorded_at < $1 AND event_type LIKE $2
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+	`, before, category+"%", limit)
+	if err != nil {
+		return 0, fmt.Errorf("delete old audit events: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected, nil
+}
+
+func (r *AuditRepository) EnsurePartitions(ctx context.Context, from time.Time, months int) ([]string, error) {
+	return database.EnsureAuditPartitions(ctx, r.db, from, months)
+}
+
+func (r *AuditRepository) DropPartitionsBefore(ctx context.Context, before time.Time) ([]string, error) {
+	return database.DropAuditPartitionsBefore(ctx, r.db, before)
+}
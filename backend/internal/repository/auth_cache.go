@@ -0,0 +1,238 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/metrics"
+)
+
+// AuthCache holds encoded auth records for a short while. Get reports a
+// miss for anything absent, expired or unreadable; failures to store or
+// drop an entry are not reported, so a broken cache only costs round trips.
+type AuthCache interface {
+	Get(ctx context.Context, key string) ([]byte, bool)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+	Delete(ctx context.Context, keys ...string)
+}
+
+// NewCachedAuthStore wraps store so GetUserAuthByEmail and GetTOTPState,
+// which every sign-in and MFA attempt repeats, are answered from cache for
+// up to ttl. Every auth repository write that changes a user's credentials,
+// name or MFA state, and erasing the user, drops that user's entries;
+// reads inside a transaction always go to the database. With a per-process
+// cache another replica may serve a dropped entry until it expires, so
+// multi-replica deployments should share a Redis cache.
+func NewCachedAuthStore(store domain.Store, cache AuthCache, ttl time.Duration) domain.Store {
+	c := &authRecordCache{cache: cache, ttl: ttl}
+	return &cachedAuthStore{
+		Store: store,
+		auth:  &cachedAuthRepository{AuthRepository: store.Auth(), cache: c},
+		admin: &cachedAdminRepository{AdminRepository: store.Admin(), cache: c},
+	}
+}
+
+type cachedAuthStore struct {
+	domain.Store
+	auth  domain.AuthRepository
+	admin domain.AdminRepository
+}
+
+func (s *cachedAuthStore) Auth() domain.AuthRepository   { return s.auth }
+func (s *cachedAuthStore) Admin() domain.AdminRepository { return s.admin }
+
+// authRecordCache keys user records by email and TOTP state by user ID,
+// and remembers which email each cached user was looked up by so a write
+// that only knows the user ID can drop both.
+type authRecordCache struct {
+	cache AuthCache
+	ttl   time.Duration
+}
+
+func (c *authRecordCache) get(ctx context.Context, kind, key string, dst any) bool {
+	data, ok := c.cache.Get(ctx, kind+":"+key)
+	if ok && json.Unmarshal(data, dst) != nil {
+		ok = false
+	}
+	result := "miss"
+	if ok {
+		result = "hit"
+	}
+	metrics.AuthCacheRequests.WithLabelValues(kind, result).Inc()
+	return ok
+}
+
+func (c *authRecordCache) set(ctx context.Context, kind, key string, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	c.cache.Set(ctx, kind+":"+key, data, c.ttl)
+}
+
+func (c *authRecordCache) setUser(ctx context.Context, email string, record domain.UserAuthRecord) {
+	c.set(ctx, "user", email, record)
+	c.cache.Set(ctx, "email:"+record.UserID, []byte(email), c.ttl)
+}
+
+func (c *authRecordCache) invalidate(ctx context.Context, userID string) {
+	keys := []string{"totp:" + userID, "email:" + userID}
+	if email, ok := c.cache.Get(ctx, "email:"+userID); ok {
+		keys = append(keys, "user:"+string(email))
+	}
+	c.cache.Delete(ctx, keys...)
+}
+
+type cachedAuthRepository struct {
+	domain.AuthRepository
+	cache *authRecordCache
+}
+
+func (r *cachedAuthRepository) GetUserAuthByEmail(ctx context.Context, email string) (domain.UserAuthRecord, error) {
+	if _, inTx := txFromContext(ctx); inTx {
+		return r.AuthRepository.GetUserAuthByEmail(ctx, email)
+	}
+	var record domain.UserAuthRecord
+	if r.cache.get(ctx, "user", email, &record) {
+		return record, nil
+	}
+	record, err := r.AuthRepository.GetUserAuthByEmail(ctx, email)
+	if err != nil {
+		return domain.UserAuthRecord{}, err
+	}
+	r.cache.setUser(ctx, email, record)
+	return record, nil
+}
+
+func (r *cachedAuthRepository) GetTOTPState(ctx context.Context, userID string) (domain.TOTPState, error) {
+	if _, inTx := txFromContext(ctx); inTx {
+		return r.AuthRepository.GetTOTPState(ctx, userID)
+	}
+	var state domain.TOTPState
+	if r.cache.get(ctx, "totp", userID, &state) {
+		return state, nil
+	}
+	state, err := r.AuthRepository.GetTOTPState(ctx, userID)
+	if err != nil {
+		return domain.TOTPState{}, err
+	}
+	r.cache.set(ctx, "totp", userID, state)
+	return state, nil
+}
+
+func (r *cachedAuthRepository) SetTOTPSecret(ctx context.Context, userID string, secretEnc []byte) (bool, error) {
+	defer r.cache.invalidate(ctx, userID)
+	return r.AuthRepository.SetTOTPSecret(ctx, userID, secretEnc)
+}
+
+func (r *cachedAuthRepository) EnableTOTP(ctx context.Context, userID string) error {
+	defer r.cache.invalidate(ctx, userID)
+	return r.AuthRepository.EnableTOTP(ctx, userID)
+}
+
+func (r *cachedAuthRepository) DisableTOTP(ctx context.Context, userID string) error {
+	defer r.cache.invalidate(ctx, userID)
+	return r.AuthRepository.DisableTOTP(ctx, userID)
+}
+
+func (r *cachedAuthRepository) RecordTOTPFailure(ctx context.Context, userID string, now time.Time, maxAttempts int, window time.Duration, lockDuration time.Duration) (*time.Time, error) {
+	defer r.cache.invalidate(ctx, userID)
+	return r.AuthRepository.RecordTOTPFailure(ctx, userID, now, maxAttempts, window, lockDuration)
+}
+
+func (r *cachedAuthRepository) ResetTOTPFailures(ctx context.Context, userID string) error {
+	defer r.cache.invalidate(ctx, userID)
+	return r.AuthRepository.ResetTOTPFailures(ctx, userID)
+}
+
+func (r *cachedAuthRepository) UpdatePassword(ctx context.Context, input domain.ResetPasswordInput) error {
+	defer r.cache.invalidate(ctx, input.UserID)
+	return r.AuthRepository.UpdatePassword(ctx, input)
+}
+
+func (r *cachedAuthRepository) SetCredentialsCompromised(ctx context.Context, userID string) error {
+	defer r.cache.invalidate(ctx, userID)
+	return r.AuthRepository.SetCredentialsCompromised(ctx, userID)
+}
+
+func (r *cachedAuthRepository) UpdateDisplayName(ctx context.Context, userID string, name string) error {
+	defer r.cache.invalidate(ctx, userID)
+	return r.AuthRepository.UpdateDisplayName(ctx, userID, name)
+}
+
+type cachedAdminRepository struct {
+	domain.AdminRepository
+	cache *authRecordCache
+}
+
+func (r *cachedAdminRepository) EraseUser(ctx context.Context, userID, email string) ([]string, error) {
+	defer r.cache.invalidate(ctx, userID)
+	return r.AdminRepository.EraseUser(ctx, userID, email)
+}
+
+func (r *cachedAdminRepository) MergeAccounts(ctx context.Context, input domain.AccountMergeInput) (map[string]int64, error) {
+	defer r.cache.invalidate(ctx, input.SourceUserID)
+	defer r.cache.invalidate(ctx, input.TargetUserID)
+	return r.AdminRepository.MergeAccounts(ctx, input)
+}
+
+// MemoryAuthCache is an AuthCache local to the process.
+type MemoryAuthCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryAuthCacheEntry
+	sweepAt int
+	now     func() time.Time
+}
+
+type memoryAuthCacheEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func NewMemoryAuthCache() *MemoryAuthCache {
+	return &MemoryAuthCache{entries: make(map[string]memoryAuthCacheEntry), sweepAt: memoryAuthCacheSweepAt, now: time.Now}
+}
+
+func (c *MemoryAuthCache) Get(_ context.Context, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (c *MemoryAuthCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	// Expired entries are swept once the map has grown, so accounts looked
+	// up once do not accumulate.
+	if len(c.entries) >= c.sweepAt {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		c.sweepAt = max(memoryAuthCacheSweepAt, 2*len(c.entries))
+	}
+	c.entries[key] = memoryAuthCacheEntry{value: value, expiresAt: now.Add(ttl)}
+}
+
+func (c *MemoryAuthCache) Delete(_ context.Context, keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+}
+
+const memoryAuthCacheSweepAt = 10000
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisAuthCache is an AuthCache shared by every API replica pointing at
+// the same Redis instance, so a write on one replica drops the entry for
+// all of them.
+type RedisAuthCache struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisAuthCache(client *redis.Client, prefix string) *RedisAuthCache {
+	if prefix == "" {
+		prefix = "pmv2:authcache:"
+	}
+	return &RedisAuthCache{client: client, prefix: prefix}
+}
+
+func (c *RedisAuthCache) Get(ctx context.Context, key string) ([]byte, bool) {
+	value, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+func (c *RedisAuthCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	_ = c.client.Set(ctx, c.prefix+key, value, ttl).Err()
+}
+
+func (c *RedisAuthCache) Delete(ctx context.Context, keys ...string) {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.prefix + key
+	}
+	_ = c.client.Del(ctx, prefixed...).Err()
+}
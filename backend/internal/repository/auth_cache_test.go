@@ -0,0 +1,88 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+)
+
+// countingStore counts the auth reads that reach the underlying store.
+type countingStore struct {
+	domain.Store
+	auth *countingAuthRepository
+}
+
+func (s *countingStore) Auth() domain.AuthRepository { return s.auth }
+
+type countingAuthRepository struct {
+	domain.AuthRepository
+	reads int
+}
+
+func (r *countingAuthRepository) GetUserAuthByEmail(ctx context.Context, email string) (domain.UserAuthRecord, error) {
+	r.reads++
+	return r.AuthRepository.GetUserAuthByEmail(ctx, email)
+}
+
+func (r *countingAuthRepository) GetTOTPState(ctx context.Context, userID string) (domain.TOTPState, error) {
+	r.reads++
+	return r.AuthRepository.GetTOTPState(ctx, userID)
+}
+
+func TestCachedAuthStore(t *testing.T) {
+	db := repository.NewMemoryDB()
+	plain := repository.NewMemoryStore(db)
+	counting := &countingStore{Store: plain, auth: &countingAuthRepository{AuthRepository: plain.Auth()}}
+	store := repository.NewCachedAuthStore(counting, repository.NewMemoryAuthCache(), time.Minute)
+	ctx := context.Background()
+	userID := createMemoryUser(t, db, "frank@example.com")
+
+	for range 3 {
+		if _, err := store.Auth().GetUserAuthByEmail(ctx, "frank@example.com"); err != nil {
+			t.Fatalf("get user: %v", err)
+		}
+		if _, err := store.Auth().GetTOTPState(ctx, userID); err != nil {
+			t.Fatalf("get totp state: %v", err)
+		}
+	}
+	if counting.auth.reads != 2 {
+		t.Fatalf("database reads = %d, want 2", counting.auth.reads)
+	}
+
+	// A failed MFA attempt drops both entries, so the lockout counter is
+	// read fresh.
+	if _, err := store.Auth().RecordTOTPFailure(ctx, userID, time.Now(), 5, time.Minute, time.Minute); err != nil {
+		t.Fatalf("record failure: %v", err)
+	}
+	state, err := store.Auth().GetTOTPState(ctx, userID)
+	if err != nil || state.FailedAttempts != 1 {
+		t.Fatalf("totp state after failure = %+v, %v", state, err)
+	}
+	record, err := store.Auth().GetUserAuthByEmail(ctx, "frank@example.com")
+	if err != nil || record.TOTPFailedAttempts != 1 {
+		t.Fatalf("user after failure = %+v, %v", record, err)
+	}
+	if counting.auth.reads != 4 {
+		t.Fatalf("database reads = %d, want 4", counting.auth.reads)
+	}
+
+	if err := store.Auth().UpdateDisplayName(ctx, userID, "Frank"); err != nil {
+		t.Fatalf("update name: %v", err)
+	}
+	if record, err = store.Auth().GetUserAuthByEmail(ctx, "frank@example.com"); err != nil || record.Name != "Frank" {
+		t.Fatalf("user after rename = %+v, %v", record, err)
+	}
+
+	// Missing accounts are not cached.
+	for range 2 {
+		if _, err := store.Auth().GetUserAuthByEmail(ctx, "nobody@example.com"); err == nil {
+			t.Fatal("unknown email: want an error")
+		}
+	}
+	if counting.auth.reads != 7 {
+		t.Fatalf("database reads = %d, want 7", counting.auth.reads)
+	}
+}
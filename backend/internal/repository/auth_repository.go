@@ -7,9 +7,9 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/lib/pq"
-
 	"pmv2/backend/internal/domain"
+
+	"github.com/google/uuid"
 )
 
 type AuthRepository struct {
@@ -21,17 +21,17 @@ func NewAuthRepository(db *sql.DB) *AuthRepository {
 }
 
 func (r *AuthRepository) CreateUserWithCredentials(ctx context.Context, input domain.CreateUserInput) error {
-	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
+	tx, commit, rollback, err := beginTx(ctx, r.db)
 	if err != nil {
 		return fmt.Errorf("start create user tx: %w", err)
 	}
+	defer rollback()
 
 	_, err = tx.ExecContext(ctx, `
-		INSERT INTO users (id, email, name, created_at, updated_at)
-		VALUES ($1, $2, $3, NOW(), NOW())
-	`, input.UserID, input.Email, nullableText(input.Name))
+		INSERT INTO users (id, email, email_canonical, name, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+	`, input.UserID, input.Email, nullableText(input.CanonicalEmail), nullableText(input.Name))
 	if err != nil {
-		_ = tx.Rollback()
 		if isUniqueViolation(err) {
 			return domain.ErrEmailTaken
 		}
@@ -42,26 +42,33 @@ func (r *AuthRepository) CreateUserWithCredentials(ctx context.Context, input do
 		INSERT INTO auth_credentials (
 			user_id, algo, params, salt, password_hash, mfa_totp_enabled, created_at, updated_at
 		) VALUES ($1, $2, $3, $4, $5, FALSE, NOW(), NOW())
-	`, input.UserID, input.Algo, input.ParamsJSON, input.Salt, input.PasswordHash)
+	`, input.UserID, nullableText(input.Algo), nullableBytes(input.ParamsJSON), input.Salt, nullableBytes(input.PasswordHash))
 	if err != nil {
-		_ = tx.Rollback()
 		return fmt.Errorf("insert auth credential: %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := commit(); err != nil {
 		return fmt.Errorf("commit create user tx: %w", err)
 	}
 	return nil
 }
 
 func (r *AuthRepository) GetUserAuthByEmail(ctx context.Context, email string) (domain.UserAuthRecord, error) {
+	return r.getUserAuth(ctx, "u.email", email)
+}
+
+func (r *AuthRepository) GetUserAuthByID(ctx context.Context, userID string) (domain.UserAuthRecord, error) {
+	return r.getUserAuth(ctx, "u.id", userID)
+}
+
+func (r *AuthRepository) getUserAuth(ctx context.Context, column string, value any) (domain.UserAuthRecord, error) {
 	var record domain.UserAuthRecord
 	var name sql.NullString
 	var secret []byte
 	var windowStart sql.NullTime
 	var lockedUntil sql.NullTime
 
-	err := r.db.QueryRowContext(ctx, `
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
 		SELECT
 			u.id,
 			u.email,
@@ -73,11 +80,12 @@ func (r *AuthRepository) GetUserAuthByEmail(ctx context.Context, email string) (
 			ac.mfa_totp_secret_enc,
 			ac.totp_failed_attempts,
 			ac.totp_window_started_at,
-			ac.totp_locked_until
+			ac.totp_locked_until,
+			ac.credentials_compromised_at IS NOT NULL
 		FROM users u
 		JOIN auth_credentials ac ON ac.user_id = u.id
-		WHERE u.email = $1
-	`, email).Scan(
+		WHERE `+column+` = $1
+	`, value).Scan(
 		&record.UserID,
 		&record.Email,
 		&name,
@@ -89,6 +97,7 @@ func (r *AuthRepository) GetUserAuthByEmail(ctx context.Context, email string) (
 		&record.TOTPFailedAttempts,
 		&windowStart,
 		&lockedUntil,
+		&record.CredentialsCompromised,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -116,29 +125,42 @@ func (r *AuthRepository) CreateSession(ctx context.Context, input domain.CreateS
 		ipAddress = input.IPAddr
 	}
 
-	_, err := r.db.ExecContext(ctx, `
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
 		INSERT INTO sessions (
-			id, user_id, refresh_token_hash, device_name, ip_address, user_agent, expires_at, created_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
-	`, input.SessionID, input.UserID, input.TokenHash, input.DeviceName, ipAddress, input.UserAgent, input.ExpiresAt)
+			id, user_id, refresh_token_hash, device_name, ip_address, user_agent, device_id, expires_at, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+	`, input.SessionID, input.UserID, input.TokenHash, input.DeviceName, ipAddress, input.UserAgent, nullableText(input.DeviceID), input.ExpiresAt)
 	if err != nil {
 		return fmt.Errorf("insert session: %w", err)
 	}
 	return nil
 }
 
+// GetActiveSessionByTokenHash always reads from the primary, even with a
+// replica configured: a lagging replica would still hand out sessions that
+// were revoked or whose refresh token was rotated.
 func (r *AuthRepository) GetActiveSessionByTokenHash(ctx context.Context, tokenHash []byte) (domain.Session, error) {
+	return r.getActiveSession(ctx, dbFor(ctx, r.db), tokenHash)
+}
+
+func (r *AuthRepository) getActiveSession(ctx context.Context, db reader, tokenHash []byte) (domain.Session, error) {
 	var session domain.Session
-	var name sql.NullString
-	err := r.db.QueryRowContext(ctx, `
-		SELECT s.id, s.user_id, u.email, u.name, ac.mfa_totp_enabled, s.expires_at
+	var name, deviceID, ipAddr, userAgent, onMismatch, dpopJKT sql.NullString
+	var bindIP, bindUA sql.NullBool
+	var lastUsed sql.NullTime
+	err := db.QueryRowContext(ctx, `
+		SELECT s.id, s.user_id, u.email, u.name, u.role, ac.mfa_totp_enabled, s.expires_at, s.step_up_required, s.device_id,
+		       s.ip_address, s.user_agent, sb.bind_ip_prefix, sb.bind_user_agent, sb.on_mismatch, s.dpop_jkt,
+		       ac.credentials_compromised_at IS NOT NULL, s.last_used_at
 		FROM sessions s
 		JOIN users u ON u.id = s.user_id
 		JOIN auth_credentials ac ON ac.user_id = u.id
+		LEFT JOIN session_bindings sb ON sb.user_id = u.id
 		WHERE s.refresh_token_hash = $1
 		  AND s.revoked_at IS NULL
 		  AND s.expires_at > NOW()
-	`, tokenHash).Scan(&session.ID, &session.UserID, &session.Email, &name, &session.TOTPEnabled, &session.ExpiresAt)
+	`, tokenHash).Scan(&session.ID, &session.UserID, &session.Email, &name, &session.Role, &session.TOTPEnabled, &session.ExpiresAt, &session.StepUpRequired, &deviceID,
+		&ipAddr, &userAgent, &bindIP, &bindUA, &onMismatch, &dpopJKT, &session.CredentialResetRequired, &lastUsed)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return domain.Session{}, domain.ErrNotFound
@@ -146,11 +168,20 @@ func (r *AuthRepository) GetActiveSessionByTokenHash(ctx context.Context, tokenH
 		return domain.Session{}, fmt.Errorf("query session: %w", err)
 	}
 	session.Name = name.String
+	session.DeviceID = deviceID.String
+	session.IPAddr = ipAddr.String
+	session.UserAgent = userAgent.String
+	session.DPoPThumbprint = dpopJKT.String
+	session.Binding = domain.SessionBinding{IPPrefix: bindIP.Bool, UserAgent: bindUA.Bool, OnMismatch: domain.SessionBindingAction(onMismatch.String)}
+	if lastUsed.Valid {
+		t := lastUsed.Time.UTC()
+		session.LastUsedAt = &t
+	}
 	return session, nil
 }
 
 func (r *AuthRepository) RevokeSessionByTokenHash(ctx context.Context, tokenHash []byte) (bool, error) {
-	result, err := r.db.ExecContext(ctx, `
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
 		UPDATE sessions
 		SET revoked_at = NOW()
 		WHERE refresh_token_hash = $1 AND revoked_at IS NULL
@@ -167,7 +198,7 @@ func (r *AuthRepository) RevokeSessionByTokenHash(ctx context.Context, tokenHash
 }
 
 func (r *AuthRepository) SetTOTPSecret(ctx context.Context, userID string, secretEnc []byte) (bool, error) {
-	result, err := r.db.ExecContext(ctx, `
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
 		UPDATE auth_credentials
 		SET
 			mfa_totp_secret_enc = $1,
@@ -190,7 +221,7 @@ func (r *AuthRepository) SetTOTPSecret(ctx context.Context, userID string, secre
 }
 
 func (r *AuthRepository) EnableTOTP(ctx context.Context, userID string) error {
-	result, err := r.db.ExecContext(ctx, `
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
 		UPDATE auth_credentials
 		SET
 			mfa_totp_enabled = TRUE,
@@ -215,7 +246,7 @@ func (r *AuthRepository) EnableTOTP(ctx context.Context, userID string) error {
 }
 
 func (r *AuthRepository) DisableTOTP(ctx context.Context, userID string) error {
-	result, err := r.db.ExecContext(ctx, `
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
 		UPDATE auth_credentials
 		SET
 			mfa_totp_enabled = FALSE,
@@ -239,7 +270,7 @@ func (r *AuthRepository) DisableTOTP(ctx context.Context, userID string) error {
 	}
 
 	// Also delete any recovery codes for this user
-	_, err = r.db.ExecContext(ctx, `DELETE FROM totp_recovery_codes WHERE user_id = $1`, userID)
+	_, err = dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM totp_recovery_codes WHERE user_id = $1`, userID)
 	if err != nil {
 		return fmt.Errorf("delete recovery codes: %w", err)
 	}
@@ -252,7 +283,7 @@ func (r *AuthRepository) GetTOTPState(ctx context.Context, userID string) (domai
 	var secret []byte
 	var windowStart sql.NullTime
 	var lockedUntil sql.NullTime
-	err := r.db.QueryRowContext(ctx, `
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
 		SELECT
 			mfa_totp_secret_enc,
 			mfa_totp_enabled,
@@ -281,10 +312,11 @@ func (r *AuthRepository) GetTOTPState(ctx context.Context, userID string) (domai
 }
 
 func (r *AuthRepository) RecordTOTPFailure(ctx context.Context, userID string, now time.Time, maxAttempts int, window time.Duration, lockDuration time.Duration) (*time.Time, error) {
-	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
+	tx, commit, rollback, err := beginTx(ctx, r.db)
 	if err != nil {
 		return nil, fmt.Errorf("start record totp failure tx: %w", err)
 	}
+	defer rollback()
 
 	var failedAttempts int
 	var windowStart sql.NullTime
@@ -296,7 +328,6 @@ func (r *AuthRepository) RecordTOTPFailure(ctx context.Context, userID string, n
 		FOR UPDATE
 	`, userID).Scan(&failedAttempts, &windowStart, &lockedUntil)
 	if err != nil {
-		_ = tx.Rollback()
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, domain.ErrNotFound
 		}
@@ -306,7 +337,7 @@ func (r *AuthRepository) RecordTOTPFailure(ctx context.Context, userID string, n
 	nowUTC := now.UTC()
 	if lockedUntil.Valid && lockedUntil.Time.After(nowUTC) {
 		lock := lockedUntil.Time.UTC()
-		return &lock, tx.Commit()
+		return &lock, commit()
 	}
 
 	if !windowStart.Valid || nowUTC.Sub(windowStart.Time.UTC()) > window {
@@ -337,11 +368,10 @@ func (r *AuthRepository) RecordTOTPFailure(ctx context.Context, userID string, n
 		WHERE user_id = $1
 	`, userID, failedAttempts, nextWindowStart, nextLockedUntil)
 	if err != nil {
-		_ = tx.Rollback()
 		return nil, fmt.Errorf("update totp failure state: %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := commit(); err != nil {
 		return nil, fmt.Errorf("commit record totp failure tx: %w", err)
 	}
 
@@ -353,7 +383,7 @@ func (r *AuthRepository) RecordTOTPFailure(ctx context.Context, userID string, n
 }
 
 func (r *AuthRepository) ResetTOTPFailures(ctx context.Context, userID string) error {
-	result, err := r.db.ExecContext(ctx, `
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
 		UPDATE auth_credentials
 		SET
 			totp_failed_attempts = 0,
@@ -377,13 +407,13 @@ func (r *AuthRepository) ResetTOTPFailures(ctx context.Context, userID string) e
 }
 
 func (r *AuthRepository) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes [][]byte) error {
-	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
+	tx, commit, rollback, err := beginTx(ctx, r.db)
 	if err != nil {
 		return fmt.Errorf("start replace recovery codes tx: %w", err)
 	}
+	defer rollback()
 
 	if _, err := tx.ExecContext(ctx, `DELETE FROM totp_recovery_codes WHERE user_id = $1`, userID); err != nil {
-		_ = tx.Rollback()
 		return fmt.Errorf("delete old recovery codes: %w", err)
 	}
 
@@ -392,19 +422,18 @@ func (r *AuthRepository) ReplaceRecoveryCodes(ctx context.Context, userID string
 			INSERT INTO totp_recovery_codes (user_id, code_hash, created_at)
 			VALUES ($1, $2, NOW())
 		`, userID, hash); err != nil {
-			_ = tx.Rollback()
 			return fmt.Errorf("insert recovery code: %w", err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := commit(); err != nil {
 		return fmt.Errorf("commit replace recovery codes tx: %w", err)
 	}
 	return nil
 }
 
 func (r *AuthRepository) ConsumeRecoveryCode(ctx context.Context, userID string, codeHash []byte) (bool, error) {
-	result, err := r.db.ExecContext(ctx, `
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
 		UPDATE totp_recovery_codes
 		SET used_at = NOW()
 		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
@@ -420,12 +449,58 @@ func (r *AuthRepository) ConsumeRecoveryCode(ctx context.Context, userID string,
 	return affected > 0, nil
 }
 
-func (r *AuthRepository) DeleteExpiredSessions(ctx context.Context) (int64, error) {
-	result, err := r.db.ExecContext(ctx, `
-		DELETE FROM sessions WHERE expires_at < NOW() OR revoked_at IS NOT NULL
-	`)
+func (r *AuthRepository) DeleteExpiredSessions(ctx context.Context, revokedBefore time.Time, afterID string, limit int) (domain.SessionPurgeBatch, error) {
+	// Walking the primary key from afterID keeps each batch to a short
+	// index range; SKIP LOCKED leaves rows another statement holds for a
+	// later purge instead of waiting on them.
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		DELETE FROM sessions
+		WHERE id IN (
+			SELECT id FROM sessions
+			WHERE id > $3 AND (expires_at < NOW() OR revoked_at < $1)
+			ORDER BY id
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id
+	`, revokedBefore, limit, sessionCursor(afterID))
+	if err != nil {
+		return domain.SessionPurgeBatch{}, fmt.Errorf("delete expired sessions: %w", err)
+	}
+	ids, err := scanStrings(rows)
+	if err != nil {
+		return domain.SessionPurgeBatch{}, fmt.Errorf("scan deleted sessions: %w", err)
+	}
+	return sessionPurgeBatch(ids), nil
+}
+
+// sessionCursor is the keyset cursor DeleteExpiredSessions starts after:
+// afterID, or the nil UUID, which sorts before every session ID.
+func sessionCursor(afterID string) string {
+	if afterID == "" {
+		return uuid.Nil.String()
+	}
+	return afterID
+}
+
+// sessionPurgeBatch summarizes the deleted session IDs. IDs are canonical
+// lowercase UUIDs, so they compare as strings as they do in the database.
+func sessionPurgeBatch(ids []string) domain.SessionPurgeBatch {
+	batch := domain.SessionPurgeBatch{Deleted: int64(len(ids))}
+	for _, id := range ids {
+		batch.LastID = max(batch.LastID, id)
+	}
+	return batch
+}
+
+func (r *AuthRepository) RevokeAllUserSessions(ctx context.Context, userID string) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE sessions
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
 	if err != nil {
-		return 0, fmt.Errorf("delete expired sessions: %w", err)
+		return 0, fmt.Errorf("revoke all user sessions: %w", err)
 	}
 
 	affected, err := result.RowsAffected()
@@ -435,14 +510,61 @@ func (r *AuthRepository) DeleteExpiredSessions(ctx context.Context) (int64, erro
 	return affected, nil
 }
 
-func (r *AuthRepository) RevokeAllUserSessions(ctx context.Context, userID string) (int64, error) {
-	result, err := r.db.ExecContext(ctx, `
+func (r *AuthRepository) ListUserSessions(ctx context.Context, userID string) ([]domain.UserSession, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT id, COALESCE(device_name, ''), COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at, last_used_at, expires_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC, id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list user sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := make([]domain.UserSession, 0)
+	for rows.Next() {
+		var s domain.UserSession
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&s.ID, &s.DeviceName, &s.IPAddress, &s.UserAgent, &s.CreatedAt, &lastUsed, &s.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("scan user session: %w", err)
+		}
+		s.CreatedAt = s.CreatedAt.UTC()
+		s.ExpiresAt = s.ExpiresAt.UTC()
+		if lastUsed.Valid {
+			t := lastUsed.Time.UTC()
+			s.LastUsedAt = &t
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+func (r *AuthRepository) RevokeUserSession(ctx context.Context, userID, sessionID string) (bool, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
 		UPDATE sessions
 		SET revoked_at = NOW()
-		WHERE user_id = $1 AND revoked_at IS NULL
-	`, userID)
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, sessionID, userID)
 	if err != nil {
-		return 0, fmt.Errorf("revoke all user sessions: %w", err)
+		return false, fmt.Errorf("revoke user session: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (r *AuthRepository) RevokeOtherUserSessions(ctx context.Context, userID, keepSessionID string) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE sessions
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL
+	`, userID, keepSessionID)
+	if err != nil {
+		return 0, fmt.Errorf("revoke other user sessions: %w", err)
 	}
 
 	affected, err := result.RowsAffected()
@@ -452,8 +574,79 @@ func (r *AuthRepository) RevokeAllUserSessions(ctx context.Context, userID strin
 	return affected, nil
 }
 
+func (r *AuthRepository) TouchSession(ctx context.Context, sessionID string, usedAt time.Time) error {
+	if _, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE sessions SET last_used_at = $2 WHERE id = $1
+	`, sessionID, usedAt.UTC()); err != nil {
+		return fmt.Errorf("touch session: %w", err)
+	}
+	return nil
+}
+
+func (r *AuthRepository) SetSessionStepUp(ctx context.Context, sessionID string, required bool) error {
+	if _, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE sessions SET step_up_required = $2 WHERE id = $1
+	`, sessionID, required); err != nil {
+		return fmt.Errorf("set session step-up: %w", err)
+	}
+	return nil
+}
+
+func (r *AuthRepository) RebindSession(ctx context.Context, sessionID string, ipAddr string, userAgent string) error {
+	if _, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE sessions SET ip_address = $2, user_agent = $3 WHERE id = $1
+	`, sessionID, ipAddr, userAgent); err != nil {
+		return fmt.Errorf("rebind session: %w", err)
+	}
+	return nil
+}
+
+func (r *AuthRepository) GetSessionBinding(ctx context.Context, userID string) (domain.SessionBinding, error) {
+	var binding domain.SessionBinding
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT bind_ip_prefix, bind_user_agent, on_mismatch FROM session_bindings WHERE user_id = $1
+	`, userID).Scan(&binding.IPPrefix, &binding.UserAgent, &binding.OnMismatch)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.SessionBinding{}, domain.ErrNotFound
+		}
+		return domain.SessionBinding{}, fmt.Errorf("get session binding: %w", err)
+	}
+	return binding, nil
+}
+
+func (r *AuthRepository) SetSessionBinding(ctx context.Context, userID string, binding domain.SessionBinding) error {
+	if _, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO session_bindings (user_id, bind_ip_prefix, bind_user_agent, on_mismatch, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			bind_ip_prefix = EXCLUDED.bind_ip_prefix,
+			bind_user_agent = EXCLUDED.bind_user_agent,
+			on_mismatch = EXCLUDED.on_mismatch,
+			updated_at = EXCLUDED.updated_at
+	`, userID, binding.IPPrefix, binding.UserAgent, binding.OnMismatch); err != nil {
+		return fmt.Errorf("set session binding: %w", err)
+	}
+	return nil
+}
+
+func (r *AuthRepository) SetSessionDPoPKey(ctx context.Context, sessionID string, thumbprint string) (bool, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE sessions SET dpop_jkt = $2
+		WHERE id = $1 AND dpop_jkt IS NULL AND revoked_at IS NULL
+	`, sessionID, thumbprint)
+	if err != nil {
+		return false, fmt.Errorf("set session dpop key: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
 func (r *AuthRepository) SetupRecovery(ctx context.Context, input domain.SetupRecoveryInput) error {
-	_, err := r.db.ExecContext(ctx, `
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
 		INSERT INTO user_recovery (user_id, recovery_key_hash, recovery_enabled, wrapped_kek, wrap_nonce, kek_salt, updated_at)
 		VALUES ($1, $2, TRUE, $3, $4, $5, NOW())
 		ON CONFLICT (user_id) DO UPDATE SET
@@ -474,7 +667,7 @@ func (r *AuthRepository) GetRecoveryRecord(ctx context.Context, userID string) (
 	var record domain.RecoveryRecord
 	var lastRecoveryAt sql.NullTime
 	var wrappedKEK, wrapNonce, kekSalt []byte
-	err := r.db.QueryRowContext(ctx, `
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
 		SELECT user_id, recovery_key_hash, recovery_enabled, last_recovery_at,
 		       wrapped_kek, wrap_nonce, kek_salt
 		FROM user_recovery
@@ -500,7 +693,7 @@ func (r *AuthRepository) GetRecoveryRecord(ctx context.Context, userID string) (
 }
 
 func (r *AuthRepository) UpdateLastRecoveryAt(ctx context.Context, userID string) error {
-	_, err := r.db.ExecContext(ctx, `
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
 		UPDATE user_recovery
 		SET last_recovery_at = NOW(), updated_at = NOW()
 		WHERE user_id = $1
@@ -512,13 +705,14 @@ func (r *AuthRepository) UpdateLastRecoveryAt(ctx context.Context, userID string
 }
 
 func (r *AuthRepository) UpdatePassword(ctx context.Context, input domain.ResetPasswordInput) error {
-	result, err := r.db.ExecContext(ctx, `
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
 		UPDATE auth_credentials
 		SET
 			algo = $2,
 			params = $3,
 			salt = $4,
 			password_hash = $5,
+			credentials_compromised_at = NULL,
 			updated_at = NOW()
 		WHERE user_id = $1
 	`, input.UserID, input.Algo, input.ParamsJSON, input.Salt, input.PasswordHash)
@@ -536,12 +730,16 @@ func (r *AuthRepository) UpdatePassword(ctx context.Context, input domain.ResetP
 	return nil
 }
 
-func isUniqueViolation(err error) bool {
-	var pqErr *pq.Error
-	if errors.As(err, &pqErr) {
-		return string(pqErr.Code) == "23505"
+func (r *AuthRepository) SetCredentialsCompromised(ctx context.Context, userID string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE auth_credentials
+		SET credentials_compromised_at = COALESCE(credentials_compromised_at, NOW()), updated_at = NOW()
+		WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("flag credentials compromised: %w", err)
 	}
-	return false
+	return requireAffected(result)
 }
 
 func nullableText(value string) any {
@@ -551,8 +749,15 @@ func nullableText(value string) any {
 	return value
 }
 
+func nullableBytes(value []byte) any {
+	if len(value) == 0 {
+		return nil
+	}
+	return value
+}
+
 func (r *AuthRepository) UpdateDisplayName(ctx context.Context, userID string, name string) error {
-	result, err := r.db.ExecContext(ctx, `
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
 		UPDATE users
 		SET name = $2, updated_at = NOW()
 		WHERE id = $1
@@ -570,3 +775,97 @@ func (r *AuthRepository) UpdateDisplayName(ctx context.Context, userID string, n
 	}
 	return nil
 }
+
+// SetUserRole changes the role of the account registered under email.
+func (r *AuthRepository) SetUserRole(ctx context.Context, email string, role domain.UserRole) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE users
+		SET role = $2, updated_at = NOW()
+		WHERE email = $1
+	`, email, role)
+	if err != nil {
+		return fmt.Errorf("update user role: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("read rows affected: %w", err)
+	}
+	if affected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *AuthRepository) RevokeSessionByID(ctx context.Context, sessionID string) (bool, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE sessions
+		SET revoked_at = NOW()
+		WHERE id = $1 AND revoked_at IS NULL
+	`, sessionID)
+	if err != nil {
+		return false, fmt.Errorf("revoke session: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (r *AuthRepository) RotateSessionToken(ctx context.Context, sessionID string, oldHash, newHash []byte, expiresAt time.Time) (bool, error) {
+	db := dbFor(ctx, r.db)
+	result, err := db.ExecContext(ctx, `
+		UPDATE sessions
+		SET refresh_token_hash = $1, expires_at = $2
+		WHERE id = $3 AND refresh_token_hash = $4 AND revoked_at IS NULL AND expires_at > NOW()
+	`, newHash, expiresAt, sessionID, oldHash)
+	if err != nil {
+		return false, fmt.Errorf("rotate session token: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("read rows affected: %w", err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	if _, err := db.ExecContext(ctx, `
+		INSERT INTO retired_session_tokens (token_hash, session_id, retired_at) VALUES ($1, $2, NOW())
+	`, oldHash, sessionID); err != nil {
+		return false, fmt.Errorf("retire session token: %w", err)
+	}
+	return true, nil
+}
+
+func (r *AuthRepository) GetRetiredSessionToken(ctx context.Context, tokenHash []byte) (domain.RetiredSessionToken, error) {
+	var token domain.RetiredSessionToken
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT t.session_id, s.user_id, t.retired_at
+		FROM retired_session_tokens t
+		JOIN sessions s ON s.id = t.session_id
+		WHERE t.token_hash = $1
+	`, tokenHash).Scan(&token.SessionID, &token.UserID, &token.RetiredAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.RetiredSessionToken{}, domain.ErrNotFound
+		}
+		return domain.RetiredSessionToken{}, fmt.Errorf("query retired session token: %w", err)
+	}
+	return token, nil
+}
+
+func (r *AuthRepository) SessionActive(ctx context.Context, sessionID string) (bool, error) {
+	var active bool
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM sessions WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		)
+	`, sessionID).Scan(&active)
+	if err != nil {
+		return false, fmt.Errorf("query session: %w", err)
+	}
+	return active, nil
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"pmv2/backend/internal/domain"
+)
+
+type AutofillRepository struct {
+	db *sql.DB
+}
+
+func NewAutofillRepository(db *sql.DB) *AutofillRepository {
+	return &AutofillRepository{db: db}
+}
+
+func (r *AutofillRepository) SetURIHMACs(ctx context.Context, ownerUserID, itemID string, hmacs [][]byte) error {
+	_, err := asOwner(ctx, r.db, ownerUserID, func(ctx context.Context) (struct{}, error) {
+		db := dbFor(ctx, r.db)
+		if _, err := db.ExecContext(ctx, `DELETE FROM vault_item_uri_hmacs WHERE item_id = $1 AND owner_user_id = $2`, itemID, ownerUserID); err != nil {
+			return struct{}{}, fmt.Errorf("clear uri hmacs: %w", err)
+		}
+		for _, hmac := range hmacs {
+			if _, err := db.ExecContext(ctx, `
+				INSERT INTO vault_item_uri_hmacs (item_id, owner_user_id, uri_hmac)
+				VALUES ($1, $2, $3)
+				ON CONFLICT DO NOTHING
+			`, itemID, ownerUserID, hmac); err != nil {
+				return struct{}{}, fmt.Errorf("insert uri hmac: %w", err)
+			}
+		}
+		return struct{}{}, nil
+	})
+	return err
+}
+
+func (r *AutofillRepository) ListURIHMACs(ctx context.Context, ownerUserID string) (map[string][][]byte, error) {
+	return asOwner(ctx, r.db, ownerUserID, func(ctx context.Context) (map[string][][]byte, error) {
+		rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+			SELECT item_id, uri_hmac FROM vault_item_uri_hmacs WHERE owner_user_id = $1
+		`, ownerUserID)
+		if err != nil {
+			return nil, fmt.Errorf("list uri hmacs: %w", err)
+		}
+		return scanURIHMACs(rows)
+	})
+}
+
+func (r *AutofillRepository) MatchURIHMACs(ctx context.Context, ownerUserID string, hmacs [][]byte) ([]string, error) {
+	return asOwner(ctx, r.db, ownerUserID, func(ctx context.Context) ([]string, error) {
+		rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+			SELECT DISTINCT h.item_id
+			FROM vault_item_uri_hmacs h
+			JOIN vault_items vi ON vi.id = h.item_id
+			WHERE h.owner_user_id = $1 AND h.uri_hmac = ANY($2) AND vi.deleted_at IS NULL
+		`, ownerUserID, pq.ByteaArray(hmacs))
+		if err != nil {
+			return nil, fmt.Errorf("match uri hmacs: %w", err)
+		}
+		return scanItemIDs(rows)
+	})
+}
+
+func (r *AutofillRepository) RecordItemUse(ctx context.Context, ownerUserID, itemID string, usedAt time.Time) (domain.ItemUsage, error) {
+	return asOwner(ctx, r.db, ownerUserID, func(ctx context.Context) (domain.ItemUsage, error) {
+		usage := domain.ItemUsage{ItemID: itemID}
+		var lastUsed time.Time
+		err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+			INSERT INTO vault_item_usage (item_id, owner_user_id, use_count, last_used_at)
+			SELECT id, owner_user_id, 1, $3 FROM vault_items
+			WHERE id = $1 AND owner_user_id = $2 AND deleted_at IS NULL
+			ON CONFLICT (item_id) DO UPDATE
+			SET use_count = vault_item_usage.use_count + 1, last_used_at = EXCLUDED.last_used_at
+			RETURNING use_count, last_used_at
+		`, itemID, ownerUserID, usedAt).Scan(&usage.UseCount, &lastUsed)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return domain.ItemUsage{}, domain.ErrNotFound
+			}
+			return domain.ItemUsage{}, fmt.Errorf("record item use: %w", err)
+		}
+		lastUsed = lastUsed.UTC()
+		usage.LastUsedAt = &lastUsed
+		return usage, nil
+	})
+}
+
+func (r *AutofillRepository) ListItemUsage(ctx context.Context, ownerUserID string) (map[string]domain.ItemUsage, error) {
+	return asOwner(ctx, r.db, ownerUserID, func(ctx context.Context) (map[string]domain.ItemUsage, error) {
+		rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+			SELECT item_id, use_count, last_used_at FROM vault_item_usage WHERE owner_user_id = $1
+		`, ownerUserID)
+		if err != nil {
+			return nil, fmt.Errorf("list item usage: %w", err)
+		}
+		return scanItemUsage(rows)
+	})
+}
+
+func scanURIHMACs(rows *sql.Rows) (map[string][][]byte, error) {
+	defer rows.Close()
+	hmacs := make(map[string][][]byte)
+	for rows.Next() {
+		var itemID string
+		var hmac []byte
+		if err := rows.Scan(&itemID, &hmac); err != nil {
+			return nil, fmt.Errorf("scan uri hmac: %w", err)
+		}
+		hmacs[itemID] = append(hmacs[itemID], hmac)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate uri hmacs: %w", err)
+	}
+	return hmacs, nil
+}
+
+func scanItemIDs(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan item id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate item ids: %w", err)
+	}
+	return ids, nil
+}
+
+func scanItemUsage(rows *sql.Rows) (map[string]domain.ItemUsage, error) {
+	defer rows.Close()
+	usage := make(map[string]domain.ItemUsage)
+	for rows.Next() {
+		var u domain.ItemUsage
+		var lastUsed time.Time
+		if err := rows.Scan(&u.ItemID, &u.UseCount, &lastUsed); err != nil {
+			return nil, fmt.Errorf("scan item usage: %w", err)
+		}
+		lastUsed = lastUsed.UTC()
+		u.LastUsedAt = &lastUsed
+		usage[u.ItemID] = u
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate item usage: %w", err)
+	}
+	return usage, nil
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"pmv2/backend/internal/domain"
+)
+
+type BackupRegistryRepository struct {
+	db *sql.DB
+}
+
+func NewBackupRegistryRepository(db *sql.DB) *BackupRegistryRepository {
+	return &BackupRegistryRepository{db: db}
+}
+
+func (r *BackupRegistryRepository) RegisterBackup(ctx context.Context, record domain.BackupRecord) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO backups_registry (id, created_by_user_id, storage_uri, checksum_sha256, encrypted)
+		VALUES ($1, $2, $3, $4, $5)
+	`, record.ID, record.CreatedByUserID, record.StorageURI, record.ChecksumSHA256, record.Encrypted)
+	if err != nil {
+		return fmt.Errorf("register backup: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/util"
+)
+
+// NewEncryptedStore wraps store so the metadata columns of sessions and
+// audit events (ip_address, user_agent, device_name, event_data), device
+// labels and the requesting device of QR sign-ins are written
+// encrypted with cipher and decrypted on read, whatever the driver. Rows
+// written before encryption was enabled are read back as they are. Audit IP
+// addresses are encrypted deterministically so the IP filter keeps working
+// for new events; the free-text search no longer matches encrypted event
+// data.
+func NewEncryptedStore(store domain.Store, cipher *util.MetadataCipher) domain.Store {
+	return &encryptedStore{
+		Store:    store,
+		auth:     &encryptedAuthRepository{AuthRepository: store.Auth(), cipher: cipher},
+		audit:    &encryptedAuditRepository{AuditRepository: store.Audit(), cipher: cipher},
+		admin:    &encryptedAdminRepository{AdminRepository: store.Admin(), cipher: cipher},
+		devices:  &encryptedDeviceRepository{DeviceRepository: store.Devices(), cipher: cipher},
+		qrLogins: &encryptedQRLoginRepository{QRLoginRepository: store.QRLogins(), cipher: cipher},
+	}
+}
+
+type encryptedStore struct {
+	domain.Store
+	auth     domain.AuthRepository
+	audit    domain.AuditRepository
+	admin    domain.AdminRepository
+	devices  domain.DeviceRepository
+	qrLogins domain.QRLoginRepository
+}
+
+func (s *encryptedStore) Auth() domain.AuthRepository        { return s.auth }
+func (s *encryptedStore) Audit() domain.AuditRepository      { return s.audit }
+func (s *encryptedStore) Admin() domain.AdminRepository      { return s.admin }
+func (s *encryptedStore) Devices() domain.DeviceRepository   { return s.devices }
+func (s *encryptedStore) QRLogins() domain.QRLoginRepository { return s.qrLogins }
+
+type encryptedAuthRepository struct {
+	domain.AuthRepository
+	cipher *util.MetadataCipher
+}
+
+func (r *encryptedAuthRepository) CreateSession(ctx context.Context, input domain.CreateSessionInput) error {
+	var err error
+	if input.DeviceName, err = r.cipher.Encrypt(input.DeviceName); err != nil {
+		return err
+	}
+	if input.IPAddr, err = r.cipher.Encrypt(input.IPAddr); err != nil {
+		return err
+	}
+	if input.UserAgent, err = r.cipher.Encrypt(input.UserAgent); err != nil {
+		return err
+	}
+	return r.AuthRepository.CreateSession(ctx, input)
+}
+
+func (r *encryptedAuthRepository) GetActiveSessionByTokenHash(ctx context.Context, tokenHash []byte) (domain.Session, error) {
+	session, err := r.AuthRepository.GetActiveSessionByTokenHash(ctx, tokenHash)
+	if err != nil {
+		return domain.Session{}, err
+	}
+	if session.IPAddr, err = r.cipher.Decrypt(session.IPAddr); err != nil {
+		return domain.Session{}, fmt.Errorf("session %s ip address: %w", session.ID, err)
+	}
+	if session.UserAgent, err = r.cipher.Decrypt(session.UserAgent); err != nil {
+		return domain.Session{}, fmt.Errorf("session %s user agent: %w", session.ID, err)
+	}
+	return session, nil
+}
+
+func (r *encryptedAuthRepository) ListUserSessions(ctx context.Context, userID string) ([]domain.UserSession, error) {
+	sessions, err := r.AuthRepository.ListUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		s := &sessions[i]
+		if s.DeviceName, err = r.cipher.Decrypt(s.DeviceName); err != nil {
+			return nil, fmt.Errorf("session %s device name: %w", s.ID, err)
+		}
+		if s.IPAddress, err = r.cipher.Decrypt(s.IPAddress); err != nil {
+			return nil, fmt.Errorf("session %s ip address: %w", s.ID, err)
+		}
+		if s.UserAgent, err = r.cipher.Decrypt(s.UserAgent); err != nil {
+			return nil, fmt.Errorf("session %s user agent: %w", s.ID, err)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *encryptedAuthRepository) RebindSession(ctx context.Context, sessionID string, ipAddr string, userAgent string) error {
+	var err error
+	if ipAddr, err = r.cipher.Encrypt(ipAddr); err != nil {
+		return err
+	}
+	if userAgent, err = r.cipher.Encrypt(userAgent); err != nil {
+		return err
+	}
+	return r.AuthRepository.RebindSession(ctx, sessionID, ipAddr, userAgent)
+}
+
+type encryptedAuditRepository struct {
+	domain.AuditRepository
+	cipher *util.MetadataCipher
+}
+
+func (r *encryptedAuditRepository) CreateEvent(ctx context.Context, event domain.AuditEvent) error {
+	var err error
+	event.IPAddress = r.cipher.EncryptDeterministic(event.IPAddress)
+	if event.UserAgent, err = r.cipher.Encrypt(event.UserAgent); err != nil {
+		return err
+	}
+	if len(event.EventData) > 0 {
+		sealed, err := r.cipher.Encrypt(string(event.EventData))
+		if err != nil {
+			return err
+		}
+		// Stored as a JSON string so JSON columns still accept it.
+		if event.EventData, err = json.Marshal(sealed); err != nil {
+			return fmt.Errorf("encode encrypted event data: %w", err)
+		}
+	}
+	return r.AuditRepository.CreateEvent(ctx, event)
+}
+
+func (r *encryptedAuditRepository) ListEvents(ctx context.Context, limit int, offset int, filter domain.AuditFilter) ([]domain.AuditEvent, int, error) {
+	events, total, err := r.AuditRepository.ListEvents(ctx, limit, offset, r.filter(filter))
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range events {
+		if err := r.decrypt(&events[i]); err != nil {
+			return nil, 0, err
+		}
+	}
+	return events, total, nil
+}
+
+func (r *encryptedAuditRepository) StreamEvents(ctx context.Context, filter domain.AuditFilter, fn func(domain.AuditEvent) error) error {
+	return r.AuditRepository.StreamEvents(ctx, r.filter(filter), func(event domain.AuditEvent) error {
+		if err := r.decrypt(&event); err != nil {
+			return err
+		}
+		return fn(event)
+	})
+}
+
+func (r *encryptedAuditRepository) ListEventsAfter(ctx context.Context, cursor domain.AuditCursor, settle time.Duration, limit int) ([]domain.AuditEvent, error) {
+	events, err := r.AuditRepository.ListEventsAfter(ctx, cursor, settle, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range events {
+		if err := r.decrypt(&events[i]); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+func (r *encryptedAuditRepository) filter(filter domain.AuditFilter) domain.AuditFilter {
+	filter.IPAddress = r.cipher.EncryptDeterministic(filter.IPAddress)
+	return filter
+}
+
+func (r *encryptedAuditRepository) decrypt(event *domain.AuditEvent) error {
+	var err error
+	if event.IPAddress, err = r.cipher.Decrypt(event.IPAddress); err != nil {
+		return fmt.Errorf("audit event %s ip address: %w", event.ID, err)
+	}
+	if event.UserAgent, err = r.cipher.Decrypt(event.UserAgent); err != nil {
+		return fmt.Errorf("audit event %s user agent: %w", event.ID, err)
+	}
+
+	var sealed string
+	if json.Unmarshal(event.EventData, &sealed) != nil || !util.IsEncryptedMetadata(sealed) {
+		return nil
+	}
+	data, err := r.cipher.Decrypt(sealed)
+	if err != nil {
+		return fmt.Errorf("audit event %s data: %w", event.ID, err)
+	}
+	event.EventData = json.RawMessage(data)
+	return nil
+}
+
+type encryptedAdminRepository struct {
+	domain.AdminRepository
+	cipher *util.MetadataCipher
+}
+
+func (r *encryptedAdminRepository) ListActiveSessions(ctx context.Context, userID string) ([]domain.AdminSession, error) {
+	sessions, err := r.AdminRepository.ListActiveSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		s := &sessions[i]
+		if s.DeviceName, err = r.cipher.Decrypt(s.DeviceName); err != nil {
+			return nil, fmt.Errorf("session %s device name: %w", s.ID, err)
+		}
+		if s.IPAddress, err = r.cipher.Decrypt(s.IPAddress); err != nil {
+			return nil, fmt.Errorf("session %s ip address: %w", s.ID, err)
+		}
+		if s.UserAgent, err = r.cipher.Decrypt(s.UserAgent); err != nil {
+			return nil, fmt.Errorf("session %s user agent: %w", s.ID, err)
+		}
+	}
+	return sessions, nil
+}
+
+type encryptedDeviceRepository struct {
+	domain.DeviceRepository
+	cipher *util.MetadataCipher
+}
+
+func (r *encryptedDeviceRepository) TouchDevice(ctx context.Context, userID string, keyHash []byte, fingerprinted bool, label string) (domain.Device, bool, error) {
+	sealed, err := r.cipher.Encrypt(label)
+	if err != nil {
+		return domain.Device{}, false, err
+	}
+	device, created, err := r.DeviceRepository.TouchDevice(ctx, userID, keyHash, fingerprinted, sealed)
+	if err != nil {
+		return domain.Device{}, false, err
+	}
+	device.Label = label
+	return device, created, nil
+}
+
+func (r *encryptedDeviceRepository) ListDevices(ctx context.Context, userID string) ([]domain.Device, error) {
+	devices, err := r.DeviceRepository.ListDevices(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range devices {
+		d := &devices[i]
+		if d.Label, err = r.cipher.Decrypt(d.Label); err != nil {
+			return nil, fmt.Errorf("device %s label: %w", d.ID, err)
+		}
+	}
+	return devices, nil
+}
+
+type encryptedQRLoginRepository struct {
+	domain.QRLoginRepository
+	cipher *util.MetadataCipher
+}
+
+func (r *encryptedQRLoginRepository) CreateQRLogin(ctx context.Context, req domain.QRLoginRequest) error {
+	var err error
+	if req.DeviceName, err = r.cipher.Encrypt(req.DeviceName); err != nil {
+		return err
+	}
+	if req.IPAddr, err = r.cipher.Encrypt(req.IPAddr); err != nil {
+		return err
+	}
+	if req.UserAgent, err = r.cipher.Encrypt(req.UserAgent); err != nil {
+		return err
+	}
+	return r.QRLoginRepository.CreateQRLogin(ctx, req)
+}
+
+func (r *encryptedQRLoginRepository) GetQRLogin(ctx context.Context, id string, now time.Time) (domain.QRLoginRequest, error) {
+	req, err := r.QRLoginRepository.GetQRLogin(ctx, id, now)
+	if err != nil {
+		return domain.QRLoginRequest{}, err
+	}
+	if req.DeviceName, err = r.cipher.Decrypt(req.DeviceName); err != nil {
+		return domain.QRLoginRequest{}, fmt.Errorf("qr login %s device name: %w", id, err)
+	}
+	if req.IPAddr, err = r.cipher.Decrypt(req.IPAddr); err != nil {
+		return domain.QRLoginRequest{}, fmt.Errorf("qr login %s ip address: %w", id, err)
+	}
+	if req.UserAgent, err = r.cipher.Decrypt(req.UserAgent); err != nil {
+		return domain.QRLoginRequest{}, fmt.Errorf("qr login %s user agent: %w", id, err)
+	}
+	return req, nil
+}
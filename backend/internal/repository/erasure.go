@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// userDataCheck is one place personal data about a user can live: a table
+// and the condition matching their rows. Where refers to the user through
+// the :id (as stored in ID columns), :id_text and :email tokens; see
+// bindUserData.
+type userDataCheck struct {
+	Table string
+	Where string
+}
+
+// userDataChecks lists every table that can hold data about a user, for the
+// erasure verification report. contains(column, token) is the dialect's
+// substring test on a text or JSON column; it finds the user mentioned in
+// free-form payloads such as audit event data.
+func userDataChecks(contains func(column, token string) string) []userDataCheck {
+	mentions := func(column string) string {
+		return "(" + contains(column, ":id_text") + " OR (:email <> '' AND " + contains(column, ":email") + "))"
+	}
+	return []userDataCheck{
+		{"users", "id = :id OR email = :email"},
+		{"auth_credentials", "user_id = :id"},
+		{"user_recovery", "user_id = :id"},
+		{"user_keys", "user_id = :id"},
+		{"totp_recovery_codes", "user_id = :id"},
+		{"sessions", "user_id = :id"},
+		{"retired_session_tokens", "session_id IN (SELECT id FROM sessions WHERE user_id = :id)"},
+		{"user_devices", "user_id = :id"},
+		{"passkeys", "user_id = :id"},
+		{"passkey_challenges", "user_id = :id OR (:email <> '' AND email = :email)"},
+		{"qr_login_requests", "user_id = :id"},
+		{"password_reset_tokens", "user_id = :id"},
+		{"push_tokens", "user_id = :id"},
+		{"machine_secrets", "owner_user_id = :id"},
+		{"machine_keys", "owner_user_id = :id"},
+		{"vault_folders", "owner_user_id = :id"},
+		{"vault_items", "owner_user_id = :id"},
+		{"vault_item_versions", "owner_user_id = :id"},
+		{"vault_item_uri_hmacs", "owner_user_id = :id"},
+		{"vault_item_usage", "owner_user_id = :id"},
+		{"vault_item_tombstones", "owner_user_id = :id"},
+		{"vault_item_access_alerts", "owner_user_id = :id"},
+		{"vault_attachments", "item_id IN (SELECT id FROM vault_items WHERE owner_user_id = :id)"},
+		{"vault_shares", "user_id = :id OR shared_by_user_id = :id"},
+		{"access_requests", "owner_user_id = :id OR requester_user_id = :id OR decided_by_user_id = :id"},
+		{"share_invitations", "shared_by_user_id = :id OR recipient_user_id = :id OR (:email <> '' AND email = :email)"},
+		{"family_memberships", "user_id = :id OR friend_id = :id OR initiated_by = :id"},
+		{"notification_preferences", "user_id = :id"},
+		{"session_bindings", "user_id = :id"},
+		{"webhook_endpoints", "user_id = :id"},
+		{"webhook_deliveries", mentions("payload")},
+		{"data_exports", "user_id = :id"},
+		{"audit_events", "user_id = :id OR actor_user_id = :id OR " + mentions("event_data")},
+		{"outbox_events", mentions("payload")},
+		{"backups_registry", "created_by_user_id = :id"},
+	}
+}
+
+// userDataCountQuery counts the rows of every check in one statement.
+func userDataCountQuery(checks []userDataCheck) string {
+	parts := make([]string, 0, len(checks))
+	for _, c := range checks {
+		parts = append(parts, "SELECT '"+c.Table+"', COUNT(*) FROM "+c.Table+" WHERE "+c.Where)
+	}
+	return strings.Join(parts, "\nUNION ALL\n")
+}
+
+// userErasureStatements delete the rows about a user that do not go away
+// with the users row: audit events, webhook deliveries and queued outbox
+// events that mention them, the backups they registered, pending passkey
+// challenges and share invitations of their email. Everything else
+// references users ON DELETE CASCADE.
+func userErasureStatements(checks []userDataCheck) []string {
+	var stmts []string
+	for _, c := range checks {
+		switch c.Table {
+		case "audit_events", "webhook_deliveries", "outbox_events", "backups_registry", "passkey_challenges", "share_invitations":
+			stmts = append(stmts, "DELETE FROM "+c.Table+" WHERE "+c.Where)
+		}
+	}
+	return stmts
+}
+
+// bindUserData replaces the :id, :id_text and :email tokens of query with
+// $N placeholders, numbered in order of first use, and returns the matching
+// arguments. Drivers reject arguments a statement does not use, so each
+// statement only gets the ones it refers to.
+func bindUserData(query string, id any, idText, email string) (string, []any) {
+	return bindTokens(query, map[string]any{":id_text": idText, ":id": id, ":email": email})
+}
+
+// bindTokens replaces the :name tokens of query that values has with $N
+// placeholders, numbered in order of first use, and returns the matching
+// arguments. Where tokens share a prefix the longest one wins.
+func bindTokens(query string, values map[string]any) (string, []any) {
+	tokens := make([]string, 0, len(values))
+	for t := range values {
+		tokens = append(tokens, t)
+	}
+	slices.SortFunc(tokens, func(a, b string) int { return len(b) - len(a) })
+
+	var args []any
+	numbers := make(map[string]string)
+	var b strings.Builder
+	for i := 0; i < len(query); {
+		token := ""
+		for _, t := range tokens {
+			if strings.HasPrefix(query[i:], t) {
+				token = t
+				break
+			}
+		}
+		if token == "" {
+			b.WriteByte(query[i])
+			i++
+			continue
+		}
+		n, ok := numbers[token]
+		if !ok {
+			args = append(args, values[token])
+			n = "$" + strconv.Itoa(len(args))
+			numbers[token] = n
+		}
+		b.WriteString(n)
+		i += len(token)
+	}
+	return b.String(), args
+}
+
+func scanStrings(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+// scanTableCounts reads the (table, count) rows of userDataCountQuery.
+func scanTableCounts(rows *sql.Rows) (map[string]int, error) {
+	defer rows.Close()
+	counts := make(map[string]int)
+	for rows.Next() {
+		var table string
+		var count int
+		if err := rows.Scan(&table, &count); err != nil {
+			return nil, fmt.Errorf("scan user data count: %w", err)
+		}
+		counts[table] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate user data counts: %w", err)
+	}
+	return counts, nil
+}
@@ -6,8 +6,6 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/lib/pq"
-
 	"pmv2/backend/internal/domain"
 )
 
@@ -22,13 +20,12 @@ func NewFamilyRepository(db *sql.DB) *FamilyRepository {
 // CreateRequest inserts a pending family relationship.
 // We store a single row with user_id = initiator, friend_id = recipient.
 func (r *FamilyRepository) CreateRequest(ctx context.Context, userID, friendID string) error {
-	_, err := r.db.ExecContext(ctx, `
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
 		INSERT INTO family_memberships (user_id, friend_id, status, initiated_by, created_at, updated_at)
 		VALUES ($1, $2, 'pending', $1, NOW(), NOW())
 	`, userID, friendID)
 	if err != nil {
-		var pqErr *pq.Error
-		if errors.As(err, &pqErr) && string(pqErr.Code) == "23505" {
+		if isUniqueViolation(err) {
 			return domain.ErrFamilyRequestAlreadySent
 		}
 		return fmt.Errorf("create family request: %w", err)
@@ -39,7 +36,7 @@ func (r *FamilyRepository) CreateRequest(ctx context.Context, userID, friendID s
 // AcceptRequest changes status from 'pending' to 'accepted'.
 // The accepting user is always the friend_id (recipient).
 func (r *FamilyRepository) AcceptRequest(ctx context.Context, userID, friendID string) error {
-	result, err := r.db.ExecContext(ctx, `
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
 		UPDATE family_memberships
 		SET status = 'accepted', updated_at = NOW()
 		WHERE user_id = $2 AND friend_id = $1 AND status = 'pending'
@@ -60,7 +57,7 @@ func (r *FamilyRepository) AcceptRequest(ctx context.Context, userID, friendID s
 
 // DeleteMembership removes the family relationship in either direction.
 func (r *FamilyRepository) DeleteMembership(ctx context.Context, userID, friendID string) error {
-	result, err := r.db.ExecContext(ctx, `
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
 		DELETE FROM family_memberships
 		WHERE (user_id = $1 AND friend_id = $2)
 		   OR (user_id = $2 AND friend_id = $1)
@@ -81,7 +78,7 @@ func (r *FamilyRepository) DeleteMembership(ctx context.Context, userID, friendI
 
 // ListMembers returns accepted family members for a user (from both directions).
 func (r *FamilyRepository) ListMembers(ctx context.Context, userID string) ([]domain.FamilyMember, error) {
-	rows, err := r.db.QueryContext(ctx, `
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
 		SELECT u.id, u.email, COALESCE(u.name, ''), fm.status, fm.created_at
 		FROM family_memberships fm
 		JOIN users u ON u.id = CASE 
@@ -114,7 +111,7 @@ func (r *FamilyRepository) ListMembers(ctx context.Context, userID string) ([]do
 
 // ListPendingReceived lists pending requests where this user is the recipient (friend_id).
 func (r *FamilyRepository) ListPendingReceived(ctx context.Context, userID string) ([]domain.FamilyRequest, error) {
-	rows, err := r.db.QueryContext(ctx, `
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
 		SELECT u.id, u.email, COALESCE(u.name, ''), fm.initiated_by, fm.created_at
 		FROM family_memberships fm
 		JOIN users u ON u.id = fm.user_id
@@ -143,7 +140,7 @@ func (r *FamilyRepository) ListPendingReceived(ctx context.Context, userID strin
 
 // ListPendingSent lists pending requests sent BY this user.
 func (r *FamilyRepository) ListPendingSent(ctx context.Context, userID string) ([]domain.FamilyRequest, error) {
-	rows, err := r.db.QueryContext(ctx, `
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
 		SELECT u.id, u.email, COALESCE(u.name, ''), fm.initiated_by, fm.created_at
 		FROM family_memberships fm
 		JOIN users u ON u.id = fm.friend_id
@@ -173,7 +170,7 @@ func (r *FamilyRepository) ListPendingSent(ctx context.Context, userID string) (
 // IsFamilyMember checks if two users have an accepted family relationship.
 func (r *FamilyRepository) IsFamilyMember(ctx context.Context, userID, friendID string) (bool, error) {
 	var count int
-	err := r.db.QueryRowContext(ctx, `
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM family_memberships
 		WHERE ((user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1))
 		  AND status = 'accepted'
@@ -187,7 +184,7 @@ func (r *FamilyRepository) IsFamilyMember(ctx context.Context, userID, friendID
 // GetMembership returns the record between two users (any direction).
 func (r *FamilyRepository) GetMembership(ctx context.Context, userID, friendID string) (domain.FamilyMembership, error) {
 	var m domain.FamilyMembership
-	err := r.db.QueryRowContext(ctx, `
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
 		SELECT user_id, friend_id, status, initiated_by, created_at, updated_at
 		FROM family_memberships
 		WHERE (user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1)
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+const ipBanColumns = `ip, offenses, reason, banned_until, created_at, updated_at`
+
+type IPBanRepository struct {
+	db *sql.DB
+}
+
+func NewIPBanRepository(db *sql.DB) *IPBanRepository {
+	return &IPBanRepository{db: db}
+}
+
+func (r *IPBanRepository) GetIPBan(ctx context.Context, ip string) (domain.IPBan, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `SELECT `+ipBanColumns+` FROM ip_bans WHERE ip = $1`, ip)
+	if err != nil {
+		return domain.IPBan{}, fmt.Errorf("query ip ban: %w", err)
+	}
+	bans, err := scanIPBans(rows)
+	if err != nil {
+		return domain.IPBan{}, err
+	}
+	if len(bans) == 0 {
+		return domain.IPBan{}, domain.ErrNotFound
+	}
+	return bans[0], nil
+}
+
+func (r *IPBanRepository) SaveIPBan(ctx context.Context, ban domain.IPBan) (domain.IPBan, error) {
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		INSERT INTO ip_bans (ip, offenses, reason, banned_until)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (ip) DO UPDATE
+		SET offenses = EXCLUDED.offenses, reason = EXCLUDED.reason, banned_until = EXCLUDED.banned_until, updated_at = NOW()
+		RETURNING created_at, updated_at
+	`, ban.IP, ban.Offenses, ban.Reason, ban.BannedUntil).Scan(&ban.CreatedAt, &ban.UpdatedAt)
+	if err != nil {
+		return domain.IPBan{}, fmt.Errorf("save ip ban: %w", err)
+	}
+	return ban, nil
+}
+
+func (r *IPBanRepository) ListActiveIPBans(ctx context.Context, now time.Time) ([]domain.IPBan, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+ipBanColumns+` FROM ip_bans
+		WHERE banned_until > $1
+		ORDER BY banned_until DESC
+	`, now)
+	if err != nil {
+		return nil, fmt.Errorf("list ip bans: %w", err)
+	}
+	return scanIPBans(rows)
+}
+
+func (r *IPBanRepository) DeleteIPBan(ctx context.Context, ip string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM ip_bans WHERE ip = $1`, ip)
+	if err != nil {
+		return fmt.Errorf("delete ip ban: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *IPBanRepository) DeleteIPBansEndedBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM ip_bans WHERE banned_until < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("delete old ip bans: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected, nil
+}
+
+// scanIPBans reads rows of ipBanColumns; the column types scan the same on
+// every backend.
+func scanIPBans(rows *sql.Rows) ([]domain.IPBan, error) {
+	defer rows.Close()
+	bans := make([]domain.IPBan, 0)
+	for rows.Next() {
+		var b domain.IPBan
+		if err := rows.Scan(&b.IP, &b.Offenses, &b.Reason, &b.BannedUntil, &b.CreatedAt, &b.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan ip ban: %w", err)
+		}
+		bans = append(bans, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate ip bans: %w", err)
+	}
+	return bans, nil
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"pmv2/backend/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type JobRepository struct {
+	db *sql.DB
+}
+
+func NewJobRepository(db *sql.DB) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+// TryLock uses a transaction-scoped Postgres advisory lock, so the lock is
+// released automatically if the process dies mid-run.
+func (r *JobRepository) TryLock(ctx context.Context, jobName string) (func(), bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("begin job lock tx: %w", err)
+	}
+
+	var acquired bool
+	if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, jobLockKey(jobName)).Scan(&acquired); err != nil {
+		_ = tx.Rollback()
+		return nil, false, fmt.Errorf("try advisory lock: %w", err)
+	}
+	if !acquired {
+		_ = tx.Rollback()
+		return nil, false, nil
+	}
+
+	return func() { _ = tx.Rollback() }, true, nil
+}
+
+func (r *JobRepository) LastRunStartedAt(ctx context.Context, jobName string) (time.Time, error) {
+	var startedAt sql.NullTime
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT max(started_at) FROM job_runs WHERE job_name = $1
+	`, jobName).Scan(&startedAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("query last job run: %w", err)
+	}
+	return startedAt.Time, nil
+}
+
+func (r *JobRepository) StartRun(ctx context.Context, jobName string, startedAt time.Time) (string, error) {
+	id := uuid.NewString()
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO job_runs (id, job_name, status, started_at)
+		VALUES ($1, $2, $3, $4)
+	`, id, jobName, domain.JobRunStatusRunning, startedAt)
+	if err != nil {
+		return "", fmt.Errorf("insert job run: %w", err)
+	}
+	return id, nil
+}
+
+func (r *JobRepository) FinishRun(ctx context.Context, runID string, status domain.JobRunStatus, errMessage string, finishedAt time.Time) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE job_runs
+		SET status = $2, error = NULLIF($3, ''), finished_at = $4
+		WHERE id = $1
+	`, runID, status, errMessage, finishedAt)
+	if err != nil {
+		return fmt.Errorf("update job run: %w", err)
+	}
+	return nil
+}
+
+func (r *JobRepository) DeleteRunsBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM job_runs WHERE started_at < $1
+	`, before)
+	if err != nil {
+		return 0, fmt.Errorf("delete job runs: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected, nil
+}
+
+func jobLockKey(jobName string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("pmv2:job:" + jobName))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"pmv2/backend/internal/domain"
+)
+
+const (
+	machineSecretColumns = `id, owner_user_id, item_id, name, ciphertext, nonce, algo_version, created_at, updated_at`
+	machineKeyColumns    = `id, owner_user_id, name, key_hash, prefix, scopes, expires_at, last_used_at, created_at`
+)
+
+type MachineSecretRepository struct {
+	db *sql.DB
+}
+
+func NewMachineSecretRepository(db *sql.DB) *MachineSecretRepository {
+	return &MachineSecretRepository{db: db}
+}
+
+func (r *MachineSecretRepository) UpsertMachineSecret(ctx context.Context, s domain.MachineSecret) (domain.MachineSecret, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		INSERT INTO machine_secrets (id, owner_user_id, item_id, name, ciphertext, nonce, algo_version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (item_id) DO UPDATE
+		SET name = EXCLUDED.name, ciphertext = EXCLUDED.ciphertext, nonce = EXCLUDED.nonce,
+			algo_version = EXCLUDED.algo_version, updated_at = NOW()
+		RETURNING `+machineSecretColumns, uuid.NewString(), s.OwnerUserID, s.ItemID, s.Name, s.Ciphertext, s.Nonce, s.AlgoVersion)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.MachineSecret{}, domain.ErrMachineSecretNameTaken
+		}
+		return domain.MachineSecret{}, fmt.Errorf("upsert machine secret: %w", err)
+	}
+	return firstMachineSecret(rows)
+}
+
+func (r *MachineSecretRepository) ListMachineSecrets(ctx context.Context, ownerUserID string) ([]domain.MachineSecret, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+machineSecretColumns+` FROM machine_secrets
+		WHERE owner_user_id = $1
+		ORDER BY name
+	`, ownerUserID)
+	if err != nil {
+		return nil, fmt.Errorf("list machine secrets: %w", err)
+	}
+	return scanMachineSecrets(rows)
+}
+
+func (r *MachineSecretRepository) GetMachineSecretByName(ctx context.Context, ownerUserID, name string) (domain.MachineSecret, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+machineSecretColumns+` FROM machine_secrets
+		WHERE owner_user_id = $1 AND name = $2
+	`, ownerUserID, name)
+	if err != nil {
+		return domain.MachineSecret{}, fmt.Errorf("get machine secret: %w", err)
+	}
+	return firstMachineSecret(rows)
+}
+
+func (r *MachineSecretRepository) DeleteMachineSecret(ctx context.Context, ownerUserID, itemID string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM machine_secrets WHERE item_id = $1 AND owner_user_id = $2`, itemID, ownerUserID)
+	if err != nil {
+		return fmt.Errorf("delete machine secret: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *MachineSecretRepository) CreateMachineKey(ctx context.Context, key domain.MachineKey) (domain.MachineKey, error) {
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		INSERT INTO machine_keys (id, owner_user_id, name, key_hash, prefix, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at
+	`, key.ID, key.OwnerUserID, key.Name, key.KeyHash, key.Prefix, pq.Array(key.Scopes), key.ExpiresAt).Scan(&key.CreatedAt)
+	if err != nil {
+		return domain.MachineKey{}, fmt.Errorf("insert machine key: %w", err)
+	}
+	return key, nil
+}
+
+func (r *MachineSecretRepository) ListMachineKeys(ctx context.Context, ownerUserID string) ([]domain.MachineKey, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+machineKeyColumns+` FROM machine_keys
+		WHERE owner_user_id = $1
+		ORDER BY created_at
+	`, ownerUserID)
+	if err != nil {
+		return nil, fmt.Errorf("list machine keys: %w", err)
+	}
+	return scanMachineKeys(rows)
+}
+
+func (r *MachineSecretRepository) CountMachineKeys(ctx context.Context, ownerUserID string) (int, error) {
+	var count int
+	if err := dbFor(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM machine_keys WHERE owner_user_id = $1`, ownerUserID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count machine keys: %w", err)
+	}
+	return count, nil
+}
+
+func (r *MachineSecretRepository) GetMachineKeyByHash(ctx context.Context, keyHash []byte) (domain.MachineKey, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `SELECT `+machineKeyColumns+` FROM machine_keys WHERE key_hash = $1`, keyHash)
+	if err != nil {
+		return domain.MachineKey{}, fmt.Errorf("get machine key: %w", err)
+	}
+	keys, err := scanMachineKeys(rows)
+	if err != nil {
+		return domain.MachineKey{}, err
+	}
+	if len(keys) == 0 {
+		return domain.MachineKey{}, domain.ErrNotFound
+	}
+	return keys[0], nil
+}
+
+func (r *MachineSecretRepository) DeleteMachineKey(ctx context.Context, ownerUserID, id string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM machine_keys WHERE id = $1 AND owner_user_id = $2`, id, ownerUserID)
+	if err != nil {
+		return fmt.Errorf("delete machine key: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *MachineSecretRepository) TouchMachineKey(ctx context.Context, id string, usedAt time.Time) error {
+	if _, err := dbFor(ctx, r.db).ExecContext(ctx, `UPDATE machine_keys SET last_used_at = $2 WHERE id = $1`, id, usedAt); err != nil {
+		return fmt.Errorf("touch machine key: %w", err)
+	}
+	return nil
+}
+
+func firstMachineSecret(rows *sql.Rows) (domain.MachineSecret, error) {
+	secrets, err := scanMachineSecrets(rows)
+	if err != nil {
+		return domain.MachineSecret{}, err
+	}
+	if len(secrets) == 0 {
+		return domain.MachineSecret{}, domain.ErrNotFound
+	}
+	return secrets[0], nil
+}
+
+func scanMachineSecrets(rows *sql.Rows) ([]domain.MachineSecret, error) {
+	defer rows.Close()
+	secrets := make([]domain.MachineSecret, 0)
+	for rows.Next() {
+		var s domain.MachineSecret
+		if err := rows.Scan(&s.ID, &s.OwnerUserID, &s.ItemID, &s.Name, &s.Ciphertext, &s.Nonce, &s.AlgoVersion, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan machine secret: %w", err)
+		}
+		secrets = append(secrets, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate machine secrets: %w", err)
+	}
+	return secrets, nil
+}
+
+func scanMachineKeys(rows *sql.Rows) ([]domain.MachineKey, error) {
+	defer rows.Close()
+	keys := make([]domain.MachineKey, 0)
+	for rows.Next() {
+		var k domain.MachineKey
+		var expiresAt, lastUsed sql.NullTime
+		if err := rows.Scan(&k.ID, &k.OwnerUserID, &k.Name, &k.KeyHash, &k.Prefix, pq.Array(&k.Scopes), &expiresAt, &lastUsed, &k.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan machine key: %w", err)
+		}
+		setMachineKeyTimes(&k, expiresAt, lastUsed)
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate machine keys: %w", err)
+	}
+	return keys, nil
+}
+
+func setMachineKeyTimes(k *domain.MachineKey, expiresAt, lastUsed sql.NullTime) {
+	if expiresAt.Valid {
+		t := expiresAt.Time.UTC()
+		k.ExpiresAt = &t
+	}
+	if lastUsed.Valid {
+		t := lastUsed.Time.UTC()
+		k.LastUsedAt = &t
+	}
+}
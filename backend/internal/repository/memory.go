@@ -0,0 +1,338 @@
+package repository
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"pmv2/backend/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// MemoryDB holds the tables behind the in-memory repositories. It backs
+// APP_ENV=demo and tests that want real repository behaviour without a
+// database; nothing survives a restart.
+//
+// One mutex guards every table. Each repository call holds it for its whole
+// duration, and MemoryTransactor holds it for a whole transaction, so calls
+// are serialised much as SQLite serialises write transactions.
+type MemoryDB struct {
+	mu    sync.Mutex
+	data  memoryData
+	locks sync.Map // name -> *sync.Mutex, see tryLock
+}
+
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{data: newMemoryData()}
+}
+
+type memoryUser struct {
+	ID            string
+	Email         string
+	Name          string
+	Role          domain.UserRole
+	EmailVerified bool
+	CreatedAt     time.Time
+
+	CanonicalEmail string
+
+	MergedInto string
+	MergedAt   *time.Time
+}
+
+type memoryCredential struct {
+	Algo               string
+	Params             []byte
+	Salt               []byte
+	PasswordHash       []byte
+	TOTPEnabled        bool
+	TOTPSecretEnc      []byte
+	TOTPFailedAttempts int
+	TOTPWindowStart    *time.Time
+	TOTPLockedUntil    *time.Time
+	CompromisedAt      *time.Time
+}
+
+type memorySession struct {
+	ID         string
+	UserID     string
+	TokenHash  string
+	DeviceName string
+	IPAddress  string
+	UserAgent  string
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	RevokedAt  *time.Time
+	StepUp     bool
+	DeviceID   string
+	// DPoPThumbprint is the key the session is bound to, if any.
+	DPoPThumbprint string
+	// Retired maps the hashes of the tokens refreshes replaced to when.
+	Retired map[string]time.Time
+
+	LastUsedAt *time.Time
+}
+
+func (s memorySession) active(now time.Time) bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(now)
+}
+
+type memoryRecoveryCode struct {
+	UserID   string
+	CodeHash string
+}
+
+type memoryShareKey struct {
+	ItemID string
+	UserID string
+}
+
+type memoryFamilyKey struct {
+	UserID   string
+	FriendID string
+}
+
+type memoryOutboxEvent struct {
+	domain.OutboxEvent
+	NextAttemptAt time.Time
+	DispatchedAt  *time.Time
+	FailedAt      *time.Time
+	LastError     string
+}
+
+type memoryDataExport struct {
+	domain.DataExport
+	Archive []byte
+}
+
+type memoryDevice struct {
+	domain.Device
+	KeyHash string
+}
+
+type memoryURIHMAC struct {
+	ItemID string
+	HMAC   string
+}
+
+// memoryTombstone is the vault_item_tombstones row of a purged item.
+type memoryTombstone struct {
+	OwnerUserID string
+	DeletedAt   time.Time
+}
+
+type memoryDeliveryKey struct {
+	EndpointID string
+	EventID    string
+}
+
+// memoryData is one copy of every table. Rows are stored by value and
+// replaced rather than modified in place, so a shallow copy of the maps is
+// a consistent snapshot.
+type memoryData struct {
+	users             map[string]memoryUser
+	credentials       map[string]memoryCredential
+	sessions          map[string]memorySession
+	devices           map[string]memoryDevice
+	passkeys          map[string]domain.Passkey
+	passkeyChallenges map[string]domain.PasskeyChallenge
+	qrLogins          map[string]domain.QRLoginRequest
+	passwordResets    map[string]domain.PasswordResetToken
+	backups           map[string]domain.BackupRecord
+	pushTokens        map[string]domain.PushToken
+	machineSecrets    map[string]domain.MachineSecret
+	machineKeys       map[string]domain.MachineKey
+	accessRequests    map[string]domain.AccessRequest
+	shareInvitations  map[string]domain.ShareInvitation
+	recoveryCodes     map[memoryRecoveryCode]bool // -> used
+	recovery          map[string]domain.RecoveryRecord
+	items             map[string]domain.VaultItem
+	itemVersions      map[string]domain.VaultItemVersion
+	uriHMACs          map[memoryURIHMAC]bool
+	itemUsage         map[string]domain.ItemUsage
+	itemAccessAlerts  map[string]domain.ItemAccessAlert
+	attachments       map[string]domain.VaultAttachment
+	itemTombstones    map[string]memoryTombstone
+	folders           map[string]domain.VaultFolder
+	userKeys          map[string]domain.UserKeys
+	shares            map[memoryShareKey]domain.VaultShare
+	family            map[memoryFamilyKey]domain.FamilyMembership
+	auditEvents       map[uuid.UUID]domain.AuditEvent
+	exportCursors     map[string]domain.AuditCursor
+	jobRuns           map[string]domain.JobRun
+	outbox            map[string]memoryOutboxEvent
+	webhookEndpoints  map[string]domain.WebhookEndpoint
+	webhookDeliveries map[string]domain.WebhookDelivery
+	deliveryKeys      map[memoryDeliveryKey]string // -> delivery ID
+	notificationPrefs map[string]domain.NotificationPreferences
+	sessionBindings   map[string]domain.SessionBinding
+	featureFlags      map[string]domain.FeatureFlag
+	dataExports       map[string]memoryDataExport
+	ipBans            map[string]domain.IPBan
+	maintenance       domain.MaintenanceState
+}
+
+func newMemoryData() memoryData {
+	return memoryData{
+		users:             make(map[string]memoryUser),
+		credentials:       make(map[string]memoryCredential),
+		sessions:          make(map[string]memorySession),
+		devices:           make(map[string]memoryDevice),
+		passkeys:          make(map[string]domain.Passkey),
+		passkeyChallenges: make(map[string]domain.PasskeyChallenge),
+		qrLogins:          make(map[string]domain.QRLoginRequest),
+		passwordResets:    make(map[string]domain.PasswordResetToken),
+		backups:           make(map[string]domain.BackupRecord),
+		pushTokens:        make(map[string]domain.PushToken),
+		machineSecrets:    make(map[string]domain.MachineSecret),
+		machineKeys:       make(map[string]domain.MachineKey),
+		accessRequests:    make(map[string]domain.AccessRequest),
+		shareInvitations:  make(map[string]domain.ShareInvitation),
+		recoveryCodes:     make(map[memoryRecoveryCode]bool),
+		recovery:          make(map[string]domain.RecoveryRecord),
+		items:             make(map[string]domain.VaultItem),
+		itemVersions:      make(map[string]domain.VaultItemVersion),
+		uriHMACs:          make(map[memoryURIHMAC]bool),
+		itemUsage:         make(map[string]domain.ItemUsage),
+		itemAccessAlerts:  make(map[string]domain.ItemAccessAlert),
+		attachments:       make(map[string]domain.VaultAttachment),
+		itemTombstones:    make(map[string]memoryTombstone),
+		folders:           make(map[string]domain.VaultFolder),
+		userKeys:          make(map[string]domain.UserKeys),
+		shares:            make(map[memoryShareKey]domain.VaultShare),
+		family:            make(map[memoryFamilyKey]domain.FamilyMembership),
+		auditEvents:       make(map[uuid.UUID]domain.AuditEvent),
+		exportCursors:     make(map[string]domain.AuditCursor),
+		jobRuns:           make(map[string]domain.JobRun),
+		outbox:            make(map[string]memoryOutboxEvent),
+		webhookEndpoints:  make(map[string]domain.WebhookEndpoint),
+		webhookDeliveries: make(map[string]domain.WebhookDelivery),
+		deliveryKeys:      make(map[memoryDeliveryKey]string),
+		notificationPrefs: make(map[string]domain.NotificationPreferences),
+		sessionBindings:   make(map[string]domain.SessionBinding),
+		featureFlags:      make(map[string]domain.FeatureFlag),
+		dataExports:       make(map[string]memoryDataExport),
+		ipBans:            make(map[string]domain.IPBan),
+	}
+}
+
+func (d memoryData) clone() memoryData {
+	return memoryData{
+		users:             maps.Clone(d.users),
+		credentials:       maps.Clone(d.credentials),
+		sessions:          maps.Clone(d.sessions),
+		devices:           maps.Clone(d.devices),
+		passkeys:          maps.Clone(d.passkeys),
+		passkeyChallenges: maps.Clone(d.passkeyChallenges),
+		qrLogins:          maps.Clone(d.qrLogins),
+		passwordResets:    maps.Clone(d.passwordResets),
+		backups:           maps.Clone(d.backups),
+		pushTokens:        maps.Clone(d.pushTokens),
+		machineSecrets:    maps.Clone(d.machineSecrets),
+		machineKeys:       maps.Clone(d.machineKeys),
+		accessRequests:    maps.Clone(d.accessRequests),
+		shareInvitations:  maps.Clone(d.shareInvitations),
+		recoveryCodes:     maps.Clone(d.recoveryCodes),
+		recovery:          maps.Clone(d.recovery),
+		items:             maps.Clone(d.items),
+		itemVersions:      maps.Clone(d.itemVersions),
+		uriHMACs:          maps.Clone(d.uriHMACs),
+		itemUsage:         maps.Clone(d.itemUsage),
+		itemAccessAlerts:  maps.Clone(d.itemAccessAlerts),
+		attachments:       maps.Clone(d.attachments),
+		itemTombstones:    maps.Clone(d.itemTombstones),
+		folders:           maps.Clone(d.folders),
+		userKeys:          maps.Clone(d.userKeys),
+		shares:            maps.Clone(d.shares),
+		family:            maps.Clone(d.family),
+		auditEvents:       maps.Clone(d.auditEvents),
+		exportCursors:     maps.Clone(d.exportCursors),
+		jobRuns:           maps.Clone(d.jobRuns),
+		outbox:            maps.Clone(d.outbox),
+		webhookEndpoints:  maps.Clone(d.webhookEndpoints),
+		webhookDeliveries: maps.Clone(d.webhookDeliveries),
+		deliveryKeys:      maps.Clone(d.deliveryKeys),
+		notificationPrefs: maps.Clone(d.notificationPrefs),
+		sessionBindings:   maps.Clone(d.sessionBindings),
+		featureFlags:      maps.Clone(d.featureFlags),
+		dataExports:       maps.Clone(d.dataExports),
+		ipBans:            maps.Clone(d.ipBans),
+		maintenance:       d.maintenance,
+	}
+}
+
+type memoryTxContextKey struct{}
+
+// lock takes the table lock unless ctx belongs to a transaction on m, which
+// already holds it. The returned func releases it.
+func (m *MemoryDB) lock(ctx context.Context) func() {
+	if db, _ := ctx.Value(memoryTxContextKey{}).(*MemoryDB); db == m {
+		return func() {}
+	}
+	m.mu.Lock()
+	return m.mu.Unlock
+}
+
+// tryLock stands in for advisory locks, like sqliteTryLock but scoped to
+// this MemoryDB.
+func (m *MemoryDB) tryLock(name string) (release func(), acquired bool) {
+	value, _ := m.locks.LoadOrStore(name, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	if !mu.TryLock() {
+		return nil, false
+	}
+	return mu.Unlock, true
+}
+
+// userByEmail is the users.email unique index.
+func (d memoryData) userByEmail(email string) (memoryUser, bool) {
+	for _, u := range d.users {
+		if u.Email == email {
+			return u, true
+		}
+	}
+	return memoryUser{}, false
+}
+
+// MemoryTransactor is Transactor for a MemoryDB. A transaction holds the
+// table lock until fn returns and rolls back by restoring the snapshot
+// taken when it began.
+type MemoryTransactor struct {
+	db *MemoryDB
+}
+
+func NewMemoryTransactor(db *MemoryDB) *MemoryTransactor {
+	return &MemoryTransactor{db: db}
+}
+
+// WithinTx runs fn in a transaction carried by the context. Nested calls
+// join the outer transaction instead of opening a new one.
+func (t *MemoryTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if db, _ := ctx.Value(memoryTxContextKey{}).(*MemoryDB); db == t.db {
+		return fn(ctx)
+	}
+
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+
+	snapshot := t.db.data.clone()
+	committed := false
+	defer func() {
+		if !committed {
+			t.db.data = snapshot
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, memoryTxContextKey{}, t.db)); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+// memoryNow stands in for NOW(). Postgres keeps microseconds.
+func memoryNow() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
@@ -0,0 +1,716 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"pmv2/backend/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type MemoryAdminRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryAdminRepository(db *MemoryDB) *MemoryAdminRepository {
+	return &MemoryAdminRepository{db: db}
+}
+
+// GetInstanceStats reports no DatabaseBytes; there is no database file to
+// measure.
+func (r *MemoryAdminRepository) GetInstanceStats(ctx context.Context, days int) (domain.InstanceStats, error) {
+	defer r.db.lock(ctx)()
+	data := r.db.data
+
+	stats := domain.InstanceStats{GeneratedAt: time.Now().UTC()}
+	monthAgo := stats.GeneratedAt.AddDate(0, 0, -30)
+
+	for _, u := range data.users {
+		stats.Users++
+		if u.Role == domain.UserRoleAdmin {
+			stats.Admins++
+		}
+		if !u.CreatedAt.Before(monthAgo) {
+			stats.NewUsers30d++
+		}
+	}
+	for _, c := range data.credentials {
+		if c.TOTPEnabled {
+			stats.TOTPUsers++
+		}
+	}
+	for _, s := range data.sessions {
+		if s.active(stats.GeneratedAt) {
+			stats.ActiveSessions++
+		}
+	}
+	for _, item := range data.items {
+		if item.DeletedAt == nil {
+			stats.VaultItems++
+		} else {
+			stats.TrashedItems++
+		}
+		stats.Storage.VaultBytes += int64(len(item.Ciphertext) + len(item.WrappedDEK))
+	}
+	for _, v := range data.itemVersions {
+		stats.Storage.HistoryBytes += int64(len(v.Ciphertext) + len(v.WrappedDEK))
+	}
+	stats.Folders = len(data.folders)
+	stats.Shares = len(data.shares)
+
+	// Activity is defined as in activitySince: sign-ins, plus audited
+	// actions attributed to their actor.
+	today := stats.GeneratedAt.Truncate(24 * time.Hour)
+	firstDay := today.AddDate(0, 0, 1-days)
+	since := firstDay
+	if monthAgo.Before(since) {
+		since = monthAgo
+	}
+	var (
+		active1d, active7d, active30d = map[string]bool{}, map[string]bool{}, map[string]bool{}
+		daily                         = map[string]map[string]bool{}
+	)
+	record := func(userID string, at time.Time) {
+		if at.Before(since) {
+			return
+		}
+		if !at.Before(monthAgo) {
+			active30d[userID] = true
+			if !at.Before(stats.GeneratedAt.AddDate(0, 0, -7)) {
+				active7d[userID] = true
+			}
+			if !at.Before(stats.GeneratedAt.AddDate(0, 0, -1)) {
+				active1d[userID] = true
+			}
+		}
+		if !at.Before(firstDay) {
+			day := at.UTC().Format(time.DateOnly)
+			if daily[day] == nil {
+				daily[day] = map[string]bool{}
+			}
+			daily[day][userID] = true
+		}
+	}
+	for _, s := range data.sessions {
+		record(s.UserID, s.CreatedAt)
+	}
+	for _, e := range data.auditEvents {
+		if e.ActorUserID != nil {
+			record(e.ActorUserID.String(), e.CreatedAt)
+		}
+	}
+	stats.ActiveUsers1d, stats.ActiveUsers7d, stats.ActiveUsers30d = len(active1d), len(active7d), len(active30d)
+
+	stats.DailyActive = make([]domain.DailyActiveUsers, 0, days)
+	for day := firstDay; !day.After(today); day = day.AddDate(0, 0, 1) {
+		stats.DailyActive = append(stats.DailyActive, domain.DailyActiveUsers{Date: day, Users: len(daily[day.Format(time.DateOnly)])})
+	}
+	return stats, nil
+}
+
+// adminUser is sqliteAdminUserSelect for one user.
+func (r *MemoryAdminRepository) adminUser(u memoryUser, now time.Time) domain.AdminUser {
+	data := r.db.data
+	cred := data.credentials[u.ID]
+	user := domain.AdminUser{
+		ID:              u.ID,
+		Email:           u.Email,
+		Name:            u.Name,
+		Role:            u.Role,
+		EmailVerified:   u.EmailVerified,
+		TOTPEnabled:     cred.TOTPEnabled,
+		TOTPLockedUntil: cred.TOTPLockedUntil,
+		CreatedAt:       u.CreatedAt,
+	}
+	for _, s := range data.sessions {
+		if s.UserID != u.ID {
+			continue
+		}
+		if s.active(now) {
+			user.ActiveSessions++
+		}
+		if user.LastLoginAt == nil || s.CreatedAt.After(*user.LastLoginAt) {
+			createdAt := s.CreatedAt
+			user.LastLoginAt = &createdAt
+		}
+	}
+	for _, item := range data.items {
+		if item.OwnerUserID == u.ID && item.DeletedAt == nil {
+			user.VaultItems++
+		}
+	}
+	return user
+}
+
+func (r *MemoryAdminRepository) SearchUsers(ctx context.Context, query string, limit int) ([]domain.AdminUser, error) {
+	defer r.db.lock(ctx)()
+
+	matches := make([]memoryUser, 0)
+	for _, u := range r.db.data.users {
+		if strings.HasPrefix(u.Email, query) {
+			matches = append(matches, u)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Email < matches[j].Email })
+	if limit < len(matches) {
+		matches = matches[:limit]
+	}
+
+	now := memoryNow()
+	users := make([]domain.AdminUser, 0, len(matches))
+	for _, u := range matches {
+		users = append(users, r.adminUser(u, now))
+	}
+	return users, nil
+}
+
+func (r *MemoryAdminRepository) GetUser(ctx context.Context, userID string) (domain.AdminUser, error) {
+	defer r.db.lock(ctx)()
+	u, ok := r.db.data.users[userID]
+	if !ok {
+		return domain.AdminUser{}, domain.ErrNotFound
+	}
+	return r.adminUser(u, memoryNow()), nil
+}
+
+func (r *MemoryAdminRepository) ListActiveSessions(ctx context.Context, userID string) ([]domain.AdminSession, error) {
+	defer r.db.lock(ctx)()
+
+	now := memoryNow()
+	sessions := make([]domain.AdminSession, 0)
+	for _, s := range r.db.data.sessions {
+		if s.UserID == userID && s.active(now) {
+			sessions = append(sessions, domain.AdminSession{
+				ID:         s.ID,
+				DeviceName: s.DeviceName,
+				IPAddress:  s.IPAddress,
+				UserAgent:  s.UserAgent,
+				CreatedAt:  s.CreatedAt,
+				ExpiresAt:  s.ExpiresAt,
+			})
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].CreatedAt.Equal(sessions[j].CreatedAt) {
+			return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+		}
+		return sessions[i].ID < sessions[j].ID
+	})
+	return sessions, nil
+}
+
+func (r *MemoryAdminRepository) RevokeSession(ctx context.Context, userID, sessionID string) (bool, error) {
+	defer r.db.lock(ctx)()
+	s, ok := r.db.data.sessions[sessionID]
+	if !ok || s.UserID != userID || s.RevokedAt != nil {
+		return false, nil
+	}
+	now := memoryNow()
+	s.RevokedAt = &now
+	r.db.data.sessions[sessionID] = s
+	return true, nil
+}
+
+func (r *MemoryAdminRepository) ListFeatureFlags(ctx context.Context) ([]domain.FeatureFlag, error) {
+	defer r.db.lock(ctx)()
+	flags := make([]domain.FeatureFlag, 0, len(r.db.data.featureFlags))
+	for _, flag := range r.db.data.featureFlags {
+		flags = append(flags, flag)
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Key < flags[j].Key })
+	return flags, nil
+}
+
+// SetFeatureFlag keeps the stored description when flag has none.
+func (r *MemoryAdminRepository) SetFeatureFlag(ctx context.Context, flag domain.FeatureFlag) (domain.FeatureFlag, error) {
+	defer r.db.lock(ctx)()
+	if existing, ok := r.db.data.featureFlags[flag.Key]; ok && flag.Description == "" {
+		flag.Description = existing.Description
+	}
+	flag.UpdatedAt = memoryNow()
+	r.db.data.featureFlags[flag.Key] = flag
+	return flag, nil
+}
+
+func (r *MemoryAdminRepository) GetMaintenance(ctx context.Context) (domain.MaintenanceState, error) {
+	defer r.db.lock(ctx)()
+	return r.db.data.maintenance, nil
+}
+
+func (r *MemoryAdminRepository) SaveMaintenance(ctx context.Context, state domain.MaintenanceState, updatedBy *string) error {
+	defer r.db.lock(ctx)()
+	state.Forced = false
+	r.db.data.maintenance = state
+	return nil
+}
+
+func (r *MemoryAdminRepository) EraseUser(ctx context.Context, userID, email string) ([]string, error) {
+	defer r.db.lock(ctx)()
+	if _, ok := r.db.data.users[userID]; !ok {
+		return nil, domain.ErrNotFound
+	}
+	paths := make([]string, 0)
+	for _, a := range r.db.data.attachments {
+		if r.db.data.items[a.ItemID].OwnerUserID == userID {
+			paths = append(paths, a.StoragePath)
+		}
+	}
+	r.db.data.userData(userID, email, true)
+	return paths, nil
+}
+
+func (r *MemoryAdminRepository) CountUserData(ctx context.Context, userID, email string) (map[string]int, error) {
+	defer r.db.lock(ctx)()
+	return r.db.data.userData(userID, email, false), nil
+}
+
+// MergeAccounts follows accountMergeStatements over the maps.
+func (r *MemoryAdminRepository) MergeAccounts(ctx context.Context, input domain.AccountMergeInput) (map[string]int64, error) {
+	defer r.db.lock(ctx)()
+	d := r.db.data
+	source, target := input.SourceUserID, input.TargetUserID
+	user, ok := d.users[source]
+	if !ok || user.MergedInto != "" {
+		return nil, domain.ErrNotFound
+	}
+	received := 0
+	for key := range d.shares {
+		if key.UserID == source {
+			received++
+		}
+	}
+	if !uniqueItemKeys(input.Shares) || received != len(input.Shares) {
+		return nil, domain.ErrShareRewrapIncomplete
+	}
+	for _, key := range input.Shares {
+		if !hasKey(d.shares, memoryShareKey{ItemID: key.ItemID, UserID: source}) {
+			return nil, domain.ErrShareRewrapIncomplete
+		}
+	}
+
+	now := time.Now().UTC()
+	moved := make(map[string]int64)
+	for _, key := range input.Shares {
+		k := memoryShareKey{ItemID: key.ItemID, UserID: source}
+		share := d.shares[k]
+		share.DEKWrapped, share.WrapNonce = key.WrappedDEK, key.WrapNonce
+		d.shares[k] = share
+	}
+	for key := range d.shares {
+		owner := d.items[key.ItemID].OwnerUserID
+		switch {
+		case key.UserID == source && (owner == target || hasKey(d.shares, memoryShareKey{ItemID: key.ItemID, UserID: target})),
+			key.UserID == target && owner == source:
+			delete(d.shares, key)
+		}
+	}
+	for key, share := range d.shares {
+		if share.SharedByUserID == source {
+			share.SharedByUserID = target
+		}
+		if key.UserID == source {
+			delete(d.shares, key)
+			share.UserID, share.UpdatedAt = target, now
+			key.UserID = target
+			moved["vault_shares"]++
+		}
+		d.shares[key] = share
+	}
+
+	for id, f := range d.folders {
+		if f.OwnerUserID == source {
+			f.OwnerUserID, f.UpdatedAt = target, now
+			d.folders[id] = f
+			moved["vault_folders"]++
+		}
+	}
+	for id, item := range d.items {
+		if item.OwnerUserID == source {
+			item.OwnerUserID, item.UpdatedAt = target, now
+			d.items[id] = item
+			moved["vault_items"]++
+		}
+	}
+	for id, v := range d.itemVersions {
+		if v.OwnerUserID == source {
+			v.OwnerUserID = target
+			d.itemVersions[id] = v
+		}
+	}
+	for id, a := range d.itemAccessAlerts {
+		if a.OwnerUserID == source {
+			a.OwnerUserID = target
+			d.itemAccessAlerts[id] = a
+		}
+	}
+	for id, t := range d.itemTombstones {
+		if t.OwnerUserID == source {
+			t.OwnerUserID = target
+			d.itemTombstones[id] = t
+		}
+	}
+
+	targetSecrets := make(map[string]bool)
+	for _, s := range d.machineSecrets {
+		if s.OwnerUserID == target {
+			targetSecrets[s.Name] = true
+		}
+	}
+	for id, s := range d.machineSecrets {
+		switch {
+		case s.OwnerUserID != source:
+		case targetSecrets[s.Name]:
+			delete(d.machineSecrets, id)
+		default:
+			s.OwnerUserID, s.UpdatedAt = target, now
+			d.machineSecrets[id] = s
+			moved["machine_secrets"]++
+		}
+	}
+	for id, k := range d.machineKeys {
+		if k.OwnerUserID == source {
+			k.OwnerUserID = target
+			d.machineKeys[id] = k
+			moved["machine_keys"]++
+		}
+	}
+
+	for id, req := range d.accessRequests {
+		if (req.OwnerUserID == source && req.RequesterUserID == target) || (req.OwnerUserID == target && req.RequesterUserID == source) {
+			delete(d.accessRequests, id)
+			continue
+		}
+		if req.OwnerUserID == source {
+			req.OwnerUserID = target
+			moved["access_requests"]++
+		}
+		if req.RequesterUserID == source {
+			req.RequesterUserID = target
+		}
+		if req.DecidedByUserID == source {
+			req.DecidedByUserID = target
+		}
+		d.accessRequests[id] = req
+	}
+	for id, inv := range d.shareInvitations {
+		if inv.SharedByUserID == source {
+			inv.SharedByUserID = target
+			moved["share_invitations"]++
+		}
+		if inv.RecipientUserID == source {
+			inv.RecipientUserID = target
+		}
+		d.shareInvitations[id] = inv
+	}
+
+	other := func(key memoryFamilyKey, userID string) (string, bool) {
+		switch userID {
+		case key.UserID:
+			return key.FriendID, true
+		case key.FriendID:
+			return key.UserID, true
+		}
+		return "", false
+	}
+	targetFamily := make(map[string]bool)
+	for key := range d.family {
+		if member, ok := other(key, target); ok {
+			targetFamily[member] = true
+		}
+	}
+	for key, m := range d.family {
+		member, ok := other(key, source)
+		if !ok {
+			if m.InitiatedBy == source {
+				m.InitiatedBy = target
+				d.family[key] = m
+			}
+			continue
+		}
+		delete(d.family, key)
+		if member == target || targetFamily[member] {
+			continue
+		}
+		if key.UserID == source {
+			key.UserID, m.UserID = target, target
+			moved["family_memberships"]++
+		} else {
+			key.FriendID, m.FriendID = target, target
+		}
+		if m.InitiatedBy == source {
+			m.InitiatedBy = target
+		}
+		m.UpdatedAt = now
+		d.family[key] = m
+	}
+
+	targetDevices := make(map[string]string) // key hash -> device ID
+	for id, device := range d.devices {
+		if device.UserID == target {
+			targetDevices[device.KeyHash] = id
+		}
+	}
+	replaced := make(map[string]string) // source device ID -> target's
+	for id, device := range d.devices {
+		if device.UserID != source {
+			continue
+		}
+		if targetID, ok := targetDevices[device.KeyHash]; ok {
+			replaced[id] = targetID
+			delete(d.devices, id)
+			continue
+		}
+		device.UserID = target
+		d.devices[id] = device
+		moved["user_devices"]++
+	}
+	for id, s := range d.sessions {
+		if s.UserID == source {
+			if targetID, ok := replaced[s.DeviceID]; ok {
+				s.DeviceID = targetID
+			}
+			s.UserID = target
+			d.sessions[id] = s
+			moved["sessions"]++
+		}
+	}
+	for id, t := range d.pushTokens {
+		if t.UserID == source {
+			if targetID, ok := replaced[t.DeviceID]; ok {
+				t.DeviceID = targetID
+			}
+			t.UserID, t.UpdatedAt = target, now
+			d.pushTokens[id] = t
+			moved["push_tokens"]++
+		}
+	}
+	for id, e := range d.webhookEndpoints {
+		if e.UserID == source {
+			e.UserID, e.UpdatedAt = target, now
+			d.webhookEndpoints[id] = e
+			moved["webhook_endpoints"]++
+		}
+	}
+
+	delete(d.credentials, source)
+	for id, p := range d.passkeys {
+		if p.UserID == source {
+			delete(d.passkeys, id)
+		}
+	}
+	for key := range d.recoveryCodes {
+		if key.UserID == source {
+			delete(d.recoveryCodes, key)
+		}
+	}
+	delete(d.userKeys, source)
+	delete(d.recovery, source)
+	delete(d.sessionBindings, source)
+	for id, t := range d.passwordResets {
+		if t.UserID == source {
+			delete(d.passwordResets, id)
+		}
+	}
+	user.MergedInto, user.MergedAt = target, &now
+	d.users[source] = user
+	return moved, nil
+}
+
+// userData counts, per table, the rows userDataChecks would find about the
+// user, deleting them when erase is set. Erasing also removes what the SQL
+// stores delete through ON DELETE CASCADE.
+func (d memoryData) userData(userID, email string, erase bool) map[string]int {
+	counts := make(map[string]int)
+	for _, check := range userDataChecks(func(string, string) string { return "" }) {
+		counts[check.Table] = 0
+	}
+	mentions := func(raw []byte) bool {
+		return strings.Contains(string(raw), userID) || (email != "" && strings.Contains(string(raw), email))
+	}
+	is := func(id *uuid.UUID) bool { return id != nil && id.String() == userID }
+	match := func(table string, ok bool) bool {
+		if ok {
+			counts[table]++
+		}
+		return ok && erase
+	}
+
+	for id, u := range d.users {
+		if match("users", id == userID || u.Email == email) {
+			delete(d.users, id)
+		}
+	}
+	if match("auth_credentials", hasKey(d.credentials, userID)) {
+		delete(d.credentials, userID)
+	}
+	if match("user_recovery", hasKey(d.recovery, userID)) {
+		delete(d.recovery, userID)
+	}
+	if match("user_keys", hasKey(d.userKeys, userID)) {
+		delete(d.userKeys, userID)
+	}
+	for key := range d.recoveryCodes {
+		if match("totp_recovery_codes", key.UserID == userID) {
+			delete(d.recoveryCodes, key)
+		}
+	}
+	for id, s := range d.sessions {
+		if match("sessions", s.UserID == userID) {
+			delete(d.sessions, id)
+		}
+	}
+	for id, device := range d.devices {
+		if match("user_devices", device.UserID == userID) {
+			delete(d.devices, id)
+		}
+	}
+	for id, p := range d.passkeys {
+		if match("passkeys", p.UserID == userID) {
+			delete(d.passkeys, id)
+		}
+	}
+	for id, c := range d.passkeyChallenges {
+		if match("passkey_challenges", c.UserID == userID || (email != "" && c.Email == email)) {
+			delete(d.passkeyChallenges, id)
+		}
+	}
+	for id, req := range d.qrLogins {
+		if match("qr_login_requests", req.UserID == userID) {
+			delete(d.qrLogins, id)
+		}
+	}
+	for id, t := range d.passwordResets {
+		if match("password_reset_tokens", t.UserID == userID) {
+			delete(d.passwordResets, id)
+		}
+	}
+	for id, t := range d.pushTokens {
+		if match("push_tokens", t.UserID == userID) {
+			delete(d.pushTokens, id)
+		}
+	}
+	for id, k := range d.machineKeys {
+		if match("machine_keys", k.OwnerUserID == userID) {
+			delete(d.machineKeys, id)
+		}
+	}
+	for id, s := range d.machineSecrets {
+		if match("machine_secrets", s.OwnerUserID == userID) {
+			delete(d.machineSecrets, id)
+		}
+	}
+	for id, f := range d.folders {
+		if match("vault_folders", f.OwnerUserID == userID) {
+			delete(d.folders, id)
+		}
+	}
+	for id, v := range d.itemVersions {
+		if match("vault_item_versions", v.OwnerUserID == userID) {
+			delete(d.itemVersions, id)
+		}
+	}
+	for key := range d.uriHMACs {
+		if match("vault_item_uri_hmacs", d.items[key.ItemID].OwnerUserID == userID) {
+			delete(d.uriHMACs, key)
+		}
+	}
+	for id, u := range d.itemUsage {
+		if match("vault_item_usage", d.items[u.ItemID].OwnerUserID == userID) {
+			delete(d.itemUsage, id)
+		}
+	}
+	for id, t := range d.itemTombstones {
+		if match("vault_item_tombstones", t.OwnerUserID == userID) {
+			delete(d.itemTombstones, id)
+		}
+	}
+	for id, a := range d.itemAccessAlerts {
+		if match("vault_item_access_alerts", a.OwnerUserID == userID) {
+			delete(d.itemAccessAlerts, id)
+		}
+	}
+	for id, a := range d.attachments {
+		if match("vault_attachments", d.items[a.ItemID].OwnerUserID == userID) {
+			delete(d.attachments, id)
+		}
+	}
+	for key, s := range d.shares {
+		owned := d.items[key.ItemID].OwnerUserID == userID
+		if match("vault_shares", key.UserID == userID || s.SharedByUserID == userID) || (erase && owned) {
+			delete(d.shares, key)
+		}
+	}
+	for id, req := range d.accessRequests {
+		involved := req.OwnerUserID == userID || req.RequesterUserID == userID
+		if match("access_requests", involved || req.DecidedByUserID == userID) {
+			if involved {
+				delete(d.accessRequests, id)
+			} else {
+				req.DecidedByUserID = ""
+				d.accessRequests[id] = req
+			}
+		}
+	}
+	for id, inv := range d.shareInvitations {
+		if match("share_invitations", inv.SharedByUserID == userID || inv.RecipientUserID == userID || (email != "" && inv.Email == email)) {
+			delete(d.shareInvitations, id)
+		}
+	}
+	for id, item := range d.items {
+		if match("vault_items", item.OwnerUserID == userID) {
+			delete(d.items, id)
+		}
+	}
+	for key, m := range d.family {
+		if match("family_memberships", key.UserID == userID || key.FriendID == userID || m.InitiatedBy == userID) {
+			delete(d.family, key)
+		}
+	}
+	if match("notification_preferences", hasKey(d.notificationPrefs, userID)) {
+		delete(d.notificationPrefs, userID)
+	}
+	if match("session_bindings", hasKey(d.sessionBindings, userID)) {
+		delete(d.sessionBindings, userID)
+	}
+	for id, e := range d.webhookEndpoints {
+		if match("webhook_endpoints", e.UserID == userID) {
+			delete(d.webhookEndpoints, id)
+		}
+	}
+	for id, delivery := range d.webhookDeliveries {
+		_, endpointLeft := d.webhookEndpoints[delivery.EndpointID]
+		if match("webhook_deliveries", mentions(delivery.Payload)) || (erase && !endpointLeft) {
+			delete(d.webhookDeliveries, id)
+			delete(d.deliveryKeys, memoryDeliveryKey{EndpointID: delivery.EndpointID, EventID: delivery.EventID})
+		}
+	}
+	for id, export := range d.dataExports {
+		if match("data_exports", export.UserID == userID) {
+			delete(d.dataExports, id)
+		}
+	}
+	for id, e := range d.auditEvents {
+		if match("audit_events", is(e.UserID) || is(e.ActorUserID) || mentions(e.EventData)) {
+			delete(d.auditEvents, id)
+		}
+	}
+	for id, e := range d.outbox {
+		if match("outbox_events", mentions(e.Payload)) {
+			delete(d.outbox, id)
+		}
+	}
+	for id, b := range d.backups {
+		if match("backups_registry", b.CreatedByUserID == userID) {
+			delete(d.backups, id)
+		}
+	}
+	return counts
+}
+
+func hasKey[K comparable, V any](m map[K]V, key K) bool {
+	_, ok := m[key]
+	return ok
+}
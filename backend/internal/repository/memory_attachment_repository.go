@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MemoryAttachmentRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryAttachmentRepository(db *MemoryDB) *MemoryAttachmentRepository {
+	return &MemoryAttachmentRepository{db: db}
+}
+
+func (r *MemoryAttachmentRepository) CreateAttachment(ctx context.Context, a domain.VaultAttachment) error {
+	defer r.db.lock(ctx)()
+	if _, ok := r.db.data.items[a.ItemID]; !ok {
+		return domain.ErrNotFound
+	}
+	a.UpdatedAt = a.CreatedAt
+	r.db.data.attachments[a.ID] = a
+	return nil
+}
+
+func (r *MemoryAttachmentRepository) ListAttachments(ctx context.Context, itemID, ownerUserID string) ([]domain.VaultAttachment, error) {
+	defer r.db.lock(ctx)()
+	attachments := make([]domain.VaultAttachment, 0)
+	for _, a := range r.db.data.attachments {
+		if a.ItemID == itemID && r.db.data.items[a.ItemID].OwnerUserID == ownerUserID {
+			attachments = append(attachments, a)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool {
+		if !attachments[i].CreatedAt.Equal(attachments[j].CreatedAt) {
+			return attachments[i].CreatedAt.Before(attachments[j].CreatedAt)
+		}
+		return attachments[i].ID < attachments[j].ID
+	})
+	return attachments, nil
+}
+
+func (r *MemoryAttachmentRepository) ListAttachmentsByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultAttachment, error) {
+	defer r.db.lock(ctx)()
+	attachments := make([]domain.VaultAttachment, 0)
+	for _, a := range r.db.data.attachments {
+		item := r.db.data.items[a.ItemID]
+		if item.OwnerUserID == ownerUserID && item.DeletedAt == nil {
+			attachments = append(attachments, a)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool {
+		a, b := attachments[i], attachments[j]
+		if a.ItemID != b.ItemID {
+			return a.ItemID < b.ItemID
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+	return attachments, nil
+}
+
+func (r *MemoryAttachmentRepository) GetAttachment(ctx context.Context, itemID, ownerUserID, attachmentID string) (domain.VaultAttachment, error) {
+	defer r.db.lock(ctx)()
+	a, ok := r.db.data.attachments[attachmentID]
+	if !ok || a.ItemID != itemID || r.db.data.items[a.ItemID].OwnerUserID != ownerUserID {
+		return domain.VaultAttachment{}, domain.ErrNotFound
+	}
+	return a, nil
+}
+
+func (r *MemoryAttachmentRepository) DeleteAttachment(ctx context.Context, itemID, ownerUserID, attachmentID string) error {
+	defer r.db.lock(ctx)()
+	a, ok := r.db.data.attachments[attachmentID]
+	if !ok || a.ItemID != itemID || r.db.data.items[a.ItemID].OwnerUserID != ownerUserID {
+		return domain.ErrNotFound
+	}
+	delete(r.db.data.attachments, attachmentID)
+	return nil
+}
+
+func (r *MemoryAttachmentRepository) DeleteTrashedAttachments(ctx context.Context, deletedBefore time.Time) ([]string, error) {
+	defer r.db.lock(ctx)()
+	paths := make([]string, 0)
+	for id, a := range r.db.data.attachments {
+		item := r.db.data.items[a.ItemID]
+		if item.DeletedAt == nil || !item.DeletedAt.Before(deletedBefore) {
+			continue
+		}
+		paths = append(paths, a.StoragePath)
+		delete(r.db.data.attachments, id)
+	}
+	return paths, nil
+}
@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"pmv2/backend/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type MemoryAuditRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryAuditRepository(db *MemoryDB) *MemoryAuditRepository {
+	return &MemoryAuditRepository{db: db}
+}
+
+func (r *MemoryAuditRepository) CreateEvent(ctx context.Context, event domain.AuditEvent) error {
+	defer r.db.lock(ctx)()
+	if _, exists := r.db.data.auditEvents[event.ID]; exists {
+		return nil
+	}
+	event.CreatedAt = event.CreatedAt.UTC()
+	event.RecordedAt = memoryNow()
+	r.db.data.auditEvents[event.ID] = event
+	return nil
+}
+
+func (r *MemoryAuditRepository) ListEvents(ctx context.Context, limit int, offset int, filter domain.AuditFilter) ([]domain.AuditEvent, int, error) {
+	defer r.db.lock(ctx)()
+
+	events := r.filterEvents(filter)
+	total := len(events)
+	if offset > len(events) {
+		offset = len(events)
+	}
+	events = events[offset:]
+	if limit >= 0 && limit < len(events) {
+		events = events[:limit]
+	}
+	return events, total, nil
+}
+
+// StreamEvents copies the matching events before calling fn, so fn may use
+// the repositories itself.
+func (r *MemoryAuditRepository) StreamEvents(ctx context.Context, filter domain.AuditFilter, fn func(domain.AuditEvent) error) error {
+	unlock := r.db.lock(ctx)
+	events := r.filterEvents(filter)
+	unlock()
+
+	for _, e := range events {
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterEvents applies filter the way auditFilterClause does and returns the
+// matches newest first.
+func (r *MemoryAuditRepository) filterEvents(filter domain.AuditFilter) []domain.AuditEvent {
+	search := strings.ToLower(filter.Search)
+	events := make([]domain.AuditEvent, 0)
+	for _, e := range r.db.data.auditEvents {
+		switch {
+		case filter.UserID != nil && (e.UserID == nil || *e.UserID != *filter.UserID),
+			filter.Category != "" && !strings.HasPrefix(string(e.EventType), filter.Category),
+			len(filter.EventTypes) > 0 && !slices.Contains(filter.EventTypes, e.EventType),
+			filter.IPAddress != "" && e.IPAddress != filter.IPAddress,
+			search != "" && !strings.Contains(strings.ToLower(string(e.EventType)), search) &&
+				!strings.Contains(strings.ToLower(string(e.EventData)), search),
+			filter.StartDate != nil && e.CreatedAt.Before(*filter.StartDate),
+			filter.EndDate != nil && e.CreatedAt.After(*filter.EndDate):
+			continue
+		}
+		events = append(events, e)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].CreatedAt.Equal(events[j].CreatedAt) {
+			return events[i].CreatedAt.After(events[j].CreatedAt)
+		}
+		return events[i].ID.String() < events[j].ID.String()
+	})
+	return events
+}
+
+func (r *MemoryAuditRepository) ListEventsAfter(ctx context.Context, cursor domain.AuditCursor, settle time.Duration, limit int) ([]domain.AuditEvent, error) {
+	defer r.db.lock(ctx)()
+
+	settled := time.Now().Add(-settle)
+	events := make([]domain.AuditEvent, 0)
+	for _, e := range r.db.data.auditEvents {
+		if memoryAfterCursor(e, cursor) && e.RecordedAt.Before(settled) {
+			events = append(events, e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return memoryAfterCursor(events[j], domain.AuditCursor{RecordedAt: events[i].RecordedAt, EventID: events[i].ID})
+	})
+	if limit < len(events) {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// memoryAfterCursor is (recorded_at, id) > (cursor.RecordedAt, cursor.EventID).
+func memoryAfterCursor(e domain.AuditEvent, cursor domain.AuditCursor) bool {
+	if !e.RecordedAt.Equal(cursor.RecordedAt) {
+		return e.RecordedAt.After(cursor.RecordedAt)
+	}
+	return e.ID.String() > cursor.EventID.String()
+}
+
+// AdvanceExportCursor runs fn without the table lock, since fn reads events
+// through ListEventsAfter; the named lock keeps exporters apart.
+func (r *MemoryAuditRepository) AdvanceExportCursor(ctx context.Context, exporter string, fn func(domain.AuditCursor) (domain.AuditCursor, error)) (locked bool, err error) {
+	release, acquired := r.db.tryLock("audit-export:" + exporter)
+	if !acquired {
+		return false, nil
+	}
+	defer release()
+
+	unlock := r.db.lock(ctx)
+	cursor, ok := r.db.data.exportCursors[exporter]
+	if !ok {
+		cursor = domain.AuditCursor{RecordedAt: time.Unix(0, 0).UTC(), EventID: uuid.Nil}
+	}
+	unlock()
+
+	next, err := fn(cursor)
+	if err != nil {
+		return true, err
+	}
+
+	defer r.db.lock(ctx)()
+	r.db.data.exportCursors[exporter] = next
+	return true, nil
+}
+
+func (r *MemoryAuditRepository) DeleteUserLogs(ctx context.Context, userID uuid.UUID) error {
+	defer r.db.lock(ctx)()
+	for id, e := range r.db.data.auditEvents {
+		if e.UserID != nil && *e.UserID == userID {
+			delete(r.db.data.auditEvents, id)
+		}
+	}
+	return nil
+}
+
+func (r *MemoryAuditRepository) DeleteEventsBefore(ctx context.Context, before time.Time, category string, limit int) (int64, error) {
+	defer r.db.lock(ctx)()
+
+	var deleted int64
+	for id, e := range r.db.data.auditEvents {
+		if deleted >= int64(limit) {
+			break
+		}
+		if e.RecordedAt.Before(before) && strings.HasPrefix(string(e.EventType), category) {
+			delete(r.db.data.auditEvents, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// EnsurePartitions does nothing; audit events are only partitioned on
+// Postgres.
+func (r *MemoryAuditRepository) EnsurePartitions(ctx context.Context, from time.Time, months int) ([]string, error) {
+	return nil, nil
+}
+
+func (r *MemoryAuditRepository) DropPartitionsBefore(ctx context.Context, before time.Time) ([]string, error) {
+	return nil, nil
+}
@@ -0,0 +1,579 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MemoryAuthRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryAuthRepository(db *MemoryDB) *MemoryAuthRepository {
+	return &MemoryAuthRepository{db: db}
+}
+
+func (r *MemoryAuthRepository) CreateUserWithCredentials(ctx context.Context, input domain.CreateUserInput) error {
+	defer r.db.lock(ctx)()
+	data := r.db.data
+
+	if _, taken := data.userByEmail(input.Email); taken {
+		return domain.ErrEmailTaken
+	}
+	if input.CanonicalEmail != "" {
+		for _, user := range data.users {
+			if user.CanonicalEmail == input.CanonicalEmail {
+				return domain.ErrEmailTaken
+			}
+		}
+	}
+	data.users[input.UserID] = memoryUser{
+		ID:             input.UserID,
+		Email:          input.Email,
+		CanonicalEmail: input.CanonicalEmail,
+		Name:           input.Name,
+		Role:           domain.UserRoleUser,
+		CreatedAt:      memoryNow(),
+	}
+	data.credentials[input.UserID] = memoryCredential{
+		Algo:         input.Algo,
+		Params:       input.ParamsJSON,
+		Salt:         input.Salt,
+		PasswordHash: input.PasswordHash,
+	}
+	return nil
+}
+
+func (r *MemoryAuthRepository) GetUserAuthByEmail(ctx context.Context, email string) (domain.UserAuthRecord, error) {
+	defer r.db.lock(ctx)()
+	data := r.db.data
+
+	user, ok := data.userByEmail(email)
+	if !ok {
+		return domain.UserAuthRecord{}, domain.ErrNotFound
+	}
+	return data.userAuth(user)
+}
+
+func (r *MemoryAuthRepository) GetUserAuthByID(ctx context.Context, userID string) (domain.UserAuthRecord, error) {
+	defer r.db.lock(ctx)()
+	data := r.db.data
+
+	user, ok := data.users[userID]
+	if !ok {
+		return domain.UserAuthRecord{}, domain.ErrNotFound
+	}
+	return data.userAuth(user)
+}
+
+func (d memoryData) userAuth(user memoryUser) (domain.UserAuthRecord, error) {
+	cred, ok := d.credentials[user.ID]
+	if !ok {
+		return domain.UserAuthRecord{}, domain.ErrNotFound
+	}
+	return domain.UserAuthRecord{
+		UserID:                 user.ID,
+		Email:                  user.Email,
+		Name:                   user.Name,
+		Salt:                   cred.Salt,
+		PasswordHash:           cred.PasswordHash,
+		RawParams:              cred.Params,
+		TOTPEnabled:            cred.TOTPEnabled,
+		TOTPSecretEnc:          cred.TOTPSecretEnc,
+		TOTPFailedAttempts:     cred.TOTPFailedAttempts,
+		TOTPWindowStart:        cred.TOTPWindowStart,
+		TOTPLockedUntil:        cred.TOTPLockedUntil,
+		CredentialsCompromised: cred.CompromisedAt != nil,
+	}, nil
+}
+
+func (r *MemoryAuthRepository) CreateSession(ctx context.Context, input domain.CreateSessionInput) error {
+	defer r.db.lock(ctx)()
+	r.db.data.sessions[input.SessionID] = memorySession{
+		ID:         input.SessionID,
+		UserID:     input.UserID,
+		TokenHash:  string(input.TokenHash),
+		DeviceName: input.DeviceName,
+		IPAddress:  input.IPAddr,
+		UserAgent:  input.UserAgent,
+		DeviceID:   input.DeviceID,
+		ExpiresAt:  input.ExpiresAt.UTC(),
+		CreatedAt:  memoryNow(),
+	}
+	return nil
+}
+
+func (r *MemoryAuthRepository) GetActiveSessionByTokenHash(ctx context.Context, tokenHash []byte) (domain.Session, error) {
+	defer r.db.lock(ctx)()
+	data := r.db.data
+
+	now := memoryNow()
+	for _, s := range data.sessions {
+		if s.TokenHash != string(tokenHash) || !s.active(now) {
+			continue
+		}
+		user, ok := data.users[s.UserID]
+		if !ok {
+			break
+		}
+		return domain.Session{
+			ID:                      s.ID,
+			UserID:                  s.UserID,
+			Email:                   user.Email,
+			Name:                    user.Name,
+			Role:                    user.Role,
+			TOTPEnabled:             data.credentials[s.UserID].TOTPEnabled,
+			ExpiresAt:               s.ExpiresAt,
+			StepUpRequired:          s.StepUp,
+			DeviceID:                s.DeviceID,
+			IPAddr:                  s.IPAddress,
+			UserAgent:               s.UserAgent,
+			Binding:                 data.sessionBindings[s.UserID],
+			DPoPThumbprint:          s.DPoPThumbprint,
+			CredentialResetRequired: data.credentials[s.UserID].CompromisedAt != nil,
+			LastUsedAt:              s.LastUsedAt,
+		}, nil
+	}
+	return domain.Session{}, domain.ErrNotFound
+}
+
+func (r *MemoryAuthRepository) RevokeSessionByTokenHash(ctx context.Context, tokenHash []byte) (bool, error) {
+	defer r.db.lock(ctx)()
+
+	now := memoryNow()
+	revoked := false
+	for id, s := range r.db.data.sessions {
+		if s.TokenHash == string(tokenHash) && s.RevokedAt == nil {
+			s.RevokedAt = &now
+			r.db.data.sessions[id] = s
+			revoked = true
+		}
+	}
+	return revoked, nil
+}
+
+func (r *MemoryAuthRepository) RevokeAllUserSessions(ctx context.Context, userID string) (int64, error) {
+	defer r.db.lock(ctx)()
+
+	now := memoryNow()
+	var revoked int64
+	for id, s := range r.db.data.sessions {
+		if s.UserID == userID && s.RevokedAt == nil {
+			s.RevokedAt = &now
+			r.db.data.sessions[id] = s
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (r *MemoryAuthRepository) ListUserSessions(ctx context.Context, userID string) ([]domain.UserSession, error) {
+	defer r.db.lock(ctx)()
+
+	now := memoryNow()
+	sessions := make([]domain.UserSession, 0)
+	for _, s := range r.db.data.sessions {
+		if s.UserID == userID && s.active(now) {
+			sessions = append(sessions, domain.UserSession{
+				ID:         s.ID,
+				DeviceName: s.DeviceName,
+				IPAddress:  s.IPAddress,
+				UserAgent:  s.UserAgent,
+				CreatedAt:  s.CreatedAt,
+				LastUsedAt: s.LastUsedAt,
+				ExpiresAt:  s.ExpiresAt,
+			})
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].CreatedAt.Equal(sessions[j].CreatedAt) {
+			return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+		}
+		return sessions[i].ID < sessions[j].ID
+	})
+	return sessions, nil
+}
+
+func (r *MemoryAuthRepository) RevokeUserSession(ctx context.Context, userID, sessionID string) (bool, error) {
+	defer r.db.lock(ctx)()
+
+	s, ok := r.db.data.sessions[sessionID]
+	if !ok || s.UserID != userID || s.RevokedAt != nil {
+		return false, nil
+	}
+	now := memoryNow()
+	s.RevokedAt = &now
+	r.db.data.sessions[sessionID] = s
+	return true, nil
+}
+
+func (r *MemoryAuthRepository) RevokeOtherUserSessions(ctx context.Context, userID, keepSessionID string) (int64, error) {
+	defer r.db.lock(ctx)()
+
+	now := memoryNow()
+	var revoked int64
+	for id, s := range r.db.data.sessions {
+		if s.UserID == userID && id != keepSessionID && s.RevokedAt == nil {
+			s.RevokedAt = &now
+			r.db.data.sessions[id] = s
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (r *MemoryAuthRepository) TouchSession(ctx context.Context, sessionID string, usedAt time.Time) error {
+	defer r.db.lock(ctx)()
+
+	if s, ok := r.db.data.sessions[sessionID]; ok {
+		usedAt = usedAt.UTC()
+		s.LastUsedAt = &usedAt
+		r.db.data.sessions[sessionID] = s
+	}
+	return nil
+}
+
+func (r *MemoryAuthRepository) SetSessionStepUp(ctx context.Context, sessionID string, required bool) error {
+	defer r.db.lock(ctx)()
+
+	if s, ok := r.db.data.sessions[sessionID]; ok {
+		s.StepUp = required
+		r.db.data.sessions[sessionID] = s
+	}
+	return nil
+}
+
+func (r *MemoryAuthRepository) RebindSession(ctx context.Context, sessionID string, ipAddr string, userAgent string) error {
+	defer r.db.lock(ctx)()
+
+	if s, ok := r.db.data.sessions[sessionID]; ok {
+		s.IPAddress = ipAddr
+		s.UserAgent = userAgent
+		r.db.data.sessions[sessionID] = s
+	}
+	return nil
+}
+
+func (r *MemoryAuthRepository) GetSessionBinding(ctx context.Context, userID string) (domain.SessionBinding, error) {
+	defer r.db.lock(ctx)()
+
+	binding, ok := r.db.data.sessionBindings[userID]
+	if !ok {
+		return domain.SessionBinding{}, domain.ErrNotFound
+	}
+	return binding, nil
+}
+
+func (r *MemoryAuthRepository) SetSessionBinding(ctx context.Context, userID string, binding domain.SessionBinding) error {
+	defer r.db.lock(ctx)()
+
+	r.db.data.sessionBindings[userID] = binding
+	return nil
+}
+
+func (r *MemoryAuthRepository) SetSessionDPoPKey(ctx context.Context, sessionID string, thumbprint string) (bool, error) {
+	defer r.db.lock(ctx)()
+
+	s, ok := r.db.data.sessions[sessionID]
+	if !ok || s.DPoPThumbprint != "" || s.RevokedAt != nil {
+		return false, nil
+	}
+	s.DPoPThumbprint = thumbprint
+	r.db.data.sessions[sessionID] = s
+	return true, nil
+}
+
+func (r *MemoryAuthRepository) RevokeSessionByID(ctx context.Context, sessionID string) (bool, error) {
+	defer r.db.lock(ctx)()
+
+	s, ok := r.db.data.sessions[sessionID]
+	if !ok || s.RevokedAt != nil {
+		return false, nil
+	}
+	now := memoryNow()
+	s.RevokedAt = &now
+	r.db.data.sessions[sessionID] = s
+	return true, nil
+}
+
+func (r *MemoryAuthRepository) SessionActive(ctx context.Context, sessionID string) (bool, error) {
+	defer r.db.lock(ctx)()
+	s, ok := r.db.data.sessions[sessionID]
+	return ok && s.active(memoryNow()), nil
+}
+
+func (r *MemoryAuthRepository) RotateSessionToken(ctx context.Context, sessionID string, oldHash, newHash []byte, expiresAt time.Time) (bool, error) {
+	defer r.db.lock(ctx)()
+
+	now := memoryNow()
+	s, ok := r.db.data.sessions[sessionID]
+	if !ok || s.TokenHash != string(oldHash) || !s.active(now) {
+		return false, nil
+	}
+	retired := make(map[string]time.Time, len(s.Retired)+1)
+	for hash, at := range s.Retired {
+		retired[hash] = at
+	}
+	retired[s.TokenHash] = now
+	s.Retired = retired
+	s.TokenHash = string(newHash)
+	s.ExpiresAt = expiresAt.UTC()
+	r.db.data.sessions[sessionID] = s
+	return true, nil
+}
+
+func (r *MemoryAuthRepository) GetRetiredSessionToken(ctx context.Context, tokenHash []byte) (domain.RetiredSessionToken, error) {
+	defer r.db.lock(ctx)()
+
+	for _, s := range r.db.data.sessions {
+		if at, ok := s.Retired[string(tokenHash)]; ok {
+			return domain.RetiredSessionToken{SessionID: s.ID, UserID: s.UserID, RetiredAt: at}, nil
+		}
+	}
+	return domain.RetiredSessionToken{}, domain.ErrNotFound
+}
+
+// updateCredential applies fn to the credentials of userID and reports
+// whether the user exists.
+func (r *MemoryAuthRepository) updateCredential(userID string, fn func(*memoryCredential)) bool {
+	cred, ok := r.db.data.credentials[userID]
+	if !ok {
+		return false
+	}
+	fn(&cred)
+	r.db.data.credentials[userID] = cred
+	return true
+}
+
+func (r *MemoryAuthRepository) SetTOTPSecret(ctx context.Context, userID string, secretEnc []byte) (bool, error) {
+	defer r.db.lock(ctx)()
+	return r.updateCredential(userID, func(c *memoryCredential) {
+		c.TOTPSecretEnc = secretEnc
+		c.TOTPEnabled = false
+		c.TOTPFailedAttempts = 0
+		c.TOTPWindowStart = nil
+		c.TOTPLockedUntil = nil
+	}), nil
+}
+
+func (r *MemoryAuthRepository) EnableTOTP(ctx context.Context, userID string) error {
+	defer r.db.lock(ctx)()
+	if !r.updateCredential(userID, func(c *memoryCredential) {
+		c.TOTPEnabled = true
+		c.TOTPFailedAttempts = 0
+		c.TOTPWindowStart = nil
+		c.TOTPLockedUntil = nil
+	}) {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *MemoryAuthRepository) DisableTOTP(ctx context.Context, userID string) error {
+	defer r.db.lock(ctx)()
+	if !r.updateCredential(userID, func(c *memoryCredential) {
+		c.TOTPEnabled = false
+		c.TOTPSecretEnc = nil
+		c.TOTPFailedAttempts = 0
+		c.TOTPWindowStart = nil
+		c.TOTPLockedUntil = nil
+	}) {
+		return domain.ErrNotFound
+	}
+	r.deleteRecoveryCodes(userID)
+	return nil
+}
+
+func (r *MemoryAuthRepository) GetTOTPState(ctx context.Context, userID string) (domain.TOTPState, error) {
+	defer r.db.lock(ctx)()
+	cred, ok := r.db.data.credentials[userID]
+	if !ok {
+		return domain.TOTPState{}, domain.ErrNotFound
+	}
+	return domain.TOTPState{
+		SecretEnc:      cred.TOTPSecretEnc,
+		Enabled:        cred.TOTPEnabled,
+		FailedAttempts: cred.TOTPFailedAttempts,
+		WindowStart:    cred.TOTPWindowStart,
+		LockedUntil:    cred.TOTPLockedUntil,
+	}, nil
+}
+
+func (r *MemoryAuthRepository) RecordTOTPFailure(ctx context.Context, userID string, now time.Time, maxAttempts int, window time.Duration, lockDuration time.Duration) (*time.Time, error) {
+	defer r.db.lock(ctx)()
+	cred, ok := r.db.data.credentials[userID]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+
+	nowUTC := now.UTC()
+	if cred.TOTPLockedUntil != nil && cred.TOTPLockedUntil.After(nowUTC) {
+		lock := *cred.TOTPLockedUntil
+		return &lock, nil
+	}
+
+	if cred.TOTPWindowStart == nil || nowUTC.Sub(*cred.TOTPWindowStart) > window {
+		cred.TOTPFailedAttempts = 0
+		cred.TOTPWindowStart = &nowUTC
+	}
+	cred.TOTPFailedAttempts++
+	cred.TOTPLockedUntil = nil
+	if cred.TOTPFailedAttempts >= maxAttempts {
+		lock := nowUTC.Add(lockDuration)
+		cred.TOTPLockedUntil = &lock
+		cred.TOTPWindowStart = nil
+		cred.TOTPFailedAttempts = 0
+	}
+
+	r.db.data.credentials[userID] = cred
+	return cred.TOTPLockedUntil, nil
+}
+
+func (r *MemoryAuthRepository) ResetTOTPFailures(ctx context.Context, userID string) error {
+	defer r.db.lock(ctx)()
+	if !r.updateCredential(userID, func(c *memoryCredential) {
+		c.TOTPFailedAttempts = 0
+		c.TOTPWindowStart = nil
+		c.TOTPLockedUntil = nil
+	}) {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *MemoryAuthRepository) deleteRecoveryCodes(userID string) {
+	for code := range r.db.data.recoveryCodes {
+		if code.UserID == userID {
+			delete(r.db.data.recoveryCodes, code)
+		}
+	}
+}
+
+func (r *MemoryAuthRepository) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes [][]byte) error {
+	defer r.db.lock(ctx)()
+	r.deleteRecoveryCodes(userID)
+	for _, hash := range codeHashes {
+		r.db.data.recoveryCodes[memoryRecoveryCode{UserID: userID, CodeHash: string(hash)}] = false
+	}
+	return nil
+}
+
+func (r *MemoryAuthRepository) ConsumeRecoveryCode(ctx context.Context, userID string, codeHash []byte) (bool, error) {
+	defer r.db.lock(ctx)()
+	code := memoryRecoveryCode{UserID: userID, CodeHash: string(codeHash)}
+	used, ok := r.db.data.recoveryCodes[code]
+	if !ok || used {
+		return false, nil
+	}
+	r.db.data.recoveryCodes[code] = true
+	return true, nil
+}
+
+func (r *MemoryAuthRepository) DeleteExpiredSessions(ctx context.Context, revokedBefore time.Time, afterID string, limit int) (domain.SessionPurgeBatch, error) {
+	defer r.db.lock(ctx)()
+
+	now := memoryNow()
+	cursor := sessionCursor(afterID)
+	var ids []string
+	for id, s := range r.db.data.sessions {
+		if id > cursor && (s.ExpiresAt.Before(now) || (s.RevokedAt != nil && s.RevokedAt.Before(revokedBefore))) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	for _, id := range ids {
+		delete(r.db.data.sessions, id)
+	}
+	return sessionPurgeBatch(ids), nil
+}
+
+func (r *MemoryAuthRepository) SetupRecovery(ctx context.Context, input domain.SetupRecoveryInput) error {
+	defer r.db.lock(ctx)()
+	record := r.db.data.recovery[input.UserID]
+	record.UserID = input.UserID
+	record.RecoveryKeyHash = input.RecoveryKeyHash
+	record.RecoveryEnabled = true
+	record.WrappedKEK = input.WrappedKEK
+	record.WrapNonce = input.WrapNonce
+	record.KEKSalt = input.KEKSalt
+	r.db.data.recovery[input.UserID] = record
+	return nil
+}
+
+func (r *MemoryAuthRepository) GetRecoveryRecord(ctx context.Context, userID string) (domain.RecoveryRecord, error) {
+	defer r.db.lock(ctx)()
+	record, ok := r.db.data.recovery[userID]
+	if !ok {
+		return domain.RecoveryRecord{}, domain.ErrNotFound
+	}
+	return record, nil
+}
+
+func (r *MemoryAuthRepository) UpdateLastRecoveryAt(ctx context.Context, userID string) error {
+	defer r.db.lock(ctx)()
+	record, ok := r.db.data.recovery[userID]
+	if !ok {
+		return nil
+	}
+	now := memoryNow()
+	record.LastRecoveryAt = &now
+	r.db.data.recovery[userID] = record
+	return nil
+}
+
+func (r *MemoryAuthRepository) UpdatePassword(ctx context.Context, input domain.ResetPasswordInput) error {
+	defer r.db.lock(ctx)()
+	if !r.updateCredential(input.UserID, func(c *memoryCredential) {
+		c.Algo = input.Algo
+		c.Params = input.ParamsJSON
+		c.Salt = input.Salt
+		c.PasswordHash = input.PasswordHash
+		c.CompromisedAt = nil
+	}) {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *MemoryAuthRepository) SetCredentialsCompromised(ctx context.Context, userID string) error {
+	defer r.db.lock(ctx)()
+	now := memoryNow()
+	if !r.updateCredential(userID, func(c *memoryCredential) {
+		if c.CompromisedAt == nil {
+			c.CompromisedAt = &now
+		}
+	}) {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *MemoryAuthRepository) UpdateDisplayName(ctx context.Context, userID string, name string) error {
+	defer r.db.lock(ctx)()
+	user, ok := r.db.data.users[userID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	user.Name = name
+	r.db.data.users[userID] = user
+	return nil
+}
+
+// SetUserRole changes the role of the account registered under email.
+func (r *MemoryAuthRepository) SetUserRole(ctx context.Context, email string, role domain.UserRole) error {
+	defer r.db.lock(ctx)()
+	user, ok := r.db.data.userByEmail(email)
+	if !ok {
+		return domain.ErrNotFound
+	}
+	user.Role = role
+	r.db.data.users[user.ID] = user
+	return nil
+}
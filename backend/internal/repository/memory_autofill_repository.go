@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MemoryAutofillRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryAutofillRepository(db *MemoryDB) *MemoryAutofillRepository {
+	return &MemoryAutofillRepository{db: db}
+}
+
+func (r *MemoryAutofillRepository) SetURIHMACs(ctx context.Context, ownerUserID, itemID string, hmacs [][]byte) error {
+	defer r.db.lock(ctx)()
+	for key := range r.db.data.uriHMACs {
+		if key.ItemID == itemID {
+			delete(r.db.data.uriHMACs, key)
+		}
+	}
+	for _, hmac := range hmacs {
+		r.db.data.uriHMACs[memoryURIHMAC{ItemID: itemID, HMAC: string(hmac)}] = true
+	}
+	return nil
+}
+
+func (r *MemoryAutofillRepository) ListURIHMACs(ctx context.Context, ownerUserID string) (map[string][][]byte, error) {
+	defer r.db.lock(ctx)()
+	hmacs := make(map[string][][]byte)
+	for key := range r.db.data.uriHMACs {
+		if r.db.data.items[key.ItemID].OwnerUserID == ownerUserID {
+			hmacs[key.ItemID] = append(hmacs[key.ItemID], []byte(key.HMAC))
+		}
+	}
+	return hmacs, nil
+}
+
+func (r *MemoryAutofillRepository) MatchURIHMACs(ctx context.Context, ownerUserID string, hmacs [][]byte) ([]string, error) {
+	defer r.db.lock(ctx)()
+	wanted := make(map[string]bool, len(hmacs))
+	for _, hmac := range hmacs {
+		wanted[string(hmac)] = true
+	}
+	seen := make(map[string]bool)
+	ids := make([]string, 0)
+	for key := range r.db.data.uriHMACs {
+		item, ok := r.db.data.items[key.ItemID]
+		if !ok || item.OwnerUserID != ownerUserID || item.DeletedAt != nil || !wanted[key.HMAC] || seen[key.ItemID] {
+			continue
+		}
+		seen[key.ItemID] = true
+		ids = append(ids, key.ItemID)
+	}
+	return ids, nil
+}
+
+func (r *MemoryAutofillRepository) RecordItemUse(ctx context.Context, ownerUserID, itemID string, usedAt time.Time) (domain.ItemUsage, error) {
+	defer r.db.lock(ctx)()
+	item, ok := r.db.data.items[itemID]
+	if !ok || item.OwnerUserID != ownerUserID || item.DeletedAt != nil {
+		return domain.ItemUsage{}, domain.ErrNotFound
+	}
+	usedAt = usedAt.UTC()
+	usage := r.db.data.itemUsage[itemID]
+	usage.ItemID = itemID
+	usage.UseCount++
+	usage.LastUsedAt = &usedAt
+	r.db.data.itemUsage[itemID] = usage
+	return usage, nil
+}
+
+func (r *MemoryAutofillRepository) ListItemUsage(ctx context.Context, ownerUserID string) (map[string]domain.ItemUsage, error) {
+	defer r.db.lock(ctx)()
+	usage := make(map[string]domain.ItemUsage)
+	for id, u := range r.db.data.itemUsage {
+		if r.db.data.items[id].OwnerUserID == ownerUserID {
+			usage[id] = u
+		}
+	}
+	return usage, nil
+}
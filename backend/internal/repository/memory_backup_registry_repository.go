@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MemoryBackupRegistryRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryBackupRegistryRepository(db *MemoryDB) *MemoryBackupRegistryRepository {
+	return &MemoryBackupRegistryRepository{db: db}
+}
+
+func (r *MemoryBackupRegistryRepository) RegisterBackup(ctx context.Context, record domain.BackupRecord) error {
+	defer r.db.lock(ctx)()
+	record.CreatedAt = memoryNow()
+	r.db.data.backups[record.ID] = record
+	return nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MemoryDataExportRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryDataExportRepository(db *MemoryDB) *MemoryDataExportRepository {
+	return &MemoryDataExportRepository{db: db}
+}
+
+func (r *MemoryDataExportRepository) CreateDataExport(ctx context.Context, export domain.DataExport) (domain.DataExport, error) {
+	defer r.db.lock(ctx)()
+	export.Status = domain.DataExportPending
+	export.CreatedAt = time.Now().UTC()
+	r.db.data.dataExports[export.ID] = memoryDataExport{DataExport: export}
+	return export, nil
+}
+
+func (r *MemoryDataExportRepository) GetDataExport(ctx context.Context, userID, exportID string) (domain.DataExport, error) {
+	defer r.db.lock(ctx)()
+	export, ok := r.db.data.dataExports[exportID]
+	if !ok || export.UserID != userID {
+		return domain.DataExport{}, domain.ErrNotFound
+	}
+	return export.DataExport, nil
+}
+
+func (r *MemoryDataExportRepository) FindPendingDataExport(ctx context.Context, userID string) (domain.DataExport, error) {
+	defer r.db.lock(ctx)()
+	var found *domain.DataExport
+	for _, export := range r.db.data.dataExports {
+		if export.UserID != userID || export.Status != domain.DataExportPending {
+			continue
+		}
+		if found == nil || export.CreatedAt.After(found.CreatedAt) {
+			found = &export.DataExport
+		}
+	}
+	if found == nil {
+		return domain.DataExport{}, domain.ErrNotFound
+	}
+	return *found, nil
+}
+
+func (r *MemoryDataExportRepository) GetDataExportArchive(ctx context.Context, userID, exportID string) ([]byte, error) {
+	defer r.db.lock(ctx)()
+	export, ok := r.db.data.dataExports[exportID]
+	if !ok || export.UserID != userID || export.Status != domain.DataExportReady {
+		return nil, domain.ErrNotFound
+	}
+	return export.Archive, nil
+}
+
+func (r *MemoryDataExportRepository) CompleteDataExport(ctx context.Context, exportID string, archive []byte, completedAt, expiresAt time.Time) error {
+	defer r.db.lock(ctx)()
+	export, ok := r.db.data.dataExports[exportID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	completed, expires := completedAt.UTC(), expiresAt.UTC()
+	export.Status = domain.DataExportReady
+	export.Archive = archive
+	export.SizeBytes = int64(len(archive))
+	export.CompletedAt = &completed
+	export.ExpiresAt = &expires
+	r.db.data.dataExports[exportID] = export
+	return nil
+}
+
+func (r *MemoryDataExportRepository) DeleteExpiredDataExports(ctx context.Context, before time.Time) (int64, error) {
+	defer r.db.lock(ctx)()
+	var deleted int64
+	for id, export := range r.db.data.dataExports {
+		if export.Status == domain.DataExportReady && export.ExpiresAt.Before(before) {
+			delete(r.db.data.dataExports, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MemoryDeviceRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryDeviceRepository(db *MemoryDB) *MemoryDeviceRepository {
+	return &MemoryDeviceRepository{db: db}
+}
+
+func (r *MemoryDeviceRepository) TouchDevice(ctx context.Context, userID string, keyHash []byte, fingerprinted bool, label string) (domain.Device, bool, error) {
+	defer r.db.lock(ctx)()
+	now := memoryNow()
+	for id, d := range r.db.data.devices {
+		if d.UserID == userID && d.KeyHash == string(keyHash) {
+			d.Label = label
+			d.LastSeenAt = now
+			r.db.data.devices[id] = d
+			return d.Device, false, nil
+		}
+	}
+	d := memoryDevice{
+		Device: domain.Device{
+			ID:            uuid.NewString(),
+			UserID:        userID,
+			Fingerprinted: fingerprinted,
+			Label:         label,
+			FirstSeenAt:   now,
+			LastSeenAt:    now,
+		},
+		KeyHash: string(keyHash),
+	}
+	r.db.data.devices[d.ID] = d
+	return d.Device, true, nil
+}
+
+func (r *MemoryDeviceRepository) ListDevices(ctx context.Context, userID string) ([]domain.Device, error) {
+	defer r.db.lock(ctx)()
+	devices := make([]domain.Device, 0)
+	for _, d := range r.db.data.devices {
+		if d.UserID == userID {
+			devices = append(devices, d.Device)
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].LastSeenAt.After(devices[j].LastSeenAt) })
+	return devices, nil
+}
+
+func (r *MemoryDeviceRepository) DeleteDevice(ctx context.Context, userID, deviceID string) error {
+	defer r.db.lock(ctx)()
+	d, ok := r.db.data.devices[deviceID]
+	if !ok || d.UserID != userID {
+		return domain.ErrNotFound
+	}
+	delete(r.db.data.devices, deviceID)
+	for id, s := range r.db.data.sessions {
+		if s.DeviceID == deviceID {
+			s.DeviceID = ""
+			r.db.data.sessions[id] = s
+		}
+	}
+	for id, t := range r.db.data.pushTokens {
+		if t.DeviceID == deviceID {
+			t.DeviceID = ""
+			r.db.data.pushTokens[id] = t
+		}
+	}
+	return nil
+}
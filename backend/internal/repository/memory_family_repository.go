@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"sort"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MemoryFamilyRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryFamilyRepository(db *MemoryDB) *MemoryFamilyRepository {
+	return &MemoryFamilyRepository{db: db}
+}
+
+// CreateRequest stores a single pending row with user_id = initiator and
+// friend_id = recipient, like the SQL repositories.
+func (r *MemoryFamilyRepository) CreateRequest(ctx context.Context, userID, friendID string) error {
+	defer r.db.lock(ctx)()
+
+	key := memoryFamilyKey{UserID: userID, FriendID: friendID}
+	if _, exists := r.db.data.family[key]; exists {
+		return domain.ErrFamilyRequestAlreadySent
+	}
+	now := memoryNow()
+	r.db.data.family[key] = domain.FamilyMembership{
+		UserID:      userID,
+		FriendID:    friendID,
+		Status:      "pending",
+		InitiatedBy: userID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	return nil
+}
+
+// AcceptRequest accepts the request friendID sent to userID.
+func (r *MemoryFamilyRepository) AcceptRequest(ctx context.Context, userID, friendID string) error {
+	defer r.db.lock(ctx)()
+
+	key := memoryFamilyKey{UserID: friendID, FriendID: userID}
+	m, ok := r.db.data.family[key]
+	if !ok || m.Status != "pending" {
+		return domain.ErrFamilyRequestNotFound
+	}
+	m.Status = "accepted"
+	m.UpdatedAt = memoryNow()
+	r.db.data.family[key] = m
+	return nil
+}
+
+// DeleteMembership removes the family relationship in either direction.
+func (r *MemoryFamilyRepository) DeleteMembership(ctx context.Context, userID, friendID string) error {
+	defer r.db.lock(ctx)()
+
+	deleted := false
+	for _, key := range []memoryFamilyKey{{UserID: userID, FriendID: friendID}, {UserID: friendID, FriendID: userID}} {
+		if _, ok := r.db.data.family[key]; ok {
+			delete(r.db.data.family, key)
+			deleted = true
+		}
+	}
+	if !deleted {
+		return domain.ErrFamilyRequestNotFound
+	}
+	return nil
+}
+
+// memberships lists the rows matching keep, newest first.
+func (r *MemoryFamilyRepository) memberships(keep func(domain.FamilyMembership) bool) []domain.FamilyMembership {
+	rows := make([]domain.FamilyMembership, 0)
+	for _, m := range r.db.data.family {
+		if keep(m) {
+			rows = append(rows, m)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if !rows[i].CreatedAt.Equal(rows[j].CreatedAt) {
+			return rows[i].CreatedAt.After(rows[j].CreatedAt)
+		}
+		return rows[i].UserID+rows[i].FriendID < rows[j].UserID+rows[j].FriendID
+	})
+	return rows
+}
+
+// ListMembers returns accepted family members for a user (from both directions).
+func (r *MemoryFamilyRepository) ListMembers(ctx context.Context, userID string) ([]domain.FamilyMember, error) {
+	defer r.db.lock(ctx)()
+
+	members := make([]domain.FamilyMember, 0)
+	for _, m := range r.memberships(func(m domain.FamilyMembership) bool {
+		return (m.UserID == userID || m.FriendID == userID) && m.Status == "accepted"
+	}) {
+		otherID := m.UserID
+		if otherID == userID {
+			otherID = m.FriendID
+		}
+		other, ok := r.db.data.users[otherID]
+		if !ok {
+			continue
+		}
+		members = append(members, domain.FamilyMember{
+			UserID:    other.ID,
+			Email:     other.Email,
+			Name:      other.Name,
+			Status:    m.Status,
+			CreatedAt: m.CreatedAt,
+		})
+	}
+	return members, nil
+}
+
+// ListPendingReceived lists pending requests where this user is the recipient (friend_id).
+func (r *MemoryFamilyRepository) ListPendingReceived(ctx context.Context, userID string) ([]domain.FamilyRequest, error) {
+	defer r.db.lock(ctx)()
+	return r.pendingRequests(func(m domain.FamilyMembership) (string, bool) {
+		return m.UserID, m.FriendID == userID
+	}), nil
+}
+
+// ListPendingSent lists pending requests sent BY this user.
+func (r *MemoryFamilyRepository) ListPendingSent(ctx context.Context, userID string) ([]domain.FamilyRequest, error) {
+	defer r.db.lock(ctx)()
+	return r.pendingRequests(func(m domain.FamilyMembership) (string, bool) {
+		return m.FriendID, m.UserID == userID
+	}), nil
+}
+
+// pendingRequests lists pending rows for which other reports true, with the
+// user it names as the other party.
+func (r *MemoryFamilyRepository) pendingRequests(other func(domain.FamilyMembership) (string, bool)) []domain.FamilyRequest {
+	reqs := make([]domain.FamilyRequest, 0)
+	for _, m := range r.memberships(func(m domain.FamilyMembership) bool {
+		_, ok := other(m)
+		return ok && m.Status == "pending"
+	}) {
+		otherID, _ := other(m)
+		user, ok := r.db.data.users[otherID]
+		if !ok {
+			continue
+		}
+		reqs = append(reqs, domain.FamilyRequest{
+			UserID:      user.ID,
+			Email:       user.Email,
+			Name:        user.Name,
+			InitiatedBy: m.InitiatedBy,
+			CreatedAt:   m.CreatedAt,
+		})
+	}
+	return reqs
+}
+
+// IsFamilyMember checks if two users have an accepted family relationship.
+func (r *MemoryFamilyRepository) IsFamilyMember(ctx context.Context, userID, friendID string) (bool, error) {
+	defer r.db.lock(ctx)()
+	m, ok := r.membership(userID, friendID)
+	return ok && m.Status == "accepted", nil
+}
+
+// GetMembership returns the record between two users (any direction).
+func (r *MemoryFamilyRepository) GetMembership(ctx context.Context, userID, friendID string) (domain.FamilyMembership, error) {
+	defer r.db.lock(ctx)()
+	m, ok := r.membership(userID, friendID)
+	if !ok {
+		return domain.FamilyMembership{}, domain.ErrFamilyRequestNotFound
+	}
+	return m, nil
+}
+
+func (r *MemoryFamilyRepository) membership(userID, friendID string) (domain.FamilyMembership, bool) {
+	if m, ok := r.db.data.family[memoryFamilyKey{UserID: userID, FriendID: friendID}]; ok {
+		return m, true
+	}
+	m, ok := r.db.data.family[memoryFamilyKey{UserID: friendID, FriendID: userID}]
+	return m, ok
+}
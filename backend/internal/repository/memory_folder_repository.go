@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"sort"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/util"
+)
+
+type MemoryFolderRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryFolderRepository(db *MemoryDB) *MemoryFolderRepository {
+	return &MemoryFolderRepository{db: db}
+}
+
+func (r *MemoryFolderRepository) CreateFolder(ctx context.Context, input domain.CreateVaultFolderInput) (domain.VaultFolder, error) {
+	folderID, err := util.NewUUID()
+	if err != nil {
+		return domain.VaultFolder{}, err
+	}
+
+	defer r.db.lock(ctx)()
+	now := memoryNow()
+	folder := domain.VaultFolder{
+		ID:             folderID,
+		OwnerUserID:    input.OwnerUserID,
+		NameCiphertext: input.NameCiphertext,
+		Nonce:          input.Nonce,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	r.db.data.folders[folderID] = folder
+	return folder, nil
+}
+
+func (r *MemoryFolderRepository) ListFoldersByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultFolder, error) {
+	defer r.db.lock(ctx)()
+
+	var folders []domain.VaultFolder
+	for _, f := range r.db.data.folders {
+		if f.OwnerUserID == ownerUserID {
+			folders = append(folders, f)
+		}
+	}
+	sort.Slice(folders, func(i, j int) bool {
+		if !folders[i].CreatedAt.Equal(folders[j].CreatedAt) {
+			return folders[i].CreatedAt.After(folders[j].CreatedAt)
+		}
+		return folders[i].ID < folders[j].ID
+	})
+	return folders, nil
+}
+
+func (r *MemoryFolderRepository) GetFolderByIDForOwner(ctx context.Context, folderID string, ownerUserID string) (domain.VaultFolder, error) {
+	defer r.db.lock(ctx)()
+	f, ok := r.db.data.folders[folderID]
+	if !ok || f.OwnerUserID != ownerUserID {
+		return domain.VaultFolder{}, domain.ErrNotFound
+	}
+	return f, nil
+}
+
+func (r *MemoryFolderRepository) UpdateFolderForOwner(ctx context.Context, folderID string, ownerUserID string, nameCiphertext []byte, nonce []byte) (domain.VaultFolder, error) {
+	defer r.db.lock(ctx)()
+	f, ok := r.db.data.folders[folderID]
+	if !ok || f.OwnerUserID != ownerUserID {
+		return domain.VaultFolder{}, domain.ErrNotFound
+	}
+	f.NameCiphertext = nameCiphertext
+	f.Nonce = nonce
+	f.UpdatedAt = memoryNow()
+	r.db.data.folders[folderID] = f
+	return f, nil
+}
+
+// DeleteFolderForOwner moves the folder's items and their history out of
+// it, as ON DELETE SET NULL does in the SQL schemas.
+func (r *MemoryFolderRepository) DeleteFolderForOwner(ctx context.Context, folderID string, ownerUserID string) (bool, error) {
+	defer r.db.lock(ctx)()
+	data := r.db.data
+
+	f, ok := data.folders[folderID]
+	if !ok || f.OwnerUserID != ownerUserID {
+		return false, nil
+	}
+	delete(data.folders, folderID)
+	for id, item := range data.items {
+		if item.FolderID != nil && *item.FolderID == folderID {
+			item.FolderID = nil
+			data.items[id] = item
+		}
+	}
+	for id, v := range data.itemVersions {
+		if v.FolderID != nil && *v.FolderID == folderID {
+			v.FolderID = nil
+			data.itemVersions[id] = v
+		}
+	}
+	return true, nil
+}
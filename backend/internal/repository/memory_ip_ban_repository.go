@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MemoryIPBanRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryIPBanRepository(db *MemoryDB) *MemoryIPBanRepository {
+	return &MemoryIPBanRepository{db: db}
+}
+
+func (r *MemoryIPBanRepository) GetIPBan(ctx context.Context, ip string) (domain.IPBan, error) {
+	defer r.db.lock(ctx)()
+	ban, ok := r.db.data.ipBans[ip]
+	if !ok {
+		return domain.IPBan{}, domain.ErrNotFound
+	}
+	return ban, nil
+}
+
+func (r *MemoryIPBanRepository) SaveIPBan(ctx context.Context, ban domain.IPBan) (domain.IPBan, error) {
+	defer r.db.lock(ctx)()
+	now := memoryNow()
+	ban.CreatedAt = now
+	if existing, ok := r.db.data.ipBans[ban.IP]; ok {
+		ban.CreatedAt = existing.CreatedAt
+	}
+	ban.UpdatedAt = now
+	ban.BannedUntil = ban.BannedUntil.UTC()
+	r.db.data.ipBans[ban.IP] = ban
+	return ban, nil
+}
+
+func (r *MemoryIPBanRepository) ListActiveIPBans(ctx context.Context, now time.Time) ([]domain.IPBan, error) {
+	defer r.db.lock(ctx)()
+	bans := make([]domain.IPBan, 0)
+	for _, ban := range r.db.data.ipBans {
+		if ban.Active(now) {
+			bans = append(bans, ban)
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].BannedUntil.After(bans[j].BannedUntil) })
+	return bans, nil
+}
+
+func (r *MemoryIPBanRepository) DeleteIPBan(ctx context.Context, ip string) error {
+	defer r.db.lock(ctx)()
+	if _, ok := r.db.data.ipBans[ip]; !ok {
+		return domain.ErrNotFound
+	}
+	delete(r.db.data.ipBans, ip)
+	return nil
+}
+
+func (r *MemoryIPBanRepository) DeleteIPBansEndedBefore(ctx context.Context, before time.Time) (int64, error) {
+	defer r.db.lock(ctx)()
+	var deleted int64
+	for ip, ban := range r.db.data.ipBans {
+		if ban.BannedUntil.Before(before) {
+			delete(r.db.data.ipBans, ip)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
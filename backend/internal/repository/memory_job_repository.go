@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"pmv2/backend/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type MemoryJobRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryJobRepository(db *MemoryDB) *MemoryJobRepository {
+	return &MemoryJobRepository{db: db}
+}
+
+// TryLock takes an in-process lock; the data lives in this process only.
+func (r *MemoryJobRepository) TryLock(ctx context.Context, jobName string) (func(), bool, error) {
+	release, acquired := r.db.tryLock("job:" + jobName)
+	return release, acquired, nil
+}
+
+func (r *MemoryJobRepository) LastRunStartedAt(ctx context.Context, jobName string) (time.Time, error) {
+	defer r.db.lock(ctx)()
+	var last time.Time
+	for _, run := range r.db.data.jobRuns {
+		if run.JobName == jobName && run.StartedAt.After(last) {
+			last = run.StartedAt
+		}
+	}
+	return last, nil
+}
+
+func (r *MemoryJobRepository) StartRun(ctx context.Context, jobName string, startedAt time.Time) (string, error) {
+	defer r.db.lock(ctx)()
+	id := uuid.NewString()
+	r.db.data.jobRuns[id] = domain.JobRun{
+		ID:        id,
+		JobName:   jobName,
+		Status:    domain.JobRunStatusRunning,
+		StartedAt: startedAt.UTC(),
+	}
+	return id, nil
+}
+
+func (r *MemoryJobRepository) FinishRun(ctx context.Context, runID string, status domain.JobRunStatus, errMessage string, finishedAt time.Time) error {
+	defer r.db.lock(ctx)()
+	run, ok := r.db.data.jobRuns[runID]
+	if !ok {
+		return nil
+	}
+	finished := finishedAt.UTC()
+	run.Status = status
+	run.Error = errMessage
+	run.FinishedAt = &finished
+	r.db.data.jobRuns[runID] = run
+	return nil
+}
+
+func (r *MemoryJobRepository) DeleteRunsBefore(ctx context.Context, before time.Time) (int64, error) {
+	defer r.db.lock(ctx)()
+	var deleted int64
+	for id, run := range r.db.data.jobRuns {
+		if run.StartedAt.Before(before) {
+			delete(r.db.data.jobRuns, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
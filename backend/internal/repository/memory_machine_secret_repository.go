@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MemoryMachineSecretRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryMachineSecretRepository(db *MemoryDB) *MemoryMachineSecretRepository {
+	return &MemoryMachineSecretRepository{db: db}
+}
+
+func (r *MemoryMachineSecretRepository) UpsertMachineSecret(ctx context.Context, s domain.MachineSecret) (domain.MachineSecret, error) {
+	defer r.db.lock(ctx)()
+	now := memoryNow()
+	stored := domain.MachineSecret{ID: uuid.NewString(), CreatedAt: now}
+	for _, existing := range r.db.data.machineSecrets {
+		if existing.OwnerUserID == s.OwnerUserID && existing.Name == s.Name && existing.ItemID != s.ItemID {
+			return domain.MachineSecret{}, domain.ErrMachineSecretNameTaken
+		}
+		if existing.ItemID == s.ItemID {
+			stored = existing
+		}
+	}
+	if _, ok := r.db.data.items[s.ItemID]; !ok {
+		return domain.MachineSecret{}, domain.ErrNotFound
+	}
+	stored.OwnerUserID = s.OwnerUserID
+	stored.ItemID = s.ItemID
+	stored.Name = s.Name
+	stored.Ciphertext = s.Ciphertext
+	stored.Nonce = s.Nonce
+	stored.AlgoVersion = s.AlgoVersion
+	stored.UpdatedAt = now
+	r.db.data.machineSecrets[stored.ID] = stored
+	return stored, nil
+}
+
+func (r *MemoryMachineSecretRepository) ListMachineSecrets(ctx context.Context, ownerUserID string) ([]domain.MachineSecret, error) {
+	defer r.db.lock(ctx)()
+	secrets := make([]domain.MachineSecret, 0)
+	for _, s := range r.db.data.machineSecrets {
+		if s.OwnerUserID == ownerUserID {
+			secrets = append(secrets, s)
+		}
+	}
+	sort.Slice(secrets, func(i, j int) bool { return secrets[i].Name < secrets[j].Name })
+	return secrets, nil
+}
+
+func (r *MemoryMachineSecretRepository) GetMachineSecretByName(ctx context.Context, ownerUserID, name string) (domain.MachineSecret, error) {
+	defer r.db.lock(ctx)()
+	for _, s := range r.db.data.machineSecrets {
+		if s.OwnerUserID == ownerUserID && s.Name == name {
+			return s, nil
+		}
+	}
+	return domain.MachineSecret{}, domain.ErrNotFound
+}
+
+func (r *MemoryMachineSecretRepository) DeleteMachineSecret(ctx context.Context, ownerUserID, itemID string) error {
+	defer r.db.lock(ctx)()
+	for id, s := range r.db.data.machineSecrets {
+		if s.OwnerUserID == ownerUserID && s.ItemID == itemID {
+			delete(r.db.data.machineSecrets, id)
+			return nil
+		}
+	}
+	return domain.ErrNotFound
+}
+
+func (r *MemoryMachineSecretRepository) CreateMachineKey(ctx context.Context, key domain.MachineKey) (domain.MachineKey, error) {
+	defer r.db.lock(ctx)()
+	key.Key = ""
+	key.Scopes = slices.Clone(key.Scopes)
+	key.CreatedAt = memoryNow()
+	r.db.data.machineKeys[key.ID] = key
+	return key, nil
+}
+
+func (r *MemoryMachineSecretRepository) ListMachineKeys(ctx context.Context, ownerUserID string) ([]domain.MachineKey, error) {
+	defer r.db.lock(ctx)()
+	keys := make([]domain.MachineKey, 0)
+	for _, k := range r.db.data.machineKeys {
+		if k.OwnerUserID == ownerUserID {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (r *MemoryMachineSecretRepository) CountMachineKeys(ctx context.Context, ownerUserID string) (int, error) {
+	defer r.db.lock(ctx)()
+	count := 0
+	for _, k := range r.db.data.machineKeys {
+		if k.OwnerUserID == ownerUserID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *MemoryMachineSecretRepository) GetMachineKeyByHash(ctx context.Context, keyHash []byte) (domain.MachineKey, error) {
+	defer r.db.lock(ctx)()
+	for _, k := range r.db.data.machineKeys {
+		if bytes.Equal(k.KeyHash, keyHash) {
+			return k, nil
+		}
+	}
+	return domain.MachineKey{}, domain.ErrNotFound
+}
+
+func (r *MemoryMachineSecretRepository) DeleteMachineKey(ctx context.Context, ownerUserID, id string) error {
+	defer r.db.lock(ctx)()
+	k, ok := r.db.data.machineKeys[id]
+	if !ok || k.OwnerUserID != ownerUserID {
+		return domain.ErrNotFound
+	}
+	delete(r.db.data.machineKeys, id)
+	return nil
+}
+
+func (r *MemoryMachineSecretRepository) TouchMachineKey(ctx context.Context, id string, usedAt time.Time) error {
+	defer r.db.lock(ctx)()
+	k, ok := r.db.data.machineKeys[id]
+	if !ok {
+		return nil
+	}
+	usedAt = usedAt.UTC()
+	k.LastUsedAt = &usedAt
+	r.db.data.machineKeys[id] = k
+	return nil
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"maps"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MemoryNotificationRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryNotificationRepository(db *MemoryDB) *MemoryNotificationRepository {
+	return &MemoryNotificationRepository{db: db}
+}
+
+func (r *MemoryNotificationRepository) GetPreferences(ctx context.Context, userID string) (domain.NotificationPreferences, error) {
+	defer r.db.lock(ctx)()
+	prefs, ok := r.db.data.notificationPrefs[userID]
+	if !ok {
+		return domain.NotificationPreferences{}, domain.ErrNotFound
+	}
+	prefs.Categories = maps.Clone(prefs.Categories)
+	return prefs, nil
+}
+
+func (r *MemoryNotificationRepository) SavePreferences(ctx context.Context, prefs domain.NotificationPreferences) (domain.NotificationPreferences, error) {
+	defer r.db.lock(ctx)()
+	prefs.UpdatedAt = memoryNow()
+	stored := prefs
+	stored.Categories = maps.Clone(prefs.Categories)
+	r.db.data.notificationPrefs[prefs.UserID] = stored
+	return prefs, nil
+}
+
+func (r *MemoryNotificationRepository) GetRecipient(ctx context.Context, userID string) (domain.NotificationRecipient, error) {
+	defer r.db.lock(ctx)()
+	user, ok := r.db.data.users[userID]
+	if !ok {
+		return domain.NotificationRecipient{}, domain.ErrNotFound
+	}
+	return domain.NotificationRecipient{Email: user.Email, Name: user.Name}, nil
+}
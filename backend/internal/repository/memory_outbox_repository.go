@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+	"time"
+
+	"pmv2/backend/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type MemoryOutboxRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryOutboxRepository(db *MemoryDB) *MemoryOutboxRepository {
+	return &MemoryOutboxRepository{db: db}
+}
+
+func (r *MemoryOutboxRepository) Enqueue(ctx context.Context, topic string, payload json.RawMessage) error {
+	defer r.db.lock(ctx)()
+	now := memoryNow()
+	id := uuid.NewString()
+	r.db.data.outbox[id] = memoryOutboxEvent{
+		OutboxEvent:   domain.OutboxEvent{ID: id, Topic: topic, Payload: payload, CreatedAt: now},
+		NextAttemptAt: now,
+	}
+	return nil
+}
+
+func (r *MemoryOutboxRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxEvent, error) {
+	defer r.db.lock(ctx)()
+
+	now := memoryNow()
+	due := make([]memoryOutboxEvent, 0)
+	for _, e := range r.db.data.outbox {
+		if e.DispatchedAt == nil && e.FailedAt == nil && !e.NextAttemptAt.After(now) {
+			due = append(due, e)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].CreatedAt.Equal(due[j].CreatedAt) {
+			return due[i].CreatedAt.Before(due[j].CreatedAt)
+		}
+		return due[i].ID < due[j].ID
+	})
+	if limit < len(due) {
+		due = due[:limit]
+	}
+
+	events := make([]domain.OutboxEvent, 0, len(due))
+	for _, e := range due {
+		e.NextAttemptAt = now.Add(lease)
+		e.Attempts++
+		r.db.data.outbox[e.ID] = e
+		events = append(events, e.OutboxEvent)
+	}
+	return events, nil
+}
+
+// update applies fn to the event with id, if there is one.
+func (r *MemoryOutboxRepository) update(id string, fn func(*memoryOutboxEvent)) {
+	e, ok := r.db.data.outbox[id]
+	if !ok {
+		return
+	}
+	fn(&e)
+	r.db.data.outbox[id] = e
+}
+
+// clearSensitive drops payloads that carry secrets; see
+// domain.SensitiveOutboxTopics.
+func (e *memoryOutboxEvent) clearSensitive() {
+	if slices.Contains(domain.SensitiveOutboxTopics, e.Topic) {
+		e.Payload = json.RawMessage(`{}`)
+	}
+}
+
+func (r *MemoryOutboxRepository) MarkDispatched(ctx context.Context, id string) error {
+	defer r.db.lock(ctx)()
+	r.update(id, func(e *memoryOutboxEvent) {
+		now := memoryNow()
+		e.DispatchedAt = &now
+		e.LastError = ""
+		e.clearSensitive()
+	})
+	return nil
+}
+
+func (r *MemoryOutboxRepository) MarkRetry(ctx context.Context, id string, lastError string, nextAttemptAt time.Time) error {
+	defer r.db.lock(ctx)()
+	r.update(id, func(e *memoryOutboxEvent) {
+		e.LastError = lastError
+		e.NextAttemptAt = nextAttemptAt.UTC()
+	})
+	return nil
+}
+
+func (r *MemoryOutboxRepository) MarkFailed(ctx context.Context, id string, lastError string) error {
+	defer r.db.lock(ctx)()
+	r.update(id, func(e *memoryOutboxEvent) {
+		now := memoryNow()
+		e.FailedAt = &now
+		e.LastError = lastError
+		e.clearSensitive()
+	})
+	return nil
+}
+
+func (r *MemoryOutboxRepository) DeleteDispatchedBefore(ctx context.Context, before time.Time) (int64, error) {
+	defer r.db.lock(ctx)()
+	var deleted int64
+	for id, e := range r.db.data.outbox {
+		if e.DispatchedAt != nil && e.DispatchedAt.Before(before) {
+			delete(r.db.data.outbox, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MemoryPasskeyRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryPasskeyRepository(db *MemoryDB) *MemoryPasskeyRepository {
+	return &MemoryPasskeyRepository{db: db}
+}
+
+func (r *MemoryPasskeyRepository) CreatePasskeyChallenge(ctx context.Context, challenge domain.PasskeyChallenge) error {
+	defer r.db.lock(ctx)()
+	r.db.data.passkeyChallenges[challenge.ID] = challenge
+	return nil
+}
+
+func (r *MemoryPasskeyRepository) ConsumePasskeyChallenge(ctx context.Context, id string, purpose domain.PasskeyChallengePurpose, now time.Time) (domain.PasskeyChallenge, error) {
+	defer r.db.lock(ctx)()
+	c, ok := r.db.data.passkeyChallenges[id]
+	if !ok || c.Purpose != purpose || !c.ExpiresAt.After(now) {
+		return domain.PasskeyChallenge{}, domain.ErrNotFound
+	}
+	delete(r.db.data.passkeyChallenges, id)
+	return c, nil
+}
+
+func (r *MemoryPasskeyRepository) DeleteExpiredPasskeyChallenges(ctx context.Context, before time.Time) (int64, error) {
+	defer r.db.lock(ctx)()
+	var deleted int64
+	for id, c := range r.db.data.passkeyChallenges {
+		if !c.ExpiresAt.After(before) {
+			delete(r.db.data.passkeyChallenges, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *MemoryPasskeyRepository) CreatePasskey(ctx context.Context, p domain.Passkey) (domain.Passkey, error) {
+	defer r.db.lock(ctx)()
+	for _, existing := range r.db.data.passkeys {
+		if bytes.Equal(existing.CredentialID, p.CredentialID) {
+			return domain.Passkey{}, domain.ErrPasskeyTaken
+		}
+	}
+	p.CreatedAt = memoryNow()
+	p.LastUsedAt = nil
+	r.db.data.passkeys[p.ID] = p
+	return p, nil
+}
+
+func (r *MemoryPasskeyRepository) GetPasskeyByCredentialID(ctx context.Context, credentialID []byte) (domain.Passkey, error) {
+	defer r.db.lock(ctx)()
+	for _, p := range r.db.data.passkeys {
+		if bytes.Equal(p.CredentialID, credentialID) {
+			return p, nil
+		}
+	}
+	return domain.Passkey{}, domain.ErrNotFound
+}
+
+func (r *MemoryPasskeyRepository) ListPasskeys(ctx context.Context, userID string) ([]domain.Passkey, error) {
+	defer r.db.lock(ctx)()
+	passkeys := make([]domain.Passkey, 0)
+	for _, p := range r.db.data.passkeys {
+		if p.UserID == userID {
+			passkeys = append(passkeys, p)
+		}
+	}
+	sort.Slice(passkeys, func(i, j int) bool { return passkeys[i].CreatedAt.Before(passkeys[j].CreatedAt) })
+	return passkeys, nil
+}
+
+func (r *MemoryPasskeyRepository) TouchPasskey(ctx context.Context, id string, signCount uint32, usedAt time.Time) error {
+	defer r.db.lock(ctx)()
+	p, ok := r.db.data.passkeys[id]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	usedAt = usedAt.UTC()
+	p.SignCount = signCount
+	p.LastUsedAt = &usedAt
+	r.db.data.passkeys[id] = p
+	return nil
+}
+
+func (r *MemoryPasskeyRepository) DeletePasskey(ctx context.Context, userID, id string) error {
+	defer r.db.lock(ctx)()
+	p, ok := r.db.data.passkeys[id]
+	if !ok || p.UserID != userID {
+		return domain.ErrNotFound
+	}
+	delete(r.db.data.passkeys, id)
+	return nil
+}
+
+func (r *MemoryPasskeyRepository) DeleteAllPasskeys(ctx context.Context, userID string) error {
+	defer r.db.lock(ctx)()
+	for id, p := range r.db.data.passkeys {
+		if p.UserID == userID {
+			delete(r.db.data.passkeys, id)
+		}
+	}
+	return nil
+}
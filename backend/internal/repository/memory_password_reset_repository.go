@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MemoryPasswordResetRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryPasswordResetRepository(db *MemoryDB) *MemoryPasswordResetRepository {
+	return &MemoryPasswordResetRepository{db: db}
+}
+
+func (r *MemoryPasswordResetRepository) CreatePasswordReset(ctx context.Context, token domain.PasswordResetToken) error {
+	defer r.db.lock(ctx)()
+	token.UsedAt = nil
+	token.CreatedAt = memoryNow()
+	r.db.data.passwordResets[token.ID] = token
+	return nil
+}
+
+func (r *MemoryPasswordResetRepository) GetPasswordReset(ctx context.Context, tokenHash []byte, now time.Time) (domain.PasswordResetToken, error) {
+	defer r.db.lock(ctx)()
+	for _, token := range r.db.data.passwordResets {
+		if bytes.Equal(token.TokenHash, tokenHash) && token.UsedAt == nil && token.ExpiresAt.After(now) {
+			return token, nil
+		}
+	}
+	return domain.PasswordResetToken{}, domain.ErrNotFound
+}
+
+func (r *MemoryPasswordResetRepository) CountPasswordResets(ctx context.Context, userID string, since time.Time) (int, error) {
+	defer r.db.lock(ctx)()
+	count := 0
+	for _, token := range r.db.data.passwordResets {
+		if token.UserID == userID && token.CreatedAt.After(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *MemoryPasswordResetRepository) UsePasswordReset(ctx context.Context, id, userID string, now time.Time) error {
+	defer r.db.lock(ctx)()
+	token, ok := r.db.data.passwordResets[id]
+	if !ok || token.UsedAt != nil {
+		return domain.ErrNotFound
+	}
+	usedAt := now.UTC()
+	for tokenID, token := range r.db.data.passwordResets {
+		if token.UserID == userID && token.UsedAt == nil {
+			token.UsedAt = &usedAt
+			r.db.data.passwordResets[tokenID] = token
+		}
+	}
+	return nil
+}
+
+func (r *MemoryPasswordResetRepository) DeleteExpiredPasswordResets(ctx context.Context, before time.Time) (int64, error) {
+	defer r.db.lock(ctx)()
+	var deleted int64
+	for id, token := range r.db.data.passwordResets {
+		if !token.ExpiresAt.After(before) {
+			delete(r.db.data.passwordResets, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
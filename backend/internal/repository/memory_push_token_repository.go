@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MemoryPushTokenRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryPushTokenRepository(db *MemoryDB) *MemoryPushTokenRepository {
+	return &MemoryPushTokenRepository{db: db}
+}
+
+func (r *MemoryPushTokenRepository) UpsertPushToken(ctx context.Context, t domain.PushToken) (domain.PushToken, error) {
+	defer r.db.lock(ctx)()
+	now := memoryNow()
+	stored := domain.PushToken{ID: uuid.NewString(), CreatedAt: now}
+	for _, existing := range r.db.data.pushTokens {
+		if existing.Platform == t.Platform && existing.Token == t.Token {
+			stored = existing
+			break
+		}
+	}
+	stored.UserID = t.UserID
+	stored.DeviceID = t.DeviceID
+	stored.Platform = t.Platform
+	stored.Token = t.Token
+	stored.UpdatedAt = now
+	r.db.data.pushTokens[stored.ID] = stored
+	return stored, nil
+}
+
+func (r *MemoryPushTokenRepository) ListPushTokens(ctx context.Context, userID string) ([]domain.PushToken, error) {
+	defer r.db.lock(ctx)()
+	tokens := make([]domain.PushToken, 0)
+	for _, t := range r.db.data.pushTokens {
+		if t.UserID == userID {
+			tokens = append(tokens, t)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].UpdatedAt.After(tokens[j].UpdatedAt) })
+	return tokens, nil
+}
+
+func (r *MemoryPushTokenRepository) DeletePushToken(ctx context.Context, userID, id string) error {
+	defer r.db.lock(ctx)()
+	t, ok := r.db.data.pushTokens[id]
+	if !ok || t.UserID != userID {
+		return domain.ErrNotFound
+	}
+	delete(r.db.data.pushTokens, id)
+	return nil
+}
+
+func (r *MemoryPushTokenRepository) DeletePushTokenByValue(ctx context.Context, platform domain.PushPlatform, token string) error {
+	defer r.db.lock(ctx)()
+	for id, t := range r.db.data.pushTokens {
+		if t.Platform == platform && t.Token == token {
+			delete(r.db.data.pushTokens, id)
+		}
+	}
+	return nil
+}
+
+func (r *MemoryPushTokenRepository) DeleteStalePushTokens(ctx context.Context, before time.Time) (int64, error) {
+	defer r.db.lock(ctx)()
+	var deleted int64
+	for id, t := range r.db.data.pushTokens {
+		if t.UpdatedAt.Before(before) {
+			delete(r.db.data.pushTokens, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
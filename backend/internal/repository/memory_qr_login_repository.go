@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MemoryQRLoginRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryQRLoginRepository(db *MemoryDB) *MemoryQRLoginRepository {
+	return &MemoryQRLoginRepository{db: db}
+}
+
+func (r *MemoryQRLoginRepository) CreateQRLogin(ctx context.Context, req domain.QRLoginRequest) error {
+	defer r.db.lock(ctx)()
+	req.Status = domain.QRLoginPending
+	req.UserID = ""
+	req.CreatedAt = memoryNow()
+	r.db.data.qrLogins[req.ID] = req
+	return nil
+}
+
+func (r *MemoryQRLoginRepository) GetQRLogin(ctx context.Context, id string, now time.Time) (domain.QRLoginRequest, error) {
+	defer r.db.lock(ctx)()
+	req, ok := r.db.data.qrLogins[id]
+	if !ok || !req.ExpiresAt.After(now) {
+		return domain.QRLoginRequest{}, domain.ErrNotFound
+	}
+	return req, nil
+}
+
+func (r *MemoryQRLoginRepository) DecideQRLogin(ctx context.Context, id, userID string, status domain.QRLoginStatus, now time.Time) error {
+	defer r.db.lock(ctx)()
+	req, ok := r.db.data.qrLogins[id]
+	if !ok || req.Status != domain.QRLoginPending || !req.ExpiresAt.After(now) {
+		return domain.ErrNotFound
+	}
+	req.Status = status
+	req.UserID = userID
+	r.db.data.qrLogins[id] = req
+	return nil
+}
+
+func (r *MemoryQRLoginRepository) DeleteQRLogin(ctx context.Context, id string) error {
+	defer r.db.lock(ctx)()
+	if _, ok := r.db.data.qrLogins[id]; !ok {
+		return domain.ErrNotFound
+	}
+	delete(r.db.data.qrLogins, id)
+	return nil
+}
+
+func (r *MemoryQRLoginRepository) DeleteExpiredQRLogins(ctx context.Context, before time.Time) (int64, error) {
+	defer r.db.lock(ctx)()
+	var deleted int64
+	for id, req := range r.db.data.qrLogins {
+		if !req.ExpiresAt.After(before) {
+			delete(r.db.data.qrLogins, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,421 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MemorySharingRepository struct {
+	db *MemoryDB
+}
+
+func NewMemorySharingRepository(db *MemoryDB) *MemorySharingRepository {
+	return &MemorySharingRepository{db: db}
+}
+
+func (r *MemorySharingRepository) CreateShare(ctx context.Context, input domain.ShareItemInput) error {
+	defer r.db.lock(ctx)()
+
+	key := memoryShareKey{ItemID: input.ItemID, UserID: input.RecipientID}
+	if _, exists := r.db.data.shares[key]; exists {
+		return domain.ErrAlreadyShared
+	}
+	now := memoryNow()
+	r.db.data.shares[key] = domain.VaultShare{
+		ItemID:         input.ItemID,
+		UserID:         input.RecipientID,
+		SharedByUserID: input.SharedByUserID,
+		DEKWrapped:     input.DEKWrapped,
+		WrapNonce:      input.WrapNonce,
+		Permissions:    input.Permissions,
+		Window:         input.Window,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	return nil
+}
+
+func (r *MemorySharingRepository) DeleteShare(ctx context.Context, itemID string, recipientUserID string) error {
+	defer r.db.lock(ctx)()
+
+	key := memoryShareKey{ItemID: itemID, UserID: recipientUserID}
+	if _, exists := r.db.data.shares[key]; !exists {
+		return domain.ErrShareNotFound
+	}
+	delete(r.db.data.shares, key)
+	return nil
+}
+
+func (r *MemorySharingRepository) UpdateSharePermissions(ctx context.Context, itemID string, recipientUserID string, permissions string) error {
+	defer r.db.lock(ctx)()
+
+	key := memoryShareKey{ItemID: itemID, UserID: recipientUserID}
+	share, exists := r.db.data.shares[key]
+	if !exists {
+		return domain.ErrShareNotFound
+	}
+	share.Permissions = permissions
+	share.UpdatedAt = time.Now().UTC()
+	r.db.data.shares[key] = share
+	return nil
+}
+
+// sortSharesNewestFirst orders shares by creation time, newest first.
+func sortSharesNewestFirst(shares []domain.VaultShare) {
+	sort.Slice(shares, func(i, j int) bool {
+		if !shares[i].CreatedAt.Equal(shares[j].CreatedAt) {
+			return shares[i].CreatedAt.After(shares[j].CreatedAt)
+		}
+		return shares[i].ItemID < shares[j].ItemID
+	})
+}
+
+func (r *MemorySharingRepository) ListSharesByRecipient(ctx context.Context, userID string) ([]domain.SharedVaultItem, error) {
+	defer r.db.lock(ctx)()
+	data := r.db.data
+
+	shares := make([]domain.VaultShare, 0)
+	for _, s := range data.shares {
+		if s.UserID == userID {
+			shares = append(shares, s)
+		}
+	}
+	sortSharesNewestFirst(shares)
+
+	items := make([]domain.SharedVaultItem, 0, len(shares))
+	for _, s := range shares {
+		item, ok := data.items[s.ItemID]
+		if !ok {
+			continue
+		}
+		sharedBy := data.users[s.SharedByUserID]
+		items = append(items, domain.SharedVaultItem{
+			VaultItem: domain.VaultItem{
+				ID:          item.ID,
+				OwnerUserID: item.OwnerUserID,
+				FolderID:    item.FolderID,
+				Ciphertext:  item.Ciphertext,
+				Nonce:       item.Nonce,
+				WrappedDEK:  item.WrappedDEK,
+				WrapNonce:   item.WrapNonce,
+				AlgoVersion: item.AlgoVersion,
+				Metadata:    item.Metadata,
+				CreatedAt:   item.CreatedAt,
+				UpdatedAt:   item.UpdatedAt,
+			},
+			SharedByUserID: s.SharedByUserID,
+			SharedByEmail:  sharedBy.Email,
+			SharedByName:   sharedBy.Name,
+			Permissions:    s.Permissions,
+			ShareDEK:       s.DEKWrapped,
+			ShareWrapNonce: s.WrapNonce,
+			ShareWindow:    s.Window,
+
+			FirstAccessedAt: s.FirstAccessedAt,
+		})
+	}
+	return items, nil
+}
+
+func (r *MemorySharingRepository) ListSharesByItem(ctx context.Context, itemID string) ([]domain.VaultShare, error) {
+	defer r.db.lock(ctx)()
+
+	shares := make([]domain.VaultShare, 0)
+	for _, s := range r.db.data.shares {
+		if s.ItemID == itemID {
+			shares = append(shares, s)
+		}
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if !shares[i].CreatedAt.Equal(shares[j].CreatedAt) {
+			return shares[i].CreatedAt.Before(shares[j].CreatedAt)
+		}
+		return shares[i].UserID < shares[j].UserID
+	})
+	return shares, nil
+}
+
+func (r *MemorySharingRepository) GetShare(ctx context.Context, itemID string, userID string) (domain.VaultShare, error) {
+	defer r.db.lock(ctx)()
+	s, ok := r.db.data.shares[memoryShareKey{ItemID: itemID, UserID: userID}]
+	if !ok {
+		return domain.VaultShare{}, domain.ErrShareNotFound
+	}
+	return s, nil
+}
+
+func (r *MemorySharingRepository) ListSentShares(ctx context.Context, userID string) ([]domain.SentShare, error) {
+	defer r.db.lock(ctx)()
+	data := r.db.data
+
+	shares := make([]domain.VaultShare, 0)
+	for _, s := range data.shares {
+		if s.SharedByUserID == userID {
+			shares = append(shares, s)
+		}
+	}
+	sortSharesNewestFirst(shares)
+
+	sent := make([]domain.SentShare, 0, len(shares))
+	for _, s := range shares {
+		item, ok := data.items[s.ItemID]
+		if !ok {
+			continue
+		}
+		recipient, ok := data.users[s.UserID]
+		if !ok {
+			continue
+		}
+		sent = append(sent, domain.SentShare{
+			ItemID:         s.ItemID,
+			ItemTitle:      string(item.Metadata),
+			RecipientID:    s.UserID,
+			RecipientEmail: recipient.Email,
+			RecipientName:  recipient.Name,
+			Permissions:    s.Permissions,
+			CreatedAt:      s.CreatedAt,
+		})
+	}
+	return sent, nil
+}
+
+func (r *MemorySharingRepository) DeleteAllSharesBetweenUsers(ctx context.Context, user1ID, user2ID string) error {
+	defer r.db.lock(ctx)()
+	for key, s := range r.db.data.shares {
+		if (s.SharedByUserID == user1ID && s.UserID == user2ID) || (s.SharedByUserID == user2ID && s.UserID == user1ID) {
+			delete(r.db.data.shares, key)
+		}
+	}
+	return nil
+}
+
+func (r *MemorySharingRepository) ListExpiredShares(ctx context.Context, now time.Time, limit int) ([]domain.VaultShare, error) {
+	defer r.db.lock(ctx)()
+
+	shares := make([]domain.VaultShare, 0)
+	for _, s := range r.db.data.shares {
+		if s.Window.Expired(now) {
+			shares = append(shares, s)
+		}
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		return shares[i].Window.ExpiresAt.Before(*shares[j].Window.ExpiresAt)
+	})
+	if len(shares) > limit {
+		shares = shares[:limit]
+	}
+	return shares, nil
+}
+
+func (r *MemorySharingRepository) GetItemOwner(ctx context.Context, itemID string) (string, error) {
+	defer r.db.lock(ctx)()
+	item, ok := r.db.data.items[itemID]
+	if !ok || item.DeletedAt != nil {
+		return "", domain.ErrNotFound
+	}
+	return item.OwnerUserID, nil
+}
+
+func (r *MemorySharingRepository) CreateAccessRequest(ctx context.Context, req domain.AccessRequest) (domain.AccessRequest, error) {
+	defer r.db.lock(ctx)()
+	req.Status = domain.AccessRequestPending
+	req.CreatedAt = memoryNow()
+	r.db.data.accessRequests[req.ID] = req
+	return r.db.data.withRequester(req), nil
+}
+
+func (r *MemorySharingRepository) GetAccessRequest(ctx context.Context, id string) (domain.AccessRequest, error) {
+	defer r.db.lock(ctx)()
+	req, ok := r.db.data.accessRequests[id]
+	if !ok {
+		return domain.AccessRequest{}, domain.ErrAccessRequestNotFound
+	}
+	return r.db.data.withRequester(req), nil
+}
+
+func (r *MemorySharingRepository) ListAccessRequestsByRequester(ctx context.Context, requesterUserID string) ([]domain.AccessRequest, error) {
+	defer r.db.lock(ctx)()
+	return r.db.data.accessRequestsWhere(func(req domain.AccessRequest) bool {
+		return req.RequesterUserID == requesterUserID
+	}, false), nil
+}
+
+func (r *MemorySharingRepository) ListPendingAccessRequestsForApprover(ctx context.Context, approverUserID string) ([]domain.AccessRequest, error) {
+	defer r.db.lock(ctx)()
+	data := r.db.data
+	return data.accessRequestsWhere(func(req domain.AccessRequest) bool {
+		if req.Status != domain.AccessRequestPending {
+			return false
+		}
+		share, shared := data.shares[memoryShareKey{ItemID: req.ItemID, UserID: approverUserID}]
+		return req.OwnerUserID == approverUserID || (shared && share.Permissions == domain.SharePermissionManage)
+	}, true), nil
+}
+
+func (r *MemorySharingRepository) DecideAccessRequest(ctx context.Context, id string, status domain.AccessRequestStatus, decidedByUserID string, decidedAt time.Time) error {
+	defer r.db.lock(ctx)()
+	req, ok := r.db.data.accessRequests[id]
+	if !ok {
+		return domain.ErrAccessRequestNotFound
+	}
+	if req.Status != domain.AccessRequestPending {
+		return domain.ErrAccessRequestDecided
+	}
+	decidedAt = decidedAt.UTC()
+	req.Status, req.DecidedByUserID, req.DecidedAt = status, decidedByUserID, &decidedAt
+	r.db.data.accessRequests[id] = req
+	return nil
+}
+
+// accessRequestsWhere returns the requests keep selects, oldest first or
+// newest first.
+func (d memoryData) accessRequestsWhere(keep func(domain.AccessRequest) bool, oldestFirst bool) []domain.AccessRequest {
+	requests := make([]domain.AccessRequest, 0)
+	for _, req := range d.accessRequests {
+		if keep(req) {
+			requests = append(requests, d.withRequester(req))
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		if !requests[i].CreatedAt.Equal(requests[j].CreatedAt) {
+			return requests[i].CreatedAt.Before(requests[j].CreatedAt) == oldestFirst
+		}
+		return requests[i].ID < requests[j].ID
+	})
+	return requests
+}
+
+func (d memoryData) withRequester(req domain.AccessRequest) domain.AccessRequest {
+	requester := d.users[req.RequesterUserID]
+	req.RequesterEmail, req.RequesterName = requester.Email, requester.Name
+	return req
+}
+
+func (r *MemorySharingRepository) CreateShareInvitation(ctx context.Context, inv domain.ShareInvitation) (domain.ShareInvitation, error) {
+	defer r.db.lock(ctx)()
+	for _, existing := range r.db.data.shareInvitations {
+		if existing.ItemID == inv.ItemID && existing.Email == inv.Email {
+			return domain.ShareInvitation{}, domain.ErrShareInvitationExists
+		}
+	}
+	inv.Status = domain.ShareInvitationPending
+	inv.CreatedAt = memoryNow()
+	r.db.data.shareInvitations[inv.ID] = inv
+	return inv, nil
+}
+
+func (r *MemorySharingRepository) GetShareInvitation(ctx context.Context, id string) (domain.ShareInvitation, error) {
+	defer r.db.lock(ctx)()
+	inv, ok := r.db.data.shareInvitations[id]
+	if !ok {
+		return domain.ShareInvitation{}, domain.ErrShareInvitationNotFound
+	}
+	return inv, nil
+}
+
+func (r *MemorySharingRepository) ListShareInvitationsBySharer(ctx context.Context, sharedByUserID string) ([]domain.ShareInvitation, error) {
+	defer r.db.lock(ctx)()
+	return r.db.data.shareInvitationsWhere(func(inv domain.ShareInvitation) bool {
+		return inv.SharedByUserID == sharedByUserID
+	}), nil
+}
+
+func (r *MemorySharingRepository) ListPendingShareInvitationsByEmail(ctx context.Context, email string, now time.Time) ([]domain.ShareInvitation, error) {
+	defer r.db.lock(ctx)()
+	return r.db.data.shareInvitationsWhere(func(inv domain.ShareInvitation) bool {
+		return inv.Email == email && inv.Status == domain.ShareInvitationPending && inv.ExpiresAt.After(now)
+	}), nil
+}
+
+func (r *MemorySharingRepository) MarkShareInvitationReady(ctx context.Context, id, recipientUserID string, readyAt time.Time) error {
+	defer r.db.lock(ctx)()
+	inv, ok := r.db.data.shareInvitations[id]
+	if !ok || inv.Status != domain.ShareInvitationPending {
+		return domain.ErrShareInvitationNotFound
+	}
+	readyAt = readyAt.UTC()
+	inv.Status, inv.RecipientUserID, inv.ReadyAt = domain.ShareInvitationReady, recipientUserID, &readyAt
+	r.db.data.shareInvitations[id] = inv
+	return nil
+}
+
+func (r *MemorySharingRepository) DeleteShareInvitation(ctx context.Context, id, sharedByUserID string) error {
+	defer r.db.lock(ctx)()
+	inv, ok := r.db.data.shareInvitations[id]
+	if !ok || inv.SharedByUserID != sharedByUserID {
+		return domain.ErrShareInvitationNotFound
+	}
+	delete(r.db.data.shareInvitations, id)
+	return nil
+}
+
+func (r *MemorySharingRepository) DeleteExpiredShareInvitations(ctx context.Context, now time.Time) (int64, error) {
+	defer r.db.lock(ctx)()
+	var deleted int64
+	for id, inv := range r.db.data.shareInvitations {
+		if !inv.ExpiresAt.After(now) {
+			delete(r.db.data.shareInvitations, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// shareInvitationsWhere returns the invitations keep selects, newest first.
+func (d memoryData) shareInvitationsWhere(keep func(domain.ShareInvitation) bool) []domain.ShareInvitation {
+	invitations := make([]domain.ShareInvitation, 0)
+	for _, inv := range d.shareInvitations {
+		if keep(inv) {
+			invitations = append(invitations, inv)
+		}
+	}
+	sort.Slice(invitations, func(i, j int) bool {
+		if !invitations[i].CreatedAt.Equal(invitations[j].CreatedAt) {
+			return invitations[i].CreatedAt.After(invitations[j].CreatedAt)
+		}
+		return invitations[i].ID < invitations[j].ID
+	})
+	return invitations
+}
+
+func (r *MemorySharingRepository) MarkShareAccessed(ctx context.Context, itemID, userID string, at time.Time) (bool, error) {
+	defer r.db.lock(ctx)()
+	key := memoryShareKey{ItemID: itemID, UserID: userID}
+	s, ok := r.db.data.shares[key]
+	if !ok || s.FirstAccessedAt != nil {
+		return false, nil
+	}
+	at = at.UTC()
+	s.FirstAccessedAt = &at
+	r.db.data.shares[key] = s
+	return true, nil
+}
+
+func (r *MemorySharingRepository) GetItemAccessAlert(ctx context.Context, itemID string) (domain.ItemAccessAlert, error) {
+	defer r.db.lock(ctx)()
+	alert, ok := r.db.data.itemAccessAlerts[itemID]
+	if !ok {
+		return domain.ItemAccessAlert{}, domain.ErrNotFound
+	}
+	return alert, nil
+}
+
+func (r *MemorySharingRepository) SetItemAccessAlert(ctx context.Context, alert domain.ItemAccessAlert) error {
+	defer r.db.lock(ctx)()
+	if existing, ok := r.db.data.itemAccessAlerts[alert.ItemID]; ok {
+		alert.CreatedAt = existing.CreatedAt
+	} else {
+		alert.CreatedAt = memoryNow()
+	}
+	r.db.data.itemAccessAlerts[alert.ItemID] = alert
+	return nil
+}
+
+func (r *MemorySharingRepository) DeleteItemAccessAlert(ctx context.Context, itemID string) error {
+	defer r.db.lock(ctx)()
+	delete(r.db.data.itemAccessAlerts, itemID)
+	return nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MemoryUserKeysRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryUserKeysRepository(db *MemoryDB) *MemoryUserKeysRepository {
+	return &MemoryUserKeysRepository{db: db}
+}
+
+func (r *MemoryUserKeysRepository) UpsertKeys(ctx context.Context, input domain.UpsertUserKeysInput) error {
+	defer r.db.lock(ctx)()
+
+	now := memoryNow()
+	keys, ok := r.db.data.userKeys[input.UserID]
+	if !ok {
+		keys = domain.UserKeys{UserID: input.UserID, CreatedAt: now}
+	}
+	keys.PublicKeyX25519 = input.PublicKeyX25519
+	keys.PublicKeyEd25519 = input.PublicKeyEd25519
+	keys.EncryptedPrivateKeys = input.EncryptedPrivateKeys
+	keys.Nonce = input.Nonce
+	keys.UpdatedAt = now
+	r.db.data.userKeys[input.UserID] = keys
+	return nil
+}
+
+func (r *MemoryUserKeysRepository) GetKeysByUserID(ctx context.Context, userID string) (domain.UserKeys, error) {
+	defer r.db.lock(ctx)()
+	keys, ok := r.db.data.userKeys[userID]
+	if !ok {
+		return domain.UserKeys{}, domain.ErrNotFound
+	}
+	return keys, nil
+}
+
+func (r *MemoryUserKeysRepository) GetPublicKeyByEmail(ctx context.Context, email string) (domain.UserKeys, string, error) {
+	defer r.db.lock(ctx)()
+	user, ok := r.db.data.userByEmail(email)
+	if !ok {
+		return domain.UserKeys{}, "", domain.ErrRecipientKeysNotFound
+	}
+	keys, ok := r.db.data.userKeys[user.ID]
+	if !ok {
+		return domain.UserKeys{}, "", domain.ErrRecipientKeysNotFound
+	}
+	return domain.UserKeys{
+		UserID:           user.ID,
+		PublicKeyX25519:  keys.PublicKeyX25519,
+		PublicKeyEd25519: keys.PublicKeyEd25519,
+		CreatedAt:        keys.CreatedAt,
+		UpdatedAt:        keys.UpdatedAt,
+	}, user.ID, nil
+}
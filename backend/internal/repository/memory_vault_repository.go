@@ -0,0 +1,446 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/util"
+)
+
+type MemoryVaultRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryVaultRepository(db *MemoryDB) *MemoryVaultRepository {
+	return &MemoryVaultRepository{db: db}
+}
+
+// item fills in IsShared, which the SQL repositories compute on read.
+func (d memoryData) item(item domain.VaultItem) domain.VaultItem {
+	item.IsShared = false
+	for key := range d.shares {
+		if key.ItemID == item.ID {
+			item.IsShared = true
+			break
+		}
+	}
+	return item
+}
+
+func (r *MemoryVaultRepository) CreateVaultItem(ctx context.Context, input domain.CreateVaultItemInput) (domain.VaultItem, error) {
+	defer r.db.lock(ctx)()
+	return r.insertVaultItem(input)
+}
+
+// CreateVaultItemsBulk inserts all items or, if one fails, none.
+func (r *MemoryVaultRepository) CreateVaultItemsBulk(ctx context.Context, inputs []domain.CreateVaultItemInput) ([]domain.VaultItem, error) {
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+
+	defer r.db.lock(ctx)()
+	items := make([]domain.VaultItem, 0, len(inputs))
+	for _, input := range inputs {
+		item, err := r.insertVaultItem(input)
+		if err != nil {
+			for _, inserted := range items {
+				delete(r.db.data.items, inserted.ID)
+			}
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (r *MemoryVaultRepository) insertVaultItem(input domain.CreateVaultItemInput) (domain.VaultItem, error) {
+	itemID, err := util.NewUUID()
+	if err != nil {
+		return domain.VaultItem{}, err
+	}
+	if input.FolderID != nil {
+		if _, ok := r.db.data.folders[*input.FolderID]; !ok {
+			return domain.VaultItem{}, fmt.Errorf("insert vault item: folder %s does not exist", *input.FolderID)
+		}
+	}
+
+	now := memoryNow()
+	item := domain.VaultItem{
+		ID:          itemID,
+		OwnerUserID: input.OwnerUserID,
+		FolderID:    input.FolderID,
+		Ciphertext:  input.Ciphertext,
+		Nonce:       input.Nonce,
+		WrappedDEK:  input.WrappedDEK,
+		WrapNonce:   input.WrapNonce,
+		AlgoVersion: input.AlgoVersion,
+		Metadata:    input.Metadata,
+		Version:     1,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	r.db.data.items[itemID] = item
+	return item, nil
+}
+
+func (r *MemoryVaultRepository) ListVaultItemsByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultItem, error) {
+	return r.listVaultItemsByOwner(ctx, ownerUserID, false), nil
+}
+
+func (r *MemoryVaultRepository) ListDeletedVaultItemsByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultItem, error) {
+	return r.listVaultItemsByOwner(ctx, ownerUserID, true), nil
+}
+
+func (r *MemoryVaultRepository) ListVaultItemPage(ctx context.Context, ownerUserID string, query domain.VaultItemQuery) ([]domain.VaultItem, int, error) {
+	defer r.db.lock(ctx)()
+	data := r.db.data
+
+	var matched []domain.VaultItem
+	for _, item := range data.items {
+		if item.OwnerUserID != ownerUserID || item.DeletedAt != nil {
+			continue
+		}
+		if query.FolderID != "" && (item.FolderID == nil || *item.FolderID != query.FolderID) {
+			continue
+		}
+		if query.Kind != "" {
+			var metadata struct {
+				Kind string `json:"kind"`
+			}
+			if json.Unmarshal(item.Metadata, &metadata) != nil || metadata.Kind != query.Kind {
+				continue
+			}
+		}
+		matched = append(matched, data.item(item))
+	}
+	key := func(item domain.VaultItem) time.Time {
+		if query.Sort == domain.VaultItemSortCreated {
+			return item.CreatedAt
+		}
+		return item.UpdatedAt
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if query.Ascending {
+			a, b = b, a
+		}
+		if !key(a).Equal(key(b)) {
+			return key(a).After(key(b))
+		}
+		return a.ID > b.ID
+	})
+
+	items := make([]domain.VaultItem, 0)
+	if query.Offset < len(matched) {
+		items = append(items, matched[query.Offset:min(query.Offset+query.Limit, len(matched))]...)
+	}
+	return items, len(matched), nil
+}
+
+func (r *MemoryVaultRepository) listVaultItemsByOwner(ctx context.Context, ownerUserID string, deleted bool) []domain.VaultItem {
+	defer r.db.lock(ctx)()
+	data := r.db.data
+
+	items := make([]domain.VaultItem, 0)
+	for _, item := range data.items {
+		if item.OwnerUserID == ownerUserID && (item.DeletedAt != nil) == deleted {
+			items = append(items, data.item(item))
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if deleted && !a.DeletedAt.Equal(*b.DeletedAt) {
+			return a.DeletedAt.After(*b.DeletedAt)
+		}
+		if !a.UpdatedAt.Equal(b.UpdatedAt) {
+			return a.UpdatedAt.After(b.UpdatedAt)
+		}
+		return a.ID < b.ID
+	})
+	return items
+}
+
+func (r *MemoryVaultRepository) GetVaultItemByIDForOwner(ctx context.Context, itemID string, ownerUserID string) (domain.VaultItem, error) {
+	defer r.db.lock(ctx)()
+	item, ok := r.db.data.items[itemID]
+	if !ok || item.OwnerUserID != ownerUserID {
+		return domain.VaultItem{}, domain.ErrNotFound
+	}
+	return r.db.data.item(item), nil
+}
+
+func (r *MemoryVaultRepository) ListVaultItemVersionsByOwner(ctx context.Context, itemID string, ownerUserID string) ([]domain.VaultItemVersion, error) {
+	defer r.db.lock(ctx)()
+
+	versions := make([]domain.VaultItemVersion, 0)
+	for _, v := range r.db.data.itemVersions {
+		if v.ItemID == itemID && v.OwnerUserID == ownerUserID {
+			versions = append(versions, v)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		if versions[i].Version != versions[j].Version {
+			return versions[i].Version > versions[j].Version
+		}
+		return versions[i].CreatedAt.After(versions[j].CreatedAt)
+	})
+	return versions, nil
+}
+
+func (r *MemoryVaultRepository) UpdateVaultItemForOwner(ctx context.Context, itemID string, ownerUserID string, input domain.UpdateVaultItemInput) (domain.VaultItem, error) {
+	defer r.db.lock(ctx)()
+	data := r.db.data
+
+	current, ok := data.items[itemID]
+	if !ok || current.OwnerUserID != ownerUserID || current.DeletedAt != nil {
+		return domain.VaultItem{}, domain.ErrNotFound
+	}
+	if input.FolderID != nil {
+		if _, ok := data.folders[*input.FolderID]; !ok {
+			return domain.VaultItem{}, fmt.Errorf("update vault item: folder %s does not exist", *input.FolderID)
+		}
+	}
+
+	versionID, err := util.NewUUID()
+	if err != nil {
+		return domain.VaultItem{}, err
+	}
+	data.itemVersions[versionID] = domain.VaultItemVersion{
+		ID:          versionID,
+		ItemID:      current.ID,
+		OwnerUserID: current.OwnerUserID,
+		FolderID:    current.FolderID,
+		Ciphertext:  current.Ciphertext,
+		Nonce:       current.Nonce,
+		WrappedDEK:  current.WrappedDEK,
+		WrapNonce:   current.WrapNonce,
+		AlgoVersion: current.AlgoVersion,
+		Metadata:    current.Metadata,
+		Version:     current.Version,
+		CreatedAt:   current.UpdatedAt,
+	}
+
+	updated := current
+	updated.FolderID = input.FolderID
+	updated.Ciphertext = input.Ciphertext
+	updated.Nonce = input.Nonce
+	updated.WrappedDEK = input.WrappedDEK
+	updated.WrapNonce = input.WrapNonce
+	updated.AlgoVersion = input.AlgoVersion
+	updated.Metadata = input.Metadata
+	updated.Version = current.Version + 1
+	updated.UpdatedAt = memoryNow()
+	data.items[itemID] = updated
+	return data.item(updated), nil
+}
+
+func (r *MemoryVaultRepository) DeleteVaultItemForOwner(ctx context.Context, itemID string, ownerUserID string) (bool, error) {
+	defer r.db.lock(ctx)()
+	item, ok := r.db.data.items[itemID]
+	if !ok || item.OwnerUserID != ownerUserID || item.DeletedAt != nil {
+		return false, nil
+	}
+	now := memoryNow()
+	item.DeletedAt = &now
+	item.UpdatedAt = now
+	r.db.data.items[itemID] = item
+	return true, nil
+}
+
+func (r *MemoryVaultRepository) RestoreVaultItemForOwner(ctx context.Context, itemID string, ownerUserID string) (domain.VaultItem, error) {
+	defer r.db.lock(ctx)()
+	item, ok := r.db.data.items[itemID]
+	if !ok || item.OwnerUserID != ownerUserID || item.DeletedAt == nil {
+		return domain.VaultItem{}, domain.ErrNotFound
+	}
+	item.DeletedAt = nil
+	item.UpdatedAt = memoryNow()
+	r.db.data.items[itemID] = item
+	return r.db.data.item(item), nil
+}
+
+// GetVaultRevision fingerprints the owner's vault like
+// VaultRepository.GetVaultRevision does.
+func (r *MemoryVaultRepository) GetVaultRevision(ctx context.Context, ownerUserID string) (string, error) {
+	defer r.db.lock(ctx)()
+	data := r.db.data
+
+	var (
+		itemCount, versionSum, shareCount, folderCount int64
+		lastUpdated, lastShareAt, lastFolder           time.Time
+	)
+	for _, item := range data.items {
+		if item.OwnerUserID != ownerUserID {
+			continue
+		}
+		itemCount++
+		versionSum += int64(item.Version)
+		if item.UpdatedAt.After(lastUpdated) {
+			lastUpdated = item.UpdatedAt
+		}
+	}
+	for _, share := range data.shares {
+		if item, ok := data.items[share.ItemID]; !ok || item.OwnerUserID != ownerUserID {
+			continue
+		}
+		shareCount++
+		if share.UpdatedAt.After(lastShareAt) {
+			lastShareAt = share.UpdatedAt
+		}
+	}
+	for _, folder := range data.folders {
+		if folder.OwnerUserID != ownerUserID {
+			continue
+		}
+		folderCount++
+		if folder.UpdatedAt.After(lastFolder) {
+			lastFolder = folder.UpdatedAt
+		}
+	}
+
+	return fmt.Sprintf("%d.%d.%d.%d.%d.%d.%d", itemCount, versionSum, lastUpdated.UnixMicro(), shareCount, lastShareAt.UnixMicro(), folderCount, lastFolder.UnixMicro()), nil
+}
+
+// PurgeDeletedVaultItems also drops the purged items' history and shares,
+// as the foreign keys of the SQL schemas cascade.
+func (r *MemoryVaultRepository) PurgeDeletedVaultItems(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	defer r.db.lock(ctx)()
+	data := r.db.data
+
+	var purged int64
+	for id, item := range data.items {
+		if item.DeletedAt == nil || !item.DeletedAt.Before(deletedBefore) {
+			continue
+		}
+		delete(data.items, id)
+		for versionID, v := range data.itemVersions {
+			if v.ItemID == id {
+				delete(data.itemVersions, versionID)
+			}
+		}
+		for key := range data.shares {
+			if key.ItemID == id {
+				delete(data.shares, key)
+			}
+		}
+		for key := range data.uriHMACs {
+			if key.ItemID == id {
+				delete(data.uriHMACs, key)
+			}
+		}
+		delete(data.itemUsage, id)
+		delete(data.itemAccessAlerts, id)
+		for attachmentID, a := range data.attachments {
+			if a.ItemID == id {
+				delete(data.attachments, attachmentID)
+			}
+		}
+		for secretID, s := range data.machineSecrets {
+			if s.ItemID == id {
+				delete(data.machineSecrets, secretID)
+			}
+		}
+		for requestID, req := range data.accessRequests {
+			if req.ItemID == id {
+				delete(data.accessRequests, requestID)
+			}
+		}
+		for invitationID, inv := range data.shareInvitations {
+			if inv.ItemID == id {
+				delete(data.shareInvitations, invitationID)
+			}
+		}
+		data.itemTombstones[id] = memoryTombstone{OwnerUserID: item.OwnerUserID, DeletedAt: memoryNow()}
+		purged++
+	}
+	return purged, nil
+}
+
+func (r *MemoryVaultRepository) ListVaultItemChangesByOwner(ctx context.Context, ownerUserID string, since time.Time) ([]domain.VaultItem, []string, error) {
+	defer r.db.lock(ctx)()
+	data := r.db.data
+
+	var items []domain.VaultItem
+	var deleted []string
+	for _, item := range data.items {
+		if item.OwnerUserID != ownerUserID || !item.UpdatedAt.After(since) {
+			continue
+		}
+		if item.DeletedAt != nil {
+			deleted = append(deleted, item.ID)
+			continue
+		}
+		items = append(items, data.item(item))
+	}
+	for id, t := range data.itemTombstones {
+		if t.OwnerUserID == ownerUserID && t.DeletedAt.After(since) {
+			deleted = append(deleted, id)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if !a.UpdatedAt.Equal(b.UpdatedAt) {
+			return a.UpdatedAt.Before(b.UpdatedAt)
+		}
+		return a.ID < b.ID
+	})
+	sort.Strings(deleted)
+	return items, deleted, nil
+}
+
+func (r *MemoryVaultRepository) DeleteVaultItemTombstones(ctx context.Context, before time.Time) (int64, error) {
+	defer r.db.lock(ctx)()
+	var deleted int64
+	for id, t := range r.db.data.itemTombstones {
+		if t.DeletedAt.Before(before) {
+			delete(r.db.data.itemTombstones, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *MemoryVaultRepository) RewrapVaultItemKeys(ctx context.Context, ownerUserID string, keys []domain.VaultItemKey) error {
+	if !uniqueItemKeys(keys) {
+		return domain.ErrRewrapIncomplete
+	}
+	defer r.db.lock(ctx)()
+	data := r.db.data
+
+	count := 0
+	for _, item := range data.items {
+		if item.OwnerUserID == ownerUserID {
+			count++
+		}
+	}
+	if count != len(keys) {
+		return domain.ErrRewrapIncomplete
+	}
+	for _, key := range keys {
+		if item, ok := data.items[key.ItemID]; !ok || item.OwnerUserID != ownerUserID {
+			return domain.ErrRewrapIncomplete
+		}
+	}
+	now := memoryNow()
+	for _, key := range keys {
+		item := data.items[key.ItemID]
+		item.WrappedDEK = key.WrappedDEK
+		item.WrapNonce = key.WrapNonce
+		item.UpdatedAt = now
+		data.items[key.ItemID] = item
+	}
+	return nil
+}
+
+func (r *MemoryVaultRepository) GetVaultSaltForUser(ctx context.Context, userID string) ([]byte, error) {
+	defer r.db.lock(ctx)()
+	cred, ok := r.db.data.credentials[userID]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return cred.Salt, nil
+}
@@ -0,0 +1,271 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"pmv2/backend/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type MemoryWebhookRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryWebhookRepository(db *MemoryDB) *MemoryWebhookRepository {
+	return &MemoryWebhookRepository{db: db}
+}
+
+func (r *MemoryWebhookRepository) CreateEndpoint(ctx context.Context, endpoint domain.WebhookEndpoint) (domain.WebhookEndpoint, error) {
+	defer r.db.lock(ctx)()
+	now := memoryNow()
+	endpoint.CreatedAt = now
+	endpoint.UpdatedAt = now
+	if len(endpoint.EventTypes) == 0 {
+		endpoint.EventTypes = nil
+	}
+
+	stored := endpoint
+	stored.Secret = ""
+	r.db.data.webhookEndpoints[endpoint.ID] = stored
+	return endpoint, nil
+}
+
+// endpoints lists the endpoints matching keep, oldest first.
+func (r *MemoryWebhookRepository) endpoints(keep func(domain.WebhookEndpoint) bool) []domain.WebhookEndpoint {
+	endpoints := make([]domain.WebhookEndpoint, 0)
+	for _, e := range r.db.data.webhookEndpoints {
+		if keep(e) {
+			endpoints = append(endpoints, e)
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if !endpoints[i].CreatedAt.Equal(endpoints[j].CreatedAt) {
+			return endpoints[i].CreatedAt.Before(endpoints[j].CreatedAt)
+		}
+		return endpoints[i].ID < endpoints[j].ID
+	})
+	return endpoints
+}
+
+func (r *MemoryWebhookRepository) ListEndpoints(ctx context.Context, userID string) ([]domain.WebhookEndpoint, error) {
+	defer r.db.lock(ctx)()
+	return r.endpoints(func(e domain.WebhookEndpoint) bool { return e.UserID == userID }), nil
+}
+
+func (r *MemoryWebhookRepository) ListEndpointsForEvent(ctx context.Context, userID string, eventType domain.EventType) ([]domain.WebhookEndpoint, error) {
+	defer r.db.lock(ctx)()
+	return r.endpoints(func(e domain.WebhookEndpoint) bool {
+		return e.UserID == userID && e.Enabled && (len(e.EventTypes) == 0 || slices.Contains(e.EventTypes, eventType))
+	}), nil
+}
+
+func (r *MemoryWebhookRepository) GetEndpoint(ctx context.Context, userID, endpointID string) (domain.WebhookEndpoint, error) {
+	defer r.db.lock(ctx)()
+	e, ok := r.db.data.webhookEndpoints[endpointID]
+	if !ok || e.UserID != userID {
+		return domain.WebhookEndpoint{}, domain.ErrNotFound
+	}
+	return e, nil
+}
+
+func (r *MemoryWebhookRepository) CountEndpoints(ctx context.Context, userID string) (int, error) {
+	defer r.db.lock(ctx)()
+	return len(r.endpoints(func(e domain.WebhookEndpoint) bool { return e.UserID == userID })), nil
+}
+
+// DeleteEndpoint also drops the endpoint's deliveries, as the foreign keys
+// of the SQL schemas cascade.
+func (r *MemoryWebhookRepository) DeleteEndpoint(ctx context.Context, userID, endpointID string) error {
+	defer r.db.lock(ctx)()
+	data := r.db.data
+
+	e, ok := data.webhookEndpoints[endpointID]
+	if !ok || e.UserID != userID {
+		return domain.ErrNotFound
+	}
+	delete(data.webhookEndpoints, endpointID)
+	for id, d := range data.webhookDeliveries {
+		if d.EndpointID == endpointID {
+			delete(data.webhookDeliveries, id)
+			delete(data.deliveryKeys, memoryDeliveryKey{EndpointID: d.EndpointID, EventID: d.EventID})
+		}
+	}
+	return nil
+}
+
+func (r *MemoryWebhookRepository) EnqueueDelivery(ctx context.Context, delivery domain.WebhookDelivery) error {
+	defer r.db.lock(ctx)()
+	data := r.db.data
+
+	key := memoryDeliveryKey{EndpointID: delivery.EndpointID, EventID: delivery.EventID}
+	if _, exists := data.deliveryKeys[key]; exists {
+		return nil
+	}
+	now := memoryNow()
+	id := uuid.NewString()
+	data.webhookDeliveries[id] = domain.WebhookDelivery{
+		ID:            id,
+		EndpointID:    delivery.EndpointID,
+		EventID:       delivery.EventID,
+		EventType:     delivery.EventType,
+		Payload:       delivery.Payload,
+		Status:        domain.WebhookDeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	data.deliveryKeys[key] = id
+	return nil
+}
+
+func (r *MemoryWebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	defer r.db.lock(ctx)()
+	data := r.db.data
+
+	now := memoryNow()
+	due := make([]domain.WebhookDelivery, 0)
+	for _, d := range data.webhookDeliveries {
+		if d.Status == domain.WebhookDeliveryPending && !d.NextAttemptAt.After(now) && data.webhookEndpoints[d.EndpointID].Enabled {
+			due = append(due, d)
+		}
+	}
+	sortDeliveries(due, false)
+	if limit < len(due) {
+		due = due[:limit]
+	}
+
+	for i, d := range due {
+		d.NextAttemptAt = now.Add(lease)
+		d.Attempts++
+		data.webhookDeliveries[d.ID] = d
+
+		endpoint := data.webhookEndpoints[d.EndpointID]
+		d.Endpoint = domain.WebhookEndpoint{ID: endpoint.ID, UserID: endpoint.UserID, URL: endpoint.URL, SecretEnc: endpoint.SecretEnc}
+		d.AttemptLog = nil
+		due[i] = d
+	}
+	return due, nil
+}
+
+// sortDeliveries orders deliveries by creation time, newest first when
+// newestFirst is set.
+func sortDeliveries(deliveries []domain.WebhookDelivery, newestFirst bool) {
+	sort.Slice(deliveries, func(i, j int) bool {
+		a, b := deliveries[i], deliveries[j]
+		if newestFirst {
+			a, b = b, a
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+}
+
+// update applies fn to the delivery with id and reports whether there is one.
+func (r *MemoryWebhookRepository) update(id string, fn func(*domain.WebhookDelivery)) bool {
+	d, ok := r.db.data.webhookDeliveries[id]
+	if !ok {
+		return false
+	}
+	fn(&d)
+	r.db.data.webhookDeliveries[id] = d
+	return true
+}
+
+func (r *MemoryWebhookRepository) RecordAttempt(ctx context.Context, deliveryID string, attempt domain.WebhookDeliveryAttempt) error {
+	defer r.db.lock(ctx)()
+	attempt.AttemptedAt = attempt.AttemptedAt.UTC()
+	r.update(deliveryID, func(d *domain.WebhookDelivery) {
+		// Clip so the append never writes into an array a snapshot shares.
+		d.AttemptLog = append(slices.Clip(d.AttemptLog), attempt)
+	})
+	return nil
+}
+
+func (r *MemoryWebhookRepository) MarkDelivered(ctx context.Context, deliveryID string) error {
+	defer r.db.lock(ctx)()
+	r.update(deliveryID, func(d *domain.WebhookDelivery) {
+		now := memoryNow()
+		d.Status = domain.WebhookDeliverySucceeded
+		d.DeliveredAt = &now
+		d.LastError = ""
+	})
+	return nil
+}
+
+func (r *MemoryWebhookRepository) MarkRetry(ctx context.Context, deliveryID string, lastError string, nextAttemptAt time.Time) error {
+	defer r.db.lock(ctx)()
+	r.update(deliveryID, func(d *domain.WebhookDelivery) {
+		d.LastError = lastError
+		d.NextAttemptAt = nextAttemptAt.UTC()
+	})
+	return nil
+}
+
+func (r *MemoryWebhookRepository) MarkDead(ctx context.Context, deliveryID string, lastError string) error {
+	defer r.db.lock(ctx)()
+	r.update(deliveryID, func(d *domain.WebhookDelivery) {
+		d.Status = domain.WebhookDeliveryDead
+		d.LastError = lastError
+	})
+	return nil
+}
+
+func (r *MemoryWebhookRepository) ListDeliveries(ctx context.Context, endpointID string, limit, offset int) ([]domain.WebhookDelivery, int, error) {
+	defer r.db.lock(ctx)()
+
+	deliveries := make([]domain.WebhookDelivery, 0)
+	for _, d := range r.db.data.webhookDeliveries {
+		if d.EndpointID == endpointID {
+			deliveries = append(deliveries, d)
+		}
+	}
+	sortDeliveries(deliveries, true)
+
+	total := len(deliveries)
+	if offset > len(deliveries) {
+		offset = len(deliveries)
+	}
+	deliveries = deliveries[offset:]
+	if limit >= 0 && limit < len(deliveries) {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, total, nil
+}
+
+func (r *MemoryWebhookRepository) Redeliver(ctx context.Context, endpointID, deliveryID string) error {
+	defer r.db.lock(ctx)()
+
+	d, ok := r.db.data.webhookDeliveries[deliveryID]
+	if !ok || d.EndpointID != endpointID {
+		return domain.ErrNotFound
+	}
+	if d.Status == domain.WebhookDeliveryPending {
+		return domain.ErrWebhookDeliveryActive
+	}
+	d.Status = domain.WebhookDeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = memoryNow()
+	d.DeliveredAt = nil
+	r.db.data.webhookDeliveries[deliveryID] = d
+	return nil
+}
+
+func (r *MemoryWebhookRepository) DeleteDeliveriesBefore(ctx context.Context, before time.Time) (int64, error) {
+	defer r.db.lock(ctx)()
+	data := r.db.data
+
+	var deleted int64
+	for id, d := range data.webhookDeliveries {
+		if d.Status != domain.WebhookDeliveryPending && d.CreatedAt.Before(before) {
+			delete(data.webhookDeliveries, id)
+			delete(data.deliveryKeys, memoryDeliveryKey{EndpointID: d.EndpointID, EventID: d.EventID})
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// mysqlConn lets the MySQL repositories write queries with the $N
+// placeholders the other repositories use; they are rewritten to ? and the
+// arguments reordered to match.
+type mysqlConn struct {
+	db dbtx
+}
+
+// mysqlFor is dbFor for the MySQL repositories.
+func mysqlFor(ctx context.Context, db *sql.DB) dbtx {
+	return mysqlConn{db: dbFor(ctx, db)}
+}
+
+func (c mysqlConn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	query, args = mysqlRebind(query, args)
+	return c.db.ExecContext(ctx, query, args...)
+}
+
+func (c mysqlConn) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	query, args = mysqlRebind(query, args)
+	return c.db.QueryContext(ctx, query, args...)
+}
+
+func (c mysqlConn) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	query, args = mysqlRebind(query, args)
+	return c.db.QueryRowContext(ctx, query, args...)
+}
+
+// PrepareContext requires the placeholders to appear in order, once each,
+// since the arguments only arrive when the statement is executed.
+func (c mysqlConn) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	query, _ = mysqlRebind(query, nil)
+	return c.db.PrepareContext(ctx, query)
+}
+
+// mysqlRebind replaces $N placeholders outside string literals with ? and
+// returns the arguments in the order the ?s consume them.
+func mysqlRebind(query string, args []any) (string, []any) {
+	if !strings.Contains(query, "$") {
+		return query, args
+	}
+
+	var (
+		b       strings.Builder
+		out     = make([]any, 0, len(args))
+		inQuote bool
+	)
+	b.Grow(len(query))
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case inQuote && ch == '\\' && i+1 < len(query):
+			b.WriteByte(ch)
+			i++
+			b.WriteByte(query[i])
+			continue
+		case ch == '\'':
+			inQuote = !inQuote
+		case !inQuote && ch == '$':
+			j := i + 1
+			for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+				j++
+			}
+			if j > i+1 {
+				n, _ := strconv.Atoi(query[i+1 : j])
+				if n >= 1 && n <= len(args) {
+					out = append(out, args[n-1])
+				}
+				b.WriteByte('?')
+				i = j - 1
+				continue
+			}
+		}
+		b.WriteByte(ch)
+	}
+	return b.String(), out
+}
+
+// mysqlPlaceholders lists n placeholders numbered from first, for IN lists.
+func mysqlPlaceholders(first, n int) string {
+	placeholders := make([]string, n)
+	for i := range placeholders {
+		placeholders[i] = "$" + strconv.Itoa(first+i)
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+// mysqlUUID converts an ID to the BINARY(16) the MySQL schema stores. A
+// string that is not a UUID is passed through unchanged; it matches no row
+// and a strict-mode insert rejects it.
+func mysqlUUID(id string) any {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return id
+	}
+	return parsed[:]
+}
+
+func mysqlNullUUID(id *string) any {
+	if id == nil {
+		return nil
+	}
+	return mysqlUUID(*id)
+}
+
+// mysqlUUIDValue converts a uuid.UUID argument; the type's own driver value
+// is its string form.
+func mysqlUUIDValue(id *uuid.UUID) any {
+	if id == nil {
+		return nil
+	}
+	return id[:]
+}
+
+// mysqlScanUUID reads a BINARY(16) ID into its string form.
+func mysqlScanUUID(dst *string) sql.Scanner {
+	return &mysqlUUIDScanner{dst: dst}
+}
+
+type mysqlUUIDScanner struct {
+	dst *string
+}
+
+func (s *mysqlUUIDScanner) Scan(src any) error {
+	raw, ok := src.([]byte)
+	if !ok {
+		return fmt.Errorf("scan %T into a uuid", src)
+	}
+	id, err := uuid.FromBytes(raw)
+	if err != nil {
+		return fmt.Errorf("scan uuid: %w", err)
+	}
+	*s.dst = id.String()
+	return nil
+}
+
+// mysqlScanNullUUID is mysqlScanUUID for nullable columns; NULL leaves dst
+// nil.
+func mysqlScanNullUUID(dst **string) sql.Scanner {
+	return &mysqlNullUUIDScanner{dst: dst}
+}
+
+type mysqlNullUUIDScanner struct {
+	dst **string
+}
+
+func (s *mysqlNullUUIDScanner) Scan(src any) error {
+	if src == nil {
+		*s.dst = nil
+		return nil
+	}
+	var id string
+	if err := (&mysqlUUIDScanner{dst: &id}).Scan(src); err != nil {
+		return err
+	}
+	*s.dst = &id
+	return nil
+}
+
+// mysqlJSON sends a JSON document as text, or NULL when there is none; a
+// JSON column rejects values sent as binary strings.
+func mysqlJSON(raw []byte) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
+
+// mysqlStringArgs converts values for an IN list built by
+// mysqlPlaceholders.
+func mysqlStringArgs(values []string) []any {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
@@ -0,0 +1,381 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MySQLAdminRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLAdminRepository(db *sql.DB) *MySQLAdminRepository {
+	return &MySQLAdminRepository{db: db}
+}
+
+func (r *MySQLAdminRepository) GetInstanceStats(ctx context.Context, days int) (domain.InstanceStats, error) {
+	stats := domain.InstanceStats{GeneratedAt: time.Now().UTC()}
+	monthAgo := stats.GeneratedAt.AddDate(0, 0, -30)
+
+	err := mysqlFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM users),
+			(SELECT COUNT(*) FROM users WHERE role = 'admin'),
+			(SELECT COUNT(*) FROM auth_credentials WHERE mfa_totp_enabled),
+			(SELECT COUNT(*) FROM users WHERE created_at >= $2),
+			(SELECT COUNT(*) FROM sessions WHERE revoked_at IS NULL AND expires_at > $1),
+			(SELECT COUNT(*) FROM vault_items WHERE deleted_at IS NULL),
+			(SELECT COUNT(*) FROM vault_items WHERE deleted_at IS NOT NULL),
+			(SELECT COUNT(*) FROM vault_folders),
+			(SELECT COUNT(*) FROM vault_shares),
+			(SELECT COALESCE(SUM(LENGTH(ciphertext) + LENGTH(dek_wrapped)), 0) FROM vault_items),
+			(SELECT COALESCE(SUM(LENGTH(ciphertext) + LENGTH(dek_wrapped)), 0) FROM vault_item_versions),
+			(SELECT COALESCE(SUM(size_bytes), 0) FROM vault_attachments),
+			(SELECT COALESCE(SUM(data_length + index_length), 0) FROM information_schema.tables WHERE table_schema = DATABASE())
+	`, stats.GeneratedAt, monthAgo).Scan(
+		&stats.Users, &stats.Admins, &stats.TOTPUsers, &stats.NewUsers30d, &stats.ActiveSessions,
+		&stats.VaultItems, &stats.TrashedItems, &stats.Folders, &stats.Shares,
+		&stats.Storage.VaultBytes, &stats.Storage.HistoryBytes, &stats.Storage.AttachmentBytes, &stats.Storage.DatabaseBytes,
+	)
+	if err != nil {
+		return domain.InstanceStats{}, fmt.Errorf("query instance stats: %w", err)
+	}
+
+	err = mysqlFor(ctx, r.db).QueryRowContext(ctx, activitySince+`
+		SELECT
+			COUNT(DISTINCT CASE WHEN created_at >= $2 THEN user_id END),
+			COUNT(DISTINCT CASE WHEN created_at >= $3 THEN user_id END),
+			COUNT(DISTINCT user_id)
+		FROM activity
+	`, monthAgo, stats.GeneratedAt.AddDate(0, 0, -1), stats.GeneratedAt.AddDate(0, 0, -7)).
+		Scan(&stats.ActiveUsers1d, &stats.ActiveUsers7d, &stats.ActiveUsers30d)
+	if err != nil {
+		return domain.InstanceStats{}, fmt.Errorf("query active users: %w", err)
+	}
+
+	// Sessions run in UTC, so the formatted date is the UTC day. Days
+	// without activity are filled in below.
+	today := stats.GeneratedAt.Truncate(24 * time.Hour)
+	firstDay := today.AddDate(0, 0, 1-days)
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, activitySince+`
+		SELECT DATE_FORMAT(created_at, '%Y-%m-%d') AS day, COUNT(DISTINCT user_id)
+		FROM activity
+		GROUP BY day
+	`, firstDay)
+	if err != nil {
+		return domain.InstanceStats{}, fmt.Errorf("query daily active users: %w", err)
+	}
+	defer rows.Close()
+
+	active := make(map[string]int)
+	for rows.Next() {
+		var (
+			day   string
+			users int
+		)
+		if err := rows.Scan(&day, &users); err != nil {
+			return domain.InstanceStats{}, fmt.Errorf("scan daily active users: %w", err)
+		}
+		active[day] = users
+	}
+	if err := rows.Err(); err != nil {
+		return domain.InstanceStats{}, fmt.Errorf("iterate daily active users: %w", err)
+	}
+
+	stats.DailyActive = make([]domain.DailyActiveUsers, 0, days)
+	for day := firstDay; !day.After(today); day = day.AddDate(0, 0, 1) {
+		stats.DailyActive = append(stats.DailyActive, domain.DailyActiveUsers{Date: day, Users: active[day.Format(time.DateOnly)]})
+	}
+	return stats, nil
+}
+
+// mysqlAdminUserSelect reads the columns mysqlScanAdminUser expects.
+const mysqlAdminUserSelect = `
+	SELECT
+		u.id, u.email, u.name, u.role, u.email_verified, u.created_at,
+		COALESCE(ac.mfa_totp_enabled, FALSE), ac.totp_locked_until,
+		(SELECT COUNT(*) FROM sessions s WHERE s.user_id = u.id AND s.revoked_at IS NULL AND s.expires_at > NOW(6)),
+		(SELECT COUNT(*) FROM vault_items vi WHERE vi.owner_user_id = u.id AND vi.deleted_at IS NULL),
+		(SELECT MAX(s.created_at) FROM sessions s WHERE s.user_id = u.id)
+	FROM users u
+	LEFT JOIN auth_credentials ac ON ac.user_id = u.id
+`
+
+// SearchUsers relies on backslash being MySQL's default LIKE escape.
+func (r *MySQLAdminRepository) SearchUsers(ctx context.Context, query string, limit int) ([]domain.AdminUser, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, mysqlAdminUserSelect+`
+		WHERE u.email LIKE $1
+		ORDER BY u.email
+		LIMIT $2
+	`, escapeLike(query)+"%", limit)
+	if err != nil {
+		return nil, fmt.Errorf("search users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]domain.AdminUser, 0)
+	for rows.Next() {
+		user, err := mysqlScanAdminUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (r *MySQLAdminRepository) GetUser(ctx context.Context, userID string) (domain.AdminUser, error) {
+	user, err := mysqlScanAdminUser(mysqlFor(ctx, r.db).QueryRowContext(ctx, mysqlAdminUserSelect+`WHERE u.id = $1`, mysqlUUID(userID)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.AdminUser{}, domain.ErrNotFound
+		}
+		return domain.AdminUser{}, fmt.Errorf("get user: %w", err)
+	}
+	return user, nil
+}
+
+// mysqlScanAdminUser is scanAdminUser for BINARY(16) IDs.
+func mysqlScanAdminUser(row interface{ Scan(...any) error }) (domain.AdminUser, error) {
+	var (
+		user        domain.AdminUser
+		name        sql.NullString
+		lockedUntil sql.NullTime
+		lastLogin   sql.NullTime
+	)
+	err := row.Scan(
+		mysqlScanUUID(&user.ID), &user.Email, &name, &user.Role, &user.EmailVerified, &user.CreatedAt,
+		&user.TOTPEnabled, &lockedUntil,
+		&user.ActiveSessions, &user.VaultItems, &lastLogin,
+	)
+	if err != nil {
+		return domain.AdminUser{}, err
+	}
+	user.Name = name.String
+	user.CreatedAt = user.CreatedAt.UTC()
+	if lockedUntil.Valid {
+		t := lockedUntil.Time.UTC()
+		user.TOTPLockedUntil = &t
+	}
+	if lastLogin.Valid {
+		t := lastLogin.Time.UTC()
+		user.LastLoginAt = &t
+	}
+	return user, nil
+}
+
+func (r *MySQLAdminRepository) ListActiveSessions(ctx context.Context, userID string) ([]domain.AdminSession, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT id, COALESCE(device_name, ''), COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at, expires_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW(6)
+		ORDER BY created_at DESC
+	`, mysqlUUID(userID))
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := make([]domain.AdminSession, 0)
+	for rows.Next() {
+		var s domain.AdminSession
+		if err := rows.Scan(mysqlScanUUID(&s.ID), &s.DeviceName, &s.IPAddress, &s.UserAgent, &s.CreatedAt, &s.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("scan session: %w", err)
+		}
+		s.CreatedAt = s.CreatedAt.UTC()
+		s.ExpiresAt = s.ExpiresAt.UTC()
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+func (r *MySQLAdminRepository) RevokeSession(ctx context.Context, userID, sessionID string) (bool, error) {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE sessions
+		SET revoked_at = NOW(6)
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, mysqlUUID(sessionID), mysqlUUID(userID))
+	if err != nil {
+		return false, fmt.Errorf("revoke session: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (r *MySQLAdminRepository) ListFeatureFlags(ctx context.Context) ([]domain.FeatureFlag, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, "SELECT `key`, enabled, description, updated_by_user_id, updated_at FROM feature_flags ORDER BY `key`")
+	if err != nil {
+		return nil, fmt.Errorf("list feature flags: %w", err)
+	}
+	defer rows.Close()
+
+	flags := make([]domain.FeatureFlag, 0)
+	for rows.Next() {
+		var flag domain.FeatureFlag
+		if err := rows.Scan(&flag.Key, &flag.Enabled, &flag.Description, mysqlScanNullUUID(&flag.UpdatedBy), &flag.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan feature flag: %w", err)
+		}
+		flag.UpdatedAt = flag.UpdatedAt.UTC()
+		flags = append(flags, flag)
+	}
+	return flags, rows.Err()
+}
+
+// SetFeatureFlag keeps the stored description when the new one is empty,
+// then reads the row back in the same transaction.
+func (r *MySQLAdminRepository) SetFeatureFlag(ctx context.Context, flag domain.FeatureFlag) (domain.FeatureFlag, error) {
+	sqlTx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return domain.FeatureFlag{}, fmt.Errorf("begin set feature flag tx: %w", err)
+	}
+	defer rollback()
+	tx := mysqlConn{db: sqlTx}
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO feature_flags (`key`, enabled, description, updated_by_user_id) "+`
+		VALUES ($1, $2, $3, $4)
+		ON DUPLICATE KEY UPDATE
+			enabled = VALUES(enabled),
+			description = IF(VALUES(description) = '', description, VALUES(description)),
+			updated_by_user_id = VALUES(updated_by_user_id),
+			updated_at = NOW(6)
+	`, flag.Key, flag.Enabled, flag.Description, mysqlNullUUID(flag.UpdatedBy)); err != nil {
+		return domain.FeatureFlag{}, fmt.Errorf("set feature flag: %w", err)
+	}
+
+	if err := tx.QueryRowContext(ctx, "SELECT description, updated_at FROM feature_flags WHERE `key` = $1", flag.Key).
+		Scan(&flag.Description, &flag.UpdatedAt); err != nil {
+		return domain.FeatureFlag{}, fmt.Errorf("read feature flag: %w", err)
+	}
+
+	if err := commit(); err != nil {
+		return domain.FeatureFlag{}, fmt.Errorf("commit set feature flag tx: %w", err)
+	}
+	flag.UpdatedAt = flag.UpdatedAt.UTC()
+	return flag, nil
+}
+
+func (r *MySQLAdminRepository) GetMaintenance(ctx context.Context) (domain.MaintenanceState, error) {
+	var (
+		state domain.MaintenanceState
+		since sql.NullTime
+	)
+	err := mysqlFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT enabled, message, since FROM maintenance_state WHERE id = 1
+	`).Scan(&state.Enabled, &state.Message, &since)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.MaintenanceState{}, nil
+	}
+	if err != nil {
+		return domain.MaintenanceState{}, fmt.Errorf("get maintenance state: %w", err)
+	}
+	if since.Valid {
+		t := since.Time.UTC()
+		state.Since = &t
+	}
+	return state, nil
+}
+
+func (r *MySQLAdminRepository) SaveMaintenance(ctx context.Context, state domain.MaintenanceState, updatedBy *string) error {
+	var since any
+	if state.Since != nil {
+		since = state.Since.UTC()
+	}
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO maintenance_state (id, enabled, message, since, updated_by_user_id)
+		VALUES (1, $1, $2, $3, $4)
+		ON DUPLICATE KEY UPDATE
+			enabled = VALUES(enabled),
+			message = VALUES(message),
+			since = VALUES(since),
+			updated_by_user_id = VALUES(updated_by_user_id),
+			updated_at = NOW(6)
+	`, state.Enabled, state.Message, since, mysqlNullUUID(updatedBy))
+	if err != nil {
+		return fmt.Errorf("save maintenance state: %w", err)
+	}
+	return nil
+}
+
+// mysqlUserDataChecks finds mentions in JSON columns through their text
+// form.
+var mysqlUserDataChecks = userDataChecks(func(column, token string) string {
+	return "INSTR(CAST(" + column + " AS CHAR), " + token + ") > 0"
+})
+
+func (r *MySQLAdminRepository) EraseUser(ctx context.Context, userID, email string) ([]string, error) {
+	sqlTx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("begin erase user tx: %w", err)
+	}
+	defer rollback()
+	tx := mysqlConn{db: sqlTx}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT a.storage_path
+		FROM vault_attachments a
+		JOIN vault_items i ON i.id = a.item_id
+		WHERE i.owner_user_id = $1
+	`, mysqlUUID(userID))
+	if err != nil {
+		return nil, fmt.Errorf("query attachment paths: %w", err)
+	}
+	paths, err := scanStrings(rows)
+	if err != nil {
+		return nil, fmt.Errorf("scan attachment paths: %w", err)
+	}
+
+	for _, stmt := range userErasureStatements(mysqlUserDataChecks) {
+		query, args := bindUserData(stmt, mysqlUUID(userID), userID, email)
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return nil, fmt.Errorf("erase user data: %w", err)
+		}
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, mysqlUUID(userID))
+	if err != nil {
+		return nil, fmt.Errorf("delete user: %w", err)
+	}
+	if err := requireAffected(result); err != nil {
+		return nil, err
+	}
+
+	if err := commit(); err != nil {
+		return nil, fmt.Errorf("commit erase user: %w", err)
+	}
+	return paths, nil
+}
+
+func (r *MySQLAdminRepository) CountUserData(ctx context.Context, userID, email string) (map[string]int, error) {
+	query, args := bindUserData(userDataCountQuery(mysqlUserDataChecks), mysqlUUID(userID), userID, email)
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("count user data: %w", err)
+	}
+	return scanTableCounts(rows)
+}
+
+func (r *MySQLAdminRepository) MergeAccounts(ctx context.Context, input domain.AccountMergeInput) (map[string]int64, error) {
+	sqlTx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("begin merge accounts tx: %w", err)
+	}
+	defer rollback()
+	tx := mysqlConn{db: sqlTx}
+
+	moved, err := mergeAccounts(ctx, tx, input, func(id string) any { return mysqlUUID(id) }, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if err := commit(); err != nil {
+		return nil, fmt.Errorf("commit merge accounts: %w", err)
+	}
+	return moved, nil
+}
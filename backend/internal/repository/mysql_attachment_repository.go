@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MySQLAttachmentRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLAttachmentRepository(db *sql.DB) *MySQLAttachmentRepository {
+	return &MySQLAttachmentRepository{db: db}
+}
+
+func (r *MySQLAttachmentRepository) CreateAttachment(ctx context.Context, a domain.VaultAttachment) error {
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO vault_attachments (id, item_id, file_name_ciphertext, nonce, size_bytes, storage_path, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+	`, mysqlUUID(a.ID), mysqlUUID(a.ItemID), a.FileNameCiphertext, a.Nonce, a.SizeBytes, a.StoragePath, a.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("create attachment: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLAttachmentRepository) ListAttachments(ctx context.Context, itemID, ownerUserID string) ([]domain.VaultAttachment, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM vault_attachments a
+		JOIN vault_items i ON i.id = a.item_id
+		WHERE a.item_id = $1 AND i.owner_user_id = $2
+		ORDER BY a.created_at, a.id
+	`, mysqlUUID(itemID), mysqlUUID(ownerUserID))
+	if err != nil {
+		return nil, fmt.Errorf("list attachments: %w", err)
+	}
+	return mysqlScanAttachments(rows)
+}
+
+func (r *MySQLAttachmentRepository) ListAttachmentsByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultAttachment, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM vault_attachments a
+		JOIN vault_items i ON i.id = a.item_id
+		WHERE i.owner_user_id = $1 AND i.deleted_at IS NULL
+		ORDER BY a.item_id, a.created_at, a.id
+	`, mysqlUUID(ownerUserID))
+	if err != nil {
+		return nil, fmt.Errorf("list attachments: %w", err)
+	}
+	return mysqlScanAttachments(rows)
+}
+
+func (r *MySQLAttachmentRepository) GetAttachment(ctx context.Context, itemID, ownerUserID, attachmentID string) (domain.VaultAttachment, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM vault_attachments a
+		JOIN vault_items i ON i.id = a.item_id
+		WHERE a.id = $1 AND a.item_id = $2 AND i.owner_user_id = $3
+	`, mysqlUUID(attachmentID), mysqlUUID(itemID), mysqlUUID(ownerUserID))
+	if err != nil {
+		return domain.VaultAttachment{}, fmt.Errorf("get attachment: %w", err)
+	}
+	attachments, err := mysqlScanAttachments(rows)
+	if err != nil {
+		return domain.VaultAttachment{}, err
+	}
+	if len(attachments) == 0 {
+		return domain.VaultAttachment{}, domain.ErrNotFound
+	}
+	return attachments[0], nil
+}
+
+func (r *MySQLAttachmentRepository) DeleteAttachment(ctx context.Context, itemID, ownerUserID, attachmentID string) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		DELETE a FROM vault_attachments a
+		JOIN vault_items i ON i.id = a.item_id
+		WHERE a.id = $1 AND a.item_id = $2 AND i.owner_user_id = $3
+	`, mysqlUUID(attachmentID), mysqlUUID(itemID), mysqlUUID(ownerUserID))
+	if err != nil {
+		return fmt.Errorf("delete attachment: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// DeleteTrashedAttachments reads the paths and deletes the rows in one
+// transaction, as MySQL has no DELETE ... RETURNING.
+func (r *MySQLAttachmentRepository) DeleteTrashedAttachments(ctx context.Context, deletedBefore time.Time) ([]string, error) {
+	sqlTx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("begin delete trashed attachments tx: %w", err)
+	}
+	defer rollback()
+	tx := mysqlConn{db: sqlTx}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT a.storage_path
+		FROM vault_attachments a
+		JOIN vault_items i ON i.id = a.item_id
+		WHERE i.deleted_at IS NOT NULL AND i.deleted_at < $1
+		FOR UPDATE
+	`, deletedBefore.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("query trashed attachments: %w", err)
+	}
+	paths, err := scanStrings(rows)
+	if err != nil {
+		return nil, fmt.Errorf("scan attachment paths: %w", err)
+	}
+	if len(paths) == 0 {
+		return paths, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE a FROM vault_attachments a
+		JOIN vault_items i ON i.id = a.item_id
+		WHERE i.deleted_at IS NOT NULL AND i.deleted_at < $1
+	`, deletedBefore.UTC()); err != nil {
+		return nil, fmt.Errorf("delete trashed attachments: %w", err)
+	}
+	if err := commit(); err != nil {
+		return nil, fmt.Errorf("commit delete trashed attachments: %w", err)
+	}
+	return paths, nil
+}
+
+func mysqlScanAttachments(rows *sql.Rows) ([]domain.VaultAttachment, error) {
+	defer rows.Close()
+	attachments := make([]domain.VaultAttachment, 0)
+	for rows.Next() {
+		var a domain.VaultAttachment
+		if err := rows.Scan(mysqlScanUUID(&a.ID), mysqlScanUUID(&a.ItemID), &a.FileNameCiphertext, &a.Nonce, &a.SizeBytes, &a.StoragePath, &a.CreatedAt, &a.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan attachment: %w", err)
+		}
+		attachments = append(attachments, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate attachments: %w", err)
+	}
+	return attachments, nil
+}
@@ -0,0 +1,278 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type MySQLAuditRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLAuditRepository(db *sql.DB) *MySQLAuditRepository {
+	return &MySQLAuditRepository{db: db}
+}
+
+func (r *MySQLAuditRepository) CreateEvent(ctx context.Context, event domain.AuditEvent) error {
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT IGNORE INTO audit_events (id, user_id, actor_user_id, event_type, event_data, ip_address, user_agent, created_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8)
+	`, mysqlUUIDValue(&event.ID), mysqlUUIDValue(event.UserID), mysqlUUIDValue(event.ActorUserID), event.EventType, mysqlJSON(event.EventData), event.IPAddress, event.UserAgent, event.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("insert audit event: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLAuditRepository) ListEvents(ctx context.Context, limit int, offset int, filter domain.AuditFilter) ([]domain.AuditEvent, int, error) {
+	where, args := mysqlAuditFilterClause(filter)
+	argIdx := len(args) + 1
+
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM audit_events %s", where)
+	err := mysqlFor(ctx, r.db).QueryRowContext(ctx, countQuery, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("count audit events: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, user_id, actor_user_id, event_type, event_data,
+			COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at
+		FROM audit_events
+		%s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d
+	`, where, argIdx, argIdx+1)
+
+	args = append(args, limit, offset)
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []domain.AuditEvent
+	for rows.Next() {
+		var e domain.AuditEvent
+		var data []byte
+		if err := rows.Scan(&e.ID, &e.UserID, &e.ActorUserID, &e.EventType, &data, &e.IPAddress, &e.UserAgent, &e.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("scan audit event: %w", err)
+		}
+		e.EventData = data
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("iterate audit events: %w", err)
+	}
+
+	return events, total, nil
+}
+
+func (r *MySQLAuditRepository) StreamEvents(ctx context.Context, filter domain.AuditFilter, fn func(domain.AuditEvent) error) error {
+	where, args := mysqlAuditFilterClause(filter)
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, fmt.Sprintf(`
+		SELECT id, user_id, actor_user_id, event_type, event_data,
+			COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at
+		FROM audit_events
+		%s
+		ORDER BY created_at DESC, id
+	`, where), args...)
+	if err != nil {
+		return fmt.Errorf("query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e domain.AuditEvent
+		var data []byte
+		if err := rows.Scan(&e.ID, &e.UserID, &e.ActorUserID, &e.EventType, &data, &e.IPAddress, &e.UserAgent, &e.CreatedAt); err != nil {
+			return fmt.Errorf("scan audit event: %w", err)
+		}
+		e.EventData = data
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate audit events: %w", err)
+	}
+	return nil
+}
+
+// mysqlAuditFilterClause is auditFilterClause for the MySQL schema. LIKE
+// follows the column collation, which is case-insensitive for event types;
+// JSON is compared as binary, so the search lowercases both sides.
+func mysqlAuditFilterClause(filter domain.AuditFilter) (string, []interface{}) {
+	where := "WHERE TRUE"
+	args := []interface{}{}
+	argIdx := 1
+
+	if filter.UserID != nil {
+		where += fmt.Sprintf(" AND user_id = $%d", argIdx)
+		args = append(args, mysqlUUIDValue(filter.UserID))
+		argIdx++
+	}
+
+	if filter.Category != "" {
+		where += fmt.Sprintf(" AND event_type LIKE $%d", argIdx)
+		args = append(args, filter.Category+"%")
+		argIdx++
+	}
+
+	if len(filter.EventTypes) > 0 {
+		where += fmt.Sprintf(" AND event_type IN (%s)", mysqlPlaceholders(argIdx, len(filter.EventTypes)))
+		for _, t := range filter.EventTypes {
+			args = append(args, string(t))
+		}
+		argIdx += len(filter.EventTypes)
+	}
+
+	if filter.IPAddress != "" {
+		where += fmt.Sprintf(" AND ip_address = $%d", argIdx)
+		args = append(args, filter.IPAddress)
+		argIdx++
+	}
+
+	if filter.Search != "" {
+		where += fmt.Sprintf(" AND (event_type LIKE $%d OR LOWER(CAST(event_data AS CHAR)) LIKE LOWER($%d))", argIdx, argIdx+1)
+		args = append(args, "%"+filter.Search+"%", "%"+filter.Search+"%")
+		argIdx += 2
+	}
+
+	if filter.StartDate != nil {
+		where += fmt.Sprintf(" AND created_at >= $%d", argIdx)
+		args = append(args, filter.StartDate.UTC())
+		argIdx++
+	}
+
+	if filter.EndDate != nil {
+		where += fmt.Sprintf(" AND created_at <= $%d", argIdx)
+		args = append(args, filter.EndDate.UTC())
+		argIdx++
+	}
+
+	return where, args
+}
+
+func (r *MySQLAuditRepository) ListEventsAfter(ctx context.Context, cursor domain.AuditCursor, settle time.Duration, limit int) ([]domain.AuditEvent, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT id, user_id, actor_user_id, event_type, event_data,
+			COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at, recorded_at
+		FROM audit_events
+		WHERE (recorded_at, id) > ($1, $2)
+		  AND recorded_at < $3
+		ORDER BY recorded_at, id
+		LIMIT $4
+	`, cursor.RecordedAt.UTC(), mysqlUUIDValue(&cursor.EventID), time.Now().UTC().Add(-settle), limit)
+	if err != nil {
+		return nil, fmt.Errorf("query audit events after cursor: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]domain.AuditEvent, 0)
+	for rows.Next() {
+		var e domain.AuditEvent
+		var data []byte
+		if err := rows.Scan(&e.ID, &e.UserID, &e.ActorUserID, &e.EventType, &data, &e.IPAddress, &e.UserAgent, &e.CreatedAt, &e.RecordedAt); err != nil {
+			return nil, fmt.Errorf("scan audit event: %w", err)
+		}
+		e.EventData = data
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate audit events: %w", err)
+	}
+	return events, nil
+}
+
+func (r *MySQLAuditRepository) AdvanceExportCursor(ctx context.Context, exporter string, fn func(domain.AuditCursor) (domain.AuditCursor, error)) (locked bool, err error) {
+	if _, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT IGNORE INTO audit_export_cursors (exporter, last_recorded_at, last_event_id)
+		VALUES ($1, $2, $3)
+	`, exporter, time.Unix(0, 0).UTC(), mysqlUUIDValue(&uuid.Nil)); err != nil {
+		return false, fmt.Errorf("ensure audit export cursor: %w", err)
+	}
+
+	sqlTx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin audit export tx: %w", err)
+	}
+	defer func() { _ = sqlTx.Rollback() }()
+	tx := mysqlConn{db: sqlTx}
+
+	var cursor domain.AuditCursor
+	err = tx.QueryRowContext(ctx, `
+		SELECT last_recorded_at, last_event_id FROM audit_export_cursors
+		WHERE exporter = $1
+		FOR UPDATE SKIP LOCKED
+	`, exporter).Scan(&cursor.RecordedAt, &cursor.EventID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("lock audit export cursor: %w", err)
+	}
+
+	next, err := fn(cursor)
+	if err != nil {
+		return true, err
+	}
+	if next != cursor {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE audit_export_cursors
+			SET last_recorded_at = $2, last_event_id = $3, updated_at = NOW(6)
+			WHERE exporter = $1
+		`, exporter, next.RecordedAt.UTC(), mysqlUUIDValue(&next.EventID)); err != nil {
+			return true, fmt.Errorf("save audit export cursor: %w", err)
+		}
+	}
+	if err := sqlTx.Commit(); err != nil {
+		return true, fmt.Errorf("commit audit export cursor: %w", err)
+	}
+	return true, nil
+}
+
+func (r *MySQLAuditRepository) DeleteUserLogs(ctx context.Context, userID uuid.UUID) error {
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM audit_events WHERE user_id = $1
+	`, mysqlUUIDValue(&userID))
+	if err != nil {
+		return fmt.Errorf("delete audit events: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLAuditRepository) DeleteEventsBefore(ctx context.Context, before time.Time, category string, limit int) (int64, error) {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM audit_events
+		WHERE recorded_at < $1 AND event_type LIKE $2
+		LIMIT $3
+	`, before.UTC(), category+"%", limit)
+	if err != nil {
+		return 0, fmt.Errorf("delete old audit events: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected, nil
+}
+
+// EnsurePartitions does nothing; audit events are only partitioned on
+// Postgres.
+func (r *MySQLAuditRepository) EnsurePartitions(ctx context.Context, from time.Time, months int) ([]string, error) {
+	return nil, nil
+}
+
+func (r *MySQLAuditRepository) DropPartitionsBefore(ctx context.Context, before time.Time) ([]string, error) {
+	return nil, nil
+}
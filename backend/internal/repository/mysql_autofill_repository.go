@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MySQLAutofillRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLAutofillRepository(db *sql.DB) *MySQLAutofillRepository {
+	return &MySQLAutofillRepository{db: db}
+}
+
+func (r *MySQLAutofillRepository) SetURIHMACs(ctx context.Context, ownerUserID, itemID string, hmacs [][]byte) error {
+	sqlTx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return fmt.Errorf("begin set uri hmacs tx: %w", err)
+	}
+	defer rollback()
+	tx := mysqlConn{db: sqlTx}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM vault_item_uri_hmacs WHERE item_id = $1 AND owner_user_id = $2`, mysqlUUID(itemID), mysqlUUID(ownerUserID)); err != nil {
+		return fmt.Errorf("clear uri hmacs: %w", err)
+	}
+	for _, hmac := range hmacs {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT IGNORE INTO vault_item_uri_hmacs (item_id, owner_user_id, uri_hmac)
+			VALUES ($1, $2, $3)
+		`, mysqlUUID(itemID), mysqlUUID(ownerUserID), hmac); err != nil {
+			return fmt.Errorf("insert uri hmac: %w", err)
+		}
+	}
+	if err := commit(); err != nil {
+		return fmt.Errorf("commit set uri hmacs: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLAutofillRepository) ListURIHMACs(ctx context.Context, ownerUserID string) (map[string][][]byte, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT item_id, uri_hmac FROM vault_item_uri_hmacs WHERE owner_user_id = $1
+	`, mysqlUUID(ownerUserID))
+	if err != nil {
+		return nil, fmt.Errorf("list uri hmacs: %w", err)
+	}
+	defer rows.Close()
+	hmacs := make(map[string][][]byte)
+	for rows.Next() {
+		var itemID string
+		var hmac []byte
+		if err := rows.Scan(mysqlScanUUID(&itemID), &hmac); err != nil {
+			return nil, fmt.Errorf("scan uri hmac: %w", err)
+		}
+		hmacs[itemID] = append(hmacs[itemID], hmac)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate uri hmacs: %w", err)
+	}
+	return hmacs, nil
+}
+
+func (r *MySQLAutofillRepository) MatchURIHMACs(ctx context.Context, ownerUserID string, hmacs [][]byte) ([]string, error) {
+	if len(hmacs) == 0 {
+		return []string{}, nil
+	}
+	args := []any{mysqlUUID(ownerUserID)}
+	for _, hmac := range hmacs {
+		args = append(args, hmac)
+	}
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT DISTINCT h.item_id
+		FROM vault_item_uri_hmacs h
+		JOIN vault_items vi ON vi.id = h.item_id
+		WHERE h.owner_user_id = $1 AND h.uri_hmac IN (`+mysqlPlaceholders(2, len(hmacs))+`) AND vi.deleted_at IS NULL
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("match uri hmacs: %w", err)
+	}
+	defer rows.Close()
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(mysqlScanUUID(&id)); err != nil {
+			return nil, fmt.Errorf("scan item id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate item ids: %w", err)
+	}
+	return ids, nil
+}
+
+// RecordItemUse reads the counter back in the same transaction, as MySQL
+// has no RETURNING.
+func (r *MySQLAutofillRepository) RecordItemUse(ctx context.Context, ownerUserID, itemID string, usedAt time.Time) (domain.ItemUsage, error) {
+	sqlTx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return domain.ItemUsage{}, fmt.Errorf("begin record item use tx: %w", err)
+	}
+	defer rollback()
+	tx := mysqlConn{db: sqlTx}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO vault_item_usage (item_id, owner_user_id, use_count, last_used_at)
+		SELECT id, owner_user_id, 1, $3 FROM vault_items
+		WHERE id = $1 AND owner_user_id = $2 AND deleted_at IS NULL
+		ON DUPLICATE KEY UPDATE use_count = use_count + 1, last_used_at = VALUES(last_used_at)
+	`, mysqlUUID(itemID), mysqlUUID(ownerUserID), usedAt.UTC())
+	if err != nil {
+		return domain.ItemUsage{}, fmt.Errorf("record item use: %w", err)
+	}
+	if err := requireAffected(result); err != nil {
+		return domain.ItemUsage{}, err
+	}
+
+	usage := domain.ItemUsage{ItemID: itemID}
+	var lastUsed time.Time
+	if err := tx.QueryRowContext(ctx, `
+		SELECT use_count, last_used_at FROM vault_item_usage WHERE item_id = $1
+	`, mysqlUUID(itemID)).Scan(&usage.UseCount, &lastUsed); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ItemUsage{}, domain.ErrNotFound
+		}
+		return domain.ItemUsage{}, fmt.Errorf("read item usage: %w", err)
+	}
+	if err := commit(); err != nil {
+		return domain.ItemUsage{}, fmt.Errorf("commit record item use: %w", err)
+	}
+	lastUsed = lastUsed.UTC()
+	usage.LastUsedAt = &lastUsed
+	return usage, nil
+}
+
+func (r *MySQLAutofillRepository) ListItemUsage(ctx context.Context, ownerUserID string) (map[string]domain.ItemUsage, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT item_id, use_count, last_used_at FROM vault_item_usage WHERE owner_user_id = $1
+	`, mysqlUUID(ownerUserID))
+	if err != nil {
+		return nil, fmt.Errorf("list item usage: %w", err)
+	}
+	defer rows.Close()
+	usage := make(map[string]domain.ItemUsage)
+	for rows.Next() {
+		var u domain.ItemUsage
+		var lastUsed time.Time
+		if err := rows.Scan(mysqlScanUUID(&u.ItemID), &u.UseCount, &lastUsed); err != nil {
+			return nil, fmt.Errorf("scan item usage: %w", err)
+		}
+		lastUsed = lastUsed.UTC()
+		u.LastUsedAt = &lastUsed
+		usage[u.ItemID] = u
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate item usage: %w", err)
+	}
+	return usage, nil
+}
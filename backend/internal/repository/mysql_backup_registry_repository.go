@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MySQLBackupRegistryRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLBackupRegistryRepository(db *sql.DB) *MySQLBackupRegistryRepository {
+	return &MySQLBackupRegistryRepository{db: db}
+}
+
+func (r *MySQLBackupRegistryRepository) RegisterBackup(ctx context.Context, record domain.BackupRecord) error {
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO backups_registry (id, created_by_user_id, storage_uri, checksum_sha256, encrypted)
+		VALUES ($1, $2, $3, $4, $5)
+	`, mysqlUUID(record.ID), mysqlUUID(record.CreatedByUserID), record.StorageURI, record.ChecksumSHA256, record.Encrypted)
+	if err != nil {
+		return fmt.Errorf("register backup: %w", err)
+	}
+	return nil
+}
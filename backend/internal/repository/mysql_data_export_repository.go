@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MySQLDataExportRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLDataExportRepository(db *sql.DB) *MySQLDataExportRepository {
+	return &MySQLDataExportRepository{db: db}
+}
+
+func (r *MySQLDataExportRepository) CreateDataExport(ctx context.Context, export domain.DataExport) (domain.DataExport, error) {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO data_exports (id, user_id, status, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, mysqlUUID(export.ID), mysqlUUID(export.UserID), domain.DataExportPending, now)
+	if err != nil {
+		return domain.DataExport{}, fmt.Errorf("insert data export: %w", err)
+	}
+	export.Status = domain.DataExportPending
+	export.CreatedAt = now
+	return export, nil
+}
+
+func (r *MySQLDataExportRepository) GetDataExport(ctx context.Context, userID, exportID string) (domain.DataExport, error) {
+	return r.queryDataExport(ctx, `
+		SELECT `+dataExportColumns+`
+		FROM data_exports
+		WHERE id = $1 AND user_id = $2
+	`, mysqlUUID(exportID), mysqlUUID(userID))
+}
+
+func (r *MySQLDataExportRepository) FindPendingDataExport(ctx context.Context, userID string) (domain.DataExport, error) {
+	return r.queryDataExport(ctx, `
+		SELECT `+dataExportColumns+`
+		FROM data_exports
+		WHERE user_id = $1 AND status = 'pending'
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, mysqlUUID(userID))
+}
+
+func (r *MySQLDataExportRepository) queryDataExport(ctx context.Context, query string, args ...any) (domain.DataExport, error) {
+	var e domain.DataExport
+	var completedAt, expiresAt sql.NullTime
+	err := mysqlFor(ctx, r.db).QueryRowContext(ctx, query, args...).Scan(mysqlScanUUID(&e.ID), mysqlScanUUID(&e.UserID), &e.Status, &e.SizeBytes, &e.CreatedAt, &completedAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.DataExport{}, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.DataExport{}, fmt.Errorf("query data export: %w", err)
+	}
+	if completedAt.Valid {
+		e.CompletedAt = &completedAt.Time
+	}
+	if expiresAt.Valid {
+		e.ExpiresAt = &expiresAt.Time
+	}
+	return e, nil
+}
+
+func (r *MySQLDataExportRepository) GetDataExportArchive(ctx context.Context, userID, exportID string) ([]byte, error) {
+	var archive []byte
+	err := mysqlFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT archive FROM data_exports
+		WHERE id = $1 AND user_id = $2 AND status = 'ready'
+	`, mysqlUUID(exportID), mysqlUUID(userID)).Scan(&archive)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query data export archive: %w", err)
+	}
+	return archive, nil
+}
+
+func (r *MySQLDataExportRepository) CompleteDataExport(ctx context.Context, exportID string, archive []byte, completedAt, expiresAt time.Time) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE data_exports
+		SET status = 'ready', archive = $2, size_bytes = $3, completed_at = $4, expires_at = $5
+		WHERE id = $1
+	`, mysqlUUID(exportID), archive, len(archive), completedAt, expiresAt)
+	if err != nil {
+		return fmt.Errorf("complete data export: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *MySQLDataExportRepository) DeleteExpiredDataExports(ctx context.Context, before time.Time) (int64, error) {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM data_exports WHERE status = 'ready' AND expires_at < $1
+	`, before)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired data exports: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected, nil
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MySQLDeviceRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLDeviceRepository(db *sql.DB) *MySQLDeviceRepository {
+	return &MySQLDeviceRepository{db: db}
+}
+
+// TouchDevice reads the row back in the same transaction; MySQL has no
+// RETURNING. The upsert affects one row when it inserts and two when it
+// updates.
+func (r *MySQLDeviceRepository) TouchDevice(ctx context.Context, userID string, keyHash []byte, fingerprinted bool, label string) (domain.Device, bool, error) {
+	sqlTx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return domain.Device{}, false, fmt.Errorf("begin touch device tx: %w", err)
+	}
+	defer rollback()
+	tx := mysqlConn{db: sqlTx}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO user_devices (id, user_id, key_hash, fingerprinted, label)
+		VALUES ($1, $2, $3, $4, $5)
+		ON DUPLICATE KEY UPDATE
+			label = VALUES(label),
+			last_seen_at = NOW(6)
+	`, mysqlUUID(uuid.NewString()), mysqlUUID(userID), keyHash, fingerprinted, label)
+	if err != nil {
+		return domain.Device{}, false, fmt.Errorf("touch device: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return domain.Device{}, false, fmt.Errorf("read rows affected: %w", err)
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+deviceColumns+` FROM user_devices
+		WHERE user_id = $1 AND key_hash = $2
+	`, mysqlUUID(userID), keyHash)
+	if err != nil {
+		return domain.Device{}, false, fmt.Errorf("read device: %w", err)
+	}
+	devices, err := mysqlScanDevices(rows)
+	if err != nil {
+		return domain.Device{}, false, err
+	}
+	if len(devices) == 0 {
+		return domain.Device{}, false, domain.ErrNotFound
+	}
+	if err := commit(); err != nil {
+		return domain.Device{}, false, fmt.Errorf("commit touch device: %w", err)
+	}
+	return devices[0], affected == 1, nil
+}
+
+func (r *MySQLDeviceRepository) ListDevices(ctx context.Context, userID string) ([]domain.Device, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+deviceColumns+` FROM user_devices
+		WHERE user_id = $1
+		ORDER BY last_seen_at DESC
+	`, mysqlUUID(userID))
+	if err != nil {
+		return nil, fmt.Errorf("list devices: %w", err)
+	}
+	return mysqlScanDevices(rows)
+}
+
+func (r *MySQLDeviceRepository) DeleteDevice(ctx context.Context, userID, deviceID string) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `DELETE FROM user_devices WHERE id = $1 AND user_id = $2`, mysqlUUID(deviceID), mysqlUUID(userID))
+	if err != nil {
+		return fmt.Errorf("delete device: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func mysqlScanDevices(rows *sql.Rows) ([]domain.Device, error) {
+	defer rows.Close()
+	devices := make([]domain.Device, 0)
+	for rows.Next() {
+		var d domain.Device
+		if err := rows.Scan(mysqlScanUUID(&d.ID), mysqlScanUUID(&d.UserID), &d.Fingerprinted, &d.Label, &d.FirstSeenAt, &d.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("scan device: %w", err)
+		}
+		devices = append(devices, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate devices: %w", err)
+	}
+	return devices, nil
+}
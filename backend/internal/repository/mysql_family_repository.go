@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MySQLFamilyRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLFamilyRepository(db *sql.DB) *MySQLFamilyRepository {
+	return &MySQLFamilyRepository{db: db}
+}
+
+// CreateRequest inserts a pending family relationship.
+// We store a single row with user_id = initiator, friend_id = recipient.
+func (r *MySQLFamilyRepository) CreateRequest(ctx context.Context, userID, friendID string) error {
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO family_memberships (user_id, friend_id, status, initiated_by)
+		VALUES ($1, $2, 'pending', $1)
+	`, mysqlUUID(userID), mysqlUUID(friendID))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.ErrFamilyRequestAlreadySent
+		}
+		return fmt.Errorf("create family request: %w", err)
+	}
+	return nil
+}
+
+// AcceptRequest changes status from 'pending' to 'accepted'.
+// The accepting user is always the friend_id (recipient).
+func (r *MySQLFamilyRepository) AcceptRequest(ctx context.Context, userID, friendID string) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE family_memberships
+		SET status = 'accepted', updated_at = NOW(6)
+		WHERE user_id = $2 AND friend_id = $1 AND status = 'pending'
+	`, mysqlUUID(userID), mysqlUUID(friendID))
+	if err != nil {
+		return fmt.Errorf("accept family request: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("read rows affected: %w", err)
+	}
+	if affected == 0 {
+		return domain.ErrFamilyRequestNotFound
+	}
+	return nil
+}
+
+// DeleteMembership removes the family relationship in either direction.
+func (r *MySQLFamilyRepository) DeleteMembership(ctx context.Context, userID, friendID string) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM family_memberships
+		WHERE (user_id = $1 AND friend_id = $2)
+		   OR (user_id = $2 AND friend_id = $1)
+	`, mysqlUUID(userID), mysqlUUID(friendID))
+	if err != nil {
+		return fmt.Errorf("delete family membership: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("read rows affected: %w", err)
+	}
+	if affected == 0 {
+		return domain.ErrFamilyRequestNotFound
+	}
+	return nil
+}
+
+// ListMembers returns accepted family members for a user (from both directions).
+func (r *MySQLFamilyRepository) ListMembers(ctx context.Context, userID string) ([]domain.FamilyMember, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT u.id, u.email, COALESCE(u.name, ''), fm.status, fm.created_at
+		FROM family_memberships fm
+		JOIN users u ON u.id = CASE
+			WHEN fm.user_id = $1 THEN fm.friend_id
+			ELSE fm.user_id
+		END
+		WHERE (fm.user_id = $1 OR fm.friend_id = $1)
+		  AND fm.status = 'accepted'
+		ORDER BY fm.created_at DESC
+	`, mysqlUUID(userID))
+	if err != nil {
+		return nil, fmt.Errorf("list family members: %w", err)
+	}
+	defer rows.Close()
+
+	members := make([]domain.FamilyMember, 0)
+	for rows.Next() {
+		var m domain.FamilyMember
+		err := rows.Scan(mysqlScanUUID(&m.UserID), &m.Email, &m.Name, &m.Status, &m.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("scan family member: %w", err)
+		}
+		members = append(members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate family members: %w", err)
+	}
+	return members, nil
+}
+
+// ListPendingReceived lists pending requests where this user is the recipient (friend_id).
+func (r *MySQLFamilyRepository) ListPendingReceived(ctx context.Context, userID string) ([]domain.FamilyRequest, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT u.id, u.email, COALESCE(u.name, ''), fm.initiated_by, fm.created_at
+		FROM family_memberships fm
+		JOIN users u ON u.id = fm.user_id
+		WHERE fm.friend_id = $1 AND fm.status = 'pending'
+		ORDER BY fm.created_at DESC
+	`, mysqlUUID(userID))
+	if err != nil {
+		return nil, fmt.Errorf("list pending received: %w", err)
+	}
+	defer rows.Close()
+
+	reqs := make([]domain.FamilyRequest, 0)
+	for rows.Next() {
+		var req domain.FamilyRequest
+		err := rows.Scan(mysqlScanUUID(&req.UserID), &req.Email, &req.Name, mysqlScanUUID(&req.InitiatedBy), &req.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("scan family request: %w", err)
+		}
+		reqs = append(reqs, req)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pending received: %w", err)
+	}
+	return reqs, nil
+}
+
+// ListPendingSent lists pending requests sent BY this user.
+func (r *MySQLFamilyRepository) ListPendingSent(ctx context.Context, userID string) ([]domain.FamilyRequest, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT u.id, u.email, COALESCE(u.name, ''), fm.initiated_by, fm.created_at
+		FROM family_memberships fm
+		JOIN users u ON u.id = fm.friend_id
+		WHERE fm.user_id = $1 AND fm.status = 'pending'
+		ORDER BY fm.created_at DESC
+	`, mysqlUUID(userID))
+	if err != nil {
+		return nil, fmt.Errorf("list pending sent: %w", err)
+	}
+	defer rows.Close()
+
+	reqs := make([]domain.FamilyRequest, 0)
+	for rows.Next() {
+		var req domain.FamilyRequest
+		err := rows.Scan(mysqlScanUUID(&req.UserID), &req.Email, &req.Name, mysqlScanUUID(&req.InitiatedBy), &req.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("scan sent request: %w", err)
+		}
+		reqs = append(reqs, req)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pending sent: %w", err)
+	}
+	return reqs, nil
+}
+
+// IsFamilyMember checks if two users have an accepted family relationship.
+func (r *MySQLFamilyRepository) IsFamilyMember(ctx context.Context, userID, friendID string) (bool, error) {
+	var count int
+	err := mysqlFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM family_memberships
+		WHERE ((user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1))
+		  AND status = 'accepted'
+	`, mysqlUUID(userID), mysqlUUID(friendID)).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check family membership: %w", err)
+	}
+	return count > 0, nil
+}
+
+// GetMembership returns the record between two users (any direction).
+func (r *MySQLFamilyRepository) GetMembership(ctx context.Context, userID, friendID string) (domain.FamilyMembership, error) {
+	var m domain.FamilyMembership
+	err := mysqlFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT user_id, friend_id, status, initiated_by, created_at, updated_at
+		FROM family_memberships
+		WHERE (user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1)
+	`, mysqlUUID(userID), mysqlUUID(friendID)).Scan(
+		mysqlScanUUID(&m.UserID), mysqlScanUUID(&m.FriendID), &m.Status, mysqlScanUUID(&m.InitiatedBy), &m.CreatedAt, &m.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.FamilyMembership{}, domain.ErrFamilyRequestNotFound
+		}
+		return domain.FamilyMembership{}, fmt.Errorf("get family membership: %w", err)
+	}
+	return m, nil
+}
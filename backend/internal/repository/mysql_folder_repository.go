@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/util"
+)
+
+type MySQLFolderRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLFolderRepository(db *sql.DB) *MySQLFolderRepository {
+	return &MySQLFolderRepository{db: db}
+}
+
+func (r *MySQLFolderRepository) CreateFolder(ctx context.Context, input domain.CreateVaultFolderInput) (domain.VaultFolder, error) {
+	folderID, err := util.NewUUID()
+	if err != nil {
+		return domain.VaultFolder{}, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	folder := domain.VaultFolder{
+		ID:             folderID,
+		OwnerUserID:    input.OwnerUserID,
+		NameCiphertext: input.NameCiphertext,
+		Nonce:          input.Nonce,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	query := `
+		INSERT INTO vault_folders (id, owner_user_id, name_ciphertext, nonce, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+	`
+	_, err = mysqlFor(ctx, r.db).ExecContext(ctx, query, mysqlUUID(folder.ID), mysqlUUID(folder.OwnerUserID), folder.NameCiphertext, folder.Nonce, now)
+	if err != nil {
+		return domain.VaultFolder{}, err
+	}
+
+	return folder, nil
+}
+
+func (r *MySQLFolderRepository) ListFoldersByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultFolder, error) {
+	query := `
+		SELECT id, owner_user_id, name_ciphertext, nonce, created_at, updated_at
+		FROM vault_folders
+		WHERE owner_user_id = $1
+		ORDER BY created_at DESC
+	`
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, query, mysqlUUID(ownerUserID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var folders []domain.VaultFolder
+	for rows.Next() {
+		var f domain.VaultFolder
+		if err := rows.Scan(mysqlScanUUID(&f.ID), mysqlScanUUID(&f.OwnerUserID), &f.NameCiphertext, &f.Nonce, &f.CreatedAt, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+		folders = append(folders, f)
+	}
+
+	return folders, rows.Err()
+}
+
+func (r *MySQLFolderRepository) GetFolderByIDForOwner(ctx context.Context, folderID string, ownerUserID string) (domain.VaultFolder, error) {
+	return r.getFolder(ctx, mysqlFor(ctx, r.db), folderID, ownerUserID)
+}
+
+func (r *MySQLFolderRepository) getFolder(ctx context.Context, db dbtx, folderID string, ownerUserID string) (domain.VaultFolder, error) {
+	query := `
+		SELECT id, owner_user_id, name_ciphertext, nonce, created_at, updated_at
+		FROM vault_folders
+		WHERE id = $1 AND owner_user_id = $2
+	`
+	var f domain.VaultFolder
+	err := db.QueryRowContext(ctx, query, mysqlUUID(folderID), mysqlUUID(ownerUserID)).Scan(mysqlScanUUID(&f.ID), mysqlScanUUID(&f.OwnerUserID), &f.NameCiphertext, &f.Nonce, &f.CreatedAt, &f.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.VaultFolder{}, domain.ErrNotFound
+		}
+		return domain.VaultFolder{}, err
+	}
+
+	return f, nil
+}
+
+// UpdateFolderForOwner re-reads the folder in the same transaction, since
+// MySQL has no UPDATE ... RETURNING.
+func (r *MySQLFolderRepository) UpdateFolderForOwner(ctx context.Context, folderID string, ownerUserID string, nameCiphertext []byte, nonce []byte) (domain.VaultFolder, error) {
+	sqlTx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return domain.VaultFolder{}, err
+	}
+	defer rollback()
+	tx := mysqlConn{db: sqlTx}
+
+	query := `
+		UPDATE vault_folders
+		SET name_ciphertext = $1, nonce = $2, updated_at = NOW(6)
+		WHERE id = $3 AND owner_user_id = $4
+	`
+	res, err := tx.ExecContext(ctx, query, nameCiphertext, nonce, mysqlUUID(folderID), mysqlUUID(ownerUserID))
+	if err != nil {
+		return domain.VaultFolder{}, err
+	}
+	if err := requireAffected(res); err != nil {
+		return domain.VaultFolder{}, err
+	}
+
+	f, err := r.getFolder(ctx, tx, folderID, ownerUserID)
+	if err != nil {
+		return domain.VaultFolder{}, err
+	}
+
+	return f, commit()
+}
+
+func (r *MySQLFolderRepository) DeleteFolderForOwner(ctx context.Context, folderID string, ownerUserID string) (bool, error) {
+	query := `DELETE FROM vault_folders WHERE id = $1 AND owner_user_id = $2`
+	res, err := mysqlFor(ctx, r.db).ExecContext(ctx, query, mysqlUUID(folderID), mysqlUUID(ownerUserID))
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
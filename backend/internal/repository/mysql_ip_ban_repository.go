@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MySQLIPBanRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLIPBanRepository(db *sql.DB) *MySQLIPBanRepository {
+	return &MySQLIPBanRepository{db: db}
+}
+
+func (r *MySQLIPBanRepository) GetIPBan(ctx context.Context, ip string) (domain.IPBan, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `SELECT `+ipBanColumns+` FROM ip_bans WHERE ip = $1`, ip)
+	if err != nil {
+		return domain.IPBan{}, fmt.Errorf("query ip ban: %w", err)
+	}
+	bans, err := scanIPBans(rows)
+	if err != nil {
+		return domain.IPBan{}, err
+	}
+	if len(bans) == 0 {
+		return domain.IPBan{}, domain.ErrNotFound
+	}
+	return bans[0], nil
+}
+
+// SaveIPBan reads the row back in the same transaction; MySQL has no
+// RETURNING.
+func (r *MySQLIPBanRepository) SaveIPBan(ctx context.Context, ban domain.IPBan) (domain.IPBan, error) {
+	sqlTx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return domain.IPBan{}, fmt.Errorf("begin save ip ban tx: %w", err)
+	}
+	defer rollback()
+	tx := mysqlConn{db: sqlTx}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO ip_bans (ip, offenses, reason, banned_until)
+		VALUES ($1, $2, $3, $4)
+		ON DUPLICATE KEY UPDATE
+			offenses = VALUES(offenses),
+			reason = VALUES(reason),
+			banned_until = VALUES(banned_until),
+			updated_at = NOW(6)
+	`, ban.IP, ban.Offenses, ban.Reason, ban.BannedUntil.UTC()); err != nil {
+		return domain.IPBan{}, fmt.Errorf("save ip ban: %w", err)
+	}
+	if err := tx.QueryRowContext(ctx, `SELECT created_at, updated_at FROM ip_bans WHERE ip = $1`, ban.IP).
+		Scan(&ban.CreatedAt, &ban.UpdatedAt); err != nil {
+		return domain.IPBan{}, fmt.Errorf("read ip ban: %w", err)
+	}
+	if err := commit(); err != nil {
+		return domain.IPBan{}, fmt.Errorf("commit save ip ban: %w", err)
+	}
+	return ban, nil
+}
+
+func (r *MySQLIPBanRepository) ListActiveIPBans(ctx context.Context, now time.Time) ([]domain.IPBan, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+ipBanColumns+` FROM ip_bans
+		WHERE banned_until > $1
+		ORDER BY banned_until DESC
+	`, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("list ip bans: %w", err)
+	}
+	return scanIPBans(rows)
+}
+
+func (r *MySQLIPBanRepository) DeleteIPBan(ctx context.Context, ip string) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `DELETE FROM ip_bans WHERE ip = $1`, ip)
+	if err != nil {
+		return fmt.Errorf("delete ip ban: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *MySQLIPBanRepository) DeleteIPBansEndedBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `DELETE FROM ip_bans WHERE banned_until < $1`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete old ip bans: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected, nil
+}
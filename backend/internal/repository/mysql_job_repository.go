@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type MySQLJobRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLJobRepository(db *sql.DB) *MySQLJobRepository {
+	return &MySQLJobRepository{db: db}
+}
+
+// TryLock takes a named lock with GET_LOCK. Named locks belong to the
+// session, so the lock pins a connection until it is released; the server
+// drops it if the connection dies mid-run.
+func (r *MySQLJobRepository) TryLock(ctx context.Context, jobName string) (func(), bool, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("open job lock conn: %w", err)
+	}
+
+	name := "pmv2:job:" + jobName
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, 0)`, name).Scan(&acquired); err != nil {
+		_ = conn.Close()
+		return nil, false, fmt.Errorf("try named lock: %w", err)
+	}
+	if acquired.Int64 != 1 {
+		_ = conn.Close()
+		return nil, false, nil
+	}
+
+	return func() {
+		_, _ = conn.ExecContext(context.Background(), `SELECT RELEASE_LOCK(?)`, name)
+		_ = conn.Close()
+	}, true, nil
+}
+
+func (r *MySQLJobRepository) LastRunStartedAt(ctx context.Context, jobName string) (time.Time, error) {
+	var startedAt sql.NullTime
+	err := mysqlFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT max(started_at) FROM job_runs WHERE job_name = $1
+	`, jobName).Scan(&startedAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("query last job run: %w", err)
+	}
+	return startedAt.Time, nil
+}
+
+func (r *MySQLJobRepository) StartRun(ctx context.Context, jobName string, startedAt time.Time) (string, error) {
+	id := uuid.NewString()
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO job_runs (id, job_name, status, started_at)
+		VALUES ($1, $2, $3, $4)
+	`, mysqlUUID(id), jobName, domain.JobRunStatusRunning, startedAt.UTC())
+	if err != nil {
+		return "", fmt.Errorf("insert job run: %w", err)
+	}
+	return id, nil
+}
+
+func (r *MySQLJobRepository) FinishRun(ctx context.Context, runID string, status domain.JobRunStatus, errMessage string, finishedAt time.Time) error {
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE job_runs
+		SET status = $2, error = NULLIF($3, ''), finished_at = $4
+		WHERE id = $1
+	`, mysqlUUID(runID), status, errMessage, finishedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("update job run: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLJobRepository) DeleteRunsBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM job_runs WHERE started_at < $1
+	`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete job runs: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected, nil
+}
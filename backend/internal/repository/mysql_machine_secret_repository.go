@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MySQLMachineSecretRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLMachineSecretRepository(db *sql.DB) *MySQLMachineSecretRepository {
+	return &MySQLMachineSecretRepository{db: db}
+}
+
+// UpsertMachineSecret updates the item's row or inserts one, then reads it
+// back, in one transaction. ON DUPLICATE KEY UPDATE would also fire on the
+// name key and overwrite another item's secret.
+func (r *MySQLMachineSecretRepository) UpsertMachineSecret(ctx context.Context, s domain.MachineSecret) (domain.MachineSecret, error) {
+	sqlTx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return domain.MachineSecret{}, fmt.Errorf("begin upsert machine secret tx: %w", err)
+	}
+	defer rollback()
+	tx := mysqlConn{db: sqlTx}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE machine_secrets
+		SET name = $3, ciphertext = $4, nonce = $5, algo_version = $6, updated_at = NOW(6)
+		WHERE item_id = $1 AND owner_user_id = $2
+	`, mysqlUUID(s.ItemID), mysqlUUID(s.OwnerUserID), s.Name, s.Ciphertext, s.Nonce, s.AlgoVersion)
+	if err == nil {
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO machine_secrets (id, owner_user_id, item_id, name, ciphertext, nonce, algo_version)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+			`, mysqlUUID(uuid.NewString()), mysqlUUID(s.OwnerUserID), mysqlUUID(s.ItemID), s.Name, s.Ciphertext, s.Nonce, s.AlgoVersion)
+		}
+	}
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.MachineSecret{}, domain.ErrMachineSecretNameTaken
+		}
+		return domain.MachineSecret{}, fmt.Errorf("upsert machine secret: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT `+machineSecretColumns+` FROM machine_secrets WHERE item_id = $1`, mysqlUUID(s.ItemID))
+	if err != nil {
+		return domain.MachineSecret{}, fmt.Errorf("read machine secret: %w", err)
+	}
+	secrets, err := mysqlScanMachineSecrets(rows)
+	if err != nil {
+		return domain.MachineSecret{}, err
+	}
+	if len(secrets) == 0 {
+		return domain.MachineSecret{}, domain.ErrNotFound
+	}
+	if err := commit(); err != nil {
+		return domain.MachineSecret{}, fmt.Errorf("commit upsert machine secret: %w", err)
+	}
+	return secrets[0], nil
+}
+
+func (r *MySQLMachineSecretRepository) ListMachineSecrets(ctx context.Context, ownerUserID string) ([]domain.MachineSecret, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+machineSecretColumns+` FROM machine_secrets
+		WHERE owner_user_id = $1
+		ORDER BY name
+	`, mysqlUUID(ownerUserID))
+	if err != nil {
+		return nil, fmt.Errorf("list machine secrets: %w", err)
+	}
+	return mysqlScanMachineSecrets(rows)
+}
+
+func (r *MySQLMachineSecretRepository) GetMachineSecretByName(ctx context.Context, ownerUserID, name string) (domain.MachineSecret, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+machineSecretColumns+` FROM machine_secrets
+		WHERE owner_user_id = $1 AND name = $2
+	`, mysqlUUID(ownerUserID), name)
+	if err != nil {
+		return domain.MachineSecret{}, fmt.Errorf("get machine secret: %w", err)
+	}
+	secrets, err := mysqlScanMachineSecrets(rows)
+	if err != nil {
+		return domain.MachineSecret{}, err
+	}
+	if len(secrets) == 0 {
+		return domain.MachineSecret{}, domain.ErrNotFound
+	}
+	return secrets[0], nil
+}
+
+func (r *MySQLMachineSecretRepository) DeleteMachineSecret(ctx context.Context, ownerUserID, itemID string) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `DELETE FROM machine_secrets WHERE item_id = $1 AND owner_user_id = $2`, mysqlUUID(itemID), mysqlUUID(ownerUserID))
+	if err != nil {
+		return fmt.Errorf("delete machine secret: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *MySQLMachineSecretRepository) CreateMachineKey(ctx context.Context, key domain.MachineKey) (domain.MachineKey, error) {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return domain.MachineKey{}, fmt.Errorf("encode machine key scopes: %w", err)
+	}
+	var expiresAt any
+	if key.ExpiresAt != nil {
+		expiresAt = key.ExpiresAt.UTC()
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	_, err = mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO machine_keys (id, owner_user_id, name, key_hash, prefix, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, mysqlUUID(key.ID), mysqlUUID(key.OwnerUserID), key.Name, key.KeyHash, key.Prefix, string(scopes), expiresAt, now)
+	if err != nil {
+		return domain.MachineKey{}, fmt.Errorf("insert machine key: %w", err)
+	}
+	key.CreatedAt = now
+	return key, nil
+}
+
+func (r *MySQLMachineSecretRepository) ListMachineKeys(ctx context.Context, ownerUserID string) ([]domain.MachineKey, error) {
+	return r.queryMachineKeys(ctx, `
+		SELECT `+machineKeyColumns+` FROM machine_keys
+		WHERE owner_user_id = $1
+		ORDER BY created_at
+	`, mysqlUUID(ownerUserID))
+}
+
+func (r *MySQLMachineSecretRepository) CountMachineKeys(ctx context.Context, ownerUserID string) (int, error) {
+	var count int
+	if err := mysqlFor(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM machine_keys WHERE owner_user_id = $1`, mysqlUUID(ownerUserID)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count machine keys: %w", err)
+	}
+	return count, nil
+}
+
+func (r *MySQLMachineSecretRepository) GetMachineKeyByHash(ctx context.Context, keyHash []byte) (domain.MachineKey, error) {
+	keys, err := r.queryMachineKeys(ctx, `SELECT `+machineKeyColumns+` FROM machine_keys WHERE key_hash = $1`, keyHash)
+	if err != nil {
+		return domain.MachineKey{}, err
+	}
+	if len(keys) == 0 {
+		return domain.MachineKey{}, domain.ErrNotFound
+	}
+	return keys[0], nil
+}
+
+func (r *MySQLMachineSecretRepository) DeleteMachineKey(ctx context.Context, ownerUserID, id string) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `DELETE FROM machine_keys WHERE id = $1 AND owner_user_id = $2`, mysqlUUID(id), mysqlUUID(ownerUserID))
+	if err != nil {
+		return fmt.Errorf("delete machine key: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *MySQLMachineSecretRepository) TouchMachineKey(ctx context.Context, id string, usedAt time.Time) error {
+	if _, err := mysqlFor(ctx, r.db).ExecContext(ctx, `UPDATE machine_keys SET last_used_at = $2 WHERE id = $1`, mysqlUUID(id), usedAt.UTC()); err != nil {
+		return fmt.Errorf("touch machine key: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLMachineSecretRepository) queryMachineKeys(ctx context.Context, query string, args ...any) ([]domain.MachineKey, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query machine keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]domain.MachineKey, 0)
+	for rows.Next() {
+		var k domain.MachineKey
+		var scopes []byte
+		var expiresAt, lastUsed sql.NullTime
+		if err := rows.Scan(mysqlScanUUID(&k.ID), mysqlScanUUID(&k.OwnerUserID), &k.Name, &k.KeyHash, &k.Prefix, &scopes, &expiresAt, &lastUsed, &k.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan machine key: %w", err)
+		}
+		if err := json.Unmarshal(scopes, &k.Scopes); err != nil {
+			return nil, fmt.Errorf("decode machine key scopes: %w", err)
+		}
+		setMachineKeyTimes(&k, expiresAt, lastUsed)
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate machine keys: %w", err)
+	}
+	return keys, nil
+}
+
+func mysqlScanMachineSecrets(rows *sql.Rows) ([]domain.MachineSecret, error) {
+	defer rows.Close()
+	secrets := make([]domain.MachineSecret, 0)
+	for rows.Next() {
+		var s domain.MachineSecret
+		if err := rows.Scan(mysqlScanUUID(&s.ID), mysqlScanUUID(&s.OwnerUserID), mysqlScanUUID(&s.ItemID), &s.Name, &s.Ciphertext, &s.Nonce, &s.AlgoVersion, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan machine secret: %w", err)
+		}
+		secrets = append(secrets, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate machine secrets: %w", err)
+	}
+	return secrets, nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MySQLNotificationRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLNotificationRepository(db *sql.DB) *MySQLNotificationRepository {
+	return &MySQLNotificationRepository{db: db}
+}
+
+func (r *MySQLNotificationRepository) GetPreferences(ctx context.Context, userID string) (domain.NotificationPreferences, error) {
+	prefs := domain.NotificationPreferences{UserID: userID}
+	var raw []byte
+	err := mysqlFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT preferences, updated_at FROM notification_preferences WHERE user_id = $1
+	`, mysqlUUID(userID)).Scan(&raw, &prefs.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.NotificationPreferences{}, domain.ErrNotFound
+		}
+		return domain.NotificationPreferences{}, fmt.Errorf("get notification preferences: %w", err)
+	}
+
+	if err := json.Unmarshal(raw, &prefs.Categories); err != nil {
+		return domain.NotificationPreferences{}, fmt.Errorf("decode notification preferences: %w", err)
+	}
+	return prefs, nil
+}
+
+func (r *MySQLNotificationRepository) SavePreferences(ctx context.Context, prefs domain.NotificationPreferences) (domain.NotificationPreferences, error) {
+	raw, err := json.Marshal(prefs.Categories)
+	if err != nil {
+		return domain.NotificationPreferences{}, fmt.Errorf("encode notification preferences: %w", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	_, err = mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO notification_preferences (user_id, preferences, updated_at)
+		VALUES ($1, $2, $3)
+		ON DUPLICATE KEY UPDATE preferences = VALUES(preferences), updated_at = VALUES(updated_at)
+	`, mysqlUUID(prefs.UserID), string(raw), now)
+	if err != nil {
+		return domain.NotificationPreferences{}, fmt.Errorf("save notification preferences: %w", err)
+	}
+	prefs.UpdatedAt = now
+	return prefs, nil
+}
+
+func (r *MySQLNotificationRepository) GetRecipient(ctx context.Context, userID string) (domain.NotificationRecipient, error) {
+	var recipient domain.NotificationRecipient
+	var name sql.NullString
+	err := mysqlFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT email, name FROM users WHERE id = $1
+	`, mysqlUUID(userID)).Scan(&recipient.Email, &name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.NotificationRecipient{}, domain.ErrNotFound
+		}
+		return domain.NotificationRecipient{}, fmt.Errorf("get notification recipient: %w", err)
+	}
+	recipient.Name = name.String
+	return recipient, nil
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type MySQLOutboxRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLOutboxRepository(db *sql.DB) *MySQLOutboxRepository {
+	return &MySQLOutboxRepository{db: db}
+}
+
+func (r *MySQLOutboxRepository) Enqueue(ctx context.Context, topic string, payload json.RawMessage) error {
+	id := uuid.New()
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO outbox_events (id, topic, payload)
+		VALUES ($1, $2, $3)
+	`, mysqlUUIDValue(&id), topic, string(payload))
+	if err != nil {
+		return fmt.Errorf("insert outbox event: %w", err)
+	}
+	return nil
+}
+
+// ClaimDue locks the due rows with SKIP LOCKED so concurrent dispatchers
+// claim disjoint batches, then pushes their next attempt past the lease.
+func (r *MySQLOutboxRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxEvent, error) {
+	sqlTx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("begin claim outbox tx: %w", err)
+	}
+	defer rollback()
+	tx := mysqlConn{db: sqlTx}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, topic, payload, attempts, created_at
+		FROM outbox_events
+		WHERE dispatched_at IS NULL AND failed_at IS NULL AND next_attempt_at <= NOW(6)
+		ORDER BY created_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]domain.OutboxEvent, 0)
+	ids := make([]any, 0)
+	for rows.Next() {
+		var e domain.OutboxEvent
+		var payload []byte
+		if err := rows.Scan(mysqlScanUUID(&e.ID), &e.Topic, &payload, &e.Attempts, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan outbox event: %w", err)
+		}
+		e.Payload = payload
+		e.Attempts++
+		events = append(events, e)
+		ids = append(ids, mysqlUUID(e.ID))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate outbox events: %w", err)
+	}
+	if len(events) == 0 {
+		return events, nil
+	}
+
+	args := append([]any{time.Now().UTC().Add(lease)}, ids...)
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`
+		UPDATE outbox_events
+		SET next_attempt_at = $1, attempts = attempts + 1
+		WHERE id IN (%s)
+	`, mysqlPlaceholders(2, len(ids))), args...); err != nil {
+		return nil, fmt.Errorf("lease outbox events: %w", err)
+	}
+
+	if err := commit(); err != nil {
+		return nil, fmt.Errorf("commit claim outbox tx: %w", err)
+	}
+	return events, nil
+}
+
+func (r *MySQLOutboxRepository) MarkDispatched(ctx context.Context, id string) error {
+	args := append([]any{mysqlUUID(id)}, mysqlStringArgs(domain.SensitiveOutboxTopics)...)
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, fmt.Sprintf(`
+		UPDATE outbox_events
+		SET dispatched_at = NOW(6), last_error = NULL,
+		    payload = CASE WHEN topic IN (%s) THEN JSON_OBJECT() ELSE payload END
+		WHERE id = $1
+	`, mysqlPlaceholders(2, len(domain.SensitiveOutboxTopics))), args...)
+	if err != nil {
+		return fmt.Errorf("mark outbox event dispatched: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLOutboxRepository) MarkRetry(ctx context.Context, id string, lastError string, nextAttemptAt time.Time) error {
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE outbox_events SET last_error = $2, next_attempt_at = $3 WHERE id = $1
+	`, mysqlUUID(id), lastError, nextAttemptAt.UTC())
+	if err != nil {
+		return fmt.Errorf("schedule outbox event retry: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLOutboxRepository) MarkFailed(ctx context.Context, id string, lastError string) error {
+	args := append([]any{mysqlUUID(id), lastError}, mysqlStringArgs(domain.SensitiveOutboxTopics)...)
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, fmt.Sprintf(`
+		UPDATE outbox_events
+		SET last_error = $2, failed_at = NOW(6),
+		    payload = CASE WHEN topic IN (%s) THEN JSON_OBJECT() ELSE payload END
+		WHERE id = $1
+	`, mysqlPlaceholders(3, len(domain.SensitiveOutboxTopics))), args...)
+	if err != nil {
+		return fmt.Errorf("mark outbox event failed: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLOutboxRepository) DeleteDispatchedBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM outbox_events WHERE dispatched_at IS NOT NULL AND dispatched_at < $1
+	`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete dispatched outbox events: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected, nil
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MySQLPasskeyRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLPasskeyRepository(db *sql.DB) *MySQLPasskeyRepository {
+	return &MySQLPasskeyRepository{db: db}
+}
+
+func (r *MySQLPasskeyRepository) CreatePasskeyChallenge(ctx context.Context, challenge domain.PasskeyChallenge) error {
+	var userID any
+	if challenge.UserID != "" {
+		userID = mysqlUUID(challenge.UserID)
+	}
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO passkey_challenges (id, purpose, user_id, email, name, challenge, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, mysqlUUID(challenge.ID), challenge.Purpose, userID, challenge.Email, challenge.Name, challenge.Challenge, challenge.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("insert passkey challenge: %w", err)
+	}
+	return nil
+}
+
+// ConsumePasskeyChallenge locks the row while reading it, since MySQL has
+// no DELETE ... RETURNING; two requests racing for one challenge cannot both
+// get it.
+func (r *MySQLPasskeyRepository) ConsumePasskeyChallenge(ctx context.Context, id string, purpose domain.PasskeyChallengePurpose, now time.Time) (domain.PasskeyChallenge, error) {
+	sqlTx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return domain.PasskeyChallenge{}, fmt.Errorf("begin consume passkey challenge tx: %w", err)
+	}
+	defer rollback()
+	tx := mysqlConn{db: sqlTx}
+
+	c := domain.PasskeyChallenge{ID: id, Purpose: purpose}
+	var userID *string
+	err = tx.QueryRowContext(ctx, `
+		SELECT user_id, email, name, challenge, expires_at FROM passkey_challenges
+		WHERE id = $1 AND purpose = $2 AND expires_at > $3
+		FOR UPDATE
+	`, mysqlUUID(id), purpose, now.UTC()).Scan(mysqlScanNullUUID(&userID), &c.Email, &c.Name, &c.Challenge, &c.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.PasskeyChallenge{}, domain.ErrNotFound
+		}
+		return domain.PasskeyChallenge{}, fmt.Errorf("read passkey challenge: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM passkey_challenges WHERE id = $1`, mysqlUUID(id)); err != nil {
+		return domain.PasskeyChallenge{}, fmt.Errorf("delete passkey challenge: %w", err)
+	}
+	if err := commit(); err != nil {
+		return domain.PasskeyChallenge{}, fmt.Errorf("commit consume passkey challenge: %w", err)
+	}
+	if userID != nil {
+		c.UserID = *userID
+	}
+	return c, nil
+}
+
+func (r *MySQLPasskeyRepository) DeleteExpiredPasskeyChallenges(ctx context.Context, before time.Time) (int64, error) {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `DELETE FROM passkey_challenges WHERE expires_at <= $1`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete expired passkey challenges: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *MySQLPasskeyRepository) CreatePasskey(ctx context.Context, p domain.Passkey) (domain.Passkey, error) {
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO passkeys (id, user_id, credential_id, public_key, sign_count, name, prf_salt, wrapped_key, wrap_nonce)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, mysqlUUID(p.ID), mysqlUUID(p.UserID), p.CredentialID, p.PublicKey, int64(p.SignCount), p.Name,
+		nullableBytes(p.PRFSalt), nullableBytes(p.WrappedKey), nullableBytes(p.WrapNonce))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.Passkey{}, domain.ErrPasskeyTaken
+		}
+		return domain.Passkey{}, fmt.Errorf("insert passkey: %w", err)
+	}
+	return r.GetPasskeyByCredentialID(ctx, p.CredentialID)
+}
+
+func (r *MySQLPasskeyRepository) GetPasskeyByCredentialID(ctx context.Context, credentialID []byte) (domain.Passkey, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+passkeyColumns+` FROM passkeys WHERE credential_id = $1
+	`, credentialID)
+	if err != nil {
+		return domain.Passkey{}, fmt.Errorf("get passkey: %w", err)
+	}
+	passkeys, err := mysqlScanPasskeys(rows)
+	if err != nil {
+		return domain.Passkey{}, err
+	}
+	if len(passkeys) == 0 {
+		return domain.Passkey{}, domain.ErrNotFound
+	}
+	return passkeys[0], nil
+}
+
+func (r *MySQLPasskeyRepository) ListPasskeys(ctx context.Context, userID string) ([]domain.Passkey, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+passkeyColumns+` FROM passkeys
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, mysqlUUID(userID))
+	if err != nil {
+		return nil, fmt.Errorf("list passkeys: %w", err)
+	}
+	return mysqlScanPasskeys(rows)
+}
+
+func (r *MySQLPasskeyRepository) TouchPasskey(ctx context.Context, id string, signCount uint32, usedAt time.Time) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE passkeys SET sign_count = $2, last_used_at = $3 WHERE id = $1
+	`, mysqlUUID(id), int64(signCount), usedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("touch passkey: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *MySQLPasskeyRepository) DeletePasskey(ctx context.Context, userID, id string) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `DELETE FROM passkeys WHERE id = $1 AND user_id = $2`, mysqlUUID(id), mysqlUUID(userID))
+	if err != nil {
+		return fmt.Errorf("delete passkey: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *MySQLPasskeyRepository) DeleteAllPasskeys(ctx context.Context, userID string) error {
+	if _, err := mysqlFor(ctx, r.db).ExecContext(ctx, `DELETE FROM passkeys WHERE user_id = $1`, mysqlUUID(userID)); err != nil {
+		return fmt.Errorf("delete passkeys: %w", err)
+	}
+	return nil
+}
+
+func mysqlScanPasskeys(rows *sql.Rows) ([]domain.Passkey, error) {
+	defer rows.Close()
+	passkeys := make([]domain.Passkey, 0)
+	for rows.Next() {
+		var p domain.Passkey
+		var lastUsed sql.NullTime
+		if err := rows.Scan(mysqlScanUUID(&p.ID), mysqlScanUUID(&p.UserID), &p.CredentialID, &p.PublicKey, &p.SignCount, &p.Name,
+			&p.PRFSalt, &p.WrappedKey, &p.WrapNonce, &p.CreatedAt, &lastUsed); err != nil {
+			return nil, fmt.Errorf("scan passkey: %w", err)
+		}
+		if lastUsed.Valid {
+			t := lastUsed.Time.UTC()
+			p.LastUsedAt = &t
+		}
+		passkeys = append(passkeys, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate passkeys: %w", err)
+	}
+	return passkeys, nil
+}
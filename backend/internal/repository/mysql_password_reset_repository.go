@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MySQLPasswordResetRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLPasswordResetRepository(db *sql.DB) *MySQLPasswordResetRepository {
+	return &MySQLPasswordResetRepository{db: db}
+}
+
+func (r *MySQLPasswordResetRepository) CreatePasswordReset(ctx context.Context, token domain.PasswordResetToken) error {
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO password_reset_tokens (id, user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, mysqlUUID(token.ID), mysqlUUID(token.UserID), token.TokenHash, token.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("insert password reset token: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLPasswordResetRepository) GetPasswordReset(ctx context.Context, tokenHash []byte, now time.Time) (domain.PasswordResetToken, error) {
+	token := domain.PasswordResetToken{TokenHash: tokenHash}
+	err := mysqlFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT id, user_id, expires_at, created_at
+		FROM password_reset_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+	`, tokenHash, now.UTC()).Scan(mysqlScanUUID(&token.ID), mysqlScanUUID(&token.UserID), &token.ExpiresAt, &token.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.PasswordResetToken{}, domain.ErrNotFound
+		}
+		return domain.PasswordResetToken{}, fmt.Errorf("get password reset token: %w", err)
+	}
+	return token, nil
+}
+
+func (r *MySQLPasswordResetRepository) CountPasswordResets(ctx context.Context, userID string, since time.Time) (int, error) {
+	var count int
+	err := mysqlFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM password_reset_tokens WHERE user_id = $1 AND created_at > $2
+	`, mysqlUUID(userID), since.UTC()).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count password reset tokens: %w", err)
+	}
+	return count, nil
+}
+
+func (r *MySQLPasswordResetRepository) UsePasswordReset(ctx context.Context, id, userID string, now time.Time) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE password_reset_tokens SET used_at = $2 WHERE id = $1 AND used_at IS NULL
+	`, mysqlUUID(id), now.UTC())
+	if err != nil {
+		return fmt.Errorf("use password reset token: %w", err)
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+	if _, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE password_reset_tokens SET used_at = $2 WHERE user_id = $1 AND used_at IS NULL
+	`, mysqlUUID(userID), now.UTC()); err != nil {
+		return fmt.Errorf("use other password reset tokens: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLPasswordResetRepository) DeleteExpiredPasswordResets(ctx context.Context, before time.Time) (int64, error) {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE expires_at <= $1`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete expired password reset tokens: %w", err)
+	}
+	return result.RowsAffected()
+}
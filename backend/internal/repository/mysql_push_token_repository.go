@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MySQLPushTokenRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLPushTokenRepository(db *sql.DB) *MySQLPushTokenRepository {
+	return &MySQLPushTokenRepository{db: db}
+}
+
+// UpsertPushToken reads the row back in the same transaction; MySQL has no
+// RETURNING.
+func (r *MySQLPushTokenRepository) UpsertPushToken(ctx context.Context, t domain.PushToken) (domain.PushToken, error) {
+	sqlTx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return domain.PushToken{}, fmt.Errorf("begin upsert push token tx: %w", err)
+	}
+	defer rollback()
+	tx := mysqlConn{db: sqlTx}
+
+	var deviceID *string
+	if t.DeviceID != "" {
+		deviceID = &t.DeviceID
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO push_tokens (id, user_id, device_id, platform, token)
+		VALUES ($1, $2, $3, $4, $5)
+		ON DUPLICATE KEY UPDATE
+			user_id = VALUES(user_id),
+			device_id = VALUES(device_id),
+			updated_at = NOW(6)
+	`, mysqlUUID(uuid.NewString()), mysqlUUID(t.UserID), mysqlNullUUID(deviceID), t.Platform, t.Token); err != nil {
+		return domain.PushToken{}, fmt.Errorf("upsert push token: %w", err)
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+pushTokenColumns+` FROM push_tokens
+		WHERE platform = $1 AND token = $2
+	`, t.Platform, t.Token)
+	if err != nil {
+		return domain.PushToken{}, fmt.Errorf("read push token: %w", err)
+	}
+	tokens, err := mysqlScanPushTokens(rows)
+	if err != nil {
+		return domain.PushToken{}, err
+	}
+	if len(tokens) == 0 {
+		return domain.PushToken{}, domain.ErrNotFound
+	}
+	if err := commit(); err != nil {
+		return domain.PushToken{}, fmt.Errorf("commit upsert push token: %w", err)
+	}
+	return tokens[0], nil
+}
+
+func (r *MySQLPushTokenRepository) ListPushTokens(ctx context.Context, userID string) ([]domain.PushToken, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+pushTokenColumns+` FROM push_tokens
+		WHERE user_id = $1
+		ORDER BY updated_at DESC
+	`, mysqlUUID(userID))
+	if err != nil {
+		return nil, fmt.Errorf("list push tokens: %w", err)
+	}
+	return mysqlScanPushTokens(rows)
+}
+
+func (r *MySQLPushTokenRepository) DeletePushToken(ctx context.Context, userID, id string) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `DELETE FROM push_tokens WHERE id = $1 AND user_id = $2`, mysqlUUID(id), mysqlUUID(userID))
+	if err != nil {
+		return fmt.Errorf("delete push token: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *MySQLPushTokenRepository) DeletePushTokenByValue(ctx context.Context, platform domain.PushPlatform, token string) error {
+	if _, err := mysqlFor(ctx, r.db).ExecContext(ctx, `DELETE FROM push_tokens WHERE platform = $1 AND token = $2`, platform, token); err != nil {
+		return fmt.Errorf("delete push token: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLPushTokenRepository) DeleteStalePushTokens(ctx context.Context, before time.Time) (int64, error) {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `DELETE FROM push_tokens WHERE updated_at < $1`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete stale push tokens: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func mysqlScanPushTokens(rows *sql.Rows) ([]domain.PushToken, error) {
+	defer rows.Close()
+	tokens := make([]domain.PushToken, 0)
+	for rows.Next() {
+		var t domain.PushToken
+		var deviceID *string
+		if err := rows.Scan(mysqlScanUUID(&t.ID), mysqlScanUUID(&t.UserID), mysqlScanNullUUID(&deviceID), &t.Platform, &t.Token, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan push token: %w", err)
+		}
+		if deviceID != nil {
+			t.DeviceID = *deviceID
+		}
+		tokens = append(tokens, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate push tokens: %w", err)
+	}
+	return tokens, nil
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MySQLQRLoginRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLQRLoginRepository(db *sql.DB) *MySQLQRLoginRepository {
+	return &MySQLQRLoginRepository{db: db}
+}
+
+func (r *MySQLQRLoginRepository) CreateQRLogin(ctx context.Context, req domain.QRLoginRequest) error {
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO qr_login_requests (id, code_hash, poll_token_hash, status, device_name, ip_address, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, mysqlUUID(req.ID), req.CodeHash, req.PollTokenHash, domain.QRLoginPending, req.DeviceName, req.IPAddr, req.UserAgent, req.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("insert qr login request: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLQRLoginRepository) GetQRLogin(ctx context.Context, id string, now time.Time) (domain.QRLoginRequest, error) {
+	req := domain.QRLoginRequest{ID: id}
+	var userID *string
+	err := mysqlFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT code_hash, poll_token_hash, status, user_id, device_name, ip_address, user_agent, expires_at, created_at
+		FROM qr_login_requests
+		WHERE id = $1 AND expires_at > $2
+	`, mysqlUUID(id), now.UTC()).Scan(&req.CodeHash, &req.PollTokenHash, &req.Status, mysqlScanNullUUID(&userID), &req.DeviceName, &req.IPAddr, &req.UserAgent, &req.ExpiresAt, &req.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.QRLoginRequest{}, domain.ErrNotFound
+		}
+		return domain.QRLoginRequest{}, fmt.Errorf("get qr login request: %w", err)
+	}
+	if userID != nil {
+		req.UserID = *userID
+	}
+	return req, nil
+}
+
+func (r *MySQLQRLoginRepository) DecideQRLogin(ctx context.Context, id, userID string, status domain.QRLoginStatus, now time.Time) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE qr_login_requests SET status = $3, user_id = $2
+		WHERE id = $1 AND status = 'pending' AND expires_at > $4
+	`, mysqlUUID(id), mysqlUUID(userID), status, now.UTC())
+	if err != nil {
+		return fmt.Errorf("decide qr login request: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *MySQLQRLoginRepository) DeleteQRLogin(ctx context.Context, id string) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `DELETE FROM qr_login_requests WHERE id = $1`, mysqlUUID(id))
+	if err != nil {
+		return fmt.Errorf("delete qr login request: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *MySQLQRLoginRepository) DeleteExpiredQRLogins(ctx context.Context, before time.Time) (int64, error) {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `DELETE FROM qr_login_requests WHERE expires_at <= $1`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete expired qr login requests: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,290 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/database"
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// openMySQL migrates the database named by PMV2_TEST_MYSQL_URL and drops
+// every table again afterwards, so it must point at a scratch database.
+func openMySQL(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("PMV2_TEST_MYSQL_URL")
+	if dsn == "" {
+		t.Skip("PMV2_TEST_MYSQL_URL not set")
+	}
+	ctx := context.Background()
+	db, err := database.OpenAndMigrate(ctx, dsn)
+	if err != nil {
+		t.Fatalf("open mysql: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = database.DropAll(ctx, db)
+		_ = db.Close()
+	})
+	return db.SQL()
+}
+
+func createMySQLUser(t *testing.T, conn *sql.DB, email string) string {
+	t.Helper()
+	userID := uuid.NewString()
+	err := repository.NewMySQLAuthRepository(conn).CreateUserWithCredentials(context.Background(), domain.CreateUserInput{
+		UserID:       userID,
+		Email:        email,
+		Algo:         "argon2id",
+		ParamsJSON:   []byte(`{"t":3}`),
+		Salt:         []byte("salt"),
+		PasswordHash: []byte("hash"),
+	})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return userID
+}
+
+func TestMySQLAuthSessions(t *testing.T) {
+	conn := openMySQL(t)
+	repo := repository.NewMySQLAuthRepository(conn)
+	ctx := context.Background()
+	userID := createMySQLUser(t, conn, "alice@example.com")
+
+	err := repo.CreateUserWithCredentials(ctx, domain.CreateUserInput{UserID: uuid.NewString(), Email: "alice@example.com", Algo: "argon2id", ParamsJSON: []byte(`{}`)})
+	if !errors.Is(err, domain.ErrEmailTaken) {
+		t.Fatalf("duplicate email: err = %v, want ErrEmailTaken", err)
+	}
+
+	record, err := repo.GetUserAuthByEmail(ctx, "alice@example.com")
+	if err != nil || record.UserID != userID {
+		t.Fatalf("GetUserAuthByEmail = %+v, %v", record, err)
+	}
+
+	for i, expires := range []time.Time{time.Now().Add(time.Hour), time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)} {
+		if err := repo.CreateSession(ctx, domain.CreateSessionInput{
+			SessionID: uuid.NewString(),
+			UserID:    userID,
+			TokenHash: []byte{byte(i)},
+			IPAddr:    "203.0.113.7",
+			ExpiresAt: expires,
+		}); err != nil {
+			t.Fatalf("create session: %v", err)
+		}
+	}
+
+	session, err := repo.GetActiveSessionByTokenHash(ctx, []byte{0})
+	if err != nil || session.UserID != userID {
+		t.Fatalf("active session = %+v, %v", session, err)
+	}
+	if _, err := repo.GetActiveSessionByTokenHash(ctx, []byte{1}); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expired session: err = %v, want ErrNotFound", err)
+	}
+	first, err := repo.DeleteExpiredSessions(ctx, time.Now(), "", 1)
+	if err != nil || first.Deleted != 1 || first.LastID == "" {
+		t.Fatalf("DeleteExpiredSessions(limit 1) = %+v, %v; want 1", first, err)
+	}
+	if batch, err := repo.DeleteExpiredSessions(ctx, time.Now(), first.LastID, 10); err != nil || batch.Deleted != 1 || batch.LastID <= first.LastID {
+		t.Fatalf("DeleteExpiredSessions after %s = %+v, %v; want the other expired session", first.LastID, batch, err)
+	}
+
+	if revoked, err := repo.RevokeSessionByTokenHash(ctx, []byte{0}); err != nil || !revoked {
+		t.Fatalf("RevokeSessionByTokenHash = %v, %v", revoked, err)
+	}
+	if batch, err := repo.DeleteExpiredSessions(ctx, time.Now().Add(-time.Hour), "", 10); err != nil || batch.Deleted != 0 {
+		t.Fatalf("DeleteExpiredSessions kept for retention = %+v, %v; want 0", batch, err)
+	}
+	if batch, err := repo.DeleteExpiredSessions(ctx, time.Now().Add(time.Minute), "", 10); err != nil || batch.Deleted != 1 {
+		t.Fatalf("DeleteExpiredSessions past retention = %+v, %v; want 1", batch, err)
+	}
+}
+
+func TestMySQLVaultItems(t *testing.T) {
+	conn := openMySQL(t)
+	repo := repository.NewMySQLVaultRepository(conn)
+	ctx := context.Background()
+	ownerID := createMySQLUser(t, conn, "bob@example.com")
+
+	before, err := repo.GetVaultRevision(ctx, ownerID)
+	if err != nil {
+		t.Fatalf("revision of an empty vault: %v", err)
+	}
+
+	item, err := repo.CreateVaultItem(ctx, domain.CreateVaultItemInput{
+		OwnerUserID: ownerID,
+		Ciphertext:  []byte("v1"),
+		Nonce:       []byte("n"),
+		WrappedDEK:  []byte("dek"),
+		WrapNonce:   []byte("wn"),
+		AlgoVersion: "xchacha20poly1305-v1",
+		Metadata:    []byte(`{"kind":"login"}`),
+	})
+	if err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	if item.Version != 1 || item.IsShared || item.CreatedAt.IsZero() {
+		t.Fatalf("created item = %+v", item)
+	}
+
+	updated, err := repo.UpdateVaultItemForOwner(ctx, item.ID, ownerID, domain.UpdateVaultItemInput{
+		Ciphertext:  []byte("v2"),
+		Nonce:       []byte("n"),
+		WrappedDEK:  []byte("dek"),
+		WrapNonce:   []byte("wn"),
+		AlgoVersion: "xchacha20poly1305-v1",
+	})
+	if err != nil || updated.Version != 2 || string(updated.Ciphertext) != "v2" {
+		t.Fatalf("update = %+v, %v", updated, err)
+	}
+	versions, err := repo.ListVaultItemVersionsByOwner(ctx, item.ID, ownerID)
+	if err != nil || len(versions) != 1 || string(versions[0].Ciphertext) != "v1" {
+		t.Fatalf("versions = %+v, %v", versions, err)
+	}
+
+	after, err := repo.GetVaultRevision(ctx, ownerID)
+	if err != nil || after == before {
+		t.Fatalf("revision = %q, %v; want it to change from %q", after, err, before)
+	}
+
+	if deleted, err := repo.DeleteVaultItemForOwner(ctx, item.ID, ownerID); err != nil || !deleted {
+		t.Fatalf("delete = %v, %v", deleted, err)
+	}
+	trash, err := repo.ListDeletedVaultItemsByOwner(ctx, ownerID)
+	if err != nil || len(trash) != 1 || trash[0].DeletedAt == nil {
+		t.Fatalf("trash = %+v, %v", trash, err)
+	}
+	if _, err := repo.RestoreVaultItemForOwner(ctx, item.ID, ownerID); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if _, err := repo.RestoreVaultItemForOwner(ctx, item.ID, ownerID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("restore of a live item: err = %v, want ErrNotFound", err)
+	}
+
+	items, err := repo.ListVaultItemsByOwner(ctx, ownerID)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("items = %+v, %v", items, err)
+	}
+}
+
+func TestMySQLAuditFilterAndExport(t *testing.T) {
+	conn := openMySQL(t)
+	repo := repository.NewMySQLAuditRepository(conn)
+	ctx := context.Background()
+
+	start := time.Now().Add(-time.Minute)
+	for i, eventType := range []domain.EventType{domain.EventTypeAuthLoginSuccess, domain.EventTypeAuthLoginFailed, domain.EventTypeVaultItemCreated} {
+		event := domain.AuditEvent{
+			ID:        uuid.New(),
+			EventType: eventType,
+			EventData: json.RawMessage(`{"n":1}`),
+			IPAddress: "198.51.100.1",
+			CreatedAt: start.Add(time.Duration(i) * time.Second),
+		}
+		if err := repo.CreateEvent(ctx, event); err != nil {
+			t.Fatalf("create event: %v", err)
+		}
+		if err := repo.CreateEvent(ctx, event); err != nil {
+			t.Fatalf("replayed event: %v", err)
+		}
+	}
+
+	events, total, err := repo.ListEvents(ctx, 10, 0, domain.AuditFilter{
+		EventTypes: []domain.EventType{domain.EventTypeAuthLoginSuccess, domain.EventTypeAuthLoginFailed},
+		IPAddress:  "198.51.100.1",
+	})
+	if err != nil || total != 2 || len(events) != 2 {
+		t.Fatalf("filtered events = %d of %d, %v; want 2", len(events), total, err)
+	}
+	if events[0].EventType != domain.EventTypeAuthLoginFailed {
+		t.Fatalf("first event = %s, want the newest", events[0].EventType)
+	}
+
+	// ListEventsAfter leaves out events recorded in the current instant.
+	time.Sleep(time.Millisecond)
+
+	var exported int
+	locked, err := repo.AdvanceExportCursor(ctx, "test", func(cursor domain.AuditCursor) (domain.AuditCursor, error) {
+		batch, err := repo.ListEventsAfter(ctx, cursor, 0, 10)
+		if err != nil {
+			return cursor, err
+		}
+		exported = len(batch)
+		last := batch[len(batch)-1]
+		return domain.AuditCursor{RecordedAt: last.RecordedAt, EventID: last.ID}, nil
+	})
+	if err != nil || !locked || exported != 3 {
+		t.Fatalf("export = %d events, locked %v, %v; want 3", exported, locked, err)
+	}
+	_, err = repo.AdvanceExportCursor(ctx, "test", func(cursor domain.AuditCursor) (domain.AuditCursor, error) {
+		batch, err := repo.ListEventsAfter(ctx, cursor, 0, 10)
+		exported = len(batch)
+		return cursor, err
+	})
+	if err != nil || exported != 0 {
+		t.Fatalf("second export = %d events, %v; want none", exported, err)
+	}
+}
+
+func TestMySQLOutboxClaim(t *testing.T) {
+	conn := openMySQL(t)
+	repo := repository.NewMySQLOutboxRepository(conn)
+	ctx := context.Background()
+
+	if err := repo.Enqueue(ctx, domain.OutboxTopicAudit, json.RawMessage(`{"a":1}`)); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	claimed, err := repo.ClaimDue(ctx, 10, time.Minute)
+	if err != nil || len(claimed) != 1 || claimed[0].Attempts != 1 || string(claimed[0].Payload) != `{"a":1}` {
+		t.Fatalf("claim = %+v, %v", claimed, err)
+	}
+	if again, err := repo.ClaimDue(ctx, 10, time.Minute); err != nil || len(again) != 0 {
+		t.Fatalf("leased event claimed again: %+v, %v", again, err)
+	}
+	if err := repo.MarkDispatched(ctx, claimed[0].ID); err != nil {
+		t.Fatalf("mark dispatched: %v", err)
+	}
+	if deleted, err := repo.DeleteDispatchedBefore(ctx, time.Now().Add(time.Minute)); err != nil || deleted != 1 {
+		t.Fatalf("DeleteDispatchedBefore = %d, %v; want 1", deleted, err)
+	}
+}
+
+func TestMySQLWebhookEndpointsForEvent(t *testing.T) {
+	conn := openMySQL(t)
+	repo := repository.NewMySQLWebhookRepository(conn)
+	ctx := context.Background()
+	userID := createMySQLUser(t, conn, "carol@example.com")
+
+	for _, types := range [][]domain.EventType{nil, {domain.EventTypeAuthLoginFailed}, {domain.EventTypeVaultItemCreated}} {
+		if _, err := repo.CreateEndpoint(ctx, domain.WebhookEndpoint{
+			ID:         uuid.NewString(),
+			UserID:     userID,
+			URL:        "https://hooks.example.com",
+			SecretEnc:  []byte("secret"),
+			EventTypes: types,
+			Enabled:    true,
+		}); err != nil {
+			t.Fatalf("create endpoint: %v", err)
+		}
+	}
+
+	endpoints, err := repo.ListEndpointsForEvent(ctx, userID, domain.EventTypeAuthLoginFailed)
+	if err != nil || len(endpoints) != 2 {
+		t.Fatalf("endpoints for login failure = %d, %v; want the catch-all and the subscribed one", len(endpoints), err)
+	}
+
+	if err := repo.EnqueueDelivery(ctx, domain.WebhookDelivery{EndpointID: endpoints[0].ID, EventID: uuid.NewString(), EventType: domain.EventTypeAuthLoginFailed, Payload: []byte(`{}`)}); err != nil {
+		t.Fatalf("enqueue delivery: %v", err)
+	}
+	deliveries, err := repo.ClaimDueDeliveries(ctx, 10, time.Minute)
+	if err != nil || len(deliveries) != 1 || deliveries[0].Endpoint.URL != "https://hooks.example.com" {
+		t.Fatalf("claimed deliveries = %+v, %v", deliveries, err)
+	}
+}
@@ -0,0 +1,541 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MySQLSharingRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLSharingRepository(db *sql.DB) *MySQLSharingRepository {
+	return &MySQLSharingRepository{db: db}
+}
+
+func (r *MySQLSharingRepository) CreateShare(ctx context.Context, input domain.ShareItemInput) error {
+	schedule, err := shareScheduleJSON(input.Window.Schedule)
+	if err != nil {
+		return err
+	}
+	_, err = mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO vault_shares (item_id, user_id, shared_by_user_id, dek_wrapped, wrap_nonce, permissions, not_before, expires_at, schedule)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, mysqlUUID(input.ItemID), mysqlUUID(input.RecipientID), mysqlUUID(input.SharedByUserID), input.DEKWrapped, input.WrapNonce, input.Permissions,
+		input.Window.NotBefore, input.Window.ExpiresAt, schedule)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.ErrAlreadyShared
+		}
+		return fmt.Errorf("create share: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLSharingRepository) DeleteShare(ctx context.Context, itemID string, recipientUserID string) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM vault_shares WHERE item_id = $1 AND user_id = $2
+	`, mysqlUUID(itemID), mysqlUUID(recipientUserID))
+	if err != nil {
+		return fmt.Errorf("delete share: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("read rows affected: %w", err)
+	}
+	if affected == 0 {
+		return domain.ErrShareNotFound
+	}
+	return nil
+}
+
+func (r *MySQLSharingRepository) UpdateSharePermissions(ctx context.Context, itemID string, recipientUserID string, permissions string) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE vault_shares SET permissions = $3, updated_at = CURRENT_TIMESTAMP(6)
+		WHERE item_id = $1 AND user_id = $2
+	`, mysqlUUID(itemID), mysqlUUID(recipientUserID), permissions)
+	if err != nil {
+		return fmt.Errorf("update share permissions: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("read rows affected: %w", err)
+	}
+	if affected == 0 {
+		return domain.ErrShareNotFound
+	}
+	return nil
+}
+
+func (r *MySQLSharingRepository) ListSharesByRecipient(ctx context.Context, userID string) ([]domain.SharedVaultItem, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT
+			vi.id, vi.owner_user_id, vi.folder_id, vi.ciphertext, vi.nonce,
+			vi.dek_wrapped, vi.wrap_nonce, vi.algo_version, vi.metadata,
+			vi.created_at, vi.updated_at,
+			vs.shared_by_user_id, vs.dek_wrapped, vs.wrap_nonce, vs.permissions,
+			vs.not_before, vs.expires_at, vs.schedule, vs.first_accessed_at,
+			COALESCE(u.email, ''), COALESCE(u.name, '')
+		FROM vault_shares vs
+		JOIN vault_items vi ON vi.id = vs.item_id
+		LEFT JOIN users u ON u.id = vs.shared_by_user_id
+		WHERE vs.user_id = $1
+		ORDER BY vs.created_at DESC
+	`, mysqlUUID(userID))
+	if err != nil {
+		return nil, fmt.Errorf("query shared items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]domain.SharedVaultItem, 0)
+	for rows.Next() {
+		var si domain.SharedVaultItem
+		var metadata []byte
+		var window shareWindowColumns
+		var firstAccessedAt sql.NullTime
+		var sharedBy *string
+		err := rows.Scan(
+			mysqlScanUUID(&si.ID), mysqlScanUUID(&si.OwnerUserID), mysqlScanNullUUID(&si.FolderID), &si.Ciphertext, &si.Nonce,
+			&si.WrappedDEK, &si.WrapNonce, &si.AlgoVersion, &metadata,
+			&si.CreatedAt, &si.UpdatedAt,
+			mysqlScanNullUUID(&sharedBy), &si.ShareDEK, &si.ShareWrapNonce, &si.Permissions,
+			&window.NotBefore, &window.ExpiresAt, &window.Schedule, &firstAccessedAt,
+			&si.SharedByEmail, &si.SharedByName,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan shared item: %w", err)
+		}
+		if si.ShareWindow, err = window.decode(); err != nil {
+			return nil, err
+		}
+		si.Metadata = metadata
+		si.FirstAccessedAt = nullTimePtr(firstAccessedAt)
+		if sharedBy != nil {
+			si.SharedByUserID = *sharedBy
+		}
+		items = append(items, si)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate shared items: %w", err)
+	}
+	return items, nil
+}
+
+func (r *MySQLSharingRepository) ListSharesByItem(ctx context.Context, itemID string) ([]domain.VaultShare, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+vaultShareColumns+`
+		FROM vault_shares
+		WHERE item_id = $1
+		ORDER BY created_at ASC
+	`, mysqlUUID(itemID))
+	if err != nil {
+		return nil, fmt.Errorf("query shares by item: %w", err)
+	}
+	return mysqlScanVaultShares(rows)
+}
+
+func (r *MySQLSharingRepository) GetShare(ctx context.Context, itemID string, userID string) (domain.VaultShare, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+vaultShareColumns+`
+		FROM vault_shares
+		WHERE item_id = $1 AND user_id = $2
+	`, mysqlUUID(itemID), mysqlUUID(userID))
+	if err != nil {
+		return domain.VaultShare{}, fmt.Errorf("get share: %w", err)
+	}
+	shares, err := mysqlScanVaultShares(rows)
+	if err != nil {
+		return domain.VaultShare{}, err
+	}
+	if len(shares) == 0 {
+		return domain.VaultShare{}, domain.ErrShareNotFound
+	}
+	return shares[0], nil
+}
+
+func (r *MySQLSharingRepository) ListSentShares(ctx context.Context, userID string) ([]domain.SentShare, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT
+			vs.item_id,
+			vi.metadata,
+			vs.user_id,
+			u.email,
+			COALESCE(u.name, ''),
+			vs.permissions,
+			vs.created_at
+		FROM vault_shares vs
+		JOIN vault_items vi ON vi.id = vs.item_id
+		JOIN users u ON u.id = vs.user_id
+		WHERE vs.shared_by_user_id = $1
+		ORDER BY vs.created_at DESC
+	`, mysqlUUID(userID))
+	if err != nil {
+		return nil, fmt.Errorf("query sent shares: %w", err)
+	}
+	defer rows.Close()
+
+	shares := make([]domain.SentShare, 0)
+	for rows.Next() {
+		var s domain.SentShare
+		var metadata []byte
+		err := rows.Scan(
+			mysqlScanUUID(&s.ItemID), &metadata, mysqlScanUUID(&s.RecipientID), &s.RecipientEmail, &s.RecipientName, &s.Permissions, &s.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan sent share: %w", err)
+		}
+		s.ItemTitle = string(metadata)
+		shares = append(shares, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate sent shares: %w", err)
+	}
+	return shares, nil
+}
+
+func (r *MySQLSharingRepository) DeleteAllSharesBetweenUsers(ctx context.Context, user1ID, user2ID string) error {
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM vault_shares
+		WHERE (shared_by_user_id = $1 AND user_id = $2)
+		   OR (shared_by_user_id = $2 AND user_id = $1)
+	`, mysqlUUID(user1ID), mysqlUUID(user2ID))
+	if err != nil {
+		return fmt.Errorf("delete all shares between users: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLSharingRepository) ListExpiredShares(ctx context.Context, now time.Time, limit int) ([]domain.VaultShare, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+vaultShareColumns+`
+		FROM vault_shares
+		WHERE expires_at <= $1
+		ORDER BY expires_at
+		LIMIT $2
+	`, now.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("query expired shares: %w", err)
+	}
+	return mysqlScanVaultShares(rows)
+}
+
+func mysqlScanVaultShares(rows *sql.Rows) ([]domain.VaultShare, error) {
+	defer rows.Close()
+	shares := make([]domain.VaultShare, 0)
+	for rows.Next() {
+		var s domain.VaultShare
+		var sharedBy *string
+		var window shareWindowColumns
+		var firstAccessedAt sql.NullTime
+		err := rows.Scan(
+			mysqlScanUUID(&s.ItemID), mysqlScanUUID(&s.UserID), mysqlScanNullUUID(&sharedBy), &s.DEKWrapped, &s.WrapNonce, &s.Permissions,
+			&window.NotBefore, &window.ExpiresAt, &window.Schedule, &firstAccessedAt, &s.CreatedAt, &s.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan share: %w", err)
+		}
+		s.FirstAccessedAt = nullTimePtr(firstAccessedAt)
+		if sharedBy != nil {
+			s.SharedByUserID = *sharedBy
+		}
+		if s.Window, err = window.decode(); err != nil {
+			return nil, err
+		}
+		shares = append(shares, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate shares: %w", err)
+	}
+	return shares, nil
+}
+
+func (r *MySQLSharingRepository) GetItemOwner(ctx context.Context, itemID string) (string, error) {
+	var owner string
+	err := mysqlFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT owner_user_id FROM vault_items WHERE id = $1 AND deleted_at IS NULL
+	`, mysqlUUID(itemID)).Scan(mysqlScanUUID(&owner))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", domain.ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("get item owner: %w", err)
+	}
+	return owner, nil
+}
+
+func (r *MySQLSharingRepository) CreateAccessRequest(ctx context.Context, req domain.AccessRequest) (domain.AccessRequest, error) {
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO access_requests (id, item_id, owner_user_id, requester_user_id, reason, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, mysqlUUID(req.ID), mysqlUUID(req.ItemID), mysqlUUID(req.OwnerUserID), mysqlUUID(req.RequesterUserID), req.Reason, domain.AccessRequestPending)
+	if err != nil {
+		return domain.AccessRequest{}, fmt.Errorf("create access request: %w", err)
+	}
+	return r.GetAccessRequest(ctx, req.ID)
+}
+
+func (r *MySQLSharingRepository) GetAccessRequest(ctx context.Context, id string) (domain.AccessRequest, error) {
+	requests, err := r.queryAccessRequests(ctx, `
+		SELECT `+accessRequestColumns+`
+		FROM access_requests ar
+		LEFT JOIN users u ON u.id = ar.requester_user_id
+		WHERE ar.id = $1
+	`, mysqlUUID(id))
+	if err != nil {
+		return domain.AccessRequest{}, fmt.Errorf("get access request: %w", err)
+	}
+	if len(requests) == 0 {
+		return domain.AccessRequest{}, domain.ErrAccessRequestNotFound
+	}
+	return requests[0], nil
+}
+
+func (r *MySQLSharingRepository) ListAccessRequestsByRequester(ctx context.Context, requesterUserID string) ([]domain.AccessRequest, error) {
+	requests, err := r.queryAccessRequests(ctx, `
+		SELECT `+accessRequestColumns+`
+		FROM access_requests ar
+		LEFT JOIN users u ON u.id = ar.requester_user_id
+		WHERE ar.requester_user_id = $1
+		ORDER BY ar.created_at DESC, ar.id
+	`, mysqlUUID(requesterUserID))
+	if err != nil {
+		return nil, fmt.Errorf("list access requests: %w", err)
+	}
+	return requests, nil
+}
+
+func (r *MySQLSharingRepository) ListPendingAccessRequestsForApprover(ctx context.Context, approverUserID string) ([]domain.AccessRequest, error) {
+	requests, err := r.queryAccessRequests(ctx, `
+		SELECT `+accessRequestColumns+`
+		FROM access_requests ar
+		LEFT JOIN users u ON u.id = ar.requester_user_id
+		WHERE ar.status = $2
+		  AND (ar.owner_user_id = $1 OR EXISTS (
+			SELECT 1 FROM vault_shares vs
+			WHERE vs.item_id = ar.item_id AND vs.user_id = $1 AND vs.permissions = $3
+		  ))
+		ORDER BY ar.created_at, ar.id
+	`, mysqlUUID(approverUserID), domain.AccessRequestPending, domain.SharePermissionManage)
+	if err != nil {
+		return nil, fmt.Errorf("list pending access requests: %w", err)
+	}
+	return requests, nil
+}
+
+func (r *MySQLSharingRepository) DecideAccessRequest(ctx context.Context, id string, status domain.AccessRequestStatus, decidedByUserID string, decidedAt time.Time) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE access_requests
+		SET status = $2, decided_by_user_id = $3, decided_at = $4
+		WHERE id = $1 AND status = $5
+	`, mysqlUUID(id), status, mysqlUUID(decidedByUserID), decidedAt.UTC(), domain.AccessRequestPending)
+	if err != nil {
+		return fmt.Errorf("decide access request: %w", err)
+	}
+	return accessRequestDecided(result)
+}
+
+func (r *MySQLSharingRepository) queryAccessRequests(ctx context.Context, query string, args ...any) ([]domain.AccessRequest, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := make([]domain.AccessRequest, 0)
+	for rows.Next() {
+		var req domain.AccessRequest
+		var decidedBy *string
+		var decidedAt sql.NullTime
+		err := rows.Scan(
+			mysqlScanUUID(&req.ID), mysqlScanUUID(&req.ItemID), mysqlScanUUID(&req.OwnerUserID), mysqlScanUUID(&req.RequesterUserID),
+			&req.RequesterEmail, &req.RequesterName,
+			&req.Reason, &req.Status, mysqlScanNullUUID(&decidedBy), &decidedAt, &req.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan access request: %w", err)
+		}
+		if decidedBy != nil {
+			req.DecidedByUserID = *decidedBy
+		}
+		if decidedAt.Valid {
+			t := decidedAt.Time.UTC()
+			req.DecidedAt = &t
+		}
+		requests = append(requests, req)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate access requests: %w", err)
+	}
+	return requests, nil
+}
+
+func (r *MySQLSharingRepository) CreateShareInvitation(ctx context.Context, inv domain.ShareInvitation) (domain.ShareInvitation, error) {
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO share_invitations (id, item_id, shared_by_user_id, email, permissions, status, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, mysqlUUID(inv.ID), mysqlUUID(inv.ItemID), mysqlUUID(inv.SharedByUserID), inv.Email, inv.Permissions, domain.ShareInvitationPending, inv.ExpiresAt.UTC())
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.ShareInvitation{}, domain.ErrShareInvitationExists
+		}
+		return domain.ShareInvitation{}, fmt.Errorf("create share invitation: %w", err)
+	}
+	return r.GetShareInvitation(ctx, inv.ID)
+}
+
+func (r *MySQLSharingRepository) GetShareInvitation(ctx context.Context, id string) (domain.ShareInvitation, error) {
+	invitations, err := r.queryShareInvitations(ctx, `
+		SELECT `+shareInvitationColumns+` FROM share_invitations WHERE id = $1
+	`, mysqlUUID(id))
+	if err != nil {
+		return domain.ShareInvitation{}, fmt.Errorf("get share invitation: %w", err)
+	}
+	if len(invitations) == 0 {
+		return domain.ShareInvitation{}, domain.ErrShareInvitationNotFound
+	}
+	return invitations[0], nil
+}
+
+func (r *MySQLSharingRepository) ListShareInvitationsBySharer(ctx context.Context, sharedByUserID string) ([]domain.ShareInvitation, error) {
+	invitations, err := r.queryShareInvitations(ctx, `
+		SELECT `+shareInvitationColumns+` FROM share_invitations
+		WHERE shared_by_user_id = $1
+		ORDER BY created_at DESC, id
+	`, mysqlUUID(sharedByUserID))
+	if err != nil {
+		return nil, fmt.Errorf("list share invitations: %w", err)
+	}
+	return invitations, nil
+}
+
+func (r *MySQLSharingRepository) ListPendingShareInvitationsByEmail(ctx context.Context, email string, now time.Time) ([]domain.ShareInvitation, error) {
+	invitations, err := r.queryShareInvitations(ctx, `
+		SELECT `+shareInvitationColumns+` FROM share_invitations
+		WHERE email = $1 AND status = $2 AND expires_at > $3
+		ORDER BY created_at DESC, id
+	`, email, domain.ShareInvitationPending, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("list pending share invitations: %w", err)
+	}
+	return invitations, nil
+}
+
+func (r *MySQLSharingRepository) MarkShareInvitationReady(ctx context.Context, id, recipientUserID string, readyAt time.Time) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE share_invitations SET status = $2, recipient_user_id = $3, ready_at = $4
+		WHERE id = $1 AND status = $5
+	`, mysqlUUID(id), domain.ShareInvitationReady, mysqlUUID(recipientUserID), readyAt.UTC(), domain.ShareInvitationPending)
+	if err != nil {
+		return fmt.Errorf("mark share invitation ready: %w", err)
+	}
+	return shareInvitationAffected(result)
+}
+
+func (r *MySQLSharingRepository) DeleteShareInvitation(ctx context.Context, id, sharedByUserID string) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM share_invitations WHERE id = $1 AND shared_by_user_id = $2
+	`, mysqlUUID(id), mysqlUUID(sharedByUserID))
+	if err != nil {
+		return fmt.Errorf("delete share invitation: %w", err)
+	}
+	return shareInvitationAffected(result)
+}
+
+func (r *MySQLSharingRepository) DeleteExpiredShareInvitations(ctx context.Context, now time.Time) (int64, error) {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `DELETE FROM share_invitations WHERE expires_at <= $1`, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete expired share invitations: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *MySQLSharingRepository) queryShareInvitations(ctx context.Context, query string, args ...any) ([]domain.ShareInvitation, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := make([]domain.ShareInvitation, 0)
+	for rows.Next() {
+		var inv domain.ShareInvitation
+		var recipient *string
+		var readyAt sql.NullTime
+		err := rows.Scan(
+			mysqlScanUUID(&inv.ID), mysqlScanUUID(&inv.ItemID), mysqlScanUUID(&inv.SharedByUserID), &inv.Email, &inv.Permissions, &inv.Status,
+			mysqlScanNullUUID(&recipient), &readyAt, &inv.ExpiresAt, &inv.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan share invitation: %w", err)
+		}
+		if recipient != nil {
+			inv.RecipientUserID = *recipient
+		}
+		if readyAt.Valid {
+			t := readyAt.Time.UTC()
+			inv.ReadyAt = &t
+		}
+		invitations = append(invitations, inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate share invitations: %w", err)
+	}
+	return invitations, nil
+}
+
+func (r *MySQLSharingRepository) MarkShareAccessed(ctx context.Context, itemID, userID string, at time.Time) (bool, error) {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE vault_shares SET first_accessed_at = $3
+		WHERE item_id = $1 AND user_id = $2 AND first_accessed_at IS NULL
+	`, mysqlUUID(itemID), mysqlUUID(userID), at.UTC())
+	if err != nil {
+		return false, fmt.Errorf("mark share accessed: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (r *MySQLSharingRepository) GetItemAccessAlert(ctx context.Context, itemID string) (domain.ItemAccessAlert, error) {
+	var alert domain.ItemAccessAlert
+	err := mysqlFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT item_id, owner_user_id, email, created_at FROM vault_item_access_alerts WHERE item_id = $1
+	`, mysqlUUID(itemID)).Scan(mysqlScanUUID(&alert.ItemID), mysqlScanUUID(&alert.OwnerUserID), &alert.Email, &alert.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.ItemAccessAlert{}, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.ItemAccessAlert{}, fmt.Errorf("get item access alert: %w", err)
+	}
+	return alert, nil
+}
+
+func (r *MySQLSharingRepository) SetItemAccessAlert(ctx context.Context, alert domain.ItemAccessAlert) error {
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO vault_item_access_alerts (item_id, owner_user_id, email, created_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP(6))
+		ON DUPLICATE KEY UPDATE email = VALUES(email)
+	`, mysqlUUID(alert.ItemID), mysqlUUID(alert.OwnerUserID), alert.Email)
+	if err != nil {
+		return fmt.Errorf("set item access alert: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLSharingRepository) DeleteItemAccessAlert(ctx context.Context, itemID string) error {
+	if _, err := mysqlFor(ctx, r.db).ExecContext(ctx, `DELETE FROM vault_item_access_alerts WHERE item_id = $1`, mysqlUUID(itemID)); err != nil {
+		return fmt.Errorf("delete item access alert: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MySQLUserKeysRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLUserKeysRepository(db *sql.DB) *MySQLUserKeysRepository {
+	return &MySQLUserKeysRepository{db: db}
+}
+
+func (r *MySQLUserKeysRepository) UpsertKeys(ctx context.Context, input domain.UpsertUserKeysInput) error {
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO user_keys (user_id, public_key_x25519, public_key_ed25519, encrypted_private_keys, nonce)
+		VALUES ($1, $2, $3, $4, $5)
+		ON DUPLICATE KEY UPDATE
+			public_key_x25519 = VALUES(public_key_x25519),
+			public_key_ed25519 = VALUES(public_key_ed25519),
+			encrypted_private_keys = VALUES(encrypted_private_keys),
+			nonce = VALUES(nonce),
+			updated_at = NOW(6)
+	`, mysqlUUID(input.UserID), input.PublicKeyX25519, nullableBytes(input.PublicKeyEd25519), input.EncryptedPrivateKeys, input.Nonce)
+	if err != nil {
+		return fmt.Errorf("upsert user keys: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLUserKeysRepository) GetKeysByUserID(ctx context.Context, userID string) (domain.UserKeys, error) {
+	var keys domain.UserKeys
+	var edKey []byte
+	err := mysqlFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT user_id, public_key_x25519, public_key_ed25519, encrypted_private_keys, nonce, created_at, updated_at
+		FROM user_keys
+		WHERE user_id = $1
+	`, mysqlUUID(userID)).Scan(
+		mysqlScanUUID(&keys.UserID),
+		&keys.PublicKeyX25519,
+		&edKey,
+		&keys.EncryptedPrivateKeys,
+		&keys.Nonce,
+		&keys.CreatedAt,
+		&keys.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.UserKeys{}, domain.ErrNotFound
+		}
+		return domain.UserKeys{}, fmt.Errorf("get user keys: %w", err)
+	}
+	keys.PublicKeyEd25519 = edKey
+	return keys, nil
+}
+
+func (r *MySQLUserKeysRepository) GetPublicKeyByEmail(ctx context.Context, email string) (domain.UserKeys, string, error) {
+	var keys domain.UserKeys
+	var userID string
+	err := mysqlFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT uk.user_id, uk.public_key_x25519, uk.public_key_ed25519, uk.created_at, uk.updated_at
+		FROM user_keys uk
+		JOIN users u ON u.id = uk.user_id
+		WHERE u.email = $1
+	`, email).Scan(
+		mysqlScanUUID(&userID),
+		&keys.PublicKeyX25519,
+		&keys.PublicKeyEd25519,
+		&keys.CreatedAt,
+		&keys.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.UserKeys{}, "", domain.ErrRecipientKeysNotFound
+		}
+		return domain.UserKeys{}, "", fmt.Errorf("get public key by email: %w", err)
+	}
+	keys.UserID = userID
+	return keys, userID, nil
+}
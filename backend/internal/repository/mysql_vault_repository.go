@@ -0,0 +1,561 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/util"
+)
+
+type MySQLVaultRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLVaultRepository(db *sql.DB) *MySQLVaultRepository {
+	return &MySQLVaultRepository{db: db}
+}
+
+// mysqlVaultItemSelect reads the columns mysqlScanVaultItem expects.
+const mysqlVaultItemSelect = `
+	SELECT
+		vi.id, vi.owner_user_id, vi.folder_id, vi.ciphertext, vi.nonce,
+		vi.dek_wrapped, vi.wrap_nonce, vi.algo_version, vi.metadata,
+		EXISTS (SELECT 1 FROM vault_shares vs WHERE vs.item_id = vi.id) AS is_shared,
+		vi.version, vi.created_at, vi.updated_at, vi.deleted_at
+	FROM vault_items vi
+`
+
+func (r *MySQLVaultRepository) CreateVaultItem(ctx context.Context, input domain.CreateVaultItemInput) (domain.VaultItem, error) {
+	tx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return domain.VaultItem{}, fmt.Errorf("begin insert vault item tx: %w", err)
+	}
+	defer rollback()
+
+	item, err := r.insertVaultItem(ctx, mysqlConn{db: tx}, input)
+	if err != nil {
+		return domain.VaultItem{}, err
+	}
+
+	if err := commit(); err != nil {
+		return domain.VaultItem{}, fmt.Errorf("commit insert vault item tx: %w", err)
+	}
+	return item, nil
+}
+
+func (r *MySQLVaultRepository) CreateVaultItemsBulk(ctx context.Context, inputs []domain.CreateVaultItemInput) ([]domain.VaultItem, error) {
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+
+	tx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("begin bulk insert tx: %w", err)
+	}
+	defer rollback()
+
+	items := make([]domain.VaultItem, 0, len(inputs))
+	for _, input := range inputs {
+		item, err := r.insertVaultItem(ctx, mysqlConn{db: tx}, input)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	if err := commit(); err != nil {
+		return nil, fmt.Errorf("commit bulk insert tx: %w", err)
+	}
+	return items, nil
+}
+
+func (r *MySQLVaultRepository) insertVaultItem(ctx context.Context, tx dbtx, input domain.CreateVaultItemInput) (domain.VaultItem, error) {
+	itemID, err := util.NewUUID()
+	if err != nil {
+		return domain.VaultItem{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO vault_items (
+			id, owner_user_id, folder_id, ciphertext, nonce, dek_wrapped, wrap_nonce, algo_version, metadata, version
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 1)
+	`, mysqlUUID(itemID), mysqlUUID(input.OwnerUserID), mysqlNullUUID(input.FolderID), input.Ciphertext, input.Nonce, input.WrappedDEK, input.WrapNonce, input.AlgoVersion, mysqlJSON(input.Metadata)); err != nil {
+		return domain.VaultItem{}, fmt.Errorf("insert vault item: %w", err)
+	}
+
+	item, err := mysqlScanVaultItem(tx.QueryRowContext(ctx, mysqlVaultItemSelect+`WHERE vi.id = $1`, mysqlUUID(itemID)))
+	if err != nil {
+		return domain.VaultItem{}, fmt.Errorf("read inserted vault item: %w", err)
+	}
+	return item, nil
+}
+
+func (r *MySQLVaultRepository) ListVaultItemsByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultItem, error) {
+	return r.listVaultItemsByOwner(ctx, ownerUserID, false)
+}
+
+func (r *MySQLVaultRepository) ListDeletedVaultItemsByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultItem, error) {
+	return r.listVaultItemsByOwner(ctx, ownerUserID, true)
+}
+
+func (r *MySQLVaultRepository) listVaultItemsByOwner(ctx context.Context, ownerUserID string, deleted bool) ([]domain.VaultItem, error) {
+	deletedPredicate := "IS NULL"
+	orderBy := "vi.updated_at DESC"
+	if deleted {
+		// MySQL already sorts NULLs last in descending order.
+		deletedPredicate = "IS NOT NULL"
+		orderBy = "vi.deleted_at DESC, vi.updated_at DESC"
+	}
+
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, mysqlVaultItemSelect+fmt.Sprintf(`
+		WHERE vi.owner_user_id = $1
+		  AND vi.deleted_at %s
+		ORDER BY %s
+	`, deletedPredicate, orderBy), mysqlUUID(ownerUserID))
+	if err != nil {
+		return nil, fmt.Errorf("query vault items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]domain.VaultItem, 0)
+	for rows.Next() {
+		item, err := mysqlScanVaultItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan vault item: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate vault items: %w", err)
+	}
+	return items, nil
+}
+
+func (r *MySQLVaultRepository) ListVaultItemPage(ctx context.Context, ownerUserID string, query domain.VaultItemQuery) ([]domain.VaultItem, int, error) {
+	db := mysqlFor(ctx, r.db)
+	where, orderBy, args := vaultItemPageClauses(query, "vi.metadata->>'$.kind'", mysqlUUID)
+	args = append([]any{mysqlUUID(ownerUserID)}, args...)
+
+	var total int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM vault_items vi WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count vault items: %w", err)
+	}
+	rows, err := db.QueryContext(ctx, mysqlVaultItemSelect+fmt.Sprintf(`
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d
+	`, where, orderBy, len(args)+1, len(args)+2), append(args, query.Limit, query.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query vault item page: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]domain.VaultItem, 0)
+	for rows.Next() {
+		item, err := mysqlScanVaultItem(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scan vault item: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("iterate vault item page: %w", err)
+	}
+	return items, total, nil
+}
+
+func (r *MySQLVaultRepository) GetVaultItemByIDForOwner(ctx context.Context, itemID string, ownerUserID string) (domain.VaultItem, error) {
+	item, err := mysqlScanVaultItem(mysqlFor(ctx, r.db).QueryRowContext(ctx, mysqlVaultItemSelect+`
+		WHERE vi.id = $1 AND vi.owner_user_id = $2
+	`, mysqlUUID(itemID), mysqlUUID(ownerUserID)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.VaultItem{}, domain.ErrNotFound
+		}
+		return domain.VaultItem{}, fmt.Errorf("get vault item: %w", err)
+	}
+	return item, nil
+}
+
+func (r *MySQLVaultRepository) ListVaultItemVersionsByOwner(ctx context.Context, itemID string, ownerUserID string) ([]domain.VaultItemVersion, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT
+			viv.id, viv.item_id, viv.owner_user_id, viv.folder_id, viv.ciphertext, viv.nonce,
+			viv.dek_wrapped, viv.wrap_nonce, viv.algo_version, viv.metadata, viv.version, viv.created_at
+		FROM vault_item_versions viv
+		WHERE viv.item_id = $1 AND viv.owner_user_id = $2
+		ORDER BY viv.version DESC, viv.created_at DESC
+	`, mysqlUUID(itemID), mysqlUUID(ownerUserID))
+	if err != nil {
+		return nil, fmt.Errorf("list vault item versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make([]domain.VaultItemVersion, 0)
+	for rows.Next() {
+		version, err := mysqlScanVaultItemVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan vault item version: %w", err)
+		}
+		versions = append(versions, version)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate vault item versions: %w", err)
+	}
+	return versions, nil
+}
+
+func (r *MySQLVaultRepository) UpdateVaultItemForOwner(ctx context.Context, itemID string, ownerUserID string, input domain.UpdateVaultItemInput) (domain.VaultItem, error) {
+	sqlTx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return domain.VaultItem{}, fmt.Errorf("begin update vault item tx: %w", err)
+	}
+	defer rollback()
+	tx := mysqlConn{db: sqlTx}
+
+	current, err := mysqlScanVaultItem(tx.QueryRowContext(ctx, mysqlVaultItemSelect+`
+		WHERE vi.id = $1 AND vi.owner_user_id = $2 AND vi.deleted_at IS NULL
+		FOR UPDATE
+	`, mysqlUUID(itemID), mysqlUUID(ownerUserID)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.VaultItem{}, domain.ErrNotFound
+		}
+		return domain.VaultItem{}, fmt.Errorf("load vault item for update: %w", err)
+	}
+
+	if err := r.insertVaultItemVersion(ctx, tx, current); err != nil {
+		return domain.VaultItem{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE vault_items
+		SET
+			folder_id = $3,
+			ciphertext = $4,
+			nonce = $5,
+			dek_wrapped = $6,
+			wrap_nonce = $7,
+			algo_version = $8,
+			metadata = $9,
+			version = $10,
+			updated_at = NOW(6)
+		WHERE id = $1 AND owner_user_id = $2
+	`, mysqlUUID(itemID), mysqlUUID(ownerUserID), mysqlNullUUID(input.FolderID), input.Ciphertext, input.Nonce, input.WrappedDEK, input.WrapNonce, input.AlgoVersion, mysqlJSON(input.Metadata), current.Version+1); err != nil {
+		return domain.VaultItem{}, fmt.Errorf("update vault item: %w", err)
+	}
+
+	updated, err := mysqlScanVaultItem(tx.QueryRowContext(ctx, mysqlVaultItemSelect+`WHERE vi.id = $1`, mysqlUUID(itemID)))
+	if err != nil {
+		return domain.VaultItem{}, fmt.Errorf("read updated vault item: %w", err)
+	}
+
+	if err := commit(); err != nil {
+		return domain.VaultItem{}, fmt.Errorf("commit update vault item tx: %w", err)
+	}
+	return updated, nil
+}
+
+func (r *MySQLVaultRepository) DeleteVaultItemForOwner(ctx context.Context, itemID string, ownerUserID string) (bool, error) {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE vault_items
+		SET deleted_at = NOW(6), updated_at = NOW(6)
+		WHERE id = $1 AND owner_user_id = $2 AND deleted_at IS NULL
+	`, mysqlUUID(itemID), mysqlUUID(ownerUserID))
+	if err != nil {
+		return false, fmt.Errorf("delete vault item: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (r *MySQLVaultRepository) RestoreVaultItemForOwner(ctx context.Context, itemID string, ownerUserID string) (domain.VaultItem, error) {
+	sqlTx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return domain.VaultItem{}, fmt.Errorf("begin restore vault item tx: %w", err)
+	}
+	defer rollback()
+	tx := mysqlConn{db: sqlTx}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE vault_items
+		SET deleted_at = NULL, updated_at = NOW(6)
+		WHERE id = $1 AND owner_user_id = $2 AND deleted_at IS NOT NULL
+	`, mysqlUUID(itemID), mysqlUUID(ownerUserID))
+	if err != nil {
+		return domain.VaultItem{}, fmt.Errorf("restore vault item: %w", err)
+	}
+	if err := requireAffected(result); err != nil {
+		return domain.VaultItem{}, err
+	}
+
+	item, err := mysqlScanVaultItem(tx.QueryRowContext(ctx, mysqlVaultItemSelect+`WHERE vi.id = $1`, mysqlUUID(itemID)))
+	if err != nil {
+		return domain.VaultItem{}, fmt.Errorf("read restored vault item: %w", err)
+	}
+
+	if err := commit(); err != nil {
+		return domain.VaultItem{}, fmt.Errorf("commit restore vault item tx: %w", err)
+	}
+	return item, nil
+}
+
+// GetVaultRevision fingerprints the owner's vault like
+// VaultRepository.GetVaultRevision does.
+func (r *MySQLVaultRepository) GetVaultRevision(ctx context.Context, ownerUserID string) (string, error) {
+	var (
+		itemCount   int64
+		versionSum  int64
+		lastUpdated sql.NullTime
+		shareCount  int64
+		lastShareAt sql.NullTime
+		folderCount int64
+		lastFolder  sql.NullTime
+	)
+	err := mysqlFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(vi.version), 0),
+			MAX(vi.updated_at),
+			(SELECT COUNT(*) FROM vault_shares vs JOIN vault_items v ON v.id = vs.item_id WHERE v.owner_user_id = $1),
+			(SELECT MAX(vs.updated_at) FROM vault_shares vs JOIN vault_items v ON v.id = vs.item_id WHERE v.owner_user_id = $1),
+			(SELECT COUNT(*) FROM vault_folders vf WHERE vf.owner_user_id = $1),
+			(SELECT MAX(vf.updated_at) FROM vault_folders vf WHERE vf.owner_user_id = $1)
+		FROM vault_items vi
+		WHERE vi.owner_user_id = $1
+	`, mysqlUUID(ownerUserID)).Scan(&itemCount, &versionSum, &lastUpdated, &shareCount, &lastShareAt, &folderCount, &lastFolder)
+	if err != nil {
+		return "", fmt.Errorf("query vault revision: %w", err)
+	}
+
+	return fmt.Sprintf("%d.%d.%d.%d.%d.%d.%d", itemCount, versionSum, lastUpdated.Time.UnixMicro(), shareCount, lastShareAt.Time.UnixMicro(), folderCount, lastFolder.Time.UnixMicro()), nil
+}
+
+func (r *MySQLVaultRepository) PurgeDeletedVaultItems(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	sqlTx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return 0, fmt.Errorf("begin purge deleted vault items tx: %w", err)
+	}
+	defer rollback()
+	tx := mysqlConn{db: sqlTx}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT IGNORE INTO vault_item_tombstones (item_id, owner_user_id, deleted_at)
+		SELECT id, owner_user_id, NOW(6) FROM vault_items WHERE deleted_at IS NOT NULL AND deleted_at < $1
+	`, deletedBefore.UTC()); err != nil {
+		return 0, fmt.Errorf("record vault item tombstones: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM vault_items WHERE deleted_at IS NOT NULL AND deleted_at < $1
+	`, deletedBefore.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("purge deleted vault items: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	if err := commit(); err != nil {
+		return 0, fmt.Errorf("commit purge deleted vault items tx: %w", err)
+	}
+	return affected, nil
+}
+
+func (r *MySQLVaultRepository) ListVaultItemChangesByOwner(ctx context.Context, ownerUserID string, since time.Time) ([]domain.VaultItem, []string, error) {
+	db := mysqlFor(ctx, r.db)
+	rows, err := db.QueryContext(ctx, mysqlVaultItemSelect+`
+		WHERE vi.owner_user_id = $1 AND vi.updated_at > $2
+		ORDER BY vi.updated_at, vi.id
+	`, mysqlUUID(ownerUserID), since.UTC())
+	if err != nil {
+		return nil, nil, fmt.Errorf("query changed vault items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []domain.VaultItem
+	var deleted []string
+	for rows.Next() {
+		item, err := mysqlScanVaultItem(rows)
+		if err != nil {
+			return nil, nil, fmt.Errorf("scan vault item: %w", err)
+		}
+		if item.DeletedAt != nil {
+			deleted = append(deleted, item.ID)
+			continue
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("iterate changed vault items: %w", err)
+	}
+
+	purged, err := db.QueryContext(ctx, `
+		SELECT item_id FROM vault_item_tombstones
+		WHERE owner_user_id = $1 AND deleted_at > $2
+		ORDER BY deleted_at, item_id
+	`, mysqlUUID(ownerUserID), since.UTC())
+	if err != nil {
+		return nil, nil, fmt.Errorf("query vault item tombstones: %w", err)
+	}
+	defer purged.Close()
+	for purged.Next() {
+		var id string
+		if err := purged.Scan(mysqlScanUUID(&id)); err != nil {
+			return nil, nil, fmt.Errorf("scan vault item tombstone: %w", err)
+		}
+		deleted = append(deleted, id)
+	}
+	if err := purged.Err(); err != nil {
+		return nil, nil, fmt.Errorf("iterate vault item tombstones: %w", err)
+	}
+	return items, deleted, nil
+}
+
+func (r *MySQLVaultRepository) DeleteVaultItemTombstones(ctx context.Context, before time.Time) (int64, error) {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `DELETE FROM vault_item_tombstones WHERE deleted_at < $1`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete vault item tombstones: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *MySQLVaultRepository) RewrapVaultItemKeys(ctx context.Context, ownerUserID string, keys []domain.VaultItemKey) error {
+	if !uniqueItemKeys(keys) {
+		return domain.ErrRewrapIncomplete
+	}
+	sqlTx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return fmt.Errorf("begin rewrap vault item keys tx: %w", err)
+	}
+	defer rollback()
+	tx := mysqlConn{db: sqlTx}
+
+	var count int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM vault_items WHERE owner_user_id = $1
+	`, mysqlUUID(ownerUserID)).Scan(&count); err != nil {
+		return fmt.Errorf("count vault items: %w", err)
+	}
+	if count != len(keys) {
+		return domain.ErrRewrapIncomplete
+	}
+	for _, key := range keys {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE vault_items
+			SET dek_wrapped = $3, wrap_nonce = $4, updated_at = NOW(6)
+			WHERE id = $1 AND owner_user_id = $2
+		`, mysqlUUID(key.ItemID), mysqlUUID(ownerUserID), key.WrappedDEK, key.WrapNonce)
+		if err != nil {
+			return fmt.Errorf("rewrap vault item key: %w", err)
+		}
+		if err := requireAffected(result); err != nil {
+			return domain.ErrRewrapIncomplete
+		}
+	}
+
+	if err := commit(); err != nil {
+		return fmt.Errorf("commit rewrap vault item keys tx: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLVaultRepository) GetVaultSaltForUser(ctx context.Context, userID string) ([]byte, error) {
+	var salt []byte
+	err := mysqlFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT salt FROM auth_credentials WHERE user_id = $1
+	`, mysqlUUID(userID)).Scan(&salt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, fmt.Errorf("get vault salt: %w", err)
+	}
+	return salt, nil
+}
+
+func (r *MySQLVaultRepository) insertVaultItemVersion(ctx context.Context, tx dbtx, item domain.VaultItem) error {
+	versionID, err := util.NewUUID()
+	if err != nil {
+		return err
+	}
+	createdAt := item.UpdatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now().UTC()
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO vault_item_versions (
+			id, item_id, owner_user_id, folder_id, ciphertext, nonce, dek_wrapped, wrap_nonce, algo_version, metadata, version, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, mysqlUUID(versionID), mysqlUUID(item.ID), mysqlUUID(item.OwnerUserID), mysqlNullUUID(item.FolderID), item.Ciphertext, item.Nonce, item.WrappedDEK, item.WrapNonce, item.AlgoVersion, mysqlJSON(item.Metadata), item.Version, createdAt); err != nil {
+		return fmt.Errorf("insert vault item version: %w", err)
+	}
+	return nil
+}
+
+// mysqlScanVaultItem is scanVaultItem for BINARY(16) IDs.
+func mysqlScanVaultItem(scanner vaultItemScanner) (domain.VaultItem, error) {
+	var item domain.VaultItem
+	var metadata []byte
+	var deletedAt sql.NullTime
+	if err := scanner.Scan(
+		mysqlScanUUID(&item.ID),
+		mysqlScanUUID(&item.OwnerUserID),
+		mysqlScanNullUUID(&item.FolderID),
+		&item.Ciphertext,
+		&item.Nonce,
+		&item.WrappedDEK,
+		&item.WrapNonce,
+		&item.AlgoVersion,
+		&metadata,
+		&item.IsShared,
+		&item.Version,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+		&deletedAt,
+	); err != nil {
+		return domain.VaultItem{}, err
+	}
+	item.Metadata = metadata
+	if deletedAt.Valid {
+		t := deletedAt.Time.UTC()
+		item.DeletedAt = &t
+	}
+	return item, nil
+}
+
+// mysqlScanVaultItemVersion is scanVaultItemVersion for BINARY(16) IDs.
+func mysqlScanVaultItemVersion(scanner vaultItemScanner) (domain.VaultItemVersion, error) {
+	var version domain.VaultItemVersion
+	var metadata []byte
+	if err := scanner.Scan(
+		mysqlScanUUID(&version.ID),
+		mysqlScanUUID(&version.ItemID),
+		mysqlScanUUID(&version.OwnerUserID),
+		mysqlScanNullUUID(&version.FolderID),
+		&version.Ciphertext,
+		&version.Nonce,
+		&version.WrappedDEK,
+		&version.WrapNonce,
+		&version.AlgoVersion,
+		&metadata,
+		&version.Version,
+		&version.CreatedAt,
+	); err != nil {
+		return domain.VaultItemVersion{}, err
+	}
+	version.Metadata = metadata
+	return version, nil
+}
@@ -0,0 +1,359 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+)
+
+type MySQLWebhookRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLWebhookRepository(db *sql.DB) *MySQLWebhookRepository {
+	return &MySQLWebhookRepository{db: db}
+}
+
+func (r *MySQLWebhookRepository) CreateEndpoint(ctx context.Context, endpoint domain.WebhookEndpoint) (domain.WebhookEndpoint, error) {
+	eventTypes, err := json.Marshal(eventTypeStrings(endpoint.EventTypes))
+	if err != nil {
+		return domain.WebhookEndpoint{}, fmt.Errorf("encode webhook event types: %w", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	_, err = mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO webhook_endpoints (id, user_id, url, secret_enc, event_types, enabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+	`, mysqlUUID(endpoint.ID), mysqlUUID(endpoint.UserID), endpoint.URL, endpoint.SecretEnc, string(eventTypes), endpoint.Enabled, now)
+	if err != nil {
+		return domain.WebhookEndpoint{}, fmt.Errorf("insert webhook endpoint: %w", err)
+	}
+	endpoint.CreatedAt = now
+	endpoint.UpdatedAt = now
+	return endpoint, nil
+}
+
+func (r *MySQLWebhookRepository) ListEndpoints(ctx context.Context, userID string) ([]domain.WebhookEndpoint, error) {
+	return r.queryEndpoints(ctx, `
+		SELECT `+webhookEndpointColumns+`
+		FROM webhook_endpoints
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, mysqlUUID(userID))
+}
+
+func (r *MySQLWebhookRepository) ListEndpointsForEvent(ctx context.Context, userID string, eventType domain.EventType) ([]domain.WebhookEndpoint, error) {
+	return r.queryEndpoints(ctx, `
+		SELECT `+webhookEndpointColumns+`
+		FROM webhook_endpoints
+		WHERE user_id = $1 AND enabled
+		  AND (JSON_LENGTH(event_types) = 0 OR JSON_CONTAINS(event_types, JSON_QUOTE($2)))
+	`, mysqlUUID(userID), string(eventType))
+}
+
+func (r *MySQLWebhookRepository) GetEndpoint(ctx context.Context, userID, endpointID string) (domain.WebhookEndpoint, error) {
+	endpoints, err := r.queryEndpoints(ctx, `
+		SELECT `+webhookEndpointColumns+`
+		FROM webhook_endpoints
+		WHERE id = $1 AND user_id = $2
+	`, mysqlUUID(endpointID), mysqlUUID(userID))
+	if err != nil {
+		return domain.WebhookEndpoint{}, err
+	}
+	if len(endpoints) == 0 {
+		return domain.WebhookEndpoint{}, domain.ErrNotFound
+	}
+	return endpoints[0], nil
+}
+
+func (r *MySQLWebhookRepository) CountEndpoints(ctx context.Context, userID string) (int, error) {
+	var count int
+	if err := mysqlFor(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM webhook_endpoints WHERE user_id = $1`, mysqlUUID(userID)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count webhook endpoints: %w", err)
+	}
+	return count, nil
+}
+
+func (r *MySQLWebhookRepository) DeleteEndpoint(ctx context.Context, userID, endpointID string) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `DELETE FROM webhook_endpoints WHERE id = $1 AND user_id = $2`, mysqlUUID(endpointID), mysqlUUID(userID))
+	if err != nil {
+		return fmt.Errorf("delete webhook endpoint: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *MySQLWebhookRepository) queryEndpoints(ctx context.Context, query string, args ...any) ([]domain.WebhookEndpoint, error) {
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query webhook endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	endpoints := make([]domain.WebhookEndpoint, 0)
+	for rows.Next() {
+		var e domain.WebhookEndpoint
+		var eventTypes []byte
+		if err := rows.Scan(mysqlScanUUID(&e.ID), mysqlScanUUID(&e.UserID), &e.URL, &e.SecretEnc, &eventTypes, &e.Enabled, &e.CreatedAt, &e.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan webhook endpoint: %w", err)
+		}
+		if err := json.Unmarshal(eventTypes, &e.EventTypes); err != nil {
+			return nil, fmt.Errorf("decode webhook event types: %w", err)
+		}
+		if len(e.EventTypes) == 0 {
+			e.EventTypes = nil
+		}
+		endpoints = append(endpoints, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate webhook endpoints: %w", err)
+	}
+	return endpoints, nil
+}
+
+func (r *MySQLWebhookRepository) EnqueueDelivery(ctx context.Context, delivery domain.WebhookDelivery) error {
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT IGNORE INTO webhook_deliveries (id, endpoint_id, event_id, event_type, payload, status)
+		VALUES ($1, $2, $3, $4, $5, 'pending')
+	`, mysqlUUID(uuid.NewString()), mysqlUUID(delivery.EndpointID), mysqlUUID(delivery.EventID), string(delivery.EventType), string(delivery.Payload))
+	if err != nil {
+		return fmt.Errorf("insert webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// ClaimDueDeliveries locks the due deliveries with SKIP LOCKED, leases
+// them and returns them with their endpoints, all in one transaction.
+func (r *MySQLWebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	sqlTx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("begin claim webhook deliveries tx: %w", err)
+	}
+	defer rollback()
+	tx := mysqlConn{db: sqlTx}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT d.id, d.endpoint_id, d.event_id, d.event_type, d.payload, d.attempts, d.created_at,
+		       e.user_id, e.url, e.secret_enc
+		FROM webhook_deliveries d
+		JOIN webhook_endpoints e ON e.id = d.endpoint_id
+		WHERE d.status = 'pending' AND d.next_attempt_at <= NOW(6) AND e.enabled
+		ORDER BY d.created_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]domain.WebhookDelivery, 0)
+	ids := make([]any, 0)
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		var eventType string
+		var payload []byte
+		if err := rows.Scan(mysqlScanUUID(&d.ID), mysqlScanUUID(&d.EndpointID), mysqlScanUUID(&d.EventID), &eventType, &payload, &d.Attempts, &d.CreatedAt,
+			mysqlScanUUID(&d.Endpoint.UserID), &d.Endpoint.URL, &d.Endpoint.SecretEnc); err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		d.EventType = domain.EventType(eventType)
+		d.Payload = payload
+		d.Attempts++
+		d.Status = domain.WebhookDeliveryPending
+		d.Endpoint.ID = d.EndpointID
+		deliveries = append(deliveries, d)
+		ids = append(ids, mysqlUUID(d.ID))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate webhook deliveries: %w", err)
+	}
+
+	if len(ids) > 0 {
+		args := append([]any{time.Now().UTC().Add(lease)}, ids...)
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`
+			UPDATE webhook_deliveries
+			SET next_attempt_at = $1, attempts = attempts + 1
+			WHERE id IN (%s)
+		`, mysqlPlaceholders(2, len(ids))), args...); err != nil {
+			return nil, fmt.Errorf("lease webhook deliveries: %w", err)
+		}
+	}
+
+	if err := commit(); err != nil {
+		return nil, fmt.Errorf("commit claim webhook deliveries tx: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (r *MySQLWebhookRepository) RecordAttempt(ctx context.Context, deliveryID string, attempt domain.WebhookDeliveryAttempt) error {
+	var statusCode sql.NullInt32
+	if attempt.StatusCode > 0 {
+		statusCode = sql.NullInt32{Int32: int32(attempt.StatusCode), Valid: true}
+	}
+	var attemptErr sql.NullString
+	if attempt.Error != "" {
+		attemptErr = sql.NullString{String: attempt.Error, Valid: true}
+	}
+
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO webhook_delivery_attempts (id, delivery_id, status_code, error, duration_ms, attempted_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, mysqlUUID(uuid.NewString()), mysqlUUID(deliveryID), statusCode, attemptErr, attempt.DurationMS, attempt.AttemptedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("insert webhook delivery attempt: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLWebhookRepository) MarkDelivered(ctx context.Context, deliveryID string) error {
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE webhook_deliveries SET status = 'succeeded', delivered_at = NOW(6), last_error = NULL WHERE id = $1
+	`, mysqlUUID(deliveryID))
+	if err != nil {
+		return fmt.Errorf("mark webhook delivery delivered: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLWebhookRepository) MarkRetry(ctx context.Context, deliveryID string, lastError string, nextAttemptAt time.Time) error {
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE webhook_deliveries SET last_error = $2, next_attempt_at = $3 WHERE id = $1
+	`, mysqlUUID(deliveryID), lastError, nextAttemptAt.UTC())
+	if err != nil {
+		return fmt.Errorf("schedule webhook delivery retry: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLWebhookRepository) MarkDead(ctx context.Context, deliveryID string, lastError string) error {
+	_, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE webhook_deliveries SET status = 'dead', last_error = $2 WHERE id = $1
+	`, mysqlUUID(deliveryID), lastError)
+	if err != nil {
+		return fmt.Errorf("mark webhook delivery dead: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLWebhookRepository) ListDeliveries(ctx context.Context, endpointID string, limit, offset int) ([]domain.WebhookDelivery, int, error) {
+	var total int
+	if err := mysqlFor(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM webhook_deliveries WHERE endpoint_id = $1`, mysqlUUID(endpointID)).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count webhook deliveries: %w", err)
+	}
+
+	rows, err := mysqlFor(ctx, r.db).QueryContext(ctx, `
+		SELECT id, endpoint_id, event_id, event_type, payload, status, attempts, COALESCE(last_error, ''), next_attempt_at, delivered_at, created_at
+		FROM webhook_deliveries
+		WHERE endpoint_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, mysqlUUID(endpointID), limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]domain.WebhookDelivery, 0)
+	index := make(map[string]int)
+	ids := make([]any, 0)
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		var eventType string
+		var payload []byte
+		var deliveredAt sql.NullTime
+		if err := rows.Scan(mysqlScanUUID(&d.ID), mysqlScanUUID(&d.EndpointID), mysqlScanUUID(&d.EventID), &eventType, &payload, &d.Status, &d.Attempts, &d.LastError, &d.NextAttemptAt, &deliveredAt, &d.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		d.EventType = domain.EventType(eventType)
+		d.Payload = payload
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.Time
+		}
+		index[d.ID] = len(deliveries)
+		ids = append(ids, mysqlUUID(d.ID))
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("iterate webhook deliveries: %w", err)
+	}
+	if len(ids) == 0 {
+		return deliveries, total, nil
+	}
+
+	attemptRows, err := mysqlFor(ctx, r.db).QueryContext(ctx, fmt.Sprintf(`
+		SELECT delivery_id, COALESCE(status_code, 0), COALESCE(error, ''), duration_ms, attempted_at
+		FROM webhook_delivery_attempts
+		WHERE delivery_id IN (%s)
+		ORDER BY attempted_at
+	`, mysqlPlaceholders(1, len(ids))), ids...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list webhook delivery attempts: %w", err)
+	}
+	defer attemptRows.Close()
+
+	for attemptRows.Next() {
+		var deliveryID string
+		var a domain.WebhookDeliveryAttempt
+		if err := attemptRows.Scan(mysqlScanUUID(&deliveryID), &a.StatusCode, &a.Error, &a.DurationMS, &a.AttemptedAt); err != nil {
+			return nil, 0, fmt.Errorf("scan webhook delivery attempt: %w", err)
+		}
+		if i, ok := index[deliveryID]; ok {
+			deliveries[i].AttemptLog = append(deliveries[i].AttemptLog, a)
+		}
+	}
+	if err := attemptRows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("iterate webhook delivery attempts: %w", err)
+	}
+	return deliveries, total, nil
+}
+
+func (r *MySQLWebhookRepository) Redeliver(ctx context.Context, endpointID, deliveryID string) error {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW(6), delivered_at = NULL
+		WHERE id = $1 AND endpoint_id = $2 AND status <> 'pending'
+	`, mysqlUUID(deliveryID), mysqlUUID(endpointID))
+	if err != nil {
+		return fmt.Errorf("redeliver webhook delivery: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("read rows affected: %w", err)
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	var status string
+	err = mysqlFor(ctx, r.db).QueryRowContext(ctx, `SELECT status FROM webhook_deliveries WHERE id = $1 AND endpoint_id = $2`, mysqlUUID(deliveryID), mysqlUUID(endpointID)).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("load webhook delivery: %w", err)
+	}
+	return domain.ErrWebhookDeliveryActive
+}
+
+func (r *MySQLWebhookRepository) DeleteDeliveriesBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := mysqlFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM webhook_deliveries WHERE status <> 'pending' AND created_at < $1
+	`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete webhook deliveries: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected, nil
+}
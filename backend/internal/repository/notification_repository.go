@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"pmv2/backend/internal/domain"
+)
+
+type NotificationRepository struct {
+	db *sql.DB
+}
+
+func NewNotificationRepository(db *sql.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+func (r *NotificationRepository) GetPreferences(ctx context.Context, userID string) (domain.NotificationPreferences, error) {
+	prefs := domain.NotificationPreferences{UserID: userID}
+	var raw []byte
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT preferences, updated_at FROM notification_preferences WHERE user_id = $1
+	`, userID).Scan(&raw, &prefs.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.NotificationPreferences{}, domain.ErrNotFound
+		}
+		return domain.NotificationPreferences{}, fmt.Errorf("get notification preferences: %w", err)
+	}
+
+	if err := json.Unmarshal(raw, &prefs.Categories); err != nil {
+		return domain.NotificationPreferences{}, fmt.Errorf("decode notification preferences: %w", err)
+	}
+	return prefs, nil
+}
+
+func (r *NotificationRepository) SavePreferences(ctx context.Context, prefs domain.NotificationPreferences) (domain.NotificationPreferences, error) {
+	raw, err := json.Marshal(prefs.Categories)
+	if err != nil {
+		return domain.NotificationPreferences{}, fmt.Errorf("encode notification preferences: %w", err)
+	}
+
+	err = dbFor(ctx, r.db).QueryRowContext(ctx, `
+		INSERT INTO notification_preferences (user_id, preferences, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET preferences = EXCLUDED.preferences, updated_at = NOW()
+		RETURNING updated_at
+	`, prefs.UserID, raw).Scan(&prefs.UpdatedAt)
+	if err != nil {
+		return domain.NotificationPreferences{}, fmt.Errorf("save notification preferences: %w", err)
+	}
+	return prefs, nil
+}
+
+func (r *NotificationRepository) GetRecipient(ctx context.Context, userID string) (domain.NotificationRecipient, error) {
+	var recipient domain.NotificationRecipient
+	var name sql.NullString
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT email, name FROM users WHERE id = $1
+	`, userID).Scan(&recipient.Email, &name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.NotificationRecipient{}, domain.ErrNotFound
+		}
+		return domain.NotificationRecipient{}, fmt.Errorf("get notification recipient: %w", err)
+	}
+	recipient.Name = name.String
+	return recipient, nil
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type OutboxRepository struct {
+	db *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// Enqueue writes the event in the caller's transaction when there is one, so
+// it is committed atomically with the business change it describes.
+func (r *OutboxRepository) Enqueue(ctx context.Context, topic string, payload json.RawMessage) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO outbox_events (id, topic, payload, created_at, next_attempt_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+	`, uuid.NewString(), topic, []byte(payload))
+	if err != nil {
+		return fmt.Errorf("insert outbox event: %w", err)
+	}
+	return nil
+}
+
+func (r *OutboxRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxEvent, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		UPDATE outbox_events
+		SET next_attempt_at = NOW() + make_interval(secs => $2), attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE dispatched_at IS NULL AND failed_at IS NULL AND next_attempt_at <= NOW()
+			ORDER BY created_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, topic, payload, attempts, created_at
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]domain.OutboxEvent, 0)
+	for rows.Next() {
+		var e domain.OutboxEvent
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.Topic, &payload, &e.Attempts, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan outbox event: %w", err)
+		}
+		e.Payload = payload
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate outbox events: %w", err)
+	}
+	return events, nil
+}
+
+func (r *OutboxRepository) MarkDispatched(ctx context.Context, id string) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE outbox_events
+		SET dispatched_at = NOW(), last_error = NULL,
+		    payload = CASE WHEN topic = ANY($2) THEN '{}'::jsonb ELSE payload END
+		WHERE id = $1
+	`, id, pq.Array(domain.SensitiveOutboxTopics))
+	if err != nil {
+		return fmt.Errorf("mark outbox event dispatched: %w", err)
+	}
+	return nil
+}
+
+func (r *OutboxRepository) MarkRetry(ctx context.Context, id string, lastError string, nextAttemptAt time.Time) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE outbox_events SET last_error = $2, next_attempt_at = $3 WHERE id = $1
+	`, id, lastError, nextAttemptAt)
+	if err != nil {
+		return fmt.Errorf("schedule outbox event retry: %w", err)
+	}
+	return nil
+}
+
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id string, lastError string) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE outbox_events
+		SET last_error = $2, failed_at = NOW(),
+		    payload = CASE WHEN topic = ANY($3) THEN '{}'::jsonb ELSE payload END
+		WHERE id = $1
+	`, id, lastError, pq.Array(domain.SensitiveOutboxTopics))
+	if err != nil {
+		return fmt.Errorf("mark outbox event failed: %w", err)
+	}
+	return nil
+}
+
+func (r *OutboxRepository) DeleteDispatchedBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM outbox_events WHERE dispatched_at IS NOT NULL AND dispatched_at < $1
+	`, before)
+	if err != nil {
+		return 0, fmt.Errorf("delete dispatched outbox events: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected, nil
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+const passkeyColumns = `id, user_id, credential_id, public_key, sign_count, name, prf_salt, wrapped_key, wrap_nonce, created_at, last_used_at`
+
+type PasskeyRepository struct {
+	db *sql.DB
+}
+
+func NewPasskeyRepository(db *sql.DB) *PasskeyRepository {
+	return &PasskeyRepository{db: db}
+}
+
+func (r *PasskeyRepository) CreatePasskeyChallenge(ctx context.Context, challenge domain.PasskeyChallenge) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO passkey_challenges (id, purpose, user_id, email, name, challenge, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, challenge.ID, challenge.Purpose, nullableText(challenge.UserID), challenge.Email, challenge.Name, challenge.Challenge, challenge.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("insert passkey challenge: %w", err)
+	}
+	return nil
+}
+
+func (r *PasskeyRepository) ConsumePasskeyChallenge(ctx context.Context, id string, purpose domain.PasskeyChallengePurpose, now time.Time) (domain.PasskeyChallenge, error) {
+	c := domain.PasskeyChallenge{ID: id, Purpose: purpose}
+	var userID sql.NullString
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		DELETE FROM passkey_challenges
+		WHERE id = $1 AND purpose = $2 AND expires_at > $3
+		RETURNING user_id, email, name, challenge, expires_at
+	`, id, purpose, now).Scan(&userID, &c.Email, &c.Name, &c.Challenge, &c.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.PasskeyChallenge{}, domain.ErrNotFound
+		}
+		return domain.PasskeyChallenge{}, fmt.Errorf("consume passkey challenge: %w", err)
+	}
+	c.UserID = userID.String
+	return c, nil
+}
+
+func (r *PasskeyRepository) DeleteExpiredPasskeyChallenges(ctx context.Context, before time.Time) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM passkey_challenges WHERE expires_at <= $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired passkey challenges: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// CreatePasskey reads the row back rather than using RETURNING, so that a
+// duplicate credential is reported by the insert itself.
+func (r *PasskeyRepository) CreatePasskey(ctx context.Context, p domain.Passkey) (domain.Passkey, error) {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO passkeys (id, user_id, credential_id, public_key, sign_count, name, prf_salt, wrapped_key, wrap_nonce)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, p.ID, p.UserID, p.CredentialID, p.PublicKey, int64(p.SignCount), p.Name,
+		nullableBytes(p.PRFSalt), nullableBytes(p.WrappedKey), nullableBytes(p.WrapNonce))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.Passkey{}, domain.ErrPasskeyTaken
+		}
+		return domain.Passkey{}, fmt.Errorf("insert passkey: %w", err)
+	}
+	return r.GetPasskeyByCredentialID(ctx, p.CredentialID)
+}
+
+func (r *PasskeyRepository) GetPasskeyByCredentialID(ctx context.Context, credentialID []byte) (domain.Passkey, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+passkeyColumns+` FROM passkeys WHERE credential_id = $1
+	`, credentialID)
+	if err != nil {
+		return domain.Passkey{}, fmt.Errorf("get passkey: %w", err)
+	}
+	passkeys, err := scanPasskeys(rows)
+	if err != nil {
+		return domain.Passkey{}, err
+	}
+	if len(passkeys) == 0 {
+		return domain.Passkey{}, domain.ErrNotFound
+	}
+	return passkeys[0], nil
+}
+
+func (r *PasskeyRepository) ListPasskeys(ctx context.Context, userID string) ([]domain.Passkey, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+passkeyColumns+` FROM passkeys
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list passkeys: %w", err)
+	}
+	return scanPasskeys(rows)
+}
+
+func (r *PasskeyRepository) TouchPasskey(ctx context.Context, id string, signCount uint32, usedAt time.Time) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE passkeys SET sign_count = $2, last_used_at = $3 WHERE id = $1
+	`, id, int64(signCount), usedAt)
+	if err != nil {
+		return fmt.Errorf("touch passkey: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *PasskeyRepository) DeletePasskey(ctx context.Context, userID, id string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM passkeys WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("delete passkey: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *PasskeyRepository) DeleteAllPasskeys(ctx context.Context, userID string) error {
+	if _, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM passkeys WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("delete passkeys: %w", err)
+	}
+	return nil
+}
+
+func scanPasskeys(rows *sql.Rows) ([]domain.Passkey, error) {
+	defer rows.Close()
+	passkeys := make([]domain.Passkey, 0)
+	for rows.Next() {
+		var p domain.Passkey
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&p.ID, &p.UserID, &p.CredentialID, &p.PublicKey, &p.SignCount, &p.Name,
+			&p.PRFSalt, &p.WrappedKey, &p.WrapNonce, &p.CreatedAt, &lastUsed); err != nil {
+			return nil, fmt.Errorf("scan passkey: %w", err)
+		}
+		if lastUsed.Valid {
+			t := lastUsed.Time.UTC()
+			p.LastUsedAt = &t
+		}
+		passkeys = append(passkeys, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate passkeys: %w", err)
+	}
+	return passkeys, nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type PasswordResetRepository struct {
+	db *sql.DB
+}
+
+func NewPasswordResetRepository(db *sql.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{db: db}
+}
+
+func (r *PasswordResetRepository) CreatePasswordReset(ctx context.Context, token domain.PasswordResetToken) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO password_reset_tokens (id, user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, token.ID, token.UserID, token.TokenHash, token.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("insert password reset token: %w", err)
+	}
+	return nil
+}
+
+func (r *PasswordResetRepository) GetPasswordReset(ctx context.Context, tokenHash []byte, now time.Time) (domain.PasswordResetToken, error) {
+	token := domain.PasswordResetToken{TokenHash: tokenHash}
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT id, user_id, expires_at, created_at
+		FROM password_reset_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+	`, tokenHash, now).Scan(&token.ID, &token.UserID, &token.ExpiresAt, &token.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.PasswordResetToken{}, domain.ErrNotFound
+		}
+		return domain.PasswordResetToken{}, fmt.Errorf("get password reset token: %w", err)
+	}
+	return token, nil
+}
+
+func (r *PasswordResetRepository) CountPasswordResets(ctx context.Context, userID string, since time.Time) (int, error) {
+	var count int
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM password_reset_tokens WHERE user_id = $1 AND created_at > $2
+	`, userID, since).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count password reset tokens: %w", err)
+	}
+	return count, nil
+}
+
+func (r *PasswordResetRepository) UsePasswordReset(ctx context.Context, id, userID string, now time.Time) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE password_reset_tokens SET used_at = $2 WHERE id = $1 AND used_at IS NULL
+	`, id, now)
+	if err != nil {
+		return fmt.Errorf("use password reset token: %w", err)
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+	if _, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE password_reset_tokens SET used_at = $2 WHERE user_id = $1 AND used_at IS NULL
+	`, userID, now); err != nil {
+		return fmt.Errorf("use other password reset tokens: %w", err)
+	}
+	return nil
+}
+
+func (r *PasswordResetRepository) DeleteExpiredPasswordResets(ctx context.Context, before time.Time) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE expires_at <= $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired password reset tokens: %w", err)
+	}
+	return result.RowsAffected()
+}
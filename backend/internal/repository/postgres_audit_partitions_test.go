@@ -0,0 +1,53 @@
+package repository_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+func TestPostgresAuditPartitions(t *testing.T) {
+	db := openPostgres(t)
+	repo := repository.NewAuditRepository(db)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	// The migration created this month's partition and the next ones.
+	if created, err := repo.EnsurePartitions(ctx, now, domain.AuditPartitionsAhead); err != nil || len(created) != 0 {
+		t.Fatalf("EnsurePartitions after migrating = %v, %v; want nothing to create", created, err)
+	}
+	old := month.AddDate(0, -14, 0)
+	created, err := repo.EnsurePartitions(ctx, old, 1)
+	if want := []string{"audit_events_" + old.Format("2006_01")}; err != nil || !slices.Equal(created, want) {
+		t.Fatalf("EnsurePartitions(%s) = %v, %v; want %v", old, created, err, want)
+	}
+
+	for _, createdAt := range []time.Time{old.Add(time.Hour), now} {
+		event := domain.AuditEvent{ID: uuid.New(), EventType: domain.EventTypeAuthLoginSuccess, CreatedAt: createdAt}
+		// Redelivered events are written once.
+		for range 2 {
+			if err := repo.CreateEvent(ctx, event); err != nil {
+				t.Fatalf("create event: %v", err)
+			}
+		}
+	}
+	events, total, err := repo.ListEvents(ctx, 10, 0, domain.AuditFilter{})
+	if err != nil || total != 2 || len(events) != 2 {
+		t.Fatalf("events = %d (total %d), %v; want 2", len(events), total, err)
+	}
+
+	dropped, err := repo.DropPartitionsBefore(ctx, month.AddDate(0, -12, 0))
+	if want := []string{"audit_events_" + old.Format("2006_01")}; err != nil || !slices.Equal(dropped, want) {
+		t.Fatalf("DropPartitionsBefore = %v, %v; want %v", dropped, err, want)
+	}
+	if _, total, err := repo.ListEvents(ctx, 10, 0, domain.AuditFilter{}); err != nil || total != 1 {
+		t.Fatalf("events after dropping = %d, %v; want this month's only", total, err)
+	}
+}
@@ -0,0 +1,79 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/repository"
+)
+
+// TestPostgresVaultRowLevelSecurity checks that the vault policies fail
+// closed: a query that runs without app.current_user_id sees no rows, not
+// everyone's. Superusers and BYPASSRLS roles skip the policies, so when the
+// test connects as one it checks them as a scratch role instead.
+func TestPostgresVaultRowLevelSecurity(t *testing.T) {
+	conn := openPostgres(t)
+	seedQueryPlanData(t, conn)
+	ctx := context.Background()
+	// User 2 owns 50 items, none of them in the trash.
+	owner := seededUserID(t, conn, 2)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	var skipsPolicies bool
+	if err := tx.QueryRowContext(ctx, `SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user`).Scan(&skipsPolicies); err != nil {
+		t.Fatalf("read role: %v", err)
+	}
+	if skipsPolicies {
+		if _, err := tx.ExecContext(ctx, `
+			CREATE ROLE pmv2_rls_probe NOLOGIN;
+			GRANT SELECT ON vault_items, vault_shares TO pmv2_rls_probe;
+			SET LOCAL ROLE pmv2_rls_probe;
+		`); err != nil {
+			t.Fatalf("switch to a role the policies bind: %v", err)
+		}
+	}
+
+	count := func(setting, value string) int {
+		t.Helper()
+		if setting != "" {
+			if _, err := tx.ExecContext(ctx, `SELECT set_config($1, $2, true)`, setting, value); err != nil {
+				t.Fatalf("set %s: %v", setting, err)
+			}
+		}
+		var n int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM vault_items`).Scan(&n); err != nil {
+			t.Fatalf("count vault items: %v", err)
+		}
+		return n
+	}
+	if n := count("", ""); n != 0 {
+		t.Fatalf("no owner set: %d vault items visible, want none", n)
+	}
+	if n := count("app.current_user_id", owner); n != 50 {
+		t.Fatalf("owner set: %d vault items visible, want their 50", n)
+	}
+	if n := count("app.current_user_id", ""); n != 0 {
+		t.Fatalf("owner cleared: %d vault items visible, want none", n)
+	}
+	if n := count("app.rls_bypass", "on"); n != 10000 {
+		t.Fatalf("bypass on: %d vault items visible, want all 10000", n)
+	}
+
+	// The repositories scope owner calls and mark the jobs that span users.
+	repo := repository.NewVaultRepository(conn)
+	items, err := repo.ListVaultItemsByOwner(ctx, owner)
+	if err != nil || len(items) != 50 {
+		t.Fatalf("ListVaultItemsByOwner = %d items, %v; want the owner's 50", len(items), err)
+	}
+	purged, err := repo.PurgeDeletedVaultItems(ctx, time.Now().Add(time.Minute))
+	if err != nil || purged != 400 {
+		t.Fatalf("PurgeDeletedVaultItems = %d, %v; want every user's 400 trashed items", purged, err)
+	}
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type QRLoginRepository struct {
+	db *sql.DB
+}
+
+func NewQRLoginRepository(db *sql.DB) *QRLoginRepository {
+	return &QRLoginRepository{db: db}
+}
+
+func (r *QRLoginRepository) CreateQRLogin(ctx context.Context, req domain.QRLoginRequest) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO qr_login_requests (id, code_hash, poll_token_hash, status, device_name, ip_address, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, req.ID, req.CodeHash, req.PollTokenHash, domain.QRLoginPending, req.DeviceName, req.IPAddr, req.UserAgent, req.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("insert qr login request: %w", err)
+	}
+	return nil
+}
+
+func (r *QRLoginRepository) GetQRLogin(ctx context.Context, id string, now time.Time) (domain.QRLoginRequest, error) {
+	req := domain.QRLoginRequest{ID: id}
+	var userID sql.NullString
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT code_hash, poll_token_hash, status, user_id, device_name, ip_address, user_agent, expires_at, created_at
+		FROM qr_login_requests
+		WHERE id = $1 AND expires_at > $2
+	`, id, now).Scan(&req.CodeHash, &req.PollTokenHash, &req.Status, &userID, &req.DeviceName, &req.IPAddr, &req.UserAgent, &req.ExpiresAt, &req.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.QRLoginRequest{}, domain.ErrNotFound
+		}
+		return domain.QRLoginRequest{}, fmt.Errorf("get qr login request: %w", err)
+	}
+	req.UserID = userID.String
+	return req, nil
+}
+
+func (r *QRLoginRepository) DecideQRLogin(ctx context.Context, id, userID string, status domain.QRLoginStatus, now time.Time) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE qr_login_requests SET status = $3, user_id = $2
+		WHERE id = $1 AND status = 'pending' AND expires_at > $4
+	`, id, userID, status, now)
+	if err != nil {
+		return fmt.Errorf("decide qr login request: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *QRLoginRepository) DeleteQRLogin(ctx context.Context, id string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM qr_login_requests WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete qr login request: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *QRLoginRepository) DeleteExpiredQRLogins(ctx context.Context, before time.Time) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM qr_login_requests WHERE expires_at <= $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired qr login requests: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// replicaRetryAfter is how long reads stay on the primary after the replica
+// fails before it is tried again.
+const replicaRetryAfter = 30 * time.Second
+
+// reader is the read-only subset of dbtx.
+type reader interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// readRouter sends reads to a replica. When a query fails there it is
+// retried on the primary, which also serves every read for the next
+// replicaRetryAfter.
+type readRouter struct {
+	primary *sql.DB
+	replica *sql.DB
+	now     func() time.Time
+	retryAt atomic.Int64 // unix nanoseconds; zero while the replica is healthy
+}
+
+func newReadRouter(primary, replica *sql.DB) *readRouter {
+	return &readRouter{primary: primary, replica: replica, now: time.Now}
+}
+
+func (r *readRouter) replicaUp() bool {
+	retryAt := r.retryAt.Load()
+	return retryAt == 0 || r.now().UnixNano() >= retryAt
+}
+
+func (r *readRouter) replicaRecovered() {
+	if r.retryAt.Load() != 0 {
+		r.retryAt.Store(0)
+	}
+}
+
+// replicaFailed takes the replica out of rotation unless err only reports
+// that the caller gave up.
+func (r *readRouter) replicaFailed(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	r.retryAt.Store(r.now().Add(replicaRetryAfter).UnixNano())
+	return true
+}
+
+func (r *readRouter) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if r.replicaUp() {
+		rows, err := r.replica.QueryContext(ctx, query, args...)
+		if err == nil {
+			r.replicaRecovered()
+			return rows, nil
+		}
+		if !r.replicaFailed(ctx, err) {
+			return nil, err
+		}
+	}
+	return r.primary.QueryContext(ctx, query, args...)
+}
+
+func (r *readRouter) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if r.replicaUp() {
+		row := r.replica.QueryRowContext(ctx, query, args...)
+		err := row.Err()
+		if err == nil {
+			r.replicaRecovered()
+			return row
+		}
+		if !r.replicaFailed(ctx, err) {
+			return row
+		}
+	}
+	return r.primary.QueryRowContext(ctx, query, args...)
+}
+
+// readFor returns where a pure read runs: the transaction carried by ctx,
+// so it sees the transaction's own writes, otherwise reads, or db when no
+// replica is configured. Replicas lag the primary; callers that must see a
+// write made moments ago check db when the replica finds nothing.
+func readFor(ctx context.Context, db *sql.DB, reads reader) reader {
+	if tx, ok := txFromContext(ctx); ok {
+		return tx
+	}
+	if reads == nil {
+		return db
+	}
+	return reads
+}
+
+// BeginTx begins a read-only transaction on the replica, or on the primary
+// while the replica is out of rotation or refuses it. Queries failing later
+// inside the transaction are not retried.
+func (r *readRouter) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	readOnly := sql.TxOptions{ReadOnly: true}
+	if opts != nil {
+		readOnly.Isolation = opts.Isolation
+	}
+	if r.replicaUp() {
+		tx, err := r.replica.BeginTx(ctx, &readOnly)
+		if err == nil {
+			r.replicaRecovered()
+			return tx, nil
+		}
+		if !r.replicaFailed(ctx, err) {
+			return nil, err
+		}
+	}
+	return r.primary.BeginTx(ctx, &readOnly)
+}
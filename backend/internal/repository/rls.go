@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// beginner starts transactions; *sql.DB and readRouter implement it.
+type beginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// asOwner runs fn with app.current_user_id set to ownerUserID, so the
+// Postgres row-level security policies on the vault tables (see
+// database.migratePostgresUp) hide every other user's rows from it even if
+// one of its queries forgets the owner predicate. The setting only lives as
+// long as a transaction: fn joins the one carried by ctx, where the previous
+// value is restored afterwards, or runs in one begun on db. Either way its
+// queries find the transaction through dbFor and readFor.
+func asOwner[T any](ctx context.Context, db beginner, ownerUserID string, fn func(ctx context.Context) (T, error)) (T, error) {
+	if ownerUserID == "" {
+		var zero T
+		return zero, errors.New("owner scope without a user id")
+	}
+	return withSetting(ctx, db, "app.current_user_id", ownerUserID, fn)
+}
+
+// asSystem runs fn with app.rls_bypass on, for the queries that are not
+// about one owner: retention jobs, admin tooling and share lookups. The
+// policies hide every row while no owner is set, so anything that reads
+// or changes the vault tables outside asOwner has to say so here.
+func asSystem[T any](ctx context.Context, db beginner, fn func(ctx context.Context) (T, error)) (T, error) {
+	return withSetting(ctx, db, "app.rls_bypass", "on", fn)
+}
+
+// withSetting runs fn with the custom setting name set to value for the
+// transaction carried by ctx, restoring the previous value afterwards, or
+// for one begun on db.
+func withSetting[T any](ctx context.Context, db beginner, name, value string, fn func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	if tx, ok := txFromContext(ctx); ok {
+		var previous string
+		if err := tx.QueryRowContext(ctx, `SELECT COALESCE(current_setting($1, true), '')`, name).Scan(&previous); err != nil {
+			return zero, fmt.Errorf("read %s: %w", name, err)
+		}
+		if err := setLocal(ctx, tx, name, value); err != nil {
+			return zero, err
+		}
+		result, err := fn(ctx)
+		if err != nil {
+			return zero, err
+		}
+		if err := setLocal(ctx, tx, name, previous); err != nil {
+			return zero, err
+		}
+		return result, nil
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return zero, fmt.Errorf("begin %s tx: %w", name, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := setLocal(ctx, tx, name, value); err != nil {
+		return zero, err
+	}
+	result, err := fn(context.WithValue(ctx, txContextKey{}, tx))
+	if err != nil {
+		return zero, err
+	}
+	if err := tx.Commit(); err != nil {
+		return zero, fmt.Errorf("commit %s tx: %w", name, err)
+	}
+	return result, nil
+}
+
+// setLocal is SET LOCAL name = value with value as a bind parameter.
+func setLocal(ctx context.Context, tx *sql.Tx, name, value string) error {
+	if _, err := tx.ExecContext(ctx, `SELECT set_config($1, $2, true)`, name, value); err != nil {
+		return fmt.Errorf("set %s: %w", name, err)
+	}
+	return nil
+}
+
+// readsFrom returns where asOwner begins a transaction for pure reads: the
+// replica router when one is configured, otherwise db.
+func readsFrom(db *sql.DB, reads reader) beginner {
+	if b, ok := reads.(beginner); ok {
+		return b
+	}
+	return db
+}
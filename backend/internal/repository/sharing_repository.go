@@ -3,10 +3,10 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
-
-	"github.com/lib/pq"
+	"time"
 
 	"pmv2/backend/internal/domain"
 )
@@ -20,13 +20,16 @@ func NewSharingRepository(db *sql.DB) *SharingRepository {
 }
 
 func (r *SharingRepository) CreateShare(ctx context.Context, input domain.ShareItemInput) error {
-	_, err := r.db.ExecContext(ctx, `
-		INSERT INTO vault_shares (item_id, user_id, shared_by_user_id, dek_wrapped, wrap_nonce, permissions, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
-	`, input.ItemID, input.RecipientID, input.SharedByUserID, input.DEKWrapped, input.WrapNonce, input.Permissions)
+	schedule, err := shareScheduleJSON(input.Window.Schedule)
+	if err != nil {
+		return err
+	}
+	_, err = dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO vault_shares (item_id, user_id, shared_by_user_id, dek_wrapped, wrap_nonce, permissions, not_before, expires_at, schedule, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW())
+	`, input.ItemID, input.RecipientID, input.SharedByUserID, input.DEKWrapped, input.WrapNonce, input.Permissions, input.Window.NotBefore, input.Window.ExpiresAt, schedule)
 	if err != nil {
-		var pqErr *pq.Error
-		if errors.As(err, &pqErr) && string(pqErr.Code) == "23505" {
+		if isUniqueViolation(err) {
 			return domain.ErrAlreadyShared
 		}
 		return fmt.Errorf("create share: %w", err)
@@ -35,7 +38,7 @@ func (r *SharingRepository) CreateShare(ctx context.Context, input domain.ShareI
 }
 
 func (r *SharingRepository) DeleteShare(ctx context.Context, itemID string, recipientUserID string) error {
-	result, err := r.db.ExecContext(ctx, `
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
 		DELETE FROM vault_shares WHERE item_id = $1 AND user_id = $2
 	`, itemID, recipientUserID)
 	if err != nil {
@@ -52,56 +55,85 @@ func (r *SharingRepository) DeleteShare(ctx context.Context, itemID string, reci
 	return nil
 }
 
-func (r *SharingRepository) ListSharesByRecipient(ctx context.Context, userID string) ([]domain.SharedVaultItem, error) {
-	rows, err := r.db.QueryContext(ctx, `
-		SELECT
-			vi.id, vi.owner_user_id, vi.folder_id, vi.ciphertext, vi.nonce,
-			vi.dek_wrapped, vi.wrap_nonce, vi.algo_version, vi.metadata,
-			vi.created_at, vi.updated_at,
-			vs.shared_by_user_id, vs.dek_wrapped, vs.wrap_nonce, vs.permissions,
-			COALESCE(u.email, ''), COALESCE(u.name, '')
-		FROM vault_shares vs
-		JOIN vault_items vi ON vi.id = vs.item_id
-		LEFT JOIN users u ON u.id = vs.shared_by_user_id
-		WHERE vs.user_id = $1
-		ORDER BY vs.created_at DESC
-	`, userID)
-	if err != nil {
-		return nil, fmt.Errorf("query shared items: %w", err)
+func (r *SharingRepository) UpdateSharePermissions(ctx context.Context, itemID string, recipientUserID string, permissions string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE vault_shares SET permissions = $3, updated_at = NOW()
+		WHERE item_id = $1 AND user_id = $2
+	`, itemID, recipientUserID, permissions)
+	if err != nil {
+		return fmt.Errorf("update share permissions: %w", err)
 	}
-	defer rows.Close()
 
-	items := make([]domain.SharedVaultItem, 0)
-	for rows.Next() {
-		var si domain.SharedVaultItem
-		var metadata []byte
-		var sharedBy sql.NullString
-		err := rows.Scan(
-			&si.ID, &si.OwnerUserID, &si.FolderID, &si.Ciphertext, &si.Nonce,
-			&si.WrappedDEK, &si.WrapNonce, &si.AlgoVersion, &metadata,
-			&si.CreatedAt, &si.UpdatedAt,
-			&sharedBy, &si.ShareDEK, &si.ShareWrapNonce, &si.Permissions,
-			&si.SharedByEmail, &si.SharedByName,
-		)
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("read rows affected: %w", err)
+	}
+	if affected == 0 {
+		return domain.ErrShareNotFound
+	}
+	return nil
+}
+
+func (r *SharingRepository) ListSharesByRecipient(ctx context.Context, userID string) ([]domain.SharedVaultItem, error) {
+	return asOwner(ctx, r.db, userID, func(ctx context.Context) ([]domain.SharedVaultItem, error) {
+		rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+			SELECT
+				vi.id, vi.owner_user_id, vi.folder_id, vi.ciphertext, vi.nonce,
+				vi.dek_wrapped, vi.wrap_nonce, vi.algo_version, vi.metadata,
+				vi.created_at, vi.updated_at,
+				vs.shared_by_user_id, vs.dek_wrapped, vs.wrap_nonce, vs.permissions,
+				vs.not_before, vs.expires_at, vs.schedule, vs.first_accessed_at,
+				COALESCE(u.email, ''), COALESCE(u.name, '')
+			FROM vault_shares vs
+			JOIN vault_items vi ON vi.id = vs.item_id
+			LEFT JOIN users u ON u.id = vs.shared_by_user_id
+			WHERE vs.user_id = $1
+			ORDER BY vs.created_at DESC
+		`, userID)
 		if err != nil {
-			return nil, fmt.Errorf("scan shared item: %w", err)
+			return nil, fmt.Errorf("query shared items: %w", err)
 		}
-		si.Metadata = metadata
-		if sharedBy.Valid {
-			si.SharedByUserID = sharedBy.String
+		defer rows.Close()
+
+		items := make([]domain.SharedVaultItem, 0)
+		for rows.Next() {
+			var si domain.SharedVaultItem
+			var metadata []byte
+			var window shareWindowColumns
+			var firstAccessedAt sql.NullTime
+			var sharedBy sql.NullString
+			err := rows.Scan(
+				&si.ID, &si.OwnerUserID, &si.FolderID, &si.Ciphertext, &si.Nonce,
+				&si.WrappedDEK, &si.WrapNonce, &si.AlgoVersion, &metadata,
+				&si.CreatedAt, &si.UpdatedAt,
+				&sharedBy, &si.ShareDEK, &si.ShareWrapNonce, &si.Permissions,
+				&window.NotBefore, &window.ExpiresAt, &window.Schedule, &firstAccessedAt,
+				&si.SharedByEmail, &si.SharedByName,
+			)
+			if err != nil {
+				return nil, fmt.Errorf("scan shared item: %w", err)
+			}
+			if si.ShareWindow, err = window.decode(); err != nil {
+				return nil, err
+			}
+			si.Metadata = metadata
+			si.FirstAccessedAt = nullTimePtr(firstAccessedAt)
+			if sharedBy.Valid {
+				si.SharedByUserID = sharedBy.String
+			}
+			items = append(items, si)
 		}
-		items = append(items, si)
-	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterate shared items: %w", err)
-	}
-	return items, nil
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("iterate shared items: %w", err)
+		}
+		return items, nil
+	})
 }
 
 func (r *SharingRepository) ListSharesByItem(ctx context.Context, itemID string) ([]domain.VaultShare, error) {
-	rows, err := r.db.QueryContext(ctx, `
-		SELECT item_id, user_id, shared_by_user_id, dek_wrapped, wrap_nonce, permissions, created_at, updated_at
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+vaultShareColumns+`
 		FROM vault_shares
 		WHERE item_id = $1
 		ORDER BY created_at ASC
@@ -109,14 +141,277 @@ func (r *SharingRepository) ListSharesByItem(ctx context.Context, itemID string)
 	if err != nil {
 		return nil, fmt.Errorf("query shares by item: %w", err)
 	}
-	defer rows.Close()
+	return scanVaultShares(rows)
+}
+
+func (r *SharingRepository) GetShare(ctx context.Context, itemID string, userID string) (domain.VaultShare, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+vaultShareColumns+`
+		FROM vault_shares
+		WHERE item_id = $1 AND user_id = $2
+	`, itemID, userID)
+	if err != nil {
+		return domain.VaultShare{}, fmt.Errorf("get share: %w", err)
+	}
+	shares, err := scanVaultShares(rows)
+	if err != nil {
+		return domain.VaultShare{}, err
+	}
+	if len(shares) == 0 {
+		return domain.VaultShare{}, domain.ErrShareNotFound
+	}
+	return shares[0], nil
+}
 
+func (r *SharingRepository) ListSentShares(ctx context.Context, userID string) ([]domain.SentShare, error) {
+	return asSystem(ctx, r.db, func(ctx context.Context) ([]domain.SentShare, error) {
+		rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+			SELECT 
+				vs.item_id, 
+				vi.metadata, 
+				vs.user_id, 
+				u.email, 
+				COALESCE(u.name, ''), 
+				vs.permissions, 
+				vs.created_at
+			FROM vault_shares vs
+			JOIN vault_items vi ON vi.id = vs.item_id
+			JOIN users u ON u.id = vs.user_id
+			WHERE vs.shared_by_user_id = $1
+			ORDER BY vs.created_at DESC
+		`, userID)
+		if err != nil {
+			return nil, fmt.Errorf("query sent shares: %w", err)
+		}
+		defer rows.Close()
+
+		shares := make([]domain.SentShare, 0)
+		for rows.Next() {
+			var s domain.SentShare
+			var metadata []byte
+			err := rows.Scan(
+				&s.ItemID, &metadata, &s.RecipientID, &s.RecipientEmail, &s.RecipientName, &s.Permissions, &s.CreatedAt,
+			)
+			if err != nil {
+				return nil, fmt.Errorf("scan sent share: %w", err)
+			}
+			// Extract title from metadata (which is JSON)
+			// Assuming metadata is decrypted title or similar? 
+			// Wait, the item title is inside the encrypted ciphertext usually. 
+			// Actually, in this project, 'metadata' in vault_items table stores the decrypted title/url for indexing/display?
+			// Let me check vault_repository.go or vault_controller.go to see what's in metadata.
+			// Actually, for simplicity, I'll just return the item ID and metadata for now.
+			// Note from previous turns: VaultViewItem has secret?.title.
+			// Let's assume metadata is a JSON blob that might contain the title if it was saved there.
+			s.ItemTitle = string(metadata) // fallback
+			shares = append(shares, s)
+		}
+		return shares, nil
+	})
+}
+
+func (r *SharingRepository) DeleteAllSharesBetweenUsers(ctx context.Context, user1ID, user2ID string) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM vault_shares 
+		WHERE (shared_by_user_id = $1 AND user_id = $2) 
+		   OR (shared_by_user_id = $2 AND user_id = $1)
+	`, user1ID, user2ID)
+	if err != nil {
+		return fmt.Errorf("delete all shares between users: %w", err)
+	}
+	return nil
+}
+
+func (r *SharingRepository) ListExpiredShares(ctx context.Context, now time.Time, limit int) ([]domain.VaultShare, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+vaultShareColumns+`
+		FROM vault_shares
+		WHERE expires_at <= $1
+		ORDER BY expires_at
+		LIMIT $2
+	`, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query expired shares: %w", err)
+	}
+	return scanVaultShares(rows)
+}
+
+func (r *SharingRepository) GetItemOwner(ctx context.Context, itemID string) (string, error) {
+	return asSystem(ctx, r.db, func(ctx context.Context) (string, error) {
+		var owner string
+		err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+			SELECT owner_user_id FROM vault_items WHERE id = $1 AND deleted_at IS NULL
+		`, itemID).Scan(&owner)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", domain.ErrNotFound
+		}
+		if err != nil {
+			return "", fmt.Errorf("get item owner: %w", err)
+		}
+		return owner, nil
+	})
+}
+
+func (r *SharingRepository) CreateAccessRequest(ctx context.Context, req domain.AccessRequest) (domain.AccessRequest, error) {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO access_requests (id, item_id, owner_user_id, requester_user_id, reason, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+	`, req.ID, req.ItemID, req.OwnerUserID, req.RequesterUserID, req.Reason, domain.AccessRequestPending)
+	if err != nil {
+		return domain.AccessRequest{}, fmt.Errorf("create access request: %w", err)
+	}
+	return r.GetAccessRequest(ctx, req.ID)
+}
+
+func (r *SharingRepository) GetAccessRequest(ctx context.Context, id string) (domain.AccessRequest, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+accessRequestColumns+`
+		FROM access_requests ar
+		LEFT JOIN users u ON u.id = ar.requester_user_id
+		WHERE ar.id = $1
+	`, id)
+	if err != nil {
+		return domain.AccessRequest{}, fmt.Errorf("get access request: %w", err)
+	}
+	return firstAccessRequest(rows)
+}
+
+func (r *SharingRepository) ListAccessRequestsByRequester(ctx context.Context, requesterUserID string) ([]domain.AccessRequest, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+accessRequestColumns+`
+		FROM access_requests ar
+		LEFT JOIN users u ON u.id = ar.requester_user_id
+		WHERE ar.requester_user_id = $1
+		ORDER BY ar.created_at DESC, ar.id
+	`, requesterUserID)
+	if err != nil {
+		return nil, fmt.Errorf("list access requests: %w", err)
+	}
+	return scanAccessRequests(rows)
+}
+
+func (r *SharingRepository) ListPendingAccessRequestsForApprover(ctx context.Context, approverUserID string) ([]domain.AccessRequest, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+accessRequestColumns+`
+		FROM access_requests ar
+		LEFT JOIN users u ON u.id = ar.requester_user_id
+		WHERE ar.status = $2
+		  AND (ar.owner_user_id = $1 OR EXISTS (
+			SELECT 1 FROM vault_shares vs
+			WHERE vs.item_id = ar.item_id AND vs.user_id = $1 AND vs.permissions = $3
+		  ))
+		ORDER BY ar.created_at, ar.id
+	`, approverUserID, domain.AccessRequestPending, domain.SharePermissionManage)
+	if err != nil {
+		return nil, fmt.Errorf("list pending access requests: %w", err)
+	}
+	return scanAccessRequests(rows)
+}
+
+func (r *SharingRepository) DecideAccessRequest(ctx context.Context, id string, status domain.AccessRequestStatus, decidedByUserID string, decidedAt time.Time) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE access_requests
+		SET status = $2, decided_by_user_id = $3, decided_at = $4
+		WHERE id = $1 AND status = $5
+	`, id, status, decidedByUserID, decidedAt.UTC(), domain.AccessRequestPending)
+	if err != nil {
+		return fmt.Errorf("decide access request: %w", err)
+	}
+	return accessRequestDecided(result)
+}
+
+func (r *SharingRepository) CreateShareInvitation(ctx context.Context, inv domain.ShareInvitation) (domain.ShareInvitation, error) {
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		INSERT INTO share_invitations (id, item_id, shared_by_user_id, email, permissions, status, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		RETURNING created_at
+	`, inv.ID, inv.ItemID, inv.SharedByUserID, inv.Email, inv.Permissions, domain.ShareInvitationPending, inv.ExpiresAt.UTC()).Scan(&inv.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.ShareInvitation{}, domain.ErrShareInvitationExists
+		}
+		return domain.ShareInvitation{}, fmt.Errorf("create share invitation: %w", err)
+	}
+	inv.Status = domain.ShareInvitationPending
+	return inv, nil
+}
+
+func (r *SharingRepository) GetShareInvitation(ctx context.Context, id string) (domain.ShareInvitation, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+shareInvitationColumns+` FROM share_invitations WHERE id = $1
+	`, id)
+	if err != nil {
+		return domain.ShareInvitation{}, fmt.Errorf("get share invitation: %w", err)
+	}
+	return firstShareInvitation(rows)
+}
+
+func (r *SharingRepository) ListShareInvitationsBySharer(ctx context.Context, sharedByUserID string) ([]domain.ShareInvitation, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+shareInvitationColumns+` FROM share_invitations
+		WHERE shared_by_user_id = $1
+		ORDER BY created_at DESC, id
+	`, sharedByUserID)
+	if err != nil {
+		return nil, fmt.Errorf("list share invitations: %w", err)
+	}
+	return scanShareInvitations(rows)
+}
+
+func (r *SharingRepository) ListPendingShareInvitationsByEmail(ctx context.Context, email string, now time.Time) ([]domain.ShareInvitation, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+shareInvitationColumns+` FROM share_invitations
+		WHERE email = $1 AND status = $2 AND expires_at > $3
+		ORDER BY created_at DESC, id
+	`, email, domain.ShareInvitationPending, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("list pending share invitations: %w", err)
+	}
+	return scanShareInvitations(rows)
+}
+
+func (r *SharingRepository) MarkShareInvitationReady(ctx context.Context, id, recipientUserID string, readyAt time.Time) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE share_invitations SET status = $2, recipient_user_id = $3, ready_at = $4
+		WHERE id = $1 AND status = $5
+	`, id, domain.ShareInvitationReady, recipientUserID, readyAt.UTC(), domain.ShareInvitationPending)
+	if err != nil {
+		return fmt.Errorf("mark share invitation ready: %w", err)
+	}
+	return shareInvitationAffected(result)
+}
+
+func (r *SharingRepository) DeleteShareInvitation(ctx context.Context, id, sharedByUserID string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM share_invitations WHERE id = $1 AND shared_by_user_id = $2
+	`, id, sharedByUserID)
+	if err != nil {
+		return fmt.Errorf("delete share invitation: %w", err)
+	}
+	return shareInvitationAffected(result)
+}
+
+func (r *SharingRepository) DeleteExpiredShareInvitations(ctx context.Context, now time.Time) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM share_invitations WHERE expires_at <= $1`, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete expired share invitations: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+const vaultShareColumns = `item_id, user_id, shared_by_user_id, dek_wrapped, wrap_nonce, permissions, not_before, expires_at, schedule, first_accessed_at, created_at, updated_at`
+
+func scanVaultShares(rows *sql.Rows) ([]domain.VaultShare, error) {
+	defer rows.Close()
 	shares := make([]domain.VaultShare, 0)
 	for rows.Next() {
 		var s domain.VaultShare
 		var sharedBy sql.NullString
+		var window shareWindowColumns
+		var firstAccessedAt sql.NullTime
 		err := rows.Scan(
-			&s.ItemID, &s.UserID, &sharedBy, &s.DEKWrapped, &s.WrapNonce, &s.Permissions, &s.CreatedAt, &s.UpdatedAt,
+			&s.ItemID, &s.UserID, &sharedBy, &s.DEKWrapped, &s.WrapNonce, &s.Permissions,
+			&window.NotBefore, &window.ExpiresAt, &window.Schedule, &firstAccessedAt, &s.CreatedAt, &s.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("scan share: %w", err)
@@ -124,90 +419,219 @@ func (r *SharingRepository) ListSharesByItem(ctx context.Context, itemID string)
 		if sharedBy.Valid {
 			s.SharedByUserID = sharedBy.String
 		}
+		s.FirstAccessedAt = nullTimePtr(firstAccessedAt)
+		if s.Window, err = window.decode(); err != nil {
+			return nil, err
+		}
 		shares = append(shares, s)
 	}
-
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("iterate shares: %w", err)
 	}
 	return shares, nil
 }
 
-func (r *SharingRepository) GetShare(ctx context.Context, itemID string, userID string) (domain.VaultShare, error) {
-	var s domain.VaultShare
-	var sharedBy sql.NullString
-	err := r.db.QueryRowContext(ctx, `
-		SELECT item_id, user_id, shared_by_user_id, dek_wrapped, wrap_nonce, permissions, created_at, updated_at
-		FROM vault_shares
-		WHERE item_id = $1 AND user_id = $2
-	`, itemID, userID).Scan(
-		&s.ItemID, &s.UserID, &sharedBy, &s.DEKWrapped, &s.WrapNonce, &s.Permissions, &s.CreatedAt, &s.UpdatedAt,
-	)
+// nullTimePtr returns t in UTC, or nil when it is NULL.
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	utc := t.Time.UTC()
+	return &utc
+}
+
+// shareWindowColumns receives the not_before, expires_at and schedule
+// columns of vault_shares.
+type shareWindowColumns struct {
+	NotBefore sql.NullTime
+	ExpiresAt sql.NullTime
+	Schedule  []byte
+}
+
+func (c shareWindowColumns) decode() (domain.ShareWindow, error) {
+	var w domain.ShareWindow
+	if c.NotBefore.Valid {
+		t := c.NotBefore.Time.UTC()
+		w.NotBefore = &t
+	}
+	if c.ExpiresAt.Valid {
+		t := c.ExpiresAt.Time.UTC()
+		w.ExpiresAt = &t
+	}
+	if len(c.Schedule) > 0 {
+		w.Schedule = &domain.ShareSchedule{}
+		if err := json.Unmarshal(c.Schedule, w.Schedule); err != nil {
+			return domain.ShareWindow{}, fmt.Errorf("decode share schedule: %w", err)
+		}
+	}
+	return w, nil
+}
+
+// shareScheduleJSON encodes a share's schedule for the schedule column,
+// which is NULL for shares without one.
+func shareScheduleJSON(schedule *domain.ShareSchedule) (any, error) {
+	if schedule == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(schedule)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return domain.VaultShare{}, domain.ErrShareNotFound
+		return nil, fmt.Errorf("encode share schedule: %w", err)
+	}
+	return string(raw), nil
+}
+
+// accessRequestColumns selects an access request joined, as u, with its
+// requester's user row.
+const accessRequestColumns = `ar.id, ar.item_id, ar.owner_user_id, ar.requester_user_id, COALESCE(u.email, ''), COALESCE(u.name, ''),
+	ar.reason, ar.status, ar.decided_by_user_id, ar.decided_at, ar.created_at`
+
+func scanAccessRequests(rows *sql.Rows) ([]domain.AccessRequest, error) {
+	defer rows.Close()
+	requests := make([]domain.AccessRequest, 0)
+	for rows.Next() {
+		var req domain.AccessRequest
+		var decidedBy sql.NullString
+		var decidedAt sql.NullTime
+		err := rows.Scan(
+			&req.ID, &req.ItemID, &req.OwnerUserID, &req.RequesterUserID, &req.RequesterEmail, &req.RequesterName,
+			&req.Reason, &req.Status, &decidedBy, &decidedAt, &req.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan access request: %w", err)
 		}
-		return domain.VaultShare{}, fmt.Errorf("get share: %w", err)
+		req.DecidedByUserID = decidedBy.String
+		if decidedAt.Valid {
+			t := decidedAt.Time.UTC()
+			req.DecidedAt = &t
+		}
+		requests = append(requests, req)
 	}
-	if sharedBy.Valid {
-		s.SharedByUserID = sharedBy.String
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate access requests: %w", err)
 	}
-	return s, nil
+	return requests, nil
 }
 
-func (r *SharingRepository) ListSentShares(ctx context.Context, userID string) ([]domain.SentShare, error) {
-	rows, err := r.db.QueryContext(ctx, `
-		SELECT 
-			vs.item_id, 
-			vi.metadata, 
-			vs.user_id, 
-			u.email, 
-			COALESCE(u.name, ''), 
-			vs.permissions, 
-			vs.created_at
-		FROM vault_shares vs
-		JOIN vault_items vi ON vi.id = vs.item_id
-		JOIN users u ON u.id = vs.user_id
-		WHERE vs.shared_by_user_id = $1
-		ORDER BY vs.created_at DESC
-	`, userID)
-	if err != nil {
-		return nil, fmt.Errorf("query sent shares: %w", err)
+func firstAccessRequest(rows *sql.Rows) (domain.AccessRequest, error) {
+	requests, err := scanAccessRequests(rows)
+	if err != nil {
+		return domain.AccessRequest{}, err
 	}
-	defer rows.Close()
+	if len(requests) == 0 {
+		return domain.AccessRequest{}, domain.ErrAccessRequestNotFound
+	}
+	return requests[0], nil
+}
+
+// accessRequestDecided maps an UPDATE of a pending request that touched no
+// row to ErrAccessRequestDecided; the service has loaded the request, so it
+// exists.
+func accessRequestDecided(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("read rows affected: %w", err)
+	}
+	if affected == 0 {
+		return domain.ErrAccessRequestDecided
+	}
+	return nil
+}
+
+const shareInvitationColumns = `id, item_id, shared_by_user_id, email, permissions, status, recipient_user_id, ready_at, expires_at, created_at`
 
-	shares := make([]domain.SentShare, 0)
+func scanShareInvitations(rows *sql.Rows) ([]domain.ShareInvitation, error) {
+	defer rows.Close()
+	invitations := make([]domain.ShareInvitation, 0)
 	for rows.Next() {
-		var s domain.SentShare
-		var metadata []byte
+		var inv domain.ShareInvitation
+		var recipient sql.NullString
+		var readyAt sql.NullTime
 		err := rows.Scan(
-			&s.ItemID, &metadata, &s.RecipientID, &s.RecipientEmail, &s.RecipientName, &s.Permissions, &s.CreatedAt,
+			&inv.ID, &inv.ItemID, &inv.SharedByUserID, &inv.Email, &inv.Permissions, &inv.Status,
+			&recipient, &readyAt, &inv.ExpiresAt, &inv.CreatedAt,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("scan sent share: %w", err)
-		}
-		// Extract title from metadata (which is JSON)
-		// Assuming metadata is decrypted title or similar? 
-		// Wait, the item title is inside the encrypted ciphertext usually. 
-		// Actually, in this project, 'metadata' in vault_items table stores the decrypted title/url for indexing/display?
-		// Let me check vault_repository.go or vault_controller.go to see what's in metadata.
-		// Actually, for simplicity, I'll just return the item ID and metadata for now.
-		// Note from previous turns: VaultViewItem has secret?.title.
-		// Let's assume metadata is a JSON blob that might contain the title if it was saved there.
-		s.ItemTitle = string(metadata) // fallback
-		shares = append(shares, s)
+			return nil, fmt.Errorf("scan share invitation: %w", err)
+		}
+		inv.RecipientUserID = recipient.String
+		if readyAt.Valid {
+			t := readyAt.Time.UTC()
+			inv.ReadyAt = &t
+		}
+		invitations = append(invitations, inv)
 	}
-	return shares, nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate share invitations: %w", err)
+	}
+	return invitations, nil
 }
 
-func (r *SharingRepository) DeleteAllSharesBetweenUsers(ctx context.Context, user1ID, user2ID string) error {
-	_, err := r.db.ExecContext(ctx, `
-		DELETE FROM vault_shares 
-		WHERE (shared_by_user_id = $1 AND user_id = $2) 
-		   OR (shared_by_user_id = $2 AND user_id = $1)
-	`, user1ID, user2ID)
+func firstShareInvitation(rows *sql.Rows) (domain.ShareInvitation, error) {
+	invitations, err := scanShareInvitations(rows)
 	if err != nil {
-		return fmt.Errorf("delete all shares between users: %w", err)
+		return domain.ShareInvitation{}, err
+	}
+	if len(invitations) == 0 {
+		return domain.ShareInvitation{}, domain.ErrShareInvitationNotFound
+	}
+	return invitations[0], nil
+}
+
+func shareInvitationAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("read rows affected: %w", err)
+	}
+	if affected == 0 {
+		return domain.ErrShareInvitationNotFound
+	}
+	return nil
+}
+
+func (r *SharingRepository) MarkShareAccessed(ctx context.Context, itemID, userID string, at time.Time) (bool, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE vault_shares SET first_accessed_at = $3
+		WHERE item_id = $1 AND user_id = $2 AND first_accessed_at IS NULL
+	`, itemID, userID, at.UTC())
+	if err != nil {
+		return false, fmt.Errorf("mark share accessed: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (r *SharingRepository) GetItemAccessAlert(ctx context.Context, itemID string) (domain.ItemAccessAlert, error) {
+	var alert domain.ItemAccessAlert
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT item_id, owner_user_id, email, created_at FROM vault_item_access_alerts WHERE item_id = $1
+	`, itemID).Scan(&alert.ItemID, &alert.OwnerUserID, &alert.Email, &alert.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.ItemAccessAlert{}, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.ItemAccessAlert{}, fmt.Errorf("get item access alert: %w", err)
+	}
+	return alert, nil
+}
+
+func (r *SharingRepository) SetItemAccessAlert(ctx context.Context, alert domain.ItemAccessAlert) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO vault_item_access_alerts (item_id, owner_user_id, email, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (item_id) DO UPDATE SET email = EXCLUDED.email
+	`, alert.ItemID, alert.OwnerUserID, alert.Email)
+	if err != nil {
+		return fmt.Errorf("set item access alert: %w", err)
+	}
+	return nil
+}
+
+func (r *SharingRepository) DeleteItemAccessAlert(ctx context.Context, itemID string) error {
+	if _, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM vault_item_access_alerts WHERE item_id = $1`, itemID); err != nil {
+		return fmt.Errorf("delete item access alert: %w", err)
 	}
 	return nil
 }
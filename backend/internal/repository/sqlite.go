@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// sqliteTimeLayout is how the SQLite repositories store timestamps: UTC and
+// fixed width, so comparing the TEXT values orders them in time. It matches
+// the strftime('%Y-%m-%dT%H:%M:%fZ', 'now') column defaults of
+// database.SQLiteUpSQL. Never pass a time.Time to SQLite directly; the
+// driver would store it in another format.
+const sqliteTimeLayout = "2006-01-02T15:04:05.000Z"
+
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+// sqliteNow stands in for NOW(): the SQLite repositories take the clock
+// from Go rather than from the database.
+func sqliteNow() string {
+	return sqliteTime(time.Now())
+}
+
+func sqliteNullTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return sqliteTime(*t)
+}
+
+// sqliteTimeValue scans timestamps that lost their column type on the way,
+// such as MAX(created_at), which the driver returns as plain TEXT.
+type sqliteTimeValue struct {
+	Time  time.Time
+	Valid bool
+}
+
+func (v *sqliteTimeValue) Scan(src any) error {
+	var text string
+	switch s := src.(type) {
+	case nil:
+		*v = sqliteTimeValue{}
+		return nil
+	case time.Time:
+		v.Time, v.Valid = s.UTC(), true
+		return nil
+	case string:
+		text = s
+	case []byte:
+		text = string(s)
+	default:
+		return fmt.Errorf("scan %T into a timestamp", src)
+	}
+
+	t, err := time.Parse(sqliteTimeLayout, text)
+	if err != nil {
+		if t, err = time.Parse(time.RFC3339Nano, text); err != nil {
+			return fmt.Errorf("parse timestamp %q: %w", text, err)
+		}
+	}
+	v.Time, v.Valid = t.UTC(), true
+	return nil
+}
+
+// sqliteStrings encodes a list as the JSON array the SQLite schema keeps in
+// place of a Postgres array. Match against it with json_each.
+func sqliteStrings(values []string) string {
+	if values == nil {
+		values = []string{}
+	}
+	raw, _ := json.Marshal(values)
+	return string(raw)
+}
+
+// sqliteLocks stand in for Postgres advisory and SKIP LOCKED row locks. A
+// SQLite file is served by a single API process, so a lock within the
+// process is enough.
+var sqliteLocks sync.Map // name -> *sync.Mutex
+
+// sqliteTryLock takes the named lock if it is free. release must be called
+// once the work is done.
+func sqliteTryLock(name string) (release func(), acquired bool) {
+	value, _ := sqliteLocks.LoadOrStore(name, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	if !mu.TryLock() {
+		return nil, false
+	}
+	return mu.Unlock, true
+}
+
+// sqliteJSON stores a JSON document as TEXT, or NULL when there is none.
+func sqliteJSON(raw []byte) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
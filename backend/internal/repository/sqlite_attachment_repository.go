@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type SQLiteAttachmentRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteAttachmentRepository(db *sql.DB) *SQLiteAttachmentRepository {
+	return &SQLiteAttachmentRepository{db: db}
+}
+
+func (r *SQLiteAttachmentRepository) CreateAttachment(ctx context.Context, a domain.VaultAttachment) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO vault_attachments (id, item_id, file_name_ciphertext, nonce, size_bytes, storage_path, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+	`, a.ID, a.ItemID, a.FileNameCiphertext, a.Nonce, a.SizeBytes, a.StoragePath, sqliteTime(a.CreatedAt))
+	if err != nil {
+		return fmt.Errorf("create attachment: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteAttachmentRepository) ListAttachments(ctx context.Context, itemID, ownerUserID string) ([]domain.VaultAttachment, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM vault_attachments a
+		JOIN vault_items i ON i.id = a.item_id
+		WHERE a.item_id = $1 AND i.owner_user_id = $2
+		ORDER BY a.created_at, a.id
+	`, itemID, ownerUserID)
+	if err != nil {
+		return nil, fmt.Errorf("list attachments: %w", err)
+	}
+	return scanAttachments(rows)
+}
+
+func (r *SQLiteAttachmentRepository) ListAttachmentsByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultAttachment, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM vault_attachments a
+		JOIN vault_items i ON i.id = a.item_id
+		WHERE i.owner_user_id = $1 AND i.deleted_at IS NULL
+		ORDER BY a.item_id, a.created_at, a.id
+	`, ownerUserID)
+	if err != nil {
+		return nil, fmt.Errorf("list attachments: %w", err)
+	}
+	return scanAttachments(rows)
+}
+
+func (r *SQLiteAttachmentRepository) GetAttachment(ctx context.Context, itemID, ownerUserID, attachmentID string) (domain.VaultAttachment, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM vault_attachments a
+		JOIN vault_items i ON i.id = a.item_id
+		WHERE a.id = $1 AND a.item_id = $2 AND i.owner_user_id = $3
+	`, attachmentID, itemID, ownerUserID)
+	if err != nil {
+		return domain.VaultAttachment{}, fmt.Errorf("get attachment: %w", err)
+	}
+	return firstAttachment(rows)
+}
+
+func (r *SQLiteAttachmentRepository) DeleteAttachment(ctx context.Context, itemID, ownerUserID, attachmentID string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM vault_attachments
+		WHERE id = $1 AND item_id = $2
+			AND item_id IN (SELECT id FROM vault_items WHERE owner_user_id = $3)
+	`, attachmentID, itemID, ownerUserID)
+	if err != nil {
+		return fmt.Errorf("delete attachment: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *SQLiteAttachmentRepository) DeleteTrashedAttachments(ctx context.Context, deletedBefore time.Time) ([]string, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		DELETE FROM vault_attachments
+		WHERE item_id IN (SELECT id FROM vault_items WHERE deleted_at IS NOT NULL AND deleted_at < $1)
+		RETURNING storage_path
+	`, sqliteTime(deletedBefore))
+	if err != nil {
+		return nil, fmt.Errorf("delete trashed attachments: %w", err)
+	}
+	paths, err := scanStrings(rows)
+	if err != nil {
+		return nil, fmt.Errorf("scan attachment paths: %w", err)
+	}
+	return paths, nil
+}
@@ -0,0 +1,278 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type SQLiteAuditRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteAuditRepository(db *sql.DB) *SQLiteAuditRepository {
+	return &SQLiteAuditRepository{db: db}
+}
+
+func (r *SQLiteAuditRepository) CreateEvent(ctx context.Context, event domain.AuditEvent) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO audit_events (id, user_id, actor_user_id, event_type, event_data, ip_address, user_agent, created_at, recorded_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8, $9)
+		ON CONFLICT (id) DO NOTHING
+	`, event.ID, event.UserID, event.ActorUserID, event.EventType, sqliteJSON(event.EventData), event.IPAddress, event.UserAgent, sqliteTime(event.CreatedAt), sqliteNow())
+	if err != nil {
+		return fmt.Errorf("insert audit event: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteAuditRepository) ListEvents(ctx context.Context, limit int, offset int, filter domain.AuditFilter) ([]domain.AuditEvent, int, error) {
+	where, args := sqliteAuditFilterClause(filter)
+	argIdx := len(args) + 1
+
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM audit_events %s", where)
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, countQuery, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("count audit events: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, user_id, actor_user_id, event_type, event_data,
+			COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at
+		FROM audit_events
+		%s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d
+	`, where, argIdx, argIdx+1)
+
+	args = append(args, limit, offset)
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []domain.AuditEvent
+	for rows.Next() {
+		var e domain.AuditEvent
+		var data []byte
+		if err := rows.Scan(&e.ID, &e.UserID, &e.ActorUserID, &e.EventType, &data, &e.IPAddress, &e.UserAgent, &e.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("scan audit event: %w", err)
+		}
+		e.EventData = data
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("iterate audit events: %w", err)
+	}
+
+	return events, total, nil
+}
+
+func (r *SQLiteAuditRepository) StreamEvents(ctx context.Context, filter domain.AuditFilter, fn func(domain.AuditEvent) error) error {
+	where, args := sqliteAuditFilterClause(filter)
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, fmt.Sprintf(`
+		SELECT id, user_id, actor_user_id, event_type, event_data,
+			COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at
+		FROM audit_events
+		%s
+		ORDER BY created_at DESC, id
+	`, where), args...)
+	if err != nil {
+		return fmt.Errorf("query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e domain.AuditEvent
+		var data []byte
+		if err := rows.Scan(&e.ID, &e.UserID, &e.ActorUserID, &e.EventType, &data, &e.IPAddress, &e.UserAgent, &e.CreatedAt); err != nil {
+			return fmt.Errorf("scan audit event: %w", err)
+		}
+		e.EventData = data
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate audit events: %w", err)
+	}
+	return nil
+}
+
+// sqliteAuditFilterClause is auditFilterClause for the SQLite schema. LIKE
+// is already case-insensitive for ASCII, and event types are matched
+// against a JSON array.
+func sqliteAuditFilterClause(filter domain.AuditFilter) (string, []interface{}) {
+	where := "WHERE TRUE"
+	args := []interface{}{}
+	argIdx := 1
+
+	if filter.UserID != nil {
+		where += fmt.Sprintf(" AND user_id = $%d", argIdx)
+		args = append(args, *filter.UserID)
+		argIdx++
+	}
+
+	if filter.Category != "" {
+		where += fmt.Sprintf(" AND event_type LIKE $%d", argIdx)
+		args = append(args, filter.Category+"%")
+		argIdx++
+	}
+
+	if len(filter.EventTypes) > 0 {
+		types := make([]string, 0, len(filter.EventTypes))
+		for _, t := range filter.EventTypes {
+			types = append(types, string(t))
+		}
+		where += fmt.Sprintf(" AND event_type IN (SELECT value FROM json_each($%d))", argIdx)
+		args = append(args, sqliteStrings(types))
+		argIdx++
+	}
+
+	if filter.IPAddress != "" {
+		where += fmt.Sprintf(" AND ip_address = $%d", argIdx)
+		args = append(args, filter.IPAddress)
+		argIdx++
+	}
+
+	if filter.Search != "" {
+		where += fmt.Sprintf(" AND (event_type LIKE $%d OR event_data LIKE $%d)", argIdx, argIdx)
+		args = append(args, "%"+filter.Search+"%")
+		argIdx++
+	}
+
+	if filter.StartDate != nil {
+		where += fmt.Sprintf(" AND created_at >= $%d", argIdx)
+		args = append(args, sqliteTime(*filter.StartDate))
+		argIdx++
+	}
+
+	if filter.EndDate != nil {
+		where += fmt.Sprintf(" AND created_at <= $%d", argIdx)
+		args = append(args, sqliteTime(*filter.EndDate))
+		argIdx++
+	}
+
+	return where, args
+}
+
+func (r *SQLiteAuditRepository) ListEventsAfter(ctx context.Context, cursor domain.AuditCursor, settle time.Duration, limit int) ([]domain.AuditEvent, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT id, user_id, actor_user_id, event_type, event_data,
+			COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at, recorded_at
+		FROM audit_events
+		WHERE (recorded_at, id) > ($1, $2)
+		  AND recorded_at < $3
+		ORDER BY recorded_at, id
+		LIMIT $4
+	`, sqliteTime(cursor.RecordedAt), cursor.EventID, sqliteTime(time.Now().Add(-settle)), limit)
+	if err != nil {
+		return nil, fmt.Errorf("query audit events after cursor: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]domain.AuditEvent, 0)
+	for rows.Next() {
+		var e domain.AuditEvent
+		var data []byte
+		if err := rows.Scan(&e.ID, &e.UserID, &e.ActorUserID, &e.EventType, &data, &e.IPAddress, &e.UserAgent, &e.CreatedAt, &e.RecordedAt); err != nil {
+			return nil, fmt.Errorf("scan audit event: %w", err)
+		}
+		e.EventData = data
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate audit events: %w", err)
+	}
+	return events, nil
+}
+
+// AdvanceExportCursor guards the cursor with an in-process lock rather
+// than a row lock. fn runs outside any transaction: holding SQLite's single
+// write lock while fn ships events would stall every other writer.
+func (r *SQLiteAuditRepository) AdvanceExportCursor(ctx context.Context, exporter string, fn func(domain.AuditCursor) (domain.AuditCursor, error)) (locked bool, err error) {
+	release, acquired := sqliteTryLock("audit-export:" + exporter)
+	if !acquired {
+		return false, nil
+	}
+	defer release()
+
+	if _, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO audit_export_cursors (exporter, last_recorded_at, last_event_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (exporter) DO NOTHING
+	`, exporter, sqliteTime(time.Unix(0, 0)), uuid.Nil); err != nil {
+		return false, fmt.Errorf("ensure audit export cursor: %w", err)
+	}
+
+	var cursor domain.AuditCursor
+	if err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT last_recorded_at, last_event_id FROM audit_export_cursors
+		WHERE exporter = $1
+	`, exporter).Scan(&cursor.RecordedAt, &cursor.EventID); err != nil {
+		return false, fmt.Errorf("read audit export cursor: %w", err)
+	}
+
+	next, err := fn(cursor)
+	if err != nil {
+		return true, err
+	}
+	if next != cursor {
+		if _, err := dbFor(ctx, r.db).ExecContext(ctx, `
+			UPDATE audit_export_cursors
+			SET last_recorded_at = $2, last_event_id = $3, updated_at = $4
+			WHERE exporter = $1
+		`, exporter, sqliteTime(next.RecordedAt), next.EventID, sqliteNow()); err != nil {
+			return true, fmt.Errorf("save audit export cursor: %w", err)
+		}
+	}
+	return true, nil
+}
+
+func (r *SQLiteAuditRepository) DeleteUserLogs(ctx context.Context, userID uuid.UUID) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM audit_events WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("delete audit events: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteAuditRepository) DeleteEventsBefore(ctx context.Context, before time.Time, category string, limit int) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM audit_events
+		WHERE id IN (
+			SELECT id FROM audit_events
+			WHERE recorded_at < $1 AND event_type LIKE $2
+			LIMIT $3
+		)
+	`, sqliteTime(before), category+"%", limit)
+	if err != nil {
+		return 0, fmt.Errorf("delete old audit events: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected, nil
+}
+
+// EnsurePartitions does nothing; audit events are only partitioned on
+// Postgres.
+func (r *SQLiteAuditRepository) EnsurePartitions(ctx context.Context, from time.Time, months int) ([]string, error) {
+	return nil, nil
+}
+
+func (r *SQLiteAuditRepository) DropPartitionsBefore(ctx context.Context, before time.Time) ([]string, error) {
+	return nil, nil
+}
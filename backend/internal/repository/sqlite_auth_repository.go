@@ -0,0 +1,754 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type SQLiteAuthRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteAuthRepository(db *sql.DB) *SQLiteAuthRepository {
+	return &SQLiteAuthRepository{db: db}
+}
+
+func (r *SQLiteAuthRepository) CreateUserWithCredentials(ctx context.Context, input domain.CreateUserInput) error {
+	tx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return fmt.Errorf("start create user tx: %w", err)
+	}
+	defer rollback()
+
+	now := sqliteNow()
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO users (id, email, email_canonical, name, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+	`, input.UserID, input.Email, nullableText(input.CanonicalEmail), nullableText(input.Name), now)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.ErrEmailTaken
+		}
+		return fmt.Errorf("insert user: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO auth_credentials (
+			user_id, algo, params, salt, password_hash, mfa_totp_enabled, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, FALSE, $6, $6)
+	`, input.UserID, nullableText(input.Algo), nullableText(string(input.ParamsJSON)), input.Salt, nullableBytes(input.PasswordHash), now)
+	if err != nil {
+		return fmt.Errorf("insert auth credential: %w", err)
+	}
+
+	if err := commit(); err != nil {
+		return fmt.Errorf("commit create user tx: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteAuthRepository) GetUserAuthByEmail(ctx context.Context, email string) (domain.UserAuthRecord, error) {
+	return r.getUserAuth(ctx, "u.email", email)
+}
+
+func (r *SQLiteAuthRepository) GetUserAuthByID(ctx context.Context, userID string) (domain.UserAuthRecord, error) {
+	return r.getUserAuth(ctx, "u.id", userID)
+}
+
+func (r *SQLiteAuthRepository) getUserAuth(ctx context.Context, column string, value any) (domain.UserAuthRecord, error) {
+	var record domain.UserAuthRecord
+	var name sql.NullString
+	var params sql.NullString
+	var windowStart sql.NullTime
+	var lockedUntil sql.NullTime
+
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT
+			u.id, u.email, u.name, ac.salt, ac.password_hash, ac.params,
+			ac.mfa_totp_enabled, ac.mfa_totp_secret_enc,
+			ac.totp_failed_attempts, ac.totp_window_started_at, ac.totp_locked_until,
+			ac.credentials_compromised_at IS NOT NULL
+		FROM users u
+		JOIN auth_credentials ac ON ac.user_id = u.id
+		WHERE `+column+` = $1
+	`, value).Scan(
+		&record.UserID, &record.Email, &name, &record.Salt, &record.PasswordHash, &params,
+		&record.TOTPEnabled, &record.TOTPSecretEnc,
+		&record.TOTPFailedAttempts, &windowStart, &lockedUntil, &record.CredentialsCompromised,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.UserAuthRecord{}, domain.ErrNotFound
+		}
+		return domain.UserAuthRecord{}, fmt.Errorf("query auth record: %w", err)
+	}
+
+	record.Name = name.String
+	if params.Valid {
+		record.RawParams = []byte(params.String)
+	}
+	if windowStart.Valid {
+		t := windowStart.Time.UTC()
+		record.TOTPWindowStart = &t
+	}
+	if lockedUntil.Valid {
+		t := lockedUntil.Time.UTC()
+		record.TOTPLockedUntil = &t
+	}
+	return record, nil
+}
+
+func (r *SQLiteAuthRepository) CreateSession(ctx context.Context, input domain.CreateSessionInput) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO sessions (
+			id, user_id, refresh_token_hash, device_name, ip_address, user_agent, device_id, expires_at, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, input.SessionID, input.UserID, input.TokenHash, input.DeviceName, nullableText(input.IPAddr), input.UserAgent, nullableText(input.DeviceID), sqliteTime(input.ExpiresAt), sqliteNow())
+	if err != nil {
+		return fmt.Errorf("insert session: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteAuthRepository) GetActiveSessionByTokenHash(ctx context.Context, tokenHash []byte) (domain.Session, error) {
+	var session domain.Session
+	var name, deviceID, ipAddr, userAgent, onMismatch, dpopJKT sql.NullString
+	var bindIP, bindUA sql.NullBool
+	var lastUsed sql.NullTime
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT s.id, s.user_id, u.email, u.name, u.role, ac.mfa_totp_enabled, s.expires_at, s.step_up_required, s.device_id,
+		       s.ip_address, s.user_agent, sb.bind_ip_prefix, sb.bind_user_agent, sb.on_mismatch, s.dpop_jkt,
+		       ac.credentials_compromised_at IS NOT NULL, s.last_used_at
+		FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		JOIN auth_credentials ac ON ac.user_id = u.id
+		LEFT JOIN session_bindings sb ON sb.user_id = u.id
+		WHERE s.refresh_token_hash = $1
+		  AND s.revoked_at IS NULL
+		  AND s.expires_at > $2
+	`, tokenHash, sqliteNow()).Scan(&session.ID, &session.UserID, &session.Email, &name, &session.Role, &session.TOTPEnabled, &session.ExpiresAt, &session.StepUpRequired, &deviceID,
+		&ipAddr, &userAgent, &bindIP, &bindUA, &onMismatch, &dpopJKT, &session.CredentialResetRequired, &lastUsed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Session{}, domain.ErrNotFound
+		}
+		return domain.Session{}, fmt.Errorf("query session: %w", err)
+	}
+	session.Name = name.String
+	session.DeviceID = deviceID.String
+	session.IPAddr = ipAddr.String
+	session.UserAgent = userAgent.String
+	session.DPoPThumbprint = dpopJKT.String
+	session.Binding = domain.SessionBinding{IPPrefix: bindIP.Bool, UserAgent: bindUA.Bool, OnMismatch: domain.SessionBindingAction(onMismatch.String)}
+	if lastUsed.Valid {
+		t := lastUsed.Time.UTC()
+		session.LastUsedAt = &t
+	}
+	return session, nil
+}
+
+func (r *SQLiteAuthRepository) RevokeSessionByTokenHash(ctx context.Context, tokenHash []byte) (bool, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE sessions
+		SET revoked_at = $2
+		WHERE refresh_token_hash = $1 AND revoked_at IS NULL
+	`, tokenHash, sqliteNow())
+	if err != nil {
+		return false, fmt.Errorf("revoke session: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (r *SQLiteAuthRepository) SetTOTPSecret(ctx context.Context, userID string, secretEnc []byte) (bool, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE auth_credentials
+		SET
+			mfa_totp_secret_enc = $1,
+			mfa_totp_enabled = FALSE,
+			totp_failed_attempts = 0,
+			totp_window_started_at = NULL,
+			totp_locked_until = NULL,
+			updated_at = $3
+		WHERE user_id = $2
+	`, secretEnc, userID, sqliteNow())
+	if err != nil {
+		return false, fmt.Errorf("store totp secret: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (r *SQLiteAuthRepository) EnableTOTP(ctx context.Context, userID string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE auth_credentials
+		SET
+			mfa_totp_enabled = TRUE,
+			totp_failed_attempts = 0,
+			totp_window_started_at = NULL,
+			totp_locked_until = NULL,
+			updated_at = $2
+		WHERE user_id = $1
+	`, userID, sqliteNow())
+	if err != nil {
+		return fmt.Errorf("enable totp: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *SQLiteAuthRepository) DisableTOTP(ctx context.Context, userID string) error {
+	tx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return fmt.Errorf("start disable totp tx: %w", err)
+	}
+	defer rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE auth_credentials
+		SET
+			mfa_totp_enabled = FALSE,
+			mfa_totp_secret_enc = NULL,
+			totp_failed_attempts = 0,
+			totp_window_started_at = NULL,
+			totp_locked_until = NULL,
+			updated_at = $2
+		WHERE user_id = $1
+	`, userID, sqliteNow())
+	if err != nil {
+		return fmt.Errorf("disable totp: %w", err)
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM totp_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+
+	if err := commit(); err != nil {
+		return fmt.Errorf("commit disable totp tx: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteAuthRepository) GetTOTPState(ctx context.Context, userID string) (domain.TOTPState, error) {
+	var state domain.TOTPState
+	var windowStart sql.NullTime
+	var lockedUntil sql.NullTime
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT mfa_totp_secret_enc, mfa_totp_enabled, totp_failed_attempts, totp_window_started_at, totp_locked_until
+		FROM auth_credentials
+		WHERE user_id = $1
+	`, userID).Scan(&state.SecretEnc, &state.Enabled, &state.FailedAttempts, &windowStart, &lockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.TOTPState{}, domain.ErrNotFound
+		}
+		return domain.TOTPState{}, fmt.Errorf("query totp state: %w", err)
+	}
+	if windowStart.Valid {
+		t := windowStart.Time.UTC()
+		state.WindowStart = &t
+	}
+	if lockedUntil.Valid {
+		t := lockedUntil.Time.UTC()
+		state.LockedUntil = &t
+	}
+	return state, nil
+}
+
+// RecordTOTPFailure needs no FOR UPDATE: transactions begin with the write
+// lock held (see database.sqlitePragmas), so they are already serialised.
+func (r *SQLiteAuthRepository) RecordTOTPFailure(ctx context.Context, userID string, now time.Time, maxAttempts int, window time.Duration, lockDuration time.Duration) (*time.Time, error) {
+	tx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("start record totp failure tx: %w", err)
+	}
+	defer rollback()
+
+	var failedAttempts int
+	var windowStart sql.NullTime
+	var lockedUntil sql.NullTime
+	err = tx.QueryRowContext(ctx, `
+		SELECT totp_failed_attempts, totp_window_started_at, totp_locked_until
+		FROM auth_credentials
+		WHERE user_id = $1
+	`, userID).Scan(&failedAttempts, &windowStart, &lockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, fmt.Errorf("query totp failure state: %w", err)
+	}
+
+	nowUTC := now.UTC()
+	if lockedUntil.Valid && lockedUntil.Time.After(nowUTC) {
+		lock := lockedUntil.Time.UTC()
+		return &lock, commit()
+	}
+
+	if !windowStart.Valid || nowUTC.Sub(windowStart.Time.UTC()) > window {
+		failedAttempts = 0
+		windowStart = sql.NullTime{Time: nowUTC, Valid: true}
+	}
+
+	failedAttempts++
+
+	nextWindowStart := &windowStart.Time
+	var nextLockedUntil *time.Time
+	if failedAttempts >= maxAttempts {
+		lock := nowUTC.Add(lockDuration)
+		nextLockedUntil = &lock
+		nextWindowStart = nil
+		failedAttempts = 0
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE auth_credentials
+		SET
+			totp_failed_attempts = $2,
+			totp_window_started_at = $3,
+			totp_locked_until = $4,
+			updated_at = $5
+		WHERE user_id = $1
+	`, userID, failedAttempts, sqliteNullTime(nextWindowStart), sqliteNullTime(nextLockedUntil), sqliteTime(nowUTC))
+	if err != nil {
+		return nil, fmt.Errorf("update totp failure state: %w", err)
+	}
+
+	if err := commit(); err != nil {
+		return nil, fmt.Errorf("commit record totp failure tx: %w", err)
+	}
+	return nextLockedUntil, nil
+}
+
+func (r *SQLiteAuthRepository) ResetTOTPFailures(ctx context.Context, userID string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE auth_credentials
+		SET
+			totp_failed_attempts = 0,
+			totp_window_started_at = NULL,
+			totp_locked_until = NULL,
+			updated_at = $2
+		WHERE user_id = $1
+	`, userID, sqliteNow())
+	if err != nil {
+		return fmt.Errorf("reset totp failures: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *SQLiteAuthRepository) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes [][]byte) error {
+	tx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return fmt.Errorf("start replace recovery codes tx: %w", err)
+	}
+	defer rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM totp_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("delete old recovery codes: %w", err)
+	}
+
+	now := sqliteNow()
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO totp_recovery_codes (user_id, code_hash, created_at)
+			VALUES ($1, $2, $3)
+		`, userID, hash, now); err != nil {
+			return fmt.Errorf("insert recovery code: %w", err)
+		}
+	}
+
+	if err := commit(); err != nil {
+		return fmt.Errorf("commit replace recovery codes tx: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteAuthRepository) ConsumeRecoveryCode(ctx context.Context, userID string, codeHash []byte) (bool, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE totp_recovery_codes
+		SET used_at = $3
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, codeHash, sqliteNow())
+	if err != nil {
+		return false, fmt.Errorf("consume recovery code: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (r *SQLiteAuthRepository) DeleteExpiredSessions(ctx context.Context, revokedBefore time.Time, afterID string, limit int) (domain.SessionPurgeBatch, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		DELETE FROM sessions
+		WHERE id IN (
+			SELECT id FROM sessions
+			WHERE id > $4 AND (expires_at < $1 OR revoked_at < $2)
+			ORDER BY id
+			LIMIT $3
+		)
+		RETURNING id
+	`, sqliteNow(), sqliteTime(revokedBefore), limit, sessionCursor(afterID))
+	if err != nil {
+		return domain.SessionPurgeBatch{}, fmt.Errorf("delete expired sessions: %w", err)
+	}
+	ids, err := scanStrings(rows)
+	if err != nil {
+		return domain.SessionPurgeBatch{}, fmt.Errorf("scan deleted sessions: %w", err)
+	}
+	return sessionPurgeBatch(ids), nil
+}
+
+func (r *SQLiteAuthRepository) RevokeAllUserSessions(ctx context.Context, userID string) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE sessions
+		SET revoked_at = $2
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID, sqliteNow())
+	if err != nil {
+		return 0, fmt.Errorf("revoke all user sessions: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected, nil
+}
+
+func (r *SQLiteAuthRepository) ListUserSessions(ctx context.Context, userID string) ([]domain.UserSession, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT id, COALESCE(device_name, ''), COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at, last_used_at, expires_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY created_at DESC, id
+	`, userID, sqliteNow())
+	if err != nil {
+		return nil, fmt.Errorf("list user sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := make([]domain.UserSession, 0)
+	for rows.Next() {
+		var s domain.UserSession
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&s.ID, &s.DeviceName, &s.IPAddress, &s.UserAgent, &s.CreatedAt, &lastUsed, &s.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("scan user session: %w", err)
+		}
+		s.CreatedAt = s.CreatedAt.UTC()
+		s.ExpiresAt = s.ExpiresAt.UTC()
+		if lastUsed.Valid {
+			t := lastUsed.Time.UTC()
+			s.LastUsedAt = &t
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+func (r *SQLiteAuthRepository) RevokeUserSession(ctx context.Context, userID, sessionID string) (bool, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE sessions
+		SET revoked_at = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, sessionID, userID, sqliteNow())
+	if err != nil {
+		return false, fmt.Errorf("revoke user session: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (r *SQLiteAuthRepository) RevokeOtherUserSessions(ctx context.Context, userID, keepSessionID string) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE sessions
+		SET revoked_at = $3
+		WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL
+	`, userID, keepSessionID, sqliteNow())
+	if err != nil {
+		return 0, fmt.Errorf("revoke other user sessions: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected, nil
+}
+
+func (r *SQLiteAuthRepository) TouchSession(ctx context.Context, sessionID string, usedAt time.Time) error {
+	if _, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE sessions SET last_used_at = $2 WHERE id = $1
+	`, sessionID, sqliteTime(usedAt)); err != nil {
+		return fmt.Errorf("touch session: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteAuthRepository) SetSessionStepUp(ctx context.Context, sessionID string, required bool) error {
+	if _, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE sessions SET step_up_required = $2 WHERE id = $1
+	`, sessionID, required); err != nil {
+		return fmt.Errorf("set session step-up: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteAuthRepository) RebindSession(ctx context.Context, sessionID string, ipAddr string, userAgent string) error {
+	if _, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE sessions SET ip_address = $2, user_agent = $3 WHERE id = $1
+	`, sessionID, ipAddr, userAgent); err != nil {
+		return fmt.Errorf("rebind session: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteAuthRepository) GetSessionBinding(ctx context.Context, userID string) (domain.SessionBinding, error) {
+	var binding domain.SessionBinding
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT bind_ip_prefix, bind_user_agent, on_mismatch FROM session_bindings WHERE user_id = $1
+	`, userID).Scan(&binding.IPPrefix, &binding.UserAgent, &binding.OnMismatch)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.SessionBinding{}, domain.ErrNotFound
+		}
+		return domain.SessionBinding{}, fmt.Errorf("get session binding: %w", err)
+	}
+	return binding, nil
+}
+
+func (r *SQLiteAuthRepository) SetSessionBinding(ctx context.Context, userID string, binding domain.SessionBinding) error {
+	if _, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO session_bindings (user_id, bind_ip_prefix, bind_user_agent, on_mismatch, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE SET
+			bind_ip_prefix = excluded.bind_ip_prefix,
+			bind_user_agent = excluded.bind_user_agent,
+			on_mismatch = excluded.on_mismatch,
+			updated_at = excluded.updated_at
+	`, userID, binding.IPPrefix, binding.UserAgent, binding.OnMismatch, sqliteNow()); err != nil {
+		return fmt.Errorf("set session binding: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteAuthRepository) SetSessionDPoPKey(ctx context.Context, sessionID string, thumbprint string) (bool, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE sessions SET dpop_jkt = $2
+		WHERE id = $1 AND dpop_jkt IS NULL AND revoked_at IS NULL
+	`, sessionID, thumbprint)
+	if err != nil {
+		return false, fmt.Errorf("set session dpop key: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (r *SQLiteAuthRepository) SetupRecovery(ctx context.Context, input domain.SetupRecoveryInput) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO user_recovery (user_id, recovery_key_hash, recovery_enabled, wrapped_kek, wrap_nonce, kek_salt, updated_at)
+		VALUES ($1, $2, TRUE, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET
+			recovery_key_hash = excluded.recovery_key_hash,
+			recovery_enabled = TRUE,
+			wrapped_kek = excluded.wrapped_kek,
+			wrap_nonce = excluded.wrap_nonce,
+			kek_salt = excluded.kek_salt,
+			updated_at = excluded.updated_at
+	`, input.UserID, input.RecoveryKeyHash, input.WrappedKEK, input.WrapNonce, input.KEKSalt, sqliteNow())
+	if err != nil {
+		return fmt.Errorf("setup recovery: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteAuthRepository) GetRecoveryRecord(ctx context.Context, userID string) (domain.RecoveryRecord, error) {
+	var record domain.RecoveryRecord
+	var lastRecoveryAt sql.NullTime
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT user_id, recovery_key_hash, recovery_enabled, last_recovery_at, wrapped_kek, wrap_nonce, kek_salt
+		FROM user_recovery
+		WHERE user_id = $1
+	`, userID).Scan(
+		&record.UserID, &record.RecoveryKeyHash, &record.RecoveryEnabled, &lastRecoveryAt,
+		&record.WrappedKEK, &record.WrapNonce, &record.KEKSalt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.RecoveryRecord{}, domain.ErrNotFound
+		}
+		return domain.RecoveryRecord{}, fmt.Errorf("query recovery record: %w", err)
+	}
+	if lastRecoveryAt.Valid {
+		t := lastRecoveryAt.Time.UTC()
+		record.LastRecoveryAt = &t
+	}
+	return record, nil
+}
+
+func (r *SQLiteAuthRepository) UpdateLastRecoveryAt(ctx context.Context, userID string) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE user_recovery
+		SET last_recovery_at = $2, updated_at = $2
+		WHERE user_id = $1
+	`, userID, sqliteNow())
+	if err != nil {
+		return fmt.Errorf("update last recovery at: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteAuthRepository) UpdatePassword(ctx context.Context, input domain.ResetPasswordInput) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE auth_credentials
+		SET algo = $2, params = $3, salt = $4, password_hash = $5, credentials_compromised_at = NULL, updated_at = $6
+		WHERE user_id = $1
+	`, input.UserID, input.Algo, string(input.ParamsJSON), input.Salt, input.PasswordHash, sqliteNow())
+	if err != nil {
+		return fmt.Errorf("update password: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *SQLiteAuthRepository) SetCredentialsCompromised(ctx context.Context, userID string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE auth_credentials
+		SET credentials_compromised_at = COALESCE(credentials_compromised_at, $2), updated_at = $2
+		WHERE user_id = $1
+	`, userID, sqliteNow())
+	if err != nil {
+		return fmt.Errorf("flag credentials compromised: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *SQLiteAuthRepository) UpdateDisplayName(ctx context.Context, userID string, name string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE users SET name = $2, updated_at = $3 WHERE id = $1
+	`, userID, nullableText(name), sqliteNow())
+	if err != nil {
+		return fmt.Errorf("update display name: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// SetUserRole changes the role of the account registered under email.
+func (r *SQLiteAuthRepository) SetUserRole(ctx context.Context, email string, role domain.UserRole) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE users SET role = $2, updated_at = $3 WHERE email = $1
+	`, email, role, sqliteNow())
+	if err != nil {
+		return fmt.Errorf("update user role: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// requireAffected turns an update that matched no row into ErrNotFound.
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("read rows affected: %w", err)
+	}
+	if affected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *SQLiteAuthRepository) RevokeSessionByID(ctx context.Context, sessionID string) (bool, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE sessions
+		SET revoked_at = $2
+		WHERE id = $1 AND revoked_at IS NULL
+	`, sessionID, sqliteNow())
+	if err != nil {
+		return false, fmt.Errorf("revoke session: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (r *SQLiteAuthRepository) RotateSessionToken(ctx context.Context, sessionID string, oldHash, newHash []byte, expiresAt time.Time) (bool, error) {
+	db := dbFor(ctx, r.db)
+	now := sqliteNow()
+	result, err := db.ExecContext(ctx, `
+		UPDATE sessions
+		SET refresh_token_hash = $1, expires_at = $2
+		WHERE id = $3 AND refresh_token_hash = $4 AND revoked_at IS NULL AND expires_at > $5
+	`, newHash, sqliteTime(expiresAt), sessionID, oldHash, now)
+	if err != nil {
+		return false, fmt.Errorf("rotate session token: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("read rows affected: %w", err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	if _, err := db.ExecContext(ctx, `
+		INSERT INTO retired_session_tokens (token_hash, session_id, retired_at) VALUES ($1, $2, $3)
+	`, oldHash, sessionID, now); err != nil {
+		return false, fmt.Errorf("retire session token: %w", err)
+	}
+	return true, nil
+}
+
+func (r *SQLiteAuthRepository) GetRetiredSessionToken(ctx context.Context, tokenHash []byte) (domain.RetiredSessionToken, error) {
+	var token domain.RetiredSessionToken
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT t.session_id, s.user_id, t.retired_at
+		FROM retired_session_tokens t
+		JOIN sessions s ON s.id = t.session_id
+		WHERE t.token_hash = $1
+	`, tokenHash).Scan(&token.SessionID, &token.UserID, &token.RetiredAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.RetiredSessionToken{}, domain.ErrNotFound
+		}
+		return domain.RetiredSessionToken{}, fmt.Errorf("query retired session token: %w", err)
+	}
+	return token, nil
+}
+
+func (r *SQLiteAuthRepository) SessionActive(ctx context.Context, sessionID string) (bool, error) {
+	var active bool
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM sessions WHERE id = $1 AND revoked_at IS NULL AND expires_at > $2
+		)
+	`, sessionID, sqliteNow()).Scan(&active)
+	if err != nil {
+		return false, fmt.Errorf("query session: %w", err)
+	}
+	return active, nil
+}
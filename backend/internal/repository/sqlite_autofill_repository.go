@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type SQLiteAutofillRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteAutofillRepository(db *sql.DB) *SQLiteAutofillRepository {
+	return &SQLiteAutofillRepository{db: db}
+}
+
+func (r *SQLiteAutofillRepository) SetURIHMACs(ctx context.Context, ownerUserID, itemID string, hmacs [][]byte) error {
+	tx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return fmt.Errorf("begin set uri hmacs tx: %w", err)
+	}
+	defer rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM vault_item_uri_hmacs WHERE item_id = $1 AND owner_user_id = $2`, itemID, ownerUserID); err != nil {
+		return fmt.Errorf("clear uri hmacs: %w", err)
+	}
+	for _, hmac := range hmacs {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO vault_item_uri_hmacs (item_id, owner_user_id, uri_hmac)
+			VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING
+		`, itemID, ownerUserID, hmac); err != nil {
+			return fmt.Errorf("insert uri hmac: %w", err)
+		}
+	}
+	if err := commit(); err != nil {
+		return fmt.Errorf("commit set uri hmacs: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteAutofillRepository) ListURIHMACs(ctx context.Context, ownerUserID string) (map[string][][]byte, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT item_id, uri_hmac FROM vault_item_uri_hmacs WHERE owner_user_id = $1
+	`, ownerUserID)
+	if err != nil {
+		return nil, fmt.Errorf("list uri hmacs: %w", err)
+	}
+	return scanURIHMACs(rows)
+}
+
+// MatchURIHMACs compares hex, since json_each cannot carry blobs.
+func (r *SQLiteAutofillRepository) MatchURIHMACs(ctx context.Context, ownerUserID string, hmacs [][]byte) ([]string, error) {
+	hexHMACs := make([]string, 0, len(hmacs))
+	for _, hmac := range hmacs {
+		hexHMACs = append(hexHMACs, strings.ToUpper(hex.EncodeToString(hmac)))
+	}
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT DISTINCT h.item_id
+		FROM vault_item_uri_hmacs h
+		JOIN vault_items vi ON vi.id = h.item_id
+		WHERE h.owner_user_id = $1 AND hex(h.uri_hmac) IN (SELECT value FROM json_each($2)) AND vi.deleted_at IS NULL
+	`, ownerUserID, sqliteStrings(hexHMACs))
+	if err != nil {
+		return nil, fmt.Errorf("match uri hmacs: %w", err)
+	}
+	return scanItemIDs(rows)
+}
+
+func (r *SQLiteAutofillRepository) RecordItemUse(ctx context.Context, ownerUserID, itemID string, usedAt time.Time) (domain.ItemUsage, error) {
+	usage := domain.ItemUsage{ItemID: itemID}
+	var lastUsed time.Time
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		INSERT INTO vault_item_usage (item_id, owner_user_id, use_count, last_used_at)
+		SELECT id, owner_user_id, 1, $3 FROM vault_items
+		WHERE id = $1 AND owner_user_id = $2 AND deleted_at IS NULL
+		ON CONFLICT (item_id) DO UPDATE
+		SET use_count = vault_item_usage.use_count + 1, last_used_at = excluded.last_used_at
+		RETURNING use_count, last_used_at
+	`, itemID, ownerUserID, sqliteTime(usedAt)).Scan(&usage.UseCount, &lastUsed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ItemUsage{}, domain.ErrNotFound
+		}
+		return domain.ItemUsage{}, fmt.Errorf("record item use: %w", err)
+	}
+	lastUsed = lastUsed.UTC()
+	usage.LastUsedAt = &lastUsed
+	return usage, nil
+}
+
+func (r *SQLiteAutofillRepository) ListItemUsage(ctx context.Context, ownerUserID string) (map[string]domain.ItemUsage, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT item_id, use_count, last_used_at FROM vault_item_usage WHERE owner_user_id = $1
+	`, ownerUserID)
+	if err != nil {
+		return nil, fmt.Errorf("list item usage: %w", err)
+	}
+	return scanItemUsage(rows)
+}
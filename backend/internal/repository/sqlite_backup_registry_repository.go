@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"pmv2/backend/internal/domain"
+)
+
+type SQLiteBackupRegistryRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteBackupRegistryRepository(db *sql.DB) *SQLiteBackupRegistryRepository {
+	return &SQLiteBackupRegistryRepository{db: db}
+}
+
+func (r *SQLiteBackupRegistryRepository) RegisterBackup(ctx context.Context, record domain.BackupRecord) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO backups_registry (id, created_by_user_id, storage_uri, checksum_sha256, encrypted, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, record.ID, record.CreatedByUserID, record.StorageURI, record.ChecksumSHA256, record.Encrypted, sqliteNow())
+	if err != nil {
+		return fmt.Errorf("register backup: %w", err)
+	}
+	return nil
+}
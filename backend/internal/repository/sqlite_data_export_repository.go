@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type SQLiteDataExportRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteDataExportRepository(db *sql.DB) *SQLiteDataExportRepository {
+	return &SQLiteDataExportRepository{db: db}
+}
+
+func (r *SQLiteDataExportRepository) CreateDataExport(ctx context.Context, export domain.DataExport) (domain.DataExport, error) {
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		INSERT INTO data_exports (id, user_id, status, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at
+	`, export.ID, export.UserID, domain.DataExportPending, sqliteNow()).Scan(&export.CreatedAt)
+	if err != nil {
+		return domain.DataExport{}, fmt.Errorf("insert data export: %w", err)
+	}
+	export.Status = domain.DataExportPending
+	return export, nil
+}
+
+func (r *SQLiteDataExportRepository) GetDataExport(ctx context.Context, userID, exportID string) (domain.DataExport, error) {
+	return r.queryDataExport(ctx, `
+		SELECT `+dataExportColumns+`
+		FROM data_exports
+		WHERE id = $1 AND user_id = $2
+	`, exportID, userID)
+}
+
+func (r *SQLiteDataExportRepository) FindPendingDataExport(ctx context.Context, userID string) (domain.DataExport, error) {
+	return r.queryDataExport(ctx, `
+		SELECT `+dataExportColumns+`
+		FROM data_exports
+		WHERE user_id = $1 AND status = 'pending'
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, userID)
+}
+
+func (r *SQLiteDataExportRepository) queryDataExport(ctx context.Context, query string, args ...any) (domain.DataExport, error) {
+	var e domain.DataExport
+	var completedAt, expiresAt sql.NullTime
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, query, args...).Scan(&e.ID, &e.UserID, &e.Status, &e.SizeBytes, &e.CreatedAt, &completedAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.DataExport{}, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.DataExport{}, fmt.Errorf("query data export: %w", err)
+	}
+	if completedAt.Valid {
+		e.CompletedAt = &completedAt.Time
+	}
+	if expiresAt.Valid {
+		e.ExpiresAt = &expiresAt.Time
+	}
+	return e, nil
+}
+
+func (r *SQLiteDataExportRepository) GetDataExportArchive(ctx context.Context, userID, exportID string) ([]byte, error) {
+	var archive []byte
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT archive FROM data_exports
+		WHERE id = $1 AND user_id = $2 AND status = 'ready'
+	`, exportID, userID).Scan(&archive)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query data export archive: %w", err)
+	}
+	return archive, nil
+}
+
+func (r *SQLiteDataExportRepository) CompleteDataExport(ctx context.Context, exportID string, archive []byte, completedAt, expiresAt time.Time) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE data_exports
+		SET status = 'ready', archive = $2, size_bytes = $3, completed_at = $4, expires_at = $5
+		WHERE id = $1
+	`, exportID, archive, len(archive), sqliteTime(completedAt), sqliteTime(expiresAt))
+	if err != nil {
+		return fmt.Errorf("complete data export: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *SQLiteDataExportRepository) DeleteExpiredDataExports(ctx context.Context, before time.Time) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM data_exports WHERE status = 'ready' AND expires_at < $1
+	`, sqliteTime(before))
+	if err != nil {
+		return 0, fmt.Errorf("delete expired data exports: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected, nil
+}
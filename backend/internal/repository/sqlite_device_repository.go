@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+)
+
+type SQLiteDeviceRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteDeviceRepository(db *sql.DB) *SQLiteDeviceRepository {
+	return &SQLiteDeviceRepository{db: db}
+}
+
+func (r *SQLiteDeviceRepository) TouchDevice(ctx context.Context, userID string, keyHash []byte, fingerprinted bool, label string) (domain.Device, bool, error) {
+	db := dbFor(ctx, r.db)
+	now := sqliteNow()
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO user_devices (id, user_id, key_hash, fingerprinted, label, first_seen_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		ON CONFLICT (user_id, key_hash) DO NOTHING
+	`, uuid.NewString(), userID, keyHash, fingerprinted, label, now)
+	if err != nil {
+		return domain.Device{}, false, fmt.Errorf("insert device: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return domain.Device{}, false, fmt.Errorf("read rows affected: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		UPDATE user_devices SET label = $3, last_seen_at = $4
+		WHERE user_id = $1 AND key_hash = $2
+		RETURNING `+deviceColumns, userID, keyHash, label, now)
+	if err != nil {
+		return domain.Device{}, false, fmt.Errorf("touch device: %w", err)
+	}
+	devices, err := scanDevices(rows)
+	if err != nil {
+		return domain.Device{}, false, err
+	}
+	if len(devices) == 0 {
+		return domain.Device{}, false, domain.ErrNotFound
+	}
+	return devices[0], affected == 1, nil
+}
+
+func (r *SQLiteDeviceRepository) ListDevices(ctx context.Context, userID string) ([]domain.Device, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+deviceColumns+` FROM user_devices
+		WHERE user_id = $1
+		ORDER BY last_seen_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list devices: %w", err)
+	}
+	return scanDevices(rows)
+}
+
+func (r *SQLiteDeviceRepository) DeleteDevice(ctx context.Context, userID, deviceID string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM user_devices WHERE id = $1 AND user_id = $2`, deviceID, userID)
+	if err != nil {
+		return fmt.Errorf("delete device: %w", err)
+	}
+	return requireAffected(result)
+}
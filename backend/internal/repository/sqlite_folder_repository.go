@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/util"
+)
+
+type SQLiteFolderRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteFolderRepository(db *sql.DB) *SQLiteFolderRepository {
+	return &SQLiteFolderRepository{db: db}
+}
+
+func (r *SQLiteFolderRepository) CreateFolder(ctx context.Context, input domain.CreateVaultFolderInput) (domain.VaultFolder, error) {
+	folderID, err := util.NewUUID()
+	if err != nil {
+		return domain.VaultFolder{}, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	folder := domain.VaultFolder{
+		ID:             folderID,
+		OwnerUserID:    input.OwnerUserID,
+		NameCiphertext: input.NameCiphertext,
+		Nonce:          input.Nonce,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	query := `
+		INSERT INTO vault_folders (id, owner_user_id, name_ciphertext, nonce, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+	`
+	_, err = dbFor(ctx, r.db).ExecContext(ctx, query, folder.ID, folder.OwnerUserID, folder.NameCiphertext, folder.Nonce, sqliteTime(now))
+	if err != nil {
+		return domain.VaultFolder{}, err
+	}
+
+	return folder, nil
+}
+
+func (r *SQLiteFolderRepository) ListFoldersByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultFolder, error) {
+	query := `
+		SELECT id, owner_user_id, name_ciphertext, nonce, created_at, updated_at
+		FROM vault_folders
+		WHERE owner_user_id = $1
+		ORDER BY created_at DESC
+	`
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, query, ownerUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var folders []domain.VaultFolder
+	for rows.Next() {
+		var f domain.VaultFolder
+		if err := rows.Scan(&f.ID, &f.OwnerUserID, &f.NameCiphertext, &f.Nonce, &f.CreatedAt, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+		folders = append(folders, f)
+	}
+
+	return folders, rows.Err()
+}
+
+func (r *SQLiteFolderRepository) GetFolderByIDForOwner(ctx context.Context, folderID string, ownerUserID string) (domain.VaultFolder, error) {
+	query := `
+		SELECT id, owner_user_id, name_ciphertext, nonce, created_at, updated_at
+		FROM vault_folders
+		WHERE id = $1 AND owner_user_id = $2
+	`
+	var f domain.VaultFolder
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, query, folderID, ownerUserID).Scan(&f.ID, &f.OwnerUserID, &f.NameCiphertext, &f.Nonce, &f.CreatedAt, &f.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.VaultFolder{}, domain.ErrNotFound
+		}
+		return domain.VaultFolder{}, err
+	}
+
+	return f, nil
+}
+
+func (r *SQLiteFolderRepository) UpdateFolderForOwner(ctx context.Context, folderID string, ownerUserID string, nameCiphertext []byte, nonce []byte) (domain.VaultFolder, error) {
+	query := `
+		UPDATE vault_folders
+		SET name_ciphertext = $1, nonce = $2, updated_at = $3
+		WHERE id = $4 AND owner_user_id = $5
+		RETURNING id, owner_user_id, name_ciphertext, nonce, created_at, updated_at
+	`
+	var f domain.VaultFolder
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, query, nameCiphertext, nonce, sqliteNow(), folderID, ownerUserID).Scan(&f.ID, &f.OwnerUserID, &f.NameCiphertext, &f.Nonce, &f.CreatedAt, &f.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.VaultFolder{}, domain.ErrNotFound
+		}
+		return domain.VaultFolder{}, err
+	}
+
+	return f, nil
+}
+
+func (r *SQLiteFolderRepository) DeleteFolderForOwner(ctx context.Context, folderID string, ownerUserID string) (bool, error) {
+	query := `DELETE FROM vault_folders WHERE id = $1 AND owner_user_id = $2`
+	res, err := dbFor(ctx, r.db).ExecContext(ctx, query, folderID, ownerUserID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type SQLiteIPBanRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteIPBanRepository(db *sql.DB) *SQLiteIPBanRepository {
+	return &SQLiteIPBanRepository{db: db}
+}
+
+func (r *SQLiteIPBanRepository) GetIPBan(ctx context.Context, ip string) (domain.IPBan, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `SELECT `+ipBanColumns+` FROM ip_bans WHERE ip = $1`, ip)
+	if err != nil {
+		return domain.IPBan{}, fmt.Errorf("query ip ban: %w", err)
+	}
+	bans, err := scanIPBans(rows)
+	if err != nil {
+		return domain.IPBan{}, err
+	}
+	if len(bans) == 0 {
+		return domain.IPBan{}, domain.ErrNotFound
+	}
+	return bans[0], nil
+}
+
+func (r *SQLiteIPBanRepository) SaveIPBan(ctx context.Context, ban domain.IPBan) (domain.IPBan, error) {
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		INSERT INTO ip_bans (ip, offenses, reason, banned_until, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		ON CONFLICT (ip) DO UPDATE
+		SET offenses = excluded.offenses, reason = excluded.reason, banned_until = excluded.banned_until, updated_at = excluded.updated_at
+		RETURNING created_at, updated_at
+	`, ban.IP, ban.Offenses, ban.Reason, sqliteTime(ban.BannedUntil), sqliteNow()).Scan(&ban.CreatedAt, &ban.UpdatedAt)
+	if err != nil {
+		return domain.IPBan{}, fmt.Errorf("save ip ban: %w", err)
+	}
+	return ban, nil
+}
+
+func (r *SQLiteIPBanRepository) ListActiveIPBans(ctx context.Context, now time.Time) ([]domain.IPBan, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+ipBanColumns+` FROM ip_bans
+		WHERE banned_until > $1
+		ORDER BY banned_until DESC
+	`, sqliteTime(now))
+	if err != nil {
+		return nil, fmt.Errorf("list ip bans: %w", err)
+	}
+	return scanIPBans(rows)
+}
+
+func (r *SQLiteIPBanRepository) DeleteIPBan(ctx context.Context, ip string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM ip_bans WHERE ip = $1`, ip)
+	if err != nil {
+		return fmt.Errorf("delete ip ban: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *SQLiteIPBanRepository) DeleteIPBansEndedBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM ip_bans WHERE banned_until < $1`, sqliteTime(before))
+	if err != nil {
+		return 0, fmt.Errorf("delete old ip bans: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected, nil
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type SQLiteJobRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteJobRepository(db *sql.DB) *SQLiteJobRepository {
+	return &SQLiteJobRepository{db: db}
+}
+
+// TryLock takes an in-process lock; a SQLite file has a single API process
+// writing to it.
+func (r *SQLiteJobRepository) TryLock(ctx context.Context, jobName string) (func(), bool, error) {
+	release, acquired := sqliteTryLock("job:" + jobName)
+	return release, acquired, nil
+}
+
+func (r *SQLiteJobRepository) LastRunStartedAt(ctx context.Context, jobName string) (time.Time, error) {
+	var startedAt sqliteTimeValue
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT max(started_at) FROM job_runs WHERE job_name = $1
+	`, jobName).Scan(&startedAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("query last job run: %w", err)
+	}
+	return startedAt.Time, nil
+}
+
+func (r *SQLiteJobRepository) StartRun(ctx context.Context, jobName string, startedAt time.Time) (string, error) {
+	id := uuid.NewString()
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO job_runs (id, job_name, status, started_at)
+		VALUES ($1, $2, $3, $4)
+	`, id, jobName, domain.JobRunStatusRunning, sqliteTime(startedAt))
+	if err != nil {
+		return "", fmt.Errorf("insert job run: %w", err)
+	}
+	return id, nil
+}
+
+func (r *SQLiteJobRepository) FinishRun(ctx context.Context, runID string, status domain.JobRunStatus, errMessage string, finishedAt time.Time) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE job_runs
+		SET status = $2, error = NULLIF($3, ''), finished_at = $4
+		WHERE id = $1
+	`, runID, status, errMessage, sqliteTime(finishedAt))
+	if err != nil {
+		return fmt.Errorf("update job run: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteJobRepository) DeleteRunsBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM job_runs WHERE started_at < $1
+	`, sqliteTime(before))
+	if err != nil {
+		return 0, fmt.Errorf("delete job runs: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected, nil
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+)
+
+type SQLiteMachineSecretRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteMachineSecretRepository(db *sql.DB) *SQLiteMachineSecretRepository {
+	return &SQLiteMachineSecretRepository{db: db}
+}
+
+func (r *SQLiteMachineSecretRepository) UpsertMachineSecret(ctx context.Context, s domain.MachineSecret) (domain.MachineSecret, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		INSERT INTO machine_secrets (id, owner_user_id, item_id, name, ciphertext, nonce, algo_version, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+		ON CONFLICT (item_id) DO UPDATE
+		SET name = excluded.name, ciphertext = excluded.ciphertext, nonce = excluded.nonce,
+			algo_version = excluded.algo_version, updated_at = excluded.updated_at
+		RETURNING `+machineSecretColumns, uuid.NewString(), s.OwnerUserID, s.ItemID, s.Name, s.Ciphertext, s.Nonce, s.AlgoVersion, sqliteNow())
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.MachineSecret{}, domain.ErrMachineSecretNameTaken
+		}
+		return domain.MachineSecret{}, fmt.Errorf("upsert machine secret: %w", err)
+	}
+	secret, err := firstMachineSecret(rows)
+	if isUniqueViolation(err) {
+		return domain.MachineSecret{}, domain.ErrMachineSecretNameTaken
+	}
+	return secret, err
+}
+
+func (r *SQLiteMachineSecretRepository) ListMachineSecrets(ctx context.Context, ownerUserID string) ([]domain.MachineSecret, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+machineSecretColumns+` FROM machine_secrets
+		WHERE owner_user_id = $1
+		ORDER BY name
+	`, ownerUserID)
+	if err != nil {
+		return nil, fmt.Errorf("list machine secrets: %w", err)
+	}
+	return scanMachineSecrets(rows)
+}
+
+func (r *SQLiteMachineSecretRepository) GetMachineSecretByName(ctx context.Context, ownerUserID, name string) (domain.MachineSecret, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+machineSecretColumns+` FROM machine_secrets
+		WHERE owner_user_id = $1 AND name = $2
+	`, ownerUserID, name)
+	if err != nil {
+		return domain.MachineSecret{}, fmt.Errorf("get machine secret: %w", err)
+	}
+	return firstMachineSecret(rows)
+}
+
+func (r *SQLiteMachineSecretRepository) DeleteMachineSecret(ctx context.Context, ownerUserID, itemID string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM machine_secrets WHERE item_id = $1 AND owner_user_id = $2`, itemID, ownerUserID)
+	if err != nil {
+		return fmt.Errorf("delete machine secret: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *SQLiteMachineSecretRepository) CreateMachineKey(ctx context.Context, key domain.MachineKey) (domain.MachineKey, error) {
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		INSERT INTO machine_keys (id, owner_user_id, name, key_hash, prefix, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING created_at
+	`, key.ID, key.OwnerUserID, key.Name, key.KeyHash, key.Prefix, sqliteStrings(key.Scopes), sqliteNullTime(key.ExpiresAt), sqliteNow()).Scan(&key.CreatedAt)
+	if err != nil {
+		return domain.MachineKey{}, fmt.Errorf("insert machine key: %w", err)
+	}
+	return key, nil
+}
+
+func (r *SQLiteMachineSecretRepository) ListMachineKeys(ctx context.Context, ownerUserID string) ([]domain.MachineKey, error) {
+	return r.queryMachineKeys(ctx, `
+		SELECT `+machineKeyColumns+` FROM machine_keys
+		WHERE owner_user_id = $1
+		ORDER BY created_at
+	`, ownerUserID)
+}
+
+func (r *SQLiteMachineSecretRepository) CountMachineKeys(ctx context.Context, ownerUserID string) (int, error) {
+	var count int
+	if err := dbFor(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM machine_keys WHERE owner_user_id = $1`, ownerUserID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count machine keys: %w", err)
+	}
+	return count, nil
+}
+
+func (r *SQLiteMachineSecretRepository) GetMachineKeyByHash(ctx context.Context, keyHash []byte) (domain.MachineKey, error) {
+	keys, err := r.queryMachineKeys(ctx, `SELECT `+machineKeyColumns+` FROM machine_keys WHERE key_hash = $1`, keyHash)
+	if err != nil {
+		return domain.MachineKey{}, err
+	}
+	if len(keys) == 0 {
+		return domain.MachineKey{}, domain.ErrNotFound
+	}
+	return keys[0], nil
+}
+
+func (r *SQLiteMachineSecretRepository) DeleteMachineKey(ctx context.Context, ownerUserID, id string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM machine_keys WHERE id = $1 AND owner_user_id = $2`, id, ownerUserID)
+	if err != nil {
+		return fmt.Errorf("delete machine key: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *SQLiteMachineSecretRepository) TouchMachineKey(ctx context.Context, id string, usedAt time.Time) error {
+	if _, err := dbFor(ctx, r.db).ExecContext(ctx, `UPDATE machine_keys SET last_used_at = $2 WHERE id = $1`, id, sqliteTime(usedAt)); err != nil {
+		return fmt.Errorf("touch machine key: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteMachineSecretRepository) queryMachineKeys(ctx context.Context, query string, args ...any) ([]domain.MachineKey, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query machine keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]domain.MachineKey, 0)
+	for rows.Next() {
+		var k domain.MachineKey
+		var scopes string
+		var expiresAt, lastUsed sql.NullTime
+		if err := rows.Scan(&k.ID, &k.OwnerUserID, &k.Name, &k.KeyHash, &k.Prefix, &scopes, &expiresAt, &lastUsed, &k.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan machine key: %w", err)
+		}
+		if err := json.Unmarshal([]byte(scopes), &k.Scopes); err != nil {
+			return nil, fmt.Errorf("decode machine key scopes: %w", err)
+		}
+		setMachineKeyTimes(&k, expiresAt, lastUsed)
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate machine keys: %w", err)
+	}
+	return keys, nil
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type SQLiteOutboxRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteOutboxRepository(db *sql.DB) *SQLiteOutboxRepository {
+	return &SQLiteOutboxRepository{db: db}
+}
+
+func (r *SQLiteOutboxRepository) Enqueue(ctx context.Context, topic string, payload json.RawMessage) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO outbox_events (id, topic, payload, created_at, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $4)
+	`, uuid.NewString(), topic, string(payload), sqliteNow())
+	if err != nil {
+		return fmt.Errorf("insert outbox event: %w", err)
+	}
+	return nil
+}
+
+// ClaimDue needs no row locks: SQLite runs one write transaction at a time,
+// so two dispatchers cannot claim the same event.
+func (r *SQLiteOutboxRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxEvent, error) {
+	now := time.Now()
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		UPDATE outbox_events
+		SET next_attempt_at = $3, attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE dispatched_at IS NULL AND failed_at IS NULL AND next_attempt_at <= $2
+			ORDER BY created_at
+			LIMIT $1
+		)
+		RETURNING id, topic, payload, attempts, created_at
+	`, limit, sqliteTime(now), sqliteTime(now.Add(lease)))
+	if err != nil {
+		return nil, fmt.Errorf("claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]domain.OutboxEvent, 0)
+	for rows.Next() {
+		var e domain.OutboxEvent
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.Topic, &payload, &e.Attempts, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan outbox event: %w", err)
+		}
+		e.Payload = payload
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate outbox events: %w", err)
+	}
+	return events, nil
+}
+
+func (r *SQLiteOutboxRepository) MarkDispatched(ctx context.Context, id string) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE outbox_events
+		SET dispatched_at = $3, last_error = NULL,
+		    payload = CASE WHEN topic IN (SELECT value FROM json_each($2)) THEN '{}' ELSE payload END
+		WHERE id = $1
+	`, id, sqliteStrings(domain.SensitiveOutboxTopics), sqliteNow())
+	if err != nil {
+		return fmt.Errorf("mark outbox event dispatched: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteOutboxRepository) MarkRetry(ctx context.Context, id string, lastError string, nextAttemptAt time.Time) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE outbox_events SET last_error = $2, next_attempt_at = $3 WHERE id = $1
+	`, id, lastError, sqliteTime(nextAttemptAt))
+	if err != nil {
+		return fmt.Errorf("schedule outbox event retry: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteOutboxRepository) MarkFailed(ctx context.Context, id string, lastError string) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE outbox_events
+		SET last_error = $2, failed_at = $4,
+		    payload = CASE WHEN topic IN (SELECT value FROM json_each($3)) THEN '{}' ELSE payload END
+		WHERE id = $1
+	`, id, lastError, sqliteStrings(domain.SensitiveOutboxTopics), sqliteNow())
+	if err != nil {
+		return fmt.Errorf("mark outbox event failed: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteOutboxRepository) DeleteDispatchedBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM outbox_events WHERE dispatched_at IS NOT NULL AND dispatched_at < $1
+	`, sqliteTime(before))
+	if err != nil {
+		return 0, fmt.Errorf("delete dispatched outbox events: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected, nil
+}
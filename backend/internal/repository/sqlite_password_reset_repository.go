@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type SQLitePasswordResetRepository struct {
+	db *sql.DB
+}
+
+func NewSQLitePasswordResetRepository(db *sql.DB) *SQLitePasswordResetRepository {
+	return &SQLitePasswordResetRepository{db: db}
+}
+
+func (r *SQLitePasswordResetRepository) CreatePasswordReset(ctx context.Context, token domain.PasswordResetToken) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO password_reset_tokens (id, user_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, token.ID, token.UserID, token.TokenHash, sqliteTime(token.ExpiresAt), sqliteNow())
+	if err != nil {
+		return fmt.Errorf("insert password reset token: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLitePasswordResetRepository) GetPasswordReset(ctx context.Context, tokenHash []byte, now time.Time) (domain.PasswordResetToken, error) {
+	token := domain.PasswordResetToken{TokenHash: tokenHash}
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT id, user_id, expires_at, created_at
+		FROM password_reset_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+	`, tokenHash, sqliteTime(now)).Scan(&token.ID, &token.UserID, &token.ExpiresAt, &token.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.PasswordResetToken{}, domain.ErrNotFound
+		}
+		return domain.PasswordResetToken{}, fmt.Errorf("get password reset token: %w", err)
+	}
+	return token, nil
+}
+
+func (r *SQLitePasswordResetRepository) CountPasswordResets(ctx context.Context, userID string, since time.Time) (int, error) {
+	var count int
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM password_reset_tokens WHERE user_id = $1 AND created_at > $2
+	`, userID, sqliteTime(since)).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count password reset tokens: %w", err)
+	}
+	return count, nil
+}
+
+func (r *SQLitePasswordResetRepository) UsePasswordReset(ctx context.Context, id, userID string, now time.Time) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE password_reset_tokens SET used_at = $2 WHERE id = $1 AND used_at IS NULL
+	`, id, sqliteTime(now))
+	if err != nil {
+		return fmt.Errorf("use password reset token: %w", err)
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+	if _, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE password_reset_tokens SET used_at = $2 WHERE user_id = $1 AND used_at IS NULL
+	`, userID, sqliteTime(now)); err != nil {
+		return fmt.Errorf("use other password reset tokens: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLitePasswordResetRepository) DeleteExpiredPasswordResets(ctx context.Context, before time.Time) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE expires_at <= $1`, sqliteTime(before))
+	if err != nil {
+		return 0, fmt.Errorf("delete expired password reset tokens: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+)
+
+type SQLitePushTokenRepository struct {
+	db *sql.DB
+}
+
+func NewSQLitePushTokenRepository(db *sql.DB) *SQLitePushTokenRepository {
+	return &SQLitePushTokenRepository{db: db}
+}
+
+func (r *SQLitePushTokenRepository) UpsertPushToken(ctx context.Context, t domain.PushToken) (domain.PushToken, error) {
+	now := sqliteNow()
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		INSERT INTO push_tokens (id, user_id, device_id, platform, token, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		ON CONFLICT (platform, token) DO UPDATE
+		SET user_id = excluded.user_id, device_id = excluded.device_id, updated_at = excluded.updated_at
+		RETURNING `+pushTokenColumns, uuid.NewString(), t.UserID, nullableText(t.DeviceID), t.Platform, t.Token, now)
+	if err != nil {
+		return domain.PushToken{}, fmt.Errorf("upsert push token: %w", err)
+	}
+	tokens, err := scanPushTokens(rows)
+	if err != nil {
+		return domain.PushToken{}, err
+	}
+	if len(tokens) == 0 {
+		return domain.PushToken{}, domain.ErrNotFound
+	}
+	return tokens[0], nil
+}
+
+func (r *SQLitePushTokenRepository) ListPushTokens(ctx context.Context, userID string) ([]domain.PushToken, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+pushTokenColumns+` FROM push_tokens
+		WHERE user_id = $1
+		ORDER BY updated_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list push tokens: %w", err)
+	}
+	return scanPushTokens(rows)
+}
+
+func (r *SQLitePushTokenRepository) DeletePushToken(ctx context.Context, userID, id string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM push_tokens WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("delete push token: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *SQLitePushTokenRepository) DeletePushTokenByValue(ctx context.Context, platform domain.PushPlatform, token string) error {
+	if _, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM push_tokens WHERE platform = $1 AND token = $2`, platform, token); err != nil {
+		return fmt.Errorf("delete push token: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLitePushTokenRepository) DeleteStalePushTokens(ctx context.Context, before time.Time) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM push_tokens WHERE updated_at < $1`, sqliteTime(before))
+	if err != nil {
+		return 0, fmt.Errorf("delete stale push tokens: %w", err)
+	}
+	return result.RowsAffected()
+}
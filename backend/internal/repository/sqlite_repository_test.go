@@ -0,0 +1,1740 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/database"
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	ctx := context.Background()
+	db, err := database.New(ctx, "sqlite://"+filepath.Join(t.TempDir(), "pmv2.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := database.MigrateUp(ctx, db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db.SQL()
+}
+
+func createSQLiteUser(t *testing.T, conn *sql.DB, email string) string {
+	t.Helper()
+	userID := uuid.NewString()
+	err := repository.NewSQLiteAuthRepository(conn).CreateUserWithCredentials(context.Background(), domain.CreateUserInput{
+		UserID:       userID,
+		Email:        email,
+		Algo:         "argon2id",
+		ParamsJSON:   []byte(`{"t":3}`),
+		Salt:         []byte("salt"),
+		PasswordHash: []byte("hash"),
+	})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return userID
+}
+
+func TestSQLiteAuthSessions(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteAuthRepository(conn)
+	ctx := context.Background()
+	userID := createSQLiteUser(t, conn, "alice@example.com")
+
+	err := repo.CreateUserWithCredentials(ctx, domain.CreateUserInput{UserID: uuid.NewString(), Email: "alice@example.com", Algo: "argon2id", ParamsJSON: []byte(`{}`)})
+	if !errors.Is(err, domain.ErrEmailTaken) {
+		t.Fatalf("duplicate email: err = %v, want ErrEmailTaken", err)
+	}
+
+	record, err := repo.GetUserAuthByEmail(ctx, "alice@example.com")
+	if err != nil || record.UserID != userID {
+		t.Fatalf("GetUserAuthByEmail = %+v, %v", record, err)
+	}
+
+	for i, expires := range []time.Time{time.Now().Add(time.Hour), time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)} {
+		if err := repo.CreateSession(ctx, domain.CreateSessionInput{
+			SessionID: uuid.NewString(),
+			UserID:    userID,
+			TokenHash: []byte{byte(i)},
+			IPAddr:    "203.0.113.7",
+			ExpiresAt: expires,
+		}); err != nil {
+			t.Fatalf("create session: %v", err)
+		}
+	}
+
+	session, err := repo.GetActiveSessionByTokenHash(ctx, []byte{0})
+	if err != nil || session.UserID != userID || session.StepUpRequired {
+		t.Fatalf("active session = %+v, %v", session, err)
+	}
+	if err := repo.SetSessionStepUp(ctx, session.ID, true); err != nil {
+		t.Fatalf("SetSessionStepUp: %v", err)
+	}
+	if session, err := repo.GetActiveSessionByTokenHash(ctx, []byte{0}); err != nil || !session.StepUpRequired {
+		t.Fatalf("session after SetSessionStepUp = %+v, %v; want step-up required", session, err)
+	}
+	if _, err := repo.GetActiveSessionByTokenHash(ctx, []byte{1}); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expired session: err = %v, want ErrNotFound", err)
+	}
+	first, err := repo.DeleteExpiredSessions(ctx, time.Now(), "", 1)
+	if err != nil || first.Deleted != 1 || first.LastID == "" {
+		t.Fatalf("DeleteExpiredSessions(limit 1) = %+v, %v; want 1", first, err)
+	}
+	if batch, err := repo.DeleteExpiredSessions(ctx, time.Now(), first.LastID, 10); err != nil || batch.Deleted != 1 || batch.LastID <= first.LastID {
+		t.Fatalf("DeleteExpiredSessions after %s = %+v, %v; want the other expired session", first.LastID, batch, err)
+	}
+
+	if revoked, err := repo.RevokeSessionByTokenHash(ctx, []byte{0}); err != nil || !revoked {
+		t.Fatalf("RevokeSessionByTokenHash = %v, %v", revoked, err)
+	}
+	if batch, err := repo.DeleteExpiredSessions(ctx, time.Now().Add(-time.Hour), "", 10); err != nil || batch.Deleted != 0 {
+		t.Fatalf("DeleteExpiredSessions kept for retention = %+v, %v; want 0", batch, err)
+	}
+	if batch, err := repo.DeleteExpiredSessions(ctx, time.Now().Add(time.Minute), "", 10); err != nil || batch.Deleted != 1 {
+		t.Fatalf("DeleteExpiredSessions past retention = %+v, %v; want 1", batch, err)
+	}
+}
+
+func TestSQLiteVaultItems(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteVaultRepository(conn)
+	ctx := context.Background()
+	ownerID := createSQLiteUser(t, conn, "bob@example.com")
+
+	before, err := repo.GetVaultRevision(ctx, ownerID)
+	if err != nil {
+		t.Fatalf("revision of an empty vault: %v", err)
+	}
+
+	item, err := repo.CreateVaultItem(ctx, domain.CreateVaultItemInput{
+		OwnerUserID: ownerID,
+		Ciphertext:  []byte("v1"),
+		Nonce:       []byte("n"),
+		WrappedDEK:  []byte("dek"),
+		WrapNonce:   []byte("wn"),
+		AlgoVersion: "xchacha20poly1305-v1",
+		Metadata:    []byte(`{"kind":"login"}`),
+	})
+	if err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	if item.Version != 1 || item.IsShared || item.CreatedAt.IsZero() {
+		t.Fatalf("created item = %+v", item)
+	}
+
+	updated, err := repo.UpdateVaultItemForOwner(ctx, item.ID, ownerID, domain.UpdateVaultItemInput{
+		Ciphertext:  []byte("v2"),
+		Nonce:       []byte("n"),
+		WrappedDEK:  []byte("dek"),
+		WrapNonce:   []byte("wn"),
+		AlgoVersion: "xchacha20poly1305-v1",
+	})
+	if err != nil || updated.Version != 2 || string(updated.Ciphertext) != "v2" {
+		t.Fatalf("update = %+v, %v", updated, err)
+	}
+	versions, err := repo.ListVaultItemVersionsByOwner(ctx, item.ID, ownerID)
+	if err != nil || len(versions) != 1 || string(versions[0].Ciphertext) != "v1" {
+		t.Fatalf("versions = %+v, %v", versions, err)
+	}
+
+	after, err := repo.GetVaultRevision(ctx, ownerID)
+	if err != nil || after == before {
+		t.Fatalf("revision = %q, %v; want it to change from %q", after, err, before)
+	}
+
+	if deleted, err := repo.DeleteVaultItemForOwner(ctx, item.ID, ownerID); err != nil || !deleted {
+		t.Fatalf("delete = %v, %v", deleted, err)
+	}
+	trash, err := repo.ListDeletedVaultItemsByOwner(ctx, ownerID)
+	if err != nil || len(trash) != 1 || trash[0].DeletedAt == nil {
+		t.Fatalf("trash = %+v, %v", trash, err)
+	}
+	if _, err := repo.RestoreVaultItemForOwner(ctx, item.ID, ownerID); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if _, err := repo.RestoreVaultItemForOwner(ctx, item.ID, ownerID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("restore of a live item: err = %v, want ErrNotFound", err)
+	}
+
+	items, err := repo.ListVaultItemsByOwner(ctx, ownerID)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("items = %+v, %v", items, err)
+	}
+}
+
+func TestSQLiteAuditFilterAndExport(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteAuditRepository(conn)
+	ctx := context.Background()
+
+	start := time.Now().Add(-time.Minute)
+	for i, eventType := range []domain.EventType{domain.EventTypeAuthLoginSuccess, domain.EventTypeAuthLoginFailed, domain.EventTypeVaultItemCreated} {
+		event := domain.AuditEvent{
+			ID:        uuid.New(),
+			EventType: eventType,
+			EventData: json.RawMessage(`{"n":1}`),
+			IPAddress: "198.51.100.1",
+			CreatedAt: start.Add(time.Duration(i) * time.Second),
+		}
+		if err := repo.CreateEvent(ctx, event); err != nil {
+			t.Fatalf("create event: %v", err)
+		}
+		if err := repo.CreateEvent(ctx, event); err != nil {
+			t.Fatalf("replayed event: %v", err)
+		}
+	}
+
+	events, total, err := repo.ListEvents(ctx, 10, 0, domain.AuditFilter{
+		EventTypes: []domain.EventType{domain.EventTypeAuthLoginSuccess, domain.EventTypeAuthLoginFailed},
+		IPAddress:  "198.51.100.1",
+	})
+	if err != nil || total != 2 || len(events) != 2 {
+		t.Fatalf("filtered events = %d of %d, %v; want 2", len(events), total, err)
+	}
+	if events[0].EventType != domain.EventTypeAuthLoginFailed {
+		t.Fatalf("first event = %s, want the newest", events[0].EventType)
+	}
+
+	// ListEventsAfter leaves out events recorded in the current instant.
+	time.Sleep(time.Millisecond)
+
+	var exported int
+	locked, err := repo.AdvanceExportCursor(ctx, "test", func(cursor domain.AuditCursor) (domain.AuditCursor, error) {
+		batch, err := repo.ListEventsAfter(ctx, cursor, 0, 10)
+		if err != nil {
+			return cursor, err
+		}
+		exported = len(batch)
+		last := batch[len(batch)-1]
+		return domain.AuditCursor{RecordedAt: last.RecordedAt, EventID: last.ID}, nil
+	})
+	if err != nil || !locked || exported != 3 {
+		t.Fatalf("export = %d events, locked %v, %v; want 3", exported, locked, err)
+	}
+	_, err = repo.AdvanceExportCursor(ctx, "test", func(cursor domain.AuditCursor) (domain.AuditCursor, error) {
+		batch, err := repo.ListEventsAfter(ctx, cursor, 0, 10)
+		exported = len(batch)
+		return cursor, err
+	})
+	if err != nil || exported != 0 {
+		t.Fatalf("second export = %d events, %v; want none", exported, err)
+	}
+}
+
+func TestSQLiteOutboxClaim(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteOutboxRepository(conn)
+	ctx := context.Background()
+
+	if err := repo.Enqueue(ctx, domain.OutboxTopicAudit, json.RawMessage(`{"a":1}`)); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	claimed, err := repo.ClaimDue(ctx, 10, time.Minute)
+	if err != nil || len(claimed) != 1 || claimed[0].Attempts != 1 || string(claimed[0].Payload) != `{"a":1}` {
+		t.Fatalf("claim = %+v, %v", claimed, err)
+	}
+	if again, err := repo.ClaimDue(ctx, 10, time.Minute); err != nil || len(again) != 0 {
+		t.Fatalf("leased event claimed again: %+v, %v", again, err)
+	}
+	if err := repo.MarkDispatched(ctx, claimed[0].ID); err != nil {
+		t.Fatalf("mark dispatched: %v", err)
+	}
+	if deleted, err := repo.DeleteDispatchedBefore(ctx, time.Now().Add(time.Minute)); err != nil || deleted != 1 {
+		t.Fatalf("DeleteDispatchedBefore = %d, %v; want 1", deleted, err)
+	}
+}
+
+func TestSQLiteWebhookEndpointsForEvent(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteWebhookRepository(conn)
+	ctx := context.Background()
+	userID := createSQLiteUser(t, conn, "carol@example.com")
+
+	for _, types := range [][]domain.EventType{nil, {domain.EventTypeAuthLoginFailed}, {domain.EventTypeVaultItemCreated}} {
+		if _, err := repo.CreateEndpoint(ctx, domain.WebhookEndpoint{
+			ID:         uuid.NewString(),
+			UserID:     userID,
+			URL:        "https://hooks.example.com",
+			SecretEnc:  []byte("secret"),
+			EventTypes: types,
+			Enabled:    true,
+		}); err != nil {
+			t.Fatalf("create endpoint: %v", err)
+		}
+	}
+
+	endpoints, err := repo.ListEndpointsForEvent(ctx, userID, domain.EventTypeAuthLoginFailed)
+	if err != nil || len(endpoints) != 2 {
+		t.Fatalf("endpoints for login failure = %d, %v; want the catch-all and the subscribed one", len(endpoints), err)
+	}
+
+	if err := repo.EnqueueDelivery(ctx, domain.WebhookDelivery{EndpointID: endpoints[0].ID, EventID: uuid.NewString(), EventType: domain.EventTypeAuthLoginFailed, Payload: []byte(`{}`)}); err != nil {
+		t.Fatalf("enqueue delivery: %v", err)
+	}
+	deliveries, err := repo.ClaimDueDeliveries(ctx, 10, time.Minute)
+	if err != nil || len(deliveries) != 1 || deliveries[0].Endpoint.URL != "https://hooks.example.com" {
+		t.Fatalf("claimed deliveries = %+v, %v", deliveries, err)
+	}
+}
+
+func TestSQLiteAdminStats(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteAdminRepository(conn)
+	ctx := context.Background()
+	userID := createSQLiteUser(t, conn, "dave@example.com")
+	if err := repository.NewSQLiteAuthRepository(conn).CreateSession(ctx, domain.CreateSessionInput{
+		SessionID: uuid.NewString(),
+		UserID:    userID,
+		TokenHash: []byte("token"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	stats, err := repo.GetInstanceStats(ctx, 7)
+	if err != nil {
+		t.Fatalf("GetInstanceStats: %v", err)
+	}
+	if stats.Users != 1 || stats.ActiveSessions != 1 || stats.ActiveUsers1d != 1 || stats.Storage.DatabaseBytes == 0 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if len(stats.DailyActive) != 7 || stats.DailyActive[6].Users != 1 || stats.DailyActive[0].Users != 0 {
+		t.Fatalf("daily active = %+v", stats.DailyActive)
+	}
+
+	user, err := repo.GetUser(ctx, userID)
+	if err != nil || user.ActiveSessions != 1 || user.LastLoginAt == nil {
+		t.Fatalf("GetUser = %+v, %v", user, err)
+	}
+}
+
+func TestSQLiteMaintenanceState(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteAdminRepository(conn)
+	ctx := context.Background()
+	adminID := createSQLiteUser(t, conn, "erin@example.com")
+
+	if state, err := repo.GetMaintenance(ctx); err != nil || state.Enabled {
+		t.Fatalf("initial state = %+v, %v", state, err)
+	}
+	since := time.Now().UTC().Truncate(time.Second)
+	for _, want := range []domain.MaintenanceState{
+		{Enabled: true, Message: "restoring", Since: &since},
+		{},
+	} {
+		if err := repo.SaveMaintenance(ctx, want, &adminID); err != nil {
+			t.Fatalf("SaveMaintenance: %v", err)
+		}
+		got, err := repo.GetMaintenance(ctx)
+		if err != nil || got.Enabled != want.Enabled || got.Message != want.Message || (got.Since == nil) != (want.Since == nil) {
+			t.Fatalf("GetMaintenance = %+v, %v; want %+v", got, err, want)
+		}
+		if want.Since != nil && !got.Since.Equal(since) {
+			t.Fatalf("since = %v, want %v", got.Since, since)
+		}
+	}
+}
+
+func TestNewStoreMatchesDriver(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, "sqlite://"+filepath.Join(t.TempDir(), "pmv2.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	store, err := repository.NewStore(db)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	if _, ok := store.Auth().(*repository.SQLiteAuthRepository); !ok {
+		t.Fatalf("auth repository = %T, want the SQLite one", store.Auth())
+	}
+	if _, ok := store.Vault().(*repository.SQLiteVaultRepository); !ok {
+		t.Fatalf("vault repository = %T, want the SQLite one", store.Vault())
+	}
+}
+
+func TestAttachReplicaRequiresPostgres(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, "sqlite://"+filepath.Join(t.TempDir(), "pmv2.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	if err := db.AttachReplica(ctx, "sqlite://"+filepath.Join(t.TempDir(), "replica.db")); err == nil {
+		t.Fatal("AttachReplica accepted a SQLite replica")
+	}
+	if db.Replica() != nil {
+		t.Fatal("a rejected replica was kept")
+	}
+	if _, err := repository.NewStore(db); err != nil {
+		t.Fatalf("new store without a replica: %v", err)
+	}
+}
+
+func TestSQLiteDataExports(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteDataExportRepository(conn)
+	ctx := context.Background()
+	userID := createSQLiteUser(t, conn, "frank@example.com")
+
+	export, err := repo.CreateDataExport(ctx, domain.DataExport{ID: uuid.NewString(), UserID: userID})
+	if err != nil || export.Status != domain.DataExportPending {
+		t.Fatalf("CreateDataExport = %+v, %v", export, err)
+	}
+	if pending, err := repo.FindPendingDataExport(ctx, userID); err != nil || pending.ID != export.ID {
+		t.Fatalf("FindPendingDataExport = %+v, %v; want %s", pending, err, export.ID)
+	}
+	if _, err := repo.GetDataExportArchive(ctx, userID, export.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("archive of a pending export: err = %v, want ErrNotFound", err)
+	}
+
+	completedAt := time.Now().UTC().Truncate(time.Millisecond)
+	expiresAt := completedAt.Add(time.Hour)
+	if err := repo.CompleteDataExport(ctx, export.ID, []byte("zip"), completedAt, expiresAt); err != nil {
+		t.Fatalf("CompleteDataExport: %v", err)
+	}
+	got, err := repo.GetDataExport(ctx, userID, export.ID)
+	if err != nil || got.Status != domain.DataExportReady || got.SizeBytes != 3 || got.ExpiresAt == nil || !got.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("GetDataExport = %+v, %v", got, err)
+	}
+	if _, err := repo.FindPendingDataExport(ctx, userID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("FindPendingDataExport after completion: err = %v, want ErrNotFound", err)
+	}
+	if _, err := repo.GetDataExport(ctx, createSQLiteUser(t, conn, "grace@example.com"), export.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("GetDataExport by another user: err = %v, want ErrNotFound", err)
+	}
+	if archive, err := repo.GetDataExportArchive(ctx, userID, export.ID); err != nil || string(archive) != "zip" {
+		t.Fatalf("GetDataExportArchive = %q, %v", archive, err)
+	}
+
+	if n, err := repo.DeleteExpiredDataExports(ctx, completedAt); err != nil || n != 0 {
+		t.Fatalf("DeleteExpiredDataExports before expiry = %d, %v", n, err)
+	}
+	if n, err := repo.DeleteExpiredDataExports(ctx, expiresAt.Add(time.Second)); err != nil || n != 1 {
+		t.Fatalf("DeleteExpiredDataExports after expiry = %d, %v; want 1", n, err)
+	}
+}
+
+func TestSQLiteEraseUser(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteAdminRepository(conn)
+	auth := repository.NewSQLiteAuthRepository(conn)
+	audit := repository.NewSQLiteAuditRepository(conn)
+	ctx := context.Background()
+	userID := createSQLiteUser(t, conn, "erin@example.com")
+	otherID := createSQLiteUser(t, conn, "frank@example.com")
+
+	for i, id := range []string{userID, otherID} {
+		if err := auth.CreateSession(ctx, domain.CreateSessionInput{
+			SessionID: uuid.NewString(),
+			UserID:    id,
+			TokenHash: []byte{byte(i)},
+			ExpiresAt: time.Now().Add(time.Hour),
+		}); err != nil {
+			t.Fatalf("create session: %v", err)
+		}
+	}
+	uid, other := uuid.MustParse(userID), uuid.MustParse(otherID)
+	for _, event := range []domain.AuditEvent{
+		{ID: uuid.New(), UserID: &uid, EventType: domain.EventTypeAuthLoginSuccess},
+		{ID: uuid.New(), UserID: &other, EventType: domain.EventTypeAuthLoginSuccess},
+		{ID: uuid.New(), UserID: &other, ActorUserID: &other, EventType: domain.EventTypeFamilyInviteSent, EventData: json.RawMessage(`{"email":"erin@example.com"}`)},
+	} {
+		event.CreatedAt = time.Now()
+		if err := audit.CreateEvent(ctx, event); err != nil {
+			t.Fatalf("create event: %v", err)
+		}
+	}
+
+	before, err := repo.CountUserData(ctx, userID, "erin@example.com")
+	if err != nil || before["users"] != 1 || before["sessions"] != 1 || before["audit_events"] != 2 {
+		t.Fatalf("CountUserData before = %v, %v", before, err)
+	}
+
+	if _, err := repo.EraseUser(ctx, userID, "erin@example.com"); err != nil {
+		t.Fatalf("EraseUser: %v", err)
+	}
+	after, err := repo.CountUserData(ctx, userID, "erin@example.com")
+	if err != nil {
+		t.Fatalf("CountUserData: %v", err)
+	}
+	for table, n := range after {
+		if n != 0 {
+			t.Errorf("%s still has %d rows about the erased user", table, n)
+		}
+	}
+	if kept, err := repo.CountUserData(ctx, otherID, ""); err != nil || kept["users"] != 1 || kept["sessions"] != 1 || kept["audit_events"] != 1 {
+		t.Fatalf("other user's data = %v, %v; want it untouched", kept, err)
+	}
+	if _, err := repo.EraseUser(ctx, userID, ""); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("second EraseUser: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteDeleteAuditEventsBefore(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteAuditRepository(conn)
+	ctx := context.Background()
+
+	for _, eventType := range []domain.EventType{domain.EventTypeAuthLoginSuccess, domain.EventTypeAuthLoginFailed, domain.EventTypeVaultItemCreated} {
+		if err := repo.CreateEvent(ctx, domain.AuditEvent{ID: uuid.New(), EventType: eventType, CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("create event: %v", err)
+		}
+	}
+
+	if deleted, err := repo.DeleteEventsBefore(ctx, time.Now().Add(-time.Hour), "", 10); err != nil || deleted != 0 {
+		t.Fatalf("DeleteEventsBefore(an hour ago) = %d, %v; want 0", deleted, err)
+	}
+	cutoff := time.Now().Add(time.Minute)
+	if deleted, err := repo.DeleteEventsBefore(ctx, cutoff, domain.LoginHistoryCategory, 1); err != nil || deleted != 1 {
+		t.Fatalf("DeleteEventsBefore(login, limit 1) = %d, %v; want 1", deleted, err)
+	}
+	if deleted, err := repo.DeleteEventsBefore(ctx, cutoff, domain.LoginHistoryCategory, 10); err != nil || deleted != 1 {
+		t.Fatalf("DeleteEventsBefore(login) = %d, %v; want the other login event", deleted, err)
+	}
+	if _, total, err := repo.ListEvents(ctx, 10, 0, domain.AuditFilter{}); err != nil || total != 1 {
+		t.Fatalf("events left = %d, %v; want only the vault event", total, err)
+	}
+	if deleted, err := repo.DeleteEventsBefore(ctx, cutoff, "", 10); err != nil || deleted != 1 {
+		t.Fatalf("DeleteEventsBefore(all) = %d, %v; want 1", deleted, err)
+	}
+}
+
+func TestSQLiteIPBans(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteIPBanRepository(conn)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	if _, err := repo.GetIPBan(ctx, "203.0.113.7"); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("GetIPBan(missing) err = %v, want ErrNotFound", err)
+	}
+
+	first, err := repo.SaveIPBan(ctx, domain.IPBan{IP: "203.0.113.7", Offenses: 1, Reason: "login_failed", BannedUntil: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("SaveIPBan: %v", err)
+	}
+	second, err := repo.SaveIPBan(ctx, domain.IPBan{IP: "203.0.113.7", Offenses: 2, Reason: "rate_limited", BannedUntil: now.Add(2 * time.Hour)})
+	if err != nil {
+		t.Fatalf("SaveIPBan(again): %v", err)
+	}
+	if second.Offenses != 2 || second.Reason != "rate_limited" || !second.CreatedAt.Equal(first.CreatedAt) {
+		t.Fatalf("upserted ban = %+v, want offense 2 keeping created_at %v", second, first.CreatedAt)
+	}
+	if _, err := repo.SaveIPBan(ctx, domain.IPBan{IP: "2001:db8::1", Offenses: 1, Reason: "login_failed", BannedUntil: now.Add(-time.Hour)}); err != nil {
+		t.Fatalf("SaveIPBan(ended): %v", err)
+	}
+
+	active, err := repo.ListActiveIPBans(ctx, now)
+	if err != nil || len(active) != 1 || active[0].IP != "203.0.113.7" {
+		t.Fatalf("ListActiveIPBans = %+v, %v; want only 203.0.113.7", active, err)
+	}
+
+	if deleted, err := repo.DeleteIPBansEndedBefore(ctx, now); err != nil || deleted != 1 {
+		t.Fatalf("DeleteIPBansEndedBefore = %d, %v; want the ended ban", deleted, err)
+	}
+	if err := repo.DeleteIPBan(ctx, "203.0.113.7"); err != nil {
+		t.Fatalf("DeleteIPBan: %v", err)
+	}
+	if err := repo.DeleteIPBan(ctx, "203.0.113.7"); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("DeleteIPBan(again) err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteDevices(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteDeviceRepository(conn)
+	auth := repository.NewSQLiteAuthRepository(conn)
+	ctx := context.Background()
+	userID := createSQLiteUser(t, conn, "ada@example.com")
+
+	first, created, err := repo.TouchDevice(ctx, userID, []byte("key"), true, "Firefox/130")
+	if err != nil || !created || !first.Fingerprinted {
+		t.Fatalf("TouchDevice = %+v, %v, %v; want a new fingerprinted device", first, created, err)
+	}
+	again, created, err := repo.TouchDevice(ctx, userID, []byte("key"), true, "Firefox/131")
+	if err != nil || created || again.ID != first.ID || again.Label != "Firefox/131" || !again.FirstSeenAt.Equal(first.FirstSeenAt) {
+		t.Fatalf("TouchDevice(again) = %+v, %v, %v; want the same device relabelled", again, created, err)
+	}
+	if _, created, err := repo.TouchDevice(ctx, createSQLiteUser(t, conn, "bob@example.com"), []byte("key"), true, ""); err != nil || !created {
+		t.Fatalf("TouchDevice(other user) = %v, %v; want a device of their own", created, err)
+	}
+
+	if err := auth.CreateSession(ctx, domain.CreateSessionInput{
+		SessionID: uuid.NewString(), UserID: userID, TokenHash: []byte("token"), DeviceID: first.ID, ExpiresAt: time.Now().Add(time.Hour),
+	}); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	session, err := auth.GetActiveSessionByTokenHash(ctx, []byte("token"))
+	if err != nil || session.DeviceID != first.ID {
+		t.Fatalf("session = %+v, %v; want device %s", session, err, first.ID)
+	}
+
+	devices, err := repo.ListDevices(ctx, userID)
+	if err != nil || len(devices) != 1 {
+		t.Fatalf("ListDevices = %+v, %v", devices, err)
+	}
+	if err := repo.DeleteDevice(ctx, userID, first.ID); err != nil {
+		t.Fatalf("DeleteDevice: %v", err)
+	}
+	if err := repo.DeleteDevice(ctx, userID, first.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("DeleteDevice(again) err = %v, want ErrNotFound", err)
+	}
+	session, err = auth.GetActiveSessionByTokenHash(ctx, []byte("token"))
+	if err != nil || session.DeviceID != "" {
+		t.Fatalf("session = %+v, %v; want it kept without a device", session, err)
+	}
+}
+
+func TestSQLitePasskeys(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLitePasskeyRepository(conn)
+	auth := repository.NewSQLiteAuthRepository(conn)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	userID := uuid.NewString()
+	if err := auth.CreateUserWithCredentials(ctx, domain.CreateUserInput{UserID: userID, Email: "ada@example.com", Salt: []byte("salt")}); err != nil {
+		t.Fatalf("CreateUserWithCredentials(passwordless): %v", err)
+	}
+	record, err := auth.GetUserAuthByID(ctx, userID)
+	if err != nil || !record.Passwordless() || record.Email != "ada@example.com" {
+		t.Fatalf("GetUserAuthByID = %+v, %v; want a passwordless account", record, err)
+	}
+
+	challenge := domain.PasskeyChallenge{
+		ID: uuid.NewString(), Purpose: domain.PasskeyChallengeAddPasskey, UserID: userID,
+		Challenge: []byte("challenge"), ExpiresAt: now.Add(time.Minute),
+	}
+	if err := repo.CreatePasskeyChallenge(ctx, challenge); err != nil {
+		t.Fatalf("CreatePasskeyChallenge: %v", err)
+	}
+	if _, err := repo.ConsumePasskeyChallenge(ctx, challenge.ID, domain.PasskeyChallengeLogin, now); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("ConsumePasskeyChallenge(wrong purpose) err = %v, want ErrNotFound", err)
+	}
+	got, err := repo.ConsumePasskeyChallenge(ctx, challenge.ID, challenge.Purpose, now)
+	if err != nil || got.UserID != userID || string(got.Challenge) != "challenge" {
+		t.Fatalf("ConsumePasskeyChallenge = %+v, %v", got, err)
+	}
+	if _, err := repo.ConsumePasskeyChallenge(ctx, challenge.ID, challenge.Purpose, now); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("ConsumePasskeyChallenge(again) err = %v, want ErrNotFound", err)
+	}
+	expired := domain.PasskeyChallenge{ID: uuid.NewString(), Purpose: domain.PasskeyChallengeLogin, Challenge: []byte("c"), ExpiresAt: now.Add(-time.Second)}
+	if err := repo.CreatePasskeyChallenge(ctx, expired); err != nil {
+		t.Fatalf("CreatePasskeyChallenge(expired): %v", err)
+	}
+	if _, err := repo.ConsumePasskeyChallenge(ctx, expired.ID, expired.Purpose, now); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("ConsumePasskeyChallenge(expired) err = %v, want ErrNotFound", err)
+	}
+	if deleted, err := repo.DeleteExpiredPasskeyChallenges(ctx, now); err != nil || deleted != 1 {
+		t.Fatalf("DeleteExpiredPasskeyChallenges = %d, %v; want 1", deleted, err)
+	}
+
+	passkey, err := repo.CreatePasskey(ctx, domain.Passkey{
+		ID: uuid.NewString(), UserID: userID, CredentialID: []byte("cred"), PublicKey: []byte("key"),
+		SignCount: 3, Name: "Laptop", PRFSalt: []byte("prf"), WrappedKey: []byte("wrapped"), WrapNonce: []byte("nonce"),
+	})
+	if err != nil || passkey.SignCount != 3 || string(passkey.WrappedKey) != "wrapped" || passkey.LastUsedAt != nil {
+		t.Fatalf("CreatePasskey = %+v, %v", passkey, err)
+	}
+	if _, err := repo.CreatePasskey(ctx, domain.Passkey{ID: uuid.NewString(), UserID: userID, CredentialID: []byte("cred"), PublicKey: []byte("key")}); !errors.Is(err, domain.ErrPasskeyTaken) {
+		t.Fatalf("CreatePasskey(duplicate) err = %v, want ErrPasskeyTaken", err)
+	}
+	if err := repo.TouchPasskey(ctx, passkey.ID, 4, now); err != nil {
+		t.Fatalf("TouchPasskey: %v", err)
+	}
+	found, err := repo.GetPasskeyByCredentialID(ctx, []byte("cred"))
+	if err != nil || found.SignCount != 4 || found.LastUsedAt == nil {
+		t.Fatalf("GetPasskeyByCredentialID = %+v, %v; want the sign-in recorded", found, err)
+	}
+
+	if err := repo.DeletePasskey(ctx, uuid.NewString(), passkey.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("DeletePasskey(other user) err = %v, want ErrNotFound", err)
+	}
+	if err := repo.DeleteAllPasskeys(ctx, userID); err != nil {
+		t.Fatalf("DeleteAllPasskeys: %v", err)
+	}
+	if passkeys, err := repo.ListPasskeys(ctx, userID); err != nil || len(passkeys) != 0 {
+		t.Fatalf("ListPasskeys = %+v, %v; want none", passkeys, err)
+	}
+}
+
+// TestSQLiteMigrationRelaxesPasswordColumns upgrades an auth_credentials
+// table from before passkey-only accounts.
+func TestSQLiteMigrationRelaxesPasswordColumns(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, "sqlite://"+filepath.Join(t.TempDir(), "pmv2.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := database.MigrateUp(ctx, db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	conn := db.SQL()
+	userID := createSQLiteUser(t, conn, "ada@example.com")
+	for _, stmt := range []string{
+		`ALTER TABLE auth_credentials RENAME TO auth_credentials_new`,
+		`CREATE TABLE auth_credentials (
+			user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			algo TEXT NOT NULL, params TEXT NOT NULL, salt BLOB NOT NULL, password_hash BLOB NOT NULL,
+			mfa_totp_enabled BOOLEAN NOT NULL DEFAULT FALSE, mfa_totp_secret_enc BLOB,
+			totp_failed_attempts INTEGER NOT NULL DEFAULT 0, totp_window_started_at TIMESTAMP, totp_locked_until TIMESTAMP,
+			created_at TIMESTAMP NOT NULL, updated_at TIMESTAMP NOT NULL
+		)`,
+		`INSERT INTO auth_credentials SELECT user_id, algo, params, salt, password_hash, mfa_totp_enabled, mfa_totp_secret_enc,
+			totp_failed_attempts, totp_window_started_at, totp_locked_until, created_at, updated_at FROM auth_credentials_new`,
+		`DROP TABLE auth_credentials_new`,
+	} {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("recreate legacy table: %v", err)
+		}
+	}
+
+	if err := database.MigrateUp(ctx, db); err != nil {
+		t.Fatalf("migrate legacy table: %v", err)
+	}
+	auth := repository.NewSQLiteAuthRepository(conn)
+	if record, err := auth.GetUserAuthByID(ctx, userID); err != nil || string(record.PasswordHash) != "hash" {
+		t.Fatalf("GetUserAuthByID = %+v, %v; want the existing credentials kept", record, err)
+	}
+	if err := auth.CreateUserWithCredentials(ctx, domain.CreateUserInput{UserID: uuid.NewString(), Email: "bob@example.com", Salt: []byte("salt")}); err != nil {
+		t.Fatalf("CreateUserWithCredentials(passwordless) after upgrade: %v", err)
+	}
+}
+
+func TestSQLiteCredentialReset(t *testing.T) {
+	conn := openSQLite(t)
+	auth := repository.NewSQLiteAuthRepository(conn)
+	vault := repository.NewSQLiteVaultRepository(conn)
+	ctx := context.Background()
+	userID := createSQLiteUser(t, conn, "ada@example.com")
+	otherID := createSQLiteUser(t, conn, "bob@example.com")
+
+	var itemIDs []string
+	for _, owner := range []string{userID, userID, otherID} {
+		item, err := vault.CreateVaultItem(ctx, domain.CreateVaultItemInput{
+			OwnerUserID: owner, Ciphertext: []byte("c"), Nonce: []byte("n"), WrappedDEK: []byte("old"), WrapNonce: []byte("wn"), AlgoVersion: "v1",
+		})
+		if err != nil {
+			t.Fatalf("create item: %v", err)
+		}
+		itemIDs = append(itemIDs, item.ID)
+	}
+
+	if err := auth.SetCredentialsCompromised(ctx, userID); err != nil {
+		t.Fatalf("SetCredentialsCompromised: %v", err)
+	}
+	if record, err := auth.GetUserAuthByID(ctx, userID); err != nil || !record.CredentialsCompromised {
+		t.Fatalf("record after flag = %+v, %v", record, err)
+	}
+	if err := auth.CreateSession(ctx, domain.CreateSessionInput{
+		SessionID: uuid.NewString(), UserID: userID, TokenHash: []byte("t"), ExpiresAt: time.Now().Add(time.Hour),
+	}); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if session, err := auth.GetActiveSessionByTokenHash(ctx, []byte("t")); err != nil || !session.CredentialResetRequired {
+		t.Fatalf("session after flag = %+v, %v", session, err)
+	}
+
+	key := func(id, dek string) domain.VaultItemKey {
+		return domain.VaultItemKey{ItemID: id, WrappedDEK: []byte(dek), WrapNonce: []byte("wn2")}
+	}
+	for name, keys := range map[string][]domain.VaultItemKey{
+		"missing item":        {key(itemIDs[0], "new")},
+		"duplicate item":      {key(itemIDs[0], "new"), key(itemIDs[0], "new")},
+		"another user's item": {key(itemIDs[0], "new"), key(itemIDs[2], "new")},
+	} {
+		if err := vault.RewrapVaultItemKeys(ctx, userID, keys); !errors.Is(err, domain.ErrRewrapIncomplete) {
+			t.Fatalf("%s: err = %v, want ErrRewrapIncomplete", name, err)
+		}
+	}
+	if err := vault.RewrapVaultItemKeys(ctx, userID, []domain.VaultItemKey{key(itemIDs[0], "new-0"), key(itemIDs[1], "new-1")}); err != nil {
+		t.Fatalf("RewrapVaultItemKeys: %v", err)
+	}
+	for i, want := range []string{"new-0", "new-1", "old"} {
+		owner := userID
+		if i == 2 {
+			owner = otherID
+		}
+		item, err := vault.GetVaultItemByIDForOwner(ctx, itemIDs[i], owner)
+		if err != nil || string(item.WrappedDEK) != want || item.Version != 1 {
+			t.Fatalf("item %d = %+v, %v; want wrapped dek %q", i, item, err, want)
+		}
+	}
+
+	if err := auth.UpdatePassword(ctx, domain.ResetPasswordInput{UserID: userID, Algo: "argon2id", ParamsJSON: []byte(`{}`), Salt: []byte("s"), PasswordHash: []byte("h")}); err != nil {
+		t.Fatalf("UpdatePassword: %v", err)
+	}
+	if record, err := auth.GetUserAuthByID(ctx, userID); err != nil || record.CredentialsCompromised {
+		t.Fatalf("record after password change = %+v, %v", record, err)
+	}
+}
+
+func TestSQLiteQRLogins(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteQRLoginRepository(conn)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	userID := createSQLiteUser(t, conn, "ada@example.com")
+
+	req := domain.QRLoginRequest{
+		ID: uuid.NewString(), CodeHash: []byte("code"), PollTokenHash: []byte("poll"),
+		DeviceName: "Office PC", IPAddr: "203.0.113.7", UserAgent: "Firefox/131", ExpiresAt: now.Add(time.Minute),
+	}
+	if err := repo.CreateQRLogin(ctx, req); err != nil {
+		t.Fatalf("CreateQRLogin: %v", err)
+	}
+	got, err := repo.GetQRLogin(ctx, req.ID, now)
+	if err != nil || got.Status != domain.QRLoginPending || got.UserID != "" || got.DeviceName != "Office PC" || string(got.PollTokenHash) != "poll" {
+		t.Fatalf("GetQRLogin = %+v, %v", got, err)
+	}
+	if _, err := repo.GetQRLogin(ctx, req.ID, req.ExpiresAt); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("GetQRLogin(expired) err = %v, want ErrNotFound", err)
+	}
+
+	if err := repo.DecideQRLogin(ctx, req.ID, userID, domain.QRLoginApproved, now); err != nil {
+		t.Fatalf("DecideQRLogin: %v", err)
+	}
+	if err := repo.DecideQRLogin(ctx, req.ID, userID, domain.QRLoginDenied, now); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("DecideQRLogin(decided) err = %v, want ErrNotFound", err)
+	}
+	if got, err := repo.GetQRLogin(ctx, req.ID, now); err != nil || got.Status != domain.QRLoginApproved || got.UserID != userID {
+		t.Fatalf("GetQRLogin(approved) = %+v, %v", got, err)
+	}
+	if err := repo.DeleteQRLogin(ctx, req.ID); err != nil {
+		t.Fatalf("DeleteQRLogin: %v", err)
+	}
+	if err := repo.DeleteQRLogin(ctx, req.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("DeleteQRLogin(again) err = %v, want ErrNotFound", err)
+	}
+
+	expired := domain.QRLoginRequest{ID: uuid.NewString(), CodeHash: []byte("c"), PollTokenHash: []byte("p"), ExpiresAt: now.Add(-time.Second)}
+	if err := repo.CreateQRLogin(ctx, expired); err != nil {
+		t.Fatalf("CreateQRLogin(expired): %v", err)
+	}
+	if deleted, err := repo.DeleteExpiredQRLogins(ctx, now); err != nil || deleted != 1 {
+		t.Fatalf("DeleteExpiredQRLogins = %d, %v; want 1", deleted, err)
+	}
+}
+
+func TestSQLiteAutofill(t *testing.T) {
+	conn := openSQLite(t)
+	vault := repository.NewSQLiteVaultRepository(conn)
+	repo := repository.NewSQLiteAutofillRepository(conn)
+	ctx := context.Background()
+	ownerID := createSQLiteUser(t, conn, "ada@example.com")
+	otherID := createSQLiteUser(t, conn, "bob@example.com")
+
+	item, err := vault.CreateVaultItem(ctx, domain.CreateVaultItemInput{
+		OwnerUserID: ownerID, Ciphertext: []byte("c"), Nonce: []byte("n"),
+		WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"), AlgoVersion: "xchacha20poly1305-v1",
+	})
+	if err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	site, other := make([]byte, domain.URIHMACSize), make([]byte, domain.URIHMACSize)
+	site[0], other[0] = 1, 2
+
+	if err := repo.SetURIHMACs(ctx, ownerID, item.ID, [][]byte{site, site, other}); err != nil {
+		t.Fatalf("SetURIHMACs: %v", err)
+	}
+	if hmacs, err := repo.ListURIHMACs(ctx, ownerID); err != nil || len(hmacs[item.ID]) != 2 {
+		t.Fatalf("ListURIHMACs = %v, %v; want 2 for the item", hmacs, err)
+	}
+	if ids, err := repo.MatchURIHMACs(ctx, ownerID, [][]byte{site}); err != nil || len(ids) != 1 || ids[0] != item.ID {
+		t.Fatalf("MatchURIHMACs = %v, %v", ids, err)
+	}
+	if ids, err := repo.MatchURIHMACs(ctx, otherID, [][]byte{site}); err != nil || len(ids) != 0 {
+		t.Fatalf("MatchURIHMACs(other owner) = %v, %v; want none", ids, err)
+	}
+	if err := repo.SetURIHMACs(ctx, ownerID, item.ID, [][]byte{other}); err != nil {
+		t.Fatalf("SetURIHMACs(replace): %v", err)
+	}
+	if ids, err := repo.MatchURIHMACs(ctx, ownerID, [][]byte{site}); err != nil || len(ids) != 0 {
+		t.Fatalf("MatchURIHMACs(replaced) = %v, %v; want none", ids, err)
+	}
+
+	first := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	if _, err := repo.RecordItemUse(ctx, ownerID, item.ID, first); err != nil {
+		t.Fatalf("RecordItemUse: %v", err)
+	}
+	usage, err := repo.RecordItemUse(ctx, ownerID, item.ID, first.Add(time.Minute))
+	if err != nil || usage.UseCount != 2 || usage.LastUsedAt == nil || !usage.LastUsedAt.Equal(first.Add(time.Minute)) {
+		t.Fatalf("RecordItemUse(again) = %+v, %v", usage, err)
+	}
+	if _, err := repo.RecordItemUse(ctx, otherID, item.ID, first); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("RecordItemUse(other owner) err = %v, want ErrNotFound", err)
+	}
+	if all, err := repo.ListItemUsage(ctx, ownerID); err != nil || all[item.ID].UseCount != 2 {
+		t.Fatalf("ListItemUsage = %v, %v", all, err)
+	}
+
+	if deleted, err := vault.DeleteVaultItemForOwner(ctx, item.ID, ownerID); err != nil || !deleted {
+		t.Fatalf("delete item = %v, %v", deleted, err)
+	}
+	if ids, err := repo.MatchURIHMACs(ctx, ownerID, [][]byte{other}); err != nil || len(ids) != 0 {
+		t.Fatalf("MatchURIHMACs(deleted item) = %v, %v; want none", ids, err)
+	}
+	if _, err := repo.RecordItemUse(ctx, ownerID, item.ID, first); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("RecordItemUse(deleted item) err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLitePushTokens(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLitePushTokenRepository(conn)
+	ctx := context.Background()
+	adaID := createSQLiteUser(t, conn, "ada@example.com")
+	bobID := createSQLiteUser(t, conn, "bob@example.com")
+
+	first, err := repo.UpsertPushToken(ctx, domain.PushToken{UserID: adaID, Platform: domain.PushPlatformFCM, Token: "tok"})
+	if err != nil {
+		t.Fatalf("UpsertPushToken: %v", err)
+	}
+	// The same app install signing in to another account moves the token.
+	moved, err := repo.UpsertPushToken(ctx, domain.PushToken{UserID: bobID, Platform: domain.PushPlatformFCM, Token: "tok"})
+	if err != nil || moved.ID != first.ID || moved.UserID != bobID {
+		t.Fatalf("UpsertPushToken(other user) = %+v, %v; want the token moved", moved, err)
+	}
+	if tokens, err := repo.ListPushTokens(ctx, adaID); err != nil || len(tokens) != 0 {
+		t.Fatalf("ListPushTokens(ada) = %+v, %v; want none", tokens, err)
+	}
+	if err := repo.DeletePushToken(ctx, adaID, first.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("DeletePushToken(other user) err = %v, want ErrNotFound", err)
+	}
+
+	if _, err := repo.UpsertPushToken(ctx, domain.PushToken{UserID: bobID, Platform: domain.PushPlatformAPNs, Token: "tok"}); err != nil {
+		t.Fatalf("UpsertPushToken(apns): %v", err)
+	}
+	if err := repo.DeletePushTokenByValue(ctx, domain.PushPlatformFCM, "tok"); err != nil {
+		t.Fatalf("DeletePushTokenByValue: %v", err)
+	}
+	tokens, err := repo.ListPushTokens(ctx, bobID)
+	if err != nil || len(tokens) != 1 || tokens[0].Platform != domain.PushPlatformAPNs {
+		t.Fatalf("ListPushTokens(bob) = %+v, %v; want the apns token", tokens, err)
+	}
+
+	if deleted, err := repo.DeleteStalePushTokens(ctx, time.Now().Add(-time.Hour)); err != nil || deleted != 0 {
+		t.Fatalf("DeleteStalePushTokens(past) = %d, %v; want 0", deleted, err)
+	}
+	if deleted, err := repo.DeleteStalePushTokens(ctx, time.Now().Add(time.Hour)); err != nil || deleted != 1 {
+		t.Fatalf("DeleteStalePushTokens(future) = %d, %v; want 1", deleted, err)
+	}
+}
+
+func TestSQLiteRotateSessionToken(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteAuthRepository(conn)
+	ctx := context.Background()
+	userID := createSQLiteUser(t, conn, "ada@example.com")
+	sessionID := uuid.NewString()
+	if err := repo.CreateSession(ctx, domain.CreateSessionInput{
+		SessionID: sessionID,
+		UserID:    userID,
+		TokenHash: []byte("old"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	if rotated, err := repo.RotateSessionToken(ctx, sessionID, []byte("old"), []byte("new"), time.Now().Add(2*time.Hour)); err != nil || !rotated {
+		t.Fatalf("RotateSessionToken = %v, %v; want rotated", rotated, err)
+	}
+	// The losing side of a concurrent refresh still holds the old hash.
+	if rotated, err := repo.RotateSessionToken(ctx, sessionID, []byte("old"), []byte("other"), time.Now().Add(2*time.Hour)); err != nil || rotated {
+		t.Fatalf("RotateSessionToken(stale) = %v, %v; want not rotated", rotated, err)
+	}
+	if _, err := repo.GetActiveSessionByTokenHash(ctx, []byte("new")); err != nil {
+		t.Fatalf("GetActiveSessionByTokenHash(new): %v", err)
+	}
+
+	retired, err := repo.GetRetiredSessionToken(ctx, []byte("old"))
+	if err != nil || retired.SessionID != sessionID || retired.UserID != userID {
+		t.Fatalf("GetRetiredSessionToken = %+v, %v", retired, err)
+	}
+	if revoked, err := repo.RevokeSessionByID(ctx, sessionID); err != nil || !revoked {
+		t.Fatalf("RevokeSessionByID = %v, %v; want revoked", revoked, err)
+	}
+	if revoked, err := repo.RevokeSessionByID(ctx, sessionID); err != nil || revoked {
+		t.Fatalf("RevokeSessionByID(again) = %v, %v; want no-op", revoked, err)
+	}
+	if active, err := repo.SessionActive(ctx, sessionID); err != nil || active {
+		t.Fatalf("SessionActive = %v, %v; want inactive", active, err)
+	}
+}
+
+func TestSQLiteUserSessions(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteAuthRepository(conn)
+	ctx := context.Background()
+	userID := createSQLiteUser(t, conn, "ada@example.com")
+	otherID := createSQLiteUser(t, conn, "bob@example.com")
+	ids := make([]string, 3)
+	for i, owner := range []string{userID, userID, otherID} {
+		ids[i] = uuid.NewString()
+		if err := repo.CreateSession(ctx, domain.CreateSessionInput{
+			SessionID:  ids[i],
+			UserID:     owner,
+			TokenHash:  []byte(ids[i]),
+			DeviceName: "laptop",
+			ExpiresAt:  time.Now().Add(time.Hour),
+		}); err != nil {
+			t.Fatalf("create session: %v", err)
+		}
+	}
+
+	usedAt := time.Now().UTC().Truncate(time.Millisecond)
+	if err := repo.TouchSession(ctx, ids[0], usedAt); err != nil {
+		t.Fatalf("TouchSession: %v", err)
+	}
+	session, err := repo.GetActiveSessionByTokenHash(ctx, []byte(ids[0]))
+	if err != nil || session.LastUsedAt == nil || !session.LastUsedAt.Equal(usedAt) {
+		t.Fatalf("GetActiveSessionByTokenHash = %+v, %v; want last used %v", session, err, usedAt)
+	}
+	sessions, err := repo.ListUserSessions(ctx, userID)
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("ListUserSessions = %+v, %v", sessions, err)
+	}
+	for _, s := range sessions {
+		if s.DeviceName != "laptop" || (s.ID == ids[0]) != (s.LastUsedAt != nil) {
+			t.Fatalf("session = %+v", s)
+		}
+	}
+
+	if revoked, err := repo.RevokeUserSession(ctx, userID, ids[2]); err != nil || revoked {
+		t.Fatalf("RevokeUserSession(other user's) = %v, %v; want no-op", revoked, err)
+	}
+	if revoked, err := repo.RevokeOtherUserSessions(ctx, userID, ids[0]); err != nil || revoked != 1 {
+		t.Fatalf("RevokeOtherUserSessions = %d, %v; want 1", revoked, err)
+	}
+	if revoked, err := repo.RevokeUserSession(ctx, userID, ids[0]); err != nil || !revoked {
+		t.Fatalf("RevokeUserSession = %v, %v; want revoked", revoked, err)
+	}
+	if sessions, err := repo.ListUserSessions(ctx, userID); err != nil || len(sessions) != 0 {
+		t.Fatalf("ListUserSessions after revoking = %+v, %v", sessions, err)
+	}
+	if sessions, err := repo.ListUserSessions(ctx, otherID); err != nil || len(sessions) != 1 {
+		t.Fatalf("ListUserSessions(other user) = %+v, %v", sessions, err)
+	}
+}
+
+func TestSQLiteSessionBinding(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteAuthRepository(conn)
+	ctx := context.Background()
+	userID := createSQLiteUser(t, conn, "ada@example.com")
+	sessionID := uuid.NewString()
+	if err := repo.CreateSession(ctx, domain.CreateSessionInput{
+		SessionID: sessionID,
+		UserID:    userID,
+		TokenHash: []byte("token"),
+		IPAddr:    "203.0.113.5",
+		UserAgent: "curl/8.0",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	if _, err := repo.GetSessionBinding(ctx, userID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("GetSessionBinding(unset) err = %v, want ErrNotFound", err)
+	}
+	session, err := repo.GetActiveSessionByTokenHash(ctx, []byte("token"))
+	if err != nil || session.Binding.Enabled() || session.IPAddr != "203.0.113.5" {
+		t.Fatalf("GetActiveSessionByTokenHash(unbound) = %+v, %v", session, err)
+	}
+
+	binding := domain.SessionBinding{UserAgent: true, OnMismatch: domain.SessionBindingRevoke}
+	if err := repo.SetSessionBinding(ctx, userID, binding); err != nil {
+		t.Fatalf("SetSessionBinding: %v", err)
+	}
+	if got, err := repo.GetSessionBinding(ctx, userID); err != nil || got != binding {
+		t.Fatalf("GetSessionBinding = %+v, %v; want %+v", got, err, binding)
+	}
+	if err := repo.RebindSession(ctx, sessionID, "198.51.100.9", "curl/8.1"); err != nil {
+		t.Fatalf("RebindSession: %v", err)
+	}
+	session, err = repo.GetActiveSessionByTokenHash(ctx, []byte("token"))
+	if err != nil || session.Binding != binding || session.IPAddr != "198.51.100.9" || session.UserAgent != "curl/8.1" {
+		t.Fatalf("GetActiveSessionByTokenHash(bound) = %+v, %v", session, err)
+	}
+}
+
+func TestSQLiteShareWindows(t *testing.T) {
+	conn := openSQLite(t)
+	vault := repository.NewSQLiteVaultRepository(conn)
+	repo := repository.NewSQLiteSharingRepository(conn)
+	ctx := context.Background()
+	ownerID := createSQLiteUser(t, conn, "ada@example.com")
+	contractorID := createSQLiteUser(t, conn, "bob@example.com")
+	friendID := createSQLiteUser(t, conn, "cy@example.com")
+
+	item, err := vault.CreateVaultItem(ctx, domain.CreateVaultItemInput{
+		OwnerUserID: ownerID, Ciphertext: []byte("c"), Nonce: []byte("n"),
+		WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"), AlgoVersion: "xchacha20poly1305-v1",
+	})
+	if err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	expires := now.Add(-time.Minute)
+	schedule := &domain.ShareSchedule{Days: []time.Weekday{time.Monday, time.Friday}, StartMinute: 540, EndMinute: 1080, Timezone: "Europe/Berlin"}
+	for _, input := range []domain.ShareItemInput{
+		{RecipientID: contractorID, Window: domain.ShareWindow{NotBefore: &now, ExpiresAt: &expires, Schedule: schedule}},
+		{RecipientID: friendID},
+	} {
+		input.ItemID, input.SharedByUserID = item.ID, ownerID
+		input.DEKWrapped, input.WrapNonce, input.Permissions = []byte("dek"), []byte("wn"), "read"
+		if err := repo.CreateShare(ctx, input); err != nil {
+			t.Fatalf("CreateShare: %v", err)
+		}
+	}
+
+	share, err := repo.GetShare(ctx, item.ID, contractorID)
+	w := share.Window
+	if err != nil || w.NotBefore == nil || !w.NotBefore.Equal(now) || w.ExpiresAt == nil || !w.ExpiresAt.Equal(expires) ||
+		w.Schedule == nil || len(w.Schedule.Days) != 2 || w.Schedule.EndMinute != 1080 || w.Schedule.Timezone != "Europe/Berlin" {
+		t.Fatalf("GetShare = %+v, %v; want the window back", share, err)
+	}
+	shared, err := repo.ListSharesByRecipient(ctx, contractorID)
+	if err != nil || len(shared) != 1 || shared[0].ShareWindow.ExpiresAt == nil {
+		t.Fatalf("ListSharesByRecipient = %+v, %v; want the window", shared, err)
+	}
+	if share, err := repo.GetShare(ctx, item.ID, friendID); err != nil || share.Window != (domain.ShareWindow{}) {
+		t.Fatalf("GetShare(unlimited) = %+v, %v; want no window", share, err)
+	}
+
+	expired, err := repo.ListExpiredShares(ctx, now, 10)
+	if err != nil || len(expired) != 1 || expired[0].UserID != contractorID {
+		t.Fatalf("ListExpiredShares = %+v, %v; want the contractor's share only", expired, err)
+	}
+	if expired, err := repo.ListExpiredShares(ctx, now.Add(-time.Hour), 10); err != nil || len(expired) != 0 {
+		t.Fatalf("ListExpiredShares(earlier) = %+v, %v; want none", expired, err)
+	}
+}
+
+func TestSQLiteUserKeys(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteUserKeysRepository(conn)
+	ctx := context.Background()
+	userID := createSQLiteUser(t, conn, "ada@example.com")
+
+	signing := make([]byte, 32)
+	if err := repo.UpsertKeys(ctx, domain.UpsertUserKeysInput{
+		UserID: userID, PublicKeyX25519: []byte("x-key"), PublicKeyEd25519: signing,
+		EncryptedPrivateKeys: []byte("priv"), Nonce: []byte("n"),
+	}); err != nil {
+		t.Fatalf("UpsertKeys: %v", err)
+	}
+	keys, recipientID, err := repo.GetPublicKeyByEmail(ctx, "ada@example.com")
+	if err != nil || recipientID != userID || string(keys.PublicKeyX25519) != "x-key" || len(keys.PublicKeyEd25519) != 32 {
+		t.Fatalf("GetPublicKeyByEmail = %+v, %q, %v; want both public keys", keys, recipientID, err)
+	}
+
+	// Replacing the keys without a signing key drops the old one, which
+	// belonged to the replaced private keys.
+	if err := repo.UpsertKeys(ctx, domain.UpsertUserKeysInput{
+		UserID: userID, PublicKeyX25519: []byte("x-key-2"), EncryptedPrivateKeys: []byte("priv"), Nonce: []byte("n"),
+	}); err != nil {
+		t.Fatalf("UpsertKeys: %v", err)
+	}
+	keys, err = repo.GetKeysByUserID(ctx, userID)
+	if err != nil || string(keys.PublicKeyX25519) != "x-key-2" || keys.PublicKeyEd25519 != nil {
+		t.Fatalf("GetKeysByUserID = %+v, %v; want the new key without a signing key", keys, err)
+	}
+}
+
+func TestSQLiteAccessRequests(t *testing.T) {
+	conn := openSQLite(t)
+	vault := repository.NewSQLiteVaultRepository(conn)
+	repo := repository.NewSQLiteSharingRepository(conn)
+	ctx := context.Background()
+	ownerID := createSQLiteUser(t, conn, "ada@example.com")
+	managerID := createSQLiteUser(t, conn, "bob@example.com")
+	requesterID := createSQLiteUser(t, conn, "cy@example.com")
+
+	item, err := vault.CreateVaultItem(ctx, domain.CreateVaultItemInput{
+		OwnerUserID: ownerID, Ciphertext: []byte("c"), Nonce: []byte("n"),
+		WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"), AlgoVersion: "xchacha20poly1305-v1",
+	})
+	if err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	if owner, err := repo.GetItemOwner(ctx, item.ID); err != nil || owner != ownerID {
+		t.Fatalf("GetItemOwner = %q, %v; want the owner", owner, err)
+	}
+	err = repo.CreateShare(ctx, domain.ShareItemInput{
+		ItemID: item.ID, RecipientID: managerID, SharedByUserID: ownerID,
+		DEKWrapped: []byte("dek"), WrapNonce: []byte("wn"), Permissions: domain.SharePermissionManage,
+	})
+	if err != nil {
+		t.Fatalf("CreateShare: %v", err)
+	}
+
+	req, err := repo.CreateAccessRequest(ctx, domain.AccessRequest{
+		ID: uuid.NewString(), ItemID: item.ID, OwnerUserID: ownerID, RequesterUserID: requesterID, Reason: "please",
+	})
+	if err != nil || req.Status != domain.AccessRequestPending || req.RequesterEmail != "cy@example.com" || req.CreatedAt.IsZero() {
+		t.Fatalf("CreateAccessRequest = %+v, %v", req, err)
+	}
+	for _, approver := range []string{ownerID, managerID} {
+		pending, err := repo.ListPendingAccessRequestsForApprover(ctx, approver)
+		if err != nil || len(pending) != 1 || pending[0].ID != req.ID {
+			t.Fatalf("ListPendingAccessRequestsForApprover = %+v, %v; want the request", pending, err)
+		}
+	}
+	if pending, err := repo.ListPendingAccessRequestsForApprover(ctx, requesterID); err != nil || len(pending) != 0 {
+		t.Fatalf("ListPendingAccessRequestsForApprover(requester) = %+v, %v; want none", pending, err)
+	}
+
+	decidedAt := time.Now().UTC().Truncate(time.Millisecond)
+	if err := repo.DecideAccessRequest(ctx, req.ID, domain.AccessRequestApproved, managerID, decidedAt); err != nil {
+		t.Fatalf("DecideAccessRequest: %v", err)
+	}
+	if err := repo.DecideAccessRequest(ctx, req.ID, domain.AccessRequestDenied, ownerID, decidedAt); !errors.Is(err, domain.ErrAccessRequestDecided) {
+		t.Fatalf("DecideAccessRequest(again) err = %v, want ErrAccessRequestDecided", err)
+	}
+	got, err := repo.GetAccessRequest(ctx, req.ID)
+	if err != nil || got.Status != domain.AccessRequestApproved || got.DecidedByUserID != managerID || got.DecidedAt == nil || !got.DecidedAt.Equal(decidedAt) {
+		t.Fatalf("GetAccessRequest = %+v, %v; want the decision", got, err)
+	}
+	if mine, err := repo.ListAccessRequestsByRequester(ctx, requesterID); err != nil || len(mine) != 1 {
+		t.Fatalf("ListAccessRequestsByRequester = %+v, %v", mine, err)
+	}
+	if _, err := repo.GetAccessRequest(ctx, uuid.NewString()); !errors.Is(err, domain.ErrAccessRequestNotFound) {
+		t.Fatalf("GetAccessRequest(unknown) err = %v, want ErrAccessRequestNotFound", err)
+	}
+}
+
+func TestSQLiteShareInvitations(t *testing.T) {
+	conn := openSQLite(t)
+	vault := repository.NewSQLiteVaultRepository(conn)
+	repo := repository.NewSQLiteSharingRepository(conn)
+	ctx := context.Background()
+	ownerID := createSQLiteUser(t, conn, "ada@example.com")
+
+	item, err := vault.CreateVaultItem(ctx, domain.CreateVaultItemInput{
+		OwnerUserID: ownerID, Ciphertext: []byte("c"), Nonce: []byte("n"),
+		WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"), AlgoVersion: "xchacha20poly1305-v1",
+	})
+	if err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	inv, err := repo.CreateShareInvitation(ctx, domain.ShareInvitation{
+		ID: uuid.NewString(), ItemID: item.ID, SharedByUserID: ownerID, Email: "bob@example.com",
+		Permissions: domain.SharePermissionRead, ExpiresAt: now.Add(time.Hour),
+	})
+	if err != nil || inv.Status != domain.ShareInvitationPending || inv.CreatedAt.IsZero() {
+		t.Fatalf("CreateShareInvitation = %+v, %v", inv, err)
+	}
+	_, err = repo.CreateShareInvitation(ctx, domain.ShareInvitation{
+		ID: uuid.NewString(), ItemID: item.ID, SharedByUserID: ownerID, Email: "bob@example.com",
+		Permissions: domain.SharePermissionRead, ExpiresAt: now.Add(time.Hour),
+	})
+	if !errors.Is(err, domain.ErrShareInvitationExists) {
+		t.Fatalf("CreateShareInvitation(duplicate) err = %v, want ErrShareInvitationExists", err)
+	}
+	if pending, err := repo.ListPendingShareInvitationsByEmail(ctx, "bob@example.com", now); err != nil || len(pending) != 1 {
+		t.Fatalf("ListPendingShareInvitationsByEmail = %+v, %v; want the invitation", pending, err)
+	}
+	if pending, err := repo.ListPendingShareInvitationsByEmail(ctx, "bob@example.com", now.Add(2*time.Hour)); err != nil || len(pending) != 0 {
+		t.Fatalf("ListPendingShareInvitationsByEmail(after expiry) = %+v, %v; want none", pending, err)
+	}
+
+	recipientID := createSQLiteUser(t, conn, "bob@example.com")
+	if err := repo.MarkShareInvitationReady(ctx, inv.ID, recipientID, now); err != nil {
+		t.Fatalf("MarkShareInvitationReady: %v", err)
+	}
+	if err := repo.MarkShareInvitationReady(ctx, inv.ID, recipientID, now); !errors.Is(err, domain.ErrShareInvitationNotFound) {
+		t.Fatalf("MarkShareInvitationReady(again) err = %v, want ErrShareInvitationNotFound", err)
+	}
+	got, err := repo.GetShareInvitation(ctx, inv.ID)
+	if err != nil || got.Status != domain.ShareInvitationReady || got.RecipientUserID != recipientID || got.ReadyAt == nil || !got.ReadyAt.Equal(now) {
+		t.Fatalf("GetShareInvitation = %+v, %v; want it ready", got, err)
+	}
+	if sent, err := repo.ListShareInvitationsBySharer(ctx, ownerID); err != nil || len(sent) != 1 {
+		t.Fatalf("ListShareInvitationsBySharer = %+v, %v", sent, err)
+	}
+
+	if err := repo.DeleteShareInvitation(ctx, inv.ID, recipientID); !errors.Is(err, domain.ErrShareInvitationNotFound) {
+		t.Fatalf("DeleteShareInvitation(not the sharer) err = %v, want ErrShareInvitationNotFound", err)
+	}
+	if deleted, err := repo.DeleteExpiredShareInvitations(ctx, now.Add(2*time.Hour)); err != nil || deleted != 1 {
+		t.Fatalf("DeleteExpiredShareInvitations = %d, %v; want 1", deleted, err)
+	}
+	if _, err := repo.GetShareInvitation(ctx, inv.ID); !errors.Is(err, domain.ErrShareInvitationNotFound) {
+		t.Fatalf("GetShareInvitation(expired) err = %v, want ErrShareInvitationNotFound", err)
+	}
+}
+
+func TestSQLiteShareAccess(t *testing.T) {
+	conn := openSQLite(t)
+	vault := repository.NewSQLiteVaultRepository(conn)
+	repo := repository.NewSQLiteSharingRepository(conn)
+	ctx := context.Background()
+	ownerID := createSQLiteUser(t, conn, "ada@example.com")
+	friendID := createSQLiteUser(t, conn, "bob@example.com")
+
+	item, err := vault.CreateVaultItem(ctx, domain.CreateVaultItemInput{
+		OwnerUserID: ownerID, Ciphertext: []byte("c"), Nonce: []byte("n"),
+		WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"), AlgoVersion: "xchacha20poly1305-v1",
+	})
+	if err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	err = repo.CreateShare(ctx, domain.ShareItemInput{
+		ItemID: item.ID, RecipientID: friendID, SharedByUserID: ownerID,
+		DEKWrapped: []byte("dek"), WrapNonce: []byte("wn"), Permissions: domain.SharePermissionRead,
+	})
+	if err != nil {
+		t.Fatalf("CreateShare: %v", err)
+	}
+
+	at := time.Now().UTC().Truncate(time.Millisecond)
+	if first, err := repo.MarkShareAccessed(ctx, item.ID, friendID, at); err != nil || !first {
+		t.Fatalf("MarkShareAccessed = %v, %v; want the first access", first, err)
+	}
+	if first, err := repo.MarkShareAccessed(ctx, item.ID, friendID, at.Add(time.Minute)); err != nil || first {
+		t.Fatalf("MarkShareAccessed(again) = %v, %v; want false", first, err)
+	}
+	shares, err := repo.ListSharesByItem(ctx, item.ID)
+	if err != nil || len(shares) != 1 || shares[0].FirstAccessedAt == nil || !shares[0].FirstAccessedAt.Equal(at) {
+		t.Fatalf("ListSharesByItem = %+v, %v; want the first access time", shares, err)
+	}
+
+	if _, err := repo.GetItemAccessAlert(ctx, item.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("GetItemAccessAlert(none) err = %v, want ErrNotFound", err)
+	}
+	for _, email := range []bool{false, true} {
+		if err := repo.SetItemAccessAlert(ctx, domain.ItemAccessAlert{ItemID: item.ID, OwnerUserID: ownerID, Email: email}); err != nil {
+			t.Fatalf("SetItemAccessAlert(%v): %v", email, err)
+		}
+	}
+	alert, err := repo.GetItemAccessAlert(ctx, item.ID)
+	if err != nil || alert.OwnerUserID != ownerID || !alert.Email || alert.CreatedAt.IsZero() {
+		t.Fatalf("GetItemAccessAlert = %+v, %v", alert, err)
+	}
+	if err := repo.DeleteItemAccessAlert(ctx, item.ID); err != nil {
+		t.Fatalf("DeleteItemAccessAlert: %v", err)
+	}
+	if _, err := repo.GetItemAccessAlert(ctx, item.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("GetItemAccessAlert(deleted) err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteCanonicalEmailUnique(t *testing.T) {
+	conn := openSQLite(t)
+	auth := repository.NewSQLiteAuthRepository(conn)
+	ctx := context.Background()
+
+	create := func(id, email, canonical string) error {
+		return auth.CreateUserWithCredentials(ctx, domain.CreateUserInput{
+			UserID: id, Email: email, CanonicalEmail: canonical, Algo: "argon2id", ParamsJSON: []byte("{}"), Salt: []byte("salt"), PasswordHash: []byte("hash"),
+		})
+	}
+	if err := create(uuid.NewString(), "a.da@gmail.com", "ada@gmail.com"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := create(uuid.NewString(), "ada+promo@gmail.com", "ada@gmail.com"); !errors.Is(err, domain.ErrEmailTaken) {
+		t.Fatalf("alias of an account: err = %v", err)
+	}
+	// Accounts without a canonical address do not collide.
+	for _, email := range []string{"bob@example.com", "carol@example.com"} {
+		if err := create(uuid.NewString(), email, ""); err != nil {
+			t.Fatalf("create %s: %v", email, err)
+		}
+	}
+}
+
+func TestSQLiteMergeAccounts(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteAdminRepository(conn)
+	vault := repository.NewSQLiteVaultRepository(conn)
+	sharing := repository.NewSQLiteSharingRepository(conn)
+	devices := repository.NewSQLiteDeviceRepository(conn)
+	auth := repository.NewSQLiteAuthRepository(conn)
+	family := repository.NewSQLiteFamilyRepository(conn)
+	ctx := context.Background()
+	sourceID := createSQLiteUser(t, conn, "ada@sso.example")
+	targetID := createSQLiteUser(t, conn, "ada@example.com")
+	carolID := createSQLiteUser(t, conn, "carol@example.com")
+
+	newItem := func(ownerID string) string {
+		item, err := vault.CreateVaultItem(ctx, domain.CreateVaultItemInput{
+			OwnerUserID: ownerID, Ciphertext: []byte("c"), Nonce: []byte("n"),
+			WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"), AlgoVersion: "xchacha20poly1305-v1",
+		})
+		if err != nil {
+			t.Fatalf("create item: %v", err)
+		}
+		return item.ID
+	}
+	share := func(itemID, recipientID, sharedByID string) {
+		if err := sharing.CreateShare(ctx, domain.ShareItemInput{
+			ItemID: itemID, RecipientID: recipientID, SharedByUserID: sharedByID,
+			DEKWrapped: []byte("dek"), WrapNonce: []byte("wn"), Permissions: domain.SharePermissionRead,
+		}); err != nil {
+			t.Fatalf("CreateShare: %v", err)
+		}
+	}
+	ownItem := newItem(sourceID)
+	share(ownItem, targetID, sourceID) // redundant once the target owns it
+	both := newItem(carolID)
+	share(both, sourceID, carolID) // the target has its own share
+	share(both, targetID, carolID)
+	onlySource := newItem(carolID)
+	share(onlySource, sourceID, carolID)
+
+	laptop, _, err := devices.TouchDevice(ctx, targetID, []byte("laptop"), true, "")
+	if err != nil {
+		t.Fatalf("TouchDevice: %v", err)
+	}
+	sourceLaptop, _, err := devices.TouchDevice(ctx, sourceID, []byte("laptop"), true, "")
+	if err != nil {
+		t.Fatalf("TouchDevice: %v", err)
+	}
+	if err := auth.CreateSession(ctx, domain.CreateSessionInput{
+		SessionID: uuid.NewString(), UserID: sourceID, TokenHash: []byte("token"), DeviceID: sourceLaptop.ID, ExpiresAt: time.Now().Add(time.Hour),
+	}); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	for _, pair := range [][2]string{{sourceID, carolID}, {carolID, targetID}} {
+		if err := family.CreateRequest(ctx, pair[0], pair[1]); err != nil {
+			t.Fatalf("CreateRequest: %v", err)
+		}
+	}
+
+	input := domain.AccountMergeInput{
+		SourceUserID: sourceID,
+		TargetUserID: targetID,
+		Shares:       []domain.VaultItemKey{{ItemID: both, WrappedDEK: []byte("b"), WrapNonce: []byte("bn")}},
+	}
+	if _, err := repo.MergeAccounts(ctx, input); !errors.Is(err, domain.ErrShareRewrapIncomplete) {
+		t.Fatalf("MergeAccounts(missing share) err = %v, want ErrShareRewrapIncomplete", err)
+	}
+	input.Shares = append(input.Shares, domain.VaultItemKey{ItemID: onlySource, WrappedDEK: []byte("new"), WrapNonce: []byte("nn")})
+	moved, err := repo.MergeAccounts(ctx, input)
+	if err != nil {
+		t.Fatalf("MergeAccounts: %v", err)
+	}
+	if moved["vault_items"] != 1 || moved["vault_shares"] != 1 || moved["sessions"] != 1 || moved["user_devices"] != 0 {
+		t.Fatalf("moved = %v", moved)
+	}
+
+	if item, err := vault.GetVaultItemByIDForOwner(ctx, ownItem, targetID); err != nil {
+		t.Fatalf("moved item = %+v, %v", item, err)
+	}
+	shares, err := sharing.ListSharesByItem(ctx, onlySource)
+	if err != nil || len(shares) != 1 || shares[0].UserID != targetID || string(shares[0].DEKWrapped) != "new" {
+		t.Fatalf("moved share = %+v, %v; want the re-wrapped key for the target", shares, err)
+	}
+	if shares, err := sharing.ListSharesByItem(ctx, ownItem); err != nil || len(shares) != 0 {
+		t.Fatalf("shares of the moved item = %+v, %v; want none", shares, err)
+	}
+	session, err := auth.GetActiveSessionByTokenHash(ctx, []byte("token"))
+	if err != nil || session.UserID != targetID || session.DeviceID != laptop.ID {
+		t.Fatalf("session = %+v, %v; want it on the target's laptop", session, err)
+	}
+	var memberships int
+	if err := conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM family_memberships`).Scan(&memberships); err != nil || memberships != 1 {
+		t.Fatalf("family memberships = %d, %v; want only the target's", memberships, err)
+	}
+
+	if _, err := auth.GetUserAuthByEmail(ctx, "ada@sso.example"); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("tombstone sign-in lookup err = %v, want ErrNotFound", err)
+	}
+	var mergedInto string
+	if err := conn.QueryRowContext(ctx, `SELECT merged_into_user_id FROM users WHERE id = $1`, sourceID).Scan(&mergedInto); err != nil || mergedInto != targetID {
+		t.Fatalf("merged_into_user_id = %q, %v", mergedInto, err)
+	}
+	if _, err := repo.MergeAccounts(ctx, domain.AccountMergeInput{SourceUserID: sourceID, TargetUserID: targetID}); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("MergeAccounts(again) err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteAttachments(t *testing.T) {
+	conn := openSQLite(t)
+	vault := repository.NewSQLiteVaultRepository(conn)
+	repo := repository.NewSQLiteAttachmentRepository(conn)
+	admin := repository.NewSQLiteAdminRepository(conn)
+	ctx := context.Background()
+	ownerID := createSQLiteUser(t, conn, "ada@example.com")
+	otherID := createSQLiteUser(t, conn, "bob@example.com")
+
+	item, err := vault.CreateVaultItem(ctx, domain.CreateVaultItemInput{
+		OwnerUserID: ownerID, Ciphertext: []byte("c"), Nonce: []byte("n"),
+		WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"), AlgoVersion: "xchacha20poly1305-v1",
+	})
+	if err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	now := time.Now().UTC()
+	for i, id := range []string{uuid.NewString(), uuid.NewString()} {
+		if err := repo.CreateAttachment(ctx, domain.VaultAttachment{
+			ID: id, ItemID: item.ID, FileNameCiphertext: []byte("name"), Nonce: []byte("n"),
+			SizeBytes: 10, StoragePath: "attachments/" + item.ID + "/" + id, CreatedAt: now.Add(time.Duration(i) * time.Second),
+		}); err != nil {
+			t.Fatalf("CreateAttachment: %v", err)
+		}
+	}
+
+	list, err := repo.ListAttachments(ctx, item.ID, ownerID)
+	if err != nil || len(list) != 2 || !list[0].CreatedAt.Before(list[1].CreatedAt) {
+		t.Fatalf("ListAttachments = %+v, %v; want both, oldest first", list, err)
+	}
+	if others, err := repo.ListAttachments(ctx, item.ID, otherID); err != nil || len(others) != 0 {
+		t.Fatalf("ListAttachments(other user) = %+v, %v; want none", others, err)
+	}
+	if owned, err := repo.ListAttachmentsByOwner(ctx, ownerID); err != nil || len(owned) != 2 || owned[0].ID != list[0].ID {
+		t.Fatalf("ListAttachmentsByOwner = %+v, %v; want both, oldest first", owned, err)
+	}
+	if owned, err := repo.ListAttachmentsByOwner(ctx, otherID); err != nil || len(owned) != 0 {
+		t.Fatalf("ListAttachmentsByOwner(other user) = %+v, %v; want none", owned, err)
+	}
+	got, err := repo.GetAttachment(ctx, item.ID, ownerID, list[0].ID)
+	if err != nil || got.StoragePath != list[0].StoragePath || got.SizeBytes != 10 {
+		t.Fatalf("GetAttachment = %+v, %v", got, err)
+	}
+	if _, err := repo.GetAttachment(ctx, item.ID, otherID, list[0].ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("GetAttachment(other user) err = %v, want ErrNotFound", err)
+	}
+	if err := repo.DeleteAttachment(ctx, item.ID, otherID, list[0].ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("DeleteAttachment(other user) err = %v, want ErrNotFound", err)
+	}
+	if err := repo.DeleteAttachment(ctx, item.ID, ownerID, list[0].ID); err != nil {
+		t.Fatalf("DeleteAttachment: %v", err)
+	}
+
+	// Only items that have been in the trash long enough give up theirs.
+	if _, err := vault.DeleteVaultItemForOwner(ctx, item.ID, ownerID); err != nil {
+		t.Fatalf("delete item: %v", err)
+	}
+	if paths, err := repo.DeleteTrashedAttachments(ctx, now.Add(-time.Hour)); err != nil || len(paths) != 0 {
+		t.Fatalf("DeleteTrashedAttachments(before the delete) = %v, %v; want none", paths, err)
+	}
+	if owned, err := repo.ListAttachmentsByOwner(ctx, ownerID); err != nil || len(owned) != 0 {
+		t.Fatalf("ListAttachmentsByOwner(trashed item) = %+v, %v; want none", owned, err)
+	}
+	if counts, err := admin.CountUserData(ctx, ownerID, ""); err != nil || counts["vault_attachments"] != 1 {
+		t.Fatalf("CountUserData = %v, %v; want the remaining attachment", counts, err)
+	}
+	paths, err := repo.DeleteTrashedAttachments(ctx, time.Now().Add(time.Hour))
+	if err != nil || len(paths) != 1 || paths[0] != list[1].StoragePath {
+		t.Fatalf("DeleteTrashedAttachments = %v, %v; want the remaining attachment's path", paths, err)
+	}
+}
+
+func TestSQLitePasswordResets(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLitePasswordResetRepository(conn)
+	ctx := context.Background()
+	userID := createSQLiteUser(t, conn, "ada@example.com")
+
+	now := time.Now().UTC()
+	first := domain.PasswordResetToken{ID: uuid.NewString(), UserID: userID, TokenHash: []byte("first"), ExpiresAt: now.Add(time.Hour)}
+	second := domain.PasswordResetToken{ID: uuid.NewString(), UserID: userID, TokenHash: []byte("second"), ExpiresAt: now.Add(time.Hour)}
+	expired := domain.PasswordResetToken{ID: uuid.NewString(), UserID: userID, TokenHash: []byte("expired"), ExpiresAt: now.Add(-time.Minute)}
+	for _, token := range []domain.PasswordResetToken{first, second, expired} {
+		if err := repo.CreatePasswordReset(ctx, token); err != nil {
+			t.Fatalf("CreatePasswordReset: %v", err)
+		}
+	}
+	if count, err := repo.CountPasswordResets(ctx, userID, now.Add(-time.Hour)); err != nil || count != 3 {
+		t.Fatalf("CountPasswordResets = %d, %v; want 3", count, err)
+	}
+
+	got, err := repo.GetPasswordReset(ctx, []byte("first"), now)
+	if err != nil || got.ID != first.ID || got.UserID != userID {
+		t.Fatalf("GetPasswordReset = %+v, %v", got, err)
+	}
+	if _, err := repo.GetPasswordReset(ctx, []byte("expired"), now); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expired token: err = %v", err)
+	}
+
+	if err := repo.UsePasswordReset(ctx, first.ID, userID, now); err != nil {
+		t.Fatalf("UsePasswordReset: %v", err)
+	}
+	if err := repo.UsePasswordReset(ctx, first.ID, userID, now); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("used twice: err = %v", err)
+	}
+	if _, err := repo.GetPasswordReset(ctx, []byte("second"), now); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("the other token survived: err = %v", err)
+	}
+
+	if deleted, err := repo.DeleteExpiredPasswordResets(ctx, now); err != nil || deleted != 1 {
+		t.Fatalf("DeleteExpiredPasswordResets = %d, %v; want 1", deleted, err)
+	}
+}
+
+func TestSQLiteVaultItemChanges(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteVaultRepository(conn)
+	ctx := context.Background()
+	ownerID := createSQLiteUser(t, conn, "bob@example.com")
+	create := func() domain.VaultItem {
+		t.Helper()
+		item, err := repo.CreateVaultItem(ctx, domain.CreateVaultItemInput{
+			OwnerUserID: ownerID, Ciphertext: []byte("c"), Nonce: []byte("n"), WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"), AlgoVersion: "xchacha20poly1305-v1",
+		})
+		if err != nil {
+			t.Fatalf("create item: %v", err)
+		}
+		return item
+	}
+	unchanged, trashed, purged := create(), create(), create()
+	time.Sleep(5 * time.Millisecond)
+	since := time.Now()
+	time.Sleep(5 * time.Millisecond)
+
+	created := create()
+	trash := func(id string) {
+		t.Helper()
+		if deleted, err := repo.DeleteVaultItemForOwner(ctx, id, ownerID); err != nil || !deleted {
+			t.Fatalf("delete = %v, %v", deleted, err)
+		}
+	}
+	trash(purged.ID)
+	time.Sleep(5 * time.Millisecond)
+	cutoff := time.Now()
+	time.Sleep(5 * time.Millisecond)
+	trash(trashed.ID)
+	if n, err := repo.PurgeDeletedVaultItems(ctx, cutoff); err != nil || n != 1 {
+		t.Fatalf("purge = %d, %v", n, err)
+	}
+	if _, err := repo.CreateVaultItem(ctx, domain.CreateVaultItemInput{
+		OwnerUserID: createSQLiteUser(t, conn, "eve@example.com"), Ciphertext: []byte("c"), Nonce: []byte("n"), WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"), AlgoVersion: "xchacha20poly1305-v1",
+	}); err != nil {
+		t.Fatalf("create other user's item: %v", err)
+	}
+
+	items, deleted, err := repo.ListVaultItemChangesByOwner(ctx, ownerID, since)
+	if err != nil || len(items) != 1 || items[0].ID != created.ID {
+		t.Fatalf("changed items = %+v, %v; want only %s", items, err, created.ID)
+	}
+	if len(deleted) != 2 || !(deleted[0] == trashed.ID && deleted[1] == purged.ID || deleted[0] == purged.ID && deleted[1] == trashed.ID) {
+		t.Fatalf("deleted = %v, want %s and %s", deleted, trashed.ID, purged.ID)
+	}
+	if items, deleted, err := repo.ListVaultItemChangesByOwner(ctx, ownerID, time.Now().Add(time.Minute)); err != nil || len(items) != 0 || len(deleted) != 0 {
+		t.Fatalf("changes since the future = %+v, %v, %v", items, deleted, err)
+	}
+	if _, err := repo.GetVaultItemByIDForOwner(ctx, unchanged.ID, ownerID); err != nil {
+		t.Fatalf("unchanged item: %v", err)
+	}
+
+	if n, err := repo.DeleteVaultItemTombstones(ctx, since); err != nil || n != 0 {
+		t.Fatalf("delete fresh tombstones = %d, %v", n, err)
+	}
+	if n, err := repo.DeleteVaultItemTombstones(ctx, time.Now().Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("delete tombstones = %d, %v", n, err)
+	}
+	if _, deleted, err := repo.ListVaultItemChangesByOwner(ctx, ownerID, since); err != nil || len(deleted) != 1 || deleted[0] != trashed.ID {
+		t.Fatalf("deleted after the tombstone went = %v, %v; want only %s", deleted, err, trashed.ID)
+	}
+}
+
+func TestSQLiteVaultItemPage(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteVaultRepository(conn)
+	ctx := context.Background()
+	ownerID := createSQLiteUser(t, conn, "bob@example.com")
+	folder, err := repository.NewSQLiteFolderRepository(conn).CreateFolder(ctx, domain.CreateVaultFolderInput{
+		OwnerUserID: ownerID, NameCiphertext: []byte("f"), Nonce: []byte("n"),
+	})
+	if err != nil {
+		t.Fatalf("create folder: %v", err)
+	}
+
+	var ids []string
+	for i, kind := range []string{"login", "note", "login", "login"} {
+		input := domain.CreateVaultItemInput{
+			OwnerUserID: ownerID, Ciphertext: []byte("c"), Nonce: []byte("n"), WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"),
+			AlgoVersion: "xchacha20poly1305-v1", Metadata: []byte(`{"kind":"` + kind + `"}`),
+		}
+		if i > 0 {
+			input.FolderID = &folder.ID
+		}
+		item, err := repo.CreateVaultItem(ctx, input)
+		if err != nil {
+			t.Fatalf("create item: %v", err)
+		}
+		ids = append(ids, item.ID)
+		time.Sleep(2 * time.Millisecond)
+	}
+	// Updating the oldest item moves it to the front of the default order
+	// but not of the creation order.
+	if _, err := repo.UpdateVaultItemForOwner(ctx, ids[0], ownerID, domain.UpdateVaultItemInput{
+		Ciphertext: []byte("c2"), Nonce: []byte("n"), WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"), AlgoVersion: "xchacha20poly1305-v1", Metadata: []byte(`{"kind":"login"}`),
+	}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if deleted, err := repo.DeleteVaultItemForOwner(ctx, ids[3], ownerID); err != nil || !deleted {
+		t.Fatalf("delete = %v, %v", deleted, err)
+	}
+
+	page := func(query domain.VaultItemQuery) ([]string, int) {
+		t.Helper()
+		items, total, err := repo.ListVaultItemPage(ctx, ownerID, query)
+		if err != nil {
+			t.Fatalf("ListVaultItemPage(%+v): %v", query, err)
+		}
+		got := make([]string, 0, len(items))
+		for _, item := range items {
+			got = append(got, item.ID)
+		}
+		return got, total
+	}
+	same := func(got, want []string) bool {
+		return strings.Join(got, ",") == strings.Join(want, ",")
+	}
+
+	if got, total := page(domain.VaultItemQuery{Limit: 2}); total != 3 || !same(got, []string{ids[0], ids[2]}) {
+		t.Fatalf("first page = %v of %d", got, total)
+	}
+	if got, total := page(domain.VaultItemQuery{Limit: 2, Offset: 2}); total != 3 || !same(got, []string{ids[1]}) {
+		t.Fatalf("second page = %v of %d", got, total)
+	}
+	if got, _ := page(domain.VaultItemQuery{Sort: domain.VaultItemSortCreated, Ascending: true, Limit: 10}); !same(got, ids[:3]) {
+		t.Fatalf("by creation = %v", got)
+	}
+	if got, total := page(domain.VaultItemQuery{FolderID: folder.ID, Kind: "login", Limit: 10}); total != 1 || !same(got, []string{ids[2]}) {
+		t.Fatalf("filtered = %v of %d", got, total)
+	}
+	if got, total := page(domain.VaultItemQuery{Kind: "card", Limit: 10}); total != 0 || len(got) != 0 {
+		t.Fatalf("no match = %v of %d", got, total)
+	}
+}
+
+func TestSQLiteBackupRegistry(t *testing.T) {
+	conn := openSQLite(t)
+	repo := repository.NewSQLiteBackupRegistryRepository(conn)
+	admin := repository.NewSQLiteAdminRepository(conn)
+	ctx := context.Background()
+	userID := createSQLiteUser(t, conn, "ada@example.com")
+
+	if err := repo.RegisterBackup(ctx, domain.BackupRecord{
+		ID: uuid.NewString(), CreatedByUserID: userID, StorageURI: "download:vault-export.json", Encrypted: true,
+	}); err != nil {
+		t.Fatalf("RegisterBackup: %v", err)
+	}
+	if counts, err := admin.CountUserData(ctx, userID, ""); err != nil || counts["backups_registry"] != 1 {
+		t.Fatalf("CountUserData = %v, %v; want the registered backup", counts, err)
+	}
+}
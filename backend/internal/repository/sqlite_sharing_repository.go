@@ -0,0 +1,438 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+type SQLiteSharingRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteSharingRepository(db *sql.DB) *SQLiteSharingRepository {
+	return &SQLiteSharingRepository{db: db}
+}
+
+func (r *SQLiteSharingRepository) CreateShare(ctx context.Context, input domain.ShareItemInput) error {
+	schedule, err := shareScheduleJSON(input.Window.Schedule)
+	if err != nil {
+		return err
+	}
+	_, err = dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO vault_shares (item_id, user_id, shared_by_user_id, dek_wrapped, wrap_nonce, permissions, not_before, expires_at, schedule, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10)
+	`, input.ItemID, input.RecipientID, input.SharedByUserID, input.DEKWrapped, input.WrapNonce, input.Permissions,
+		sqliteNullTime(input.Window.NotBefore), sqliteNullTime(input.Window.ExpiresAt), schedule, sqliteNow())
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.ErrAlreadyShared
+		}
+		return fmt.Errorf("create share: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteSharingRepository) DeleteShare(ctx context.Context, itemID string, recipientUserID string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM vault_shares WHERE item_id = $1 AND user_id = $2
+	`, itemID, recipientUserID)
+	if err != nil {
+		return fmt.Errorf("delete share: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("read rows affected: %w", err)
+	}
+	if affected == 0 {
+		return domain.ErrShareNotFound
+	}
+	return nil
+}
+
+func (r *SQLiteSharingRepository) UpdateSharePermissions(ctx context.Context, itemID string, recipientUserID string, permissions string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE vault_shares SET permissions = $3, updated_at = $4
+		WHERE item_id = $1 AND user_id = $2
+	`, itemID, recipientUserID, permissions, sqliteNow())
+	if err != nil {
+		return fmt.Errorf("update share permissions: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("read rows affected: %w", err)
+	}
+	if affected == 0 {
+		return domain.ErrShareNotFound
+	}
+	return nil
+}
+
+func (r *SQLiteSharingRepository) ListSharesByRecipient(ctx context.Context, userID string) ([]domain.SharedVaultItem, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT
+			vi.id, vi.owner_user_id, vi.folder_id, vi.ciphertext, vi.nonce,
+			vi.dek_wrapped, vi.wrap_nonce, vi.algo_version, vi.metadata,
+			vi.created_at, vi.updated_at,
+			vs.shared_by_user_id, vs.dek_wrapped, vs.wrap_nonce, vs.permissions,
+			vs.not_before, vs.expires_at, vs.schedule, vs.first_accessed_at,
+			COALESCE(u.email, ''), COALESCE(u.name, '')
+		FROM vault_shares vs
+		JOIN vault_items vi ON vi.id = vs.item_id
+		LEFT JOIN users u ON u.id = vs.shared_by_user_id
+		WHERE vs.user_id = $1
+		ORDER BY vs.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query shared items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]domain.SharedVaultItem, 0)
+	for rows.Next() {
+		var si domain.SharedVaultItem
+		var metadata []byte
+		var window shareWindowColumns
+		var firstAccessedAt sql.NullTime
+		var sharedBy sql.NullString
+		err := rows.Scan(
+			&si.ID, &si.OwnerUserID, &si.FolderID, &si.Ciphertext, &si.Nonce,
+			&si.WrappedDEK, &si.WrapNonce, &si.AlgoVersion, &metadata,
+			&si.CreatedAt, &si.UpdatedAt,
+			&sharedBy, &si.ShareDEK, &si.ShareWrapNonce, &si.Permissions,
+			&window.NotBefore, &window.ExpiresAt, &window.Schedule, &firstAccessedAt,
+			&si.SharedByEmail, &si.SharedByName,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan shared item: %w", err)
+		}
+		if si.ShareWindow, err = window.decode(); err != nil {
+			return nil, err
+		}
+		si.Metadata = metadata
+		si.FirstAccessedAt = nullTimePtr(firstAccessedAt)
+		if sharedBy.Valid {
+			si.SharedByUserID = sharedBy.String
+		}
+		items = append(items, si)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate shared items: %w", err)
+	}
+	return items, nil
+}
+
+func (r *SQLiteSharingRepository) ListSharesByItem(ctx context.Context, itemID string) ([]domain.VaultShare, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+vaultShareColumns+`
+		FROM vault_shares
+		WHERE item_id = $1
+		ORDER BY created_at ASC
+	`, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("query shares by item: %w", err)
+	}
+	return scanVaultShares(rows)
+}
+
+func (r *SQLiteSharingRepository) GetShare(ctx context.Context, itemID string, userID string) (domain.VaultShare, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+vaultShareColumns+`
+		FROM vault_shares
+		WHERE item_id = $1 AND user_id = $2
+	`, itemID, userID)
+	if err != nil {
+		return domain.VaultShare{}, fmt.Errorf("get share: %w", err)
+	}
+	shares, err := scanVaultShares(rows)
+	if err != nil {
+		return domain.VaultShare{}, err
+	}
+	if len(shares) == 0 {
+		return domain.VaultShare{}, domain.ErrShareNotFound
+	}
+	return shares[0], nil
+}
+
+func (r *SQLiteSharingRepository) ListSentShares(ctx context.Context, userID string) ([]domain.SentShare, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT
+			vs.item_id,
+			vi.metadata,
+			vs.user_id,
+			u.email,
+			COALESCE(u.name, ''),
+			vs.permissions,
+			vs.created_at
+		FROM vault_shares vs
+		JOIN vault_items vi ON vi.id = vs.item_id
+		JOIN users u ON u.id = vs.user_id
+		WHERE vs.shared_by_user_id = $1
+		ORDER BY vs.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query sent shares: %w", err)
+	}
+	defer rows.Close()
+
+	shares := make([]domain.SentShare, 0)
+	for rows.Next() {
+		var s domain.SentShare
+		var metadata []byte
+		err := rows.Scan(
+			&s.ItemID, &metadata, &s.RecipientID, &s.RecipientEmail, &s.RecipientName, &s.Permissions, &s.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan sent share: %w", err)
+		}
+		s.ItemTitle = string(metadata)
+		shares = append(shares, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate sent shares: %w", err)
+	}
+	return shares, nil
+}
+
+func (r *SQLiteSharingRepository) DeleteAllSharesBetweenUsers(ctx context.Context, user1ID, user2ID string) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM vault_shares
+		WHERE (shared_by_user_id = $1 AND user_id = $2)
+		   OR (shared_by_user_id = $2 AND user_id = $1)
+	`, user1ID, user2ID)
+	if err != nil {
+		return fmt.Errorf("delete all shares between users: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteSharingRepository) ListExpiredShares(ctx context.Context, now time.Time, limit int) ([]domain.VaultShare, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+vaultShareColumns+`
+		FROM vault_shares
+		WHERE expires_at <= $1
+		ORDER BY expires_at
+		LIMIT $2
+	`, sqliteTime(now), limit)
+	if err != nil {
+		return nil, fmt.Errorf("query expired shares: %w", err)
+	}
+	return scanVaultShares(rows)
+}
+
+func (r *SQLiteSharingRepository) GetItemOwner(ctx context.Context, itemID string) (string, error) {
+	var owner string
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT owner_user_id FROM vault_items WHERE id = $1 AND deleted_at IS NULL
+	`, itemID).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", domain.ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("get item owner: %w", err)
+	}
+	return owner, nil
+}
+
+func (r *SQLiteSharingRepository) CreateAccessRequest(ctx context.Context, req domain.AccessRequest) (domain.AccessRequest, error) {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO access_requests (id, item_id, owner_user_id, requester_user_id, reason, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, req.ID, req.ItemID, req.OwnerUserID, req.RequesterUserID, req.Reason, domain.AccessRequestPending, sqliteNow())
+	if err != nil {
+		return domain.AccessRequest{}, fmt.Errorf("create access request: %w", err)
+	}
+	return r.GetAccessRequest(ctx, req.ID)
+}
+
+func (r *SQLiteSharingRepository) GetAccessRequest(ctx context.Context, id string) (domain.AccessRequest, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+accessRequestColumns+`
+		FROM access_requests ar
+		LEFT JOIN users u ON u.id = ar.requester_user_id
+		WHERE ar.id = $1
+	`, id)
+	if err != nil {
+		return domain.AccessRequest{}, fmt.Errorf("get access request: %w", err)
+	}
+	return firstAccessRequest(rows)
+}
+
+func (r *SQLiteSharingRepository) ListAccessRequestsByRequester(ctx context.Context, requesterUserID string) ([]domain.AccessRequest, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+accessRequestColumns+`
+		FROM access_requests ar
+		LEFT JOIN users u ON u.id = ar.requester_user_id
+		WHERE ar.requester_user_id = $1
+		ORDER BY ar.created_at DESC, ar.id
+	`, requesterUserID)
+	if err != nil {
+		return nil, fmt.Errorf("list access requests: %w", err)
+	}
+	return scanAccessRequests(rows)
+}
+
+func (r *SQLiteSharingRepository) ListPendingAccessRequestsForApprover(ctx context.Context, approverUserID string) ([]domain.AccessRequest, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+accessRequestColumns+`
+		FROM access_requests ar
+		LEFT JOIN users u ON u.id = ar.requester_user_id
+		WHERE ar.status = $2
+		  AND (ar.owner_user_id = $1 OR EXISTS (
+			SELECT 1 FROM vault_shares vs
+			WHERE vs.item_id = ar.item_id AND vs.user_id = $1 AND vs.permissions = $3
+		  ))
+		ORDER BY ar.created_at, ar.id
+	`, approverUserID, domain.AccessRequestPending, domain.SharePermissionManage)
+	if err != nil {
+		return nil, fmt.Errorf("list pending access requests: %w", err)
+	}
+	return scanAccessRequests(rows)
+}
+
+func (r *SQLiteSharingRepository) DecideAccessRequest(ctx context.Context, id string, status domain.AccessRequestStatus, decidedByUserID string, decidedAt time.Time) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE access_requests
+		SET status = $2, decided_by_user_id = $3, decided_at = $4
+		WHERE id = $1 AND status = $5
+	`, id, status, decidedByUserID, sqliteTime(decidedAt), domain.AccessRequestPending)
+	if err != nil {
+		return fmt.Errorf("decide access request: %w", err)
+	}
+	return accessRequestDecided(result)
+}
+
+func (r *SQLiteSharingRepository) CreateShareInvitation(ctx context.Context, inv domain.ShareInvitation) (domain.ShareInvitation, error) {
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		INSERT INTO share_invitations (id, item_id, shared_by_user_id, email, permissions, status, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING created_at
+	`, inv.ID, inv.ItemID, inv.SharedByUserID, inv.Email, inv.Permissions, domain.ShareInvitationPending, sqliteTime(inv.ExpiresAt), sqliteNow()).Scan(&inv.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.ShareInvitation{}, domain.ErrShareInvitationExists
+		}
+		return domain.ShareInvitation{}, fmt.Errorf("create share invitation: %w", err)
+	}
+	inv.Status = domain.ShareInvitationPending
+	return inv, nil
+}
+
+func (r *SQLiteSharingRepository) GetShareInvitation(ctx context.Context, id string) (domain.ShareInvitation, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+shareInvitationColumns+` FROM share_invitations WHERE id = $1
+	`, id)
+	if err != nil {
+		return domain.ShareInvitation{}, fmt.Errorf("get share invitation: %w", err)
+	}
+	return firstShareInvitation(rows)
+}
+
+func (r *SQLiteSharingRepository) ListShareInvitationsBySharer(ctx context.Context, sharedByUserID string) ([]domain.ShareInvitation, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+shareInvitationColumns+` FROM share_invitations
+		WHERE shared_by_user_id = $1
+		ORDER BY created_at DESC, id
+	`, sharedByUserID)
+	if err != nil {
+		return nil, fmt.Errorf("list share invitations: %w", err)
+	}
+	return scanShareInvitations(rows)
+}
+
+func (r *SQLiteSharingRepository) ListPendingShareInvitationsByEmail(ctx context.Context, email string, now time.Time) ([]domain.ShareInvitation, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT `+shareInvitationColumns+` FROM share_invitations
+		WHERE email = $1 AND status = $2 AND expires_at > $3
+		ORDER BY created_at DESC, id
+	`, email, domain.ShareInvitationPending, sqliteTime(now))
+	if err != nil {
+		return nil, fmt.Errorf("list pending share invitations: %w", err)
+	}
+	return scanShareInvitations(rows)
+}
+
+func (r *SQLiteSharingRepository) MarkShareInvitationReady(ctx context.Context, id, recipientUserID string, readyAt time.Time) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE share_invitations SET status = $2, recipient_user_id = $3, ready_at = $4
+		WHERE id = $1 AND status = $5
+	`, id, domain.ShareInvitationReady, recipientUserID, sqliteTime(readyAt), domain.ShareInvitationPending)
+	if err != nil {
+		return fmt.Errorf("mark share invitation ready: %w", err)
+	}
+	return shareInvitationAffected(result)
+}
+
+func (r *SQLiteSharingRepository) DeleteShareInvitation(ctx context.Context, id, sharedByUserID string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM share_invitations WHERE id = $1 AND shared_by_user_id = $2
+	`, id, sharedByUserID)
+	if err != nil {
+		return fmt.Errorf("delete share invitation: %w", err)
+	}
+	return shareInvitationAffected(result)
+}
+
+func (r *SQLiteSharingRepository) DeleteExpiredShareInvitations(ctx context.Context, now time.Time) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM share_invitations WHERE expires_at <= $1`, sqliteTime(now))
+	if err != nil {
+		return 0, fmt.Errorf("delete expired share invitations: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *SQLiteSharingRepository) MarkShareAccessed(ctx context.Context, itemID, userID string, at time.Time) (bool, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE vault_shares SET first_accessed_at = $3
+		WHERE item_id = $1 AND user_id = $2 AND first_accessed_at IS NULL
+	`, itemID, userID, sqliteTime(at))
+	if err != nil {
+		return false, fmt.Errorf("mark share accessed: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (r *SQLiteSharingRepository) GetItemAccessAlert(ctx context.Context, itemID string) (domain.ItemAccessAlert, error) {
+	var alert domain.ItemAccessAlert
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT item_id, owner_user_id, email, created_at FROM vault_item_access_alerts WHERE item_id = $1
+	`, itemID).Scan(&alert.ItemID, &alert.OwnerUserID, &alert.Email, &alert.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.ItemAccessAlert{}, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.ItemAccessAlert{}, fmt.Errorf("get item access alert: %w", err)
+	}
+	return alert, nil
+}
+
+func (r *SQLiteSharingRepository) SetItemAccessAlert(ctx context.Context, alert domain.ItemAccessAlert) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO vault_item_access_alerts (item_id, owner_user_id, email, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (item_id) DO UPDATE SET email = excluded.email
+	`, alert.ItemID, alert.OwnerUserID, alert.Email, sqliteNow())
+	if err != nil {
+		return fmt.Errorf("set item access alert: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteSharingRepository) DeleteItemAccessAlert(ctx context.Context, itemID string) error {
+	if _, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM vault_item_access_alerts WHERE item_id = $1`, itemID); err != nil {
+		return fmt.Errorf("delete item access alert: %w", err)
+	}
+	return nil
+}
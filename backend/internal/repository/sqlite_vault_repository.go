@@ -0,0 +1,497 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/util"
+)
+
+type SQLiteVaultRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteVaultRepository(db *sql.DB) *SQLiteVaultRepository {
+	return &SQLiteVaultRepository{db: db}
+}
+
+// sqliteVaultItemSelect reads the columns scanVaultItem expects.
+const sqliteVaultItemSelect = `
+	SELECT
+		vi.id, vi.owner_user_id, vi.folder_id, vi.ciphertext, vi.nonce,
+		vi.dek_wrapped, vi.wrap_nonce, vi.algo_version, vi.metadata,
+		EXISTS (SELECT 1 FROM vault_shares vs WHERE vs.item_id = vi.id) AS is_shared,
+		vi.version, vi.created_at, vi.updated_at, vi.deleted_at
+	FROM vault_items vi
+`
+
+func (r *SQLiteVaultRepository) CreateVaultItem(ctx context.Context, input domain.CreateVaultItemInput) (domain.VaultItem, error) {
+	tx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return domain.VaultItem{}, fmt.Errorf("begin insert vault item tx: %w", err)
+	}
+	defer rollback()
+
+	item, err := r.insertVaultItem(ctx, tx, input)
+	if err != nil {
+		return domain.VaultItem{}, err
+	}
+
+	if err := commit(); err != nil {
+		return domain.VaultItem{}, fmt.Errorf("commit insert vault item tx: %w", err)
+	}
+	return item, nil
+}
+
+func (r *SQLiteVaultRepository) CreateVaultItemsBulk(ctx context.Context, inputs []domain.CreateVaultItemInput) ([]domain.VaultItem, error) {
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+
+	tx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("begin bulk insert tx: %w", err)
+	}
+	defer rollback()
+
+	items := make([]domain.VaultItem, 0, len(inputs))
+	for _, input := range inputs {
+		item, err := r.insertVaultItem(ctx, tx, input)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	if err := commit(); err != nil {
+		return nil, fmt.Errorf("commit bulk insert tx: %w", err)
+	}
+	return items, nil
+}
+
+func (r *SQLiteVaultRepository) insertVaultItem(ctx context.Context, tx *sql.Tx, input domain.CreateVaultItemInput) (domain.VaultItem, error) {
+	itemID, err := util.NewUUID()
+	if err != nil {
+		return domain.VaultItem{}, err
+	}
+
+	now := sqliteNow()
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO vault_items (
+			id, owner_user_id, folder_id, ciphertext, nonce, dek_wrapped, wrap_nonce, algo_version, metadata, version, created_at, updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 1, $10, $10)
+	`, itemID, input.OwnerUserID, input.FolderID, input.Ciphertext, input.Nonce, input.WrappedDEK, input.WrapNonce, input.AlgoVersion, sqliteJSON(input.Metadata), now); err != nil {
+		return domain.VaultItem{}, fmt.Errorf("insert vault item: %w", err)
+	}
+
+	item, err := scanVaultItem(tx.QueryRowContext(ctx, sqliteVaultItemSelect+`WHERE vi.id = $1`, itemID))
+	if err != nil {
+		return domain.VaultItem{}, fmt.Errorf("read inserted vault item: %w", err)
+	}
+	return item, nil
+}
+
+func (r *SQLiteVaultRepository) ListVaultItemsByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultItem, error) {
+	return r.listVaultItemsByOwner(ctx, ownerUserID, false)
+}
+
+func (r *SQLiteVaultRepository) ListDeletedVaultItemsByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultItem, error) {
+	return r.listVaultItemsByOwner(ctx, ownerUserID, true)
+}
+
+func (r *SQLiteVaultRepository) listVaultItemsByOwner(ctx context.Context, ownerUserID string, deleted bool) ([]domain.VaultItem, error) {
+	deletedPredicate := "IS NULL"
+	orderBy := "vi.updated_at DESC"
+	if deleted {
+		deletedPredicate = "IS NOT NULL"
+		orderBy = "vi.deleted_at DESC NULLS LAST, vi.updated_at DESC"
+	}
+
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, sqliteVaultItemSelect+fmt.Sprintf(`
+		WHERE vi.owner_user_id = $1
+		  AND vi.deleted_at %s
+		ORDER BY %s
+	`, deletedPredicate, orderBy), ownerUserID)
+	if err != nil {
+		return nil, fmt.Errorf("query vault items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]domain.VaultItem, 0)
+	for rows.Next() {
+		item, err := scanVaultItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan vault item: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate vault items: %w", err)
+	}
+	return items, nil
+}
+
+func (r *SQLiteVaultRepository) ListVaultItemPage(ctx context.Context, ownerUserID string, query domain.VaultItemQuery) ([]domain.VaultItem, int, error) {
+	db := dbFor(ctx, r.db)
+	where, orderBy, args := vaultItemPageClauses(query, "json_extract(vi.metadata, '$.kind')", func(id string) any { return id })
+	args = append([]any{ownerUserID}, args...)
+
+	var total int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM vault_items vi WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count vault items: %w", err)
+	}
+	rows, err := db.QueryContext(ctx, sqliteVaultItemSelect+fmt.Sprintf(`
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d
+	`, where, orderBy, len(args)+1, len(args)+2), append(args, query.Limit, query.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query vault item page: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]domain.VaultItem, 0)
+	for rows.Next() {
+		item, err := scanVaultItem(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scan vault item: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("iterate vault item page: %w", err)
+	}
+	return items, total, nil
+}
+
+func (r *SQLiteVaultRepository) GetVaultItemByIDForOwner(ctx context.Context, itemID string, ownerUserID string) (domain.VaultItem, error) {
+	item, err := scanVaultItem(dbFor(ctx, r.db).QueryRowContext(ctx, sqliteVaultItemSelect+`
+		WHERE vi.id = $1 AND vi.owner_user_id = $2
+	`, itemID, ownerUserID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.VaultItem{}, domain.ErrNotFound
+		}
+		return domain.VaultItem{}, fmt.Errorf("get vault item: %w", err)
+	}
+	return item, nil
+}
+
+func (r *SQLiteVaultRepository) ListVaultItemVersionsByOwner(ctx context.Context, itemID string, ownerUserID string) ([]domain.VaultItemVersion, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT
+			viv.id, viv.item_id, viv.owner_user_id, viv.folder_id, viv.ciphertext, viv.nonce,
+			viv.dek_wrapped, viv.wrap_nonce, viv.algo_version, viv.metadata, viv.version, viv.created_at
+		FROM vault_item_versions viv
+		WHERE viv.item_id = $1 AND viv.owner_user_id = $2
+		ORDER BY viv.version DESC, viv.created_at DESC
+	`, itemID, ownerUserID)
+	if err != nil {
+		return nil, fmt.Errorf("list vault item versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make([]domain.VaultItemVersion, 0)
+	for rows.Next() {
+		version, err := scanVaultItemVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan vault item version: %w", err)
+		}
+		versions = append(versions, version)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate vault item versions: %w", err)
+	}
+	return versions, nil
+}
+
+func (r *SQLiteVaultRepository) UpdateVaultItemForOwner(ctx context.Context, itemID string, ownerUserID string, input domain.UpdateVaultItemInput) (domain.VaultItem, error) {
+	tx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return domain.VaultItem{}, fmt.Errorf("begin update vault item tx: %w", err)
+	}
+	defer rollback()
+
+	current, err := scanVaultItem(tx.QueryRowContext(ctx, sqliteVaultItemSelect+`
+		WHERE vi.id = $1 AND vi.owner_user_id = $2 AND vi.deleted_at IS NULL
+	`, itemID, ownerUserID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.VaultItem{}, domain.ErrNotFound
+		}
+		return domain.VaultItem{}, fmt.Errorf("load vault item for update: %w", err)
+	}
+
+	if err := r.insertVaultItemVersion(ctx, tx, current); err != nil {
+		return domain.VaultItem{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE vault_items
+		SET
+			folder_id = $3,
+			ciphertext = $4,
+			nonce = $5,
+			dek_wrapped = $6,
+			wrap_nonce = $7,
+			algo_version = $8,
+			metadata = $9,
+			version = $10,
+			updated_at = $11
+		WHERE id = $1 AND owner_user_id = $2
+	`, itemID, ownerUserID, input.FolderID, input.Ciphertext, input.Nonce, input.WrappedDEK, input.WrapNonce, input.AlgoVersion, sqliteJSON(input.Metadata), current.Version+1, sqliteNow()); err != nil {
+		return domain.VaultItem{}, fmt.Errorf("update vault item: %w", err)
+	}
+
+	updated, err := scanVaultItem(tx.QueryRowContext(ctx, sqliteVaultItemSelect+`WHERE vi.id = $1`, itemID))
+	if err != nil {
+		return domain.VaultItem{}, fmt.Errorf("read updated vault item: %w", err)
+	}
+
+	if err := commit(); err != nil {
+		return domain.VaultItem{}, fmt.Errorf("commit update vault item tx: %w", err)
+	}
+	return updated, nil
+}
+
+func (r *SQLiteVaultRepository) DeleteVaultItemForOwner(ctx context.Context, itemID string, ownerUserID string) (bool, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE vault_items
+		SET deleted_at = $3, updated_at = $3
+		WHERE id = $1 AND owner_user_id = $2 AND deleted_at IS NULL
+	`, itemID, ownerUserID, sqliteNow())
+	if err != nil {
+		return false, fmt.Errorf("delete vault item: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (r *SQLiteVaultRepository) RestoreVaultItemForOwner(ctx context.Context, itemID string, ownerUserID string) (domain.VaultItem, error) {
+	tx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return domain.VaultItem{}, fmt.Errorf("begin restore vault item tx: %w", err)
+	}
+	defer rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE vault_items
+		SET deleted_at = NULL, updated_at = $3
+		WHERE id = $1 AND owner_user_id = $2 AND deleted_at IS NOT NULL
+	`, itemID, ownerUserID, sqliteNow())
+	if err != nil {
+		return domain.VaultItem{}, fmt.Errorf("restore vault item: %w", err)
+	}
+	if err := requireAffected(result); err != nil {
+		return domain.VaultItem{}, err
+	}
+
+	item, err := scanVaultItem(tx.QueryRowContext(ctx, sqliteVaultItemSelect+`WHERE vi.id = $1`, itemID))
+	if err != nil {
+		return domain.VaultItem{}, fmt.Errorf("read restored vault item: %w", err)
+	}
+
+	if err := commit(); err != nil {
+		return domain.VaultItem{}, fmt.Errorf("commit restore vault item tx: %w", err)
+	}
+	return item, nil
+}
+
+// GetVaultRevision fingerprints the owner's vault like
+// VaultRepository.GetVaultRevision does.
+func (r *SQLiteVaultRepository) GetVaultRevision(ctx context.Context, ownerUserID string) (string, error) {
+	var (
+		itemCount   int64
+		versionSum  int64
+		lastUpdated sqliteTimeValue
+		shareCount  int64
+		lastShareAt sqliteTimeValue
+		folderCount int64
+		lastFolder  sqliteTimeValue
+	)
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(vi.version), 0),
+			MAX(vi.updated_at),
+			(SELECT COUNT(*) FROM vault_shares vs JOIN vault_items v ON v.id = vs.item_id WHERE v.owner_user_id = $1),
+			(SELECT MAX(vs.updated_at) FROM vault_shares vs JOIN vault_items v ON v.id = vs.item_id WHERE v.owner_user_id = $1),
+			(SELECT COUNT(*) FROM vault_folders vf WHERE vf.owner_user_id = $1),
+			(SELECT MAX(vf.updated_at) FROM vault_folders vf WHERE vf.owner_user_id = $1)
+		FROM vault_items vi
+		WHERE vi.owner_user_id = $1
+	`, ownerUserID).Scan(&itemCount, &versionSum, &lastUpdated, &shareCount, &lastShareAt, &folderCount, &lastFolder)
+	if err != nil {
+		return "", fmt.Errorf("query vault revision: %w", err)
+	}
+
+	return fmt.Sprintf("%d.%d.%d.%d.%d.%d.%d", itemCount, versionSum, lastUpdated.Time.UnixMicro(), shareCount, lastShareAt.Time.UnixMicro(), folderCount, lastFolder.Time.UnixMicro()), nil
+}
+
+func (r *SQLiteVaultRepository) PurgeDeletedVaultItems(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	tx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return 0, fmt.Errorf("begin purge deleted vault items tx: %w", err)
+	}
+	defer rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO vault_item_tombstones (item_id, owner_user_id, deleted_at)
+		SELECT id, owner_user_id, $2 FROM vault_items WHERE deleted_at IS NOT NULL AND deleted_at < $1
+		ON CONFLICT (item_id) DO NOTHING
+	`, sqliteTime(deletedBefore), sqliteNow()); err != nil {
+		return 0, fmt.Errorf("record vault item tombstones: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM vault_items WHERE deleted_at IS NOT NULL AND deleted_at < $1
+	`, sqliteTime(deletedBefore))
+	if err != nil {
+		return 0, fmt.Errorf("purge deleted vault items: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	if err := commit(); err != nil {
+		return 0, fmt.Errorf("commit purge deleted vault items tx: %w", err)
+	}
+	return affected, nil
+}
+
+func (r *SQLiteVaultRepository) ListVaultItemChangesByOwner(ctx context.Context, ownerUserID string, since time.Time) ([]domain.VaultItem, []string, error) {
+	db := dbFor(ctx, r.db)
+	rows, err := db.QueryContext(ctx, sqliteVaultItemSelect+`
+		WHERE vi.owner_user_id = $1 AND vi.updated_at > $2
+		ORDER BY vi.updated_at, vi.id
+	`, ownerUserID, sqliteTime(since))
+	if err != nil {
+		return nil, nil, fmt.Errorf("query changed vault items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []domain.VaultItem
+	var deleted []string
+	for rows.Next() {
+		item, err := scanVaultItem(rows)
+		if err != nil {
+			return nil, nil, fmt.Errorf("scan vault item: %w", err)
+		}
+		if item.DeletedAt != nil {
+			deleted = append(deleted, item.ID)
+			continue
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("iterate changed vault items: %w", err)
+	}
+
+	purged, err := db.QueryContext(ctx, `
+		SELECT item_id FROM vault_item_tombstones
+		WHERE owner_user_id = $1 AND deleted_at > $2
+		ORDER BY deleted_at, item_id
+	`, ownerUserID, sqliteTime(since))
+	if err != nil {
+		return nil, nil, fmt.Errorf("query vault item tombstones: %w", err)
+	}
+	ids, err := scanStrings(purged)
+	if err != nil {
+		return nil, nil, fmt.Errorf("scan vault item tombstones: %w", err)
+	}
+	deleted = append(deleted, ids...)
+	return items, deleted, nil
+}
+
+func (r *SQLiteVaultRepository) DeleteVaultItemTombstones(ctx context.Context, before time.Time) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM vault_item_tombstones WHERE deleted_at < $1`, sqliteTime(before))
+	if err != nil {
+		return 0, fmt.Errorf("delete vault item tombstones: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *SQLiteVaultRepository) RewrapVaultItemKeys(ctx context.Context, ownerUserID string, keys []domain.VaultItemKey) error {
+	if !uniqueItemKeys(keys) {
+		return domain.ErrRewrapIncomplete
+	}
+	tx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return fmt.Errorf("begin rewrap vault item keys tx: %w", err)
+	}
+	defer rollback()
+
+	var count int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM vault_items WHERE owner_user_id = $1
+	`, ownerUserID).Scan(&count); err != nil {
+		return fmt.Errorf("count vault items: %w", err)
+	}
+	if count != len(keys) {
+		return domain.ErrRewrapIncomplete
+	}
+	now := sqliteNow()
+	for _, key := range keys {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE vault_items
+			SET dek_wrapped = $3, wrap_nonce = $4, updated_at = $5
+			WHERE id = $1 AND owner_user_id = $2
+		`, key.ItemID, ownerUserID, key.WrappedDEK, key.WrapNonce, now)
+		if err != nil {
+			return fmt.Errorf("rewrap vault item key: %w", err)
+		}
+		if err := requireAffected(result); err != nil {
+			return domain.ErrRewrapIncomplete
+		}
+	}
+
+	if err := commit(); err != nil {
+		return fmt.Errorf("commit rewrap vault item keys tx: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteVaultRepository) GetVaultSaltForUser(ctx context.Context, userID string) ([]byte, error) {
+	var salt []byte
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT salt FROM auth_credentials WHERE user_id = $1
+	`, userID).Scan(&salt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, fmt.Errorf("get vault salt: %w", err)
+	}
+	return salt, nil
+}
+
+func (r *SQLiteVaultRepository) insertVaultItemVersion(ctx context.Context, tx *sql.Tx, item domain.VaultItem) error {
+	versionID, err := util.NewUUID()
+	if err != nil {
+		return err
+	}
+	createdAt := item.UpdatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO vault_item_versions (
+			id, item_id, owner_user_id, folder_id, ciphertext, nonce, dek_wrapped, wrap_nonce, algo_version, metadata, version, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, versionID, item.ID, item.OwnerUserID, item.FolderID, item.Ciphertext, item.Nonce, item.WrappedDEK, item.WrapNonce, item.AlgoVersion, sqliteJSON(item.Metadata), item.Version, sqliteTime(createdAt)); err != nil {
+		return fmt.Errorf("insert vault item version: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,370 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+)
+
+type SQLiteWebhookRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteWebhookRepository(db *sql.DB) *SQLiteWebhookRepository {
+	return &SQLiteWebhookRepository{db: db}
+}
+
+func (r *SQLiteWebhookRepository) CreateEndpoint(ctx context.Context, endpoint domain.WebhookEndpoint) (domain.WebhookEndpoint, error) {
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		INSERT INTO webhook_endpoints (id, user_id, url, secret_enc, event_types, enabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		RETURNING created_at, updated_at
+	`, endpoint.ID, endpoint.UserID, endpoint.URL, endpoint.SecretEnc, sqliteStrings(eventTypeStrings(endpoint.EventTypes)), endpoint.Enabled, sqliteNow()).
+		Scan(&endpoint.CreatedAt, &endpoint.UpdatedAt)
+	if err != nil {
+		return domain.WebhookEndpoint{}, fmt.Errorf("insert webhook endpoint: %w", err)
+	}
+	return endpoint, nil
+}
+
+func (r *SQLiteWebhookRepository) ListEndpoints(ctx context.Context, userID string) ([]domain.WebhookEndpoint, error) {
+	return r.queryEndpoints(ctx, `
+		SELECT `+webhookEndpointColumns+`
+		FROM webhook_endpoints
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, userID)
+}
+
+func (r *SQLiteWebhookRepository) ListEndpointsForEvent(ctx context.Context, userID string, eventType domain.EventType) ([]domain.WebhookEndpoint, error) {
+	return r.queryEndpoints(ctx, `
+		SELECT `+webhookEndpointColumns+`
+		FROM webhook_endpoints
+		WHERE user_id = $1 AND enabled
+		  AND (json_array_length(event_types) = 0 OR EXISTS (SELECT 1 FROM json_each(event_types) WHERE value = $2))
+	`, userID, string(eventType))
+}
+
+func (r *SQLiteWebhookRepository) GetEndpoint(ctx context.Context, userID, endpointID string) (domain.WebhookEndpoint, error) {
+	endpoints, err := r.queryEndpoints(ctx, `
+		SELECT `+webhookEndpointColumns+`
+		FROM webhook_endpoints
+		WHERE id = $1 AND user_id = $2
+	`, endpointID, userID)
+	if err != nil {
+		return domain.WebhookEndpoint{}, err
+	}
+	if len(endpoints) == 0 {
+		return domain.WebhookEndpoint{}, domain.ErrNotFound
+	}
+	return endpoints[0], nil
+}
+
+func (r *SQLiteWebhookRepository) CountEndpoints(ctx context.Context, userID string) (int, error) {
+	var count int
+	if err := dbFor(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM webhook_endpoints WHERE user_id = $1`, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count webhook endpoints: %w", err)
+	}
+	return count, nil
+}
+
+func (r *SQLiteWebhookRepository) DeleteEndpoint(ctx context.Context, userID, endpointID string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM webhook_endpoints WHERE id = $1 AND user_id = $2`, endpointID, userID)
+	if err != nil {
+		return fmt.Errorf("delete webhook endpoint: %w", err)
+	}
+	return requireAffected(result)
+}
+
+func (r *SQLiteWebhookRepository) queryEndpoints(ctx context.Context, query string, args ...any) ([]domain.WebhookEndpoint, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query webhook endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	endpoints := make([]domain.WebhookEndpoint, 0)
+	for rows.Next() {
+		var e domain.WebhookEndpoint
+		var eventTypes string
+		if err := rows.Scan(&e.ID, &e.UserID, &e.URL, &e.SecretEnc, &eventTypes, &e.Enabled, &e.CreatedAt, &e.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan webhook endpoint: %w", err)
+		}
+		if err := json.Unmarshal([]byte(eventTypes), &e.EventTypes); err != nil {
+			return nil, fmt.Errorf("decode webhook event types: %w", err)
+		}
+		if len(e.EventTypes) == 0 {
+			e.EventTypes = nil
+		}
+		endpoints = append(endpoints, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate webhook endpoints: %w", err)
+	}
+	return endpoints, nil
+}
+
+func (r *SQLiteWebhookRepository) EnqueueDelivery(ctx context.Context, delivery domain.WebhookDelivery) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (id, endpoint_id, event_id, event_type, payload, status, created_at, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, 'pending', $6, $6)
+		ON CONFLICT (endpoint_id, event_id) DO NOTHING
+	`, uuid.NewString(), delivery.EndpointID, delivery.EventID, string(delivery.EventType), string(delivery.Payload), sqliteNow())
+	if err != nil {
+		return fmt.Errorf("insert webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// ClaimDueDeliveries leases deliveries, then reads them back with their
+// endpoints in the same transaction; SQLite cannot join against the rows an
+// UPDATE returns.
+func (r *SQLiteWebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	tx, commit, rollback, err := beginTx(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("begin claim webhook deliveries tx: %w", err)
+	}
+	defer rollback()
+
+	now := time.Now()
+	idRows, err := tx.QueryContext(ctx, `
+		UPDATE webhook_deliveries
+		SET next_attempt_at = $3, attempts = attempts + 1
+		WHERE id IN (
+			SELECT d.id FROM webhook_deliveries d
+			JOIN webhook_endpoints e ON e.id = d.endpoint_id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= $2 AND e.enabled
+			ORDER BY d.created_at
+			LIMIT $1
+		)
+		RETURNING id
+	`, limit, sqliteTime(now), sqliteTime(now.Add(lease)))
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+	ids := make([]string, 0)
+	for idRows.Next() {
+		var id string
+		if err := idRows.Scan(&id); err != nil {
+			idRows.Close()
+			return nil, fmt.Errorf("scan claimed webhook delivery: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	idRows.Close()
+	if err := idRows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate claimed webhook deliveries: %w", err)
+	}
+
+	deliveries := make([]domain.WebhookDelivery, 0, len(ids))
+	if len(ids) > 0 {
+		rows, err := tx.QueryContext(ctx, `
+			SELECT d.id, d.endpoint_id, d.event_id, d.event_type, d.payload, d.attempts, d.created_at,
+			       e.user_id, e.url, e.secret_enc
+			FROM webhook_deliveries d
+			JOIN webhook_endpoints e ON e.id = d.endpoint_id
+			WHERE d.id IN (SELECT value FROM json_each($1))
+			ORDER BY d.created_at
+		`, sqliteStrings(ids))
+		if err != nil {
+			return nil, fmt.Errorf("load claimed webhook deliveries: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var d domain.WebhookDelivery
+			var eventType string
+			var payload []byte
+			if err := rows.Scan(&d.ID, &d.EndpointID, &d.EventID, &eventType, &payload, &d.Attempts, &d.CreatedAt,
+				&d.Endpoint.UserID, &d.Endpoint.URL, &d.Endpoint.SecretEnc); err != nil {
+				return nil, fmt.Errorf("scan webhook delivery: %w", err)
+			}
+			d.EventType = domain.EventType(eventType)
+			d.Payload = payload
+			d.Status = domain.WebhookDeliveryPending
+			d.Endpoint.ID = d.EndpointID
+			deliveries = append(deliveries, d)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("iterate webhook deliveries: %w", err)
+		}
+	}
+
+	if err := commit(); err != nil {
+		return nil, fmt.Errorf("commit claim webhook deliveries tx: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (r *SQLiteWebhookRepository) RecordAttempt(ctx context.Context, deliveryID string, attempt domain.WebhookDeliveryAttempt) error {
+	var statusCode sql.NullInt32
+	if attempt.StatusCode > 0 {
+		statusCode = sql.NullInt32{Int32: int32(attempt.StatusCode), Valid: true}
+	}
+	var attemptErr sql.NullString
+	if attempt.Error != "" {
+		attemptErr = sql.NullString{String: attempt.Error, Valid: true}
+	}
+
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO webhook_delivery_attempts (id, delivery_id, status_code, error, duration_ms, attempted_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, uuid.NewString(), deliveryID, statusCode, attemptErr, attempt.DurationMS, sqliteTime(attempt.AttemptedAt))
+	if err != nil {
+		return fmt.Errorf("insert webhook delivery attempt: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteWebhookRepository) MarkDelivered(ctx context.Context, deliveryID string) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE webhook_deliveries SET status = 'succeeded', delivered_at = $2, last_error = NULL WHERE id = $1
+	`, deliveryID, sqliteNow())
+	if err != nil {
+		return fmt.Errorf("mark webhook delivery delivered: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteWebhookRepository) MarkRetry(ctx context.Context, deliveryID string, lastError string, nextAttemptAt time.Time) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE webhook_deliveries SET last_error = $2, next_attempt_at = $3 WHERE id = $1
+	`, deliveryID, lastError, sqliteTime(nextAttemptAt))
+	if err != nil {
+		return fmt.Errorf("schedule webhook delivery retry: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteWebhookRepository) MarkDead(ctx context.Context, deliveryID string, lastError string) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE webhook_deliveries SET status = 'dead', last_error = $2 WHERE id = $1
+	`, deliveryID, lastError)
+	if err != nil {
+		return fmt.Errorf("mark webhook delivery dead: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteWebhookRepository) ListDeliveries(ctx context.Context, endpointID string, limit, offset int) ([]domain.WebhookDelivery, int, error) {
+	var total int
+	if err := dbFor(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM webhook_deliveries WHERE endpoint_id = $1`, endpointID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count webhook deliveries: %w", err)
+	}
+
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT id, endpoint_id, event_id, event_type, payload, status, attempts, COALESCE(last_error, ''), next_attempt_at, delivered_at, created_at
+		FROM webhook_deliveries
+		WHERE endpoint_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, endpointID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]domain.WebhookDelivery, 0)
+	index := make(map[string]int)
+	ids := make([]string, 0)
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		var eventType string
+		var payload []byte
+		var deliveredAt sql.NullTime
+		if err := rows.Scan(&d.ID, &d.EndpointID, &d.EventID, &eventType, &payload, &d.Status, &d.Attempts, &d.LastError, &d.NextAttemptAt, &deliveredAt, &d.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		d.EventType = domain.EventType(eventType)
+		d.Payload = payload
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.Time
+		}
+		index[d.ID] = len(deliveries)
+		ids = append(ids, d.ID)
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("iterate webhook deliveries: %w", err)
+	}
+	if len(ids) == 0 {
+		return deliveries, total, nil
+	}
+
+	attemptRows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT delivery_id, COALESCE(status_code, 0), COALESCE(error, ''), duration_ms, attempted_at
+		FROM webhook_delivery_attempts
+		WHERE delivery_id IN (SELECT value FROM json_each($1))
+		ORDER BY attempted_at
+	`, sqliteStrings(ids))
+	if err != nil {
+		return nil, 0, fmt.Errorf("list webhook delivery attempts: %w", err)
+	}
+	defer attemptRows.Close()
+
+	for attemptRows.Next() {
+		var deliveryID string
+		var a domain.WebhookDeliveryAttempt
+		if err := attemptRows.Scan(&deliveryID, &a.StatusCode, &a.Error, &a.DurationMS, &a.AttemptedAt); err != nil {
+			return nil, 0, fmt.Errorf("scan webhook delivery attempt: %w", err)
+		}
+		if i, ok := index[deliveryID]; ok {
+			deliveries[i].AttemptLog = append(deliveries[i].AttemptLog, a)
+		}
+	}
+	if err := attemptRows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("iterate webhook delivery attempts: %w", err)
+	}
+	return deliveries, total, nil
+}
+
+func (r *SQLiteWebhookRepository) Redeliver(ctx context.Context, endpointID, deliveryID string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = $3, delivered_at = NULL
+		WHERE id = $1 AND endpoint_id = $2 AND status <> 'pending'
+	`, deliveryID, endpointID, sqliteNow())
+	if err != nil {
+		return fmt.Errorf("redeliver webhook delivery: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("read rows affected: %w", err)
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	var status string
+	err = dbFor(ctx, r.db).QueryRowContext(ctx, `SELECT status FROM webhook_deliveries WHERE id = $1 AND endpoint_id = $2`, deliveryID, endpointID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("load webhook delivery: %w", err)
+	}
+	return domain.ErrWebhookDeliveryActive
+}
+
+func (r *SQLiteWebhookRepository) DeleteDeliveriesBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM webhook_deliveries WHERE status <> 'pending' AND created_at < $1
+	`, sqliteTime(before))
+	if err != nil {
+		return 0, fmt.Errorf("delete webhook deliveries: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected, nil
+}
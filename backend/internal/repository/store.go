@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"pmv2/backend/internal/database"
+	"pmv2/backend/internal/domain"
+)
+
+// StoreFactory builds the repositories of one storage driver over db.
+type StoreFactory func(db *sql.DB) domain.Store
+
+var (
+	storeFactoriesMu sync.RWMutex
+	storeFactories   = map[database.Driver]StoreFactory{
+		database.DriverPostgres: NewPostgresStore,
+		database.DriverSQLite:   NewSQLiteStore,
+		database.DriverMySQL:    NewMySQLStore,
+	}
+)
+
+// RegisterStore makes a storage driver's repositories available to
+// NewStore, replacing any factory already registered under that name.
+func RegisterStore(driver database.Driver, factory StoreFactory) {
+	storeFactoriesMu.Lock()
+	defer storeFactoriesMu.Unlock()
+	storeFactories[driver] = factory
+}
+
+// NewStore returns the repositories matching the driver db was opened with.
+// When db has a read replica, the Postgres repositories send vault listings
+// and audit queries to it, falling back to the primary while it fails.
+// Session lookups stay on the primary so revocations apply at once.
+func NewStore(db *database.DB) (domain.Store, error) {
+	if replica := db.Replica(); replica != nil {
+		if db.Driver() != database.DriverPostgres {
+			return nil, fmt.Errorf("read replicas are not supported for driver %q", db.Driver())
+		}
+		return newPostgresStore(db.SQL(), newReadRouter(db.SQL(), replica)), nil
+	}
+
+	storeFactoriesMu.RLock()
+	factory, ok := storeFactories[db.Driver()]
+	storeFactoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no store registered for driver %q", db.Driver())
+	}
+	return factory(db.SQL()), nil
+}
+
+// repositoryStore is a domain.Store whose repositories share one backing
+// storage.
+type repositoryStore struct {
+	auth           domain.AuthRepository
+	vault          domain.VaultRepository
+	folders        domain.FolderRepository
+	userKeys       domain.UserKeysRepository
+	sharing        domain.SharingRepository
+	family         domain.FamilyRepository
+	audit          domain.AuditRepository
+	jobs           domain.JobRepository
+	outbox         domain.OutboxRepository
+	webhooks       domain.WebhookRepository
+	notifications  domain.NotificationRepository
+	admin          domain.AdminRepository
+	dataExports    domain.DataExportRepository
+	ipBans         domain.IPBanRepository
+	devices        domain.DeviceRepository
+	passkeys       domain.PasskeyRepository
+	qrLogins       domain.QRLoginRepository
+	autofill       domain.AutofillRepository
+	pushTokens     domain.PushTokenRepository
+	machineSecrets domain.MachineSecretRepository
+	attachments    domain.AttachmentRepository
+	passwordResets domain.PasswordResetRepository
+	backups        domain.BackupRegistryRepository
+	transactor     domain.Transactor
+}
+
+func NewPostgresStore(db *sql.DB) domain.Store {
+	return newPostgresStore(db, nil)
+}
+
+// newPostgresStore sends the reads listed on NewStore to reads, or to db
+// when reads is nil.
+func newPostgresStore(db *sql.DB, reads reader) domain.Store {
+	return &repositoryStore{
+		auth:           NewAuthRepository(db),
+		vault:          &VaultRepository{db: db, reads: reads},
+		folders:        NewPostgresFolderRepository(db),
+		userKeys:       NewUserKeysRepository(db),
+		sharing:        NewSharingRepository(db),
+		family:         NewFamilyRepository(db),
+		audit:          &AuditRepository{db: db, reads: reads},
+		jobs:           NewJobRepository(db),
+		outbox:         NewOutboxRepository(db),
+		webhooks:       NewWebhookRepository(db),
+		notifications:  NewNotificationRepository(db),
+		admin:          NewAdminRepository(db),
+		dataExports:    NewDataExportRepository(db),
+		ipBans:         NewIPBanRepository(db),
+		devices:        NewDeviceRepository(db),
+		passkeys:       NewPasskeyRepository(db),
+		qrLogins:       NewQRLoginRepository(db),
+		autofill:       NewAutofillRepository(db),
+		pushTokens:     NewPushTokenRepository(db),
+		machineSecrets: NewMachineSecretRepository(db),
+		attachments:    NewAttachmentRepository(db),
+		passwordResets: NewPasswordResetRepository(db),
+		backups:        NewBackupRegistryRepository(db),
+		transactor:     NewTransactor(db),
+	}
+}
+
+func NewSQLiteStore(db *sql.DB) domain.Store {
+	return &repositoryStore{
+		auth:           NewSQLiteAuthRepository(db),
+		vault:          NewSQLiteVaultRepository(db),
+		folders:        NewSQLiteFolderRepository(db),
+		userKeys:       NewSQLiteUserKeysRepository(db),
+		sharing:        NewSQLiteSharingRepository(db),
+		family:         NewSQLiteFamilyRepository(db),
+		audit:          NewSQLiteAuditRepository(db),
+		jobs:           NewSQLiteJobRepository(db),
+		outbox:         NewSQLiteOutboxRepository(db),
+		webhooks:       NewSQLiteWebhookRepository(db),
+		notifications:  NewSQLiteNotificationRepository(db),
+		admin:          NewSQLiteAdminRepository(db),
+		dataExports:    NewSQLiteDataExportRepository(db),
+		ipBans:         NewSQLiteIPBanRepository(db),
+		devices:        NewSQLiteDeviceRepository(db),
+		passkeys:       NewSQLitePasskeyRepository(db),
+		qrLogins:       NewSQLiteQRLoginRepository(db),
+		autofill:       NewSQLiteAutofillRepository(db),
+		pushTokens:     NewSQLitePushTokenRepository(db),
+		machineSecrets: NewSQLiteMachineSecretRepository(db),
+		attachments:    NewSQLiteAttachmentRepository(db),
+		passwordResets: NewSQLitePasswordResetRepository(db),
+		backups:        NewSQLiteBackupRegistryRepository(db),
+		transactor:     NewTransactor(db),
+	}
+}
+
+func NewMySQLStore(db *sql.DB) domain.Store {
+	return &repositoryStore{
+		auth:           NewMySQLAuthRepository(db),
+		vault:          NewMySQLVaultRepository(db),
+		folders:        NewMySQLFolderRepository(db),
+		userKeys:       NewMySQLUserKeysRepository(db),
+		sharing:        NewMySQLSharingRepository(db),
+		family:         NewMySQLFamilyRepository(db),
+		audit:          NewMySQLAuditRepository(db),
+		jobs:           NewMySQLJobRepository(db),
+		outbox:         NewMySQLOutboxRepository(db),
+		webhooks:       NewMySQLWebhookRepository(db),
+		notifications:  NewMySQLNotificationRepository(db),
+		admin:          NewMySQLAdminRepository(db),
+		dataExports:    NewMySQLDataExportRepository(db),
+		ipBans:         NewMySQLIPBanRepository(db),
+		devices:        NewMySQLDeviceRepository(db),
+		passkeys:       NewMySQLPasskeyRepository(db),
+		qrLogins:       NewMySQLQRLoginRepository(db),
+		autofill:       NewMySQLAutofillRepository(db),
+		pushTokens:     NewMySQLPushTokenRepository(db),
+		machineSecrets: NewMySQLMachineSecretRepository(db),
+		attachments:    NewMySQLAttachmentRepository(db),
+		passwordResets: NewMySQLPasswordResetRepository(db),
+		backups:        NewMySQLBackupRegistryRepository(db),
+		transactor:     NewTransactor(db),
+	}
+}
+
+// NewMemoryStore returns repositories that keep their data in db; see
+// MemoryDB.
+func NewMemoryStore(db *MemoryDB) domain.Store {
+	return &repositoryStore{
+		auth:           NewMemoryAuthRepository(db),
+		vault:          NewMemoryVaultRepository(db),
+		folders:        NewMemoryFolderRepository(db),
+		userKeys:       NewMemoryUserKeysRepository(db),
+		sharing:        NewMemorySharingRepository(db),
+		family:         NewMemoryFamilyRepository(db),
+		audit:          NewMemoryAuditRepository(db),
+		jobs:           NewMemoryJobRepository(db),
+		outbox:         NewMemoryOutboxRepository(db),
+		webhooks:       NewMemoryWebhookRepository(db),
+		notifications:  NewMemoryNotificationRepository(db),
+		admin:          NewMemoryAdminRepository(db),
+		dataExports:    NewMemoryDataExportRepository(db),
+		ipBans:         NewMemoryIPBanRepository(db),
+		devices:        NewMemoryDeviceRepository(db),
+		passkeys:       NewMemoryPasskeyRepository(db),
+		qrLogins:       NewMemoryQRLoginRepository(db),
+		autofill:       NewMemoryAutofillRepository(db),
+		pushTokens:     NewMemoryPushTokenRepository(db),
+		machineSecrets: NewMemoryMachineSecretRepository(db),
+		attachments:    NewMemoryAttachmentRepository(db),
+		passwordResets: NewMemoryPasswordResetRepository(db),
+		backups:        NewMemoryBackupRegistryRepository(db),
+		transactor:     NewMemoryTransactor(db),
+	}
+}
+
+func (s *repositoryStore) Auth() domain.AuthRepository                    { return s.auth }
+func (s *repositoryStore) Vault() domain.VaultRepository                  { return s.vault }
+func (s *repositoryStore) Folders() domain.FolderRepository               { return s.folders }
+func (s *repositoryStore) UserKeys() domain.UserKeysRepository            { return s.userKeys }
+func (s *repositoryStore) Sharing() domain.SharingRepository              { return s.sharing }
+func (s *repositoryStore) Family() domain.FamilyRepository                { return s.family }
+func (s *repositoryStore) Audit() domain.AuditRepository                  { return s.audit }
+func (s *repositoryStore) Jobs() domain.JobRepository                     { return s.jobs }
+func (s *repositoryStore) Outbox() domain.OutboxRepository                { return s.outbox }
+func (s *repositoryStore) Webhooks() domain.WebhookRepository             { return s.webhooks }
+func (s *repositoryStore) Notifications() domain.NotificationRepository   { return s.notifications }
+func (s *repositoryStore) Admin() domain.AdminRepository                  { return s.admin }
+func (s *repositoryStore) DataExports() domain.DataExportRepository       { return s.dataExports }
+func (s *repositoryStore) IPBans() domain.IPBanRepository                 { return s.ipBans }
+func (s *repositoryStore) Devices() domain.DeviceRepository               { return s.devices }
+func (s *repositoryStore) Passkeys() domain.PasskeyRepository             { return s.passkeys }
+func (s *repositoryStore) QRLogins() domain.QRLoginRepository             { return s.qrLogins }
+func (s *repositoryStore) Autofill() domain.AutofillRepository            { return s.autofill }
+func (s *repositoryStore) PushTokens() domain.PushTokenRepository         { return s.pushTokens }
+func (s *repositoryStore) MachineSecrets() domain.MachineSecretRepository { return s.machineSecrets }
+func (s *repositoryStore) Attachments() domain.AttachmentRepository       { return s.attachments }
+func (s *repositoryStore) PasswordResets() domain.PasswordResetRepository { return s.passwordResets }
+func (s *repositoryStore) Backups() domain.BackupRegistryRepository       { return s.backups }
+func (s *repositoryStore) Transactor() domain.Transactor                  { return s.transactor }
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// dbtx is the subset of *sql.DB and *sql.Tx the repositories use.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+type txContextKey struct{}
+
+type Transactor struct {
+	db *sql.DB
+}
+
+func NewTransactor(db *sql.DB) *Transactor {
+	return &Transactor{db: db}
+}
+
+// WithinTx runs fn in a transaction carried by the context. Nested calls
+// join the outer transaction instead of opening a new one.
+func (t *Transactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := txFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := t.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := fn(context.WithValue(ctx, txContextKey{}, tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+func txFromContext(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(*sql.Tx)
+	return tx, ok
+}
+
+// dbFor returns the transaction carried by ctx, or db when there is none.
+func dbFor(ctx context.Context, db *sql.DB) dbtx {
+	if tx, ok := txFromContext(ctx); ok {
+		return tx
+	}
+	return db
+}
+
+// beginTx joins the transaction carried by ctx or begins a new one. commit
+// is a no-op for a joined transaction; the outer WithinTx owns it.
+func beginTx(ctx context.Context, db *sql.DB) (tx *sql.Tx, commit func() error, rollback func(), err error) {
+	if tx, ok := txFromContext(ctx); ok {
+		return tx, func() error { return nil }, func() {}, nil
+	}
+
+	tx, err = db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return tx, tx.Commit, func() { _ = tx.Rollback() }, nil
+}
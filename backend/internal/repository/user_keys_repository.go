@@ -18,15 +18,16 @@ func NewUserKeysRepository(db *sql.DB) *UserKeysRepository {
 }
 
 func (r *UserKeysRepository) UpsertKeys(ctx context.Context, input domain.UpsertUserKeysInput) error {
-	_, err := r.db.ExecContext(ctx, `
-		INSERT INTO user_keys (user_id, public_key_x25519, encrypted_private_keys, nonce, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, NOW(), NOW())
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO user_keys (user_id, public_key_x25519, public_key_ed25519, encrypted_private_keys, nonce, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
 		ON CONFLICT (user_id) DO UPDATE SET
 			public_key_x25519 = EXCLUDED.public_key_x25519,
+			public_key_ed25519 = EXCLUDED.public_key_ed25519,
 			encrypted_private_keys = EXCLUDED.encrypted_private_keys,
 			nonce = EXCLUDED.nonce,
 			updated_at = NOW()
-	`, input.UserID, input.PublicKeyX25519, input.EncryptedPrivateKeys, input.Nonce)
+	`, input.UserID, input.PublicKeyX25519, nullableBytes(input.PublicKeyEd25519), input.EncryptedPrivateKeys, input.Nonce)
 	if err != nil {
 		return fmt.Errorf("upsert user keys: %w", err)
 	}
@@ -36,7 +37,7 @@ func (r *UserKeysRepository) UpsertKeys(ctx context.Context, input domain.Upsert
 func (r *UserKeysRepository) GetKeysByUserID(ctx context.Context, userID string) (domain.UserKeys, error) {
 	var keys domain.UserKeys
 	var edKey []byte
-	err := r.db.QueryRowContext(ctx, `
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
 		SELECT user_id, public_key_x25519, public_key_ed25519, encrypted_private_keys, nonce, created_at, updated_at
 		FROM user_keys
 		WHERE user_id = $1
@@ -62,14 +63,15 @@ func (r *UserKeysRepository) GetKeysByUserID(ctx context.Context, userID string)
 func (r *UserKeysRepository) GetPublicKeyByEmail(ctx context.Context, email string) (domain.UserKeys, string, error) {
 	var keys domain.UserKeys
 	var userID string
-	err := r.db.QueryRowContext(ctx, `
-		SELECT uk.user_id, uk.public_key_x25519, uk.created_at, uk.updated_at
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		SELECT uk.user_id, uk.public_key_x25519, uk.public_key_ed25519, uk.created_at, uk.updated_at
 		FROM user_keys uk
 		JOIN users u ON u.id = uk.user_id
 		WHERE u.email = $1
 	`, email).Scan(
 		&userID,
 		&keys.PublicKeyX25519,
+		&keys.PublicKeyEd25519,
 		&keys.CreatedAt,
 		&keys.UpdatedAt,
 	)
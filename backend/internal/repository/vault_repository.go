@@ -12,7 +12,8 @@ import (
 )
 
 type VaultRepository struct {
-	db *sql.DB
+	db    *sql.DB
+	reads reader // see readFor; nil reads from db
 }
 
 func NewVaultRepository(db *sql.DB) *VaultRepository {
@@ -20,27 +21,29 @@ func NewVaultRepository(db *sql.DB) *VaultRepository {
 }
 
 func (r *VaultRepository) CreateVaultItem(ctx context.Context, input domain.CreateVaultItemInput) (domain.VaultItem, error) {
-	itemID, err := util.NewUUID()
-	if err != nil {
-		return domain.VaultItem{}, err
-	}
+	return asOwner(ctx, r.db, input.OwnerUserID, func(ctx context.Context) (domain.VaultItem, error) {
+		itemID, err := util.NewUUID()
+		if err != nil {
+			return domain.VaultItem{}, err
+		}
 
-	row := r.db.QueryRowContext(ctx, `
-		INSERT INTO vault_items (
-			id, owner_user_id, folder_id, ciphertext, nonce, dek_wrapped, wrap_nonce, algo_version, metadata, version, created_at, updated_at
-		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 1, NOW(), NOW())
-		RETURNING
-			id, owner_user_id, folder_id, ciphertext, nonce, dek_wrapped, wrap_nonce, algo_version, metadata,
-			(SELECT COUNT(*) > 0 FROM vault_shares vs WHERE vs.item_id = vault_items.id) as is_shared,
-			version, created_at, updated_at, deleted_at
-	`, itemID, input.OwnerUserID, input.FolderID, input.Ciphertext, input.Nonce, input.WrappedDEK, input.WrapNonce, input.AlgoVersion, nullableJSON(input.Metadata))
-
-	item, err := scanVaultItem(row)
-	if err != nil {
-		return domain.VaultItem{}, fmt.Errorf("insert vault item: %w", err)
-	}
-	return item, nil
+		row := dbFor(ctx, r.db).QueryRowContext(ctx, `
+			INSERT INTO vault_items (
+				id, owner_user_id, folder_id, ciphertext, nonce, dek_wrapped, wrap_nonce, algo_version, metadata, version, created_at, updated_at
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 1, NOW(), NOW())
+			RETURNING
+				id, owner_user_id, folder_id, ciphertext, nonce, dek_wrapped, wrap_nonce, algo_version, metadata,
+				(SELECT COUNT(*) > 0 FROM vault_shares vs WHERE vs.item_id = vault_items.id) as is_shared,
+				version, created_at, updated_at, deleted_at
+		`, itemID, input.OwnerUserID, input.FolderID, input.Ciphertext, input.Nonce, input.WrappedDEK, input.WrapNonce, input.AlgoVersion, nullableJSON(input.Metadata))
+
+		item, err := scanVaultItem(row)
+		if err != nil {
+			return domain.VaultItem{}, fmt.Errorf("insert vault item: %w", err)
+		}
+		return item, nil
+	})
 }
 
 func (r *VaultRepository) CreateVaultItemsBulk(ctx context.Context, inputs []domain.CreateVaultItemInput) ([]domain.VaultItem, error) {
@@ -48,49 +51,50 @@ func (r *VaultRepository) CreateVaultItemsBulk(ctx context.Context, inputs []dom
 		return nil, nil
 	}
 
-	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("begin bulk insert tx: %w", err)
-	}
-	defer func() {
-		_ = tx.Rollback()
-	}()
-
-	stmt, err := tx.PrepareContext(ctx, `
-		INSERT INTO vault_items (
-			id, owner_user_id, folder_id, ciphertext, nonce, dek_wrapped, wrap_nonce, algo_version, metadata, version, created_at, updated_at
-		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 1, NOW(), NOW())
-		RETURNING
-			id, owner_user_id, folder_id, ciphertext, nonce, dek_wrapped, wrap_nonce, algo_version, metadata,
-			(SELECT COUNT(*) > 0 FROM vault_shares vs WHERE vs.item_id = vault_items.id) as is_shared,
-			version, created_at, updated_at, deleted_at
-	`)
-	if err != nil {
-		return nil, fmt.Errorf("prepare bulk insert stmt: %w", err)
-	}
-	defer stmt.Close()
-
-	items := make([]domain.VaultItem, 0, len(inputs))
-	for _, input := range inputs {
-		itemID, err := util.NewUUID()
+	// The import is one user's; the policy rejects any row owned by another.
+	return asOwner(ctx, r.db, inputs[0].OwnerUserID, func(ctx context.Context) ([]domain.VaultItem, error) {
+		tx, commit, rollback, err := beginTx(ctx, r.db)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("begin bulk insert tx: %w", err)
 		}
-
-		row := stmt.QueryRowContext(ctx, itemID, input.OwnerUserID, input.FolderID, input.Ciphertext, input.Nonce, input.WrappedDEK, input.WrapNonce, input.AlgoVersion, nullableJSON(input.Metadata))
-		item, err := scanVaultItem(row)
+		defer rollback()
+
+		stmt, err := tx.PrepareContext(ctx, `
+			INSERT INTO vault_items (
+				id, owner_user_id, folder_id, ciphertext, nonce, dek_wrapped, wrap_nonce, algo_version, metadata, version, created_at, updated_at
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 1, NOW(), NOW())
+			RETURNING
+				id, owner_user_id, folder_id, ciphertext, nonce, dek_wrapped, wrap_nonce, algo_version, metadata,
+				(SELECT COUNT(*) > 0 FROM vault_shares vs WHERE vs.item_id = vault_items.id) as is_shared,
+				version, created_at, updated_at, deleted_at
+		`)
 		if err != nil {
-			return nil, fmt.Errorf("insert vault item in bulk: %w", err)
+			return nil, fmt.Errorf("prepare bulk insert stmt: %w", err)
+		}
+		defer stmt.Close()
+
+		items := make([]domain.VaultItem, 0, len(inputs))
+		for _, input := range inputs {
+			itemID, err := util.NewUUID()
+			if err != nil {
+				return nil, err
+			}
+
+			row := stmt.QueryRowContext(ctx, itemID, input.OwnerUserID, input.FolderID, input.Ciphertext, input.Nonce, input.WrappedDEK, input.WrapNonce, input.AlgoVersion, nullableJSON(input.Metadata))
+			item, err := scanVaultItem(row)
+			if err != nil {
+				return nil, fmt.Errorf("insert vault item in bulk: %w", err)
+			}
+			items = append(items, item)
 		}
-		items = append(items, item)
-	}
 
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("commit bulk insert tx: %w", err)
-	}
+		if err := commit(); err != nil {
+			return nil, fmt.Errorf("commit bulk insert tx: %w", err)
+		}
 
-	return items, nil
+		return items, nil
+	})
 }
 
 func (r *VaultRepository) ListVaultItemsByOwner(ctx context.Context, ownerUserID string) ([]domain.VaultItem, error) {
@@ -102,192 +106,452 @@ func (r *VaultRepository) ListDeletedVaultItemsByOwner(ctx context.Context, owne
 }
 
 func (r *VaultRepository) listVaultItemsByOwner(ctx context.Context, ownerUserID string, deleted bool) ([]domain.VaultItem, error) {
-	deletedPredicate := "IS NULL"
-	orderBy := "vi.updated_at DESC"
-	if deleted {
-		deletedPredicate = "IS NOT NULL"
-		orderBy = "vi.deleted_at DESC NULLS LAST, vi.updated_at DESC"
-	}
+	return asOwner(ctx, readsFrom(r.db, r.reads), ownerUserID, func(ctx context.Context) ([]domain.VaultItem, error) {
+		deletedPredicate := "IS NULL"
+		orderBy := "vi.updated_at DESC"
+		if deleted {
+			deletedPredicate = "IS NOT NULL"
+			orderBy = "vi.deleted_at DESC NULLS LAST, vi.updated_at DESC"
+		}
 
-	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(`
-		SELECT
-			vi.id, vi.owner_user_id, vi.folder_id, vi.ciphertext, vi.nonce,
-			vi.dek_wrapped, vi.wrap_nonce, vi.algo_version, vi.metadata,
-			(SELECT COUNT(*) > 0 FROM vault_shares vs WHERE vs.item_id = vi.id) as is_shared,
-			vi.version, vi.created_at, vi.updated_at, vi.deleted_at
-		FROM vault_items vi
-		WHERE vi.owner_user_id = $1
-		  AND vi.deleted_at %s
-		ORDER BY %s
-	`, deletedPredicate, orderBy), ownerUserID)
-	if err != nil {
-		return nil, fmt.Errorf("query vault items: %w", err)
-	}
-	defer rows.Close()
+		rows, err := readFor(ctx, r.db, r.reads).QueryContext(ctx, fmt.Sprintf(`
+			SELECT
+				vi.id, vi.owner_user_id, vi.folder_id, vi.ciphertext, vi.nonce,
+				vi.dek_wrapped, vi.wrap_nonce, vi.algo_version, vi.metadata,
+				(SELECT COUNT(*) > 0 FROM vault_shares vs WHERE vs.item_id = vi.id) as is_shared,
+				vi.version, vi.created_at, vi.updated_at, vi.deleted_at
+			FROM vault_items vi
+			WHERE vi.owner_user_id = $1
+			  AND vi.deleted_at %s
+			ORDER BY %s
+		`, deletedPredicate, orderBy), ownerUserID)
+		if err != nil {
+			return nil, fmt.Errorf("query vault items: %w", err)
+		}
+		defer rows.Close()
+
+		items := make([]domain.VaultItem, 0)
+		for rows.Next() {
+			item, err := scanVaultItem(rows)
+			if err != nil {
+				return nil, fmt.Errorf("scan vault item: %w", err)
+			}
+			items = append(items, item)
+		}
+
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("iterate vault items: %w", err)
+		}
+
+		return items, nil
+	})
+}
+
+type vaultItemPage struct {
+	items []domain.VaultItem
+	total int
+}
+
+func (r *VaultRepository) ListVaultItemPage(ctx context.Context, ownerUserID string, query domain.VaultItemQuery) ([]domain.VaultItem, int, error) {
+	page, err := asOwner(ctx, readsFrom(r.db, r.reads), ownerUserID, func(ctx context.Context) (vaultItemPage, error) {
+		db := readFor(ctx, r.db, r.reads)
+		where, orderBy, args := vaultItemPageClauses(query, "vi.metadata->>'kind'", func(id string) any { return id })
+		args = append([]any{ownerUserID}, args...)
 
-	items := make([]domain.VaultItem, 0)
-	for rows.Next() {
-		item, err := scanVaultItem(rows)
+		var page vaultItemPage
+		if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM vault_items vi WHERE `+where, args...).Scan(&page.total); err != nil {
+			return page, fmt.Errorf("count vault items: %w", err)
+		}
+		rows, err := db.QueryContext(ctx, fmt.Sprintf(`
+			SELECT
+				vi.id, vi.owner_user_id, vi.folder_id, vi.ciphertext, vi.nonce,
+				vi.dek_wrapped, vi.wrap_nonce, vi.algo_version, vi.metadata,
+				(SELECT COUNT(*) > 0 FROM vault_shares vs WHERE vs.item_id = vi.id) as is_shared,
+				vi.version, vi.created_at, vi.updated_at, vi.deleted_at
+			FROM vault_items vi
+			WHERE %s
+			ORDER BY %s
+			LIMIT $%d OFFSET $%d
+		`, where, orderBy, len(args)+1, len(args)+2), append(args, query.Limit, query.Offset)...)
 		if err != nil {
-			return nil, fmt.Errorf("scan vault item: %w", err)
+			return page, fmt.Errorf("query vault item page: %w", err)
 		}
-		items = append(items, item)
-	}
+		defer rows.Close()
+
+		page.items = make([]domain.VaultItem, 0)
+		for rows.Next() {
+			item, err := scanVaultItem(rows)
+			if err != nil {
+				return page, fmt.Errorf("scan vault item: %w", err)
+			}
+			page.items = append(page.items, item)
+		}
+		if err := rows.Err(); err != nil {
+			return page, fmt.Errorf("iterate vault item page: %w", err)
+		}
+		return page, nil
+	})
+	return page.items, page.total, err
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterate vault items: %w", err)
+// vaultItemPageClauses builds the WHERE and ORDER BY of a ListVaultItemPage
+// query over vault_items vi, and the arguments of its filters, numbered
+// from $2 after the owner. kindExpr is the dialect's expression for the
+// metadata's kind and folderArg converts a folder ID to a query argument.
+func vaultItemPageClauses(query domain.VaultItemQuery, kindExpr string, folderArg func(string) any) (string, string, []any) {
+	where := "vi.owner_user_id = $1 AND vi.deleted_at IS NULL"
+	var args []any
+	if query.FolderID != "" {
+		args = append(args, folderArg(query.FolderID))
+		where += fmt.Sprintf(" AND vi.folder_id = $%d", len(args)+1)
+	}
+	if query.Kind != "" {
+		args = append(args, query.Kind)
+		where += fmt.Sprintf(" AND %s = $%d", kindExpr, len(args)+1)
 	}
 
-	return items, nil
+	column := "vi.updated_at"
+	if query.Sort == domain.VaultItemSortCreated {
+		column = "vi.created_at"
+	}
+	direction := "DESC"
+	if query.Ascending {
+		direction = "ASC"
+	}
+	return where, fmt.Sprintf("%s %s, vi.id %s", column, direction, direction), args
 }
 
 func (r *VaultRepository) GetVaultItemByIDForOwner(ctx context.Context, itemID string, ownerUserID string) (domain.VaultItem, error) {
-	item, err := scanVaultItem(r.db.QueryRowContext(ctx, `
-		SELECT
-			vi.id, vi.owner_user_id, vi.folder_id, vi.ciphertext, vi.nonce,
-			vi.dek_wrapped, vi.wrap_nonce, vi.algo_version, vi.metadata,
-			(SELECT COUNT(*) > 0 FROM vault_shares vs WHERE vs.item_id = vi.id) as is_shared,
-			vi.version, vi.created_at, vi.updated_at, vi.deleted_at
-		FROM vault_items vi
-		WHERE vi.id = $1 AND vi.owner_user_id = $2
-	`, itemID, ownerUserID))
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return domain.VaultItem{}, domain.ErrNotFound
+	return asOwner(ctx, r.db, ownerUserID, func(ctx context.Context) (domain.VaultItem, error) {
+		item, err := scanVaultItem(dbFor(ctx, r.db).QueryRowContext(ctx, `
+			SELECT
+				vi.id, vi.owner_user_id, vi.folder_id, vi.ciphertext, vi.nonce,
+				vi.dek_wrapped, vi.wrap_nonce, vi.algo_version, vi.metadata,
+				(SELECT COUNT(*) > 0 FROM vault_shares vs WHERE vs.item_id = vi.id) as is_shared,
+				vi.version, vi.created_at, vi.updated_at, vi.deleted_at
+			FROM vault_items vi
+			WHERE vi.id = $1 AND vi.owner_user_id = $2
+		`, itemID, ownerUserID))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return domain.VaultItem{}, domain.ErrNotFound
+			}
+			return domain.VaultItem{}, fmt.Errorf("get vault item: %w", err)
 		}
-		return domain.VaultItem{}, fmt.Errorf("get vault item: %w", err)
-	}
-	return item, nil
+		return item, nil
+	})
 }
 
 func (r *VaultRepository) ListVaultItemVersionsByOwner(ctx context.Context, itemID string, ownerUserID string) ([]domain.VaultItemVersion, error) {
-	rows, err := r.db.QueryContext(ctx, `
-		SELECT
-			viv.id, viv.item_id, viv.owner_user_id, viv.folder_id, viv.ciphertext, viv.nonce,
-			viv.dek_wrapped, viv.wrap_nonce, viv.algo_version, viv.metadata, viv.version, viv.created_at
-		FROM vault_item_versions viv
-		WHERE viv.item_id = $1 AND viv.owner_user_id = $2
-		ORDER BY viv.version DESC, viv.created_at DESC
-	`, itemID, ownerUserID)
-	if err != nil {
-		return nil, fmt.Errorf("list vault item versions: %w", err)
-	}
-	defer rows.Close()
-
-	versions := make([]domain.VaultItemVersion, 0)
-	for rows.Next() {
-		version, err := scanVaultItemVersion(rows)
+	return asOwner(ctx, readsFrom(r.db, r.reads), ownerUserID, func(ctx context.Context) ([]domain.VaultItemVersion, error) {
+		rows, err := readFor(ctx, r.db, r.reads).QueryContext(ctx, `
+			SELECT
+				viv.id, viv.item_id, viv.owner_user_id, viv.folder_id, viv.ciphertext, viv.nonce,
+				viv.dek_wrapped, viv.wrap_nonce, viv.algo_version, viv.metadata, viv.version, viv.created_at
+			FROM vault_item_versions viv
+			WHERE viv.item_id = $1 AND viv.owner_user_id = $2
+			ORDER BY viv.version DESC, viv.created_at DESC
+		`, itemID, ownerUserID)
 		if err != nil {
-			return nil, fmt.Errorf("scan vault item version: %w", err)
+			return nil, fmt.Errorf("list vault item versions: %w", err)
+		}
+		defer rows.Close()
+
+		versions := make([]domain.VaultItemVersion, 0)
+		for rows.Next() {
+			version, err := scanVaultItemVersion(rows)
+			if err != nil {
+				return nil, fmt.Errorf("scan vault item version: %w", err)
+			}
+			versions = append(versions, version)
 		}
-		versions = append(versions, version)
-	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterate vault item versions: %w", err)
-	}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("iterate vault item versions: %w", err)
+		}
 
-	return versions, nil
+		return versions, nil
+	})
 }
 
 func (r *VaultRepository) UpdateVaultItemForOwner(ctx context.Context, itemID string, ownerUserID string, input domain.UpdateVaultItemInput) (domain.VaultItem, error) {
-	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
-	if err != nil {
-		return domain.VaultItem{}, fmt.Errorf("begin update vault item tx: %w", err)
-	}
-	defer func() {
-		_ = tx.Rollback()
-	}()
-
-	current, err := scanVaultItem(tx.QueryRowContext(ctx, `
-		SELECT
-			vi.id, vi.owner_user_id, vi.folder_id, vi.ciphertext, vi.nonce,
-			vi.dek_wrapped, vi.wrap_nonce, vi.algo_version, vi.metadata,
-			(SELECT COUNT(*) > 0 FROM vault_shares vs WHERE vs.item_id = vi.id) as is_shared,
-			vi.version, vi.created_at, vi.updated_at, vi.deleted_at
-		FROM vault_items vi
-		WHERE vi.id = $1 AND vi.owner_user_id = $2 AND vi.deleted_at IS NULL
-		FOR UPDATE
-	`, itemID, ownerUserID))
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return domain.VaultItem{}, domain.ErrNotFound
+	return asOwner(ctx, r.db, ownerUserID, func(ctx context.Context) (domain.VaultItem, error) {
+		tx, commit, rollback, err := beginTx(ctx, r.db)
+		if err != nil {
+			return domain.VaultItem{}, fmt.Errorf("begin update vault item tx: %w", err)
+		}
+		defer rollback()
+
+		current, err := scanVaultItem(tx.QueryRowContext(ctx, `
+			SELECT
+				vi.id, vi.owner_user_id, vi.folder_id, vi.ciphertext, vi.nonce,
+				vi.dek_wrapped, vi.wrap_nonce, vi.algo_version, vi.metadata,
+				(SELECT COUNT(*) > 0 FROM vault_shares vs WHERE vs.item_id = vi.id) as is_shared,
+				vi.version, vi.created_at, vi.updated_at, vi.deleted_at
+			FROM vault_items vi
+			WHERE vi.id = $1 AND vi.owner_user_id = $2 AND vi.deleted_at IS NULL
+			FOR UPDATE
+		`, itemID, ownerUserID))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return domain.VaultItem{}, domain.ErrNotFound
+			}
+			return domain.VaultItem{}, fmt.Errorf("lock vault item for update: %w", err)
 		}
-		return domain.VaultItem{}, fmt.Errorf("lock vault item for update: %w", err)
-	}
 
-	if err := r.insertVaultItemVersion(ctx, tx, current); err != nil {
-		return domain.VaultItem{}, err
-	}
+		if err := r.insertVaultItemVersion(ctx, tx, current); err != nil {
+			return domain.VaultItem{}, err
+		}
 
-	nextVersion := current.Version + 1
-	updated, err := scanVaultItem(tx.QueryRowContext(ctx, `
-		UPDATE vault_items
-		SET
-			folder_id = $3,
-			ciphertext = $4,
-			nonce = $5,
-			dek_wrapped = $6,
-			wrap_nonce = $7,
-			algo_version = $8,
-			metadata = $9,
-			version = $10,
-			updated_at = NOW()
-		WHERE id = $1 AND owner_user_id = $2
-		RETURNING
-			id, owner_user_id, folder_id, ciphertext, nonce, dek_wrapped, wrap_nonce, algo_version, metadata,
-			(SELECT COUNT(*) > 0 FROM vault_shares vs WHERE vs.item_id = vault_items.id) as is_shared,
-			version, created_at, updated_at, deleted_at
-	`, itemID, ownerUserID, input.FolderID, input.Ciphertext, input.Nonce, input.WrappedDEK, input.WrapNonce, input.AlgoVersion, nullableJSON(input.Metadata), nextVersion))
-	if err != nil {
-		return domain.VaultItem{}, fmt.Errorf("update vault item: %w", err)
+		nextVersion := current.Version + 1
+		updated, err := scanVaultItem(tx.QueryRowContext(ctx, `
+			UPDATE vault_items
+			SET
+				folder_id = $3,
+				ciphertext = $4,
+				nonce = $5,
+				dek_wrapped = $6,
+				wrap_nonce = $7,
+				algo_version = $8,
+				metadata = $9,
+				version = $10,
+				updated_at = NOW()
+			WHERE id = $1 AND owner_user_id = $2
+			RETURNING
+				id, owner_user_id, folder_id, ciphertext, nonce, dek_wrapped, wrap_nonce, algo_version, metadata,
+				(SELECT COUNT(*) > 0 FROM vault_shares vs WHERE vs.item_id = vault_items.id) as is_shared,
+				version, created_at, updated_at, deleted_at
+		`, itemID, ownerUserID, input.FolderID, input.Ciphertext, input.Nonce, input.WrappedDEK, input.WrapNonce, input.AlgoVersion, nullableJSON(input.Metadata), nextVersion))
+		if err != nil {
+			return domain.VaultItem{}, fmt.Errorf("update vault item: %w", err)
+		}
+
+		if err := commit(); err != nil {
+			return domain.VaultItem{}, fmt.Errorf("commit update vault item tx: %w", err)
+		}
+		return updated, nil
+	})
+}
+
+func (r *VaultRepository) DeleteVaultItemForOwner(ctx context.Context, itemID string, ownerUserID string) (bool, error) {
+	return asOwner(ctx, r.db, ownerUserID, func(ctx context.Context) (bool, error) {
+		result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+			UPDATE vault_items
+			SET deleted_at = NOW(), updated_at = NOW()
+			WHERE id = $1 AND owner_user_id = $2 AND deleted_at IS NULL
+		`, itemID, ownerUserID)
+		if err != nil {
+			return false, fmt.Errorf("delete vault item: %w", err)
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return false, fmt.Errorf("read rows affected: %w", err)
+		}
+		return affected > 0, nil
+	})
+}
+
+func (r *VaultRepository) RestoreVaultItemForOwner(ctx context.Context, itemID string, ownerUserID string) (domain.VaultItem, error) {
+	return asOwner(ctx, r.db, ownerUserID, func(ctx context.Context) (domain.VaultItem, error) {
+		item, err := scanVaultItem(dbFor(ctx, r.db).QueryRowContext(ctx, `
+			UPDATE vault_items
+			SET deleted_at = NULL, updated_at = NOW()
+			WHERE id = $1 AND owner_user_id = $2 AND deleted_at IS NOT NULL
+			RETURNING
+				id, owner_user_id, folder_id, ciphertext, nonce, dek_wrapped, wrap_nonce, algo_version, metadata,
+				(SELECT COUNT(*) > 0 FROM vault_shares vs WHERE vs.item_id = vault_items.id) as is_shared,
+				version, created_at, updated_at, deleted_at
+		`, itemID, ownerUserID))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return domain.VaultItem{}, domain.ErrNotFound
+			}
+			return domain.VaultItem{}, fmt.Errorf("restore vault item: %w", err)
+		}
+		return item, nil
+	})
+}
+
+// GetVaultRevision returns a fingerprint of the owner's vault that changes
+// whenever an item is created, updated, trashed, restored or (un)shared, or
+// a folder is created, renamed or deleted. It only reads aggregates, so it
+// is much cheaper than listing the items.
+func (r *VaultRepository) GetVaultRevision(ctx context.Context, ownerUserID string) (string, error) {
+	return asOwner(ctx, r.db, ownerUserID, func(ctx context.Context) (string, error) {
+		var (
+			itemCount   int64
+			versionSum  int64
+			lastUpdated time.Time
+			shareCount  int64
+			lastShareAt time.Time
+			folderCount int64
+			lastFolder  time.Time
+		)
+		err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+			SELECT
+				COUNT(*),
+				COALESCE(SUM(vi.version), 0),
+				COALESCE(MAX(vi.updated_at), 'epoch'::timestamptz),
+				(SELECT COUNT(*) FROM vault_shares vs JOIN vault_items v ON v.id = vs.item_id WHERE v.owner_user_id = $1),
+				(SELECT COALESCE(MAX(vs.updated_at), 'epoch'::timestamptz) FROM vault_shares vs JOIN vault_items v ON v.id = vs.item_id WHERE v.owner_user_id = $1),
+				(SELECT COUNT(*) FROM vault_folders vf WHERE vf.owner_user_id = $1),
+				(SELECT COALESCE(MAX(vf.updated_at), 'epoch'::timestamptz) FROM vault_folders vf WHERE vf.owner_user_id = $1)
+			FROM vault_items vi
+			WHERE vi.owner_user_id = $1
+		`, ownerUserID).Scan(&itemCount, &versionSum, &lastUpdated, &shareCount, &lastShareAt, &folderCount, &lastFolder)
+		if err != nil {
+			return "", fmt.Errorf("query vault revision: %w", err)
+		}
+
+		return fmt.Sprintf("%d.%d.%d.%d.%d.%d.%d", itemCount, versionSum, lastUpdated.UnixMicro(), shareCount, lastShareAt.UnixMicro(), folderCount, lastFolder.UnixMicro()), nil
+	})
+}
+
+func (r *VaultRepository) RewrapVaultItemKeys(ctx context.Context, ownerUserID string, keys []domain.VaultItemKey) error {
+	if !uniqueItemKeys(keys) {
+		return domain.ErrRewrapIncomplete
 	}
+	// asOwner runs fn in a transaction, so the keys change all at once.
+	_, err := asOwner(ctx, r.db, ownerUserID, func(ctx context.Context) (struct{}, error) {
+		db := dbFor(ctx, r.db)
+		var count int
+		if err := db.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM vault_items WHERE owner_user_id = $1
+		`, ownerUserID).Scan(&count); err != nil {
+			return struct{}{}, fmt.Errorf("count vault items: %w", err)
+		}
+		if count != len(keys) {
+			return struct{}{}, domain.ErrRewrapIncomplete
+		}
+		for _, key := range keys {
+			result, err := db.ExecContext(ctx, `
+				UPDATE vault_items
+				SET dek_wrapped = $3, wrap_nonce = $4, updated_at = NOW()
+				WHERE id = $1 AND owner_user_id = $2
+			`, key.ItemID, ownerUserID, key.WrappedDEK, key.WrapNonce)
+			if err != nil {
+				return struct{}{}, fmt.Errorf("rewrap vault item key: %w", err)
+			}
+			if err := requireAffected(result); err != nil {
+				return struct{}{}, domain.ErrRewrapIncomplete
+			}
+		}
+		return struct{}{}, nil
+	})
+	return err
+}
 
-	if err := tx.Commit(); err != nil {
-		return domain.VaultItem{}, fmt.Errorf("commit update vault item tx: %w", err)
+// uniqueItemKeys reports whether no item appears twice in keys.
+func uniqueItemKeys(keys []domain.VaultItemKey) bool {
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if seen[key.ItemID] {
+			return false
+		}
+		seen[key.ItemID] = true
 	}
-	return updated, nil
+	return true
 }
 
-func (r *VaultRepository) DeleteVaultItemForOwner(ctx context.Context, itemID string, ownerUserID string) (bool, error) {
-	result, err := r.db.ExecContext(ctx, `
-		UPDATE vault_items
-		SET deleted_at = NOW(), updated_at = NOW()
-		WHERE id = $1 AND owner_user_id = $2 AND deleted_at IS NULL
-	`, itemID, ownerUserID)
-	if err != nil {
-		return false, fmt.Errorf("delete vault item: %w", err)
+// PurgeDeletedVaultItems permanently removes items that have been in the
+// trash since before deletedBefore, across all users, and leaves their
+// tombstones for delta syncs.
+func (r *VaultRepository) PurgeDeletedVaultItems(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	return asSystem(ctx, r.db, func(ctx context.Context) (int64, error) {
+		result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+			WITH purged AS (
+				DELETE FROM vault_items WHERE deleted_at IS NOT NULL AND deleted_at < $1
+				RETURNING id, owner_user_id
+			)
+			INSERT INTO vault_item_tombstones (item_id, owner_user_id, deleted_at)
+			SELECT id, owner_user_id, NOW() FROM purged
+			ON CONFLICT (item_id) DO NOTHING
+		`, deletedBefore)
+		if err != nil {
+			return 0, fmt.Errorf("purge deleted vault items: %w", err)
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("read rows affected: %w", err)
+		}
+		return affected, nil
+	})
+}
+
+func (r *VaultRepository) ListVaultItemChangesByOwner(ctx context.Context, ownerUserID string, since time.Time) ([]domain.VaultItem, []string, error) {
+	type changes struct {
+		items   []domain.VaultItem
+		deleted []string
 	}
+	got, err := asOwner(ctx, readsFrom(r.db, r.reads), ownerUserID, func(ctx context.Context) (changes, error) {
+		db := readFor(ctx, r.db, r.reads)
+		rows, err := db.QueryContext(ctx, `
+			SELECT
+				vi.id, vi.owner_user_id, vi.folder_id, vi.ciphertext, vi.nonce,
+				vi.dek_wrapped, vi.wrap_nonce, vi.algo_version, vi.metadata,
+				(SELECT COUNT(*) > 0 FROM vault_shares vs WHERE vs.item_id = vi.id) as is_shared,
+				vi.version, vi.created_at, vi.updated_at, vi.deleted_at
+			FROM vault_items vi
+			WHERE vi.owner_user_id = $1 AND vi.updated_at > $2
+			ORDER BY vi.updated_at, vi.id
+		`, ownerUserID, since)
+		if err != nil {
+			return changes{}, fmt.Errorf("query changed vault items: %w", err)
+		}
+		defer rows.Close()
+
+		var c changes
+		for rows.Next() {
+			item, err := scanVaultItem(rows)
+			if err != nil {
+				return changes{}, fmt.Errorf("scan vault item: %w", err)
+			}
+			if item.DeletedAt != nil {
+				c.deleted = append(c.deleted, item.ID)
+				continue
+			}
+			c.items = append(c.items, item)
+		}
+		if err := rows.Err(); err != nil {
+			return changes{}, fmt.Errorf("iterate changed vault items: %w", err)
+		}
 
-	affected, err := result.RowsAffected()
+		purged, err := db.QueryContext(ctx, `
+			SELECT item_id FROM vault_item_tombstones
+			WHERE owner_user_id = $1 AND deleted_at > $2
+			ORDER BY deleted_at, item_id
+		`, ownerUserID, since)
+		if err != nil {
+			return changes{}, fmt.Errorf("query vault item tombstones: %w", err)
+		}
+		ids, err := scanStrings(purged)
+		if err != nil {
+			return changes{}, fmt.Errorf("scan vault item tombstones: %w", err)
+		}
+		c.deleted = append(c.deleted, ids...)
+		return c, nil
+	})
 	if err != nil {
-		return false, fmt.Errorf("read rows affected: %w", err)
+		return nil, nil, err
 	}
-	return affected > 0, nil
+	return got.items, got.deleted, nil
 }
 
-func (r *VaultRepository) RestoreVaultItemForOwner(ctx context.Context, itemID string, ownerUserID string) (domain.VaultItem, error) {
-	item, err := scanVaultItem(r.db.QueryRowContext(ctx, `
-		UPDATE vault_items
-		SET deleted_at = NULL, updated_at = NOW()
-		WHERE id = $1 AND owner_user_id = $2 AND deleted_at IS NOT NULL
-		RETURNING
-			id, owner_user_id, folder_id, ciphertext, nonce, dek_wrapped, wrap_nonce, algo_version, metadata,
-			(SELECT COUNT(*) > 0 FROM vault_shares vs WHERE vs.item_id = vault_items.id) as is_shared,
-			version, created_at, updated_at, deleted_at
-	`, itemID, ownerUserID))
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return domain.VaultItem{}, domain.ErrNotFound
+func (r *VaultRepository) DeleteVaultItemTombstones(ctx context.Context, before time.Time) (int64, error) {
+	return asSystem(ctx, r.db, func(ctx context.Context) (int64, error) {
+		result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM vault_item_tombstones WHERE deleted_at < $1`, before)
+		if err != nil {
+			return 0, fmt.Errorf("delete vault item tombstones: %w", err)
 		}
-		return domain.VaultItem{}, fmt.Errorf("restore vault item: %w", err)
-	}
-	return item, nil
+		return result.RowsAffected()
+	})
 }
 
 func (r *VaultRepository) GetVaultSaltForUser(ctx context.Context, userID string) ([]byte, error) {
 	var salt []byte
-	err := r.db.QueryRowContext(ctx, `
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
 		SELECT salt FROM auth_credentials WHERE user_id = $1
 	`, userID).Scan(&salt)
 
@@ -0,0 +1,352 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"pmv2/backend/internal/domain"
+)
+
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+func (r *WebhookRepository) CreateEndpoint(ctx context.Context, endpoint domain.WebhookEndpoint) (domain.WebhookEndpoint, error) {
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, `
+		INSERT INTO webhook_endpoints (id, user_id, url, secret_enc, event_types, enabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		RETURNING created_at, updated_at
+	`, endpoint.ID, endpoint.UserID, endpoint.URL, endpoint.SecretEnc, pq.Array(eventTypeStrings(endpoint.EventTypes)), endpoint.Enabled).
+		Scan(&endpoint.CreatedAt, &endpoint.UpdatedAt)
+	if err != nil {
+		return domain.WebhookEndpoint{}, fmt.Errorf("insert webhook endpoint: %w", err)
+	}
+	return endpoint, nil
+}
+
+const webhookEndpointColumns = `id, user_id, url, secret_enc, event_types, enabled, created_at, updated_at`
+
+func (r *WebhookRepository) ListEndpoints(ctx context.Context, userID string) ([]domain.WebhookEndpoint, error) {
+	return r.queryEndpoints(ctx, `
+		SELECT `+webhookEndpointColumns+`
+		FROM webhook_endpoints
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, userID)
+}
+
+func (r *WebhookRepository) ListEndpointsForEvent(ctx context.Context, userID string, eventType domain.EventType) ([]domain.WebhookEndpoint, error) {
+	return r.queryEndpoints(ctx, `
+		SELECT `+webhookEndpointColumns+`
+		FROM webhook_endpoints
+		WHERE user_id = $1 AND enabled AND (cardinality(event_types) = 0 OR $2 = ANY(event_types))
+	`, userID, string(eventType))
+}
+
+func (r *WebhookRepository) GetEndpoint(ctx context.Context, userID, endpointID string) (domain.WebhookEndpoint, error) {
+	endpoints, err := r.queryEndpoints(ctx, `
+		SELECT `+webhookEndpointColumns+`
+		FROM webhook_endpoints
+		WHERE id = $1 AND user_id = $2
+	`, endpointID, userID)
+	if err != nil {
+		return domain.WebhookEndpoint{}, err
+	}
+	if len(endpoints) == 0 {
+		return domain.WebhookEndpoint{}, domain.ErrNotFound
+	}
+	return endpoints[0], nil
+}
+
+func (r *WebhookRepository) CountEndpoints(ctx context.Context, userID string) (int, error) {
+	var count int
+	if err := dbFor(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM webhook_endpoints WHERE user_id = $1`, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count webhook endpoints: %w", err)
+	}
+	return count, nil
+}
+
+func (r *WebhookRepository) DeleteEndpoint(ctx context.Context, userID, endpointID string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `DELETE FROM webhook_endpoints WHERE id = $1 AND user_id = $2`, endpointID, userID)
+	if err != nil {
+		return fmt.Errorf("delete webhook endpoint: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("read rows affected: %w", err)
+	}
+	if affected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *WebhookRepository) queryEndpoints(ctx context.Context, query string, args ...any) ([]domain.WebhookEndpoint, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query webhook endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	endpoints := make([]domain.WebhookEndpoint, 0)
+	for rows.Next() {
+		var e domain.WebhookEndpoint
+		var eventTypes []string
+		if err := rows.Scan(&e.ID, &e.UserID, &e.URL, &e.SecretEnc, pq.Array(&eventTypes), &e.Enabled, &e.CreatedAt, &e.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan webhook endpoint: %w", err)
+		}
+		for _, t := range eventTypes {
+			e.EventTypes = append(e.EventTypes, domain.EventType(t))
+		}
+		endpoints = append(endpoints, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate webhook endpoints: %w", err)
+	}
+	return endpoints, nil
+}
+
+func (r *WebhookRepository) EnqueueDelivery(ctx context.Context, delivery domain.WebhookDelivery) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (id, endpoint_id, event_id, event_type, payload, status, created_at, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, 'pending', NOW(), NOW())
+		ON CONFLICT (endpoint_id, event_id) DO NOTHING
+	`, uuid.NewString(), delivery.EndpointID, delivery.EventID, string(delivery.EventType), []byte(delivery.Payload))
+	if err != nil {
+		return fmt.Errorf("insert webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		WITH claimed AS (
+			UPDATE webhook_deliveries
+			SET next_attempt_at = NOW() + make_interval(secs => $2), attempts = attempts + 1
+			WHERE id IN (
+				SELECT d.id FROM webhook_deliveries d
+				JOIN webhook_endpoints e ON e.id = d.endpoint_id
+				WHERE d.status = 'pending' AND d.next_attempt_at <= NOW() AND e.enabled
+				ORDER BY d.created_at
+				LIMIT $1
+				FOR UPDATE OF d SKIP LOCKED
+			)
+			RETURNING id, endpoint_id, event_id, event_type, payload, attempts, created_at
+		)
+		SELECT c.id, c.endpoint_id, c.event_id, c.event_type, c.payload, c.attempts, c.created_at,
+		       e.user_id, e.url, e.secret_enc
+		FROM claimed c
+		JOIN webhook_endpoints e ON e.id = c.endpoint_id
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]domain.WebhookDelivery, 0)
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		var eventType string
+		var payload []byte
+		if err := rows.Scan(&d.ID, &d.EndpointID, &d.EventID, &eventType, &payload, &d.Attempts, &d.CreatedAt,
+			&d.Endpoint.UserID, &d.Endpoint.URL, &d.Endpoint.SecretEnc); err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		d.EventType = domain.EventType(eventType)
+		d.Payload = payload
+		d.Status = domain.WebhookDeliveryPending
+		d.Endpoint.ID = d.EndpointID
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, deliveryID string, attempt domain.WebhookDeliveryAttempt) error {
+	var statusCode sql.NullInt32
+	if attempt.StatusCode > 0 {
+		statusCode = sql.NullInt32{Int32: int32(attempt.StatusCode), Valid: true}
+	}
+	var attemptErr sql.NullString
+	if attempt.Error != "" {
+		attemptErr = sql.NullString{String: attempt.Error, Valid: true}
+	}
+
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO webhook_delivery_attempts (id, delivery_id, status_code, error, duration_ms, attempted_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, uuid.NewString(), deliveryID, statusCode, attemptErr, attempt.DurationMS, attempt.AttemptedAt)
+	if err != nil {
+		return fmt.Errorf("insert webhook delivery attempt: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookRepository) MarkDelivered(ctx context.Context, deliveryID string) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE webhook_deliveries SET status = 'succeeded', delivered_at = NOW(), last_error = NULL WHERE id = $1
+	`, deliveryID)
+	if err != nil {
+		return fmt.Errorf("mark webhook delivery delivered: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookRepository) MarkRetry(ctx context.Context, deliveryID string, lastError string, nextAttemptAt time.Time) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE webhook_deliveries SET last_error = $2, next_attempt_at = $3 WHERE id = $1
+	`, deliveryID, lastError, nextAttemptAt)
+	if err != nil {
+		return fmt.Errorf("schedule webhook delivery retry: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookRepository) MarkDead(ctx context.Context, deliveryID string, lastError string) error {
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE webhook_deliveries SET status = 'dead', last_error = $2 WHERE id = $1
+	`, deliveryID, lastError)
+	if err != nil {
+		return fmt.Errorf("mark webhook delivery dead: %w", err)
+	}
+	return nil
+}
+
+// ListDeliveries returns the endpoint's deliveries, newest first, each with
+// its attempt history.
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, endpointID string, limit, offset int) ([]domain.WebhookDelivery, int, error) {
+	var total int
+	if err := dbFor(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM webhook_deliveries WHERE endpoint_id = $1`, endpointID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count webhook deliveries: %w", err)
+	}
+
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT id, endpoint_id, event_id, event_type, payload, status, attempts, COALESCE(last_error, ''), next_attempt_at, delivered_at, created_at
+		FROM webhook_deliveries
+		WHERE endpoint_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, endpointID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]domain.WebhookDelivery, 0)
+	index := make(map[string]int)
+	ids := make([]string, 0)
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		var eventType string
+		var payload []byte
+		var deliveredAt sql.NullTime
+		if err := rows.Scan(&d.ID, &d.EndpointID, &d.EventID, &eventType, &payload, &d.Status, &d.Attempts, &d.LastError, &d.NextAttemptAt, &deliveredAt, &d.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		d.EventType = domain.EventType(eventType)
+		d.Payload = payload
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.Time
+		}
+		index[d.ID] = len(deliveries)
+		ids = append(ids, d.ID)
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("iterate webhook deliveries: %w", err)
+	}
+	if len(ids) == 0 {
+		return deliveries, total, nil
+	}
+
+	attemptRows, err := dbFor(ctx, r.db).QueryContext(ctx, `
+		SELECT delivery_id, COALESCE(status_code, 0), COALESCE(error, ''), duration_ms, attempted_at
+		FROM webhook_delivery_attempts
+		WHERE delivery_id = ANY($1::uuid[])
+		ORDER BY attempted_at
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, 0, fmt.Errorf("list webhook delivery attempts: %w", err)
+	}
+	defer attemptRows.Close()
+
+	for attemptRows.Next() {
+		var deliveryID string
+		var a domain.WebhookDeliveryAttempt
+		if err := attemptRows.Scan(&deliveryID, &a.StatusCode, &a.Error, &a.DurationMS, &a.AttemptedAt); err != nil {
+			return nil, 0, fmt.Errorf("scan webhook delivery attempt: %w", err)
+		}
+		if i, ok := index[deliveryID]; ok {
+			deliveries[i].AttemptLog = append(deliveries[i].AttemptLog, a)
+		}
+	}
+	if err := attemptRows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("iterate webhook delivery attempts: %w", err)
+	}
+	return deliveries, total, nil
+}
+
+func (r *WebhookRepository) Redeliver(ctx context.Context, endpointID, deliveryID string) error {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW(), delivered_at = NULL
+		WHERE id = $1 AND endpoint_id = $2 AND status <> 'pending'
+	`, deliveryID, endpointID)
+	if err != nil {
+		return fmt.Errorf("redeliver webhook delivery: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("read rows affected: %w", err)
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	var status string
+	err = dbFor(ctx, r.db).QueryRowContext(ctx, `SELECT status FROM webhook_deliveries WHERE id = $1 AND endpoint_id = $2`, deliveryID, endpointID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("load webhook delivery: %w", err)
+	}
+	return domain.ErrWebhookDeliveryActive
+}
+
+func (r *WebhookRepository) DeleteDeliveriesBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM webhook_deliveries WHERE status <> 'pending' AND created_at < $1
+	`, before)
+	if err != nil {
+		return 0, fmt.Errorf("delete webhook deliveries: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("read rows affected: %w", err)
+	}
+	return affected, nil
+}
+
+func eventTypeStrings(types []domain.EventType) []string {
+	out := make([]string, 0, len(types))
+	for _, t := range types {
+		out = append(out, string(t))
+	}
+	return out
+}
@@ -3,12 +3,17 @@ package router
 import (
 	"log/slog"
 	"net/http"
+	"net/http/pprof"
 	"strings"
 	"time"
 
+	"pmv2/backend/internal/buildinfo"
 	"pmv2/backend/internal/config"
 	"pmv2/backend/internal/controller"
 	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/graphqlapi"
+	"pmv2/backend/internal/i18n"
+	"pmv2/backend/internal/metrics"
 	"pmv2/backend/internal/middlewares"
 	"pmv2/backend/internal/service"
 	"pmv2/backend/internal/util"
@@ -22,6 +27,9 @@ type routeGroup struct {
 	mux         *http.ServeMux
 	prefix      string
 	middlewares []handlerMiddleware
+	// timeout is the request budget of the group's routes, applied
+	// outside every other middleware so authentication counts against it.
+	timeout time.Duration
 }
 
 func newRouteGroup(mux *http.ServeMux, prefix string, middlewares ...handlerMiddleware) routeGroup {
@@ -40,11 +48,20 @@ func (g routeGroup) Group(prefix string, middlewares ...handlerMiddleware) route
 		mux:         g.mux,
 		prefix:      joinPath(g.prefix, prefix),
 		middlewares: combined,
+		timeout:     g.timeout,
 	}
 }
 
+// WithTimeout returns the group with its routes' request budget replaced
+// by budget (zero for none). Budgets do not nest: a route gets exactly one.
+func (g routeGroup) WithTimeout(budget time.Duration) routeGroup {
+	g.timeout = budget
+	return g
+}
+
 func (g routeGroup) Handle(method string, path string, handler http.HandlerFunc, middlewares ...handlerMiddleware) {
-	pattern := joinPath(g.prefix, path)
+	route := joinPath(g.prefix, path)
+	pattern := route
 	method = strings.ToUpper(strings.TrimSpace(method))
 	if method != "" {
 		pattern = method + " " + pattern
@@ -57,11 +74,34 @@ func (g routeGroup) Handle(method string, path string, handler http.HandlerFunc,
 	for i := len(allMiddlewares) - 1; i >= 0; i-- {
 		handler = allMiddlewares[i](handler)
 	}
+	handler = withTimeout(g.timeout)(handler)
+
+	g.mux.HandleFunc(pattern, instrument(route, handler))
+}
+
+// Fallback serves every request under the group's prefix that no route of
+// the group matches. Group middlewares are not applied and the handler is not
+// instrumented: it is expected to dispatch to a route that is.
+func (g routeGroup) Fallback(handler http.Handler) {
+	g.mux.Handle(g.prefix+"/", handler)
+}
+
+// instrument and withTimeout are middlewares.Instrument and
+// middlewares.Timeout; Handle's parameter shadows the package.
+var (
+	instrument  = middlewares.Instrument
+	withTimeout = middlewares.Timeout
+)
 
-	g.mux.HandleFunc(pattern, handler)
+// Handlers are the HTTP handlers of the process. Ops is nil unless the
+// operations listener is configured (see config.OpsEnabled); the /admin API,
+// /metrics and pprof are then served only by Ops and never by API.
+type Handlers struct {
+	API http.Handler
+	Ops http.Handler
 }
 
-func NewRouter(cfg config.Config, logger *slog.Logger, auditService *service.AuditService, authService *service.AuthService, vaultService *service.VaultService, folderService *service.FolderService, sharingService *service.SharingService, familyService *service.FamilyService) http.Handler {
+func NewRouter(cfg config.Config, logger *slog.Logger, rateLimitStore middlewares.RateLimitStore, auditService *service.AuditService, authService *service.AuthService, vaultService *service.VaultService, attachmentService *service.AttachmentService, folderService *service.FolderService, sharingService *service.SharingService, familyService *service.FamilyService, webhookService *service.WebhookService, notificationService *service.NotificationService, pushService *service.PushService, adminService *service.AdminService, dataExportService *service.DataExportService, ipBanService *service.IPBanService, machineSecretService *service.MachineSecretService, accessRequestService *service.AccessRequestService, shareInvitationService *service.ShareInvitationService, messages *i18n.Bundle) Handlers {
 	authController := controller.NewAuthController(authService, controller.AuthCookieConfig{
 		Name:   cfg.SessionCookieName,
 		Secure: isProductionEnv(cfg.Env),
@@ -72,53 +112,224 @@ func NewRouter(cfg config.Config, logger *slog.Logger, auditService *service.Aud
 		Iterations:  cfg.KDFIterations,
 		Parallelism: cfg.KDFParallelism,
 	})
+	attachmentController := controller.NewAttachmentController(attachmentService, logger)
 	folderController := controller.NewFolderController(folderService, logger)
 	sharingController := controller.NewSharingController(sharingService, logger)
 	familyController := controller.NewFamilyController(familyService, logger)
+	webhookController := controller.NewWebhookController(webhookService, logger)
+	notificationController := controller.NewNotificationController(notificationService, logger)
+	pushController := controller.NewPushController(pushService, logger)
+	adminController := controller.NewAdminController(adminService, logger)
+	dataExportController := controller.NewDataExportController(dataExportService, logger)
+	ipBanController := controller.NewIPBanController(ipBanService, logger)
+	machineSecretController := controller.NewMachineSecretController(machineSecretService, logger)
+	accessRequestController := controller.NewAccessRequestController(accessRequestService, logger)
+	shareInvitationController := controller.NewShareInvitationController(shareInvitationService, logger)
+	graphqlHandler := graphqlapi.NewHandler(graphqlapi.Config{
+		MaxDepth:      cfg.GraphQLMaxDepth,
+		MaxComplexity: cfg.GraphQLMaxComplexity,
+	}, vaultService, folderService, sharingService, logger)
 	authMiddleware := middlewares.NewAuthMiddleware(authService, cfg.SessionCookieName)
 	mux := http.NewServeMux()
 
-	authLimiter := middlewares.NewRateLimiter(rate.Limit(5), 15)
-	root := newRouteGroup(mux, "/")
-	v1 := root.Group("/api/v1")
-	auth := v1.Group("/auth")
+	limiter := func(name string) *middlewares.RateLimiter {
+		rule := cfg.RateLimits[name]
+		return middlewares.NewRateLimiterWithStore(rateLimitStore, name, rate.Limit(rule.Rate), rule.Burst)
+	}
+	loginLimiter := limiter("login")
+	registerLimiter := limiter("register")
+	recoveryLimiter := limiter("recovery")
+	hintLimiter := limiter("hint")
+	breachLimiter := limiter("breach_check")
+	exportLimiter := limiter("export")
+	heavy := middlewares.NewConcurrencyLimiter(cfg.ConcurrencyLimitGlobal, cfg.ConcurrencyLimitPerUser, cfg.ConcurrencyRetryAfter)
+	globalLimiter := limiter("global")
+	loginAccountLimiter := limiter("login_account")
+	registerAccountLimiter := limiter("register_account")
+	recoveryAccountLimiter := limiter("recovery_account")
+	if cfg.EmailAliasNormalization {
+		registerAccountLimiter.UseCanonicalEmails()
+		recoveryAccountLimiter.UseCanonicalEmails()
+	}
+
+	// /api/v1 is frozen. Breaking changes ship in /api/v2, which defines
+	// only the routes that changed and falls back to v1 for the rest.
+	apiV1 := middlewares.APIVersion{
+		Version:      1,
+		Prefix:       "/api/v1",
+		Successor:    "/api/v2",
+		DeprecatedAt: cfg.APIV1DeprecatedAt,
+		Sunset:       cfg.APIV1Sunset,
+	}
+	apiV2 := middlewares.APIVersion{Version: 2, Prefix: "/api/v2"}
+	apiVersions := []middlewares.APIVersion{apiV1, apiV2}
+
+	// Every API route gets a request budget: short for sign-in and the
+	// other auth calls, long for bulk import, full sync and data export
+	// downloads, the default for the rest.
+	root := newRouteGroup(mux, "/").WithTimeout(cfg.RequestTimeout)
+	v1 := root.Group(apiV1.Prefix, apiV1.Middleware)
+	v2 := root.Group(apiV2.Prefix, apiV2.Middleware)
+	v2.Fallback(middlewares.VersionFallback(apiV1, apiV2, mux))
+	auth := v1.Group("/auth").WithTimeout(cfg.RequestTimeoutAuth)
+	authLong := auth.WithTimeout(cfg.RequestTimeoutLong)
 	vault := v1.Group("/vault")
+	vaultLong := vault.WithTimeout(cfg.RequestTimeoutLong)
 	folders := v1.Group("/folders")
 	users := v1.Group("/users")
+	keys := v1.Group("/keys")
 	family := v1.Group("/family")
 	audit := v1.Group("/audit")
+	webhooks := v1.Group("/webhooks")
+	secrets := v1.Group("/secrets")
+
+	// Operational surfaces live on their own mux when the mTLS listener is
+	// enabled; otherwise the admin API stays on the public one.
+	opsMux := mux
+	if cfg.OpsEnabled() {
+		opsMux = http.NewServeMux()
+	}
+	ops := newRouteGroup(opsMux, "/").WithTimeout(cfg.RequestTimeout)
+	adminV1 := ops.Group("/admin/api/v1")
 
 	// Health check
+	build := buildinfo.Get()
 	root.Handle(http.MethodGet, "/healthz", func(w http.ResponseWriter, r *http.Request) {
 		util.WriteJSON(w, http.StatusOK, dto.HealthResponse{
 			Status:  "ok",
 			Service: "pmv2-api",
 			Time:    time.Now().UTC().Format(time.RFC3339),
 			Env:     cfg.Env,
+			Version: build.Version,
+			Commit:  build.Commit,
+		})
+	})
+
+	// The running build, for operators and bug reports
+	root.Handle(http.MethodGet, "/version", func(w http.ResponseWriter, r *http.Request) {
+		util.WriteJSON(w, http.StatusOK, dto.VersionResponse{
+			Version:   build.Version,
+			Commit:    build.Commit,
+			BuildTime: build.BuildTime,
+			GoVersion: build.GoVersion,
 		})
 	})
 
+	// Supported API versions and their deprecation schedule
+	root.Handle(http.MethodGet, "/api/versions", func(w http.ResponseWriter, r *http.Request) {
+		resp := dto.APIVersionsResponse{Versions: make([]dto.APIVersionResponse, 0, len(apiVersions))}
+		for _, version := range apiVersions {
+			resp.Versions = append(resp.Versions, apiVersionToResponse(version))
+		}
+		util.WriteJSON(w, http.StatusOK, resp)
+	})
+
+	// Read-only GraphQL for dashboards; unversioned, the schema evolves
+	// additively.
+	root.Handle(http.MethodPost, "/api/graphql", authMiddleware.WithSession(graphqlHandler.HandleQuery))
+
 	// Auth routes - Unauthenticated
-	auth.Handle(http.MethodPost, "/register", authController.HandleRegister, authLimiter.Middleware)
-	auth.Handle(http.MethodPost, "/login", authController.HandleLogin, authLimiter.Middleware)
-	auth.Handle(http.MethodPost, "/recovery/verify", authController.HandleRecoveryVerify, authLimiter.Middleware)
-	auth.Handle(http.MethodPost, "/recovery/reset", authController.HandleRecoveryReset, authLimiter.Middleware)
+	auth.Handle(http.MethodPost, "/register", authController.HandleRegister, registerLimiter.Middleware, registerAccountLimiter.AccountMiddleware, breachLimiter.Middleware)
+	auth.Handle(http.MethodPost, "/login", authController.HandleLogin, loginLimiter.Middleware, loginAccountLimiter.AccountMiddleware)
+	auth.Handle(http.MethodPost, "/recovery/verify", authController.HandleRecoveryVerify, recoveryLimiter.Middleware)
+	auth.Handle(http.MethodPost, "/recovery/reset", authController.HandleRecoveryReset, recoveryLimiter.Middleware, breachLimiter.Middleware)
+	auth.Handle(http.MethodPost, "/recovery/passkey/begin", authController.HandlePasskeyRecoveryBegin, recoveryLimiter.Middleware)
+	auth.Handle(http.MethodPost, "/recovery/passkey/finish", authController.HandlePasskeyRecoveryFinish, recoveryLimiter.Middleware)
+	// Forgot password: a reset link is emailed, and its token sets a new
+	// master password along with the re-wrapped vault keys
+	auth.Handle(http.MethodPost, "/password-reset/request", authController.HandlePasswordResetRequest, recoveryLimiter.Middleware, recoveryAccountLimiter.AccountMiddleware)
+	authLong.Handle(http.MethodPost, "/password-reset/confirm", authController.HandlePasswordResetConfirm, recoveryLimiter.Middleware, breachLimiter.Middleware)
+	// Sign-out link of lockout alert emails
+	auth.Handle(http.MethodPost, "/revoke-sessions", authController.HandleRevokeSessions, recoveryLimiter.Middleware)
+
+	// Passkey-only accounts and passkey sign-in
+	auth.Handle(http.MethodPost, "/passkeys/register/begin", authController.HandlePasskeyRegisterBegin, registerLimiter.Middleware)
+	auth.Handle(http.MethodPost, "/passkeys/register/finish", authController.HandlePasskeyRegisterFinish, registerLimiter.Middleware)
+	auth.Handle(http.MethodPost, "/passkeys/login/begin", authController.HandlePasskeyLoginBegin, loginLimiter.Middleware)
+	auth.Handle(http.MethodPost, "/passkeys/login/finish", authController.HandlePasskeyLoginFinish, loginLimiter.Middleware)
+	auth.Handle(http.MethodPost, "/passkeys/second-factor/begin", authController.HandlePasskeySecondFactorBegin, loginLimiter.Middleware)
+	// The same ceremonies under the /webauthn paths clients written
+	// against the WebAuthn naming use
+	auth.Handle(http.MethodPost, "/webauthn/register/begin", authController.HandlePasskeyRegisterBegin, registerLimiter.Middleware)
+	auth.Handle(http.MethodPost, "/webauthn/register/finish", authController.HandlePasskeyRegisterFinish, registerLimiter.Middleware)
+	auth.Handle(http.MethodPost, "/webauthn/login/begin", authController.HandlePasskeyLoginBegin, loginLimiter.Middleware)
+	auth.Handle(http.MethodPost, "/webauthn/login/finish", authController.HandlePasskeyLoginFinish, loginLimiter.Middleware)
+
+	// Sign-in by QR code: the new device starts and polls, a signed-in
+	// device scans and decides
+	auth.Handle(http.MethodPost, "/qr-login", authController.HandleQRLoginStart, loginLimiter.Middleware)
+	auth.Handle(http.MethodPost, "/qr-login/poll", authController.HandleQRLoginPoll, loginLimiter.Middleware)
+
+	// Token refresh; it checks the token itself so a replayed one is caught
+	auth.Handle(http.MethodPost, "/refresh", authController.HandleRefreshSession, loginLimiter.Middleware)
 
 	// Auth routes - Authenticated
-	auth.Handle(http.MethodGet, "/me", authMiddleware.WithSession(authController.HandleMe))
-	auth.Handle(http.MethodPost, "/logout", authMiddleware.WithSession(authController.HandleLogout))
+	auth.Handle(http.MethodGet, "/me", authMiddleware.WithSessionDuringStepUp(authController.HandleMe))
+	auth.Handle(http.MethodGet, "/status", authMiddleware.WithOptionalSession(authController.HandleSessionStatus))
+	// Session event stream; held open, so it has no request budget
+	auth.WithTimeout(0).Handle(http.MethodGet, "/events", authMiddleware.WithSessionDuringStepUp(authController.HandleEvents))
+	auth.Handle(http.MethodPost, "/logout", authMiddleware.WithSessionDuringStepUp(authController.HandleLogout))
 	auth.Handle(http.MethodPut, "/profile", authMiddleware.WithSession(authController.HandleUpdateProfile))
+	// Forced master password change of an account flagged compromised
+	authLong.Handle(http.MethodPost, "/credential-reset", authMiddleware.WithSessionDuringCredentialReset(heavy.Limit(authController.HandleCredentialReset)), recoveryLimiter.Middleware, breachLimiter.Middleware)
+	// Master password change, re-wrapping the vault's item keys
+	authLong.Handle(http.MethodPost, "/change-password", authMiddleware.WithSession(heavy.Limit(authController.HandleChangePassword)), recoveryLimiter.Middleware, breachLimiter.Middleware)
+	// Folding a second account of the caller's into theirs
+	authLong.Handle(http.MethodPost, "/merge", authMiddleware.WithSession(heavy.Limit(authController.HandleMergeAccounts)), recoveryLimiter.Middleware)
+	auth.Handle(http.MethodGet, "/session-binding", authMiddleware.WithSession(authController.HandleGetSessionBinding))
+	auth.Handle(http.MethodPut, "/session-binding", authMiddleware.WithSession(authController.HandleUpdateSessionBinding))
+	auth.Handle(http.MethodPost, "/dpop-key", authMiddleware.WithSession(authController.HandleRegisterDPoPKey))
+
+	// Devices signed in from
+	auth.Handle(http.MethodGet, "/devices", authMiddleware.WithSession(authController.HandleListDevices))
+	auth.Handle(http.MethodDelete, "/devices/{device_id}", authMiddleware.WithSession(authController.HandleRemoveDevice))
+
+	// Active sessions; DELETE /sessions keeps only the caller's
+	auth.Handle(http.MethodGet, "/sessions", authMiddleware.WithSession(authController.HandleListSessions))
+	auth.Handle(http.MethodDelete, "/sessions", authMiddleware.WithSession(authController.HandleRevokeOtherSessions))
+	auth.Handle(http.MethodDelete, "/sessions/{session_id}", authMiddleware.WithSession(authController.HandleRevokeSession))
+
+	// Passkeys of the signed-in account
+	auth.Handle(http.MethodGet, "/passkeys", authMiddleware.WithSession(authController.HandleListPasskeys))
+	auth.Handle(http.MethodPost, "/passkeys/add/begin", authMiddleware.WithSession(authController.HandleAddPasskeyBegin))
+	auth.Handle(http.MethodPost, "/passkeys/add/finish", authMiddleware.WithSession(authController.HandleAddPasskeyFinish))
+	auth.Handle(http.MethodDelete, "/passkeys/{passkey_id}", authMiddleware.WithSession(authController.HandleRemovePasskey))
+	auth.Handle(http.MethodPost, "/qr-login/{request_id}/scan", authMiddleware.WithSession(authController.HandleQRLoginScan))
+	auth.Handle(http.MethodPost, "/qr-login/{request_id}/approve", authMiddleware.WithSession(authController.HandleQRLoginApprove))
+	auth.Handle(http.MethodPost, "/qr-login/{request_id}/deny", authMiddleware.WithSession(authController.HandleQRLoginDeny))
 
 	// TOTP routes
 	auth.Handle(http.MethodPost, "/totp/setup", authMiddleware.WithSession(authController.HandleTOTPSetup))
 	auth.Handle(http.MethodPost, "/totp/enable", authMiddleware.WithSession(authController.HandleTOTPEnable))
-	auth.Handle(http.MethodPost, "/totp/verify", authMiddleware.WithSession(authController.HandleTOTPVerify))
+	auth.Handle(http.MethodPost, "/totp/verify", authMiddleware.WithSessionDuringStepUp(authController.HandleTOTPVerify))
 	auth.Handle(http.MethodPost, "/totp/disable", authMiddleware.WithSession(authController.HandleTOTPDisable))
 
+	// Own activity log (alias of GET /audit)
+	auth.Handle(http.MethodGet, "/audit", authMiddleware.WithSession(auditController.HandleGetLogs))
+
+	// Notification preferences
+	auth.Handle(http.MethodGet, "/notifications", authMiddleware.WithSession(notificationController.HandleGetPreferences))
+	auth.Handle(http.MethodPatch, "/notifications", authMiddleware.WithSession(notificationController.HandleUpdatePreferences))
+
+	// Mobile push tokens
+	auth.Handle(http.MethodPost, "/push-tokens", authMiddleware.WithSession(pushController.HandleRegisterToken))
+	auth.Handle(http.MethodGet, "/push-tokens", authMiddleware.WithSession(pushController.HandleListTokens))
+	auth.Handle(http.MethodDelete, "/push-tokens/{token_id}", authMiddleware.WithSession(pushController.HandleRemoveToken))
+
+	// API keys for service accounts reading machine secrets
+	auth.Handle(http.MethodPost, "/api-keys", authMiddleware.WithSession(machineSecretController.HandleCreateKey))
+	auth.Handle(http.MethodGet, "/api-keys", authMiddleware.WithSession(machineSecretController.HandleListKeys))
+	auth.Handle(http.MethodDelete, "/api-keys/{key_id}", authMiddleware.WithSession(machineSecretController.HandleDeleteKey))
+
 	// Recovery setup
 	auth.Handle(http.MethodGet, "/recovery/status", authMiddleware.WithSession(authController.HandleGetRecoveryStatus))
 	auth.Handle(http.MethodPost, "/recovery/setup", authMiddleware.WithSession(authController.HandleRecoverySetup))
 
+	// Personal data export, built in the background
+	auth.Handle(http.MethodPost, "/export-data", authMiddleware.WithSession(dataExportController.HandleRequestExport), exportLimiter.Middleware)
+	auth.Handle(http.MethodGet, "/export-data/{export_id}", authMiddleware.WithSession(dataExportController.HandleGetExport), exportLimiter.Middleware)
+	authLong.Handle(http.MethodGet, "/export-data/{export_id}/archive", authMiddleware.WithSession(heavy.Limit(dataExportController.HandleDownloadExport)), exportLimiter.Middleware)
+
 	// Folder routes
 	folders.Handle(http.MethodPost, "", authMiddleware.WithSession(folderController.HandleCreateFolder))
 	folders.Handle(http.MethodGet, "", authMiddleware.WithSession(folderController.HandleListFolders))
@@ -126,29 +337,69 @@ func NewRouter(cfg config.Config, logger *slog.Logger, auditService *service.Aud
 	folders.Handle(http.MethodDelete, "/{folder_id}", authMiddleware.WithSession(folderController.HandleDeleteFolder))
 
 	// Vault routes
-	vault.Handle(http.MethodGet, "/kdf-params", vaultController.HandleGetKDFParams) // Public — no auth
-	vault.Handle(http.MethodGet, "/salt", authMiddleware.WithSession(vaultController.HandleGetVaultSalt))
+	vault.Handle(http.MethodGet, "/kdf-params", vaultController.HandleGetKDFParams, hintLimiter.Middleware) // Public — no auth
+	vault.Handle(http.MethodGet, "/salt", authMiddleware.WithSessionDuringCredentialReset(vaultController.HandleGetVaultSalt))
 	vault.Handle(http.MethodPost, "/items", authMiddleware.WithSession(vaultController.HandleCreateItem))
-	vault.Handle(http.MethodPost, "/items/bulk", authMiddleware.WithSession(vaultController.HandleBulkCreateItems))
-	vault.Handle(http.MethodGet, "/items", authMiddleware.WithSession(vaultController.HandleListItems))
-	vault.Handle(http.MethodGet, "/items/trash", authMiddleware.WithSession(vaultController.HandleListDeletedItems))
+	vaultLong.Handle(http.MethodPost, "/items/bulk", authMiddleware.WithSession(heavy.Limit(vaultController.HandleBulkCreateItems)))
+	vaultLong.Handle(http.MethodPost, "/import", authMiddleware.WithSession(heavy.Limit(vaultController.HandleImportItems)))
+	vault.Handle(http.MethodGet, "/items", authMiddleware.WithSessionDuringCredentialReset(vaultController.HandleListItems))
+	vaultLong.Handle(http.MethodGet, "/sync", authMiddleware.WithSession(vaultController.HandleSync))
+	vaultLong.Handle(http.MethodGet, "/sync/full", authMiddleware.WithSession(vaultController.HandleFullSync), exportLimiter.Middleware)
+	vault.Handle(http.MethodGet, "/items/trash", authMiddleware.WithSessionDuringCredentialReset(vaultController.HandleListDeletedItems))
 	vault.Handle(http.MethodGet, "/items/{item_id}", authMiddleware.WithSession(vaultController.HandleGetItem))
 	vault.Handle(http.MethodGet, "/items/{item_id}/history", authMiddleware.WithSession(vaultController.HandleListItemVersions))
 	vault.Handle(http.MethodPut, "/items/{item_id}", authMiddleware.WithSession(vaultController.HandleUpdateItem))
 	vault.Handle(http.MethodPost, "/items/{item_id}/restore", authMiddleware.WithSession(vaultController.HandleRestoreItem))
 	vault.Handle(http.MethodDelete, "/items/{item_id}", authMiddleware.WithSession(vaultController.HandleDeleteItem))
+	vaultLong.Handle(http.MethodPost, "/items/{item_id}/attachments", authMiddleware.WithSession(heavy.Limit(attachmentController.HandleUploadAttachment)))
+	vault.Handle(http.MethodGet, "/items/{item_id}/attachments", authMiddleware.WithSession(attachmentController.HandleListAttachments))
+	vaultLong.Handle(http.MethodGet, "/items/{item_id}/attachments/{attachment_id}", authMiddleware.WithSession(heavy.Limit(attachmentController.HandleDownloadAttachment)))
+	vault.Handle(http.MethodGet, "/items/{item_id}/attachments/{attachment_id}/url", authMiddleware.WithSession(attachmentController.HandleAttachmentDownloadURL))
+	vault.Handle(http.MethodDelete, "/items/{item_id}/attachments/{attachment_id}", authMiddleware.WithSession(attachmentController.HandleDeleteAttachment))
+	vaultLong.Handle(http.MethodGet, "/backups/{export_id}/diff", authMiddleware.WithSession(heavy.Limit(dataExportController.HandleDiffExport)))
+	vaultLong.Handle(http.MethodGet, "/export", authMiddleware.WithSession(heavy.Limit(vaultController.HandleExport)), exportLimiter.Middleware)
+
+	// Browser extension: URI matching and autofill usage
+	vault.Handle(http.MethodPost, "/items/match", authMiddleware.WithSession(vaultController.HandleMatchItems))
+	vault.Handle(http.MethodPost, "/items/{item_id}/used", authMiddleware.WithSession(vaultController.HandleRecordItemUse))
 
 	// Sharing routes
 	vault.Handle(http.MethodGet, "/shared", authMiddleware.WithSession(sharingController.HandleListSharedWithMe))
 	vault.Handle(http.MethodGet, "/shared/sent", authMiddleware.WithSession(sharingController.HandleListSentShares))
 	vault.Handle(http.MethodPost, "/items/{item_id}/shares", authMiddleware.WithSession(sharingController.HandleShareItem))
 	vault.Handle(http.MethodGet, "/items/{item_id}/shares", authMiddleware.WithSession(sharingController.HandleListSharesForItem))
+	vault.Handle(http.MethodPatch, "/items/{item_id}/shares/{user_id}", authMiddleware.WithSession(sharingController.HandleUpdateSharePermissions))
 	vault.Handle(http.MethodDelete, "/items/{item_id}/shares/{user_id}", authMiddleware.WithSession(sharingController.HandleRevokeShare))
+	vault.Handle(http.MethodGet, "/items/{item_id}/access-alert", authMiddleware.WithSession(sharingController.HandleGetAccessAlert))
+	vault.Handle(http.MethodPut, "/items/{item_id}/access-alert", authMiddleware.WithSession(sharingController.HandleSetAccessAlert))
+	vault.Handle(http.MethodDelete, "/items/{item_id}/access-alert", authMiddleware.WithSession(sharingController.HandleDeleteAccessAlert))
+	vault.Handle(http.MethodPost, "/items/{item_id}/access-requests", authMiddleware.WithSession(accessRequestController.HandleRequestAccess))
+	vault.Handle(http.MethodGet, "/access-requests", authMiddleware.WithSession(accessRequestController.HandleListPending))
+	vault.Handle(http.MethodGet, "/access-requests/mine", authMiddleware.WithSession(accessRequestController.HandleListMine))
+	vault.Handle(http.MethodPost, "/access-requests/{request_id}/approve", authMiddleware.WithSession(accessRequestController.HandleApprove))
+	vault.Handle(http.MethodPost, "/access-requests/{request_id}/deny", authMiddleware.WithSession(accessRequestController.HandleDeny))
+	vault.Handle(http.MethodPost, "/items/{item_id}/share-invitations", authMiddleware.WithSession(shareInvitationController.HandleInvite))
+	vault.Handle(http.MethodGet, "/share-invitations", authMiddleware.WithSession(shareInvitationController.HandleList))
+	vault.Handle(http.MethodDelete, "/share-invitations/{invitation_id}", authMiddleware.WithSession(shareInvitationController.HandleCancel))
+	vault.Handle(http.MethodPost, "/share-invitations/{invitation_id}/complete", authMiddleware.WithSession(shareInvitationController.HandleComplete))
+
+	// Items published for CI pipelines
+	vault.Handle(http.MethodGet, "/machine-secrets", authMiddleware.WithSession(machineSecretController.HandleListSecrets))
+	vault.Handle(http.MethodPut, "/items/{item_id}/machine-secret", authMiddleware.WithSession(machineSecretController.HandlePublishSecret))
+	vault.Handle(http.MethodDelete, "/items/{item_id}/machine-secret", authMiddleware.WithSession(machineSecretController.HandleUnpublishSecret))
 
-	// User keys routes
+	// Machine secrets, read by service accounts with an API key instead of
+	// a session
+	secrets.Handle(http.MethodGet, "/{name}", machineSecretController.HandleReadSecret)
+
+	// User keys routes; /keys is the same API under its own prefix
 	users.Handle(http.MethodPut, "/keys", authMiddleware.WithSession(sharingController.HandleUpsertKeys))
 	users.Handle(http.MethodGet, "/keys", authMiddleware.WithSession(sharingController.HandleGetMyKeys))
 	users.Handle(http.MethodGet, "/keys/lookup", authMiddleware.WithSession(sharingController.HandleGetPublicKey))
+	users.Handle(http.MethodGet, "/{user_id}/key-fingerprint", authMiddleware.WithSession(sharingController.HandleGetKeyFingerprint))
+	keys.Handle(http.MethodPost, "", authMiddleware.WithSession(sharingController.HandleUpsertKeys))
+	keys.Handle(http.MethodGet, "", authMiddleware.WithSession(sharingController.HandleGetMyKeys))
+	keys.Handle(http.MethodGet, "/lookup", authMiddleware.WithSession(sharingController.HandleGetPublicKey))
 
 	// Family routes
 	family.Handle(http.MethodPost, "/request", authMiddleware.WithSession(familyController.HandleSendRequest))
@@ -163,13 +414,101 @@ func NewRouter(cfg config.Config, logger *slog.Logger, auditService *service.Aud
 	audit.Handle(http.MethodGet, "/summary", authMiddleware.WithSession(auditController.HandleGetSummary))
 	audit.Handle(http.MethodDelete, "", authMiddleware.WithSession(auditController.HandleClearLogs))
 
-	root.Handle("", "/", func(w http.ResponseWriter, r *http.Request) {
-		util.WriteJSON(w, http.StatusNotFound, dto.ErrorResponse{Error: "not_found", Message: "route not found"})
-	})
+	// Webhook routes
+	webhooks.Handle(http.MethodPost, "", authMiddleware.WithSession(webhookController.HandleCreateWebhook))
+	webhooks.Handle(http.MethodGet, "", authMiddleware.WithSession(webhookController.HandleListWebhooks))
+	webhooks.Handle(http.MethodDelete, "/{webhook_id}", authMiddleware.WithSession(webhookController.HandleDeleteWebhook))
+	webhooks.Handle(http.MethodGet, "/{webhook_id}/deliveries", authMiddleware.WithSession(webhookController.HandleListDeliveries))
+	webhooks.Handle(http.MethodPost, "/{webhook_id}/deliveries/{delivery_id}/redeliver", authMiddleware.WithSession(webhookController.HandleRedeliver))
+
+	// Admin routes
+	adminV1.Handle(http.MethodGet, "/audit", authMiddleware.WithAdminSession(auditController.HandleAdminGetLogs))
+	adminV1.Handle(http.MethodGet, "/stats", authMiddleware.WithAdminSession(adminController.HandleGetStats))
+	adminV1.Handle(http.MethodGet, "/users", authMiddleware.WithAdminSession(adminController.HandleSearchUsers))
+	adminV1.Handle(http.MethodGet, "/users/{user_id}", authMiddleware.WithAdminSession(adminController.HandleGetUser))
+	adminV1.Handle(http.MethodGet, "/users/{user_id}/sessions", authMiddleware.WithAdminSession(adminController.HandleListSessions))
+	adminV1.Handle(http.MethodDelete, "/users/{user_id}/sessions", authMiddleware.WithAdminSession(adminController.HandleRevokeSessions))
+	adminV1.Handle(http.MethodDelete, "/users/{user_id}/sessions/{session_id}", authMiddleware.WithAdminSession(adminController.HandleRevokeSessions))
+	adminV1.Handle(http.MethodDelete, "/users/{user_id}/lockout", authMiddleware.WithAdminSession(adminController.HandleClearLockout))
+	adminV1.Handle(http.MethodPost, "/users/{user_id}/credentials-compromised", authMiddleware.WithAdminSession(adminController.HandleFlagCredentialsCompromised))
+	adminV1.Handle(http.MethodPost, "/users/{user_id}/erase", authMiddleware.WithAdminSession(adminController.HandleEraseUser))
+	adminV1.Handle(http.MethodGet, "/users/{user_id}/erasure-report", authMiddleware.WithAdminSession(adminController.HandleErasureReport))
+	adminV1.Handle(http.MethodGet, "/feature-flags", authMiddleware.WithAdminSession(adminController.HandleListFeatureFlags))
+	adminV1.Handle(http.MethodPut, "/feature-flags/{key}", authMiddleware.WithAdminSession(adminController.HandleSetFeatureFlag))
+	adminV1.Handle(http.MethodGet, "/maintenance", authMiddleware.WithAdminSession(adminController.HandleGetMaintenance))
+	adminV1.Handle(http.MethodPut, "/maintenance", authMiddleware.WithAdminSession(adminController.HandleSetMaintenance))
+	adminV1.Handle(http.MethodGet, "/ip-bans", authMiddleware.WithAdminSession(ipBanController.HandleListBans))
+	adminV1.Handle(http.MethodDelete, "/ip-bans/{ip}", authMiddleware.WithAdminSession(ipBanController.HandleLiftBan))
+
+	notFound := func(w http.ResponseWriter, r *http.Request) {
+		util.WriteError(w, http.StatusNotFound, "not_found", "route not found")
+	}
+	root.Handle("", "/", notFound)
+
+	// Maintenance mode spares health checks, the admin API and sign-in, so
+	// admins can still reach the switch.
+	maintenance := middlewares.Maintenance(adminService.Maintenance, cfg.MaintenanceRetryAfter, "/healthz", "/version", "/admin/", apiV1.Prefix+"/auth/login", apiV2.Prefix+"/auth/login")
+	var handler http.Handler = globalLimiter.Handler(maintenance(mux), cfg.RateLimitExemptPaths...)
+	// Banned addresses are refused before they cost a rate limit token; the
+	// ban check sits outside the limiters so their 429s count as strikes.
+	// The admin API stays reachable so a banned admin can lift the ban.
+	if cfg.IPBanEnabled {
+		handler = middlewares.BanIPs(ipBanService, "/healthz", "/admin/")(handler)
+	}
+	handler = middlewares.WithLanguage(messages)(middlewares.WithRequestMeta(cfg.TrustedProxies)(middlewares.RequestLogger(logger)(middlewares.Recover(logger)(handler))))
+	if cfg.CompressionEnabled {
+		handler = middlewares.Compress(cfg.CompressionMinBytes, handler)
+	}
+
+	securityHeaders := middlewares.SecurityHeaders{
+		ContentSecurityPolicy: cfg.SecurityCSP,
+		ReferrerPolicy:        cfg.ReferrerPolicy,
+		PermissionsPolicy:     cfg.PermissionsPolicy,
+		RouteCSP:              cfg.SecurityRouteCSP,
+	}
+	hsts := middlewares.HSTSValue(cfg.HSTSMaxAge, cfg.HSTSIncludeSubdomains, cfg.HSTSPreload)
+	apiHeaders := securityHeaders
+	if cfg.TLSEnabled() {
+		apiHeaders.StrictTransportSecurity = hsts
+	}
+	handler = middlewares.WithSecurityHeaders(apiHeaders)(handler)
+
+	corsPolicy := middlewares.CORSPolicy{Origins: cfg.CORSAllowedOrigins, AnonymousOrigins: cfg.CORSAnonymousOrigins}
+	handlers := Handlers{API: middlewares.CORS(corsPolicy, handler)}
+	if cfg.OpsEnabled() {
+		ops.Handle(http.MethodGet, "/healthz", func(w http.ResponseWriter, r *http.Request) {
+			util.WriteJSON(w, http.StatusOK, dto.HealthResponse{Status: "ok", Service: "pmv2-ops", Time: time.Now().UTC().Format(time.RFC3339), Env: cfg.Env, Version: build.Version, Commit: build.Commit})
+		})
+		ops.Handle(http.MethodGet, "/metrics", metrics.Handler().ServeHTTP)
+		// Profiles run for as long as the caller asks and stream their output.
+		debug := ops.WithTimeout(0)
+		debug.Handle("", "/debug/pprof/", pprof.Index)
+		debug.Handle("", "/debug/pprof/cmdline", pprof.Cmdline)
+		debug.Handle("", "/debug/pprof/profile", pprof.Profile)
+		debug.Handle("", "/debug/pprof/symbol", pprof.Symbol)
+		debug.Handle("", "/debug/pprof/trace", pprof.Trace)
+		ops.Handle("", "/", notFound)
 
-	return middlewares.CORS(cfg.FrontendOrigin, middlewares.WithSecurityHeaders(
-		middlewares.RequestLogger(logger)(mux),
-	))
+		var opsHandler http.Handler = middlewares.WithRequestMeta(cfg.TrustedProxies)(middlewares.RequestLogger(logger)(middlewares.Recover(logger)(opsMux)))
+		opsHeaders := securityHeaders
+		opsHeaders.StrictTransportSecurity = hsts
+		opsHandler = middlewares.WithSecurityHeaders(opsHeaders)(opsHandler)
+		handlers.Ops = middlewares.CORS(corsPolicy, opsHandler)
+	}
+
+	return handlers
+}
+
+func apiVersionToResponse(version middlewares.APIVersion) dto.APIVersionResponse {
+	resp := dto.APIVersionResponse{Version: version.Version, Prefix: version.Prefix, Status: "supported"}
+	if version.Deprecated() {
+		resp.Status = "deprecated"
+		resp.DeprecatedAt = version.DeprecatedAt.UTC().Format(time.RFC3339)
+	}
+	if !version.Sunset.IsZero() {
+		resp.Sunset = version.Sunset.UTC().Format(time.RFC3339)
+	}
+	return resp
 }
 
 func joinPath(prefix string, path string) string {
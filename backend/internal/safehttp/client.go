@@ -0,0 +1,149 @@
+// Package safehttp is the HTTP client for requests to URLs users supply,
+// such as webhook endpoints. It only connects to public addresses, checked
+// on the address actually dialled so DNS rebinding cannot slip past a check
+// made earlier, reads at most a set number of response bytes, and meters
+// requests per user so one account cannot turn the server into a traffic
+// source.
+package safehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+var (
+	// ErrQuotaExceeded is returned by Do when the key is out of requests.
+	ErrQuotaExceeded = errors.New("outbound request quota exceeded")
+	// ErrResponseTooLarge is returned by Do for a response announcing more
+	// than the limit, and by the body once more than the limit is read.
+	ErrResponseTooLarge = errors.New("response exceeds the size limit")
+)
+
+// QuotaStore decides whether key may make another request. The rate limit
+// stores of the middlewares package satisfy it, so a Redis store shares
+// quotas across replicas.
+type QuotaStore interface {
+	Allow(ctx context.Context, key string, limit rate.Limit, burst int) (bool, error)
+}
+
+type Config struct {
+	// Timeout bounds each request, redirects and reading the body included.
+	Timeout time.Duration
+	// MaxResponseBytes caps the response body; 0 leaves it unbounded.
+	MaxResponseBytes int64
+	// MaxRedirects is how many redirects are followed, each through the
+	// same address check; 0 follows none and returns the redirect.
+	MaxRedirects int
+	// AllowPrivateNetworks lets requests reach loopback and private
+	// addresses; it is meant for local development only.
+	AllowPrivateNetworks bool
+	// Quota meters requests per key: QuotaBurst at once, refilled at
+	// QuotaRate per second. A nil store or a zero rate or burst disables
+	// it. QuotaScope namespaces the keys in a shared store.
+	Quota      QuotaStore
+	QuotaRate  rate.Limit
+	QuotaBurst int
+	QuotaScope string
+}
+
+// Client sends requests to user-supplied URLs. It is safe for concurrent
+// use.
+type Client struct {
+	cfg    Config
+	client *http.Client
+}
+
+func NewClient(cfg Config) *Client {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.QuotaScope == "" {
+		cfg.QuotaScope = "outbound"
+	}
+	maxRedirects := cfg.MaxRedirects
+	return &Client{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			// No proxy: the dialer's address check must see the host
+			// itself.
+			Transport: &http.Transport{
+				DialContext:         NewDialer(cfg.Timeout, cfg.AllowPrivateNetworks).DialContext,
+				ForceAttemptHTTP2:   true,
+				MaxIdleConns:        100,
+				IdleConnTimeout:     90 * time.Second,
+				TLSHandshakeTimeout: 10 * time.Second,
+			},
+			CheckRedirect: func(_ *http.Request, via []*http.Request) error {
+				if len(via) > maxRedirects {
+					return http.ErrUseLastResponse
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// Do sends req on behalf of key, usually the ID of the user who supplied
+// the URL; an empty key is not metered. The caller closes the body as with
+// http.Client.Do.
+func (c *Client) Do(key string, req *http.Request) (*http.Response, error) {
+	if err := c.allow(req.Context(), key); err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if limit := c.cfg.MaxResponseBytes; limit > 0 {
+		if resp.ContentLength > limit {
+			resp.Body.Close()
+			return nil, ErrResponseTooLarge
+		}
+		resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: limit}
+	}
+	return resp, nil
+}
+
+func (c *Client) allow(ctx context.Context, key string) error {
+	if key == "" || c.cfg.Quota == nil || c.cfg.QuotaRate <= 0 || c.cfg.QuotaBurst <= 0 {
+		return nil
+	}
+	allowed, err := c.cfg.Quota.Allow(ctx, c.cfg.QuotaScope+":"+key, c.cfg.QuotaRate, c.cfg.QuotaBurst)
+	if err != nil {
+		return fmt.Errorf("check outbound quota: %w", err)
+	}
+	if !allowed {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// limitedBody fails with ErrResponseTooLarge rather than stopping quietly
+// at the limit, so a truncated response is never mistaken for a whole one.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	// One byte past the limit tells a body of exactly the limit from a
+	// larger one.
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = 0
+		return n, ErrResponseTooLarge
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,111 @@
+package safehttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+// countingQuota allows burst requests per key and never refills.
+type countingQuota map[string]int
+
+func (q countingQuota) Allow(_ context.Context, key string, _ rate.Limit, burst int) (bool, error) {
+	q[key]++
+	return q[key] <= burst, nil
+}
+
+func get(t *testing.T, c *Client, key, url string) (string, error) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	resp, err := c.Do(key, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestClientCapsResponseSize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Flushing first leaves the length unannounced.
+		if r.URL.Query().Has("chunked") {
+			w.(http.Flusher).Flush()
+		}
+		_, _ = io.WriteString(w, strings.Repeat("x", 10))
+	}))
+	defer srv.Close()
+
+	for limit, wantErr := range map[int64]error{9: ErrResponseTooLarge, 10: nil} {
+		c := NewClient(Config{AllowPrivateNetworks: true, MaxResponseBytes: limit})
+		for _, url := range []string{srv.URL, srv.URL + "?chunked"} {
+			body, err := get(t, c, "", url)
+			if !errors.Is(err, wantErr) {
+				t.Errorf("limit %d, %s: err = %v, want %v", limit, url, err, wantErr)
+			}
+			if err == nil && len(body) != 10 {
+				t.Errorf("limit %d, %s: body = %q", limit, url, body)
+			}
+		}
+	}
+}
+
+func TestClientQuotaPerKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+	quota := countingQuota{}
+	c := NewClient(Config{AllowPrivateNetworks: true, Quota: quota, QuotaRate: 1, QuotaBurst: 2})
+
+	for i := range 2 {
+		if _, err := get(t, c, "ada", srv.URL); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if _, err := get(t, c, "ada", srv.URL); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("third request: err = %v, want ErrQuotaExceeded", err)
+	}
+	if _, err := get(t, c, "bob", srv.URL); err != nil {
+		t.Fatalf("another key: %v", err)
+	}
+	if quota["outbound:ada"] != 3 {
+		t.Fatalf("quota keys = %v", quota)
+	}
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	hit := false
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { hit = true }))
+	defer srv.Close()
+
+	if _, err := get(t, NewClient(Config{}), "", srv.URL); !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("err = %v, want ErrForbiddenAddress", err)
+	}
+	if hit {
+		t.Fatal("request reached a loopback server")
+	}
+}
+
+func TestClientRedirects(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "moved")
+	}))
+	defer target.Close()
+	srv := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	defer srv.Close()
+
+	if body, err := get(t, NewClient(Config{AllowPrivateNetworks: true}), "", srv.URL); err != nil || body == "moved" {
+		t.Fatalf("no redirects: body = %q, err = %v; want the redirect itself", body, err)
+	}
+	if body, err := get(t, NewClient(Config{AllowPrivateNetworks: true, MaxRedirects: 1}), "", srv.URL); err != nil || body != "moved" {
+		t.Fatalf("one redirect: body = %q, err = %v", body, err)
+	}
+}
@@ -0,0 +1,91 @@
+package safehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned for hosts that resolve to loopback,
+// private, link-local (including cloud metadata) or otherwise non-public
+// addresses. Without this check a user-supplied URL could point at
+// services on the API's own network.
+var ErrForbiddenAddress = errors.New("host resolves to a non-public address")
+
+// nonPublic lists special-purpose ranges not covered by the netip.Addr
+// predicates used in PublicAddr.
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // TEST-NET-1
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // TEST-NET-2
+	netip.MustParsePrefix("203.0.113.0/24"),  // TEST-NET-3
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, may embed any IPv4
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+}
+
+// PublicAddr reports whether a user-supplied URL may reach addr.
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || addr.IsLoopback() ||
+		addr.IsLinkLocalUnicast() || addr.IsUnspecified() {
+		return false
+	}
+	for _, prefix := range nonPublic {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckHost resolves host (a name or an IP literal) and fails if any of its
+// addresses is not public. It is the check made when a URL is saved; the
+// dialer built by NewDialer repeats it for the address actually connected
+// to, which defeats DNS rebinding.
+func CheckHost(ctx context.Context, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !PublicAddr(addr) {
+			return ErrForbiddenAddress
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("resolve host: %w", err)
+	}
+	for _, addr := range addrs {
+		if !PublicAddr(addr) {
+			return ErrForbiddenAddress
+		}
+	}
+	return nil
+}
+
+// NewDialer returns a dialer that refuses connections to non-public
+// addresses unless allowPrivate is set (local development only).
+func NewDialer(timeout time.Duration, allowPrivate bool) *net.Dialer {
+	d := &net.Dialer{Timeout: timeout}
+	if allowPrivate {
+		return d
+	}
+	d.Control = func(network, address string, _ syscall.RawConn) error {
+		addrPort, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return fmt.Errorf("parse dial address: %w", err)
+		}
+		if !PublicAddr(addrPort.Addr()) {
+			return ErrForbiddenAddress
+		}
+		return nil
+	}
+	return d
+}
@@ -0,0 +1,56 @@
+package safehttp
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestPublicAddr(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34":        true,
+		"2606:2800:220:1::":    true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false, // cloud metadata
+		"fd00:ec2::254":        false, // AWS IPv6 metadata
+		"fe80::1":              false,
+		"0.0.0.0":              false,
+		"100.64.0.1":           false,
+		"224.0.0.1":            false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:10.0.0.1":      false,
+		"64:ff9b::a00:1":       false,
+		"255.255.255.255":      false,
+		"::ffff:93.184.216.34": true,
+	}
+	for raw, want := range cases {
+		if got := PublicAddr(netip.MustParseAddr(raw)); got != want {
+			t.Errorf("PublicAddr(%s) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	ctx := context.Background()
+	for _, host := range []string{"127.0.0.1", "169.254.169.254", "::1", "localhost"} {
+		if err := CheckHost(ctx, host); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("CheckHost(%s) = %v, want ErrForbiddenAddress", host, err)
+		}
+	}
+	if err := CheckHost(ctx, "93.184.216.34"); err != nil {
+		t.Errorf("CheckHost(public literal) = %v", err)
+	}
+}
+
+func TestDialerRefusesPrivateAddresses(t *testing.T) {
+	_, err := NewDialer(time.Second, false).DialContext(context.Background(), "tcp", "127.0.0.1:9")
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("dial loopback = %v, want ErrForbiddenAddress", err)
+	}
+}
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+)
+
+// AccessRequestService lets family members ask for a share of an item and
+// the item's approvers grant or refuse it. Every step is recorded in the
+// audit log; the request event names the approvers, which is how the push
+// service knows whom to notify.
+type AccessRequestService struct {
+	shares domain.SharingRepository
+	family domain.FamilyRepository
+	tx     domain.Transactor
+	audit  *AuditService
+	now    func() time.Time
+}
+
+func NewAccessRequestService(shares domain.SharingRepository, family domain.FamilyRepository, tx domain.Transactor, audit *AuditService) *AccessRequestService {
+	return &AccessRequestService{shares: shares, family: family, tx: tx, audit: audit, now: time.Now}
+}
+
+// ApproveAccessInput is the share an approver grants. DEKWrapped and
+// WrapNonce are the item key wrapped for the requester by the approver's
+// client.
+type ApproveAccessInput struct {
+	DEKWrapped  []byte
+	WrapNonce   []byte
+	Permissions string
+	Window      domain.ShareWindow
+}
+
+// RequestAccess records userID's request for a share of itemID. Only family
+// members of the owner may ask; to anyone else the item does not exist.
+func (s *AccessRequestService) RequestAccess(ctx context.Context, userID, itemID, reason string) (domain.AccessRequest, error) {
+	if strings.TrimSpace(userID) == "" {
+		return domain.AccessRequest{}, domain.ErrUnauthorizedSession
+	}
+	reason = strings.TrimSpace(reason)
+	if utf8.RuneCountInString(reason) > domain.MaxAccessRequestReasonLength {
+		return domain.AccessRequest{}, domain.ErrInvalidAccessReason
+	}
+	itemID = strings.TrimSpace(itemID)
+	if _, err := uuid.Parse(itemID); err != nil {
+		return domain.AccessRequest{}, domain.ErrNotFound
+	}
+
+	ownerID, err := s.shares.GetItemOwner(ctx, itemID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.AccessRequest{}, domain.ErrNotFound
+		}
+		return domain.AccessRequest{}, fmt.Errorf("get item owner: %w", err)
+	}
+	if ownerID == userID {
+		return domain.AccessRequest{}, domain.ErrAlreadyHasAccess
+	}
+	isMember, err := s.family.IsFamilyMember(ctx, ownerID, userID)
+	if err != nil {
+		return domain.AccessRequest{}, fmt.Errorf("check family membership: %w", err)
+	}
+	if !isMember {
+		return domain.AccessRequest{}, domain.ErrNotFound
+	}
+	if _, err := s.shares.GetShare(ctx, itemID, userID); err == nil {
+		return domain.AccessRequest{}, domain.ErrAlreadyHasAccess
+	} else if !errors.Is(err, domain.ErrShareNotFound) {
+		return domain.AccessRequest{}, fmt.Errorf("check existing share: %w", err)
+	}
+
+	mine, err := s.shares.ListAccessRequestsByRequester(ctx, userID)
+	if err != nil {
+		return domain.AccessRequest{}, fmt.Errorf("list access requests: %w", err)
+	}
+	for _, req := range mine {
+		if req.ItemID == itemID && req.Status == domain.AccessRequestPending {
+			return domain.AccessRequest{}, domain.ErrAccessRequestPending
+		}
+	}
+
+	req, err := s.shares.CreateAccessRequest(ctx, domain.AccessRequest{
+		ID:              uuid.NewString(),
+		ItemID:          itemID,
+		OwnerUserID:     ownerID,
+		RequesterUserID: userID,
+		Reason:          reason,
+	})
+	if err != nil {
+		return domain.AccessRequest{}, fmt.Errorf("create access request: %w", err)
+	}
+
+	approvers, err := s.approvers(ctx, req)
+	if err != nil {
+		return domain.AccessRequest{}, err
+	}
+	uid, _ := uuid.Parse(userID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeSharingAccessRequested, map[string]interface{}{
+		"request_id":   req.ID,
+		"item_id":      itemID,
+		"owner_id":     ownerID,
+		"approver_ids": approvers,
+	})
+	return req, nil
+}
+
+// ListMyRequests returns the user's own requests, newest first.
+func (s *AccessRequestService) ListMyRequests(ctx context.Context, userID string) ([]domain.AccessRequest, error) {
+	if strings.TrimSpace(userID) == "" {
+		return nil, domain.ErrUnauthorizedSession
+	}
+	requests, err := s.shares.ListAccessRequestsByRequester(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list access requests: %w", err)
+	}
+	return requests, nil
+}
+
+// ListPendingForApprover returns the pending requests the user may decide,
+// oldest first.
+func (s *AccessRequestService) ListPendingForApprover(ctx context.Context, userID string) ([]domain.AccessRequest, error) {
+	if strings.TrimSpace(userID) == "" {
+		return nil, domain.ErrUnauthorizedSession
+	}
+	requests, err := s.shares.ListPendingAccessRequestsForApprover(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list pending access requests: %w", err)
+	}
+	// A manage share outside its window does not let its holder decide.
+	decidable := requests[:0]
+	for _, req := range requests {
+		ok, err := s.canDecide(ctx, userID, req)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			decidable = append(decidable, req)
+		}
+	}
+	return decidable, nil
+}
+
+// Approve grants the request with the share input describes, shared by the
+// approver. Only the owner may grant SharePermissionManage.
+func (s *AccessRequestService) Approve(ctx context.Context, userID, requestID string, input ApproveAccessInput) (domain.AccessRequest, error) {
+	req, err := s.decidable(ctx, userID, requestID)
+	if err != nil {
+		return domain.AccessRequest{}, err
+	}
+	if len(input.DEKWrapped) == 0 || len(input.WrapNonce) == 0 {
+		return domain.AccessRequest{}, domain.ErrInvalidVaultPayload
+	}
+	switch input.Permissions {
+	case "":
+		input.Permissions = domain.SharePermissionRead
+	case domain.SharePermissionManage:
+		if userID != req.OwnerUserID {
+			return domain.AccessRequest{}, domain.ErrNotItemOwner
+		}
+	}
+	now := s.now()
+	window, err := normalizeShareWindow(input.Window, now)
+	if err != nil {
+		return domain.AccessRequest{}, err
+	}
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.shares.DecideAccessRequest(ctx, req.ID, domain.AccessRequestApproved, userID, now); err != nil {
+			return err
+		}
+		return s.shares.CreateShare(ctx, domain.ShareItemInput{
+			ItemID:         req.ItemID,
+			RecipientID:    req.RequesterUserID,
+			SharedByUserID: userID,
+			DEKWrapped:     input.DEKWrapped,
+			WrapNonce:      input.WrapNonce,
+			Permissions:    input.Permissions,
+			Window:         window,
+		})
+	})
+	if err != nil {
+		if errors.Is(err, domain.ErrAccessRequestDecided) || errors.Is(err, domain.ErrAlreadyShared) {
+			return domain.AccessRequest{}, err
+		}
+		return domain.AccessRequest{}, fmt.Errorf("approve access request: %w", err)
+	}
+	req.Status, req.DecidedByUserID, req.DecidedAt = domain.AccessRequestApproved, userID, &now
+
+	uid, _ := uuid.Parse(userID)
+	share := map[string]interface{}{
+		"item_id":     req.ItemID,
+		"friend_id":   req.RequesterUserID,
+		"permissions": input.Permissions,
+		"request_id":  req.ID,
+	}
+	if window.ExpiresAt != nil {
+		share["expires_at"] = window.ExpiresAt.Format(time.RFC3339)
+	}
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeSharingItemShared, share)
+	s.logDecision(ctx, req, domain.EventTypeSharingAccessApproved)
+	return req, nil
+}
+
+// Deny refuses the request.
+func (s *AccessRequestService) Deny(ctx context.Context, userID, requestID string) (domain.AccessRequest, error) {
+	req, err := s.decidable(ctx, userID, requestID)
+	if err != nil {
+		return domain.AccessRequest{}, err
+	}
+	now := s.now()
+	if err := s.shares.DecideAccessRequest(ctx, req.ID, domain.AccessRequestDenied, userID, now); err != nil {
+		if errors.Is(err, domain.ErrAccessRequestDecided) {
+			return domain.AccessRequest{}, err
+		}
+		return domain.AccessRequest{}, fmt.Errorf("deny access request: %w", err)
+	}
+	req.Status, req.DecidedByUserID, req.DecidedAt = domain.AccessRequestDenied, userID, &now
+	s.logDecision(ctx, req, domain.EventTypeSharingAccessDenied)
+	return req, nil
+}
+
+// decidable loads a pending request userID may decide. Requests the user
+// cannot see at all are reported as not found.
+func (s *AccessRequestService) decidable(ctx context.Context, userID, requestID string) (domain.AccessRequest, error) {
+	if strings.TrimSpace(userID) == "" {
+		return domain.AccessRequest{}, domain.ErrUnauthorizedSession
+	}
+	requestID = strings.TrimSpace(requestID)
+	if _, err := uuid.Parse(requestID); err != nil {
+		return domain.AccessRequest{}, domain.ErrAccessRequestNotFound
+	}
+	req, err := s.shares.GetAccessRequest(ctx, requestID)
+	if err != nil {
+		if errors.Is(err, domain.ErrAccessRequestNotFound) {
+			return domain.AccessRequest{}, domain.ErrAccessRequestNotFound
+		}
+		return domain.AccessRequest{}, fmt.Errorf("get access request: %w", err)
+	}
+	ok, err := s.canDecide(ctx, userID, req)
+	if err != nil {
+		return domain.AccessRequest{}, err
+	}
+	if !ok {
+		if req.RequesterUserID == userID {
+			return domain.AccessRequest{}, domain.ErrNotAccessApprover
+		}
+		return domain.AccessRequest{}, domain.ErrAccessRequestNotFound
+	}
+	if req.Status != domain.AccessRequestPending {
+		return domain.AccessRequest{}, domain.ErrAccessRequestDecided
+	}
+	return req, nil
+}
+
+// canDecide reports whether userID is one of the request's approvers: the
+// owner, or a recipient whose manage share grants access right now.
+func (s *AccessRequestService) canDecide(ctx context.Context, userID string, req domain.AccessRequest) (bool, error) {
+	if userID == req.OwnerUserID {
+		return true, nil
+	}
+	share, err := s.shares.GetShare(ctx, req.ItemID, userID)
+	if errors.Is(err, domain.ErrShareNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("get approver share: %w", err)
+	}
+	return share.Permissions == domain.SharePermissionManage && share.Window.Allows(s.now()), nil
+}
+
+// approvers lists the users who may decide req, owner first.
+func (s *AccessRequestService) approvers(ctx context.Context, req domain.AccessRequest) ([]string, error) {
+	shares, err := s.shares.ListSharesByItem(ctx, req.ItemID)
+	if err != nil {
+		return nil, fmt.Errorf("list item shares: %w", err)
+	}
+	approvers := []string{req.OwnerUserID}
+	now := s.now()
+	for _, share := range shares {
+		if share.Permissions == domain.SharePermissionManage && share.Window.Allows(now) {
+			approvers = append(approvers, share.UserID)
+		}
+	}
+	return approvers, nil
+}
+
+// logDecision records the decision for the approver and, when a manager
+// decided, for the owner too, so the owner's log shows every grant of their
+// item.
+func (s *AccessRequestService) logDecision(ctx context.Context, req domain.AccessRequest, eventType domain.EventType) {
+	data := map[string]interface{}{
+		"request_id":   req.ID,
+		"item_id":      req.ItemID,
+		"requester_id": req.RequesterUserID,
+		"decided_by":   req.DecidedByUserID,
+	}
+	uid, _ := uuid.Parse(req.DecidedByUserID)
+	s.audit.LogEvent(ctx, &uid, eventType, data)
+	if req.DecidedByUserID != req.OwnerUserID {
+		owner, _ := uuid.Parse(req.OwnerUserID)
+		s.audit.LogEvent(ctx, &owner, eventType, data)
+	}
+}
@@ -0,0 +1,144 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestAccessRequests(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	auth := service.NewAuthService(store.Auth(), store.Transactor(), nil, "pepper", time.Hour, "pmv2")
+	vault := service.NewVaultService(store.Vault(), store.Folders(), store.Transactor(), nil)
+	sharing := service.NewSharingService(store.Sharing(), store.UserKeys(), store.Vault(), store.Family(), audit)
+	family := service.NewFamilyService(store.Family(), store.Auth(), sharing, nil)
+	requests := service.NewAccessRequestService(store.Sharing(), store.Family(), store.Transactor(), audit)
+
+	register := func(email, name string) string {
+		t.Helper()
+		user, err := auth.Register(ctx, email, "Correct-Horse-9", name)
+		if err != nil {
+			t.Fatalf("register %s: %v", email, err)
+		}
+		return user.UserID
+	}
+	befriend := func(a, b, bEmail string) {
+		t.Helper()
+		if err := family.SendRequest(ctx, a, bEmail); err != nil {
+			t.Fatalf("SendRequest: %v", err)
+		}
+		if err := family.AcceptRequest(ctx, b, a); err != nil {
+			t.Fatalf("AcceptRequest: %v", err)
+		}
+	}
+	owner := register("ada@example.com", "Ada")
+	manager := register("bob@example.com", "Bob")
+	member := register("cy@example.com", "Cy")
+	stranger := register("dee@example.com", "Dee")
+	befriend(owner, manager, "bob@example.com")
+	befriend(owner, member, "cy@example.com")
+
+	item, err := vault.CreateItem(ctx, owner, domain.CreateVaultItemInput{
+		Ciphertext: []byte("c"), Nonce: []byte("n"), WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"),
+		AlgoVersion: "xchacha20poly1305-v1",
+	})
+	if err != nil {
+		t.Fatalf("CreateItem: %v", err)
+	}
+	err = sharing.ShareItem(ctx, owner, item.ID, domain.ShareItemInput{
+		RecipientID: manager, DEKWrapped: []byte("dek"), WrapNonce: []byte("wn"), Permissions: domain.SharePermissionManage,
+	})
+	if err != nil {
+		t.Fatalf("ShareItem(manage): %v", err)
+	}
+
+	if _, err := requests.RequestAccess(ctx, stranger, item.ID, ""); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("RequestAccess(stranger) err = %v, want ErrNotFound", err)
+	}
+	if _, err := requests.RequestAccess(ctx, manager, item.ID, ""); !errors.Is(err, domain.ErrAlreadyHasAccess) {
+		t.Fatalf("RequestAccess(recipient) err = %v, want ErrAlreadyHasAccess", err)
+	}
+	req, err := requests.RequestAccess(ctx, member, item.ID, "need the wifi password")
+	if err != nil {
+		t.Fatalf("RequestAccess: %v", err)
+	}
+	if req.Status != domain.AccessRequestPending || req.OwnerUserID != owner || req.RequesterEmail != "cy@example.com" {
+		t.Fatalf("request = %+v", req)
+	}
+	if _, err := requests.RequestAccess(ctx, member, item.ID, ""); !errors.Is(err, domain.ErrAccessRequestPending) {
+		t.Fatalf("RequestAccess(again) err = %v, want ErrAccessRequestPending", err)
+	}
+
+	for _, approver := range []string{owner, manager} {
+		pending, err := requests.ListPendingForApprover(ctx, approver)
+		if err != nil || len(pending) != 1 || pending[0].ID != req.ID {
+			t.Fatalf("ListPendingForApprover = %+v, %v; want the request", pending, err)
+		}
+	}
+	if pending, _ := requests.ListPendingForApprover(ctx, member); len(pending) != 0 {
+		t.Fatalf("requester sees pending %+v, want none", pending)
+	}
+	if _, err := requests.Deny(ctx, member, req.ID); !errors.Is(err, domain.ErrNotAccessApprover) {
+		t.Fatalf("Deny(requester) err = %v, want ErrNotAccessApprover", err)
+	}
+	if _, err := requests.Deny(ctx, stranger, req.ID); !errors.Is(err, domain.ErrAccessRequestNotFound) {
+		t.Fatalf("Deny(stranger) err = %v, want ErrAccessRequestNotFound", err)
+	}
+
+	grant := service.ApproveAccessInput{DEKWrapped: []byte("dek-for-cy"), WrapNonce: []byte("wn")}
+	if _, err := requests.Approve(ctx, manager, req.ID, service.ApproveAccessInput{
+		DEKWrapped: grant.DEKWrapped, WrapNonce: grant.WrapNonce, Permissions: domain.SharePermissionManage,
+	}); !errors.Is(err, domain.ErrNotItemOwner) {
+		t.Fatalf("Approve(manager grants manage) err = %v, want ErrNotItemOwner", err)
+	}
+	approved, err := requests.Approve(ctx, manager, req.ID, grant)
+	if err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	if approved.Status != domain.AccessRequestApproved || approved.DecidedByUserID != manager {
+		t.Fatalf("approved = %+v", approved)
+	}
+	if _, err := requests.Approve(ctx, owner, req.ID, grant); !errors.Is(err, domain.ErrAccessRequestDecided) {
+		t.Fatalf("Approve(again) err = %v, want ErrAccessRequestDecided", err)
+	}
+
+	shared, err := sharing.ListSharedWithMe(ctx, member)
+	if err != nil || len(shared) != 1 || string(shared[0].ShareDEK) != "dek-for-cy" || shared[0].SharedByUserID != manager {
+		t.Fatalf("ListSharedWithMe = %+v, %v; want the approved share", shared, err)
+	}
+	mine, err := requests.ListMyRequests(ctx, member)
+	if err != nil || len(mine) != 1 || mine[0].Status != domain.AccessRequestApproved {
+		t.Fatalf("ListMyRequests = %+v, %v", mine, err)
+	}
+
+	// The owner's log shows the grant their manager made.
+	ownerID := uuid.MustParse(owner)
+	_, total, err := store.Audit().ListEvents(ctx, 10, 0, domain.AuditFilter{UserID: &ownerID, EventTypes: []domain.EventType{domain.EventTypeSharingAccessApproved}})
+	if err != nil || total != 1 {
+		t.Fatalf("owner's %s events = %d, %v; want 1", domain.EventTypeSharingAccessApproved, total, err)
+	}
+
+	// A denied request can be made again.
+	if err := sharing.RevokeShare(ctx, owner, item.ID, member); err != nil {
+		t.Fatalf("RevokeShare: %v", err)
+	}
+	again, err := requests.RequestAccess(ctx, member, item.ID, "")
+	if err != nil {
+		t.Fatalf("RequestAccess(after revoke): %v", err)
+	}
+	if _, err := requests.Deny(ctx, owner, again.ID); err != nil {
+		t.Fatalf("Deny: %v", err)
+	}
+	if _, err := requests.RequestAccess(ctx, member, item.ID, ""); err != nil {
+		t.Fatalf("RequestAccess(after deny): %v", err)
+	}
+}
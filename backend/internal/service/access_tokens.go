@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/util"
+)
+
+// accessTokenPrefix tells access tokens from session tokens, which are hex.
+const accessTokenPrefix = "at1."
+
+// MaxAccessTokenTTL bounds how long an access token lasts, and so how long
+// SessionEvents has to remember a revocation for.
+const MaxAccessTokenTTL = time.Hour
+
+// AccessTokens mints and checks short-lived access tokens. A token carries
+// what Authenticate reads from the sessions row, signed with a key bound to
+// the auth pepper, so checking one needs no database read. Every sign-in
+// issues one next to the session token, which is the refresh token: POST
+// /auth/refresh trades it for a new one and a fresh access token.
+//
+// A token outlives the revocation of its session until it expires unless
+// the revocation was made on the same replica; see SessionEvents.
+type AccessTokens struct {
+	key []byte
+	ttl time.Duration
+	now func() time.Time
+}
+
+func NewAccessTokens(pepper string, ttl time.Duration) *AccessTokens {
+	return &AccessTokens{key: util.DeriveAccessTokenKey(pepper), ttl: min(ttl, MaxAccessTokenTTL), now: time.Now}
+}
+
+// accessClaims are the session fields an access token carries. Times are
+// Unix milliseconds.
+type accessClaims struct {
+	SessionID        string          `json:"sid"`
+	UserID           string          `json:"sub"`
+	Email            string          `json:"email"`
+	Name             string          `json:"name,omitempty"`
+	Role             domain.UserRole `json:"role"`
+	TOTPEnabled      bool            `json:"totp,omitempty"`
+	DeviceID         string          `json:"dev,omitempty"`
+	DPoPThumbprint   string          `json:"jkt,omitempty"`
+	SessionExpiresAt int64           `json:"sexp"`
+	IssuedAt         int64           `json:"iat"`
+	ExpiresAt        int64           `json:"exp"`
+}
+
+// issue returns a token for session, or false for one that has to be
+// checked against the database on every request: awaiting step-up or a
+// credential reset, or bound to its client.
+func (t *AccessTokens) issue(session domain.Session) (string, time.Time, bool) {
+	if session.StepUpRequired || session.CredentialResetRequired || session.Binding.Enabled() {
+		return "", time.Time{}, false
+	}
+	now := t.now()
+	expiresAt := now.Add(t.ttl)
+	if session.ExpiresAt.Before(expiresAt) {
+		expiresAt = session.ExpiresAt
+	}
+	payload, err := json.Marshal(accessClaims{
+		SessionID:        session.ID,
+		UserID:           session.UserID,
+		Email:            session.Email,
+		Name:             session.Name,
+		Role:             session.Role,
+		TOTPEnabled:      session.TOTPEnabled,
+		DeviceID:         session.DeviceID,
+		DPoPThumbprint:   session.DPoPThumbprint,
+		SessionExpiresAt: session.ExpiresAt.UnixMilli(),
+		IssuedAt:         now.UnixMilli(),
+		ExpiresAt:        expiresAt.UnixMilli(),
+	})
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	signed := accessTokenPrefix + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(t.sign(signed)), expiresAt.UTC(), true
+}
+
+// verify returns the claims of a token that is intact and unexpired.
+func (t *AccessTokens) verify(token string) (accessClaims, bool) {
+	if !IsAccessToken(token) {
+		return accessClaims{}, false
+	}
+	i := strings.LastIndexByte(token, '.')
+	signed, mac := token[:i], token[i+1:]
+	sig, err := base64.RawURLEncoding.DecodeString(mac)
+	if err != nil || !hmac.Equal(sig, t.sign(signed)) {
+		return accessClaims{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(signed, accessTokenPrefix))
+	if err != nil {
+		return accessClaims{}, false
+	}
+	var claims accessClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return accessClaims{}, false
+	}
+	if !t.now().Before(time.UnixMilli(claims.ExpiresAt)) {
+		return accessClaims{}, false
+	}
+	if _, err := uuid.Parse(claims.UserID); err != nil {
+		return accessClaims{}, false
+	}
+	return claims, true
+}
+
+func (t *AccessTokens) sign(signed string) []byte {
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
+
+// UseAccessTokens has sign-ins and refreshes issue access tokens alongside
+// the session token, and Authenticate accept them.
+func (s *AuthService) UseAccessTokens(tokens *AccessTokens) {
+	s.accessTokens = tokens
+}
+
+// IsAccessToken reports whether token has the form of an access token.
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, accessTokenPrefix)
+}
+
+// authenticateAccessToken is Authenticate for access tokens. Only the
+// signature, the expiry and the revocations and step-up flags made on this
+// replica are checked; the session row is not read.
+func (s *AuthService) authenticateAccessToken(ctx context.Context, token string) (domain.Session, error) {
+	if s.accessTokens == nil {
+		return domain.Session{}, domain.ErrUnauthorizedSession
+	}
+	claims, ok := s.accessTokens.verify(token)
+	if !ok || s.sessionEvents.revokedSince(claims.UserID, time.UnixMilli(claims.IssuedAt)) {
+		return domain.Session{}, domain.ErrUnauthorizedSession
+	}
+	session := domain.Session{
+		ID:             claims.SessionID,
+		UserID:         claims.UserID,
+		Email:          claims.Email,
+		Name:           claims.Name,
+		Role:           claims.Role,
+		TOTPEnabled:    claims.TOTPEnabled,
+		ExpiresAt:      time.UnixMilli(claims.SessionExpiresAt).UTC(),
+		DeviceID:       claims.DeviceID,
+		DPoPThumbprint: claims.DPoPThumbprint,
+	}
+	if err := s.checkDPoP(ctx, session, token); err != nil {
+		return domain.Session{}, err
+	}
+	return session, nil
+}
@@ -0,0 +1,404 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/util"
+)
+
+const (
+	defaultAdminSearchLimit = 20
+	maxAdminSearchLimit     = 100
+
+	// statsDailyActiveDays is how many days of daily active users Stats reports.
+	statsDailyActiveDays = 30
+)
+
+var featureFlagKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_.-]{0,63}$`)
+
+// AdminService backs the /admin API. Every call that reads a user's
+// metadata or changes anything is audited; the acting admin is recorded as
+// the event's actor.
+type AdminService struct {
+	repo        domain.AdminRepository
+	auth        domain.AuthRepository
+	audit       *AuditService
+	blobs       BlobStore
+	events      *SessionEvents
+	now         func() time.Time
+	maintenance atomic.Pointer[domain.MaintenanceState]
+	forced      atomic.Pointer[domain.MaintenanceState]
+
+	statsTTL     time.Duration
+	statsMu      sync.Mutex
+	stats        domain.InstanceStats
+	statsExpires time.Time
+}
+
+// NewAdminService builds the service. Stats results are reused for
+// statsTTL; zero recomputes them on every call.
+func NewAdminService(repo domain.AdminRepository, auth domain.AuthRepository, audit *AuditService, statsTTL time.Duration) *AdminService {
+	s := &AdminService{repo: repo, auth: auth, audit: audit, now: time.Now, statsTTL: statsTTL}
+	s.maintenance.Store(&domain.MaintenanceState{})
+	return s
+}
+
+// BlobStore removes stored objects, such as attachment ciphertext, by the
+// storage path the database records for them.
+type BlobStore interface {
+	Delete(ctx context.Context, path string) error
+}
+
+// UseBlobStore sets where EraseUser deletes attachment objects. Without one
+// their objects are counted as failed in the erasure report.
+func (s *AdminService) UseBlobStore(blobs BlobStore) {
+	s.blobs = blobs
+}
+
+// UseSessionEvents has sessions the admin revokes, or ends by erasing
+// their user, signed out of their event streams right away.
+func (s *AdminService) UseSessionEvents(events *SessionEvents) {
+	s.events = events
+}
+
+// Stats returns instance-wide totals, from cache while they are younger
+// than the TTL. Concurrent callers share one computation. They are
+// aggregates only, so reading them is not audited.
+func (s *AdminService) Stats(ctx context.Context) (domain.InstanceStats, error) {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+
+	if s.now().Before(s.statsExpires) {
+		return s.stats, nil
+	}
+	stats, err := s.repo.GetInstanceStats(ctx, statsDailyActiveDays)
+	if err != nil {
+		return domain.InstanceStats{}, fmt.Errorf("get instance stats: %w", err)
+	}
+	if s.statsTTL > 0 {
+		s.stats = stats
+		s.statsExpires = s.now().Add(s.statsTTL)
+	}
+	return stats, nil
+}
+
+// SearchUsers lists accounts whose email starts with query.
+func (s *AdminService) SearchUsers(ctx context.Context, adminID, query string, limit int) ([]domain.AdminUser, error) {
+	if limit <= 0 {
+		limit = defaultAdminSearchLimit
+	}
+	limit = min(limit, maxAdminSearchLimit)
+	query = util.NormalizeEmail(query)
+
+	users, err := s.repo.SearchUsers(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("search users: %w", err)
+	}
+
+	aid, _ := uuid.Parse(adminID)
+	s.audit.LogEvent(ctx, &aid, domain.EventTypeAdminUsersSearched, map[string]any{
+		"query":   query,
+		"results": len(users),
+	})
+	return users, nil
+}
+
+func (s *AdminService) GetUser(ctx context.Context, userID string) (domain.AdminUser, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return domain.AdminUser{}, domain.ErrNotFound
+	}
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return domain.AdminUser{}, err
+	}
+
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeAdminUserViewed, nil)
+	return user, nil
+}
+
+func (s *AdminService) ListSessions(ctx context.Context, userID string) ([]domain.AdminSession, error) {
+	if _, err := s.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	sessions, err := s.repo.ListActiveSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// RevokeSessions signs the user out of one session, or of every session
+// when sessionID is empty, and reports how many were revoked.
+func (s *AdminService) RevokeSessions(ctx context.Context, userID, sessionID string) (int64, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return 0, domain.ErrNotFound
+	}
+
+	var revoked int64
+	if sessionID == "" {
+		if _, err := s.repo.GetUser(ctx, userID); err != nil {
+			return 0, err
+		}
+		if revoked, err = s.auth.RevokeAllUserSessions(ctx, userID); err != nil {
+			return 0, fmt.Errorf("revoke sessions: %w", err)
+		}
+	} else {
+		if _, err := uuid.Parse(sessionID); err != nil {
+			return 0, domain.ErrNotFound
+		}
+		ok, err := s.repo.RevokeSession(ctx, userID, sessionID)
+		if err != nil {
+			return 0, fmt.Errorf("revoke session: %w", err)
+		}
+		if !ok {
+			return 0, domain.ErrNotFound
+		}
+		revoked = 1
+	}
+
+	s.events.SessionsRevoked(userID)
+
+	data := map[string]any{"revoked": revoked}
+	if sessionID != "" {
+		data["session_id"] = sessionID
+	}
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeAdminSessionsRevoked, data)
+	return revoked, nil
+}
+
+// ClearLockout lifts a two-factor lockout and resets the failure counter.
+func (s *AdminService) ClearLockout(ctx context.Context, userID string) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return domain.ErrNotFound
+	}
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.auth.ResetTOTPFailures(ctx, userID); err != nil {
+		return fmt.Errorf("reset totp failures: %w", err)
+	}
+
+	data := map[string]any{}
+	if user.TOTPLockedUntil != nil && user.TOTPLockedUntil.After(s.now()) {
+		data["locked_until"] = user.TOTPLockedUntil.Format(time.RFC3339)
+	}
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeAdminLockoutCleared, data)
+	return nil
+}
+
+// FlagCredentialsCompromised marks the user's credentials as compromised
+// and signs every session out. The user can still sign in, but each new
+// session is restricted to changing the master password until they do.
+// Passkey-only accounts have no password to change, so cannot be flagged.
+func (s *AdminService) FlagCredentialsCompromised(ctx context.Context, userID string) (int64, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return 0, domain.ErrNotFound
+	}
+	record, err := s.auth.GetUserAuthByID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if record.Passwordless() {
+		return 0, domain.ErrPasswordlessAccount
+	}
+
+	// Flagged first, so a sign-in racing the revocation is restricted.
+	if err := s.auth.SetCredentialsCompromised(ctx, userID); err != nil {
+		return 0, fmt.Errorf("flag credentials compromised: %w", err)
+	}
+	revoked, err := s.auth.RevokeAllUserSessions(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("revoke sessions: %w", err)
+	}
+	s.events.SessionsRevoked(userID)
+
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeAdminCredentialsCompromised, map[string]any{"revoked": revoked})
+	return revoked, nil
+}
+
+func (s *AdminService) ListFeatureFlags(ctx context.Context) ([]domain.FeatureFlag, error) {
+	flags, err := s.repo.ListFeatureFlags(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list feature flags: %w", err)
+	}
+	return flags, nil
+}
+
+// SetFeatureFlag creates or updates a flag. An empty description keeps the
+// current one.
+func (s *AdminService) SetFeatureFlag(ctx context.Context, adminID, key string, enabled bool, description string) (domain.FeatureFlag, error) {
+	key = strings.TrimSpace(key)
+	if !featureFlagKeyPattern.MatchString(key) {
+		return domain.FeatureFlag{}, domain.ErrInvalidFeatureFlag
+	}
+
+	flag, err := s.repo.SetFeatureFlag(ctx, domain.FeatureFlag{
+		Key:         key,
+		Enabled:     enabled,
+		Description: strings.TrimSpace(description),
+		UpdatedBy:   &adminID,
+	})
+	if err != nil {
+		return domain.FeatureFlag{}, fmt.Errorf("set feature flag: %w", err)
+	}
+
+	aid, _ := uuid.Parse(adminID)
+	s.audit.LogEvent(ctx, &aid, domain.EventTypeAdminFeatureFlagSet, map[string]any{
+		"key":     flag.Key,
+		"enabled": flag.Enabled,
+	})
+	return flag, nil
+}
+
+// Maintenance reports the current maintenance state: the forced one while
+// ForceMaintenance is in effect, otherwise the stored one as last loaded.
+// It is read on every request and never touches storage.
+func (s *AdminService) Maintenance() domain.MaintenanceState {
+	if forced := s.forced.Load(); forced != nil {
+		return *forced
+	}
+	return *s.maintenance.Load()
+}
+
+// ForceMaintenance keeps this process in maintenance mode for its lifetime,
+// whatever is stored, for deployments started with MAINTENANCE_MODE.
+func (s *AdminService) ForceMaintenance(message string) {
+	since := s.now().UTC()
+	s.forced.Store(&domain.MaintenanceState{
+		Enabled: true,
+		Message: strings.TrimSpace(message),
+		Since:   &since,
+		Forced:  true,
+	})
+}
+
+// LoadMaintenance replaces the in-memory maintenance state with the stored
+// one, so a restart or another replica's toggle takes effect here.
+func (s *AdminService) LoadMaintenance(ctx context.Context) error {
+	state, err := s.repo.GetMaintenance(ctx)
+	if err != nil {
+		return err
+	}
+	s.maintenance.Store(&state)
+	return nil
+}
+
+// SyncMaintenance calls LoadMaintenance every interval until ctx is
+// cancelled, passing failures to onError and keeping the last known state.
+func (s *AdminService) SyncMaintenance(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := s.LoadMaintenance(ctx); err != nil && ctx.Err() == nil {
+			onError(err)
+		}
+	}
+}
+
+// SetMaintenance turns maintenance mode on or off and stores the new state
+// so it survives restarts. message is shown to clients while it is on. A
+// state forced by ForceMaintenance still wins until the process restarts.
+func (s *AdminService) SetMaintenance(ctx context.Context, adminID string, enabled bool, message string) (domain.MaintenanceState, error) {
+	message = strings.TrimSpace(message)
+
+	state := domain.MaintenanceState{Enabled: enabled}
+	if enabled {
+		since := s.now().UTC()
+		if current := s.maintenance.Load(); current.Enabled && current.Since != nil {
+			since = *current.Since
+		}
+		state.Message = message
+		state.Since = &since
+	}
+	if err := s.repo.SaveMaintenance(ctx, state, &adminID); err != nil {
+		return domain.MaintenanceState{}, fmt.Errorf("save maintenance state: %w", err)
+	}
+	s.maintenance.Store(&state)
+
+	aid, _ := uuid.Parse(adminID)
+	s.audit.LogEvent(ctx, &aid, domain.EventTypeAdminMaintenanceSet, map[string]any{
+		"enabled": enabled,
+		"message": state.Message,
+	})
+	return s.Maintenance(), nil
+}
+
+// EraseUser permanently deletes a user and everything stored about them,
+// audit history included, then checks every table again and returns the
+// result. The audit event it leaves names only the acting admin.
+func (s *AdminService) EraseUser(ctx context.Context, adminID, userID string) (domain.ErasureReport, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return domain.ErasureReport{}, domain.ErrNotFound
+	}
+	if userID == adminID {
+		return domain.ErasureReport{}, domain.ErrCannotEraseSelf
+	}
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return domain.ErasureReport{}, err
+	}
+
+	paths, err := s.repo.EraseUser(ctx, userID, user.Email)
+	if err != nil {
+		return domain.ErasureReport{}, fmt.Errorf("erase user: %w", err)
+	}
+	s.events.SessionsRevoked(userID)
+
+	var deleted, failed int
+	for _, path := range paths {
+		if s.blobs == nil || s.blobs.Delete(ctx, path) != nil {
+			failed++
+			continue
+		}
+		deleted++
+	}
+
+	report, err := s.ErasureReport(ctx, userID, user.Email)
+	if err != nil {
+		return domain.ErasureReport{}, err
+	}
+	report.BlobsDeleted = deleted
+	report.BlobsFailed = failed
+
+	aid, _ := uuid.Parse(adminID)
+	s.audit.LogEvent(ctx, &aid, domain.EventTypeAdminUserErased, map[string]any{
+		"clean":         report.Clean(),
+		"blobs_deleted": deleted,
+		"blobs_failed":  failed,
+	})
+	return report, nil
+}
+
+// ErasureReport checks every table for data about a user, by ID and by
+// email when one is given, so an erasure can be verified after the fact.
+func (s *AdminService) ErasureReport(ctx context.Context, userID, email string) (domain.ErasureReport, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return domain.ErasureReport{}, domain.ErrNotFound
+	}
+	remaining, err := s.repo.CountUserData(ctx, userID, util.NormalizeEmail(email))
+	if err != nil {
+		return domain.ErasureReport{}, fmt.Errorf("count user data: %w", err)
+	}
+	return domain.ErasureReport{UserID: userID, Remaining: remaining, CheckedAt: s.now().UTC()}, nil
+}
@@ -0,0 +1,238 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+const adminTestUser = "22222222-2222-2222-2222-222222222222"
+
+// mockAdminRepo implements only what the tests call; any other call panics
+// on the nil embedded interface.
+type mockAdminRepo struct {
+	domain.AdminRepository
+	revoked    []string
+	flags      []domain.FeatureFlag
+	statsCalls int
+	saved      []domain.MaintenanceState
+}
+
+func (m *mockAdminRepo) GetInstanceStats(ctx context.Context, days int) (domain.InstanceStats, error) {
+	m.statsCalls++
+	return domain.InstanceStats{Users: m.statsCalls, DailyActive: make([]domain.DailyActiveUsers, days)}, nil
+}
+
+func (m *mockAdminRepo) GetUser(ctx context.Context, userID string) (domain.AdminUser, error) {
+	if userID != adminTestUser {
+		return domain.AdminUser{}, domain.ErrNotFound
+	}
+	return domain.AdminUser{ID: userID}, nil
+}
+
+func (m *mockAdminRepo) RevokeSession(ctx context.Context, userID, sessionID string) (bool, error) {
+	if sessionID != "33333333-3333-3333-3333-333333333333" {
+		return false, nil
+	}
+	m.revoked = append(m.revoked, sessionID)
+	return true, nil
+}
+
+func (m *mockAdminRepo) SetFeatureFlag(ctx context.Context, flag domain.FeatureFlag) (domain.FeatureFlag, error) {
+	m.flags = append(m.flags, flag)
+	return flag, nil
+}
+
+func (m *mockAdminRepo) GetMaintenance(ctx context.Context) (domain.MaintenanceState, error) {
+	if len(m.saved) == 0 {
+		return domain.MaintenanceState{}, nil
+	}
+	return m.saved[len(m.saved)-1], nil
+}
+
+func (m *mockAdminRepo) SaveMaintenance(ctx context.Context, state domain.MaintenanceState, updatedBy *string) error {
+	m.saved = append(m.saved, state)
+	return nil
+}
+
+type mockAdminAuthRepo struct {
+	domain.AuthRepository
+	revokedAll []string
+}
+
+func (m *mockAdminAuthRepo) RevokeAllUserSessions(ctx context.Context, userID string) (int64, error) {
+	m.revokedAll = append(m.revokedAll, userID)
+	return 3, nil
+}
+
+func TestAdminStatsAreCached(t *testing.T) {
+	repo := &mockAdminRepo{}
+	svc := service.NewAdminService(repo, nil, nil, time.Hour)
+	for range 3 {
+		stats, err := svc.Stats(context.Background())
+		if err != nil || stats.Users != 1 {
+			t.Fatalf("Stats = %+v, %v; want the first result", stats, err)
+		}
+		if len(stats.DailyActive) != 30 {
+			t.Fatalf("daily active days = %d, want 30", len(stats.DailyActive))
+		}
+	}
+	if repo.statsCalls != 1 {
+		t.Fatalf("computed %d times, want 1", repo.statsCalls)
+	}
+
+	uncached := service.NewAdminService(repo, nil, nil, 0)
+	_, _ = uncached.Stats(context.Background())
+	_, _ = uncached.Stats(context.Background())
+	if repo.statsCalls != 3 {
+		t.Fatalf("computed %d times with caching off, want 3", repo.statsCalls)
+	}
+}
+
+func TestAdminRevokeSessions(t *testing.T) {
+	repo := &mockAdminRepo{}
+	auth := &mockAdminAuthRepo{}
+	svc := service.NewAdminService(repo, auth, nil, 0)
+	ctx := context.Background()
+
+	if n, err := svc.RevokeSessions(ctx, adminTestUser, ""); err != nil || n != 3 {
+		t.Fatalf("revoke all = %d, %v; want 3", n, err)
+	}
+	if len(auth.revokedAll) != 1 {
+		t.Fatalf("RevokeAllUserSessions called %d times", len(auth.revokedAll))
+	}
+
+	if n, err := svc.RevokeSessions(ctx, adminTestUser, "33333333-3333-3333-3333-333333333333"); err != nil || n != 1 {
+		t.Fatalf("revoke one = %d, %v; want 1", n, err)
+	}
+	for _, sessionID := range []string{"44444444-4444-4444-4444-444444444444", "not-a-uuid"} {
+		if _, err := svc.RevokeSessions(ctx, adminTestUser, sessionID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("revoke %s: err = %v, want ErrNotFound", sessionID, err)
+		}
+	}
+	if _, err := svc.RevokeSessions(ctx, "55555555-5555-5555-5555-555555555555", ""); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("revoke for an unknown user: err = %v, want ErrNotFound", err)
+	}
+	if len(auth.revokedAll) != 1 {
+		t.Fatal("sessions of an unknown user were revoked")
+	}
+}
+
+func TestAdminSetFeatureFlagValidatesKey(t *testing.T) {
+	repo := &mockAdminRepo{}
+	svc := service.NewAdminService(repo, nil, nil, 0)
+
+	for _, key := range []string{"", "Sharing", "9lives", "has space", string(make([]byte, 65))} {
+		if _, err := svc.SetFeatureFlag(context.Background(), adminTestUser, key, true, ""); !errors.Is(err, domain.ErrInvalidFeatureFlag) {
+			t.Errorf("SetFeatureFlag(%q) = %v, want ErrInvalidFeatureFlag", key, err)
+		}
+	}
+
+	flag, err := svc.SetFeatureFlag(context.Background(), adminTestUser, " sharing.v2 ", true, "new sharing UI")
+	if err != nil {
+		t.Fatalf("SetFeatureFlag: %v", err)
+	}
+	if flag.Key != "sharing.v2" || !flag.Enabled || flag.UpdatedBy == nil || *flag.UpdatedBy != adminTestUser {
+		t.Fatalf("flag = %+v", flag)
+	}
+}
+
+func TestAdminMaintenanceKeepsStartWhileOn(t *testing.T) {
+	repo := &mockAdminRepo{}
+	svc := service.NewAdminService(repo, nil, nil, 0)
+	ctx := context.Background()
+
+	if svc.Maintenance().Enabled {
+		t.Fatal("maintenance is on by default")
+	}
+	first, err := svc.SetMaintenance(ctx, adminTestUser, true, "  restoring a backup  ")
+	if err != nil || !first.Enabled || first.Message != "restoring a backup" || first.Since == nil {
+		t.Fatalf("state = %+v, %v", first, err)
+	}
+	second, _ := svc.SetMaintenance(ctx, adminTestUser, true, "almost done")
+	if !second.Since.Equal(*first.Since) || second.Message != "almost done" {
+		t.Fatalf("second state = %+v, want since %v", second, first.Since)
+	}
+	if off, _ := svc.SetMaintenance(ctx, adminTestUser, false, "ignored"); off.Enabled || off.Message != "" || off.Since != nil {
+		t.Fatalf("off state = %+v", off)
+	}
+	if svc.Maintenance().Enabled {
+		t.Fatal("maintenance still on")
+	}
+}
+
+func TestAdminMaintenanceIsPersistedAndCanBeForced(t *testing.T) {
+	repo := &mockAdminRepo{}
+	ctx := context.Background()
+	if _, err := service.NewAdminService(repo, nil, nil, 0).SetMaintenance(ctx, adminTestUser, true, "restoring"); err != nil {
+		t.Fatalf("SetMaintenance: %v", err)
+	}
+
+	restarted := service.NewAdminService(repo, nil, nil, 0)
+	if err := restarted.LoadMaintenance(ctx); err != nil {
+		t.Fatalf("LoadMaintenance: %v", err)
+	}
+	if state := restarted.Maintenance(); !state.Enabled || state.Message != "restoring" {
+		t.Fatalf("after restart state = %+v", state)
+	}
+
+	restarted.ForceMaintenance("migrating")
+	off, err := restarted.SetMaintenance(ctx, adminTestUser, false, "")
+	if err != nil {
+		t.Fatalf("SetMaintenance: %v", err)
+	}
+	if !off.Enabled || !off.Forced || off.Message != "migrating" {
+		t.Fatalf("forced state = %+v, want it to stay on", off)
+	}
+	if stored := repo.saved[len(repo.saved)-1]; stored.Enabled {
+		t.Fatalf("stored state = %+v, want it off", stored)
+	}
+}
+
+func TestAdminEraseUser(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	userID := uuid.NewString()
+	err := store.Auth().CreateUserWithCredentials(ctx, domain.CreateUserInput{
+		UserID: userID, Email: "ada@example.com", Algo: "argon2id", ParamsJSON: []byte(`{}`),
+	})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if _, err := store.Vault().CreateVaultItem(ctx, domain.CreateVaultItemInput{
+		OwnerUserID: userID, Ciphertext: []byte("secret"), Nonce: []byte("n"), WrappedDEK: []byte("d"), WrapNonce: []byte("w"), AlgoVersion: "v1",
+	}); err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+
+	audit := service.NewAuditService(store.Audit(), nil)
+	uid := uuid.MustParse(userID)
+	audit.LogEvent(ctx, &uid, domain.EventTypeAuthLoginSuccess, nil)
+	svc := service.NewAdminService(store.Admin(), store.Auth(), audit, 0)
+
+	if _, err := svc.EraseUser(ctx, userID, userID); !errors.Is(err, domain.ErrCannotEraseSelf) {
+		t.Fatalf("self erasure: err = %v, want ErrCannotEraseSelf", err)
+	}
+	report, err := svc.EraseUser(ctx, adminTestUser, userID)
+	if err != nil {
+		t.Fatalf("EraseUser: %v", err)
+	}
+	if !report.Clean() || report.Remaining["vault_items"] != 0 || report.Remaining["audit_events"] != 0 {
+		t.Fatalf("report = %+v, want clean", report)
+	}
+	if _, err := store.Admin().GetUser(ctx, userID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("GetUser after erasure: err = %v, want ErrNotFound", err)
+	}
+
+	// The erasure's own audit event must not bring the user back.
+	if again, err := svc.ErasureReport(ctx, userID, "ADA@example.com "); err != nil || !again.Clean() {
+		t.Fatalf("ErasureReport = %+v, %v; want clean", again, err)
+	}
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/geoip"
+	"pmv2/backend/internal/util"
+)
+
+// Geolocator approximates where an IP address is.
+type Geolocator interface {
+	Locate(ip string) (geoip.Location, bool)
+}
+
+// minTravelKm is the shortest distance between two sign-ins the impossible
+// travel check considers; city-level geolocation is often off by less.
+const minTravelKm = 500
+
+// loginHistoryLimit is how many of a user's recent sign-ins the checks
+// look back over.
+const loginHistoryLimit = 100
+
+// AnomalyPolicy sets what counts as suspicious.
+type AnomalyPolicy struct {
+	// Two sign-ins implying travel faster than MaxTravelSpeedKmh are
+	// impossible travel.
+	MaxTravelSpeedKmh float64
+	// DownloadThreshold vault items served to one user within
+	// DownloadWindow is a mass download. Zero turns the check off.
+	DownloadThreshold int
+	DownloadWindow    time.Duration
+	// A data export requested within NewDeviceWindow of the first sign-in
+	// from a device is suspicious.
+	NewDeviceWindow time.Duration
+	// StepUp also flags the session involved, so it must verify a TOTP code
+	// before it can do anything else.
+	StepUp bool
+}
+
+// AnomalyService looks for account takeover patterns: sign-ins from places
+// too far apart to travel between, a session pulling far more vault items
+// than usual, and a data export requested from a device that only just
+// signed in. Each finding is a high-severity security audit event. The
+// checks are best effort: they never fail the request that triggered them.
+//
+// The vault, auth and export services report activity to it; a nil
+// *AnomalyService ignores everything.
+type AnomalyService struct {
+	history domain.AuditRepository
+	auth    domain.AuthRepository
+	devices domain.DeviceRepository
+	audit   *AuditService
+	geo     Geolocator
+	policy  AnomalyPolicy
+	events  *SessionEvents
+	now     func() time.Time
+
+	mu        sync.Mutex
+	downloads map[string]*downloadWindow // by user ID
+	pruned    time.Time
+}
+
+type downloadWindow struct {
+	start   time.Time
+	items   int
+	flagged bool
+}
+
+// NewAnomalyService builds the detector. geo may be nil, which turns the
+// impossible travel check off. devices may be nil, in which case devices are
+// told apart by user agent alone.
+func NewAnomalyService(history domain.AuditRepository, auth domain.AuthRepository, devices domain.DeviceRepository, audit *AuditService, geo Geolocator, policy AnomalyPolicy) *AnomalyService {
+	return &AnomalyService{
+		history:   history,
+		auth:      auth,
+		devices:   devices,
+		audit:     audit,
+		geo:       geo,
+		policy:    policy,
+		now:       time.Now,
+		downloads: make(map[string]*downloadWindow),
+	}
+}
+
+// UseSessionEvents has a session flagged for step-up refuse the access
+// tokens it already holds, so its next request goes through the session
+// token and meets the step-up gate.
+func (s *AnomalyService) UseSessionEvents(events *SessionEvents) {
+	s.events = events
+}
+
+// CheckLogin compares a sign-in from ip with the user's previous one and
+// flags impossible travel.
+func (s *AnomalyService) CheckLogin(ctx context.Context, userID, sessionID, ip string) {
+	if s == nil || s.geo == nil {
+		return
+	}
+	here, ok := s.geo.Locate(ip)
+	if !ok {
+		return
+	}
+
+	at := s.now()
+	logins, err := s.logins(ctx, userID)
+	if err != nil {
+		slog.WarnContext(ctx, "anomaly check: read login history failed", slog.Any("error", err))
+		return
+	}
+	var previous domain.AuditEvent
+	for _, e := range logins {
+		if e.CreatedAt.Before(at) && e.IPAddress != "" {
+			previous = e
+			break
+		}
+	}
+	if previous.IPAddress == "" || previous.IPAddress == ip {
+		return
+	}
+	there, ok := s.geo.Locate(previous.IPAddress)
+	if !ok {
+		return
+	}
+
+	distance := geoip.DistanceKm(there, here)
+	elapsed := at.Sub(previous.CreatedAt)
+	speed := distance / math.Max(elapsed.Hours(), 1.0/3600)
+	if distance < minTravelKm || speed <= s.policy.MaxTravelSpeedKmh {
+		return
+	}
+	s.flag(ctx, userID, sessionID, domain.EventTypeSecurityImpossibleTravel, map[string]any{
+		"previous_ip":     previous.IPAddress,
+		"distance_km":     math.Round(distance),
+		"elapsed_seconds": int64(elapsed.Seconds()),
+		"speed_kmh":       math.Round(speed),
+	})
+}
+
+// CountDownloads records that items vault items were served to the user
+// and flags a mass download the first time a window goes over the
+// threshold. Counts are kept per process.
+func (s *AnomalyService) CountDownloads(ctx context.Context, userID string, items int) {
+	if s == nil || s.policy.DownloadThreshold <= 0 || items <= 0 {
+		return
+	}
+
+	now := s.now()
+	s.mu.Lock()
+	if now.Sub(s.pruned) >= s.policy.DownloadWindow {
+		for id, w := range s.downloads {
+			if now.Sub(w.start) >= s.policy.DownloadWindow {
+				delete(s.downloads, id)
+			}
+		}
+		s.pruned = now
+	}
+	w, ok := s.downloads[userID]
+	if !ok || now.Sub(w.start) >= s.policy.DownloadWindow {
+		w = &downloadWindow{start: now}
+		s.downloads[userID] = w
+	}
+	w.items += items
+	crossed := !w.flagged && w.items >= s.policy.DownloadThreshold
+	if crossed {
+		w.flagged = true
+	}
+	total := w.items
+	s.mu.Unlock()
+
+	if crossed {
+		s.flag(ctx, userID, util.RequestMetaFromContext(ctx).SessionID, domain.EventTypeSecurityMassDownload, map[string]any{
+			"items":          total,
+			"window_seconds": int64(s.policy.DownloadWindow.Seconds()),
+		})
+	}
+}
+
+// CheckExport flags a data export requested from a device whose first
+// sign-in was less than NewDeviceWindow ago. The device is the one recorded
+// for the session when there is one, and otherwise told apart by its user
+// agent. A user's very first device is not new.
+func (s *AnomalyService) CheckExport(ctx context.Context, userID string) {
+	meta := util.RequestMetaFromContext(ctx)
+	if s == nil {
+		return
+	}
+	if meta.DeviceID != "" && s.devices != nil {
+		s.checkExportFromDevice(ctx, userID, meta)
+		return
+	}
+	if meta.UserAgent == "" {
+		return
+	}
+
+	logins, err := s.logins(ctx, userID)
+	if err != nil {
+		slog.WarnContext(ctx, "anomaly check: read login history failed", slog.Any("error", err))
+		return
+	}
+	// logins is newest first, so the last match is the first sign-in.
+	var firstSeen time.Time
+	for _, e := range logins {
+		if e.UserAgent == meta.UserAgent {
+			firstSeen = e.CreatedAt
+		}
+	}
+	if firstSeen.IsZero() || s.now().Sub(firstSeen) > s.policy.NewDeviceWindow {
+		return
+	}
+	knownDevice := false
+	for _, e := range logins {
+		if e.UserAgent != meta.UserAgent && e.CreatedAt.Before(firstSeen) {
+			knownDevice = true
+			break
+		}
+	}
+	if !knownDevice {
+		return
+	}
+	s.flag(ctx, userID, meta.SessionID, domain.EventTypeSecurityExportAfterNewDevice, map[string]any{
+		"device_first_seen": firstSeen.UTC().Format(time.RFC3339),
+	})
+}
+
+// checkExportFromDevice is CheckExport for a session whose device is
+// recorded.
+func (s *AnomalyService) checkExportFromDevice(ctx context.Context, userID string, meta util.RequestMeta) {
+	devices, err := s.devices.ListDevices(ctx, userID)
+	if err != nil {
+		slog.WarnContext(ctx, "anomaly check: list devices failed", slog.Any("error", err))
+		return
+	}
+	var current domain.Device
+	for _, d := range devices {
+		if d.ID == meta.DeviceID {
+			current = d
+		}
+	}
+	if current.ID == "" || s.now().Sub(current.FirstSeenAt) > s.policy.NewDeviceWindow {
+		return
+	}
+	knownDevice := false
+	for _, d := range devices {
+		if d.ID != current.ID && d.FirstSeenAt.Before(current.FirstSeenAt) {
+			knownDevice = true
+			break
+		}
+	}
+	if !knownDevice {
+		return
+	}
+	s.flag(ctx, userID, meta.SessionID, domain.EventTypeSecurityExportAfterNewDevice, map[string]any{
+		"device_id":         current.ID,
+		"device_first_seen": current.FirstSeenAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// logins returns the user's recent successful sign-ins, newest first.
+func (s *AnomalyService) logins(ctx context.Context, userID string) ([]domain.AuditEvent, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, nil
+	}
+	events, _, err := s.history.ListEvents(ctx, loginHistoryLimit, 0, domain.AuditFilter{
+		UserID:     &uid,
+		EventTypes: []domain.EventType{domain.EventTypeAuthLoginSuccess},
+	})
+	return events, err
+}
+
+// flag records a finding and, when the policy asks for it, requires the
+// session to step up. Accounts without TOTP cannot step up, so their
+// sessions are left alone.
+func (s *AnomalyService) flag(ctx context.Context, userID, sessionID string, eventType domain.EventType, data map[string]any) {
+	stepUp := false
+	if s.policy.StepUp && sessionID != "" {
+		state, err := s.auth.GetTOTPState(ctx, userID)
+		if err != nil {
+			slog.WarnContext(ctx, "anomaly check: read totp state failed", slog.Any("error", err))
+		} else if state.Enabled {
+			if err := s.auth.SetSessionStepUp(ctx, sessionID, true); err != nil {
+				slog.WarnContext(ctx, "anomaly check: require step-up failed", slog.Any("error", err))
+			} else {
+				stepUp = true
+				s.events.SessionsRevoked(userID)
+			}
+		}
+	}
+
+	data["session_id"] = sessionID
+	data["step_up_required"] = stepUp
+	uid, _ := uuid.Parse(userID)
+	s.audit.LogEvent(ctx, &uid, eventType, data)
+}
@@ -0,0 +1,195 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/geoip"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+	"pmv2/backend/internal/util"
+)
+
+type fixedGeo map[string]geoip.Location
+
+func (g fixedGeo) Locate(ip string) (geoip.Location, bool) {
+	loc, ok := g[ip]
+	return loc, ok
+}
+
+const (
+	londonIP  = "198.51.100.1"
+	newYorkIP = "203.0.113.1"
+)
+
+func TestAnomalyDetection(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	userID := uuid.NewString()
+	if err := store.Auth().CreateUserWithCredentials(ctx, domain.CreateUserInput{
+		UserID: userID, Email: "ada@example.com", Name: "Ada", Algo: "argon2id", ParamsJSON: []byte(`{}`),
+	}); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if _, err := store.Auth().SetTOTPSecret(ctx, userID, []byte("secret")); err != nil {
+		t.Fatalf("set totp secret: %v", err)
+	}
+	if err := store.Auth().EnableTOTP(ctx, userID); err != nil {
+		t.Fatalf("enable totp: %v", err)
+	}
+	sessionID := uuid.NewString()
+	if err := store.Auth().CreateSession(ctx, domain.CreateSessionInput{
+		SessionID: sessionID, UserID: userID, TokenHash: []byte("token"), ExpiresAt: time.Now().Add(time.Hour),
+	}); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	uid := uuid.MustParse(userID)
+	login := func(ip, userAgent string, ago time.Duration) {
+		t.Helper()
+		if err := store.Audit().CreateEvent(ctx, domain.AuditEvent{
+			ID: uuid.New(), UserID: &uid, EventType: domain.EventTypeAuthLoginSuccess,
+			IPAddress: ip, UserAgent: userAgent, CreatedAt: time.Now().Add(-ago),
+		}); err != nil {
+			t.Fatalf("record login: %v", err)
+		}
+	}
+	findings := func(eventType domain.EventType) int {
+		t.Helper()
+		_, total, err := store.Audit().ListEvents(ctx, 10, 0, domain.AuditFilter{EventTypes: []domain.EventType{eventType}})
+		if err != nil {
+			t.Fatalf("list events: %v", err)
+		}
+		return total
+	}
+
+	audit := service.NewAuditService(store.Audit(), nil)
+	geo := fixedGeo{
+		londonIP:  {Latitude: 51.5072, Longitude: -0.1276},
+		newYorkIP: {Latitude: 40.7128, Longitude: -74.0060},
+	}
+	svc := service.NewAnomalyService(store.Audit(), store.Auth(), store.Devices(), audit, geo, service.AnomalyPolicy{
+		MaxTravelSpeedKmh: 1000,
+		DownloadThreshold: 5,
+		DownloadWindow:    time.Minute,
+		NewDeviceWindow:   time.Hour,
+		StepUp:            true,
+	})
+
+	t.Run("impossible travel", func(t *testing.T) {
+		login(londonIP, "laptop", 2*24*time.Hour)
+		login(londonIP, "laptop", time.Hour)
+		svc.CheckLogin(ctx, userID, sessionID, londonIP)
+		if n := findings(domain.EventTypeSecurityImpossibleTravel); n != 0 {
+			t.Fatalf("same place: %d findings", n)
+		}
+
+		svc.CheckLogin(ctx, userID, sessionID, newYorkIP)
+		if n := findings(domain.EventTypeSecurityImpossibleTravel); n != 1 {
+			t.Fatalf("London to New York in an hour: %d findings, want 1", n)
+		}
+		session, err := store.Auth().GetActiveSessionByTokenHash(ctx, []byte("token"))
+		if err != nil || !session.StepUpRequired {
+			t.Fatalf("session = %+v, %v; want step-up required", session, err)
+		}
+		if err := store.Auth().SetSessionStepUp(ctx, sessionID, false); err != nil {
+			t.Fatalf("clear step-up: %v", err)
+		}
+	})
+
+	t.Run("mass download", func(t *testing.T) {
+		reqCtx := util.WithRequestMeta(ctx, util.RequestMeta{SessionID: sessionID})
+		svc.CountDownloads(reqCtx, userID, 3)
+		if n := findings(domain.EventTypeSecurityMassDownload); n != 0 {
+			t.Fatalf("below threshold: %d findings", n)
+		}
+		svc.CountDownloads(reqCtx, userID, 2)
+		svc.CountDownloads(reqCtx, userID, 10)
+		if n := findings(domain.EventTypeSecurityMassDownload); n != 1 {
+			t.Fatalf("over threshold: %d findings, want one per window", n)
+		}
+	})
+
+	t.Run("export after new device", func(t *testing.T) {
+		login(londonIP, "phone", 10*time.Minute)
+		exportFrom := func(userAgent string) context.Context {
+			return util.WithRequestMeta(ctx, util.RequestMeta{UserAgent: userAgent, SessionID: sessionID})
+		}
+
+		svc.CheckExport(exportFrom("laptop"), userID)
+		if n := findings(domain.EventTypeSecurityExportAfterNewDevice); n != 0 {
+			t.Fatalf("known device: %d findings", n)
+		}
+		svc.CheckExport(exportFrom("phone"), userID)
+		if n := findings(domain.EventTypeSecurityExportAfterNewDevice); n != 1 {
+			t.Fatalf("new device: %d findings, want 1", n)
+		}
+	})
+}
+
+func TestAnomalyStepUpRefusesIssuedAccessTokens(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	events := service.NewSessionEvents()
+	auth := service.NewAuthService(store.Auth(), store.Transactor(), audit, "pepper", time.Hour, "pmv2")
+	auth.UseSessionEvents(events, time.Minute)
+	auth.UseAccessTokens(service.NewAccessTokens("pepper", time.Minute))
+
+	user, err := auth.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	login, err := auth.Login(ctx, domain.LoginInput{Email: "ada@example.com", Password: "Correct-Horse-9"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	refreshed, err := auth.RefreshSession(ctx, login.SessionToken)
+	if err != nil || refreshed.AccessToken == "" {
+		t.Fatalf("refresh = %+v, %v; want an access token", refreshed, err)
+	}
+	session, err := auth.Authenticate(ctx, refreshed.AccessToken)
+	if err != nil {
+		t.Fatalf("authenticate with the access token: %v", err)
+	}
+	if _, err := store.Auth().SetTOTPSecret(ctx, user.UserID, []byte("secret")); err != nil {
+		t.Fatalf("set totp secret: %v", err)
+	}
+	if err := store.Auth().EnableTOTP(ctx, user.UserID); err != nil {
+		t.Fatalf("enable totp: %v", err)
+	}
+	uid := uuid.MustParse(user.UserID)
+	if err := store.Audit().CreateEvent(ctx, domain.AuditEvent{
+		ID: uuid.New(), UserID: &uid, EventType: domain.EventTypeAuthLoginSuccess, IPAddress: londonIP, CreatedAt: time.Now().Add(-time.Hour),
+	}); err != nil {
+		t.Fatalf("record login: %v", err)
+	}
+
+	anomalies := service.NewAnomalyService(store.Audit(), store.Auth(), store.Devices(), audit, fixedGeo{
+		londonIP:  {Latitude: 51.5072, Longitude: -0.1276},
+		newYorkIP: {Latitude: 40.7128, Longitude: -74.0060},
+	}, service.AnomalyPolicy{MaxTravelSpeedKmh: 1000, StepUp: true})
+	anomalies.UseSessionEvents(events)
+	anomalies.CheckLogin(ctx, user.UserID, session.ID, newYorkIP)
+
+	// The access tokens predate the step-up, so they no longer skip the
+	// gate; the session token finds the flag and gets no new access token.
+	for _, token := range []string{login.AccessToken, refreshed.AccessToken} {
+		if _, err := auth.Authenticate(ctx, token); !errors.Is(err, domain.ErrUnauthorizedSession) {
+			t.Fatalf("authenticate with an access token after step-up = %v, want ErrUnauthorizedSession", err)
+		}
+	}
+	session, err = auth.Authenticate(ctx, refreshed.SessionToken)
+	if err != nil || !session.StepUpRequired {
+		t.Fatalf("session = %+v, %v; want step-up required", session, err)
+	}
+	again, err := auth.RefreshSession(ctx, refreshed.SessionToken)
+	if err != nil || again.AccessToken != "" {
+		t.Fatalf("refresh after step-up = %+v, %v; want no access token", again, err)
+	}
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/blobstore"
+	"pmv2/backend/internal/domain"
+)
+
+// AttachmentService stores encrypted files alongside vault items. Rows
+// live in the database and the ciphertext in a blob store, under
+// attachments/<item_id>/<attachment_id>. Only the item's owner can read or
+// change its attachments.
+type AttachmentService struct {
+	repo     domain.AttachmentRepository
+	vault    domain.VaultRepository
+	blobs    blobstore.Store
+	audit    *AuditService
+	maxBytes int64
+	now      func() time.Time
+
+	signedURLTTL time.Duration
+}
+
+// NewAttachmentService builds the service. Uploads over maxBytes are
+// refused. Without a blob store every call fails with ErrNotFound, as if
+// the item had no attachments.
+func NewAttachmentService(repo domain.AttachmentRepository, vault domain.VaultRepository, blobs blobstore.Store, audit *AuditService, maxBytes int64) *AttachmentService {
+	return &AttachmentService{repo: repo, vault: vault, blobs: blobs, audit: audit, maxBytes: maxBytes, now: time.Now}
+}
+
+// UseSignedURLs lets DownloadURL hand out URLs valid for ttl, when the blob
+// store can sign them.
+func (s *AttachmentService) UseSignedURLs(ttl time.Duration) {
+	s.signedURLTTL = ttl
+}
+
+// MaxBytes is the largest attachment Upload accepts.
+func (s *AttachmentService) MaxBytes() int64 {
+	return s.maxBytes
+}
+
+// Upload stores body, the encrypted file, as a new attachment of the item.
+// The item must be out of the trash. A body over MaxBytes is refused with
+// ErrAttachmentTooLarge once that many bytes have been read.
+func (s *AttachmentService) Upload(ctx context.Context, userID, itemID string, fileNameCiphertext, nonce []byte, body io.Reader) (domain.VaultAttachment, error) {
+	if s.blobs == nil {
+		return domain.VaultAttachment{}, domain.ErrNotFound
+	}
+	if len(fileNameCiphertext) == 0 || len(nonce) == 0 {
+		return domain.VaultAttachment{}, domain.ErrInvalidVaultPayload
+	}
+	item, err := s.ownedItem(ctx, userID, itemID)
+	if err != nil {
+		return domain.VaultAttachment{}, err
+	}
+	if item.DeletedAt != nil {
+		return domain.VaultAttachment{}, domain.ErrNotFound
+	}
+	existing, err := s.repo.ListAttachments(ctx, item.ID, userID)
+	if err != nil {
+		return domain.VaultAttachment{}, fmt.Errorf("list attachments: %w", err)
+	}
+	if len(existing) >= domain.MaxAttachmentsPerItem {
+		return domain.VaultAttachment{}, domain.ErrAttachmentLimit
+	}
+
+	attachment := domain.VaultAttachment{
+		ID:                 uuid.NewString(),
+		ItemID:             item.ID,
+		FileNameCiphertext: fileNameCiphertext,
+		Nonce:              nonce,
+		CreatedAt:          s.now().UTC(),
+	}
+	attachment.StoragePath = "attachments/" + attachment.ItemID + "/" + attachment.ID
+	attachment.UpdatedAt = attachment.CreatedAt
+
+	size, err := s.blobs.Put(ctx, attachment.StoragePath, io.LimitReader(body, s.maxBytes+1))
+	if err != nil {
+		s.deleteBlob(ctx, attachment.StoragePath)
+		return domain.VaultAttachment{}, fmt.Errorf("store attachment: %w", err)
+	}
+	switch {
+	case size > s.maxBytes:
+		s.deleteBlob(ctx, attachment.StoragePath)
+		return domain.VaultAttachment{}, domain.ErrAttachmentTooLarge
+	case size == 0:
+		s.deleteBlob(ctx, attachment.StoragePath)
+		return domain.VaultAttachment{}, domain.ErrInvalidVaultPayload
+	}
+	attachment.SizeBytes = size
+
+	if err := s.repo.CreateAttachment(ctx, attachment); err != nil {
+		s.deleteBlob(ctx, attachment.StoragePath)
+		return domain.VaultAttachment{}, fmt.Errorf("create attachment: %w", err)
+	}
+
+	uid, _ := uuid.Parse(userID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeVaultAttachmentAdded, map[string]any{
+		"item_id":       attachment.ItemID,
+		"attachment_id": attachment.ID,
+		"size_bytes":    attachment.SizeBytes,
+	})
+	return attachment, nil
+}
+
+func (s *AttachmentService) List(ctx context.Context, userID, itemID string) ([]domain.VaultAttachment, error) {
+	if s.blobs == nil {
+		return nil, domain.ErrNotFound
+	}
+	item, err := s.ownedItem(ctx, userID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	attachments, err := s.repo.ListAttachments(ctx, item.ID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list attachments: %w", err)
+	}
+	return attachments, nil
+}
+
+// Open returns the attachment and its ciphertext, which the caller must
+// close.
+func (s *AttachmentService) Open(ctx context.Context, userID, itemID, attachmentID string) (domain.VaultAttachment, io.ReadCloser, error) {
+	attachment, err := s.get(ctx, userID, itemID, attachmentID)
+	if err != nil {
+		return domain.VaultAttachment{}, nil, err
+	}
+	body, err := s.blobs.Open(ctx, attachment.StoragePath)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		slog.WarnContext(ctx, "attachment blob missing", slog.String("attachment_id", attachment.ID))
+		return domain.VaultAttachment{}, nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.VaultAttachment{}, nil, fmt.Errorf("open attachment: %w", err)
+	}
+	return attachment, body, nil
+}
+
+// DownloadURL returns a signed URL from which the client downloads the
+// attachment's ciphertext straight from the blob store, and when the URL
+// expires. It is ErrSignedURLUnavailable unless signed URLs are on and the
+// store supports them; the client then uses Open's endpoint.
+func (s *AttachmentService) DownloadURL(ctx context.Context, userID, itemID, attachmentID string) (string, time.Time, error) {
+	signer, ok := s.blobs.(blobstore.URLSigner)
+	if !ok || s.signedURLTTL <= 0 {
+		return "", time.Time{}, domain.ErrSignedURLUnavailable
+	}
+	attachment, err := s.get(ctx, userID, itemID, attachmentID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := s.now().UTC().Add(s.signedURLTTL)
+	url, err := signer.SignedURL(ctx, attachment.StoragePath, s.signedURLTTL)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("sign attachment url: %w", err)
+	}
+	return url, expiresAt, nil
+}
+
+// Delete removes the attachment. A blob that cannot be deleted is logged
+// and left behind; the attachment is gone either way.
+func (s *AttachmentService) Delete(ctx context.Context, userID, itemID, attachmentID string) error {
+	attachment, err := s.get(ctx, userID, itemID, attachmentID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteAttachment(ctx, attachment.ItemID, userID, attachment.ID); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.ErrNotFound
+		}
+		return fmt.Errorf("delete attachment: %w", err)
+	}
+	s.deleteBlob(ctx, attachment.StoragePath)
+
+	uid, _ := uuid.Parse(userID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeVaultAttachmentDeleted, map[string]any{
+		"item_id":       attachment.ItemID,
+		"attachment_id": attachment.ID,
+	})
+	return nil
+}
+
+// PurgeTrashed deletes the attachments of items that have been in the
+// trash for longer than retention. It runs before VaultService.PurgeTrash,
+// whose cascade would otherwise drop the rows and orphan the blobs.
+func (s *AttachmentService) PurgeTrashed(ctx context.Context, retention time.Duration) (int64, error) {
+	if s.blobs == nil {
+		return 0, nil
+	}
+	paths, err := s.repo.DeleteTrashedAttachments(ctx, s.now().UTC().Add(-retention))
+	if err != nil {
+		return 0, fmt.Errorf("purge trashed attachments: %w", err)
+	}
+	for _, path := range paths {
+		s.deleteBlob(ctx, path)
+	}
+	return int64(len(paths)), nil
+}
+
+func (s *AttachmentService) get(ctx context.Context, userID, itemID, attachmentID string) (domain.VaultAttachment, error) {
+	if s.blobs == nil {
+		return domain.VaultAttachment{}, domain.ErrNotFound
+	}
+	itemID, attachmentID = strings.TrimSpace(itemID), strings.TrimSpace(attachmentID)
+	if _, err := uuid.Parse(itemID); err != nil {
+		return domain.VaultAttachment{}, domain.ErrNotFound
+	}
+	if _, err := uuid.Parse(attachmentID); err != nil {
+		return domain.VaultAttachment{}, domain.ErrNotFound
+	}
+	attachment, err := s.repo.GetAttachment(ctx, itemID, userID, attachmentID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.VaultAttachment{}, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.VaultAttachment{}, fmt.Errorf("get attachment: %w", err)
+	}
+	return attachment, nil
+}
+
+func (s *AttachmentService) ownedItem(ctx context.Context, userID, itemID string) (domain.VaultItem, error) {
+	itemID = strings.TrimSpace(itemID)
+	if _, err := uuid.Parse(itemID); err != nil {
+		return domain.VaultItem{}, domain.ErrNotFound
+	}
+	item, err := s.vault.GetVaultItemByIDForOwner(ctx, itemID, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.VaultItem{}, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.VaultItem{}, fmt.Errorf("get vault item: %w", err)
+	}
+	return item, nil
+}
+
+func (s *AttachmentService) deleteBlob(ctx context.Context, path string) {
+	if err := s.blobs.Delete(ctx, path); err != nil {
+		slog.WarnContext(ctx, "delete attachment blob failed", slog.String("path", path), slog.Any("error", err))
+	}
+}
@@ -0,0 +1,163 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/blobstore"
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestAttachments(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	auth := service.NewAuthService(store.Auth(), store.Transactor(), nil, "pepper", time.Hour, "pmv2")
+	vault := service.NewVaultService(store.Vault(), store.Folders(), store.Transactor(), nil)
+	blobs, err := blobstore.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	svc := service.NewAttachmentService(store.Attachments(), store.Vault(), blobs, audit, 16)
+
+	owner, err := auth.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	other, err := auth.Register(ctx, "bob@example.com", "Correct-Horse-9", "Bob")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	item, err := vault.CreateItem(ctx, owner.UserID, domain.CreateVaultItemInput{
+		Ciphertext: []byte("c"), Nonce: []byte("n"), WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"),
+		AlgoVersion: "xchacha20poly1305-v1",
+	})
+	if err != nil {
+		t.Fatalf("CreateItem: %v", err)
+	}
+	upload := func(userID, body string) (domain.VaultAttachment, error) {
+		return svc.Upload(ctx, userID, item.ID, []byte("name-c"), []byte("name-n"), strings.NewReader(body))
+	}
+
+	if _, err := upload(owner.UserID, "seventeen-bytes!!"); !errors.Is(err, domain.ErrAttachmentTooLarge) {
+		t.Fatalf("Upload(too large) err = %v, want ErrAttachmentTooLarge", err)
+	}
+	if _, err := upload(other.UserID, "ciphertext"); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("Upload(other user) err = %v, want ErrNotFound", err)
+	}
+	attachment, err := upload(owner.UserID, "ciphertext")
+	if err != nil || attachment.SizeBytes != int64(len("ciphertext")) {
+		t.Fatalf("Upload = %+v, %v", attachment, err)
+	}
+
+	list, err := svc.List(ctx, owner.UserID, item.ID)
+	if err != nil || len(list) != 1 || list[0].ID != attachment.ID || string(list[0].FileNameCiphertext) != "name-c" {
+		t.Fatalf("List = %+v, %v; want the one upload", list, err)
+	}
+	if _, err := svc.List(ctx, other.UserID, item.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("List(other user) err = %v, want ErrNotFound", err)
+	}
+
+	_, body, err := svc.Open(ctx, owner.UserID, item.ID, attachment.ID)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got, _ := io.ReadAll(body)
+	body.Close()
+	if string(got) != "ciphertext" {
+		t.Fatalf("Open read %q, want the uploaded ciphertext", got)
+	}
+	if _, _, err := svc.Open(ctx, other.UserID, item.ID, attachment.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("Open(other user) err = %v, want ErrNotFound", err)
+	}
+
+	if err := svc.Delete(ctx, other.UserID, item.ID, attachment.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("Delete(other user) err = %v, want ErrNotFound", err)
+	}
+	if err := svc.Delete(ctx, owner.UserID, item.ID, attachment.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := blobs.Open(ctx, attachment.StoragePath); !errors.Is(err, blobstore.ErrNotFound) {
+		t.Fatalf("blob after Delete err = %v, want ErrNotFound", err)
+	}
+
+	// Purging the trash takes the blobs of trashed items with it.
+	kept, err := upload(owner.UserID, "ciphertext")
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if err := vault.DeleteItem(ctx, owner.UserID, item.ID); err != nil {
+		t.Fatalf("DeleteItem: %v", err)
+	}
+	if _, err := upload(owner.UserID, "ciphertext"); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("Upload(trashed item) err = %v, want ErrNotFound", err)
+	}
+	if purged, err := svc.PurgeTrashed(ctx, time.Hour); err != nil || purged != 0 {
+		t.Fatalf("PurgeTrashed(within retention) = %d, %v; want nothing purged", purged, err)
+	}
+	if purged, err := svc.PurgeTrashed(ctx, -time.Hour); err != nil || purged != 1 {
+		t.Fatalf("PurgeTrashed = %d, %v; want 1", purged, err)
+	}
+	if _, err := blobs.Open(ctx, kept.StoragePath); !errors.Is(err, blobstore.ErrNotFound) {
+		t.Fatalf("blob after PurgeTrashed err = %v, want ErrNotFound", err)
+	}
+}
+
+// signingStore is a local store that signs URLs like an S3 store would.
+type signingStore struct {
+	*blobstore.LocalStore
+}
+
+func (signingStore) SignedURL(_ context.Context, path string, ttl time.Duration) (string, error) {
+	return "https://blobs.example.com/" + path + "?expires=" + ttl.String(), nil
+}
+
+func TestAttachmentDownloadURL(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	auth := service.NewAuthService(store.Auth(), store.Transactor(), nil, "pepper", time.Hour, "pmv2")
+	vault := service.NewVaultService(store.Vault(), store.Folders(), store.Transactor(), nil)
+	local, err := blobstore.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	svc := service.NewAttachmentService(store.Attachments(), store.Vault(), signingStore{local}, nil, 16)
+
+	owner, err := auth.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	other, err := auth.Register(ctx, "bob@example.com", "Correct-Horse-9", "Bob")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	item, err := vault.CreateItem(ctx, owner.UserID, domain.CreateVaultItemInput{
+		Ciphertext: []byte("c"), Nonce: []byte("n"), WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"),
+		AlgoVersion: "xchacha20poly1305-v1",
+	})
+	if err != nil {
+		t.Fatalf("CreateItem: %v", err)
+	}
+	attachment, err := svc.Upload(ctx, owner.UserID, item.ID, []byte("name-c"), []byte("name-n"), strings.NewReader("ciphertext"))
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	if _, _, err := svc.DownloadURL(ctx, owner.UserID, item.ID, attachment.ID); !errors.Is(err, domain.ErrSignedURLUnavailable) {
+		t.Fatalf("DownloadURL before UseSignedURLs: err = %v", err)
+	}
+	svc.UseSignedURLs(5 * time.Minute)
+	url, expiresAt, err := svc.DownloadURL(ctx, owner.UserID, item.ID, attachment.ID)
+	if err != nil || url != "https://blobs.example.com/"+attachment.StoragePath+"?expires=5m0s" || time.Until(expiresAt) > 5*time.Minute {
+		t.Fatalf("DownloadURL = %q, %s, %v", url, expiresAt, err)
+	}
+	if _, _, err := svc.DownloadURL(ctx, other.UserID, item.ID, attachment.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("DownloadURL(other user): err = %v, want ErrNotFound", err)
+	}
+}
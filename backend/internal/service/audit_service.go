@@ -5,27 +5,53 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"pmv2/backend/internal/domain"
-	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/util"
 
 	"github.com/google/uuid"
 )
 
 type AuditService struct {
-	repo *repository.AuditRepository
+	repo        domain.AuditRepository
+	outbox      domain.OutboxRepository
+	subscribers []AuditSubscriber
 }
 
-func NewAuditService(repo *repository.AuditRepository) *AuditService {
-	return &AuditService{repo: repo}
+// AuditSubscriber is called with each event once it has been stored. An
+// error makes the outbox redeliver the event, so subscribers must be
+// idempotent.
+type AuditSubscriber func(ctx context.Context, event domain.AuditEvent) error
+
+// NewAuditService builds the audit service. When outbox is non-nil events are
+// queued there and written to audit_events by the outbox dispatcher, which
+// lets callers record them in the same transaction as the change itself.
+func NewAuditService(repo domain.AuditRepository, outbox domain.OutboxRepository) *AuditService {
+	return &AuditService{repo: repo, outbox: outbox}
+}
+
+// Subscribe registers fn to receive stored events. Call it before the
+// outbox dispatcher starts.
+func (s *AuditService) Subscribe(fn AuditSubscriber) {
+	s.subscribers = append(s.subscribers, fn)
 }
 
 // LogEvent is a helper to quickly record an event.
 // It fails silently (logs to slog) so it doesn't break the main business flow if logging fails.
 func (s *AuditService) LogEvent(ctx context.Context, userID *uuid.UUID, eventType domain.EventType, eventData interface{}) {
+	if err := s.Record(ctx, userID, eventType, eventData); err != nil {
+		slog.Error("failed to persist audit event", "error", err, "event_type", eventType)
+	}
+}
+
+// Record stores an event and reports failures. Call it inside a
+// Transactor.WithinTx block when the event must not outlive a rolled-back
+// change (or be lost after a committed one).
+func (s *AuditService) Record(ctx context.Context, userID *uuid.UUID, eventType domain.EventType, eventData interface{}) error {
 	if s == nil || s.repo == nil {
-		return
+		return nil
 	}
 	var rawData json.RawMessage
 	if eventData != nil {
@@ -40,25 +66,160 @@ func (s *AuditService) LogEvent(ctx context.Context, userID *uuid.UUID, eventTyp
 		rawData = []byte("{}")
 	}
 
+	meta := util.RequestMetaFromContext(ctx)
 	event := domain.AuditEvent{
 		ID:        uuid.New(),
 		UserID:    userID,
 		EventType: eventType,
-		EventData: rawData,
+		EventData: redactAuditData(rawData),
+		IPAddress: meta.IPAddress,
+		UserAgent: meta.UserAgent,
 		CreatedAt: time.Now().UTC(),
 	}
+	if actor, err := uuid.Parse(meta.ActorUserID); err == nil {
+		event.ActorUserID = &actor
+	}
 
-	if err := s.repo.CreateEvent(ctx, event); err != nil {
-		slog.Error("failed to persist audit event", "error", err, "event_type", eventType)
+	if s.outbox == nil {
+		if err := s.repo.CreateEvent(ctx, event); err != nil {
+			return err
+		}
+		return s.notify(ctx, event)
 	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal audit event: %w", err)
+	}
+	if err := s.outbox.Enqueue(ctx, domain.OutboxTopicAudit, payload); err != nil {
+		return fmt.Errorf("enqueue audit event: %w", err)
+	}
+	return nil
 }
 
+// PurgeEvents deletes events recorded more than retention ago, only those
+// in category when it is not empty, batchSize rows per statement.
+func (s *AuditService) PurgeEvents(ctx context.Context, retention time.Duration, category string, batchSize int) (int64, error) {
+	before := time.Now().UTC().Add(-retention)
+	var total int64
+	for {
+		deleted, err := s.repo.DeleteEventsBefore(ctx, before, category, batchSize)
+		total += deleted
+		if err != nil {
+			return total, fmt.Errorf("purge audit events: %w", err)
+		}
+		if deleted < int64(batchSize) {
+			return total, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+	}
+}
+
+// MaintainPartitions keeps the monthly audit partitions created
+// AuditPartitionsAhead months in advance and, when retention is
+// set, drops the partitions whose events are all older than retention,
+// sparing PurgeEvents from deleting them row by row.
+func (s *AuditService) MaintainPartitions(ctx context.Context, retention time.Duration) (created, dropped []string, err error) {
+	now := time.Now().UTC()
+	if created, err = s.repo.EnsurePartitions(ctx, now, domain.AuditPartitionsAhead); err != nil {
+		return created, nil, fmt.Errorf("create audit partitions: %w", err)
+	}
+	if retention <= 0 {
+		return created, nil, nil
+	}
+	if dropped, err = s.repo.DropPartitionsBefore(ctx, now.Add(-retention)); err != nil {
+		return created, dropped, fmt.Errorf("drop audit partitions: %w", err)
+	}
+	return created, dropped, nil
+}
+
+// HandleOutboxEvent persists an audit event delivered by the outbox
+// dispatcher. Writes are idempotent on the event ID, so redelivery is safe.
+func (s *AuditService) HandleOutboxEvent(ctx context.Context, event domain.OutboxEvent) error {
+	var audit domain.AuditEvent
+	if err := json.Unmarshal(event.Payload, &audit); err != nil {
+		return fmt.Errorf("decode audit event: %w", err)
+	}
+	if err := s.repo.CreateEvent(ctx, audit); err != nil {
+		return err
+	}
+	return s.notify(ctx, audit)
+}
+
+func (s *AuditService) notify(ctx context.Context, event domain.AuditEvent) error {
+	for _, fn := range s.subscribers {
+		if err := fn(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sensitiveAuditKeys are field name fragments whose values never belong in
+// the audit log, whatever a caller passes in.
+var sensitiveAuditKeys = []string{"password", "secret", "token", "ciphertext", "private", "recovery_key", "totp_code", "code_hash", "dek", "kek"}
+
+// redactAuditData replaces the values of sensitive fields, at any depth,
+// with a fixed marker.
+func redactAuditData(raw json.RawMessage) json.RawMessage {
+	var data any
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return raw
+	}
+	redacted, err := json.Marshal(redactValue(data))
+	if err != nil {
+		return raw
+	}
+	return redacted
+}
+
+func redactValue(v any) any {
+	switch typed := v.(type) {
+	case map[string]any:
+		for key, value := range typed {
+			if isSensitiveAuditKey(key) {
+				typed[key] = "[REDACTED]"
+				continue
+			}
+			typed[key] = redactValue(value)
+		}
+		return typed
+	case []any:
+		for i := range typed {
+			typed[i] = redactValue(typed[i])
+		}
+		return typed
+	default:
+		return v
+	}
+}
+
+func isSensitiveAuditKey(key string) bool {
+	lower := strings.ToLower(key)
+	for _, fragment := range sensitiveAuditKeys {
+		if strings.Contains(lower, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetActivityLog lists the user's own events; filter.UserID is overridden.
 func (s *AuditService) GetActivityLog(ctx context.Context, userID string, limit, offset int, filter domain.AuditFilter) (*domain.AuditPaginatedResponse, error) {
 	uid, err := uuid.Parse(userID)
 	if err != nil {
 		return nil, fmt.Errorf("invalid user id: %w", err)
 	}
 
+	filter.UserID = &uid
+	return s.QueryEvents(ctx, limit, offset, filter)
+}
+
+// QueryEvents lists events across users. Only admin endpoints may call it
+// without setting filter.UserID.
+func (s *AuditService) QueryEvents(ctx context.Context, limit, offset int, filter domain.AuditFilter) (*domain.AuditPaginatedResponse, error) {
 	if limit <= 0 || limit > 100 {
 		limit = 20
 	}
@@ -66,7 +227,7 @@ func (s *AuditService) GetActivityLog(ctx context.Context, userID string, limit,
 		offset = 0
 	}
 
-	events, total, err := s.repo.ListEvents(ctx, uid, limit, offset, filter)
+	events, total, err := s.repo.ListEvents(ctx, limit, offset, filter)
 	if err != nil {
 		return nil, fmt.Errorf("list audit events: %w", err)
 	}
@@ -85,6 +246,16 @@ func (s *AuditService) GetActivityLog(ctx context.Context, userID string, limit,
 	}, nil
 }
 
+// ExportEvents calls fn for every event matching filter, newest first, for
+// CSV/JSON download. Events are streamed rather than loaded at once, so an
+// export has no size cap.
+func (s *AuditService) ExportEvents(ctx context.Context, filter domain.AuditFilter, fn func(domain.AuditEvent) error) error {
+	if err := s.repo.StreamEvents(ctx, filter, fn); err != nil {
+		return fmt.Errorf("export audit events: %w", err)
+	}
+	return nil
+}
+
 func (s *AuditService) ClearActivityLog(ctx context.Context, userID string) error {
 	uid, err := uuid.Parse(userID)
 	if err != nil {
@@ -105,11 +276,12 @@ func (s *AuditService) GetSecuritySummary(ctx context.Context, userID string) (s
 	// Check for failed logins or revoked shares in the last 24 hours
 	yesterday := time.Now().Add(-24 * time.Hour).UTC()
 	filter := domain.AuditFilter{
+		UserID:    &uid,
 		StartDate: &yesterday,
 	}
 
 	// We only need to check if any such events exist
-	events, _, err := s.repo.ListEvents(ctx, uid, 50, 0, filter)
+	events, _, err := s.repo.ListEvents(ctx, 50, 0, filter)
 	if err != nil {
 		return "unknown", err
 	}
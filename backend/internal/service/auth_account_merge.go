@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+)
+
+// UseAccountMerge lets a user fold a second account of theirs, say one
+// created through SSO and one with an email, into the one they are signed
+// in to. It needs the vault keys too; see UseVaultKeys.
+func (s *AuthService) UseAccountMerge(accounts domain.AdminRepository) {
+	s.accounts = accounts
+}
+
+// MergeAccounts moves everything the account of sourceToken owns or takes
+// part in (vault items, folders, shares, family, devices and sessions among
+// them) to the account of session, then leaves the source a tombstone that
+// can no longer sign in, all in one transaction. Holding a session of each
+// proves control of both.
+//
+// The client, having unlocked both vaults, sends the key of every source
+// item re-wrapped under the survivor's key-encryption key and the key of
+// every share the source received re-wrapped for the survivor's public
+// key. Item history and the recovery key's copy of the source's vault key
+// are not re-wrapped, and URI HMACs computed with the source's key no
+// longer match until the client recomputes them.
+func (s *AuthService) MergeAccounts(ctx context.Context, session domain.Session, sourceToken string, items, shares []domain.VaultItemKey) (domain.AccountMerge, error) {
+	if s.accounts == nil || s.vault == nil {
+		return domain.AccountMerge{}, fmt.Errorf("merge accounts: not configured")
+	}
+	source, err := s.Authenticate(ctx, sourceToken)
+	if err != nil {
+		if errors.Is(err, domain.ErrUnauthorizedSession) {
+			return domain.AccountMerge{}, domain.ErrInvalidMergeSession
+		}
+		return domain.AccountMerge{}, err
+	}
+	if source.StepUpRequired || source.CredentialResetRequired {
+		return domain.AccountMerge{}, domain.ErrInvalidMergeSession
+	}
+	if source.UserID == session.UserID {
+		return domain.AccountMerge{}, domain.ErrMergeSameAccount
+	}
+	if source.Role == domain.UserRoleAdmin {
+		return domain.AccountMerge{}, domain.ErrMergeAdminAccount
+	}
+
+	merge := domain.AccountMerge{SourceUserID: source.UserID, TargetUserID: session.UserID, MergedAt: s.now().UTC()}
+	err = withinTx(ctx, s.tx, func(ctx context.Context) error {
+		if err := s.vault.RewrapVaultItemKeys(ctx, source.UserID, items); err != nil {
+			return err
+		}
+		moved, err := s.accounts.MergeAccounts(ctx, domain.AccountMergeInput{
+			SourceUserID: source.UserID,
+			TargetUserID: session.UserID,
+			Items:        items,
+			Shares:       shares,
+		})
+		if err != nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				return domain.ErrInvalidMergeSession
+			}
+			return fmt.Errorf("merge accounts: %w", err)
+		}
+		merge.Moved = moved
+
+		for _, userID := range []string{source.UserID, session.UserID} {
+			uid, _ := uuid.Parse(userID)
+			if err := s.audit.Record(ctx, &uid, domain.EventTypeAuthAccountMerged, map[string]any{
+				"source_user_id": source.UserID,
+				"target_user_id": session.UserID,
+				"source_email":   source.Email,
+				"moved":          moved,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.AccountMerge{}, err
+	}
+	return merge, nil
+}
@@ -0,0 +1,86 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestMergeAccounts(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	svc := service.NewAuthService(store.Auth(), store.Transactor(), audit, "pepper", time.Hour, "pmv2")
+	svc.UseVaultKeys(store.Vault())
+	svc.UseAccountMerge(store.Admin())
+
+	signIn := func(email string) domain.Session {
+		out, err := svc.Login(ctx, domain.LoginInput{Email: email, Password: "Correct-Horse-9"})
+		if err != nil {
+			t.Fatalf("login %s: %v", email, err)
+		}
+		session, err := svc.Authenticate(ctx, out.SessionToken)
+		if err != nil {
+			t.Fatalf("authenticate %s: %v", email, err)
+		}
+		return session
+	}
+	for _, email := range []string{"ada@example.com", "ada@sso.example"} {
+		if _, err := svc.Register(ctx, email, "Correct-Horse-9", "Ada"); err != nil {
+			t.Fatalf("register %s: %v", email, err)
+		}
+	}
+	target := signIn("ada@example.com")
+	sourceOut, err := svc.Login(ctx, domain.LoginInput{Email: "ada@sso.example", Password: "Correct-Horse-9"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	item, err := store.Vault().CreateVaultItem(ctx, domain.CreateVaultItemInput{
+		OwnerUserID: sourceOut.UserID, Ciphertext: []byte("secret"), Nonce: []byte("n"), WrappedDEK: []byte("old"), WrapNonce: []byte("w"), AlgoVersion: "v1",
+	})
+	if err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	keys := []domain.VaultItemKey{{ItemID: item.ID, WrappedDEK: []byte("new"), WrapNonce: []byte("w2")}}
+
+	if _, err := svc.MergeAccounts(ctx, target, "not-a-token", keys, nil); !errors.Is(err, domain.ErrInvalidMergeSession) {
+		t.Fatalf("bad token: err = %v", err)
+	}
+	if _, err := svc.MergeAccounts(ctx, signIn("ada@sso.example"), sourceOut.SessionToken, keys, nil); !errors.Is(err, domain.ErrMergeSameAccount) {
+		t.Fatalf("same account: err = %v", err)
+	}
+	if _, err := svc.MergeAccounts(ctx, target, sourceOut.SessionToken, nil, nil); !errors.Is(err, domain.ErrRewrapIncomplete) {
+		t.Fatalf("missing item key: err = %v", err)
+	}
+
+	merge, err := svc.MergeAccounts(ctx, target, sourceOut.SessionToken, keys, nil)
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if merge.SourceUserID != sourceOut.UserID || merge.Moved["vault_items"] != 1 {
+		t.Fatalf("merge = %+v", merge)
+	}
+	moved, err := store.Vault().GetVaultItemByIDForOwner(ctx, item.ID, target.UserID)
+	if err != nil || string(moved.WrappedDEK) != "new" {
+		t.Fatalf("item after merge = %+v, %v", moved, err)
+	}
+	// The source's sessions now belong to the survivor.
+	if session, err := svc.Authenticate(ctx, sourceOut.SessionToken); err != nil || session.UserID != target.UserID {
+		t.Fatalf("source session after merge = %+v, %v", session, err)
+	}
+	if _, err := svc.Login(ctx, domain.LoginInput{Email: "ada@sso.example", Password: "Correct-Horse-9"}); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("tombstone login: err = %v", err)
+	}
+	sourceUID := uuid.MustParse(sourceOut.UserID)
+	events, total, err := store.Audit().ListEvents(ctx, 10, 0, domain.AuditFilter{UserID: &sourceUID, EventTypes: []domain.EventType{domain.EventTypeAuthAccountMerged}})
+	if err != nil || total != 1 {
+		t.Fatalf("merge audit events of the source = %+v, %v", events, err)
+	}
+}
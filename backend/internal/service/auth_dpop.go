@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dpop"
+	"pmv2/backend/internal/util"
+)
+
+// dpopReplays remembers the proofs accepted lately so each is accepted
+// once. It is kept in memory: behind several replicas, a captured proof
+// could still be replayed once against each other replica within
+// dpop.MaxAge, and only for the request it was made for.
+type dpopReplays struct {
+	mu     sync.Mutex
+	seen   map[string]time.Time // thumbprint and proof ID -> when it may be forgotten
+	pruned time.Time
+}
+
+// first records the proof and reports whether it had not been seen before.
+func (r *dpopReplays) first(proof dpop.Proof, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.seen == nil {
+		r.seen = make(map[string]time.Time)
+	}
+	if now.Sub(r.pruned) > dpop.MaxAge {
+		for key, forgetAt := range r.seen {
+			if now.After(forgetAt) {
+				delete(r.seen, key)
+			}
+		}
+		r.pruned = now
+	}
+
+	key := proof.Thumbprint + "." + proof.ID
+	if _, ok := r.seen[key]; ok {
+		return false
+	}
+	// Past this the proof is stale anyway.
+	r.seen[key] = proof.IssuedAt.Add(dpop.MaxAge)
+	return true
+}
+
+// UseDPoP lets clients of the given types bind their session to a DPoP
+// key. Sessions already bound require proofs whatever is configured.
+func (s *AuthService) UseDPoP(clientTypes []string) {
+	s.dpopClientTypes = clientTypes
+}
+
+// RegisterDPoPKey binds the session to the key that signed the request's
+// DPoP proof, made for token. From then on every request on the session
+// must carry a fresh proof from that key, so access tokens issued before,
+// which name no key, are refused. It returns the key's thumbprint.
+func (s *AuthService) RegisterDPoPKey(ctx context.Context, session domain.Session, token string, clientType string) (string, error) {
+	clientType = strings.ToLower(util.TrimOrEmpty(clientType))
+	if clientType == "" || !slices.Contains(s.dpopClientTypes, clientType) {
+		return "", domain.ErrDPoPNotEnabled
+	}
+	if session.DPoPThumbprint != "" {
+		return "", domain.ErrDPoPKeyRegistered
+	}
+	proof, err := s.verifyDPoPProof(ctx, token)
+	if err != nil {
+		return "", err
+	}
+
+	bound, err := s.repo.SetSessionDPoPKey(ctx, session.ID, proof.Thumbprint)
+	if err != nil {
+		return "", fmt.Errorf("bind session to dpop key: %w", err)
+	}
+	if !bound {
+		return "", domain.ErrDPoPKeyRegistered
+	}
+	s.sessionEvents.SessionsRevoked(session.UserID)
+
+	uid, _ := uuid.Parse(session.UserID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeAuthDPoPKeyRegistered, map[string]any{
+		"session_id":  session.ID,
+		"client_type": clientType,
+		"jkt":         proof.Thumbprint,
+	})
+	return proof.Thumbprint, nil
+}
+
+// checkDPoP lets a session bound to a DPoP key through only with a fresh
+// proof from that key for the request in ctx.
+func (s *AuthService) checkDPoP(ctx context.Context, session domain.Session, token string) error {
+	if session.DPoPThumbprint == "" {
+		return nil
+	}
+	proof, err := s.verifyDPoPProof(ctx, token)
+	if err != nil {
+		return err
+	}
+	if proof.Thumbprint != session.DPoPThumbprint {
+		return domain.ErrInvalidDPoPProof.WithDetail("signed by another key")
+	}
+	return nil
+}
+
+// verifyDPoPProof checks the proof of the request in ctx and that it was
+// not used before.
+func (s *AuthService) verifyDPoPProof(ctx context.Context, token string) (dpop.Proof, error) {
+	meta := util.RequestMetaFromContext(ctx)
+	if meta.DPoPProof == "" {
+		return dpop.Proof{}, domain.ErrInvalidDPoPProof
+	}
+	now := s.now()
+	proof, err := dpop.Verify(meta.DPoPProof, dpop.Request{Method: meta.Method, Path: meta.Path, Token: token}, now)
+	if errors.Is(err, dpop.ErrInvalidProof) {
+		return dpop.Proof{}, domain.ErrInvalidDPoPProof.WithDetail(strings.TrimPrefix(err.Error(), dpop.ErrInvalidProof.Error()+": "))
+	}
+	if err != nil {
+		return dpop.Proof{}, err
+	}
+	if !s.dpopReplays.first(proof, now) {
+		return dpop.Proof{}, domain.ErrInvalidDPoPProof.WithDetail("proof already used")
+	}
+	return proof, nil
+}
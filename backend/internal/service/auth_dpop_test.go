@@ -0,0 +1,101 @@
+package service_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/dpop"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+	"pmv2/backend/internal/util"
+)
+
+func TestDPoPBoundSession(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	svc := service.NewAuthService(store.Auth(), store.Transactor(), audit, "pepper", time.Hour, "pmv2")
+	svc.UseDPoP([]string{"desktop"})
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	thief, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	// request returns the context of a request carrying a proof signed by
+	// signer, or none when signer is nil.
+	request := func(signer *ecdsa.PrivateKey, method, path, token string) context.Context {
+		t.Helper()
+		meta := util.RequestMeta{IPAddress: "203.0.113.5", Method: method, Path: path}
+		if signer != nil {
+			proof, err := dpop.Sign(signer, method, "https://vault.example.com"+path, token, time.Now())
+			if err != nil {
+				t.Fatalf("sign proof: %v", err)
+			}
+			meta.DPoPProof = proof
+		}
+		return util.WithRequestMeta(ctx, meta)
+	}
+
+	if _, err := svc.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	login, err := svc.Login(ctx, domain.LoginInput{Email: "ada@example.com", Password: "Correct-Horse-9"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	token := login.SessionToken
+
+	registerCtx := request(key, "POST", "/api/v1/auth/dpop-key", token)
+	session, err := svc.Authenticate(registerCtx, token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if _, err := svc.RegisterDPoPKey(registerCtx, session, token, "web"); !errors.Is(err, domain.ErrDPoPNotEnabled) {
+		t.Fatalf("register for a web client err = %v, want ErrDPoPNotEnabled", err)
+	}
+	jkt, err := svc.RegisterDPoPKey(registerCtx, session, token, "Desktop")
+	if err != nil {
+		t.Fatalf("RegisterDPoPKey: %v", err)
+	}
+	if want, _ := dpop.Thumbprint(&key.PublicKey); jkt != want {
+		t.Fatalf("jkt = %s, want %s", jkt, want)
+	}
+
+	// The token alone, or with a proof from another key, is refused.
+	if _, err := svc.Authenticate(request(nil, "GET", "/api/v1/auth/me", token), token); !errors.Is(err, domain.ErrInvalidDPoPProof) {
+		t.Fatalf("authenticate without a proof = %v, want ErrInvalidDPoPProof", err)
+	}
+	if _, err := svc.Authenticate(request(thief, "GET", "/api/v1/auth/me", token), token); !errors.Is(err, domain.ErrInvalidDPoPProof) {
+		t.Fatalf("authenticate with another key = %v, want ErrInvalidDPoPProof", err)
+	}
+
+	me := request(key, "GET", "/api/v1/auth/me", token)
+	if _, err := svc.Authenticate(me, token); err != nil {
+		t.Fatalf("authenticate with a proof: %v", err)
+	}
+	if _, err := svc.Authenticate(me, token); !errors.Is(err, domain.ErrInvalidDPoPProof) {
+		t.Fatalf("replayed proof = %v, want ErrInvalidDPoPProof", err)
+	}
+
+	// A refresh keeps the binding.
+	refreshed, err := svc.RefreshSession(request(key, "POST", "/api/v1/auth/refresh", token), token)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if _, err := svc.Authenticate(request(nil, "GET", "/api/v1/auth/me", refreshed.SessionToken), refreshed.SessionToken); !errors.Is(err, domain.ErrInvalidDPoPProof) {
+		t.Fatalf("refreshed token without a proof = %v, want ErrInvalidDPoPProof", err)
+	}
+	if _, err := svc.Authenticate(request(key, "GET", "/api/v1/auth/me", refreshed.SessionToken), refreshed.SessionToken); err != nil {
+		t.Fatalf("refreshed token with a proof: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+)
+
+// passwordFailures counts failed password attempts per account, in this
+// process only, like the IP ban strikes; with several replicas an account
+// under attack reaches the threshold on one of them later, not never.
+type passwordFailures struct {
+	threshold int
+	window    time.Duration
+
+	mu       sync.Mutex
+	attempts map[string][]time.Time // by user ID
+}
+
+// passwordFailuresSweepAt is how many accounts are tracked before those
+// without a recent failure are forgotten.
+const passwordFailuresSweepAt = 10000
+
+// record counts a failure at now and reports whether it brings the account
+// to the threshold within the window, along with when the first counted
+// failure happened. Reaching it starts the count over, so a steady attack
+// is reported once per threshold failures rather than on every one.
+func (f *passwordFailures) record(userID string, now time.Time) (time.Time, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cutoff := now.Add(-f.window)
+	if len(f.attempts) >= passwordFailuresSweepAt {
+		for id, times := range f.attempts {
+			if !times[len(times)-1].After(cutoff) {
+				delete(f.attempts, id)
+			}
+		}
+	}
+
+	recent := f.attempts[userID]
+	for len(recent) > 0 && !recent[0].After(cutoff) {
+		recent = recent[1:]
+	}
+	recent = append(recent, now)
+	if len(recent) < f.threshold {
+		f.attempts[userID] = recent
+		return time.Time{}, false
+	}
+	delete(f.attempts, userID)
+	return recent[0], true
+}
+
+// UseLoginFailureAlerts has threshold failed password attempts on one
+// account within window recorded as a security_login_failures_exceeded
+// event, which the notification service turns into an email. The MFA
+// lockout is always recorded that way.
+func (s *AuthService) UseLoginFailureAlerts(threshold int, window time.Duration) {
+	s.passwordFailures = &passwordFailures{threshold: threshold, window: window, attempts: make(map[string][]time.Time)}
+}
+
+// UseSessionRevokeLinks lets RevokeSessionsByLink accept the tokens links
+// mints.
+func (s *AuthService) UseSessionRevokeLinks(links *SessionRevokeLinks) {
+	s.revokeLinks = links
+}
+
+// recordPasswordFailure logs a wrong password for an existing account and
+// reports the account once failures reach the alert threshold.
+func (s *AuthService) recordPasswordFailure(ctx context.Context, userID string, now time.Time) {
+	uid, _ := uuid.Parse(userID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeAuthLoginFailed, map[string]string{
+		"reason": "invalid_password",
+	})
+	if s.passwordFailures == nil {
+		return
+	}
+	if since, reached := s.passwordFailures.record(userID, now); reached {
+		s.audit.LogEvent(ctx, &uid, domain.EventTypeSecurityLoginFailuresExceeded, domain.LoginFailuresExceeded{
+			Reason:         "password",
+			FailedAttempts: s.passwordFailures.threshold,
+			Since:          since,
+		})
+	}
+}
+
+// RevokeSessionsByLink signs the account a lockout alert's link was minted
+// for out of every session. It needs no session of its own: whoever holds
+// the email may use it.
+func (s *AuthService) RevokeSessionsByLink(ctx context.Context, token string) error {
+	if s.revokeLinks == nil {
+		return domain.ErrInvalidRevokeLink
+	}
+	userID, err := s.revokeLinks.Verify(token)
+	if err != nil {
+		return err
+	}
+
+	var revoked int64
+	err = withinTx(ctx, s.tx, func(ctx context.Context) error {
+		var err error
+		if revoked, err = s.repo.RevokeAllUserSessions(ctx, userID); err != nil {
+			return fmt.Errorf("revoke sessions by link: %w", err)
+		}
+		uid, _ := uuid.Parse(userID)
+		return s.audit.Record(ctx, &uid, domain.EventTypeAuthSessionsRevokedByLink, map[string]int64{"revoked": revoked})
+	})
+	if err != nil {
+		return err
+	}
+	s.sessionEvents.SessionsRevoked(userID)
+	return nil
+}
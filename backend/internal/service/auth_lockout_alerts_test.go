@@ -0,0 +1,79 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/mailer"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestLoginFailuresAlertSignsOutEverywhere(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	svc := service.NewAuthService(store.Auth(), store.Transactor(), audit, "pepper", time.Hour, "pmv2")
+	links := service.NewSessionRevokeLinks("pepper", 24*time.Hour)
+	svc.UseLoginFailureAlerts(3, time.Minute)
+	svc.UseSessionRevokeLinks(links)
+	emails := &captureEmailer{}
+	notifications := service.NewNotificationService(store.Notifications(), audit, emails)
+	notifications.UseSessionRevokeLinks(links, "https://app.example/")
+	audit.Subscribe(notifications.HandleAuditEvent)
+
+	if _, err := svc.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	session, err := svc.Login(ctx, domain.LoginInput{Email: "ada@example.com", Password: "Correct-Horse-9"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	emails.sent = nil // the new sign-in alert
+
+	for i := range 3 {
+		if len(emails.sent) != 0 {
+			t.Fatalf("alert sent after %d failures", i)
+		}
+		_, err := svc.Login(ctx, domain.LoginInput{Email: "ada@example.com", Password: "wrong"})
+		if !errors.Is(err, domain.ErrInvalidCredentials) {
+			t.Fatalf("wrong password: err = %v", err)
+		}
+	}
+	if len(emails.sent) != 1 || emails.sent[0].tmpl != mailer.TemplateLoginFailures {
+		t.Fatalf("sent = %+v", emails.sent)
+	}
+	data := emails.sent[0].data.(mailer.LoginFailuresData)
+	if data.Attempts != 3 || data.Detail != "email.login_failures.password" || len(data.Recent) != 3 {
+		t.Fatalf("data = %+v", data)
+	}
+
+	link, err := url.Parse(data.RevokeLink)
+	if err != nil || link.Host != "app.example" || link.Path != "/revoke-sessions" {
+		t.Fatalf("revoke link = %q", data.RevokeLink)
+	}
+	if err := svc.RevokeSessionsByLink(ctx, link.Query().Get("token")+"x"); !errors.Is(err, domain.ErrInvalidRevokeLink) {
+		t.Fatalf("tampered token: err = %v", err)
+	}
+	if err := svc.RevokeSessionsByLink(ctx, link.Query().Get("token")); err != nil {
+		t.Fatalf("revoke by link: %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, session.SessionToken); err == nil {
+		t.Fatal("the session outlived the sign-out link")
+	}
+}
+
+func TestSessionRevokeLinksExpire(t *testing.T) {
+	links := service.NewSessionRevokeLinks("pepper", -time.Second)
+	if _, err := links.Verify(links.New(notificationTestUser)); !errors.Is(err, domain.ErrInvalidRevokeLink) {
+		t.Fatalf("expired token: err = %v", err)
+	}
+	other := service.NewSessionRevokeLinks("another pepper", time.Hour)
+	if _, err := links.Verify(other.New(notificationTestUser)); !errors.Is(err, domain.ErrInvalidRevokeLink) {
+		t.Fatalf("token signed with another key: err = %v", err)
+	}
+}
@@ -0,0 +1,405 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/util"
+	"pmv2/backend/internal/webauthn"
+)
+
+// passkeyChallengeTTL is how long a client has to answer a passkey
+// challenge.
+const passkeyChallengeTTL = 5 * time.Minute
+
+// UsePasskeys enables signing in with passkeys, and accounts that have
+// passkeys only. Without it the passkey flows report ErrNotFound.
+func (s *AuthService) UsePasskeys(passkeys domain.PasskeyRepository, rp webauthn.RelyingParty) {
+	s.passkeys = passkeys
+	s.relyingParty = rp
+}
+
+// RelyingParty is the site passkeys are created for.
+func (s *AuthService) RelyingParty() webauthn.RelyingParty {
+	return s.relyingParty
+}
+
+// BeginPasskeyRegistration starts creating a passkey-only account. The
+// challenge carries the user ID the account will get.
+func (s *AuthService) BeginPasskeyRegistration(ctx context.Context, email, name string) (domain.PasskeyChallenge, error) {
+	normalizedEmail := util.NormalizeEmail(email)
+	if normalizedEmail == "" {
+		return domain.PasskeyChallenge{}, domain.ErrInvalidCredentials
+	}
+	userID, err := util.NewUUID()
+	if err != nil {
+		return domain.PasskeyChallenge{}, err
+	}
+	return s.newPasskeyChallenge(ctx, domain.PasskeyChallenge{
+		Purpose: domain.PasskeyChallengeRegisterAccount,
+		UserID:  userID,
+		Email:   normalizedEmail,
+		Name:    util.TrimOrEmpty(name),
+	})
+}
+
+// FinishPasskeyRegistration creates the account a registration challenge
+// was issued for, with the new passkey as its only credential, and signs
+// it in.
+func (s *AuthService) FinishPasskeyRegistration(ctx context.Context, reg domain.PasskeyRegistration, login domain.LoginInput) (domain.LoginOutput, error) {
+	challenge, err := s.consumePasskeyChallenge(ctx, reg.ChallengeID, domain.PasskeyChallengeRegisterAccount)
+	if err != nil {
+		return domain.LoginOutput{}, err
+	}
+	passkey, err := s.verifyPasskeyRegistration(challenge, reg)
+	if err != nil {
+		return domain.LoginOutput{}, err
+	}
+	salt, err := util.NewSalt()
+	if err != nil {
+		return domain.LoginOutput{}, err
+	}
+
+	var account domain.RegisterOutput
+	err = withinTx(ctx, s.tx, func(ctx context.Context) error {
+		var err error
+		account, err = s.createAccount(ctx, domain.CreateUserInput{
+			UserID: challenge.UserID,
+			Email:  challenge.Email,
+			Name:   challenge.Name,
+			Salt:   salt,
+		}, map[string]any{"method": "passkey"})
+		if err != nil {
+			return err
+		}
+		_, err = s.passkeys.CreatePasskey(ctx, passkey)
+		return err
+	})
+	if err != nil {
+		return domain.LoginOutput{}, err
+	}
+
+	return s.startSession(ctx, domain.UserAuthRecord{
+		UserID: account.UserID,
+		Email:  account.Email,
+		Name:   account.Name,
+		Salt:   salt,
+	}, login, map[string]any{"method": "passkey"})
+}
+
+// BeginPasskeyLogin starts a sign-in with any passkey the authenticator
+// holds for this site.
+func (s *AuthService) BeginPasskeyLogin(ctx context.Context) (domain.PasskeyChallenge, error) {
+	return s.newPasskeyChallenge(ctx, domain.PasskeyChallenge{Purpose: domain.PasskeyChallengeLogin})
+}
+
+// FinishPasskeyLogin signs in with a passkey. The authenticator verified
+// the user, so no TOTP code is asked for.
+func (s *AuthService) FinishPasskeyLogin(ctx context.Context, assertion domain.PasskeyAssertion, login domain.LoginInput) (domain.PasskeyLoginOutput, error) {
+	passkey, err := s.verifyPasskeyAssertion(ctx, domain.PasskeyChallengeLogin, "", assertion)
+	if err != nil {
+		return domain.PasskeyLoginOutput{}, err
+	}
+
+	record, err := s.repo.GetUserAuthByID(ctx, passkey.UserID)
+	if err != nil {
+		return domain.PasskeyLoginOutput{}, fmt.Errorf("read auth record: %w", err)
+	}
+	output, err := s.startSession(ctx, record, login, map[string]any{"method": "passkey", "passkey_id": passkey.ID})
+	if err != nil {
+		return domain.PasskeyLoginOutput{}, err
+	}
+	return domain.PasskeyLoginOutput{LoginOutput: output, Passkey: passkey}, nil
+}
+
+// BeginPasskeySecondFactor starts answering the second-factor step of a
+// password sign-in with a passkey instead of a TOTP code. The challenge is
+// not tied to an account, so asking for one reveals nothing about it; Login
+// checks that the passkey that answers it belongs to the account.
+func (s *AuthService) BeginPasskeySecondFactor(ctx context.Context) (domain.PasskeyChallenge, error) {
+	return s.newPasskeyChallenge(ctx, domain.PasskeyChallenge{Purpose: domain.PasskeyChallengeSecondFactor})
+}
+
+// verifyPasskeySecondFactor checks an assertion answering the second-factor
+// step of userID's sign-in and returns the ID of the passkey that made it.
+func (s *AuthService) verifyPasskeySecondFactor(ctx context.Context, userID string, assertion domain.PasskeyAssertion) (string, error) {
+	if s.passkeys == nil {
+		return "", domain.ErrInvalidPasskey
+	}
+	passkey, err := s.verifyPasskeyAssertion(ctx, domain.PasskeyChallengeSecondFactor, userID, assertion)
+	if err != nil {
+		return "", err
+	}
+	return passkey.ID, nil
+}
+
+// BeginAddPasskey starts adding a passkey to a signed-in account.
+func (s *AuthService) BeginAddPasskey(ctx context.Context, userID, email string) (domain.PasskeyChallenge, error) {
+	if s.passkeys == nil {
+		return domain.PasskeyChallenge{}, domain.ErrNotFound
+	}
+	existing, err := s.passkeys.ListPasskeys(ctx, userID)
+	if err != nil {
+		return domain.PasskeyChallenge{}, fmt.Errorf("list passkeys: %w", err)
+	}
+	if len(existing) >= domain.MaxPasskeysPerUser {
+		return domain.PasskeyChallenge{}, domain.ErrPasskeyLimit
+	}
+	return s.newPasskeyChallenge(ctx, domain.PasskeyChallenge{
+		Purpose: domain.PasskeyChallengeAddPasskey,
+		UserID:  userID,
+		Email:   email,
+	})
+}
+
+// FinishAddPasskey registers the passkey created for an add challenge.
+func (s *AuthService) FinishAddPasskey(ctx context.Context, userID string, reg domain.PasskeyRegistration) (domain.Passkey, error) {
+	challenge, err := s.consumePasskeyChallenge(ctx, reg.ChallengeID, domain.PasskeyChallengeAddPasskey)
+	if err != nil {
+		return domain.Passkey{}, err
+	}
+	if challenge.UserID != userID {
+		return domain.Passkey{}, domain.ErrInvalidPasskey
+	}
+	passkey, err := s.verifyPasskeyRegistration(challenge, reg)
+	if err != nil {
+		return domain.Passkey{}, err
+	}
+	created, err := s.passkeys.CreatePasskey(ctx, passkey)
+	if err != nil {
+		return domain.Passkey{}, err
+	}
+
+	uid, _ := uuid.Parse(userID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeAuthPasskeyAdded, map[string]string{"passkey_id": created.ID})
+	return created, nil
+}
+
+// ListPasskeys returns the user's passkeys, oldest first.
+func (s *AuthService) ListPasskeys(ctx context.Context, userID string) ([]domain.Passkey, error) {
+	if s.passkeys == nil {
+		return []domain.Passkey{}, nil
+	}
+	return s.passkeys.ListPasskeys(ctx, userID)
+}
+
+// RemovePasskey deletes one of the user's passkeys. An account without a
+// password cannot remove its last one.
+func (s *AuthService) RemovePasskey(ctx context.Context, userID, passkeyID string) error {
+	if s.passkeys == nil {
+		return domain.ErrNotFound
+	}
+	if _, err := uuid.Parse(passkeyID); err != nil {
+		return domain.ErrNotFound
+	}
+	return withinTx(ctx, s.tx, func(ctx context.Context) error {
+		record, err := s.repo.GetUserAuthByID(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("read auth record: %w", err)
+		}
+		if record.Passwordless() {
+			existing, err := s.passkeys.ListPasskeys(ctx, userID)
+			if err != nil {
+				return fmt.Errorf("list passkeys: %w", err)
+			}
+			if len(existing) <= 1 {
+				return domain.ErrLastPasskey
+			}
+		}
+		if err := s.passkeys.DeletePasskey(ctx, userID, passkeyID); err != nil {
+			return err
+		}
+		uid, _ := uuid.Parse(userID)
+		return s.audit.Record(ctx, &uid, domain.EventTypeAuthPasskeyRemoved, map[string]string{"passkey_id": passkeyID})
+	})
+}
+
+// BeginPasskeyRecovery starts enrolling a new passkey for an account whose
+// recovery key was verified by VerifyRecoveryKey; recoveryToken is the
+// token that returned.
+func (s *AuthService) BeginPasskeyRecovery(ctx context.Context, recoveryToken string) (domain.PasskeyChallenge, error) {
+	if s.passkeys == nil {
+		return domain.PasskeyChallenge{}, domain.ErrNotFound
+	}
+	session, err := s.recoverySession(ctx, recoveryToken)
+	if err != nil {
+		return domain.PasskeyChallenge{}, err
+	}
+	return s.newPasskeyChallenge(ctx, domain.PasskeyChallenge{
+		Purpose: domain.PasskeyChallengeRecover,
+		UserID:  session.UserID,
+		Email:   session.Email,
+	})
+}
+
+// FinishPasskeyRecovery replaces every passkey of the account with the new
+// one, signs out every session and signs the account back in. It is the
+// passkey counterpart of ResetPassword.
+func (s *AuthService) FinishPasskeyRecovery(ctx context.Context, recoveryToken string, reg domain.PasskeyRegistration, login domain.LoginInput) (domain.LoginOutput, error) {
+	session, err := s.recoverySession(ctx, recoveryToken)
+	if err != nil {
+		return domain.LoginOutput{}, err
+	}
+	challenge, err := s.consumePasskeyChallenge(ctx, reg.ChallengeID, domain.PasskeyChallengeRecover)
+	if err != nil {
+		return domain.LoginOutput{}, err
+	}
+	if challenge.UserID != session.UserID {
+		return domain.LoginOutput{}, domain.ErrInvalidPasskey
+	}
+	passkey, err := s.verifyPasskeyRegistration(challenge, reg)
+	if err != nil {
+		return domain.LoginOutput{}, err
+	}
+
+	var record domain.UserAuthRecord
+	err = withinTx(ctx, s.tx, func(ctx context.Context) error {
+		var err error
+		record, err = s.repo.GetUserAuthByID(ctx, session.UserID)
+		if err != nil {
+			return fmt.Errorf("read auth record for recovery: %w", err)
+		}
+		if err := s.passkeys.DeleteAllPasskeys(ctx, session.UserID); err != nil {
+			return err
+		}
+		if _, err := s.passkeys.CreatePasskey(ctx, passkey); err != nil {
+			return err
+		}
+		if _, err := s.repo.RevokeAllUserSessions(ctx, session.UserID); err != nil {
+			return fmt.Errorf("revoke sessions after recovery: %w", err)
+		}
+		if err := s.repo.UpdateLastRecoveryAt(ctx, session.UserID); err != nil {
+			return fmt.Errorf("update last recovery timestamp: %w", err)
+		}
+		uid, _ := uuid.Parse(session.UserID)
+		return s.audit.Record(ctx, &uid, domain.EventTypeAuthPasskeyRecovered, map[string]string{
+			"method":     "recovery_key",
+			"passkey_id": passkey.ID,
+		})
+	})
+	if err != nil {
+		return domain.LoginOutput{}, err
+	}
+	s.sessionEvents.SessionsRevoked(record.UserID)
+	return s.startSession(ctx, record, login, map[string]any{"method": "passkey"})
+}
+
+// PurgePasskeyChallenges deletes challenges nobody answered in time.
+func (s *AuthService) PurgePasskeyChallenges(ctx context.Context) (int64, error) {
+	if s.passkeys == nil {
+		return 0, nil
+	}
+	return s.passkeys.DeleteExpiredPasskeyChallenges(ctx, s.now().UTC())
+}
+
+func (s *AuthService) newPasskeyChallenge(ctx context.Context, challenge domain.PasskeyChallenge) (domain.PasskeyChallenge, error) {
+	if s.passkeys == nil {
+		return domain.PasskeyChallenge{}, domain.ErrNotFound
+	}
+	random, err := webauthn.NewChallenge()
+	if err != nil {
+		return domain.PasskeyChallenge{}, err
+	}
+	challenge.ID = uuid.NewString()
+	challenge.Challenge = random
+	challenge.ExpiresAt = s.now().UTC().Add(passkeyChallengeTTL)
+	if err := s.passkeys.CreatePasskeyChallenge(ctx, challenge); err != nil {
+		return domain.PasskeyChallenge{}, fmt.Errorf("store passkey challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// consumePasskeyChallenge takes the challenge out of the store, so it can
+// be answered once only.
+func (s *AuthService) consumePasskeyChallenge(ctx context.Context, id string, purpose domain.PasskeyChallengePurpose) (domain.PasskeyChallenge, error) {
+	if s.passkeys == nil {
+		return domain.PasskeyChallenge{}, domain.ErrNotFound
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return domain.PasskeyChallenge{}, domain.ErrInvalidPasskey
+	}
+	challenge, err := s.passkeys.ConsumePasskeyChallenge(ctx, id, purpose, s.now().UTC())
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.PasskeyChallenge{}, domain.ErrInvalidPasskey
+		}
+		return domain.PasskeyChallenge{}, fmt.Errorf("consume passkey challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// verifyPasskeyAssertion checks an assertion answering a challenge issued
+// for purpose and records the use of the passkey that made it. A non-empty
+// userID is the account the passkey has to belong to.
+func (s *AuthService) verifyPasskeyAssertion(ctx context.Context, purpose domain.PasskeyChallengePurpose, userID string, assertion domain.PasskeyAssertion) (domain.Passkey, error) {
+	challenge, err := s.consumePasskeyChallenge(ctx, assertion.ChallengeID, purpose)
+	if err != nil {
+		return domain.Passkey{}, err
+	}
+	passkey, err := s.passkeys.GetPasskeyByCredentialID(ctx, assertion.CredentialID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.Passkey{}, domain.ErrInvalidPasskey
+		}
+		return domain.Passkey{}, fmt.Errorf("read passkey: %w", err)
+	}
+	if userID != "" && passkey.UserID != userID {
+		return domain.Passkey{}, domain.ErrInvalidPasskey
+	}
+	signCount, err := s.relyingParty.VerifyAssertion(challenge.Challenge, assertion.ClientDataJSON, assertion.AuthenticatorData, assertion.Signature, passkey.PublicKey, passkey.SignCount)
+	if err != nil {
+		slog.WarnContext(ctx, "passkey assertion rejected", slog.String("passkey_id", passkey.ID), slog.Any("error", err))
+		return domain.Passkey{}, domain.ErrInvalidPasskey
+	}
+
+	usedAt := s.now().UTC()
+	if err := s.passkeys.TouchPasskey(ctx, passkey.ID, signCount, usedAt); err != nil {
+		return domain.Passkey{}, fmt.Errorf("record passkey use: %w", err)
+	}
+	passkey.SignCount = signCount
+	passkey.LastUsedAt = &usedAt
+	return passkey, nil
+}
+
+// verifyPasskeyRegistration checks a new credential and returns it as a
+// passkey of the challenge's user.
+func (s *AuthService) verifyPasskeyRegistration(challenge domain.PasskeyChallenge, reg domain.PasskeyRegistration) (domain.Passkey, error) {
+	signCount, err := s.relyingParty.VerifyRegistration(challenge.Challenge, reg.CredentialID, reg.ClientDataJSON, reg.AuthenticatorData, reg.PublicKey)
+	if err != nil {
+		slog.Warn("passkey registration rejected", slog.Any("error", err))
+		return domain.Passkey{}, domain.ErrInvalidPasskey
+	}
+	return domain.Passkey{
+		ID:           uuid.NewString(),
+		UserID:       challenge.UserID,
+		CredentialID: reg.CredentialID,
+		PublicKey:    reg.PublicKey,
+		SignCount:    signCount,
+		Name:         util.TrimOrEmpty(reg.Name),
+		PRFSalt:      reg.PRFSalt,
+		WrappedKey:   reg.WrappedKey,
+		WrapNonce:    reg.WrapNonce,
+	}, nil
+}
+
+// recoverySession returns the short-lived session VerifyRecoveryKey issued
+// recoveryToken for.
+func (s *AuthService) recoverySession(ctx context.Context, recoveryToken string) (domain.Session, error) {
+	if util.TrimOrEmpty(recoveryToken) == "" {
+		return domain.Session{}, domain.ErrInvalidRecoveryToken
+	}
+	session, err := s.repo.GetActiveSessionByTokenHash(ctx, util.HashToken(recoveryToken, s.pepper))
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.Session{}, domain.ErrInvalidRecoveryToken
+		}
+		return domain.Session{}, fmt.Errorf("validate recovery token: %w", err)
+	}
+	return session, nil
+}
@@ -0,0 +1,234 @@
+package service_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+	"pmv2/backend/internal/webauthn"
+)
+
+// softPasskey is a software authenticator for example.com.
+type softPasskey struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	counter      uint32
+}
+
+func newSoftPasskey(t *testing.T, credentialID string) *softPasskey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return &softPasskey{key: key, credentialID: []byte(credentialID)}
+}
+
+func (p *softPasskey) clientData(typ string, challenge []byte) []byte {
+	b, _ := json.Marshal(map[string]string{
+		"type":      typ,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    "https://example.com",
+	})
+	return b
+}
+
+func (p *softPasskey) authData(attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte("example.com"))
+	flags := byte(webauthn.FlagUserPresent | webauthn.FlagUserVerified)
+	if attested {
+		flags |= webauthn.FlagAttested
+	}
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, p.counter)
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(p.credentialID)))
+		data = append(data, p.credentialID...)
+	}
+	return data
+}
+
+func (p *softPasskey) register(t *testing.T, challenge domain.PasskeyChallenge) domain.PasskeyRegistration {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&p.key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	return domain.PasskeyRegistration{
+		ChallengeID:       challenge.ID,
+		CredentialID:      p.credentialID,
+		ClientDataJSON:    p.clientData("webauthn.create", challenge.Challenge),
+		AuthenticatorData: p.authData(true),
+		PublicKey:         der,
+		Name:              "Laptop",
+		PRFSalt:           []byte("prf-salt"),
+		WrappedKey:        []byte("wrapped-vault-key"),
+		WrapNonce:         []byte("nonce"),
+	}
+}
+
+func (p *softPasskey) assert(t *testing.T, challenge domain.PasskeyChallenge) domain.PasskeyAssertion {
+	t.Helper()
+	p.counter++
+	clientData := p.clientData("webauthn.get", challenge.Challenge)
+	authData := p.authData(false)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, p.key, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return domain.PasskeyAssertion{
+		ChallengeID:       challenge.ID,
+		CredentialID:      p.credentialID,
+		ClientDataJSON:    clientData,
+		AuthenticatorData: authData,
+		Signature:         sig,
+	}
+}
+
+func TestPasskeyOnlyAccount(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	svc := service.NewAuthService(store.Auth(), store.Transactor(), nil, "pepper", time.Hour, "pmv2")
+	svc.UsePasskeys(store.Passkeys(), webauthn.RelyingParty{ID: "example.com", Name: "Example", Origins: []string{"https://example.com"}})
+	laptop := newSoftPasskey(t, "laptop")
+
+	challenge, err := svc.BeginPasskeyRegistration(ctx, "Ada@Example.com", "Ada")
+	if err != nil {
+		t.Fatalf("BeginPasskeyRegistration: %v", err)
+	}
+	account, err := svc.FinishPasskeyRegistration(ctx, laptop.register(t, challenge), domain.LoginInput{})
+	if err != nil || account.SessionToken == "" || account.Email != "ada@example.com" {
+		t.Fatalf("FinishPasskeyRegistration = %+v, %v", account, err)
+	}
+	if _, err := svc.FinishPasskeyRegistration(ctx, laptop.register(t, challenge), domain.LoginInput{}); !errors.Is(err, domain.ErrInvalidPasskey) {
+		t.Fatalf("replayed registration err = %v, want ErrInvalidPasskey", err)
+	}
+
+	// There is no password to sign in with.
+	if _, err := svc.Login(ctx, domain.LoginInput{Email: "ada@example.com", Password: "Correct-Horse-9"}); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("password login err = %v, want ErrInvalidCredentials", err)
+	}
+
+	challenge, err = svc.BeginPasskeyLogin(ctx)
+	if err != nil {
+		t.Fatalf("BeginPasskeyLogin: %v", err)
+	}
+	assertion := laptop.assert(t, challenge)
+	out, err := svc.FinishPasskeyLogin(ctx, assertion, domain.LoginInput{})
+	if err != nil || out.UserID != account.UserID || string(out.Passkey.WrappedKey) != "wrapped-vault-key" {
+		t.Fatalf("FinishPasskeyLogin = %+v, %v", out, err)
+	}
+	if _, err := svc.FinishPasskeyLogin(ctx, assertion, domain.LoginInput{}); !errors.Is(err, domain.ErrInvalidPasskey) {
+		t.Fatalf("replayed assertion err = %v, want ErrInvalidPasskey", err)
+	}
+
+	if err := svc.RemovePasskey(ctx, account.UserID, out.Passkey.ID); !errors.Is(err, domain.ErrLastPasskey) {
+		t.Fatalf("RemovePasskey(last) err = %v, want ErrLastPasskey", err)
+	}
+
+	phone := newSoftPasskey(t, "phone")
+	challenge, err = svc.BeginAddPasskey(ctx, account.UserID, account.Email)
+	if err != nil {
+		t.Fatalf("BeginAddPasskey: %v", err)
+	}
+	if _, err := svc.FinishAddPasskey(ctx, account.UserID, phone.register(t, challenge)); err != nil {
+		t.Fatalf("FinishAddPasskey: %v", err)
+	}
+	if err := svc.RemovePasskey(ctx, account.UserID, out.Passkey.ID); err != nil {
+		t.Fatalf("RemovePasskey: %v", err)
+	}
+	passkeys, err := svc.ListPasskeys(ctx, account.UserID)
+	if err != nil || len(passkeys) != 1 || string(passkeys[0].CredentialID) != "phone" {
+		t.Fatalf("ListPasskeys = %+v, %v", passkeys, err)
+	}
+}
+
+func TestPasskeyAsSecondFactor(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	svc := service.NewAuthService(store.Auth(), store.Transactor(), nil, "pepper", time.Hour, "pmv2")
+	svc.UsePasskeys(store.Passkeys(), webauthn.RelyingParty{ID: "example.com", Name: "Example", Origins: []string{"https://example.com"}})
+
+	ada, err := svc.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := store.Auth().EnableTOTP(ctx, ada.UserID); err != nil {
+		t.Fatalf("enable totp: %v", err)
+	}
+	key := newSoftPasskey(t, "security-key")
+	challenge, err := svc.BeginAddPasskey(ctx, ada.UserID, ada.Email)
+	if err != nil {
+		t.Fatalf("BeginAddPasskey: %v", err)
+	}
+	if _, err := svc.FinishAddPasskey(ctx, ada.UserID, key.register(t, challenge)); err != nil {
+		t.Fatalf("FinishAddPasskey: %v", err)
+	}
+
+	// A passkey of another account does not answer for Ada.
+	stranger, err := svc.BeginPasskeyRegistration(ctx, "bob@example.com", "Bob")
+	if err != nil {
+		t.Fatalf("BeginPasskeyRegistration: %v", err)
+	}
+	bobKey := newSoftPasskey(t, "bob")
+	if _, err := svc.FinishPasskeyRegistration(ctx, bobKey.register(t, stranger), domain.LoginInput{}); err != nil {
+		t.Fatalf("FinishPasskeyRegistration: %v", err)
+	}
+
+	login := func(assertion *domain.PasskeyAssertion, totpCode string) (domain.LoginOutput, error) {
+		return svc.Login(ctx, domain.LoginInput{Email: "ada@example.com", Password: "Correct-Horse-9", TOTPCode: totpCode, PasskeyAssertion: assertion})
+	}
+	answer := func(p *softPasskey) *domain.PasskeyAssertion {
+		t.Helper()
+		challenge, err := svc.BeginPasskeySecondFactor(ctx)
+		if err != nil {
+			t.Fatalf("BeginPasskeySecondFactor: %v", err)
+		}
+		assertion := p.assert(t, challenge)
+		return &assertion
+	}
+
+	if _, err := login(nil, ""); !errors.Is(err, domain.ErrMFARequired) {
+		t.Fatalf("login without a second factor err = %v, want ErrMFARequired", err)
+	}
+	if _, err := login(answer(key), "123456"); !errors.Is(err, domain.ErrInvalidMFAInput) {
+		t.Fatalf("login with a passkey and a code err = %v, want ErrInvalidMFAInput", err)
+	}
+	if _, err := login(answer(bobKey), ""); !errors.Is(err, domain.ErrInvalidMFA) {
+		t.Fatalf("login with another account's passkey err = %v, want ErrInvalidMFA", err)
+	}
+
+	// A sign-in challenge cannot stand in for a second-factor one.
+	loginChallenge, err := svc.BeginPasskeyLogin(ctx)
+	if err != nil {
+		t.Fatalf("BeginPasskeyLogin: %v", err)
+	}
+	wrongPurpose := key.assert(t, loginChallenge)
+	if _, err := login(&wrongPurpose, ""); !errors.Is(err, domain.ErrInvalidMFA) {
+		t.Fatalf("login answering a sign-in challenge err = %v, want ErrInvalidMFA", err)
+	}
+
+	assertion := answer(key)
+	out, err := login(assertion, "")
+	if err != nil || out.UserID != ada.UserID || out.SessionToken == "" {
+		t.Fatalf("login with a passkey = %+v, %v", out, err)
+	}
+	if _, err := login(assertion, ""); !errors.Is(err, domain.ErrInvalidMFA) {
+		t.Fatalf("replayed assertion err = %v, want ErrInvalidMFA", err)
+	}
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/mailer"
+	"pmv2/backend/internal/util"
+)
+
+const (
+	// passwordResetTTL is how long an emailed password reset link works.
+	passwordResetTTL = time.Hour
+	// passwordResetsPerHour caps the reset emails an account gets in an
+	// hour, however many times it is asked for.
+	passwordResetsPerHour = 3
+)
+
+// UsePasswordResets enables the forgot-password flow. Reset links are sent
+// through emails and open the reset page of frontendOrigin. Without it
+// requests are ignored and every token is ErrPasswordResetInvalid.
+func (s *AuthService) UsePasswordResets(resets domain.PasswordResetRepository, emails Emailer, frontendOrigin string) {
+	s.passwordResets = resets
+	s.passwordResetEmails = emails
+	s.frontendOrigin = strings.TrimRight(frontendOrigin, "/")
+}
+
+// RequestPasswordReset emails a single-use reset link to the account of
+// email. Whether there is one is not revealed: unknown emails, passkey-only
+// accounts and accounts that were already sent passwordResetsPerHour links
+// in the last hour get no email and the same nil error.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, email string) error {
+	normalizedEmail := util.NormalizeEmail(email)
+	if s.passwordResets == nil || s.passwordResetEmails == nil || normalizedEmail == "" {
+		return nil
+	}
+	record, err := s.repo.GetUserAuthByEmail(ctx, normalizedEmail)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("read auth record for password reset: %w", err)
+	}
+	if record.Passwordless() {
+		return nil
+	}
+
+	now := s.now().UTC()
+	sent, err := s.passwordResets.CountPasswordResets(ctx, record.UserID, now.Add(-time.Hour))
+	if err != nil {
+		return fmt.Errorf("count password resets: %w", err)
+	}
+	if sent >= passwordResetsPerHour {
+		return nil
+	}
+
+	token, err := util.NewOpaqueToken(32)
+	if err != nil {
+		return err
+	}
+	reset := domain.PasswordResetToken{
+		ID:        uuid.NewString(),
+		UserID:    record.UserID,
+		TokenHash: util.HashToken(token, s.pepper),
+		ExpiresAt: now.Add(passwordResetTTL),
+	}
+	// The email goes through the outbox, so it is only sent if the token
+	// it carries was stored.
+	err = withinTx(ctx, s.tx, func(ctx context.Context) error {
+		if err := s.passwordResets.CreatePasswordReset(ctx, reset); err != nil {
+			return fmt.Errorf("store password reset token: %w", err)
+		}
+		return s.passwordResetEmails.Send(ctx, record.Email, mailer.TemplateRecovery, mailer.RecoveryData{
+			Name:      record.Name,
+			Link:      s.frontendOrigin + "/reset-password?token=" + url.QueryEscape(token),
+			ExpiresIn: "1 hour",
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	uid, _ := uuid.Parse(record.UserID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeAuthPasswordResetRequested, map[string]string{
+		"password_reset_id": reset.ID,
+	})
+	return nil
+}
+
+// ConfirmPasswordReset sets a new master password with an emailed token.
+// The vault's key-encryption key is derived from the password, so the
+// item keys re-wrapped under the new one replace the old ones in the same
+// transaction that uses up the token and changes the password; see
+// domain.PasswordResetInput. Every session is signed out and none is
+// started: the account signs in with its new password, second factor
+// included.
+//
+// Item history and the recovery key's copy of the vault key are left
+// wrapped under the old key.
+func (s *AuthService) ConfirmPasswordReset(ctx context.Context, input domain.PasswordResetInput) error {
+	if s.passwordResets == nil || input.Token == "" {
+		return domain.ErrPasswordResetInvalid
+	}
+	if s.vault == nil {
+		return fmt.Errorf("password reset: vault keys are not configured")
+	}
+	now := s.now().UTC()
+	reset, err := s.passwordResets.GetPasswordReset(ctx, util.HashToken(input.Token, s.pepper), now)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.ErrPasswordResetInvalid
+		}
+		return fmt.Errorf("read password reset token: %w", err)
+	}
+	record, err := s.repo.GetUserAuthByID(ctx, reset.UserID)
+	if err != nil {
+		return fmt.Errorf("read auth record: %w", err)
+	}
+	if record.Passwordless() {
+		return domain.ErrPasswordlessAccount
+	}
+	credentials, err := s.hashNewPassword(ctx, record, input.NewPassword, input.NewSalt)
+	if err != nil {
+		return err
+	}
+
+	err = withinTx(ctx, s.tx, func(ctx context.Context) error {
+		// Using the token up first claims it: of two confirmations racing
+		// for it, only one changes the password.
+		if err := s.passwordResets.UsePasswordReset(ctx, reset.ID, reset.UserID, now); err != nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				return domain.ErrPasswordResetInvalid
+			}
+			return fmt.Errorf("use password reset token: %w", err)
+		}
+		if err := s.repo.UpdatePassword(ctx, credentials); err != nil {
+			return fmt.Errorf("update password: %w", err)
+		}
+		if err := s.vault.RewrapVaultItemKeys(ctx, record.UserID, input.Items); err != nil {
+			return err
+		}
+		revoked, err := s.repo.RevokeAllUserSessions(ctx, record.UserID)
+		if err != nil {
+			return fmt.Errorf("revoke sessions after password reset: %w", err)
+		}
+
+		uid, _ := uuid.Parse(record.UserID)
+		return s.audit.Record(ctx, &uid, domain.EventTypeAuthPasswordReset, map[string]any{
+			"method":            "email",
+			"password_reset_id": reset.ID,
+			"items_rewrapped":   len(input.Items),
+			"sessions_revoked":  revoked,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	s.sessionEvents.SessionsRevoked(record.UserID)
+	return nil
+}
+
+// PurgePasswordResets deletes reset tokens that have expired, used or not.
+func (s *AuthService) PurgePasswordResets(ctx context.Context) (int64, error) {
+	if s.passwordResets == nil {
+		return 0, nil
+	}
+	return s.passwordResets.DeleteExpiredPasswordResets(ctx, s.now().UTC())
+}
@@ -0,0 +1,115 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/mailer"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestPasswordResetByEmail(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	svc := service.NewAuthService(store.Auth(), store.Transactor(), audit, "pepper", time.Hour, "pmv2")
+	svc.UseVaultKeys(store.Vault())
+	emails := &captureEmailer{}
+	svc.UsePasswordResets(store.PasswordResets(), emails, "https://vault.example.com/")
+
+	user, err := svc.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	item, err := store.Vault().CreateVaultItem(ctx, domain.CreateVaultItemInput{
+		OwnerUserID: user.UserID, Ciphertext: []byte("secret"), Nonce: []byte("n"), WrappedDEK: []byte("old"), WrapNonce: []byte("w"), AlgoVersion: "v1",
+	})
+	if err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	login := func(password string) (domain.LoginOutput, error) {
+		return svc.Login(ctx, domain.LoginInput{Email: "ada@example.com", Password: password})
+	}
+	signedIn, err := login("Correct-Horse-9")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	// Unknown emails are answered the same and sent nothing.
+	if err := svc.RequestPasswordReset(ctx, "nobody@example.com"); err != nil || len(emails.sent) != 0 {
+		t.Fatalf("unknown email: err = %v, %d emails sent", err, len(emails.sent))
+	}
+	requestToken := func() string {
+		t.Helper()
+		before := len(emails.sent)
+		if err := svc.RequestPasswordReset(ctx, " Ada@Example.com "); err != nil {
+			t.Fatalf("request reset: %v", err)
+		}
+		if len(emails.sent) != before+1 {
+			t.Fatalf("%d emails sent, want one more", len(emails.sent)-before)
+		}
+		sent := emails.sent[len(emails.sent)-1]
+		data, ok := sent.data.(mailer.RecoveryData)
+		if sent.to != "ada@example.com" || sent.tmpl != mailer.TemplateRecovery || !ok {
+			t.Fatalf("sent %+v", sent)
+		}
+		link, err := url.Parse(data.Link)
+		if err != nil || link.Host != "vault.example.com" || link.Path != "/reset-password" {
+			t.Fatalf("link = %q, %v", data.Link, err)
+		}
+		return link.Query().Get("token")
+	}
+	stale := requestToken()
+	token := requestToken()
+
+	newSalt := bytes.Repeat([]byte{9}, 32)
+	keys := []domain.VaultItemKey{{ItemID: item.ID, WrappedDEK: []byte("new"), WrapNonce: []byte("w2")}}
+	confirm := func(token string, keys []domain.VaultItemKey) error {
+		return svc.ConfirmPasswordReset(ctx, domain.PasswordResetInput{Token: token, NewPassword: "Battery-Staple-7", NewSalt: newSalt, Items: keys})
+	}
+	if err := confirm("not-a-token", keys); !errors.Is(err, domain.ErrPasswordResetInvalid) {
+		t.Fatalf("unknown token: err = %v", err)
+	}
+	// A vault left wrapped under the old key is refused, and the token
+	// survives for another try.
+	if err := confirm(token, nil); !errors.Is(err, domain.ErrRewrapIncomplete) {
+		t.Fatalf("missing item: err = %v", err)
+	}
+	if err := confirm(token, keys); err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	if err := confirm(token, keys); !errors.Is(err, domain.ErrPasswordResetInvalid) {
+		t.Fatalf("token reused: err = %v", err)
+	}
+	if err := confirm(stale, keys); !errors.Is(err, domain.ErrPasswordResetInvalid) {
+		t.Fatalf("older token outlived the reset: err = %v", err)
+	}
+
+	if _, err := svc.Authenticate(ctx, signedIn.SessionToken); err == nil {
+		t.Fatal("a session outlived the password reset")
+	}
+	if got, err := store.Vault().GetVaultItemByIDForOwner(ctx, item.ID, user.UserID); err != nil || string(got.WrappedDEK) != "new" {
+		t.Fatalf("item = %+v, %v; want the re-wrapped key", got, err)
+	}
+	if _, err := login("Correct-Horse-9"); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("old password: err = %v", err)
+	}
+	if _, err := login("Battery-Staple-7"); err != nil {
+		t.Fatalf("new password: %v", err)
+	}
+
+	// Past the hourly cap further requests send nothing.
+	requestToken()
+	if err := svc.RequestPasswordReset(ctx, "ada@example.com"); err != nil {
+		t.Fatalf("request over the cap: %v", err)
+	}
+	if len(emails.sent) != 3 {
+		t.Fatalf("%d emails sent, want 3", len(emails.sent))
+	}
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/util"
+)
+
+// qrLoginTTL is how long a QR code sign-in request waits for approval.
+const qrLoginTTL = 2 * time.Minute
+
+// UseQRLogin enables signing a new device in by scanning its QR code with a
+// device that is already signed in. Without it the QR flows report
+// ErrQRLoginNotFound.
+func (s *AuthService) UseQRLogin(qrLogins domain.QRLoginRepository) {
+	s.qrLogins = qrLogins
+}
+
+// StartQRLogin opens a sign-in request for the device described by input.
+// The device shows the request's ID and code as a QR code and polls with
+// the poll token until the request is approved, denied or expires.
+func (s *AuthService) StartQRLogin(ctx context.Context, input domain.LoginInput) (domain.QRLoginStart, error) {
+	if s.qrLogins == nil {
+		return domain.QRLoginStart{}, domain.ErrQRLoginNotFound
+	}
+	code, err := util.NewOpaqueToken(16)
+	if err != nil {
+		return domain.QRLoginStart{}, err
+	}
+	pollToken, err := util.NewOpaqueToken(32)
+	if err != nil {
+		return domain.QRLoginStart{}, err
+	}
+	req := domain.QRLoginRequest{
+		ID:            uuid.NewString(),
+		CodeHash:      util.HashToken(code, s.pepper),
+		PollTokenHash: util.HashToken(pollToken, s.pepper),
+		Status:        domain.QRLoginPending,
+		DeviceName:    util.TrimOrEmpty(input.DeviceName),
+		IPAddr:        util.NormalizeIP(input.IPAddr),
+		UserAgent:     util.TrimOrEmpty(input.UserAgent),
+		ExpiresAt:     s.now().UTC().Add(qrLoginTTL),
+	}
+	if err := s.qrLogins.CreateQRLogin(ctx, req); err != nil {
+		return domain.QRLoginStart{}, fmt.Errorf("store qr login request: %w", err)
+	}
+	return domain.QRLoginStart{QRLoginRequest: req, Code: code, PollToken: pollToken}, nil
+}
+
+// GetQRLogin returns the request behind a scanned QR code, so the approver
+// can see which device is asking before deciding.
+func (s *AuthService) GetQRLogin(ctx context.Context, id, code string) (domain.QRLoginRequest, error) {
+	req, err := s.qrLogin(ctx, id)
+	if err != nil {
+		return domain.QRLoginRequest{}, err
+	}
+	if !s.tokenMatches(code, req.CodeHash) {
+		return domain.QRLoginRequest{}, domain.ErrQRLoginNotFound
+	}
+	return req, nil
+}
+
+// ApproveQRLogin lets the requesting device in to the account of session.
+func (s *AuthService) ApproveQRLogin(ctx context.Context, session domain.Session, id, code string) error {
+	return s.decideQRLogin(ctx, session, id, code, domain.QRLoginApproved)
+}
+
+func (s *AuthService) DenyQRLogin(ctx context.Context, session domain.Session, id, code string) error {
+	return s.decideQRLogin(ctx, session, id, code, domain.QRLoginDenied)
+}
+
+// PollQRLogin reports where a request stands to the device that opened it.
+// Once the request is approved the first poll signs the device in, under
+// the name it gave when it opened the request, and the request is gone; a
+// denied request is gone after the poll that reports it.
+func (s *AuthService) PollQRLogin(ctx context.Context, id, pollToken string, input domain.LoginInput) (domain.QRLoginPoll, error) {
+	req, err := s.qrLogin(ctx, id)
+	if err != nil {
+		return domain.QRLoginPoll{}, err
+	}
+	if !s.tokenMatches(pollToken, req.PollTokenHash) {
+		return domain.QRLoginPoll{}, domain.ErrQRLoginNotFound
+	}
+
+	switch req.Status {
+	case domain.QRLoginPending:
+		return domain.QRLoginPoll{Status: req.Status}, nil
+	case domain.QRLoginDenied:
+		if err := s.qrLogins.DeleteQRLogin(ctx, id); err != nil && !errors.Is(err, domain.ErrNotFound) {
+			return domain.QRLoginPoll{}, fmt.Errorf("delete qr login request: %w", err)
+		}
+		return domain.QRLoginPoll{}, domain.ErrQRLoginDenied
+	}
+
+	// Deleting the request claims it: of two polls racing for it, only
+	// one gets a session.
+	if err := s.qrLogins.DeleteQRLogin(ctx, id); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.QRLoginPoll{}, domain.ErrQRLoginNotFound
+		}
+		return domain.QRLoginPoll{}, fmt.Errorf("claim qr login request: %w", err)
+	}
+	record, err := s.repo.GetUserAuthByID(ctx, req.UserID)
+	if err != nil {
+		return domain.QRLoginPoll{}, fmt.Errorf("read auth record: %w", err)
+	}
+	input.DeviceName = req.DeviceName
+	output, err := s.startSession(ctx, record, input, map[string]any{"method": "qr", "qr_login_id": id})
+	if err != nil {
+		return domain.QRLoginPoll{}, err
+	}
+	return domain.QRLoginPoll{Status: domain.QRLoginApproved, Login: output}, nil
+}
+
+// PurgeQRLogins deletes requests nobody decided on or collected in time.
+func (s *AuthService) PurgeQRLogins(ctx context.Context) (int64, error) {
+	if s.qrLogins == nil {
+		return 0, nil
+	}
+	return s.qrLogins.DeleteExpiredQRLogins(ctx, s.now().UTC())
+}
+
+func (s *AuthService) decideQRLogin(ctx context.Context, session domain.Session, id, code string, status domain.QRLoginStatus) error {
+	req, err := s.GetQRLogin(ctx, id, code)
+	if err != nil {
+		return err
+	}
+	if err := s.qrLogins.DecideQRLogin(ctx, id, session.UserID, status, s.now().UTC()); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.ErrQRLoginNotFound
+		}
+		return fmt.Errorf("decide qr login request: %w", err)
+	}
+
+	eventType := domain.EventTypeAuthQRLoginApproved
+	if status == domain.QRLoginDenied {
+		eventType = domain.EventTypeAuthQRLoginDenied
+	}
+	uid, _ := uuid.Parse(session.UserID)
+	s.audit.LogEvent(ctx, &uid, eventType, map[string]string{
+		"qr_login_id":       id,
+		"session_id":        session.ID,
+		"device_ip_address": req.IPAddr,
+		"device_user_agent": req.UserAgent,
+	})
+	return nil
+}
+
+func (s *AuthService) qrLogin(ctx context.Context, id string) (domain.QRLoginRequest, error) {
+	if s.qrLogins == nil {
+		return domain.QRLoginRequest{}, domain.ErrQRLoginNotFound
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return domain.QRLoginRequest{}, domain.ErrQRLoginNotFound
+	}
+	req, err := s.qrLogins.GetQRLogin(ctx, id, s.now().UTC())
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.QRLoginRequest{}, domain.ErrQRLoginNotFound
+		}
+		return domain.QRLoginRequest{}, fmt.Errorf("read qr login request: %w", err)
+	}
+	return req, nil
+}
+
+func (s *AuthService) tokenMatches(token string, hash []byte) bool {
+	return token != "" && subtle.ConstantTimeCompare(util.HashToken(token, s.pepper), hash) == 1
+}
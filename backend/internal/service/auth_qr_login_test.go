@@ -0,0 +1,101 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestQRLogin(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	svc := service.NewAuthService(store.Auth(), store.Transactor(), nil, "pepper", time.Hour, "pmv2")
+	svc.UseQRLogin(store.QRLogins())
+
+	if _, err := svc.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	phone, err := svc.Login(ctx, domain.LoginInput{Email: "ada@example.com", Password: "Correct-Horse-9"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	session, err := svc.Authenticate(ctx, phone.SessionToken)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+
+	start, err := svc.StartQRLogin(ctx, domain.LoginInput{DeviceName: "Office PC", IPAddr: "203.0.113.7", UserAgent: "Firefox/131"})
+	if err != nil {
+		t.Fatalf("StartQRLogin: %v", err)
+	}
+	if poll, err := svc.PollQRLogin(ctx, start.ID, start.PollToken, domain.LoginInput{}); err != nil || poll.Status != domain.QRLoginPending {
+		t.Fatalf("PollQRLogin(pending) = %+v, %v", poll, err)
+	}
+	if _, err := svc.PollQRLogin(ctx, start.ID, start.Code, domain.LoginInput{}); !errors.Is(err, domain.ErrQRLoginNotFound) {
+		t.Fatalf("PollQRLogin(wrong token) err = %v, want ErrQRLoginNotFound", err)
+	}
+	if err := svc.ApproveQRLogin(ctx, session, start.ID, "wrong"); !errors.Is(err, domain.ErrQRLoginNotFound) {
+		t.Fatalf("ApproveQRLogin(wrong code) err = %v, want ErrQRLoginNotFound", err)
+	}
+
+	req, err := svc.GetQRLogin(ctx, start.ID, start.Code)
+	if err != nil || req.DeviceName != "Office PC" || req.IPAddr != "203.0.113.7" || req.UserAgent != "Firefox/131" {
+		t.Fatalf("GetQRLogin = %+v, %v", req, err)
+	}
+	if err := svc.ApproveQRLogin(ctx, session, start.ID, start.Code); err != nil {
+		t.Fatalf("ApproveQRLogin: %v", err)
+	}
+	if err := svc.DenyQRLogin(ctx, session, start.ID, start.Code); !errors.Is(err, domain.ErrQRLoginNotFound) {
+		t.Fatalf("DenyQRLogin(decided) err = %v, want ErrQRLoginNotFound", err)
+	}
+
+	poll, err := svc.PollQRLogin(ctx, start.ID, start.PollToken, domain.LoginInput{IPAddr: "203.0.113.7"})
+	if err != nil || poll.Status != domain.QRLoginApproved || poll.Login.UserID != session.UserID {
+		t.Fatalf("PollQRLogin(approved) = %+v, %v", poll, err)
+	}
+	pc, err := svc.Authenticate(ctx, poll.Login.SessionToken)
+	if err != nil || pc.UserID != session.UserID {
+		t.Fatalf("authenticate new device = %+v, %v", pc, err)
+	}
+	// The session is handed out once.
+	if _, err := svc.PollQRLogin(ctx, start.ID, start.PollToken, domain.LoginInput{}); !errors.Is(err, domain.ErrQRLoginNotFound) {
+		t.Fatalf("PollQRLogin(collected) err = %v, want ErrQRLoginNotFound", err)
+	}
+
+	denied, err := svc.StartQRLogin(ctx, domain.LoginInput{})
+	if err != nil {
+		t.Fatalf("StartQRLogin: %v", err)
+	}
+	if err := svc.DenyQRLogin(ctx, session, denied.ID, denied.Code); err != nil {
+		t.Fatalf("DenyQRLogin: %v", err)
+	}
+	if _, err := svc.PollQRLogin(ctx, denied.ID, denied.PollToken, domain.LoginInput{}); !errors.Is(err, domain.ErrQRLoginDenied) {
+		t.Fatalf("PollQRLogin(denied) err = %v, want ErrQRLoginDenied", err)
+	}
+}
+
+func TestQRLoginExpires(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	svc := service.NewAuthService(store.Auth(), store.Transactor(), nil, "pepper", time.Hour, "pmv2")
+	svc.UseQRLogin(store.QRLogins())
+
+	start, err := svc.StartQRLogin(ctx, domain.LoginInput{})
+	if err != nil {
+		t.Fatalf("StartQRLogin: %v", err)
+	}
+	if _, err := store.QRLogins().GetQRLogin(ctx, start.ID, start.ExpiresAt); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("GetQRLogin(at expiry) err = %v, want ErrNotFound", err)
+	}
+	if deleted, err := store.QRLogins().DeleteExpiredQRLogins(ctx, start.ExpiresAt); err != nil || deleted != 1 {
+		t.Fatalf("DeleteExpiredQRLogins = %d, %v; want 1", deleted, err)
+	}
+	if _, err := svc.PollQRLogin(ctx, start.ID, start.PollToken, domain.LoginInput{}); !errors.Is(err, domain.ErrQRLoginNotFound) {
+		t.Fatalf("PollQRLogin(expired) err = %v, want ErrQRLoginNotFound", err)
+	}
+}
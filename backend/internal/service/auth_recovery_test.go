@@ -0,0 +1,66 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestRecoveryKeyIsAudited(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	svc := service.NewAuthService(store.Auth(), store.Transactor(), audit, "pepper", time.Hour, "pmv2")
+
+	user, err := svc.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	uid := uuid.MustParse(user.UserID)
+	events := func(eventType domain.EventType) []domain.AuditEvent {
+		t.Helper()
+		events, _, err := store.Audit().ListEvents(ctx, 10, 0, domain.AuditFilter{UserID: &uid, EventTypes: []domain.EventType{eventType}})
+		if err != nil {
+			t.Fatalf("ListEvents: %v", err)
+		}
+		return events
+	}
+
+	for _, key := range []string{"first-recovery-key", "second-recovery-key"} {
+		if err := svc.SetupRecovery(ctx, user.UserID, key, []byte("kek"), []byte("n"), []byte("salt")); err != nil {
+			t.Fatalf("SetupRecovery: %v", err)
+		}
+	}
+	setups := events(domain.EventTypeRecoverySetup)
+	rotations := 0
+	for _, e := range setups {
+		var data struct {
+			Rotated bool `json:"rotated"`
+		}
+		if err := json.Unmarshal(e.EventData, &data); err == nil && data.Rotated {
+			rotations++
+		}
+	}
+	if len(setups) != 2 || rotations != 1 {
+		t.Fatalf("recovery_setup events = %d with %d rotations, want 2 with 1", len(setups), rotations)
+	}
+
+	// The replaced key no longer works, and trying it is on record.
+	if _, _, _, err := svc.VerifyRecoveryKey(ctx, "ada@example.com", "first-recovery-key", ""); !errors.Is(err, domain.ErrInvalidRecoveryKey) {
+		t.Fatalf("VerifyRecoveryKey(old key) err = %v, want ErrInvalidRecoveryKey", err)
+	}
+	if failed := events(domain.EventTypeRecoveryFailed); len(failed) != 1 {
+		t.Fatalf("recovery_failed events = %d, want 1", len(failed))
+	}
+	if token, _, _, err := svc.VerifyRecoveryKey(ctx, "ada@example.com", "second-recovery-key", ""); err != nil || token == "" {
+		t.Fatalf("VerifyRecoveryKey = %q, %v; want a recovery token", token, err)
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/util"
+)
+
+// RefreshSession swaps the session token for a new one and extends the
+// session by the session TTL. The old token is kept as retired: should it
+// ever be presented again, a copy of it is in someone else's hands and the
+// session is revoked (see checkTokenReuse). With access tokens in use, a
+// fresh one comes along; an access token cannot itself be refreshed.
+func (s *AuthService) RefreshSession(ctx context.Context, token string) (domain.LoginOutput, error) {
+	if util.TrimOrEmpty(token) == "" || IsAccessToken(token) {
+		return domain.LoginOutput{}, domain.ErrUnauthorizedSession
+	}
+
+	session, err := s.Authenticate(ctx, token)
+	if err != nil {
+		return domain.LoginOutput{}, err
+	}
+
+	newToken, err := util.NewOpaqueToken(32)
+	if err != nil {
+		return domain.LoginOutput{}, err
+	}
+	expiresAt := s.now().UTC().Add(s.sessionTTL)
+	var rotated bool
+	err = withinTx(ctx, s.tx, func(ctx context.Context) error {
+		var err error
+		rotated, err = s.repo.RotateSessionToken(ctx, session.ID, util.HashToken(token, s.pepper), util.HashToken(newToken, s.pepper), expiresAt)
+		return err
+	})
+	if err != nil {
+		return domain.LoginOutput{}, fmt.Errorf("refresh session: %w", err)
+	}
+	if !rotated {
+		// A concurrent refresh with the same token won; that is a race,
+		// not a replay.
+		return domain.LoginOutput{}, domain.ErrUnauthorizedSession
+	}
+
+	output := domain.LoginOutput{
+		SessionToken: newToken,
+		ExpiresAt:    expiresAt,
+		UserID:       session.UserID,
+		Email:        session.Email,
+		Name:         session.Name,
+		TOTPEnabled:  session.TOTPEnabled,
+	}
+	if s.accessTokens != nil {
+		session.ExpiresAt = expiresAt
+		output.AccessToken, output.AccessTokenExpiresAt, _ = s.accessTokens.issue(session)
+	}
+	return output, nil
+}
+
+// checkTokenReuse is called with the hash of a token that matched no active
+// session. If a refresh retired it, the token was replayed: the session it
+// belonged to, with every token derived from it, is revoked and a security
+// event is logged, which also emails the user. Only the first replay of a
+// live session does so.
+func (s *AuthService) checkTokenReuse(ctx context.Context, tokenHash []byte) {
+	retired, err := s.repo.GetRetiredSessionToken(ctx, tokenHash)
+	if errors.Is(err, domain.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "token reuse check failed", slog.Any("error", err))
+		return
+	}
+
+	revoked, err := s.repo.RevokeSessionByID(ctx, retired.SessionID)
+	if err != nil {
+		slog.WarnContext(ctx, "revoke session after token reuse failed", slog.String("session_id", retired.SessionID), slog.Any("error", err))
+		return
+	}
+	if !revoked {
+		return
+	}
+	s.sessionEvents.SessionsRevoked(retired.UserID)
+
+	uid, _ := uuid.Parse(retired.UserID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeSecuritySessionTokenReused, map[string]any{
+		"session_id": retired.SessionID,
+		"retired_at": retired.RetiredAt.UTC(),
+	})
+}
@@ -0,0 +1,127 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestRefreshSessionRevokesOnTokenReuse(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	svc := service.NewAuthService(store.Auth(), store.Transactor(), audit, "pepper", time.Hour, "pmv2")
+
+	if _, err := svc.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	login, err := svc.Login(ctx, domain.LoginInput{Email: "ada@example.com", Password: "Correct-Horse-9"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	refreshed, err := svc.RefreshSession(ctx, login.SessionToken)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if refreshed.SessionToken == login.SessionToken {
+		t.Fatal("refresh kept the old token")
+	}
+	if _, err := svc.Authenticate(ctx, refreshed.SessionToken); err != nil {
+		t.Fatalf("authenticate with the new token: %v", err)
+	}
+
+	// The retired token comes back: someone else holds a copy.
+	if _, err := svc.Authenticate(ctx, login.SessionToken); !errors.Is(err, domain.ErrUnauthorizedSession) {
+		t.Fatalf("authenticate with the retired token = %v, want ErrUnauthorizedSession", err)
+	}
+	if _, err := svc.Authenticate(ctx, refreshed.SessionToken); !errors.Is(err, domain.ErrUnauthorizedSession) {
+		t.Fatalf("the session survived token reuse: %v", err)
+	}
+
+	events, _, err := store.Audit().ListEvents(ctx, 10, 0, domain.AuditFilter{EventTypes: []domain.EventType{domain.EventTypeSecuritySessionTokenReused}})
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("token reuse events = %d, want 1", len(events))
+	}
+
+	// A second replay finds the session already revoked and logs nothing.
+	_, _ = svc.Authenticate(ctx, login.SessionToken)
+	events, _, _ = store.Audit().ListEvents(ctx, 10, 0, domain.AuditFilter{EventTypes: []domain.EventType{domain.EventTypeSecuritySessionTokenReused}})
+	if len(events) != 1 {
+		t.Fatalf("token reuse events after a second replay = %d, want 1", len(events))
+	}
+}
+
+func TestRefreshIssuesAccessTokens(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	svc := service.NewAuthService(store.Auth(), store.Transactor(), audit, "pepper", time.Hour, "pmv2")
+	svc.UseSessionEvents(service.NewSessionEvents(), time.Minute)
+	svc.UseAccessTokens(service.NewAccessTokens("pepper", time.Minute))
+
+	if _, err := svc.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	refresh := func() domain.LoginOutput {
+		t.Helper()
+		login, err := svc.Login(ctx, domain.LoginInput{Email: "ada@example.com", Password: "Correct-Horse-9"})
+		if err != nil || login.AccessToken == "" {
+			t.Fatalf("login = %+v, %v; want an access token", login, err)
+		}
+		if _, err := svc.Authenticate(ctx, login.AccessToken); err != nil {
+			t.Fatalf("authenticate with the login's access token: %v", err)
+		}
+		out, err := svc.RefreshSession(ctx, login.SessionToken)
+		if err != nil {
+			t.Fatalf("refresh: %v", err)
+		}
+		if out.AccessToken == "" || time.Until(out.AccessTokenExpiresAt) > time.Minute {
+			t.Fatalf("access token = %q expiring %v", out.AccessToken, out.AccessTokenExpiresAt)
+		}
+		return out
+	}
+
+	first := refresh()
+	session, err := svc.Authenticate(ctx, first.AccessToken)
+	if err != nil || session.UserID != first.UserID || session.Email != "ada@example.com" {
+		t.Fatalf("authenticate with the access token = %+v, %v", session, err)
+	}
+	if _, err := svc.RefreshSession(ctx, first.AccessToken); !errors.Is(err, domain.ErrUnauthorizedSession) {
+		t.Fatalf("refresh with the access token = %v, want ErrUnauthorizedSession", err)
+	}
+	if _, err := svc.Authenticate(ctx, first.AccessToken+"x"); !errors.Is(err, domain.ErrUnauthorizedSession) {
+		t.Fatalf("authenticate with a tampered token = %v, want ErrUnauthorizedSession", err)
+	}
+
+	// The session row is not read, so a revocation this replica did not
+	// see goes unnoticed until the token expires...
+	if _, err := store.Auth().RevokeSessionByID(ctx, session.ID); err != nil {
+		t.Fatalf("revoke session: %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, first.AccessToken); err != nil {
+		t.Fatalf("authenticate after an unseen revocation: %v", err)
+	}
+
+	// ...while one it made refuses every access token issued before it.
+	second := refresh()
+	if err := svc.Logout(ctx, second.AccessToken); err != nil {
+		t.Fatalf("logout with the access token: %v", err)
+	}
+	for _, token := range []string{first.AccessToken, second.AccessToken} {
+		if _, err := svc.Authenticate(ctx, token); !errors.Is(err, domain.ErrUnauthorizedSession) {
+			t.Fatalf("authenticate after logout = %v, want ErrUnauthorizedSession", err)
+		}
+	}
+	if _, err := svc.Authenticate(ctx, second.SessionToken); !errors.Is(err, domain.ErrUnauthorizedSession) {
+		t.Fatalf("the session survived logout by access token: %v", err)
+	}
+}
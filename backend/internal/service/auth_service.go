@@ -5,36 +5,128 @@ import (
 	"crypto/subtle"
 	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/google/uuid"
 
 	"pmv2/backend/internal/domain"
 	"pmv2/backend/internal/util"
+	"pmv2/backend/internal/webauthn"
 )
 
 type AuthService struct {
 	repo          domain.AuthRepository
+	tx            domain.Transactor
 	pepper        string
 	sessionTTL    time.Duration
 	totpIssuer    string
 	totpSecretKey []byte
+	hashParams    domain.Argon2Params
+	hashGate      *passwordHashGate
 	now           func() time.Time
 	audit         *AuditService
+	anomalies     *AnomalyService
+	devices       domain.DeviceRepository
+	passkeys      domain.PasskeyRepository
+	relyingParty  webauthn.RelyingParty
+	qrLogins      domain.QRLoginRepository
+
+	passwordResets      domain.PasswordResetRepository
+	passwordResetEmails Emailer
+	frontendOrigin      string
+
+	sessionEvents        *SessionEvents
+	sessionCheckInterval time.Duration
+
+	dpopClientTypes []string
+	dpopReplays     dpopReplays
+
+	passwordFailures *passwordFailures
+	revokeLinks      *SessionRevokeLinks
+
+	vault    domain.VaultRepository
+	breaches BreachChecker
+	accounts domain.AdminRepository
+
+	canonicalEmails bool
+
+	accessTokens *AccessTokens
 }
 
-func NewAuthService(repo domain.AuthRepository, audit *AuditService, pepper string, sessionTTL time.Duration, issuer string) *AuthService {
+// NewAuthService builds the auth service. Flows that touch several rows,
+// such as a password reset revoking every session, run as one transaction
+// through tx; a nil tx runs them unwrapped.
+func NewAuthService(repo domain.AuthRepository, tx domain.Transactor, audit *AuditService, pepper string, sessionTTL time.Duration, issuer string) *AuthService {
 	return &AuthService{
 		repo:          repo,
+		tx:            tx,
 		pepper:        pepper,
 		sessionTTL:    sessionTTL,
 		totpIssuer:    issuer,
 		totpSecretKey: util.DeriveTOTPEncryptionKey(pepper),
+		hashParams:    util.DefaultArgon2Params(),
 		now:           time.Now,
 		audit:         audit,
 	}
 }
 
+// UsePasswordHashParams sets the Argon2id parameters new password hashes
+// get; by default util.DefaultArgon2Params. Stored hashes are checked with
+// the parameters saved alongside them, so changing these is safe.
+func (s *AuthService) UsePasswordHashParams(params domain.Argon2Params) {
+	s.hashParams = params
+}
+
+// UsePasswordHashLimit lets at most concurrency password hashes run at
+// once; a request that cannot start one within queueTimeout fails with
+// ErrServerBusy.
+func (s *AuthService) UsePasswordHashLimit(concurrency int, queueTimeout time.Duration) {
+	s.hashGate = newPasswordHashGate(concurrency, queueTimeout)
+}
+
+// hashPassword is util.HashPassword within the hashing limit.
+func (s *AuthService) hashPassword(ctx context.Context, password string, params domain.Argon2Params) (salt []byte, hash []byte, err error) {
+	release, err := s.hashGate.acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+	return util.HashPassword(password, params)
+}
+
+// hashPasswordWithSalt is util.HashPasswordWithSalt within the hashing
+// limit.
+func (s *AuthService) hashPasswordWithSalt(ctx context.Context, password string, salt []byte, params domain.Argon2Params) ([]byte, error) {
+	release, err := s.hashGate.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return util.HashPasswordWithSalt(password, salt, params), nil
+}
+
+// verifyPassword is util.VerifyPassword within the hashing limit.
+func (s *AuthService) verifyPassword(ctx context.Context, password string, salt []byte, expected []byte, params domain.Argon2Params) (bool, error) {
+	release, err := s.hashGate.acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+	return util.VerifyPassword(password, salt, expected, params), nil
+}
+
+// UseAnomalyDetection has every sign-in checked for impossible travel.
+func (s *AuthService) UseAnomalyDetection(anomalies *AnomalyService) {
+	s.anomalies = anomalies
+}
+
+// UseDeviceTracking has sign-ins recorded against the device they came
+// from, so signing in again from a known device raises no new device alert.
+func (s *AuthService) UseDeviceTracking(devices domain.DeviceRepository) {
+	s.devices = devices
+}
+
 const (
 	totpMaxAttempts   = 5
 	totpAttemptWindow = 30 * time.Second
@@ -48,17 +140,20 @@ func (s *AuthService) Register(ctx context.Context, email string, password strin
 		return domain.RegisterOutput{}, domain.ErrInvalidCredentials
 	}
 
-	if err := util.ValidatePasswordStrength(password); err != nil {
+	if err := util.ValidatePasswordStrength(password, normalizedEmail, name); err != nil {
+		return domain.RegisterOutput{}, err
+	}
+	if err := s.checkBreachedPassword(ctx, password); err != nil {
 		return domain.RegisterOutput{}, err
 	}
 
-	params := util.DefaultArgon2Params()
+	params := s.hashParams
 	paramsJSON, err := util.MarshalArgon2Params(params)
 	if err != nil {
 		return domain.RegisterOutput{}, fmt.Errorf("marshal argon2 params: %w", err)
 	}
 
-	salt, passwordHash, err := util.HashPassword(password, params)
+	salt, passwordHash, err := s.hashPassword(ctx, password, params)
 	if err != nil {
 		return domain.RegisterOutput{}, err
 	}
@@ -68,16 +163,41 @@ func (s *AuthService) Register(ctx context.Context, email string, password strin
 		return domain.RegisterOutput{}, err
 	}
 
-	trimmedName := util.TrimOrEmpty(name)
-	err = s.repo.CreateUserWithCredentials(ctx, domain.CreateUserInput{
+	return s.createAccount(ctx, domain.CreateUserInput{
 		UserID:       userID,
 		Email:        normalizedEmail,
-		Name:         trimmedName,
+		Name:         util.TrimOrEmpty(name),
 		Algo:         "argon2id",
 		ParamsJSON:   paramsJSON,
 		Salt:         salt,
 		PasswordHash: passwordHash,
-	})
+	}, nil)
+}
+
+// UseEmailAliasNormalization refuses to register an address that a known
+// provider delivers to the same mailbox as an existing account's, such as
+// a.da+promo@gmail.com next to ada@gmail.com. The address given is still
+// the one mail goes to.
+func (s *AuthService) UseEmailAliasNormalization() {
+	s.canonicalEmails = true
+}
+
+// createAccount creates the user and logs the registration with eventData.
+// A passkey-only account has no Algo, ParamsJSON or PasswordHash.
+func (s *AuthService) createAccount(ctx context.Context, input domain.CreateUserInput, eventData map[string]any) (domain.RegisterOutput, error) {
+	if s.canonicalEmails {
+		input.CanonicalEmail = util.CanonicalEmail(input.Email)
+		// Accounts from before the option was on have no canonical address
+		// stored; one registered under it is still caught here.
+		if input.CanonicalEmail != input.Email {
+			if _, err := s.repo.GetUserAuthByEmail(ctx, input.CanonicalEmail); err == nil {
+				return domain.RegisterOutput{}, domain.ErrEmailTaken
+			} else if !errors.Is(err, domain.ErrNotFound) {
+				return domain.RegisterOutput{}, fmt.Errorf("look up canonical email: %w", err)
+			}
+		}
+	}
+	err := s.repo.CreateUserWithCredentials(ctx, input)
 	if err != nil {
 		if errors.Is(err, domain.ErrEmailTaken) {
 			return domain.RegisterOutput{}, domain.ErrEmailTaken
@@ -85,10 +205,13 @@ func (s *AuthService) Register(ctx context.Context, email string, password strin
 		return domain.RegisterOutput{}, fmt.Errorf("create user credentials: %w", err)
 	}
 
+	uid, _ := uuid.Parse(input.UserID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeAuthRegistered, eventData)
+
 	return domain.RegisterOutput{
-		UserID: userID,
-		Email:  normalizedEmail,
-		Name:   trimmedName,
+		UserID: input.UserID,
+		Email:  input.Email,
+		Name:   input.Name,
 	}, nil
 }
 
@@ -99,7 +222,13 @@ func (s *AuthService) Login(ctx context.Context, input domain.LoginInput) (domai
 	}
 	trimmedTOTPCode := util.TrimOrEmpty(input.TOTPCode)
 	trimmedRecoveryCode := util.TrimOrEmpty(input.RecoveryCode)
-	if trimmedTOTPCode != "" && trimmedRecoveryCode != "" {
+	factors := 0
+	for _, given := range []bool{trimmedTOTPCode != "", trimmedRecoveryCode != "", input.PasskeyAssertion != nil} {
+		if given {
+			factors++
+		}
+	}
+	if factors > 1 {
 		return domain.LoginOutput{}, domain.ErrInvalidMFAInput
 	}
 
@@ -111,26 +240,47 @@ func (s *AuthService) Login(ctx context.Context, input domain.LoginInput) (domai
 		return domain.LoginOutput{}, fmt.Errorf("read auth record: %w", err)
 	}
 
+	if record.Passwordless() {
+		return domain.LoginOutput{}, domain.ErrInvalidCredentials
+	}
 	params, err := util.ParseArgon2Params(record.RawParams)
 	if err != nil {
 		return domain.LoginOutput{}, fmt.Errorf("parse hash params: %w", err)
 	}
 
-	if !util.VerifyPassword(input.Password, record.Salt, record.PasswordHash, params) {
+	ok, err := s.verifyPassword(ctx, input.Password, record.Salt, record.PasswordHash, params)
+	if err != nil {
+		return domain.LoginOutput{}, err
+	}
+	if !ok {
+		s.recordPasswordFailure(ctx, record.UserID, s.now().UTC())
 		return domain.LoginOutput{}, domain.ErrInvalidCredentials
 	}
 
+	var eventData map[string]any
 	if record.TOTPEnabled {
 		nowUTC := s.now().UTC()
 		if s.isMFALocked(record.TOTPLockedUntil, nowUTC) {
-			return domain.LoginOutput{}, domain.ErrMFARateLimited
+			return domain.LoginOutput{}, domain.ErrMFARateLimited.WithLockedUntil(*record.TOTPLockedUntil)
 		}
 
-		if trimmedTOTPCode == "" && trimmedRecoveryCode == "" {
+		if factors == 0 {
 			return domain.LoginOutput{}, domain.ErrMFARequired
 		}
 
-		if trimmedRecoveryCode != "" {
+		if input.PasskeyAssertion != nil {
+			passkeyID, err := s.verifyPasskeySecondFactor(ctx, record.UserID, *input.PasskeyAssertion)
+			if err != nil {
+				if errors.Is(err, domain.ErrInvalidPasskey) {
+					return domain.LoginOutput{}, s.recordMFAFailure(ctx, record.UserID, nowUTC)
+				}
+				return domain.LoginOutput{}, err
+			}
+			if err := s.repo.ResetTOTPFailures(ctx, record.UserID); err != nil {
+				return domain.LoginOutput{}, fmt.Errorf("reset totp failures after passkey: %w", err)
+			}
+			eventData = map[string]any{"mfa_method": "passkey", "passkey_id": passkeyID}
+		} else if trimmedRecoveryCode != "" {
 			consumed, err := s.repo.ConsumeRecoveryCode(ctx, record.UserID, util.HashRecoveryCode(trimmedRecoveryCode, s.pepper))
 			if err != nil {
 				return domain.LoginOutput{}, fmt.Errorf("consume recovery code: %w", err)
@@ -155,6 +305,14 @@ func (s *AuthService) Login(ctx context.Context, input domain.LoginInput) (domai
 		}
 	}
 
+	return s.startSession(ctx, record, input, eventData)
+}
+
+// startSession signs the user in once their credentials have been checked:
+// it creates the session, records the device and logs the sign-in with
+// eventData added to the event. With access tokens in use, the first one
+// comes along.
+func (s *AuthService) startSession(ctx context.Context, record domain.UserAuthRecord, input domain.LoginInput, eventData map[string]any) (domain.LoginOutput, error) {
 	sessionToken, err := util.NewOpaqueToken(32)
 	if err != nil {
 		return domain.LoginOutput{}, err
@@ -165,49 +323,146 @@ func (s *AuthService) Login(ctx context.Context, input domain.LoginInput) (domai
 		return domain.LoginOutput{}, err
 	}
 
+	if eventData == nil {
+		eventData = make(map[string]any)
+	}
+	eventData["ip_address"] = input.IPAddr
+	eventData["device_name"] = input.DeviceName
+	device, newDevice, tracked := s.touchDevice(ctx, record.UserID, input)
+	if tracked {
+		eventData["device_id"] = device.ID
+		eventData["new_device"] = newDevice
+	}
+
 	expiresAt := s.now().UTC().Add(s.sessionTTL)
+	tokenHash := util.HashToken(sessionToken, s.pepper)
 	err = s.repo.CreateSession(ctx, domain.CreateSessionInput{
 		SessionID:  sessionID,
 		UserID:     record.UserID,
-		TokenHash:  util.HashToken(sessionToken, s.pepper),
+		TokenHash:  tokenHash,
 		DeviceName: util.TrimOrEmpty(input.DeviceName),
 		IPAddr:     util.NormalizeIP(input.IPAddr),
 		UserAgent:  util.TrimOrEmpty(input.UserAgent),
+		DeviceID:   device.ID,
 		ExpiresAt:  expiresAt,
 	})
 	if err != nil {
 		return domain.LoginOutput{}, fmt.Errorf("create session: %w", err)
 	}
 
-	uid, _ := uuid.Parse(record.UserID)
-	s.audit.LogEvent(ctx, &uid, domain.EventTypeAuthLoginSuccess, map[string]string{
-		"ip_address":  input.IPAddr,
-		"device_name": input.DeviceName,
-	})
-
-	return domain.LoginOutput{
+	output := domain.LoginOutput{
 		SessionToken: sessionToken,
 		ExpiresAt:    expiresAt,
 		UserID:       record.UserID,
 		Email:        record.Email,
 		Name:         record.Name,
 		TOTPEnabled:  record.TOTPEnabled,
-	}, nil
+		// The session is restricted until the password is changed.
+		CredentialResetRequired: record.CredentialsCompromised,
+	}
+	// The token is read back from the row, which has the role and the
+	// account's binding, and issued before the anomaly checks, so a step-up
+	// they require refuses it.
+	if s.accessTokens != nil {
+		if session, err := s.repo.GetActiveSessionByTokenHash(ctx, tokenHash); err == nil {
+			output.AccessToken, output.AccessTokenExpiresAt, _ = s.accessTokens.issue(session)
+		} else {
+			slog.WarnContext(ctx, "read new session for access token failed", slog.Any("error", err))
+		}
+	}
+	s.anomalies.CheckLogin(ctx, record.UserID, sessionID, util.NormalizeIP(input.IPAddr))
+
+	uid, _ := uuid.Parse(record.UserID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeAuthLoginSuccess, eventData)
+
+	return output, nil
+}
+
+// touchDevice records a sign-in against the device it came from: the one
+// with the client's fingerprint if it sent one, otherwise the one with its
+// user agent. Only a peppered hash of either is stored. tracked is false when
+// device tracking is off, the device cannot be told apart, or recording it
+// failed; a sign-in never fails over it.
+func (s *AuthService) touchDevice(ctx context.Context, userID string, input domain.LoginInput) (device domain.Device, created bool, tracked bool) {
+	if s.devices == nil {
+		return domain.Device{}, false, false
+	}
+	userAgent := util.TrimOrEmpty(input.UserAgent)
+	fingerprint := util.TrimOrEmpty(input.DeviceFingerprint)
+	key := "user-agent:" + userAgent
+	if fingerprint != "" {
+		key = "fingerprint:" + fingerprint
+	} else if userAgent == "" {
+		return domain.Device{}, false, false
+	}
+
+	device, created, err := s.devices.TouchDevice(ctx, userID, util.HashToken(key, s.pepper), fingerprint != "", userAgent)
+	if err != nil {
+		slog.WarnContext(ctx, "record sign-in device failed", slog.Any("error", err))
+		return domain.Device{}, false, false
+	}
+	return device, created, true
+}
+
+// ListDevices returns the devices the user has signed in from, most
+// recently used first. It is empty when device tracking is off.
+func (s *AuthService) ListDevices(ctx context.Context, userID string) ([]domain.Device, error) {
+	if s.devices == nil {
+		return []domain.Device{}, nil
+	}
+	devices, err := s.devices.ListDevices(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list devices: %w", err)
+	}
+	return devices, nil
+}
+
+// RemoveDevice forgets one of the user's devices, so its next sign-in is
+// treated as coming from a new device. Its sessions are not revoked.
+func (s *AuthService) RemoveDevice(ctx context.Context, userID, deviceID string) error {
+	if s.devices == nil {
+		return domain.ErrNotFound
+	}
+	if _, err := uuid.Parse(deviceID); err != nil {
+		return domain.ErrNotFound
+	}
+	if err := s.devices.DeleteDevice(ctx, userID, deviceID); err != nil {
+		return err
+	}
+
+	uid, _ := uuid.Parse(userID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeAuthDeviceRemoved, map[string]string{"device_id": deviceID})
+	return nil
 }
 
 func (s *AuthService) Authenticate(ctx context.Context, token string) (domain.Session, error) {
 	if util.TrimOrEmpty(token) == "" {
 		return domain.Session{}, domain.ErrUnauthorizedSession
 	}
+	if IsAccessToken(token) {
+		return s.authenticateAccessToken(ctx, token)
+	}
 
-	session, err := s.repo.GetActiveSessionByTokenHash(ctx, util.HashToken(token, s.pepper))
+	tokenHash := util.HashToken(token, s.pepper)
+	session, err := s.repo.GetActiveSessionByTokenHash(ctx, tokenHash)
 	if err != nil {
 		if errors.Is(err, domain.ErrNotFound) {
+			s.checkTokenReuse(ctx, tokenHash)
 			return domain.Session{}, domain.ErrUnauthorizedSession
 		}
 		return domain.Session{}, fmt.Errorf("authenticate session: %w", err)
 	}
+	// The proof comes first: a caller without the session's key must not
+	// be able to trip the binding and sign the owner out.
+	if err := s.checkDPoP(ctx, session, token); err != nil {
+		return domain.Session{}, err
+	}
 
+	session, err = s.enforceSessionBinding(ctx, session)
+	if err != nil {
+		return domain.Session{}, err
+	}
+	s.touchSession(ctx, &session)
 	return session, nil
 }
 
@@ -216,25 +471,37 @@ func (s *AuthService) Logout(ctx context.Context, token string) error {
 		return domain.ErrUnauthorizedSession
 	}
 
-	tokenHash := util.HashToken(token, s.pepper)
-	session, err := s.repo.GetActiveSessionByTokenHash(ctx, tokenHash)
-	if err != nil {
-		if errors.Is(err, domain.ErrNotFound) {
-			return domain.ErrUnauthorizedSession
+	var session domain.Session
+	var revoked bool
+	var err error
+	if IsAccessToken(token) {
+		if session, err = s.authenticateAccessToken(ctx, token); err != nil {
+			return err
+		}
+		revoked, err = s.repo.RevokeSessionByID(ctx, session.ID)
+	} else {
+		tokenHash := util.HashToken(token, s.pepper)
+		// The session is only looked up to attribute the audit event;
+		// whether the token was valid is decided by the revoke below.
+		session, err = s.repo.GetActiveSessionByTokenHash(ctx, tokenHash)
+		if err != nil && !errors.Is(err, domain.ErrNotFound) {
+			return fmt.Errorf("lookup session for logout: %w", err)
 		}
-		return fmt.Errorf("lookup session for logout: %w", err)
+		revoked, err = s.repo.RevokeSessionByTokenHash(ctx, tokenHash)
 	}
-
-	revoked, err := s.repo.RevokeSessionByTokenHash(ctx, tokenHash)
 	if err != nil {
 		return fmt.Errorf("logout: %w", err)
 	}
 	if !revoked {
 		return domain.ErrUnauthorizedSession
 	}
+	s.sessionEvents.SessionsRevoked(session.UserID)
 
-	uid, _ := uuid.Parse(session.UserID)
-	s.audit.LogEvent(ctx, &uid, domain.EventTypeAuthLogout, nil)
+	var uidPtr *uuid.UUID
+	if uid, err := uuid.Parse(session.UserID); err == nil {
+		uidPtr = &uid
+	}
+	s.audit.LogEvent(ctx, uidPtr, domain.EventTypeAuthLogout, nil)
 
 	return nil
 }
@@ -275,7 +542,7 @@ func (s *AuthService) EnableTOTP(ctx context.Context, userID string, code string
 
 	nowUTC := s.now().UTC()
 	if s.isMFALocked(state.LockedUntil, nowUTC) {
-		return nil, domain.ErrMFARateLimited
+		return nil, domain.ErrMFARateLimited.WithLockedUntil(*state.LockedUntil)
 	}
 
 	secret, err := util.ParseStoredTOTPSecret(state.SecretEnc, s.totpSecretKey)
@@ -296,19 +563,36 @@ func (s *AuthService) EnableTOTP(ctx context.Context, userID string, code string
 		return nil, s.recordMFAFailure(ctx, userID, nowUTC)
 	}
 
-	if err := s.repo.EnableTOTP(ctx, userID); err != nil {
-		if errors.Is(err, domain.ErrNotFound) {
-			return nil, domain.ErrUnauthorizedSession
+	var codes []string
+	err = withinTx(ctx, s.tx, func(ctx context.Context) error {
+		if err := s.repo.EnableTOTP(ctx, userID); err != nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				return domain.ErrUnauthorizedSession
+			}
+			return fmt.Errorf("enable totp: %w", err)
 		}
-		return nil, fmt.Errorf("enable totp: %w", err)
-	}
-	if err := s.repo.ResetTOTPFailures(ctx, userID); err != nil {
-		return nil, fmt.Errorf("reset totp failures: %w", err)
+		if err := s.repo.ResetTOTPFailures(ctx, userID); err != nil {
+			return fmt.Errorf("reset totp failures: %w", err)
+		}
+
+		var err error
+		if codes, err = s.generateAndStoreRecoveryCodes(ctx, userID); err != nil {
+			return err
+		}
+
+		uid, _ := uuid.Parse(userID)
+		return s.audit.Record(ctx, &uid, domain.EventTypeMFASetup, nil)
+	})
+	if err != nil {
+		return nil, err
 	}
-	return s.generateAndStoreRecoveryCodes(ctx, userID)
+	return codes, nil
 }
 
-func (s *AuthService) VerifyTOTPForSession(ctx context.Context, userID string, code string) error {
+// VerifyTOTPForSession checks a TOTP code for the signed-in user. On a
+// session awaiting step-up it also clears the flag.
+func (s *AuthService) VerifyTOTPForSession(ctx context.Context, session domain.Session, code string) error {
+	userID := session.UserID
 	state, err := s.repo.GetTOTPState(ctx, userID)
 	if err != nil {
 		if errors.Is(err, domain.ErrNotFound) {
@@ -319,7 +603,7 @@ func (s *AuthService) VerifyTOTPForSession(ctx context.Context, userID string, c
 
 	nowUTC := s.now().UTC()
 	if s.isMFALocked(state.LockedUntil, nowUTC) {
-		return domain.ErrMFARateLimited
+		return domain.ErrMFARateLimited.WithLockedUntil(*state.LockedUntil)
 	}
 
 	secret, err := util.ParseStoredTOTPSecret(state.SecretEnc, s.totpSecretKey)
@@ -336,14 +620,33 @@ func (s *AuthService) VerifyTOTPForSession(ctx context.Context, userID string, c
 	if err := s.repo.ResetTOTPFailures(ctx, userID); err != nil {
 		return fmt.Errorf("reset totp failures: %w", err)
 	}
+	if session.StepUpRequired {
+		if err := s.repo.SetSessionStepUp(ctx, session.ID, false); err != nil {
+			return fmt.Errorf("clear step-up: %w", err)
+		}
+		// Whoever passed step-up is now the client a bound session
+		// belongs to; otherwise the next request would flag it again.
+		if session.Binding.Enabled() {
+			meta := util.RequestMetaFromContext(ctx)
+			if err := s.repo.RebindSession(ctx, session.ID, meta.IPAddress, meta.UserAgent); err != nil {
+				return fmt.Errorf("rebind session: %w", err)
+			}
+		}
+		uid, _ := uuid.Parse(userID)
+		s.audit.LogEvent(ctx, &uid, domain.EventTypeAuthStepUpVerified, map[string]string{"session_id": session.ID})
+	}
 	return nil
 }
 
 func (s *AuthService) DisableTOTP(ctx context.Context, userID string) error {
-	if err := s.repo.DisableTOTP(ctx, userID); err != nil {
-		return fmt.Errorf("disable totp service: %w", err)
-	}
-	return nil
+	return withinTx(ctx, s.tx, func(ctx context.Context) error {
+		if err := s.repo.DisableTOTP(ctx, userID); err != nil {
+			return fmt.Errorf("disable totp service: %w", err)
+		}
+
+		uid, _ := uuid.Parse(userID)
+		return s.audit.Record(ctx, &uid, domain.EventTypeMFADisabled, nil)
+	})
 }
 
 func (s *AuthService) recordMFAFailure(ctx context.Context, userID string, now time.Time) error {
@@ -361,7 +664,13 @@ func (s *AuthService) recordMFAFailure(ctx context.Context, userID string, now t
 	})
 
 	if s.isMFALocked(lockedUntil, now) {
-		return domain.ErrMFARateLimited
+		s.audit.LogEvent(ctx, &uid, domain.EventTypeSecurityLoginFailuresExceeded, domain.LoginFailuresExceeded{
+			Reason:         "mfa",
+			FailedAttempts: totpMaxAttempts,
+			Since:          now.Add(-totpAttemptWindow),
+			LockedUntil:    lockedUntil,
+		})
+		return domain.ErrMFARateLimited.WithLockedUntil(*lockedUntil)
 	}
 	return domain.ErrInvalidMFA
 }
@@ -399,14 +708,28 @@ func (s *AuthService) SetupRecovery(ctx context.Context, userID string, recovery
 	}
 
 	recoveryKeyHash := util.HashRecoveryKey(recoveryKey, s.pepper)
+	rotated, err := s.GetRecoveryStatus(ctx, userID)
+	if err != nil {
+		return err
+	}
 
-	return s.repo.SetupRecovery(ctx, domain.SetupRecoveryInput{
+	err = s.repo.SetupRecovery(ctx, domain.SetupRecoveryInput{
 		UserID:          userID,
 		RecoveryKeyHash: recoveryKeyHash,
 		WrappedKEK:      wrappedKEK,
 		WrapNonce:       wrapNonce,
 		KEKSalt:         kekSalt,
 	})
+	if err != nil {
+		return err
+	}
+
+	// A new key replaces the old one, which stops working.
+	uid, _ := uuid.Parse(userID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeRecoverySetup, map[string]any{
+		"rotated": rotated,
+	})
+	return nil
 }
 
 func (s *AuthService) GetRecoveryStatus(ctx context.Context, userID string) (bool, error) {
@@ -451,17 +774,21 @@ func (s *AuthService) VerifyRecoveryKey(ctx context.Context, email string, recov
 
 	nowUTC := s.now().UTC()
 	if recoveryRecord.LastRecoveryAt != nil && nowUTC.Sub(*recoveryRecord.LastRecoveryAt) < recoveryCooldown {
-		return "", time.Time{}, nil, domain.ErrRecoveryCooldown
+		return "", time.Time{}, nil, domain.ErrRecoveryCooldown.WithLockedUntil(recoveryRecord.LastRecoveryAt.Add(recoveryCooldown))
 	}
 
 	keyHash := util.HashRecoveryKey(recoveryKey, s.pepper)
 	if subtle.ConstantTimeCompare(keyHash, recoveryRecord.RecoveryKeyHash) != 1 {
+		uid, _ := uuid.Parse(record.UserID)
+		s.audit.LogEvent(ctx, &uid, domain.EventTypeRecoveryFailed, map[string]any{
+			"reason": "invalid_recovery_key",
+		})
 		return "", time.Time{}, nil, domain.ErrInvalidRecoveryKey
 	}
 
 	if record.TOTPEnabled {
 		if s.isMFALocked(record.TOTPLockedUntil, nowUTC) {
-			return "", time.Time{}, nil, domain.ErrMFARateLimited
+			return "", time.Time{}, nil, domain.ErrMFARateLimited.WithLockedUntil(*record.TOTPLockedUntil)
 		}
 
 		trimmedCode := util.TrimOrEmpty(totpCode)
@@ -507,58 +834,29 @@ func (s *AuthService) VerifyRecoveryKey(ctx context.Context, email string, recov
 }
 
 func (s *AuthService) ResetPassword(ctx context.Context, recoveryToken string, newPassword string, deviceName string, ipAddr string, userAgent string) (domain.LoginOutput, error) {
-	if util.TrimOrEmpty(recoveryToken) == "" {
-		return domain.LoginOutput{}, domain.ErrInvalidRecoveryToken
-	}
-
-	session, err := s.repo.GetActiveSessionByTokenHash(ctx, util.HashToken(recoveryToken, s.pepper))
+	session, err := s.recoverySession(ctx, recoveryToken)
 	if err != nil {
-		if errors.Is(err, domain.ErrNotFound) {
-			return domain.LoginOutput{}, domain.ErrInvalidRecoveryToken
-		}
-		return domain.LoginOutput{}, fmt.Errorf("validate recovery token: %w", err)
+		return domain.LoginOutput{}, err
 	}
 
-	if err := util.ValidatePasswordStrength(newPassword); err != nil {
+	if err := util.ValidatePasswordStrength(newPassword, session.Email, session.Name); err != nil {
+		return domain.LoginOutput{}, err
+	}
+	if err := s.checkBreachedPassword(ctx, newPassword); err != nil {
 		return domain.LoginOutput{}, err
 	}
 
-	params := util.DefaultArgon2Params()
+	params := s.hashParams
 	paramsJSON, err := util.MarshalArgon2Params(params)
 	if err != nil {
 		return domain.LoginOutput{}, fmt.Errorf("marshal argon2 params: %w", err)
 	}
 
-	salt, passwordHash, err := util.HashPassword(newPassword, params)
+	salt, passwordHash, err := s.hashPassword(ctx, newPassword, params)
 	if err != nil {
 		return domain.LoginOutput{}, err
 	}
 
-	err = s.repo.UpdatePassword(ctx, domain.ResetPasswordInput{
-		UserID:       session.UserID,
-		Algo:         "argon2id",
-		ParamsJSON:   paramsJSON,
-		Salt:         salt,
-		PasswordHash: passwordHash,
-	})
-	if err != nil {
-		return domain.LoginOutput{}, fmt.Errorf("update password: %w", err)
-	}
-
-	if _, err := s.repo.RevokeAllUserSessions(ctx, session.UserID); err != nil {
-		return domain.LoginOutput{}, fmt.Errorf("revoke sessions after recovery: %w", err)
-	}
-
-	if err := s.repo.UpdateLastRecoveryAt(ctx, session.UserID); err != nil {
-		return domain.LoginOutput{}, fmt.Errorf("update last recovery timestamp: %w", err)
-	}
-
-	// Fetch full user record to populate session details
-	record, err := s.repo.GetUserAuthByEmail(ctx, session.Email)
-	if err != nil {
-		return domain.LoginOutput{}, fmt.Errorf("read auth record for reset session: %w", err)
-	}
-
 	// Create a new session so the user remains logged in immediately
 	sessionToken, err := util.NewOpaqueToken(32)
 	if err != nil {
@@ -570,19 +868,69 @@ func (s *AuthService) ResetPassword(ctx context.Context, recoveryToken string, n
 		return domain.LoginOutput{}, err
 	}
 
+	// The new password, the revoked sessions and the replacement session
+	// commit together, so a failure part way cannot leave old sessions
+	// alive behind a changed password.
+	var record domain.UserAuthRecord
 	expiresAt := s.now().UTC().Add(s.sessionTTL)
-	err = s.repo.CreateSession(ctx, domain.CreateSessionInput{
-		SessionID:  newSessionID,
-		UserID:     record.UserID,
-		TokenHash:  util.HashToken(sessionToken, s.pepper),
-		DeviceName: util.TrimOrEmpty(deviceName),
-		IPAddr:     util.NormalizeIP(ipAddr),
-		UserAgent:  util.TrimOrEmpty(userAgent),
-		ExpiresAt:  expiresAt,
+	err = withinTx(ctx, s.tx, func(ctx context.Context) error {
+		// Fetch full user record to populate session details
+		var err error
+		record, err = s.repo.GetUserAuthByEmail(ctx, session.Email)
+		if err != nil {
+			return fmt.Errorf("read auth record for reset session: %w", err)
+		}
+		// A passkey-only account's vault key is not wrapped by a password,
+		// so giving it one would not unlock anything; it enrols a new
+		// passkey through FinishPasskeyRecovery instead.
+		if record.Passwordless() {
+			return domain.ErrPasswordlessAccount
+		}
+
+		err = s.repo.UpdatePassword(ctx, domain.ResetPasswordInput{
+			UserID:       session.UserID,
+			Algo:         "argon2id",
+			ParamsJSON:   paramsJSON,
+			Salt:         salt,
+			PasswordHash: passwordHash,
+		})
+		if err != nil {
+			return fmt.Errorf("update password: %w", err)
+		}
+
+		if _, err := s.repo.RevokeAllUserSessions(ctx, session.UserID); err != nil {
+			return fmt.Errorf("revoke sessions after recovery: %w", err)
+		}
+
+		if err := s.repo.UpdateLastRecoveryAt(ctx, session.UserID); err != nil {
+			return fmt.Errorf("update last recovery timestamp: %w", err)
+		}
+
+		uid, _ := uuid.Parse(session.UserID)
+		if err := s.audit.Record(ctx, &uid, domain.EventTypeAuthPasswordReset, map[string]string{
+			"method": "recovery_key",
+		}); err != nil {
+			return err
+		}
+
+		err = s.repo.CreateSession(ctx, domain.CreateSessionInput{
+			SessionID:  newSessionID,
+			UserID:     record.UserID,
+			TokenHash:  util.HashToken(sessionToken, s.pepper),
+			DeviceName: util.TrimOrEmpty(deviceName),
+			IPAddr:     util.NormalizeIP(ipAddr),
+			UserAgent:  util.TrimOrEmpty(userAgent),
+			ExpiresAt:  expiresAt,
+		})
+		if err != nil {
+			return fmt.Errorf("create session after reset: %w", err)
+		}
+		return nil
 	})
 	if err != nil {
-		return domain.LoginOutput{}, fmt.Errorf("create session after reset: %w", err)
+		return domain.LoginOutput{}, err
 	}
+	s.sessionEvents.SessionsRevoked(record.UserID)
 
 	return domain.LoginOutput{
 		SessionToken: sessionToken,
@@ -608,3 +956,35 @@ func (s *AuthService) UpdateProfile(ctx context.Context, userID string, name str
 	
 	return nil
 }
+
+// PurgeSessions deletes expired sessions, and revoked ones older than
+// revokedRetention, in batches of batchSize rows taken in ID order, pausing
+// between batches so that a large purge never holds locks for long or
+// crowds out sign-ins. It reports every batch it ran, also when it fails.
+func (s *AuthService) PurgeSessions(ctx context.Context, revokedRetention time.Duration, batchSize int, pause time.Duration) (domain.SessionPurgeStats, error) {
+	revokedBefore := s.now().UTC().Add(-revokedRetention)
+	var stats domain.SessionPurgeStats
+	var cursor string
+	for {
+		started := time.Now()
+		batch, err := s.repo.DeleteExpiredSessions(ctx, revokedBefore, cursor, batchSize)
+		if err != nil {
+			return stats, fmt.Errorf("purge sessions: %w", err)
+		}
+		batch.Duration = time.Since(started)
+		stats.Batches = append(stats.Batches, batch)
+		stats.Deleted += batch.Deleted
+		if batch.Deleted < int64(batchSize) || batch.LastID == "" {
+			return stats, nil
+		}
+		cursor = batch.LastID
+
+		timer := time.NewTimer(pause)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return stats, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
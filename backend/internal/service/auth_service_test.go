@@ -2,6 +2,9 @@ package service_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
 	"testing"
 	"time"
 
@@ -23,7 +26,7 @@ type mockAuthRepo struct {
 	resetTOTPFailuresFn     func(ctx context.Context, userID string) error
 	replaceRecoveryCodesFn  func(ctx context.Context, userID string, codeHashes [][]byte) error
 	consumeRecoveryCodeFn   func(ctx context.Context, userID string, codeHash []byte) (bool, error)
-	deleteExpiredSessionsFn func(ctx context.Context) (int64, error)
+	deleteExpiredSessionsFn func(ctx context.Context, revokedBefore time.Time, afterID string, limit int) (domain.SessionPurgeBatch, error)
 }
 
 func (m *mockAuthRepo) CreateUserWithCredentials(ctx context.Context, input domain.CreateUserInput) error {
@@ -40,6 +43,10 @@ func (m *mockAuthRepo) GetUserAuthByEmail(ctx context.Context, email string) (do
 	return domain.UserAuthRecord{}, domain.ErrNotFound
 }
 
+func (m *mockAuthRepo) GetUserAuthByID(ctx context.Context, userID string) (domain.UserAuthRecord, error) {
+	return domain.UserAuthRecord{}, domain.ErrNotFound
+}
+
 func (m *mockAuthRepo) CreateSession(ctx context.Context, input domain.CreateSessionInput) error {
 	if m.createSessionFn != nil {
 		return m.createSessionFn(ctx, input)
@@ -116,17 +123,69 @@ func (m *mockAuthRepo) ConsumeRecoveryCode(ctx context.Context, userID string, c
 	return false, nil
 }
 
-func (m *mockAuthRepo) DeleteExpiredSessions(ctx context.Context) (int64, error) {
+func (m *mockAuthRepo) DeleteExpiredSessions(ctx context.Context, revokedBefore time.Time, afterID string, limit int) (domain.SessionPurgeBatch, error) {
 	if m.deleteExpiredSessionsFn != nil {
-		return m.deleteExpiredSessionsFn(ctx)
+		return m.deleteExpiredSessionsFn(ctx, revokedBefore, afterID, limit)
 	}
-	return 0, nil
+	return domain.SessionPurgeBatch{}, nil
 }
 
 func (m *mockAuthRepo) RevokeAllUserSessions(ctx context.Context, userID string) (int64, error) {
 	return 0, nil
 }
 
+func (m *mockAuthRepo) SetSessionStepUp(ctx context.Context, sessionID string, required bool) error {
+	return nil
+}
+
+func (m *mockAuthRepo) RebindSession(ctx context.Context, sessionID string, ipAddr string, userAgent string) error {
+	return nil
+}
+
+func (m *mockAuthRepo) GetSessionBinding(ctx context.Context, userID string) (domain.SessionBinding, error) {
+	return domain.SessionBinding{}, domain.ErrNotFound
+}
+
+func (m *mockAuthRepo) SetSessionBinding(ctx context.Context, userID string, binding domain.SessionBinding) error {
+	return nil
+}
+
+func (m *mockAuthRepo) SetSessionDPoPKey(ctx context.Context, sessionID string, thumbprint string) (bool, error) {
+	return true, nil
+}
+
+func (m *mockAuthRepo) RevokeSessionByID(ctx context.Context, sessionID string) (bool, error) {
+	return false, nil
+}
+
+func (m *mockAuthRepo) SessionActive(ctx context.Context, sessionID string) (bool, error) {
+	return true, nil
+}
+
+func (m *mockAuthRepo) ListUserSessions(ctx context.Context, userID string) ([]domain.UserSession, error) {
+	return []domain.UserSession{}, nil
+}
+
+func (m *mockAuthRepo) RevokeUserSession(ctx context.Context, userID, sessionID string) (bool, error) {
+	return false, nil
+}
+
+func (m *mockAuthRepo) RevokeOtherUserSessions(ctx context.Context, userID, keepSessionID string) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockAuthRepo) TouchSession(ctx context.Context, sessionID string, usedAt time.Time) error {
+	return nil
+}
+
+func (m *mockAuthRepo) RotateSessionToken(ctx context.Context, sessionID string, oldHash, newHash []byte, expiresAt time.Time) (bool, error) {
+	return false, nil
+}
+
+func (m *mockAuthRepo) GetRetiredSessionToken(ctx context.Context, tokenHash []byte) (domain.RetiredSessionToken, error) {
+	return domain.RetiredSessionToken{}, domain.ErrNotFound
+}
+
 func (m *mockAuthRepo) SetupRecovery(ctx context.Context, input domain.SetupRecoveryInput) error {
 	return nil
 }
@@ -143,12 +202,16 @@ func (m *mockAuthRepo) UpdatePassword(ctx context.Context, input domain.ResetPas
 	return nil
 }
 
+func (m *mockAuthRepo) SetCredentialsCompromised(ctx context.Context, userID string) error {
+	return nil
+}
+
 func (m *mockAuthRepo) UpdateDisplayName(ctx context.Context, userID string, name string) error {
 	return nil
 }
 
 func newTestAuthService(repo *mockAuthRepo) *service.AuthService {
-	return service.NewAuthService(repo, nil, "pepper123", time.Hour, "Test Issuer")
+	return service.NewAuthService(repo, nil, nil, "pepper123", time.Hour, "Test Issuer")
 }
 
 func TestRegister_Success(t *testing.T) {
@@ -166,7 +229,7 @@ func TestRegister_Success(t *testing.T) {
 	}
 
 	svc := newTestAuthService(repo)
-	resp, err := svc.Register(context.Background(), "test@example.com", "Password123!", "Test User")
+	resp, err := svc.Register(context.Background(), "test@example.com", "Correct-Horse-9", "Test User")
 	if err != nil {
 		t.Fatalf("Register failed: %v", err)
 	}
@@ -180,6 +243,37 @@ func TestRegister_Success(t *testing.T) {
 	}
 }
 
+func TestRegister_BusyWhenHashingSlotsAreTaken(t *testing.T) {
+	repo := &mockAuthRepo{createUserFn: func(ctx context.Context, input domain.CreateUserInput) error { return nil }}
+	svc := newTestAuthService(repo)
+	svc.UsePasswordHashLimit(1, 0)
+
+	// Each hash takes tens of milliseconds, so with one slot and no
+	// queueing some of these overlap and are turned away.
+	const attempts = 8
+	errs := make(chan error, attempts)
+	for range attempts {
+		go func() {
+			_, err := svc.Register(context.Background(), "test@example.com", "Correct-Horse-9", "Test User")
+			errs <- err
+		}()
+	}
+	var ok, busy int
+	for range attempts {
+		switch err := <-errs; {
+		case err == nil:
+			ok++
+		case errors.Is(err, domain.ErrServerBusy):
+			busy++
+		default:
+			t.Fatalf("Register: %v", err)
+		}
+	}
+	if ok == 0 || busy == 0 {
+		t.Fatalf("%d registered and %d busy; want some of each", ok, busy)
+	}
+}
+
 func TestRegister_EmailTaken(t *testing.T) {
 	repo := &mockAuthRepo{
 		createUserFn: func(ctx context.Context, input domain.CreateUserInput) error {
@@ -188,7 +282,7 @@ func TestRegister_EmailTaken(t *testing.T) {
 	}
 
 	svc := newTestAuthService(repo)
-	_, err := svc.Register(context.Background(), "test@example.com", "Password123!", "Test User")
+	_, err := svc.Register(context.Background(), "test@example.com", "Correct-Horse-9", "Test User")
 	if err != domain.ErrEmailTaken {
 		t.Errorf("expected ErrEmailTaken, got %v", err)
 	}
@@ -246,3 +340,99 @@ func TestAuthenticate(t *testing.T) {
 		t.Errorf("expected UserID 123, got %s", session.UserID)
 	}
 }
+
+type txContextKey struct{}
+
+// recordingTransactor marks the context it hands to fn and remembers the
+// error fn returned, standing in for a storage transaction.
+type recordingTransactor struct {
+	calls int
+	err   error
+}
+
+func (r *recordingTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	r.calls++
+	r.err = fn(context.WithValue(ctx, txContextKey{}, true))
+	return r.err
+}
+
+func TestResetPassword_RunsInOneTransaction(t *testing.T) {
+	inTx := func(ctx context.Context) bool { return ctx.Value(txContextKey{}) != nil }
+	errCreate := errors.New("insert failed")
+
+	repo := &mockAuthRepo{
+		getActiveSessionFn: func(ctx context.Context, tokenHash []byte) (domain.Session, error) {
+			return domain.Session{UserID: "123", Email: "test@example.com"}, nil
+		},
+		getUserAuthByEmailFn: func(ctx context.Context, email string) (domain.UserAuthRecord, error) {
+			if !inTx(ctx) {
+				t.Error("GetUserAuthByEmail ran outside the transaction")
+			}
+			return domain.UserAuthRecord{UserID: "123", Email: email, PasswordHash: []byte("old-hash")}, nil
+		},
+		createSessionFn: func(ctx context.Context, input domain.CreateSessionInput) error {
+			if !inTx(ctx) {
+				t.Error("CreateSession ran outside the transaction")
+			}
+			return errCreate
+		},
+	}
+	tx := &recordingTransactor{}
+	svc := service.NewAuthService(repo, tx, nil, "pepper123", time.Hour, "Test Issuer")
+
+	_, err := svc.ResetPassword(context.Background(), "recovery-token", "Correct-Horse-Battery-9", "", "", "")
+	if !errors.Is(err, errCreate) {
+		t.Fatalf("expected the CreateSession error, got %v", err)
+	}
+	if tx.calls != 1 || !errors.Is(tx.err, errCreate) {
+		t.Fatalf("expected one rolled-back transaction, got %d calls ending in %v", tx.calls, tx.err)
+	}
+}
+
+func TestPurgeSessions_DeletesInBatches(t *testing.T) {
+	remaining := 25
+	var cursors []string
+	var revokedCutoff time.Time
+	repo := &mockAuthRepo{
+		deleteExpiredSessionsFn: func(ctx context.Context, revokedBefore time.Time, afterID string, limit int) (domain.SessionPurgeBatch, error) {
+			cursors = append(cursors, afterID)
+			revokedCutoff = revokedBefore
+			n := min(remaining, limit)
+			remaining -= n
+			return domain.SessionPurgeBatch{Deleted: int64(n), LastID: fmt.Sprintf("id-%02d", 25-remaining)}, nil
+		},
+	}
+
+	stats, err := newTestAuthService(repo).PurgeSessions(context.Background(), 24*time.Hour, 10, time.Millisecond)
+	if err != nil {
+		t.Fatalf("PurgeSessions: %v", err)
+	}
+	if stats.Deleted != 25 || len(stats.Batches) != 3 {
+		t.Fatalf("purged %d in %d batches, want 25 in 3", stats.Deleted, len(stats.Batches))
+	}
+	if want := []string{"", "id-10", "id-20"}; !slices.Equal(cursors, want) {
+		t.Fatalf("batch cursors = %q, want %q", cursors, want)
+	}
+	if stats.Batches[2].Deleted != 5 {
+		t.Fatalf("last batch = %+v, want 5 deleted", stats.Batches[2])
+	}
+	if age := time.Since(revokedCutoff); age < 24*time.Hour || age > 25*time.Hour {
+		t.Fatalf("revoked cutoff is %s ago, want the 24h retention", age)
+	}
+}
+
+func TestEnableTOTP_LockedOutReportsUntilWhen(t *testing.T) {
+	lockedUntil := time.Now().Add(5 * time.Minute).UTC().Truncate(time.Second)
+	repo := &mockAuthRepo{
+		getTOTPStateFn: func(ctx context.Context, userID string) (domain.TOTPState, error) {
+			return domain.TOTPState{FailedAttempts: 5, LockedUntil: &lockedUntil}, nil
+		},
+	}
+	_, err := newTestAuthService(repo).EnableTOTP(context.Background(), "user-1", "123456")
+	if !errors.Is(err, domain.ErrMFARateLimited) {
+		t.Fatalf("err = %v, want mfa_rate_limited", err)
+	}
+	if e, _ := domain.AsError(err); !e.LockedUntil.Equal(lockedUntil) {
+		t.Fatalf("locked until = %s, want %s", e.LockedUntil, lockedUntil)
+	}
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+)
+
+// sessionTouchInterval is how stale a session's last use may get before
+// Authenticate records it again, so busy sessions do not write on every
+// request.
+const sessionTouchInterval = time.Minute
+
+// ListSessions lists the user's active sessions, newest first.
+func (s *AuthService) ListSessions(ctx context.Context, userID string) ([]domain.UserSession, error) {
+	sessions, err := s.repo.ListUserSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs one of the user's sessions out, the caller's own
+// included.
+func (s *AuthService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return domain.ErrNotFound
+	}
+	revoked, err := s.repo.RevokeUserSession(ctx, userID, sessionID)
+	if err != nil {
+		return fmt.Errorf("revoke session: %w", err)
+	}
+	if !revoked {
+		return domain.ErrNotFound
+	}
+	s.sessionEvents.SessionsRevoked(userID)
+
+	uid, _ := uuid.Parse(userID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeAuthSessionRevoked, map[string]any{
+		"session_id": sessionID,
+	})
+	return nil
+}
+
+// RevokeOtherSessions signs the user out everywhere but the session of the
+// caller and reports how many sessions that was.
+func (s *AuthService) RevokeOtherSessions(ctx context.Context, session domain.Session) (int64, error) {
+	revoked, err := s.repo.RevokeOtherUserSessions(ctx, session.UserID, session.ID)
+	if err != nil {
+		return 0, fmt.Errorf("revoke other sessions: %w", err)
+	}
+	if revoked == 0 {
+		return 0, nil
+	}
+	s.sessionEvents.SessionsRevoked(session.UserID)
+
+	uid, _ := uuid.Parse(session.UserID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeAuthSessionRevoked, map[string]any{
+		"kept_session_id": session.ID,
+		"revoked":         revoked,
+	})
+	return revoked, nil
+}
+
+// touchSession records that session was just used, unless it already was
+// within sessionTouchInterval. A failure is logged rather than failing the
+// request.
+func (s *AuthService) touchSession(ctx context.Context, session *domain.Session) {
+	now := s.now().UTC()
+	if session.LastUsedAt != nil && now.Sub(*session.LastUsedAt) < sessionTouchInterval {
+		return
+	}
+	if err := s.repo.TouchSession(ctx, session.ID, now); err != nil {
+		slog.WarnContext(ctx, "record session use failed", slog.String("session_id", session.ID), slog.Any("error", err))
+		return
+	}
+	session.LastUsedAt = &now
+}
@@ -0,0 +1,100 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestListAndRevokeSessions(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	svc := service.NewAuthService(store.Auth(), store.Transactor(), audit, "pepper", time.Hour, "pmv2")
+
+	if _, err := svc.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if _, err := svc.Register(ctx, "bob@example.com", "Correct-Horse-9", "Bob"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	login := func(email, device string) domain.Session {
+		t.Helper()
+		out, err := svc.Login(ctx, domain.LoginInput{Email: email, Password: "Correct-Horse-9", DeviceName: device, UserAgent: device + "/1.0"})
+		if err != nil {
+			t.Fatalf("login: %v", err)
+		}
+		session, err := svc.Authenticate(ctx, out.SessionToken)
+		if err != nil {
+			t.Fatalf("authenticate: %v", err)
+		}
+		return session
+	}
+	laptop := login("ada@example.com", "laptop")
+	phone := login("ada@example.com", "phone")
+	login("ada@example.com", "tablet")
+	bob := login("bob@example.com", "laptop")
+
+	if laptop.LastUsedAt == nil {
+		t.Fatal("authenticate did not record the session's use")
+	}
+	sessions, err := svc.ListSessions(ctx, laptop.UserID)
+	if err != nil {
+		t.Fatalf("list sessions: %v", err)
+	}
+	if len(sessions) != 3 {
+		t.Fatalf("sessions = %+v", sessions)
+	}
+	for _, s := range sessions {
+		if s.LastUsedAt == nil || s.DeviceName == "" || s.UserAgent != s.DeviceName+"/1.0" {
+			t.Fatalf("session = %+v", s)
+		}
+	}
+
+	if err := svc.RevokeSession(ctx, laptop.UserID, bob.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("revoke another user's session: err = %v", err)
+	}
+	if err := svc.RevokeSession(ctx, laptop.UserID, "not-a-session"); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("revoke malformed ID: err = %v", err)
+	}
+	if err := svc.RevokeSession(ctx, laptop.UserID, phone.ID); err != nil {
+		t.Fatalf("revoke session: %v", err)
+	}
+	if err := svc.RevokeSession(ctx, laptop.UserID, phone.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("revoke twice: err = %v", err)
+	}
+
+	revoked, err := svc.RevokeOtherSessions(ctx, laptop)
+	if err != nil {
+		t.Fatalf("revoke other sessions: %v", err)
+	}
+	if revoked != 1 {
+		t.Fatalf("revoked = %d, want the tablet only", revoked)
+	}
+	sessions, err = svc.ListSessions(ctx, laptop.UserID)
+	if err != nil {
+		t.Fatalf("list sessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != laptop.ID {
+		t.Fatalf("sessions after revoking the others = %+v", sessions)
+	}
+	if others, _ := svc.ListSessions(ctx, bob.UserID); len(others) != 1 {
+		t.Fatalf("another user's sessions = %+v", others)
+	}
+
+	uid := uuid.MustParse(laptop.UserID)
+	events, _, err := store.Audit().ListEvents(ctx, 10, 0, domain.AuditFilter{UserID: &uid, EventTypes: []domain.EventType{domain.EventTypeAuthSessionRevoked}})
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("revocation events = %d, want 2", len(events))
+	}
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/util"
+)
+
+// credentialResetSaltLength is the length of the salt a client picks for
+// its new master password, the same as util.NewSalt.
+const credentialResetSaltLength = 32
+
+// UseVaultKeys lets a master password change re-wrap the item keys of the
+// vault, which are wrapped under a key derived from the password.
+func (s *AuthService) UseVaultKeys(vault domain.VaultRepository) {
+	s.vault = vault
+}
+
+// ResetCompromisedCredentials changes the master password of an account an
+// admin flagged compromised. The item keys of the vault, re-wrapped by the
+// client under the key derived from the new password and salt, replace the
+// old ones in the same transaction, which also clears the flag and signs
+// every session out; the caller gets a fresh, unrestricted one.
+//
+// Item history and the recovery key's copy of the vault key are left
+// wrapped under the old key.
+func (s *AuthService) ResetCompromisedCredentials(ctx context.Context, session domain.Session, input domain.CredentialResetInput, deviceName, ipAddr, userAgent string) (domain.LoginOutput, error) {
+	if s.vault == nil {
+		return domain.LoginOutput{}, fmt.Errorf("credential reset: vault keys are not configured")
+	}
+	record, err := s.repo.GetUserAuthByID(ctx, session.UserID)
+	if err != nil {
+		return domain.LoginOutput{}, fmt.Errorf("read auth record: %w", err)
+	}
+	if !record.CredentialsCompromised {
+		return domain.LoginOutput{}, domain.ErrCredentialResetNotDue
+	}
+	if record.Passwordless() {
+		return domain.LoginOutput{}, domain.ErrPasswordlessAccount
+	}
+
+	credentials, err := s.newPasswordCredentials(ctx, record, input)
+	if err != nil {
+		return domain.LoginOutput{}, err
+	}
+
+	sessionToken, err := util.NewOpaqueToken(32)
+	if err != nil {
+		return domain.LoginOutput{}, err
+	}
+	newSessionID, err := util.NewUUID()
+	if err != nil {
+		return domain.LoginOutput{}, err
+	}
+
+	expiresAt := s.now().UTC().Add(s.sessionTTL)
+	err = withinTx(ctx, s.tx, func(ctx context.Context) error {
+		if err := s.repo.UpdatePassword(ctx, credentials); err != nil {
+			return fmt.Errorf("update password: %w", err)
+		}
+		if err := s.vault.RewrapVaultItemKeys(ctx, record.UserID, input.Items); err != nil {
+			return err
+		}
+		if _, err := s.repo.RevokeAllUserSessions(ctx, record.UserID); err != nil {
+			return fmt.Errorf("revoke sessions after credential reset: %w", err)
+		}
+
+		uid, _ := uuid.Parse(record.UserID)
+		if err := s.audit.Record(ctx, &uid, domain.EventTypeAuthPasswordReset, map[string]any{
+			"method":          "credential_reset",
+			"items_rewrapped": len(input.Items),
+		}); err != nil {
+			return err
+		}
+
+		if err := s.repo.CreateSession(ctx, domain.CreateSessionInput{
+			SessionID:  newSessionID,
+			UserID:     record.UserID,
+			TokenHash:  util.HashToken(sessionToken, s.pepper),
+			DeviceName: util.TrimOrEmpty(deviceName),
+			IPAddr:     util.NormalizeIP(ipAddr),
+			UserAgent:  util.TrimOrEmpty(userAgent),
+			DeviceID:   session.DeviceID,
+			ExpiresAt:  expiresAt,
+		}); err != nil {
+			return fmt.Errorf("create session after credential reset: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.LoginOutput{}, err
+	}
+	s.sessionEvents.SessionsRevoked(record.UserID)
+
+	return domain.LoginOutput{
+		SessionToken: sessionToken,
+		ExpiresAt:    expiresAt,
+		UserID:       record.UserID,
+		Email:        record.Email,
+		Name:         record.Name,
+		TOTPEnabled:  record.TOTPEnabled,
+	}, nil
+}
+
+// ChangePassword changes the master password of a signed-in account at its
+// owner's request. As with ResetCompromisedCredentials, the client picks
+// the new salt and sends every item key re-wrapped under the key derived
+// from it; the keys, the password and the revocation of every other
+// session change in one transaction, so a vault is never left wrapped
+// under a key its password no longer derives. The caller's session stays
+// signed in. It returns how many sessions were signed out.
+//
+// Item history and the recovery key's copy of the vault key are left
+// wrapped under the old key.
+func (s *AuthService) ChangePassword(ctx context.Context, session domain.Session, input domain.CredentialResetInput) (int64, error) {
+	if s.vault == nil {
+		return 0, fmt.Errorf("change password: vault keys are not configured")
+	}
+	record, err := s.repo.GetUserAuthByID(ctx, session.UserID)
+	if err != nil {
+		return 0, fmt.Errorf("read auth record: %w", err)
+	}
+	if record.Passwordless() {
+		return 0, domain.ErrPasswordlessAccount
+	}
+	credentials, err := s.newPasswordCredentials(ctx, record, input)
+	if err != nil {
+		return 0, err
+	}
+
+	var revoked int64
+	err = withinTx(ctx, s.tx, func(ctx context.Context) error {
+		if err := s.repo.UpdatePassword(ctx, credentials); err != nil {
+			return fmt.Errorf("update password: %w", err)
+		}
+		if err := s.vault.RewrapVaultItemKeys(ctx, record.UserID, input.Items); err != nil {
+			return err
+		}
+		var err error
+		if revoked, err = s.repo.RevokeOtherUserSessions(ctx, record.UserID, session.ID); err != nil {
+			return fmt.Errorf("revoke sessions after password change: %w", err)
+		}
+
+		uid, _ := uuid.Parse(record.UserID)
+		return s.audit.Record(ctx, &uid, domain.EventTypeAuthPasswordReset, map[string]any{
+			"method":           "change_password",
+			"items_rewrapped":  len(input.Items),
+			"sessions_revoked": revoked,
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	if revoked > 0 {
+		s.sessionEvents.SessionsRevoked(record.UserID)
+	}
+	return revoked, nil
+}
+
+// newPasswordCredentials checks a master password change, current password
+// first, and hashes the new password with the client's salt; see
+// hashNewPassword.
+func (s *AuthService) newPasswordCredentials(ctx context.Context, record domain.UserAuthRecord, input domain.CredentialResetInput) (domain.ResetPasswordInput, error) {
+	params, err := util.ParseArgon2Params(record.RawParams)
+	if err != nil {
+		return domain.ResetPasswordInput{}, fmt.Errorf("parse hash params: %w", err)
+	}
+	ok, err := s.verifyPassword(ctx, input.CurrentPassword, record.Salt, record.PasswordHash, params)
+	if err != nil {
+		return domain.ResetPasswordInput{}, err
+	}
+	if !ok {
+		s.recordPasswordFailure(ctx, record.UserID, s.now().UTC())
+		return domain.ResetPasswordInput{}, domain.ErrInvalidCredentials
+	}
+	if subtle.ConstantTimeCompare([]byte(input.CurrentPassword), []byte(input.NewPassword)) == 1 {
+		return domain.ResetPasswordInput{}, domain.ErrPasswordUnchanged
+	}
+	return s.hashNewPassword(ctx, record, input.NewPassword, input.NewSalt)
+}
+
+// hashNewPassword checks a new master password and the salt the client
+// picked for it, and hashes the password with the parameters new passwords
+// get.
+func (s *AuthService) hashNewPassword(ctx context.Context, record domain.UserAuthRecord, password string, salt []byte) (domain.ResetPasswordInput, error) {
+	if err := util.ValidatePasswordStrength(password, record.Email, record.Name); err != nil {
+		return domain.ResetPasswordInput{}, err
+	}
+	if err := s.checkBreachedPassword(ctx, password); err != nil {
+		return domain.ResetPasswordInput{}, err
+	}
+	if len(salt) != credentialResetSaltLength || bytes.Equal(salt, record.Salt) {
+		return domain.ResetPasswordInput{}, domain.ErrInvalidNewSalt
+	}
+
+	newParams := s.hashParams
+	paramsJSON, err := util.MarshalArgon2Params(newParams)
+	if err != nil {
+		return domain.ResetPasswordInput{}, fmt.Errorf("marshal argon2 params: %w", err)
+	}
+	passwordHash, err := s.hashPasswordWithSalt(ctx, password, salt, newParams)
+	if err != nil {
+		return domain.ResetPasswordInput{}, err
+	}
+	return domain.ResetPasswordInput{
+		UserID:       record.UserID,
+		Algo:         "argon2id",
+		ParamsJSON:   paramsJSON,
+		Salt:         salt,
+		PasswordHash: passwordHash,
+	}, nil
+}
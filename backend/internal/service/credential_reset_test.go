@@ -0,0 +1,182 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestCredentialResetAfterAdminFlag(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	svc := service.NewAuthService(store.Auth(), store.Transactor(), audit, "pepper", time.Hour, "pmv2")
+	svc.UseVaultKeys(store.Vault())
+	admin := service.NewAdminService(store.Admin(), store.Auth(), audit, 0)
+
+	user, err := svc.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	var itemIDs []string
+	for range 2 {
+		item, err := store.Vault().CreateVaultItem(ctx, domain.CreateVaultItemInput{
+			OwnerUserID: user.UserID, Ciphertext: []byte("secret"), Nonce: []byte("n"), WrappedDEK: []byte("old"), WrapNonce: []byte("w"), AlgoVersion: "v1",
+		})
+		if err != nil {
+			t.Fatalf("create item: %v", err)
+		}
+		itemIDs = append(itemIDs, item.ID)
+	}
+	// Trashed items are re-wrapped too.
+	if _, err := store.Vault().DeleteVaultItemForOwner(ctx, itemIDs[1], user.UserID); err != nil {
+		t.Fatalf("trash item: %v", err)
+	}
+	before, err := svc.Login(ctx, domain.LoginInput{Email: "ada@example.com", Password: "Correct-Horse-9"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	if revoked, err := admin.FlagCredentialsCompromised(ctx, user.UserID); err != nil || revoked != 1 {
+		t.Fatalf("flag = %d, %v", revoked, err)
+	}
+	if _, err := svc.Authenticate(ctx, before.SessionToken); err == nil {
+		t.Fatal("the session outlived the flag")
+	}
+
+	restricted, err := svc.Login(ctx, domain.LoginInput{Email: "ada@example.com", Password: "Correct-Horse-9"})
+	if err != nil || !restricted.CredentialResetRequired {
+		t.Fatalf("login after flag = %+v, %v", restricted, err)
+	}
+	session, err := svc.Authenticate(ctx, restricted.SessionToken)
+	if err != nil || !session.CredentialResetRequired {
+		t.Fatalf("session = %+v, %v", session, err)
+	}
+
+	newSalt := bytes.Repeat([]byte{7}, 32)
+	keys := []domain.VaultItemKey{
+		{ItemID: itemIDs[0], WrappedDEK: []byte("new-0"), WrapNonce: []byte("w0")},
+		{ItemID: itemIDs[1], WrappedDEK: []byte("new-1"), WrapNonce: []byte("w1")},
+	}
+	reset := func(newPassword string, keys []domain.VaultItemKey) (domain.LoginOutput, error) {
+		return svc.ResetCompromisedCredentials(ctx, session, domain.CredentialResetInput{
+			UserID: user.UserID, CurrentPassword: "Correct-Horse-9", NewPassword: newPassword, NewSalt: newSalt, Items: keys,
+		}, "laptop", "203.0.113.7", "test")
+	}
+	if _, err := reset("Correct-Horse-9", keys); !errors.Is(err, domain.ErrPasswordUnchanged) {
+		t.Fatalf("same password: err = %v", err)
+	}
+	if _, err := reset("Battery-Staple-7", keys[:1]); !errors.Is(err, domain.ErrRewrapIncomplete) {
+		t.Fatalf("missing item: err = %v", err)
+	}
+	// Nothing changed: the old password still signs in, restricted.
+	if out, err := svc.Login(ctx, domain.LoginInput{Email: "ada@example.com", Password: "Correct-Horse-9"}); err != nil || !out.CredentialResetRequired {
+		t.Fatalf("login after failed reset = %+v, %v", out, err)
+	}
+
+	out, err := reset("Battery-Staple-7", keys)
+	if err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, restricted.SessionToken); err == nil {
+		t.Fatal("the restricted session outlived the reset")
+	}
+	if session, err := svc.Authenticate(ctx, out.SessionToken); err != nil || session.CredentialResetRequired {
+		t.Fatalf("session after reset = %+v, %v", session, err)
+	}
+	for i, id := range itemIDs {
+		item, err := store.Vault().GetVaultItemByIDForOwner(ctx, id, user.UserID)
+		if err != nil || !bytes.Equal(item.WrappedDEK, keys[i].WrappedDEK) {
+			t.Fatalf("item %d = %+v, %v", i, item, err)
+		}
+	}
+	if salt, err := store.Vault().GetVaultSaltForUser(ctx, user.UserID); err != nil || !bytes.Equal(salt, newSalt) {
+		t.Fatalf("salt = %x, %v", salt, err)
+	}
+	if _, err := svc.Login(ctx, domain.LoginInput{Email: "ada@example.com", Password: "Correct-Horse-9"}); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("old password: err = %v", err)
+	}
+	if _, err := reset("Another-Horse-5", keys); !errors.Is(err, domain.ErrCredentialResetNotDue) {
+		t.Fatalf("reset again: err = %v", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	svc := service.NewAuthService(store.Auth(), store.Transactor(), audit, "pepper", time.Hour, "pmv2")
+	svc.UseVaultKeys(store.Vault())
+
+	user, err := svc.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	item, err := store.Vault().CreateVaultItem(ctx, domain.CreateVaultItemInput{
+		OwnerUserID: user.UserID, Ciphertext: []byte("secret"), Nonce: []byte("n"), WrappedDEK: []byte("old"), WrapNonce: []byte("w"), AlgoVersion: "v1",
+	})
+	if err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	login := func(password string) (domain.LoginOutput, error) {
+		return svc.Login(ctx, domain.LoginInput{Email: "ada@example.com", Password: password})
+	}
+	current, err := login("Correct-Horse-9")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	other, err := login("Correct-Horse-9")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	session, err := svc.Authenticate(ctx, current.SessionToken)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+
+	newSalt := bytes.Repeat([]byte{9}, 32)
+	keys := []domain.VaultItemKey{{ItemID: item.ID, WrappedDEK: []byte("new"), WrapNonce: []byte("w2")}}
+	change := func(currentPassword string, keys []domain.VaultItemKey) (int64, error) {
+		return svc.ChangePassword(ctx, session, domain.CredentialResetInput{
+			UserID: user.UserID, CurrentPassword: currentPassword, NewPassword: "Battery-Staple-7", NewSalt: newSalt, Items: keys,
+		})
+	}
+	if _, err := change("Wrong-Horse-1", keys); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("wrong current password: err = %v", err)
+	}
+	if _, err := change("Correct-Horse-9", nil); !errors.Is(err, domain.ErrRewrapIncomplete) {
+		t.Fatalf("missing item: err = %v", err)
+	}
+	if _, err := login("Correct-Horse-9"); err != nil {
+		t.Fatalf("login after failed change: %v", err)
+	}
+
+	// The failed attempt above signed in a third session.
+	if revoked, err := change("Correct-Horse-9", keys); err != nil || revoked != 2 {
+		t.Fatalf("ChangePassword = %d, %v; want the two other sessions revoked", revoked, err)
+	}
+	if _, err := svc.Authenticate(ctx, current.SessionToken); err != nil {
+		t.Fatalf("the caller's session was revoked: %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, other.SessionToken); err == nil {
+		t.Fatal("another session outlived the password change")
+	}
+	if got, err := store.Vault().GetVaultItemByIDForOwner(ctx, item.ID, user.UserID); err != nil || string(got.WrappedDEK) != "new" {
+		t.Fatalf("item = %+v, %v; want the re-wrapped key", got, err)
+	}
+	if salt, err := store.Vault().GetVaultSaltForUser(ctx, user.UserID); err != nil || !bytes.Equal(salt, newSalt) {
+		t.Fatalf("salt = %x, %v", salt, err)
+	}
+	if _, err := login("Correct-Horse-9"); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("old password: err = %v", err)
+	}
+	if _, err := login("Battery-Staple-7"); err != nil {
+		t.Fatalf("new password: %v", err)
+	}
+}
@@ -0,0 +1,416 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/mailer"
+)
+
+// DataExportService builds the archive behind POST /auth/export-data: a zip
+// of JSON files holding every piece of personal data the server has about
+// a user. Vault contents stay encrypted; the server never had the keys.
+type DataExportService struct {
+	exports   domain.DataExportRepository
+	admin     domain.AdminRepository
+	vault     domain.VaultRepository
+	folders   domain.FolderRepository
+	outbox    domain.OutboxRepository
+	tx        domain.Transactor
+	audit     *AuditService
+	emails    Emailer
+	ttl       time.Duration
+	anomalies *AnomalyService
+}
+
+// NewDataExportService builds the service. Ready archives can be downloaded
+// for ttl. emails may be nil, in which case nobody is told an export is
+// ready and clients have to poll.
+func NewDataExportService(exports domain.DataExportRepository, admin domain.AdminRepository, vault domain.VaultRepository, folders domain.FolderRepository, outbox domain.OutboxRepository, tx domain.Transactor, audit *AuditService, emails Emailer, ttl time.Duration) *DataExportService {
+	return &DataExportService{
+		exports: exports,
+		admin:   admin,
+		vault:   vault,
+		folders: folders,
+		outbox:  outbox,
+		tx:      tx,
+		audit:   audit,
+		emails:  emails,
+		ttl:     ttl,
+	}
+}
+
+// UseAnomalyDetection has export requests checked for coming from a device
+// that only just signed in.
+func (s *DataExportService) UseAnomalyDetection(anomalies *AnomalyService) {
+	s.anomalies = anomalies
+}
+
+// RequestExport queues a new export, or returns the one already being built
+// for the user.
+func (s *DataExportService) RequestExport(ctx context.Context, userID string) (domain.DataExport, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return domain.DataExport{}, domain.ErrUnauthorizedSession
+	}
+	s.anomalies.CheckExport(ctx, userID)
+
+	pending, err := s.exports.FindPendingDataExport(ctx, userID)
+	if err == nil {
+		return pending, nil
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		return domain.DataExport{}, fmt.Errorf("find pending data export: %w", err)
+	}
+
+	var export domain.DataExport
+	err = withinTx(ctx, s.tx, func(ctx context.Context) error {
+		created, err := s.exports.CreateDataExport(ctx, domain.DataExport{ID: uuid.NewString(), UserID: userID})
+		if err != nil {
+			return err
+		}
+		payload, err := json.Marshal(domain.DataExportRequest{ExportID: created.ID, UserID: userID})
+		if err != nil {
+			return fmt.Errorf("marshal data export request: %w", err)
+		}
+		if err := s.outbox.Enqueue(ctx, domain.OutboxTopicDataExport, payload); err != nil {
+			return fmt.Errorf("enqueue data export: %w", err)
+		}
+		export = created
+		return s.audit.Record(ctx, &uid, domain.EventTypeAuthDataExportRequested, map[string]any{"export_id": created.ID})
+	})
+	if err != nil {
+		return domain.DataExport{}, fmt.Errorf("request data export: %w", err)
+	}
+	return export, nil
+}
+
+// GetExport returns one of the user's exports.
+func (s *DataExportService) GetExport(ctx context.Context, userID, exportID string) (domain.DataExport, error) {
+	if _, err := uuid.Parse(exportID); err != nil {
+		return domain.DataExport{}, domain.ErrNotFound
+	}
+	return s.exports.GetDataExport(ctx, userID, exportID)
+}
+
+// DownloadExport returns a ready, unexpired archive. An export still being
+// built reports ErrDataExportNotReady; an expired one is ErrNotFound.
+func (s *DataExportService) DownloadExport(ctx context.Context, userID, exportID string) (domain.DataExport, []byte, error) {
+	export, archive, err := s.readyArchive(ctx, userID, exportID)
+	if err != nil {
+		return domain.DataExport{}, nil, err
+	}
+
+	uid, _ := uuid.Parse(userID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeAuthDataExportDownloaded, map[string]any{"export_id": exportID})
+	return export, archive, nil
+}
+
+// DiffExport compares the vault items in a ready export with the user's live
+// vault. Only IDs and versions are compared; nothing is decrypted.
+func (s *DataExportService) DiffExport(ctx context.Context, userID, exportID string) (domain.VaultBackupDiff, error) {
+	export, archive, err := s.readyArchive(ctx, userID, exportID)
+	if err != nil {
+		return domain.VaultBackupDiff{}, err
+	}
+	backup, err := archivedItemVersions(archive)
+	if err != nil {
+		return domain.VaultBackupDiff{}, err
+	}
+	live, err := s.vault.ListVaultItemsByOwner(ctx, userID)
+	if err != nil {
+		return domain.VaultBackupDiff{}, fmt.Errorf("list vault items: %w", err)
+	}
+
+	diff := domain.VaultBackupDiff{ExportID: export.ID, TakenAt: export.CreatedAt}
+	if export.CompletedAt != nil {
+		diff.TakenAt = *export.CompletedAt
+	}
+	for _, item := range live {
+		backupVersion, ok := backup[item.ID]
+		delete(backup, item.ID)
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, domain.VaultItemDiff{ItemID: item.ID, LiveVersion: item.Version})
+		case backupVersion != item.Version:
+			diff.Changed = append(diff.Changed, domain.VaultItemDiff{ItemID: item.ID, BackupVersion: backupVersion, LiveVersion: item.Version})
+		default:
+			diff.Unchanged++
+		}
+	}
+	for id, version := range backup {
+		diff.Removed = append(diff.Removed, domain.VaultItemDiff{ItemID: id, BackupVersion: version})
+	}
+	for _, items := range [][]domain.VaultItemDiff{diff.Added, diff.Removed, diff.Changed} {
+		slices.SortFunc(items, func(a, b domain.VaultItemDiff) int { return strings.Compare(a.ItemID, b.ItemID) })
+	}
+	return diff, nil
+}
+
+// readyArchive returns a ready, unexpired export and its archive.
+func (s *DataExportService) readyArchive(ctx context.Context, userID, exportID string) (domain.DataExport, []byte, error) {
+	export, err := s.GetExport(ctx, userID, exportID)
+	if err != nil {
+		return domain.DataExport{}, nil, err
+	}
+	if export.Status != domain.DataExportReady {
+		return domain.DataExport{}, nil, domain.ErrDataExportNotReady
+	}
+	if export.Expired(time.Now()) {
+		return domain.DataExport{}, nil, domain.ErrNotFound
+	}
+
+	archive, err := s.exports.GetDataExportArchive(ctx, userID, exportID)
+	if err != nil {
+		return domain.DataExport{}, nil, err
+	}
+	return export, archive, nil
+}
+
+// archivedItemVersions reads the version of every item that was not in the
+// trash when the archive was built.
+func archivedItemVersions(archive []byte) (map[string]int, error) {
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, fmt.Errorf("open export archive: %w", err)
+	}
+	f, err := zr.Open("vault_items.json")
+	if err != nil {
+		return nil, fmt.Errorf("open vault_items.json: %w", err)
+	}
+	defer f.Close()
+
+	var items []struct {
+		ID        string     `json:"id"`
+		Version   int        `json:"version"`
+		DeletedAt *time.Time `json:"deleted_at"`
+	}
+	if err := json.NewDecoder(f).Decode(&items); err != nil {
+		return nil, fmt.Errorf("decode vault_items.json: %w", err)
+	}
+	versions := make(map[string]int, len(items))
+	for _, item := range items {
+		if item.DeletedAt == nil {
+			versions[item.ID] = item.Version
+		}
+	}
+	return versions, nil
+}
+
+// HandleOutboxEvent builds the archive for a queued export and emails the
+// user that it is ready. An export that is already ready, or whose user is
+// gone, is skipped, so redelivery is safe.
+func (s *DataExportService) HandleOutboxEvent(ctx context.Context, event domain.OutboxEvent) error {
+	var req domain.DataExportRequest
+	if err := json.Unmarshal(event.Payload, &req); err != nil {
+		return fmt.Errorf("decode data export request: %w", err)
+	}
+
+	export, err := s.exports.GetDataExport(ctx, req.UserID, req.ExportID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if export.Status != domain.DataExportPending {
+		return nil
+	}
+
+	user, err := s.admin.GetUser(ctx, req.UserID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load user: %w", err)
+	}
+
+	archive, err := s.buildArchive(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	completedAt := time.Now().UTC()
+	expiresAt := completedAt.Add(s.ttl)
+	return withinTx(ctx, s.tx, func(ctx context.Context) error {
+		if err := s.exports.CompleteDataExport(ctx, export.ID, archive, completedAt, expiresAt); err != nil {
+			return err
+		}
+		if s.emails == nil {
+			return nil
+		}
+		if err := s.emails.Send(ctx, user.Email, mailer.TemplateDataExport, mailer.DataExportData{
+			Name:      user.Name,
+			ExpiresAt: expiresAt.Format(time.RFC1123),
+		}); err != nil {
+			return fmt.Errorf("send data export email: %w", err)
+		}
+		return nil
+	})
+}
+
+// PurgeExpired deletes archives whose download window closed before now.
+func (s *DataExportService) PurgeExpired(ctx context.Context) (int64, error) {
+	return s.exports.DeleteExpiredDataExports(ctx, time.Now().UTC())
+}
+
+type exportProfile struct {
+	ID            string          `json:"id"`
+	Email         string          `json:"email"`
+	Name          string          `json:"name"`
+	Role          domain.UserRole `json:"role"`
+	EmailVerified bool            `json:"email_verified"`
+	TOTPEnabled   bool            `json:"totp_enabled"`
+	LastLoginAt   *time.Time      `json:"last_login_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+type exportSession struct {
+	ID         string    `json:"id"`
+	DeviceName string    `json:"device_name,omitempty"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// exportVaultItem keeps the encrypted fields as they are stored; []byte
+// fields are base64 in JSON.
+type exportVaultItem struct {
+	ID          string          `json:"id"`
+	FolderID    *string         `json:"folder_id,omitempty"`
+	Ciphertext  []byte          `json:"ciphertext"`
+	Nonce       []byte          `json:"nonce"`
+	WrappedDEK  []byte          `json:"wrapped_dek"`
+	WrapNonce   []byte          `json:"wrap_nonce"`
+	AlgoVersion string          `json:"algo_version"`
+	Metadata    json.RawMessage `json:"metadata,omitempty"`
+	Version     int             `json:"version"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	DeletedAt   *time.Time      `json:"deleted_at,omitempty"`
+}
+
+type exportVaultFolder struct {
+	ID             string    `json:"id"`
+	NameCiphertext []byte    `json:"name_ciphertext"`
+	Nonce          []byte    `json:"nonce"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// buildArchive collects the user's data into a zip, one JSON file per kind.
+func (s *DataExportService) buildArchive(ctx context.Context, user domain.AdminUser) ([]byte, error) {
+	sessions, err := s.admin.ListActiveSessions(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	exportedSessions := make([]exportSession, 0, len(sessions))
+	for _, sess := range sessions {
+		exportedSessions = append(exportedSessions, exportSession(sess))
+	}
+
+	uid, err := uuid.Parse(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user id: %w", err)
+	}
+	events := make([]domain.AuditEvent, 0)
+	err = s.audit.ExportEvents(ctx, domain.AuditFilter{UserID: &uid}, func(e domain.AuditEvent) error {
+		events = append(events, e)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list audit events: %w", err)
+	}
+
+	live, err := s.vault.ListVaultItemsByOwner(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("list vault items: %w", err)
+	}
+	trashed, err := s.vault.ListDeletedVaultItemsByOwner(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("list trashed vault items: %w", err)
+	}
+	items := make([]exportVaultItem, 0, len(live)+len(trashed))
+	for _, item := range append(live, trashed...) {
+		exported := exportVaultItem{
+			ID:          item.ID,
+			FolderID:    item.FolderID,
+			Ciphertext:  item.Ciphertext,
+			Nonce:       item.Nonce,
+			WrappedDEK:  item.WrappedDEK,
+			WrapNonce:   item.WrapNonce,
+			AlgoVersion: item.AlgoVersion,
+			Version:     item.Version,
+			CreatedAt:   item.CreatedAt,
+			UpdatedAt:   item.UpdatedAt,
+			DeletedAt:   item.DeletedAt,
+		}
+		if json.Valid(item.Metadata) {
+			exported.Metadata = item.Metadata
+		}
+		items = append(items, exported)
+	}
+
+	folders, err := s.folders.ListFoldersByOwner(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("list vault folders: %w", err)
+	}
+	exportedFolders := make([]exportVaultFolder, 0, len(folders))
+	for _, f := range folders {
+		exportedFolders = append(exportedFolders, exportVaultFolder{
+			ID:             f.ID,
+			NameCiphertext: f.NameCiphertext,
+			Nonce:          f.Nonce,
+			CreatedAt:      f.CreatedAt,
+			UpdatedAt:      f.UpdatedAt,
+		})
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", exportProfile{
+			ID:            user.ID,
+			Email:         user.Email,
+			Name:          user.Name,
+			Role:          user.Role,
+			EmailVerified: user.EmailVerified,
+			TOTPEnabled:   user.TOTPEnabled,
+			LastLoginAt:   user.LastLoginAt,
+			CreatedAt:     user.CreatedAt,
+		}},
+		{"sessions.json", exportedSessions},
+		{"audit_events.json", events},
+		{"vault_items.json", items},
+		{"vault_folders.json", exportedFolders},
+	}
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, fmt.Errorf("add %s: %w", f.name, err)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.data); err != nil {
+			return nil, fmt.Errorf("write %s: %w", f.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("close archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
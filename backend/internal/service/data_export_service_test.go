@@ -0,0 +1,185 @@
+package service_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/mailer"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestDataExportBuildsArchiveAndEmailsUser(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	userID := uuid.NewString()
+	err := store.Auth().CreateUserWithCredentials(ctx, domain.CreateUserInput{
+		UserID: userID, Email: "ada@example.com", Name: "Ada", Algo: "argon2id", ParamsJSON: []byte(`{}`),
+	})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	item, err := store.Vault().CreateVaultItem(ctx, domain.CreateVaultItemInput{
+		OwnerUserID: userID, Ciphertext: []byte("secret"), Nonce: []byte("n"), WrappedDEK: []byte("d"), WrapNonce: []byte("w"), AlgoVersion: "v1",
+	})
+	if err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+
+	audit := service.NewAuditService(store.Audit(), nil)
+	emails := &captureEmailer{}
+	svc := service.NewDataExportService(store.DataExports(), store.Admin(), store.Vault(), store.Folders(), store.Outbox(), store.Transactor(), audit, emails, time.Hour)
+
+	export, err := svc.RequestExport(ctx, userID)
+	if err != nil {
+		t.Fatalf("RequestExport: %v", err)
+	}
+	if again, err := svc.RequestExport(ctx, userID); err != nil || again.ID != export.ID {
+		t.Fatalf("second RequestExport = %+v, %v; want the pending export %s", again, err, export.ID)
+	}
+	if _, _, err := svc.DownloadExport(ctx, userID, export.ID); !errors.Is(err, domain.ErrDataExportNotReady) {
+		t.Fatalf("download before build: err = %v, want ErrDataExportNotReady", err)
+	}
+
+	events, err := store.Outbox().ClaimDue(ctx, 10, time.Minute)
+	if err != nil || len(events) != 1 || events[0].Topic != domain.OutboxTopicDataExport {
+		t.Fatalf("ClaimDue = %+v, %v; want one data export event", events, err)
+	}
+	for range 2 {
+		if err := svc.HandleOutboxEvent(ctx, events[0]); err != nil {
+			t.Fatalf("HandleOutboxEvent: %v", err)
+		}
+	}
+	if len(emails.sent) != 1 || emails.sent[0].to != "ada@example.com" || emails.sent[0].tmpl != mailer.TemplateDataExport {
+		t.Fatalf("sent = %+v, want one data export email to ada@example.com", emails.sent)
+	}
+
+	if _, _, err := svc.DownloadExport(ctx, uuid.NewString(), export.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("download by another user: err = %v, want ErrNotFound", err)
+	}
+	ready, archive, err := svc.DownloadExport(ctx, userID, export.ID)
+	if err != nil {
+		t.Fatalf("DownloadExport: %v", err)
+	}
+	if ready.Status != domain.DataExportReady || ready.SizeBytes != int64(len(archive)) {
+		t.Fatalf("export = %+v, want ready with size %d", ready, len(archive))
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		files[f.Name], _ = io.ReadAll(rc)
+		_ = rc.Close()
+	}
+	for _, name := range []string{"profile.json", "sessions.json", "audit_events.json", "vault_items.json", "vault_folders.json"} {
+		if !json.Valid(files[name]) {
+			t.Fatalf("%s is missing or not JSON: %q", name, files[name])
+		}
+	}
+
+	var profile struct{ Email string }
+	_ = json.Unmarshal(files["profile.json"], &profile)
+	if profile.Email != "ada@example.com" {
+		t.Fatalf("profile email = %q", profile.Email)
+	}
+	var items []struct {
+		ID         string
+		Ciphertext []byte
+	}
+	_ = json.Unmarshal(files["vault_items.json"], &items)
+	if len(items) != 1 || items[0].ID != item.ID || string(items[0].Ciphertext) != "secret" {
+		t.Fatalf("vault items = %+v, want the encrypted item", items)
+	}
+	var auditEvents []domain.AuditEvent
+	_ = json.Unmarshal(files["audit_events.json"], &auditEvents)
+	if len(auditEvents) != 1 || auditEvents[0].EventType != domain.EventTypeAuthDataExportRequested {
+		t.Fatalf("audit events = %+v, want the export request", auditEvents)
+	}
+}
+
+func TestDataExportDiff(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	userID := uuid.NewString()
+	err := store.Auth().CreateUserWithCredentials(ctx, domain.CreateUserInput{
+		UserID: userID, Email: "ada@example.com", Name: "Ada", Algo: "argon2id", ParamsJSON: []byte(`{}`),
+	})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	create := func() domain.VaultItem {
+		t.Helper()
+		item, err := store.Vault().CreateVaultItem(ctx, domain.CreateVaultItemInput{
+			OwnerUserID: userID, Ciphertext: []byte("c"), Nonce: []byte("n"), WrappedDEK: []byte("d"), WrapNonce: []byte("w"), AlgoVersion: "v1",
+		})
+		if err != nil {
+			t.Fatalf("create item: %v", err)
+		}
+		return item
+	}
+	kept, edited, deleted := create(), create(), create()
+
+	audit := service.NewAuditService(store.Audit(), nil)
+	svc := service.NewDataExportService(store.DataExports(), store.Admin(), store.Vault(), store.Folders(), store.Outbox(), store.Transactor(), audit, nil, time.Hour)
+	export, err := svc.RequestExport(ctx, userID)
+	if err != nil {
+		t.Fatalf("RequestExport: %v", err)
+	}
+	if _, err := svc.DiffExport(ctx, userID, export.ID); !errors.Is(err, domain.ErrDataExportNotReady) {
+		t.Fatalf("DiffExport before build: err = %v, want ErrDataExportNotReady", err)
+	}
+	events, err := store.Outbox().ClaimDue(ctx, 10, time.Minute)
+	if err != nil || len(events) != 1 {
+		t.Fatalf("ClaimDue = %+v, %v", events, err)
+	}
+	if err := svc.HandleOutboxEvent(ctx, events[0]); err != nil {
+		t.Fatalf("HandleOutboxEvent: %v", err)
+	}
+
+	updated, err := store.Vault().UpdateVaultItemForOwner(ctx, edited.ID, userID, domain.UpdateVaultItemInput{
+		Ciphertext: []byte("c2"), Nonce: []byte("n"), WrappedDEK: []byte("d"), WrapNonce: []byte("w"), AlgoVersion: "v1",
+	})
+	if err != nil {
+		t.Fatalf("update item: %v", err)
+	}
+	if ok, err := store.Vault().DeleteVaultItemForOwner(ctx, deleted.ID, userID); err != nil || !ok {
+		t.Fatalf("delete item = %v, %v", ok, err)
+	}
+	added := create()
+
+	if _, err := svc.DiffExport(ctx, uuid.NewString(), export.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("DiffExport by another user: err = %v, want ErrNotFound", err)
+	}
+	diff, err := svc.DiffExport(ctx, userID, export.ID)
+	if err != nil {
+		t.Fatalf("DiffExport: %v", err)
+	}
+	want := domain.VaultBackupDiff{
+		ExportID:  export.ID,
+		TakenAt:   diff.TakenAt,
+		Added:     []domain.VaultItemDiff{{ItemID: added.ID, LiveVersion: added.Version}},
+		Removed:   []domain.VaultItemDiff{{ItemID: deleted.ID, BackupVersion: deleted.Version}},
+		Changed:   []domain.VaultItemDiff{{ItemID: edited.ID, BackupVersion: edited.Version, LiveVersion: updated.Version}},
+		Unchanged: 1,
+	}
+	if !reflect.DeepEqual(diff, want) || diff.TakenAt.IsZero() || updated.Version == edited.Version {
+		t.Fatalf("DiffExport = %+v, want %+v (kept %s)", diff, want, kept.ID)
+	}
+}
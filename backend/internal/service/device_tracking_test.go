@@ -0,0 +1,89 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestLoginRecognisesDevices(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	svc := service.NewAuthService(store.Auth(), store.Transactor(), audit, "pepper", time.Hour, "pmv2")
+	svc.UseDeviceTracking(store.Devices())
+
+	user, err := svc.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	login := func(fingerprint, userAgent string) map[string]any {
+		t.Helper()
+		out, err := svc.Login(ctx, domain.LoginInput{
+			Email: "ada@example.com", Password: "Correct-Horse-9", UserAgent: userAgent, DeviceFingerprint: fingerprint,
+		})
+		if err != nil {
+			t.Fatalf("login: %v", err)
+		}
+		session, err := svc.Authenticate(ctx, out.SessionToken)
+		if err != nil {
+			t.Fatalf("authenticate: %v", err)
+		}
+		events, _, err := store.Audit().ListEvents(ctx, 1, 0, domain.AuditFilter{EventTypes: []domain.EventType{domain.EventTypeAuthLoginSuccess}})
+		if err != nil || len(events) != 1 {
+			t.Fatalf("list events = %v, %v", events, err)
+		}
+		var data map[string]any
+		if err := json.Unmarshal(events[0].EventData, &data); err != nil {
+			t.Fatalf("decode event data: %v", err)
+		}
+		if data["device_id"] != session.DeviceID {
+			t.Fatalf("event device %v, session device %q", data["device_id"], session.DeviceID)
+		}
+		return data
+	}
+
+	if data := login("fp-laptop", "Firefox/130"); data["new_device"] != true {
+		t.Fatalf("first sign-in: %v", data)
+	}
+	// A browser update changes the user agent but not the fingerprint.
+	if data := login("fp-laptop", "Firefox/131"); data["new_device"] != false {
+		t.Fatalf("same fingerprint: %v", data)
+	}
+	if data := login("", "Safari/17"); data["new_device"] != true {
+		t.Fatalf("new user agent: %v", data)
+	}
+	if data := login("", "Safari/17"); data["new_device"] != false {
+		t.Fatalf("same user agent: %v", data)
+	}
+
+	devices, err := svc.ListDevices(ctx, user.UserID)
+	if err != nil || len(devices) != 2 {
+		t.Fatalf("devices = %+v, %v", devices, err)
+	}
+	var laptop domain.Device
+	for _, d := range devices {
+		if d.Fingerprinted {
+			laptop = d
+		}
+	}
+	if laptop.Label != "Firefox/131" {
+		t.Fatalf("laptop = %+v", laptop)
+	}
+
+	if err := svc.RemoveDevice(ctx, user.UserID, laptop.ID); err != nil {
+		t.Fatalf("remove device: %v", err)
+	}
+	if err := svc.RemoveDevice(ctx, user.UserID, laptop.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("remove again = %v, want ErrNotFound", err)
+	}
+	if data := login("fp-laptop", "Firefox/131"); data["new_device"] != true {
+		t.Fatalf("removed device: %v", data)
+	}
+}
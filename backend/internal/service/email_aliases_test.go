@@ -0,0 +1,42 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestRegisterRefusesEmailAliases(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	svc := service.NewAuthService(store.Auth(), store.Transactor(), service.NewAuditService(store.Audit(), nil), "pepper", time.Hour, "pmv2")
+
+	// Registered before the option was on: no canonical address stored.
+	if _, err := svc.Register(ctx, "ada@gmail.com", "Correct-Horse-9", ""); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	svc.UseEmailAliasNormalization()
+	if _, err := svc.Register(ctx, "A.da+promo@gmail.com", "Correct-Horse-9", ""); !errors.Is(err, domain.ErrEmailTaken) {
+		t.Fatalf("alias of an older account: err = %v", err)
+	}
+
+	out, err := svc.Register(ctx, "b.o.b+news@googlemail.com", "Correct-Horse-9", "")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	// The address given is the one kept for delivery.
+	if out.Email != "b.o.b+news@googlemail.com" {
+		t.Fatalf("email = %q", out.Email)
+	}
+	if _, err := svc.Register(ctx, "bob@gmail.com", "Correct-Horse-9", ""); !errors.Is(err, domain.ErrEmailTaken) {
+		t.Fatalf("alias of a newer account: err = %v", err)
+	}
+	if _, err := svc.Register(ctx, "bob+1@example.com", "Correct-Horse-9", ""); err != nil {
+		t.Fatalf("unknown provider: %v", err)
+	}
+}
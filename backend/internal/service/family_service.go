@@ -104,7 +104,14 @@ func (s *FamilyService) RejectRequest(ctx context.Context, currentUserID, otherU
 		_ = s.sharingService.RevokeAllSharesBetweenUsers(ctx, currentUserID, otherUserID)
 	}
 
-	return s.familyRepo.DeleteMembership(ctx, currentUserID, otherUserID)
+	err := s.familyRepo.DeleteMembership(ctx, currentUserID, otherUserID)
+	if err == nil {
+		uid, _ := uuid.Parse(currentUserID)
+		s.audit.LogEvent(ctx, &uid, domain.EventTypeFamilyInviteRejected, map[string]interface{}{
+			"friend_id": otherUserID,
+		})
+	}
+	return err
 }
 
 // RemoveMember removes an accepted family member.
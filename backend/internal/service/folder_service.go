@@ -3,19 +3,31 @@ package service
 import (
 	"context"
 
+	"github.com/google/uuid"
+
 	"pmv2/backend/internal/domain"
 )
 
 type FolderService struct {
-	repo domain.FolderRepository
+	repo  domain.FolderRepository
+	audit *AuditService
 }
 
-func NewFolderService(repo domain.FolderRepository) *FolderService {
-	return &FolderService{repo: repo}
+func NewFolderService(repo domain.FolderRepository, audit *AuditService) *FolderService {
+	return &FolderService{repo: repo, audit: audit}
 }
 
 func (s *FolderService) CreateFolder(ctx context.Context, input domain.CreateVaultFolderInput) (domain.VaultFolder, error) {
-	return s.repo.CreateFolder(ctx, input)
+	folder, err := s.repo.CreateFolder(ctx, input)
+	if err != nil {
+		return domain.VaultFolder{}, err
+	}
+
+	uid, _ := uuid.Parse(input.OwnerUserID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeVaultFolderCreated, map[string]string{
+		"folder_id": folder.ID,
+	})
+	return folder, nil
 }
 
 func (s *FolderService) ListFolders(ctx context.Context, ownerUserID string) ([]domain.VaultFolder, error) {
@@ -27,7 +39,16 @@ func (s *FolderService) GetFolder(ctx context.Context, ownerUserID string, folde
 }
 
 func (s *FolderService) UpdateFolder(ctx context.Context, ownerUserID string, folderID string, nameCiphertext []byte, nonce []byte) (domain.VaultFolder, error) {
-	return s.repo.UpdateFolderForOwner(ctx, folderID, ownerUserID, nameCiphertext, nonce)
+	folder, err := s.repo.UpdateFolderForOwner(ctx, folderID, ownerUserID, nameCiphertext, nonce)
+	if err != nil {
+		return domain.VaultFolder{}, err
+	}
+
+	uid, _ := uuid.Parse(ownerUserID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeVaultFolderUpdated, map[string]string{
+		"folder_id": folderID,
+	})
+	return folder, nil
 }
 
 func (s *FolderService) DeleteFolder(ctx context.Context, ownerUserID string, folderID string) error {
@@ -38,5 +59,10 @@ func (s *FolderService) DeleteFolder(ctx context.Context, ownerUserID string, fo
 	if !deleted {
 		return domain.ErrNotFound
 	}
+
+	uid, _ := uuid.Parse(ownerUserID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeVaultFolderDeleted, map[string]string{
+		"folder_id": folderID,
+	})
 	return nil
 }
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/util"
+)
+
+// IPBanPolicy decides when an address is banned and for how long.
+type IPBanPolicy struct {
+	// Threshold strikes within Window earn a ban.
+	Threshold int
+	Window    time.Duration
+	// The first ban lasts BaseDuration; each further offense doubles it, up
+	// to MaxDuration.
+	BaseDuration time.Duration
+	MaxDuration  time.Duration
+}
+
+// duration is how long the ban for an address's offenses-th offense lasts.
+func (p IPBanPolicy) duration(offenses int) time.Duration {
+	d := p.BaseDuration
+	for i := 1; i < offenses && d < p.MaxDuration; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxDuration)
+}
+
+// IPBanService bans client addresses that keep failing to sign in or
+// keep getting rate limited. Strikes are counted per process; bans are
+// stored, and every replica enforces them from a copy it reloads with
+// SyncBans, so checking a request never touches storage.
+type IPBanService struct {
+	repo   domain.IPBanRepository
+	tx     domain.Transactor
+	audit  *AuditService
+	policy IPBanPolicy
+	now    func() time.Time
+
+	mu      sync.Mutex
+	strikes map[string][]time.Time
+
+	banned atomic.Pointer[map[string]time.Time] // ip -> banned until
+}
+
+func NewIPBanService(repo domain.IPBanRepository, tx domain.Transactor, audit *AuditService, policy IPBanPolicy) *IPBanService {
+	s := &IPBanService{
+		repo:    repo,
+		tx:      tx,
+		audit:   audit,
+		policy:  policy,
+		now:     time.Now,
+		strikes: make(map[string][]time.Time),
+	}
+	s.banned.Store(&map[string]time.Time{})
+	return s
+}
+
+// BannedUntil reports whether ip is banned, and until when, as of the last
+// reload. It is called on every request and never touches storage.
+func (s *IPBanService) BannedUntil(ip string) (time.Time, bool) {
+	until, ok := (*s.banned.Load())[ip]
+	if !ok || !s.now().Before(until) {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// Strike counts one offense by ip, such as a failed sign-in or a rate
+// limited request, and bans the address once it reaches the policy's
+// threshold within its window.
+func (s *IPBanService) Strike(ctx context.Context, ip, reason string) error {
+	if ip == "" {
+		return nil
+	}
+	if _, banned := s.BannedUntil(ip); banned {
+		return nil
+	}
+
+	now := s.now()
+	s.mu.Lock()
+	recent := s.strikes[ip][:0]
+	for _, at := range s.strikes[ip] {
+		if now.Sub(at) < s.policy.Window {
+			recent = append(recent, at)
+		}
+	}
+	recent = append(recent, now)
+	if len(recent) < s.policy.Threshold {
+		s.strikes[ip] = recent
+		s.mu.Unlock()
+		return nil
+	}
+	delete(s.strikes, ip)
+	s.mu.Unlock()
+
+	_, err := s.ban(ctx, ip, reason)
+	return err
+}
+
+// ban stores the next, longer ban for ip and starts enforcing it here.
+func (s *IPBanService) ban(ctx context.Context, ip, reason string) (domain.IPBan, error) {
+	var ban domain.IPBan
+	err := withinTx(ctx, s.tx, func(ctx context.Context) error {
+		previous, err := s.repo.GetIPBan(ctx, ip)
+		if err != nil && !errors.Is(err, domain.ErrNotFound) {
+			return err
+		}
+		offenses := previous.Offenses + 1
+		ban, err = s.repo.SaveIPBan(ctx, domain.IPBan{
+			IP:          ip,
+			Offenses:    offenses,
+			Reason:      reason,
+			BannedUntil: s.now().UTC().Add(s.policy.duration(offenses)),
+		})
+		if err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, nil, domain.EventTypeSystemIPBanned, map[string]any{
+			"ip":           ip,
+			"reason":       reason,
+			"offenses":     offenses,
+			"banned_until": ban.BannedUntil.Format(time.RFC3339),
+		})
+	})
+	if err != nil {
+		return domain.IPBan{}, fmt.Errorf("ban ip: %w", err)
+	}
+
+	s.update(func(banned map[string]time.Time) { banned[ip] = ban.BannedUntil })
+	return ban, nil
+}
+
+// HandleAuditEvent is an AuditSubscriber that counts failed sign-ins as
+// strikes against the client address.
+func (s *IPBanService) HandleAuditEvent(ctx context.Context, event domain.AuditEvent) error {
+	if event.EventType != domain.EventTypeAuthLoginFailed {
+		return nil
+	}
+	return s.Strike(ctx, event.IPAddress, "login_failed")
+}
+
+// ListBans returns the bans in force, read from storage.
+func (s *IPBanService) ListBans(ctx context.Context) ([]domain.IPBan, error) {
+	bans, err := s.repo.ListActiveIPBans(ctx, s.now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("list ip bans: %w", err)
+	}
+	return bans, nil
+}
+
+// LiftBan ends a ban and forgets the address's earlier offenses. Other
+// replicas stop enforcing it at their next reload.
+func (s *IPBanService) LiftBan(ctx context.Context, adminID, ip string) error {
+	ip = util.NormalizeIP(ip)
+	if ip == "" {
+		return domain.ErrInvalidIP
+	}
+
+	if err := s.repo.DeleteIPBan(ctx, ip); err != nil {
+		return err
+	}
+	s.update(func(banned map[string]time.Time) { delete(banned, ip) })
+	s.mu.Lock()
+	delete(s.strikes, ip)
+	s.mu.Unlock()
+
+	aid, _ := uuid.Parse(adminID)
+	s.audit.LogEvent(ctx, &aid, domain.EventTypeAdminIPBanLifted, map[string]any{"ip": ip})
+	return nil
+}
+
+// PurgeExpired forgets addresses whose last ban ended more than forgetAfter
+// ago, so their next ban starts from BaseDuration again. It also drops
+// strike counts that have fallen out of the window.
+func (s *IPBanService) PurgeExpired(ctx context.Context, forgetAfter time.Duration) (int64, error) {
+	now := s.now()
+	s.mu.Lock()
+	for ip, strikes := range s.strikes {
+		if now.Sub(strikes[len(strikes)-1]) >= s.policy.Window {
+			delete(s.strikes, ip)
+		}
+	}
+	s.mu.Unlock()
+
+	return s.repo.DeleteIPBansEndedBefore(ctx, now.UTC().Add(-forgetAfter))
+}
+
+// LoadBans replaces the bans enforced here with the ones stored, so bans
+// issued or lifted by another replica take effect.
+func (s *IPBanService) LoadBans(ctx context.Context) error {
+	bans, err := s.ListBans(ctx)
+	if err != nil {
+		return err
+	}
+	banned := make(map[string]time.Time, len(bans))
+	for _, b := range bans {
+		banned[b.IP] = b.BannedUntil
+	}
+	s.mu.Lock()
+	s.banned.Store(&banned)
+	s.mu.Unlock()
+	return nil
+}
+
+// SyncBans calls LoadBans every interval until ctx is cancelled, passing
+// failures to onError and keeping the last known bans.
+func (s *IPBanService) SyncBans(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := s.LoadBans(ctx); err != nil && ctx.Err() == nil {
+			onError(err)
+		}
+	}
+}
+
+// update applies fn to a copy of the enforced bans and swaps it in.
+func (s *IPBanService) update(fn func(banned map[string]time.Time)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	banned := make(map[string]time.Time)
+	for ip, until := range *s.banned.Load() {
+		banned[ip] = until
+	}
+	fn(banned)
+	s.banned.Store(&banned)
+}
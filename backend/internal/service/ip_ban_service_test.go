@@ -0,0 +1,96 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestIPBanEscalatesAndCanBeLifted(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	policy := service.IPBanPolicy{Threshold: 3, Window: time.Minute, BaseDuration: time.Hour, MaxDuration: 3 * time.Hour}
+	svc := service.NewIPBanService(store.IPBans(), store.Transactor(), audit, policy)
+	const ip = "198.51.100.4"
+
+	strike := func(n int) {
+		t.Helper()
+		for range n {
+			if err := svc.Strike(ctx, ip, "login_failed"); err != nil {
+				t.Fatalf("Strike: %v", err)
+			}
+		}
+	}
+
+	strike(2)
+	if _, banned := svc.BannedUntil(ip); banned {
+		t.Fatal("banned below the threshold")
+	}
+	strike(1)
+	until, banned := svc.BannedUntil(ip)
+	if !banned || time.Until(until) < 59*time.Minute || time.Until(until) > time.Hour {
+		t.Fatalf("first ban until %v (banned %v), want about an hour", until, banned)
+	}
+	if _, banned := svc.BannedUntil("198.51.100.5"); banned {
+		t.Fatal("another address is banned")
+	}
+
+	// Only the stored ban is shared; a fresh service (another replica)
+	// enforces it once it reloads.
+	other := service.NewIPBanService(store.IPBans(), store.Transactor(), audit, policy)
+	if err := other.LoadBans(ctx); err != nil {
+		t.Fatalf("LoadBans: %v", err)
+	}
+	if _, banned := other.BannedUntil(ip); !banned {
+		t.Fatal("ban not enforced after reload")
+	}
+
+	// Repeat offenses double the ban, capped at MaxDuration.
+	for offense, want := range []time.Duration{2 * time.Hour, 3 * time.Hour} {
+		if _, err := store.IPBans().SaveIPBan(ctx, domain.IPBan{IP: ip, Offenses: offense + 1, Reason: "login_failed", BannedUntil: time.Now().Add(-time.Second)}); err != nil {
+			t.Fatalf("end ban: %v", err)
+		}
+		fresh := service.NewIPBanService(store.IPBans(), store.Transactor(), audit, policy)
+		for range 3 {
+			if err := fresh.Strike(ctx, ip, "rate_limited"); err != nil {
+				t.Fatalf("Strike: %v", err)
+			}
+		}
+		ban, err := store.IPBans().GetIPBan(ctx, ip)
+		if err != nil {
+			t.Fatalf("GetIPBan: %v", err)
+		}
+		if ban.Offenses != offense+2 || time.Until(ban.BannedUntil) < want-time.Minute || time.Until(ban.BannedUntil) > want {
+			t.Fatalf("offense %d: ban = %+v, want about %s", ban.Offenses, ban, want)
+		}
+	}
+
+	_, events, err := store.Audit().ListEvents(ctx, 10, 0, domain.AuditFilter{EventTypes: []domain.EventType{domain.EventTypeSystemIPBanned}})
+	if err != nil || events != 3 {
+		t.Fatalf("ban events = %d, %v; want 3", events, err)
+	}
+
+	if err := svc.LiftBan(ctx, uuid.NewString(), "not-an-ip"); !errors.Is(err, domain.ErrInvalidIP) {
+		t.Fatalf("LiftBan(not-an-ip) err = %v, want ErrInvalidIP", err)
+	}
+	if err := svc.LiftBan(ctx, uuid.NewString(), ip); err != nil {
+		t.Fatalf("LiftBan: %v", err)
+	}
+	if _, banned := svc.BannedUntil(ip); banned {
+		t.Fatal("still banned after LiftBan")
+	}
+	if bans, err := svc.ListBans(ctx); err != nil || len(bans) != 0 {
+		t.Fatalf("ListBans = %+v, %v; want none", bans, err)
+	}
+	if err := svc.LiftBan(ctx, uuid.NewString(), ip); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("LiftBan(again) err = %v, want ErrNotFound", err)
+	}
+}
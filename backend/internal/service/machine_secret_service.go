@@ -0,0 +1,302 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/util"
+)
+
+const (
+	// maxMachineKeysPerUser bounds the API keys one account hands out.
+	maxMachineKeysPerUser = 20
+	maxMachineKeyScopes   = 20
+	maxMachineKeyName     = 128
+	// machineKeyPrefix marks API keys so secret scanners can find leaked
+	// ones and the API can tell them from session tokens.
+	machineKeyPrefix = "pmk_"
+)
+
+// MachineSecretService publishes vault items for CI pipelines and serves
+// them to service accounts holding a scoped API key. Every read is recorded
+// in the owner's audit log.
+type MachineSecretService struct {
+	repo   domain.MachineSecretRepository
+	vault  domain.VaultRepository
+	audit  *AuditService
+	pepper string
+	now    func() time.Time
+}
+
+// NewMachineSecretService builds the service. API keys are stored hashed
+// with pepper, as session tokens are.
+func NewMachineSecretService(repo domain.MachineSecretRepository, vault domain.VaultRepository, audit *AuditService, pepper string) *MachineSecretService {
+	return &MachineSecretService{repo: repo, vault: vault, audit: audit, pepper: pepper, now: time.Now}
+}
+
+// PublishSecret publishes the item under name, with the copy of its value
+// the client encrypted for the pipelines. Publishing an item again replaces
+// its name and copy.
+func (s *MachineSecretService) PublishSecret(ctx context.Context, userID, itemID, name string, ciphertext, nonce []byte, algoVersion string) (domain.MachineSecret, error) {
+	name = strings.TrimSpace(name)
+	if !validMachineSecretName(name) {
+		return domain.MachineSecret{}, domain.ErrInvalidMachineSecretName
+	}
+	if len(ciphertext) == 0 || len(nonce) == 0 || strings.TrimSpace(algoVersion) == "" {
+		return domain.MachineSecret{}, domain.ErrInvalidVaultPayload
+	}
+	item, err := s.liveItem(ctx, userID, strings.TrimSpace(itemID))
+	if err != nil {
+		return domain.MachineSecret{}, err
+	}
+
+	secret, err := s.repo.UpsertMachineSecret(ctx, domain.MachineSecret{
+		OwnerUserID: userID,
+		ItemID:      item.ID,
+		Name:        name,
+		Ciphertext:  ciphertext,
+		Nonce:       nonce,
+		AlgoVersion: strings.TrimSpace(algoVersion),
+	})
+	if err != nil {
+		if errors.Is(err, domain.ErrMachineSecretNameTaken) {
+			return domain.MachineSecret{}, domain.ErrMachineSecretNameTaken
+		}
+		return domain.MachineSecret{}, fmt.Errorf("publish machine secret: %w", err)
+	}
+
+	uid, _ := uuid.Parse(userID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeMachineSecretPublished, map[string]any{
+		"item_id": secret.ItemID,
+		"name":    secret.Name,
+	})
+	return secret, nil
+}
+
+// UnpublishSecret stops serving the item to pipelines.
+func (s *MachineSecretService) UnpublishSecret(ctx context.Context, userID, itemID string) error {
+	itemID = strings.TrimSpace(itemID)
+	if err := s.repo.DeleteMachineSecret(ctx, userID, itemID); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.ErrNotFound
+		}
+		return fmt.Errorf("unpublish machine secret: %w", err)
+	}
+
+	uid, _ := uuid.Parse(userID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeMachineSecretUnpublished, map[string]any{
+		"item_id": itemID,
+	})
+	return nil
+}
+
+func (s *MachineSecretService) ListSecrets(ctx context.Context, userID string) ([]domain.MachineSecret, error) {
+	secrets, err := s.repo.ListMachineSecrets(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list machine secrets: %w", err)
+	}
+	return secrets, nil
+}
+
+// CreateKey hands out an API key that reads the secrets its scopes match,
+// until ttl passes (zero or less for never). The returned key is the only
+// time the plaintext is shown to the user.
+func (s *MachineSecretService) CreateKey(ctx context.Context, userID, name string, scopes []string, ttl time.Duration) (domain.MachineKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxMachineKeyName {
+		return domain.MachineKey{}, domain.ErrInvalidMachineKeyName
+	}
+	if len(scopes) == 0 || len(scopes) > maxMachineKeyScopes {
+		return domain.MachineKey{}, domain.ErrInvalidMachineKeyScope
+	}
+	cleaned := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if !validMachineKeyScope(scope) {
+			return domain.MachineKey{}, domain.ErrInvalidMachineKeyScope
+		}
+		cleaned = append(cleaned, scope)
+	}
+
+	count, err := s.repo.CountMachineKeys(ctx, userID)
+	if err != nil {
+		return domain.MachineKey{}, fmt.Errorf("count machine keys: %w", err)
+	}
+	if count >= maxMachineKeysPerUser {
+		return domain.MachineKey{}, domain.ErrMachineKeyLimitReached
+	}
+
+	token, err := util.NewOpaqueToken(32)
+	if err != nil {
+		return domain.MachineKey{}, err
+	}
+	plaintext := machineKeyPrefix + token
+	key := domain.MachineKey{
+		ID:          uuid.NewString(),
+		OwnerUserID: userID,
+		Name:        name,
+		KeyHash:     util.HashToken(plaintext, s.pepper),
+		Prefix:      plaintext[:len(machineKeyPrefix)+8],
+		Scopes:      cleaned,
+	}
+	if ttl > 0 {
+		expiresAt := s.now().UTC().Add(ttl)
+		key.ExpiresAt = &expiresAt
+	}
+	key, err = s.repo.CreateMachineKey(ctx, key)
+	if err != nil {
+		return domain.MachineKey{}, fmt.Errorf("create machine key: %w", err)
+	}
+	key.Key = plaintext
+
+	uid, _ := uuid.Parse(userID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeMachineKeyCreated, map[string]any{
+		"key_id": key.ID,
+		"name":   key.Name,
+		"scopes": key.Scopes,
+	})
+	return key, nil
+}
+
+func (s *MachineSecretService) ListKeys(ctx context.Context, userID string) ([]domain.MachineKey, error) {
+	keys, err := s.repo.ListMachineKeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list machine keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (s *MachineSecretService) DeleteKey(ctx context.Context, userID, keyID string) error {
+	keyID = strings.TrimSpace(keyID)
+	if _, err := uuid.Parse(keyID); err != nil {
+		return domain.ErrNotFound
+	}
+	if err := s.repo.DeleteMachineKey(ctx, userID, keyID); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.ErrNotFound
+		}
+		return fmt.Errorf("delete machine key: %w", err)
+	}
+
+	uid, _ := uuid.Parse(userID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeMachineKeyDeleted, map[string]any{
+		"key_id": keyID,
+	})
+	return nil
+}
+
+// ReadSecret serves the secret published under name to the holder of the
+// API key rawKey. The scope is checked before the secret is looked up, so a
+// key cannot probe for names outside it; refusals of a valid key are logged
+// like reads. Items in the trash are not served.
+func (s *MachineSecretService) ReadSecret(ctx context.Context, rawKey, name string) (domain.MachineSecret, error) {
+	rawKey = strings.TrimSpace(rawKey)
+	if !strings.HasPrefix(rawKey, machineKeyPrefix) {
+		return domain.MachineSecret{}, domain.ErrInvalidMachineKey
+	}
+	key, err := s.repo.GetMachineKeyByHash(ctx, util.HashToken(rawKey, s.pepper))
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.MachineSecret{}, domain.ErrInvalidMachineKey
+	}
+	if err != nil {
+		return domain.MachineSecret{}, fmt.Errorf("get machine key: %w", err)
+	}
+	now := s.now()
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return domain.MachineSecret{}, domain.ErrInvalidMachineKey
+	}
+
+	uid, _ := uuid.Parse(key.OwnerUserID)
+	deny := func(reason string, err error) (domain.MachineSecret, error) {
+		s.audit.LogEvent(ctx, &uid, domain.EventTypeMachineSecretReadDenied, map[string]any{
+			"key_id": key.ID,
+			"name":   name,
+			"reason": reason,
+		})
+		return domain.MachineSecret{}, err
+	}
+
+	name = strings.TrimSpace(name)
+	if !validMachineSecretName(name) || !machineKeyAllows(key, name) {
+		return deny("out_of_scope", domain.ErrMachineSecretOutOfScope)
+	}
+	secret, err := s.repo.GetMachineSecretByName(ctx, key.OwnerUserID, name)
+	if errors.Is(err, domain.ErrNotFound) {
+		return deny("not_found", domain.ErrNotFound)
+	}
+	if err != nil {
+		return domain.MachineSecret{}, fmt.Errorf("get machine secret: %w", err)
+	}
+	if _, err := s.liveItem(ctx, key.OwnerUserID, secret.ItemID); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return deny("item_deleted", domain.ErrNotFound)
+		}
+		return domain.MachineSecret{}, err
+	}
+
+	if err := s.repo.TouchMachineKey(ctx, key.ID, now); err != nil {
+		return domain.MachineSecret{}, fmt.Errorf("touch machine key: %w", err)
+	}
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeMachineSecretRead, map[string]any{
+		"key_id":  key.ID,
+		"name":    secret.Name,
+		"item_id": secret.ItemID,
+	})
+	return secret, nil
+}
+
+// liveItem returns the user's item unless it is missing or in the trash.
+func (s *MachineSecretService) liveItem(ctx context.Context, userID, itemID string) (domain.VaultItem, error) {
+	if _, err := uuid.Parse(itemID); err != nil {
+		return domain.VaultItem{}, domain.ErrNotFound
+	}
+	item, err := s.vault.GetVaultItemByIDForOwner(ctx, itemID, userID)
+	if errors.Is(err, domain.ErrNotFound) || (err == nil && item.DeletedAt != nil) {
+		return domain.VaultItem{}, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.VaultItem{}, fmt.Errorf("get vault item: %w", err)
+	}
+	return item, nil
+}
+
+// machineKeyAllows reports whether one of the key's scopes matches name.
+func machineKeyAllows(key domain.MachineKey, name string) bool {
+	for _, scope := range key.Scopes {
+		if prefix, ok := strings.CutSuffix(scope, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if scope == name {
+			return true
+		}
+	}
+	return false
+}
+
+func validMachineSecretName(name string) bool {
+	if name == "" || len(name) > domain.MaxMachineSecretNameLength {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '.' && r != '_' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// validMachineKeyScope accepts a secret name, or a prefix of one followed
+// by '*'; "*" alone matches every secret.
+func validMachineKeyScope(scope string) bool {
+	if prefix, ok := strings.CutSuffix(scope, "*"); ok {
+		return prefix == "" || validMachineSecretName(prefix)
+	}
+	return validMachineSecretName(scope)
+}
@@ -0,0 +1,111 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestMachineSecrets(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	auth := service.NewAuthService(store.Auth(), store.Transactor(), nil, "pepper", time.Hour, "pmv2")
+	vault := service.NewVaultService(store.Vault(), store.Folders(), store.Transactor(), nil)
+	svc := service.NewMachineSecretService(store.MachineSecrets(), store.Vault(), audit, "pepper")
+
+	user, err := auth.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	newItem := func() domain.VaultItem {
+		t.Helper()
+		item, err := vault.CreateItem(ctx, user.UserID, domain.CreateVaultItemInput{
+			Ciphertext: []byte("c"), Nonce: []byte("n"), WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"),
+			AlgoVersion: "xchacha20poly1305-v1",
+		})
+		if err != nil {
+			t.Fatalf("CreateItem: %v", err)
+		}
+		return item
+	}
+	publish := func(itemID, name string) (domain.MachineSecret, error) {
+		return svc.PublishSecret(ctx, user.UserID, itemID, name, []byte("pipeline-c"), []byte("pipeline-n"), "xchacha20poly1305-v1")
+	}
+	deployItem, dbItem := newItem(), newItem()
+
+	if _, err := publish(deployItem.ID, "deploy/token"); !errors.Is(err, domain.ErrInvalidMachineSecretName) {
+		t.Fatalf("PublishSecret(bad name) err = %v, want ErrInvalidMachineSecretName", err)
+	}
+	if _, err := publish(deployItem.ID, "prod.deploy_token"); err != nil {
+		t.Fatalf("PublishSecret: %v", err)
+	}
+	if _, err := publish(dbItem.ID, "prod.deploy_token"); !errors.Is(err, domain.ErrMachineSecretNameTaken) {
+		t.Fatalf("PublishSecret(taken name) err = %v, want ErrMachineSecretNameTaken", err)
+	}
+	if _, err := publish(dbItem.ID, "staging.db_password"); err != nil {
+		t.Fatalf("PublishSecret: %v", err)
+	}
+
+	if _, err := svc.CreateKey(ctx, user.UserID, "ci", []string{"prod/*"}, 0); !errors.Is(err, domain.ErrInvalidMachineKeyScope) {
+		t.Fatalf("CreateKey(bad scope) err = %v, want ErrInvalidMachineKeyScope", err)
+	}
+	key, err := svc.CreateKey(ctx, user.UserID, "ci", []string{"prod.*"}, 0)
+	if err != nil || key.Key == "" || key.ExpiresAt != nil {
+		t.Fatalf("CreateKey = %+v, %v; want a key that never expires", key, err)
+	}
+
+	secret, err := svc.ReadSecret(ctx, key.Key, "prod.deploy_token")
+	if err != nil || string(secret.Ciphertext) != "pipeline-c" {
+		t.Fatalf("ReadSecret = %+v, %v; want the published copy", secret, err)
+	}
+	if _, err := svc.ReadSecret(ctx, key.Key, "staging.db_password"); !errors.Is(err, domain.ErrMachineSecretOutOfScope) {
+		t.Fatalf("ReadSecret(out of scope) err = %v, want ErrMachineSecretOutOfScope", err)
+	}
+	if _, err := svc.ReadSecret(ctx, key.Key+"x", "prod.deploy_token"); !errors.Is(err, domain.ErrInvalidMachineKey) {
+		t.Fatalf("ReadSecret(wrong key) err = %v, want ErrInvalidMachineKey", err)
+	}
+	keys, _ := svc.ListKeys(ctx, user.UserID)
+	if len(keys) != 1 || keys[0].LastUsedAt == nil || keys[0].Key != "" {
+		t.Fatalf("ListKeys = %+v, want the key marked used and no plaintext", keys)
+	}
+
+	expired, err := svc.CreateKey(ctx, user.UserID, "old", []string{"*"}, time.Nanosecond)
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, err := svc.ReadSecret(ctx, expired.Key, "prod.deploy_token"); !errors.Is(err, domain.ErrInvalidMachineKey) {
+		t.Fatalf("ReadSecret(expired key) err = %v, want ErrInvalidMachineKey", err)
+	}
+
+	// An item in the trash is not served.
+	if err := vault.DeleteItem(ctx, user.UserID, deployItem.ID); err != nil {
+		t.Fatalf("DeleteItem: %v", err)
+	}
+	if _, err := svc.ReadSecret(ctx, key.Key, "prod.deploy_token"); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("ReadSecret(trashed item) err = %v, want ErrNotFound", err)
+	}
+
+	for eventType, want := range map[domain.EventType]int{
+		domain.EventTypeMachineSecretRead:       1,
+		domain.EventTypeMachineSecretReadDenied: 2,
+	} {
+		_, total, err := store.Audit().ListEvents(ctx, 10, 0, domain.AuditFilter{EventTypes: []domain.EventType{eventType}})
+		if err != nil || total != want {
+			t.Fatalf("%s events = %d, %v; want %d", eventType, total, err, want)
+		}
+	}
+
+	if err := svc.DeleteKey(ctx, user.UserID, key.ID); err != nil {
+		t.Fatalf("DeleteKey: %v", err)
+	}
+	if _, err := svc.ReadSecret(ctx, key.Key, "prod.deploy_token"); !errors.Is(err, domain.ErrInvalidMachineKey) {
+		t.Fatalf("ReadSecret(deleted key) err = %v, want ErrInvalidMachineKey", err)
+	}
+}
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/mailer"
+)
+
+// Emailer queues a templated email; *mailer.Mailer implements it.
+type Emailer interface {
+	Send(ctx context.Context, to string, tmpl mailer.Template, data any) error
+}
+
+type NotificationService struct {
+	repo   domain.NotificationRepository
+	audit  *AuditService
+	emails Emailer
+
+	revokeLinks    *SessionRevokeLinks
+	frontendOrigin string
+}
+
+// NewNotificationService builds the service. emails may be nil, in which
+// case no email notifications are sent.
+func NewNotificationService(repo domain.NotificationRepository, audit *AuditService, emails Emailer) *NotificationService {
+	return &NotificationService{repo: repo, audit: audit, emails: emails}
+}
+
+// UseSessionRevokeLinks adds a link that signs the account out everywhere
+// to login failure alerts. It opens frontendOrigin's /revoke-sessions page,
+// which hands the token to POST /auth/revoke-sessions.
+func (s *NotificationService) UseSessionRevokeLinks(links *SessionRevokeLinks, frontendOrigin string) {
+	s.revokeLinks = links
+	s.frontendOrigin = strings.TrimRight(frontendOrigin, "/")
+}
+
+// GetPreferences returns the user's preferences with defaults filled in for
+// any category they have not configured.
+func (s *NotificationService) GetPreferences(ctx context.Context, userID string) (domain.NotificationPreferences, error) {
+	prefs := domain.DefaultNotificationPreferences(userID)
+
+	stored, err := s.repo.GetPreferences(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return prefs, nil
+	}
+	if err != nil {
+		return domain.NotificationPreferences{}, fmt.Errorf("get notification preferences: %w", err)
+	}
+
+	for _, category := range domain.NotificationCategories {
+		if pref, ok := stored.Categories[category]; ok {
+			prefs.Categories[category] = pref
+		}
+	}
+	prefs.UpdatedAt = stored.UpdatedAt
+	return prefs, nil
+}
+
+// UpdatePreferences applies patch on top of the current preferences.
+func (s *NotificationService) UpdatePreferences(ctx context.Context, userID string, patch map[domain.NotificationCategory]domain.NotificationPreferencePatch) (domain.NotificationPreferences, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return domain.NotificationPreferences{}, fmt.Errorf("invalid user id: %w", err)
+	}
+
+	prefs, err := s.GetPreferences(ctx, userID)
+	if err != nil {
+		return domain.NotificationPreferences{}, err
+	}
+
+	for category, change := range patch {
+		pref, ok := prefs.Categories[category]
+		if !ok {
+			return domain.NotificationPreferences{}, domain.ErrInvalidNotificationPreference.WithDetail(fmt.Sprintf("unknown category %q", category))
+		}
+		if change.Enabled != nil {
+			pref.Enabled = *change.Enabled
+		}
+		if change.Channels != nil {
+			channels := make([]domain.NotificationChannel, 0, len(change.Channels))
+			for _, channel := range change.Channels {
+				if !slices.Contains(domain.NotificationChannels, channel) {
+					return domain.NotificationPreferences{}, domain.ErrInvalidNotificationPreference.WithDetail(fmt.Sprintf("unknown channel %q", channel))
+				}
+				if !slices.Contains(channels, channel) {
+					channels = append(channels, channel)
+				}
+			}
+			pref.Channels = channels
+		}
+		prefs.Categories[category] = pref
+	}
+
+	saved, err := s.repo.SavePreferences(ctx, prefs)
+	if err != nil {
+		return domain.NotificationPreferences{}, fmt.Errorf("update notification preferences: %w", err)
+	}
+
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeNotificationPreferencesUpdated, map[string]interface{}{
+		"categories": saved.Categories,
+	})
+	return saved, nil
+}
+
+// Allows reports whether a category notification may be sent to the user on
+// channel. Every email and webhook sender consults it. If preferences cannot
+// be loaded the defaults apply.
+func (s *NotificationService) Allows(ctx context.Context, userID string, category domain.NotificationCategory, channel domain.NotificationChannel) bool {
+	prefs, err := s.GetPreferences(ctx, userID)
+	if err != nil {
+		slog.WarnContext(ctx, "notification preferences unavailable, using defaults", slog.String("user_id", userID), slog.Any("error", err))
+		prefs = domain.DefaultNotificationPreferences(userID)
+	}
+	return prefs.Allows(category, channel)
+}
+
+// HandleAuditEvent emails the user about events that double as
+// notifications, when their preferences allow the email channel. It is
+// registered as an audit subscriber.
+func (s *NotificationService) HandleAuditEvent(ctx context.Context, event domain.AuditEvent) error {
+	if s.emails == nil || event.UserID == nil {
+		return nil
+	}
+	if event.EventType == domain.EventTypeSecuritySessionTokenReused {
+		return s.sendTokenReusedAlert(ctx, event)
+	}
+	if event.EventType == domain.EventTypeSecurityLoginFailuresExceeded {
+		return s.sendLoginFailuresAlert(ctx, event)
+	}
+	category, ok := domain.NotificationCategoryForEvent(event.EventType)
+	if !ok {
+		return nil
+	}
+	if category == domain.NotificationNewDeviceAlerts && knownDevice(event) {
+		return nil
+	}
+	var access shareAccess
+	if category == domain.NotificationShareAccess {
+		if err := json.Unmarshal(event.EventData, &access); err != nil || !access.Email {
+			return nil
+		}
+	}
+	userID := event.UserID.String()
+	if !s.Allows(ctx, userID, category, domain.NotificationChannelEmail) {
+		return nil
+	}
+
+	recipient, err := s.repo.GetRecipient(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch category {
+	case domain.NotificationNewDeviceAlerts:
+		err = s.emails.Send(ctx, recipient.Email, mailer.TemplateSecurityAlert, mailer.SecurityAlertData{
+			Name:      recipient.Name,
+			Title:     "email.security_alert.new_sign_in.title",
+			Detail:    "email.security_alert.new_sign_in.detail",
+			IPAddress: event.IPAddress,
+			UserAgent: event.UserAgent,
+			Time:      event.CreatedAt.UTC().Format(time.RFC1123),
+		})
+	case domain.NotificationShareAccess:
+		friend, ferr := s.repo.GetRecipient(ctx, access.FriendID)
+		if errors.Is(ferr, domain.ErrNotFound) {
+			return nil
+		}
+		if ferr != nil {
+			return ferr
+		}
+		name := friend.Name
+		if name == "" {
+			name = friend.Email
+		}
+		err = s.emails.Send(ctx, recipient.Email, mailer.TemplateShareAccessed, mailer.ShareAccessedData{
+			Name:          recipient.Name,
+			RecipientName: name,
+			Time:          event.CreatedAt.UTC().Format(time.RFC1123),
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("send %s email: %w", category, err)
+	}
+	return nil
+}
+
+// sendTokenReusedAlert tells the user a session of theirs was revoked
+// because a retired token came back. It is not a notification category:
+// preferences cannot turn it off.
+func (s *NotificationService) sendTokenReusedAlert(ctx context.Context, event domain.AuditEvent) error {
+	recipient, err := s.repo.GetRecipient(ctx, event.UserID.String())
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = s.emails.Send(ctx, recipient.Email, mailer.TemplateSecurityAlert, mailer.SecurityAlertData{
+		Name:      recipient.Name,
+		Title:     "email.security_alert.token_reused.title",
+		Detail:    "email.security_alert.token_reused.detail",
+		IPAddress: event.IPAddress,
+		UserAgent: event.UserAgent,
+		Time:      event.CreatedAt.UTC().Format(time.RFC1123),
+	})
+	if err != nil {
+		return fmt.Errorf("send token reuse email: %w", err)
+	}
+	return nil
+}
+
+// loginFailuresListed caps how many failed attempts a login failure alert
+// lists.
+const loginFailuresListed = 10
+
+// sendLoginFailuresAlert tells the user their account hit the MFA lockout
+// or the failed password alert threshold, listing the latest failed
+// attempts. Like the token reuse alert, preferences cannot turn it off.
+func (s *NotificationService) sendLoginFailuresAlert(ctx context.Context, event domain.AuditEvent) error {
+	var data domain.LoginFailuresExceeded
+	if err := json.Unmarshal(event.EventData, &data); err != nil {
+		return fmt.Errorf("decode login failures event: %w", err)
+	}
+	userID := event.UserID.String()
+	recipient, err := s.repo.GetRecipient(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	email := mailer.LoginFailuresData{
+		Name:     recipient.Name,
+		Detail:   "email.login_failures." + data.Reason,
+		Attempts: data.FailedAttempts,
+	}
+	if data.LockedUntil != nil {
+		email.LockedUntil = data.LockedUntil.UTC().Format(time.RFC1123)
+	}
+	if s.audit != nil {
+		until := event.CreatedAt
+		page, err := s.audit.GetActivityLog(ctx, userID, loginFailuresListed, 0, domain.AuditFilter{
+			EventTypes: []domain.EventType{domain.EventTypeAuthLoginFailed},
+			StartDate:  &data.Since,
+			EndDate:    &until,
+		})
+		if err != nil {
+			return fmt.Errorf("list failed sign-ins: %w", err)
+		}
+		for _, attempt := range page.Events {
+			email.Recent = append(email.Recent, mailer.LoginAttempt{
+				Time:      attempt.CreatedAt.UTC().Format(time.RFC1123),
+				IPAddress: attempt.IPAddress,
+				UserAgent: attempt.UserAgent,
+			})
+		}
+	}
+	if s.revokeLinks != nil && s.frontendOrigin != "" {
+		email.RevokeLink = s.frontendOrigin + "/revoke-sessions?token=" + url.QueryEscape(s.revokeLinks.New(userID))
+		email.ExpiresIn = fmt.Sprintf("%d hours", int(s.revokeLinks.TTL().Round(time.Hour)/time.Hour))
+	}
+
+	if err := s.emails.Send(ctx, recipient.Email, mailer.TemplateLoginFailures, email); err != nil {
+		return fmt.Errorf("send login failures email: %w", err)
+	}
+	return nil
+}
+
+// shareAccess is the data of a sharing_item_accessed event. Email is the
+// item's access alert setting at the time.
+type shareAccess struct {
+	FriendID string `json:"friend_id"`
+	Email    bool   `json:"email"`
+}
+
+// knownDevice reports whether a sign-in event says it came from a device the
+// user had signed in from before. Events recorded without device tracking
+// say nothing, and count as new.
+func knownDevice(event domain.AuditEvent) bool {
+	var data struct {
+		NewDevice *bool `json:"new_device"`
+	}
+	if err := json.Unmarshal(event.EventData, &data); err != nil {
+		return false
+	}
+	return data.NewDevice != nil && !*data.NewDevice
+}
@@ -0,0 +1,215 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/mailer"
+	"pmv2/backend/internal/service"
+)
+
+type mockNotificationRepo struct {
+	domain.NotificationRepository
+	stored    *domain.NotificationPreferences
+	saved     []domain.NotificationPreferences
+	recipient domain.NotificationRecipient
+}
+
+func (m *mockNotificationRepo) GetPreferences(ctx context.Context, userID string) (domain.NotificationPreferences, error) {
+	if m.stored == nil {
+		return domain.NotificationPreferences{}, domain.ErrNotFound
+	}
+	return *m.stored, nil
+}
+
+func (m *mockNotificationRepo) SavePreferences(ctx context.Context, prefs domain.NotificationPreferences) (domain.NotificationPreferences, error) {
+	m.saved = append(m.saved, prefs)
+	return prefs, nil
+}
+
+func (m *mockNotificationRepo) GetRecipient(ctx context.Context, userID string) (domain.NotificationRecipient, error) {
+	if m.recipient.Email == "" {
+		return domain.NotificationRecipient{}, domain.ErrNotFound
+	}
+	return m.recipient, nil
+}
+
+type sentEmail struct {
+	to   string
+	tmpl mailer.Template
+	data any
+}
+
+type captureEmailer struct{ sent []sentEmail }
+
+func (e *captureEmailer) Send(ctx context.Context, to string, tmpl mailer.Template, data any) error {
+	e.sent = append(e.sent, sentEmail{to: to, tmpl: tmpl, data: data})
+	return nil
+}
+
+const notificationTestUser = "22222222-2222-2222-2222-222222222222"
+
+func TestGetPreferencesMergesDefaults(t *testing.T) {
+	repo := &mockNotificationRepo{}
+	svc := service.NewNotificationService(repo, nil, nil)
+
+	prefs, err := svc.GetPreferences(context.Background(), notificationTestUser)
+	if err != nil {
+		t.Fatalf("GetPreferences: %v", err)
+	}
+	if !prefs.Allows(domain.NotificationNewDeviceAlerts, domain.NotificationChannelEmail) {
+		t.Fatal("new device alerts should be emailed by default")
+	}
+
+	updatedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	repo.stored = &domain.NotificationPreferences{
+		UserID: notificationTestUser,
+		Categories: map[domain.NotificationCategory]domain.CategoryPreference{
+			domain.NotificationNewDeviceAlerts: {Enabled: true, Channels: []domain.NotificationChannel{domain.NotificationChannelWebhook}},
+			"retired_category":                 {Enabled: true},
+		},
+		UpdatedAt: updatedAt,
+	}
+	prefs, err = svc.GetPreferences(context.Background(), notificationTestUser)
+	if err != nil {
+		t.Fatalf("GetPreferences: %v", err)
+	}
+	if prefs.Allows(domain.NotificationNewDeviceAlerts, domain.NotificationChannelEmail) {
+		t.Fatal("the stored channel list should replace the default")
+	}
+	if _, ok := prefs.Categories["retired_category"]; ok {
+		t.Fatal("unknown stored categories should be dropped")
+	}
+	if !prefs.UpdatedAt.Equal(updatedAt) {
+		t.Fatalf("UpdatedAt = %s, want %s", prefs.UpdatedAt, updatedAt)
+	}
+}
+
+func TestUpdatePreferencesValidates(t *testing.T) {
+	disabled := false
+	cases := []struct {
+		name  string
+		user  string
+		patch map[domain.NotificationCategory]domain.NotificationPreferencePatch
+	}{
+		{"unknown category", notificationTestUser, map[domain.NotificationCategory]domain.NotificationPreferencePatch{
+			"weekly_newsletter": {Enabled: &disabled},
+		}},
+		{"unknown channel", notificationTestUser, map[domain.NotificationCategory]domain.NotificationPreferencePatch{
+			domain.NotificationNewDeviceAlerts: {Channels: []domain.NotificationChannel{"sms"}},
+		}},
+		{"invalid user id", "not-a-uuid", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &mockNotificationRepo{}
+			svc := service.NewNotificationService(repo, nil, nil)
+			if _, err := svc.UpdatePreferences(context.Background(), tc.user, tc.patch); err == nil {
+				t.Fatal("expected an error")
+			}
+			if len(repo.saved) != 0 {
+				t.Fatal("invalid preferences were saved")
+			}
+		})
+	}
+
+	repo := &mockNotificationRepo{}
+	svc := service.NewNotificationService(repo, nil, nil)
+	_, err := svc.UpdatePreferences(context.Background(), notificationTestUser, map[domain.NotificationCategory]domain.NotificationPreferencePatch{
+		"weekly_newsletter": {Enabled: &disabled},
+	})
+	if !errors.Is(err, domain.ErrInvalidNotificationPreference) {
+		t.Fatalf("err = %v, want ErrInvalidNotificationPreference", err)
+	}
+}
+
+func TestUpdatePreferencesAppliesPatch(t *testing.T) {
+	repo := &mockNotificationRepo{}
+	svc := service.NewNotificationService(repo, nil, nil)
+
+	email := domain.NotificationChannelEmail
+	saved, err := svc.UpdatePreferences(context.Background(), notificationTestUser, map[domain.NotificationCategory]domain.NotificationPreferencePatch{
+		domain.NotificationNewDeviceAlerts: {Channels: []domain.NotificationChannel{email, email}},
+	})
+	if err != nil {
+		t.Fatalf("UpdatePreferences: %v", err)
+	}
+	pref := saved.Categories[domain.NotificationNewDeviceAlerts]
+	if !pref.Enabled {
+		t.Fatal("a channel-only patch must leave Enabled unchanged")
+	}
+	if !slices.Equal(pref.Channels, []domain.NotificationChannel{email}) {
+		t.Fatalf("channels = %v, want [email] with duplicates removed", pref.Channels)
+	}
+	if len(repo.saved) != 1 {
+		t.Fatalf("saved %d times, want 1", len(repo.saved))
+	}
+}
+
+func TestHandleAuditEventEmailsNewDeviceAlerts(t *testing.T) {
+	uid := uuid.MustParse(notificationTestUser)
+	login := domain.AuditEvent{UserID: &uid, EventType: domain.EventTypeAuthLoginSuccess, IPAddress: "203.0.113.7", CreatedAt: time.Now()}
+
+	repo := &mockNotificationRepo{recipient: domain.NotificationRecipient{Email: "user@example.com", Name: "Ada"}}
+	emails := &captureEmailer{}
+	svc := service.NewNotificationService(repo, nil, emails)
+
+	if err := svc.HandleAuditEvent(context.Background(), login); err != nil {
+		t.Fatalf("HandleAuditEvent: %v", err)
+	}
+	if len(emails.sent) != 1 || emails.sent[0].to != "user@example.com" || emails.sent[0].tmpl != mailer.TemplateSecurityAlert {
+		t.Fatalf("sent = %+v", emails.sent)
+	}
+	if data, ok := emails.sent[0].data.(mailer.SecurityAlertData); !ok || data.IPAddress != "203.0.113.7" || data.Name != "Ada" {
+		t.Fatalf("data = %+v", emails.sent[0].data)
+	}
+
+	other := login
+	other.EventType = domain.EventTypeAuthLogout
+	if err := svc.HandleAuditEvent(context.Background(), other); err != nil {
+		t.Fatalf("HandleAuditEvent: %v", err)
+	}
+	if len(emails.sent) != 1 {
+		t.Fatal("events without a notification category must not send email")
+	}
+
+	known := login
+	known.EventData = []byte(`{"device_id":"d","new_device":false}`)
+	if err := svc.HandleAuditEvent(context.Background(), known); err != nil {
+		t.Fatalf("HandleAuditEvent: %v", err)
+	}
+	if len(emails.sent) != 1 {
+		t.Fatal("a sign-in from a known device must not send a new device alert")
+	}
+
+	repo.stored = &domain.NotificationPreferences{
+		UserID: notificationTestUser,
+		Categories: map[domain.NotificationCategory]domain.CategoryPreference{
+			domain.NotificationNewDeviceAlerts: {Enabled: true, Channels: []domain.NotificationChannel{domain.NotificationChannelWebhook}},
+		},
+	}
+	if err := svc.HandleAuditEvent(context.Background(), login); err != nil {
+		t.Fatalf("HandleAuditEvent: %v", err)
+	}
+	if len(emails.sent) != 1 {
+		t.Fatal("an opted-out email channel must not send email")
+	}
+
+	// Token reuse is not a category: opting out of emails does not silence it.
+	reused := domain.AuditEvent{UserID: &uid, EventType: domain.EventTypeSecuritySessionTokenReused, CreatedAt: time.Now()}
+	if err := svc.HandleAuditEvent(context.Background(), reused); err != nil {
+		t.Fatalf("HandleAuditEvent: %v", err)
+	}
+	if len(emails.sent) != 2 {
+		t.Fatal("token reuse must always be emailed")
+	}
+	if data := emails.sent[1].data.(mailer.SecurityAlertData); data.Title != "email.security_alert.token_reused.title" {
+		t.Fatalf("data = %+v", data)
+	}
+}
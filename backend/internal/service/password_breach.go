@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"pmv2/backend/internal/domain"
+)
+
+// BreachChecker tells whether a password has appeared in a known data
+// breach.
+type BreachChecker interface {
+	Breached(ctx context.Context, password string) (bool, error)
+}
+
+// UseBreachCheck has new master passwords, at registration and on every
+// change, rejected with ErrBreachedPassword when checker knows them.
+func (s *AuthService) UseBreachCheck(checker BreachChecker) {
+	s.breaches = checker
+}
+
+// checkBreachedPassword rejects a known breached password. A check that
+// cannot answer lets the password through: an outage of the breach source
+// should not stop people from signing up or changing their password.
+func (s *AuthService) checkBreachedPassword(ctx context.Context, password string) error {
+	if s.breaches == nil {
+		return nil
+	}
+	breached, err := s.breaches.Breached(ctx, password)
+	if err != nil {
+		slog.WarnContext(ctx, "breached password check failed", slog.Any("error", err))
+		return nil
+	}
+	if breached {
+		return domain.ErrBreachedPassword
+	}
+	return nil
+}
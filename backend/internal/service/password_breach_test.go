@@ -0,0 +1,39 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+type breachList map[string]bool
+
+func (b breachList) Breached(_ context.Context, password string) (bool, error) {
+	if password == "Unknown-Answer-1" {
+		return false, errors.New("breach source unreachable")
+	}
+	return b[password], nil
+}
+
+func TestBreachedPasswordsRejected(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	svc := service.NewAuthService(store.Auth(), store.Transactor(), service.NewAuditService(store.Audit(), nil), "pepper", time.Hour, "pmv2")
+	svc.UseBreachCheck(breachList{"Tr0ub4dor&3x": true})
+
+	if _, err := svc.Register(ctx, "ada@example.com", "Tr0ub4dor&3x", "Ada"); !errors.Is(err, domain.ErrBreachedPassword) {
+		t.Fatalf("register with a breached password: err = %v", err)
+	}
+	// A check that cannot answer lets the password through.
+	if _, err := svc.Register(ctx, "ada@example.com", "Unknown-Answer-1", "Ada"); err != nil {
+		t.Fatalf("register while the check is down: %v", err)
+	}
+	if _, err := svc.Register(ctx, "bob@example.com", "Correct-Horse-9", "Bob"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/metrics"
+)
+
+// passwordHashGate bounds how many Argon2id hashes run at once. Each one
+// allocates the full memory cost (64 MiB by default), so a burst of sign-ins
+// could otherwise exhaust the process's memory. A nil gate does not limit.
+type passwordHashGate struct {
+	slots chan struct{}
+	wait  time.Duration
+}
+
+func newPasswordHashGate(concurrency int, wait time.Duration) *passwordHashGate {
+	return &passwordHashGate{slots: make(chan struct{}, concurrency), wait: wait}
+}
+
+// acquire waits up to the gate's queue timeout for a free slot, failing
+// with ErrServerBusy when none frees up. The caller must call release.
+func (g *passwordHashGate) acquire(ctx context.Context) (release func(), err error) {
+	if g == nil {
+		return func() {}, nil
+	}
+	select {
+	case g.slots <- struct{}{}:
+		return g.release, nil
+	default:
+	}
+
+	timer := time.NewTimer(g.wait)
+	defer timer.Stop()
+	select {
+	case g.slots <- struct{}{}:
+		return g.release, nil
+	case <-timer.C:
+		metrics.PasswordHashRejected.Inc()
+		return nil, domain.ErrServerBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (g *passwordHashGate) release() {
+	<-g.slots
+}
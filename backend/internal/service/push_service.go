@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/i18n"
+	"pmv2/backend/internal/push"
+)
+
+// PushService keeps the push tokens of users' mobile apps and notifies
+// them of sign-ins that need an authenticator code, sign-ins from new
+// devices and items shared with them. Notifications are queued on the
+// outbox, one per token, and delivered through provider.
+type PushService struct {
+	repo          domain.PushTokenRepository
+	outbox        domain.OutboxRepository
+	notifications *NotificationService
+	provider      push.Provider
+	tokenTTL      time.Duration
+	now           func() time.Time
+}
+
+// NewPushService builds the service. Tokens not registered again within
+// tokenTTL are dropped by PurgeStaleTokens.
+func NewPushService(repo domain.PushTokenRepository, outbox domain.OutboxRepository, notifications *NotificationService, provider push.Provider, tokenTTL time.Duration) *PushService {
+	return &PushService{repo: repo, outbox: outbox, notifications: notifications, provider: provider, tokenTTL: tokenTTL, now: time.Now}
+}
+
+// RegisterToken records the app's token for the session's user and device.
+// Apps call it on every start, which keeps the token from going stale.
+func (s *PushService) RegisterToken(ctx context.Context, session domain.Session, platform domain.PushPlatform, token string) (domain.PushToken, error) {
+	token = strings.TrimSpace(token)
+	if !slices.Contains(domain.PushPlatforms, platform) || token == "" || len(token) > domain.MaxPushTokenLength || strings.ContainsFunc(token, isControlOrSpace) {
+		return domain.PushToken{}, domain.ErrInvalidPushToken
+	}
+
+	registered, err := s.repo.UpsertPushToken(ctx, domain.PushToken{
+		UserID:   session.UserID,
+		DeviceID: session.DeviceID,
+		Platform: platform,
+		Token:    token,
+	})
+	if err != nil {
+		return domain.PushToken{}, fmt.Errorf("register push token: %w", err)
+	}
+	return registered, nil
+}
+
+func (s *PushService) ListTokens(ctx context.Context, userID string) ([]domain.PushToken, error) {
+	tokens, err := s.repo.ListPushTokens(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list push tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+func (s *PushService) RemoveToken(ctx context.Context, userID, tokenID string) error {
+	if err := s.repo.DeletePushToken(ctx, userID, strings.TrimSpace(tokenID)); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.ErrNotFound
+		}
+		return fmt.Errorf("remove push token: %w", err)
+	}
+	return nil
+}
+
+// HandleAuditEvent queues the pushes an event calls for. It is registered
+// as an audit subscriber. Step-up prompts are always sent; alerts, share
+// invitations and access requests follow the recipient's notification
+// preferences.
+func (s *PushService) HandleAuditEvent(ctx context.Context, event domain.AuditEvent) error {
+	if event.UserID == nil {
+		return nil
+	}
+	userID := event.UserID.String()
+
+	var data struct {
+		DeviceID       string   `json:"device_id"`
+		SessionID      string   `json:"session_id"`
+		StepUpRequired bool     `json:"step_up_required"`
+		ItemID         string   `json:"item_id"`
+		FriendID       string   `json:"friend_id"`
+		RequestID      string   `json:"request_id"`
+		InvitationID   string   `json:"invitation_id"`
+		ApproverIDs    []string `json:"approver_ids"`
+	}
+	_ = json.Unmarshal(event.EventData, &data)
+
+	// Any anomaly that put the session behind step-up asks the user's
+	// phone for an authenticator code.
+	if data.StepUpRequired {
+		return s.notify(ctx, userID, "", domain.PushKindMFAPrompt, map[string]string{"session_id": data.SessionID})
+	}
+
+	switch event.EventType {
+	case domain.EventTypeAuthLoginSuccess:
+		if knownDevice(event) || !s.notifications.Allows(ctx, userID, domain.NotificationNewDeviceAlerts, domain.NotificationChannelPush) {
+			return nil
+		}
+		// The new device knows it just signed in.
+		return s.notify(ctx, userID, data.DeviceID, domain.PushKindNewDevice, nil)
+	case domain.EventTypeSharingItemShared:
+		if data.FriendID == "" || !s.notifications.Allows(ctx, data.FriendID, domain.NotificationShareInvitations, domain.NotificationChannelPush) {
+			return nil
+		}
+		return s.notify(ctx, data.FriendID, "", domain.PushKindShareInvitation, map[string]string{"item_id": data.ItemID})
+	case domain.EventTypeSharingAccessRequested:
+		for _, approverID := range data.ApproverIDs {
+			if !s.notifications.Allows(ctx, approverID, domain.NotificationAccessRequests, domain.NotificationChannelPush) {
+				continue
+			}
+			err := s.notify(ctx, approverID, "", domain.PushKindAccessRequest, map[string]string{"item_id": data.ItemID, "request_id": data.RequestID})
+			if err != nil {
+				return err
+			}
+		}
+	case domain.EventTypeSharingInvitationReady:
+		if !s.notifications.Allows(ctx, userID, domain.NotificationShareInvitations, domain.NotificationChannelPush) {
+			return nil
+		}
+		return s.notify(ctx, userID, "", domain.PushKindShareInvitationReady, map[string]string{"item_id": data.ItemID, "invitation_id": data.InvitationID})
+	}
+	return nil
+}
+
+// notify queues kind for every token of the user except those of
+// skipDeviceID.
+func (s *PushService) notify(ctx context.Context, userID, skipDeviceID string, kind domain.PushKind, data map[string]string) error {
+	tokens, err := s.repo.ListPushTokens(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("list push tokens: %w", err)
+	}
+
+	l := i18n.FromContext(ctx)
+	for _, t := range tokens {
+		if skipDeviceID != "" && t.DeviceID == skipDeviceID {
+			continue
+		}
+		payload, err := json.Marshal(domain.PushMessage{
+			Platform: t.Platform,
+			Token:    t.Token,
+			Kind:     kind,
+			Title:    l.Message("push." + string(kind) + ".title"),
+			Body:     l.Message("push." + string(kind) + ".body"),
+			Data:     data,
+		})
+		if err != nil {
+			return fmt.Errorf("marshal push message: %w", err)
+		}
+		if err := s.outbox.Enqueue(ctx, domain.OutboxTopicPush, payload); err != nil {
+			return fmt.Errorf("enqueue push message: %w", err)
+		}
+	}
+	return nil
+}
+
+// HandleOutboxEvent delivers a queued push. A token the push service has
+// dropped is forgotten rather than retried.
+func (s *PushService) HandleOutboxEvent(ctx context.Context, event domain.OutboxEvent) error {
+	var msg domain.PushMessage
+	if err := json.Unmarshal(event.Payload, &msg); err != nil {
+		return fmt.Errorf("decode push message: %w", err)
+	}
+
+	err := s.provider.Send(ctx, msg)
+	switch {
+	case errors.Is(err, push.ErrUnregistered):
+		if err := s.repo.DeletePushTokenByValue(ctx, msg.Platform, msg.Token); err != nil {
+			return fmt.Errorf("forget unregistered push token: %w", err)
+		}
+		return nil
+	case errors.Is(err, push.ErrNoProvider):
+		slog.WarnContext(ctx, "push dropped, platform not configured", slog.String("platform", string(msg.Platform)))
+		return nil
+	}
+	return err
+}
+
+// PurgeStaleTokens drops tokens their apps have not registered again
+// within the token TTL.
+func (s *PushService) PurgeStaleTokens(ctx context.Context) (int64, error) {
+	deleted, err := s.repo.DeleteStalePushTokens(ctx, s.now().UTC().Add(-s.tokenTTL))
+	if err != nil {
+		return 0, fmt.Errorf("purge stale push tokens: %w", err)
+	}
+	return deleted, nil
+}
+
+func isControlOrSpace(r rune) bool {
+	return r <= ' ' || r == 0x7f
+}
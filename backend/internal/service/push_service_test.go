@@ -0,0 +1,149 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/push"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+type capturePushProvider struct {
+	sent []domain.PushMessage
+	err  error
+}
+
+func (p *capturePushProvider) Send(ctx context.Context, msg domain.PushMessage) error {
+	p.sent = append(p.sent, msg)
+	return p.err
+}
+
+func TestPushService(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	provider := &capturePushProvider{}
+	notifications := service.NewNotificationService(store.Notifications(), nil, nil)
+	svc := service.NewPushService(store.PushTokens(), store.Outbox(), notifications, provider, time.Hour)
+
+	userID := uuid.New()
+	phone := domain.Session{UserID: userID.String(), DeviceID: "phone"}
+	if _, err := svc.RegisterToken(ctx, phone, "pager", "tok"); !errors.Is(err, domain.ErrInvalidPushToken) {
+		t.Fatalf("unknown platform: err = %v", err)
+	}
+	if _, err := svc.RegisterToken(ctx, phone, domain.PushPlatformFCM, "has space"); !errors.Is(err, domain.ErrInvalidPushToken) {
+		t.Fatalf("token with whitespace: err = %v", err)
+	}
+	first, err := svc.RegisterToken(ctx, phone, domain.PushPlatformFCM, "fcm-token")
+	if err != nil {
+		t.Fatalf("RegisterToken: %v", err)
+	}
+	again, err := svc.RegisterToken(ctx, phone, domain.PushPlatformFCM, "fcm-token")
+	if err != nil || again.ID != first.ID {
+		t.Fatalf("registering again should refresh the token: %+v, %v", again, err)
+	}
+	if _, err := svc.RegisterToken(ctx, domain.Session{UserID: userID.String(), DeviceID: "tablet"}, domain.PushPlatformAPNs, "apns-token"); err != nil {
+		t.Fatalf("RegisterToken: %v", err)
+	}
+
+	queued := func() []domain.PushMessage {
+		t.Helper()
+		events, err := store.Outbox().ClaimDue(ctx, 100, time.Minute)
+		if err != nil {
+			t.Fatalf("ClaimDue: %v", err)
+		}
+		var msgs []domain.PushMessage
+		for _, event := range events {
+			if event.Topic != domain.OutboxTopicPush {
+				continue
+			}
+			if err := svc.HandleOutboxEvent(ctx, event); err != nil {
+				t.Fatalf("HandleOutboxEvent: %v", err)
+			}
+			var msg domain.PushMessage
+			_ = json.Unmarshal(event.Payload, &msg)
+			msgs = append(msgs, msg)
+		}
+		return msgs
+	}
+
+	login := domain.AuditEvent{
+		UserID:    &userID,
+		EventType: domain.EventTypeAuthLoginSuccess,
+		EventData: []byte(`{"device_id":"tablet","new_device":true}`),
+	}
+	if err := svc.HandleAuditEvent(ctx, login); err != nil {
+		t.Fatalf("HandleAuditEvent: %v", err)
+	}
+	msgs := queued()
+	if len(msgs) != 1 || msgs[0].Token != "fcm-token" || msgs[0].Kind != domain.PushKindNewDevice || msgs[0].Title == "" {
+		t.Fatalf("new device alert should go to the other devices only: %+v", msgs)
+	}
+
+	travel := domain.AuditEvent{
+		UserID:    &userID,
+		EventType: domain.EventTypeSecurityImpossibleTravel,
+		EventData: []byte(`{"session_id":"s1","step_up_required":true}`),
+	}
+	if err := svc.HandleAuditEvent(ctx, travel); err != nil {
+		t.Fatalf("HandleAuditEvent: %v", err)
+	}
+	if msgs := queued(); len(msgs) != 2 || msgs[0].Kind != domain.PushKindMFAPrompt || msgs[0].Data["session_id"] != "s1" {
+		t.Fatalf("step-up should prompt every device: %+v", msgs)
+	}
+
+	owner := uuid.New()
+	share := domain.AuditEvent{
+		UserID:    &owner,
+		EventType: domain.EventTypeSharingItemShared,
+		EventData: []byte(`{"item_id":"i1","friend_id":"` + userID.String() + `"}`),
+	}
+	if err := svc.HandleAuditEvent(ctx, share); err != nil {
+		t.Fatalf("HandleAuditEvent: %v", err)
+	}
+	if msgs := queued(); len(msgs) != 2 || msgs[0].Kind != domain.PushKindShareInvitation || msgs[0].Data["item_id"] != "i1" {
+		t.Fatalf("share invitation should reach the recipient: %+v", msgs)
+	}
+
+	off := false
+	if _, err := notifications.UpdatePreferences(ctx, userID.String(), map[domain.NotificationCategory]domain.NotificationPreferencePatch{
+		domain.NotificationShareInvitations: {Enabled: &off},
+	}); err != nil {
+		t.Fatalf("UpdatePreferences: %v", err)
+	}
+	if err := svc.HandleAuditEvent(ctx, share); err != nil {
+		t.Fatalf("HandleAuditEvent: %v", err)
+	}
+	if msgs := queued(); len(msgs) != 0 {
+		t.Fatalf("disabled share invitations were pushed: %+v", msgs)
+	}
+
+	provider.err = push.ErrUnregistered
+	if err := svc.HandleAuditEvent(ctx, travel); err != nil {
+		t.Fatalf("HandleAuditEvent: %v", err)
+	}
+	queued()
+	if tokens, _ := svc.ListTokens(ctx, userID.String()); len(tokens) != 0 {
+		t.Fatalf("unregistered tokens should be forgotten: %+v", tokens)
+	}
+
+	current, err := svc.RegisterToken(ctx, phone, domain.PushPlatformFCM, "fcm-token")
+	if err != nil {
+		t.Fatalf("RegisterToken: %v", err)
+	}
+	if deleted, err := svc.PurgeStaleTokens(ctx); err != nil || deleted != 0 {
+		t.Fatalf("fresh token purged: %d, %v", deleted, err)
+	}
+	if err := svc.RemoveToken(ctx, uuid.NewString(), current.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("removing another user's token: err = %v", err)
+	}
+	if err := svc.RemoveToken(ctx, userID.String(), current.ID); err != nil {
+		t.Fatalf("RemoveToken: %v", err)
+	}
+}
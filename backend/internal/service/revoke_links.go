@@ -0,0 +1,75 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/util"
+)
+
+// SessionRevokeLinks mints and checks the tokens behind the "sign out
+// everywhere" link of lockout alert emails. A token carries the user ID and
+// its expiry, signed with a key bound to the auth pepper, so nothing is
+// stored; using one again before it expires only signs the account out
+// again.
+type SessionRevokeLinks struct {
+	key []byte
+	ttl time.Duration
+	now func() time.Time
+}
+
+func NewSessionRevokeLinks(pepper string, ttl time.Duration) *SessionRevokeLinks {
+	return &SessionRevokeLinks{key: util.DeriveRevokeLinkKey(pepper), ttl: ttl, now: time.Now}
+}
+
+// TTL is how long a token stays valid.
+func (l *SessionRevokeLinks) TTL() time.Duration {
+	return l.ttl
+}
+
+// New returns a token for userID.
+func (l *SessionRevokeLinks) New(userID string) string {
+	payload := userID + "." + strconv.FormatInt(l.now().Add(l.ttl).Unix(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(l.sign(payload))
+}
+
+// Verify returns the user ID of a token that is intact and unexpired.
+func (l *SessionRevokeLinks) Verify(token string) (string, error) {
+	encoded, mac, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", domain.ErrInvalidRevokeLink
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", domain.ErrInvalidRevokeLink
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(mac)
+	if err != nil || !hmac.Equal(sig, l.sign(string(payload))) {
+		return "", domain.ErrInvalidRevokeLink
+	}
+	userID, expiry, ok := strings.Cut(string(payload), ".")
+	if !ok {
+		return "", domain.ErrInvalidRevokeLink
+	}
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || !l.now().Before(time.Unix(expiresAt, 0)) {
+		return "", domain.ErrInvalidRevokeLink
+	}
+	if _, err := uuid.Parse(userID); err != nil {
+		return "", domain.ErrInvalidRevokeLink
+	}
+	return userID, nil
+}
+
+func (l *SessionRevokeLinks) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, l.key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/util"
+)
+
+// A bound session may move within these prefixes, as a client does when its
+// provider hands it another address from the same pool.
+const (
+	bindIPv4PrefixBits = 24
+	bindIPv6PrefixBits = 48
+)
+
+// userAgentFamilies tells browsers apart by the product token that names
+// them. Order matters: most browsers also claim to be Safari, and Chromium
+// based ones to be Chrome.
+var userAgentFamilies = []struct{ token, family string }{
+	{"Edg/", "edge"},
+	{"EdgA/", "edge"},
+	{"EdgiOS/", "edge"},
+	{"OPR/", "opera"},
+	{"SamsungBrowser/", "samsung"},
+	{"Firefox/", "firefox"},
+	{"FxiOS/", "firefox"},
+	{"CriOS/", "chrome"},
+	{"Chrome/", "chrome"},
+	{"Chromium/", "chrome"},
+	{"Safari/", "safari"},
+}
+
+// GetSessionBinding returns the user's session binding setting.
+func (s *AuthService) GetSessionBinding(ctx context.Context, userID string) (domain.SessionBinding, error) {
+	binding, err := s.repo.GetSessionBinding(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.DefaultSessionBinding(), nil
+	}
+	if err != nil {
+		return domain.SessionBinding{}, fmt.Errorf("get session binding: %w", err)
+	}
+	return binding, nil
+}
+
+// UpdateSessionBinding changes the binding setting of the session's user.
+// The session itself is rebound to the client making the change, so turning
+// binding on never signs out the device doing it. Other sessions stay bound
+// to the client that signed them in.
+func (s *AuthService) UpdateSessionBinding(ctx context.Context, session domain.Session, binding domain.SessionBinding) (domain.SessionBinding, error) {
+	if !binding.Valid() {
+		return domain.SessionBinding{}, domain.ErrInvalidSessionBinding.WithDetail(fmt.Sprintf("unknown on_mismatch %q", binding.OnMismatch))
+	}
+	if binding.OnMismatch == domain.SessionBindingStepUp && !session.TOTPEnabled {
+		return domain.SessionBinding{}, domain.ErrInvalidSessionBinding.WithDetail("step_up requires totp to be enabled")
+	}
+
+	err := withinTx(ctx, s.tx, func(ctx context.Context) error {
+		if err := s.repo.SetSessionBinding(ctx, session.UserID, binding); err != nil {
+			return fmt.Errorf("set session binding: %w", err)
+		}
+		if binding.Enabled() {
+			meta := util.RequestMetaFromContext(ctx)
+			if err := s.repo.RebindSession(ctx, session.ID, meta.IPAddress, meta.UserAgent); err != nil {
+				return fmt.Errorf("rebind session: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.SessionBinding{}, err
+	}
+
+	uid, _ := uuid.Parse(session.UserID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeAuthSessionBindingUpdated, map[string]any{
+		"bind_ip_prefix":  binding.IPPrefix,
+		"bind_user_agent": binding.UserAgent,
+		"on_mismatch":     binding.OnMismatch,
+	})
+	return binding, nil
+}
+
+// enforceSessionBinding checks a bound session against the client of the
+// request in ctx. On a mismatch the session is flagged for step-up or
+// revoked, as the user chose; accounts without TOTP cannot step up, so
+// theirs is revoked either way. Work that is not a request carries no
+// client and is let through.
+func (s *AuthService) enforceSessionBinding(ctx context.Context, session domain.Session) (domain.Session, error) {
+	if !session.Binding.Enabled() {
+		return session, nil
+	}
+	meta := util.RequestMetaFromContext(ctx)
+	if meta.IPAddress == "" && meta.UserAgent == "" {
+		return session, nil
+	}
+	mismatch := bindingMismatch(session, meta)
+	if mismatch == "" {
+		return session, nil
+	}
+
+	uid, _ := uuid.Parse(session.UserID)
+	if session.Binding.OnMismatch == domain.SessionBindingStepUp && session.TOTPEnabled {
+		if session.StepUpRequired {
+			return session, nil
+		}
+		if err := s.repo.SetSessionStepUp(ctx, session.ID, true); err != nil {
+			return domain.Session{}, fmt.Errorf("flag moved session: %w", err)
+		}
+		session.StepUpRequired = true
+		s.audit.LogEvent(ctx, &uid, domain.EventTypeSecuritySessionMoved, map[string]any{
+			"session_id": session.ID,
+			"mismatch":   mismatch,
+			"action":     domain.SessionBindingStepUp,
+		})
+		return session, nil
+	}
+
+	revoked, err := s.repo.RevokeSessionByID(ctx, session.ID)
+	if err != nil {
+		return domain.Session{}, fmt.Errorf("revoke moved session: %w", err)
+	}
+	if revoked {
+		s.sessionEvents.SessionsRevoked(session.UserID)
+		s.audit.LogEvent(ctx, &uid, domain.EventTypeSecuritySessionMoved, map[string]any{
+			"session_id": session.ID,
+			"mismatch":   mismatch,
+			"action":     domain.SessionBindingRevoke,
+		})
+	}
+	return domain.Session{}, domain.ErrUnauthorizedSession
+}
+
+// bindingMismatch names the first bound property the request's client
+// differs in, or returns "" when it matches.
+func bindingMismatch(session domain.Session, meta util.RequestMeta) string {
+	if session.Binding.IPPrefix && ipPrefix(session.IPAddr) != ipPrefix(meta.IPAddress) {
+		return "ip_prefix"
+	}
+	if session.Binding.UserAgent && userAgentFamily(session.UserAgent) != userAgentFamily(meta.UserAgent) {
+		return "user_agent"
+	}
+	return ""
+}
+
+// ipPrefix returns the network a bound session may move within, or "" for
+// an address that does not parse.
+func ipPrefix(addr string) string {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return ""
+	}
+	ip = ip.Unmap()
+	bits := bindIPv6PrefixBits
+	if ip.Is4() {
+		bits = bindIPv4PrefixBits
+	}
+	prefix, err := ip.Prefix(bits)
+	if err != nil {
+		return ""
+	}
+	return prefix.String()
+}
+
+// userAgentFamily reduces a user agent to the browser it names, so version
+// upgrades do not break the binding. Other clients are known by their first
+// product token.
+func userAgentFamily(userAgent string) string {
+	for _, f := range userAgentFamilies {
+		if strings.Contains(userAgent, f.token) {
+			return f.family
+		}
+	}
+	product, _, _ := strings.Cut(strings.TrimSpace(userAgent), " ")
+	product, _, _ = strings.Cut(product, "/")
+	return strings.ToLower(product)
+}
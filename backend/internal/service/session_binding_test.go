@@ -0,0 +1,67 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+	"pmv2/backend/internal/util"
+)
+
+func TestSessionBindingRevokesMovedSession(t *testing.T) {
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	svc := service.NewAuthService(store.Auth(), store.Transactor(), audit, "pepper", time.Hour, "pmv2")
+	from := func(ip, userAgent string) context.Context {
+		return util.WithRequestMeta(context.Background(), util.RequestMeta{IPAddress: ip, UserAgent: userAgent})
+	}
+	const firefox = "Mozilla/5.0 (X11; Linux x86_64; rv:130.0) Gecko/20100101 Firefox/130.0"
+	home := from("203.0.113.5", firefox)
+
+	if _, err := svc.Register(home, "ada@example.com", "Correct-Horse-9", "Ada"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	login, err := svc.Login(home, domain.LoginInput{Email: "ada@example.com", Password: "Correct-Horse-9", IPAddr: "203.0.113.5", UserAgent: firefox})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	session, err := svc.Authenticate(home, login.SessionToken)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+
+	stepUp := domain.SessionBinding{IPPrefix: true, OnMismatch: domain.SessionBindingStepUp}
+	if _, err := svc.UpdateSessionBinding(home, session, stepUp); !errors.Is(err, domain.ErrInvalidSessionBinding) {
+		t.Fatalf("step_up without totp err = %v, want ErrInvalidSessionBinding", err)
+	}
+	binding := domain.SessionBinding{IPPrefix: true, UserAgent: true, OnMismatch: domain.SessionBindingRevoke}
+	if _, err := svc.UpdateSessionBinding(home, session, binding); err != nil {
+		t.Fatalf("update session binding: %v", err)
+	}
+	if got, err := svc.GetSessionBinding(home, session.UserID); err != nil || got != binding {
+		t.Fatalf("GetSessionBinding = %+v, %v; want %+v", got, err, binding)
+	}
+
+	// A new address in the same /24 and a browser upgrade are the same client.
+	upgraded := "Mozilla/5.0 (X11; Linux x86_64; rv:131.0) Gecko/20100101 Firefox/131.0"
+	if _, err := svc.Authenticate(from("203.0.113.77", upgraded), login.SessionToken); err != nil {
+		t.Fatalf("authenticate from the same network: %v", err)
+	}
+
+	// The token replayed from another machine kills the session for everyone.
+	if _, err := svc.Authenticate(from("198.51.100.9", firefox), login.SessionToken); !errors.Is(err, domain.ErrUnauthorizedSession) {
+		t.Fatalf("authenticate from elsewhere = %v, want ErrUnauthorizedSession", err)
+	}
+	if _, err := svc.Authenticate(home, login.SessionToken); !errors.Is(err, domain.ErrUnauthorizedSession) {
+		t.Fatalf("the moved session survived: %v", err)
+	}
+
+	events, _, err := store.Audit().ListEvents(context.Background(), 10, 0, domain.AuditFilter{EventTypes: []domain.EventType{domain.EventTypeSecuritySessionMoved}})
+	if err != nil || len(events) != 1 || events[0].IPAddress != "198.51.100.9" {
+		t.Fatalf("session moved events = %+v, %v; want one from the other machine", events, err)
+	}
+}
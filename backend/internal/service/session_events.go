@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+// SessionEvents tells the event streams open on this replica that one of
+// their user's sessions was revoked. Streams only learn that they should
+// look again; each checks whether its own session survived, so signing a
+// user out of one session leaves the others connected.
+//
+// It also remembers when each user last had a session revoked, or flagged
+// for step-up, for as long as an access token lasts, so the user's access
+// tokens issued before then are refused and their clients go back to the
+// session token, which is checked against the sessions row.
+type SessionEvents struct {
+	mu      sync.Mutex
+	streams map[string]map[chan struct{}]struct{}
+	revoked map[string]time.Time
+	now     func() time.Time
+}
+
+func NewSessionEvents() *SessionEvents {
+	return &SessionEvents{
+		streams: make(map[string]map[chan struct{}]struct{}),
+		revoked: make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+// watch registers a stream of userID. The channel receives a value after
+// any of the user's sessions is revoked; stop unregisters it.
+func (e *SessionEvents) watch(userID string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	e.mu.Lock()
+	if e.streams[userID] == nil {
+		e.streams[userID] = make(map[chan struct{}]struct{})
+	}
+	e.streams[userID][ch] = struct{}{}
+	e.mu.Unlock()
+
+	return ch, func() {
+		e.mu.Lock()
+		delete(e.streams[userID], ch)
+		if len(e.streams[userID]) == 0 {
+			delete(e.streams, userID)
+		}
+		e.mu.Unlock()
+	}
+}
+
+// SessionsRevoked wakes the streams of userID. It never blocks: a stream
+// that has not yet handled the previous wake-up will check anyway.
+func (e *SessionEvents) SessionsRevoked(userID string) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.now()
+	for id, at := range e.revoked {
+		if now.Sub(at) > MaxAccessTokenTTL {
+			delete(e.revoked, id)
+		}
+	}
+	e.revoked[userID] = now
+	for ch := range e.streams[userID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// revokedSince reports whether a session of userID was revoked on this
+// replica at or after t.
+func (e *SessionEvents) revokedSince(userID string, t time.Time) bool {
+	if e == nil {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	at, ok := e.revoked[userID]
+	return ok && !at.Before(t)
+}
+
+// UseSessionEvents has revocations pushed to the session's event stream
+// (GET /auth/events). Streams re-check their session every checkInterval
+// too, which covers revocations made on other replicas.
+func (s *AuthService) UseSessionEvents(events *SessionEvents, checkInterval time.Duration) {
+	s.sessionEvents = events
+	s.sessionCheckInterval = checkInterval
+}
+
+// WatchSession returns a channel that is closed once the session is
+// revoked or expires, or ctx ends. It follows the session rather than its
+// token, which a refresh may replace while the stream is open.
+func (s *AuthService) WatchSession(ctx context.Context, session domain.Session) <-chan struct{} {
+	ended := make(chan struct{})
+	wake, stop := make(<-chan struct{}), func() {}
+	if s.sessionEvents != nil {
+		wake, stop = s.sessionEvents.watch(session.UserID)
+	}
+	interval := s.sessionCheckInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	go func() {
+		defer close(ended)
+		defer stop()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-wake:
+			case <-ticker.C:
+			}
+			active, err := s.repo.SessionActive(ctx, session.ID)
+			if err == nil && !active {
+				return
+			}
+			// An error is a database hiccup; try again next time.
+		}
+	}()
+	return ended
+}
@@ -0,0 +1,60 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestWatchSessionEndsOnRevocation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	svc := service.NewAuthService(store.Auth(), store.Transactor(), audit, "pepper", time.Hour, "pmv2")
+	// A long check interval: only the revocation itself may wake the watch.
+	svc.UseSessionEvents(service.NewSessionEvents(), time.Hour)
+
+	if _, err := svc.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	watch := func() (string, <-chan struct{}) {
+		t.Helper()
+		out, err := svc.Login(ctx, domain.LoginInput{Email: "ada@example.com", Password: "Correct-Horse-9"})
+		if err != nil {
+			t.Fatalf("login: %v", err)
+		}
+		session, err := svc.Authenticate(ctx, out.SessionToken)
+		if err != nil {
+			t.Fatalf("authenticate: %v", err)
+		}
+		return out.SessionToken, svc.WatchSession(ctx, session)
+	}
+	laptopToken, laptop := watch()
+	_, phone := watch()
+
+	if err := svc.Logout(ctx, laptopToken); err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+	select {
+	case <-laptop:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the signed-out session's watch did not end")
+	}
+	select {
+	case <-phone:
+		t.Fatal("signing out one session ended the watch of another")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	select {
+	case <-phone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the watch outlived its context")
+	}
+}
@@ -0,0 +1,101 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/mailer"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestShareAccessAlerts(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	auth := service.NewAuthService(store.Auth(), store.Transactor(), nil, "pepper", time.Hour, "pmv2")
+	vault := service.NewVaultService(store.Vault(), store.Folders(), store.Transactor(), nil)
+	sharing := service.NewSharingService(store.Sharing(), store.UserKeys(), store.Vault(), store.Family(), audit)
+	family := service.NewFamilyService(store.Family(), store.Auth(), sharing, nil)
+	emails := &captureEmailer{}
+	notifications := service.NewNotificationService(store.Notifications(), audit, emails)
+	audit.Subscribe(notifications.HandleAuditEvent)
+
+	owner, err := auth.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	friend, err := auth.Register(ctx, "bob@example.com", "Correct-Horse-9", "Bob")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := family.SendRequest(ctx, owner.UserID, "bob@example.com"); err != nil {
+		t.Fatalf("SendRequest: %v", err)
+	}
+	if err := family.AcceptRequest(ctx, friend.UserID, owner.UserID); err != nil {
+		t.Fatalf("AcceptRequest: %v", err)
+	}
+
+	share := func() domain.VaultItem {
+		t.Helper()
+		item, err := vault.CreateItem(ctx, owner.UserID, domain.CreateVaultItemInput{
+			Ciphertext: []byte("c"), Nonce: []byte("n"), WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"),
+			AlgoVersion: "xchacha20poly1305-v1",
+		})
+		if err != nil {
+			t.Fatalf("CreateItem: %v", err)
+		}
+		err = sharing.ShareItem(ctx, owner.UserID, item.ID, domain.ShareItemInput{
+			RecipientID: friend.UserID, DEKWrapped: []byte("dek"), WrapNonce: []byte("wn"),
+		})
+		if err != nil {
+			t.Fatalf("ShareItem: %v", err)
+		}
+		return item
+	}
+	alerted, emailed, quiet := share(), share(), share()
+
+	if _, err := sharing.SetAccessAlert(ctx, friend.UserID, alerted.ID, false); !errors.Is(err, domain.ErrNotItemOwner) {
+		t.Fatalf("SetAccessAlert(recipient) err = %v, want ErrNotItemOwner", err)
+	}
+	if _, err := sharing.SetAccessAlert(ctx, owner.UserID, alerted.ID, false); err != nil {
+		t.Fatalf("SetAccessAlert: %v", err)
+	}
+	if alert, err := sharing.SetAccessAlert(ctx, owner.UserID, emailed.ID, true); err != nil || !alert.Email {
+		t.Fatalf("SetAccessAlert(email) = %+v, %v", alert, err)
+	}
+
+	for range 2 {
+		if items, err := sharing.ListSharedWithMe(ctx, friend.UserID); err != nil || len(items) != 3 {
+			t.Fatalf("ListSharedWithMe = %d items, %v; want 3", len(items), err)
+		}
+	}
+
+	ownerID := uuid.MustParse(owner.UserID)
+	_, total, err := store.Audit().ListEvents(ctx, 10, 0, domain.AuditFilter{UserID: &ownerID, EventTypes: []domain.EventType{domain.EventTypeSharingItemAccessed}})
+	if err != nil || total != 2 {
+		t.Fatalf("owner's %s events = %d, %v; want one per alerted item", domain.EventTypeSharingItemAccessed, total, err)
+	}
+	if len(emails.sent) != 1 || emails.sent[0].to != "ada@example.com" || emails.sent[0].tmpl != mailer.TemplateShareAccessed {
+		t.Fatalf("sent = %+v, want one access email to the owner", emails.sent)
+	}
+	if data := emails.sent[0].data.(mailer.ShareAccessedData); data.RecipientName != "Bob" {
+		t.Fatalf("email data = %+v", data)
+	}
+	shares, err := sharing.ListSharesForItem(ctx, owner.UserID, quiet.ID)
+	if err != nil || len(shares) != 1 || shares[0].FirstAccessedAt == nil {
+		t.Fatalf("ListSharesForItem = %+v, %v; want the access recorded without an alert", shares, err)
+	}
+
+	if err := sharing.DeleteAccessAlert(ctx, owner.UserID, emailed.ID); err != nil {
+		t.Fatalf("DeleteAccessAlert: %v", err)
+	}
+	if _, err := sharing.GetAccessAlert(ctx, owner.UserID, emailed.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("GetAccessAlert(deleted) err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/mailer"
+	"pmv2/backend/internal/util"
+)
+
+// ShareInvitationTTL is how long an invitee has to register before the
+// invitation lapses.
+const ShareInvitationTTL = 14 * 24 * time.Hour
+
+// ShareInvitationService shares items with people who have no account yet.
+// The invitee is emailed a registration link; once they have registered and
+// uploaded a public key the sharer is told the invitation is ready, and the
+// sharer's client wraps the item key for them to complete it.
+type ShareInvitationService struct {
+	shares         domain.SharingRepository
+	users          domain.AuthRepository
+	tx             domain.Transactor
+	audit          *AuditService
+	emails         Emailer
+	frontendOrigin string
+	now            func() time.Time
+
+	canonicalEmails bool
+}
+
+// NewShareInvitationService builds the service. Registration links point at
+// frontendOrigin. emails may be nil, in which case invitations are recorded
+// but nobody is emailed.
+func NewShareInvitationService(shares domain.SharingRepository, users domain.AuthRepository, tx domain.Transactor, audit *AuditService, emails Emailer, frontendOrigin string) *ShareInvitationService {
+	return &ShareInvitationService{
+		shares:         shares,
+		users:          users,
+		tx:             tx,
+		audit:          audit,
+		emails:         emails,
+		frontendOrigin: strings.TrimRight(frontendOrigin, "/"),
+		now:            time.Now,
+	}
+}
+
+// UseEmailAliasNormalization records invitations under the address with
+// provider aliases folded (util.CanonicalEmail), so inviting several aliases
+// of one mailbox to an item is one invitation, and whichever alias the
+// invitee registers with claims it. Known providers deliver the folded
+// address to the same mailbox.
+func (s *ShareInvitationService) UseEmailAliasNormalization() {
+	s.canonicalEmails = true
+}
+
+// Invite invites email to a share of the sharer's item. An email that
+// already has an account is refused; the item is shared with it directly.
+func (s *ShareInvitationService) Invite(ctx context.Context, userID, itemID, email, permissions string) (domain.ShareInvitation, error) {
+	if strings.TrimSpace(userID) == "" {
+		return domain.ShareInvitation{}, domain.ErrUnauthorizedSession
+	}
+	email = util.NormalizeEmail(email)
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return domain.ShareInvitation{}, domain.ErrInvalidInvitationEmail
+	}
+	switch permissions {
+	case "":
+		permissions = domain.SharePermissionRead
+	case domain.SharePermissionRead, domain.SharePermissionManage:
+	default:
+		return domain.ShareInvitation{}, domain.ErrInvalidVaultPayload.WithDetail("permissions must be read or manage")
+	}
+	itemID = strings.TrimSpace(itemID)
+	if _, err := uuid.Parse(itemID); err != nil {
+		return domain.ShareInvitation{}, domain.ErrNotItemOwner
+	}
+
+	ownerID, err := s.shares.GetItemOwner(ctx, itemID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return domain.ShareInvitation{}, fmt.Errorf("get item owner: %w", err)
+	}
+	if err != nil || ownerID != userID {
+		return domain.ShareInvitation{}, domain.ErrNotItemOwner
+	}
+	if _, err := s.users.GetUserAuthByEmail(ctx, email); err == nil {
+		return domain.ShareInvitation{}, domain.ErrRecipientRegistered
+	} else if !errors.Is(err, domain.ErrNotFound) {
+		return domain.ShareInvitation{}, fmt.Errorf("look up invitee: %w", err)
+	}
+	if s.canonicalEmails && util.CanonicalEmail(email) != email {
+		email = util.CanonicalEmail(email)
+		if _, err := s.users.GetUserAuthByEmail(ctx, email); err == nil {
+			return domain.ShareInvitation{}, domain.ErrRecipientRegistered
+		} else if !errors.Is(err, domain.ErrNotFound) {
+			return domain.ShareInvitation{}, fmt.Errorf("look up invitee: %w", err)
+		}
+	}
+	sharer, err := s.users.GetUserAuthByID(ctx, userID)
+	if err != nil {
+		return domain.ShareInvitation{}, fmt.Errorf("get sharer: %w", err)
+	}
+
+	var inv domain.ShareInvitation
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		inv, err = s.shares.CreateShareInvitation(ctx, domain.ShareInvitation{
+			ID:             uuid.NewString(),
+			ItemID:         itemID,
+			SharedByUserID: userID,
+			Email:          email,
+			Permissions:    permissions,
+			ExpiresAt:      s.now().UTC().Add(ShareInvitationTTL),
+		})
+		if err != nil {
+			return err
+		}
+		if s.emails == nil {
+			return nil
+		}
+		name := sharer.Name
+		if name == "" {
+			name = sharer.Email
+		}
+		return s.emails.Send(ctx, email, mailer.TemplateShareInvitation, mailer.ShareInvitationData{
+			SharerName: name,
+			Link:       s.frontendOrigin + "/register?email=" + url.QueryEscape(email),
+			ExpiresIn:  "14 days",
+		})
+	})
+	if err != nil {
+		if errors.Is(err, domain.ErrShareInvitationExists) {
+			return domain.ShareInvitation{}, err
+		}
+		return domain.ShareInvitation{}, fmt.Errorf("create share invitation: %w", err)
+	}
+
+	uid, _ := uuid.Parse(userID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeSharingInvitationSent, map[string]interface{}{
+		"invitation_id": inv.ID,
+		"item_id":       itemID,
+		"permissions":   permissions,
+	})
+	return inv, nil
+}
+
+// List returns the invitations the user sent that are still open, newest
+// first.
+func (s *ShareInvitationService) List(ctx context.Context, userID string) ([]domain.ShareInvitation, error) {
+	if strings.TrimSpace(userID) == "" {
+		return nil, domain.ErrUnauthorizedSession
+	}
+	invitations, err := s.shares.ListShareInvitationsBySharer(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list share invitations: %w", err)
+	}
+	return invitations, nil
+}
+
+// Cancel withdraws an invitation the user sent.
+func (s *ShareInvitationService) Cancel(ctx context.Context, userID, invitationID string) error {
+	if strings.TrimSpace(userID) == "" {
+		return domain.ErrUnauthorizedSession
+	}
+	invitationID = strings.TrimSpace(invitationID)
+	if _, err := uuid.Parse(invitationID); err != nil {
+		return domain.ErrShareInvitationNotFound
+	}
+	if err := s.shares.DeleteShareInvitation(ctx, invitationID, userID); err != nil {
+		if errors.Is(err, domain.ErrShareInvitationNotFound) {
+			return err
+		}
+		return fmt.Errorf("delete share invitation: %w", err)
+	}
+	uid, _ := uuid.Parse(userID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeSharingInvitationCancelled, map[string]interface{}{
+		"invitation_id": invitationID,
+	})
+	return nil
+}
+
+// Complete turns a ready invitation into a share with the key the sharer's
+// client wrapped for the invitee. The invitation stands in for the family
+// link ShareItem otherwise requires.
+func (s *ShareInvitationService) Complete(ctx context.Context, userID, invitationID string, dekWrapped, wrapNonce []byte, window domain.ShareWindow) (domain.ShareInvitation, error) {
+	if strings.TrimSpace(userID) == "" {
+		return domain.ShareInvitation{}, domain.ErrUnauthorizedSession
+	}
+	invitationID = strings.TrimSpace(invitationID)
+	if _, err := uuid.Parse(invitationID); err != nil {
+		return domain.ShareInvitation{}, domain.ErrShareInvitationNotFound
+	}
+	inv, err := s.shares.GetShareInvitation(ctx, invitationID)
+	if err != nil {
+		if errors.Is(err, domain.ErrShareInvitationNotFound) {
+			return domain.ShareInvitation{}, err
+		}
+		return domain.ShareInvitation{}, fmt.Errorf("get share invitation: %w", err)
+	}
+	if inv.SharedByUserID != userID {
+		return domain.ShareInvitation{}, domain.ErrShareInvitationNotFound
+	}
+	if inv.Status != domain.ShareInvitationReady {
+		return domain.ShareInvitation{}, domain.ErrShareInvitationNotReady
+	}
+	if len(dekWrapped) == 0 || len(wrapNonce) == 0 {
+		return domain.ShareInvitation{}, domain.ErrInvalidVaultPayload
+	}
+	window, err = normalizeShareWindow(window, s.now())
+	if err != nil {
+		return domain.ShareInvitation{}, err
+	}
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.shares.CreateShare(ctx, domain.ShareItemInput{
+			ItemID:         inv.ItemID,
+			RecipientID:    inv.RecipientUserID,
+			SharedByUserID: userID,
+			DEKWrapped:     dekWrapped,
+			WrapNonce:      wrapNonce,
+			Permissions:    inv.Permissions,
+			Window:         window,
+		}); err != nil {
+			return err
+		}
+		return s.shares.DeleteShareInvitation(ctx, inv.ID, userID)
+	})
+	if err != nil {
+		if errors.Is(err, domain.ErrAlreadyShared) || errors.Is(err, domain.ErrShareInvitationNotFound) {
+			return domain.ShareInvitation{}, err
+		}
+		return domain.ShareInvitation{}, fmt.Errorf("complete share invitation: %w", err)
+	}
+
+	uid, _ := uuid.Parse(userID)
+	share := map[string]interface{}{
+		"item_id":       inv.ItemID,
+		"friend_id":     inv.RecipientUserID,
+		"permissions":   inv.Permissions,
+		"invitation_id": inv.ID,
+	}
+	if window.ExpiresAt != nil {
+		share["expires_at"] = window.ExpiresAt.Format(time.RFC3339)
+	}
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeSharingItemShared, share)
+	return inv, nil
+}
+
+// HandleAuditEvent marks a user's pending invitations ready once they have
+// uploaded a public key to wrap item keys for. Register with
+// AuditService.Subscribe.
+func (s *ShareInvitationService) HandleAuditEvent(ctx context.Context, event domain.AuditEvent) error {
+	if event.EventType != domain.EventTypeSharingKeysUpdated || event.UserID == nil {
+		return nil
+	}
+	userID := event.UserID.String()
+	user, err := s.users.GetUserAuthByID(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get invitee: %w", err)
+	}
+
+	now := s.now().UTC()
+	invitations, err := s.shares.ListPendingShareInvitationsByEmail(ctx, util.NormalizeEmail(user.Email), now)
+	if err != nil {
+		return fmt.Errorf("list pending share invitations: %w", err)
+	}
+	if canonical := util.CanonicalEmail(user.Email); s.canonicalEmails && canonical != util.NormalizeEmail(user.Email) {
+		aliased, err := s.shares.ListPendingShareInvitationsByEmail(ctx, canonical, now)
+		if err != nil {
+			return fmt.Errorf("list pending share invitations: %w", err)
+		}
+		invitations = append(invitations, aliased...)
+	}
+	for _, inv := range invitations {
+		err := s.shares.MarkShareInvitationReady(ctx, inv.ID, userID, now)
+		if errors.Is(err, domain.ErrShareInvitationNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("mark share invitation ready: %w", err)
+		}
+		sharer, _ := uuid.Parse(inv.SharedByUserID)
+		s.audit.LogEvent(ctx, &sharer, domain.EventTypeSharingInvitationReady, map[string]interface{}{
+			"invitation_id": inv.ID,
+			"item_id":       inv.ItemID,
+			"friend_id":     userID,
+		})
+	}
+	return nil
+}
+
+// PurgeExpired deletes invitations nobody answered in time.
+func (s *ShareInvitationService) PurgeExpired(ctx context.Context) (int64, error) {
+	return s.shares.DeleteExpiredShareInvitations(ctx, s.now().UTC())
+}
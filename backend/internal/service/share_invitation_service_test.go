@@ -0,0 +1,117 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/mailer"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestShareInvitations(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	auth := service.NewAuthService(store.Auth(), store.Transactor(), nil, "pepper", time.Hour, "pmv2")
+	vault := service.NewVaultService(store.Vault(), store.Folders(), store.Transactor(), nil)
+	sharing := service.NewSharingService(store.Sharing(), store.UserKeys(), store.Vault(), store.Family(), audit)
+	emails := &captureEmailer{}
+	invitations := service.NewShareInvitationService(store.Sharing(), store.Auth(), store.Transactor(), audit, emails, "https://app.example/")
+	audit.Subscribe(invitations.HandleAuditEvent)
+
+	owner, err := auth.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	item, err := vault.CreateItem(ctx, owner.UserID, domain.CreateVaultItemInput{
+		Ciphertext: []byte("c"), Nonce: []byte("n"), WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"),
+		AlgoVersion: "xchacha20poly1305-v1",
+	})
+	if err != nil {
+		t.Fatalf("CreateItem: %v", err)
+	}
+
+	if _, err := invitations.Invite(ctx, owner.UserID, item.ID, "ada@example.com", ""); !errors.Is(err, domain.ErrRecipientRegistered) {
+		t.Fatalf("Invite(registered) err = %v, want ErrRecipientRegistered", err)
+	}
+	if _, err := invitations.Invite(ctx, owner.UserID, item.ID, "not an email", ""); !errors.Is(err, domain.ErrInvalidInvitationEmail) {
+		t.Fatalf("Invite(bad email) err = %v, want ErrInvalidInvitationEmail", err)
+	}
+	inv, err := invitations.Invite(ctx, owner.UserID, item.ID, " Bob@Example.com ", "")
+	if err != nil {
+		t.Fatalf("Invite: %v", err)
+	}
+	if inv.Email != "bob@example.com" || inv.Permissions != domain.SharePermissionRead || inv.Status != domain.ShareInvitationPending {
+		t.Fatalf("invitation = %+v", inv)
+	}
+	if _, err := invitations.Invite(ctx, owner.UserID, item.ID, "bob@example.com", ""); !errors.Is(err, domain.ErrShareInvitationExists) {
+		t.Fatalf("Invite(again) err = %v, want ErrShareInvitationExists", err)
+	}
+	if len(emails.sent) != 1 || emails.sent[0].to != "bob@example.com" || emails.sent[0].tmpl != mailer.TemplateShareInvitation {
+		t.Fatalf("sent = %+v, want one invitation email", emails.sent)
+	}
+	data := emails.sent[0].data.(mailer.ShareInvitationData)
+	if data.SharerName != "Ada" || data.Link != "https://app.example/register?email=bob%40example.com" {
+		t.Fatalf("email data = %+v", data)
+	}
+
+	// Registering alone is not enough: the sharer needs a public key to
+	// wrap the item key for.
+	bob, err := auth.Register(ctx, "bob@example.com", "Correct-Horse-9", "Bob")
+	if err != nil {
+		t.Fatalf("register invitee: %v", err)
+	}
+	if _, err := invitations.Complete(ctx, owner.UserID, inv.ID, []byte("dek"), []byte("wn"), domain.ShareWindow{}); !errors.Is(err, domain.ErrShareInvitationNotReady) {
+		t.Fatalf("Complete(pending) err = %v, want ErrShareInvitationNotReady", err)
+	}
+	err = sharing.UpsertUserKeys(ctx, bob.UserID, domain.UpsertUserKeysInput{
+		PublicKeyX25519: []byte("pub"), EncryptedPrivateKeys: []byte("priv"), Nonce: []byte("n"),
+	})
+	if err != nil {
+		t.Fatalf("UpsertUserKeys: %v", err)
+	}
+	listed, err := invitations.List(ctx, owner.UserID)
+	if err != nil || len(listed) != 1 || listed[0].Status != domain.ShareInvitationReady || listed[0].RecipientUserID != bob.UserID {
+		t.Fatalf("List = %+v, %v; want the invitation ready for the invitee", listed, err)
+	}
+	ownerID := uuid.MustParse(owner.UserID)
+	_, total, err := store.Audit().ListEvents(ctx, 10, 0, domain.AuditFilter{UserID: &ownerID, EventTypes: []domain.EventType{domain.EventTypeSharingInvitationReady}})
+	if err != nil || total != 1 {
+		t.Fatalf("sharer's %s events = %d, %v; want 1", domain.EventTypeSharingInvitationReady, total, err)
+	}
+
+	if _, err := invitations.Complete(ctx, bob.UserID, inv.ID, []byte("dek"), []byte("wn"), domain.ShareWindow{}); !errors.Is(err, domain.ErrShareInvitationNotFound) {
+		t.Fatalf("Complete(invitee) err = %v, want ErrShareInvitationNotFound", err)
+	}
+	if _, err := invitations.Complete(ctx, owner.UserID, inv.ID, []byte("dek-for-bob"), []byte("wn"), domain.ShareWindow{}); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	shared, err := sharing.ListSharedWithMe(ctx, bob.UserID)
+	if err != nil || len(shared) != 1 || string(shared[0].ShareDEK) != "dek-for-bob" {
+		t.Fatalf("ListSharedWithMe = %+v, %v; want the completed share", shared, err)
+	}
+	if listed, _ := invitations.List(ctx, owner.UserID); len(listed) != 0 {
+		t.Fatalf("List after complete = %+v, want none", listed)
+	}
+
+	// A cancelled invitation is gone.
+	other, err := invitations.Invite(ctx, owner.UserID, item.ID, "cy@example.com", domain.SharePermissionManage)
+	if err != nil {
+		t.Fatalf("Invite(cy): %v", err)
+	}
+	if err := invitations.Cancel(ctx, bob.UserID, other.ID); !errors.Is(err, domain.ErrShareInvitationNotFound) {
+		t.Fatalf("Cancel(not the sharer) err = %v, want ErrShareInvitationNotFound", err)
+	}
+	if err := invitations.Cancel(ctx, owner.UserID, other.ID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if listed, _ := invitations.List(ctx, owner.UserID); len(listed) != 0 {
+		t.Fatalf("List after cancel = %+v, want none", listed)
+	}
+}
@@ -2,13 +2,16 @@ package service
 
 import (
 	"context"
+	"crypto/ed25519"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 
 	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/util"
 )
 
 type SharingService struct {
@@ -17,8 +20,13 @@ type SharingService struct {
 	vaultRepo  domain.VaultRepository
 	familyRepo domain.FamilyRepository
 	audit      *AuditService
+	now        func() time.Time
 }
 
+// expiredShareBatch is how many expired shares RevokeExpiredShares loads
+// at a time.
+const expiredShareBatch = 500
+
 func NewSharingService(
 	shareRepo domain.SharingRepository,
 	keysRepo domain.UserKeysRepository,
@@ -32,6 +40,7 @@ func NewSharingService(
 		vaultRepo:  vaultRepo,
 		familyRepo: familyRepo,
 		audit:      audit,
+		now:        time.Now,
 	}
 }
 
@@ -43,9 +52,18 @@ func (s *SharingService) UpsertUserKeys(ctx context.Context, userID string, inpu
 	if len(input.PublicKeyX25519) == 0 || len(input.EncryptedPrivateKeys) == 0 || len(input.Nonce) == 0 {
 		return domain.ErrInvalidVaultPayload
 	}
+	if len(input.PublicKeyEd25519) != 0 && len(input.PublicKeyEd25519) != ed25519.PublicKeySize {
+		return domain.ErrInvalidVaultPayload
+	}
 
 	input.UserID = userID
-	return s.keysRepo.UpsertKeys(ctx, input)
+	if err := s.keysRepo.UpsertKeys(ctx, input); err != nil {
+		return err
+	}
+
+	uid, _ := uuid.Parse(userID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeSharingKeysUpdated, nil)
+	return nil
 }
 
 // GetUserKeys retrieves the current user's full key material.
@@ -76,6 +94,43 @@ func (s *SharingService) GetPublicKeyByEmail(ctx context.Context, email string)
 	return keys, userID, nil
 }
 
+// GetKeyFingerprint returns the fingerprint of userID's public key and the
+// safety number between it and the viewer's.
+func (s *SharingService) GetKeyFingerprint(ctx context.Context, viewerID, userID string) (domain.KeyFingerprint, error) {
+	if strings.TrimSpace(viewerID) == "" {
+		return domain.KeyFingerprint{}, domain.ErrUnauthorizedSession
+	}
+	userID = strings.TrimSpace(userID)
+	if _, err := uuid.Parse(userID); err != nil {
+		return domain.KeyFingerprint{}, domain.ErrRecipientKeysNotFound
+	}
+	keys, err := s.keysRepo.GetKeysByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.KeyFingerprint{}, domain.ErrRecipientKeysNotFound
+		}
+		return domain.KeyFingerprint{}, fmt.Errorf("get user keys: %w", err)
+	}
+	fp := domain.KeyFingerprint{
+		UserID:      userID,
+		Fingerprint: util.KeyFingerprint(keys.PublicKeyX25519),
+		UpdatedAt:   keys.UpdatedAt,
+	}
+	if viewerID == userID {
+		return fp, nil
+	}
+
+	mine, err := s.keysRepo.GetKeysByUserID(ctx, viewerID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return fp, nil
+	}
+	if err != nil {
+		return domain.KeyFingerprint{}, fmt.Errorf("get viewer keys: %w", err)
+	}
+	fp.SafetyNumber = util.SafetyNumber(viewerID, mine.PublicKeyX25519, userID, keys.PublicKeyX25519)
+	return fp, nil
+}
+
 // ShareItem creates a share record. Only the item owner can share.
 func (s *SharingService) ShareItem(ctx context.Context, ownerUserID string, itemID string, input domain.ShareItemInput) error {
 	if strings.TrimSpace(ownerUserID) == "" {
@@ -111,11 +166,19 @@ func (s *SharingService) ShareItem(ctx context.Context, ownerUserID string, item
 	if len(input.DEKWrapped) == 0 || len(input.WrapNonce) == 0 {
 		return domain.ErrInvalidVaultPayload
 	}
+	window, err := normalizeShareWindow(input.Window, s.now())
+	if err != nil {
+		return err
+	}
+	input.Window = window
 
 	input.ItemID = itemID
 	input.SharedByUserID = ownerUserID
 	if input.Permissions == "" {
-		input.Permissions = "read"
+		input.Permissions = domain.SharePermissionRead
+	}
+	if err := validateSharePermissions(input.Permissions); err != nil {
+		return err
 	}
 
 	err = s.shareRepo.CreateShare(ctx, input)
@@ -127,11 +190,18 @@ func (s *SharingService) ShareItem(ctx context.Context, ownerUserID string, item
 	}
 
 	uid, _ := uuid.Parse(ownerUserID)
-	s.audit.LogEvent(ctx, &uid, domain.EventTypeSharingItemShared, map[string]interface{}{
+	event := map[string]interface{}{
 		"item_id":     itemID,
 		"friend_id":   input.RecipientID,
 		"permissions": input.Permissions,
-	})
+	}
+	if window.ExpiresAt != nil {
+		event["expires_at"] = window.ExpiresAt.Format(time.RFC3339)
+	}
+	if window.NotBefore != nil || window.Schedule != nil {
+		event["time_limited"] = true
+	}
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeSharingItemShared, event)
 
 	return nil
 }
@@ -165,7 +235,58 @@ func (s *SharingService) RevokeShare(ctx context.Context, ownerUserID string, it
 	return nil
 }
 
-// ListSharedWithMe returns all items shared with the given user.
+// UpdateSharePermissions changes what recipientUserID may do with their
+// share of an item: read it, or also manage its shares. Only the item
+// owner can change it.
+func (s *SharingService) UpdateSharePermissions(ctx context.Context, ownerUserID, itemID, recipientUserID, permissions string) error {
+	if strings.TrimSpace(ownerUserID) == "" {
+		return domain.ErrUnauthorizedSession
+	}
+	if err := validateSharePermissions(permissions); err != nil {
+		return err
+	}
+	if err := s.verifyItemOwner(ctx, ownerUserID, itemID); err != nil {
+		return err
+	}
+
+	share, err := s.shareRepo.GetShare(ctx, itemID, recipientUserID)
+	if err != nil {
+		if errors.Is(err, domain.ErrShareNotFound) {
+			return err
+		}
+		return fmt.Errorf("get share: %w", err)
+	}
+	if share.Permissions == permissions {
+		return nil
+	}
+	if err := s.shareRepo.UpdateSharePermissions(ctx, itemID, recipientUserID, permissions); err != nil {
+		if errors.Is(err, domain.ErrShareNotFound) {
+			return err
+		}
+		return fmt.Errorf("update share permissions: %w", err)
+	}
+
+	uid, _ := uuid.Parse(ownerUserID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeSharingPermissionsUpdated, map[string]interface{}{
+		"item_id":              itemID,
+		"friend_id":            recipientUserID,
+		"permissions":          permissions,
+		"previous_permissions": share.Permissions,
+	})
+	return nil
+}
+
+func validateSharePermissions(permissions string) error {
+	switch permissions {
+	case domain.SharePermissionRead, domain.SharePermissionManage:
+		return nil
+	}
+	return domain.ErrInvalidVaultPayload.WithDetail("permissions must be read or manage")
+}
+
+// ListSharedWithMe returns the items shared with the given user whose
+// share grants access right now; shares outside their window or schedule
+// are left out until it opens again.
 func (s *SharingService) ListSharedWithMe(ctx context.Context, userID string) ([]domain.SharedVaultItem, error) {
 	if strings.TrimSpace(userID) == "" {
 		return nil, domain.ErrUnauthorizedSession
@@ -174,7 +295,102 @@ func (s *SharingService) ListSharedWithMe(ctx context.Context, userID string) ([
 	if err != nil {
 		return nil, fmt.Errorf("list shared with me: %w", err)
 	}
-	return items, nil
+	now := s.now()
+	active := items[:0]
+	for _, item := range items {
+		if !item.ShareWindow.Allows(now) {
+			continue
+		}
+		if item.FirstAccessedAt == nil {
+			if err := s.recordFirstAccess(ctx, item.ID, userID, now); err != nil {
+				return nil, err
+			}
+		}
+		active = append(active, item)
+	}
+	return active, nil
+}
+
+// recordFirstAccess stamps the share of itemID as accessed by userID and, if
+// this was the first time and the item has an access alert, tells the owner.
+func (s *SharingService) recordFirstAccess(ctx context.Context, itemID, userID string, at time.Time) error {
+	first, err := s.shareRepo.MarkShareAccessed(ctx, itemID, userID, at.UTC())
+	if err != nil {
+		return fmt.Errorf("mark share accessed: %w", err)
+	}
+	if !first {
+		return nil
+	}
+	alert, err := s.shareRepo.GetItemAccessAlert(ctx, itemID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get item access alert: %w", err)
+	}
+	owner, _ := uuid.Parse(alert.OwnerUserID)
+	s.audit.LogEvent(ctx, &owner, domain.EventTypeSharingItemAccessed, map[string]interface{}{
+		"item_id":   itemID,
+		"friend_id": userID,
+		"email":     alert.Email,
+	})
+	return nil
+}
+
+// GetAccessAlert returns the owner's access alert for an item.
+func (s *SharingService) GetAccessAlert(ctx context.Context, ownerUserID, itemID string) (domain.ItemAccessAlert, error) {
+	if err := s.verifyItemOwner(ctx, ownerUserID, itemID); err != nil {
+		return domain.ItemAccessAlert{}, err
+	}
+	alert, err := s.shareRepo.GetItemAccessAlert(ctx, itemID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.ItemAccessAlert{}, err
+		}
+		return domain.ItemAccessAlert{}, fmt.Errorf("get item access alert: %w", err)
+	}
+	return alert, nil
+}
+
+// SetAccessAlert turns on access alerts for an item: the owner is told in
+// their event feed, and by email if email is set, when a recipient first
+// accesses a share of it.
+func (s *SharingService) SetAccessAlert(ctx context.Context, ownerUserID, itemID string, email bool) (domain.ItemAccessAlert, error) {
+	if err := s.verifyItemOwner(ctx, ownerUserID, itemID); err != nil {
+		return domain.ItemAccessAlert{}, err
+	}
+	err := s.shareRepo.SetItemAccessAlert(ctx, domain.ItemAccessAlert{ItemID: itemID, OwnerUserID: ownerUserID, Email: email})
+	if err != nil {
+		return domain.ItemAccessAlert{}, fmt.Errorf("set item access alert: %w", err)
+	}
+	return s.GetAccessAlert(ctx, ownerUserID, itemID)
+}
+
+// DeleteAccessAlert turns access alerts for an item off.
+func (s *SharingService) DeleteAccessAlert(ctx context.Context, ownerUserID, itemID string) error {
+	if err := s.verifyItemOwner(ctx, ownerUserID, itemID); err != nil {
+		return err
+	}
+	if err := s.shareRepo.DeleteItemAccessAlert(ctx, itemID); err != nil {
+		return fmt.Errorf("delete item access alert: %w", err)
+	}
+	return nil
+}
+
+func (s *SharingService) verifyItemOwner(ctx context.Context, ownerUserID, itemID string) error {
+	if strings.TrimSpace(ownerUserID) == "" {
+		return domain.ErrUnauthorizedSession
+	}
+	if _, err := uuid.Parse(itemID); err != nil {
+		return domain.ErrNotItemOwner
+	}
+	if _, err := s.vaultRepo.GetVaultItemByIDForOwner(ctx, itemID, ownerUserID); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.ErrNotItemOwner
+		}
+		return fmt.Errorf("verify item ownership: %w", err)
+	}
+	return nil
 }
 
 // ListSharesForItem lists all recipients of a shared item. Only the owner can list.
@@ -216,3 +432,81 @@ func (s *SharingService) RevokeAllSharesBetweenUsers(ctx context.Context, user1I
 	}
 	return s.shareRepo.DeleteAllSharesBetweenUsers(ctx, user1ID, user2ID)
 }
+
+// RevokeExpiredShares deletes the shares whose window has ended, recording
+// each revocation in the owner's audit log. Recipients already lose access
+// at expiry; this keeps the owner's share lists current.
+func (s *SharingService) RevokeExpiredShares(ctx context.Context) (int64, error) {
+	var revoked int64
+	for {
+		shares, err := s.shareRepo.ListExpiredShares(ctx, s.now(), expiredShareBatch)
+		if err != nil {
+			return revoked, fmt.Errorf("list expired shares: %w", err)
+		}
+		for _, share := range shares {
+			err := s.shareRepo.DeleteShare(ctx, share.ItemID, share.UserID)
+			if errors.Is(err, domain.ErrShareNotFound) {
+				continue
+			}
+			if err != nil {
+				return revoked, fmt.Errorf("revoke expired share: %w", err)
+			}
+			revoked++
+
+			var owner *uuid.UUID
+			if uid, err := uuid.Parse(share.SharedByUserID); err == nil {
+				owner = &uid
+			}
+			s.audit.LogEvent(ctx, owner, domain.EventTypeSharingRevoked, map[string]interface{}{
+				"item_id":   share.ItemID,
+				"friend_id": share.UserID,
+				"reason":    "expired",
+			})
+		}
+		if len(shares) < expiredShareBatch {
+			return revoked, nil
+		}
+	}
+}
+
+// normalizeShareWindow checks a requested window against now and returns
+// it in UTC, with a schedule's timezone defaulting to UTC.
+func normalizeShareWindow(w domain.ShareWindow, now time.Time) (domain.ShareWindow, error) {
+	if w.NotBefore != nil {
+		t := w.NotBefore.UTC()
+		w.NotBefore = &t
+	}
+	if w.ExpiresAt != nil {
+		t := w.ExpiresAt.UTC()
+		if !t.After(now) || (w.NotBefore != nil && !t.After(*w.NotBefore)) {
+			return domain.ShareWindow{}, domain.ErrInvalidShareWindow
+		}
+		w.ExpiresAt = &t
+	}
+	if w.Schedule == nil {
+		return w, nil
+	}
+
+	schedule := *w.Schedule
+	const minutesPerDay = 24 * 60
+	if len(schedule.Days) == 0 || len(schedule.Days) > 7 ||
+		schedule.StartMinute < 0 || schedule.StartMinute >= minutesPerDay ||
+		schedule.EndMinute < 0 || schedule.EndMinute > minutesPerDay ||
+		schedule.StartMinute == schedule.EndMinute {
+		return domain.ShareWindow{}, domain.ErrInvalidShareWindow
+	}
+	for _, day := range schedule.Days {
+		if day < time.Sunday || day > time.Saturday {
+			return domain.ShareWindow{}, domain.ErrInvalidShareWindow
+		}
+	}
+	schedule.Timezone = strings.TrimSpace(schedule.Timezone)
+	if schedule.Timezone == "" {
+		schedule.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+		return domain.ShareWindow{}, domain.ErrInvalidShareWindow.WithDetail("unknown timezone " + schedule.Timezone)
+	}
+	w.Schedule = &schedule
+	return w, nil
+}
@@ -0,0 +1,243 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestShareWindows(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	auth := service.NewAuthService(store.Auth(), store.Transactor(), nil, "pepper", time.Hour, "pmv2")
+	vault := service.NewVaultService(store.Vault(), store.Folders(), store.Transactor(), nil)
+	sharing := service.NewSharingService(store.Sharing(), store.UserKeys(), store.Vault(), store.Family(), audit)
+	family := service.NewFamilyService(store.Family(), store.Auth(), sharing, nil)
+
+	owner, err := auth.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	contractor, err := auth.Register(ctx, "bob@example.com", "Correct-Horse-9", "Bob")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := family.SendRequest(ctx, owner.UserID, "bob@example.com"); err != nil {
+		t.Fatalf("SendRequest: %v", err)
+	}
+	if err := family.AcceptRequest(ctx, contractor.UserID, owner.UserID); err != nil {
+		t.Fatalf("AcceptRequest: %v", err)
+	}
+
+	share := func(window domain.ShareWindow) (domain.VaultItem, error) {
+		t.Helper()
+		item, err := vault.CreateItem(ctx, owner.UserID, domain.CreateVaultItemInput{
+			Ciphertext: []byte("c"), Nonce: []byte("n"), WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"),
+			AlgoVersion: "xchacha20poly1305-v1",
+		})
+		if err != nil {
+			t.Fatalf("CreateItem: %v", err)
+		}
+		return item, sharing.ShareItem(ctx, owner.UserID, item.ID, domain.ShareItemInput{
+			RecipientID: contractor.UserID, DEKWrapped: []byte("dek"), WrapNonce: []byte("wn"), Window: window,
+		})
+	}
+
+	now := time.Now().UTC()
+	past, later, soon := now.Add(-time.Hour), now.Add(time.Hour), now.Add(50*time.Millisecond)
+	if _, err := share(domain.ShareWindow{ExpiresAt: &past}); !errors.Is(err, domain.ErrInvalidShareWindow) {
+		t.Fatalf("ShareItem(expired) err = %v, want ErrInvalidShareWindow", err)
+	}
+	if _, err := share(domain.ShareWindow{Schedule: &domain.ShareSchedule{Days: []time.Weekday{time.Monday}, StartMinute: 540, EndMinute: 1080, Timezone: "Mars/Olympus"}}); !errors.Is(err, domain.ErrInvalidShareWindow) {
+		t.Fatalf("ShareItem(bad timezone) err = %v, want ErrInvalidShareWindow", err)
+	}
+
+	allWeek := []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday}
+	open, err := share(domain.ShareWindow{ExpiresAt: &later, Schedule: &domain.ShareSchedule{Days: allWeek, StartMinute: 0, EndMinute: 24 * 60}})
+	if err != nil {
+		t.Fatalf("ShareItem(open): %v", err)
+	}
+	if _, err := share(domain.ShareWindow{NotBefore: &later}); err != nil {
+		t.Fatalf("ShareItem(not yet): %v", err)
+	}
+	if _, err := share(domain.ShareWindow{Schedule: &domain.ShareSchedule{Days: []time.Weekday{(now.Weekday() + 1) % 7}, StartMinute: 0, EndMinute: 24 * 60}}); err != nil {
+		t.Fatalf("ShareItem(tomorrow only): %v", err)
+	}
+	expiring, err := share(domain.ShareWindow{ExpiresAt: &soon})
+	if err != nil {
+		t.Fatalf("ShareItem(expiring): %v", err)
+	}
+
+	items, err := sharing.ListSharedWithMe(ctx, contractor.UserID)
+	if err != nil || len(items) != 2 {
+		t.Fatalf("ListSharedWithMe = %d items, %v; want the open and expiring shares", len(items), err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	items, _ = sharing.ListSharedWithMe(ctx, contractor.UserID)
+	if len(items) != 1 || items[0].ID != open.ID {
+		t.Fatalf("ListSharedWithMe after expiry = %+v, want only the open share", items)
+	}
+
+	revoked, err := sharing.RevokeExpiredShares(ctx)
+	if err != nil || revoked != 1 {
+		t.Fatalf("RevokeExpiredShares = %d, %v; want 1", revoked, err)
+	}
+	if shares, _ := sharing.ListSharesForItem(ctx, owner.UserID, expiring.ID); len(shares) != 0 {
+		t.Fatalf("shares of the expired item = %+v, want none", shares)
+	}
+	if revoked, err := sharing.RevokeExpiredShares(ctx); err != nil || revoked != 0 {
+		t.Fatalf("RevokeExpiredShares(again) = %d, %v; want 0", revoked, err)
+	}
+}
+
+func TestUpdateSharePermissions(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	auth := service.NewAuthService(store.Auth(), store.Transactor(), nil, "pepper", time.Hour, "pmv2")
+	vault := service.NewVaultService(store.Vault(), store.Folders(), store.Transactor(), nil)
+	sharing := service.NewSharingService(store.Sharing(), store.UserKeys(), store.Vault(), store.Family(), service.NewAuditService(store.Audit(), nil))
+	family := service.NewFamilyService(store.Family(), store.Auth(), sharing, nil)
+
+	owner, err := auth.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	friend, err := auth.Register(ctx, "bob@example.com", "Correct-Horse-9", "Bob")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := family.SendRequest(ctx, owner.UserID, "bob@example.com"); err != nil {
+		t.Fatalf("SendRequest: %v", err)
+	}
+	if err := family.AcceptRequest(ctx, friend.UserID, owner.UserID); err != nil {
+		t.Fatalf("AcceptRequest: %v", err)
+	}
+	item, err := vault.CreateItem(ctx, owner.UserID, domain.CreateVaultItemInput{
+		Ciphertext: []byte("c"), Nonce: []byte("n"), WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"),
+		AlgoVersion: "xchacha20poly1305-v1",
+	})
+	if err != nil {
+		t.Fatalf("CreateItem: %v", err)
+	}
+	if err := sharing.ShareItem(ctx, owner.UserID, item.ID, domain.ShareItemInput{
+		RecipientID: friend.UserID, DEKWrapped: []byte("dek"), WrapNonce: []byte("wn"), Permissions: "admin",
+	}); !errors.Is(err, domain.ErrInvalidVaultPayload) {
+		t.Fatalf("ShareItem(unknown permissions) err = %v, want ErrInvalidVaultPayload", err)
+	}
+	if err := sharing.ShareItem(ctx, owner.UserID, item.ID, domain.ShareItemInput{
+		RecipientID: friend.UserID, DEKWrapped: []byte("dek"), WrapNonce: []byte("wn"),
+	}); err != nil {
+		t.Fatalf("ShareItem: %v", err)
+	}
+
+	if err := sharing.UpdateSharePermissions(ctx, friend.UserID, item.ID, friend.UserID, domain.SharePermissionManage); !errors.Is(err, domain.ErrNotItemOwner) {
+		t.Fatalf("UpdateSharePermissions(recipient) err = %v, want ErrNotItemOwner", err)
+	}
+	if err := sharing.UpdateSharePermissions(ctx, owner.UserID, item.ID, owner.UserID, domain.SharePermissionManage); !errors.Is(err, domain.ErrShareNotFound) {
+		t.Fatalf("UpdateSharePermissions(no share) err = %v, want ErrShareNotFound", err)
+	}
+	if err := sharing.UpdateSharePermissions(ctx, owner.UserID, item.ID, friend.UserID, "write"); !errors.Is(err, domain.ErrInvalidVaultPayload) {
+		t.Fatalf("UpdateSharePermissions(unknown) err = %v, want ErrInvalidVaultPayload", err)
+	}
+	if err := sharing.UpdateSharePermissions(ctx, owner.UserID, item.ID, friend.UserID, domain.SharePermissionManage); err != nil {
+		t.Fatalf("UpdateSharePermissions: %v", err)
+	}
+	items, err := sharing.ListSharedWithMe(ctx, friend.UserID)
+	if err != nil || len(items) != 1 || items[0].Permissions != domain.SharePermissionManage {
+		t.Fatalf("ListSharedWithMe = %+v, %v; want a manage share", items, err)
+	}
+}
+
+func TestShareScheduleAllows(t *testing.T) {
+	// Monday 2024-01-01 in UTC.
+	at := func(day, hour, minute int) time.Time { return time.Date(2024, 1, day, hour, minute, 0, 0, time.UTC) }
+	weekdays := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	office := domain.ShareSchedule{Days: weekdays, StartMinute: 9 * 60, EndMinute: 18 * 60, Timezone: "UTC"}
+	night := domain.ShareSchedule{Days: []time.Weekday{time.Friday}, StartMinute: 22 * 60, EndMinute: 6 * 60, Timezone: "UTC"}
+	berlin := domain.ShareSchedule{Days: weekdays, StartMinute: 9 * 60, EndMinute: 18 * 60, Timezone: "Europe/Berlin"}
+
+	for _, tc := range []struct {
+		name     string
+		schedule domain.ShareSchedule
+		t        time.Time
+		want     bool
+	}{
+		{"office hours", office, at(1, 9, 0), true},
+		{"before office hours", office, at(1, 8, 59), false},
+		{"end is exclusive", office, at(1, 18, 0), false},
+		{"weekend", office, at(6, 12, 0), false},
+		{"overnight start", night, at(5, 23, 0), true},
+		{"overnight next morning", night, at(6, 5, 59), true},
+		{"overnight ended", night, at(6, 6, 0), false},
+		{"overnight wrong day", night, at(4, 23, 0), false},
+		{"local timezone", berlin, at(1, 17, 30), false},
+		{"local timezone open", berlin, at(1, 8, 0), true},
+	} {
+		if got := tc.schedule.Allows(tc.t); got != tc.want {
+			t.Errorf("%s: Allows(%s) = %v, want %v", tc.name, tc.t, got, tc.want)
+		}
+	}
+}
+
+func TestKeyFingerprint(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	auth := service.NewAuthService(store.Auth(), store.Transactor(), nil, "pepper", time.Hour, "pmv2")
+	sharing := service.NewSharingService(store.Sharing(), store.UserKeys(), store.Vault(), store.Family(), nil)
+
+	register := func(email, publicKey string) string {
+		t.Helper()
+		user, err := auth.Register(ctx, email, "Correct-Horse-9", "")
+		if err != nil {
+			t.Fatalf("register %s: %v", email, err)
+		}
+		if publicKey != "" {
+			err := sharing.UpsertUserKeys(ctx, user.UserID, domain.UpsertUserKeysInput{
+				PublicKeyX25519: []byte(publicKey), EncryptedPrivateKeys: []byte("priv"), Nonce: []byte("n"),
+			})
+			if err != nil {
+				t.Fatalf("UpsertUserKeys: %v", err)
+			}
+		}
+		return user.UserID
+	}
+	ada := register("ada@example.com", "ada-key")
+	bob := register("bob@example.com", "bob-key")
+	cy := register("cy@example.com", "")
+
+	own, err := sharing.GetKeyFingerprint(ctx, ada, ada)
+	if err != nil || own.Fingerprint == "" || own.SafetyNumber != "" {
+		t.Fatalf("own fingerprint = %+v, %v; want a fingerprint without safety number", own, err)
+	}
+	fromAda, err := sharing.GetKeyFingerprint(ctx, ada, bob)
+	if err != nil || fromAda.SafetyNumber == "" {
+		t.Fatalf("GetKeyFingerprint(ada, bob) = %+v, %v; want a safety number", fromAda, err)
+	}
+	fromBob, err := sharing.GetKeyFingerprint(ctx, bob, ada)
+	if err != nil || fromBob.SafetyNumber != fromAda.SafetyNumber || fromBob.Fingerprint != own.Fingerprint {
+		t.Fatalf("GetKeyFingerprint(bob, ada) = %+v, %v; want the same safety number and ada's fingerprint", fromBob, err)
+	}
+	if fp, err := sharing.GetKeyFingerprint(ctx, cy, bob); err != nil || fp.SafetyNumber != "" {
+		t.Fatalf("GetKeyFingerprint(viewer without keys) = %+v, %v; want no safety number", fp, err)
+	}
+	if _, err := sharing.GetKeyFingerprint(ctx, ada, cy); !errors.Is(err, domain.ErrRecipientKeysNotFound) {
+		t.Fatalf("GetKeyFingerprint(user without keys) err = %v, want ErrRecipientKeysNotFound", err)
+	}
+
+	// A replaced key changes the safety number, which is what a client
+	// warns about.
+	if err := sharing.UpsertUserKeys(ctx, bob, domain.UpsertUserKeysInput{
+		PublicKeyX25519: []byte("new-bob-key"), EncryptedPrivateKeys: []byte("priv"), Nonce: []byte("n"),
+	}); err != nil {
+		t.Fatalf("UpsertUserKeys: %v", err)
+	}
+	if fp, _ := sharing.GetKeyFingerprint(ctx, ada, bob); fp.SafetyNumber == fromAda.SafetyNumber {
+		t.Fatal("safety number unchanged after key replacement")
+	}
+}
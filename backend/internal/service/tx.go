@@ -0,0 +1,17 @@
+package service
+
+import (
+	"context"
+
+	"pmv2/backend/internal/domain"
+)
+
+// withinTx runs fn as one unit of work through tx: every repository call
+// made with the ctx fn receives commits or rolls back together. A nil tx
+// runs fn directly, for services built without storage transactions.
+func withinTx(ctx context.Context, tx domain.Transactor, fn func(ctx context.Context) error) error {
+	if tx == nil {
+		return fn(ctx)
+	}
+	return tx.WithinTx(ctx, fn)
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+// UseAutofill turns on the browser extension's URI matching and usage
+// counters. Without it URI HMACs sent with items are dropped, nothing
+// matches and uses are not counted.
+func (s *VaultService) UseAutofill(autofill domain.AutofillRepository) {
+	s.autofill = autofill
+}
+
+// ListAutofillItems returns the user's active items with the URI HMACs they
+// match on and their usage. The caller leaves the ciphertext out, so these
+// reads are not counted as downloads.
+func (s *VaultService) ListAutofillItems(ctx context.Context, userID string) ([]domain.AutofillItem, error) {
+	ownerUserID := strings.TrimSpace(userID)
+	if ownerUserID == "" {
+		return nil, domain.ErrUnauthorizedSession
+	}
+
+	items, err := s.repo.ListVaultItemsByOwner(ctx, ownerUserID)
+	if err != nil {
+		return nil, fmt.Errorf("list vault items: %w", err)
+	}
+	hmacs := map[string][][]byte{}
+	usage := map[string]domain.ItemUsage{}
+	if s.autofill != nil {
+		if hmacs, err = s.autofill.ListURIHMACs(ctx, ownerUserID); err != nil {
+			return nil, fmt.Errorf("list uri hmacs: %w", err)
+		}
+		if usage, err = s.autofill.ListItemUsage(ctx, ownerUserID); err != nil {
+			return nil, fmt.Errorf("list item usage: %w", err)
+		}
+	}
+
+	out := make([]domain.AutofillItem, 0, len(items))
+	for _, item := range items {
+		out = append(out, autofillItem(item, hmacs[item.ID], usage))
+	}
+	return out, nil
+}
+
+// MatchItems returns the user's active items carrying any of hmacs, most
+// recently used first. The items are returned whole, ready to fill.
+func (s *VaultService) MatchItems(ctx context.Context, userID string, hmacs [][]byte) ([]domain.AutofillItem, error) {
+	ownerUserID := strings.TrimSpace(userID)
+	if ownerUserID == "" {
+		return nil, domain.ErrUnauthorizedSession
+	}
+	if len(hmacs) == 0 {
+		return nil, domain.ErrInvalidURIHMAC
+	}
+	if err := validateURIHMACs(hmacs); err != nil {
+		return nil, err
+	}
+	if s.autofill == nil {
+		return []domain.AutofillItem{}, nil
+	}
+
+	ids, err := s.autofill.MatchURIHMACs(ctx, ownerUserID, hmacs)
+	if err != nil {
+		return nil, fmt.Errorf("match uri hmacs: %w", err)
+	}
+	usage, err := s.autofill.ListItemUsage(ctx, ownerUserID)
+	if err != nil {
+		return nil, fmt.Errorf("list item usage: %w", err)
+	}
+
+	matches := make([]domain.AutofillItem, 0, len(ids))
+	for _, id := range ids {
+		item, err := s.repo.GetVaultItemByIDForOwner(ctx, id, ownerUserID)
+		if err != nil {
+			// Deleted since it matched.
+			if errors.Is(err, domain.ErrNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("get vault item: %w", err)
+		}
+		matches = append(matches, autofillItem(item, nil, usage))
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i].Usage, matches[j].Usage
+		switch {
+		case a.LastUsedAt == nil || b.LastUsedAt == nil:
+			return a.LastUsedAt != nil && b.LastUsedAt == nil
+		case !a.LastUsedAt.Equal(*b.LastUsedAt):
+			return a.LastUsedAt.After(*b.LastUsedAt)
+		default:
+			return matches[i].UpdatedAt.After(matches[j].UpdatedAt)
+		}
+	})
+	s.anomalies.CountDownloads(ctx, ownerUserID, len(matches))
+	return matches, nil
+}
+
+// RecordItemUse counts one autofill of an active item of the user.
+func (s *VaultService) RecordItemUse(ctx context.Context, userID, itemID string) (domain.ItemUsage, error) {
+	ownerUserID := strings.TrimSpace(userID)
+	trimmedItemID := strings.TrimSpace(itemID)
+	if ownerUserID == "" {
+		return domain.ItemUsage{}, domain.ErrUnauthorizedSession
+	}
+	if trimmedItemID == "" || s.autofill == nil {
+		return domain.ItemUsage{}, domain.ErrNotFound
+	}
+
+	usage, err := s.autofill.RecordItemUse(ctx, ownerUserID, trimmedItemID, time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.ItemUsage{}, domain.ErrNotFound
+		}
+		return domain.ItemUsage{}, fmt.Errorf("record item use: %w", err)
+	}
+	return usage, nil
+}
+
+func (s *VaultService) setURIHMACs(ctx context.Context, ownerUserID, itemID string, hmacs [][]byte) error {
+	if hmacs == nil || s.autofill == nil {
+		return nil
+	}
+	if err := s.autofill.SetURIHMACs(ctx, ownerUserID, itemID, hmacs); err != nil {
+		return fmt.Errorf("set uri hmacs: %w", err)
+	}
+	return nil
+}
+
+func autofillItem(item domain.VaultItem, hmacs [][]byte, usage map[string]domain.ItemUsage) domain.AutofillItem {
+	u, ok := usage[item.ID]
+	if !ok {
+		u = domain.ItemUsage{ItemID: item.ID}
+	}
+	return domain.AutofillItem{VaultItem: item, URIHMACs: hmacs, Usage: u}
+}
+
+func validateURIHMACs(hmacs [][]byte) error {
+	if len(hmacs) > domain.MaxURIHMACsPerItem {
+		return domain.ErrInvalidURIHMAC
+	}
+	for _, hmac := range hmacs {
+		if len(hmac) != domain.URIHMACSize {
+			return domain.ErrInvalidURIHMAC
+		}
+	}
+	return nil
+}
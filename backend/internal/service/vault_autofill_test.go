@@ -0,0 +1,99 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestAutofill(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	auth := service.NewAuthService(store.Auth(), store.Transactor(), nil, "pepper", time.Hour, "pmv2")
+	vault := service.NewVaultService(store.Vault(), store.Folders(), store.Transactor(), nil)
+	vault.UseAutofill(store.Autofill())
+
+	user, err := auth.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	site := make([]byte, domain.URIHMACSize)
+	site[0] = 1
+	newItem := func(hmacs [][]byte) domain.VaultItem {
+		t.Helper()
+		item, err := vault.CreateItem(ctx, user.UserID, domain.CreateVaultItemInput{
+			Ciphertext: []byte("c"), Nonce: []byte("n"), WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"),
+			AlgoVersion: "xchacha20poly1305-v1", URIHMACs: hmacs,
+		})
+		if err != nil {
+			t.Fatalf("CreateItem: %v", err)
+		}
+		return item
+	}
+
+	if _, err := vault.CreateItem(ctx, user.UserID, domain.CreateVaultItemInput{
+		Ciphertext: []byte("c"), Nonce: []byte("n"), WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"),
+		AlgoVersion: "xchacha20poly1305-v1", URIHMACs: [][]byte{[]byte("short")},
+	}); !errors.Is(err, domain.ErrInvalidURIHMAC) {
+		t.Fatalf("CreateItem(short hmac) err = %v, want ErrInvalidURIHMAC", err)
+	}
+
+	older := newItem([][]byte{site})
+	recent := newItem([][]byte{site})
+	unmatched := newItem(nil)
+
+	if _, err := vault.RecordItemUse(ctx, user.UserID, recent.ID); err != nil {
+		t.Fatalf("RecordItemUse: %v", err)
+	}
+	matches, err := vault.MatchItems(ctx, user.UserID, [][]byte{site})
+	if err != nil || len(matches) != 2 || matches[0].ID != recent.ID || matches[0].Usage.UseCount != 1 {
+		t.Fatalf("MatchItems = %+v, %v; want the recently used item first", matches, err)
+	}
+	if string(matches[1].Ciphertext) != "c" {
+		t.Fatalf("matched item ciphertext = %q, want the full item", matches[1].Ciphertext)
+	}
+
+	// An update without URI HMACs keeps them; an empty list clears them.
+	update := domain.UpdateVaultItemInput{
+		Ciphertext: []byte("c2"), Nonce: []byte("n"), WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"),
+		AlgoVersion: "xchacha20poly1305-v1",
+	}
+	if _, err := vault.UpdateItem(ctx, user.UserID, older.ID, update); err != nil {
+		t.Fatalf("UpdateItem: %v", err)
+	}
+	if matches, _ := vault.MatchItems(ctx, user.UserID, [][]byte{site}); len(matches) != 2 {
+		t.Fatalf("MatchItems after update = %d items, want 2", len(matches))
+	}
+	update.URIHMACs = [][]byte{}
+	if _, err := vault.UpdateItem(ctx, user.UserID, older.ID, update); err != nil {
+		t.Fatalf("UpdateItem(clear): %v", err)
+	}
+	if matches, _ := vault.MatchItems(ctx, user.UserID, [][]byte{site}); len(matches) != 1 || matches[0].ID != recent.ID {
+		t.Fatalf("MatchItems after clearing = %+v, want only the recent item", matches)
+	}
+
+	items, err := vault.ListAutofillItems(ctx, user.UserID)
+	if err != nil || len(items) != 3 {
+		t.Fatalf("ListAutofillItems = %d items, %v; want 3", len(items), err)
+	}
+	for _, item := range items {
+		if item.ID == unmatched.ID && (len(item.URIHMACs) != 0 || item.Usage.UseCount != 0) {
+			t.Fatalf("unmatched item = %+v", item)
+		}
+	}
+
+	if err := vault.DeleteItem(ctx, user.UserID, recent.ID); err != nil {
+		t.Fatalf("DeleteItem: %v", err)
+	}
+	if _, err := vault.RecordItemUse(ctx, user.UserID, recent.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("RecordItemUse(deleted) err = %v, want ErrNotFound", err)
+	}
+	if matches, _ := vault.MatchItems(ctx, user.UserID, [][]byte{site}); len(matches) != 0 {
+		t.Fatalf("MatchItems after delete = %+v, want none", matches)
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+)
+
+// UseExports turns on the vault export. attachments lists the attachments
+// described in an export and backups registers every export taken; without
+// them Export fails.
+func (s *VaultService) UseExports(attachments domain.AttachmentRepository, backups domain.BackupRegistryRepository) {
+	s.attachments = attachments
+	s.backups = backups
+}
+
+// Export returns the user's active vault for download in format, which
+// defaults to JSON. The export is registered in the backups registry and
+// audited before anything is served, so an export cut short still shows in
+// the user's activity; its checksum is not recorded since the document is
+// streamed and never stored.
+func (s *VaultService) Export(ctx context.Context, userID, format string) (domain.VaultExport, error) {
+	ownerUserID := strings.TrimSpace(userID)
+	uid, err := uuid.Parse(ownerUserID)
+	if err != nil {
+		return domain.VaultExport{}, domain.ErrUnauthorizedSession
+	}
+	switch format {
+	case "":
+		format = domain.VaultExportJSON
+	case domain.VaultExportJSON, domain.VaultExportCSV:
+	default:
+		return domain.VaultExport{}, domain.ErrInvalidExportFormat
+	}
+	if s.attachments == nil || s.backups == nil {
+		return domain.VaultExport{}, fmt.Errorf("vault export is not configured")
+	}
+	s.anomalies.CheckExport(ctx, ownerUserID)
+
+	export := domain.VaultExport{ID: uuid.NewString(), Format: format, CreatedAt: time.Now().UTC()}
+	if export.Items, err = s.repo.ListVaultItemsByOwner(ctx, ownerUserID); err != nil {
+		return domain.VaultExport{}, fmt.Errorf("list vault items: %w", err)
+	}
+	if export.Folders, err = s.folders.ListFoldersByOwner(ctx, ownerUserID); err != nil {
+		return domain.VaultExport{}, fmt.Errorf("list folders: %w", err)
+	}
+	if export.Attachments, err = s.attachments.ListAttachmentsByOwner(ctx, ownerUserID); err != nil {
+		return domain.VaultExport{}, fmt.Errorf("list attachments: %w", err)
+	}
+
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		if err := s.backups.RegisterBackup(ctx, domain.BackupRecord{
+			ID:              export.ID,
+			CreatedByUserID: ownerUserID,
+			StorageURI:      "download:vault-export." + format,
+			Encrypted:       true,
+		}); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, &uid, domain.EventTypeVaultExported, map[string]any{
+			"export_id":   export.ID,
+			"format":      format,
+			"items":       len(export.Items),
+			"folders":     len(export.Folders),
+			"attachments": len(export.Attachments),
+		})
+	})
+	if err != nil {
+		return domain.VaultExport{}, err
+	}
+	s.anomalies.CountDownloads(ctx, ownerUserID, len(export.Items))
+	return export, nil
+}
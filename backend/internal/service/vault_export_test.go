@@ -0,0 +1,69 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestVaultExport(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	auth := service.NewAuthService(store.Auth(), store.Transactor(), audit, "pepper", time.Hour, "pmv2")
+	vault := service.NewVaultService(store.Vault(), store.Folders(), store.Transactor(), audit)
+
+	user, err := auth.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if _, err := vault.Export(ctx, user.UserID, ""); err == nil {
+		t.Fatal("Export without UseExports succeeded")
+	}
+	vault.UseExports(store.Attachments(), store.Backups())
+
+	payload := domain.CreateVaultItemInput{
+		Ciphertext: []byte("c"), Nonce: []byte("n"), WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"), AlgoVersion: "xchacha20poly1305-v1",
+	}
+	kept, err := vault.CreateItem(ctx, user.UserID, payload)
+	if err != nil {
+		t.Fatalf("CreateItem: %v", err)
+	}
+	trashed, err := vault.CreateItem(ctx, user.UserID, payload)
+	if err != nil {
+		t.Fatalf("CreateItem: %v", err)
+	}
+	if err := store.Attachments().CreateAttachment(ctx, domain.VaultAttachment{
+		ID: uuid.NewString(), ItemID: kept.ID, FileNameCiphertext: []byte("name"), Nonce: []byte("n"), SizeBytes: 3, StoragePath: "a/b",
+	}); err != nil {
+		t.Fatalf("CreateAttachment: %v", err)
+	}
+	if err := vault.DeleteItem(ctx, user.UserID, trashed.ID); err != nil {
+		t.Fatalf("DeleteItem: %v", err)
+	}
+
+	if _, err := vault.Export(ctx, user.UserID, "xml"); !errors.Is(err, domain.ErrInvalidExportFormat) {
+		t.Fatalf("Export(xml): err = %v", err)
+	}
+	export, err := vault.Export(ctx, user.UserID, "")
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if export.Format != domain.VaultExportJSON || len(export.Items) != 1 || export.Items[0].ID != kept.ID || len(export.Attachments) != 1 {
+		t.Fatalf("export = %+v, want the active item and its attachment", export)
+	}
+
+	uid := uuid.MustParse(user.UserID)
+	events, total, err := store.Audit().ListEvents(ctx, 10, 0, domain.AuditFilter{UserID: &uid, EventTypes: []domain.EventType{domain.EventTypeVaultExported}})
+	if err != nil || total != 1 || !strings.Contains(string(events[0].EventData), export.ID) {
+		t.Fatalf("%s events = %+v, %v", domain.EventTypeVaultExported, events, err)
+	}
+}
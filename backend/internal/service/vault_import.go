@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/importer"
+)
+
+// ImportItems stores the items a client read from another password
+// manager's export, which source names, and encrypted itself; the server
+// never sees the export. Each row is validated on its own: the valid ones
+// are created in one transaction and audited as one import, and the rest
+// are reported by row. A failure to store fails the whole import.
+func (s *VaultService) ImportItems(ctx context.Context, userID, source string, rows []domain.VaultImportRow) (domain.VaultImportResult, error) {
+	ownerUserID := strings.TrimSpace(userID)
+	uid, err := uuid.Parse(ownerUserID)
+	if err != nil {
+		return domain.VaultImportResult{}, domain.ErrUnauthorizedSession
+	}
+	format, err := importer.ParseFormat(source)
+	if err != nil {
+		return domain.VaultImportResult{}, domain.ErrInvalidImportSource
+	}
+
+	var result domain.VaultImportResult
+	valid := make([]domain.VaultImportRow, 0, len(rows))
+	seen := make(map[int]bool, len(rows))
+	for _, row := range rows {
+		if err := validateImportRow(row, seen); err != nil {
+			result.Errors = append(result.Errors, domain.VaultImportRowError{Row: row.Row, Err: err})
+			continue
+		}
+		row.Item.OwnerUserID = ownerUserID
+		valid = append(valid, row)
+	}
+	if len(valid) == 0 {
+		return result, nil
+	}
+
+	inputs := make([]domain.CreateVaultItemInput, len(valid))
+	for i, row := range valid {
+		inputs[i] = row.Item
+	}
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		items, err := s.repo.CreateVaultItemsBulk(ctx, inputs)
+		if err != nil {
+			return fmt.Errorf("create imported vault items: %w", err)
+		}
+		result.Imported = make([]domain.ImportedVaultItem, 0, len(items))
+		for i, item := range items {
+			if err := s.setURIHMACs(ctx, ownerUserID, item.ID, inputs[i].URIHMACs); err != nil {
+				return err
+			}
+			result.Imported = append(result.Imported, domain.ImportedVaultItem{Row: valid[i].Row, Item: item})
+		}
+		return s.audit.Record(ctx, &uid, domain.EventTypeVaultImported, map[string]any{
+			"source":  string(format),
+			"items":   len(items),
+			"skipped": len(result.Errors),
+		})
+	})
+	if err != nil {
+		return domain.VaultImportResult{}, err
+	}
+	return result, nil
+}
+
+// validateImportRow checks one row of an import, recording its row number
+// in seen.
+func validateImportRow(row domain.VaultImportRow, seen map[int]bool) *domain.Error {
+	if row.Row <= 0 || seen[row.Row] {
+		return domain.ErrInvalidImportRow
+	}
+	seen[row.Row] = true
+
+	item := row.Item
+	if err := validateVaultPayload(item.Ciphertext, item.Nonce, item.WrappedDEK, item.WrapNonce, item.AlgoVersion, item.Metadata); err != nil {
+		return domain.ErrInvalidVaultPayload
+	}
+	if err := validateURIHMACs(item.URIHMACs); err != nil {
+		return domain.ErrInvalidURIHMAC
+	}
+	var meta struct {
+		Kind string `json:"kind"`
+	}
+	if len(item.Metadata) > 0 {
+		_ = json.Unmarshal(item.Metadata, &meta)
+	}
+	if !importer.IsKind(meta.Kind) {
+		return domain.ErrUnsupportedImportKind
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestVaultImport(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	audit := service.NewAuditService(store.Audit(), nil)
+	auth := service.NewAuthService(store.Auth(), store.Transactor(), audit, "pepper", time.Hour, "pmv2")
+	vault := service.NewVaultService(store.Vault(), store.Folders(), store.Transactor(), audit)
+
+	user, err := auth.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	row := func(n int, kind string) domain.VaultImportRow {
+		return domain.VaultImportRow{Row: n, Item: domain.CreateVaultItemInput{
+			Ciphertext: []byte("c"), Nonce: []byte("n"), WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"), AlgoVersion: "xchacha20poly1305-v1",
+			Metadata: []byte(`{"kind":"` + kind + `"}`),
+		}}
+	}
+
+	if _, err := vault.ImportItems(ctx, user.UserID, "dashlane_csv", []domain.VaultImportRow{row(1, "login")}); !errors.Is(err, domain.ErrInvalidImportSource) {
+		t.Fatalf("ImportItems(dashlane_csv): err = %v", err)
+	}
+
+	result, err := vault.ImportItems(ctx, user.UserID, "bitwarden_json", []domain.VaultImportRow{
+		row(1, "login"), row(2, "card"), row(2, "note"), row(3, "bank"), row(4, "note"),
+	})
+	if err != nil {
+		t.Fatalf("ImportItems: %v", err)
+	}
+	if len(result.Imported) != 3 || result.Imported[0].Row != 1 || result.Imported[1].Row != 2 || result.Imported[2].Row != 4 {
+		t.Fatalf("imported = %+v, want rows 1, 2 and 4", result.Imported)
+	}
+	if len(result.Errors) != 2 ||
+		result.Errors[0].Row != 2 || !errors.Is(result.Errors[0].Err, domain.ErrInvalidImportRow) ||
+		result.Errors[1].Row != 3 || !errors.Is(result.Errors[1].Err, domain.ErrUnsupportedImportKind) {
+		t.Fatalf("errors = %+v, want the repeated row 2 and the bank on row 3", result.Errors)
+	}
+	items, err := vault.ListItems(ctx, user.UserID)
+	if err != nil || len(items) != 3 {
+		t.Fatalf("ListItems = %d items, %v; want the 3 imported", len(items), err)
+	}
+
+	// Nothing valid: nothing is stored or audited.
+	result, err = vault.ImportItems(ctx, user.UserID, "keepass_csv", []domain.VaultImportRow{row(0, "login")})
+	if err != nil || len(result.Imported) != 0 || len(result.Errors) != 1 {
+		t.Fatalf("ImportItems(row 0) = %+v, %v", result, err)
+	}
+
+	uid := uuid.MustParse(user.UserID)
+	events, total, err := store.Audit().ListEvents(ctx, 10, 0, domain.AuditFilter{UserID: &uid, EventTypes: []domain.EventType{domain.EventTypeVaultImported}})
+	if err != nil || total != 1 || !strings.Contains(string(events[0].EventData), `"source":"bitwarden_json"`) {
+		t.Fatalf("%s events = %+v, %v", domain.EventTypeVaultImported, events, err)
+	}
+}
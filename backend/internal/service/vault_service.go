@@ -2,10 +2,14 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -13,12 +17,32 @@ import (
 )
 
 type VaultService struct {
-	repo  domain.VaultRepository
-	audit *AuditService
+	repo      domain.VaultRepository
+	folders   domain.FolderRepository
+	tx        domain.Transactor
+	audit     *AuditService
+	anomalies *AnomalyService
+	autofill  domain.AutofillRepository
+
+	attachments domain.AttachmentRepository
+	backups     domain.BackupRegistryRepository
 }
 
-func NewVaultService(repo domain.VaultRepository, audit *AuditService) *VaultService {
-	return &VaultService{repo: repo, audit: audit}
+// NewVaultService builds the vault service. Item changes and their audit
+// events are committed together through tx; a nil tx runs them unwrapped.
+// folders is only read, to include folders in sync snapshots.
+func NewVaultService(repo domain.VaultRepository, folders domain.FolderRepository, tx domain.Transactor, audit *AuditService) *VaultService {
+	return &VaultService{repo: repo, folders: folders, tx: tx, audit: audit}
+}
+
+// UseAnomalyDetection has the items served by reads counted towards the
+// mass download check.
+func (s *VaultService) UseAnomalyDetection(anomalies *AnomalyService) {
+	s.anomalies = anomalies
+}
+
+func (s *VaultService) withinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTx(ctx, s.tx, fn)
 }
 
 func (s *VaultService) CreateItem(ctx context.Context, userID string, input domain.CreateVaultItemInput) (domain.VaultItem, error) {
@@ -30,17 +54,30 @@ func (s *VaultService) CreateItem(ctx context.Context, userID string, input doma
 	if err := validateVaultPayload(input.Ciphertext, input.Nonce, input.WrappedDEK, input.WrapNonce, input.AlgoVersion, input.Metadata); err != nil {
 		return domain.VaultItem{}, err
 	}
-
-	input.OwnerUserID = ownerUserID
-	item, err := s.repo.CreateVaultItem(ctx, input)
-	if err != nil {
-		return domain.VaultItem{}, fmt.Errorf("create vault item: %w", err)
+	if err := validateURIHMACs(input.URIHMACs); err != nil {
+		return domain.VaultItem{}, err
 	}
 
+	input.OwnerUserID = ownerUserID
 	uid, _ := uuid.Parse(ownerUserID)
-	s.audit.LogEvent(ctx, &uid, domain.EventTypeVaultItemCreated, map[string]string{
-		"item_id": item.ID,
+
+	var item domain.VaultItem
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		item, err = s.repo.CreateVaultItem(ctx, input)
+		if err != nil {
+			return fmt.Errorf("create vault item: %w", err)
+		}
+		if err := s.setURIHMACs(ctx, ownerUserID, item.ID, input.URIHMACs); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, &uid, domain.EventTypeVaultItemCreated, map[string]string{
+			"item_id": item.ID,
+		})
 	})
+	if err != nil {
+		return domain.VaultItem{}, err
+	}
 
 	return item, nil
 }
@@ -56,20 +93,35 @@ func (s *VaultService) CreateItemsBulk(ctx context.Context, userID string, input
 		if err := validateVaultPayload(input.Ciphertext, input.Nonce, input.WrappedDEK, input.WrapNonce, input.AlgoVersion, input.Metadata); err != nil {
 			return nil, err
 		}
+		if err := validateURIHMACs(input.URIHMACs); err != nil {
+			return nil, err
+		}
 		input.OwnerUserID = ownerUserID
 		validInputs = append(validInputs, input)
 	}
 
-	items, err := s.repo.CreateVaultItemsBulk(ctx, validInputs)
-	if err != nil {
-		return nil, fmt.Errorf("create vault items bulk: %w", err)
-	}
-
 	uid, _ := uuid.Parse(ownerUserID)
-	s.audit.LogEvent(ctx, &uid, domain.EventTypeVaultItemCreated, map[string]string{
-		"count": fmt.Sprintf("%d", len(items)),
-		"bulk":  "true",
+
+	var items []domain.VaultItem
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		items, err = s.repo.CreateVaultItemsBulk(ctx, validInputs)
+		if err != nil {
+			return fmt.Errorf("create vault items bulk: %w", err)
+		}
+		for i, item := range items {
+			if err := s.setURIHMACs(ctx, ownerUserID, item.ID, validInputs[i].URIHMACs); err != nil {
+				return err
+			}
+		}
+		return s.audit.Record(ctx, &uid, domain.EventTypeVaultItemCreated, map[string]string{
+			"count": fmt.Sprintf("%d", len(items)),
+			"bulk":  "true",
+		})
 	})
+	if err != nil {
+		return nil, err
+	}
 
 	return items, nil
 }
@@ -84,9 +136,171 @@ func (s *VaultService) ListItems(ctx context.Context, userID string) ([]domain.V
 	if err != nil {
 		return nil, fmt.Errorf("list vault items: %w", err)
 	}
+	s.anomalies.CountDownloads(ctx, ownerUserID, len(items))
 	return items, nil
 }
 
+const (
+	// defaultItemPageSize and maxItemPageSize bound ListItemPage's limit.
+	defaultItemPageSize = 50
+	maxItemPageSize     = 500
+)
+
+// ListItemPage returns one page of the user's active items and how many
+// match the query in all. A limit outside 1..maxItemPageSize gets the
+// default page size; an unknown sort or a folder ID that is not a UUID
+// fails with ErrInvalidItemQuery.
+func (s *VaultService) ListItemPage(ctx context.Context, userID string, query domain.VaultItemQuery) ([]domain.VaultItem, int, error) {
+	ownerUserID := strings.TrimSpace(userID)
+	if ownerUserID == "" {
+		return nil, 0, domain.ErrUnauthorizedSession
+	}
+	switch query.Sort {
+	case "":
+		query.Sort = domain.VaultItemSortUpdated
+	case domain.VaultItemSortUpdated, domain.VaultItemSortCreated:
+	default:
+		return nil, 0, domain.ErrInvalidItemQuery
+	}
+	if query.FolderID != "" {
+		if _, err := uuid.Parse(query.FolderID); err != nil {
+			return nil, 0, domain.ErrInvalidItemQuery
+		}
+	}
+	if query.Limit <= 0 || query.Limit > maxItemPageSize {
+		query.Limit = defaultItemPageSize
+	}
+	if query.Offset < 0 {
+		return nil, 0, domain.ErrInvalidItemQuery
+	}
+
+	items, total, err := s.repo.ListVaultItemPage(ctx, ownerUserID, query)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list vault item page: %w", err)
+	}
+	s.anomalies.CountDownloads(ctx, ownerUserID, len(items))
+	return items, total, nil
+}
+
+// VaultRevision returns an opaque tag that changes whenever the user's vault
+// does. Clients use it as an ETag to skip downloading an unchanged vault.
+func (s *VaultService) VaultRevision(ctx context.Context, userID string) (string, error) {
+	ownerUserID := strings.TrimSpace(userID)
+	if ownerUserID == "" {
+		return "", domain.ErrUnauthorizedSession
+	}
+
+	revision, err := s.repo.GetVaultRevision(ctx, ownerUserID)
+	if err != nil {
+		return "", fmt.Errorf("get vault revision: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(ownerUserID + ":" + revision))
+	return hex.EncodeToString(sum[:12]), nil
+}
+
+// Sync returns the user's active items and folders for a full client sync.
+// revision is the value VaultRevision returned for this request; it is
+// echoed in the snapshot so the client can send it back as If-None-Match.
+// The snapshot's cursor starts the client's next DeltaSync.
+func (s *VaultService) Sync(ctx context.Context, userID, revision string) (domain.VaultSnapshot, error) {
+	ownerUserID := strings.TrimSpace(userID)
+	if ownerUserID == "" {
+		return domain.VaultSnapshot{}, domain.ErrUnauthorizedSession
+	}
+
+	cursor := time.Now().UTC()
+	items, err := s.repo.ListVaultItemsByOwner(ctx, ownerUserID)
+	if err != nil {
+		return domain.VaultSnapshot{}, fmt.Errorf("list vault items: %w", err)
+	}
+	folders, err := s.folders.ListFoldersByOwner(ctx, ownerUserID)
+	if err != nil {
+		return domain.VaultSnapshot{}, fmt.Errorf("list folders: %w", err)
+	}
+	s.anomalies.CountDownloads(ctx, ownerUserID, len(items))
+	return domain.VaultSnapshot{Revision: revision, Items: items, Folders: folders, Cursor: cursor}, nil
+}
+
+// deltaSyncOverlap is how far before its cursor a delta sync looks. A
+// change committed just after a cursor was taken can carry an updated_at a
+// little before it; items sent twice are harmless to the client, missed ones
+// are not.
+const deltaSyncOverlap = time.Minute
+
+// DeltaSync returns what changed in the user's vault since the cursor of an
+// earlier snapshot: the items created or updated since, the IDs of those
+// trashed or purged since, and every folder. A cursor older than
+// domain.VaultTombstoneRetention may have lost purged items and fails with
+// ErrSyncCursorExpired; the client falls back to a full Sync.
+func (s *VaultService) DeltaSync(ctx context.Context, userID, revision string, since time.Time) (domain.VaultSnapshot, error) {
+	ownerUserID := strings.TrimSpace(userID)
+	if ownerUserID == "" {
+		return domain.VaultSnapshot{}, domain.ErrUnauthorizedSession
+	}
+	cursor := time.Now().UTC()
+	if since.IsZero() {
+		return domain.VaultSnapshot{}, domain.ErrInvalidSyncCursor
+	}
+	if since.Before(cursor.Add(-domain.VaultTombstoneRetention)) {
+		return domain.VaultSnapshot{}, domain.ErrSyncCursorExpired
+	}
+
+	items, deleted, err := s.repo.ListVaultItemChangesByOwner(ctx, ownerUserID, since.Add(-deltaSyncOverlap))
+	if err != nil {
+		return domain.VaultSnapshot{}, fmt.Errorf("list vault item changes: %w", err)
+	}
+	folders, err := s.folders.ListFoldersByOwner(ctx, ownerUserID)
+	if err != nil {
+		return domain.VaultSnapshot{}, fmt.Errorf("list folders: %w", err)
+	}
+	s.anomalies.CountDownloads(ctx, ownerUserID, len(items))
+	return domain.VaultSnapshot{Revision: revision, Items: items, Folders: folders, Cursor: cursor, DeletedItemIDs: deleted}, nil
+}
+
+// FullSync returns the snapshot served by the streamed full sync: folders,
+// then items, each ordered by ID so that a stream cut short can be resumed.
+// offset skips the records the client already received; a resumed sync
+// (offset above 0) must name the revision it started from, and fails with
+// ErrSyncRevisionChanged once the vault has moved on.
+func (s *VaultService) FullSync(ctx context.Context, userID, fromRevision string, offset int) (domain.VaultSnapshot, error) {
+	ownerUserID := strings.TrimSpace(userID)
+	if ownerUserID == "" {
+		return domain.VaultSnapshot{}, domain.ErrUnauthorizedSession
+	}
+	if offset < 0 {
+		return domain.VaultSnapshot{}, domain.ErrInvalidSyncOffset
+	}
+
+	revision, err := s.VaultRevision(ctx, ownerUserID)
+	if err != nil {
+		return domain.VaultSnapshot{}, err
+	}
+	if offset > 0 && revision != fromRevision {
+		return domain.VaultSnapshot{}, domain.ErrSyncRevisionChanged
+	}
+
+	items, err := s.repo.ListVaultItemsByOwner(ctx, ownerUserID)
+	if err != nil {
+		return domain.VaultSnapshot{}, fmt.Errorf("list vault items: %w", err)
+	}
+	folders, err := s.folders.ListFoldersByOwner(ctx, ownerUserID)
+	if err != nil {
+		return domain.VaultSnapshot{}, fmt.Errorf("list folders: %w", err)
+	}
+	if offset > len(folders)+len(items) {
+		return domain.VaultSnapshot{}, domain.ErrInvalidSyncOffset
+	}
+	sort.Slice(folders, func(i, j int) bool { return folders[i].ID < folders[j].ID })
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+
+	skipFolders := min(offset, len(folders))
+	folders = folders[skipFolders:]
+	items = items[offset-skipFolders:]
+	s.anomalies.CountDownloads(ctx, ownerUserID, len(items))
+	return domain.VaultSnapshot{Revision: revision, Items: items, Folders: folders}, nil
+}
+
 func (s *VaultService) ListDeletedItems(ctx context.Context, userID string) ([]domain.VaultItem, error) {
 	ownerUserID := strings.TrimSpace(userID)
 	if ownerUserID == "" {
@@ -117,6 +331,7 @@ func (s *VaultService) GetItem(ctx context.Context, userID string, itemID string
 		}
 		return domain.VaultItem{}, fmt.Errorf("get vault item: %w", err)
 	}
+	s.anomalies.CountDownloads(ctx, ownerUserID, 1)
 	return item, nil
 }
 
@@ -133,19 +348,32 @@ func (s *VaultService) UpdateItem(ctx context.Context, userID string, itemID str
 	if err := validateVaultPayload(input.Ciphertext, input.Nonce, input.WrappedDEK, input.WrapNonce, input.AlgoVersion, input.Metadata); err != nil {
 		return domain.VaultItem{}, err
 	}
-
-	item, err := s.repo.UpdateVaultItemForOwner(ctx, trimmedItemID, ownerUserID, input)
-	if err != nil {
-		if errors.Is(err, domain.ErrNotFound) {
-			return domain.VaultItem{}, domain.ErrNotFound
-		}
-		return domain.VaultItem{}, fmt.Errorf("update vault item: %w", err)
+	if err := validateURIHMACs(input.URIHMACs); err != nil {
+		return domain.VaultItem{}, err
 	}
 
 	uid, _ := uuid.Parse(ownerUserID)
-	s.audit.LogEvent(ctx, &uid, domain.EventTypeVaultItemUpdated, map[string]string{
-		"item_id": trimmedItemID,
+
+	var item domain.VaultItem
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		item, err = s.repo.UpdateVaultItemForOwner(ctx, trimmedItemID, ownerUserID, input)
+		if err != nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				return domain.ErrNotFound
+			}
+			return fmt.Errorf("update vault item: %w", err)
+		}
+		if err := s.setURIHMACs(ctx, ownerUserID, trimmedItemID, input.URIHMACs); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, &uid, domain.EventTypeVaultItemUpdated, map[string]string{
+			"item_id": trimmedItemID,
+		})
 	})
+	if err != nil {
+		return domain.VaultItem{}, err
+	}
 
 	return item, nil
 }
@@ -177,20 +405,20 @@ func (s *VaultService) DeleteItem(ctx context.Context, userID string, itemID str
 		return domain.ErrNotFound
 	}
 
-	deleted, err := s.repo.DeleteVaultItemForOwner(ctx, trimmedItemID, ownerUserID)
-	if err != nil {
-		return fmt.Errorf("delete vault item: %w", err)
-	}
-	if !deleted {
-		return domain.ErrNotFound
-	}
-
 	uid, _ := uuid.Parse(ownerUserID)
-	s.audit.LogEvent(ctx, &uid, domain.EventTypeVaultItemDeleted, map[string]string{
-		"item_id": trimmedItemID,
-	})
 
-	return nil
+	return s.withinTx(ctx, func(ctx context.Context) error {
+		deleted, err := s.repo.DeleteVaultItemForOwner(ctx, trimmedItemID, ownerUserID)
+		if err != nil {
+			return fmt.Errorf("delete vault item: %w", err)
+		}
+		if !deleted {
+			return domain.ErrNotFound
+		}
+		return s.audit.Record(ctx, &uid, domain.EventTypeVaultItemDeleted, map[string]string{
+			"item_id": trimmedItemID,
+		})
+	})
 }
 
 func (s *VaultService) RestoreItem(ctx context.Context, userID string, itemID string) (domain.VaultItem, error) {
@@ -203,22 +431,49 @@ func (s *VaultService) RestoreItem(ctx context.Context, userID string, itemID st
 		return domain.VaultItem{}, domain.ErrNotFound
 	}
 
-	item, err := s.repo.RestoreVaultItemForOwner(ctx, trimmedItemID, ownerUserID)
-	if err != nil {
-		if errors.Is(err, domain.ErrNotFound) {
-			return domain.VaultItem{}, domain.ErrNotFound
-		}
-		return domain.VaultItem{}, fmt.Errorf("restore vault item: %w", err)
-	}
-
 	uid, _ := uuid.Parse(ownerUserID)
-	s.audit.LogEvent(ctx, &uid, domain.EventTypeVaultItemRestored, map[string]string{
-		"item_id": trimmedItemID,
+
+	var item domain.VaultItem
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		item, err = s.repo.RestoreVaultItemForOwner(ctx, trimmedItemID, ownerUserID)
+		if err != nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				return domain.ErrNotFound
+			}
+			return fmt.Errorf("restore vault item: %w", err)
+		}
+		return s.audit.Record(ctx, &uid, domain.EventTypeVaultItemRestored, map[string]string{
+			"item_id": trimmedItemID,
+		})
 	})
+	if err != nil {
+		return domain.VaultItem{}, err
+	}
 
 	return item, nil
 }
 
+// PurgeTrash permanently deletes items that have sat in the trash for longer
+// than retention.
+func (s *VaultService) PurgeTrash(ctx context.Context, retention time.Duration) (int64, error) {
+	purged, err := s.repo.PurgeDeletedVaultItems(ctx, time.Now().UTC().Add(-retention))
+	if err != nil {
+		return 0, fmt.Errorf("purge trash: %w", err)
+	}
+	return purged, nil
+}
+
+// PurgeTombstones deletes the records of purged items that are older than
+// any cursor DeltaSync still accepts.
+func (s *VaultService) PurgeTombstones(ctx context.Context) (int64, error) {
+	deleted, err := s.repo.DeleteVaultItemTombstones(ctx, time.Now().UTC().Add(-domain.VaultTombstoneRetention))
+	if err != nil {
+		return 0, fmt.Errorf("purge vault item tombstones: %w", err)
+	}
+	return deleted, nil
+}
+
 func (s *VaultService) GetVaultSalt(ctx context.Context, userID string) ([]byte, error) {
 	ownerUserID := strings.TrimSpace(userID)
 	if ownerUserID == "" {
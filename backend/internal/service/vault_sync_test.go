@@ -0,0 +1,97 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/repository"
+	"pmv2/backend/internal/service"
+)
+
+func TestVaultDeltaSync(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore(repository.NewMemoryDB())
+	auth := service.NewAuthService(store.Auth(), store.Transactor(), nil, "pepper", time.Hour, "pmv2")
+	vault := service.NewVaultService(store.Vault(), store.Folders(), store.Transactor(), nil)
+
+	user, err := auth.Register(ctx, "ada@example.com", "Correct-Horse-9", "Ada")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	payload := domain.CreateVaultItemInput{
+		Ciphertext: []byte("c"), Nonce: []byte("n"), WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"), AlgoVersion: "xchacha20poly1305-v1",
+	}
+	create := func() domain.VaultItem {
+		t.Helper()
+		item, err := vault.CreateItem(ctx, user.UserID, payload)
+		if err != nil {
+			t.Fatalf("CreateItem: %v", err)
+		}
+		return item
+	}
+	updated, trashed, purged := create(), create(), create()
+
+	full, err := vault.Sync(ctx, user.UserID, "rev")
+	if err != nil || len(full.Items) != 3 || full.Cursor.IsZero() {
+		t.Fatalf("Sync = %+v, %v", full, err)
+	}
+
+	if _, err := vault.UpdateItem(ctx, user.UserID, updated.ID, domain.UpdateVaultItemInput{
+		Ciphertext: []byte("c2"), Nonce: []byte("n"), WrappedDEK: []byte("dek"), WrapNonce: []byte("wn"), AlgoVersion: "xchacha20poly1305-v1",
+	}); err != nil {
+		t.Fatalf("UpdateItem: %v", err)
+	}
+	created := create()
+	for _, id := range []string{trashed.ID, purged.ID} {
+		if err := vault.DeleteItem(ctx, user.UserID, id); err != nil {
+			t.Fatalf("DeleteItem: %v", err)
+		}
+	}
+	if _, err := store.Vault().PurgeDeletedVaultItems(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if _, err := vault.RestoreItem(ctx, user.UserID, trashed.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("RestoreItem of a purged item: err = %v", err)
+	}
+
+	delta, err := vault.DeltaSync(ctx, user.UserID, "rev2", full.Cursor)
+	if err != nil {
+		t.Fatalf("DeltaSync: %v", err)
+	}
+	got := make(map[string]string)
+	for _, item := range delta.Items {
+		got[item.ID] = string(item.Ciphertext)
+	}
+	if got[updated.ID] != "c2" || got[created.ID] != "c" {
+		t.Fatalf("delta items = %v, want the update and the new item", got)
+	}
+	deleted := make(map[string]bool)
+	for _, id := range delta.DeletedItemIDs {
+		deleted[id] = true
+		if _, ok := got[id]; ok {
+			t.Fatalf("item %s is both changed and deleted", id)
+		}
+	}
+	if !deleted[trashed.ID] || !deleted[purged.ID] {
+		t.Fatalf("deleted = %v, want the purged items", delta.DeletedItemIDs)
+	}
+	if delta.Revision != "rev2" || delta.Cursor.Before(full.Cursor) {
+		t.Fatalf("delta = %+v, want a later cursor", delta)
+	}
+
+	if _, err := vault.DeltaSync(ctx, user.UserID, "rev", time.Time{}); !errors.Is(err, domain.ErrInvalidSyncCursor) {
+		t.Fatalf("DeltaSync without a cursor: err = %v", err)
+	}
+	stale := time.Now().Add(-domain.VaultTombstoneRetention - time.Hour)
+	if _, err := vault.DeltaSync(ctx, user.UserID, "rev", stale); !errors.Is(err, domain.ErrSyncCursorExpired) {
+		t.Fatalf("DeltaSync from an expired cursor: err = %v", err)
+	}
+
+	// Tombstones outlive the trash for as long as cursors are accepted.
+	if n, err := vault.PurgeTombstones(ctx); err != nil || n != 0 {
+		t.Fatalf("PurgeTombstones = %d, %v; want the fresh ones kept", n, err)
+	}
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/safehttp"
+	"pmv2/backend/internal/util"
+)
+
+// maxWebhooksPerUser bounds the fan-out of a single event.
+const maxWebhooksPerUser = 10
+
+type WebhookService struct {
+	repo          domain.WebhookRepository
+	audit         *AuditService
+	notifications *NotificationService
+	secretKey     []byte
+	allowHTTP     bool
+	allowPrivate  bool
+}
+
+// NewWebhookService builds the service. Signing secrets are stored
+// encrypted with secretKey. allowHTTP permits plain http:// endpoint URLs
+// and allowPrivate endpoints on loopback or private addresses; both are only
+// meant for local development.
+func NewWebhookService(repo domain.WebhookRepository, audit *AuditService, notifications *NotificationService, secretKey []byte, allowHTTP, allowPrivate bool) *WebhookService {
+	return &WebhookService{repo: repo, audit: audit, notifications: notifications, secretKey: secretKey, allowHTTP: allowHTTP, allowPrivate: allowPrivate}
+}
+
+// CreateEndpoint registers a new endpoint and generates its signing secret.
+// The returned endpoint is the only time the secret is shown to the user.
+func (s *WebhookService) CreateEndpoint(ctx context.Context, userID string, rawURL string, eventTypes []string) (domain.WebhookEndpoint, error) {
+	endpointURL, err := s.validateURL(ctx, rawURL)
+	if err != nil {
+		return domain.WebhookEndpoint{}, err
+	}
+
+	types := make([]domain.EventType, 0, len(eventTypes))
+	for _, t := range eventTypes {
+		t = strings.TrimSpace(t)
+		if !isEventTypeName(t) {
+			return domain.WebhookEndpoint{}, domain.ErrInvalidWebhookEvent
+		}
+		types = append(types, domain.EventType(t))
+	}
+
+	count, err := s.repo.CountEndpoints(ctx, userID)
+	if err != nil {
+		return domain.WebhookEndpoint{}, fmt.Errorf("count webhooks: %w", err)
+	}
+	if count >= maxWebhooksPerUser {
+		return domain.WebhookEndpoint{}, domain.ErrWebhookLimitReached
+	}
+
+	token, err := util.NewOpaqueToken(32)
+	if err != nil {
+		return domain.WebhookEndpoint{}, err
+	}
+	secret := "whsec_" + token
+	secretEnc, err := util.EncryptWebhookSecret(secret, s.secretKey)
+	if err != nil {
+		return domain.WebhookEndpoint{}, fmt.Errorf("encrypt webhook secret: %w", err)
+	}
+
+	endpoint, err := s.repo.CreateEndpoint(ctx, domain.WebhookEndpoint{
+		ID:         uuid.NewString(),
+		UserID:     userID,
+		URL:        endpointURL,
+		SecretEnc:  secretEnc,
+		EventTypes: types,
+		Enabled:    true,
+	})
+	if err != nil {
+		return domain.WebhookEndpoint{}, fmt.Errorf("create webhook: %w", err)
+	}
+	endpoint.Secret = secret
+
+	uid, _ := uuid.Parse(userID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeWebhookCreated, map[string]interface{}{
+		"webhook_id":  endpoint.ID,
+		"url":         endpoint.URL,
+		"event_types": endpoint.EventTypes,
+	})
+	return endpoint, nil
+}
+
+func (s *WebhookService) ListEndpoints(ctx context.Context, userID string) ([]domain.WebhookEndpoint, error) {
+	endpoints, err := s.repo.ListEndpoints(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list webhooks: %w", err)
+	}
+	return endpoints, nil
+}
+
+func (s *WebhookService) DeleteEndpoint(ctx context.Context, userID, endpointID string) error {
+	if _, err := uuid.Parse(endpointID); err != nil {
+		return domain.ErrNotFound
+	}
+	if err := s.repo.DeleteEndpoint(ctx, userID, endpointID); err != nil {
+		return err
+	}
+
+	uid, _ := uuid.Parse(userID)
+	s.audit.LogEvent(ctx, &uid, domain.EventTypeWebhookDeleted, map[string]interface{}{
+		"webhook_id": endpointID,
+	})
+	return nil
+}
+
+// ListDeliveries returns the delivery log of one of the user's endpoints.
+func (s *WebhookService) ListDeliveries(ctx context.Context, userID, endpointID string, limit, offset int) ([]domain.WebhookDelivery, int, error) {
+	if _, err := s.ownedEndpoint(ctx, userID, endpointID); err != nil {
+		return nil, 0, err
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	deliveries, total, err := s.repo.ListDeliveries(ctx, endpointID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list webhook deliveries: %w", err)
+	}
+	return deliveries, total, nil
+}
+
+// Redeliver re-queues a dead-lettered (or already delivered) delivery.
+func (s *WebhookService) Redeliver(ctx context.Context, userID, endpointID, deliveryID string) error {
+	if _, err := s.ownedEndpoint(ctx, userID, endpointID); err != nil {
+		return err
+	}
+	if _, err := uuid.Parse(deliveryID); err != nil {
+		return domain.ErrNotFound
+	}
+	return s.repo.Redeliver(ctx, endpointID, deliveryID)
+}
+
+// webhookPayload is the JSON body POSTed to endpoints.
+type webhookPayload struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt string          `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// HandleAuditEvent queues a delivery of event to each matching endpoint of
+// the user it concerns. Events that belong to a notification category are
+// skipped when the user turned off webhooks for it. It runs from the outbox
+// dispatcher after the event is stored; enqueueing is idempotent so a
+// redelivered event is harmless.
+func (s *WebhookService) HandleAuditEvent(ctx context.Context, event domain.AuditEvent) error {
+	if event.UserID == nil {
+		return nil
+	}
+	if category, ok := domain.NotificationCategoryForEvent(event.EventType); ok && s.notifications != nil &&
+		!s.notifications.Allows(ctx, event.UserID.String(), category, domain.NotificationChannelWebhook) {
+		return nil
+	}
+
+	endpoints, err := s.repo.ListEndpointsForEvent(ctx, event.UserID.String(), event.EventType)
+	if err != nil {
+		return fmt.Errorf("list webhooks for event: %w", err)
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	data := event.EventData
+	if len(data) == 0 {
+		data = json.RawMessage("{}")
+	}
+	payload, err := json.Marshal(webhookPayload{
+		ID:        event.ID.String(),
+		Type:      string(event.EventType),
+		CreatedAt: event.CreatedAt.UTC().Format(time.RFC3339),
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal webhook payload: %w", err)
+	}
+
+	for _, endpoint := range endpoints {
+		if err := s.repo.EnqueueDelivery(ctx, domain.WebhookDelivery{
+			EndpointID: endpoint.ID,
+			EventID:    event.ID.String(),
+			EventType:  event.EventType,
+			Payload:    payload,
+		}); err != nil {
+			return fmt.Errorf("enqueue webhook delivery: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *WebhookService) ownedEndpoint(ctx context.Context, userID, endpointID string) (domain.WebhookEndpoint, error) {
+	if _, err := uuid.Parse(endpointID); err != nil {
+		return domain.WebhookEndpoint{}, domain.ErrNotFound
+	}
+	return s.repo.GetEndpoint(ctx, userID, endpointID)
+}
+
+// validateURL normalises raw and rejects URLs that are not HTTPS or whose
+// host resolves to a non-public address. The deliverer checks the address
+// again when it connects, in case DNS changes in between.
+func (s *WebhookService) validateURL(ctx context.Context, raw string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || parsed.Hostname() == "" || parsed.User != nil {
+		return "", domain.ErrInvalidWebhookURL
+	}
+	switch parsed.Scheme {
+	case "https":
+	case "http":
+		if !s.allowHTTP {
+			return "", domain.ErrInvalidWebhookURL
+		}
+	default:
+		return "", domain.ErrInvalidWebhookURL
+	}
+	if !s.allowPrivate {
+		if err := safehttp.CheckHost(ctx, parsed.Hostname()); err != nil {
+			return "", domain.ErrInvalidWebhookURL
+		}
+	}
+	parsed.Fragment = ""
+	return parsed.String(), nil
+}
+
+func isEventTypeName(t string) bool {
+	if t == "" || len(t) > 64 {
+		return false
+	}
+	for _, r := range t {
+		if (r < 'a' || r > 'z') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,141 @@
+// Package siem ships audit events to an external SIEM.
+//
+// The exporter tails audit_events in insertion order and keeps its position
+// in audit_export_cursors, so after downtime (of the API or the collector)
+// it resumes where it stopped and backfills everything it missed. Every
+// replica runs an exporter, but each batch holds a lock on the cursor row,
+// so only one of them ships a given batch.
+package siem
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+// Sink delivers a batch of events. A returned error means none of the batch
+// should be considered delivered; it will be sent again.
+type Sink interface {
+	Send(ctx context.Context, events []domain.AuditEvent) error
+	Close() error
+}
+
+// EventSource is implemented by every domain.AuditRepository.
+type EventSource interface {
+	ListEventsAfter(ctx context.Context, cursor domain.AuditCursor, settle time.Duration, limit int) ([]domain.AuditEvent, error)
+	// AdvanceExportCursor runs fn while holding the exporter's cursor lock
+	// and saves the cursor fn returns. locked is false, and fn is not
+	// called, when another replica holds the lock.
+	AdvanceExportCursor(ctx context.Context, exporter string, fn func(domain.AuditCursor) (domain.AuditCursor, error)) (locked bool, err error)
+}
+
+type Config struct {
+	// Name identifies the cursor; use a distinct name per destination.
+	Name          string
+	BatchSize     int
+	FlushInterval time.Duration
+	// Settle holds back very recent rows so concurrent inserts can commit.
+	Settle time.Duration
+}
+
+type Exporter struct {
+	source EventSource
+	sink   Sink
+	cfg    Config
+	logger *slog.Logger
+}
+
+func NewExporter(source EventSource, sink Sink, logger *slog.Logger, cfg Config) *Exporter {
+	if cfg.Name == "" {
+		cfg.Name = "siem"
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	if cfg.Settle <= 0 {
+		cfg.Settle = 2 * time.Second
+	}
+	return &Exporter{source: source, sink: sink, cfg: cfg, logger: logger}
+}
+
+// Run exports until ctx is cancelled. Failed batches are retried with
+// exponential backoff; the cursor only advances after a successful send.
+func (e *Exporter) Run(ctx context.Context) {
+	defer e.sink.Close()
+
+	failures := 0
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		sent, err := e.exportBatch(ctx)
+		wait := e.cfg.FlushInterval
+		switch {
+		case err != nil:
+			failures++
+			wait = retryDelay(failures)
+			e.logger.Warn("siem export failed",
+				slog.String("exporter", e.cfg.Name),
+				slog.Int("consecutive_failures", failures),
+				slog.Duration("retry_in", wait),
+				slog.Any("error", err),
+			)
+		case sent == e.cfg.BatchSize:
+			// Backlog (e.g. after downtime): keep going without waiting.
+			failures = 0
+			wait = 0
+		default:
+			failures = 0
+		}
+		timer.Reset(wait)
+	}
+}
+
+// exportBatch ships the next batch if this replica wins the cursor lock. It
+// returns how many events were sent.
+func (e *Exporter) exportBatch(ctx context.Context) (int, error) {
+	sent := 0
+	// The cursor update must not be lost to shutdown once the batch is sent.
+	_, err := e.source.AdvanceExportCursor(context.WithoutCancel(ctx), e.cfg.Name, func(cursor domain.AuditCursor) (domain.AuditCursor, error) {
+		events, err := e.source.ListEventsAfter(ctx, cursor, e.cfg.Settle, e.cfg.BatchSize)
+		if err != nil || len(events) == 0 {
+			return cursor, err
+		}
+
+		sendCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		if err := e.sink.Send(sendCtx, events); err != nil {
+			return cursor, err
+		}
+
+		sent = len(events)
+		last := events[len(events)-1]
+		return domain.AuditCursor{RecordedAt: last.RecordedAt, EventID: last.ID}, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return sent, nil
+}
+
+func retryDelay(failures int) time.Duration {
+	if failures > 8 {
+		return 5 * time.Minute
+	}
+	d := time.Duration(1<<failures) * time.Second
+	if d > 5*time.Minute {
+		return 5 * time.Minute
+	}
+	return d
+}
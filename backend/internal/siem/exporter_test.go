@@ -0,0 +1,137 @@
+package siem
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+)
+
+// fakeSource holds events and a cursor behind a try-lock, like the
+// FOR UPDATE SKIP LOCKED row in audit_export_cursors.
+type fakeSource struct {
+	lock   sync.Mutex
+	mu     sync.Mutex
+	events []domain.AuditEvent
+	cursor domain.AuditCursor
+}
+
+func newFakeSource(n int) *fakeSource {
+	s := &fakeSource{}
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range n {
+		s.events = append(s.events, domain.AuditEvent{ID: uuid.New(), RecordedAt: base.Add(time.Duration(i) * time.Second)})
+	}
+	return s
+}
+
+func (s *fakeSource) ListEventsAfter(ctx context.Context, cursor domain.AuditCursor, settle time.Duration, limit int) ([]domain.AuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []domain.AuditEvent
+	for _, e := range s.events {
+		if e.RecordedAt.After(cursor.RecordedAt) && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (s *fakeSource) AdvanceExportCursor(ctx context.Context, exporter string, fn func(domain.AuditCursor) (domain.AuditCursor, error)) (bool, error) {
+	if !s.lock.TryLock() {
+		return false, nil
+	}
+	defer s.lock.Unlock()
+
+	next, err := fn(s.cursor)
+	if err != nil {
+		return true, err
+	}
+	s.cursor = next
+	return true, nil
+}
+
+type recordingSink struct {
+	mu   sync.Mutex
+	sent map[uuid.UUID]int
+	fail bool
+	slow time.Duration
+}
+
+func (s *recordingSink) Send(ctx context.Context, events []domain.AuditEvent) error {
+	time.Sleep(s.slow)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errors.New("collector down")
+	}
+	for _, e := range events {
+		s.sent[e.ID]++
+	}
+	return nil
+}
+
+func (s *recordingSink) Close() error { return nil }
+
+func newTestExporter(source EventSource, sink Sink) *Exporter {
+	return NewExporter(source, sink, slog.New(slog.NewTextHandler(io.Discard, nil)), Config{BatchSize: 10})
+}
+
+func TestReplicasExportEachEventOnce(t *testing.T) {
+	source := newFakeSource(95)
+	sink := &recordingSink{sent: make(map[uuid.UUID]int), slow: time.Millisecond}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			exporter := newTestExporter(source, sink)
+			for range 50 {
+				if _, err := exporter.exportBatch(context.Background()); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(sink.sent) != 95 {
+		t.Fatalf("exported %d distinct events, want 95", len(sink.sent))
+	}
+	for id, n := range sink.sent {
+		if n != 1 {
+			t.Fatalf("event %s sent %d times", id, n)
+		}
+	}
+}
+
+func TestFailedSendKeepsCursor(t *testing.T) {
+	source := newFakeSource(3)
+	sink := &recordingSink{sent: make(map[uuid.UUID]int), fail: true}
+	exporter := newTestExporter(source, sink)
+
+	if _, err := exporter.exportBatch(context.Background()); err == nil {
+		t.Fatal("exportBatch succeeded with a failing sink")
+	}
+	if !source.cursor.RecordedAt.IsZero() {
+		t.Fatalf("cursor advanced to %v after a failed send", source.cursor)
+	}
+
+	sink.fail = false
+	n, err := exporter.exportBatch(context.Background())
+	if err != nil || n != 3 {
+		t.Fatalf("retry: sent %d, err %v", n, err)
+	}
+	if source.cursor.EventID != source.events[2].ID {
+		t.Fatalf("cursor = %v, want the last event", source.cursor)
+	}
+}
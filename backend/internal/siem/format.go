@@ -0,0 +1,106 @@
+package siem
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+const (
+	vendor  = "PMV2"
+	product = "PasswordManager"
+	version = "2"
+)
+
+// severity maps an event onto the 0-10 CEF scale (LEEF reuses it as sev).
+func severity(eventType domain.EventType) int {
+	t := string(eventType)
+	switch {
+	case strings.HasPrefix(t, "security_"):
+		return 8
+	case strings.HasPrefix(t, "admin_"):
+		return 6
+	case strings.Contains(t, "failed"), strings.Contains(t, "disabled"), strings.Contains(t, "revoked"), strings.Contains(t, "reset"):
+		return 5
+	default:
+		return 3
+	}
+}
+
+// FormatCEF renders an event as an ArcSight Common Event Format line.
+func FormatCEF(e domain.AuditEvent) string {
+	ext := []string{
+		"rt=" + fmt.Sprint(e.CreatedAt.UnixMilli()),
+		"externalId=" + cefExtEscape(e.ID.String()),
+	}
+	if e.UserID != nil {
+		ext = append(ext, "suid="+cefExtEscape(e.UserID.String()))
+	}
+	if e.ActorUserID != nil {
+		ext = append(ext, "cs1Label=actorUserId", "cs1="+cefExtEscape(e.ActorUserID.String()))
+	}
+	if e.IPAddress != "" {
+		ext = append(ext, "src="+cefExtEscape(e.IPAddress))
+	}
+	if e.UserAgent != "" {
+		ext = append(ext, "requestClientApplication="+cefExtEscape(e.UserAgent))
+	}
+	if len(e.EventData) > 0 {
+		ext = append(ext, "msg="+cefExtEscape(string(e.EventData)))
+	}
+
+	return fmt.Sprintf("CEF:0|%s|%s|%s|%s|%s|%d|%s",
+		cefHeaderEscape(vendor),
+		cefHeaderEscape(product),
+		cefHeaderEscape(version),
+		cefHeaderEscape(string(e.EventType)),
+		cefHeaderEscape(strings.ReplaceAll(string(e.EventType), "_", " ")),
+		severity(e.EventType),
+		strings.Join(ext, " "),
+	)
+}
+
+// FormatLEEF renders an event as an IBM QRadar LEEF 2.0 line.
+func FormatLEEF(e domain.AuditEvent) string {
+	attrs := []string{
+		"devTime=" + leefEscape(e.CreatedAt.UTC().Format("Jan 02 2006 15:04:05.000 MST")),
+		"devTimeFormat=MMM dd yyyy HH:mm:ss.SSS z",
+		"sev=" + fmt.Sprint(severity(e.EventType)),
+		"eventId=" + leefEscape(e.ID.String()),
+	}
+	if e.UserID != nil {
+		attrs = append(attrs, "usrName="+leefEscape(e.UserID.String()))
+	}
+	if e.ActorUserID != nil {
+		attrs = append(attrs, "actorUserId="+leefEscape(e.ActorUserID.String()))
+	}
+	if e.IPAddress != "" {
+		attrs = append(attrs, "src="+leefEscape(e.IPAddress))
+	}
+	if e.UserAgent != "" {
+		attrs = append(attrs, "userAgent="+leefEscape(e.UserAgent))
+	}
+	if len(e.EventData) > 0 {
+		attrs = append(attrs, "eventData="+leefEscape(string(e.EventData)))
+	}
+
+	return fmt.Sprintf("LEEF:2.0|%s|%s|%s|%s|%s",
+		vendor, product, version, string(e.EventType), strings.Join(attrs, "\t"))
+}
+
+var (
+	cefHeaderReplacer = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtReplacer    = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+	leefReplacer      = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+)
+
+func cefHeaderEscape(s string) string { return cefHeaderReplacer.Replace(s) }
+func cefExtEscape(s string) string    { return cefExtReplacer.Replace(s) }
+func leefEscape(s string) string      { return leefReplacer.Replace(s) }
+
+// rfc5424Timestamp is the syslog header timestamp layout.
+func rfc5424Timestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z07:00")
+}
@@ -0,0 +1,100 @@
+package siem
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pmv2/backend/internal/domain"
+)
+
+func testEvent() domain.AuditEvent {
+	user := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	return domain.AuditEvent{
+		ID:        uuid.MustParse("22222222-2222-2222-2222-222222222222"),
+		UserID:    &user,
+		EventType: domain.EventTypeAuthLoginFailed,
+		EventData: json.RawMessage(`{"reason":"a=b|c"}`),
+		IPAddress: "203.0.113.7",
+		UserAgent: "curl/8.0\nInjected: header",
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func TestFormatCEF(t *testing.T) {
+	line := FormatCEF(testEvent())
+
+	wantPrefix := "CEF:0|PMV2|PasswordManager|2|auth_login_failed|auth login failed|5|"
+	if !strings.HasPrefix(line, wantPrefix) {
+		t.Fatalf("header = %q, want prefix %q", line, wantPrefix)
+	}
+	for _, want := range []string{
+		"rt=1767323045000",
+		"externalId=22222222-2222-2222-2222-222222222222",
+		"suid=11111111-1111-1111-1111-111111111111",
+		"src=203.0.113.7",
+		`requestClientApplication=curl/8.0\nInjected: header`,
+		`msg={"reason":"a\=b|c"}`,
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("missing %q in %q", want, line)
+		}
+	}
+	if strings.ContainsAny(line, "\r\n") {
+		t.Errorf("line breaks must be escaped: %q", line)
+	}
+}
+
+func TestCEFHeaderEscaping(t *testing.T) {
+	e := testEvent()
+	e.EventType = `odd|type\`
+	line := FormatCEF(e)
+	if !strings.Contains(line, `|odd\|type\\|`) {
+		t.Fatalf("header not escaped: %q", line)
+	}
+}
+
+func TestFormatLEEF(t *testing.T) {
+	line := FormatLEEF(testEvent())
+
+	wantPrefix := "LEEF:2.0|PMV2|PasswordManager|2|auth_login_failed|"
+	if !strings.HasPrefix(line, wantPrefix) {
+		t.Fatalf("header = %q, want prefix %q", line, wantPrefix)
+	}
+	attrs := strings.Split(strings.TrimPrefix(line, wantPrefix), "\t")
+	want := map[string]string{
+		"devTime":   "Jan 02 2026 03:04:05.000 UTC",
+		"sev":       "5",
+		"eventId":   "22222222-2222-2222-2222-222222222222",
+		"usrName":   "11111111-1111-1111-1111-111111111111",
+		"src":       "203.0.113.7",
+		"userAgent": "curl/8.0 Injected: header",
+	}
+	got := make(map[string]string)
+	for _, attr := range attrs {
+		key, value, _ := strings.Cut(attr, "=")
+		got[key] = value
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s = %q, want %q", key, got[key], value)
+		}
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	cases := map[domain.EventType]int{
+		domain.EventTypeAuthLoginSuccess:         3,
+		domain.EventTypeAuthLoginFailed:          5,
+		"admin_audit_queried":                    6,
+		domain.EventTypeSecurityImpossibleTravel: 8,
+	}
+	for eventType, want := range cases {
+		if got := severity(eventType); got != want {
+			t.Errorf("severity(%s) = %d, want %d", eventType, got, want)
+		}
+	}
+}
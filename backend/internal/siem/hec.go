@@ -0,0 +1,88 @@
+package siem
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+// HECSink posts batches to a Splunk HTTP Event Collector.
+type HECSink struct {
+	url      string
+	token    string
+	hostname string
+	client   *http.Client
+}
+
+// NewHECSink takes the collector base URL (e.g. https://splunk:8088); the
+// /services/collector/event path is appended when missing.
+func NewHECSink(baseURL, token string) *HECSink {
+	url := strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if !strings.HasSuffix(url, "/services/collector/event") {
+		url += "/services/collector/event"
+	}
+	hostname, _ := os.Hostname()
+	return &HECSink{
+		url:      url,
+		token:    token,
+		hostname: hostname,
+		client:   &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+type hecEvent struct {
+	Time       float64           `json:"time"`
+	Host       string            `json:"host,omitempty"`
+	Source     string            `json:"source"`
+	SourceType string            `json:"sourcetype"`
+	Event      domain.AuditEvent `json:"event"`
+}
+
+func (s *HECSink) Send(ctx context.Context, events []domain.AuditEvent) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, e := range events {
+		if err := enc.Encode(hecEvent{
+			Time:       float64(e.CreatedAt.UnixMilli()) / 1000,
+			Host:       s.hostname,
+			Source:     "pmv2",
+			SourceType: "pmv2:audit",
+			Event:      e,
+		}); err != nil {
+			return fmt.Errorf("encode hec event: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, &body)
+	if err != nil {
+		return fmt.Errorf("build hec request: %w", err)
+	}
+	req.Header.Set("Authorization", "Splunk "+s.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post hec events: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("hec responded %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func (s *HECSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,112 @@
+package siem
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+func TestHECSinkPostsNewlineDelimitedEvents(t *testing.T) {
+	var got []hecEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/services/collector/event" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Splunk secret-token" {
+			t.Errorf("Authorization = %q", auth)
+		}
+		dec := json.NewDecoder(r.Body)
+		for dec.More() {
+			var e hecEvent
+			if err := dec.Decode(&e); err != nil {
+				t.Errorf("decode: %v", err)
+				return
+			}
+			got = append(got, e)
+		}
+	}))
+	defer srv.Close()
+
+	sink := NewHECSink(srv.URL+"/", "secret-token")
+	defer sink.Close()
+	events := []domain.AuditEvent{testEvent(), testEvent()}
+	if err := sink.Send(context.Background(), events); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("collector got %d events, want 2", len(got))
+	}
+	if got[0].SourceType != "pmv2:audit" || got[0].Event.ID != events[0].ID || got[0].Time != 1767323045 {
+		t.Errorf("event = %+v", got[0])
+	}
+}
+
+func TestHECSinkReportsRejectedBatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"text":"Invalid token"}`, http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	err := NewHECSink(srv.URL, "bad").Send(context.Background(), []domain.AuditEvent{testEvent()})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("Send = %v, want a 403 error", err)
+	}
+}
+
+func TestSyslogSinkWritesRFC5424OverTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	lines := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	sink, err := NewSyslogSink("tcp", ln.Addr().String(), "leef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if err := sink.Send(context.Background(), []domain.AuditEvent{testEvent(), testEvent()}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	for range 2 {
+		select {
+		case line := <-lines:
+			if !strings.HasPrefix(line, "<86>1 2026-01-02T03:04:05.000Z ") || !strings.Contains(line, " pmv2 - audit - LEEF:2.0|") {
+				t.Errorf("line = %q", line)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("syslog line not received")
+		}
+	}
+}
+
+func TestNewSyslogSinkRejectsUnknownOptions(t *testing.T) {
+	if _, err := NewSyslogSink("unix", "/dev/log", "cef"); err == nil {
+		t.Error("unix network accepted")
+	}
+	if _, err := NewSyslogSink("udp", "127.0.0.1:514", "json"); err == nil {
+		t.Error("json format accepted")
+	}
+}
@@ -0,0 +1,93 @@
+package siem
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"pmv2/backend/internal/domain"
+)
+
+// syslogPriority is facility authpriv (10) with severity info (6).
+const syslogPriority = 10*8 + 6
+
+// SyslogSink writes one RFC 5424 message per event over UDP or TCP. TCP
+// messages are newline-framed. The connection is (re)opened lazily.
+type SyslogSink struct {
+	network  string
+	addr     string
+	format   func(domain.AuditEvent) string
+	hostname string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogSink accepts format "cef" or "leef".
+func NewSyslogSink(network, addr, format string) (*SyslogSink, error) {
+	network = strings.ToLower(strings.TrimSpace(network))
+	if network != "udp" && network != "tcp" {
+		return nil, fmt.Errorf("unsupported syslog network %q", network)
+	}
+
+	var formatter func(domain.AuditEvent) string
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "cef":
+		formatter = FormatCEF
+	case "leef":
+		formatter = FormatLEEF
+	default:
+		return nil, fmt.Errorf("unsupported syslog format %q", format)
+	}
+
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+	return &SyslogSink{network: network, addr: addr, format: formatter, hostname: hostname}, nil
+}
+
+func (s *SyslogSink) Send(ctx context.Context, events []domain.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, s.network, s.addr)
+		if err != nil {
+			return fmt.Errorf("dial syslog: %w", err)
+		}
+		s.conn = conn
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = s.conn.SetWriteDeadline(deadline)
+	} else {
+		_ = s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	}
+
+	for _, e := range events {
+		line := fmt.Sprintf("<%d>1 %s %s pmv2 - audit - %s\n", syslogPriority, rfc5424Timestamp(e.CreatedAt), s.hostname, s.format(e))
+		if _, err := s.conn.Write([]byte(line)); err != nil {
+			_ = s.conn.Close()
+			s.conn = nil
+			return fmt.Errorf("write syslog: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,96 @@
+// Package telemetry sends the opt-in anonymous usage report, so maintainers
+// can see which versions and features self-hosted instances run. Report is
+// the whole payload: coarse aggregates only, never host names, addresses,
+// emails or IDs of any kind.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Report is everything an instance sends.
+type Report struct {
+	Version   string   `json:"version"`
+	GoVersion string   `json:"go_version"`
+	OS        string   `json:"os"`
+	Arch      string   `json:"arch"`
+	Database  string   `json:"database"`
+	Users     string   `json:"users"` // a UserBucket, never the exact count
+	Features  []string `json:"features"`
+}
+
+// UserBucket rounds a user count to an order of magnitude, so a report
+// says roughly how big an instance is and no more.
+func UserBucket(n int) string {
+	switch {
+	case n <= 0:
+		return "0"
+	case n <= 10:
+		return "1-10"
+	case n <= 100:
+		return "11-100"
+	case n <= 1000:
+		return "101-1000"
+	case n <= 10000:
+		return "1001-10000"
+	default:
+		return "10000+"
+	}
+}
+
+// Collector builds the report to send.
+type Collector func(ctx context.Context) (Report, error)
+
+// Reporter posts reports to the configured endpoint.
+type Reporter struct {
+	endpoint string
+	collect  Collector
+	client   *http.Client
+}
+
+func NewReporter(endpoint string, collect Collector) *Reporter {
+	return &Reporter{
+		endpoint: endpoint,
+		collect:  collect,
+		client:   &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Send collects a report and posts it as JSON. Any non-2xx answer is an
+// error; nothing is retried before the next scheduled report.
+func (r *Reporter) Send(ctx context.Context) error {
+	report, err := r.collect(ctx)
+	if err != nil {
+		return fmt.Errorf("collect telemetry: %w", err)
+	}
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("encode telemetry: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build telemetry request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post telemetry: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("telemetry endpoint responded %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,53 @@
+package telemetry_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pmv2/backend/internal/telemetry"
+)
+
+func TestUserBucket(t *testing.T) {
+	for n, want := range map[int]string{0: "0", 1: "1-10", 10: "1-10", 11: "11-100", 1000: "101-1000", 10001: "10000+"} {
+		if got := telemetry.UserBucket(n); got != want {
+			t.Errorf("UserBucket(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestReporterPostsReport(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("request = %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	reporter := telemetry.NewReporter(srv.URL, func(ctx context.Context) (telemetry.Report, error) {
+		return telemetry.Report{Version: "v1.2.0", Database: "sqlite", Users: telemetry.UserBucket(42), Features: []string{"totp"}}, nil
+	})
+	if err := reporter.Send(context.Background()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got["version"] != "v1.2.0" || got["users"] != "11-100" || got["database"] != "sqlite" {
+		t.Fatalf("report = %v", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	if err := telemetry.NewReporter(failing.URL, func(ctx context.Context) (telemetry.Report, error) {
+		return telemetry.Report{}, nil
+	}).Send(context.Background()); err == nil {
+		t.Fatal("Send to a failing endpoint must return an error")
+	}
+}
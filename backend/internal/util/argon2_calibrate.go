@@ -0,0 +1,51 @@
+package util
+
+import (
+	"time"
+
+	"golang.org/x/crypto/argon2"
+
+	"pmv2/backend/internal/domain"
+)
+
+// Argon2MinMemoryKiB is the least memory Argon2id should be given (OWASP's
+// 19 MiB floor).
+const Argon2MinMemoryKiB = 19456
+
+// argon2MaxCalibrationPasses bounds CalibrateArgon2 on a very fast host or
+// with an unreasonable target.
+const argon2MaxCalibrationPasses = 32
+
+// CalibrateArgon2 finds Argon2id parameters that take at least target per
+// hash on this machine. Memory costs an attacker more than passes do, so the
+// whole memoryKiB budget is used and passes are added until one hash takes
+// target. It returns the parameters and how long a hash with them took; that
+// is over target when a single pass already is.
+func CalibrateArgon2(target time.Duration, memoryKiB uint32, parallelism uint8) (domain.Argon2Params, time.Duration) {
+	params := DefaultArgon2Params()
+	params.Memory = max(memoryKiB, Argon2MinMemoryKiB)
+	params.Parallelism = max(parallelism, 1)
+	params.Iterations = 1
+
+	took := timeArgon2(params)
+	for took < target && params.Iterations < argon2MaxCalibrationPasses {
+		params.Iterations++
+		took = timeArgon2(params)
+	}
+	return params, took
+}
+
+// timeArgon2 reports the faster of two hashes, so a stray pause does not
+// make the parameters look more expensive than they are.
+func timeArgon2(params domain.Argon2Params) time.Duration {
+	salt := make([]byte, 32)
+	fastest := time.Duration(0)
+	for range 2 {
+		start := time.Now()
+		argon2.IDKey([]byte("pmv2-calibration"), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+		if took := time.Since(start); fastest == 0 || took < fastest {
+			fastest = took
+		}
+	}
+	return fastest
+}
@@ -20,6 +20,7 @@ import (
 	"golang.org/x/crypto/chacha20poly1305"
 
 	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/metrics"
 )
 
 func DefaultArgon2Params() domain.Argon2Params {
@@ -32,19 +33,40 @@ func DefaultArgon2Params() domain.Argon2Params {
 }
 
 func HashPassword(password string, params domain.Argon2Params) (salt []byte, hash []byte, err error) {
-	salt = make([]byte, 32)
+	salt, err = NewSalt()
+	if err != nil {
+		return nil, nil, err
+	}
+	return salt, HashPasswordWithSalt(password, salt, params), nil
+}
+
+// HashPasswordWithSalt hashes password with a salt the caller chose, such
+// as one a client already derived its vault key with.
+func HashPasswordWithSalt(password string, salt []byte, params domain.Argon2Params) []byte {
+	defer observePasswordHash("hash", time.Now())
+	return argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+}
+
+// NewSalt returns a random 32-byte salt. Accounts without a password still
+// get one: it is the salt clients derive their vault keys with.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, 32)
 	if _, err := rand.Read(salt); err != nil {
-		return nil, nil, fmt.Errorf("generate salt: %w", err)
+		return nil, fmt.Errorf("generate salt: %w", err)
 	}
-	hash = argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
-	return salt, hash, nil
+	return salt, nil
 }
 
 func VerifyPassword(password string, salt []byte, expected []byte, params domain.Argon2Params) bool {
+	defer observePasswordHash("verify", time.Now())
 	actual := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
 	return subtle.ConstantTimeCompare(actual, expected) == 1
 }
 
+func observePasswordHash(op string, start time.Time) {
+	metrics.PasswordHashDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+}
+
 func MarshalArgon2Params(params domain.Argon2Params) ([]byte, error) {
 	return json.Marshal(params)
 }
@@ -160,6 +182,20 @@ func DeriveTOTPEncryptionKey(pepper string) []byte {
 	return sum[:]
 }
 
+// DeriveRevokeLinkKey derives the key that signs the sign-out links in
+// lockout alert emails. Like the TOTP key it is bound to the auth pepper.
+func DeriveRevokeLinkKey(pepper string) []byte {
+	sum := sha256.Sum256([]byte("pmv2:revoke-link:" + pepper))
+	return sum[:]
+}
+
+// DeriveAccessTokenKey derives the key that signs access tokens. Like the
+// TOTP key it is bound to the auth pepper.
+func DeriveAccessTokenKey(pepper string) []byte {
+	sum := sha256.Sum256([]byte("pmv2:access-token:" + pepper))
+	return sum[:]
+}
+
 func EncryptTOTPSecret(secret string, key []byte) ([]byte, error) {
 	trimmedSecret := strings.TrimSpace(secret)
 	if trimmedSecret == "" {
@@ -209,6 +245,56 @@ func DecryptTOTPSecret(payload []byte, key []byte) (string, error) {
 	return string(plaintext), nil
 }
 
+// DeriveWebhookSecretKey derives the key that encrypts webhook signing
+// secrets at rest. Like the TOTP key it is bound to the auth pepper.
+func DeriveWebhookSecretKey(pepper string) []byte {
+	sum := sha256.Sum256([]byte("pmv2:webhook-secret:" + pepper))
+	return sum[:]
+}
+
+// EncryptWebhookSecret seals secret with XChaCha20-Poly1305; the random
+// nonce is prepended to the ciphertext.
+func EncryptWebhookSecret(secret string, key []byte) ([]byte, error) {
+	if secret == "" {
+		return nil, errors.New("webhook secret is empty")
+	}
+	if len(key) != chacha20poly1305.KeySize {
+		return nil, errors.New("invalid webhook secret key length")
+	}
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, fmt.Errorf("create xchacha20poly1305 cipher: %w", err)
+	}
+
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate webhook secret nonce: %w", err)
+	}
+	return append(nonce, aead.Seal(nil, nonce, []byte(secret), []byte("pmv2:webhook-secret:v1"))...), nil
+}
+
+func DecryptWebhookSecret(payload []byte, key []byte) (string, error) {
+	if len(key) != chacha20poly1305.KeySize {
+		return "", errors.New("invalid webhook secret key length")
+	}
+	if len(payload) <= chacha20poly1305.NonceSizeX {
+		return "", errors.New("invalid webhook secret payload length")
+	}
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return "", fmt.Errorf("create xchacha20poly1305 cipher: %w", err)
+	}
+
+	nonce := payload[:chacha20poly1305.NonceSizeX]
+	plaintext, err := aead.Open(nil, nonce, payload[chacha20poly1305.NonceSizeX:], []byte("pmv2:webhook-secret:v1"))
+	if err != nil {
+		return "", fmt.Errorf("decrypt webhook secret: %w", err)
+	}
+	return string(plaintext), nil
+}
+
 func ParseStoredTOTPSecret(payload []byte, key []byte) (string, error) {
 	secret, err := DecryptTOTPSecret(payload, key)
 	if err == nil {
@@ -2,8 +2,13 @@ package util
 
 import (
 	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"pmv2/backend/internal/metrics"
 )
 
 func TestHashAndVerifyPassword(t *testing.T) {
@@ -19,6 +24,17 @@ func TestHashAndVerifyPassword(t *testing.T) {
 	if VerifyPassword("wrong password", salt, hash, params) {
 		t.Fatal("expected wrong password to fail")
 	}
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{
+		`pmv2_password_hash_duration_seconds_count{op="hash"}`,
+		`pmv2_password_hash_duration_seconds_count{op="verify"}`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("metrics output is missing %q", want)
+		}
+	}
 }
 
 func TestVerifyTOTP(t *testing.T) {
@@ -54,6 +70,34 @@ func TestEncryptDecryptTOTPSecret(t *testing.T) {
 	}
 }
 
+func TestEncryptDecryptWebhookSecret(t *testing.T) {
+	key := DeriveWebhookSecretKey("unit-test-pepper")
+	secret := "whsec_abc123"
+
+	ciphertext, err := EncryptWebhookSecret(secret, key)
+	if err != nil {
+		t.Fatalf("encrypt webhook secret: %v", err)
+	}
+	if bytes.Contains(ciphertext, []byte(secret)) {
+		t.Fatal("ciphertext should not contain plaintext secret")
+	}
+
+	decrypted, err := DecryptWebhookSecret(ciphertext, key)
+	if err != nil {
+		t.Fatalf("decrypt webhook secret: %v", err)
+	}
+	if decrypted != secret {
+		t.Fatalf("expected decrypted secret to match, got %q", decrypted)
+	}
+
+	if bytes.Equal(key, DeriveTOTPEncryptionKey("unit-test-pepper")) {
+		t.Fatal("webhook and TOTP keys must differ")
+	}
+	if _, err := DecryptWebhookSecret(ciphertext, DeriveWebhookSecretKey("other-pepper")); err == nil {
+		t.Fatal("expected decryption with another pepper to fail")
+	}
+}
+
 func TestRecoveryCodeHelpers(t *testing.T) {
 	codes, err := GenerateRecoveryCodes(10)
 	if err != nil {
@@ -160,3 +204,15 @@ func TestBuildOTPAuthURL(t *testing.T) {
 		t.Errorf("BuildOTPAuthURL() mismatch.\nExpected: %s\nGot: %s", expected, url)
 	}
 }
+
+func TestCalibrateArgon2(t *testing.T) {
+	params, took := CalibrateArgon2(0, 1024, 0)
+	if params.Memory != Argon2MinMemoryKiB || params.Iterations != 1 || params.Parallelism != 1 || params.KeyLength != 32 || took <= 0 {
+		t.Fatalf("CalibrateArgon2(0) = %+v, %s; want one pass at the memory floor", params, took)
+	}
+
+	params, took = CalibrateArgon2(3*took, Argon2MinMemoryKiB, 1)
+	if params.Iterations < 2 {
+		t.Fatalf("CalibrateArgon2(3 passes' time) = %+v, %s; want more passes", params, took)
+	}
+}
@@ -2,10 +2,15 @@ package util
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
+	"net/netip"
+	"strconv"
 	"strings"
+	"time"
 
 	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/i18n"
 )
 
 func ReadJSON(r *http.Request, into any) error {
@@ -20,8 +25,50 @@ func WriteJSON(w http.ResponseWriter, status int, payload any) {
 	_ = json.NewEncoder(w).Encode(payload)
 }
 
+// WriteError writes the standard error body. The message is translated
+// into the request's language under the ID "error.<code>"; code itself is
+// never translated.
 func WriteError(w http.ResponseWriter, status int, code string, message string) {
-	WriteJSON(w, status, dto.ErrorResponse{Error: code, Message: message})
+	WriteJSON(w, status, dto.ErrorResponse{Error: code, Message: i18n.FromWriter(w).Text("error."+code, message)})
+}
+
+// WriteLockedError answers like WriteError for a caller locked out until
+// until, which it reports as locked_until and, in whole seconds rounded up,
+// as Retry-After.
+func WriteLockedError(w http.ResponseWriter, status int, code string, message string, until time.Time) {
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(time.Until(until).Seconds())))))
+	WriteJSON(w, status, dto.ErrorResponse{
+		Error:       code,
+		Message:     i18n.FromWriter(w).Text("error."+code, message),
+		LockedUntil: until.UTC().Format(time.RFC3339),
+	})
+}
+
+// CheckETag sets a weak ETag built from tag and, when the request's
+// If-None-Match already names it, answers 304 Not Modified. It reports
+// whether the response has been written. The tag is weak because it
+// identifies the vault state, not the bytes: the same state may be sent
+// gzipped or not by the compression middleware.
+func CheckETag(w http.ResponseWriter, r *http.Request, tag string) bool {
+	etag := `W/"` + tag + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	// If-None-Match uses the weak comparison, so W/ prefixes are ignored.
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") || candidate == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// AccessTokenCookie names the cookie that carries the access token next to
+// the session cookie named sessionCookie.
+func AccessTokenCookie(sessionCookie string) string {
+	return sessionCookie + "_access"
 }
 
 func BearerToken(header string) string {
@@ -32,23 +79,75 @@ func BearerToken(header string) string {
 	if len(parts) != 2 {
 		return ""
 	}
-	if !strings.EqualFold(parts[0], "Bearer") {
+	// DPoP-bound tokens travel under their own scheme (RFC 9449).
+	if !strings.EqualFold(parts[0], "Bearer") && !strings.EqualFold(parts[0], "DPoP") {
 		return ""
 	}
 	return strings.TrimSpace(parts[1])
 }
 
-// ClientIPFromRequest extracts the client's IP address from the request.
-// X-Forwarded-For is preferred (for proxy deployments); falls back to RemoteAddr.
+// ClientIPFromRequest returns the client IP resolved for r by the request
+// metadata middleware (see ClientIP). Without that middleware it falls back
+// to the direct peer address; X-Forwarded-For is never read here.
 // The port suffix is always stripped so the result is safe to store in a Postgres INET column.
 func ClientIPFromRequest(r *http.Request) string {
-	forwarded := strings.TrimSpace(r.Header.Get("X-Forwarded-For"))
-	if forwarded != "" {
-		parts := strings.Split(forwarded, ",")
-		if len(parts) > 0 {
-			return NormalizeIP(strings.TrimSpace(parts[0]))
-		}
+	if ip := RequestMetaFromContext(r.Context()).IPAddress; ip != "" {
+		return ip
 	}
 	return NormalizeIP(r.RemoteAddr)
 }
 
+// ClientIP resolves the address of the client behind r. X-Forwarded-For is
+// only believed when the direct peer is one of trustedProxies. The header is
+// then read from the right, skipping hops that are themselves trusted
+// proxies, and the first untrusted address is the client. Entries further
+// left were supplied by the client and are ignored, so rotating the header
+// cannot change the result.
+func ClientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	peer := NormalizeIP(r.RemoteAddr)
+	if !isTrustedProxy(peer, trustedProxies) {
+		return peer
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := NormalizeIP(hops[i])
+		if hop == "" {
+			// A malformed hop means the chain cannot be trusted past here.
+			break
+		}
+		if !isTrustedProxy(hop, trustedProxies) {
+			return hop
+		}
+		peer = hop
+	}
+	// Every hop was a trusted proxy; the leftmost one is the best we know.
+	return peer
+}
+
+func isTrustedProxy(ip string, trustedProxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// CSVSafe neutralises a value that spreadsheet applications would evaluate
+// as a formula (CSV injection) by prefixing it with a single quote.
+func CSVSafe(value string) string {
+	if value == "" {
+		return value
+	}
+	switch value[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + value
+	}
+	return value
+}
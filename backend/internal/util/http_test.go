@@ -0,0 +1,137 @@
+package util
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/dto"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+
+	cases := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		trusted    []netip.Prefix
+		want       string
+	}{
+		{"direct, header ignored", "203.0.113.7:4321", []string{"198.51.100.1"}, nil, "203.0.113.7"},
+		{"untrusted peer cannot spoof", "203.0.113.7:4321", []string{"198.51.100.1"}, trusted, "203.0.113.7"},
+		{"trusted proxy", "10.0.0.5:80", []string{"198.51.100.1"}, trusted, "198.51.100.1"},
+		{"client-supplied entries ignored", "10.0.0.5:80", []string{"1.2.3.4, 198.51.100.1"}, trusted, "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.5:80", []string{"1.2.3.4, 198.51.100.1, 10.1.2.3"}, trusted, "198.51.100.1"},
+		{"repeated headers", "10.0.0.5:80", []string{"1.2.3.4", "198.51.100.1"}, trusted, "198.51.100.1"},
+		{"malformed hop stops the walk", "10.0.0.5:80", []string{"198.51.100.1, bogus"}, trusted, "10.0.0.5"},
+		{"only trusted hops", "10.0.0.5:80", []string{"10.9.9.9"}, trusted, "10.9.9.9"},
+		{"no header from proxy", "10.0.0.5:80", nil, trusted, "10.0.0.5"},
+		{"ipv6 proxy", "[2001:db8::1]:443", []string{"2001:db8:ffff::2, 2a00:1450::1"}, trusted, "2a00:1450::1"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tc.remoteAddr
+			for _, v := range tc.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := ClientIP(r, tc.trusted); got != tc.want {
+				t.Errorf("ClientIP = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestClientIPFromRequestPrefersResolvedMeta(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.5:80"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	if got := ClientIPFromRequest(r); got != "10.0.0.5" {
+		t.Fatalf("without meta: got %q, want the peer address", got)
+	}
+
+	r = r.WithContext(WithRequestMeta(r.Context(), RequestMeta{IPAddress: "198.51.100.1"}))
+	if got := ClientIPFromRequest(r); got != "198.51.100.1" {
+		t.Fatalf("with meta: got %q, want the resolved address", got)
+	}
+}
+
+func TestCheckETag(t *testing.T) {
+	cases := []struct {
+		name        string
+		ifNoneMatch string
+		want304     bool
+	}{
+		{"no header", "", false},
+		{"weak match", `W/"rev1"`, true},
+		{"strong form matches weakly", `"rev1"`, true},
+		{"wildcard", "*", true},
+		{"match in list", `W/"old", W/"rev1"`, true},
+		{"mismatch", `W/"rev0"`, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tc.ifNoneMatch)
+			}
+			rec := httptest.NewRecorder()
+
+			if got := CheckETag(rec, req, "rev1"); got != tc.want304 {
+				t.Fatalf("CheckETag = %v, want %v", got, tc.want304)
+			}
+			if etag := rec.Header().Get("ETag"); etag != `W/"rev1"` {
+				t.Fatalf("ETag = %q, want weak tag", etag)
+			}
+			if tc.want304 && rec.Code != http.StatusNotModified {
+				t.Fatalf("status = %d, want 304", rec.Code)
+			}
+		})
+	}
+}
+
+func TestCSVSafe(t *testing.T) {
+	cases := map[string]string{
+		"":                  "",
+		"Mozilla/5.0":       "Mozilla/5.0",
+		"203.0.113.7":       "203.0.113.7",
+		`{"item":"x"}`:      `{"item":"x"}`,
+		"=HYPERLINK(\"x\")": "'=HYPERLINK(\"x\")",
+		"+1":                "'+1",
+		"-2+3":              "'-2+3",
+		"@SUM(A1)":          "'@SUM(A1)",
+		"\t=1":              "'\t=1",
+		"\r=1":              "'\r=1",
+	}
+	for in, want := range cases {
+		if got := CSVSafe(in); got != want {
+			t.Errorf("CSVSafe(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWriteLockedError(t *testing.T) {
+	until := time.Now().Add(90*time.Second + 300*time.Millisecond)
+	rec := httptest.NewRecorder()
+	WriteLockedError(rec, http.StatusTooManyRequests, "mfa_rate_limited", "try again later", until)
+
+	if got := rec.Header().Get("Retry-After"); got != "91" {
+		t.Errorf("Retry-After = %q, want 91", got)
+	}
+	var body dto.ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Error != "mfa_rate_limited" || body.LockedUntil != until.UTC().Format(time.RFC3339) {
+		t.Errorf("body = %+v", body)
+	}
+}
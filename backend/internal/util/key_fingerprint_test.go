@@ -0,0 +1,30 @@
+package util
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestKeyFingerprint(t *testing.T) {
+	fp := KeyFingerprint([]byte("public-key"))
+	if !regexp.MustCompile(`^([0-9A-F]{4} ){15}[0-9A-F]{4}$`).MatchString(fp) {
+		t.Fatalf("fingerprint %q is not 16 groups of 4 hex digits", fp)
+	}
+	if fp != KeyFingerprint([]byte("public-key")) || fp == KeyFingerprint([]byte("other-key")) {
+		t.Fatal("fingerprint must depend only on the key")
+	}
+}
+
+func TestSafetyNumber(t *testing.T) {
+	const ada, bob = "11111111-1111-1111-1111-111111111111", "22222222-2222-2222-2222-222222222222"
+	n := SafetyNumber(ada, []byte("ada-key"), bob, []byte("bob-key"))
+	if !regexp.MustCompile(`^([0-9]{5} ){11}[0-9]{5}$`).MatchString(n) {
+		t.Fatalf("safety number %q is not 12 groups of 5 digits", n)
+	}
+	if got := SafetyNumber(bob, []byte("bob-key"), ada, []byte("ada-key")); got != n {
+		t.Fatalf("safety number depends on order: %q != %q", got, n)
+	}
+	if SafetyNumber(ada, []byte("ada-key"), bob, []byte("mallory-key")) == n {
+		t.Fatal("a substituted key must change the safety number")
+	}
+}
@@ -0,0 +1,100 @@
+package util
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// metadataPrefix marks a value sealed by MetadataCipher. Stored values
+// without it predate encryption and are read back unchanged.
+const metadataPrefix = "pmv2m1:"
+
+var metadataAD = []byte("pmv2:metadata:v1")
+
+// MetadataCipher encrypts server-visible metadata (IP addresses, user
+// agents, device names, audit event data) so a database dump alone does not
+// expose it. Sealed values are text: the prefix, then the base64 nonce and
+// XChaCha20-Poly1305 ciphertext.
+type MetadataCipher struct {
+	aead   cipher.AEAD
+	sivKey []byte
+}
+
+// NewMetadataCipher builds a cipher from a 32-byte deployment key, normally
+// a KMS data key.
+func NewMetadataCipher(key []byte) (*MetadataCipher, error) {
+	if len(key) != chacha20poly1305.KeySize {
+		return nil, errors.New("invalid metadata key length")
+	}
+	encKey := sha256.Sum256(append([]byte("pmv2:metadata-enc:"), key...))
+	sivKey := sha256.Sum256(append([]byte("pmv2:metadata-siv:"), key...))
+
+	aead, err := chacha20poly1305.NewX(encKey[:])
+	if err != nil {
+		return nil, fmt.Errorf("create xchacha20poly1305 cipher: %w", err)
+	}
+	return &MetadataCipher{aead: aead, sivKey: sivKey[:]}, nil
+}
+
+// Encrypt seals value under a random nonce. The empty string stays empty.
+func (c *MetadataCipher) Encrypt(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generate metadata nonce: %w", err)
+	}
+	return c.seal(nonce, value), nil
+}
+
+// EncryptDeterministic seals value under a nonce derived from it, so equal
+// values give equal ciphertexts and a column can still be filtered on. It
+// reveals which rows share a value, nothing more.
+func (c *MetadataCipher) EncryptDeterministic(value string) string {
+	if value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, c.sivKey)
+	mac.Write([]byte(value))
+	return c.seal(mac.Sum(nil)[:chacha20poly1305.NonceSizeX], value)
+}
+
+func (c *MetadataCipher) seal(nonce []byte, value string) string {
+	sealed := c.aead.Seal(nonce, nonce, []byte(value), metadataAD)
+	return metadataPrefix + base64.RawStdEncoding.EncodeToString(sealed)
+}
+
+// IsEncryptedMetadata reports whether value was sealed by a MetadataCipher.
+func IsEncryptedMetadata(value string) bool {
+	return strings.HasPrefix(value, metadataPrefix)
+}
+
+// Decrypt opens a value from either Encrypt method. Values without the
+// prefix are returned as they are.
+func (c *MetadataCipher) Decrypt(value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, metadataPrefix)
+	if !ok {
+		return value, nil
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("decode metadata: %w", err)
+	}
+	if len(sealed) <= chacha20poly1305.NonceSizeX {
+		return "", errors.New("invalid metadata payload length")
+	}
+	plaintext, err := c.aead.Open(nil, sealed[:chacha20poly1305.NonceSizeX], sealed[chacha20poly1305.NonceSizeX:], metadataAD)
+	if err != nil {
+		return "", fmt.Errorf("decrypt metadata: %w", err)
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,51 @@
+package util
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMetadataCipher(t *testing.T) {
+	c, err := NewMetadataCipher(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("new metadata cipher: %v", err)
+	}
+
+	sealed, err := c.Encrypt("Mozilla/5.0")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if !IsEncryptedMetadata(sealed) || strings.Contains(sealed, "Mozilla") {
+		t.Fatalf("sealed value %q is not encrypted", sealed)
+	}
+	if again, _ := c.Encrypt("Mozilla/5.0"); again == sealed {
+		t.Fatal("Encrypt should use a fresh nonce")
+	}
+	if got, err := c.Decrypt(sealed); err != nil || got != "Mozilla/5.0" {
+		t.Fatalf("decrypt = %q, %v", got, err)
+	}
+
+	ip := c.EncryptDeterministic("198.51.100.1")
+	if ip != c.EncryptDeterministic("198.51.100.1") || ip == c.EncryptDeterministic("198.51.100.2") {
+		t.Fatal("EncryptDeterministic should map equal values, and only those, to equal ciphertexts")
+	}
+	if got, err := c.Decrypt(ip); err != nil || got != "198.51.100.1" {
+		t.Fatalf("decrypt deterministic = %q, %v", got, err)
+	}
+
+	if got, err := c.Decrypt("203.0.113.9"); err != nil || got != "203.0.113.9" {
+		t.Fatalf("legacy plaintext = %q, %v; want it unchanged", got, err)
+	}
+	if empty, _ := c.Encrypt(""); empty != "" {
+		t.Fatalf("empty value sealed to %q", empty)
+	}
+
+	other, _ := NewMetadataCipher(bytes.Repeat([]byte{8}, 32))
+	if _, err := other.Decrypt(sealed); err == nil {
+		t.Fatal("expected decryption with another key to fail")
+	}
+	if _, err := NewMetadataCipher([]byte("short")); err == nil {
+		t.Fatal("expected a short key to be rejected")
+	}
+}
@@ -9,6 +9,51 @@ func NormalizeEmail(email string) string {
 	return strings.ToLower(strings.TrimSpace(email))
 }
 
+// aliasingProvider is how a mail provider delivers aliases of an address to
+// the same mailbox.
+type aliasingProvider struct {
+	domain     string // the provider's main domain
+	ignoreDots bool   // "a.da" is "ada"
+}
+
+// aliasingProviders are the domains known to deliver user+tag to user.
+var aliasingProviders = map[string]aliasingProvider{
+	"gmail.com":      {domain: "gmail.com", ignoreDots: true},
+	"googlemail.com": {domain: "gmail.com", ignoreDots: true},
+	"outlook.com":    {domain: "outlook.com"},
+	"hotmail.com":    {domain: "hotmail.com"},
+	"live.com":       {domain: "live.com"},
+	"icloud.com":     {domain: "icloud.com"},
+	"me.com":         {domain: "icloud.com"},
+	"mac.com":        {domain: "icloud.com"},
+	"fastmail.com":   {domain: "fastmail.com"},
+	"proton.me":      {domain: "proton.me"},
+	"protonmail.com": {domain: "proton.me"},
+}
+
+// CanonicalEmail normalizes email and folds the aliases a known provider
+// delivers to one mailbox: the +tag, and for Gmail the dots of the local
+// part and the googlemail.com domain. Other addresses are only normalized.
+func CanonicalEmail(email string) string {
+	email = NormalizeEmail(email)
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok {
+		return email
+	}
+	provider, known := aliasingProviders[domain]
+	if !known {
+		return email
+	}
+	local, _, _ = strings.Cut(local, "+")
+	if provider.ignoreDots {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	if local == "" {
+		return email
+	}
+	return local + "@" + provider.domain
+}
+
 func NormalizeIP(addr string) string {
 	trimmed := strings.TrimSpace(addr)
 	if trimmed == "" {
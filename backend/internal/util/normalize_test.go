@@ -0,0 +1,20 @@
+package util
+
+import "testing"
+
+func TestCanonicalEmail(t *testing.T) {
+	for in, want := range map[string]string{
+		" A.Da+Promo@GMail.com ": "ada@gmail.com",
+		"a.da@googlemail.com":    "ada@gmail.com",
+		"ada+work@outlook.com":   "ada@outlook.com",
+		"a.da+x@outlook.com":     "a.da@outlook.com",
+		"ada+x@me.com":           "ada@icloud.com",
+		"a.da+tag@example.com":   "a.da+tag@example.com",
+		"+tag@gmail.com":         "+tag@gmail.com",
+		"not-an-address":         "not-an-address",
+	} {
+		if got := CanonicalEmail(in); got != want {
+			t.Errorf("CanonicalEmail(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
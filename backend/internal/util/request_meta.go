@@ -0,0 +1,37 @@
+package util
+
+import "context"
+
+// RequestMeta describes who made the current request and from where. It is
+// attached to the request context by middleware so services can record it
+// (e.g. in audit events) without threading it through every signature.
+type RequestMeta struct {
+	// RequestID identifies the request in logs and is echoed to the
+	// client in X-Request-ID.
+	RequestID   string
+	IPAddress   string
+	UserAgent   string
+	ActorUserID string
+	SessionID   string
+	// DeviceID is the device that signed the session in, if known.
+	DeviceID string
+	// Method, Path and DPoPProof are what a DPoP-bound session's proof is
+	// checked against (see package dpop). Path is the path the client
+	// requested, before any rewriting.
+	Method    string
+	Path      string
+	DPoPProof string
+}
+
+type requestMetaKey struct{}
+
+func WithRequestMeta(ctx context.Context, meta RequestMeta) context.Context {
+	return context.WithValue(ctx, requestMetaKey{}, meta)
+}
+
+// RequestMetaFromContext returns the metadata attached to ctx, or the zero
+// value for background work that did not originate from a request.
+func RequestMetaFromContext(ctx context.Context) RequestMeta {
+	meta, _ := ctx.Value(requestMetaKey{}).(RequestMeta)
+	return meta
+}
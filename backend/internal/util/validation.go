@@ -0,0 +1,150 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"pmv2/backend/internal/dto"
+	"pmv2/backend/internal/i18n"
+)
+
+// Field error codes. Clients switch on these, so they must stay stable.
+const (
+	FieldRequired     = "required"
+	FieldInvalid      = "invalid"
+	FieldInvalidType  = "invalid_type"
+	FieldUnknown      = "unknown_field"
+	FieldMalformed    = "malformed"
+	FieldWeakPassword = "weak_password"
+	FieldConflict     = "conflict"
+)
+
+// FieldErrors collects the problems found while validating one request.
+type FieldErrors []dto.FieldError
+
+func (f *FieldErrors) Add(field, code, message string) {
+	*f = append(*f, dto.FieldError{Field: field, Code: code, Message: message})
+}
+
+// Required records a FieldRequired error when value is blank and reports
+// whether it was present.
+func (f *FieldErrors) Required(field, value string) bool {
+	if strings.TrimSpace(value) != "" {
+		return true
+	}
+	f.Add(field, FieldRequired, field+" is required")
+	return false
+}
+
+// Email checks that value is a single bare address ("a@b.example", no
+// display name).
+func (f *FieldErrors) Email(field, value string) {
+	if !f.Required(field, value) {
+		return
+	}
+	trimmed := strings.TrimSpace(value)
+	addr, err := mail.ParseAddress(trimmed)
+	if err != nil || addr.Address != trimmed {
+		f.Add(field, FieldInvalid, field+" is not a valid email address")
+	}
+}
+
+// WriteValidationError answers 400 with code, message and the field list.
+// Like WriteError it translates the message, and each field message under
+// "field.<code>" with the field name as argument.
+func WriteValidationError(w http.ResponseWriter, code string, message string, fields FieldErrors) {
+	loc := i18n.FromWriter(w)
+	localized := make([]dto.FieldError, len(fields))
+	for i, f := range fields {
+		f.Message = loc.Text("field."+f.Code, f.Message, f.Field)
+		localized[i] = f
+	}
+	WriteJSON(w, http.StatusBadRequest, dto.ErrorResponse{
+		Error:   code,
+		Message: loc.Text("error."+code, message),
+		Fields:  localized,
+	})
+}
+
+// WriteFieldErrors answers 400 validation_failed. The message repeats the
+// first field's, so clients that ignore the list still show something useful.
+func WriteFieldErrors(w http.ResponseWriter, fields FieldErrors) {
+	message := "request validation failed"
+	if len(fields) > 0 {
+		message = i18n.FromWriter(w).Text("field."+fields[0].Code, fields[0].Message, fields[0].Field)
+	}
+	WriteValidationError(w, "validation_failed", message, fields)
+}
+
+// WriteDecodeError answers a ReadJSON failure with invalid_json, naming the
+// offending field where the decoder reported one.
+func WriteDecodeError(w http.ResponseWriter, err error) {
+	WriteValidationError(w, "invalid_json", "invalid request body", DecodeErrorFields(err))
+}
+
+// DecodeErrorFields translates a ReadJSON error into field errors.
+func DecodeErrorFields(err error) FieldErrors {
+	var fields FieldErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		field := jsonFieldPath(typeErr.Field)
+		if field == "" {
+			field = "body"
+		}
+		fields.Add(field, FieldInvalidType, field+" must be of type "+jsonTypeName(typeErr.Type.String()))
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		fields.Add("", FieldMalformed, "request body is not valid JSON")
+	case errors.Is(err, io.EOF):
+		fields.Add("", FieldRequired, "request body is required")
+	default:
+		// DisallowUnknownFields reports `json: unknown field "name"` without
+		// a typed error.
+		if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			name = strings.Trim(name, `"`)
+			fields.Add(name, FieldUnknown, name+" is not a recognised field")
+		} else {
+			fields.Add("", FieldMalformed, "request body could not be decoded")
+		}
+	}
+	return fields
+}
+
+// jsonFieldPath rewrites the decoder's "items.0.nonce" as "items[0].nonce",
+// the form the validators use.
+func jsonFieldPath(path string) string {
+	var b strings.Builder
+	for i, part := range strings.Split(path, ".") {
+		if _, err := strconv.Atoi(part); err == nil && i > 0 {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+func jsonTypeName(goType string) string {
+	goType = strings.TrimPrefix(goType, "*")
+	switch {
+	case goType == "string":
+		return "string"
+	case goType == "bool":
+		return "boolean"
+	case strings.HasPrefix(goType, "int"), strings.HasPrefix(goType, "uint"), strings.HasPrefix(goType, "float"):
+		return "number"
+	case strings.HasPrefix(goType, "[]"):
+		return "array"
+	default:
+		return "object"
+	}
+}
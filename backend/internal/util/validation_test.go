@@ -0,0 +1,92 @@
+package util
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pmv2/backend/internal/dto"
+)
+
+func TestDecodeErrorFields(t *testing.T) {
+	type payload struct {
+		Email string `json:"email"`
+		Items []struct {
+			Count int `json:"count"`
+		} `json:"items"`
+	}
+
+	cases := []struct {
+		name      string
+		body      string
+		wantField string
+		wantCode  string
+	}{
+		{"wrong type", `{"email": 42}`, "email", FieldInvalidType},
+		{"nested wrong type", `{"items": [{"count": 1}, {"count": "x"}]}`, "items[1].count", FieldInvalidType},
+		{"unknown field", `{"emial": "a@b.example"}`, "emial", FieldUnknown},
+		{"syntax error", `{"email": }`, "", FieldMalformed},
+		{"truncated", `{"email": "a@b`, "", FieldMalformed},
+		{"empty body", ``, "", FieldRequired},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			var into payload
+			err := ReadJSON(r, &into)
+			if err == nil {
+				t.Fatal("ReadJSON succeeded")
+			}
+			fields := DecodeErrorFields(err)
+			if len(fields) != 1 || fields[0].Field != tc.wantField || fields[0].Code != tc.wantCode {
+				t.Fatalf("fields = %+v, want %s/%s", fields, tc.wantField, tc.wantCode)
+			}
+		})
+	}
+}
+
+func TestFieldErrorsEmail(t *testing.T) {
+	cases := map[string]string{
+		"a@b.example":          "",
+		"  a@b.example  ":      "",
+		"":                     FieldRequired,
+		"not-an-email":         FieldInvalid,
+		"Bob <bob@b.example>":  FieldInvalid,
+		"a@b.example, c@d.com": FieldInvalid,
+	}
+	for value, want := range cases {
+		var fields FieldErrors
+		fields.Email("email", value)
+		got := ""
+		if len(fields) > 0 {
+			got = fields[0].Code
+		}
+		if got != want {
+			t.Errorf("Email(%q) code = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestWriteFieldErrors(t *testing.T) {
+	var fields FieldErrors
+	fields.Required("email", " ")
+	fields.Add("password", FieldWeakPassword, "password is too weak")
+
+	rec := httptest.NewRecorder()
+	WriteFieldErrors(rec, fields)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var resp dto.ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error != "validation_failed" || resp.Message != "email is required" || len(resp.Fields) != 2 {
+		t.Fatalf("response = %+v", resp)
+	}
+	if resp.Fields[1] != (dto.FieldError{Field: "password", Code: FieldWeakPassword, Message: "password is too weak"}) {
+		t.Fatalf("fields[1] = %+v", resp.Fields[1])
+	}
+}
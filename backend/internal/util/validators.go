@@ -1,12 +1,39 @@
 package util
 
 import (
+	_ "embed"
+	"strings"
+	"sync"
 	"unicode"
 
 	"pmv2/backend/internal/domain"
 )
 
-func ValidatePasswordStrength(password string) error {
+// commonPasswords lists the most used passwords, one per line in lower case.
+//
+//go:embed common_passwords.txt
+var commonPasswords string
+
+var commonPasswordSet = sync.OnceValue(func() map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, line := range strings.Split(commonPasswords, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			set[line] = struct{}{}
+		}
+	}
+	return set
+})
+
+// minPersonalTokenLength is the shortest part of an email local-part or
+// name a password may not contain; shorter ones match too many words.
+const minPersonalTokenLength = 3
+
+// ValidatePasswordStrength rejects a master password that is short, lacks a
+// character class, is a common password (decorated with case, leetspeak or
+// leading and trailing digits and symbols or not, so "Password123!" is one),
+// or contains the local part of email or a part of name. email and name may
+// be empty.
+func ValidatePasswordStrength(password, email, name string) error {
 	if len(password) < 8 {
 		return domain.ErrWeakPassword
 	}
@@ -26,5 +53,46 @@ func ValidatePasswordStrength(password string) error {
 	if !hasUpper || !hasLower || !hasNumber || !hasSpecial {
 		return domain.ErrWeakPassword
 	}
+	if isCommonPassword(password) || containsPersonalInfo(password, email, name) {
+		return domain.ErrWeakPassword
+	}
 	return nil
 }
+
+var leetspeak = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s", "!", "i")
+
+func isCommonPassword(password string) bool {
+	set := commonPasswordSet()
+	lower := strings.ToLower(password)
+	if _, ok := set[lower]; ok {
+		return true
+	}
+	base := strings.TrimFunc(lower, func(r rune) bool { return !unicode.IsLetter(r) })
+	if base == "" {
+		return false
+	}
+	for _, candidate := range []string{base, leetspeak.Replace(base)} {
+		if _, ok := set[candidate]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPersonalInfo(password, email, name string) bool {
+	lower := strings.ToLower(password)
+	local, _, _ := strings.Cut(strings.ToLower(email), "@")
+	tokens := []string{local}
+	tokens = append(tokens, strings.FieldsFunc(local, isNotLetterOrDigit)...)
+	tokens = append(tokens, strings.FieldsFunc(strings.ToLower(name), isNotLetterOrDigit)...)
+	for _, token := range tokens {
+		if len([]rune(token)) >= minPersonalTokenLength && strings.Contains(lower, token) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNotLetterOrDigit(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"errors"
+	"testing"
+
+	"pmv2/backend/internal/domain"
+)
+
+func TestValidatePasswordStrength(t *testing.T) {
+	cases := []struct {
+		password string
+		ok       bool
+	}{
+		{"Correct-Horse-9", true},
+		{"Short-1", false},
+		{"alllowercase-9", false},
+		// Common passwords, however decorated.
+		{"Password123!", false},
+		{"P@ssw0rd!", false},
+		{"!!Monkey2024", false},
+		{"Qwerty123$", false},
+		// The email local part or a part of the name.
+		{"Lovelace-1815!", false},
+		{"x-Ada.Byron-9", false},
+		{"AdaByron#1815", false},
+	}
+	for _, tc := range cases {
+		err := ValidatePasswordStrength(tc.password, "ada.byron@example.com", "Augusta Lovelace")
+		if tc.ok != (err == nil) || (err != nil && !errors.Is(err, domain.ErrWeakPassword)) {
+			t.Errorf("ValidatePasswordStrength(%q) = %v, want ok=%v", tc.password, err, tc.ok)
+		}
+	}
+	if err := ValidatePasswordStrength("Lovelace-1815!", "", ""); err != nil {
+		t.Errorf("without an email or name: %v", err)
+	}
+}
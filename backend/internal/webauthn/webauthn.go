@@ -0,0 +1,216 @@
+// Package webauthn verifies passkey registrations and sign-ins. It covers
+// what the server needs for "none" attestation: the client data, the
+// authenticator data and the assertion signature. Clients send the
+// credential's public key as the DER SubjectPublicKeyInfo returned by
+// AuthenticatorAttestationResponse.getPublicKey(), so no CBOR is parsed;
+// attestation statements are not checked.
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrVerification is wrapped by every error reporting a credential, client
+// data or signature that does not check out.
+var ErrVerification = errors.New("webauthn verification failed")
+
+// ChallengeSize is the length of the random challenges NewChallenge makes.
+const ChallengeSize = 32
+
+// COSE algorithm identifiers of the key types that can be verified; clients
+// pass them as pubKeyCredParams.
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// Algorithms lists the supported COSE algorithms, most preferred first.
+var Algorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// Authenticator data flags.
+const (
+	FlagUserPresent  = 0x01
+	FlagUserVerified = 0x04
+	FlagAttested     = 0x40
+)
+
+// RelyingParty is the site credentials are scoped to. ID is its domain;
+// Origins are the exact origins client data may come from.
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+// NewChallenge returns a fresh random challenge.
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, ChallengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, fmt.Errorf("generate webauthn challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// AuthenticatorData is the part of authenticator data the server checks.
+// CredentialID is only present in registrations.
+type AuthenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	CredentialID []byte
+}
+
+// ParseAuthenticatorData decodes the fixed header and, when the attested
+// flag is set, the credential ID that follows it.
+func ParseAuthenticatorData(data []byte) (AuthenticatorData, error) {
+	if len(data) < 37 {
+		return AuthenticatorData{}, fmt.Errorf("%w: authenticator data too short", ErrVerification)
+	}
+	ad := AuthenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if ad.Flags&FlagAttested != 0 {
+		// AAGUID (16 bytes), then a big-endian length and the credential ID.
+		rest := data[37:]
+		if len(rest) < 18 {
+			return AuthenticatorData{}, fmt.Errorf("%w: attested credential data too short", ErrVerification)
+		}
+		n := int(binary.BigEndian.Uint16(rest[16:18]))
+		if len(rest) < 18+n {
+			return AuthenticatorData{}, fmt.Errorf("%w: credential id truncated", ErrVerification)
+		}
+		ad.CredentialID = rest[18 : 18+n]
+	}
+	return ad, nil
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// VerifyRegistration checks a new credential created in answer to
+// challenge and returns its initial signature counter. The user must have
+// been verified (e.g. by PIN or biometrics), since a passkey may be the
+// account's only credential.
+func (rp RelyingParty) VerifyRegistration(challenge, credentialID, clientDataJSON, authenticatorData, publicKey []byte) (uint32, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return 0, err
+	}
+	ad, err := rp.verifyAuthenticatorData(authenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	if ad.Flags&FlagAttested == 0 || !bytes.Equal(ad.CredentialID, credentialID) {
+		return 0, fmt.Errorf("%w: credential id does not match the authenticator data", ErrVerification)
+	}
+	if _, err := parsePublicKey(publicKey); err != nil {
+		return 0, err
+	}
+	return ad.SignCount, nil
+}
+
+// VerifyAssertion checks a sign-in signed by the credential with publicKey
+// in answer to challenge and returns the new signature counter. A counter
+// that did not move forward means the credential was cloned.
+func (rp RelyingParty) VerifyAssertion(challenge, clientDataJSON, authenticatorData, signature, publicKey []byte, storedSignCount uint32) (uint32, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+	ad, err := rp.verifyAuthenticatorData(authenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(slices.Clip(authenticatorData), clientDataHash[:]...)
+	if !verifySignature(key, signed, signature) {
+		return 0, fmt.Errorf("%w: bad signature", ErrVerification)
+	}
+	if (ad.SignCount != 0 || storedSignCount != 0) && ad.SignCount <= storedSignCount {
+		return 0, fmt.Errorf("%w: signature counter went backwards", ErrVerification)
+	}
+	return ad.SignCount, nil
+}
+
+func (rp RelyingParty) verifyClientData(raw []byte, wantType string, challenge []byte) error {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return fmt.Errorf("%w: decode client data: %v", ErrVerification, err)
+	}
+	if cd.Type != wantType {
+		return fmt.Errorf("%w: client data type %q, want %q", ErrVerification, cd.Type, wantType)
+	}
+	got, err := base64.RawURLEncoding.DecodeString(cd.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return fmt.Errorf("%w: challenge mismatch", ErrVerification)
+	}
+	if !slices.Contains(rp.Origins, cd.Origin) {
+		return fmt.Errorf("%w: origin %q not allowed", ErrVerification, cd.Origin)
+	}
+	return nil
+}
+
+func (rp RelyingParty) verifyAuthenticatorData(raw []byte) (AuthenticatorData, error) {
+	ad, err := ParseAuthenticatorData(raw)
+	if err != nil {
+		return AuthenticatorData{}, err
+	}
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if subtle.ConstantTimeCompare(ad.RPIDHash, rpIDHash[:]) != 1 {
+		return AuthenticatorData{}, fmt.Errorf("%w: relying party id mismatch", ErrVerification)
+	}
+	if ad.Flags&FlagUserPresent == 0 || ad.Flags&FlagUserVerified == 0 {
+		return AuthenticatorData{}, fmt.Errorf("%w: user not verified", ErrVerification)
+	}
+	return ad, nil
+}
+
+func parsePublicKey(der []byte) (any, error) {
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%w: parse public key: %v", ErrVerification, err)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey, *rsa.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported public key type %T", ErrVerification, key)
+	}
+}
+
+func verifySignature(key any, message, signature []byte) bool {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		return ecdsa.VerifyASN1(k, digest[:], signature)
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, message, signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(message)
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) == nil
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package webauthn_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"pmv2/backend/internal/webauthn"
+)
+
+var rp = webauthn.RelyingParty{ID: "example.com", Name: "Example", Origins: []string{"https://example.com"}}
+
+// authenticator is a software passkey.
+type authenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	counter      uint32
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return &authenticator{key: key, credentialID: []byte("credential-1")}
+}
+
+func (a *authenticator) publicKey(t *testing.T) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&a.key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	return der
+}
+
+func clientData(typ string, challenge []byte, origin string) []byte {
+	b, _ := json.Marshal(map[string]string{
+		"type":      typ,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    origin,
+	})
+	return b
+}
+
+func (a *authenticator) authData(rpID string, flags byte, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.counter)
+	if attested {
+		data = append(data, make([]byte, 16)...) // AAGUID
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+	}
+	return data
+}
+
+func (a *authenticator) sign(t *testing.T, authData, clientDataJSON []byte) []byte {
+	t.Helper()
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return sig
+}
+
+const verified = webauthn.FlagUserPresent | webauthn.FlagUserVerified
+
+func TestVerifyRegistration(t *testing.T) {
+	a := newAuthenticator(t)
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+
+	cd := clientData("webauthn.create", challenge, "https://example.com")
+	if _, err := rp.VerifyRegistration(challenge, a.credentialID, cd, a.authData("example.com", verified|webauthn.FlagAttested, true), a.publicKey(t)); err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		clientData, authData, credentialID []byte
+	}{
+		"wrong type":       {clientData("webauthn.get", challenge, "https://example.com"), a.authData("example.com", verified|webauthn.FlagAttested, true), a.credentialID},
+		"wrong challenge":  {clientData("webauthn.create", []byte("other"), "https://example.com"), a.authData("example.com", verified|webauthn.FlagAttested, true), a.credentialID},
+		"wrong origin":     {clientData("webauthn.create", challenge, "https://evil.example"), a.authData("example.com", verified|webauthn.FlagAttested, true), a.credentialID},
+		"wrong rp id":      {cd, a.authData("evil.example", verified|webauthn.FlagAttested, true), a.credentialID},
+		"not verified":     {cd, a.authData("example.com", webauthn.FlagUserPresent|webauthn.FlagAttested, true), a.credentialID},
+		"other credential": {cd, a.authData("example.com", verified|webauthn.FlagAttested, true), []byte("credential-2")},
+	} {
+		if _, err := rp.VerifyRegistration(challenge, tc.credentialID, tc.clientData, tc.authData, a.publicKey(t)); !errors.Is(err, webauthn.ErrVerification) {
+			t.Errorf("%s: err = %v, want ErrVerification", name, err)
+		}
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	a := newAuthenticator(t)
+	challenge := []byte("0123456789abcdef0123456789abcdef")
+	cd := clientData("webauthn.get", challenge, "https://example.com")
+
+	a.counter = 5
+	authData := a.authData("example.com", verified, false)
+	count, err := rp.VerifyAssertion(challenge, cd, authData, a.sign(t, authData, cd), a.publicKey(t), 4)
+	if err != nil || count != 5 {
+		t.Fatalf("VerifyAssertion = %d, %v; want 5", count, err)
+	}
+
+	if _, err := rp.VerifyAssertion(challenge, cd, authData, a.sign(t, authData, cd), a.publicKey(t), 5); !errors.Is(err, webauthn.ErrVerification) {
+		t.Errorf("replayed counter: err = %v, want ErrVerification", err)
+	}
+	other := newAuthenticator(t)
+	if _, err := rp.VerifyAssertion(challenge, cd, authData, other.sign(t, authData, cd), a.publicKey(t), 0); !errors.Is(err, webauthn.ErrVerification) {
+		t.Errorf("signed by another key: err = %v, want ErrVerification", err)
+	}
+
+	// Authenticators without a counter always report zero.
+	a.counter = 0
+	authData = a.authData("example.com", verified, false)
+	if _, err := rp.VerifyAssertion(challenge, cd, authData, a.sign(t, authData, cd), a.publicKey(t), 0); err != nil {
+		t.Errorf("counterless authenticator: %v", err)
+	}
+}
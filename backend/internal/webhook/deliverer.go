@@ -0,0 +1,202 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/safehttp"
+	"pmv2/backend/internal/util"
+)
+
+type Config struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// MaxAttempts is how many times a delivery is tried before it is moved
+	// to the dead-letter state.
+	MaxAttempts int
+	// Timeout bounds each HTTP attempt.
+	Timeout time.Duration
+	// SecretKey decrypts endpoint signing secrets (see
+	// util.DeriveWebhookSecretKey).
+	SecretKey []byte
+	// AllowPrivateNetworks lets deliveries reach loopback and private
+	// addresses; it is meant for local development only.
+	AllowPrivateNetworks bool
+	// Client sends the deliveries, metered per endpoint owner. It must not
+	// follow redirects. nil builds an unmetered one from Timeout and
+	// AllowPrivateNetworks.
+	Client *safehttp.Client
+}
+
+// maxResponseBytes is how much of an endpoint's response is read; only its
+// status matters.
+const maxResponseBytes = 64 << 10
+
+// Deliverer polls webhook_deliveries and POSTs each due delivery to its
+// endpoint. Failures are retried with exponential backoff; several replicas
+// may run a deliverer concurrently.
+type Deliverer struct {
+	repo   domain.WebhookRepository
+	logger *slog.Logger
+	cfg    Config
+	client *safehttp.Client
+}
+
+func NewDeliverer(repo domain.WebhookRepository, logger *slog.Logger, cfg Config) *Deliverer {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 2 * time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 50
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	client := cfg.Client
+	if client == nil {
+		// Never follow redirects: the signed request goes to the
+		// registered URL only.
+		client = safehttp.NewClient(safehttp.Config{
+			Timeout:              cfg.Timeout,
+			MaxResponseBytes:     maxResponseBytes,
+			AllowPrivateNetworks: cfg.AllowPrivateNetworks,
+		})
+	}
+	return &Deliverer{
+		repo:   repo,
+		logger: logger,
+		cfg:    cfg,
+		client: client,
+	}
+}
+
+// Run polls until ctx is cancelled.
+func (d *Deliverer) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		deliveries, err := d.repo.ClaimDueDeliveries(context.WithoutCancel(ctx), d.cfg.BatchSize, 2*d.cfg.Timeout)
+		if err != nil {
+			d.logger.Error("webhook claim failed", slog.Any("error", err))
+		}
+		for _, delivery := range deliveries {
+			d.deliver(context.WithoutCancel(ctx), delivery)
+		}
+
+		wait := d.cfg.PollInterval
+		if len(deliveries) == d.cfg.BatchSize {
+			wait = 0
+		}
+		timer.Reset(wait)
+	}
+}
+
+func (d *Deliverer) deliver(ctx context.Context, delivery domain.WebhookDelivery) {
+	started := time.Now()
+	status, err := d.post(ctx, delivery)
+
+	attempt := domain.WebhookDeliveryAttempt{
+		StatusCode:  status,
+		DurationMS:  int(time.Since(started).Milliseconds()),
+		AttemptedAt: started.UTC(),
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	if recErr := d.repo.RecordAttempt(ctx, delivery.ID, attempt); recErr != nil {
+		d.logger.Error("failed to record webhook attempt", slog.String("delivery_id", delivery.ID), slog.Any("error", recErr))
+	}
+
+	if err == nil {
+		if markErr := d.repo.MarkDelivered(ctx, delivery.ID); markErr != nil {
+			d.logger.Error("failed to mark webhook delivered", slog.String("delivery_id", delivery.ID), slog.Any("error", markErr))
+		}
+		return
+	}
+
+	if delivery.Attempts >= d.cfg.MaxAttempts {
+		d.logger.Warn("webhook delivery dead-lettered",
+			slog.String("delivery_id", delivery.ID),
+			slog.String("endpoint_id", delivery.EndpointID),
+			slog.Int("attempts", delivery.Attempts),
+			slog.Any("error", err),
+		)
+		if markErr := d.repo.MarkDead(ctx, delivery.ID, err.Error()); markErr != nil {
+			d.logger.Error("failed to dead-letter webhook delivery", slog.String("delivery_id", delivery.ID), slog.Any("error", markErr))
+		}
+		return
+	}
+
+	next := time.Now().UTC().Add(retryDelay(delivery.Attempts))
+	if markErr := d.repo.MarkRetry(ctx, delivery.ID, err.Error(), next); markErr != nil {
+		d.logger.Error("failed to schedule webhook retry", slog.String("delivery_id", delivery.ID), slog.Any("error", markErr))
+	}
+}
+
+// post sends the delivery and returns the response status (0 if none).
+func (d *Deliverer) post(ctx context.Context, delivery domain.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	secret, err := util.DecryptWebhookSecret(delivery.Endpoint.SecretEnc, d.cfg.SecretKey)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pmv2-webhooks/1")
+	req.Header.Set(EventHeader, string(delivery.EventType))
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(SignatureHeader, Sign(secret, time.Now(), body))
+
+	resp, err := d.client.Do(delivery.Endpoint.UserID, req)
+	if err != nil {
+		var urlErr interface{ Timeout() bool }
+		if errors.As(err, &urlErr) && urlErr.Timeout() {
+			return 0, errors.New("request timed out")
+		}
+		return 0, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryDelay doubles from 30s after each failed attempt and caps at six hours.
+func retryDelay(attempts int) time.Duration {
+	const ceiling = 6 * time.Hour
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > 12 {
+		return ceiling
+	}
+	delay := 30 * time.Second << (attempts - 1)
+	if delay > ceiling {
+		return ceiling
+	}
+	return delay
+}
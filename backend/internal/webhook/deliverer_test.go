@@ -0,0 +1,172 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pmv2/backend/internal/domain"
+	"pmv2/backend/internal/safehttp"
+	"pmv2/backend/internal/util"
+)
+
+// fakeWebhookRepo records what the deliverer reports back.
+type fakeWebhookRepo struct {
+	domain.WebhookRepository
+	attempts  []domain.WebhookDeliveryAttempt
+	delivered []string
+	retryAt   map[string]time.Time
+	dead      map[string]string
+}
+
+func newFakeWebhookRepo() *fakeWebhookRepo {
+	return &fakeWebhookRepo{retryAt: make(map[string]time.Time), dead: make(map[string]string)}
+}
+
+func (r *fakeWebhookRepo) RecordAttempt(ctx context.Context, deliveryID string, attempt domain.WebhookDeliveryAttempt) error {
+	r.attempts = append(r.attempts, attempt)
+	return nil
+}
+func (r *fakeWebhookRepo) MarkDelivered(ctx context.Context, deliveryID string) error {
+	r.delivered = append(r.delivered, deliveryID)
+	return nil
+}
+func (r *fakeWebhookRepo) MarkRetry(ctx context.Context, deliveryID string, lastError string, nextAttemptAt time.Time) error {
+	r.retryAt[deliveryID] = nextAttemptAt
+	return nil
+}
+func (r *fakeWebhookRepo) MarkDead(ctx context.Context, deliveryID string, lastError string) error {
+	r.dead[deliveryID] = lastError
+	return nil
+}
+
+var testSecretKey = util.DeriveWebhookSecretKey("pepper-test")
+
+func testDelivery(t *testing.T, url string, attempts int) domain.WebhookDelivery {
+	t.Helper()
+	secretEnc, err := util.EncryptWebhookSecret("whsec_test", testSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return domain.WebhookDelivery{
+		ID:        "delivery-1",
+		EventType: "vault_item_created",
+		Payload:   json.RawMessage(`{"id":"evt_1"}`),
+		Attempts:  attempts,
+		Endpoint:  domain.WebhookEndpoint{URL: url, SecretEnc: secretEnc},
+	}
+}
+
+func newTestDeliverer(repo domain.WebhookRepository, allowPrivate bool) *Deliverer {
+	return NewDeliverer(repo, slog.New(slog.NewTextHandler(io.Discard, nil)), Config{
+		MaxAttempts:          3,
+		Timeout:              time.Second,
+		SecretKey:            testSecretKey,
+		AllowPrivateNetworks: allowPrivate,
+	})
+}
+
+func TestDeliverSignsRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify("whsec_test", r.Header.Get(SignatureHeader), body, DefaultTolerance, time.Now()); err != nil {
+			t.Errorf("receiver rejected signature: %v", err)
+		}
+		if r.Header.Get(EventHeader) != "vault_item_created" || r.Header.Get(DeliveryHeader) != "delivery-1" {
+			t.Errorf("headers = %v", r.Header)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	repo := newFakeWebhookRepo()
+	newTestDeliverer(repo, true).deliver(context.Background(), testDelivery(t, srv.URL, 1))
+
+	if len(repo.delivered) != 1 {
+		t.Fatalf("delivered = %v, retry = %v", repo.delivered, repo.retryAt)
+	}
+	if len(repo.attempts) != 1 || repo.attempts[0].StatusCode != http.StatusNoContent {
+		t.Fatalf("attempts = %+v", repo.attempts)
+	}
+}
+
+func TestDeliverRetriesThenDeadLetters(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	repo := newFakeWebhookRepo()
+	d := newTestDeliverer(repo, true)
+
+	before := time.Now()
+	d.deliver(context.Background(), testDelivery(t, srv.URL, 2))
+	next, ok := repo.retryAt["delivery-1"]
+	if !ok {
+		t.Fatal("failed delivery was not scheduled for retry")
+	}
+	if wait := next.Sub(before); wait < time.Minute || wait > time.Minute+5*time.Second {
+		t.Fatalf("retry in %s, want about 1m after the second attempt", wait)
+	}
+
+	d.deliver(context.Background(), testDelivery(t, srv.URL, 3))
+	if msg := repo.dead["delivery-1"]; !strings.Contains(msg, "502") {
+		t.Fatalf("dead letter = %q, want the 502", msg)
+	}
+	if len(repo.delivered) != 0 {
+		t.Fatalf("delivered = %v", repo.delivered)
+	}
+}
+
+func TestDeliverDoesNotFollowRedirects(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("redirect was followed")
+	}))
+	defer target.Close()
+	srv := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer srv.Close()
+
+	repo := newFakeWebhookRepo()
+	newTestDeliverer(repo, true).deliver(context.Background(), testDelivery(t, srv.URL, 1))
+	if _, ok := repo.retryAt["delivery-1"]; !ok {
+		t.Fatal("a redirect must count as a failed attempt")
+	}
+}
+
+func TestDeliverRefusesPrivateAddresses(t *testing.T) {
+	hit := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hit = true }))
+	defer srv.Close()
+
+	repo := newFakeWebhookRepo()
+	newTestDeliverer(repo, false).deliver(context.Background(), testDelivery(t, srv.URL, 1))
+	if hit {
+		t.Fatal("request reached a loopback endpoint")
+	}
+	if len(repo.attempts) != 1 || !strings.Contains(repo.attempts[0].Error, safehttp.ErrForbiddenAddress.Error()) {
+		t.Fatalf("attempts = %+v", repo.attempts)
+	}
+}
+
+func TestPostRejectsUndecryptableSecret(t *testing.T) {
+	delivery := testDelivery(t, "https://example.com/hook", 1)
+	delivery.Endpoint.SecretEnc = []byte("garbage")
+	if _, err := newTestDeliverer(newFakeWebhookRepo(), false).post(context.Background(), delivery); err == nil {
+		t.Fatal("post succeeded with a corrupt secret")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	cases := map[int]time.Duration{0: 30 * time.Second, 1: 30 * time.Second, 2: time.Minute, 5: 8 * time.Minute, 12: 6 * time.Hour, 40: 6 * time.Hour}
+	for attempts, want := range cases {
+		if got := retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSignVerifyRoundTrip(t *testing.T) {
+	now := time.Unix(1767323045, 0)
+	body := []byte(`{"id":"evt_1","type":"vault_item_created"}`)
+	header := Sign("whsec_test", now, body)
+
+	if err := Verify("whsec_test", header, body, DefaultTolerance, now.Add(time.Minute)); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		now    time.Time
+		want   error
+	}{
+		{"tampered body", "whsec_test", header, []byte(`{"id":"evt_2"}`), now, ErrInvalidSignature},
+		{"wrong secret", "whsec_other", header, body, now, ErrInvalidSignature},
+		{"replayed too late", "whsec_test", header, body, now.Add(DefaultTolerance + time.Second), ErrStaleSignature},
+		{"from the future", "whsec_test", header, body, now.Add(-DefaultTolerance - time.Second), ErrStaleSignature},
+		{"missing header", "whsec_test", "", body, now, ErrMissingSignature},
+		{"no timestamp", "whsec_test", "v1=00ff", body, now, ErrInvalidSignature},
+		{"bad timestamp", "whsec_test", "t=soon,v1=00ff", body, now, ErrInvalidSignature},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := Verify(tc.secret, tc.header, tc.body, DefaultTolerance, tc.now); !errors.Is(err, tc.want) {
+				t.Fatalf("Verify = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestVerifyAcceptsAnyMatchingSignature(t *testing.T) {
+	// Receivers rotating secrets may see several v1 values.
+	now := time.Unix(1767323045, 0)
+	body := []byte(`{}`)
+	header := Sign("whsec_new", now, body) + ",v1=" + "deadbeef"
+
+	if err := Verify("whsec_new", header, body, DefaultTolerance, now); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+}
+
+func TestSignMatchesDocumentedScheme(t *testing.T) {
+	// Receivers in other languages compute HMAC-SHA256(secret, "<t>.<body>").
+	got := Sign("secret", time.Unix(1700000000, 0), []byte("body"))
+	want := "t=1700000000,v1=42ac6f0448c1d9c3e1e82b9726248f58fef84afffcbad5188246e96070e0ea46"
+	if got != want {
+		t.Fatalf("Sign = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// Manager owns the process's background goroutines so they can be stopped
+// together on shutdown. Every worker receives a context that is cancelled
+// when Shutdown is called; Shutdown then waits for all of them to return.
+type Manager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	logger *slog.Logger
+}
+
+func NewManager(logger *slog.Logger) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{ctx: ctx, cancel: cancel, logger: logger}
+}
+
+// Go starts fn in its own goroutine. fn must return promptly once ctx is
+// done; work already in progress may finish first. A panicking worker is
+// logged instead of crashing the process.
+func (m *Manager) Go(name string, fn func(ctx context.Context)) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer func() {
+			if rec := recover(); rec != nil {
+				m.logger.Error("background worker panicked", slog.String("worker", name), slog.Any("panic", rec))
+			}
+		}()
+
+		m.logger.Debug("background worker started", slog.String("worker", name))
+		fn(m.ctx)
+		m.logger.Debug("background worker stopped", slog.String("worker", name))
+	}()
+}
+
+// Shutdown cancels every worker and waits for them to drain, giving up when
+// ctx expires. It returns ctx.Err() if some workers were still running.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestManager() *Manager {
+	return NewManager(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestShutdownCancelsAndWaits(t *testing.T) {
+	m := newTestManager()
+	var stopped atomic.Int32
+	for range 3 {
+		m.Go("loop", func(ctx context.Context) {
+			<-ctx.Done()
+			stopped.Add(1)
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := m.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if got := stopped.Load(); got != 3 {
+		t.Fatalf("%d workers stopped, want 3", got)
+	}
+}
+
+func TestShutdownGivesUpOnStuckWorker(t *testing.T) {
+	m := newTestManager()
+	release := make(chan struct{})
+	defer close(release)
+	m.Go("stuck", func(ctx context.Context) { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := m.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Shutdown = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestPanickingWorkerDoesNotCrash(t *testing.T) {
+	m := newTestManager()
+	m.Go("boom", func(ctx context.Context) { panic("boom") })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := m.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
@@ -22,6 +22,17 @@ export interface LoginRequest {
     device_name?: string;
     totp_code?: string;
     recovery_code?: string;
+    passkey_assertion?: PasskeyAssertion;
+}
+
+// PasskeyAssertion answers a challenge from POST
+// /auth/passkeys/second-factor/begin; binary values are unpadded base64url.
+export interface PasskeyAssertion {
+    challenge_id: string;
+    credential_id: string;
+    client_data_json: string;
+    authenticator_data: string;
+    signature: string;
 }
 
 export interface LoginResponse {
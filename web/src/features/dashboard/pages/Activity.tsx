@@ -98,6 +98,38 @@ const getEventDetails = (event: AuditEvent) => {
       title: "Logged Out",
       text: "Session terminated successfully",
     },
+    auth_session_revoked: {
+      icon: <LogOut size={18} />,
+      importance: "info",
+      label: "Authentication",
+      title: "Sessions Signed Out",
+      text: data.revoked
+        ? `Signed out ${data.revoked} other session(s)`
+        : "A session was signed out remotely",
+    },
+    recovery_setup: {
+      icon: <FileKey size={18} />,
+      importance: "success",
+      label: "Account Recovery",
+      title: data.rotated ? "Recovery Key Replaced" : "Recovery Key Created",
+      text: data.rotated
+        ? "A new recovery key replaced the previous one"
+        : "Account recovery was set up with a recovery key",
+    },
+    recovery_failed: {
+      icon: <ShieldAlert size={18} />,
+      importance: "critical",
+      label: "Security Alert",
+      title: "Failed Recovery Attempt",
+      text: "Someone tried to recover your account with a wrong recovery key",
+    },
+    auth_password_reset_requested: {
+      icon: <FileKey size={18} />,
+      importance: "warning",
+      label: "Account Recovery",
+      title: "Password Reset Requested",
+      text: "A password reset link was emailed to you",
+    },
     vault_item_created: {
       icon: <Key size={18} />,
       importance: "info",
@@ -119,6 +151,34 @@ const getEventDetails = (event: AuditEvent) => {
       title: "Item Removed",
       text: `A vault entry was permanently deleted`,
     },
+    vault_attachment_added: {
+      icon: <FileKey size={18} />,
+      importance: "info",
+      label: "Vault Action",
+      title: "Attachment Added",
+      text: `An encrypted file was attached to a vault entry`,
+    },
+    vault_attachment_deleted: {
+      icon: <Trash2 size={18} />,
+      importance: "critical",
+      label: "Vault Action",
+      title: "Attachment Removed",
+      text: `A file was removed from a vault entry`,
+    },
+    vault_exported: {
+      icon: <ShieldAlert size={18} />,
+      importance: "warning",
+      label: "Vault Action",
+      title: "Vault Exported",
+      text: `${data.items ?? "All"} encrypted vault entries were downloaded as ${String(data.format || "json").toUpperCase()}`,
+    },
+    vault_imported: {
+      icon: <FileKey size={18} />,
+      importance: "info",
+      label: "Vault Action",
+      title: "Vault Imported",
+      text: `${data.items ?? 0} entries were imported from a ${String(data.source || "").split("_")[0] || "password manager"} export${Number(data.skipped) > 0 ? `, ${data.skipped} skipped` : ""}`,
+    },
     sharing_item_shared: {
       icon: <Share2 size={18} />,
       importance: "success",
@@ -133,6 +193,13 @@ const getEventDetails = (event: AuditEvent) => {
       title: "Access Revoked",
       text: `Terminated shared access for a vault item`,
     },
+    sharing_permissions_updated: {
+      icon: <Share2 size={18} />,
+      importance: "info",
+      label: "Sharing",
+      title: "Access Changed",
+      text: `Changed a member's access to '${data.permissions || "read"}'`,
+    },
     family_invite_sent: {
       icon: <UserPlus size={18} />,
       importance: "info",
@@ -13,15 +13,15 @@ import type {
 export const sharingService = {
   // User keys
   upsertUserKeys(req: UpsertUserKeysRequest) {
-    return request<{ status: string }>("PUT", "/users/keys", req);
+    return request<{ status: string }>("POST", "/keys", req);
   },
   getMyKeys() {
-    return request<UserKeysResponse>("GET", "/users/keys");
+    return request<UserKeysResponse>("GET", "/keys");
   },
   getPublicKey(email: string) {
     return request<UserPublicKeyResponse>(
       "GET",
-      `/users/keys/lookup?email=${encodeURIComponent(email)}`
+      `/keys/lookup?email=${encodeURIComponent(email)}`
     );
   },
 
@@ -2,12 +2,14 @@
 
 export interface UpsertUserKeysRequest {
   public_key_x25519: string;
+  public_key_ed25519?: string;
   encrypted_private_keys: string;
   nonce: string;
 }
 
 export interface UserKeysResponse {
   public_key_x25519: string;
+  public_key_ed25519?: string;
   encrypted_private_keys: string;
   nonce: string;
   has_keys: boolean;
@@ -17,6 +19,7 @@ export interface UserPublicKeyResponse {
   user_id: string;
   email: string;
   public_key_x25519: string;
+  public_key_ed25519?: string;
 }
 
 export interface ShareItemRequest {
@@ -11,12 +11,17 @@ const ERROR_MESSAGES: Record<string, string> = {
   invalid_credentials: "Invalid email or password.",
   email_taken: "This email is already registered.",
   weak_password: "Password does not meet the security requirements.",
+  breached_password: "This password has appeared in a data breach. Please choose a different one.",
   unauthorized: "Your session has expired. Please log in again.",
+  merge_same_account: "You are already signed in to this account.",
+  invalid_merge_session: "Sign in to the account to merge again, then retry.",
+  merge_admin_account: "An admin account cannot be merged into another.",
+  share_rewrap_incomplete: "Some shared items could not be moved. Refresh and try again.",
 
   // MFA
   mfa_required: "Multi-factor authentication is required.",
   invalid_mfa: "Invalid authentication code. Please try again.",
-  invalid_mfa_input: "Provide either a TOTP code or a recovery code, not both.",
+  invalid_mfa_input: "Provide only one of a TOTP code, a recovery code or a passkey.",
   mfa_rate_limited: "Too many failed attempts. Please wait and try again.",
   totp_not_initialized: "TOTP has not been set up yet. Complete setup first.",
   totp_not_enabled: "TOTP is not enabled on this account.",
@@ -26,12 +31,18 @@ const ERROR_MESSAGES: Record<string, string> = {
   recovery_not_setup: "Account recovery has not been configured.",
   recovery_cooldown: "A recovery was attempted too recently. Please wait.",
   invalid_recovery_token: "The recovery link has expired. Start over.",
+  password_reset_invalid: "The password reset link is invalid, used or expired. Request a new one.",
 
   // Vault
   invalid_vault_payload: "The vault data is corrupted or in an unexpected format.",
   not_found: "The requested item could not be found.",
   empty_items: "No items were provided.",
   too_many_items: "Too many items in a single request (max 500).",
+  invalid_import_source: "That export format cannot be imported.",
+  unsupported_import_kind: "Only logins, cards and notes can be imported.",
+  invalid_import_row: "Each imported row needs its own positive row number.",
+  attachment_too_large: "The file is larger than the maximum attachment size.",
+  attachment_limit: "This item already has the maximum number of attachments.",
 
   // Generic
   internal_error: "Something went wrong on our end. Please try again later.",